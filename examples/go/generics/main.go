@@ -0,0 +1,57 @@
+// Type parameters: generic helpers over slices and a generic stack.
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// Map applies f to every element of s.
+func Map[T, U any](s []T, f func(T) U) []U {
+	out := make([]U, 0, len(s))
+	for _, v := range s {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+// Max returns the largest element of a non-empty slice.
+func Max[T cmp.Ordered](s []T) T {
+	m := s[0]
+	for _, v := range s[1:] {
+		m = max(m, v)
+	}
+	return m
+}
+
+// Stack is a last-in, first-out collection of any element type.
+type Stack[T any] struct {
+	items []T
+}
+
+func (s *Stack[T]) Push(v T) { s.items = append(s.items, v) }
+
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+func main() {
+	words := []string{"gopher", "generic", "go"}
+	fmt.Println(Map(words, strings.ToUpper))
+	fmt.Println(Map(words, func(s string) int { return len(s) }))
+	fmt.Println(Max([]int{3, 41, 7}), Max(words))
+
+	var s Stack[float64]
+	s.Push(1.5)
+	s.Push(2.5)
+	for v, ok := s.Pop(); ok; v, ok = s.Pop() {
+		fmt.Println("popped", v)
+	}
+}
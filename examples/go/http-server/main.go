@@ -0,0 +1,55 @@
+// A small JSON API built on net/http's method and wildcard routing.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+type todo struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Done  bool   `json:"done"`
+}
+
+type store struct {
+	mu    sync.Mutex
+	todos []todo
+}
+
+func (s *store) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.todos)
+}
+
+func (s *store) add(w http.ResponseWriter, r *http.Request) {
+	var t todo
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil || t.Title == "" {
+		http.Error(w, "expected {\"title\": \"...\"}", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	t.ID = len(s.todos) + 1
+	s.todos = append(s.todos, t)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func main() {
+	s := &store{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /todos", s.list)
+	mux.HandleFunc("POST /todos", s.add)
+
+	log.Println("listening on :8080")
+	log.Fatal(http.ListenAndServe(":8080", mux))
+}
@@ -0,0 +1,51 @@
+// A fixed pool of goroutines works through a queue of jobs, and the
+// results are collected over a channel.
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type result struct {
+	job, worker int
+	square      int
+}
+
+func worker(id int, jobs <-chan int, results chan<- result, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for j := range jobs {
+		time.Sleep(10 * time.Millisecond) // simulate work
+		results <- result{job: j, worker: id, square: j * j}
+	}
+}
+
+func main() {
+	jobs := make(chan int)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for w := 1; w <= 3; w++ {
+		wg.Add(1)
+		go worker(w, jobs, results, &wg)
+	}
+
+	go func() {
+		for j := 1; j <= 9; j++ {
+			jobs <- j
+		}
+		close(jobs)
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	sum := 0
+	for r := range results {
+		fmt.Printf("worker %d: %d² = %d\n", r.worker, r.job, r.square)
+		sum += r.square
+	}
+	fmt.Println("sum of squares:", sum)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"slices"
+	"strings"
+)
+
+// maxDepth bounds how deep the calls of a handler passing on its
+// ResponseWriter or Request are followed.
+const maxDepth = 4
+
+// analysis reads what the handler of an operation does.
+type analysis struct {
+	g     *generator
+	op    *operation
+	seen  map[*ast.FuncDecl]bool
+	depth int
+	// contentType is the last Content-Type set on the response, and raw
+	// whether it is written other than as JSON.
+	contentType string
+	raw         bool
+	rawBody     bool
+	multipart   bool
+	// optionalBody is set by handlers checking whether there is a body.
+	optionalBody bool
+}
+
+// skipHeaders are the request headers that are not parameters of an
+// operation's own.
+var skipHeaders = map[string]bool{
+	"Accept": true, "Accept-Encoding": true, "Authorization": true, "Connection": true,
+	"Content-Type": true, "Cookie": true, "Origin": true, "Upgrade": true,
+	"User-Agent": true, "X-Forwarded-For": true, "X-Forwarded-Proto": true,
+	"X-Csrf-Token": true, "X-Request-Id": true,
+}
+
+// skipQuery are the query parameters that are not an operation's own:
+// access_token authenticates WebSockets, which cannot send headers.
+var skipQuery = map[string]bool{"access_token": true}
+
+// walk reads n, following the calls it makes with the ResponseWriter or
+// Request.
+func (a *analysis) walk(n ast.Node) {
+	if n == nil {
+		return
+	}
+	g := a.g
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if isPointerTo(g.info.TypeOf(n.X), "net/http", "Request") {
+				switch n.Sel.Name {
+				case "Body":
+					a.rawBody = true
+				case "ContentLength":
+					a.optionalBody = true
+				}
+			}
+			return true
+		case *ast.CallExpr:
+			a.call(n)
+		}
+		return true
+	})
+}
+
+func (a *analysis) call(call *ast.CallExpr) {
+	g := a.g
+	fn := g.callee(call)
+	if fn == nil {
+		return
+	}
+	args := call.Args
+	httpx := g.module + "/internal/httpx"
+	switch fn.FullName() {
+	case httpx + ".JSON":
+		if len(args) == 3 {
+			a.json(g.status(args[1]), g.schemaOfExpr(args[2]))
+		}
+		return
+	case httpx + ".Error", httpx + ".Errorf":
+		if len(args) >= 2 {
+			a.error(g.status(args[1]))
+		}
+		return
+	case httpx + ".DecodeJSON":
+		if len(args) >= 3 {
+			t := g.info.TypeOf(args[2])
+			if p, ok := types.Unalias(t).(*types.Pointer); ok {
+				if _, isIface := p.Elem().Underlying().(*types.Interface); !isIface {
+					a.op.body = &body{contentType: "application/json", schema: g.schemaOfExpr(args[2])}
+				}
+			}
+		}
+		return
+	case "net/http.Error":
+		if len(args) == 3 {
+			a.response(g.status(args[2])).raw = appendNew(a.response(g.status(args[2])).raw, "text/plain")
+		}
+		return
+	case "net/http.Redirect":
+		if len(args) == 4 {
+			a.response(g.status(args[3]))
+		}
+		return
+	case "(net/http.ResponseWriter).WriteHeader":
+		if len(args) == 1 {
+			a.response(g.status(args[0]))
+		}
+		return
+	case "(net/http.ResponseWriter).Write", "net/http.ServeContent", "net/http.ServeFile", "io.Copy", "io.CopyN", "io.WriteString", "fmt.Fprint", "fmt.Fprintf", "fmt.Fprintln", "encoding/json.NewEncoder":
+		if fn.FullName() == "(net/http.ResponseWriter).Write" || len(args) > 0 && isNamed(g.info.TypeOf(args[0]), "net/http", "ResponseWriter") {
+			a.raw = true
+		}
+		return
+	case "(net/url.Values).Get", "(net/url.Values).Has", "(*net/http.Request).FormValue":
+		if len(args) == 1 {
+			if name, ok := g.stringValue(args[0]); ok && !skipQuery[name] {
+				a.param(name, "query")
+			}
+		}
+		return
+	case "(*net/http.Request).ParseMultipartForm", "(*net/http.Request).MultipartReader", "(*net/http.Request).FormFile":
+		a.multipart = true
+		return
+	case "(net/http.Header).Get", "(net/http.Header).Values":
+		if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok && len(args) == 1 {
+			if h, ok := ast.Unparen(sel.X).(*ast.SelectorExpr); ok && isPointerTo(g.info.TypeOf(h.X), "net/http", "Request") {
+				if name, ok := g.stringValue(args[0]); ok && !skipHeaders[canonicalHeader(name)] && !strings.HasPrefix(canonicalHeader(name), "Sec-Websocket-") {
+					a.param(canonicalHeader(name), "header")
+				}
+			}
+		}
+		return
+	case "(net/http.Header).Set":
+		if len(args) == 2 {
+			if name, ok := g.stringValue(args[0]); ok && canonicalHeader(name) == "Content-Type" {
+				if v, ok := g.stringValue(args[1]); ok {
+					a.contentType, _, _ = strings.Cut(v, ";")
+				}
+			}
+		}
+		return
+	}
+	if fn.Pkg() == nil {
+		return
+	}
+	switch fn.Pkg().Path() {
+	case g.module + "/internal/ws":
+		if fn.Name() == "Upgrade" {
+			a.op.websocket = true
+		}
+		return
+	case httpx:
+		return
+	}
+	// Follow the module's functions the handler hands its ResponseWriter
+	// or Request to.
+	decl := g.decls[fn.Origin()]
+	if decl == nil || a.seen[decl] || a.depth >= maxDepth || !passesRequest(g, call) {
+		return
+	}
+	a.seen[decl] = true
+	a.depth++
+	a.walk(decl.Body)
+	a.depth--
+}
+
+// passesRequest reports whether call has a ResponseWriter or Request
+// argument.
+func passesRequest(g *generator, call *ast.CallExpr) bool {
+	for _, arg := range call.Args {
+		t := g.info.TypeOf(arg)
+		if isNamed(t, "net/http", "ResponseWriter") || isPointerTo(t, "net/http", "Request") {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *analysis) response(status int) *response {
+	r := a.op.responses[status]
+	if r == nil {
+		r = &response{}
+		a.op.responses[status] = r
+	}
+	return r
+}
+
+func (a *analysis) json(status int, s *schema) {
+	if status == 0 {
+		status = 200
+	}
+	r := a.response(status)
+	for _, have := range r.json {
+		if have.equal(s) {
+			return
+		}
+	}
+	r.json = append(r.json, s)
+}
+
+func (a *analysis) error(status int) {
+	if status == 0 {
+		return // covered by the default response
+	}
+	a.response(status).error = true
+}
+
+func (a *analysis) param(name, in string) {
+	for _, p := range a.op.params {
+		if p.name == name && p.in == in {
+			return
+		}
+	}
+	a.op.params = append(a.op.params, param{name: name, in: in})
+}
+
+// finish records the raw responses and request bodies found.
+func (a *analysis) finish() {
+	op := a.op
+	if a.raw && !op.websocket {
+		switch ct := a.contentType; ct {
+		case "application/json":
+			a.json(200, anySchema)
+		case "":
+			ct = "application/octet-stream"
+			fallthrough
+		default:
+			r := a.response(200)
+			r.raw = appendNew(r.raw, ct)
+		}
+	}
+	switch {
+	case op.body != nil:
+		op.body.optional = a.optionalBody
+	case a.multipart:
+		op.body = &body{contentType: "multipart/form-data"}
+	case a.rawBody && op.method != "GET" && op.method != "HEAD" && !op.websocket:
+		op.body = &body{contentType: "application/octet-stream"}
+	}
+	if op.websocket {
+		op.responses[101] = &response{}
+	}
+	op.responses[0] = &response{error: true}
+	// An operation answering only errors, such as one whose success is a
+	// bare WriteHeader in a helper not followed, succeeds without a body.
+	ok := false
+	for status := range op.responses {
+		if status > 0 && status < 400 {
+			ok = true
+		}
+	}
+	if !ok {
+		op.responses[200] = &response{}
+	}
+}
+
+// status returns the constant value of a status expression, or 0.
+func (g *generator) status(e ast.Expr) int {
+	tv := g.info.Types[e]
+	if tv.Value == nil || tv.Value.Kind() != constant.Int {
+		return 0
+	}
+	v, ok := constant.Int64Val(tv.Value)
+	if !ok || v < 100 || v > 599 {
+		return 0
+	}
+	return int(v)
+}
+
+func (g *generator) stringValue(e ast.Expr) (string, bool) {
+	tv := g.info.Types[e]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+func canonicalHeader(name string) string {
+	parts := strings.Split(name, "-")
+	for i, p := range parts {
+		parts[i] = upperFirst(strings.ToLower(p))
+	}
+	return strings.Join(parts, "-")
+}
+
+func appendNew(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
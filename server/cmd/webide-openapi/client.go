@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// client returns the source of the generated part of pkg/client: a type
+// per schema and a method per operation, except for WebSockets.
+func (g *generator) client() ([]byte, error) {
+	c := &clientGen{g: g, types: make(map[string]string), imports: map[string]bool{"context": true}}
+	var methods bytes.Buffer
+	for _, op := range g.ops {
+		if op.websocket {
+			continue
+		}
+		c.method(&methods, op)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by webide-openapi; DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	var imports []string
+	for p := range c.imports {
+		imports = append(imports, p)
+	}
+	sort.Strings(imports)
+	for _, p := range imports {
+		fmt.Fprintf(&buf, "\t%q\n", p)
+	}
+	buf.WriteString(")\n\n")
+	buf.Write(methods.Bytes())
+	var names []string
+	for name := range c.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteString(c.types[name])
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format client: %w", err)
+	}
+	return src, nil
+}
+
+// clientGen writes the client's types as the methods need them.
+type clientGen struct {
+	g       *generator
+	types   map[string]string
+	imports map[string]bool
+}
+
+// result is how a method returns the response of its operation.
+type result int
+
+const (
+	resultNone result = iota
+	resultJSON
+	resultRawJSON
+	resultResponse
+)
+
+func (c *clientGen) method(buf *bytes.Buffer, op *operation) {
+	name := op.id
+	var args, pathArgs []string
+	args = append(args, "ctx context.Context")
+
+	// The path, with its parameters escaped.
+	var path []string
+	lit := ""
+	for _, e := range strings.Split(op.path, "/")[1:] {
+		if p, ok := strings.CutPrefix(e, "{"); ok {
+			p = strings.TrimSuffix(p, "}")
+			id := goIdent(p)
+			pathArgs = append(pathArgs, id)
+			path = append(path, fmt.Sprintf("%q", lit+"/"))
+			lit = ""
+			if c.isRest(op, p) {
+				path = append(path, "escapePath("+id+")")
+			} else {
+				c.imports["net/url"] = true
+				path = append(path, "url.PathEscape("+id+")")
+			}
+			continue
+		}
+		lit += "/" + e
+	}
+	if lit != "" || len(path) == 0 {
+		path = append(path, fmt.Sprintf("%q", lit))
+	}
+	if len(pathArgs) > 0 {
+		args = append(args, strings.Join(pathArgs, ", ")+" string")
+	}
+
+	// The body.
+	in := "nil"
+	rawBody := false
+	contentType := ""
+	if b := op.body; b != nil {
+		switch {
+		case b.schema != nil:
+			t := c.goType(b.schema, name+"Request", false)
+			if c.isStruct(t) {
+				t = "*" + t
+			}
+			args = append(args, "body "+t)
+			in = "body"
+		case b.contentType == "multipart/form-data":
+			c.imports["io"] = true
+			args = append(args, "body io.Reader", "contentType string")
+			rawBody, contentType = true, "contentType"
+		default:
+			c.imports["io"] = true
+			args = append(args, "body io.Reader")
+			rawBody, contentType = true, fmt.Sprintf("%q", b.contentType)
+		}
+	}
+
+	// The query and header parameters.
+	params := ""
+	var fields []param
+	for _, p := range op.params {
+		if p.in != "path" {
+			fields = append(fields, p)
+		}
+	}
+	if len(fields) > 0 {
+		params = name + "Params"
+		c.params(params, name, fields)
+		args = append(args, "params *"+params)
+	}
+
+	// The response.
+	kind, out := c.result(op)
+	var ret string
+	switch kind {
+	case resultNone:
+		ret = "error"
+	case resultJSON:
+		if c.isStruct(out) {
+			ret = "(*" + out + ", error)"
+		} else {
+			ret = "(" + out + ", error)"
+		}
+	case resultRawJSON:
+		c.imports["encoding/json"] = true
+		out = "json.RawMessage"
+		ret = "(json.RawMessage, error)"
+	case resultResponse:
+		ret = "(*http.Response, error)"
+	}
+	c.imports["net/http"] = true
+
+	if op.doc == "" {
+		fmt.Fprintf(buf, "// %s calls %s %s.\n", name, op.method, op.path)
+	} else {
+		buf.WriteString(comment(name+" "+lowerFirstWord(op.doc), ""))
+		fmt.Fprintf(buf, "//\n//\t%s %s\n", op.method, op.path)
+	}
+	if kind == resultResponse {
+		buf.WriteString("//\n// The caller must close the response's body.\n")
+	}
+	fmt.Fprintf(buf, "func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), ret)
+	q := "nil, nil"
+	if params != "" {
+		buf.WriteString("\tq, h := params.values()\n")
+		q = "q, h"
+	}
+	method := httpMethod(op.method)
+	p := strings.Join(path, "+")
+	send := fmt.Sprintf("c.send(ctx, %s, %s, %s, %s)", method, p, q, in)
+	if rawBody {
+		send = fmt.Sprintf("c.do(ctx, %s, %s, %s, body, %s)", method, p, q, contentType)
+	}
+	switch kind {
+	case resultResponse:
+		fmt.Fprintf(buf, "\treturn %s\n", send)
+	case resultNone:
+		fmt.Fprintf(buf, "\tresp, err := %s\n\tif err != nil {\n\t\treturn err\n\t}\n\treturn decode(resp, nil)\n", send)
+	default:
+		zero := "nil"
+		ptr := c.isStruct(out)
+		if !ptr && !strings.HasPrefix(out, "[]") && !strings.HasPrefix(out, "map[") && out != "any" && out != "json.RawMessage" {
+			zero = "out"
+		}
+		fmt.Fprintf(buf, "\tvar out %s\n", out)
+		fmt.Fprintf(buf, "\tresp, err := %s\n\tif err != nil {\n\t\treturn %s, err\n\t}\n", send, zero)
+		if ptr {
+			buf.WriteString("\tif err := decode(resp, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n")
+		} else {
+			fmt.Fprintf(buf, "\terr = decode(resp, &out)\n\treturn out, err\n")
+		}
+	}
+	buf.WriteString("}\n\n")
+}
+
+func (c *clientGen) isRest(op *operation, name string) bool {
+	for _, p := range op.params {
+		if p.in == "path" && p.name == name {
+			return p.rest
+		}
+	}
+	return false
+}
+
+// result returns how the method of op returns its successful response,
+// and the Go type of a JSON one.
+func (c *clientGen) result(op *operation) (result, string) {
+	var statuses []int
+	for s := range op.responses {
+		if s >= 200 && s < 400 {
+			statuses = append(statuses, s)
+		}
+	}
+	sort.Ints(statuses)
+	for _, s := range statuses {
+		r := op.responses[s]
+		var raw []string
+		for _, ct := range r.raw {
+			// Streams of JSON lines are only sent on request.
+			if ct != "application/x-ndjson" || len(r.json) == 0 {
+				raw = append(raw, ct)
+			}
+		}
+		switch {
+		case len(raw) > 0:
+			return resultResponse, ""
+		case len(r.json) == 1:
+			return resultJSON, c.goType(r.json[0], op.id+"Response", false)
+		case len(r.json) > 1:
+			return resultRawJSON, ""
+		}
+	}
+	return resultNone, ""
+}
+
+// params writes the type of the query and header parameters of a method.
+func (c *clientGen) params(name, method string, fields []param) {
+	c.imports["net/url"] = true
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// %s are the query and header parameters of %s; empty ones are not sent.\n", name, method)
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	goNames := uniqueNames(fields, func(p param) string { return p.name })
+	for i, p := range fields {
+		in := "?" + p.name
+		if p.in == "header" {
+			in = p.name + " header"
+		}
+		fmt.Fprintf(&b, "\t%s string // %s\n", goNames[i], in)
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "func (p *%s) values() (url.Values, http.Header) {\n", name)
+	b.WriteString("\tq, h := make(url.Values), make(http.Header)\n\tif p == nil {\n\t\treturn q, h\n\t}\n")
+	for i, p := range fields {
+		set := "q.Set"
+		if p.in == "header" {
+			set = "h.Set"
+		}
+		fmt.Fprintf(&b, "\tif p.%s != \"\" {\n\t\t%s(%q, p.%s)\n\t}\n", goNames[i], set, p.name, goNames[i])
+	}
+	b.WriteString("\treturn q, h\n}\n\n")
+	c.types[name] = b.String()
+}
+
+// goType returns the Go type of s, writing the types it needs, and naming
+// that of an object without a component name.
+func (c *clientGen) goType(s *schema, name string, field bool) string {
+	switch {
+	case s.ref != "":
+		c.component(s.ref)
+		if field && s.nullable {
+			return "*" + s.ref
+		}
+		return s.ref
+	case s.typ == "string" && s.format == "date-time":
+		c.imports["time"] = true
+		if field {
+			return "*time.Time"
+		}
+		return "time.Time"
+	case s.typ == "string" && s.format == "byte":
+		return "[]byte"
+	}
+	t := ""
+	switch s.typ {
+	case "string":
+		t = "string"
+	case "boolean":
+		t = "bool"
+	case "integer":
+		t = "int64"
+	case "number":
+		t = "float64"
+	case "array":
+		return "[]" + c.goType(s.items, name+"Item", false)
+	case "object":
+		switch {
+		case s.values != nil:
+			return "map[string]" + c.goType(s.values, name+"Value", false)
+		case len(s.props) == 0:
+			return "map[string]any"
+		}
+		c.structType(name, s, "")
+		if field && s.nullable {
+			return "*" + name
+		}
+		return name
+	default:
+		return "any"
+	}
+	if field && s.nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func (c *clientGen) isStruct(t string) bool {
+	if t == "" || strings.ContainsAny(t, "[]*.") || t == "any" || token.Lookup(t).IsKeyword() {
+		return false
+	}
+	switch t {
+	case "string", "bool", "int64", "float64":
+		return false
+	}
+	return true
+}
+
+func (c *clientGen) component(name string) {
+	if _, ok := c.types[name]; ok {
+		return
+	}
+	comp := c.g.components[name]
+	c.structType(name, comp.schema, comp.doc)
+}
+
+// structType writes the struct type name for the object s.
+func (c *clientGen) structType(name string, s *schema, doc string) {
+	if _, ok := c.types[name]; ok {
+		return
+	}
+	c.types[name] = "" // for recursive types
+	var b bytes.Buffer
+	if doc != "" {
+		b.WriteString(comment(doc, ""))
+	}
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	goNames := uniqueNames(s.props, func(p prop) string { return p.name })
+	for i, p := range s.props {
+		t := c.goType(p.schema, name+goNames[i], true)
+		if doc := fieldDoc(p.schema); doc != "" {
+			b.WriteString(comment(doc, "\t"))
+		}
+		fmt.Fprintf(&b, "\t%s %s `json:\"%s,omitempty\"`\n", goNames[i], t, p.name)
+	}
+	b.WriteString("}\n\n")
+	c.types[name] = b.String()
+}
+
+func fieldDoc(s *schema) string {
+	doc := s.doc
+	if s.enum != nil {
+		var values []string
+		for _, v := range s.enum {
+			values = append(values, fmt.Sprint(v))
+		}
+		if doc != "" {
+			doc += " "
+		}
+		doc += "One of " + strings.Join(values, ", ") + "."
+	}
+	return doc
+}
+
+// uniqueNames returns the exported Go names of the JSON names of list,
+// numbering those that would be the same.
+func uniqueNames[T any](list []T, jsonName func(T) string) []string {
+	names := make([]string, len(list))
+	seen := make(map[string]bool)
+	for i, v := range list {
+		n := camel(jsonName(v))
+		if n == "" || n[0] >= '0' && n[0] <= '9' {
+			n = "F" + n
+		}
+		base := n
+		for j := 2; seen[n]; j++ {
+			n = fmt.Sprintf("%s%d", base, j)
+		}
+		seen[n] = true
+		names[i] = n
+	}
+	return names
+}
+
+// goIdent returns a Go parameter name for a path parameter.
+func goIdent(name string) string {
+	id := lowerFirst(camel(name))
+	switch {
+	case id == "":
+		return "p"
+	case token.Lookup(id).IsKeyword():
+		return id + "Name"
+	}
+	switch id {
+	case "c", "ctx", "body", "params", "contentType", "q", "h", "out", "resp", "err":
+		return id + "Param"
+	}
+	return id
+}
+
+func httpMethod(m string) string {
+	switch m {
+	case "GET":
+		return "http.MethodGet"
+	case "POST":
+		return "http.MethodPost"
+	case "PUT":
+		return "http.MethodPut"
+	case "PATCH":
+		return "http.MethodPatch"
+	case "DELETE":
+		return "http.MethodDelete"
+	case "HEAD":
+		return "http.MethodHead"
+	}
+	return fmt.Sprintf("%q", m)
+}
+
+// lowerFirstWord returns the description of an operation continuing its
+// method's name in a doc comment.
+func lowerFirstWord(doc string) string {
+	first, rest, _ := strings.Cut(doc, " ")
+	if strings.ToUpper(first) != first || len(first) == 1 {
+		first = strings.ToLower(first[:1]) + first[1:]
+	}
+	if rest == "" {
+		return first
+	}
+	return first + " " + rest
+}
+
+// comment wraps text as a comment indented by indent.
+func comment(text, indent string) string {
+	var b strings.Builder
+	line := ""
+	for _, w := range strings.Fields(text) {
+		if line != "" && len(indent)*4+len(line)+1+len(w) > 76 {
+			b.WriteString(indent + "// " + line + "\n")
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += w
+	}
+	if line != "" {
+		b.WriteString(indent + "// " + line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+)
+
+// loader type-checks the packages of the module from source, sharing one
+// types.Info between them, and the standard library from export data.
+type loader struct {
+	root, module string
+	fset         *token.FileSet
+	std          types.Importer
+	info         *types.Info
+	pkgs         map[string]*pkg
+	// decls are the declarations of the module's functions and methods.
+	decls map[*types.Func]*ast.FuncDecl
+	// docs are the doc comments of struct fields, and typeDocs those of
+	// types.
+	docs     map[*types.Var]string
+	typeDocs map[*types.TypeName]string
+}
+
+// pkg is a package of the module.
+type pkg struct {
+	path  string
+	doc   string
+	files []*ast.File
+	types *types.Package
+}
+
+func newLoader(root, module string) *loader {
+	fset := token.NewFileSet()
+	return &loader{
+		root:   root,
+		module: module,
+		fset:   fset,
+		std:    importer.ForCompiler(fset, "gc", nil),
+		info: &types.Info{
+			Types: make(map[ast.Expr]types.TypeAndValue),
+			Defs:  make(map[*ast.Ident]types.Object),
+			Uses:  make(map[*ast.Ident]types.Object),
+		},
+		pkgs:     make(map[string]*pkg),
+		decls:    make(map[*types.Func]*ast.FuncDecl),
+		docs:     make(map[*types.Var]string),
+		typeDocs: make(map[*types.TypeName]string),
+	}
+}
+
+func (l *loader) inModule(path string) bool {
+	return path == l.module || strings.HasPrefix(path, l.module+"/")
+}
+
+// Import implements types.Importer.
+func (l *loader) Import(path string) (*types.Package, error) {
+	if !l.inModule(path) {
+		return l.std.Import(path)
+	}
+	p, err := l.load(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.types, nil
+}
+
+// load parses and type-checks the module's package path, and those it
+// imports.
+func (l *loader) load(path string) (*pkg, error) {
+	if p, ok := l.pkgs[path]; ok {
+		if p.types == nil {
+			return nil, fmt.Errorf("import cycle through %s", path)
+		}
+		return p, nil
+	}
+	p := &pkg{path: path}
+	l.pkgs[path] = p
+	dir := filepath.Join(l.root, filepath.FromSlash(strings.TrimPrefix(strings.TrimPrefix(path, l.module), "/")))
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(l.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if f.Doc != nil && p.doc == "" {
+			p.doc = f.Doc.Text()
+		}
+		p.files = append(p.files, f)
+	}
+	conf := types.Config{Importer: l}
+	tp, err := conf.Check(path, l.fset, p.files, l.info)
+	if err != nil {
+		return nil, err
+	}
+	p.types = tp
+	for _, f := range p.files {
+		l.index(f)
+	}
+	return p, nil
+}
+
+// index records the function declarations and the docs of the types and
+// fields of f.
+func (l *loader) index(f *ast.File) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GenDecl:
+			for _, spec := range n.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc.Text()
+				if doc == "" && len(n.Specs) == 1 {
+					doc = n.Doc.Text()
+				}
+				if tn, ok := l.info.Defs[ts.Name].(*types.TypeName); ok && doc != "" {
+					l.typeDocs[tn] = doc
+				}
+			}
+		case *ast.FuncDecl:
+			if fn, ok := l.info.Defs[n.Name].(*types.Func); ok {
+				l.decls[fn] = n
+			}
+		case *ast.StructType:
+			for _, field := range n.Fields.List {
+				doc := field.Doc.Text()
+				if doc == "" {
+					doc = field.Comment.Text()
+				}
+				for _, name := range field.Names {
+					if v, ok := l.info.Defs[name].(*types.Var); ok && doc != "" {
+						l.docs[v] = doc
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+// pkgOf returns the module package of obj, or nil.
+func (l *loader) pkgOf(obj types.Object) *pkg {
+	if obj == nil || obj.Pkg() == nil {
+		return nil
+	}
+	return l.pkgs[obj.Pkg().Path()]
+}
@@ -0,0 +1,128 @@
+// Command webide-openapi generates the OpenAPI 3 definition of the
+// server's HTTP API, and the Go client of pkg/client, from the handlers
+// themselves. It type-checks cmd/webide-server, follows the Register
+// methods it calls to every route mounted on a ServeMux, and reads each
+// handler: its doc comment, the path, query and header parameters it
+// reads, the body it decodes with httpx.DecodeJSON and the responses it
+// writes with httpx.JSON and httpx.Error, whose Go types become the
+// schemas.
+//
+// Usage:
+//
+//	webide-openapi [-check]
+//
+// It is run by go generate in internal/openapi, and rewrites
+// internal/openapi/openapi.json and pkg/client/api.go of the module
+// holding the working directory. With -check it writes nothing, and fails
+// if either file is out of date.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	specFile   = "internal/openapi/openapi.json"
+	clientFile = "pkg/client/api.go"
+	serverCmd  = "cmd/webide-server"
+)
+
+func main() {
+	check := flag.Bool("check", false, "fail if the generated files are out of date instead of writing them")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: webide-openapi [-check]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if err := run(*check); err != nil {
+		fmt.Fprintln(os.Stderr, "webide-openapi:", err)
+		os.Exit(1)
+	}
+}
+
+func run(check bool) error {
+	root, module, err := findModule()
+	if err != nil {
+		return err
+	}
+	l := newLoader(root, module)
+	main, err := l.load(module + "/" + serverCmd)
+	if err != nil {
+		return err
+	}
+	g := newGenerator(l)
+	g.collect(main)
+	if len(g.ops) == 0 {
+		return errors.New("no routes found")
+	}
+
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+	client, err := g.client()
+	if err != nil {
+		return err
+	}
+	stale := false
+	for _, out := range []struct {
+		name string
+		data []byte
+	}{{specFile, spec}, {clientFile, client}} {
+		p := filepath.Join(root, out.name)
+		old, err := os.ReadFile(p)
+		if err == nil && bytes.Equal(old, out.data) {
+			continue
+		}
+		if check {
+			fmt.Fprintf(os.Stderr, "%s is out of date\n", out.name)
+			stale = true
+			continue
+		}
+		if err := os.WriteFile(p, out.data, 0o644); err != nil {
+			return err
+		}
+	}
+	if stale {
+		return errors.New("run go generate ./internal/openapi")
+	}
+	return nil
+}
+
+// findModule returns the directory and path of the module holding the
+// working directory.
+func findModule() (dir, path string, err error) {
+	dir, err = os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			path := modulePath(data)
+			if path == "" {
+				return "", "", fmt.Errorf("%s: no module line", filepath.Join(dir, "go.mod"))
+			}
+			return dir, path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errors.New("not in a module")
+		}
+		dir = parent
+	}
+}
+
+func modulePath(gomod []byte) string {
+	for _, line := range bytes.Split(gomod, []byte("\n")) {
+		if rest, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("module ")); ok {
+			return string(bytes.Trim(bytes.TrimSpace(rest), `"`))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// operation is a route of the API.
+type operation struct {
+	// id is the operation's name in the client; its operationId is the
+	// same in lower camel case.
+	id      string
+	method  string
+	pattern string
+	path    string // in OpenAPI form, {path...} as {path}
+	tag     string
+	summary string
+	doc     string
+	params  []param
+	body    *body
+	// responses by status; 0 is the default response.
+	responses map[int]*response
+	websocket bool
+}
+
+// param is a path, query or header parameter.
+type param struct {
+	name string
+	in   string
+	rest bool // a {name...} path parameter, which may hold slashes
+}
+
+// body is a request body.
+type body struct {
+	contentType string
+	schema      *schema
+	optional    bool
+}
+
+// response is what an operation answers with one status.
+type response struct {
+	// json are the schemas of JSON bodies, and raw the content types of
+	// others.
+	json  []*schema
+	raw   []string
+	error bool
+}
+
+// generator collects the operations of the API and the schemas they use.
+type generator struct {
+	*loader
+	ops        []*operation
+	components map[string]*component
+	// named maps the type strings of named types to their components.
+	named map[string]*component
+	tags  map[string]string
+}
+
+func newGenerator(l *loader) *generator {
+	return &generator{
+		loader:     l,
+		components: make(map[string]*component),
+		named:      make(map[string]*component),
+		tags:       make(map[string]string),
+	}
+}
+
+// collect finds the routes mounted by the functions of main, and those they
+// call with a ServeMux.
+func (g *generator) collect(main *pkg) {
+	seen := make(map[*ast.FuncDecl]bool)
+	for _, f := range main.files {
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok {
+				g.mounts(fd, seen)
+			}
+		}
+	}
+	g.name()
+}
+
+// mounts collects the routes mounted in fd.
+func (g *generator) mounts(fd *ast.FuncDecl, seen map[*ast.FuncDecl]bool) {
+	if fd.Body == nil || seen[fd] {
+		return
+	}
+	seen[fd] = true
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		fn := g.callee(call)
+		if fn == nil {
+			return true
+		}
+		switch fn.FullName() {
+		case "(*net/http.ServeMux).Handle", "(*net/http.ServeMux).HandleFunc":
+			if len(call.Args) == 2 {
+				if tv := g.info.Types[call.Args[0]]; tv.Value != nil && tv.Value.Kind() == constant.String {
+					g.route(constant.StringVal(tv.Value), call.Args[1], fd)
+				}
+			}
+			return true
+		}
+		if decl := g.decls[fn.Origin()]; decl != nil && takesMux(fn) {
+			g.mounts(decl, seen)
+		}
+		return true
+	})
+}
+
+func takesMux(fn *types.Func) bool {
+	sig := fn.Type().(*types.Signature)
+	for i := range sig.Params().Len() {
+		if isPointerTo(sig.Params().At(i).Type(), "net/http", "ServeMux") {
+			return true
+		}
+	}
+	return false
+}
+
+// callee returns the function or method call calls, if it is not a
+// conversion, a builtin or a function value.
+func (g *generator) callee(call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	case *ast.IndexExpr:
+		if sel, ok := fun.X.(*ast.SelectorExpr); ok {
+			id = sel.Sel
+		} else if x, ok := fun.X.(*ast.Ident); ok {
+			id = x
+		}
+	}
+	if id == nil {
+		return nil
+	}
+	fn, _ := g.info.Uses[id].(*types.Func)
+	return fn
+}
+
+// route adds the operation of a route mounted in fd.
+func (g *generator) route(pattern string, handler ast.Expr, fd *ast.FuncDecl) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "GET", pattern
+	}
+	if !strings.HasPrefix(path, "/") || path == "/" {
+		return // a host pattern, or everything
+	}
+	for _, op := range g.ops {
+		if op.method == method && op.pattern == path {
+			return // mounted on another mux too
+		}
+	}
+	op := &operation{method: method, pattern: path, responses: make(map[int]*response)}
+	path = strings.TrimSuffix(path, "{$}")
+	var elems []string
+	for _, e := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(e, "{"); ok {
+			name = strings.TrimSuffix(name, "}")
+			rest := strings.HasSuffix(name, "...")
+			name = strings.TrimSuffix(name, "...")
+			op.params = append(op.params, param{name: name, in: "path", rest: rest})
+			e = "{" + name + "}"
+		}
+		elems = append(elems, e)
+	}
+	op.path = strings.Join(elems, "/")
+
+	a := &analysis{g: g, op: op, seen: make(map[*ast.FuncDecl]bool)}
+	name, pkgName, doc := g.handler(a, handler)
+	if fn, ok := g.info.Defs[fd.Name].(*types.Func); pkgName == "" && ok {
+		pkgName = fn.Pkg().Name()
+	}
+	if name == "" {
+		name = lastStatic(elems)
+	}
+	op.tag = pkgName
+	op.id = camel(name)
+	if !strings.HasPrefix(op.id, camel(pkgName)) {
+		op.id = camel(pkgName) + op.id
+	}
+	op.doc = docText(doc, name)
+	op.summary = firstSentence(op.doc)
+	a.finish()
+	if _, ok := g.tags[pkgName]; !ok {
+		if p := g.pkgs[g.pathOf(pkgName)]; p != nil {
+			g.tags[pkgName] = firstSentence(strings.Join(strings.Fields(p.doc), " "))
+		} else {
+			g.tags[pkgName] = ""
+		}
+	}
+	g.ops = append(g.ops, op)
+}
+
+// pathOf returns the path of the module package named name.
+func (g *generator) pathOf(name string) string {
+	for path, p := range g.pkgs {
+		if p.types != nil && p.types.Name() == name {
+			return path
+		}
+	}
+	return ""
+}
+
+// handler reads the handler of a route into a, returning the name, package
+// and doc comment of the function handling it.
+func (g *generator) handler(a *analysis, h ast.Expr) (name, pkgName, doc string) {
+	switch h := ast.Unparen(h).(type) {
+	case *ast.FuncLit:
+		a.walk(h.Body)
+		return "", "", ""
+	case *ast.SelectorExpr, *ast.Ident:
+		var id *ast.Ident
+		if sel, ok := h.(*ast.SelectorExpr); ok {
+			id = sel.Sel
+		} else {
+			id = h.(*ast.Ident)
+		}
+		if fn, ok := g.info.Uses[id].(*types.Func); ok {
+			if decl := g.decls[fn.Origin()]; decl != nil {
+				a.walk(decl.Body)
+				return fn.Name(), fn.Pkg().Name(), decl.Doc.Text()
+			}
+			return fn.Name(), fn.Pkg().Name(), ""
+		}
+		return g.serveHTTP(a, g.info.TypeOf(h))
+	case *ast.CallExpr:
+		fn := g.callee(h)
+		if fn == nil {
+			return g.serveHTTP(a, g.info.TypeOf(h))
+		}
+		// A function returning the handler, such as a middleware or a
+		// handler for one of several actions: its handler arguments are
+		// read too, and its first argument names the route.
+		name, pkgName := fn.Name(), fn.Pkg().Name()
+		if decl := g.decls[fn.Origin()]; decl != nil {
+			a.walk(decl.Body)
+			doc = decl.Doc.Text()
+		}
+		for i, arg := range h.Args {
+			if isHandlerFunc(g.info.TypeOf(arg)) {
+				if n, p, d := g.handler(a, arg); i == 0 && n != "" {
+					name, pkgName, doc = n, p, d
+				}
+				continue
+			}
+			if i == 0 {
+				if id := lastIdent(arg); id != "" {
+					name += camel(id)
+				}
+			}
+		}
+		return name, pkgName, doc
+	}
+	return g.serveHTTP(a, g.info.TypeOf(h))
+}
+
+// serveHTTP reads the ServeHTTP method of the type of an http.Handler.
+func (g *generator) serveHTTP(a *analysis, t types.Type) (name, pkgName, doc string) {
+	if t == nil {
+		return "", "", ""
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "ServeHTTP")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return "", "", ""
+	}
+	if decl := g.decls[fn.Origin()]; decl != nil {
+		a.walk(decl.Body)
+		return "", fn.Pkg().Name(), docText(decl.Doc.Text(), "ServeHTTP")
+	}
+	return "", "", ""
+}
+
+func isHandlerFunc(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	sig, ok := t.Underlying().(*types.Signature)
+	return ok && sig.Params().Len() == 2 && sig.Results().Len() == 0 &&
+		isNamed(sig.Params().At(0).Type(), "net/http", "ResponseWriter") &&
+		isPointerTo(sig.Params().At(1).Type(), "net/http", "Request")
+}
+
+func lastIdent(e ast.Expr) string {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	}
+	return ""
+}
+
+func lastStatic(elems []string) string {
+	for i := len(elems) - 1; i >= 0; i-- {
+		if e := elems[i]; e != "" && !strings.HasPrefix(e, "{") {
+			return e
+		}
+	}
+	return "root"
+}
+
+// name makes the IDs of operations unique. Those of a handler mounted
+// for several methods get the method added, and those of one mounted on
+// several paths the path elements after the shortest.
+func (g *generator) name() {
+	byID := make(map[string][]*operation)
+	for _, op := range g.ops {
+		byID[op.id] = append(byID[op.id], op)
+	}
+	for _, ops := range byID {
+		if len(ops) == 1 {
+			continue
+		}
+		methods := make(map[string]bool)
+		for _, op := range ops {
+			methods[op.method] = true
+		}
+		if len(methods) == len(ops) {
+			for _, op := range ops {
+				op.id += camel(strings.ToLower(op.method))
+			}
+			continue
+		}
+		sort.SliceStable(ops, func(i, j int) bool { return len(ops[i].path) < len(ops[j].path) })
+		for _, op := range ops[1:] {
+			for _, e := range strings.Split(strings.TrimPrefix(op.path, ops[0].path), "/") {
+				op.id += camel(strings.Trim(e, "{}"))
+			}
+			if op.method != ops[0].method {
+				op.id += camel(strings.ToLower(op.method))
+			}
+		}
+	}
+}
+
+// docText rewrites a function's doc comment, which starts with its name,
+// as the description of its route.
+func docText(doc, name string) string {
+	doc = strings.Join(strings.Fields(doc), " ")
+	first, rest, _ := strings.Cut(doc, " ")
+	if w := splitWords(name); first == name || len(w) > 0 && first == w[0] {
+		return upperFirst(rest)
+	}
+	return doc
+}
+
+func firstSentence(s string) string {
+	if i := strings.Index(s, ". "); i >= 0 {
+		return s[:i+1]
+	}
+	return s
+}
+
+// camel turns names such as go-version, go_version and goVersion into
+// GoVersion, keeping common initialisms in capitals.
+func camel(s string) string {
+	var b strings.Builder
+	for _, word := range splitWords(s) {
+		if up := strings.ToUpper(word); initialisms[up] {
+			b.WriteString(up)
+			continue
+		}
+		b.WriteString(upperFirst(word))
+	}
+	return b.String()
+}
+
+var initialisms = map[string]bool{
+	"ACL": true, "AI": true, "API": true, "CPU": true, "CSS": true, "DNS": true,
+	"HTML": true, "HTTP": true, "ID": true, "IDS": true, "IP": true, "JSON": true,
+	"LSP": true, "OS": true, "PR": true, "SHA": true, "SQL": true, "SSH": true,
+	"TLS": true, "TTL": true, "UI": true, "URI": true, "URL": true, "UUID": true,
+	"XML": true,
+}
+
+// splitWords splits s at separators and before capitals following a lower
+// case letter or digit.
+func splitWords(s string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = cur[:0]
+		}
+	}
+	rs := []rune(s)
+	for i, r := range rs {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1])):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	if len(words) > 0 && words[len(words)-1] == "s" && len(words) > 1 && strings.ToUpper(words[len(words)-2]) == "ID" {
+		// IDs
+		words[len(words)-2] += "s"
+		words = words[:len(words)-1]
+	}
+	return words
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func lowerFirst(s string) string {
+	// Lower a leading initialism as a whole: IDList is idList.
+	r := []rune(s)
+	i := 0
+	for i < len(r) && unicode.IsUpper(r[i]) {
+		i++
+	}
+	switch {
+	case i == 0:
+		return s
+	case i == 1 || i == len(r):
+		i = max(i, 1)
+	default:
+		i-- // the last capital starts the next word
+	}
+	for j := range i {
+		r[j] = unicode.ToLower(r[j])
+	}
+	return string(r)
+}
+
+func isNamed(t types.Type, pkgPath, name string) bool {
+	n, ok := types.Unalias(t).(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == pkgPath && n.Obj().Name() == name
+}
+
+func isPointerTo(t types.Type, pkgPath, name string) bool {
+	p, ok := types.Unalias(t).(*types.Pointer)
+	return ok && isNamed(p.Elem(), pkgPath, name)
+}
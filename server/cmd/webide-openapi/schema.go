@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// schema is a JSON schema, as the OpenAPI definition and the client
+// generated from it need it.
+type schema struct {
+	ref    string // the name of a component
+	typ    string // string, integer, number, boolean, array or object; "" for any value
+	format string
+	items  *schema
+	props  []prop
+	// values is the schema of the values of a map.
+	values   *schema
+	enum     []any
+	nullable bool
+	doc      string
+}
+
+// prop is a property of an object.
+type prop struct {
+	name     string
+	schema   *schema
+	required bool
+}
+
+// component is a schema of the API with a name, for a named struct type.
+type component struct {
+	name   string
+	schema *schema
+	// doc is the type's doc comment.
+	doc string
+}
+
+func (s *schema) equal(t *schema) bool {
+	return reflect.DeepEqual(s, t)
+}
+
+var (
+	anySchema  = &schema{}
+	timeSchema = &schema{typ: "string", format: "date-time"}
+)
+
+// schemaOfExpr returns the schema of the JSON encoding of e, reading the
+// keys of map literals.
+func (g *generator) schemaOfExpr(e ast.Expr) *schema {
+	e = ast.Unparen(e)
+	if u, ok := e.(*ast.UnaryExpr); ok {
+		e = ast.Unparen(u.X)
+	}
+	lit, ok := e.(*ast.CompositeLit)
+	if !ok {
+		return g.schemaOf(g.info.TypeOf(e))
+	}
+	m, ok := types.Unalias(g.info.TypeOf(lit)).Underlying().(*types.Map)
+	if !ok || !isString(m.Key()) {
+		return g.schemaOf(g.info.TypeOf(e))
+	}
+	s := &schema{typ: "object"}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := g.stringValue(kv.Key)
+		if !ok {
+			return g.schemaOf(g.info.TypeOf(e))
+		}
+		s.props = append(s.props, prop{name: key, schema: g.schemaOfExpr(kv.Value), required: true})
+	}
+	return s
+}
+
+func isString(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// schemaOf returns the schema of the JSON encoding of a value of type t.
+func (g *generator) schemaOf(t types.Type) *schema {
+	if t == nil {
+		return anySchema
+	}
+	t = types.Unalias(t)
+	switch {
+	case isNamed(t, "time", "Time"):
+		return timeSchema
+	case isNamed(t, "time", "Duration"):
+		return &schema{typ: "integer", format: "int64", doc: "In nanoseconds."}
+	case isNamed(t, "encoding/json", "RawMessage"):
+		return anySchema
+	case hasMethod(t, "MarshalJSON"):
+		return anySchema
+	case hasMethod(t, "MarshalText"):
+		return &schema{typ: "string"}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		s := basicSchema(u)
+		if n, ok := t.(*types.Named); ok && s != nil {
+			s.enum = g.enum(n)
+		}
+		if s == nil {
+			return anySchema
+		}
+		return s
+	case *types.Pointer:
+		s := *g.schemaOf(u.Elem())
+		s.nullable = true
+		return &s
+	case *types.Slice:
+		if b, ok := u.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Byte && !hasMethod(u.Elem(), "MarshalJSON") && !hasMethod(u.Elem(), "MarshalText") {
+			return &schema{typ: "string", format: "byte"}
+		}
+		return &schema{typ: "array", items: g.schemaOf(u.Elem())}
+	case *types.Array:
+		return &schema{typ: "array", items: g.schemaOf(u.Elem())}
+	case *types.Map:
+		return &schema{typ: "object", values: g.schemaOf(u.Elem())}
+	case *types.Struct:
+		if n, ok := t.(*types.Named); ok {
+			return &schema{ref: g.component(n).name}
+		}
+		return g.object(u)
+	}
+	return anySchema
+}
+
+func basicSchema(b *types.Basic) *schema {
+	switch {
+	case b.Info()&types.IsBoolean != 0:
+		return &schema{typ: "boolean"}
+	case b.Info()&types.IsString != 0:
+		return &schema{typ: "string"}
+	case b.Info()&types.IsFloat != 0:
+		return &schema{typ: "number"}
+	case b.Info()&types.IsInteger != 0:
+		switch b.Kind() {
+		case types.Int8, types.Int16, types.Int32, types.Uint8, types.Uint16:
+			return &schema{typ: "integer", format: "int32"}
+		}
+		return &schema{typ: "integer", format: "int64"}
+	}
+	return nil
+}
+
+// hasMethod reports whether t or *t has the method name.
+func hasMethod(t types.Type, name string) bool {
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		return false
+	}
+	if _, ok := t.(*types.Pointer); !ok {
+		t = types.NewPointer(t)
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// enum returns the values of the constants of type n declared in its
+// package, if there are several.
+func (g *generator) enum(n *types.Named) []any {
+	p := n.Obj().Pkg()
+	if p == nil || !g.inModule(p.Path()) {
+		return nil
+	}
+	var values []any
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if !ok || !types.Identical(c.Type(), n) {
+			continue
+		}
+		switch c.Val().Kind() {
+		case constant.String:
+			values = append(values, constant.StringVal(c.Val()))
+		case constant.Int:
+			v, _ := constant.Int64Val(c.Val())
+			values = append(values, v)
+		}
+	}
+	if len(values) < 2 {
+		return nil
+	}
+	sort.Slice(values, func(i, j int) bool { return fmt.Sprint(values[i]) < fmt.Sprint(values[j]) })
+	return slices.CompactFunc(values, func(a, b any) bool { return a == b })
+}
+
+// component returns the component of the named struct type n, adding it
+// on first use.
+func (g *generator) component(n *types.Named) *component {
+	key := types.TypeString(n, nil)
+	if c, ok := g.named[key]; ok {
+		return c
+	}
+	name := componentName(n)
+	for i := 2; g.components[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", componentName(n), i)
+	}
+	c := &component{name: name, doc: typeDoc(g.typeDocs[n.Obj()], n.Obj().Name(), name)}
+	g.named[key] = c
+	g.components[name] = c
+	// Added before the fields are read, for recursive types.
+	c.schema = g.object(n.Underlying().(*types.Struct))
+	return c
+}
+
+// typeDoc rewrites the doc comment of the type name as that of the
+// component called comp.
+func typeDoc(doc, name, comp string) string {
+	doc = strings.Join(strings.Fields(doc), " ")
+	first, rest, _ := strings.Cut(doc, " ")
+	if first == name && rest != "" {
+		return comp + " " + rest
+	}
+	return doc
+}
+
+// componentName is the name of a type prefixed with its package's, as
+// RunnerResult for runner.Result, unless it starts with it, as Org for
+// org.Org.
+func componentName(n *types.Named) string {
+	name := camel(n.Obj().Name())
+	if args := n.TypeArgs(); args != nil {
+		for i := range args.Len() {
+			if a, ok := types.Unalias(args.At(i)).(*types.Named); ok {
+				name += camel(a.Obj().Name())
+			}
+		}
+	}
+	if n.Obj().Pkg() == nil {
+		return name
+	}
+	prefix := camel(n.Obj().Pkg().Name())
+	if strings.HasPrefix(name, prefix) {
+		return name
+	}
+	return prefix + name
+}
+
+// object returns the schema of a struct, as encoding/json encodes it:
+// the fields of embedded structs without a name are promoted, and fields
+// without omitempty are always present.
+func (g *generator) object(st *types.Struct) *schema {
+	s := &schema{typ: "object"}
+	direct := make(map[string]bool)
+	for i := range st.NumFields() {
+		if name, _, ok := jsonField(st, i); ok && name != "" {
+			direct[name] = true
+		}
+	}
+	have := make(map[string]bool)
+	for i := range st.NumFields() {
+		f := st.Field(i)
+		name, opts, ok := jsonField(st, i)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			// An embedded struct without a JSON name.
+			inner := g.schemaOf(f.Type())
+			if inner.ref != "" {
+				if inner = g.components[inner.ref].schema; inner == nil {
+					continue // embeds itself
+				}
+			}
+			for _, p := range inner.props {
+				if !direct[p.name] && !have[p.name] {
+					have[p.name] = true
+					s.props = append(s.props, p)
+				}
+			}
+			continue
+		}
+		if have[name] {
+			continue
+		}
+		have[name] = true
+		fs := g.schemaOf(f.Type())
+		if strings.Contains(opts, ",string") && (fs.typ == "integer" || fs.typ == "number" || fs.typ == "boolean") {
+			fs = &schema{typ: "string"}
+		}
+		if doc := strings.Join(strings.Fields(g.docs[f]), " "); doc != "" {
+			c := *fs
+			c.doc = doc
+			fs = &c
+		}
+		s.props = append(s.props, prop{name: name, schema: fs, required: !strings.Contains(opts, ",omitempty")})
+	}
+	return s
+}
+
+// jsonField returns the JSON name and options of field i of st, or "" for
+// an embedded struct whose fields are promoted, and whether it is encoded
+// at all.
+func jsonField(st *types.Struct, i int) (name, opts string, ok bool) {
+	f := st.Field(i)
+	tag := reflect.StructTag(st.Tag(i)).Get("json")
+	if tag == "-" {
+		return "", "", false
+	}
+	name, rest, _ := strings.Cut(tag, ",")
+	if rest != "" {
+		opts = "," + rest
+	}
+	if f.Embedded() && name == "" {
+		t := types.Unalias(f.Type())
+		if p, ok := t.(*types.Pointer); ok {
+			t = types.Unalias(p.Elem())
+		}
+		if _, isStruct := t.Underlying().(*types.Struct); isStruct {
+			return "", opts, true
+		}
+	}
+	if !f.Exported() {
+		return "", "", false
+	}
+	if name == "" {
+		name = f.Name()
+	}
+	return name, opts, true
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const description = `The HTTP API of the Web IDE server. Successful calls answer with their ` +
+	`payload as-is; failures answer with {"success": false, "error": "..."} and ` +
+	`an HTTP status. Authenticate with an access token or a personal access ` +
+	`token as "Authorization: Bearer <token>". Routes marked x-websocket are ` +
+	`WebSocket upgrades, whose frames are not described here.`
+
+// spec returns the OpenAPI definition of the operations.
+func (g *generator) spec() ([]byte, error) {
+	paths := make(map[string]map[string]any)
+	used := make(map[string]bool)
+	for _, op := range g.ops {
+		if paths[op.path] == nil {
+			paths[op.path] = make(map[string]any)
+		}
+		paths[op.path][strings.ToLower(op.method)] = g.operation(op, used)
+	}
+	schemas := map[string]any{
+		"Error": map[string]any{
+			"type":     "object",
+			"required": []string{"success", "error"},
+			"properties": map[string]any{
+				"success": map[string]any{"type": "boolean"},
+				"error":   map[string]any{"type": "string"},
+			},
+		},
+	}
+	// Components are added as they are found to be used, including by
+	// other components.
+	for len(schemas)-1 < len(used) {
+		for name := range used {
+			if _, ok := schemas[name]; !ok {
+				c := g.components[name]
+				s := jsonSchema(c.schema, used)
+				if c.doc != "" {
+					s["description"] = c.doc
+				}
+				schemas[name] = s
+			}
+		}
+	}
+	var tags []map[string]any
+	for name, doc := range g.tags {
+		t := map[string]any{"name": name}
+		if doc != "" {
+			t["description"] = doc
+		}
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i]["name"].(string) < tags[j]["name"].(string) })
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Web IDE API",
+			"version":     "1",
+			"description": description,
+		},
+		"servers":  []any{map[string]any{"url": "/"}},
+		"tags":     tags,
+		"paths":    paths,
+		"security": []any{map[string]any{"bearerAuth": []string{}}, map[string]any{}},
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *generator) operation(op *operation, used map[string]bool) map[string]any {
+	out := map[string]any{
+		"operationId": lowerFirst(op.id),
+		"tags":        []string{op.tag},
+	}
+	if op.summary != "" {
+		out["summary"] = op.summary
+	}
+	if op.doc != "" && op.doc != op.summary {
+		out["description"] = op.doc
+	}
+	if op.websocket {
+		out["x-websocket"] = true
+	}
+	var params []any
+	for _, p := range op.params {
+		param := map[string]any{
+			"name":   p.name,
+			"in":     p.in,
+			"schema": map[string]any{"type": "string"},
+		}
+		if p.in == "path" {
+			param["required"] = true
+		}
+		if p.rest {
+			param["description"] = "A slash-separated path."
+		}
+		params = append(params, param)
+	}
+	if params != nil {
+		out["parameters"] = params
+	}
+	if b := op.body; b != nil {
+		media := map[string]any{}
+		if b.schema != nil {
+			media["schema"] = jsonSchema(b.schema, used)
+		} else if b.contentType != "multipart/form-data" {
+			media["schema"] = map[string]any{"type": "string", "format": "binary"}
+		}
+		out["requestBody"] = map[string]any{
+			"required": !b.optional,
+			"content":  map[string]any{b.contentType: media},
+		}
+	}
+	responses := make(map[string]any)
+	for status, r := range op.responses {
+		code := strconv.Itoa(status)
+		desc := http.StatusText(status)
+		if status == 0 {
+			code, desc = "default", "Error"
+		}
+		resp := map[string]any{"description": desc}
+		content := make(map[string]any)
+		switch len(r.json) {
+		case 0:
+		case 1:
+			content["application/json"] = map[string]any{"schema": jsonSchema(r.json[0], used)}
+		default:
+			var one []any
+			for _, s := range r.json {
+				one = append(one, jsonSchema(s, used))
+			}
+			content["application/json"] = map[string]any{"schema": map[string]any{"oneOf": one}}
+		}
+		for _, ct := range r.raw {
+			s := map[string]any{"type": "string"}
+			if !strings.HasPrefix(ct, "text/") && ct != "application/x-ndjson" {
+				s["format"] = "binary"
+			}
+			content[ct] = map[string]any{"schema": s}
+		}
+		if r.error && len(r.json) == 0 {
+			content["application/json"] = map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}}
+		}
+		if len(content) > 0 {
+			resp["content"] = content
+		}
+		responses[code] = resp
+	}
+	out["responses"] = responses
+	return out
+}
+
+// jsonSchema returns s as an OpenAPI schema object, adding the components
+// it refers to to used.
+func jsonSchema(s *schema, used map[string]bool) map[string]any {
+	if s.ref != "" {
+		used[s.ref] = true
+		ref := map[string]any{"$ref": "#/components/schemas/" + s.ref}
+		if s.doc != "" {
+			// Siblings of $ref are ignored in OpenAPI 3.0.
+			return map[string]any{"allOf": []any{ref}, "description": s.doc}
+		}
+		return ref
+	}
+	out := make(map[string]any)
+	if s.typ != "" {
+		out["type"] = s.typ
+	}
+	if s.format != "" {
+		out["format"] = s.format
+	}
+	if s.doc != "" {
+		out["description"] = s.doc
+	}
+	if s.nullable && s.typ != "" {
+		out["nullable"] = true
+	}
+	if s.enum != nil {
+		out["enum"] = s.enum
+	}
+	if s.items != nil {
+		out["items"] = jsonSchema(s.items, used)
+	}
+	if s.values != nil {
+		if s.values.typ == "" && s.values.ref == "" {
+			out["additionalProperties"] = true
+		} else {
+			out["additionalProperties"] = jsonSchema(s.values, used)
+		}
+	}
+	if s.typ == "object" && s.values == nil {
+		props := make(map[string]any)
+		var required []string
+		for _, p := range s.props {
+			props[p.name] = jsonSchema(p.schema, used)
+			if p.required {
+				required = append(required, p.name)
+			}
+		}
+		if len(props) > 0 {
+			out["properties"] = props
+		}
+		if required != nil {
+			out["required"] = required
+		}
+	}
+	return out
+}
@@ -0,0 +1,1192 @@
+// Command webide-server runs the Go backend for the Web-IDE: sandboxed code
+// execution and the workspace services built on top of it.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/access"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/admin"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ai"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/analytics"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/apiversion"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/artifact"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/assignment"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/bootstrap"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/cluster"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/collab"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/config"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/debug"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/devcontainer"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/devserve"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/drain"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/egress"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/envvars"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/flags"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/fork"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/format"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/gallery"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ghimport"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/gist"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/gotest"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/grpc"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/health"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/hibernate"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/history"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/images"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/jobs"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/kube"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/lint"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/lsp"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/markdown"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/metrics"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/modproxy"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/notebook"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/openapi"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/playground"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ports"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/quota"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ratelimit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/recording"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/recovery"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/repl"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/review"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/search"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/secrets"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/settings"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/snapshot"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/snippet"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/sshd"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/storage"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/store"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/tasks"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/textnorm"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/toolchain"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/traceview"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/tracing"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/trash"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/upload"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/vulncheck"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/wasm"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/watcher"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/webhook"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/archive"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diff"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/git"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/goast"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gomod"
+)
+
+func main() {
+	configPath := flag.String("config", os.Getenv("WEBIDE_CONFIG"), "YAML `file` of settings, which WEBIDE_* variables override")
+	flag.Parse()
+	var logLevel slog.LevelVar
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel}))
+	slog.SetDefault(logger)
+
+	// Settings come from the environment and the config file. Tunables
+	// registered with onReload are read again on SIGHUP and by the admin
+	// API; the others take a restart.
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		slog.Error("init config", "err", err)
+		os.Exit(1)
+	}
+	onReload := func(what string, fn func(c *config.Config) error) {
+		if err := conf.OnReload(fn); err != nil {
+			slog.Error("init "+what, "err", err)
+			os.Exit(1)
+		}
+	}
+	onReload("log level", func(c *config.Config) error {
+		logLevel.Set(c.Level("WEBIDE_LOG_LEVEL"))
+		return nil
+	})
+
+	addr := conf.StringOr("WEBIDE_ADDR", ":8080")
+	dataDir := conf.StringOr("WEBIDE_DATA_DIR", "data")
+
+	workspaces, err := workspace.NewManager(filepath.Join(dataDir, "workspaces"))
+	if err != nil {
+		slog.Error("init workspaces", "err", err)
+		os.Exit(1)
+	}
+	orgs, err := org.NewService(org.Config{Dir: filepath.Join(dataDir, "orgs")}, workspaces)
+	if err != nil {
+		slog.Error("init organizations", "err", err)
+		os.Exit(1)
+	}
+	// Feature flags turn subsystems on for some users first. Administrators
+	// set them through the admin API, over the defaults of WEBIDE_FLAGS.
+	features, err := flags.New(flags.Config{Dir: filepath.Join(dataDir, "flags")}, orgs)
+	if err != nil {
+		slog.Error("init feature flags", "err", err)
+		os.Exit(1)
+	}
+	features.Define("ai-assistant", "The AI assistant, when WEBIDE_AI_ENDPOINT is set")
+	features.Define("sandbox-pool", "Runs in the warm sandbox pool, when WEBIDE_SANDBOX_POOL is on")
+	onReload("feature flags", func(c *config.Config) error {
+		rules, ok := config.Get(c, "WEBIDE_FLAGS", flags.ParseDefaults)
+		if !ok {
+			rules = make(map[string]flags.Rule)
+		}
+		// Both subsystems have their own switch, so their flags default
+		// to on.
+		for _, name := range []string{"ai-assistant", "sandbox-pool"} {
+			if _, set := rules[name]; !set {
+				rules[name] = flags.Rule{Enabled: true}
+			}
+		}
+		features.SetDefaults(rules)
+		return nil
+	})
+	// Subsystems record their metrics here, served at /metrics, and the
+	// services the server depends on are checked by /readyz.
+	reg := metrics.NewRegistry()
+	probes := health.New()
+	// On SIGTERM the server drains: /readyz fails, new runs and sockets are
+	// refused, and the work in progress is saved before it exits.
+	drainer := drain.New(drain.Config{})
+	probes.Add("draining", drainer.Check)
+	// With an OTLP endpoint, requests are traced through the queue and the
+	// sandbox, and the spans sent to the collector.
+	var tracer *tracing.Tracer
+	if endpoint := conf.StringOr("WEBIDE_OTLP_ENDPOINT", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")); endpoint != "" {
+		tracer = tracing.New(tracing.Config{
+			Endpoint:    endpoint,
+			Headers:     splitLabels(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+			Service:     os.Getenv("OTEL_SERVICE_NAME"),
+			SampleRatio: conf.Number("WEBIDE_TRACE_SAMPLE"),
+		})
+		defer tracer.Close()
+	}
+
+	// With a database, users, sessions, workspace owners and snippets are
+	// kept there instead of in files under the data directory, so several
+	// servers can share them.
+	var db *store.Store
+	if url := conf.String("WEBIDE_DATABASE_URL"); url != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		db, err = store.Open(ctx, store.Config{URL: url, MaxConns: int(conf.Number("WEBIDE_DATABASE_MAX_CONNS"))})
+		cancel()
+		if err != nil {
+			slog.Error("init database", "err", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		workspaces.SetCatalog(db.Workspaces())
+		probes.Add("database", db.Ping)
+	}
+	// With Redis, refresh sessions and OAuth flows are kept there and
+	// collaboration and terminal events relayed between servers, so any
+	// server behind the load balancer can serve any request.
+	var shared *cluster.Cluster
+	if url := conf.String("WEBIDE_REDIS_URL"); url != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		shared, err = cluster.Open(ctx, cluster.Config{URL: url, Prefix: conf.String("WEBIDE_REDIS_PREFIX")})
+		cancel()
+		if err != nil {
+			slog.Error("init redis", "err", err)
+			os.Exit(1)
+		}
+		defer shared.Close()
+		probes.Add("redis", shared.Ping)
+	}
+	// With a storage backend, workspaces are kept there and local disk
+	// only holds the ones open on this server.
+	if kind := conf.String("WEBIDE_STORAGE"); kind != "" {
+		backend, err := storageBackend(conf, kind, dataDir)
+		if err != nil {
+			slog.Error("init storage", "err", err)
+			os.Exit(1)
+		}
+		remote := storage.New(storage.Config{
+			Interval: conf.Duration("WEBIDE_STORAGE_SYNC_SECONDS", time.Second),
+		}, backend, workspaces)
+		defer remote.Close()
+		workspaces.SetRemote(remote)
+	}
+	toolchains, err := toolchain.NewService(toolchain.Config{
+		Versions:       conf.List("WEBIDE_GO_VERSIONS"),
+		Default:        conf.String("WEBIDE_GO_VERSION"),
+		Image:          conf.String("WEBIDE_GO_IMAGE"),
+		WorkspaceImage: conf.String("WEBIDE_WORKSPACE_IMAGE"),
+		SettingsDir:    filepath.Join(dataDir, "toolchain"),
+	})
+	if err != nil {
+		slog.Error("init toolchains", "err", err)
+		os.Exit(1)
+	}
+	customImages := images.NewService(images.Config{
+		Registries:  conf.List("WEBIDE_IMAGE_REGISTRIES"),
+		MaxBytes:    int64(conf.Number("WEBIDE_IMAGE_MAX_BYTES")),
+		SettingsDir: filepath.Join(dataDir, "images"),
+		// Workspaces configured for devcontainers build their image the
+		// first time their container is needed.
+		FeatureRegistries: conf.List("WEBIDE_FEATURE_REGISTRIES"),
+		Dir:               workspaces.Open,
+	})
+	bin, err := trash.New(trash.Config{Dir: filepath.Join(dataDir, "trash")}, workspaces)
+	if err != nil {
+		slog.Error("init trash", "err", err)
+		os.Exit(1)
+	}
+	defer bin.Close()
+	artifacts, err := artifact.New(artifact.Config{Dir: filepath.Join(dataDir, "artifacts")})
+	if err != nil {
+		slog.Error("init artifacts", "err", err)
+		os.Exit(1)
+	}
+	defer artifacts.Close()
+	quotas, err := quota.NewService(quota.Config{
+		Dir:   filepath.Join(dataDir, "quota"),
+		Trash: bin,
+	}, workspaces)
+	if err != nil {
+		slog.Error("init quotas", "err", err)
+		os.Exit(1)
+	}
+	onReload("quotas", func(c *config.Config) error {
+		quotas.SetDefaults(quota.Limits{
+			CPUSeconds:    c.Number("WEBIDE_QUOTA_CPU_SECONDS"),
+			MemoryGBHours: c.Number("WEBIDE_QUOTA_MEMORY_GB_HOURS"),
+			StorageBytes:  int64(c.Number("WEBIDE_QUOTA_STORAGE_BYTES")),
+			Sandboxes:     int(c.Number("WEBIDE_QUOTA_SANDBOXES")),
+		})
+		return nil
+	})
+	tmpDir := conf.StringOr("WEBIDE_TMP_DIR", os.TempDir())
+	docker := runner.NewDockerSandbox(conf.String("WEBIDE_SANDBOX_RUNTIME"))
+	// With WEBIDE_SANDBOX=kubernetes, runs and workspaces get pods on a
+	// cluster instead of containers on this host.
+	var kc *kube.Client
+	switch conf.String("WEBIDE_SANDBOX") {
+	case "", "docker":
+	case "kubernetes":
+		kc = &kube.Client{
+			Namespace: conf.String("WEBIDE_KUBERNETES_NAMESPACE"),
+			Context:   conf.String("WEBIDE_KUBERNETES_CONTEXT"),
+		}
+	default:
+		slog.Error("unknown sandbox driver", "env", "WEBIDE_SANDBOX", "value", conf.String("WEBIDE_SANDBOX"))
+		os.Exit(1)
+	}
+
+	// Sandboxes reach the module proxy through the host's gateway, unless
+	// told another URL.
+	var modules *modproxy.Proxy
+	var goproxy string
+	var sandboxHosts []string
+	if conf.Bool("WEBIDE_MODULE_PROXY", false) {
+		modules, err = modproxy.New(modproxy.Config{
+			Upstream: conf.String("WEBIDE_MODULE_UPSTREAM"),
+			Dir:      filepath.Join(dataDir, "modproxy"),
+		})
+		if err != nil {
+			slog.Error("init module proxy", "err", err)
+			os.Exit(1)
+		}
+		goproxy = conf.String("WEBIDE_MODULE_PROXY_URL")
+		if goproxy == "" && kc != nil {
+			slog.Error("WEBIDE_MODULE_PROXY_URL is required with the kubernetes sandbox")
+			os.Exit(1)
+		}
+		if goproxy == "" {
+			_, port, _ := net.SplitHostPort(addr)
+			goproxy = "http://host.docker.internal:" + port + "/goproxy"
+			sandboxHosts = []string{"host.docker.internal:host-gateway"}
+		}
+		docker.Hosts = sandboxHosts
+	}
+
+	// With a network policy, sandboxes with networking go out through the
+	// egress proxy only. That takes a sandbox network without a route out
+	// of its own, from which the proxy is reachable.
+	var policy *egress.Service
+	sandboxNetwork := conf.String("WEBIDE_SANDBOX_NETWORK")
+	if conf.Bool("WEBIDE_EGRESS", false) {
+		egressAddr := conf.StringOr("WEBIDE_EGRESS_ADDR", ":3128")
+		proxyURL := conf.String("WEBIDE_EGRESS_PROXY_URL")
+		if proxyURL == "" && kc != nil {
+			slog.Error("WEBIDE_EGRESS_PROXY_URL is required with the kubernetes sandbox")
+			os.Exit(1)
+		}
+		if proxyURL == "" {
+			_, port, _ := net.SplitHostPort(egressAddr)
+			proxyURL = "http://host.docker.internal:" + port
+			if !slices.Contains(sandboxHosts, "host.docker.internal:host-gateway") {
+				sandboxHosts = append(sandboxHosts, "host.docker.internal:host-gateway")
+			}
+		}
+		var noProxy []string
+		if u, err := url.Parse(goproxy); err == nil && u.Hostname() != "" {
+			noProxy = append(noProxy, u.Hostname())
+		}
+		policy, err = egress.New(egress.Config{
+			Allow:       conf.List("WEBIDE_EGRESS_ALLOW"),
+			Overridable: conf.List("WEBIDE_EGRESS_OVERRIDABLE"),
+			ProxyURL:    proxyURL,
+			NoProxy:     noProxy,
+			SettingsDir: filepath.Join(dataDir, "egress"),
+		})
+		if err != nil {
+			slog.Error("init network policy", "err", err)
+			os.Exit(1)
+		}
+		if sandboxNetwork == "" && kc == nil {
+			slog.Warn("WEBIDE_SANDBOX_NETWORK is unset: sandboxes can connect around the egress proxy")
+		}
+		docker.Hosts = sandboxHosts
+		egressSrv := &http.Server{
+			Addr:              egressAddr,
+			Handler:           policy.Proxy(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			slog.Info("egress proxy listening", "addr", egressAddr)
+			if err := egressSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("egress proxy failed", "err", err)
+				os.Exit(1)
+			}
+		}()
+		defer egressSrv.Close()
+	}
+	docker.Network = sandboxNetwork
+	var containers runner.Sandbox = docker
+	if kc == nil {
+		probes.Add("docker", docker.Ping)
+	} else {
+		probes.Add("kubernetes", kc.Ping)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := kc.ApplyPolicies(ctx, kube.Isolation{
+			Server:   splitLabels(conf.StringOr("WEBIDE_KUBERNETES_SERVER_LABELS", "app=webide-server")),
+			Restrict: policy != nil,
+		})
+		cancel()
+		if err != nil {
+			slog.Error("apply network policies", "err", err)
+			os.Exit(1)
+		}
+		containers = &runner.KubernetesSandbox{Kube: kc, RuntimeClass: conf.String("WEBIDE_SANDBOX_RUNTIME")}
+	}
+	if conf.Bool("WEBIDE_SANDBOX_POOL", false) {
+		if kc != nil {
+			slog.Error("the sandbox pool needs the docker sandbox")
+			os.Exit(1)
+		}
+		pool, err := runner.NewPool(runner.PoolConfig{
+			// Run directories are moved into the pool's, so they share a
+			// file system.
+			Dir:         filepath.Join(tmpDir, "webide-pool"),
+			ModuleCache: conf.String("WEBIDE_MODULE_CACHE"),
+			MinIdle:     int(conf.Number("WEBIDE_POOL_MIN_IDLE")),
+			MaxIdle:     int(conf.Number("WEBIDE_POOL_MAX_IDLE")),
+			Max:         int(conf.Number("WEBIDE_POOL_MAX")),
+		}, docker)
+		if err != nil {
+			slog.Error("init sandbox pool", "err", err)
+			os.Exit(1)
+		}
+		defer pool.Close()
+		containers = flags.Sandbox(features, "sandbox-pool", pool, containers)
+	}
+	// Administrators see the active users and workspaces and the running
+	// sandboxes, adjust quotas and send notices through the admin API.
+	adminCfg := admin.Config{
+		Window:     conf.Duration("WEBIDE_ADMIN_ACTIVE_MINUTES", time.Minute),
+		Workspaces: workspaces,
+		Quotas:     quotas,
+	}
+	if shared != nil {
+		adminCfg.Bus = shared.Bus()
+	}
+	ops, err := admin.NewService(adminCfg)
+	if err != nil {
+		slog.Error("init admin", "err", err)
+		os.Exit(1)
+	}
+	defer ops.Close()
+	sandbox := quota.Sandbox(quotas, admin.Sandbox(ops, containers))
+	// Runs queue for a limited number of workers, fairly across users.
+	queue := runner.NewQueue(runner.QueueConfig{
+		Workers:  int(conf.Number("WEBIDE_QUEUE_WORKERS")),
+		MaxDepth: int(conf.Number("WEBIDE_QUEUE_DEPTH")),
+		Metrics:  reg,
+		Owner: func(ctx context.Context) string {
+			if u := auth.UserFrom(ctx); u != nil {
+				return u.ID
+			}
+			return ""
+		},
+	})
+	runCfg := runner.Config{
+		Toolchains: customImages.Toolchains(toolchains),
+		TempDir:    tmpDir,
+		Artifacts:  artifacts,
+		GOPROXY:    goproxy,
+		Queue:      queue,
+		Metrics:    reg,
+		Languages: []runner.Language{
+			runner.Python(conf.String("WEBIDE_PYTHON_IMAGE")),
+			runner.Node(conf.String("WEBIDE_NODE_IMAGE")),
+		},
+	}
+	if !conf.Bool("WEBIDE_BUILD_CACHE", true) {
+		runCfg.CacheTTL = -1
+	}
+
+	var providers []auth.Provider
+	if id := conf.String("WEBIDE_GITHUB_CLIENT_ID"); id != "" {
+		providers = append(providers, auth.GitHubProvider(id, conf.String("WEBIDE_GITHUB_CLIENT_SECRET")))
+	}
+	if id := conf.String("WEBIDE_GOOGLE_CLIENT_ID"); id != "" {
+		providers = append(providers, auth.GoogleProvider(id, conf.String("WEBIDE_GOOGLE_CLIENT_SECRET")))
+	}
+	authCfg := auth.Config{
+		Dir:             filepath.Join(dataDir, "auth"),
+		Secret:          []byte(conf.String("WEBIDE_AUTH_SECRET")),
+		InsecureCookies: conf.Bool("WEBIDE_INSECURE_COOKIES", false),
+		Providers:       providers,
+		BaseURL:         conf.String("WEBIDE_PUBLIC_URL"),
+		EncryptionKey:   []byte(conf.String("WEBIDE_TOKEN_KEY")),
+		Admins:          conf.List("WEBIDE_ADMINS"),
+	}
+	if db != nil {
+		authCfg.Records = db.Users()
+	}
+	if shared != nil {
+		if authCfg.Records == nil {
+			slog.Error("init auth: WEBIDE_REDIS_URL needs WEBIDE_DATABASE_URL, so servers share their users")
+			os.Exit(1)
+		}
+		authCfg.Records = shared.Records(authCfg.Records)
+		authCfg.Logins = shared.Logins()
+	}
+	accounts, err := auth.NewService(authCfg)
+	if err != nil {
+		slog.Error("init auth", "err", err)
+		os.Exit(1)
+	}
+	// Sensitive actions are recorded in the audit log, which the server's
+	// administrators read through the admin API.
+	var auditLog *audit.Log
+	if conf.Bool("WEBIDE_AUDIT", true) {
+		auditLog, err = audit.New(audit.Config{Dir: conf.StringOr("WEBIDE_AUDIT_DIR", filepath.Join(dataDir, "audit"))})
+		if err != nil {
+			slog.Error("init audit log", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	members, err := access.NewService(access.Config{Dir: filepath.Join(dataDir, "access")}, workspaces, orgs)
+	if err != nil {
+		slog.Error("init access", "err", err)
+		os.Exit(1)
+	}
+
+	var (
+		envRoles    envvars.Roles
+		secretRoles secrets.Roles
+	)
+	if conf.Bool("WEBIDE_AUTH", true) {
+		envRoles, secretRoles = members, members
+	}
+	variables, err := envvars.NewStore(envvars.Config{
+		Dir: filepath.Join(dataDir, "env"),
+		Key: []byte(conf.String("WEBIDE_ENV_KEY")),
+	}, envRoles)
+	if err != nil {
+		slog.Error("init environment variables", "err", err)
+		os.Exit(1)
+	}
+	secretsCfg := secrets.Config{
+		Dir: filepath.Join(dataDir, "secrets"),
+		Key: []byte(conf.String("WEBIDE_SECRETS_KEY")),
+	}
+	if k := conf.String("WEBIDE_SECRETS_PREVIOUS_KEY"); k != "" {
+		secretsCfg.PreviousKeys = [][]byte{[]byte(k)}
+	}
+	vault, err := secrets.New(secretsCfg, workspaces, secretRoles, orgs)
+	if err != nil {
+		slog.Error("init secrets", "err", err)
+		os.Exit(1)
+	}
+	bus := events.NewBus()
+	hooks, err := webhook.New(webhook.Config{Dir: filepath.Join(dataDir, "webhooks")}, bus)
+	if err != nil {
+		slog.Error("init webhooks", "err", err)
+		os.Exit(1)
+	}
+	defer hooks.Close()
+	recordings, err := recording.New(recording.Config{Dir: filepath.Join(dataDir, "recordings")}, workspaces, bus)
+	if err != nil {
+		slog.Error("init recordings", "err", err)
+		os.Exit(1)
+	}
+	defer recordings.Close()
+	runCfg.Variables = variables
+	if policy != nil {
+		runCfg.Egress = policy
+	}
+	runCfg.Secrets = vault
+	runCfg.Access = artifact.Access{Roles: members, Accounts: accounts}
+	run := runner.New(runCfg, sandbox)
+	onReload("run limits", func(c *config.Config) error {
+		return run.SetLimits(runner.Limits{
+			CPUs:      c.Number("WEBIDE_RUN_CPUS"),
+			MemoryMB:  int64(c.Number("WEBIDE_RUN_MEMORY_MB")),
+			TimeoutMS: c.Duration("WEBIDE_RUN_TIMEOUT_SECONDS", time.Second).Milliseconds(),
+		}, runner.Limits{
+			CPUs:      c.Number("WEBIDE_RUN_MAX_CPUS"),
+			MemoryMB:  int64(c.Number("WEBIDE_RUN_MAX_MEMORY_MB")),
+			TimeoutMS: c.Duration("WEBIDE_RUN_MAX_TIMEOUT_SECONDS", time.Second).Milliseconds(),
+		})
+	})
+
+	sockets := &ws.Conns{}
+	origins := conf.List("WEBIDE_CORS_ORIGINS")
+	if len(origins) == 0 {
+		origins = splitList(os.Getenv("CORS_ORIGINS"))
+	}
+	wsOpts := &ws.Options{CheckOrigin: ws.AllowOrigins(origins), Metrics: reg, Conns: sockets}
+
+	sunsets := make(map[string]time.Time)
+	for _, name := range apiversion.Names() {
+		if t, ok := config.Get(conf, "WEBIDE_API_SUNSET_"+strings.ToUpper(name), parseDate); ok {
+			sunsets[name] = t
+		}
+	}
+	versions, err := apiversion.New(apiversion.Config{Default: conf.String("WEBIDE_API_VERSION"), Sunsets: sunsets})
+	if err != nil {
+		slog.Error("init api versions", "err", err)
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	openapi.NewHandler().Register(mux)
+	apiversion.NewHandler(versions).Register(mux)
+	auth.NewHandler(accounts).Register(mux)
+	if auditLog != nil {
+		audit.NewHandler(auditLog, accounts).Register(mux)
+	}
+	admin.NewHandler(ops, accounts, wsOpts).Register(mux)
+	config.NewHandler(conf, accounts).Register(mux)
+	flags.NewHandler(features, accounts).Register(mux)
+	if modules != nil {
+		modules.Register(mux)
+	}
+	access.NewHandler(members, workspaces).Register(mux)
+	envvars.NewHandler(variables, workspaces).Register(mux)
+	secrets.NewHandler(vault, workspaces).Register(mux)
+	webhook.NewHandler(hooks, workspaces).Register(mux)
+	org.NewHandler(orgs, accounts).Register(mux)
+	quota.NewHandler(quotas).Register(mux)
+	workspace.NewHandler(workspaces).Register(mux)
+	toolchain.NewHandler(toolchains, workspaces).Register(mux)
+	if policy != nil {
+		egress.NewHandler(policy, workspaces).Register(mux)
+	}
+	images.NewHandler(customImages, workspaces, wsOpts).Register(mux)
+	fileHistory, err := history.NewStore(history.Config{Dir: filepath.Join(dataDir, "history")})
+	if err != nil {
+		slog.Error("init file history", "err", err)
+		os.Exit(1)
+	}
+	eol, err := textnorm.ParseEOL(conf.String("WEBIDE_EOL"))
+	if err != nil {
+		slog.Error("init text normalization", "err", err)
+		os.Exit(1)
+	}
+	texts := textnorm.NewService(textnorm.Config{
+		SettingsDir: filepath.Join(dataDir, "textnorm"),
+		Default: textnorm.Policy{
+			EOL:                    eol,
+			TrimTrailingWhitespace: conf.Bool("WEBIDE_TRIM_TRAILING_WHITESPACE", false),
+			StripBOM:               conf.Bool("WEBIDE_STRIP_BOM", false),
+		},
+	})
+	textnorm.NewHandler(texts, workspaces).Register(mux)
+	files.NewHandler(workspaces, fileHistory, bin, texts).Register(mux)
+	trash.NewHandler(bin, workspaces).Register(mux)
+	uploads, err := upload.New(upload.Config{Dir: filepath.Join(dataDir, "uploads")}, workspaces, fileHistory)
+	if err != nil {
+		slog.Error("init uploads", "err", err)
+		os.Exit(1)
+	}
+	upload.NewHandler(uploads, workspaces, wsOpts).Register(mux)
+	history.NewHandler(fileHistory, workspaces).Register(mux)
+	search.NewHandler(search.New(search.Config{History: fileHistory}), workspaces).Register(mux)
+	archive.NewHandler(workspaces, artifacts, archive.Limits{}).Register(mux)
+	forkCfg := fork.Config{Variables: variables, Toolchains: toolchains, Images: customImages}
+	if policy != nil {
+		forkCfg.Network = policy
+	}
+	fork.NewHandler(fork.New(forkCfg, workspaces), members).Register(mux)
+	artifact.NewHandler(artifacts, workspaces, members, accounts).Register(mux)
+	snapshotCfg := snapshot.Config{
+		Dir:      filepath.Join(dataDir, "snapshots"),
+		Interval: conf.Duration("WEBIDE_SNAPSHOT_MINUTES", time.Minute),
+	}
+	if conf.String("WEBIDE_SNAPSHOTS") == "manual" {
+		snapshotCfg.Interval = -1
+	}
+	snapshots, err := snapshot.New(snapshotCfg, workspaces)
+	if err != nil {
+		slog.Error("init snapshots", "err", err)
+		os.Exit(1)
+	}
+	defer snapshots.Close()
+	snapshot.NewHandler(snapshots, workspaces).Register(mux)
+	drafts, err := recovery.NewStore(recovery.Config{Dir: filepath.Join(dataDir, "drafts")})
+	if err != nil {
+		slog.Error("init drafts", "err", err)
+		os.Exit(1)
+	}
+	defer drafts.Close()
+	recovery.NewHandler(drafts, workspaces).Register(mux)
+	git.NewHandler(workspaces).Register(mux)
+	diff.NewHandler().Register(mux)
+
+	fileEvents := watcher.NewHub(watcher.Options{})
+	defer fileEvents.Close()
+	bin.Watch(fileEvents, fileHistory)
+	watcher.NewHandler(fileEvents, workspaces, wsOpts).Register(mux)
+	runner.NewHandler(run, wsOpts).Register(mux)
+	traces := traceview.NewManager(traceview.Config{Command: strings.Fields(conf.String("WEBIDE_GO_TRACE"))})
+	defer traces.Close()
+	traceview.NewHandler(traces, run).Register(mux)
+	snippetCfg := snippet.Config{Dir: filepath.Join(dataDir, "snippets")}
+	if db != nil {
+		snippetCfg.Backend = db.Snippets()
+	}
+	snippets := snippet.NewStore(snippetCfg)
+	snippet.NewHandler(snippets, run).Register(mux)
+	play := playground.NewHandler(playground.Config{Origins: conf.List("WEBIDE_EMBED_ORIGINS")}, run)
+	play.Register(mux)
+	gist.NewHandler(gist.NewClient(gist.Config{APIURL: conf.String("WEBIDE_GITHUB_API")}), workspaces, snippets, accounts).Register(mux)
+	gallery.NewHandler(gallery.New(gallery.Config{Dir: conf.String("WEBIDE_EXAMPLES_DIR")}), workspaces).Register(mux)
+
+	collabCfg := collab.Config{Saved: fileHistory.Edited, Metrics: reg, Recorder: recordings, Normalizer: texts}
+	terminalCfg := terminal.Config{Metrics: reg, Recorder: recordings}
+	if shared != nil {
+		collabCfg.Bus = shared.Bus()
+		terminalCfg.Bus = shared.Bus()
+	}
+	documents := collab.NewManager(collabCfg)
+	defer documents.Close()
+	collab.NewHandler(documents, workspaces, wsOpts).Register(mux)
+
+	// Review changes reach everyone with the file open as collab frames.
+	reviews, err := review.NewStore(review.Config{
+		Dir: filepath.Join(dataDir, "reviews"),
+		Notify: func(workspaceID, path string, c review.Change) {
+			data, err := json.Marshal(c)
+			if err != nil {
+				slog.Error("encode review change", "err", err)
+				return
+			}
+			documents.Review(workspaceID, path, data)
+		},
+	})
+	if err != nil {
+		slog.Error("init reviews", "err", err)
+		os.Exit(1)
+	}
+	review.NewHandler(reviews, workspaces).Register(mux)
+	recording.NewHandler(recordings, workspaces, wsOpts).Register(mux)
+
+	// New sandboxes install their owner's dotfiles and run the
+	// workspace's setup script before the first command run in them.
+	bootstraps, err := bootstrap.New(bootstrap.Config{
+		Dir:     filepath.Join(dataDir, "bootstrap"),
+		Timeout: conf.Duration("WEBIDE_BOOTSTRAP_MINUTES", time.Minute),
+	}, workspaces)
+	if err != nil {
+		slog.Error("init bootstrap", "err", err)
+		os.Exit(1)
+	}
+	bootstrap.NewHandler(bootstraps, workspaces).Register(mux)
+	prefs, err := settings.NewStore(settings.Config{Dir: filepath.Join(dataDir, "settings")})
+	if err != nil {
+		slog.Error("init settings", "err", err)
+		os.Exit(1)
+	}
+	settings.NewHandler(prefs).Register(mux)
+
+	dl := &terminal.DockerLauncher{
+		ImageFor:  customImages.WorkspaceImage(toolchains.WorkspaceImage),
+		Runtime:   conf.String("WEBIDE_SANDBOX_RUNTIME"),
+		CPUs:      2,
+		MemoryMB:  2048,
+		PidsLimit: 256,
+		Hosts:     sandboxHosts,
+		Network:   sandboxNetwork,
+		Created:   bootstraps.Created,
+	}
+	if goproxy != "" {
+		dl.Env = []string{"GOPROXY=" + goproxy}
+	}
+	// A devcontainer.json's environment is the container's, except for
+	// the network policy's variables, which come last to win.
+	dl.EnvFor = devcontainer.EnvFor(workspaces, "/workspace")
+	if policy != nil {
+		devEnv := dl.EnvFor
+		dl.EnvFor = func(id string) []string { return append(devEnv(id), policy.Environ(id)...) }
+	}
+	var launcher terminal.Launcher = dl
+	var sandboxes ports.Sandboxes = dl
+	var workspacePods hibernate.Containers = dl
+	if kc != nil {
+		kl := &terminal.KubernetesLauncher{
+			Kube:            kc,
+			Claim:           conf.String("WEBIDE_KUBERNETES_CLAIM"),
+			ClaimRoot:       dataDir,
+			ImageFor:        dl.ImageFor,
+			RuntimeClass:    conf.String("WEBIDE_SANDBOX_RUNTIME"),
+			Env:             dl.Env,
+			EnvFor:          dl.EnvFor,
+			CPUs:            dl.CPUs,
+			MemoryMB:        dl.MemoryMB,
+			RequestCPUs:     conf.Number("WEBIDE_KUBERNETES_REQUEST_CPUS"),
+			RequestMemoryMB: int64(conf.Number("WEBIDE_KUBERNETES_REQUEST_MEMORY_MB")),
+			Created:         dl.Created,
+		}
+		if kl.Claim == "" && conf.String("WEBIDE_TERMINAL") != "local" {
+			slog.Error("WEBIDE_KUBERNETES_CLAIM is required with the kubernetes sandbox")
+			os.Exit(1)
+		}
+		launcher, sandboxes, workspacePods = kl, kl, kl
+	}
+	if conf.String("WEBIDE_TERMINAL") == "local" {
+		launcher = terminal.LocalLauncher{}
+	}
+	// Programs the user runs see the workspace's variables and secrets;
+	// the tools the IDE runs itself do not.
+	userLauncher := bootstraps.Launcher(secrets.Launcher(vault, envvars.Launcher(variables, launcher)))
+	terminals := terminal.NewService(terminalCfg, userLauncher)
+	defer terminals.Close()
+	terminal.NewHandler(terminals, workspaces, quotas, wsOpts).Register(mux)
+	if conf.String("WEBIDE_TERMINAL") == "local" {
+		ops.SetTerminals(terminals, nil)
+	} else {
+		ops.SetTerminals(terminals, workspacePods)
+	}
+
+	// Users who registered a public key reach the workspaces they edit
+	// over SSH as well, with shells started like the terminals'.
+	var gateway *sshd.Server
+	if sshAddr := conf.String("WEBIDE_SSH_ADDR"); sshAddr != "" {
+		if !conf.Bool("WEBIDE_AUTH", true) {
+			slog.Error("init ssh gateway: WEBIDE_SSH_ADDR needs authentication, to know whose keys sign in")
+			os.Exit(1)
+		}
+		hostKey, err := sshd.LoadHostKey(conf.StringOr("WEBIDE_SSH_HOST_KEY", filepath.Join(dataDir, "ssh", "host_ed25519")))
+		if err != nil {
+			slog.Error("init ssh gateway", "err", err)
+			os.Exit(1)
+		}
+		sshKeys, err := sshd.NewKeys(filepath.Join(dataDir, "ssh"))
+		if err != nil {
+			slog.Error("init ssh gateway", "err", err)
+			os.Exit(1)
+		}
+		sshd.NewHandler(sshKeys).Register(mux)
+		gateway = sshd.New(sshd.Config{HostKey: hostKey, Audit: auditLog}, sshKeys, accounts, members, workspaces, userLauncher, quotas)
+		ln, err := net.Listen("tcp", sshAddr)
+		if err != nil {
+			slog.Error("init ssh gateway", "err", err)
+			os.Exit(1)
+		}
+		go func() {
+			slog.Info("ssh gateway listening", "addr", sshAddr)
+			if err := gateway.Serve(ln); err != nil {
+				slog.Error("ssh gateway failed", "err", err)
+				os.Exit(1)
+			}
+		}()
+		defer gateway.Close()
+	}
+
+	if conf.String("WEBIDE_TERMINAL") == "local" {
+		sandboxes = terminal.LocalLauncher{}
+	}
+	var previewRoles ports.Roles
+	if conf.Bool("WEBIDE_AUTH", true) {
+		previewRoles = members
+	}
+	previews, err := ports.New(ports.Config{
+		Dir:       filepath.Join(dataDir, "ports"),
+		Domain:    conf.String("WEBIDE_PREVIEW_DOMAIN"),
+		Scheme:    conf.String("WEBIDE_PREVIEW_SCHEME"),
+		BaseURL:   conf.String("WEBIDE_PUBLIC_URL"),
+		Forwarded: devcontainer.PortsFor(workspaces),
+	}, sandboxes, previewRoles)
+	if err != nil {
+		slog.Error("init port previews", "err", err)
+		os.Exit(1)
+	}
+	ports.NewHandler(previews, workspaces).Register(mux)
+	devServers := devserve.New(devserve.Config{}, userLauncher, fileEvents, previews)
+	defer devServers.Close()
+	devserve.NewHandler(devServers, workspaces, wsOpts).Register(mux)
+
+	// Idle workspace containers are stopped, and started again by the
+	// next command run in them.
+	var lifecycle *hibernate.Service
+	if conf.String("WEBIDE_TERMINAL") != "local" && conf.Bool("WEBIDE_HIBERNATE", true) {
+		lifecycle, err = hibernate.New(hibernate.Config{
+			Dir:         filepath.Join(dataDir, "hibernate"),
+			IdleTimeout: conf.Duration("WEBIDE_HIBERNATE_MINUTES", time.Minute),
+			Busy: func(id string) bool {
+				return len(terminals.List(id)) > 0 || devServers.Running(id) || (gateway != nil && gateway.Busy(id))
+			},
+		}, workspacePods)
+		if err != nil {
+			slog.Error("init hibernation", "err", err)
+			os.Exit(1)
+		}
+		defer lifecycle.Close()
+		hibernate.NewHandler(lifecycle, workspaces).Register(mux)
+	}
+
+	debugger := debug.NewService(debug.Config{DelvePackage: conf.String("WEBIDE_DELVE_PACKAGE")}, userLauncher)
+	defer debugger.Close()
+	debug.NewHandler(debugger, workspaces, wsOpts).Register(mux)
+	tests := gotest.NewService(gotest.Config{HistoryDir: filepath.Join(dataDir, "benchmarks"), Queue: queue}, userLauncher)
+	gotest.NewHandler(tests, workspaces, artifacts, wsOpts).Register(mux)
+	// Submissions are graded with the plain launcher, in sandboxes of
+	// their own, without the variables and secrets of their workspaces.
+	graders := gotest.NewService(gotest.Config{Queue: queue}, launcher)
+	gradeSandboxes, _ := launcher.(assignment.Sandboxes)
+	var gradeRoles assignment.Roles
+	if conf.Bool("WEBIDE_AUTH", true) {
+		gradeRoles = members
+	}
+	assignments, err := assignment.New(assignment.Config{Dir: filepath.Join(dataDir, "assignments")},
+		graders, gradeSandboxes, workspaces, orgs, gradeRoles)
+	if err != nil {
+		slog.Error("init assignments", "err", err)
+		os.Exit(1)
+	}
+	defer assignments.Close()
+	assignment.NewHandler(assignments).Register(mux)
+	// Administrators and instructors report on adoption from counts of
+	// the events, which name no one.
+	reports, err := analytics.New(analytics.Config{
+		Dir:         filepath.Join(dataDir, "analytics"),
+		Days:        int(conf.Number("WEBIDE_ANALYTICS_DAYS")),
+		MinUsers:    int(conf.Number("WEBIDE_ANALYTICS_MIN_USERS")),
+		Workspaces:  workspaces,
+		Assignments: assignments,
+	}, bus)
+	if err != nil {
+		slog.Error("init analytics", "err", err)
+		os.Exit(1)
+	}
+	defer reports.Close()
+	analytics.NewHandler(reports, accounts, orgs).Register(mux)
+	chores := tasks.NewService(tasks.Config{TaskPackage: conf.String("WEBIDE_TASK_PACKAGE")}, userLauncher)
+	tasks.NewHandler(chores, workspaces, wsOpts).Register(mux)
+	formatter := format.NewService(format.Config{SettingsDir: filepath.Join(dataDir, "format")}, launcher)
+	format.NewHandler(formatter, workspaces).Register(mux)
+	markdown.NewHandler(markdown.Config{}, workspaces).Register(mux)
+	linter := lint.NewService(lint.Config{StaticcheckPackage: conf.String("WEBIDE_STATICCHECK_PACKAGE")}, launcher)
+	lint.NewHandler(linter, workspaces, wsOpts).Register(mux)
+	vulnScans := vulncheck.New(vulncheck.Config{
+		GovulncheckPackage: conf.String("WEBIDE_GOVULNCHECK_PACKAGE"),
+		DB:                 conf.String("WEBIDE_VULN_DB"),
+	}, launcher, fileEvents)
+	vulncheck.NewHandler(vulnScans, workspaces, wsOpts).Register(mux)
+	deps := gomod.NewService(gomod.Config{}, launcher)
+	gomod.NewHandler(deps, workspaces).Register(mux)
+	// Scheduled jobs run as their creators, with the variables and
+	// secrets of their workspaces.
+	jobsCfg := jobs.Config{
+		Dir:          filepath.Join(dataDir, "jobs"),
+		Tests:        tests,
+		Dependencies: deps,
+		Snapshots:    snapshots,
+		Bus:          bus,
+	}
+	if conf.Bool("WEBIDE_AUTH", true) {
+		jobsCfg.Users, jobsCfg.Roles = accounts, members
+	}
+	scheduled, err := jobs.New(jobsCfg, workspaces)
+	if err != nil {
+		slog.Error("init jobs", "err", err)
+		os.Exit(1)
+	}
+	defer scheduled.Close()
+	jobs.NewHandler(scheduled, workspaces).Register(mux)
+	wasmBuilds := wasm.NewService(wasm.Config{TinyGo: conf.Bool("WEBIDE_TINYGO", false)}, launcher)
+	wasm.NewHandler(wasmBuilds, workspaces).Register(mux)
+	imports := ghimport.NewService(ghimport.Config{Host: conf.String("WEBIDE_GITHUB_HOST")}, launcher)
+	defer imports.Close()
+	ghimport.NewHandler(imports, workspaces, accounts).Register(mux)
+	repls := repl.NewService(repl.Config{YaegiModule: conf.String("WEBIDE_YAEGI_MODULE")}, launcher)
+	defer repls.Close()
+	repl.NewHandler(repls, workspaces, wsOpts).Register(mux)
+	notebooks := notebook.NewService(notebook.Config{}, repls)
+	defer notebooks.Close()
+	notebook.NewHandler(notebooks, workspaces).Register(mux)
+	assistant, err := ai.NewService(ai.Config{
+		Endpoint:      conf.String("WEBIDE_AI_ENDPOINT"),
+		APIKey:        conf.String("WEBIDE_AI_API_KEY"),
+		Model:         conf.String("WEBIDE_AI_MODEL"),
+		Dir:           filepath.Join(dataDir, "ai"),
+		MonthlyTokens: int64(conf.Number("WEBIDE_AI_MONTHLY_TOKENS")),
+		Allowed:       features.Check("ai-assistant"),
+	})
+	if err != nil {
+		slog.Error("init ai", "err", err)
+		os.Exit(1)
+	}
+	ai.NewHandler(assistant, workspaces, wsOpts).Register(mux)
+
+	lspCfg := lsp.Config{
+		Command:  strings.Fields(conf.String("WEBIDE_GOPLS")),
+		ToolsDir: filepath.Join(dataDir, "lsp"),
+		Metrics:  reg,
+	}
+	if p := conf.String("WEBIDE_LSP_SERVERS"); p != "" {
+		if lspCfg.Servers, err = lsp.LoadServers(p); err != nil {
+			slog.Error("init language servers", "err", err)
+			os.Exit(1)
+		}
+	}
+	languageServers := lsp.NewManager(lspCfg)
+	defer languageServers.Close()
+	lsp.NewHandler(languageServers, workspaces, wsOpts).Register(mux)
+	goast.NewHandler(workspaces).Register(mux)
+
+	trustProxy := conf.Bool("WEBIDE_TRUST_PROXY", false)
+	var routes http.Handler = tracing.Middleware(tracer, audit.Middleware(auditLog, trustProxy, events.Middleware(bus, admin.Middleware(ops, mux))))
+	if lifecycle != nil {
+		routes = hibernate.Middleware(lifecycle, routes)
+	}
+	if conf.Bool("WEBIDE_RATE_LIMIT", true) {
+		limiter := ratelimit.NewLimiter(ratelimit.Config{TrustProxy: trustProxy})
+		defaults := map[string]ratelimit.Policy{}
+		for _, name := range limiter.Names() {
+			defaults[name] = limiter.Policy(name)
+		}
+		onReload("rate limits", func(c *config.Config) error {
+			var errs []error
+			for name, def := range defaults {
+				p, ok := config.Get(c, "WEBIDE_RATE_LIMIT_"+strings.ToUpper(name), ratelimit.ParsePolicy)
+				if !ok {
+					p = def
+				}
+				errs = append(errs, limiter.SetPolicy(name, p))
+			}
+			return errors.Join(errs...)
+		})
+		routes = ratelimit.Middleware(limiter, routes)
+	}
+	handler := routes
+	if conf.Bool("WEBIDE_AUTH", true) {
+		handler = auth.Middleware(accounts, members, routes)
+	}
+	handler = access.LinkMiddleware(members, routes, handler)
+	handler = playground.Middleware(play, handler)
+	// Versioned API routes are translated into the handlers' v1 ahead of
+	// everything reading API paths.
+	handler = apiversion.Middleware(versions, handler)
+	// Previewed apps are served ahead of the IDE's routes and their auth.
+	handler = ports.Middleware(previews, handler)
+
+	// The probes and metrics are answered ahead of the rate limit and
+	// auth, for load balancers and Prometheus. With WEBIDE_METRICS_ADDR,
+	// the metrics are only served on that address, to keep them off the
+	// public one.
+	outer := http.NewServeMux()
+	probes.Register(outer)
+	metricsAddr := conf.String("WEBIDE_METRICS_ADDR")
+	if metricsAddr == "" {
+		outer.Handle("GET /metrics", reg)
+	}
+	// The core of the API is served over gRPC as well, through the same
+	// handlers, to clients speaking HTTP/2 without TLS.
+	useGRPC := conf.Bool("WEBIDE_GRPC", true)
+	if useGRPC {
+		handler = grpc.Middleware(handler)
+	}
+	outer.Handle("/", drain.Middleware(drainer, handler))
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           outer,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if useGRPC {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	// Settings nothing read are likely misspelled; values that could not
+	// be used stop the server rather than being taken as unset.
+	if err := conf.Err(); err != nil {
+		slog.Error("invalid configuration", "err", err)
+		os.Exit(1)
+	}
+	if unused := conf.Unused(); len(unused) > 0 {
+		slog.Warn("unknown settings in the config file", "file", conf.Path(), "keys", unused)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			res, err := conf.Reload()
+			if err != nil {
+				slog.Error("config reload", "err", err)
+				continue
+			}
+			for _, c := range res.Changes {
+				slog.Info("config reload", "key", c.Key, "live", c.Live, "overridden", c.Overridden)
+			}
+			for _, e := range res.Errors {
+				slog.Error("config reload", "err", e)
+			}
+		}
+	}()
+
+	if metricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", reg)
+		metricsSrv := &http.Server{Addr: metricsAddr, Handler: metricsMux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			slog.Info("metrics listening", "addr", metricsAddr)
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("metrics server failed", "err", err)
+				os.Exit(1)
+			}
+		}()
+		defer metricsSrv.Close()
+	}
+
+	go func() {
+		slog.Info("webide-server listening", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server failed", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	// A second signal kills the server without waiting for the drain.
+	stop()
+	drainTimeout := 30 * time.Second
+	if n := conf.Number("WEBIDE_DRAIN_SECONDS"); n > 0 {
+		drainTimeout = time.Duration(n * float64(time.Second))
+	}
+	slog.Info("draining", "timeout", drainTimeout)
+	drainer.Start()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+
+	// Runs in progress may finish until the drain timeout, and those still
+	// executing then are stopped. Workspace containers are left running,
+	// for the server that takes over the workspace.
+	if err := ops.WaitSandboxes(drainCtx); err != nil {
+		slog.Warn("drain: stopping sandboxes", "count", ops.StopSandboxes())
+	}
+	// Clients are told to reconnect, which takes them to another server.
+	reason := fmt.Sprintf("server restarting; reconnect in %d s", int(math.Ceil(drainer.ReconnectAfter().Seconds())))
+	if n := sockets.CloseAll(ws.CloseServiceRestart, reason); n > 0 {
+		slog.Info("drain: closed sockets", "count", n)
+	}
+	// Collaborative documents and drafts are saved now, so the deferred
+	// Close calls have nothing left to do.
+	documents.Close()
+	drafts.Close()
+
+	// Snapshotting the workspaces in use and finishing the last requests
+	// share a further 15 seconds, however long the sandboxes took.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if active := ops.ActiveWorkspaces(); len(active) > 0 {
+		n := snapshots.Shutdown(shutdownCtx, active)
+		slog.Info("drain: snapshotted workspaces", "count", n, "active", len(active))
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("shutdown", "err", err)
+	}
+}
+
+// storageBackend returns the workspace storage backend of the given kind,
+// configured from conf.
+func storageBackend(conf *config.Config, kind, dataDir string) (storage.Backend, error) {
+	switch kind {
+	case "disk":
+		return storage.NewDisk(conf.StringOr("WEBIDE_STORAGE_DIR", filepath.Join(dataDir, "storage")))
+	case "nfs":
+		return storage.NewNFS(conf.String("WEBIDE_STORAGE_DIR"))
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:     conf.String("WEBIDE_S3_ENDPOINT"),
+			Region:       conf.StringOr("WEBIDE_S3_REGION", os.Getenv("AWS_REGION")),
+			Bucket:       conf.String("WEBIDE_S3_BUCKET"),
+			Prefix:       conf.String("WEBIDE_S3_PREFIX"),
+			AccessKey:    conf.StringOr("WEBIDE_S3_ACCESS_KEY", os.Getenv("AWS_ACCESS_KEY_ID")),
+			SecretKey:    conf.StringOr("WEBIDE_S3_SECRET_KEY", os.Getenv("AWS_SECRET_ACCESS_KEY")),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		})
+	}
+	return nil, fmt.Errorf("unknown WEBIDE_STORAGE %q: want disk, nfs or s3", kind)
+}
+
+// splitLabels parses "key=value" pairs separated by commas.
+func splitLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for _, v := range splitList(s) {
+		key, value, _ := strings.Cut(v, "=")
+		labels[key] = value
+	}
+	return labels
+}
+
+// parseDate parses a date such as 2027-01-31, as UTC midnight.
+func parseDate(s string) (time.Time, error) {
+	return time.Parse(time.DateOnly, s)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/pty"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// config is what webide login saves.
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "webide", "config.json"), nil
+}
+
+// loadConfig reads the saved configuration, overridden by WEBIDE_URL and
+// WEBIDE_TOKEN.
+func loadConfig() (config, error) {
+	var cfg config
+	if p, err := configPath(); err == nil {
+		data, err := os.ReadFile(p)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return cfg, fmt.Errorf("read %s: %w", p, err)
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			return cfg, err
+		}
+	}
+	if v := os.Getenv("WEBIDE_URL"); v != "" {
+		cfg.Server = v
+	}
+	if v := os.Getenv("WEBIDE_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	return cfg, nil
+}
+
+func (cfg config) save() error {
+	p, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(cfg, "", "  ")
+	return os.WriteFile(p, append(data, '\n'), 0o600)
+}
+
+// client calls the server's API with the token.
+type client struct {
+	server *url.URL
+	token  string
+	http   *http.Client
+}
+
+func newClient(cfg config) (*client, error) {
+	if cfg.Server == "" {
+		return nil, errors.New("no server: run webide login or set WEBIDE_URL")
+	}
+	u, err := url.Parse(strings.TrimSuffix(cfg.Server, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("bad server URL %q", cfg.Server)
+	}
+	return &client{server: u, token: cfg.Token, http: &http.Client{}}, nil
+}
+
+// load returns a client for the saved configuration.
+func load() (*client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newClient(cfg)
+}
+
+// do sends a request to path, which may carry a query, and fails for
+// responses other than 2xx with the server's error message.
+func (c *client) do(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.server.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, apiError(resp.StatusCode, data)
+	}
+	return resp, nil
+}
+
+// call sends in, unless nil, as JSON and decodes the response into out,
+// unless nil.
+func (c *client) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	}
+	resp, err := c.do(ctx, method, path, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// dial opens a WebSocket to path.
+func (c *client) dial(ctx context.Context, path string) (*ws.Conn, error) {
+	u := *c.server
+	u.Scheme = map[string]string{"http": "ws", "https": "wss"}[u.Scheme]
+	header := make(http.Header)
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	conn, err := ws.Dial(ctx, u.String()+path, header)
+	var de *ws.DialError
+	if errors.As(err, &de) {
+		return nil, apiError(de.StatusCode, de.Body)
+	}
+	return conn, err
+}
+
+// apiError turns an error response into an error carrying the server's
+// message.
+func apiError(status int, body []byte) error {
+	var e struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		msg = e.Error
+	}
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	if status == http.StatusUnauthorized {
+		msg += " (run webide login)"
+	}
+	return fmt.Errorf("%d: %s", status, msg)
+}
+
+// workspacePath returns the API path of a workspace, with elem appended.
+func workspacePath(id string, elem ...string) string {
+	p := "/api/workspaces/" + url.PathEscape(id)
+	for _, e := range elem {
+		p += "/" + e
+	}
+	return p
+}
+
+func login(ctx context.Context, flags *flag.FlagSet, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	server := flags.String("server", cfg.Server, "`URL` of the IDE, such as https://ide.example.com")
+	flags.Parse(args)
+	if *server == "" {
+		return errors.New("-server is required")
+	}
+	fmt.Fprint(os.Stderr, "Personal access token (empty for a server without sign-in): ")
+	token, err := readSecret(os.Stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	cfg = config{Server: strings.TrimSuffix(*server, "/"), Token: token}
+	c, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	var me struct {
+		Email string `json:"email"`
+	}
+	if token != "" {
+		if err := c.call(ctx, http.MethodGet, "/api/auth/me", nil, &me); err != nil {
+			return err
+		}
+	} else if err := c.call(ctx, http.MethodGet, "/api/workspaces", nil, nil); err != nil {
+		return err
+	}
+	if err := cfg.save(); err != nil {
+		return err
+	}
+	if me.Email != "" {
+		fmt.Fprintf(os.Stderr, "Signed in to %s as %s.\n", cfg.Server, me.Email)
+	} else {
+		fmt.Fprintf(os.Stderr, "Using %s.\n", cfg.Server)
+	}
+	return nil
+}
+
+// readSecret reads a line from f without echoing it when f is a terminal.
+func readSecret(f *os.File) (string, error) {
+	restore, err := pty.MakeRaw(f)
+	if err != nil {
+		line, err := bufio.NewReader(f).ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+	defer restore()
+	var buf []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := f.Read(b); err != nil {
+			return "", err
+		}
+		switch b[0] {
+		case '\r', '\n':
+			return strings.TrimSpace(string(buf)), nil
+		case 3, 4: // Ctrl-C, Ctrl-D
+			return "", errors.New("canceled")
+		case 8, 127:
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+			}
+		default:
+			buf = append(buf, b[0])
+		}
+	}
+}
+
+func listWorkspaces(ctx context.Context, flags *flag.FlagSet, args []string) error {
+	flags.Parse(args)
+	c, err := load()
+	if err != nil {
+		return err
+	}
+	var out struct {
+		Workspaces []struct {
+			ID string `json:"id"`
+		} `json:"workspaces"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/workspaces", nil, &out); err != nil {
+		return err
+	}
+	for _, w := range out.Workspaces {
+		fmt.Println(w.ID)
+	}
+	return nil
+}
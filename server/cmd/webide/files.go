@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/archive"
+)
+
+func pull(ctx context.Context, flags *flag.FlagSet, args []string) error {
+	id := workspaceFlag(flags)
+	all := flags.Bool("all", false, "include .git and what .gitignore lists")
+	flags.Parse(args)
+	if err := requireWorkspace(*id); err != nil {
+		return err
+	}
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	c, err := load()
+	if err != nil {
+		return err
+	}
+	q := url.Values{"format": {"tar.gz"}}
+	if *all {
+		q.Set("all", "1")
+	}
+	resp, err := c.do(ctx, http.MethodGet, workspacePath(*id, "export")+"?"+q.Encode(), nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The archive is unpacked next to the files it replaces, and only
+	// moved over them once it is complete.
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(dir, ".webide-pull-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	n, err := archive.Extract(resp.Body, tmp, archive.Limits{})
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(tmp, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(tmp, p)
+		dst := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		return os.Rename(p, dst)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Pulled %d files into %s.\n", n, dir)
+	return nil
+}
+
+func push(ctx context.Context, flags *flag.FlagSet, args []string) error {
+	id := workspaceFlag(flags)
+	flags.Parse(args)
+	if err := requireWorkspace(*id); err != nil {
+		return err
+	}
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	c, err := load()
+	if err != nil {
+		return err
+	}
+	n := 0
+	err = walkFiles(dir, func(rel, abs string) error {
+		f, err := os.Open(abs)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		resp, err := c.do(ctx, http.MethodPut, workspacePath(*id, "files", escapePath(rel)), f, "application/octet-stream")
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		resp.Body.Close()
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Pushed %d files to %s.\n", n, *id)
+	return nil
+}
+
+// walkFiles calls fn with the slash-separated path relative to dir and
+// the path of each regular file under dir, leaving out .git and what the
+// root .gitignore lists, as workspace exports do.
+func walkFiles(dir string, fn func(rel, abs string) error) error {
+	gitignore, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	ignore := archive.ParseIgnore(".git/\n.webide-pull-*/\n" + string(gitignore))
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(rel, p)
+	})
+}
+
+// escapePath escapes each element of the slash-separated path p.
+func escapePath(p string) string {
+	elems := strings.Split(p, "/")
+	for i, e := range elems {
+		elems[i] = url.PathEscape(e)
+	}
+	return strings.Join(elems, "/")
+}
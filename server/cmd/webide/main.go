@@ -0,0 +1,110 @@
+// Command webide is the IDE's command-line client. It signs in with a
+// personal access token and pushes and pulls workspace files, runs programs
+// with their output streamed to the shell, runs a workspace's tests and
+// opens terminals in it, so the IDE can be scripted from a local shell.
+//
+// Usage:
+//
+//	webide login [-server URL]
+//	webide workspaces
+//	webide pull [-w ID] [-all] [DIR]
+//	webide push [-w ID] [DIR]
+//	webide run [-w ID] [-lang NAME] [-go VERSION] [-race] [PATH]
+//	webide test [-w ID] [-run REGEXP] [-short] [-cover] [-json] [PACKAGE...]
+//	webide terminal [-w ID] [-session NAME] [-shell SHELL]
+//
+// The server and token are read from WEBIDE_URL and WEBIDE_TOKEN, else
+// from the configuration webide login saves. -w defaults to
+// WEBIDE_WORKSPACE.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// command is a webide subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, flags *flag.FlagSet, args []string) error
+}
+
+var commands = []*command{
+	{"login", "[-server URL]", "save the server and a personal access token", login},
+	{"workspaces", "", "list your workspaces", listWorkspaces},
+	{"pull", "[-w ID] [-all] [DIR]", "download a workspace's files into DIR", pull},
+	{"push", "[-w ID] [DIR]", "upload the files of DIR to a workspace", push},
+	{"run", "[-w ID] [-lang NAME] [PATH]", "run a file or directory, streaming its output", run},
+	{"test", "[-w ID] [-run REGEXP] [PACKAGE...]", "run a workspace's tests", test},
+	{"terminal", "[-w ID] [-session NAME] [-shell SHELL]", "open a shell in a workspace", terminal},
+}
+
+// exitError makes the command exit with its status without printing
+// anything more, as for a run whose program failed.
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var cmd *command
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		if os.Args[1] != "help" && os.Args[1] != "-h" && os.Args[1] != "--help" {
+			fmt.Fprintf(os.Stderr, "webide: unknown command %q\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("webide "+cmd.name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: webide %s %s\n", cmd.name, cmd.args)
+		flags.PrintDefaults()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := cmd.run(ctx, flags, os.Args[2:])
+	stop()
+	var exit exitError
+	switch {
+	case errors.As(err, &exit):
+		os.Exit(int(exit))
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "webide %s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: webide <command> [arguments]\n\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun webide <command> -h for a command's flags.")
+}
+
+// workspaceFlag adds the -w flag to flags.
+func workspaceFlag(flags *flag.FlagSet) *string {
+	return flags.String("w", os.Getenv("WEBIDE_WORKSPACE"), "workspace `ID`; defaults to $WEBIDE_WORKSPACE")
+}
+
+// requireWorkspace fails when no workspace was given.
+func requireWorkspace(id string) error {
+	if id == "" {
+		return errors.New("no workspace: pass -w or set WEBIDE_WORKSPACE")
+	}
+	return nil
+}
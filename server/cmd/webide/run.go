@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/gotest"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// reconnects bounds the attempts to resume a stream after losing its
+// socket; the server keeps it for 30 seconds.
+const reconnects = 5
+
+// runFrame is a frame sent on /ws/run.
+type runFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token,omitempty"`
+	After int64  `json:"after,omitempty"`
+	Data  string `json:"data,omitempty"`
+	EOF   bool   `json:"eof,omitempty"`
+	Seq   int64  `json:"seq,omitempty"`
+	*runner.Request
+}
+
+// runEvent is a frame received on /ws/run.
+type runEvent struct {
+	runner.Event
+	Token string `json:"token,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func run(ctx context.Context, flags *flag.FlagSet, args []string) error {
+	id := flags.String("w", os.Getenv("WEBIDE_WORKSPACE"), "workspace `ID` whose environment variables, secrets and Go version the run gets")
+	lang := flags.String("lang", "", "language `NAME`; defaults to go, or for a file to the language of its extension")
+	goVersion := flags.String("go", "", "Go `VERSION` to build with")
+	race := flags.Bool("race", false, "build with the race detector")
+	flags.Parse(args)
+	target := "."
+	if flags.NArg() > 0 {
+		target = flags.Arg(0)
+	}
+	c, err := load()
+	if err != nil {
+		return err
+	}
+	req := runner.Request{Language: *lang, Workspace: *id, GoVersion: *goVersion}
+	req.Options.Race = *race
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = walkFiles(target, func(rel, abs string) error {
+			data, err := os.ReadFile(abs)
+			req.Files = append(req.Files, runner.File{Path: rel, Content: string(data)})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return err
+		}
+		req.Source = string(data)
+		if req.Language == "" {
+			if req.Language, err = languageOf(ctx, c, target); err != nil {
+				return err
+			}
+		}
+	}
+	return streamRun(ctx, c, &req)
+}
+
+// languageOf returns the language whose source file has the extension of
+// the file p, or "" for go.
+func languageOf(ctx context.Context, c *client, p string) (string, error) {
+	ext := filepath.Ext(p)
+	if ext == ".go" {
+		return "", nil
+	}
+	var out struct {
+		Languages []runner.LanguageInfo `json:"languages"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/run/languages", nil, &out); err != nil {
+		return "", err
+	}
+	for _, l := range out.Languages {
+		if filepath.Ext(l.SourceFile) == ext {
+			return l.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no language runs %s files; pass -lang", ext)
+}
+
+// streamRun runs req on /ws/run, copying its output to stdout and stderr
+// and stdin to the program. A lost socket is replaced, resuming the run
+// with its token. It returns an exitError unless the program exited with
+// status 0.
+func streamRun(ctx context.Context, c *client, req *runner.Request) error {
+	conn, err := c.dial(ctx, "/ws/run")
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteJSON(runFrame{Type: "run", Request: req}); err != nil {
+		conn.Close()
+		return err
+	}
+	// Stdin is written to whichever socket is current.
+	var mu sync.Mutex
+	current := conn
+	defer func() {
+		mu.Lock()
+		current.Close()
+		mu.Unlock()
+	}()
+	send := func(f runFrame) {
+		mu.Lock()
+		defer mu.Unlock()
+		current.WriteJSON(f)
+	}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				send(runFrame{Type: "stdin", Data: string(buf[:n])})
+			}
+			if err != nil {
+				send(runFrame{Type: "stdin", EOF: true})
+				return
+			}
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		current.Close()
+		mu.Unlock()
+	}()
+
+	var token string
+	var seq int64
+	for attempt := 0; ; {
+		var ev runEvent
+		err := conn.ReadJSON(&ev)
+		if err != nil {
+			var ce *ws.CloseError
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if token == "" || errors.As(err, &ce) || attempt == reconnects {
+				return fmt.Errorf("lost the run: %w", err)
+			}
+			attempt++
+			time.Sleep(time.Duration(attempt) * time.Second)
+			fmt.Fprintf(os.Stderr, "webide: reconnecting (%v)\n", err)
+			if next, err := c.dial(ctx, "/ws/run"); err == nil {
+				next.WriteJSON(runFrame{Type: "resume", Token: token, After: seq})
+				mu.Lock()
+				current.Close()
+				current, conn = next, next
+				mu.Unlock()
+			}
+			continue
+		}
+		if ev.Seq > 0 {
+			seq = ev.Seq
+			if seq%32 == 0 {
+				send(runFrame{Type: "ack", Seq: seq})
+			}
+		}
+		switch ev.Type {
+		case "session":
+			token = ev.Token
+		case "resumed":
+			attempt = 0
+		case "error":
+			return errors.New(ev.Error)
+		case runner.EventQueued:
+			fmt.Fprintf(os.Stderr, "webide: queued, position %d\n", ev.Position)
+		case runner.EventStdout:
+			io.WriteString(os.Stdout, ev.Data)
+		case runner.EventStderr:
+			io.WriteString(os.Stderr, ev.Data)
+		case runner.EventExited, runner.EventTimedOut:
+			res := ev.Result
+			if res == nil {
+				return errors.New("run ended without a result")
+			}
+			if res.Message != "" {
+				fmt.Fprintf(os.Stderr, "webide: %s\n", res.Message)
+			}
+			switch {
+			case res.TimedOut:
+				return exitError(124)
+			case res.ExitCode != 0:
+				return exitError(res.ExitCode)
+			}
+			return nil
+		}
+	}
+}
+
+func test(ctx context.Context, flags *flag.FlagSet, args []string) error {
+	id := workspaceFlag(flags)
+	var req gotest.Request
+	flags.StringVar(&req.Module, "module", "", "module `DIR` in the workspace, for workspaces holding several")
+	flags.StringVar(&req.Run, "run", "", "run only the tests matching `REGEXP`")
+	flags.StringVar(&req.Skip, "skip", "", "skip the tests matching `REGEXP`")
+	flags.BoolVar(&req.Short, "short", false, "set -short")
+	flags.BoolVar(&req.Cover, "cover", false, "record coverage")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+	if err := requireWorkspace(*id); err != nil {
+		return err
+	}
+	req.Packages = flags.Args()
+	c, err := load()
+	if err != nil {
+		return err
+	}
+	var rep gotest.Report
+	if err := c.call(ctx, http.MethodPost, workspacePath(*id, "tests"), req, &rep); err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+	} else {
+		printReport(&rep)
+	}
+	if !rep.Passed {
+		return exitError(1)
+	}
+	return nil
+}
+
+// printReport prints rep as go test would, with the output of the failed
+// tests only.
+func printReport(rep *gotest.Report) {
+	for _, p := range rep.Packages {
+		for _, t := range p.Tests {
+			if t.Status == gotest.StatusFail {
+				fmt.Print(t.Output)
+			}
+		}
+		switch p.Status {
+		case gotest.StatusFail:
+			fmt.Print(p.Output)
+		default:
+			fmt.Printf("ok  \t%s\t%.3fs\n", p.Package, float64(p.DurationMS)/1000)
+		}
+	}
+	fmt.Print(rep.Output)
+	s := rep.Summary
+	status := "PASS"
+	switch {
+	case rep.TimedOut:
+		status = "TIMEOUT"
+	case !rep.Passed:
+		status = "FAIL"
+	}
+	line := fmt.Sprintf("%s: %d passed, %d failed, %d skipped", status, s.Passed, s.Failed, s.Skipped)
+	if rep.Coverage != nil {
+		line += fmt.Sprintf(", %.1f%% of statements covered", *rep.Coverage)
+	}
+	fmt.Println(line)
+}
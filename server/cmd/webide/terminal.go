@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/pty"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// termFrame is a frame of /ws/terminal, in either direction.
+type termFrame struct {
+	Type     string `json:"type"`
+	Session  string `json:"session,omitempty"`
+	Created  bool   `json:"created,omitempty"`
+	Resumed  bool   `json:"resumed,omitempty"`
+	Seq      int64  `json:"seq,omitempty"`
+	Data     string `json:"data,omitempty"`
+	Cols     uint16 `json:"cols,omitempty"`
+	Rows     uint16 `json:"rows,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+}
+
+func terminal(ctx context.Context, flags *flag.FlagSet, args []string) error {
+	id := workspaceFlag(flags)
+	session := flags.String("session", "", "`NAME` of the session to attach to; defaults to a new one")
+	shell := flags.String("shell", "", "`SHELL` to start, such as bash or sh")
+	flags.Parse(args)
+	if err := requireWorkspace(*id); err != nil {
+		return err
+	}
+	c, err := load()
+	if err != nil {
+		return err
+	}
+	size, err := pty.GetSize(os.Stdout)
+	if err != nil {
+		size = pty.Size{Cols: 80, Rows: 24}
+	}
+	// Sessions outlive the socket, so a lost one is reattached from the
+	// last output received.
+	name, after := *session, int64(-1)
+	attach := func() (*ws.Conn, error) {
+		q := url.Values{"cols": {strconv.Itoa(int(size.Cols))}, "rows": {strconv.Itoa(int(size.Rows))}}
+		if name != "" {
+			q.Set("session", name)
+		}
+		if *shell != "" {
+			q.Set("shell", *shell)
+		}
+		if after >= 0 {
+			q.Set("after", strconv.FormatInt(after, 10))
+		}
+		return c.dial(ctx, "/ws/terminal/"+url.PathEscape(*id)+"?"+q.Encode())
+	}
+	conn, err := attach()
+	if err != nil {
+		return err
+	}
+
+	if restore, err := pty.MakeRaw(os.Stdin); err == nil {
+		defer restore()
+	}
+	var mu sync.Mutex
+	current := conn
+	defer func() {
+		mu.Lock()
+		current.Close()
+		mu.Unlock()
+	}()
+	send := func(f termFrame) {
+		mu.Lock()
+		defer mu.Unlock()
+		current.WriteJSON(f)
+	}
+	stop := pty.NotifySize(os.Stdout, func(s pty.Size) {
+		mu.Lock()
+		size = s
+		mu.Unlock()
+		send(termFrame{Type: "resize", Cols: s.Cols, Rows: s.Rows})
+	})
+	defer stop()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				send(termFrame{Type: "input", Data: string(buf[:n])})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		current.Close()
+		mu.Unlock()
+	}()
+
+	for attempt := 0; ; {
+		var f termFrame
+		err := conn.ReadJSON(&f)
+		if err != nil {
+			var ce *ws.CloseError
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if name == "" || errors.As(err, &ce) || attempt == reconnects {
+				return fmt.Errorf("lost the terminal: %w", err)
+			}
+			attempt++
+			time.Sleep(time.Duration(attempt) * time.Second)
+			if next, err := attach(); err == nil {
+				mu.Lock()
+				current.Close()
+				current, conn = next, next
+				mu.Unlock()
+			}
+			continue
+		}
+		switch f.Type {
+		case "attached":
+			name, attempt = f.Session, 0
+			if after >= 0 && !f.Resumed {
+				// The scrollback that follows is the whole buffer.
+				io.WriteString(os.Stdout, "\x1b[2J\x1b[H")
+			}
+		case "scrollback", "output":
+			io.WriteString(os.Stdout, f.Data)
+			after = f.Seq
+		case "exit":
+			if f.ExitCode != nil && *f.ExitCode != 0 {
+				return exitError(*f.ExitCode)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/VedantPanchal23/Web-IDE/server
+
+go 1.24
@@ -0,0 +1,421 @@
+// Package access shares workspaces. The user who creates a workspace owns
+// it; the owner invites others as editors, who may change files and run
+// code, or viewers, who may only read, for example students looking at a
+// reference solution. The auth middleware asks Role for every workspace
+// request and enforces the answer.
+//
+// Share links let guests without an account follow a workspace's
+// collaborative sessions until the link expires (see LinkMiddleware).
+//
+// Members, invitations and share links are kept in one JSON file, like
+// accounts.
+package access
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the membership file; defaults to a directory under the OS
+	// temp dir.
+	Dir string
+	// InvitationTTL is how long invitations can be accepted; defaults to
+	// 7 days.
+	InvitationTTL time.Duration
+	// MaxLinkTTL caps how long share links work; defaults to 7 days.
+	MaxLinkTTL time.Duration
+}
+
+var (
+	// ErrInvalid is returned for unknown roles, bad email addresses and
+	// attempts to change the owner.
+	ErrInvalid = errors.New("access: invalid request")
+	// ErrNotFound is returned for unknown members, invitations and share
+	// links.
+	ErrNotFound = errors.New("access: not found")
+	// ErrWrongInvitee is returned when someone accepts an invitation sent
+	// to another email address.
+	ErrWrongInvitee = errors.New("access: invitation is for another email address")
+)
+
+// Owners reports who owns a workspace; "" means it predates accounts.
+type Owners interface {
+	Owner(id string) (string, error)
+}
+
+// Orgs grants roles on workspaces owned by an organization, whose owner
+// is "org:" followed by the organization ID.
+type Orgs interface {
+	Role(orgID, workspaceID, userID string) (workspace.Role, error)
+}
+
+// Member is a user a workspace is shared with.
+type Member struct {
+	UserID  string         `json:"userId"`
+	Email   string         `json:"email,omitempty"`
+	Role    workspace.Role `json:"role"`
+	AddedBy string         `json:"addedBy,omitempty"`
+	AddedAt time.Time      `json:"addedAt"`
+}
+
+// Invitation offers a role on a workspace. Whoever holds its token may
+// accept it, provided their email address matches when one is set.
+type Invitation struct {
+	ID        string         `json:"id"`
+	Workspace string         `json:"workspace"`
+	Email     string         `json:"email,omitempty"`
+	Role      workspace.Role `json:"role"`
+	InvitedBy string         `json:"invitedBy,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+}
+
+// Shared is a workspace shared with a user.
+type Shared struct {
+	ID   string         `json:"id"`
+	Role workspace.Role `json:"role"`
+}
+
+type invitationRecord struct {
+	Invitation
+	// Hash is the hex SHA-256 of the invitation token.
+	Hash string `json:"hash"`
+}
+
+// state is what the membership file holds.
+type state struct {
+	// Members maps workspace IDs to user IDs to members.
+	Members     map[string]map[string]*Member `json:"members"`
+	Invitations map[string]*invitationRecord  `json:"invitations"`
+	Links       map[string]*linkRecord        `json:"links"`
+}
+
+// Service manages workspace members, invitations and share links.
+type Service struct {
+	cfg    Config
+	owners Owners
+	orgs   Orgs
+	now    func() time.Time
+
+	mu       sync.Mutex
+	st       state
+	sessions map[string]map[*linkSession]struct{} // by link ID
+}
+
+// NewService returns a Service, filling unset Config fields with defaults
+// and loading stored members. owners tells who owns each workspace; orgs,
+// which may be nil, resolves roles on organization workspaces.
+func NewService(cfg Config, owners Owners, orgs Orgs) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-access")
+	}
+	if cfg.InvitationTTL <= 0 {
+		cfg.InvitationTTL = 7 * 24 * time.Hour
+	}
+	if cfg.MaxLinkTTL <= 0 {
+		cfg.MaxLinkTTL = 7 * 24 * time.Hour
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("access: create dir: %w", err)
+	}
+	s := &Service{cfg: cfg, owners: owners, orgs: orgs, now: time.Now, sessions: make(map[string]map[*linkSession]struct{})}
+	data, err := os.ReadFile(s.path())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("access: read members: %w", err)
+	default:
+		if err := json.Unmarshal(data, &s.st); err != nil {
+			return nil, fmt.Errorf("access: read members: %w", err)
+		}
+	}
+	if s.st.Members == nil {
+		s.st.Members = make(map[string]map[string]*Member)
+	}
+	if s.st.Invitations == nil {
+		s.st.Invitations = make(map[string]*invitationRecord)
+	}
+	if s.st.Links == nil {
+		s.st.Links = make(map[string]*linkRecord)
+	}
+	return s, nil
+}
+
+// Role returns userID's role on a workspace, or "" when it is not shared
+// with them. Workspaces without an owner are open to everyone as owners.
+// On organization workspaces, the role is the higher of what the
+// organization and the workspace's own members grant.
+func (s *Service) Role(workspaceID, userID string) (workspace.Role, error) {
+	owner, err := s.owners.Owner(workspaceID)
+	if err != nil {
+		return "", err
+	}
+	if owner == "" || owner == userID {
+		return workspace.RoleOwner, nil
+	}
+	var role workspace.Role
+	if orgID, ok := strings.CutPrefix(owner, "org:"); ok && s.orgs != nil {
+		if role, err = s.orgs.Role(orgID, workspaceID, userID); err != nil {
+			return "", err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.st.Members[workspaceID][userID]; ok {
+		role = workspace.MaxRole(role, m.Role)
+	}
+	return role, nil
+}
+
+// Members returns the members of a workspace, ordered by when they
+// joined.
+func (s *Service) Members(workspaceID string) []Member {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Member{}
+	for _, m := range s.st.Members[workspaceID] {
+		out = append(out, *m)
+	}
+	slices.SortFunc(out, func(a, b Member) int { return a.AddedAt.Compare(b.AddedAt) })
+	return out
+}
+
+// Shared returns the workspaces shared with userID.
+func (s *Service) Shared(userID string) []Shared {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Shared{}
+	for id, members := range s.st.Members {
+		if m, ok := members[userID]; ok {
+			out = append(out, Shared{ID: id, Role: m.Role})
+		}
+	}
+	slices.SortFunc(out, func(a, b Shared) int { return strings.Compare(a.ID, b.ID) })
+	return out
+}
+
+// SetRole changes a member's role.
+func (s *Service) SetRole(workspaceID, userID string, role workspace.Role) (*Member, error) {
+	if err := checkRole(role); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.st.Members[workspaceID][userID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a member", ErrNotFound, userID)
+	}
+	prev := m.Role
+	m.Role = role
+	if err := s.save(); err != nil {
+		m.Role = prev
+		return nil, err
+	}
+	out := *m
+	return &out, nil
+}
+
+// Remove takes a member off a workspace.
+func (s *Service) Remove(workspaceID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members := s.st.Members[workspaceID]
+	if _, ok := members[userID]; !ok {
+		return fmt.Errorf("%w: %s is not a member", ErrNotFound, userID)
+	}
+	delete(members, userID)
+	if len(members) == 0 {
+		delete(s.st.Members, workspaceID)
+	}
+	return s.save()
+}
+
+// Invite creates an invitation to a workspace and returns it with its
+// token, which is not stored and cannot be shown again. With an empty
+// email, anyone with the token may accept.
+func (s *Service) Invite(workspaceID, email string, role workspace.Role, by string) (*Invitation, string, error) {
+	if err := checkRole(role); err != nil {
+		return nil, "", err
+	}
+	if email != "" {
+		addr, err := mail.ParseAddress(strings.TrimSpace(email))
+		if err != nil || addr.Name != "" {
+			return nil, "", fmt.Errorf("%w: bad email address", ErrInvalid)
+		}
+		email = strings.ToLower(addr.Address)
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := s.now().UTC()
+	inv := &invitationRecord{
+		Invitation: Invitation{
+			ID:        newID("inv-"),
+			Workspace: workspaceID,
+			Email:     email,
+			Role:      role,
+			InvitedBy: by,
+			CreatedAt: now,
+			ExpiresAt: now.Add(s.cfg.InvitationTTL),
+		},
+		Hash: hashToken(token),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, rec := range s.st.Invitations {
+		if !now.Before(rec.ExpiresAt) {
+			delete(s.st.Invitations, id)
+		}
+	}
+	s.st.Invitations[inv.ID] = inv
+	if err := s.save(); err != nil {
+		delete(s.st.Invitations, inv.ID)
+		return nil, "", err
+	}
+	out := inv.Invitation
+	return &out, token, nil
+}
+
+// Invitations returns the open invitations to a workspace, newest first.
+func (s *Service) Invitations(workspaceID string) []Invitation {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Invitation{}
+	for _, rec := range s.st.Invitations {
+		if rec.Workspace == workspaceID && now.Before(rec.ExpiresAt) {
+			out = append(out, rec.Invitation)
+		}
+	}
+	slices.SortFunc(out, func(a, b Invitation) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out
+}
+
+// Revoke deletes an invitation to a workspace.
+func (s *Service) Revoke(workspaceID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.st.Invitations[id]
+	if !ok || rec.Workspace != workspaceID {
+		return fmt.Errorf("%w: no invitation %s", ErrNotFound, id)
+	}
+	delete(s.st.Invitations, id)
+	return s.save()
+}
+
+// Accept makes userID, whose email address is email, a member on the
+// terms of the invitation with the given token. Invitations work once.
+func (s *Service) Accept(token, userID, email string) (*Member, error) {
+	hash := hashToken(token)
+	now := s.now().UTC()
+	s.mu.Lock()
+	var inv *invitationRecord
+	for _, rec := range s.st.Invitations {
+		if rec.Hash == hash {
+			inv = rec
+		}
+	}
+	s.mu.Unlock()
+	if inv == nil || !now.Before(inv.ExpiresAt) {
+		return nil, fmt.Errorf("%w: unknown or expired invitation", ErrNotFound)
+	}
+	if inv.Email != "" && !strings.EqualFold(inv.Email, email) {
+		return nil, ErrWrongInvitee
+	}
+	owner, err := s.owners.Owner(inv.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	if owner == userID {
+		return nil, fmt.Errorf("%w: you own this workspace", ErrInvalid)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.st.Invitations[inv.ID]; !ok {
+		return nil, fmt.Errorf("%w: unknown or expired invitation", ErrNotFound)
+	}
+	members := s.st.Members[inv.Workspace]
+	if members == nil {
+		members = make(map[string]*Member)
+		s.st.Members[inv.Workspace] = members
+	}
+	m := &Member{UserID: userID, Email: email, Role: inv.Role, AddedBy: inv.InvitedBy, AddedAt: now}
+	members[userID] = m
+	delete(s.st.Invitations, inv.ID)
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	out := *m
+	return &out, nil
+}
+
+func checkRole(role workspace.Role) error {
+	switch role {
+	case workspace.RoleEditor, workspace.RoleViewer:
+		return nil
+	case workspace.RoleOwner:
+		return fmt.Errorf("%w: a workspace has exactly one owner", ErrInvalid)
+	}
+	return fmt.Errorf("%w: role must be %s or %s", ErrInvalid, workspace.RoleEditor, workspace.RoleViewer)
+}
+
+func (s *Service) path() string {
+	return filepath.Join(s.cfg.Dir, "members.json")
+}
+
+// save atomically writes the membership file. s.mu must be held.
+func (s *Service) save() error {
+	data, err := json.Marshal(s.st)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.cfg.Dir, ".members-*")
+	if err != nil {
+		return fmt.Errorf("access: write members: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("access: write members: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("access: write members: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path()); err != nil {
+		return fmt.Errorf("access: write members: %w", err)
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newID(prefix string) string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return prefix + hex.EncodeToString(b[:])
+}
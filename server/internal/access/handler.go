@@ -0,0 +1,275 @@
+package access
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Workspaces resolves workspace IDs.
+type Workspaces interface {
+	Open(id string) (string, error)
+	Owner(id string) (string, error)
+}
+
+// Handler serves the member and invitation routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, ws Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: ws}
+}
+
+// Register mounts the access routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/shared", h.shared)
+	mux.HandleFunc("GET /api/workspaces/{id}/members", h.members)
+	mux.HandleFunc("PUT /api/workspaces/{id}/members/{user}", h.setRole)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/members/{user}", h.remove)
+	mux.HandleFunc("GET /api/workspaces/{id}/invitations", h.invitations)
+	mux.HandleFunc("POST /api/workspaces/{id}/invitations", h.invite)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/invitations/{inv}", h.revoke)
+	mux.HandleFunc("POST /api/invitations/{token}/accept", h.accept)
+	mux.HandleFunc("GET /api/workspaces/{id}/share-links", h.links)
+	mux.HandleFunc("POST /api/workspaces/{id}/share-links", h.createLink)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/share-links/{link}", h.revokeLink)
+	mux.HandleFunc("GET /api/share/{token}", h.resolveLink)
+}
+
+// shared lists the workspaces other users shared with the caller.
+func (h *Handler) shared(w http.ResponseWriter, r *http.Request) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.JSON(w, http.StatusOK, map[string]any{"workspaces": []Shared{}})
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"workspaces": h.svc.Shared(u.ID)})
+}
+
+func (h *Handler) members(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r, false)
+	if !ok {
+		return
+	}
+	owner, err := h.workspaces.Owner(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"owner": owner, "members": h.svc.Members(id)})
+}
+
+type roleRequest struct {
+	Role workspace.Role `json:"role"`
+}
+
+func (h *Handler) setRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r, true)
+	if !ok {
+		return
+	}
+	var req roleRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	m, err := h.svc.SetRole(id, r.PathValue("user"), req.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, m)
+}
+
+// remove takes a member off the workspace. Owners remove anyone; members
+// may remove themselves to leave.
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	user := r.PathValue("user")
+	u := auth.UserFrom(r.Context())
+	leaving := u != nil && u.ID == user
+	id, ok := h.workspace(w, r, !leaving)
+	if !ok {
+		return
+	}
+	if err := h.svc.Remove(id, user); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionMemberRemove, Workspace: id, Target: user})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) invitations(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r, true)
+	if !ok {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"invitations": h.svc.Invitations(id)})
+}
+
+type inviteRequest struct {
+	Email string         `json:"email,omitempty"`
+	Role  workspace.Role `json:"role"`
+}
+
+// invite creates an invitation. The response carries its token, which
+// the owner passes on, for example as a link to the IDE, and which is
+// shown only once.
+func (h *Handler) invite(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r, true)
+	if !ok {
+		return
+	}
+	var req inviteRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var by string
+	if u := auth.UserFrom(r.Context()); u != nil {
+		by = u.ID
+	}
+	inv, token, err := h.svc.Invite(id, req.Email, req.Role, by)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, map[string]any{"invitation": inv, "token": token})
+}
+
+func (h *Handler) revoke(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r, true)
+	if !ok {
+		return
+	}
+	if err := h.svc.Revoke(id, r.PathValue("inv")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) accept(w http.ResponseWriter, r *http.Request) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "sign in to accept invitations")
+		return
+	}
+	m, err := h.svc.Accept(r.PathValue("token"), u.ID, u.Email)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, m)
+}
+
+func (h *Handler) links(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r, true)
+	if !ok {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"links": h.svc.Links(id)})
+}
+
+type linkRequest struct {
+	Path       string `json:"path,omitempty"`
+	Access     string `json:"access"`
+	TTLSeconds int    `json:"ttlSeconds,omitempty"`
+}
+
+// createLink makes a share link. Like an invitation's, its token is shown
+// only in this response.
+func (h *Handler) createLink(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r, true)
+	if !ok {
+		return
+	}
+	var req linkRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var by string
+	if u := auth.UserFrom(r.Context()); u != nil {
+		by = u.ID
+	}
+	l, token, err := h.svc.CreateLink(id, req.Path, req.Access, time.Duration(req.TTLSeconds)*time.Second, by)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Action:    audit.ActionShareLinkCreate,
+		Workspace: id,
+		Target:    l.ID,
+		Details:   map[string]string{"path": l.Path, "access": l.Access, "expiresAt": l.ExpiresAt.Format(time.RFC3339)},
+	})
+	httpx.JSON(w, http.StatusCreated, map[string]any{"link": l, "token": token})
+}
+
+func (h *Handler) revokeLink(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r, true)
+	if !ok {
+		return
+	}
+	if err := h.svc.RevokeLink(id, r.PathValue("link")); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionShareLinkRevoke, Workspace: id, Target: r.PathValue("link")})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveLink tells a guest what a share link opens. It is public, like
+// the sessions the link admits to.
+func (h *Handler) resolveLink(w http.ResponseWriter, r *http.Request) {
+	l, err := h.svc.ResolveLink(r.PathValue("token"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{
+		"workspace": l.Workspace,
+		"path":      l.Path,
+		"access":    l.Access,
+		"expiresAt": l.ExpiresAt,
+	})
+}
+
+// workspace returns the workspace a request addresses. With ownerOnly,
+// callers other than the owner get 403.
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request, ownerOnly bool) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	if ownerOnly && workspace.RoleFrom(r.Context()) != workspace.RoleOwner {
+		httpx.Error(w, http.StatusForbidden, "only the owner can manage access")
+		return "", false
+	}
+	return id, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrWrongInvitee):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	default:
+		slog.Error("workspace access", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not update workspace access")
+	}
+}
@@ -0,0 +1,236 @@
+package access
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/collab"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// What share links allow.
+const (
+	// LinkRead lets guests follow the session.
+	LinkRead = "read"
+	// LinkComment also lets them comment.
+	LinkComment = "comment"
+	// LinkFork lets signed-in users fork the workspace instead, such as
+	// students starting from an instructor's project.
+	LinkFork = "fork"
+)
+
+// defaultLinkTTL is how long share links work when their creator does
+// not say.
+const defaultLinkTTL = 24 * time.Hour
+
+// Link is a share link: whoever holds its token may follow the workspace's
+// collaborative sessions, or only the one on Path, without an account
+// until ExpiresAt. Fork links instead let signed-in users fork it.
+type Link struct {
+	ID        string    `json:"id"`
+	Workspace string    `json:"workspace"`
+	Path      string    `json:"path,omitempty"`
+	Access    string    `json:"access"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type linkRecord struct {
+	Link
+	// Hash is the hex SHA-256 of the link token.
+	Hash string `json:"hash"`
+}
+
+// linkSession is a guest's open request, ended when its link is revoked.
+type linkSession struct {
+	cancel context.CancelFunc
+}
+
+// CreateLink makes a share link to a workspace, or to one file in it when
+// path is set, and returns it with its token, which is not stored. ttl
+// defaults to a day and is capped by Config.MaxLinkTTL.
+func (s *Service) CreateLink(workspaceID, path, access string, ttl time.Duration, by string) (*Link, string, error) {
+	if access != LinkRead && access != LinkComment && access != LinkFork {
+		return nil, "", fmt.Errorf("%w: access must be %s, %s or %s", ErrInvalid, LinkRead, LinkComment, LinkFork)
+	}
+	clean, err := files.Clean(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if access == LinkFork && clean != "" {
+		return nil, "", fmt.Errorf("%w: fork links are to whole workspaces", ErrInvalid)
+	}
+	switch {
+	case ttl < 0:
+		return nil, "", fmt.Errorf("%w: negative lifetime", ErrInvalid)
+	case ttl == 0:
+		ttl = min(defaultLinkTTL, s.cfg.MaxLinkTTL)
+	case ttl > s.cfg.MaxLinkTTL:
+		return nil, "", fmt.Errorf("%w: links last at most %s", ErrInvalid, s.cfg.MaxLinkTTL)
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := s.now().UTC()
+	l := &linkRecord{
+		Link: Link{
+			ID:        newID("sl-"),
+			Workspace: workspaceID,
+			Path:      clean,
+			Access:    access,
+			CreatedBy: by,
+			CreatedAt: now,
+			ExpiresAt: now.Add(ttl),
+		},
+		Hash: hashToken(token),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, rec := range s.st.Links {
+		if !now.Before(rec.ExpiresAt) {
+			delete(s.st.Links, id)
+		}
+	}
+	s.st.Links[l.ID] = l
+	if err := s.save(); err != nil {
+		delete(s.st.Links, l.ID)
+		return nil, "", err
+	}
+	out := l.Link
+	return &out, token, nil
+}
+
+// Links returns the share links to a workspace that have not expired,
+// newest first.
+func (s *Service) Links(workspaceID string) []Link {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Link{}
+	for _, rec := range s.st.Links {
+		if rec.Workspace == workspaceID && now.Before(rec.ExpiresAt) {
+			out = append(out, rec.Link)
+		}
+	}
+	slices.SortFunc(out, func(a, b Link) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out
+}
+
+// RevokeLink deletes a share link and ends the sessions opened with it.
+func (s *Service) RevokeLink(workspaceID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.st.Links[id]
+	if !ok || rec.Workspace != workspaceID {
+		return fmt.Errorf("%w: no share link %s", ErrNotFound, id)
+	}
+	delete(s.st.Links, id)
+	for sess := range s.sessions[id] {
+		sess.cancel()
+	}
+	delete(s.sessions, id)
+	return s.save()
+}
+
+// ResolveLink returns the share link with the given token.
+func (s *Service) ResolveLink(token string) (*Link, error) {
+	hash := hashToken(token)
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range s.st.Links {
+		if rec.Hash == hash && now.Before(rec.ExpiresAt) {
+			out := rec.Link
+			return &out, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown or expired share link", ErrNotFound)
+}
+
+// join registers a guest's request under link l and returns its context,
+// which ends when the link expires or is revoked, and a function to call
+// when the request is done.
+func (s *Service) join(ctx context.Context, l *Link) (context.Context, func()) {
+	ctx, cancel := context.WithDeadline(ctx, l.ExpiresAt)
+	sess := &linkSession{cancel: cancel}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.st.Links[l.ID]; !ok {
+		cancel()
+		return ctx, cancel
+	}
+	if s.sessions[l.ID] == nil {
+		s.sessions[l.ID] = make(map[*linkSession]struct{})
+	}
+	s.sessions[l.ID][sess] = struct{}{}
+	return ctx, func() {
+		cancel()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.sessions[l.ID], sess)
+		if len(s.sessions[l.ID]) == 0 {
+			delete(s.sessions, l.ID)
+		}
+	}
+}
+
+// LinkMiddleware admits guests with a share link, passed as ?share=, to
+// the link's collaborative sessions without an account: the collaboration
+// socket of the linked file, or of any file for links without a path, and
+// the workspace's presence. guests serves those requests as viewers;
+// comment links also let them comment, and fork links open no sessions. Other requests go to next, which
+// normally authenticates them.
+func LinkMiddleware(s *Service, guests, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("share")
+		id, path, ok := linkTarget(r)
+		if token == "" || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		l, err := s.ResolveLink(token)
+		if err != nil || l.Access == LinkFork || l.Workspace != id || (l.Path != "" && l.Path != path) {
+			httpx.Error(w, http.StatusNotFound, "share link not found or expired")
+			return
+		}
+		ctx, done := s.join(r.Context(), l)
+		defer done()
+		ctx = workspace.WithRole(ctx, workspace.RoleViewer)
+		if l.Access == LinkComment {
+			ctx = collab.WithComments(ctx)
+		}
+		guests.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// linkTarget returns the workspace and, for collaboration sockets, the
+// file that r addresses when it is a request share links allow.
+func linkTarget(r *http.Request) (id, path string, ok bool) {
+	if r.Method != http.MethodGet {
+		return "", "", false
+	}
+	seg := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 5)
+	switch {
+	case len(seg) == 5 && seg[0] == "ws" && seg[1] == "workspaces" && seg[3] == "collab":
+		clean, err := files.Clean(seg[4])
+		if err != nil || clean == "" {
+			return "", "", false
+		}
+		return seg[2], clean, true
+	case len(seg) == 4 && seg[0] == "api" && seg[1] == "workspaces" && seg[3] == "presence":
+		return seg[2], "", true
+	}
+	return "", "", false
+}
@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+)
+
+// activity is what a user or workspace did recently on this server.
+type activity struct {
+	email string // users only
+	last  time.Time
+	// conns are the requests being served now, which include open
+	// sockets.
+	conns int
+}
+
+// Middleware records each request of a signed-in user, and each request
+// for a workspace, as activity. It must run inside auth.Middleware. Without
+// a Service it returns next.
+func Middleware(s *Service, next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := auth.UserFrom(r.Context())
+		ws := workspaceOf(r)
+		s.begin(u, ws)
+		defer s.end(u, ws)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// workspaceOf returns the workspace r is for, if any.
+func workspaceOf(r *http.Request) string {
+	seg := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 4)
+	switch {
+	case len(seg) >= 3 && (seg[0] == "api" || seg[0] == "ws") && seg[1] == "workspaces":
+		return seg[2]
+	case len(seg) == 3 && seg[0] == "ws" && seg[1] == "lsp":
+		return r.URL.Query().Get("workspace")
+	case len(seg) >= 3 && seg[0] == "ws":
+		// /ws/terminal/{id}, /ws/debug/{id} and the like.
+		return seg[2]
+	}
+	return ""
+}
+
+func (s *Service) begin(u *auth.User, ws string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if u != nil {
+		a := activityIn(s.users, u.ID)
+		a.email, a.last = u.Email, now
+		a.conns++
+	}
+	if ws != "" {
+		a := activityIn(s.workspaces, ws)
+		a.last = now
+		a.conns++
+	}
+}
+
+// end finishes a request begun with begin. Activity with requests open
+// is never pruned, so it is still there.
+func (s *Service) end(u *auth.User, ws string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if u != nil {
+		a := s.users[u.ID]
+		a.last = now
+		a.conns--
+	}
+	if ws != "" {
+		a := s.workspaces[ws]
+		a.last = now
+		a.conns--
+	}
+}
+
+func activityIn(m map[string]*activity, key string) *activity {
+	a := m[key]
+	if a == nil {
+		a = &activity{}
+		m[key] = a
+	}
+	return a
+}
+
+// pruneLocked forgets users and workspaces idle for longer than the
+// window. s.mu must be held.
+func (s *Service) pruneLocked() {
+	cutoff := s.now().Add(-s.cfg.Window)
+	for _, m := range []map[string]*activity{s.users, s.workspaces} {
+		for key, a := range m {
+			if a.conns <= 0 && a.last.Before(cutoff) {
+				delete(m, key)
+			}
+		}
+	}
+}
+
+// UserInfo describes an active user.
+type UserInfo struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	// LastSeen is the time of the user's last request, and Connections
+	// the requests and sockets they have open now.
+	LastSeen    time.Time `json:"lastSeen"`
+	Connections int       `json:"connections"`
+	Sandboxes   int       `json:"sandboxes"`
+}
+
+// Users lists the users seen within the window, most recently seen first.
+func (s *Service) Users() []UserInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	sandboxes := make(map[string]int)
+	for _, r := range s.runs {
+		sandboxes[r.info.User]++
+	}
+	out := make([]UserInfo, 0, len(s.users))
+	for id, a := range s.users {
+		out = append(out, UserInfo{
+			ID:          id,
+			Email:       a.email,
+			LastSeen:    a.last,
+			Connections: a.conns,
+			Sandboxes:   sandboxes[id],
+		})
+	}
+	slices.SortFunc(out, func(a, b UserInfo) int {
+		if c := b.LastSeen.Compare(a.LastSeen); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
+}
+
+// ActiveWorkspaces lists the workspaces used within the window, in no
+// particular order.
+func (s *Service) ActiveWorkspaces() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	out := make([]string, 0, len(s.workspaces))
+	for id := range s.workspaces {
+		out = append(out, id)
+	}
+	return out
+}
@@ -0,0 +1,257 @@
+// Package admin lets the server's administrators see and manage a live
+// instance: the users and workspaces active on it, the sandboxes running
+// programs, each user's quota, and maintenance notices shown to everyone
+// connected.
+//
+// Middleware records the requests of each signed-in user and each
+// workspace, and Sandbox tracks the processes a runner executes so they
+// can be listed and stopped. Both see this server only, so behind a load
+// balancer an administrator manages the server that answers. Notices are
+// relayed to every server through Config.Bus.
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/quota"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Window is how long a user or workspace counts as active after its
+	// last request; defaults to 15 minutes. Open sockets keep them active.
+	Window time.Duration
+	// Workspaces tells the owners of workspaces.
+	Workspaces Workspaces
+	// Quotas, when set, are the quotas administrators adjust.
+	Quotas Quotas
+	// Bus, when set, relays notices between servers.
+	Bus Bus
+}
+
+// Workspaces tells the owner of a workspace.
+type Workspaces interface {
+	Owner(id string) (string, error)
+}
+
+// Terminals lists and ends the terminal sessions of workspaces, as
+// terminal.Service does.
+type Terminals interface {
+	List(workspaceID string) []terminal.Info
+	Kill(workspaceID, name string) error
+}
+
+// Containers manages workspace containers, as the terminal launchers do.
+type Containers interface {
+	Running(ctx context.Context) ([]string, error)
+	Stop(ctx context.Context, workspaceID string) error
+}
+
+// Quotas reports and sets users' limits, as quota.Service does.
+type Quotas interface {
+	Usage(userID string) (*quota.Report, error)
+	SetLimits(userID string, l *quota.Limits) error
+}
+
+// ErrNotFound is returned for sandboxes that are not running here.
+var ErrNotFound = errors.New("admin: not found")
+
+// Service keeps what administrators see of the server.
+type Service struct {
+	cfg        Config
+	now        func() time.Time
+	terminals  Terminals
+	containers Containers
+
+	mu         sync.Mutex
+	users      map[string]*activity
+	workspaces map[string]*activity
+	runs       map[string]*run
+	notice     *Notice
+	subs       map[chan *Notice]struct{}
+
+	unsubscribe func() // set under a bus
+}
+
+// NewService returns a Service, filling unset Config fields with
+// defaults. Under a bus it subscribes to the notices of other servers.
+func NewService(cfg Config) (*Service, error) {
+	if cfg.Window <= 0 {
+		cfg.Window = 15 * time.Minute
+	}
+	s := &Service{
+		cfg:        cfg,
+		now:        time.Now,
+		users:      make(map[string]*activity),
+		workspaces: make(map[string]*activity),
+		runs:       make(map[string]*run),
+		subs:       make(map[chan *Notice]struct{}),
+	}
+	if cfg.Bus != nil {
+		cancel, err := cfg.Bus.Subscribe(noticeChannel, s.receive)
+		if err != nil {
+			return nil, fmt.Errorf("admin: subscribe to notices: %w", err)
+		}
+		s.unsubscribe = cancel
+	}
+	return s, nil
+}
+
+// SetTerminals has the terminal sessions of t listed with each workspace,
+// and ended when it is stopped, and likewise the workspace containers of
+// c. Either may be nil. It must be called before the Service is used.
+func (s *Service) SetTerminals(t Terminals, c Containers) {
+	s.terminals, s.containers = t, c
+}
+
+// Close stops relaying notices and disconnects their subscribers.
+func (s *Service) Close() {
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.subs {
+		close(c)
+	}
+	clear(s.subs)
+}
+
+// WorkspaceInfo describes an active workspace.
+type WorkspaceInfo struct {
+	ID    string `json:"id"`
+	Owner string `json:"owner,omitempty"`
+	// LastSeen is the time of the last request for the workspace, and
+	// Connections the requests and sockets open now.
+	LastSeen    *time.Time `json:"lastSeen,omitempty"`
+	Connections int        `json:"connections"`
+	Sandboxes   int        `json:"sandboxes"`
+	Terminals   int        `json:"terminals"`
+	// Running reports that the workspace's container is running.
+	Running bool `json:"running"`
+}
+
+// Workspaces lists the workspaces used within the window, executing
+// programs, or with a running container, most recently used first.
+func (s *Service) Workspaces(ctx context.Context) ([]WorkspaceInfo, error) {
+	infos := make(map[string]*WorkspaceInfo)
+	get := func(id string) *WorkspaceInfo {
+		if infos[id] == nil {
+			infos[id] = &WorkspaceInfo{ID: id}
+		}
+		return infos[id]
+	}
+	s.mu.Lock()
+	s.pruneLocked()
+	for id, a := range s.workspaces {
+		last := a.last
+		info := get(id)
+		info.LastSeen, info.Connections = &last, a.conns
+	}
+	for _, r := range s.runs {
+		if r.info.Workspace != "" {
+			get(r.info.Workspace).Sandboxes++
+		}
+	}
+	s.mu.Unlock()
+	if s.containers != nil {
+		running, err := s.containers.Running(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("admin: list containers: %w", err)
+		}
+		for _, id := range running {
+			get(id).Running = true
+		}
+	}
+
+	// Listing terminals may wait on other servers, so the workspaces are
+	// asked at once.
+	var wg sync.WaitGroup
+	for id, info := range infos {
+		info.Owner, _ = s.cfg.Workspaces.Owner(id)
+		if s.terminals == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info.Terminals = len(s.terminals.List(id))
+		}()
+	}
+	wg.Wait()
+
+	out := make([]WorkspaceInfo, 0, len(infos))
+	for _, info := range infos {
+		out = append(out, *info)
+	}
+	slices.SortFunc(out, func(a, b WorkspaceInfo) int {
+		switch {
+		case a.LastSeen != nil && b.LastSeen != nil && !a.LastSeen.Equal(*b.LastSeen):
+			return b.LastSeen.Compare(*a.LastSeen)
+		case a.LastSeen != nil && b.LastSeen == nil:
+			return -1
+		case a.LastSeen == nil && b.LastSeen != nil:
+			return 1
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+// StopWorkspace ends everything running for a workspace: its terminal
+// sessions, the programs executing for it and its container. The
+// workspace's files are kept, and its container starts again with the
+// next command run in it.
+func (s *Service) StopWorkspace(ctx context.Context, id string) error {
+	if s.terminals != nil {
+		for _, t := range s.terminals.List(id) {
+			if err := s.terminals.Kill(id, t.Name); err != nil && !errors.Is(err, terminal.ErrNoSession) {
+				slog.Error("admin: kill terminal", "workspace", id, "name", t.Name, "err", err)
+			}
+		}
+	}
+	s.mu.Lock()
+	for _, r := range s.runs {
+		if r.info.Workspace == id {
+			r.cancel(runner.ErrStopped)
+		}
+	}
+	s.mu.Unlock()
+	if s.containers != nil {
+		if err := s.containers.Stop(ctx, id); err != nil {
+			return fmt.Errorf("admin: stop container: %w", err)
+		}
+	}
+	return nil
+}
+
+// Quota reports a user's usage this month and their limits. It needs
+// Config.Quotas.
+func (s *Service) Quota(userID string) (*quota.Report, error) {
+	return s.cfg.Quotas.Usage(userID)
+}
+
+// SetQuota gives a user limits of their own, or with nil returns them to
+// the server's. It needs Config.Quotas.
+func (s *Service) SetQuota(userID string, l *quota.Limits) error {
+	return s.cfg.Quotas.SetLimits(userID, l)
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
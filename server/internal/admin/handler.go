@@ -0,0 +1,328 @@
+package admin
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/quota"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Accounts tells the server's administrators and looks up users, as
+// auth.Service does.
+type Accounts interface {
+	IsAdmin(u *auth.User) bool
+	User(id string) (*auth.User, error)
+}
+
+// Handler serves the admin API, and the notices to every client.
+type Handler struct {
+	svc      *Service
+	accounts Accounts
+	wsOpts   *ws.Options
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, accounts Accounts, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, accounts: accounts, wsOpts: wsOpts}
+}
+
+// Register mounts the admin and notice routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/admin/users", h.users)
+	mux.HandleFunc("GET /api/admin/workspaces", h.workspaces)
+	mux.HandleFunc("POST /api/admin/workspaces/{id}/stop", h.stopWorkspace)
+	mux.HandleFunc("GET /api/admin/sandboxes", h.sandboxes)
+	mux.HandleFunc("POST /api/admin/sandboxes/{id}/stop", h.stopSandbox)
+	mux.HandleFunc("POST /api/admin/notices", h.broadcast)
+	mux.HandleFunc("DELETE /api/admin/notices", h.clearNotice)
+	if h.svc.cfg.Quotas != nil {
+		mux.HandleFunc("GET /api/admin/users/{id}/quota", h.quota)
+		mux.HandleFunc("PUT /api/admin/users/{id}/quota", h.setQuota)
+		mux.HandleFunc("DELETE /api/admin/users/{id}/quota", h.resetQuota)
+	}
+	mux.HandleFunc("GET /api/notices", h.notice)
+	mux.HandleFunc("GET /ws/notices", h.serveNotices)
+}
+
+// admin answers requests from anyone but an administrator with 403.
+func (h *Handler) admin(w http.ResponseWriter, r *http.Request) bool {
+	if !h.accounts.IsAdmin(auth.UserFrom(r.Context())) {
+		httpx.Error(w, http.StatusForbidden, "administrators only")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) users(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"users": h.svc.Users()})
+}
+
+func (h *Handler) workspaces(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	infos, err := h.svc.Workspaces(r.Context())
+	if err != nil {
+		slog.Error("admin: list workspaces", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not list workspaces")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"workspaces": infos})
+}
+
+func (h *Handler) stopWorkspace(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	id := r.PathValue("id")
+	if _, err := h.svc.cfg.Workspaces.Owner(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	if err := h.svc.StopWorkspace(r.Context(), id); err != nil {
+		slog.Error("admin: stop workspace", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not stop the workspace")
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionWorkspaceStop, Workspace: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) sandboxes(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"sandboxes": h.svc.Sandboxes()})
+}
+
+func (h *Handler) stopSandbox(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	info, err := h.svc.StopSandbox(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "sandbox not found")
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Action:    audit.ActionSandboxStop,
+		Workspace: info.Workspace,
+		Target:    info.ID,
+		Details:   map[string]string{"owner": info.User, "image": info.Image},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// noticeRequest is the body of POST /api/admin/notices. Expires may be
+// given as a time or as a number of seconds from now.
+type noticeRequest struct {
+	Level          string     `json:"level"`
+	Message        string     `json:"message"`
+	Expires        *time.Time `json:"expires"`
+	ExpiresSeconds int64      `json:"expiresSeconds"`
+}
+
+func (h *Handler) broadcast(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	var req noticeRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	n := Notice{Level: req.Level, Message: req.Message, Expires: req.Expires}
+	if req.ExpiresSeconds > 0 && n.Expires == nil {
+		t := h.svc.now().Add(time.Duration(req.ExpiresSeconds) * time.Second).UTC()
+		n.Expires = &t
+	}
+	sent, err := h.svc.Broadcast(n)
+	switch {
+	case errors.Is(err, ErrInvalidNotice):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		slog.Error("admin: broadcast notice", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not send the notice")
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Action:  audit.ActionNotice,
+		Target:  sent.ID,
+		Details: map[string]string{"level": sent.Level, "message": sent.Message},
+	})
+	httpx.JSON(w, http.StatusCreated, sent)
+}
+
+func (h *Handler) clearNotice(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	if err := h.svc.ClearNotice(); err != nil {
+		slog.Error("admin: clear notice", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not clear the notice")
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionNoticeClear})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// user answers with 404 when the user of the path does not exist.
+func (h *Handler) user(w http.ResponseWriter, r *http.Request) (*auth.User, bool) {
+	u, err := h.accounts.User(r.PathValue("id"))
+	if errors.Is(err, auth.ErrNotFound) {
+		httpx.Error(w, http.StatusNotFound, "user not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.Error("admin: look up user", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not look up the user")
+		return nil, false
+	}
+	return u, true
+}
+
+// quota reports a user's usage this month and the limits that apply.
+func (h *Handler) quota(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	h.writeQuota(w, u.ID)
+}
+
+// setQuota gives a user limits of their own. Fields left out or zero keep
+// the server's limit, and negative ones disable it.
+func (h *Handler) setQuota(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	var l quota.Limits
+	if err := httpx.DecodeJSON(w, r, &l, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.svc.SetQuota(u.ID, &l); err != nil {
+		slog.Error("admin: set quota", "user", u.ID, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not set the quota")
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Action: audit.ActionQuotaSet,
+		Target: u.ID,
+		Details: map[string]string{
+			"cpuSeconds":    formatLimit(l.CPUSeconds),
+			"memoryGbHours": formatLimit(l.MemoryGBHours),
+			"storageBytes":  formatLimit(float64(l.StorageBytes)),
+			"sandboxes":     formatLimit(float64(l.Sandboxes)),
+		},
+	})
+	h.writeQuota(w, u.ID)
+}
+
+// resetQuota returns a user to the server's limits.
+func (h *Handler) resetQuota(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.SetQuota(u.ID, nil); err != nil {
+		slog.Error("admin: reset quota", "user", u.ID, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not reset the quota")
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionQuotaReset, Target: u.ID})
+	h.writeQuota(w, u.ID)
+}
+
+func (h *Handler) writeQuota(w http.ResponseWriter, userID string) {
+	rep, err := h.svc.Quota(userID)
+	if err != nil {
+		slog.Error("admin: usage", "user", userID, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not read usage")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, rep)
+}
+
+// formatLimit records a requested limit, with "default" for zero.
+func formatLimit(v float64) string {
+	if v == 0 {
+		return "default"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// notice returns {"notice":...}, with null when there is none.
+func (h *Handler) notice(w http.ResponseWriter, r *http.Request) {
+	httpx.JSON(w, http.StatusOK, map[string]any{"notice": h.svc.Notice()})
+}
+
+// noticeFrame is a Notice as sent to clients: {"type":"notice",...} for a
+// new notice and {"type":"clear"} once it is taken down.
+type noticeFrame struct {
+	Type string `json:"type"`
+	*Notice
+}
+
+// serveNotices sends the current notice, then every change to it, until
+// the client disconnects. Client messages are ignored.
+func (h *Handler) serveNotices(w http.ResponseWriter, r *http.Request) {
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	notices, cancel := h.svc.subscribe()
+	defer cancel()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case n, ok := <-notices:
+			if !ok {
+				conn.CloseWithCode(ws.CloseGoingAway, "reconnect for the current notice")
+				return
+			}
+			f := noticeFrame{Type: "notice", Notice: n}
+			if n == nil {
+				f.Type = "clear"
+			}
+			if err := conn.WriteJSON(f); err != nil {
+				return
+			}
+		}
+	}
+}
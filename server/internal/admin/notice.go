@@ -0,0 +1,169 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Levels of a Notice.
+const (
+	LevelInfo     = "info"
+	LevelWarning  = "warning"
+	LevelCritical = "critical"
+)
+
+// maxNotice bounds the length of a notice's message.
+const maxNotice = 1000
+
+// Notice is a message for everyone using the server, such as one about
+// planned maintenance.
+type Notice struct {
+	ID      string    `json:"id"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+	// Expires, if set, is when clients stop showing the notice.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// ErrInvalidNotice is returned for notices without a message, with an
+// unknown level or that have already expired.
+var ErrInvalidNotice = errors.New("admin: invalid notice")
+
+// Bus relays notices between servers. Every server subscribes to the
+// notice channel, and a notice is published there rather than delivered
+// directly, so each server delivers it to its own clients.
+type Bus interface {
+	// Publish sends data to every subscription of channel and returns
+	// how many there are, this server's included.
+	Publish(channel string, data []byte) (int, error)
+	// Subscribe calls fn with the messages published to channel, in
+	// order, until cancel is called. fn is called with nil when messages
+	// may have been missed.
+	Subscribe(channel string, fn func(data []byte)) (cancel func(), err error)
+}
+
+const noticeChannel = "admin:notices"
+
+// busMessage is a notice relayed between servers; a nil Notice clears
+// the current one.
+type busMessage struct {
+	Notice *Notice `json:"notice"`
+}
+
+// Broadcast shows n to everyone connected, on every server, replacing
+// the current notice. It fills in the ID and time, and the level when n
+// has none.
+func (s *Service) Broadcast(n Notice) (*Notice, error) {
+	if n.Level == "" {
+		n.Level = LevelInfo
+	}
+	switch {
+	case n.Message == "":
+		return nil, fmt.Errorf("%w: message is required", ErrInvalidNotice)
+	case len(n.Message) > maxNotice:
+		return nil, fmt.Errorf("%w: message is longer than %d bytes", ErrInvalidNotice, maxNotice)
+	case n.Level != LevelInfo && n.Level != LevelWarning && n.Level != LevelCritical:
+		return nil, fmt.Errorf("%w: level must be %s, %s or %s", ErrInvalidNotice, LevelInfo, LevelWarning, LevelCritical)
+	}
+	n.ID, n.Time = newID(), s.now().UTC()
+	if n.Expires != nil && !n.Expires.After(n.Time) {
+		return nil, fmt.Errorf("%w: expires is in the past", ErrInvalidNotice)
+	}
+	if err := s.send(&n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ClearNotice takes the current notice down everywhere.
+func (s *Service) ClearNotice() error {
+	return s.send(nil)
+}
+
+// Notice returns the current notice, or nil when there is none or it has
+// expired. Under a bus, a server started after a notice was sent does not
+// know it.
+func (s *Service) Notice() *Notice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.currentLocked()
+}
+
+func (s *Service) currentLocked() *Notice {
+	if n := s.notice; n != nil && (n.Expires == nil || s.now().Before(*n.Expires)) {
+		return n
+	}
+	return nil
+}
+
+// send publishes n under a bus and delivers it here otherwise.
+func (s *Service) send(n *Notice) error {
+	if s.cfg.Bus == nil {
+		s.deliver(n)
+		return nil
+	}
+	data, err := json.Marshal(busMessage{Notice: n})
+	if err != nil {
+		return err
+	}
+	if _, err := s.cfg.Bus.Publish(noticeChannel, data); err != nil {
+		return fmt.Errorf("admin: publish notice: %w", err)
+	}
+	return nil
+}
+
+// receive delivers a notice relayed from a server, this one included.
+func (s *Service) receive(data []byte) {
+	if data == nil {
+		// Missed notices are not recovered; the next one replaces them.
+		return
+	}
+	var msg busMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		slog.Warn("admin: invalid notice from bus", "err", err)
+		return
+	}
+	s.deliver(msg.Notice)
+}
+
+// deliver makes n the current notice and sends it to the subscribers. A
+// subscriber that has fallen behind is disconnected, and sees the current
+// notice when it subscribes again.
+func (s *Service) deliver(n *Notice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notice = n
+	for c := range s.subs {
+		select {
+		case c <- n:
+		default:
+			close(c)
+			delete(s.subs, c)
+		}
+	}
+}
+
+// subscribe returns a channel receiving the current notice, if any, and
+// every later one, with nil when the notice is cleared. It is closed when
+// the subscriber falls behind or the Service closes.
+func (s *Service) subscribe() (notices <-chan *Notice, cancel func()) {
+	c := make(chan *Notice, 8)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := s.currentLocked(); n != nil {
+		c <- n
+	}
+	s.subs[c] = struct{}{}
+	return c, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[c]; ok {
+			delete(s.subs, c)
+			close(c)
+		}
+	}
+}
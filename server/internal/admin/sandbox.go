@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+)
+
+// SandboxInfo describes a process executing in a sandbox.
+type SandboxInfo struct {
+	ID string `json:"id"`
+	// User is who started it, and Workspace what for; both are empty for
+	// runs without them, such as shared snippets.
+	User      string        `json:"user,omitempty"`
+	Workspace string        `json:"workspace,omitempty"`
+	Image     string        `json:"image"`
+	Limits    runner.Limits `json:"limits"`
+	Network   bool          `json:"network,omitempty"`
+	StartedAt time.Time     `json:"startedAt"`
+}
+
+// run is a process executing in a tracked sandbox.
+type run struct {
+	info   SandboxInfo
+	cancel context.CancelCauseFunc
+}
+
+// Sandbox tracks the processes inner executes in s, so administrators
+// can list and stop them.
+func Sandbox(s *Service, inner runner.Sandbox) runner.Sandbox {
+	return &sandbox{s: s, inner: inner}
+}
+
+type sandbox struct {
+	s     *Service
+	inner runner.Sandbox
+}
+
+func (sb *sandbox) Exec(ctx context.Context, spec runner.Spec) (*runner.ExecResult, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	r := &run{
+		info: SandboxInfo{
+			ID:        newID(),
+			Workspace: spec.Workspace,
+			Image:     spec.Image,
+			Limits:    spec.Limits,
+			Network:   spec.Network,
+			StartedAt: sb.s.now(),
+		},
+		cancel: cancel,
+	}
+	if u := auth.UserFrom(ctx); u != nil {
+		r.info.User = u.ID
+	}
+	sb.s.mu.Lock()
+	sb.s.runs[r.info.ID] = r
+	sb.s.mu.Unlock()
+	defer func() {
+		sb.s.mu.Lock()
+		delete(sb.s.runs, r.info.ID)
+		sb.s.mu.Unlock()
+	}()
+
+	res, err := sb.inner.Exec(ctx, spec)
+	if errors.Is(context.Cause(ctx), runner.ErrStopped) {
+		return nil, runner.ErrStopped
+	}
+	return res, err
+}
+
+// Sandboxes lists the processes executing here, oldest first.
+func (s *Service) Sandboxes() []SandboxInfo {
+	s.mu.Lock()
+	out := make([]SandboxInfo, 0, len(s.runs))
+	for _, r := range s.runs {
+		out = append(out, r.info)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(out, func(a, b SandboxInfo) int {
+		if c := a.StartedAt.Compare(b.StartedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
+}
+
+// StopSandbox kills a process executing here. Its run ends with
+// runner.ErrStopped.
+func (s *Service) StopSandbox(id string) (SandboxInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.runs[id]
+	if r == nil {
+		return SandboxInfo{}, ErrNotFound
+	}
+	r.cancel(runner.ErrStopped)
+	return r.info, nil
+}
+
+// WaitSandboxes waits until no process is executing here, or until ctx is
+// done. It does not stop new ones from starting.
+func (s *Service) WaitSandboxes(ctx context.Context) error {
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		s.mu.Lock()
+		n := len(s.runs)
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+// StopSandboxes kills every process executing here, as StopSandbox does,
+// and returns how many there were.
+func (s *Service) StopSandboxes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.runs {
+		r.cancel(runner.ErrStopped)
+	}
+	return len(s.runs)
+}
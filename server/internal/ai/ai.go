@@ -0,0 +1,346 @@
+// Package ai proxies code assistance to a language model: completions at
+// the cursor, explanations of errors, and unit tests for a function. The
+// backend is any server speaking the OpenAI chat completions API, hosted
+// or local, such as Ollama, llama.cpp or vLLM.
+//
+// Each request is assembled on the server from the open file, which may
+// be the editor's unsaved buffer, and its diagnostics, so clients only
+// name what they want. Replies are streamed as the model writes them.
+//
+// Users get a monthly budget of tokens, counting both the prompt and the
+// reply. Tokens are counted as the backend reports them, or estimated
+// from the text when it does not.
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+)
+
+var (
+	// ErrDisabled is returned when no backend is configured.
+	ErrDisabled = errors.New("ai: assistance is not configured")
+	// ErrNotAllowed is returned for users Config.Allowed leaves out.
+	ErrNotAllowed = errors.New("ai: assistance is not enabled for this user")
+	// ErrInvalidRequest is returned for requests that cannot be answered,
+	// such as a cursor outside the file.
+	ErrInvalidRequest = errors.New("ai: invalid request")
+	// ErrBudgetExceeded is returned once a user has spent their tokens for
+	// the month.
+	ErrBudgetExceeded = errors.New("ai: token budget exceeded")
+	// ErrTooManyRequests is returned when a user is at
+	// Config.MaxConcurrent.
+	ErrTooManyRequests = errors.New("ai: too many requests in progress")
+	// ErrBackend wraps failures of the model backend.
+	ErrBackend = errors.New("ai: backend failed")
+)
+
+// Config configures a Service.
+type Config struct {
+	// Endpoint is the base URL of the OpenAI-compatible API, such as
+	// "https://api.openai.com/v1" or "http://localhost:11434/v1"; empty
+	// disables assistance.
+	Endpoint string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+	// Model defaults to "gpt-4o-mini".
+	Model string
+	// Dir holds the usage file; defaults to a directory under the OS temp
+	// dir.
+	Dir string
+	// MonthlyTokens is each user's budget per calendar month (UTC);
+	// defaults to 500000. Negative values disable the budget.
+	MonthlyTokens int64
+	// MaxContextBytes caps the file context of one prompt; defaults to
+	// 32 KiB.
+	MaxContextBytes int
+	// MaxTokens caps the reply to explanations and tests; defaults to
+	// 2048. MaxCompletionTokens caps completions; defaults to 256.
+	MaxTokens           int
+	MaxCompletionTokens int
+	// Timeout bounds one request to the backend; defaults to 2 minutes.
+	Timeout time.Duration
+	// MaxConcurrent caps each user's requests in flight; defaults to 2.
+	MaxConcurrent int
+	// Allowed, when set, reports whether the user in ctx may be assisted,
+	// such as by a feature flag.
+	Allowed func(ctx context.Context) bool
+	Client  *http.Client
+}
+
+// Usage counts the tokens of a request.
+type Usage struct {
+	PromptTokens     int64 `json:"promptTokens"`
+	CompletionTokens int64 `json:"completionTokens"`
+	// Estimated reports that the backend did not count the tokens.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// Response is the backend's full reply.
+type Response struct {
+	Text  string `json:"text"`
+	Usage Usage  `json:"usage"`
+}
+
+// Report is a user's token usage this month.
+type Report struct {
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	Used        int64     `json:"used"`
+	// Limit is negative when usage is not limited.
+	Limit int64 `json:"limit"`
+}
+
+// record is what the usage file holds for a user.
+type record struct {
+	// Period is the month usage accrued in, as "2006-01".
+	Period string `json:"period"`
+	Tokens int64  `json:"tokens"`
+}
+
+// Service answers assistance requests.
+type Service struct {
+	cfg Config
+	now func() time.Time
+
+	mu     sync.Mutex
+	usage  map[string]*record
+	active map[string]int
+}
+
+// NewService returns a Service, filling unset Config fields with defaults
+// and loading recorded usage.
+func NewService(cfg Config) (*Service, error) {
+	if cfg.Model == "" {
+		cfg.Model = "gpt-4o-mini"
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-ai")
+	}
+	if cfg.MonthlyTokens == 0 {
+		cfg.MonthlyTokens = 500000
+	}
+	if cfg.MaxContextBytes <= 0 {
+		cfg.MaxContextBytes = 32 << 10
+	}
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = 2048
+	}
+	if cfg.MaxCompletionTokens <= 0 {
+		cfg.MaxCompletionTokens = 256
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Minute
+	}
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 2
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{}
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("ai: create dir: %w", err)
+	}
+	s := &Service{cfg: cfg, now: time.Now, usage: make(map[string]*record), active: make(map[string]int)}
+	data, err := os.ReadFile(s.path())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("ai: read usage: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.usage); err != nil {
+			return nil, fmt.Errorf("ai: read usage: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Enabled reports whether a backend is configured.
+func (s *Service) Enabled() bool { return s.cfg.Endpoint != "" }
+
+// Assist answers req for the workspace at dir, passing the reply to emit
+// as it is written; emit may be nil. The user in ctx is charged for the
+// tokens; requests without a user, as when authentication is off, are not
+// metered.
+func (s *Service) Assist(ctx context.Context, dir string, task Task, req Request, emit func(string)) (*Response, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+	if s.cfg.Allowed != nil && !s.cfg.Allowed(ctx) {
+		return nil, ErrNotAllowed
+	}
+	doc, err := s.load(dir, req)
+	if err != nil {
+		return nil, err
+	}
+	msgs, maxTokens, err := s.prompt(task, doc, req)
+	if err != nil {
+		return nil, err
+	}
+	if emit == nil {
+		emit = func(string) {}
+	}
+
+	var userID string
+	if u := auth.UserFrom(ctx); u != nil {
+		userID = u.ID
+		left, err := s.acquire(userID)
+		if err != nil {
+			return nil, err
+		}
+		defer s.release(userID)
+		prompt := estimate(msgs)
+		if left >= 0 && prompt >= left {
+			return nil, fmt.Errorf("%w: the request needs about %d tokens and %d are left this month", ErrBudgetExceeded, prompt, left)
+		}
+		if left >= 0 {
+			maxTokens = int(min(int64(maxTokens), left-prompt))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	usage, text, err := s.chat(ctx, chatRequest{Messages: msgs, MaxTokens: maxTokens, Temperature: temperature(task)}, emit)
+	if usage == (Usage{}) {
+		usage = Usage{PromptTokens: estimate(msgs), CompletionTokens: tokens(text), Estimated: true}
+	}
+	// A failed or canceled reply still used the tokens streamed so far.
+	if userID != "" && (err == nil || text != "") {
+		s.charge(userID, usage.PromptTokens+usage.CompletionTokens)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if task == Complete {
+		text = cleanCompletion(text)
+	}
+	return &Response{Text: text, Usage: usage}, nil
+}
+
+// temperature keeps completions predictable and lets prose vary a little.
+func temperature(task Task) float64 {
+	if task == Complete {
+		return 0
+	}
+	return 0.2
+}
+
+// tokens estimates the tokens of text at four bytes each, the usual ratio
+// for code and English.
+func tokens(text string) int64 {
+	return int64(len(text)+3) / 4
+}
+
+func estimate(msgs []message) int64 {
+	var n int64
+	for _, m := range msgs {
+		n += tokens(m.Content) + 4
+	}
+	return n
+}
+
+// acquire admits a request of the user, returning the tokens they have
+// left, or -1 when unlimited.
+func (s *Service) acquire(userID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := s.recordLocked(userID)
+	limit := s.cfg.MonthlyTokens
+	if limit >= 0 && rec.Tokens >= limit {
+		return 0, fmt.Errorf("%w: all %d tokens of this month used", ErrBudgetExceeded, limit)
+	}
+	if s.active[userID] >= s.cfg.MaxConcurrent {
+		return 0, ErrTooManyRequests
+	}
+	s.active[userID]++
+	if limit < 0 {
+		return -1, nil
+	}
+	return limit - rec.Tokens, nil
+}
+
+func (s *Service) release(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[userID]--; s.active[userID] <= 0 {
+		delete(s.active, userID)
+	}
+}
+
+func (s *Service) charge(userID string, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(userID).Tokens += n
+	if err := s.save(); err != nil {
+		// The usage stays counted in memory and is saved with the next
+		// charge.
+		slog.Error("save ai usage", "err", err)
+	}
+}
+
+// Usage reports a user's tokens this month.
+func (s *Service) Usage(userID string) Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := periodStart(s.now())
+	return Report{
+		PeriodStart: start,
+		PeriodEnd:   start.AddDate(0, 1, 0),
+		Used:        s.recordLocked(userID).Tokens,
+		Limit:       max(s.cfg.MonthlyTokens, -1),
+	}
+}
+
+// recordLocked returns the user's record for the current month, starting
+// a new one when the month has changed. s.mu must be held.
+func (s *Service) recordLocked(userID string) *record {
+	period := periodStart(s.now()).Format("2006-01")
+	rec, ok := s.usage[userID]
+	if !ok || rec.Period != period {
+		rec = &record{Period: period}
+		s.usage[userID] = rec
+	}
+	return rec
+}
+
+func periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *Service) path() string {
+	return filepath.Join(s.cfg.Dir, "usage.json")
+}
+
+// save atomically writes the usage file. s.mu must be held.
+func (s *Service) save() error {
+	data, err := json.Marshal(s.usage)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.cfg.Dir, ".usage.json-*")
+	if err != nil {
+		return fmt.Errorf("ai: write usage: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ai: write usage: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ai: write usage: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path()); err != nil {
+		return fmt.Errorf("ai: write usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// message is a chat message of the OpenAI chat completions API.
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []message      `json:"messages"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Temperature   float64        `json:"temperature"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Stop          []string       `json:"stop,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chunk is one server-sent event of a streamed completion. The last
+// carries the usage when the backend reports it.
+type chunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *apiError `json:"error"`
+}
+
+type apiError struct {
+	Message string `json:"message"`
+}
+
+// chat streams a completion from the backend, passing each piece of text
+// to emit. Usage is zero when the backend does not report it.
+func (s *Service) chat(ctx context.Context, req chatRequest, emit func(string)) (Usage, string, error) {
+	req.Model = s.cfg.Model
+	req.Stream = true
+	req.StreamOptions = &streamOptions{IncludeUsage: true}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Usage{}, "", err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.cfg.Endpoint, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return Usage{}, "", err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("Accept", "text/event-stream")
+	if s.cfg.APIKey != "" {
+		hreq.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	}
+	resp, err := s.cfg.Client.Do(hreq)
+	if err != nil {
+		return Usage{}, "", fmt.Errorf("%w: %v", ErrBackend, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		var e struct {
+			Error *apiError `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Error != nil {
+			msg = e.Error.Message
+		}
+		return Usage{}, "", fmt.Errorf("%w: backend answered %s: %s", ErrBackend, resp.Status, msg)
+	}
+
+	var (
+		usage Usage
+		text  strings.Builder
+	)
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var c chunk
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			return usage, text.String(), fmt.Errorf("%w: malformed stream: %v", ErrBackend, err)
+		}
+		if c.Error != nil {
+			return usage, text.String(), fmt.Errorf("%w: %s", ErrBackend, c.Error.Message)
+		}
+		if c.Usage != nil {
+			usage = Usage{PromptTokens: c.Usage.PromptTokens, CompletionTokens: c.Usage.CompletionTokens}
+		}
+		for _, ch := range c.Choices {
+			if ch.Delta.Content != "" {
+				text.WriteString(ch.Delta.Content)
+				emit(ch.Delta.Content)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return usage, text.String(), ctx.Err()
+		}
+		return usage, text.String(), fmt.Errorf("%w: %v", ErrBackend, err)
+	}
+	return usage, text.String(), nil
+}
@@ -0,0 +1,171 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// maxRequestBytes bounds a request, which may carry an unsaved buffer.
+const maxRequestBytes = 8 << 20
+
+// Handler serves the assistance API for workspaces.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler answering with svc. wsOpts configures the
+// WebSocket upgrade for streamed replies and may be nil.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the assistance routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/workspaces/{id}/ai/complete", h.task(Complete))
+	mux.HandleFunc("POST /api/workspaces/{id}/ai/explain", h.task(Explain))
+	mux.HandleFunc("POST /api/workspaces/{id}/ai/tests", h.task(Tests))
+	mux.HandleFunc("GET /ws/ai/{id}", h.serveStream)
+	mux.HandleFunc("GET /api/ai/usage", h.usage)
+}
+
+// task answers one request with the full reply.
+func (h *Handler) task(t Task) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		dir, err := h.workspaces.Open(id)
+		if err != nil {
+			httpx.Error(w, http.StatusNotFound, "workspace not found")
+			return
+		}
+		var req Request
+		if err := httpx.DecodeJSON(w, r, &req, maxRequestBytes); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		res, err := h.svc.Assist(r.Context(), dir, t, req, nil)
+		if err != nil {
+			status, msg := errorStatus(id, err)
+			httpx.Error(w, status, msg)
+			return
+		}
+		httpx.JSON(w, http.StatusOK, res)
+	}
+}
+
+// errorStatus maps an Assist error to an HTTP status and client message.
+func errorStatus(id string, err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrBudgetExceeded), errors.Is(err, ErrTooManyRequests):
+		return http.StatusTooManyRequests, err.Error()
+	case errors.Is(err, ErrDisabled):
+		return http.StatusServiceUnavailable, err.Error()
+	case errors.Is(err, ErrNotAllowed):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "the model took too long to answer"
+	case errors.Is(err, ErrBackend):
+		slog.Warn("ai backend", "workspace", id, "err", err)
+		return http.StatusBadGateway, err.Error()
+	}
+	slog.Error("ai", "workspace", id, "err", err)
+	return http.StatusInternalServerError, "could not answer the request"
+}
+
+// clientFrame is the opening message sent by the editor over /ws/ai.
+type clientFrame struct {
+	Type Task `json:"type"`
+	Request
+}
+
+// deltaFrame carries the next piece of the reply.
+type deltaFrame struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// doneFrame ends the stream with the full reply.
+type doneFrame struct {
+	Type string `json:"type"`
+	*Response
+}
+
+// errorFrame reports a request-level failure.
+type errorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// serveStream handles /ws/ai/{id}. The client sends a
+// {"type":"complete"|"explain"|"tests", ...Request} frame; the server
+// replies with delta frames as the model writes, then a done frame, and
+// closes the socket. Closing it early cancels the request.
+func (h *Handler) serveStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var first clientFrame
+	if err := conn.ReadJSON(&first); err != nil {
+		conn.WriteJSON(errorFrame{Type: "error", Error: "expected a request frame"})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	res, err := h.svc.Assist(ctx, dir, first.Type, first.Request, func(text string) {
+		if werr := conn.WriteJSON(deltaFrame{Type: "delta", Text: text}); werr != nil {
+			cancel()
+		}
+	})
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			_, msg := errorStatus(id, err)
+			conn.WriteJSON(errorFrame{Type: "error", Error: msg})
+		}
+		return
+	}
+	conn.WriteJSON(doneFrame{Type: "done", Response: res})
+	conn.CloseWithCode(ws.CloseNormal, "reply finished")
+}
+
+// usage reports the caller's tokens this month and their budget.
+func (h *Handler) usage(w http.ResponseWriter, r *http.Request) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "usage is metered per account; sign in")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, h.svc.Usage(u.ID))
+}
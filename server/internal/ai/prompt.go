@@ -0,0 +1,402 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Task names a kind of assistance.
+type Task string
+
+const (
+	// Complete suggests the code to insert at the cursor.
+	Complete Task = "complete"
+	// Explain explains an error and how to fix it.
+	Explain Task = "explain"
+	// Tests writes unit tests for a function.
+	Tests Task = "tests"
+)
+
+// Request asks for assistance with a file of a workspace.
+type Request struct {
+	// Path is the open file, relative to the workspace root.
+	Path string `json:"path"`
+	// Content is the editor's buffer, which may be unsaved; the saved file
+	// is read when it is nil.
+	Content *string `json:"content,omitempty"`
+	// Line and Column are the 1-based cursor position, in bytes, that
+	// Complete inserts at.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Error is the message Explain explains, such as a compiler error or a
+	// panic; the file's diagnostics are explained when it is empty.
+	Error string `json:"error,omitempty"`
+	// Function is the function Tests tests, as "Name" or "Type.Method".
+	Function string `json:"function,omitempty"`
+	// Diagnostics are the editor's problems for the file. For Go files
+	// without any, the server reports syntax errors itself.
+	Diagnostics []diag.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// document is the open file with its problems.
+type document struct {
+	path  string
+	lang  string
+	text  string
+	diags []diag.Diagnostic
+}
+
+var languages = map[string]string{
+	".go": "go", ".mod": "go.mod", ".js": "javascript", ".mjs": "javascript",
+	".ts": "typescript", ".tsx": "tsx", ".jsx": "jsx", ".py": "python",
+	".rs": "rust", ".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp",
+	".java": "java", ".rb": "ruby", ".sh": "sh", ".sql": "sql",
+	".html": "html", ".css": "css", ".json": "json", ".yaml": "yaml",
+	".yml": "yaml", ".toml": "toml", ".md": "markdown", ".proto": "protobuf",
+}
+
+// load assembles the open file of req from the workspace at dir.
+func (s *Service) load(dir string, req Request) (*document, error) {
+	if req.Path == "" {
+		if req.Content != nil {
+			return nil, fmt.Errorf("%w: content needs a path", ErrInvalidRequest)
+		}
+		return nil, nil
+	}
+	p, err := files.Clean(req.Path)
+	if err != nil || p == "" {
+		return nil, fmt.Errorf("%w: invalid path", ErrInvalidRequest)
+	}
+	doc := &document{path: p, lang: languages[path.Ext(p)]}
+	if req.Content != nil {
+		doc.text = *req.Content
+	} else {
+		f, err := files.New(dir)
+		if err != nil {
+			return nil, err
+		}
+		data, err := f.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, files.ErrIsDir) {
+			return nil, fmt.Errorf("%w: no file %s", ErrInvalidRequest, p)
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc.text = string(data)
+	}
+	if len(doc.text) > maxFileBytes {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidRequest, p)
+	}
+	for _, d := range req.Diagnostics {
+		if d.File == "" || d.File == p {
+			doc.diags = append(doc.diags, d)
+		}
+	}
+	if len(doc.diags) == 0 && doc.lang == "go" {
+		doc.diags = syntaxErrors(p, doc.text)
+	}
+	return doc, nil
+}
+
+// maxFileBytes bounds the files read for context; only an excerpt is
+// sent to the backend.
+const maxFileBytes = 4 << 20
+
+func syntaxErrors(p, src string) []diag.Diagnostic {
+	_, err := parser.ParseFile(token.NewFileSet(), p, src, parser.AllErrors|parser.SkipObjectResolution)
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return nil
+	}
+	list.RemoveMultiples()
+	var ds []diag.Diagnostic
+	for _, e := range list {
+		pos := diag.Position{Line: e.Pos.Line, Column: e.Pos.Column}
+		ds = append(ds, diag.Diagnostic{File: p, Range: diag.Range{Start: pos, End: pos}, Severity: diag.SeverityError, Source: "compiler", Message: e.Msg})
+	}
+	return ds
+}
+
+// prompt returns the conversation asking the backend for task, and the
+// most tokens the reply may take.
+func (s *Service) prompt(task Task, doc *document, req Request) ([]message, int, error) {
+	budget := s.cfg.MaxContextBytes
+	var b strings.Builder
+	switch task {
+	case Complete:
+		if doc == nil {
+			return nil, 0, fmt.Errorf("%w: completion needs a path", ErrInvalidRequest)
+		}
+		off, ok := offset(doc.text, req.Line, req.Column)
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: cursor %d:%d is outside %s", ErrInvalidRequest, req.Line, req.Column, doc.path)
+		}
+		prefix, suffix := doc.text[:off], doc.text[off:]
+		// The code before the cursor matters most.
+		if n := budget / 4; len(suffix) > n {
+			suffix = trimEnd(suffix, n)
+		}
+		if n := budget - len(suffix); len(prefix) > n {
+			prefix = trimStart(prefix, n)
+		}
+		fmt.Fprintf(&b, "File: %s\n\n%s", doc.path, fence(doc.lang, prefix+cursor+suffix))
+		writeDiagnostics(&b, doc)
+		return []message{
+			{Role: "system", Content: completeInstructions},
+			{Role: "user", Content: b.String()},
+		}, s.cfg.MaxCompletionTokens, nil
+
+	case Explain:
+		if req.Error == "" && (doc == nil || len(doc.diags) == 0) {
+			return nil, 0, fmt.Errorf("%w: there is no error to explain", ErrInvalidRequest)
+		}
+		if req.Error != "" {
+			msg := req.Error
+			if len(msg) > budget/4 {
+				msg = trimEnd(msg, budget/4)
+			}
+			fmt.Fprintf(&b, "Error:\n%s\n", fence("", msg))
+			budget -= len(msg)
+		}
+		if doc != nil {
+			writeDiagnostics(&b, doc)
+			line := 1
+			if d, ok := diag.ParseLine(firstLine(req.Error)); ok && path.Base(d.File) == path.Base(doc.path) {
+				line = d.Range.Start.Line
+			} else if len(doc.diags) > 0 {
+				line = doc.diags[0].Range.Start.Line
+			}
+			fmt.Fprintf(&b, "\n%s, with line numbers:\n%s", doc.path, fence(doc.lang, excerpt(doc.text, line, budget)))
+		}
+		return []message{
+			{Role: "system", Content: explainInstructions},
+			{Role: "user", Content: b.String()},
+		}, s.cfg.MaxTokens, nil
+
+	case Tests:
+		if doc == nil || doc.lang != "go" {
+			return nil, 0, fmt.Errorf("%w: tests are written for Go files", ErrInvalidRequest)
+		}
+		pkg, imports, src, err := function(doc.text, req.Function)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(src) > budget {
+			src = trimEnd(src, budget)
+		}
+		fmt.Fprintf(&b, "Write tests for %s in package %s, file %s.\n", req.Function, pkg, doc.path)
+		if len(imports) > 0 {
+			fmt.Fprintf(&b, "The file imports %s.\n", strings.Join(imports, ", "))
+		}
+		fmt.Fprintf(&b, "\n%s", fence("go", src))
+		return []message{
+			{Role: "system", Content: testsInstructions},
+			{Role: "user", Content: b.String()},
+		}, s.cfg.MaxTokens, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unknown task %q", ErrInvalidRequest, task)
+}
+
+// cursor marks where a completion is inserted.
+const cursor = "<CURSOR>"
+
+const (
+	completeInstructions = "You complete code in an editor. Reply with only the text to insert at " + cursor + ", " +
+		"continuing the code before it and fitting the code after it. Do not repeat existing code, " +
+		"explain, or use Markdown fences. Reply with nothing if no completion fits."
+	explainInstructions = "You are a programming assistant in a web IDE. Explain briefly what causes the error " +
+		"and how to fix it, quoting the corrected code where it helps. Answer in Markdown."
+	testsInstructions = "You write Go unit tests. Reply with one complete _test.go file in the same package " +
+		"that tests the function with the standard testing package, table-driven where it fits, " +
+		"in a single ```go code block followed by at most two sentences on what it covers."
+)
+
+func writeDiagnostics(b *strings.Builder, doc *document) {
+	if len(doc.diags) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\nProblems in %s:\n", doc.path)
+	for i, d := range doc.diags {
+		if i == 20 {
+			fmt.Fprintf(b, "- and %d more\n", len(doc.diags)-i)
+			break
+		}
+		fmt.Fprintf(b, "- %d:%d: %s: %s\n", d.Range.Start.Line, d.Range.Start.Column, d.Severity, d.Message)
+	}
+}
+
+// fence quotes code in a Markdown code block longer than any backtick
+// run inside it.
+func fence(lang, code string) string {
+	ticks := "```"
+	for strings.Contains(code, ticks) {
+		ticks += "`"
+	}
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	return ticks + lang + "\n" + code + ticks + "\n"
+}
+
+// offset returns the byte offset of a 1-based line and column.
+func offset(text string, line, col int) (int, bool) {
+	if line < 1 || col < 1 {
+		return 0, false
+	}
+	off := 0
+	for i := 1; i < line; i++ {
+		j := strings.IndexByte(text[off:], '\n')
+		if j < 0 {
+			return 0, false
+		}
+		off += j + 1
+	}
+	end := len(text)
+	if j := strings.IndexByte(text[off:], '\n'); j >= 0 {
+		end = off + j
+	}
+	if off+col-1 > end {
+		return 0, false
+	}
+	return off + col - 1, true
+}
+
+// trimStart keeps the last whole lines of s that fit in n bytes.
+func trimStart(s string, n int) string {
+	s = s[len(s)-n:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// trimEnd keeps the first whole lines of s that fit in n bytes.
+func trimEnd(s string, n int) string {
+	s = s[:n]
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[:i+1]
+	}
+	return s
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	return s
+}
+
+// excerpt numbers the lines of text around line, as many as fit in limit
+// bytes.
+func excerpt(text string, line, limit int) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	line = min(max(line, 1), len(lines))
+	lo, hi := line-1, line // lines[lo:hi] are included
+	size := 0
+	add := func(i int) bool {
+		n := len(lines[i]) + 8
+		if size+n > limit {
+			return false
+		}
+		size += n
+		return true
+	}
+	if !add(lo) {
+		return ""
+	}
+	for grew := true; grew; {
+		grew = false
+		if lo > 0 && add(lo-1) {
+			lo--
+			grew = true
+		}
+		if hi < len(lines) && add(hi) {
+			hi++
+			grew = true
+		}
+	}
+	var b strings.Builder
+	for i := lo; i < hi; i++ {
+		fmt.Fprintf(&b, "%5d  %s", i+1, lines[i])
+	}
+	return b.String()
+}
+
+// function finds a function or method of a Go file by name, returning
+// the file's package, its imports and the function's source.
+func function(src, name string) (pkg string, imports []string, code string, err error) {
+	if name == "" {
+		return "", nil, "", fmt.Errorf("%w: name the function to test", ErrInvalidRequest)
+	}
+	fset := token.NewFileSet()
+	// A file being edited may not parse; the functions before the first
+	// error still can be tested.
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if f == nil || f.Name == nil {
+		return "", nil, "", fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	recv, fn, isMethod := strings.Cut(name, ".")
+	if !isMethod {
+		recv, fn = "", name
+	}
+	for _, imp := range f.Imports {
+		imports = append(imports, imp.Path.Value)
+	}
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Name.Name != fn || receiver(fd) != recv {
+			continue
+		}
+		start := fd.Pos()
+		if fd.Doc != nil {
+			start = fd.Doc.Pos()
+		}
+		code := src[fset.Position(start).Offset:fset.Position(fd.End()).Offset]
+		return f.Name.Name, imports, code, nil
+	}
+	return "", nil, "", fmt.Errorf("%w: no function %s", ErrInvalidRequest, name)
+}
+
+// receiver names the receiver type of a method, or "" for a function.
+func receiver(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	t := fd.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.Ident:
+			return x.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// cleanCompletion removes the fences a backend may wrap a completion in
+// despite being asked not to.
+func cleanCompletion(s string) string {
+	t := strings.TrimSpace(s)
+	if !strings.HasPrefix(t, "```") || !strings.HasSuffix(t, "```") || len(t) < 6 {
+		return s
+	}
+	t = strings.TrimSuffix(t, "```")
+	if i := strings.IndexByte(t, '\n'); i >= 0 {
+		return strings.TrimRight(t[i+1:], "\n")
+	}
+	return s
+}
@@ -0,0 +1,396 @@
+// Package analytics sums up how a deployment is used, for the reports
+// administrators and instructors make on its adoption: runs per day, the
+// languages they are in, build times, active users and the submissions to
+// assignments.
+//
+// The figures are counted from the events of the events package as they
+// are published, per day in UTC, for the deployment and for the
+// organization whose workspace each happened in. Users are kept only as
+// keyed hashes, to count them, and reports never name them: a day or a
+// language whose runs fewer than Config.MinUsers users made has its
+// figures withheld, so that none tells what one person did.
+//
+// The figures are kept in Config.Dir, one file for the deployment and one
+// per organization. Each server counts the events it publishes itself.
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/assignment"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the figures and the key users are hashed with; defaults
+	// to a directory under the OS temp dir.
+	Dir string
+	// Days is how many days of figures are kept; defaults to 400.
+	Days int
+	// MinUsers is the fewest users whose figures a report shows; defaults
+	// to 5. 1 shows every figure.
+	MinUsers int
+	// Workspaces, when set, tells which organization owns a workspace,
+	// for the reports of organizations.
+	Workspaces Workspaces
+	// Assignments, when set, sums up the submissions to the assignments
+	// of organizations.
+	Assignments Assignments
+}
+
+// Bus delivers events, as events.Bus does.
+type Bus interface {
+	Subscribe(fn func(events.Event)) (cancel func())
+}
+
+// Workspaces tells the owners of workspaces.
+type Workspaces interface {
+	Owner(id string) (string, error)
+}
+
+// Assignments sums up submissions, as assignment.Service does.
+type Assignments interface {
+	Stats(orgID string, from, to time.Time) []assignment.Stats
+}
+
+// ErrInvalid is returned for reports over invalid periods.
+var ErrInvalid = errors.New("analytics: invalid period")
+
+// MaxDays bounds the period of a report.
+const MaxDays = 366
+
+// flushInterval is how often changed figures are saved.
+const flushInterval = time.Minute
+
+// day holds the figures of one day. Users are the hashes of the users
+// active that day, sorted.
+type day struct {
+	Users     []string          `json:"users,omitempty"`
+	Languages map[string]*usage `json:"languages,omitempty"`
+}
+
+// usage is what was run in one language in a day.
+type usage struct {
+	Runs   int `json:"runs"`
+	Failed int `json:"failed,omitempty"`
+	// Builds counts the runs whose program was built rather than taken
+	// from the cache, and BuildMS sums their build times.
+	Builds  int      `json:"builds,omitempty"`
+	BuildMS int64    `json:"buildMs,omitempty"`
+	Users   []string `json:"users,omitempty"`
+}
+
+// figures are the days of the deployment or of an organization, by date.
+type figures map[string]*day
+
+// Service counts events into daily figures and reports on them.
+type Service struct {
+	cfg Config
+	key []byte
+	now func() time.Time
+
+	mu     sync.Mutex
+	scopes map[string]figures // "" for the deployment, else an org ID
+	dirty  map[string]bool
+	owners map[string]string // workspace ID to org ID, cleared on flush
+
+	unsubscribe func()
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// New returns a Service counting the events of bus, filling unset Config
+// fields with defaults. It saves the figures every minute and drops those
+// older than Config.Days.
+func New(cfg Config, bus Bus) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-analytics")
+	}
+	if cfg.Days <= 0 {
+		cfg.Days = 400
+	}
+	if cfg.MinUsers <= 0 {
+		cfg.MinUsers = 5
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("analytics: create dir: %w", err)
+	}
+	key, err := loadKey(filepath.Join(cfg.Dir, "key"))
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		cfg:    cfg,
+		key:    key,
+		now:    time.Now,
+		scopes: make(map[string]figures),
+		dirty:  make(map[string]bool),
+		owners: make(map[string]string),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.unsubscribe = bus.Subscribe(s.receive)
+	go s.loop()
+	return s, nil
+}
+
+// Close stops counting and saves the figures.
+func (s *Service) Close() {
+	s.unsubscribe()
+	close(s.stop)
+	<-s.done
+	s.flush()
+}
+
+// loadKey reads the key users are hashed with at p, generating it on
+// first use. Without it the hashes cannot be told from those of other
+// users.
+func loadKey(p string) ([]byte, error) {
+	data, err := os.ReadFile(p)
+	if err == nil && len(data) >= 32 {
+		return data, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("analytics: read key: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p, key, 0o600); err != nil {
+		return nil, fmt.Errorf("analytics: write key: %w", err)
+	}
+	return key, nil
+}
+
+func (s *Service) load() error {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("analytics: read figures: %w", err)
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		scope := ""
+		if name != "deployment" {
+			if scope, ok = strings.CutPrefix(name, "org-"); !ok {
+				continue
+			}
+		}
+		data, err := os.ReadFile(filepath.Join(s.cfg.Dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("analytics: read figures: %w", err)
+		}
+		var f figures
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("analytics: read %s: %w", e.Name(), err)
+		}
+		s.scopes[scope] = f
+	}
+	return nil
+}
+
+func (s *Service) path(scope string) string {
+	if scope == "" {
+		return filepath.Join(s.cfg.Dir, "deployment.json")
+	}
+	return filepath.Join(s.cfg.Dir, "org-"+scope+".json")
+}
+
+func (s *Service) loop() {
+	defer close(s.done)
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			s.flush()
+		}
+	}
+}
+
+// flush drops the days past Config.Days and saves the figures that
+// changed.
+func (s *Service) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldest := s.now().UTC().AddDate(0, 0, -s.cfg.Days).Format(time.DateOnly)
+	for scope, f := range s.scopes {
+		for date := range f {
+			if date < oldest {
+				delete(f, date)
+				s.dirty[scope] = true
+			}
+		}
+	}
+	for scope := range s.dirty {
+		if err := s.saveLocked(scope); err != nil {
+			slog.Warn("analytics: save figures", "err", err)
+			continue
+		}
+		delete(s.dirty, scope)
+	}
+	// Workspaces change hands now and then.
+	clear(s.owners)
+}
+
+// saveLocked writes the figures of a scope. s.mu must be held.
+func (s *Service) saveLocked(scope string) error {
+	p := s.path(scope)
+	f := s.scopes[scope]
+	if len(f) == 0 {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("analytics: write figures: %w", err)
+		}
+		return nil
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("analytics: write figures: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("analytics: write figures: %w", err)
+	}
+	return nil
+}
+
+// hash returns the pseudonym a user is counted under.
+func (s *Service) hash(userID string) string {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(userID))
+	return hex.EncodeToString(m.Sum(nil)[:8])
+}
+
+// orgOf returns the organization owning a workspace, or "".
+func (s *Service) orgOf(workspaceID string) string {
+	if s.cfg.Workspaces == nil || workspaceID == "" {
+		return ""
+	}
+	s.mu.Lock()
+	orgID, ok := s.owners[workspaceID]
+	s.mu.Unlock()
+	if ok {
+		return orgID
+	}
+	owner, err := s.cfg.Workspaces.Owner(workspaceID)
+	if err != nil {
+		return ""
+	}
+	orgID, _ = strings.CutPrefix(owner, org.OwnerPrefix)
+	if orgID == owner {
+		orgID = ""
+	}
+	s.mu.Lock()
+	s.owners[workspaceID] = orgID
+	s.mu.Unlock()
+	return orgID
+}
+
+// receive counts an event. Events of no one, such as those of scheduled
+// jobs, count only when they are runs.
+func (s *Service) receive(e events.Event) {
+	if e.User == "" && e.Type != events.RunFinished {
+		return
+	}
+	user := ""
+	if e.User != "" {
+		user = s.hash(e.User)
+	}
+	scopes := []string{""}
+	if orgID := s.orgOf(e.Workspace); orgID != "" {
+		scopes = append(scopes, orgID)
+	}
+	date := e.Time.UTC().Format(time.DateOnly)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, scope := range scopes {
+		f := s.scopes[scope]
+		if f == nil {
+			f = make(figures)
+			s.scopes[scope] = f
+		}
+		d := f[date]
+		if d == nil {
+			d = &day{}
+			f[date] = d
+		}
+		if user != "" {
+			d.Users = addUser(d.Users, user)
+		}
+		if e.Type == events.RunFinished {
+			d.run(e.Data, user)
+		}
+		s.dirty[scope] = true
+	}
+}
+
+// run counts a run from the data of its run.finished event.
+func (d *day) run(data map[string]any, user string) {
+	lang, _ := data["language"].(string)
+	if d.Languages == nil {
+		d.Languages = make(map[string]*usage)
+	}
+	u := d.Languages[lang]
+	if u == nil {
+		u = &usage{}
+		d.Languages[lang] = u
+	}
+	u.Runs++
+	if timedOut, _ := data["timedOut"].(bool); timedOut || number(data["exitCode"]) != 0 {
+		u.Failed++
+	}
+	if ms := number(data["buildMs"]); ms > 0 {
+		u.Builds++
+		u.BuildMS += ms
+	}
+	if user != "" {
+		u.Users = addUser(u.Users, user)
+	}
+}
+
+func number(v any) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// addUser adds user to the sorted set users.
+func addUser(users []string, user string) []string {
+	i, found := slices.BinarySearch(users, user)
+	if found {
+		return users
+	}
+	return slices.Insert(users, i, user)
+}
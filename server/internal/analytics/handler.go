@@ -0,0 +1,137 @@
+package analytics
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+)
+
+// Accounts tells the server's administrators, as auth.Service does.
+type Accounts interface {
+	IsAdmin(u *auth.User) bool
+}
+
+// Orgs reports a user's membership of an organization, and whether it
+// exists, as org.Service does.
+type Orgs interface {
+	Get(id, userID string) (*org.Org, string, error)
+	Exists(id string) bool
+}
+
+// Handler serves the analytics routes.
+type Handler struct {
+	svc      *Service
+	accounts Accounts
+	orgs     Orgs
+}
+
+// NewHandler returns a Handler for svc. orgs may be nil, for a server
+// without organizations.
+func NewHandler(svc *Service, accounts Accounts, orgs Orgs) *Handler {
+	return &Handler{svc: svc, accounts: accounts, orgs: orgs}
+}
+
+// Register mounts the analytics routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/admin/analytics", h.deployment)
+	if h.orgs != nil {
+		mux.HandleFunc("GET /api/orgs/{org}/analytics", h.org)
+	}
+}
+
+// defaultDays is the period of a report without from.
+const defaultDays = 30
+
+// query reads the period of a report from ?from= and ?to=, dates that
+// default to the last 30 days.
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) (Query, bool) {
+	var q Query
+	params := r.URL.Query()
+	q.To = h.svc.now().UTC()
+	if v := params.Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, "to must be a date such as 2026-10-14")
+			return q, false
+		}
+		q.To = t
+	}
+	q.From = q.To.AddDate(0, 0, 1-defaultDays)
+	if v := params.Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, "from must be a date such as 2026-09-15")
+			return q, false
+		}
+		q.From = t
+	}
+	return q, true
+}
+
+// deployment reports on the whole deployment, or with ?org= on one
+// organization, to administrators.
+func (h *Handler) deployment(w http.ResponseWriter, r *http.Request) {
+	if !h.accounts.IsAdmin(auth.UserFrom(r.Context())) {
+		httpx.Error(w, http.StatusForbidden, "administrators only")
+		return
+	}
+	q, ok := h.query(w, r)
+	if !ok {
+		return
+	}
+	if q.Org = r.URL.Query().Get("org"); q.Org != "" {
+		if h.orgs == nil || !h.orgs.Exists(q.Org) {
+			httpx.Error(w, http.StatusNotFound, "organization not found")
+			return
+		}
+	}
+	h.report(w, q)
+}
+
+// org reports on an organization to its admins, its instructors, and to
+// the server's administrators.
+func (h *Handler) org(w http.ResponseWriter, r *http.Request) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "sign in to see analytics")
+		return
+	}
+	id := r.PathValue("org")
+	if !h.accounts.IsAdmin(u) {
+		_, role, err := h.orgs.Get(id, u.ID)
+		if err != nil {
+			httpx.Error(w, http.StatusNotFound, "organization not found")
+			return
+		}
+		if role != org.RoleAdmin {
+			httpx.Error(w, http.StatusForbidden, "only organization admins see analytics")
+			return
+		}
+	} else if !h.orgs.Exists(id) {
+		httpx.Error(w, http.StatusNotFound, "organization not found")
+		return
+	}
+	q, ok := h.query(w, r)
+	if !ok {
+		return
+	}
+	q.Org = id
+	h.report(w, q)
+}
+
+func (h *Handler) report(w http.ResponseWriter, q Query) {
+	rep, err := h.svc.Report(q)
+	if errors.Is(err, ErrInvalid) {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "could not make the report")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,174 @@
+package analytics
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/assignment"
+)
+
+// Query selects the figures of a report.
+type Query struct {
+	// Org, when set, limits the report to the workspaces of an
+	// organization and adds its assignments.
+	Org string
+	// From and To are the first and last days of the report, in UTC.
+	From, To time.Time
+}
+
+// Usage is what users did in a day, in a language or over a whole report.
+type Usage struct {
+	// ActiveUsers is how many signed-in users did anything: ran a program,
+	// saved a file, opened one for editing and the like. For a language,
+	// it is how many ran programs in it.
+	ActiveUsers int `json:"activeUsers"`
+	Runs        int `json:"runs"`
+	FailedRuns  int `json:"failedRuns"`
+	// Builds counts the runs that built their program rather than taking
+	// it from the cache, and AverageBuildMS is how long that took.
+	Builds         int   `json:"builds"`
+	AverageBuildMS int64 `json:"averageBuildMs"`
+	// Withheld says the runs were made by fewer than the report's
+	// MinUsers users, so their figures are left out.
+	Withheld bool `json:"withheld,omitempty"`
+}
+
+// Day is the usage of one day.
+type Day struct {
+	Date string `json:"date"`
+	Usage
+}
+
+// Language is the usage of one language.
+type Language struct {
+	Language string `json:"language"`
+	Usage
+	// Share is the fraction of the report's runs in the language.
+	Share float64 `json:"share"`
+}
+
+// Report sums up the usage of the deployment, or of an organization, over
+// a period.
+type Report struct {
+	Org  string `json:"org,omitempty"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// MinUsers is the fewest users whose figures the report shows.
+	MinUsers int   `json:"minUsers"`
+	Totals   Usage `json:"totals"`
+	// Days are every day of the period, oldest first.
+	Days []Day `json:"days"`
+	// Languages are the most used first; withheld ones come last.
+	Languages []Language `json:"languages"`
+	// Assignments, for organizations, are their assignments, newest
+	// first, with the submissions of the period.
+	Assignments []assignment.Stats `json:"assignments,omitempty"`
+}
+
+// tally adds up usages before they become a Usage.
+type tally struct {
+	usage
+	// active are the users active at all, and usage.Users those who ran
+	// programs.
+	active []string
+}
+
+func (t *tally) add(u *usage) {
+	t.Runs += u.Runs
+	t.Failed += u.Failed
+	t.Builds += u.Builds
+	t.BuildMS += u.BuildMS
+	for _, user := range u.Users {
+		t.Users = addUser(t.Users, user)
+	}
+}
+
+// result turns t into a Usage, withholding the figures of fewer than
+// minUsers users. Runs without a signed-in user have no users and are
+// never withheld.
+func (t *tally) result(active, minUsers int) Usage {
+	u := Usage{ActiveUsers: active}
+	if n := len(t.Users); n > 0 && n < minUsers {
+		u.Withheld = true
+		return u
+	}
+	u.Runs, u.FailedRuns, u.Builds = t.Runs, t.Failed, t.Builds
+	if t.Builds > 0 {
+		u.AverageBuildMS = t.BuildMS / int64(t.Builds)
+	}
+	return u
+}
+
+// Report sums up the figures q selects.
+func (s *Service) Report(q Query) (*Report, error) {
+	from, to := q.From.UTC().Truncate(24*time.Hour), q.To.UTC().Truncate(24*time.Hour)
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: from is after to", ErrInvalid)
+	}
+	if n := int(to.Sub(from)/(24*time.Hour)) + 1; n > MaxDays {
+		return nil, fmt.Errorf("%w: %d days, at most %d", ErrInvalid, n, MaxDays)
+	}
+	rep := &Report{
+		Org:      q.Org,
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		MinUsers: s.cfg.MinUsers,
+		Days:     []Day{},
+	}
+	var total tally
+	langs := make(map[string]*tally)
+
+	s.mu.Lock()
+	f := s.scopes[q.Org]
+	for t := from; !t.After(to); t = t.AddDate(0, 0, 1) {
+		date := t.Format(time.DateOnly)
+		d := f[date]
+		if d == nil {
+			rep.Days = append(rep.Days, Day{Date: date})
+			continue
+		}
+		var dt tally
+		for lang, u := range d.Languages {
+			dt.add(u)
+			total.add(u)
+			lt := langs[lang]
+			if lt == nil {
+				lt = &tally{}
+				langs[lang] = lt
+			}
+			lt.add(u)
+		}
+		for _, user := range d.Users {
+			total.active = addUser(total.active, user)
+		}
+		rep.Days = append(rep.Days, Day{Date: date, Usage: dt.result(len(d.Users), s.cfg.MinUsers)})
+	}
+	s.mu.Unlock()
+
+	rep.Totals = total.result(len(total.active), s.cfg.MinUsers)
+	rep.Languages = make([]Language, 0, len(langs))
+	for lang, lt := range langs {
+		l := Language{Language: lang, Usage: lt.result(len(lt.Users), s.cfg.MinUsers)}
+		if !l.Withheld && total.Runs > 0 {
+			l.Share = float64(lt.Runs) / float64(total.Runs)
+		}
+		rep.Languages = append(rep.Languages, l)
+	}
+	// Withheld languages are not ranked, which would tell their runs.
+	slices.SortFunc(rep.Languages, func(a, b Language) int {
+		if a.Withheld != b.Withheld {
+			if a.Withheld {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(cmp.Compare(b.Runs, a.Runs), strings.Compare(a.Language, b.Language))
+	})
+	if q.Org != "" && s.cfg.Assignments != nil {
+		rep.Assignments = s.cfg.Assignments.Stats(q.Org, from, to.AddDate(0, 0, 1))
+	}
+	return rep, nil
+}
@@ -0,0 +1,371 @@
+// Package apiversion serves the API under versioned prefixes, /api/v1 and
+// /api/v2, next to the unversioned /api routes. The handlers implement v1;
+// each later version is a list of changes from the one before it, which
+// translate the version's requests into requests of the version before and
+// the answers back. Integrations move to a version route by route, and old
+// versions keep working, with deprecation headers, until they are retired.
+package apiversion
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Header names the version a request to an unversioned route asks for,
+// and the version that answered a request.
+const Header = "API-Version"
+
+// A Version is a version of the API.
+type Version struct {
+	Name string
+	// Released is when the version was introduced, and so when the one
+	// before it was deprecated.
+	Released time.Time
+	// Changes are the differences from the version before.
+	Changes []Change
+}
+
+// A Change is a difference of a version from the one before it.
+type Change struct {
+	// Pattern is the ServeMux pattern of the routes that changed, in the
+	// version's paths without their prefix, such as "POST /api/runs". An
+	// empty Pattern changes every route.
+	Pattern string
+	// Description says what changed, for clients migrating.
+	Description string
+	// Path is the routes' path in the version before, with the wildcards
+	// of Pattern, when they moved. The old path is gone from the version,
+	// and deprecated in the one before.
+	Path string
+	// Request, when set, translates a request of the version into one of
+	// the version before.
+	Request func(r *http.Request) error
+	// Response, when set, translates a JSON response of the version
+	// before into one of the version; with Errors, only error responses.
+	Response func(res *Response) error
+	Errors   bool
+}
+
+// Response is a buffered JSON response, as a Change translates it.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// v1 is the API the handlers serve.
+var v1 = &Version{Name: "v1"}
+
+// versions are the versions of the API, oldest first.
+var versions = []*Version{v1, v2}
+
+// Names returns the names of the versions, oldest first.
+func Names() []string {
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = v.Name
+	}
+	return names
+}
+
+// Config configures the versions served.
+type Config struct {
+	// Default is the version of unversioned routes, for requests without
+	// an API-Version header; v1 when empty.
+	Default string
+	// Sunsets are the dates versions are retired on, by name. A version
+	// with a sunset is deprecated until then and answered with 410 Gone
+	// after.
+	Sunsets map[string]time.Time
+}
+
+// API is the versions of the API as a server serves them.
+type API struct {
+	cfg      Config
+	versions []*Version
+}
+
+// New returns the API for cfg.
+func New(cfg Config) (*API, error) {
+	if cfg.Default == "" {
+		cfg.Default = v1.Name
+	}
+	a := &API{cfg: cfg, versions: versions}
+	if _, ok := a.index(cfg.Default); !ok {
+		return nil, fmt.Errorf("apiversion: unknown default version %q", cfg.Default)
+	}
+	for name := range cfg.Sunsets {
+		if _, ok := a.index(name); !ok {
+			return nil, fmt.Errorf("apiversion: sunset of unknown version %q", name)
+		}
+	}
+	if sunset, ok := cfg.Sunsets[cfg.Default]; ok && !time.Now().Before(sunset) {
+		return nil, fmt.Errorf("apiversion: default version %s is retired", cfg.Default)
+	}
+	return a, nil
+}
+
+func (a *API) index(name string) (int, bool) {
+	i := slices.IndexFunc(a.versions, func(v *Version) bool { return v.Name == name })
+	return i, i >= 0
+}
+
+func (a *API) latest() *Version { return a.versions[len(a.versions)-1] }
+
+// Middleware serves the versioned routes of api. Requests to /api/vN are
+// translated into requests of v1 without the prefix, as are requests to
+// unversioned routes, of the version their API-Version header names or
+// the default one. It belongs outside everything that reads the paths of
+// API routes, such as the auth middleware.
+func Middleware(api *API, next http.Handler) http.Handler {
+	// The requests of a version go through its changes and then those of
+	// the versions before; entries adds the deprecation of the routes a
+	// later version moved, for requests made in the version itself.
+	entries := make([]http.Handler, len(api.versions))
+	chain := next
+	for i, v := range api.versions {
+		if i > 0 {
+			chain = stage(v, chain)
+		}
+		entries[i] = chain
+		if i+1 < len(api.versions) {
+			entries[i] = moved(api.versions[i+1], chain)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, rest, ok := split(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		asked := r.Header.Get(Header)
+		switch {
+		case name == "" && asked != "":
+			name = asked
+		case name == "":
+			name = api.cfg.Default
+		case asked != "" && asked != name:
+			httpx.Errorf(w, http.StatusBadRequest, "%s %s does not match the path's version, %s", Header, asked, name)
+			return
+		}
+		i, ok := api.index(name)
+		if !ok {
+			httpx.Errorf(w, http.StatusNotFound, "unknown API version %q", name)
+			return
+		}
+		if sunset, ok := api.cfg.Sunsets[name]; ok {
+			if !time.Now().Before(sunset) {
+				httpx.Errorf(w, http.StatusGone, "API %s was retired on %s; use %s", name, sunset.Format(time.DateOnly), api.latest().Name)
+				return
+			}
+			if i+1 < len(api.versions) {
+				w.Header().Set("Deprecation", deprecation(api.versions[i+1].Released))
+			}
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Add("Link", `</api/versions>; rel="deprecation"`)
+		}
+		w.Header().Set(Header, name)
+		if rest != r.URL.Path {
+			r = withPath(r, rest, strings.Replace(r.URL.RawPath, "/"+name, "", 1))
+		}
+		entries[i].ServeHTTP(w, r)
+	})
+}
+
+var versionElem = regexp.MustCompile(`^v[0-9]+$`)
+
+// split returns the version of the API path p, if it has one, and p
+// without it. ok is false for paths outside /api.
+func split(p string) (version, rest string, ok bool) {
+	if p != "/api" && !strings.HasPrefix(p, "/api/") {
+		return "", p, false
+	}
+	elem, after, _ := strings.Cut(strings.TrimPrefix(p, "/api/"), "/")
+	if !versionElem.MatchString(elem) {
+		return "", p, true
+	}
+	if after == "" && !strings.HasSuffix(p, "/") {
+		return elem, "/api", true
+	}
+	return elem, "/api/" + after, true
+}
+
+// withPath returns a copy of r for the path p, and its escaped form
+// rawPath, if any.
+func withPath(r *http.Request, p, rawPath string) *http.Request {
+	r = r.Clone(r.Context())
+	r.URL.Path, r.URL.RawPath = p, rawPath
+	return r
+}
+
+// deprecation formats t as a Deprecation header: "@" and its Unix time.
+func deprecation(t time.Time) string {
+	return fmt.Sprintf("@%d", t.Unix())
+}
+
+// prefixed returns the path p of an API route in version v.
+func prefixed(v, p string) string {
+	return "/api/" + v + strings.TrimPrefix(p, "/api")
+}
+
+// stage returns a handler translating the requests of v into requests of
+// the version before, served by prev.
+func stage(v *Version, prev http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", prev)
+	var h http.Handler = mux
+	for _, c := range v.Changes {
+		if c.Pattern == "" {
+			h = translate(c, h)
+			continue
+		}
+		mux.Handle(c.Pattern, translate(c, prev))
+		if c.Path != "" {
+			method, path := cutPattern(c.Pattern)
+			mux.HandleFunc(method+c.Path, func(w http.ResponseWriter, r *http.Request) {
+				httpx.Errorf(w, http.StatusNotFound, "%s%s moved to %s%s in %s", method, c.Path, method, prefixed(v.Name, expand(path, r)), v.Name)
+			})
+		}
+	}
+	return h
+}
+
+// moved returns a handler marking the routes of the version before next
+// that moved in next as deprecated, linking to their successors.
+func moved(next *Version, prev http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", prev)
+	for _, c := range next.Changes {
+		if c.Path == "" {
+			continue
+		}
+		method, path := cutPattern(c.Pattern)
+		mux.HandleFunc(method+c.Path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation(next.Released))
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, prefixed(next.Name, expand(path, r))))
+			prev.ServeHTTP(w, r)
+		})
+	}
+	return mux
+}
+
+// cutPattern splits a ServeMux pattern into its method, followed by a
+// space, if it has one, and its path.
+func cutPattern(p string) (method, path string) {
+	if m, rest, ok := strings.Cut(p, " "); ok {
+		return m + " ", rest
+	}
+	return "", p
+}
+
+var wildcard = regexp.MustCompile(`\{([^}.]*)(\.\.\.)?\}`)
+
+// expand returns the path of a route with the wildcards of the pattern r
+// matched, such as "/api/workspaces/{id}", replaced by their values.
+func expand(path string, r *http.Request) string {
+	path = strings.TrimSuffix(path, "{$}")
+	return wildcard.ReplaceAllStringFunc(path, func(m string) string {
+		return r.PathValue(wildcard.FindStringSubmatch(m)[1])
+	})
+}
+
+// translate returns a handler applying c to requests for next and their
+// responses.
+func translate(c Change, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Path != "" {
+			r = withPath(r, expand(c.Path, r), "")
+		}
+		if c.Request != nil {
+			if c.Path == "" {
+				r = r.Clone(r.Context())
+			}
+			if err := c.Request(r); err != nil {
+				httpx.Error(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		if c.Response == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t := &translator{ResponseWriter: w, change: c}
+		next.ServeHTTP(t, r)
+		t.finish()
+	})
+}
+
+// translator buffers the JSON responses a Change translates and passes
+// the others through.
+type translator struct {
+	http.ResponseWriter
+	change Change
+	status int
+	buf    *bytes.Buffer
+}
+
+func (t *translator) WriteHeader(code int) {
+	if code < http.StatusOK {
+		// Informational responses, such as 103 Early Hints, precede
+		// the final one.
+		t.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if t.status != 0 {
+		return
+	}
+	t.status = code
+	ct := t.Header().Get("Content-Type")
+	if (ct == "application/json" || strings.HasPrefix(ct, "application/json;")) &&
+		code != http.StatusNoContent && code != http.StatusNotModified &&
+		(!t.change.Errors || code >= http.StatusBadRequest) {
+		t.buf = new(bytes.Buffer)
+		return
+	}
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *translator) Write(p []byte) (int, error) {
+	if t.status == 0 {
+		t.WriteHeader(http.StatusOK)
+	}
+	if t.buf != nil {
+		return t.buf.Write(p)
+	}
+	return t.ResponseWriter.Write(p)
+}
+
+// Flush flushes responses passed through; buffered ones are written
+// whole.
+func (t *translator) Flush() {
+	if t.buf == nil {
+		http.NewResponseController(t.ResponseWriter).Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (t *translator) Unwrap() http.ResponseWriter { return t.ResponseWriter }
+
+// finish translates and writes a buffered response.
+func (t *translator) finish() {
+	if t.buf == nil {
+		return
+	}
+	res := &Response{Status: t.status, Header: t.Header(), Body: t.buf.Bytes()}
+	if err := t.change.Response(res); err != nil {
+		// The response is sent as the handler wrote it.
+		slog.Error("apiversion: translate response", "change", t.change.Description, "err", err)
+		res.Status, res.Body = t.status, t.buf.Bytes()
+	}
+	t.Header().Del("Content-Length")
+	t.ResponseWriter.WriteHeader(res.Status)
+	t.ResponseWriter.Write(res.Body)
+}
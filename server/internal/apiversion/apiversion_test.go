@@ -0,0 +1,249 @@
+package apiversion
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// echo is what the v1 handlers of the tests answer: the request they got.
+type echo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query"`
+	Body   string `json:"body"`
+}
+
+// v1Handlers serves a few v1 routes, answering with the request they got.
+func v1Handlers() http.Handler {
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		httpx.JSON(w, http.StatusOK, echo{r.Method, r.URL.Path, r.URL.RawQuery, string(body)})
+	}
+	mux.HandleFunc("POST /api/run", reply)
+	mux.HandleFunc("GET /api/run/languages", reply)
+	mux.HandleFunc("POST /api/workspaces", reply)
+	mux.HandleFunc("GET /api/workspaces/{id}", func(w http.ResponseWriter, r *http.Request) {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+	})
+	mux.HandleFunc("GET /api/build/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "log")
+	})
+	return mux
+}
+
+func serve(t *testing.T, h http.Handler, method, target, version, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if version != "" {
+		req.Header.Set(Header, version)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestV2(t *testing.T) {
+	api, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Middleware(api, v1Handlers())
+	tests := []struct {
+		name             string
+		method, target   string
+		version, body    string
+		status           int
+		want             echo // what the v1 handler got, for 200s
+		deprecation      bool
+		successor, error string
+	}{
+		{
+			name: "moved route", method: "POST", target: "/api/v2/runs", body: `{"code": "x"}`,
+			status: 200, want: echo{"POST", "/api/run", "", `{"code": "x"}`},
+		},
+		{
+			name: "moved route by header", method: "GET", target: "/api/runs/languages", version: "v2",
+			status: 200, want: echo{Method: "GET", Path: "/api/run/languages"},
+		},
+		{
+			name: "old path in v2", method: "POST", target: "/api/v2/run",
+			status: 404, error: `{"error":{"status":404,"code":"not_found","message":"POST /api/run moved to POST /api/v2/runs in v2"}}`,
+		},
+		{
+			name: "old path in v1", method: "POST", target: "/api/v1/run", body: "{}",
+			status: 200, want: echo{"POST", "/api/run", "", "{}"}, deprecation: true, successor: "/api/v2/runs",
+		},
+		{
+			name: "old path unversioned", method: "GET", target: "/api/run/languages",
+			status: 200, want: echo{Method: "GET", Path: "/api/run/languages"}, deprecation: true, successor: "/api/v2/runs/languages",
+		},
+		{
+			name: "template in the body", method: "POST", target: "/api/v2/workspaces", body: `{"name": "demo", "template": "go"}`,
+			status: 200, want: echo{"POST", "/api/workspaces", "template=go", `{"name":"demo"}`},
+		},
+		{
+			name: "template query dropped", method: "POST", target: "/api/v2/workspaces?template=go&x=1", body: `{"name": "demo"}`,
+			status: 200, want: echo{"POST", "/api/workspaces", "x=1", `{"name": "demo"}`},
+		},
+		{
+			name: "template query in v1", method: "POST", target: "/api/v1/workspaces?template=go", body: `{"name": "demo", "template": "go"}`,
+			status: 200, want: echo{"POST", "/api/workspaces", "template=go", `{"name": "demo", "template": "go"}`},
+		},
+		{
+			name: "template not a string", method: "POST", target: "/api/v2/workspaces", body: `{"template": 1}`,
+			status: 400, error: `{"error":{"status":400,"code":"bad_request","message":"\"template\" must be a string"}}`,
+		},
+		{
+			name: "error in v2", method: "GET", target: "/api/v2/workspaces/ws1",
+			status: 404, error: `{"error":{"status":404,"code":"not_found","message":"workspace not found"}}`,
+		},
+		{
+			name: "error in v1", method: "GET", target: "/api/v1/workspaces/ws1",
+			status: 404, error: `{"error":"workspace not found","success":false}`,
+		},
+		{
+			name: "header against the path", method: "GET", target: "/api/v2/workspaces/ws1", version: "v1",
+			status: 400, error: `{"error":"API-Version v1 does not match the path's version, v2","success":false}`,
+		},
+		{
+			name: "unknown version", method: "GET", target: "/api/v9/workspaces/ws1",
+			status: 404, error: `{"error":"unknown API version \"v9\"","success":false}`,
+		},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, tt.method, tt.target, tt.version, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s: %s %s = %d %s, want %d", tt.name, tt.method, tt.target, rec.Code, rec.Body, tt.status)
+			continue
+		}
+		if tt.error != "" {
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.error {
+				t.Errorf("%s: body = %s, want %s", tt.name, got, tt.error)
+			}
+		} else {
+			var got echo
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got != tt.want {
+				t.Errorf("%s: the v1 handler got %+v, want %+v", tt.name, got, tt.want)
+			}
+		}
+		wantDep, wantLink := "", ""
+		if tt.deprecation {
+			wantDep = deprecation(v2.Released)
+			wantLink = "<" + tt.successor + `>; rel="successor-version"`
+		}
+		if got := rec.Header().Get("Deprecation"); got != wantDep {
+			t.Errorf("%s: Deprecation = %q, want %q", tt.name, got, wantDep)
+		}
+		if got := rec.Header().Get("Link"); got != wantLink {
+			t.Errorf("%s: Link = %q, want %q", tt.name, got, wantLink)
+		}
+		if got := rec.Header().Get("Sunset"); got != "" {
+			t.Errorf("%s: Sunset = %q without a sunset", tt.name, got)
+		}
+	}
+
+	// Only JSON is translated.
+	rec := serve(t, h, "GET", "/api/v2/build/b1", "", "")
+	if rec.Code != 200 || rec.Body.String() != "log" || rec.Header().Get(Header) != "v2" {
+		t.Errorf("GET of a text route in v2 = %d %q, %s %q", rec.Code, rec.Body, Header, rec.Header().Get(Header))
+	}
+}
+
+func TestSunset(t *testing.T) {
+	sunset := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	api, err := New(Config{Sunsets: map[string]time.Time{"v1": sunset}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Middleware(api, v1Handlers())
+	for _, target := range []string{"/api/v1/workspaces", "/api/workspaces"} {
+		rec := serve(t, h, "POST", target, "", "{}")
+		if rec.Code != 200 {
+			t.Fatalf("POST %s = %d %s", target, rec.Code, rec.Body)
+		}
+		if got, want := rec.Header().Get("Deprecation"), deprecation(v2.Released); got != want {
+			t.Errorf("POST %s: Deprecation = %q, want %q", target, got, want)
+		}
+		if got, want := rec.Header().Get("Sunset"), sunset.UTC().Format(http.TimeFormat); got != want {
+			t.Errorf("POST %s: Sunset = %q, want %q", target, got, want)
+		}
+		if got := rec.Header().Get("Link"); got != `</api/versions>; rel="deprecation"` {
+			t.Errorf("POST %s: Link = %q", target, got)
+		}
+	}
+	// The route v2 moved links to its successor too.
+	rec := serve(t, h, "POST", "/api/v1/run", "", "{}")
+	if links := rec.Header().Values("Link"); len(links) != 2 || links[1] != `</api/v2/runs>; rel="successor-version"` {
+		t.Errorf("POST /api/v1/run: Link = %q", links)
+	}
+	if rec := serve(t, h, "POST", "/api/v2/runs", "", "{}"); rec.Header().Get("Sunset") != "" || rec.Header().Get("Deprecation") != "" {
+		t.Errorf("v2 is deprecated: %v", rec.Header())
+	}
+
+	retired := map[string]time.Time{"v1": time.Now().Add(-time.Hour)}
+	if _, err := New(Config{Sunsets: retired}); err == nil {
+		t.Error("New with the default retired succeeded")
+	}
+	api, err = New(Config{Default: "v2", Sunsets: retired})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = Middleware(api, v1Handlers())
+	if rec := serve(t, h, "POST", "/api/v1/workspaces", "", "{}"); rec.Code != http.StatusGone {
+		t.Errorf("POST to a retired version = %d, want 410", rec.Code)
+	}
+	// A retired version's errors are in its format.
+	if rec := serve(t, h, "GET", "/api/workspaces/ws1", "v1", ""); rec.Code != http.StatusGone || !strings.HasPrefix(rec.Body.String(), `{"error":"API v1 was retired on`) {
+		t.Errorf("GET to a retired version = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, h, "GET", "/api/workspaces/ws1", "", ""); rec.Code != 404 || !strings.Contains(rec.Body.String(), `"code":"not_found"`) {
+		t.Errorf("GET with v2 the default = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in, version, rest string
+		ok                bool
+	}{
+		{"/api/v2/runs", "v2", "/api/runs", true},
+		{"/api/v2", "v2", "/api", true},
+		{"/api/v2/", "v2", "/api/", true},
+		{"/api/runs", "", "/api/runs", true},
+		{"/api/version/x", "", "/api/version/x", true},
+		{"/api", "", "/api", true},
+		{"/apiv2/runs", "", "/apiv2/runs", false},
+		{"/ws/v2/run", "", "/ws/v2/run", false},
+	}
+	for _, tt := range tests {
+		version, rest, ok := split(tt.in)
+		if version != tt.version || rest != tt.rest || ok != tt.ok {
+			t.Errorf("split(%q) = %q, %q, %v; want %q, %q, %v", tt.in, version, rest, ok, tt.version, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := map[int]string{
+		400: "bad_request",
+		404: "not_found",
+		413: "request_entity_too_large",
+		418: "im_a_teapot",
+		429: "too_many_requests",
+		599: "error",
+	}
+	for status, want := range tests {
+		if got := errorCode(status); got != want {
+			t.Errorf("errorCode(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package apiversion
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Handler lists the versions of the API.
+type Handler struct {
+	api *API
+}
+
+// NewHandler returns the Handler listing the versions of api.
+func NewHandler(api *API) *Handler {
+	return &Handler{api: api}
+}
+
+// Register mounts the version list on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/versions", h.list)
+}
+
+// VersionInfo describes a version of the API in responses.
+type VersionInfo struct {
+	Name    string `json:"name"`
+	Default bool   `json:"default,omitempty"`
+	// Released is when the version was introduced, unset for v1.
+	Released *time.Time `json:"released,omitempty"`
+	// Deprecated is when the version was deprecated, and Sunset when it
+	// is retired, for versions with a sunset.
+	Deprecated *time.Time   `json:"deprecated,omitempty"`
+	Sunset     *time.Time   `json:"sunset,omitempty"`
+	Changes    []ChangeInfo `json:"changes,omitempty"`
+}
+
+// ChangeInfo describes a change from the version before.
+type ChangeInfo struct {
+	// Route is the pattern of the routes changed, in the version's
+	// paths; empty for changes to every route.
+	Route string `json:"route,omitempty"`
+	// Previous is the routes' path in the version before, when they
+	// moved.
+	Previous    string `json:"previous,omitempty"`
+	Description string `json:"description"`
+}
+
+// list returns the versions, oldest first, and the latest one's name.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	infos := make([]VersionInfo, len(h.api.versions))
+	for i, v := range h.api.versions {
+		info := VersionInfo{Name: v.Name, Default: v.Name == h.api.cfg.Default}
+		if !v.Released.IsZero() {
+			t := v.Released
+			info.Released = &t
+		}
+		if sunset, ok := h.api.cfg.Sunsets[v.Name]; ok {
+			info.Sunset = &sunset
+			if i+1 < len(h.api.versions) {
+				t := h.api.versions[i+1].Released
+				info.Deprecated = &t
+			}
+		}
+		for _, c := range v.Changes {
+			ci := ChangeInfo{Description: c.Description}
+			if c.Pattern != "" {
+				method, path := cutPattern(c.Pattern)
+				ci.Route = method + prefixed(v.Name, path)
+			}
+			if c.Path != "" {
+				ci.Previous = prefixed(h.api.versions[i-1].Name, c.Path)
+			}
+			info.Changes = append(info.Changes, ci)
+		}
+		infos[i] = info
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"versions": infos, "latest": h.api.latest().Name})
+}
@@ -0,0 +1,131 @@
+package apiversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// v2 reports errors as objects with a code, serves runs as a collection
+// and takes the template of a new workspace in the request body.
+var v2 = &Version{
+	Name:     "v2",
+	Released: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC),
+	Changes: []Change{
+		{
+			Description: `Errors are {"error": {"status", "code", "message"}}, where code is the status text in snake case, such as "not_found", instead of {"success": false, "error"}.`,
+			Response:    errorObject,
+			Errors:      true,
+		},
+		{
+			Pattern:     "POST /api/runs",
+			Path:        "/api/run",
+			Description: "POST /api/run moved to POST /api/runs.",
+		},
+		{
+			Pattern:     "GET /api/runs/languages",
+			Path:        "/api/run/languages",
+			Description: "GET /api/run/languages moved to GET /api/runs/languages.",
+		},
+		{
+			Pattern:     "POST /api/workspaces",
+			Description: `The template of a new workspace is the body's "template" instead of the template query parameter.`,
+			Request:     templateInBody,
+		},
+	},
+}
+
+// v2Error is the body of an error response in v2.
+type v2Error struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func errorObject(res *Response) error {
+	var v1Error struct {
+		Success *bool  `json:"success"`
+		Error   string `json:"error"`
+	}
+	if json.Unmarshal(res.Body, &v1Error) != nil || v1Error.Success == nil || *v1Error.Success {
+		return nil
+	}
+	var e v2Error
+	e.Error.Status = res.Status
+	e.Error.Code = errorCode(res.Status)
+	e.Error.Message = v1Error.Error
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	res.Body = append(data, '\n')
+	return nil
+}
+
+// errorCode is the status text of status in snake case, such as
+// "too_many_requests".
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == ' ' || r == '-':
+			return '_'
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		case r >= 'a' && r <= 'z':
+			return r
+		}
+		return -1
+	}, text)
+}
+
+// templateInBody moves the "template" of the body of a workspace creation
+// to the query, where v1 takes it.
+func templateInBody(r *http.Request) error {
+	q := r.URL.Query()
+	q.Del("template")
+	defer func() { r.URL.RawQuery = q.Encode() }()
+	if r.ContentLength == 0 {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, httpx.DefaultMaxBody+1))
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	if len(data) > httpx.DefaultMaxBody {
+		return fmt.Errorf("request body exceeds %d bytes", httpx.DefaultMaxBody)
+	}
+	var body map[string]json.RawMessage
+	if json.Unmarshal(data, &body) == nil {
+		if raw, ok := body["template"]; ok {
+			var name string
+			if json.Unmarshal(raw, &name) != nil {
+				return errors.New(`"template" must be a string`)
+			}
+			delete(body, "template")
+			if name != "" {
+				q.Set("template", name)
+			}
+			if data, err = json.Marshal(body); err != nil {
+				return err
+			}
+		}
+	}
+	// A body that is not an object is left for the handler to refuse.
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	return nil
+}
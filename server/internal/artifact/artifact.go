@@ -0,0 +1,486 @@
+// Package artifact stores the outputs of builds, tests, profiled runs and
+// exports outside the workspace tree, so they do not show up among its
+// files and can be downloaded after the request that made them.
+//
+// Contents are addressed by their SHA-256, so identical outputs, such as
+// the binary of an unchanged program built twice, are stored once. Each
+// artifact is a record naming its content, with a kind, a file name and
+// an expiry; expired records are swept hourly along with the contents no
+// record refers to anymore. Artifacts are downloaded by ID with an access
+// token, or without one through a URL signed until they expire.
+package artifact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir holds the contents and records; defaults to a directory under
+	// the OS temp dir.
+	Dir string
+	// TTL is how long artifacts are kept unless stored for longer;
+	// defaults to 24 hours.
+	TTL time.Duration
+	// MaxTTL caps the lifetime artifacts are stored or extended for;
+	// defaults to 30 days.
+	MaxTTL time.Duration
+}
+
+// Kind is what an artifact holds.
+type Kind string
+
+const (
+	KindBinary   Kind = "binary"
+	KindProfile  Kind = "profile"
+	KindTrace    Kind = "trace"
+	KindCoverage Kind = "coverage"
+	KindArchive  Kind = "archive"
+)
+
+var (
+	// ErrNotFound is returned for unknown or expired artifacts.
+	ErrNotFound = errors.New("artifact: not found")
+	// ErrInvalidTTL is returned for lifetimes that are not positive.
+	ErrInvalidTTL = errors.New("artifact: invalid ttl")
+)
+
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Artifact is a stored output.
+type Artifact struct {
+	ID          string `json:"id"`
+	Kind        Kind   `json:"kind"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	// Workspace is the workspace the artifact was made from, if any, and
+	// Owner the user who made it.
+	Workspace string `json:"workspace,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	// Labels describe the artifact further, such as the target of a
+	// binary.
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	// URL downloads the artifact without an access token until it
+	// expires. It is not stored.
+	URL string `json:"url,omitempty"`
+}
+
+// Filter selects artifacts for List; empty fields match all.
+type Filter struct {
+	Workspace string
+	Owner     string
+	Kind      Kind
+}
+
+func (f Filter) match(a *Artifact) bool {
+	return (f.Workspace == "" || a.Workspace == f.Workspace) &&
+		(f.Owner == "" || a.Owner == f.Owner) &&
+		(f.Kind == "" || a.Kind == f.Kind)
+}
+
+// sweepInterval is how often expired artifacts are removed.
+const sweepInterval = time.Hour
+
+// Store keeps artifacts on disk.
+type Store struct {
+	cfg    Config
+	secret []byte
+	now    func() time.Time
+
+	mu      sync.Mutex
+	records map[string]*Artifact
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns a Store, filling unset Config fields with defaults, loads
+// the artifacts stored before and starts sweeping expired ones.
+func New(cfg Config) (*Store, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-artifacts")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.MaxTTL <= 0 {
+		cfg.MaxTTL = 30 * 24 * time.Hour
+	}
+	cfg.TTL = min(cfg.TTL, cfg.MaxTTL)
+	for _, d := range []string{"blobs", "records"} {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, d), 0o700); err != nil {
+			return nil, fmt.Errorf("artifact: create dir: %w", err)
+		}
+	}
+	secret, err := loadSecret(filepath.Join(cfg.Dir, "secret"))
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		cfg:     cfg,
+		secret:  secret,
+		now:     time.Now,
+		records: make(map[string]*Artifact),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	go s.sweep()
+	return s, nil
+}
+
+// Close stops sweeping.
+func (s *Store) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+}
+
+// loadSecret reads the key signing download URLs at p, generating it on
+// first use.
+func loadSecret(p string) ([]byte, error) {
+	data, err := os.ReadFile(p)
+	if err == nil && len(data) >= 32 {
+		return data, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("artifact: read secret: %w", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p, secret, 0o600); err != nil {
+		return nil, fmt.Errorf("artifact: write secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (s *Store) load() error {
+	des, err := os.ReadDir(filepath.Join(s.cfg.Dir, "records"))
+	if err != nil {
+		return fmt.Errorf("artifact: load: %w", err)
+	}
+	for _, de := range des {
+		id, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok || !idPattern.MatchString(id) {
+			continue
+		}
+		data, err := os.ReadFile(s.recordPath(id))
+		if err != nil {
+			return fmt.Errorf("artifact: load: %w", err)
+		}
+		var a Artifact
+		if err := json.Unmarshal(data, &a); err != nil {
+			slog.Warn("artifact: skipping unreadable record", "id", id, "err", err)
+			continue
+		}
+		s.records[id] = &a
+	}
+	return nil
+}
+
+func (s *Store) recordPath(id string) string {
+	return filepath.Join(s.cfg.Dir, "records", id+".json")
+}
+
+func (s *Store) blobPath(sum string) string {
+	return filepath.Join(s.cfg.Dir, "blobs", sum[:2], sum)
+}
+
+// Put stores what r holds as an artifact with the kind, name, content
+// type, workspace and labels of a, kept for ttl, or Config.TTL when it is
+// zero. The owner is the user of ctx unless a names one.
+func (s *Store) Put(ctx context.Context, a Artifact, ttl time.Duration, r io.Reader) (*Artifact, error) {
+	if ttl < 0 {
+		return nil, ErrInvalidTTL
+	}
+	if ttl == 0 {
+		ttl = s.cfg.TTL
+	}
+	ttl = min(ttl, s.cfg.MaxTTL)
+	if a.Owner == "" {
+		if u := auth.UserFrom(ctx); u != nil {
+			a.Owner = u.ID
+		}
+	}
+	if a.ContentType == "" {
+		a.ContentType = "application/octet-stream"
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(s.cfg.Dir, "blobs"), ".put-*")
+	if err != nil {
+		return nil, fmt.Errorf("artifact: store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("artifact: store: %w", err)
+	}
+	a.ID, a.Size, a.SHA256, a.URL = id, n, hex.EncodeToString(h.Sum(nil)), ""
+	a.CreatedAt = s.now().UTC()
+	a.ExpiresAt = a.CreatedAt.Add(ttl)
+
+	// The content is linked and the record written under the lock, so
+	// a sweep cannot remove content a new record is about to refer to.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob := s.blobPath(a.SHA256)
+	if _, err := os.Stat(blob); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(blob), 0o700); err != nil {
+			return nil, fmt.Errorf("artifact: store: %w", err)
+		}
+		if err := os.Rename(tmp.Name(), blob); err != nil {
+			return nil, fmt.Errorf("artifact: store: %w", err)
+		}
+	}
+	if err := s.writeLocked(&a); err != nil {
+		s.removeBlobLocked(a.SHA256)
+		return nil, err
+	}
+	s.records[a.ID] = &a
+	return s.view(&a), nil
+}
+
+// PutFile is Put with the contents of the file at path.
+func (s *Store) PutFile(ctx context.Context, a Artifact, ttl time.Duration, path string) (*Artifact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("artifact: store: %w", err)
+	}
+	defer f.Close()
+	return s.Put(ctx, a, ttl, f)
+}
+
+func (s *Store) writeLocked(a *Artifact) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	tmp := s.recordPath(a.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("artifact: write record: %w", err)
+	}
+	if err := os.Rename(tmp, s.recordPath(a.ID)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("artifact: write record: %w", err)
+	}
+	return nil
+}
+
+// Get returns the artifact id.
+func (s *Store) Get(id string) (*Artifact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.getLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.view(a), nil
+}
+
+func (s *Store) getLocked(id string) (*Artifact, error) {
+	a, ok := s.records[id]
+	if !ok || !s.now().Before(a.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return a, nil
+}
+
+// Path returns the file holding the content of a.
+func (s *Store) Path(a *Artifact) string {
+	return s.blobPath(a.SHA256)
+}
+
+// Open returns the artifact id and its opened content.
+func (s *Store) Open(id string) (*Artifact, *os.File, error) {
+	a, err := s.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(s.Path(a))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("artifact: open %s: %w", id, err)
+	}
+	return a, f, nil
+}
+
+// List returns the artifacts f selects, newest first.
+func (s *Store) List(f Filter) []*Artifact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var out []*Artifact
+	for _, a := range s.records {
+		if now.Before(a.ExpiresAt) && f.match(a) {
+			out = append(out, s.view(a))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Extend keeps the artifact id until ttl from now, at most Config.MaxTTL
+// after it was created.
+func (s *Store) Extend(id string, ttl time.Duration) (*Artifact, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.getLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	c := *a
+	c.ExpiresAt = s.now().UTC().Add(ttl)
+	if limit := c.CreatedAt.Add(s.cfg.MaxTTL); c.ExpiresAt.After(limit) {
+		c.ExpiresAt = limit
+	}
+	if err := s.writeLocked(&c); err != nil {
+		return nil, err
+	}
+	s.records[id] = &c
+	return s.view(&c), nil
+}
+
+// Delete removes the artifact id, and its content unless another
+// artifact holds the same.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.getLocked(id)
+	if err != nil {
+		return err
+	}
+	s.deleteLocked(a)
+	return nil
+}
+
+func (s *Store) deleteLocked(a *Artifact) {
+	delete(s.records, a.ID)
+	if err := os.Remove(s.recordPath(a.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("artifact: remove record", "id", a.ID, "err", err)
+	}
+	for _, o := range s.records {
+		if o.SHA256 == a.SHA256 {
+			return
+		}
+	}
+	s.removeBlobLocked(a.SHA256)
+}
+
+func (s *Store) removeBlobLocked(sum string) {
+	if err := os.Remove(s.blobPath(sum)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("artifact: remove content", "sha256", sum, "err", err)
+	}
+	// The directory of the hash's prefix goes once empty.
+	os.Remove(filepath.Dir(s.blobPath(sum)))
+}
+
+// Prune removes the expired artifacts and returns how many there were.
+func (s *Store) Prune() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	n := 0
+	for _, a := range s.records {
+		if !now.Before(a.ExpiresAt) {
+			s.deleteLocked(a)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Store) sweep() {
+	defer close(s.done)
+	t := time.NewTicker(sweepInterval)
+	defer t.Stop()
+	for {
+		if n := s.Prune(); n > 0 {
+			slog.Info("artifact: removed expired artifacts", "count", n)
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// view returns a copy of a with its download URL.
+func (s *Store) view(a *Artifact) *Artifact {
+	c := *a
+	exp := strconv.FormatInt(a.ExpiresAt.Unix(), 10)
+	c.URL = "/api/artifacts/" + a.ID + "/download?" + url.Values{"expires": {exp}, "sig": {s.sign(a.ID, exp)}}.Encode()
+	return &c
+}
+
+func (s *Store) sign(id, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "|" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether sig signs the download of the artifact id until
+// expires, a Unix time that has not passed.
+func (s *Store) Verify(id, expires, sig string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.now().Unix() >= exp {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.sign(id, expires)))
+}
+
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("artifact: id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
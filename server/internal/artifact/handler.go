@@ -0,0 +1,241 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Roles reports a user's role on a workspace, "" for none, as
+// access.Service does.
+type Roles interface {
+	Role(workspaceID, userID string) (workspace.Role, error)
+}
+
+// Accounts tells the server's administrators, as auth.Service does.
+type Accounts interface {
+	IsAdmin(u *auth.User) bool
+}
+
+// Access says who may see and change artifacts besides their owners: the
+// users with a role on an artifact's workspace, as told by Roles, and the
+// administrators of Accounts. Either may be nil.
+type Access struct {
+	Roles    Roles
+	Accounts Accounts
+}
+
+// Reads reports whether the user of ctx may see a: its owner, the users
+// with a role on its workspace, administrators, and anyone without
+// sign-in.
+func (ac Access) Reads(ctx context.Context, a *Artifact) bool {
+	u := auth.UserFrom(ctx)
+	if u == nil || (a.Owner != "" && a.Owner == u.ID) || ac.admin(u) {
+		return true
+	}
+	if a.Workspace == "" || ac.Roles == nil {
+		return false
+	}
+	role, err := ac.Roles.Role(a.Workspace, u.ID)
+	return err == nil && role != ""
+}
+
+// Owns reports whether the user of ctx may change a: its owner,
+// administrators, and anyone without sign-in. Only administrators change
+// artifacts without an owner.
+func (ac Access) Owns(ctx context.Context, a *Artifact) bool {
+	u := auth.UserFrom(ctx)
+	return u == nil || (a.Owner != "" && a.Owner == u.ID) || ac.admin(u)
+}
+
+func (ac Access) admin(u *auth.User) bool {
+	return ac.Accounts != nil && ac.Accounts.IsAdmin(u)
+}
+
+// Handler serves the artifact API.
+type Handler struct {
+	store      *Store
+	workspaces Workspaces
+	access     Access
+}
+
+// NewHandler returns a Handler for the artifacts of s. Signed-in users
+// reach the artifacts they made and those of the workspaces roles shares
+// with them; administrators of accounts reach every artifact.
+func NewHandler(s *Store, wm Workspaces, roles Roles, accounts Accounts) *Handler {
+	return &Handler{store: s, workspaces: wm, access: Access{Roles: roles, Accounts: accounts}}
+}
+
+// Register mounts the artifact routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/artifacts", h.list)
+	mux.HandleFunc("GET /api/workspaces/{id}/artifacts", h.listWorkspace)
+	mux.HandleFunc("GET /api/artifacts/{id}", h.get)
+	mux.HandleFunc("GET /api/artifacts/{id}/download", h.download)
+	mux.HandleFunc("PATCH /api/artifacts/{id}", h.extend)
+	mux.HandleFunc("DELETE /api/artifacts/{id}", h.remove)
+}
+
+// list returns the caller's artifacts, newest first, of ?kind= if set.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	f := Filter{Kind: Kind(r.URL.Query().Get("kind"))}
+	if u := auth.UserFrom(r.Context()); u != nil {
+		f.Owner = u.ID
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"artifacts": orEmpty(h.store.List(f))})
+}
+
+// listWorkspace returns the artifacts made from a workspace, newest
+// first, of ?kind= if set.
+func (h *Handler) listWorkspace(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	f := Filter{Workspace: id, Kind: Kind(r.URL.Query().Get("kind"))}
+	httpx.JSON(w, http.StatusOK, map[string]any{"artifacts": orEmpty(h.store.List(f))})
+}
+
+func orEmpty(as []*Artifact) []*Artifact {
+	if as == nil {
+		return []*Artifact{}
+	}
+	return as
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	a, err := h.store.Get(r.PathValue("id"))
+	if err == nil && !h.reads(r, a) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, a)
+}
+
+// download serves an artifact as an attachment, or with inline=1 for
+// display in the browser. A request with the expires and sig of its URL
+// needs no access token.
+func (h *Handler) download(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	q := r.URL.Query()
+	signed := q.Has("sig")
+	if signed && !h.store.Verify(id, q.Get("expires"), q.Get("sig")) {
+		httpx.Error(w, http.StatusForbidden, "invalid or expired download link")
+		return
+	}
+	a, f, err := h.store.Open(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	if !signed && !h.reads(r, a) {
+		writeError(w, ErrNotFound)
+		return
+	}
+	disposition := "attachment"
+	if q.Get("inline") == "1" {
+		disposition = "inline"
+		// Reports are shown as standalone pages, without scripts or
+		// access to the IDE's origin.
+		w.Header().Set("Content-Security-Policy", "sandbox; default-src 'none'; style-src 'unsafe-inline'; img-src data:")
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Content-Sha256", a.SHA256)
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	http.ServeContent(w, r, a.Name, a.CreatedAt, f)
+}
+
+type extendRequest struct {
+	// TTLSeconds is how long from now to keep the artifact.
+	TTLSeconds int64 `json:"ttlSeconds"`
+}
+
+// extend keeps an artifact for longer, or less long.
+func (h *Handler) extend(w http.ResponseWriter, r *http.Request) {
+	a, err := h.store.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !h.reads(r, a) {
+		writeError(w, ErrNotFound)
+		return
+	}
+	if !h.owns(r, a) {
+		httpx.Error(w, http.StatusForbidden, "only the owner may change an artifact")
+		return
+	}
+	var req extendRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if a, err = h.store.Extend(a.ID, time.Duration(req.TTLSeconds)*time.Second); err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, a)
+}
+
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	a, err := h.store.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !h.reads(r, a) {
+		writeError(w, ErrNotFound)
+		return
+	}
+	if !h.owns(r, a) {
+		httpx.Error(w, http.StatusForbidden, "only the owner may delete an artifact")
+		return
+	}
+	if err := h.store.Delete(a.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reads reports whether the caller of r may see a. Artifacts the caller
+// may not see are answered as not found.
+func (h *Handler) reads(r *http.Request, a *Artifact) bool {
+	return h.access.Reads(r.Context(), a)
+}
+
+// owns reports whether the caller of r may change a.
+func (h *Handler) owns(r *http.Request, a *Artifact) bool {
+	return h.access.Owns(r.Context(), a)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "artifact not found")
+	case errors.Is(err, ErrInvalidTTL):
+		httpx.Error(w, http.StatusBadRequest, "ttlSeconds must be positive")
+	default:
+		slog.Error("artifact", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "artifact operation failed")
+	}
+}
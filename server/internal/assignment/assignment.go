@@ -0,0 +1,859 @@
+// Package assignment grades classroom assignments. An organization's
+// admins, its instructors, define assignments with hidden Go tests and the
+// constraints a submission must meet; its members, the students, submit a
+// workspace, which is copied, given the hidden tests in place of its own
+// and tested in a sandbox of its own. Each submission is scored by the
+// points of its passing tests.
+//
+// Assignments and their submissions are kept in Config.Dir, one file per
+// assignment.
+package assignment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/gotest"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the assignments; defaults to a directory under the OS
+	// temp dir.
+	Dir string
+	// WorkDir is where submissions are copied to be graded; defaults to
+	// "work" under Dir. The sandboxes must be able to mount it, like the
+	// workspaces.
+	WorkDir string
+	// MaxTestBytes bounds the hidden tests of an assignment; defaults to
+	// 1 MiB.
+	MaxTestBytes int
+	// MaxWorkspaceBytes bounds the files copied from a submitted
+	// workspace; defaults to 100 MiB.
+	MaxWorkspaceBytes int64
+	// MaxOutputBytes bounds the output kept per test; defaults to 8 KiB.
+	MaxOutputBytes int
+	// Workers is how many submissions are graded at once; defaults to 2.
+	Workers int
+}
+
+// Tests runs go test in a directory, as gotest.Service does.
+type Tests interface {
+	Run(ctx context.Context, workspaceID, dir string, req gotest.Request) (*gotest.Report, error)
+}
+
+// Sandboxes removes the sandbox a submission was tested in.
+type Sandboxes interface {
+	Remove(ctx context.Context, id string) error
+}
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Orgs reports a user's membership of an organization.
+type Orgs interface {
+	Get(id, userID string) (*org.Org, string, error)
+}
+
+// Roles reports a user's role on a workspace, "" for none.
+type Roles interface {
+	Role(workspaceID, userID string) (workspace.Role, error)
+}
+
+var (
+	// ErrNotFound is returned for unknown assignments and submissions.
+	ErrNotFound = errors.New("assignment: not found")
+	// ErrInvalid is returned for invalid assignments and submissions.
+	ErrInvalid = errors.New("assignment: invalid request")
+	// ErrForbidden is returned when the caller may not see or change
+	// what they asked for.
+	ErrForbidden = errors.New("assignment: forbidden")
+	// ErrClosed is returned for submissions after the due date.
+	ErrClosed = errors.New("assignment: past the due date")
+	// ErrLimit is returned for submissions beyond MaxSubmissions.
+	ErrLimit = errors.New("assignment: no submissions left")
+	// ErrBusy is returned when too many submissions wait to be graded.
+	ErrBusy = errors.New("assignment: too many submissions waiting")
+)
+
+// Statuses of a submission.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusGraded  = "graded"
+	// StatusFailed means the submission could not be graded, for reasons
+	// of the server's rather than the student's, such as a missing
+	// workspace; Submission.Error says why.
+	StatusFailed = "failed"
+)
+
+// StatusNotRun is the TestFeedback status of a hidden test that go test did
+// not report, such as after a build failure.
+const StatusNotRun = "notrun"
+
+// File is a hidden test file.
+type File struct {
+	// Path is relative to the workspace root and ends in _test.go.
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Assignment is a task graded by hidden tests.
+type Assignment struct {
+	ID          string `json:"id"`
+	Org         string `json:"org"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	// Module is the directory of the Go module relative to the workspace
+	// root; defaults to the root. The tests must be inside it.
+	Module string `json:"module,omitempty"`
+	// Tests are the hidden test files; only instructors see them. They
+	// replace every test file of the submitted workspace.
+	Tests []File `json:"tests,omitempty"`
+	// Points are what each top-level test is worth, by name; tests not
+	// listed are worth 1.
+	Points map[string]int `json:"points,omitempty"`
+	// Due, when set, is when submissions close.
+	Due *time.Time `json:"due,omitempty"`
+	// MaxSubmissions caps each student's submissions; 0 allows any
+	// number.
+	MaxSubmissions int `json:"maxSubmissions,omitempty"`
+	// TimeoutMS bounds the test run, as gotest.Request.TimeoutMS.
+	TimeoutMS int64 `json:"timeoutMs,omitempty"`
+	// Required are files a submission must have, relative to the
+	// workspace root.
+	Required []string `json:"required,omitempty"`
+	// ForbiddenImports are packages the submission's own Go files may not
+	// import, such as "os/exec"; a path ending in "/..." forbids the
+	// packages under it too.
+	ForbiddenImports []string `json:"forbiddenImports,omitempty"`
+	// ShowOutput shows students the output of the tests and of go test,
+	// which can give away what the hidden tests check.
+	ShowOutput bool `json:"showOutput,omitempty"`
+	// MaxScore is the sum of the points of the hidden tests.
+	MaxScore  int       `json:"maxScore"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
+
+// forStudents returns a without what only instructors see.
+func (a Assignment) forStudents() Assignment {
+	a.Tests = nil
+	return a
+}
+
+// TestFeedback is the outcome of one hidden test.
+type TestFeedback struct {
+	Name      string `json:"name"`
+	Package   string `json:"package,omitempty"`
+	Status    string `json:"status"`
+	Points    int    `json:"points"`
+	MaxPoints int    `json:"maxPoints"`
+	// Output is the test's output, truncated to Config.MaxOutputBytes.
+	Output string `json:"output,omitempty"`
+}
+
+// Submission is a workspace submitted for grading.
+type Submission struct {
+	ID         string         `json:"id"`
+	Assignment string         `json:"assignment"`
+	Workspace  string         `json:"workspace"`
+	User       string         `json:"user"`
+	Email      string         `json:"email,omitempty"`
+	Time       time.Time      `json:"time"`
+	Status     string         `json:"status"`
+	Score      int            `json:"score"`
+	MaxScore   int            `json:"maxScore"`
+	Tests      []TestFeedback `json:"tests,omitempty"`
+	// Violations are the constraints the submission broke, such as a
+	// missing file or a forbidden import. A submission with violations
+	// is not tested and scores 0.
+	Violations []string `json:"violations,omitempty"`
+	// Output is what go test printed outside of the tests, such as build
+	// errors.
+	Output     string     `json:"output,omitempty"`
+	TimedOut   bool       `json:"timedOut,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	DurationMS int64      `json:"durationMs,omitempty"`
+	GradedAt   *time.Time `json:"gradedAt,omitempty"`
+}
+
+// forStudents returns sub without the output a hides from students.
+func (sub Submission) forStudents(a *Assignment) Submission {
+	if a.ShowOutput {
+		return sub
+	}
+	sub.Output = ""
+	sub.Tests = slices.Clone(sub.Tests)
+	for i := range sub.Tests {
+		sub.Tests[i].Output = ""
+	}
+	return sub
+}
+
+// Grade is a student's standing on an assignment, from their graded
+// submissions.
+type Grade struct {
+	User        string `json:"user"`
+	Email       string `json:"email,omitempty"`
+	Submissions int    `json:"submissions"`
+	// Best is the highest score and Latest the last graded submission.
+	Best     int         `json:"best"`
+	MaxScore int         `json:"maxScore"`
+	Latest   *Submission `json:"latest,omitempty"`
+}
+
+// Stats sums up the submissions to an assignment over a period, without
+// telling the students apart.
+type Stats struct {
+	Assignment string     `json:"assignment"`
+	Title      string     `json:"title"`
+	Due        *time.Time `json:"due,omitempty"`
+	MaxScore   int        `json:"maxScore"`
+	// Submissions counts the submissions of the period, Graded those
+	// graded and Failed those that could not be.
+	Submissions int `json:"submissions"`
+	Graded      int `json:"graded"`
+	Failed      int `json:"failed"`
+	// Students is how many students submitted, and Completed how many of
+	// them reached MaxScore.
+	Students  int `json:"students"`
+	Completed int `json:"completed"`
+	// AverageScore is the mean of each student's best score, and
+	// AverageDurationMS how long grading took on average.
+	AverageScore      float64 `json:"averageScore"`
+	AverageDurationMS int64   `json:"averageDurationMs"`
+}
+
+type record struct {
+	Assignment  Assignment    `json:"assignment"`
+	Submissions []*Submission `json:"submissions"`
+}
+
+// Service keeps assignments and grades their submissions.
+type Service struct {
+	cfg        Config
+	tests      Tests
+	sandboxes  Sandboxes
+	workspaces Workspaces
+	orgs       Orgs
+	roles      Roles
+	now        func() time.Time
+
+	mu      sync.Mutex
+	records map[string]*record
+
+	queue chan string // submission IDs, as assignment/submission
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// queueSize bounds the submissions waiting to be graded.
+const queueSize = 256
+
+// New returns a Service, filling unset Config fields with defaults, and
+// starts its grading workers. Submissions a previous server left queued
+// or running are marked failed. sandboxes may be nil, for sandboxes that
+// leave nothing behind.
+func New(cfg Config, tests Tests, sandboxes Sandboxes, ws Workspaces, orgs Orgs, roles Roles) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-assignments")
+	}
+	if cfg.WorkDir == "" {
+		cfg.WorkDir = filepath.Join(cfg.Dir, "work")
+	}
+	if cfg.MaxTestBytes <= 0 {
+		cfg.MaxTestBytes = 1 << 20
+	}
+	if cfg.MaxWorkspaceBytes <= 0 {
+		cfg.MaxWorkspaceBytes = 100 << 20
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 8 << 10
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	for _, d := range []string{cfg.Dir, cfg.WorkDir} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return nil, fmt.Errorf("assignment: create dir: %w", err)
+		}
+	}
+	s := &Service{
+		cfg:        cfg,
+		tests:      tests,
+		sandboxes:  sandboxes,
+		workspaces: ws,
+		orgs:       orgs,
+		roles:      roles,
+		now:        time.Now,
+		records:    make(map[string]*record),
+		queue:      make(chan string, queueSize),
+		stop:       make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	for range cfg.Workers {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s, nil
+}
+
+// Close stops grading, once the submissions being graded are done.
+// Queued submissions are marked failed when the Service next starts.
+func (s *Service) Close() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Service) load() error {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("assignment: read assignments: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.cfg.Dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("assignment: read assignments: %w", err)
+		}
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("assignment: read %s: %w", e.Name(), err)
+		}
+		interrupted := false
+		for _, sub := range rec.Submissions {
+			if sub.Status == StatusQueued || sub.Status == StatusRunning {
+				sub.Status, sub.Error = StatusFailed, "grading was interrupted by a server restart; submit again"
+				interrupted = true
+			}
+		}
+		s.records[rec.Assignment.ID] = &rec
+		if interrupted {
+			if err := s.saveLocked(&rec); err != nil {
+				return err
+			}
+		}
+	}
+	// Copies left by grading that was interrupted.
+	if entries, err := os.ReadDir(s.cfg.WorkDir); err == nil {
+		for _, e := range entries {
+			os.RemoveAll(filepath.Join(s.cfg.WorkDir, e.Name()))
+		}
+	}
+	return nil
+}
+
+// role returns the caller's role in an organization. Without an
+// organization service, or a signed-in user, there are no members.
+func (s *Service) role(orgID, userID string) (string, error) {
+	if s.orgs == nil || userID == "" {
+		return "", fmt.Errorf("%w: no organization %s", org.ErrNotFound, orgID)
+	}
+	_, role, err := s.orgs.Get(orgID, userID)
+	return role, err
+}
+
+// admin checks that userID is an admin of orgID.
+func (s *Service) admin(orgID, userID string) error {
+	role, err := s.role(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if role != org.RoleAdmin {
+		return fmt.Errorf("%w: only organization admins manage assignments", ErrForbidden)
+	}
+	return nil
+}
+
+// recordLocked returns the record of an assignment of orgID. s.mu must be
+// held.
+func (s *Service) recordLocked(orgID, id string) (*record, error) {
+	rec, ok := s.records[id]
+	if !ok || rec.Assignment.Org != orgID {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+// List returns the assignments of an organization, newest first, to its
+// members. Only admins get the hidden tests.
+func (s *Service) List(orgID, userID string) ([]Assignment, error) {
+	role, err := s.role(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Assignment{}
+	for _, rec := range s.records {
+		if rec.Assignment.Org != orgID {
+			continue
+		}
+		if role == org.RoleAdmin {
+			out = append(out, rec.Assignment)
+		} else {
+			out = append(out, rec.Assignment.forStudents())
+		}
+	}
+	slices.SortFunc(out, func(a, b Assignment) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+// Get returns an assignment to the members of its organization. Only
+// admins get the hidden tests.
+func (s *Service) Get(orgID, id, userID string) (*Assignment, error) {
+	role, err := s.role(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.recordLocked(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	a := rec.Assignment
+	if role != org.RoleAdmin {
+		a = a.forStudents()
+	}
+	return &a, nil
+}
+
+// Create adds an assignment to an organization; userID must be one of its
+// admins. The ID, organization and times of a are set by Create.
+func (s *Service) Create(orgID, userID string, a Assignment) (*Assignment, error) {
+	if err := s.admin(orgID, userID); err != nil {
+		return nil, err
+	}
+	if err := s.check(&a); err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	a.ID, a.Org, a.CreatedBy, a.CreatedAt, a.UpdatedAt = "as-"+newID(), orgID, userID, now, now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := &record{Assignment: a, Submissions: []*Submission{}}
+	if err := s.saveLocked(rec); err != nil {
+		return nil, err
+	}
+	s.records[a.ID] = rec
+	return &a, nil
+}
+
+// Update replaces an assignment, keeping its submissions and their
+// scores; userID must be an admin of its organization.
+func (s *Service) Update(orgID, id, userID string, a Assignment) (*Assignment, error) {
+	if err := s.admin(orgID, userID); err != nil {
+		return nil, err
+	}
+	if err := s.check(&a); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.recordLocked(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	old := rec.Assignment
+	a.ID, a.Org, a.CreatedBy, a.CreatedAt, a.UpdatedAt = old.ID, old.Org, old.CreatedBy, old.CreatedAt, s.now().UTC()
+	rec.Assignment = a
+	if err := s.saveLocked(rec); err != nil {
+		rec.Assignment = old
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Delete removes an assignment and its submissions; userID must be an
+// admin of its organization.
+func (s *Service) Delete(orgID, id, userID string) error {
+	if err := s.admin(orgID, userID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.recordLocked(orgID, id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("assignment: delete: %w", err)
+	}
+	delete(s.records, id)
+	return nil
+}
+
+// Submit queues the workspace wsID for grading. userID must be a member
+// of the assignment's organization and an owner or editor of the
+// workspace.
+func (s *Service) Submit(orgID, id, wsID, userID, email string) (*Submission, error) {
+	if _, err := s.role(orgID, userID); err != nil {
+		return nil, err
+	}
+	if _, err := s.workspaces.Open(wsID); err != nil {
+		return nil, fmt.Errorf("%w: workspace %s", ErrNotFound, wsID)
+	}
+	if s.roles != nil {
+		role, err := s.roles.Role(wsID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if role != workspace.RoleOwner && role != workspace.RoleEditor {
+			return nil, fmt.Errorf("%w: submit a workspace you can edit", ErrForbidden)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.recordLocked(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	a := &rec.Assignment
+	now := s.now().UTC()
+	if a.Due != nil && now.After(*a.Due) {
+		return nil, fmt.Errorf("%w: it was due %s", ErrClosed, a.Due.Format(time.RFC3339))
+	}
+	if a.MaxSubmissions > 0 {
+		n := 0
+		for _, sub := range rec.Submissions {
+			if sub.User == userID && sub.Status != StatusFailed {
+				n++
+			}
+		}
+		if n >= a.MaxSubmissions {
+			return nil, fmt.Errorf("%w: %d of %d used", ErrLimit, n, a.MaxSubmissions)
+		}
+	}
+	sub := &Submission{
+		ID:         "sub-" + newID(),
+		Assignment: id,
+		Workspace:  wsID,
+		User:       userID,
+		Email:      email,
+		Time:       now,
+		Status:     StatusQueued,
+		MaxScore:   a.MaxScore,
+	}
+	select {
+	case s.queue <- id + "/" + sub.ID:
+	default:
+		return nil, ErrBusy
+	}
+	rec.Submissions = append(rec.Submissions, sub)
+	if err := s.saveLocked(rec); err != nil {
+		slog.Error("assignment: save submission", "assignment", id, "err", err)
+	}
+	out := sub.forStudents(a)
+	return &out, nil
+}
+
+// Submissions returns an assignment's submissions, newest first: all of
+// them, or those of one user with forUser, to admins, and their own to
+// other members.
+func (s *Service) Submissions(orgID, id, userID, forUser string) ([]Submission, error) {
+	role, err := s.role(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role != org.RoleAdmin {
+		forUser = userID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.recordLocked(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	out := []Submission{}
+	for _, sub := range slices.Backward(rec.Submissions) {
+		if forUser != "" && sub.User != forUser {
+			continue
+		}
+		if role == org.RoleAdmin {
+			out = append(out, *sub)
+		} else {
+			out = append(out, sub.forStudents(&rec.Assignment))
+		}
+	}
+	return out, nil
+}
+
+// Submission returns a submission to the admins of the organization and
+// to the student who made it.
+func (s *Service) Submission(orgID, id, subID, userID string) (*Submission, error) {
+	role, err := s.role(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.recordLocked(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range rec.Submissions {
+		if sub.ID != subID {
+			continue
+		}
+		switch {
+		case role == org.RoleAdmin:
+			out := *sub
+			return &out, nil
+		case sub.User == userID:
+			out := sub.forStudents(&rec.Assignment)
+			return &out, nil
+		}
+		break
+	}
+	return nil, ErrNotFound
+}
+
+// Grades returns the standing of every student who submitted to an
+// assignment, by user ID; userID must be an admin of its organization.
+func (s *Service) Grades(orgID, id, userID string) ([]Grade, error) {
+	if err := s.admin(orgID, userID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.recordLocked(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[string]*Grade)
+	for _, sub := range rec.Submissions {
+		g := byUser[sub.User]
+		if g == nil {
+			g = &Grade{User: sub.User, Email: sub.Email, MaxScore: rec.Assignment.MaxScore}
+			byUser[sub.User] = g
+		}
+		g.Submissions++
+		if sub.Status != StatusGraded {
+			continue
+		}
+		latest := *sub
+		g.Best, g.Latest = max(g.Best, sub.Score), &latest
+	}
+	out := make([]Grade, 0, len(byUser))
+	for _, g := range byUser {
+		out = append(out, *g)
+	}
+	slices.SortFunc(out, func(a, b Grade) int { return strings.Compare(a.User, b.User) })
+	return out, nil
+}
+
+// Stats sums up the submissions made between from and to to each
+// assignment of an organization, newest assignment first. It does not
+// check who is asking; the analytics package does.
+func (s *Service) Stats(orgID string, from, to time.Time) []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Stats{}
+	for _, rec := range s.records {
+		a := &rec.Assignment
+		if a.Org != orgID {
+			continue
+		}
+		st := Stats{Assignment: a.ID, Title: a.Title, Due: a.Due, MaxScore: a.MaxScore}
+		best := make(map[string]int)
+		var duration int64
+		for _, sub := range rec.Submissions {
+			if sub.Time.Before(from) || !sub.Time.Before(to) {
+				continue
+			}
+			st.Submissions++
+			if _, ok := best[sub.User]; !ok {
+				best[sub.User] = 0
+			}
+			switch sub.Status {
+			case StatusGraded:
+				st.Graded++
+				duration += sub.DurationMS
+				best[sub.User] = max(best[sub.User], sub.Score)
+			case StatusFailed:
+				st.Failed++
+			}
+		}
+		st.Students = len(best)
+		total := 0
+		for _, score := range best {
+			total += score
+			if score >= a.MaxScore {
+				st.Completed++
+			}
+		}
+		if st.Students > 0 {
+			st.AverageScore = float64(total) / float64(st.Students)
+		}
+		if st.Graded > 0 {
+			st.AverageDurationMS = duration / int64(st.Graded)
+		}
+		out = append(out, st)
+	}
+	slices.SortFunc(out, func(a, b Stats) int {
+		return s.records[b.Assignment].Assignment.CreatedAt.Compare(s.records[a.Assignment].Assignment.CreatedAt)
+	})
+	return out
+}
+
+// check validates a and sets its MaxScore.
+func (s *Service) check(a *Assignment) error {
+	a.Title = strings.TrimSpace(a.Title)
+	if a.Title == "" || len(a.Title) > 200 {
+		return fmt.Errorf("%w: title must have 1 to 200 characters", ErrInvalid)
+	}
+	if a.Module != "" {
+		m, ok := relPath(a.Module)
+		if !ok {
+			return fmt.Errorf("%w: module must be a relative path inside the workspace", ErrInvalid)
+		}
+		a.Module = m
+	}
+	if len(a.Tests) == 0 {
+		return fmt.Errorf("%w: an assignment needs hidden tests", ErrInvalid)
+	}
+	size := 0
+	var names []string
+	for i, f := range a.Tests {
+		p, ok := relPath(f.Path)
+		if !ok || !strings.HasSuffix(p, "_test.go") {
+			return fmt.Errorf("%w: test path %q must be a relative path ending in _test.go", ErrInvalid, f.Path)
+		}
+		if a.Module != "" && !strings.HasPrefix(p, a.Module+"/") {
+			return fmt.Errorf("%w: test %s is outside module %s", ErrInvalid, p, a.Module)
+		}
+		a.Tests[i].Path = p
+		size += len(f.Content)
+		found, err := testNames(p, f.Content)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		names = append(names, found...)
+	}
+	if size > s.cfg.MaxTestBytes {
+		return fmt.Errorf("%w: tests exceed %d bytes", ErrInvalid, s.cfg.MaxTestBytes)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%w: the hidden tests have no Test functions", ErrInvalid)
+	}
+	a.MaxScore = 0
+	for _, n := range names {
+		a.MaxScore += a.points(n)
+	}
+	for n, p := range a.Points {
+		if p < 0 {
+			return fmt.Errorf("%w: %s has negative points", ErrInvalid, n)
+		}
+	}
+	for i, f := range a.Required {
+		p, ok := relPath(f)
+		if !ok {
+			return fmt.Errorf("%w: required file %q must be a relative path", ErrInvalid, f)
+		}
+		a.Required[i] = p
+	}
+	for _, imp := range a.ForbiddenImports {
+		if imp == "" || strings.ContainsAny(imp, " \t\"") {
+			return fmt.Errorf("%w: invalid import path %q", ErrInvalid, imp)
+		}
+	}
+	if a.TimeoutMS < 0 || a.MaxSubmissions < 0 {
+		return fmt.Errorf("%w: timeoutMs and maxSubmissions must not be negative", ErrInvalid)
+	}
+	return nil
+}
+
+// points returns what the test named name is worth.
+func (a *Assignment) points(name string) int {
+	if p, ok := a.Points[name]; ok {
+		return p
+	}
+	return 1
+}
+
+// testNames returns the names of the Test functions of a test file.
+func testNames(name, src string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), name, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, d := range f.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && isTestName(fn.Name.Name) {
+			names = append(names, fn.Name.Name)
+		}
+	}
+	return names, nil
+}
+
+// isTestName reports whether go test runs a function named name as a
+// test: Test followed by nothing or by a character that is not a
+// lowercase letter.
+func isTestName(name string) bool {
+	rest, ok := strings.CutPrefix(name, "Test")
+	return ok && (rest == "" || rest[0] < 'a' || rest[0] > 'z')
+}
+
+// relPath cleans p, a slash-separated path, and reports whether it stays
+// inside the directory it is relative to.
+func relPath(p string) (string, bool) {
+	p = path.Clean(strings.TrimPrefix(p, "./"))
+	if p == "." || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") || strings.Contains(p, "\\") {
+		return "", false
+	}
+	return p, true
+}
+
+func (s *Service) path(id string) string {
+	return filepath.Join(s.cfg.Dir, id+".json")
+}
+
+// saveLocked writes rec. s.mu must be held.
+func (s *Service) saveLocked(rec *record) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := s.path(rec.Assignment.ID)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("assignment: write: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("assignment: write: %w", err)
+	}
+	return nil
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,301 @@
+package assignment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/gotest"
+)
+
+// skipDirs are not copied from submitted workspaces.
+var skipDirs = map[string]bool{".git": true, "node_modules": true}
+
+func (s *Service) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case key := <-s.queue:
+			id, subID, _ := strings.Cut(key, "/")
+			s.grade(id, subID)
+		}
+	}
+}
+
+// grade grades a queued submission and records the outcome.
+func (s *Service) grade(id, subID string) {
+	s.mu.Lock()
+	rec, ok := s.records[id]
+	var sub *Submission
+	if ok {
+		i := slices.IndexFunc(rec.Submissions, func(sub *Submission) bool { return sub.ID == subID })
+		if i >= 0 {
+			sub = rec.Submissions[i]
+		}
+	}
+	if sub == nil {
+		// The assignment was deleted meanwhile.
+		s.mu.Unlock()
+		return
+	}
+	a := rec.Assignment
+	sub.Status = StatusRunning
+	wsID := sub.Workspace
+	s.mu.Unlock()
+
+	start := s.now()
+	res, err := s.run(&a, subID, wsID)
+	if err != nil {
+		slog.Warn("assignment: grading failed", "assignment", id, "submission", subID, "err", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[id]; !ok {
+		return
+	}
+	if err != nil {
+		sub.Status, sub.Error = StatusFailed, err.Error()
+	} else {
+		sub.Status = StatusGraded
+		sub.Score, sub.MaxScore = res.Score, res.MaxScore
+		sub.Tests, sub.Violations = res.Tests, res.Violations
+		sub.Output, sub.TimedOut = res.Output, res.TimedOut
+	}
+	now := s.now().UTC()
+	sub.GradedAt, sub.DurationMS = &now, now.Sub(start).Milliseconds()
+	if err := s.saveLocked(rec); err != nil {
+		slog.Error("assignment: save submission", "assignment", id, "err", err)
+	}
+}
+
+// result is the outcome of a graded submission.
+type result struct {
+	Score, MaxScore int
+	Tests           []TestFeedback
+	Violations      []string
+	Output          string
+	TimedOut        bool
+}
+
+// run copies the workspace wsID, swaps its tests for the hidden ones,
+// checks the constraints of a and runs the tests in a sandbox of their
+// own, named for the submission.
+func (s *Service) run(a *Assignment, subID, wsID string) (*result, error) {
+	dir, err := s.workspaces.Open(wsID)
+	if err != nil {
+		return nil, fmt.Errorf("open workspace %s: %w", wsID, err)
+	}
+	work := filepath.Join(s.cfg.WorkDir, subID)
+	defer os.RemoveAll(work)
+	if err := copyTree(dir, work, s.cfg.MaxWorkspaceBytes); err != nil {
+		return nil, err
+	}
+
+	res := &result{MaxScore: a.MaxScore}
+	res.Violations = append(res.Violations, missing(work, a.Required)...)
+	imports, err := forbidden(filepath.Join(work, filepath.FromSlash(a.Module)), a.ForbiddenImports)
+	if err != nil {
+		return nil, err
+	}
+	res.Violations = append(res.Violations, imports...)
+
+	var pkgs []string
+	var names []string
+	for _, f := range a.Tests {
+		p := filepath.Join(work, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(p, []byte(f.Content), 0o644); err != nil {
+			return nil, err
+		}
+		rel := strings.TrimPrefix(path.Dir(f.Path), a.Module)
+		if pkg := "./" + strings.TrimPrefix(rel, "/"); !slices.Contains(pkgs, pkg) {
+			pkgs = append(pkgs, pkg)
+		}
+		found, _ := testNames(f.Path, f.Content)
+		names = append(names, found...)
+	}
+	if len(res.Violations) > 0 {
+		for _, n := range names {
+			res.Tests = append(res.Tests, TestFeedback{Name: n, Status: StatusNotRun, MaxPoints: a.points(n)})
+		}
+		return res, nil
+	}
+
+	sandbox := "grade-" + subID
+	rep, err := s.tests.Run(context.Background(), sandbox, work, gotest.Request{
+		Module:    a.Module,
+		Packages:  pkgs,
+		TimeoutMS: a.TimeoutMS,
+	})
+	if s.sandboxes != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := s.sandboxes.Remove(ctx, sandbox); err != nil {
+			slog.Warn("assignment: remove sandbox", "sandbox", sandbox, "err", err)
+		}
+		cancel()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run tests: %w", err)
+	}
+	res.TimedOut = rep.TimedOut
+	res.Output = rep.Output
+	byName := make(map[string]TestFeedback)
+	for _, p := range rep.Packages {
+		if p.Status == gotest.StatusFail {
+			res.Output += p.Output
+		}
+		for _, t := range p.Tests {
+			if strings.Contains(t.Name, "/") {
+				continue
+			}
+			byName[t.Name] = TestFeedback{Name: t.Name, Package: p.Package, Status: string(t.Status), Output: t.Output}
+		}
+	}
+	res.Output = s.truncate(res.Output)
+	for _, n := range names {
+		fb, ok := byName[n]
+		if !ok {
+			fb = TestFeedback{Name: n, Status: StatusNotRun}
+		}
+		fb.MaxPoints = a.points(n)
+		if fb.Status == string(gotest.StatusPass) {
+			fb.Points = fb.MaxPoints
+		}
+		fb.Output = s.truncate(fb.Output)
+		res.Score += fb.Points
+		res.Tests = append(res.Tests, fb)
+	}
+	return res, nil
+}
+
+// truncate cuts out to Config.MaxOutputBytes.
+func (s *Service) truncate(out string) string {
+	if len(out) <= s.cfg.MaxOutputBytes {
+		return out
+	}
+	return strings.ToValidUTF8(out[:s.cfg.MaxOutputBytes], "") + "\n... output truncated\n"
+}
+
+// copyTree copies the regular files under src to dst, except test files
+// and skipDirs, failing past max bytes.
+func copyTree(src, dst string, max int64) error {
+	var total int64
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir() && skipDirs[d.Name()] && p != src:
+			return filepath.SkipDir
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case !d.Type().IsRegular(), strings.HasSuffix(d.Name(), "_test.go"):
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if total += info.Size(); total > max {
+			return fmt.Errorf("workspace exceeds %d MiB", max>>20)
+		}
+		return copyFile(p, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// missing returns a violation for each required file not in dir.
+func missing(dir string, required []string) []string {
+	var out []string
+	for _, f := range required {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f))); errors.Is(err, fs.ErrNotExist) {
+			out = append(out, "missing required file "+f)
+		}
+	}
+	return out
+}
+
+// forbidden returns a violation for each import of a forbidden package in
+// the Go files under dir.
+func forbidden(dir string, imports []string) ([]string, error) {
+	if len(imports) == 0 {
+		return nil, nil
+	}
+	var out []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && p == dir:
+			return filepath.SkipDir
+		case err != nil:
+			return err
+		case d.IsDir() && (d.Name() == "vendor" || d.Name() == "testdata"):
+			return filepath.SkipDir
+		case d.IsDir() || filepath.Ext(p) != ".go":
+			return nil
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), p, nil, parser.ImportsOnly)
+		if err != nil {
+			// The build reports it.
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		for _, spec := range f.Imports {
+			imp, _ := strconv.Unquote(spec.Path.Value)
+			if matchImport(imp, imports) {
+				out = append(out, fmt.Sprintf("%s imports forbidden package %s", filepath.ToSlash(rel), imp))
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// matchImport reports whether imp is one of patterns, or under one ending
+// in "/...".
+func matchImport(imp string, patterns []string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "/..."); ok {
+			if imp == prefix || strings.HasPrefix(imp, prefix+"/") {
+				return true
+			}
+		} else if imp == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package assignment
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+)
+
+// Handler serves the assignment routes.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts the assignment routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/orgs/{org}/assignments", h.list)
+	mux.HandleFunc("POST /api/orgs/{org}/assignments", h.create)
+	mux.HandleFunc("GET /api/orgs/{org}/assignments/{assignment}", h.get)
+	mux.HandleFunc("PUT /api/orgs/{org}/assignments/{assignment}", h.update)
+	mux.HandleFunc("DELETE /api/orgs/{org}/assignments/{assignment}", h.delete)
+	mux.HandleFunc("POST /api/orgs/{org}/assignments/{assignment}/submissions", h.submit)
+	mux.HandleFunc("GET /api/orgs/{org}/assignments/{assignment}/submissions", h.submissions)
+	mux.HandleFunc("GET /api/orgs/{org}/assignments/{assignment}/submissions/{submission}", h.submission)
+	mux.HandleFunc("GET /api/orgs/{org}/assignments/{assignment}/grades", h.grades)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.svc.List(r.PathValue("org"), u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"assignments": list})
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req Assignment
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a, err := h.svc.Create(r.PathValue("org"), u.ID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, a)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	a, err := h.svc.Get(r.PathValue("org"), r.PathValue("assignment"), u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, a)
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req Assignment
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a, err := h.svc.Update(r.PathValue("org"), r.PathValue("assignment"), u.ID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, a)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(r.PathValue("org"), r.PathValue("assignment"), u.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type submitRequest struct {
+	Workspace string `json:"workspace"`
+}
+
+// submit queues a workspace for grading; the client polls the submission
+// until it is graded.
+func (h *Handler) submit(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req submitRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Workspace == "" {
+		httpx.Error(w, http.StatusBadRequest, "workspace is required")
+		return
+	}
+	sub, err := h.svc.Submit(r.PathValue("org"), r.PathValue("assignment"), req.Workspace, u.ID, u.Email)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusAccepted, sub)
+}
+
+// submissions lists an assignment's submissions: the caller's own, or for
+// admins everyone's, filtered by ?user=.
+func (h *Handler) submissions(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.svc.Submissions(r.PathValue("org"), r.PathValue("assignment"), u.ID, r.URL.Query().Get("user"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"submissions": list})
+}
+
+func (h *Handler) submission(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	sub, err := h.svc.Submission(r.PathValue("org"), r.PathValue("assignment"), r.PathValue("submission"), u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, sub)
+}
+
+func (h *Handler) grades(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	grades, err := h.svc.Grades(r.PathValue("org"), r.PathValue("assignment"), u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"grades": grades})
+}
+
+func user(w http.ResponseWriter, r *http.Request) (*auth.User, bool) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "sign in to use assignments")
+		return nil, false
+	}
+	return u, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound), errors.Is(err, org.ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrForbidden):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrClosed):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrLimit):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, ErrBusy):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		slog.Error("assignments", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "assignment request failed")
+	}
+}
@@ -0,0 +1,289 @@
+// Package audit keeps a log of sensitive actions: who deleted files, read
+// or changed secrets, created share links, deleted organizations, or used
+// the admin API, when, and from which address. Schools and companies keep
+// it for compliance, so entries are only ever appended.
+//
+// Middleware puts the Log and the client's address in each request's
+// context, and Record adds an entry for the signed-in user of a context.
+// Without a Log in the context Record does nothing, so the code that
+// records need not check whether auditing is on.
+//
+// The log is JSON lines in Config.Dir. Once the current file reaches
+// Config.MaxBytes it is renamed after the time it was rotated and a new
+// one started; rotated files are kept until the operator archives them.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Actions.
+const (
+	ActionFileDelete      = "file.delete"
+	ActionFileMove        = "file.move"
+	ActionTrashPurge      = "trash.purge"
+	ActionSecretSet       = "secret.set"
+	ActionSecretDelete    = "secret.delete"
+	ActionSecretInject    = "secret.inject"
+	ActionSecretAudit     = "secret.audit"
+	ActionShareLinkCreate = "share_link.create"
+	ActionShareLinkRevoke = "share_link.revoke"
+	ActionMemberRemove    = "workspace.member.remove"
+	ActionWorkspaceFork   = "workspace.fork"
+	ActionOrgDelete       = "org.delete"
+	ActionAuditRead       = "admin.audit.read"
+	ActionAuditExport     = "admin.audit.export"
+	ActionSandboxStop     = "admin.sandbox.stop"
+	ActionWorkspaceStop   = "admin.workspace.stop"
+	ActionQuotaSet        = "admin.quota.set"
+	ActionQuotaReset      = "admin.quota.reset"
+	ActionNotice          = "admin.notice"
+	ActionNoticeClear     = "admin.notice.clear"
+	ActionConfigReload    = "admin.config.reload"
+	ActionFlagSet         = "admin.flag.set"
+	ActionFlagDelete      = "admin.flag.delete"
+	ActionWebhookCreate   = "webhook.create"
+	ActionWebhookDelete   = "webhook.delete"
+	ActionJobCreate       = "job.create"
+	ActionJobDelete       = "job.delete"
+	ActionSSHKeyAdd       = "ssh_key.add"
+	ActionSSHKeyDelete    = "ssh_key.delete"
+	ActionSSHLogin        = "ssh.login"
+	ActionDotfilesSet     = "dotfiles.set"
+	ActionDotfilesDelete  = "dotfiles.delete"
+	ActionBootstrapRun    = "bootstrap.run"
+)
+
+// Entry is one recorded action.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// User and Email are who acted; empty for requests without a
+	// signed-in user.
+	User  string `json:"user,omitempty"`
+	Email string `json:"email,omitempty"`
+	IP    string `json:"ip,omitempty"`
+	// Workspace and Org are where, and Target what was acted on, such
+	// as a file path, a secret or a share link.
+	Workspace string            `json:"workspace,omitempty"`
+	Org       string            `json:"org,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Config configures a Log.
+type Config struct {
+	// Dir holds the log files; defaults to a directory under the OS temp
+	// dir.
+	Dir string
+	// MaxBytes is the size at which the current file is rotated; defaults
+	// to 16 MiB.
+	MaxBytes int64
+}
+
+// currentFile is the file entries are appended to.
+const currentFile = "audit.jsonl"
+
+// Log appends entries to the audit log and reads them back.
+type Log struct {
+	cfg Config
+	now func() time.Time
+
+	mu sync.Mutex
+}
+
+// New returns a Log, filling unset Config fields with defaults.
+func New(cfg Config) (*Log, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-audit")
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 16 << 20
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("audit: create dir: %w", err)
+	}
+	return &Log{cfg: cfg, now: time.Now}, nil
+}
+
+// Append adds e to the log, stamped with the current time.
+func (l *Log) Append(e Entry) error {
+	e.Time = l.now().UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p := filepath.Join(l.cfg.Dir, currentFile)
+	if fi, err := os.Stat(p); err == nil && fi.Size() >= l.cfg.MaxBytes {
+		rotated := filepath.Join(l.cfg.Dir, "audit-"+e.Time.Format("20060102T150405.000000000Z")+".jsonl")
+		if err := os.Rename(p, rotated); err != nil {
+			return fmt.Errorf("audit: rotate log: %w", err)
+		}
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: write log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("audit: write log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("audit: write log: %w", err)
+	}
+	return nil
+}
+
+// Filter selects entries. Zero fields match every entry.
+type Filter struct {
+	User      string
+	Workspace string
+	Org       string
+	// Action matches the action itself or, ending in ".", the actions it
+	// starts, such as "secret.".
+	Action string
+	// Since and Until bound the time of the entries, Until exclusively.
+	Since, Until time.Time
+}
+
+// Match reports whether f selects e.
+func (f Filter) Match(e *Entry) bool {
+	switch {
+	case f.User != "" && e.User != f.User,
+		f.Workspace != "" && e.Workspace != f.Workspace,
+		f.Org != "" && e.Org != f.Org,
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	case f.Action == "", e.Action == f.Action:
+		return true
+	}
+	return strings.HasSuffix(f.Action, ".") && strings.HasPrefix(e.Action, f.Action)
+}
+
+// Query returns the last limit entries f selects, newest first.
+func (l *Log) Query(f Filter, limit int) ([]Entry, error) {
+	var entries []Entry
+	err := l.Each(f, func(e *Entry) error {
+		entries = append(entries, *e)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// Each calls fn with the entries f selects, oldest first, until fn
+// returns an error.
+func (l *Log) Each(f Filter, fn func(*Entry) error) error {
+	l.mu.Lock()
+	files, err := l.files()
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, p := range files {
+		if err := readLog(p, f, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// files lists the log files, oldest first. The names of rotated files
+// sort by the time they were rotated.
+func (l *Log) files() ([]string, error) {
+	rotated, err := filepath.Glob(filepath.Join(l.cfg.Dir, "audit-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(rotated)
+	return append(rotated, filepath.Join(l.cfg.Dir, currentFile)), nil
+}
+
+func readLog(p string, f Filter, fn func(*Entry) error) error {
+	file, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("audit: read log: %w", err)
+	}
+	defer file.Close()
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e Entry
+		// A line cut short by a crash is skipped.
+		if json.Unmarshal(sc.Bytes(), &e) != nil || !f.Match(&e) {
+			continue
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("audit: read log: %w", err)
+	}
+	return nil
+}
+
+type (
+	logKey    struct{}
+	clientKey struct{}
+)
+
+// Middleware makes l and the client's address available to Record in the
+// context of each request. With trustProxy the address is taken from
+// X-Forwarded-For. Without a Log it returns next.
+func Middleware(l *Log, trustProxy bool, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), logKey{}, l)
+		ctx = context.WithValue(ctx, clientKey{}, httpx.ClientIP(r, trustProxy))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Record adds e to the Log of ctx, as done by the user of ctx from the
+// client's address. The action already happened, so a failure to record
+// it is logged rather than returned.
+func Record(ctx context.Context, e Entry) {
+	l, _ := ctx.Value(logKey{}).(*Log)
+	if l == nil {
+		return
+	}
+	if u := auth.UserFrom(ctx); u != nil && e.User == "" {
+		e.User, e.Email = u.ID, u.Email
+	}
+	if e.IP == "" {
+		e.IP, _ = ctx.Value(clientKey{}).(string)
+	}
+	if err := l.Append(e); err != nil {
+		slog.Error("audit: record", "action", e.Action, "user", e.User, "err", err)
+	}
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+const (
+	defaultEntries = 100
+	maxEntries     = 1000
+)
+
+// Admins tells the server's administrators, who may read the log.
+type Admins interface {
+	IsAdmin(u *auth.User) bool
+}
+
+// Handler serves the audit log to administrators.
+type Handler struct {
+	log    *Log
+	admins Admins
+}
+
+// NewHandler returns a Handler for log.
+func NewHandler(log *Log, admins Admins) *Handler {
+	return &Handler{log: log, admins: admins}
+}
+
+// Register mounts the audit routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/admin/audit", h.query)
+	mux.HandleFunc("GET /api/admin/audit/export", h.export)
+}
+
+// admin answers requests from anyone but an administrator with 403.
+func (h *Handler) admin(w http.ResponseWriter, r *http.Request) bool {
+	if !h.admins.IsAdmin(auth.UserFrom(r.Context())) {
+		httpx.Error(w, http.StatusForbidden, "administrators only")
+		return false
+	}
+	return true
+}
+
+// filter parses the user, workspace, org, action, since and until query
+// parameters; the times are RFC 3339.
+func filter(r *http.Request) (Filter, error) {
+	q := r.URL.Query()
+	f := Filter{User: q.Get("user"), Workspace: q.Get("workspace"), Org: q.Get("org"), Action: q.Get("action")}
+	for name, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(name); v != "" {
+			var err error
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				return Filter{}, errors.New(name + " must be an RFC 3339 time")
+			}
+		}
+	}
+	return f, nil
+}
+
+// query returns the newest matching entries, up to limit.
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	f, err := filter(r)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := defaultEntries
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxEntries {
+			httpx.Errorf(w, http.StatusBadRequest, "limit must be between 1 and %d", maxEntries)
+			return
+		}
+		limit = n
+	}
+	entries, err := h.log.Query(f, limit)
+	if err != nil {
+		slog.Error("audit: query", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not read the audit log")
+		return
+	}
+	Record(r.Context(), Entry{Action: ActionAuditRead, Details: details(r)})
+	if entries == nil {
+		entries = []Entry{}
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"entries": entries})
+}
+
+// export streams every matching entry, oldest first, as JSON lines.
+func (h *Handler) export(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	f, err := filter(r)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	Record(r.Context(), Entry{Action: ActionAuditExport, Details: details(r)})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	enc := json.NewEncoder(w)
+	err = h.log.Each(f, func(e *Entry) error { return enc.Encode(e) })
+	if err != nil {
+		// The status is sent; the truncated body is all that can tell.
+		slog.Error("audit: export", "err", err)
+	}
+}
+
+// details records the filter an administrator read the log with.
+func details(r *http.Request) map[string]string {
+	d := make(map[string]string)
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 && k != "limit" {
+			d[k] = v[0]
+		}
+	}
+	if len(d) == 0 {
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,442 @@
+// Package auth authenticates users of the IDE. Users sign up with an email
+// address and password, or sign in with GitHub or Google, and receive a short-lived JWT access token and a
+// long-lived refresh token; browsers can have both set as HttpOnly cookies
+// instead. The middleware requires a valid access token on every API and
+// WebSocket route except the public ones and confines workspace routes to
+// the workspace's owner.
+//
+// Users and refresh sessions are kept in JSON files by default, which suits
+// a single server, or in the Records a Config names, such as a database
+// several servers share; the signing key is generated on first start
+// unless one is configured.
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds users, sessions and the generated signing key; defaults to
+	// a directory under the OS temp dir.
+	Dir string
+	// Records stores users, sessions and personal access tokens; defaults
+	// to JSON files in Dir.
+	Records Records
+	// Logins holds OAuth flows until their callback; defaults to memory,
+	// which needs the callback to reach the server that started the flow.
+	Logins Logins
+	// Secret signs tokens. When empty, a random key is generated and kept
+	// in Dir, so sessions survive restarts.
+	Secret []byte
+	// AccessTTL is the lifetime of access tokens; defaults to 15 minutes.
+	AccessTTL time.Duration
+	// RefreshTTL is the lifetime of refresh tokens; defaults to 30 days.
+	RefreshTTL time.Duration
+	// InsecureCookies drops the Secure attribute from cookies, for
+	// development over plain HTTP.
+	InsecureCookies bool
+
+	// Providers are the OAuth providers users can sign in with.
+	Providers []Provider
+	// BaseURL is the server's public URL, used in OAuth redirect URIs. By
+	// default it is taken from each request.
+	BaseURL string
+	// EncryptionKey encrypts stored provider tokens; by default a key is
+	// derived from Secret.
+	EncryptionKey []byte
+	// Client makes requests to OAuth providers; defaults to a client with
+	// a 30 second timeout.
+	Client *http.Client
+	// Admins are the email addresses of the server's administrators, who
+	// may use the admin API.
+	Admins []string
+}
+
+var (
+	// ErrInvalidInput is returned for malformed email addresses, weak
+	// passwords and overlong names.
+	ErrInvalidInput = errors.New("auth: invalid input")
+	// ErrEmailTaken is returned by Signup for registered addresses.
+	ErrEmailTaken = errors.New("auth: email already registered")
+	// ErrInvalidCredentials is returned by Login for unknown addresses and
+	// wrong passwords alike.
+	ErrInvalidCredentials = errors.New("auth: invalid email or password")
+	// ErrInvalidToken is returned for tokens that are malformed, expired,
+	// revoked or of the wrong type.
+	ErrInvalidToken = errors.New("auth: invalid token")
+	// ErrNotFound is returned for unknown users, linked accounts and
+	// tokens.
+	ErrNotFound = errors.New("auth: not found")
+)
+
+// User is an account.
+type User struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Tokens are issued on signup, login and refresh.
+type Tokens struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	// ExpiresIn is the access token's lifetime in seconds.
+	ExpiresIn int64 `json:"expiresIn"`
+
+	accessExpiry  time.Time
+	refreshExpiry time.Time
+}
+
+// Service manages users and their sessions.
+type Service struct {
+	cfg       Config
+	secret    []byte
+	key       []byte
+	providers map[string]Provider
+	client    *http.Client
+	now       func() time.Time
+
+	records Records
+	logins  Logins
+
+	mu sync.Mutex
+	// used is when each token's last use was recorded.
+	used map[string]time.Time
+}
+
+// NewService returns a Service, filling unset Config fields with defaults
+// and loading stored users.
+func NewService(cfg Config) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-auth")
+	}
+	if cfg.AccessTTL <= 0 {
+		cfg.AccessTTL = 15 * time.Minute
+	}
+	if cfg.RefreshTTL <= 0 {
+		cfg.RefreshTTL = 30 * 24 * time.Hour
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("auth: create dir: %w", err)
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	s := &Service{
+		cfg:       cfg,
+		secret:    cfg.Secret,
+		providers: make(map[string]Provider),
+		client:    cfg.Client,
+		now:       time.Now,
+		records:   cfg.Records,
+		logins:    cfg.Logins,
+		used:      make(map[string]time.Time),
+	}
+	for _, p := range cfg.Providers {
+		if p.Name != ProviderGitHub && p.Name != ProviderGoogle {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, p.Name)
+		}
+		if p.ClientID == "" || p.ClientSecret == "" {
+			return nil, fmt.Errorf("auth: provider %s: client ID and secret are required", p.Name)
+		}
+		s.providers[p.Name] = p
+	}
+	if len(s.secret) == 0 {
+		secret, err := loadSecret(filepath.Join(cfg.Dir, "secret"))
+		if err != nil {
+			return nil, err
+		}
+		s.secret = secret
+	}
+	s.key = deriveKey(s.secret, "webide provider tokens")
+	if len(cfg.EncryptionKey) > 0 {
+		s.key = deriveKey(cfg.EncryptionKey, "webide provider tokens")
+	}
+	if s.records == nil {
+		records, err := newFileRecords(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		s.records = records
+	}
+	if s.logins == nil {
+		s.logins = &memLogins{pending: make(map[string]PendingLogin)}
+	}
+	return s, nil
+}
+
+// loadSecret reads the signing key at p, generating it on first use.
+func loadSecret(p string) ([]byte, error) {
+	data, err := os.ReadFile(p)
+	if err == nil && len(data) >= 32 {
+		return data, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("auth: read secret: %w", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p, secret, 0o600); err != nil {
+		return nil, fmt.Errorf("auth: write secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Signup registers a user and signs them in.
+func (s *Service) Signup(email, password, name string) (*User, *Tokens, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case len(password) < 8:
+		return nil, nil, fmt.Errorf("%w: password must have at least 8 characters", ErrInvalidInput)
+	case len(password) > 256:
+		return nil, nil, fmt.Errorf("%w: password too long", ErrInvalidInput)
+	case len(name) > 100:
+		return nil, nil, fmt.Errorf("%w: name too long", ErrInvalidInput)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := &UserRecord{
+		User:         User{ID: newID("u-"), Email: email, Name: strings.TrimSpace(name), CreatedAt: s.now().UTC()},
+		PasswordHash: hash,
+	}
+	if err := s.records.CreateUser(u); err != nil {
+		return nil, nil, err
+	}
+	tokens, err := s.issue(u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := u.User
+	return &out, tokens, nil
+}
+
+// dummyHash is checked against when a login names an unknown address, so
+// the response time does not reveal which addresses are registered.
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := hashPassword("webide-dummy-password")
+	return h
+})
+
+// Login checks a user's password and signs them in.
+func (s *Service) Login(email, password string) (*User, *Tokens, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+	u, err := s.records.UserByEmail(email)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, nil, err
+	}
+	if u == nil || u.PasswordHash == "" {
+		checkPassword(dummyHash(), password)
+		return nil, nil, ErrInvalidCredentials
+	}
+	if !checkPassword(u.PasswordHash, password) {
+		return nil, nil, ErrInvalidCredentials
+	}
+	tokens, err := s.issue(u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := u.User
+	return &out, tokens, nil
+}
+
+// Refresh exchanges a refresh token for new tokens. Each refresh token
+// works once: the session it belongs to is replaced by a new one.
+func (s *Service) Refresh(token string) (*User, *Tokens, error) {
+	c, err := parseToken(s.secret, token, refreshToken, s.now())
+	if err != nil {
+		return nil, nil, err
+	}
+	sess, err := s.records.TakeSession(c.ID)
+	if errors.Is(err, ErrNotFound) || err == nil && sess.UserID != c.Subject {
+		return nil, nil, fmt.Errorf("%w: session ended", ErrInvalidToken)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := s.records.User(c.Subject)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil, fmt.Errorf("%w: session ended", ErrInvalidToken)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := s.issue(u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := u.User
+	return &out, tokens, nil
+}
+
+// Logout ends the session of a refresh token. Access tokens issued for it
+// stay valid until they expire.
+func (s *Service) Logout(token string) error {
+	c, err := parseToken(s.secret, token, refreshToken, s.now())
+	if err != nil {
+		return err
+	}
+	if _, err := s.records.TakeSession(c.ID); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Authenticate returns the user an access token or personal access token
+// was issued to.
+func (s *Service) Authenticate(token string) (*User, error) {
+	u, _, err := s.authenticate(token)
+	return u, err
+}
+
+// authenticate is Authenticate that also returns the personal access
+// token used, or nil for access tokens.
+func (s *Service) authenticate(token string) (*User, *APIToken, error) {
+	if strings.HasPrefix(token, patPrefix) {
+		return s.authenticateAPIToken(token)
+	}
+	c, err := parseToken(s.secret, token, accessToken, s.now())
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := s.User(c.Subject)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil, fmt.Errorf("%w: user no longer exists", ErrInvalidToken)
+	}
+	return u, nil, err
+}
+
+// IsAdmin reports whether u is an administrator of the server.
+func (s *Service) IsAdmin(u *User) bool {
+	return u != nil && slices.ContainsFunc(s.cfg.Admins, func(email string) bool {
+		return strings.EqualFold(email, u.Email)
+	})
+}
+
+// User returns the user with the given ID.
+func (s *Service) User(id string) (*User, error) {
+	u, err := s.records.User(id)
+	if err != nil {
+		return nil, err
+	}
+	return &u.User, nil
+}
+
+// UserByEmail returns the user registered with an email address.
+func (s *Service) UserByEmail(email string) (*User, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.records.UserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	return &u.User, nil
+}
+
+// issue creates a session for userID and returns its tokens.
+func (s *Service) issue(userID string) (*Tokens, error) {
+	now := s.now()
+	t := &Tokens{
+		TokenType:     "Bearer",
+		ExpiresIn:     int64(s.cfg.AccessTTL / time.Second),
+		accessExpiry:  now.Add(s.cfg.AccessTTL),
+		refreshExpiry: now.Add(s.cfg.RefreshTTL),
+	}
+	var err error
+	t.AccessToken, err = signToken(s.secret, claims{Subject: userID, Type: accessToken, IssuedAt: now.Unix(), ExpiresAt: t.accessExpiry.Unix()})
+	if err != nil {
+		return nil, err
+	}
+	jti := newID("")
+	t.RefreshToken, err = signToken(s.secret, claims{Subject: userID, Type: refreshToken, IssuedAt: now.Unix(), ExpiresAt: t.refreshExpiry.Unix(), ID: jti})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.records.CreateSession(jti, Session{UserID: userID, ExpiresAt: t.refreshExpiry.UTC()}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" || len(addr.Address) > 254 {
+		return "", fmt.Errorf("%w: bad email address", ErrInvalidInput)
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+func newID(prefix string) string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return prefix + hex.EncodeToString(b[:])
+}
+
+// readJSON decodes the file at p into v; a missing file leaves v as is.
+func readJSON(p string, v any) error {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("auth: read %s: %w", filepath.Base(p), err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("auth: read %s: %w", filepath.Base(p), err)
+	}
+	return nil
+}
+
+// writeJSON atomically replaces the file at p with v.
+func writeJSON(p string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".auth-*")
+	if err != nil {
+		return fmt.Errorf("auth: write %s: %w", filepath.Base(p), err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("auth: write %s: %w", filepath.Base(p), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("auth: write %s: %w", filepath.Base(p), err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("auth: write %s: %w", filepath.Base(p), err)
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Handler serves signup, login and session routes.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts the auth routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/auth/signup", h.signup)
+	mux.HandleFunc("POST /api/auth/login", h.login)
+	mux.HandleFunc("POST /api/auth/refresh", h.refresh)
+	mux.HandleFunc("POST /api/auth/logout", h.logout)
+	mux.HandleFunc("GET /api/auth/me", h.me)
+	mux.HandleFunc("GET /api/auth/providers", h.providers)
+	mux.HandleFunc("GET /api/auth/oauth/{provider}", h.oauthStart)
+	mux.HandleFunc("GET /api/auth/oauth/{provider}/callback", h.oauthCallback)
+	mux.HandleFunc("GET /api/auth/identities", h.identities)
+	mux.HandleFunc("DELETE /api/auth/identities/{provider}", h.unlink)
+	mux.HandleFunc("GET /api/auth/tokens", h.listTokens)
+	mux.HandleFunc("POST /api/auth/tokens", h.createToken)
+	mux.HandleFunc("DELETE /api/auth/tokens/{id}", h.revokeToken)
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name,omitempty"`
+	// Cookie asks for the tokens as HttpOnly cookies instead of in the
+	// response body.
+	Cookie bool `json:"cookie,omitempty"`
+}
+
+type sessionResponse struct {
+	User *User `json:"user"`
+	*Tokens
+}
+
+func (h *Handler) signup(w http.ResponseWriter, r *http.Request) {
+	var req credentials
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	u, tokens, err := h.svc.Signup(req.Email, req.Password, req.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeSession(w, http.StatusCreated, u, tokens, req.Cookie)
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	var req credentials
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	u, tokens, err := h.svc.Login(req.Email, req.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeSession(w, http.StatusOK, u, tokens, req.Cookie)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// refresh rotates a session. The refresh token comes from the body or,
+// in cookie mode, from the refresh cookie, in which case the new tokens
+// are set as cookies too.
+func (h *Handler) refresh(w http.ResponseWriter, r *http.Request) {
+	token, fromCookie, ok := h.refreshToken(w, r)
+	if !ok {
+		return
+	}
+	u, tokens, err := h.svc.Refresh(token)
+	if err != nil {
+		if fromCookie {
+			h.clearCookies(w)
+		}
+		writeError(w, err)
+		return
+	}
+	h.writeSession(w, http.StatusOK, u, tokens, fromCookie)
+}
+
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+	token, fromCookie, ok := h.refreshToken(w, r)
+	if !ok {
+		return
+	}
+	if fromCookie {
+		h.clearCookies(w)
+	}
+	if err := h.svc.Logout(token); err != nil && !errors.Is(err, ErrInvalidToken) {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) me(w http.ResponseWriter, r *http.Request) {
+	u, _, ok := h.svc.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, struct {
+		*User
+		Admin bool `json:"admin,omitempty"`
+	}{u, h.svc.IsAdmin(u)})
+}
+
+// session authenticates a request that manages the account, which
+// personal access tokens may not do.
+func (h *Handler) session(w http.ResponseWriter, r *http.Request) (*User, bool) {
+	u, tok, ok := h.svc.authenticateRequest(w, r)
+	if !ok {
+		return nil, false
+	}
+	if tok != nil {
+		httpx.Error(w, http.StatusForbidden, "personal access tokens cannot manage the account")
+		return nil, false
+	}
+	return u, true
+}
+
+func (h *Handler) listTokens(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	tokens, err := h.svc.Tokens(u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"tokens": tokens})
+}
+
+type createTokenRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	// ExpiresInDays bounds the token's lifetime; without it the token
+	// does not expire.
+	ExpiresInDays int `json:"expiresInDays,omitempty"`
+}
+
+// createToken issues a personal access token. The response is the only
+// time the token itself is shown.
+func (h *Handler) createToken(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	var req createTokenRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > 3650 {
+		httpx.Error(w, http.StatusBadRequest, "expiresInDays must be between 0 and 3650")
+		return
+	}
+	tok, secret, err := h.svc.CreateToken(u.ID, req.Name, req.Scope, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, map[string]any{"token": secret, "info": tok})
+}
+
+func (h *Handler) revokeToken(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.RevokeToken(u.ID, r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) providers(w http.ResponseWriter, r *http.Request) {
+	httpx.JSON(w, http.StatusOK, map[string]any{"providers": h.svc.Providers()})
+}
+
+// stateCookie binds an OAuth flow to the browser that started it, so a
+// callback cannot be replayed into someone else's browser.
+const stateCookie = "webide_oauth_state"
+
+// oauthStart sends the browser to the provider. With link=1 the signed-in
+// user links the provider account instead of signing in; redirect is the
+// local path the browser returns to.
+func (h *Handler) oauthStart(w http.ResponseWriter, r *http.Request) {
+	var linkUser string
+	if r.URL.Query().Get("link") == "1" {
+		u, ok := h.session(w, r)
+		if !ok {
+			return
+		}
+		linkUser = u.ID
+	}
+	provider := r.PathValue("provider")
+	authURL, state, err := h.svc.AuthCodeURL(provider, h.redirectURI(r, provider), localRedirect(r.URL.Query().Get("redirect")), linkUser)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.setCookie(w, stateCookie, state, "/api/auth/oauth", time.Now().Add(stateTTL), true)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oauthCallback completes the flow, sets the session cookies and sends
+// the browser back to where it started.
+func (h *Handler) oauthCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		msg := "sign-in cancelled at provider: " + e
+		if d := q.Get("error_description"); d != "" {
+			msg += ": " + d
+		}
+		httpx.Error(w, http.StatusBadRequest, msg)
+		return
+	}
+	state := q.Get("state")
+	c, err := r.Cookie(stateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(state)) != 1 {
+		writeError(w, ErrInvalidState)
+		return
+	}
+	h.setCookie(w, stateCookie, "", "/api/auth/oauth", time.Time{}, true)
+	provider := r.PathValue("provider")
+	_, tokens, redirect, err := h.svc.Exchange(r.Context(), provider, state, q.Get("code"), h.redirectURI(r, provider))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.setSessionCookies(w, tokens)
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (h *Handler) identities(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	ids, err := h.svc.Identities(u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"identities": ids})
+}
+
+func (h *Handler) unlink(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Unlink(u.ID, r.PathValue("provider")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// redirectURI is the callback URL registered with provider.
+func (h *Handler) redirectURI(r *http.Request, provider string) string {
+	base := h.svc.cfg.BaseURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/api/auth/oauth/" + url.PathEscape(provider) + "/callback"
+}
+
+// localRedirect returns p if it is a path on this server, or "/", so the
+// flow cannot be used to send users elsewhere.
+func localRedirect(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return "/"
+	}
+	return p
+}
+
+// refreshToken reads the refresh token of a refresh or logout request,
+// writing an error response when there is none.
+func (h *Handler) refreshToken(w http.ResponseWriter, r *http.Request) (token string, fromCookie, ok bool) {
+	var req refreshRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return "", false, false
+		}
+	}
+	if req.RefreshToken != "" {
+		return req.RefreshToken, false, true
+	}
+	c, err := r.Cookie(RefreshCookie)
+	if err != nil || c.Value == "" {
+		httpx.Error(w, http.StatusBadRequest, "refreshToken is required")
+		return "", false, false
+	}
+	// The refresh cookie is scoped to /api/auth but still sent on
+	// cross-site form posts, so cookie requests must prove same origin.
+	if !validCSRF(r) {
+		httpx.Error(w, http.StatusForbidden, "missing or invalid CSRF token")
+		return "", false, false
+	}
+	return c.Value, true, true
+}
+
+func (h *Handler) writeSession(w http.ResponseWriter, status int, u *User, tokens *Tokens, cookie bool) {
+	if !cookie {
+		httpx.JSON(w, status, sessionResponse{User: u, Tokens: tokens})
+		return
+	}
+	h.setSessionCookies(w, tokens)
+	httpx.JSON(w, status, map[string]any{"user": u, "expiresIn": tokens.ExpiresIn})
+}
+
+func (h *Handler) setSessionCookies(w http.ResponseWriter, tokens *Tokens) {
+	h.setCookie(w, AccessCookie, tokens.AccessToken, "/", tokens.accessExpiry, true)
+	h.setCookie(w, RefreshCookie, tokens.RefreshToken, "/api/auth", tokens.refreshExpiry, true)
+	// Scripts read the CSRF cookie to echo it in CSRFHeader.
+	h.setCookie(w, CSRFCookie, newID(""), "/", tokens.refreshExpiry, false)
+}
+
+func (h *Handler) clearCookies(w http.ResponseWriter) {
+	h.setCookie(w, AccessCookie, "", "/", time.Time{}, true)
+	h.setCookie(w, RefreshCookie, "", "/api/auth", time.Time{}, true)
+	h.setCookie(w, CSRFCookie, "", "/", time.Time{}, false)
+}
+
+// setCookie sets a cookie, or deletes it when expires is zero.
+func (h *Handler) setCookie(w http.ResponseWriter, name, value, path string, expires time.Time, httpOnly bool) {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Expires:  expires,
+		HttpOnly: httpOnly,
+		Secure:   !h.svc.cfg.InsecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if expires.IsZero() {
+		c.MaxAge = -1
+	}
+	http.SetCookie(w, c)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidInput):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrEmailTaken):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrInvalidCredentials):
+		httpx.Error(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, ErrInvalidToken):
+		unauthorized(w, err.Error())
+	case errors.Is(err, ErrUnknownProvider), errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidState):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrIdentityTaken), errors.Is(err, ErrLastSignIn):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrProvider):
+		httpx.Error(w, http.StatusBadGateway, err.Error())
+	default:
+		slog.Error("auth", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "authentication failed")
+	}
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Cookie names used in cookie mode.
+const (
+	AccessCookie  = "webide_access"
+	RefreshCookie = "webide_refresh"
+	CSRFCookie    = "webide_csrf"
+	// CSRFHeader must echo CSRFCookie on unsafe requests authenticated by
+	// cookie.
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// Roles reports a user's role on a workspace, "" for none.
+type Roles interface {
+	Role(workspaceID, userID string) (workspace.Role, error)
+}
+
+type (
+	userKey  struct{}
+	tokenKey struct{}
+)
+
+// UserFrom returns the user the middleware authenticated, or nil.
+func UserFrom(ctx context.Context) *User {
+	u, _ := ctx.Value(userKey{}).(*User)
+	return u
+}
+
+// WithUser returns a context carrying u as UserFrom reports it, for work
+// done for a user outside of an HTTP request, such as an SSH session.
+func WithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// TokenFrom returns the personal access token the request was
+// authenticated with, or nil.
+func TokenFrom(ctx context.Context) *APIToken {
+	t, _ := ctx.Value(tokenKey{}).(*APIToken)
+	return t
+}
+
+// WithToken returns a context carrying t as TokenFrom reports it.
+func WithToken(ctx context.Context, t *APIToken) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+// Middleware requires an access token on every API, WebSocket and preview
+// route except the public ones. Workspace routes answer 404 to users
+// without a role on the workspace and 403 to viewers for anything but
+// reading. Authenticated requests carry the user (see UserFrom), the
+// personal access token if one was used (see TokenFrom) and their role
+// (see workspace.WithRole), and create workspaces in their name (see
+// workspace.WithOwner).
+//
+// The token is taken from an Authorization: Bearer header, the access
+// cookie or, on GET requests, an access_token query parameter, which is
+// how WebSockets and preview iframes authenticate when cookies are not in
+// use. Personal access tokens are accepted wherever access tokens are and
+// limited to the requests their scope allows.
+func Middleware(s *Service, roles Roles, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !protected(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u, tok, ok := s.authenticateRequest(w, r)
+		if !ok {
+			return
+		}
+		if tok != nil && !scopeAllows(tok.Scope, r) {
+			httpx.Errorf(w, http.StatusForbidden, "token scope %q does not allow this request", tok.Scope)
+			return
+		}
+		ctx := WithUser(r.Context(), u)
+		if tok != nil {
+			ctx = WithToken(ctx, tok)
+		}
+		ctx = workspace.WithOwner(ctx, u.ID)
+		if id := workspaceID(r); id != "" && workspace.ValidID(id) {
+			role, err := roles.Role(id, u.ID)
+			if err != nil {
+				slog.Error("workspace role", "id", id, "err", err)
+				httpx.Error(w, http.StatusInternalServerError, "could not check workspace access")
+				return
+			}
+			if role == "" {
+				httpx.Error(w, http.StatusNotFound, "workspace not found")
+				return
+			}
+			if role == workspace.RoleViewer && !viewerAllows(r, u.ID) {
+				httpx.Error(w, http.StatusForbidden, "viewers have read-only access")
+				return
+			}
+			ctx = workspace.WithRole(ctx, role)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateRequest returns the user of r's access token, and the
+// personal access token if one was used, writing an error response when
+// there is none.
+func (s *Service) authenticateRequest(w http.ResponseWriter, r *http.Request) (*User, *APIToken, bool) {
+	token, fromCookie := accessTokenFrom(r)
+	if token == "" {
+		unauthorized(w, "authentication required")
+		return nil, nil, false
+	}
+	if fromCookie && !safeMethod(r.Method) && !validCSRF(r) {
+		httpx.Error(w, http.StatusForbidden, "missing or invalid CSRF token")
+		return nil, nil, false
+	}
+	u, tok, err := s.authenticate(token)
+	switch {
+	case errors.Is(err, ErrInvalidToken):
+		unauthorized(w, err.Error())
+		return nil, nil, false
+	case err != nil:
+		slog.Error("authenticate", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not authenticate")
+		return nil, nil, false
+	}
+	return u, tok, true
+}
+
+// protected reports whether r needs an access token. The auth routes,
+// embeds, shared snippets, share link lookups, the read-only catalogues,
+// the API's OpenAPI definition and versions, and artifact downloads
+// through signed URLs are public.
+func protected(r *http.Request) bool {
+	p := r.URL.Path
+	switch {
+	case strings.HasPrefix(p, "/api/auth/"), strings.HasPrefix(p, "/embed/"):
+		return false
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		switch {
+		case p == "/api/toolchains", p == "/api/templates", p == "/api/openapi.json",
+			p == "/api/versions", p == "/api/examples", strings.HasPrefix(p, "/api/examples/"):
+			return false
+		case strings.HasPrefix(p, "/api/snippets/") && strings.Count(p, "/") == 3,
+			strings.HasPrefix(p, "/api/share/") && strings.Count(p, "/") == 3:
+			return false
+		case strings.HasPrefix(p, "/api/artifacts/") && strings.HasSuffix(p, "/download") && r.URL.Query().Has("sig"):
+			// The artifact handler verifies the signature instead.
+			return false
+		}
+	}
+	return strings.HasPrefix(p, "/api/") || strings.HasPrefix(p, "/ws/") || strings.HasPrefix(p, "/preview/")
+}
+
+// viewerAllows reports whether a viewer may make request r: reading,
+// following collaborative sessions, whose socket rejects their edits,
+// reviewing code, forking the workspace into one of their own, and
+// leaving it.
+func viewerAllows(r *http.Request, userID string) bool {
+	if readRequest(r) {
+		return true
+	}
+	seg := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(seg) >= 5 && seg[0] == "ws" && seg[1] == "workspaces" && seg[3] == "collab":
+		return true
+	case len(seg) >= 4 && seg[0] == "api" && seg[1] == "workspaces" && seg[3] == "reviews":
+		return true
+	case r.Method == http.MethodPost && len(seg) == 4 && seg[0] == "api" && seg[1] == "workspaces" && seg[3] == "fork":
+		return true
+	case r.Method == http.MethodDelete && len(seg) == 5 && seg[0] == "api" && seg[1] == "workspaces" && seg[3] == "members" && seg[4] == userID:
+		return true
+	}
+	return false
+}
+
+// workspaceID returns the workspace a request addresses, or "".
+func workspaceID(r *http.Request) string {
+	seg := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(seg) >= 4 && seg[0] == "api" && seg[1] == "workspaces":
+		return seg[2]
+	case len(seg) >= 4 && seg[0] == "ws" && seg[1] == "workspaces":
+		return seg[2]
+	case len(seg) == 3 && seg[0] == "ws" && seg[1] == "lsp":
+		return r.URL.Query().Get("workspace")
+	case len(seg) == 3 && seg[0] == "ws":
+		switch seg[1] {
+		case "ai", "debug", "lint", "repl", "terminal", "preview":
+			return seg[2]
+		}
+	case len(seg) >= 2 && seg[0] == "preview":
+		return seg[1]
+	}
+	return ""
+}
+
+func accessTokenFrom(r *http.Request) (token string, fromCookie bool) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), false
+		}
+		return "", false
+	}
+	if c, err := r.Cookie(AccessCookie); err == nil && c.Value != "" {
+		return c.Value, true
+	}
+	if r.Method == http.MethodGet {
+		return r.URL.Query().Get("access_token"), false
+	}
+	return "", false
+}
+
+func validCSRF(r *http.Request) bool {
+	c, err := r.Cookie(CSRFCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.Header.Get(CSRFHeader))) == 1
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="webide"`)
+	httpx.Error(w, http.StatusUnauthorized, msg)
+}
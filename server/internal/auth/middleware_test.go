@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestViewerAllows(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/api/workspaces/ws1/files/main.go", true},
+		{"PUT", "/api/workspaces/ws1/files/main.go", false},
+		{"DELETE", "/api/workspaces/ws1/files/main.go", false},
+		{"GET", "/ws/workspaces/ws1/collab/main.go", true},
+		{"GET", "/ws/terminal/ws1", false},
+		// Viewers review.
+		{"GET", "/api/workspaces/ws1/reviews", true},
+		{"POST", "/api/workspaces/ws1/reviews", true},
+		{"PATCH", "/api/workspaces/ws1/reviews/t1", true},
+		{"DELETE", "/api/workspaces/ws1/reviews/t1", true},
+		{"POST", "/api/workspaces/ws1/reviews/t1/comments", true},
+		{"PATCH", "/api/workspaces/ws1/reviews/t1/comments/c1", true},
+		{"DELETE", "/api/workspaces/ws1/reviews/t1/comments/c1", true},
+		{"POST", "/api/workspaces/ws1/reviewsx", false},
+		{"POST", "/api/workspaces/ws1/files/reviews", false},
+		{"POST", "/api/workspaces/ws1/fork", true},
+		{"DELETE", "/api/workspaces/ws1/members/u1", true},
+		{"DELETE", "/api/workspaces/ws1/members/u2", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := viewerAllows(r, "u1"); got != tt.want {
+			t.Errorf("viewerAllows(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownProvider is returned for providers that are not configured.
+	ErrUnknownProvider = errors.New("auth: unknown provider")
+	// ErrInvalidState is returned for OAuth callbacks whose state is
+	// unknown, expired or not bound to the browser.
+	ErrInvalidState = errors.New("auth: invalid oauth state")
+	// ErrProvider is returned when a provider rejects a code or token, or
+	// answers unexpectedly.
+	ErrProvider = errors.New("auth: provider error")
+	// ErrIdentityTaken is returned when linking an account that is
+	// already linked to another user.
+	ErrIdentityTaken = errors.New("auth: account linked to another user")
+	// ErrLastSignIn is returned when unlinking the only way a user without
+	// a password can sign in.
+	ErrLastSignIn = errors.New("auth: cannot unlink the last sign-in method")
+)
+
+// Provider names.
+const (
+	ProviderGitHub = "github"
+	ProviderGoogle = "google"
+)
+
+// Provider is an OAuth2 provider users can sign in with. Name selects how
+// profiles are read and must be ProviderGitHub or ProviderGoogle.
+type Provider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	// ProfileURL returns the signed-in account: GitHub's /user endpoint
+	// or Google's OpenID Connect userinfo endpoint.
+	ProfileURL string
+	Scopes     []string
+}
+
+// GitHubProvider returns the GitHub provider. Its scopes cover cloning
+// and pushing private repositories and creating gists, so the Git and
+// gist integrations can act for the user.
+func GitHubProvider(clientID, clientSecret string) Provider {
+	return Provider{
+		Name:         ProviderGitHub,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		ProfileURL:   "https://api.github.com/user",
+		Scopes:       []string{"read:user", "user:email", "repo", "gist"},
+	}
+}
+
+// GoogleProvider returns the Google provider.
+func GoogleProvider(clientID, clientSecret string) Provider {
+	return Provider{
+		Name:         ProviderGoogle,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		ProfileURL:   "https://openidconnect.googleapis.com/v1/userinfo",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// Identity is a provider account linked to a user.
+type Identity struct {
+	Provider string `json:"provider"`
+	// Subject is the account's ID at the provider.
+	Subject  string    `json:"subject"`
+	Login    string    `json:"login,omitempty"`
+	Email    string    `json:"email,omitempty"`
+	Scopes   []string  `json:"scopes,omitempty"`
+	LinkedAt time.Time `json:"linkedAt"`
+}
+
+// IdentityRecord is an identity as stored, with its provider tokens
+// encrypted.
+type IdentityRecord struct {
+	Identity
+	AccessToken  string    `json:"accessToken,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// PendingLogin is an OAuth flow waiting for its callback.
+type PendingLogin struct {
+	Provider string    `json:"provider"`
+	Redirect string    `json:"redirect,omitempty"`
+	LinkUser string    `json:"linkUser,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Logins holds OAuth flows by state between the redirect to the provider
+// and the callback.
+type Logins interface {
+	// PutLogin stores a flow until it expires.
+	PutLogin(state string, l PendingLogin) error
+	// TakeLogin deletes a flow and returns it, or returns ErrNotFound if
+	// there is none, so each state works once.
+	TakeLogin(state string) (PendingLogin, error)
+}
+
+// memLogins keeps flows in memory.
+type memLogins struct {
+	mu      sync.Mutex
+	pending map[string]PendingLogin
+}
+
+func (m *memLogins) PutLogin(state string, l PendingLogin) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for k, v := range m.pending {
+		if now.After(v.Expires) {
+			delete(m.pending, k)
+		}
+	}
+	m.pending[state] = l
+	return nil
+}
+
+func (m *memLogins) TakeLogin(state string) (PendingLogin, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.pending[state]
+	if !ok {
+		return l, ErrNotFound
+	}
+	delete(m.pending, state)
+	return l, nil
+}
+
+// stateTTL bounds how long a user may take at the provider.
+const stateTTL = 10 * time.Minute
+
+// profile is what a provider tells about the signed-in account.
+type profile struct {
+	subject       string
+	login         string
+	name          string
+	email         string
+	emailVerified bool
+}
+
+// providerToken is a token endpoint response.
+type providerToken struct {
+	AccessToken      string      `json:"access_token"`
+	RefreshToken     string      `json:"refresh_token"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	Scope            string      `json:"scope"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// Providers returns the names of the configured providers.
+func (s *Service) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// AuthCodeURL starts an OAuth flow with provider and returns the URL to
+// send the browser to and the flow's state. After the callback the browser
+// is sent to redirect. When linkUser is set, the provider account is
+// linked to that user instead of signing in.
+func (s *Service) AuthCodeURL(provider, redirectURI, redirect, linkUser string) (authURL, state string, err error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", fmt.Errorf("%w: %q", ErrUnknownProvider, provider)
+	}
+	state = newID("")
+	if err := s.logins.PutLogin(state, PendingLogin{Provider: provider, Redirect: redirect, LinkUser: linkUser, Expires: s.now().Add(stateTTL)}); err != nil {
+		return "", "", err
+	}
+
+	q := url.Values{
+		"client_id":     {p.ClientID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {strings.Join(p.Scopes, " ")},
+		"state":         {state},
+	}
+	if p.Name == ProviderGoogle {
+		// Ask for a refresh token so the stored token outlives the hour
+		// Google access tokens last.
+		q.Set("access_type", "offline")
+		q.Set("prompt", "consent")
+	}
+	return p.AuthURL + "?" + q.Encode(), state, nil
+}
+
+// Exchange completes an OAuth flow: it trades code for the provider's
+// tokens, stores them with the linked identity and signs the user in. A
+// provider account signs in to the user it is linked to; a new one is
+// linked to the user that started the flow with linkUser, or else to the
+// user with the same verified email address, or else to a new user.
+func (s *Service) Exchange(ctx context.Context, provider, state, code, redirectURI string) (u *User, tokens *Tokens, redirect string, err error) {
+	pending, err := s.logins.TakeLogin(state)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, nil, "", ErrInvalidState
+	case err != nil:
+		return nil, nil, "", err
+	case pending.Provider != provider || s.now().After(pending.Expires):
+		return nil, nil, "", ErrInvalidState
+	}
+	p := s.providers[provider]
+	tok, err := s.tokenRequest(ctx, p, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	prof, err := s.fetchProfile(ctx, p, tok.AccessToken)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	rec, err := s.sealIdentity(p, prof, tok)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	user, err := s.linkIdentity(pending.LinkUser, prof, rec)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	tokens, err = s.issue(user.ID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	out := user.User
+	return &out, tokens, pending.Redirect, nil
+}
+
+// linkIdentity attaches rec to the user it belongs to, creating the user
+// if needed.
+func (s *Service) linkIdentity(linkUser string, prof *profile, rec IdentityRecord) (*UserRecord, error) {
+	owner, err := s.records.UserByIdentity(rec.Provider, rec.Subject)
+	switch {
+	case err != nil && !errors.Is(err, ErrNotFound):
+		return nil, err
+	case owner != nil && linkUser != "" && owner.ID != linkUser:
+		return nil, ErrIdentityTaken
+	case owner != nil:
+		for _, i := range owner.Identities {
+			if i.Provider == rec.Provider {
+				rec.LinkedAt = i.LinkedAt
+			}
+		}
+		if err := s.records.PutIdentity(owner.ID, rec); err != nil {
+			return nil, err
+		}
+		return owner, nil
+	}
+
+	var u *UserRecord
+	switch {
+	case linkUser != "":
+		if u, err = s.records.User(linkUser); err != nil {
+			return nil, err
+		}
+	case prof.email != "" && prof.emailVerified:
+		u, err = s.records.UserByEmail(strings.ToLower(prof.email))
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	if u == nil {
+		if prof.email == "" || !prof.emailVerified {
+			return nil, fmt.Errorf("%w: %s reported no verified email address", ErrProvider, rec.Provider)
+		}
+		email, err := normalizeEmail(prof.email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s reported a bad email address", ErrProvider, rec.Provider)
+		}
+		u = &UserRecord{User: User{ID: newID("u-"), Email: email, Name: prof.name, CreatedAt: s.now().UTC()}}
+		if err := s.records.CreateUser(u); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.records.PutIdentity(u.ID, rec); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Identities returns the provider accounts linked to a user.
+func (s *Service) Identities(userID string) ([]Identity, error) {
+	u, err := s.records.User(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Identity, 0, len(u.Identities))
+	for _, i := range u.Identities {
+		out = append(out, i.Identity)
+	}
+	return out, nil
+}
+
+// Unlink removes a user's provider account and its stored tokens.
+func (s *Service) Unlink(userID, provider string) error {
+	u, err := s.records.User(userID)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(u.Identities, func(i IdentityRecord) bool { return i.Provider == provider }) {
+		return fmt.Errorf("%w: %s is not linked", ErrNotFound, provider)
+	}
+	if u.PasswordHash == "" && len(u.Identities) == 1 {
+		return ErrLastSignIn
+	}
+	return s.records.DeleteIdentity(userID, provider)
+}
+
+// ProviderToken returns the access token of the signed-in user's account
+// at provider, refreshing it if it has expired. It returns "" without an
+// error when the request is anonymous or the user has not linked one.
+func (s *Service) ProviderToken(ctx context.Context, provider string) (string, error) {
+	cur := UserFrom(ctx)
+	if cur == nil {
+		return "", nil
+	}
+	u, err := s.records.User(cur.ID)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var rec IdentityRecord
+	found := false
+	for _, i := range u.Identities {
+		if i.Provider == provider {
+			rec, found = i, true
+		}
+	}
+	if !found || rec.AccessToken == "" {
+		return "", nil
+	}
+	if rec.Expiry.IsZero() || s.now().Add(time.Minute).Before(rec.Expiry) || rec.RefreshToken == "" {
+		return open(s.key, rec.AccessToken)
+	}
+
+	refresh, err := open(s.key, rec.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownProvider, provider)
+	}
+	tok, err := s.tokenRequest(ctx, p, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}})
+	if err != nil {
+		return "", err
+	}
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = refresh
+	}
+	updated, err := s.sealIdentity(p, nil, tok)
+	if err != nil {
+		return "", err
+	}
+	rec.AccessToken, rec.RefreshToken, rec.Expiry = updated.AccessToken, updated.RefreshToken, updated.Expiry
+	if err := s.records.PutIdentity(cur.ID, rec); err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+// sealIdentity returns the stored form of an identity with tok's tokens
+// encrypted. prof may be nil when only the tokens matter.
+func (s *Service) sealIdentity(p Provider, prof *profile, tok *providerToken) (IdentityRecord, error) {
+	rec := IdentityRecord{Identity: Identity{Provider: p.Name, LinkedAt: s.now().UTC()}}
+	if prof != nil {
+		rec.Subject, rec.Login, rec.Email = prof.subject, prof.login, prof.email
+	}
+	rec.Scopes = strings.FieldsFunc(tok.Scope, func(r rune) bool { return r == ' ' || r == ',' })
+	var err error
+	if rec.AccessToken, err = seal(s.key, tok.AccessToken); err != nil {
+		return rec, err
+	}
+	if tok.RefreshToken != "" {
+		if rec.RefreshToken, err = seal(s.key, tok.RefreshToken); err != nil {
+			return rec, err
+		}
+	}
+	if n, err := tok.ExpiresIn.Int64(); err == nil && n > 0 {
+		rec.Expiry = s.now().Add(time.Duration(n) * time.Second).UTC()
+	}
+	return rec, nil
+}
+
+// tokenRequest posts form, with the client credentials, to p's token
+// endpoint.
+func (s *Service) tokenRequest(ctx context.Context, p Provider, form url.Values) (*providerToken, error) {
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var tok providerToken
+	status, err := s.do(req, &tok)
+	if err != nil {
+		return nil, err
+	}
+	if tok.Error != "" {
+		msg := tok.Error
+		if tok.ErrorDescription != "" {
+			msg += ": " + tok.ErrorDescription
+		}
+		return nil, fmt.Errorf("%w: %s: %s", ErrProvider, p.Name, msg)
+	}
+	if status != http.StatusOK || tok.AccessToken == "" {
+		return nil, fmt.Errorf("%w: %s: token endpoint answered %d", ErrProvider, p.Name, status)
+	}
+	return &tok, nil
+}
+
+func (s *Service) fetchProfile(ctx context.Context, p Provider, token string) (*profile, error) {
+	switch p.Name {
+	case ProviderGitHub:
+		var u struct {
+			ID    int64  `json:"id"`
+			Login string `json:"login"`
+			Name  string `json:"name"`
+		}
+		if err := s.getJSON(ctx, p, p.ProfileURL, token, &u); err != nil {
+			return nil, err
+		}
+		// The profile's email is only the public one, and says nothing
+		// about verification.
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := s.getJSON(ctx, p, p.ProfileURL+"/emails", token, &emails); err != nil {
+			return nil, err
+		}
+		prof := &profile{subject: strconv.FormatInt(u.ID, 10), login: u.Login, name: u.Name}
+		for _, e := range emails {
+			if e.Primary {
+				prof.email, prof.emailVerified = e.Email, e.Verified
+			}
+		}
+		return prof, nil
+	case ProviderGoogle:
+		var u struct {
+			Sub           string `json:"sub"`
+			Email         string `json:"email"`
+			EmailVerified bool   `json:"email_verified"`
+			Name          string `json:"name"`
+		}
+		if err := s.getJSON(ctx, p, p.ProfileURL, token, &u); err != nil {
+			return nil, err
+		}
+		return &profile{subject: u.Sub, login: u.Email, name: u.Name, email: u.Email, emailVerified: u.EmailVerified}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, p.Name)
+}
+
+func (s *Service) getJSON(ctx context.Context, p Provider, u, token string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	status, err := s.do(req, v)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%w: %s: %s answered %d", ErrProvider, p.Name, req.URL.Path, status)
+	}
+	return nil
+}
+
+// do sends req and decodes a JSON response into v, returning the status.
+func (s *Service) do(req *http.Request, v any) (int, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrProvider, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrProvider, err)
+	}
+	if resp.StatusCode == http.StatusOK || json.Valid(data) {
+		if err := json.Unmarshal(data, v); err != nil && resp.StatusCode == http.StatusOK {
+			return 0, fmt.Errorf("%w: decode %s: %w", ErrProvider, req.URL.Path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
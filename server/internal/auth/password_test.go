@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// TestPBKDF2Vector checks the PBKDF2-HMAC-SHA256 vectors of RFC 7914,
+// section 11.
+func TestPBKDF2Vector(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iter           int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tt := range tests {
+		enc := base64.RawStdEncoding
+		want, _ := hex.DecodeString(tt.want)
+		encoded := fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", tt.iter, enc.EncodeToString([]byte(tt.salt)), enc.EncodeToString(want))
+		if !checkPassword(encoded, tt.password) {
+			t.Errorf("checkPassword(%q) with the RFC 7914 key = false, want true", tt.password)
+		}
+		if checkPassword(encoded, tt.password+"x") {
+			t.Errorf("checkPassword(%q) with the key of %q = true, want false", tt.password+"x", tt.password)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	encoded, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkPassword(encoded, "correct horse") {
+		t.Error("checkPassword of the hashed password = false")
+	}
+	if checkPassword(encoded, "correct horsE") {
+		t.Error("checkPassword of another password = true")
+	}
+	again, _ := hashPassword("correct horse")
+	if again == encoded {
+		t.Error("two hashes of a password are equal; salts are not random")
+	}
+}
+
+func TestCheckPasswordMalformed(t *testing.T) {
+	key, _ := pbkdf2.Key(sha256.New, "pw", []byte("salt"), 1, 32)
+	enc := base64.RawStdEncoding
+	for _, encoded := range []string{
+		"",
+		"pbkdf2-sha256$1$c2FsdA",
+		"pbkdf2-sha1$1$c2FsdA$" + enc.EncodeToString(key),
+		"pbkdf2-sha256$0$c2FsdA$" + enc.EncodeToString(key),
+		"pbkdf2-sha256$x$c2FsdA$" + enc.EncodeToString(key),
+		"pbkdf2-sha256$1$!!$" + enc.EncodeToString(key),
+		"pbkdf2-sha256$1$c2FsdA$",
+	} {
+		if checkPassword(encoded, "pw") {
+			t.Errorf("checkPassword(%q) = true, want false", encoded)
+		}
+	}
+	if !checkPassword("pbkdf2-sha256$1$c2FsdA$"+enc.EncodeToString(key), "pw") {
+		t.Error("checkPassword of a well-formed hash = false")
+	}
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes of personal access tokens.
+const (
+	// ScopeRead allows reading workspaces, files and results, but nothing
+	// that changes them or runs code.
+	ScopeRead = "read"
+	// ScopeRun adds running, building, testing and linting code.
+	ScopeRun = "run"
+	// ScopeFull allows everything a signed-in user may do, except managing
+	// tokens.
+	ScopeFull = "full"
+)
+
+// patPrefix starts every personal access token, so they are easy to tell
+// from JWTs and to find in leaked logs.
+const patPrefix = "webide_pat_"
+
+// maxTokensPerUser bounds the tokens one user may hold.
+const maxTokensPerUser = 50
+
+// lastUsedInterval is how often a token's last use is written to disk.
+const lastUsedInterval = time.Minute
+
+// APIToken describes a personal access token. The token itself is shown
+// once, when it is created; only its hash is stored.
+type APIToken struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	// Prefix is the start of the token, to tell tokens apart.
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// TokenRecord is a personal access token as stored.
+type TokenRecord struct {
+	APIToken
+	UserID string `json:"userId"`
+	// Hash is the hex SHA-256 of the token. The tokens are random, so a
+	// slow hash would add nothing.
+	Hash string `json:"hash"`
+}
+
+// CreateToken issues a personal access token for a user. ttl bounds its
+// lifetime; zero means it does not expire. It returns the token's
+// description and the token itself.
+func (s *Service) CreateToken(userID, name, scope string, ttl time.Duration) (*APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "" || len(name) > 100:
+		return nil, "", fmt.Errorf("%w: name must have 1 to 100 characters", ErrInvalidInput)
+	case scope != ScopeRead && scope != ScopeRun && scope != ScopeFull:
+		return nil, "", fmt.Errorf("%w: scope must be %s, %s or %s", ErrInvalidInput, ScopeRead, ScopeRun, ScopeFull)
+	case ttl < 0:
+		return nil, "", fmt.Errorf("%w: negative lifetime", ErrInvalidInput)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	secret := patPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	now := s.now().UTC()
+	t := &TokenRecord{
+		APIToken: APIToken{ID: newID("t-"), Name: name, Scope: scope, Prefix: secret[:len(patPrefix)+4], CreatedAt: now},
+		UserID:   userID,
+		Hash:     hashToken(secret),
+	}
+	if ttl > 0 {
+		exp := now.Add(ttl)
+		t.ExpiresAt = &exp
+	}
+	if err := s.records.CreateToken(t, maxTokensPerUser); err != nil {
+		return nil, "", err
+	}
+	out := t.APIToken
+	return &out, secret, nil
+}
+
+// Tokens returns a user's personal access tokens, newest first.
+func (s *Service) Tokens(userID string) ([]APIToken, error) {
+	recs, err := s.records.Tokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := []APIToken{}
+	for _, t := range recs {
+		out = append(out, t.APIToken)
+	}
+	slices.SortFunc(out, func(a, b APIToken) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+// RevokeToken deletes one of a user's personal access tokens.
+func (s *Service) RevokeToken(userID, id string) error {
+	if err := s.records.DeleteToken(userID, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.used, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// authenticateAPIToken returns the user and token of a personal access
+// token and records its use.
+func (s *Service) authenticateAPIToken(secret string) (*User, *APIToken, error) {
+	hash := hashToken(secret)
+	now := s.now().UTC()
+	t, err := s.records.TokenByHash(hash)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil, fmt.Errorf("%w: unknown or revoked token", ErrInvalidToken)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.ExpiresAt != nil && !now.Before(*t.ExpiresAt) {
+		return nil, nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	u, err := s.records.User(t.UserID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil, fmt.Errorf("%w: user no longer exists", ErrInvalidToken)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	t.LastUsedAt = &now
+	s.mu.Lock()
+	touch := now.Sub(s.used[t.ID]) >= lastUsedInterval
+	if touch {
+		s.used[t.ID] = now
+	}
+	s.mu.Unlock()
+	if touch {
+		if err := s.records.TouchToken(t.ID, now); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &u.User, &t.APIToken, nil
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// scopeAllows reports whether a token of scope may make request r.
+func scopeAllows(scope string, r *http.Request) bool {
+	switch scope {
+	case ScopeFull:
+		return true
+	case ScopeRun:
+		if runRequest(r) {
+			return true
+		}
+		return readRequest(r)
+	case ScopeRead:
+		return readRequest(r)
+	}
+	return false
+}
+
+// readRequest reports whether r only reads. WebSockets are GET requests,
+// so only those that only push data to the client count.
+func readRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	seg := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if seg[0] != "ws" {
+		return true
+	}
+	switch {
+	case len(seg) == 4 && seg[1] == "workspaces" && seg[3] == "events":
+		return true
+	case len(seg) == 5 && seg[1] == "workspaces" && seg[3] == "recordings":
+		// Playing a recording back.
+		return true
+	case len(seg) == 5 && seg[1] == "workspaces" && seg[3] == "uploads":
+		// Watching an upload's progress.
+		return true
+	case len(seg) >= 2 && (seg[1] == "preview" || seg[1] == "lsp"):
+		return true
+	}
+	return false
+}
+
+// runRequest reports whether r runs, builds, tests or lints code without
+// changing workspace files.
+func runRequest(r *http.Request) bool {
+	seg := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && (r.URL.Path == "/api/run" || r.URL.Path == "/api/builds"):
+		return true
+	case r.Method == http.MethodGet && (r.URL.Path == "/ws/run" || len(seg) == 3 && seg[0] == "ws" && seg[1] == "lint"):
+		return true
+	case r.Method == http.MethodPost && len(seg) >= 4 && seg[0] == "api" && seg[1] == "workspaces":
+		switch strings.Join(seg[3:], "/") {
+		case "tests", "benchmarks", "lint", "wasm/build":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Records stores users, their refresh sessions and their personal access
+// tokens. Lookups of missing records return ErrNotFound. Records are
+// returned as copies, so callers may change them freely.
+type Records interface {
+	// CreateUser adds u, or returns ErrEmailTaken if its address is
+	// registered.
+	CreateUser(u *UserRecord) error
+	User(id string) (*UserRecord, error)
+	// UserByEmail returns the user registered with a normalized address.
+	UserByEmail(email string) (*UserRecord, error)
+	// UserByIdentity returns the user a provider account is linked to.
+	UserByIdentity(provider, subject string) (*UserRecord, error)
+	// PutIdentity links rec to a user, replacing the identity it has at
+	// the same provider, or returns ErrIdentityTaken if the provider
+	// account is linked to another user.
+	PutIdentity(userID string, rec IdentityRecord) error
+	DeleteIdentity(userID, provider string) error
+
+	// CreateSession stores a refresh session and drops expired ones.
+	CreateSession(id string, sess Session) error
+	// TakeSession deletes a session and returns it, so each refresh token
+	// works once even when several servers share the records.
+	TakeSession(id string) (Session, error)
+
+	// CreateToken stores t, or returns ErrInvalidInput if its user holds
+	// max tokens already.
+	CreateToken(t *TokenRecord, max int) error
+	// Tokens returns a user's tokens in no particular order.
+	Tokens(userID string) ([]TokenRecord, error)
+	TokenByHash(hash string) (*TokenRecord, error)
+	DeleteToken(userID, id string) error
+	// TouchToken records that a token was used at.
+	TouchToken(id string, at time.Time) error
+}
+
+// UserRecord is a user as stored.
+type UserRecord struct {
+	User
+	PasswordHash string           `json:"passwordHash,omitempty"`
+	Identities   []IdentityRecord `json:"identities,omitempty"`
+}
+
+func (u *UserRecord) clone() *UserRecord {
+	out := *u
+	out.Identities = slices.Clone(u.Identities)
+	return &out
+}
+
+// Session is a refresh token that has not been used or revoked.
+type Session struct {
+	UserID    string    `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// fileRecords keeps records in memory and writes each kind to a JSON file
+// in dir whenever it changes, which suits a single server.
+type fileRecords struct {
+	dir string
+
+	mu       sync.Mutex
+	users    map[string]*UserRecord
+	sessions map[string]Session
+	tokens   map[string]*TokenRecord
+}
+
+// newFileRecords loads the records kept in dir.
+func newFileRecords(dir string) (*fileRecords, error) {
+	r := &fileRecords{
+		dir:      dir,
+		users:    make(map[string]*UserRecord),
+		sessions: make(map[string]Session),
+		tokens:   make(map[string]*TokenRecord),
+	}
+	if err := readJSON(filepath.Join(dir, "users.json"), &r.users); err != nil {
+		return nil, err
+	}
+	if err := readJSON(filepath.Join(dir, "sessions.json"), &r.sessions); err != nil {
+		return nil, err
+	}
+	if err := readJSON(filepath.Join(dir, "tokens.json"), &r.tokens); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *fileRecords) saveUsers() error {
+	return writeJSON(filepath.Join(r.dir, "users.json"), r.users)
+}
+
+func (r *fileRecords) saveSessions() error {
+	return writeJSON(filepath.Join(r.dir, "sessions.json"), r.sessions)
+}
+
+func (r *fileRecords) saveTokens() error {
+	return writeJSON(filepath.Join(r.dir, "tokens.json"), r.tokens)
+}
+
+func (r *fileRecords) CreateUser(u *UserRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.users {
+		if other.Email == u.Email {
+			return ErrEmailTaken
+		}
+	}
+	r.users[u.ID] = u.clone()
+	if err := r.saveUsers(); err != nil {
+		delete(r.users, u.ID)
+		return err
+	}
+	return nil
+}
+
+func (r *fileRecords) User(id string) (*UserRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: no user %s", ErrNotFound, id)
+	}
+	return u.clone(), nil
+}
+
+func (r *fileRecords) UserByEmail(email string) (*UserRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == email {
+			return u.clone(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no user with address %s", ErrNotFound, email)
+}
+
+func (r *fileRecords) UserByIdentity(provider, subject string) (*UserRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u := r.userByIdentity(provider, subject); u != nil {
+		return u.clone(), nil
+	}
+	return nil, fmt.Errorf("%w: no user linked to the %s account", ErrNotFound, provider)
+}
+
+// userByIdentity returns the user linked to a provider account. r.mu must
+// be held.
+func (r *fileRecords) userByIdentity(provider, subject string) *UserRecord {
+	for _, u := range r.users {
+		for _, id := range u.Identities {
+			if id.Provider == provider && id.Subject == subject {
+				return u
+			}
+		}
+	}
+	return nil
+}
+
+func (r *fileRecords) PutIdentity(userID string, rec IdentityRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok {
+		return fmt.Errorf("%w: no user %s", ErrNotFound, userID)
+	}
+	if owner := r.userByIdentity(rec.Provider, rec.Subject); owner != nil && owner.ID != userID {
+		return ErrIdentityTaken
+	}
+	prev := u.Identities
+	// One account per provider: linking another replaces it.
+	u.Identities = slices.DeleteFunc(slices.Clone(prev), func(i IdentityRecord) bool { return i.Provider == rec.Provider })
+	u.Identities = append(u.Identities, rec)
+	if err := r.saveUsers(); err != nil {
+		u.Identities = prev
+		return err
+	}
+	return nil
+}
+
+func (r *fileRecords) DeleteIdentity(userID, provider string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok {
+		return fmt.Errorf("%w: no user %s", ErrNotFound, userID)
+	}
+	i := slices.IndexFunc(u.Identities, func(i IdentityRecord) bool { return i.Provider == provider })
+	if i < 0 {
+		return fmt.Errorf("%w: %s is not linked", ErrNotFound, provider)
+	}
+	prev := u.Identities
+	u.Identities = slices.Delete(slices.Clone(prev), i, i+1)
+	if err := r.saveUsers(); err != nil {
+		u.Identities = prev
+		return err
+	}
+	return nil
+}
+
+func (r *fileRecords) CreateSession(id string, sess Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for k, s := range r.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(r.sessions, k)
+		}
+	}
+	r.sessions[id] = sess
+	return r.saveSessions()
+}
+
+func (r *fileRecords) TakeSession(id string) (Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sess, ok := r.sessions[id]
+	if !ok {
+		return Session{}, fmt.Errorf("%w: no session %s", ErrNotFound, id)
+	}
+	delete(r.sessions, id)
+	if err := r.saveSessions(); err != nil {
+		r.sessions[id] = sess
+		return Session{}, err
+	}
+	return sess, nil
+}
+
+func (r *fileRecords) CreateToken(t *TokenRecord, max int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[t.UserID]; !ok {
+		return fmt.Errorf("%w: no user %s", ErrNotFound, t.UserID)
+	}
+	n := 0
+	for _, other := range r.tokens {
+		if other.UserID == t.UserID {
+			n++
+		}
+	}
+	if n >= max {
+		return fmt.Errorf("%w: at most %d tokens per user", ErrInvalidInput, max)
+	}
+	rec := *t
+	r.tokens[t.ID] = &rec
+	if err := r.saveTokens(); err != nil {
+		delete(r.tokens, t.ID)
+		return err
+	}
+	return nil
+}
+
+func (r *fileRecords) Tokens(userID string) ([]TokenRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []TokenRecord
+	for _, t := range r.tokens {
+		if t.UserID == userID {
+			out = append(out, *t)
+		}
+	}
+	return out, nil
+}
+
+func (r *fileRecords) TokenByHash(hash string) (*TokenRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.Hash == hash {
+			out := *t
+			return &out, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown token", ErrNotFound)
+}
+
+func (r *fileRecords) DeleteToken(userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[id]
+	if !ok || t.UserID != userID {
+		return fmt.Errorf("%w: no token %s", ErrNotFound, id)
+	}
+	delete(r.tokens, id)
+	if err := r.saveTokens(); err != nil {
+		r.tokens[id] = t
+		return err
+	}
+	return nil
+}
+
+func (r *fileRecords) TouchToken(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[id]
+	if !ok {
+		return fmt.Errorf("%w: no token %s", ErrNotFound, id)
+	}
+	t.LastUsedAt = &at
+	return r.saveTokens()
+}
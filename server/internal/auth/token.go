@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Token types, carried in the typ claim so a refresh token cannot be used
+// as an access token or the other way around.
+const (
+	accessToken  = "access"
+	refreshToken = "refresh"
+)
+
+// claims are the JWT claims the service issues.
+type claims struct {
+	Subject   string `json:"sub"`
+	Type      string `json:"typ"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	// ID identifies a refresh token's session.
+	ID string `json:"jti,omitempty"`
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// signToken returns c as an HS256 JWT.
+func signToken(secret []byte, c claims) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sign(secret, unsigned)), nil
+}
+
+// parseToken verifies an HS256 JWT of type typ and returns its claims.
+func parseToken(secret []byte, token, typ string, now time.Time) (*claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if json.Unmarshal(header, &h) != nil || h.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm", ErrInvalidToken)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, sign(secret, parts[0]+"."+parts[1])) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	switch {
+	case c.Type != typ:
+		return nil, fmt.Errorf("%w: wrong token type", ErrInvalidToken)
+	case now.Unix() >= c.ExpiresAt:
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	return &c, nil
+}
+
+func sign(secret []byte, s string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
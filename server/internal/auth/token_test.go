@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestParseToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tok, err := signToken(testSecret, claims{Subject: "u1", Type: accessToken, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := parseToken(testSecret, tok, accessToken, now)
+	if err != nil {
+		t.Fatalf("parseToken: %v", err)
+	}
+	if c.Subject != "u1" {
+		t.Errorf("subject = %q, want u1", c.Subject)
+	}
+
+	tests := []struct {
+		name   string
+		secret []byte
+		typ    string
+		now    time.Time
+	}{
+		{"wrong secret", []byte("another secret"), accessToken, now},
+		{"wrong type", testSecret, refreshToken, now},
+		{"expired", testSecret, accessToken, now.Add(time.Minute)},
+	}
+	for _, tt := range tests {
+		if _, err := parseToken(tt.secret, tok, tt.typ, tt.now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+}
+
+// TestParseTokenAlgorithm checks that tokens claiming an algorithm other
+// than HS256 are refused whatever their signature, so a token signed with
+// "none" or with the HMAC secret used as an RSA key cannot pass.
+func TestParseTokenAlgorithm(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tok, err := signToken(testSecret, claims{Subject: "u1", Type: accessToken, ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.Split(tok, ".")[1]
+	enc := base64.RawURLEncoding
+	for _, header := range []string{
+		`{"alg":"none","typ":"JWT"}`,
+		`{"alg":"None","typ":"JWT"}`,
+		`{"alg":"RS256","typ":"JWT"}`,
+		`{"alg":"HS512","typ":"JWT"}`,
+		`{"typ":"JWT"}`,
+		`not json`,
+	} {
+		h := enc.EncodeToString([]byte(header))
+		for _, sig := range []string{"", enc.EncodeToString(sign(testSecret, h+"."+payload))} {
+			forged := h + "." + payload + "." + sig
+			if _, err := parseToken(testSecret, forged, accessToken, now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("header %s, signature %q: err = %v, want ErrInvalidToken", header, sig, err)
+			}
+		}
+	}
+}
+
+func TestParseTokenMalformed(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, tok := range []string{"", "a.b", "a.b.c.d", "!!.e30.", jwtHeader + ".%%%." + "x"} {
+		if _, err := parseToken(testSecret, tok, accessToken, now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("parseToken(%q) = %v, want ErrInvalidToken", tok, err)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+// Package bootstrap sets up new workspace sandboxes. The first time a
+// workspace's container is started, the lifecycle commands of its
+// devcontainer.json are run, its owner's dotfiles repository is cloned
+// and installed in it, and the workspace's own .webide/setup.sh is run,
+// so the tools and shell configuration a user wants are there in every
+// new environment.
+//
+// Commands started in a sandbox that is being set up wait for the setup
+// to finish, up to Config.Timeout. Its output is kept, bounded, and
+// served with the status of the last run.
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/devcontainer"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+)
+
+var (
+	// ErrNoDotfiles is returned for users without dotfiles.
+	ErrNoDotfiles = errors.New("bootstrap: no dotfiles set")
+	// ErrNotRun is returned for workspaces that have not been set up.
+	ErrNotRun = errors.New("bootstrap: workspace has not been set up")
+	// ErrInvalid is returned for invalid dotfiles settings.
+	ErrInvalid = errors.New("bootstrap: invalid dotfiles")
+	// ErrRunning is returned when a workspace is already being set up.
+	ErrRunning = errors.New("bootstrap: setup already running")
+)
+
+// SetupScript is the workspace script run in new sandboxes, relative to
+// the workspace root.
+const SetupScript = ".webide/setup.sh"
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the dotfiles settings, and the status and log of the last
+	// setup of each workspace.
+	Dir string
+	// Timeout bounds a setup; defaults to 10 minutes.
+	Timeout time.Duration
+	// MaxLogBytes caps the output kept of a setup; defaults to 1 MiB.
+	MaxLogBytes int64
+}
+
+// Workspaces resolves workspaces and their owners.
+type Workspaces interface {
+	Open(id string) (string, error)
+	Owner(id string) (string, error)
+}
+
+// States of a setup, for Status.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateTimedOut  = "timed_out"
+)
+
+// Status describes the last setup of a workspace.
+type Status struct {
+	State string `json:"state"`
+	// Dotfiles is the repository that was installed, if any.
+	Dotfiles   string     `json:"dotfiles,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	ExitCode   int        `json:"exitCode"`
+	// Error is why the setup could not run at all.
+	Error        string `json:"error,omitempty"`
+	LogTruncated bool   `json:"logTruncated,omitempty"`
+}
+
+// Service keeps the users' dotfiles and sets up workspace sandboxes.
+type Service struct {
+	cfg        Config
+	workspaces Workspaces
+	dotfiles   *store
+	launcher   terminal.Launcher
+	now        func() time.Time
+
+	mu sync.Mutex
+	// pending holds the workspaces whose sandbox was created but not yet
+	// set up, and runs the setups in progress.
+	pending map[string]bool
+	runs    map[string]*run
+}
+
+type run struct {
+	done chan struct{}
+}
+
+// New returns a Service, filling unset Config fields with defaults. Its
+// setups run with the launcher given to Launcher.
+func New(cfg Config, wm Workspaces) (*Service, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Minute
+	}
+	if cfg.MaxLogBytes <= 0 {
+		cfg.MaxLogBytes = 1 << 20
+	}
+	if err := os.MkdirAll(filepath.Join(cfg.Dir, "runs"), 0o700); err != nil {
+		return nil, fmt.Errorf("bootstrap: create dir: %w", err)
+	}
+	return &Service{
+		cfg:        cfg,
+		workspaces: wm,
+		dotfiles:   &store{dir: cfg.Dir},
+		now:        time.Now,
+		pending:    make(map[string]bool),
+		runs:       make(map[string]*run),
+	}, nil
+}
+
+// Dotfiles returns a user's dotfiles settings.
+func (s *Service) Dotfiles(userID string) (Dotfiles, error) {
+	d, ok, err := s.dotfiles.get(userID)
+	if err != nil {
+		return Dotfiles{}, err
+	}
+	if !ok {
+		return Dotfiles{}, ErrNoDotfiles
+	}
+	return d, nil
+}
+
+// SetDotfiles replaces a user's dotfiles settings. They apply to sandboxes
+// created from then on.
+func (s *Service) SetDotfiles(userID string, d Dotfiles) (Dotfiles, error) {
+	if err := d.normalize(); err != nil {
+		return Dotfiles{}, err
+	}
+	d.UpdatedAt = s.now().UTC()
+	if err := s.dotfiles.put(userID, d); err != nil {
+		return Dotfiles{}, err
+	}
+	return d, nil
+}
+
+// DeleteDotfiles removes a user's dotfiles settings.
+func (s *Service) DeleteDotfiles(userID string) error {
+	return s.dotfiles.delete(userID)
+}
+
+// Created marks a workspace's sandbox as new, to be set up before the
+// next command run in it. It is meant for the Created hook of
+// *terminal.DockerLauncher and *terminal.KubernetesLauncher.
+func (s *Service) Created(workspaceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[workspaceID] = true
+}
+
+// Status returns the status of the last setup of a workspace.
+func (s *Service) Status(workspaceID string) (Status, error) {
+	s.mu.Lock()
+	pending, running := s.pending[workspaceID], s.runs[workspaceID] != nil
+	s.mu.Unlock()
+	if pending {
+		return Status{State: StatePending}, nil
+	}
+	data, err := os.ReadFile(s.statusPath(workspaceID))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return Status{}, ErrNotRun
+	case err != nil:
+		return Status{}, fmt.Errorf("bootstrap: read status: %w", err)
+	}
+	var st Status
+	if err := json.Unmarshal(data, &st); err != nil {
+		return Status{}, fmt.Errorf("bootstrap: read status: %w", err)
+	}
+	if st.State == StateRunning && !running {
+		st.State, st.Error = StateFailed, "interrupted by a server restart"
+	}
+	return st, nil
+}
+
+// Log opens the output of the last setup of a workspace, which is still
+// being written while the setup runs.
+func (s *Service) Log(workspaceID string) (*os.File, error) {
+	f, err := os.Open(s.logPath(workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotRun
+	}
+	return f, err
+}
+
+// Run sets up a workspace's sandbox again, in the background, and returns
+// the status of the new setup.
+func (s *Service) Run(workspaceID string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runs[workspaceID] != nil {
+		return Status{}, ErrRunning
+	}
+	delete(s.pending, workspaceID)
+	s.startLocked(workspaceID)
+	return Status{State: StateRunning}, nil
+}
+
+// wait sets up a workspace's sandbox if it is new, and waits for the
+// setup in progress, if any, to finish.
+func (s *Service) wait(ctx context.Context, workspaceID string) error {
+	s.mu.Lock()
+	r := s.runs[workspaceID]
+	if r == nil && s.pending[workspaceID] {
+		delete(s.pending, workspaceID)
+		r = s.startLocked(workspaceID)
+	}
+	s.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startLocked starts setting up a workspace. s.mu must be held.
+func (s *Service) startLocked(workspaceID string) *run {
+	r := &run{done: make(chan struct{})}
+	s.runs[workspaceID] = r
+	go func() {
+		defer close(r.done)
+		st := s.setup(workspaceID)
+		if err := s.saveStatus(workspaceID, st); err != nil {
+			slog.Error("bootstrap: save status", "workspace", workspaceID, "err", err)
+		}
+		s.mu.Lock()
+		delete(s.runs, workspaceID)
+		s.mu.Unlock()
+	}()
+	return r
+}
+
+// setup runs the setup script in a workspace's sandbox.
+func (s *Service) setup(workspaceID string) Status {
+	start := s.now().UTC()
+	st := Status{State: StateRunning, StartedAt: &start}
+	finish := func(state string) Status {
+		end := s.now().UTC()
+		st.State, st.FinishedAt = state, &end
+		return st
+	}
+	if err := s.saveStatus(workspaceID, st); err != nil {
+		slog.Error("bootstrap: save status", "workspace", workspaceID, "err", err)
+	}
+	logFile, err := os.OpenFile(s.logPath(workspaceID), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		st.Error = err.Error()
+		return finish(StateFailed)
+	}
+	defer logFile.Close()
+
+	dir, err := s.workspaces.Open(workspaceID)
+	if err != nil {
+		st.Error = err.Error()
+		return finish(StateFailed)
+	}
+	var d Dotfiles
+	if owner, err := s.workspaces.Owner(workspaceID); err == nil && owner != "" {
+		if d, _, err = s.dotfiles.get(owner); err != nil {
+			st.Error = err.Error()
+			return finish(StateFailed)
+		}
+	}
+	st.Dotfiles = d.Repository
+	var lifecycle string
+	switch cfg, err := devcontainer.Load(dir); {
+	case err == nil:
+		lifecycle = cfg.Lifecycle()
+	case !errors.Is(err, devcontainer.ErrNotFound):
+		fmt.Fprintf(logFile, "==> Skipping devcontainer.json: %v\n", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	argv := []string{"sh", "-c", script, "sh", d.Repository, d.Ref, d.Install, SetupScript, lifecycle}
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, []string{"GIT_TERMINAL_PROMPT=0"})
+	if err != nil {
+		st.Error = err.Error()
+		return finish(StateFailed)
+	}
+	out := &limitedWriter{w: logFile, n: s.cfg.MaxLogBytes}
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.WaitDelay = 3 * time.Second
+	if err := cmd.Start(); err != nil {
+		st.Error = err.Error()
+		return finish(StateFailed)
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+	err = cmd.Wait()
+	st.LogTruncated = out.truncated
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		st.ExitCode = -1
+		return finish(StateTimedOut)
+	case err == nil:
+		return finish(StateSucceeded)
+	case errors.As(err, &exitErr):
+		st.ExitCode = exitErr.ExitCode()
+	default:
+		st.Error = err.Error()
+	}
+	return finish(StateFailed)
+}
+
+func (s *Service) statusPath(workspaceID string) string {
+	return filepath.Join(s.cfg.Dir, "runs", workspaceID+".json")
+}
+
+func (s *Service) logPath(workspaceID string) string {
+	return filepath.Join(s.cfg.Dir, "runs", workspaceID+".log")
+}
+
+func (s *Service) saveStatus(workspaceID string, st Status) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.statusPath(workspaceID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.statusPath(workspaceID))
+}
+
+// limitedWriter writes up to n bytes to w and drops the rest. It is safe
+// for the concurrent writes of a command's stdout and stderr.
+type limitedWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	n         int64
+	truncated bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if int64(len(p)) > l.n {
+		l.truncated = true
+		if l.n > 0 {
+			l.w.Write(p[:l.n])
+			l.n = 0
+		}
+		return len(p), nil
+	}
+	l.n -= int64(len(p))
+	l.w.Write(p)
+	return len(p), nil
+}
+
+// script sets up a sandbox: $5 is the devcontainer.json's lifecycle
+// script, $1 the dotfiles repository, $2 its ref and $3 its install
+// script, all possibly empty, and $4 the workspace's setup script. A
+// failing step does not stop the next one; the exit status is that of the
+// last failure.
+const script = `status=0
+home=${HOME:-$PWD}
+if [ -n "$5" ]; then
+	sh -c "$5" || status=$?
+fi
+if [ -n "$1" ]; then
+	dir="$home/.dotfiles"
+	echo "==> Installing dotfiles from $1"
+	if [ -d "$dir/.git" ]; then
+		git -C "$dir" pull --ff-only || status=$?
+	elif [ -n "$2" ]; then
+		git clone --depth 1 --branch "$2" -- "$1" "$dir" || status=$?
+	else
+		git clone --depth 1 -- "$1" "$dir" || status=$?
+	fi
+	if [ -d "$dir" ]; then
+		install=$3
+		if [ -z "$install" ]; then
+			for f in install.sh install bootstrap.sh bootstrap script/bootstrap setup.sh setup script/setup; do
+				if [ -f "$dir/$f" ]; then install=$f; break; fi
+			done
+		fi
+		if [ -n "$install" ]; then
+			echo "==> Running $install"
+			if [ -x "$dir/$install" ]; then
+				(cd "$dir" && "./$install") || status=$?
+			else
+				(cd "$dir" && sh "./$install") || status=$?
+			fi
+		else
+			echo "==> Linking dotfiles into $home"
+			for f in "$dir"/.[!.]*; do
+				name=${f##*/}
+				[ "$name" = .git ] && continue
+				[ -e "$f" ] || continue
+				if [ -e "$home/$name" ] || [ -L "$home/$name" ]; then
+					echo "$home/$name exists, skipped"
+				else
+					ln -s "$f" "$home/$name" || status=$?
+				fi
+			done
+		fi
+	fi
+fi
+if [ -f "$4" ]; then
+	echo "==> Running $4"
+	if command -v bash >/dev/null 2>&1; then
+		bash "$4" || status=$?
+	else
+		sh "$4" || status=$?
+	fi
+fi
+exit $status
+`
@@ -0,0 +1,145 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dotfiles is a user's dotfiles repository, installed in the sandboxes of
+// the workspaces they own.
+type Dotfiles struct {
+	// Repository is an https Git URL, or "owner/name" for a GitHub
+	// repository.
+	Repository string `json:"repository"`
+	// Ref is the branch or tag to clone; defaults to the repository's
+	// default branch.
+	Ref string `json:"ref,omitempty"`
+	// Install is the script in the repository that installs it. When
+	// empty the first of the usual names is run, and without one the
+	// repository's top-level dotfiles are linked into the home directory.
+	Install   string    `json:"install,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+var (
+	shorthand = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+	validRef  = regexp.MustCompile(`^[A-Za-z0-9._/-]{1,200}$`)
+)
+
+// normalize checks d and expands a GitHub shorthand repository.
+func (d *Dotfiles) normalize() error {
+	d.Repository = strings.TrimSpace(d.Repository)
+	if shorthand.MatchString(d.Repository) {
+		d.Repository = "https://github.com/" + d.Repository
+	}
+	u, err := url.Parse(d.Repository)
+	if err != nil || u.Scheme != "https" || u.Host == "" || len(d.Repository) > 500 {
+		return fmt.Errorf("%w: repository must be an https URL or owner/name", ErrInvalid)
+	}
+	if d.Ref != "" && (!validRef.MatchString(d.Ref) || strings.HasPrefix(d.Ref, "-")) {
+		return fmt.Errorf("%w: invalid ref", ErrInvalid)
+	}
+	if d.Install != "" {
+		clean := filepath.ToSlash(filepath.Clean(d.Install))
+		if filepath.IsAbs(d.Install) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") ||
+			strings.HasPrefix(clean, "-") || len(clean) > 200 {
+			return fmt.Errorf("%w: install must be a path in the repository", ErrInvalid)
+		}
+		d.Install = clean
+	}
+	return nil
+}
+
+// store keeps the users' dotfiles settings, in one file in its directory.
+type store struct {
+	dir string
+
+	mu    sync.Mutex
+	users map[string]Dotfiles // loaded on first use
+}
+
+func (s *store) get(userID string) (Dotfiles, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users, err := s.loadLocked()
+	if err != nil {
+		return Dotfiles{}, false, err
+	}
+	d, ok := users[userID]
+	return d, ok, nil
+}
+
+func (s *store) put(userID string, d Dotfiles) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users, err := s.loadLocked()
+	if err != nil {
+		return err
+	}
+	next := maps.Clone(users)
+	next[userID] = d
+	return s.saveLocked(next)
+}
+
+func (s *store) delete(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users, err := s.loadLocked()
+	if err != nil {
+		return err
+	}
+	if _, ok := users[userID]; !ok {
+		return ErrNoDotfiles
+	}
+	next := maps.Clone(users)
+	delete(next, userID)
+	return s.saveLocked(next)
+}
+
+func (s *store) path() string { return filepath.Join(s.dir, "dotfiles.json") }
+
+// loadLocked returns the settings by user. s.mu must be held.
+func (s *store) loadLocked() (map[string]Dotfiles, error) {
+	if s.users != nil {
+		return s.users, nil
+	}
+	users := map[string]Dotfiles{}
+	data, err := os.ReadFile(s.path())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("bootstrap: read dotfiles: %w", err)
+	default:
+		if err := json.Unmarshal(data, &users); err != nil {
+			return nil, fmt.Errorf("bootstrap: read dotfiles: %w", err)
+		}
+	}
+	s.users = users
+	return users, nil
+}
+
+// saveLocked replaces the settings. s.mu must be held.
+func (s *store) saveLocked(users map[string]Dotfiles) error {
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("bootstrap: write dotfiles: %w", err)
+	}
+	if err := os.Rename(tmp, s.path()); err != nil {
+		return fmt.Errorf("bootstrap: write dotfiles: %w", err)
+	}
+	s.users = users
+	return nil
+}
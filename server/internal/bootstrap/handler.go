@@ -0,0 +1,161 @@
+package bootstrap
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Handler serves the dotfiles and workspace setup routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the dotfiles and setup routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/dotfiles", h.getDotfiles)
+	mux.HandleFunc("PUT /api/dotfiles", h.setDotfiles)
+	mux.HandleFunc("DELETE /api/dotfiles", h.deleteDotfiles)
+	mux.HandleFunc("GET /api/workspaces/{id}/bootstrap", h.status)
+	mux.HandleFunc("GET /api/workspaces/{id}/bootstrap/log", h.log)
+	mux.HandleFunc("POST /api/workspaces/{id}/bootstrap", h.run)
+}
+
+// user returns the signed-in user. Dotfiles run in every workspace the
+// user owns, so like SSH keys they are not managed with personal access
+// tokens.
+func (h *Handler) user(w http.ResponseWriter, r *http.Request) (*auth.User, bool) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "authentication required")
+		return nil, false
+	}
+	if auth.TokenFrom(r.Context()) != nil {
+		httpx.Error(w, http.StatusForbidden, "personal access tokens cannot manage dotfiles")
+		return nil, false
+	}
+	return u, true
+}
+
+func (h *Handler) getDotfiles(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.svc.Dotfiles(u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, d)
+}
+
+func (h *Handler) setDotfiles(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	var req Dotfiles
+	if err := httpx.DecodeJSON(w, r, &req, 16<<10); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	d, err := h.svc.SetDotfiles(u.ID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionDotfilesSet, Target: d.Repository})
+	httpx.JSON(w, http.StatusOK, d)
+}
+
+func (h *Handler) deleteDotfiles(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteDotfiles(u.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionDotfilesDelete})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return id, true
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	st, err := h.svc.Status(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, st)
+}
+
+func (h *Handler) log(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	f, err := h.svc.Log(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.Copy(w, f)
+}
+
+// run sets the workspace's sandbox up again, such as after its setup
+// script was changed.
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	st, err := h.svc.Run(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionBootstrapRun, Workspace: id})
+	httpx.JSON(w, http.StatusAccepted, st)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrRunning):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNoDotfiles), errors.Is(err, ErrNotRun):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("bootstrap", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "bootstrap request failed")
+	}
+}
@@ -0,0 +1,52 @@
+package bootstrap
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+)
+
+// launcher holds back the commands of another Launcher until their
+// workspace's sandbox is set up.
+type launcher struct {
+	svc  *Service
+	next terminal.Launcher
+}
+
+// Launcher returns a terminal.Launcher that runs commands with l once the
+// sandbox of their workspace has been set up, and makes l the launcher
+// setups run with. A failed setup does not hold back commands; it is
+// reported in its status and log.
+func (s *Service) Launcher(l terminal.Launcher) terminal.Launcher {
+	s.launcher = l
+	return &launcher{svc: s, next: l}
+}
+
+// Command implements terminal.Launcher. The command is prepared first, as
+// that is what creates the sandbox.
+func (l *launcher) Command(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error) {
+	cmd, err := l.next.Command(ctx, workspaceID, dir, argv, env)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.svc.wait(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// Exec implements terminal.Launcher.
+func (l *launcher) Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error) {
+	cmd, err := l.next.Exec(ctx, workspaceID, dir, argv, env)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.svc.wait(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// Root implements terminal.Launcher.
+func (l *launcher) Root(dir string) string { return l.next.Root(dir) }
@@ -0,0 +1,178 @@
+// Package cluster lets several servers behind a load balancer serve the
+// same users and workspaces without sticky sessions. It keeps refresh
+// sessions and OAuth flows in Redis, so a request may reach a different
+// server than the one before it, and its Bus relays collaboration and
+// terminal events between the servers through Redis pub/sub.
+//
+// Sessions are stored with SET ... PXAT and taken with GETDEL, so the
+// server must run Redis 6.2 or later.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/redis"
+)
+
+// Config configures a Cluster.
+type Config struct {
+	// URL is the Redis server's redis:// or rediss:// URL.
+	URL string
+	// Prefix namespaces keys and channels, so servers of different
+	// deployments can share a Redis server; defaults to "webide:".
+	Prefix string
+	// PoolSize caps connections to Redis; defaults to 10.
+	PoolSize int
+}
+
+// timeout bounds each Redis command.
+const timeout = 5 * time.Second
+
+// Cluster is the Redis server the servers of a deployment share.
+type Cluster struct {
+	client *redis.Client
+	prefix string
+}
+
+// Open connects to Redis, filling unset Config fields with defaults.
+func Open(ctx context.Context, cfg Config) (*Cluster, error) {
+	rc, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	rc.PoolSize = cfg.PoolSize
+	rc.Timeout = timeout
+	if cfg.Prefix == "" {
+		cfg.Prefix = "webide:"
+	}
+	c := &Cluster{client: redis.NewClient(rc), prefix: cfg.Prefix}
+	if err := c.client.Ping(ctx); err != nil {
+		c.client.Close()
+		return nil, fmt.Errorf("cluster: connect: %w", err)
+	}
+	return c, nil
+}
+
+// Close closes the connections to Redis.
+func (c *Cluster) Close() error {
+	return c.client.Close()
+}
+
+// Ping checks that Redis answers.
+func (c *Cluster) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx)
+}
+
+// Bus relays messages between the servers.
+func (c *Cluster) Bus() *Bus { return &Bus{c: c} }
+
+// Bus publishes to and subscribes to channels shared by all servers. It
+// satisfies collab.Bus and terminal.Bus.
+type Bus struct{ c *Cluster }
+
+// Publish sends data to everyone subscribed to channel, on any server,
+// and returns how many subscriptions received it.
+func (b *Bus) Publish(channel string, data []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return b.c.client.Publish(ctx, b.c.prefix+channel, data)
+}
+
+// Subscribe calls fn with each message published to channel until cancel
+// is called, and with nil when messages may have been missed because the
+// connection to Redis was lost. fn runs on a goroutine shared by all
+// subscriptions and must not block.
+func (b *Bus) Subscribe(channel string, fn func(data []byte)) (cancel func(), err error) {
+	return b.c.client.Subscribe(b.c.prefix+channel, fn)
+}
+
+// Records returns base with its refresh sessions kept in Redis instead,
+// where they expire on their own.
+func (c *Cluster) Records(base auth.Records) auth.Records {
+	return sessions{Records: base, c: c}
+}
+
+// sessions overrides the session methods of auth.Records.
+type sessions struct {
+	auth.Records
+	c *Cluster
+}
+
+func (s sessions) CreateSession(id string, sess auth.Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	if err := s.c.set("session:"+id, data, sess.ExpiresAt); err != nil {
+		return fmt.Errorf("cluster: create session: %w", err)
+	}
+	return nil
+}
+
+func (s sessions) TakeSession(id string) (auth.Session, error) {
+	var sess auth.Session
+	data, err := s.c.take("session:" + id)
+	if errors.Is(err, redis.ErrNil) {
+		return sess, fmt.Errorf("%w: no session %s", auth.ErrNotFound, id)
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &sess)
+	}
+	if err != nil {
+		return sess, fmt.Errorf("cluster: take session: %w", err)
+	}
+	return sess, nil
+}
+
+// Logins keeps OAuth flows in Redis, so the provider's callback may reach
+// any server.
+func (c *Cluster) Logins() auth.Logins { return logins{c} }
+
+type logins struct{ c *Cluster }
+
+func (l logins) PutLogin(state string, pl auth.PendingLogin) error {
+	data, err := json.Marshal(pl)
+	if err != nil {
+		return err
+	}
+	if err := l.c.set("login:"+state, data, pl.Expires); err != nil {
+		return fmt.Errorf("cluster: store login: %w", err)
+	}
+	return nil
+}
+
+func (l logins) TakeLogin(state string) (auth.PendingLogin, error) {
+	var pl auth.PendingLogin
+	data, err := l.c.take("login:" + state)
+	if errors.Is(err, redis.ErrNil) {
+		return pl, auth.ErrNotFound
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &pl)
+	}
+	if err != nil {
+		return pl, fmt.Errorf("cluster: take login: %w", err)
+	}
+	return pl, nil
+}
+
+// set stores data under key until expires.
+func (c *Cluster) set(key string, data []byte, expires time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := c.client.Do(ctx, "SET", c.prefix+key, data, "PXAT", expires.UnixMilli())
+	return err
+}
+
+// take deletes key and returns what it held, or redis.ErrNil if nothing.
+func (c *Cluster) take(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	v, err := redis.String(c.client.Do(ctx, "GETDEL", c.prefix+key))
+	return []byte(v), err
+}
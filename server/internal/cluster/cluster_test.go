@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/redis/redistest"
+)
+
+func open(t *testing.T, srv *redistest.Server, prefix string) *Cluster {
+	t.Helper()
+	c, err := Open(context.Background(), Config{URL: srv.URL(), Prefix: prefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open(context.Background(), Config{URL: "http://localhost"}); err == nil {
+		t.Error("Open with an http URL succeeded")
+	}
+	srv := redistest.NewServer(t)
+	srv.SetPassword("secret")
+	if _, err := Open(context.Background(), Config{URL: srv.URL()}); err == nil {
+		t.Error("Open without the password succeeded")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	srv := redistest.NewServer(t)
+	records := open(t, srv, "").Records(nil)
+	want := auth.Session{UserID: "u1", ExpiresAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := records.CreateSession("s1", want); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.Get("webide:session:s1"); !ok {
+		t.Error("session not stored under the default prefix")
+	}
+	// Another server of the deployment takes it, once.
+	other := open(t, srv, "").Records(nil)
+	got, err := other.TakeSession("s1")
+	if err != nil || !got.ExpiresAt.Equal(want.ExpiresAt) || got.UserID != want.UserID {
+		t.Errorf("TakeSession = %+v, %v, want %+v", got, err, want)
+	}
+	if _, err := records.TakeSession("s1"); !errors.Is(err, auth.ErrNotFound) {
+		t.Errorf("second TakeSession = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLogins(t *testing.T) {
+	srv := redistest.NewServer(t)
+	a, b := open(t, srv, "a:").Logins(), open(t, srv, "b:").Logins()
+	pl := auth.PendingLogin{Provider: "github", Redirect: "/ide", Expires: time.Now().Add(time.Minute).Truncate(time.Second)}
+	if err := a.PutLogin("state", pl); err != nil {
+		t.Fatal(err)
+	}
+	// Prefixes keep deployments apart.
+	if _, err := b.TakeLogin("state"); !errors.Is(err, auth.ErrNotFound) {
+		t.Errorf("TakeLogin under another prefix = %v, want ErrNotFound", err)
+	}
+	got, err := a.TakeLogin("state")
+	if err != nil || got.Provider != pl.Provider || got.Redirect != pl.Redirect || !got.Expires.Equal(pl.Expires) {
+		t.Errorf("TakeLogin = %+v, %v, want %+v", got, err, pl)
+	}
+}
+
+func receive(t *testing.T, got <-chan []byte) []byte {
+	t.Helper()
+	select {
+	case data := <-got:
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+		return nil
+	}
+}
+
+func TestBus(t *testing.T) {
+	srv := redistest.NewServer(t)
+	a, b := open(t, srv, "").Bus(), open(t, srv, "").Bus()
+	got := make(chan []byte, 16)
+	cancel, err := b.Subscribe("collab:doc", func(data []byte) { got <- data })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	if n, err := a.Publish("collab:doc", []byte("op")); err != nil || n != 1 {
+		t.Fatalf("Publish = %d, %v, want 1 subscription", n, err)
+	}
+	if data := receive(t, got); string(data) != "op" {
+		t.Errorf("message %q, want op", data)
+	}
+
+	// A lost connection is reported with nil, and the subscription
+	// renewed.
+	srv.Drop()
+	if data := receive(t, got); data != nil {
+		t.Errorf("message %q after a reconnect, want nil", data)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, err := a.Publish("collab:doc", []byte("again"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not renewed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if data := receive(t, got); string(data) != "again" {
+		t.Errorf("message %q, want again", data)
+	}
+}
@@ -0,0 +1,527 @@
+// Package collab lets several editors work on the same file at once. Each
+// open file is held as an RGA sequence CRDT (see Doc); peers apply their
+// edits locally, send them as operations, and the server integrates and
+// relays them to everyone else on the file. Because every operation names
+// the characters it builds on rather than numeric offsets, concurrent
+// edits merge without conflicts regardless of arrival order.
+//
+// The server is the relay for all peers of a file, so each peer receives
+// operations in an order that respects their dependencies. The merged text
+// is written back to the workspace shortly after each change and when the
+// last peer leaves.
+//
+// Several servers can serve the same document through a Bus (see
+// Config.Bus): each keeps a replica, and the bus carries their peers'
+// operations and presence between them in one order for all.
+package collab
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/metrics"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/textnorm"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Manager.
+type Config struct {
+	// FlushInterval is how long after a change the merged text is saved;
+	// defaults to 2 seconds.
+	FlushInterval time.Duration
+	// MaxDocBytes limits the size of a collaborative document; defaults
+	// to 1 MiB.
+	MaxDocBytes int
+	// Saved, if set, is called after a document is written to disk with
+	// the text and the names of the peers who edited it since the last save.
+	Saved func(workspaceID, path string, data []byte, editors []string)
+	// Bus, if set, relays each document between the servers that have it
+	// open, so peers connected to different servers edit together. Each
+	// server writes the merged text to its own copy of the workspace.
+	Bus Bus
+	// SyncTimeout is how long a server opening a document waits for a
+	// replica from the servers that have it open before reading the file
+	// itself; defaults to 2 seconds.
+	SyncTimeout time.Duration
+	// Metrics, when set, receives the documents open, the peers connected
+	// and the operations applied.
+	Metrics *metrics.Registry
+	// Recorder, if set, is told of the edits applied to documents.
+	Recorder Recorder
+	// Normalizer, if set, rewrites a file's text as it is loaded into a
+	// document, so every peer starts from the normalized text, and as it
+	// is saved, which mends what peers inserted without touching the
+	// document they share. Peers are told of the invisible characters
+	// they insert.
+	Normalizer Normalizer
+}
+
+// Normalizer rewrites text before it is saved, as textnorm.Service does.
+type Normalizer interface {
+	Normalize(workspaceID, name string, data []byte) ([]byte, *textnorm.Report)
+}
+
+// Recorder follows edits by offset, as session recordings do.
+type Recorder interface {
+	// Recording reports whether the edits to the document at path are
+	// wanted, and whether its text before the next edit is too.
+	Recording(workspaceID, path string) (edits, text bool)
+	// Edited receives the edits of one operation by editor, and the text
+	// before them if Recording asked for it.
+	Edited(workspaceID, path, editor, text string, edits []Edit)
+}
+
+var (
+	// ErrBinary is returned when the file is not valid UTF-8 text.
+	ErrBinary = errors.New("collab: file is not UTF-8 text")
+	// ErrClosed is returned once the Manager has been closed.
+	ErrClosed = errors.New("collab: manager closed")
+)
+
+// peerBuffer is how many messages a peer may lag behind before it is
+// disconnected. A peer that misses an operation would diverge, so it is
+// dropped and must rejoin for a fresh snapshot.
+const peerBuffer = 256
+
+// fileSite attributes the characters loaded from disk.
+const fileSite = "file"
+
+// Manager holds the open collaborative documents.
+type Manager struct {
+	cfg  Config
+	node string // identifies this server on the bus
+
+	mu     sync.Mutex
+	docs   map[string]*document // workspace ID + "\x00" + path
+	closed bool
+
+	ops *metrics.Counter
+}
+
+// NewManager returns a Manager, filling unset Config fields with defaults.
+func NewManager(cfg Config) *Manager {
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 2 * time.Second
+	}
+	if cfg.MaxDocBytes <= 0 {
+		cfg.MaxDocBytes = 1 << 20
+	}
+	if cfg.SyncTimeout <= 0 {
+		cfg.SyncTimeout = 2 * time.Second
+	}
+	m := &Manager{cfg: cfg, node: newSite(), docs: make(map[string]*document)}
+	m.ops = cfg.Metrics.Counter("webide_collab_ops_total", "Collaborative editing operations applied from local peers.")
+	cfg.Metrics.GaugeFunc("webide_collab_documents", "Collaborative documents open.", func() float64 {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return float64(len(m.docs))
+	})
+	cfg.Metrics.GaugeFunc("webide_collab_peers", "Peers connected to collaborative documents on this server.", func() float64 {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		n := 0
+		for _, d := range m.docs {
+			d.mu.Lock()
+			n += len(d.peers)
+			d.mu.Unlock()
+		}
+		return float64(n)
+	})
+	return m
+}
+
+// MessageType names the messages sent to peers.
+type MessageType string
+
+const (
+	MsgSnapshot  MessageType = "snapshot"
+	MsgOp        MessageType = "op"
+	MsgJoin      MessageType = "join"
+	MsgLeave     MessageType = "leave"
+	MsgPresence  MessageType = "presence"
+	MsgComment   MessageType = "comment"
+	MsgReview    MessageType = "review"
+	MsgInvisible MessageType = "invisible"
+)
+
+// Message is sent from the server to a peer.
+type Message struct {
+	Type MessageType `json:"type"`
+	// Site is the receiving peer's site ID (snapshot) or the site that
+	// sent the operation or left (op, leave).
+	Site  string `json:"site,omitempty"`
+	Clock uint64 `json:"clock,omitempty"`
+	Runs  []Run  `json:"runs,omitempty"`
+	Op    *Op    `json:"op,omitempty"`
+	// Peers lists the other peers already on the document (snapshot).
+	Peers []Presence `json:"peers,omitempty"`
+	// Presence is the peer that joined or moved its cursor (join, presence).
+	Presence *Presence `json:"presence,omitempty"`
+	// Comment is a peer's remark on the document (comment).
+	Comment *Comment `json:"comment,omitempty"`
+	// Review is a change to the document's review threads (review).
+	Review json.RawMessage `json:"review,omitempty"`
+	// Invisible are the invisible characters in the peer's last insert
+	// (invisible).
+	Invisible []Invisible `json:"invisible,omitempty"`
+}
+
+// Invisible is an invisible character a peer inserted, such as a zero
+// width space that came with pasted code.
+type Invisible struct {
+	ID   ID     `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+type document struct {
+	m         *Manager
+	key       string
+	workspace string
+	path      string
+	fs        *files.FS
+
+	saveMu sync.Mutex // orders writes to disk
+
+	mu    sync.Mutex
+	doc   *Doc
+	peers map[*Peer]struct{}
+	dirty bool
+	timer *time.Timer
+	// editors are the names of the peers that changed the document since
+	// it was last saved.
+	editors map[string]struct{}
+	// remote are the peers on other servers, by site.
+	remote map[string]Presence
+
+	// Under a bus, doc is nil until join has the replica, and messages
+	// that arrive meanwhile are kept in backlog.
+	synced      chan struct{} // closed once state is set
+	state       *Message
+	backlog     []busMessage
+	unsubscribe func()
+}
+
+// Peer is one editor connected to a document.
+type Peer struct {
+	d    *document
+	site string
+	user User
+	c    chan Message
+
+	sel *Selection // guarded by d.mu
+}
+
+// Join connects a new peer, shown to others as user, to path in the
+// workspace rooted at dir, loading the file on first use. The first message
+// on the peer's channel is a snapshot of the document.
+func (m *Manager) Join(workspaceID, dir, path string, user User) (*Peer, error) {
+	clean, err := files.Clean(path)
+	if err != nil {
+		return nil, err
+	}
+	if clean == "" {
+		return nil, files.ErrIsDir
+	}
+	key := workspaceID + "\x00" + clean
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	d, ok := m.docs[key]
+	if !ok {
+		d, err = m.load(key, workspaceID, dir, clean)
+		if err != nil {
+			return nil, err
+		}
+		m.docs[key] = d
+	}
+
+	site := newSite()
+	p := &Peer{d: d, site: site, user: user.normalize(site), c: make(chan Message, peerBuffer)}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	others := d.presencesLocked()
+	pr := p.presenceLocked()
+	d.sendLocked(nil, Message{Type: MsgJoin, Presence: &pr})
+	d.peers[p] = struct{}{}
+	p.c <- Message{Type: MsgSnapshot, Site: p.site, Clock: d.doc.Clock(), Runs: d.doc.Runs(), Peers: others}
+	return p, nil
+}
+
+func (m *Manager) load(key, workspaceID, dir, path string) (*document, error) {
+	fsys, err := files.New(dir)
+	if err != nil {
+		return nil, err
+	}
+	d := &document{
+		m:         m,
+		key:       key,
+		workspace: workspaceID,
+		path:      path,
+		fs:        fsys,
+		peers:     make(map[*Peer]struct{}),
+		remote:    make(map[string]Presence),
+	}
+	read := func() (*Doc, error) { return m.read(fsys, workspaceID, path) }
+	if m.cfg.Bus != nil {
+		err = d.join(read)
+	} else {
+		d.doc, err = read()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// read loads the file at path in the workspace as a new document,
+// normalized.
+func (m *Manager) read(fsys *files.FS, workspaceID, path string) (*Doc, error) {
+	data, err := fsys.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if m.cfg.Normalizer != nil && len(data) <= m.cfg.MaxDocBytes {
+		data, _ = m.cfg.Normalizer.Normalize(workspaceID, path, data)
+	}
+	if len(data) > m.cfg.MaxDocBytes {
+		return nil, ErrTooLarge
+	}
+	if !utf8.Valid(data) {
+		return nil, ErrBinary
+	}
+	return NewDoc(fileSite, string(data), m.cfg.MaxDocBytes), nil
+}
+
+// Site returns the peer's site ID, which it must use for its inserts.
+func (p *Peer) Site() string { return p.site }
+
+// Messages delivers snapshots and other peers' operations. It is closed
+// when the peer leaves or falls too far behind.
+func (p *Peer) Messages() <-chan Message { return p.c }
+
+// Apply integrates an operation from the peer and relays it to the others.
+func (p *Peer) Apply(op Op) error {
+	d := p.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.peers[p]; !ok {
+		return ErrClosed
+	}
+	if op.Kind == OpInsert && (op.ID == nil || op.ID.Site != p.site) {
+		return fmt.Errorf("%w: inserts must use the peer's site", ErrInvalidOp)
+	}
+	if err := d.applyLocked(op, p.user.Name); err != nil {
+		return err
+	}
+	d.sendLocked(p, Message{Type: MsgOp, Site: p.site, Op: &op})
+	d.changedLocked(p.user.Name)
+	d.m.ops.Inc()
+	if op.Kind == OpInsert && d.m.cfg.Normalizer != nil {
+		p.invisibleLocked(op)
+	}
+	return nil
+}
+
+// invisibleLocked tells the peer of the invisible characters its insert
+// op put in the document. The notice is dropped for a peer that is behind.
+// d.mu must be held.
+func (p *Peer) invisibleLocked(op Op) {
+	diags := textnorm.Scan(op.Text)
+	if len(diags) == 0 {
+		return
+	}
+	chars := make([]Invisible, len(diags))
+	for i, dg := range diags {
+		id := *op.ID
+		id.Seq += uint64(utf8.RuneCountInString(op.Text[:dg.Offset]))
+		chars[i] = Invisible{ID: id, Code: dg.Code, Name: dg.Name}
+	}
+	select {
+	case p.c <- Message{Type: MsgInvisible, Site: p.site, Invisible: chars}:
+	default:
+	}
+}
+
+// applyLocked integrates op by editor into the document, telling the
+// recorder. d.mu must be held.
+func (d *document) applyLocked(op Op, editor string) error {
+	r := d.m.cfg.Recorder
+	if r == nil {
+		return d.doc.Apply(op)
+	}
+	record, base := r.Recording(d.workspace, d.path)
+	if !record {
+		return d.doc.Apply(op)
+	}
+	var text string
+	if base {
+		text = d.doc.Text()
+	}
+	var edits []Edit
+	if op.Kind == OpDelete {
+		edits = d.doc.edits(op)
+	}
+	if err := d.doc.Apply(op); err != nil {
+		return err
+	}
+	if op.Kind == OpInsert {
+		edits = d.doc.edits(op)
+	}
+	if len(edits) > 0 || base {
+		r.Edited(d.workspace, d.path, editor, text, edits)
+	}
+	return nil
+}
+
+// changedLocked schedules a save after an edit by editor. d.mu must be
+// held.
+func (d *document) changedLocked(editor string) {
+	d.dirty = true
+	if d.editors == nil {
+		d.editors = make(map[string]struct{})
+	}
+	if editor != "" {
+		d.editors[editor] = struct{}{}
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.m.cfg.FlushInterval, d.flush)
+	}
+}
+
+// Leave disconnects the peer. When the last peer leaves, the document is
+// saved and unloaded.
+func (p *Peer) Leave() {
+	d := p.d
+	m := d.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d.mu.Lock()
+	if _, ok := d.peers[p]; ok {
+		delete(d.peers, p)
+		close(p.c)
+		d.sendLocked(nil, Message{Type: MsgLeave, Site: p.site})
+	}
+	empty := len(d.peers) == 0
+	d.mu.Unlock()
+
+	if empty && m.docs[d.key] == d {
+		delete(m.docs, d.key)
+		d.close()
+	}
+}
+
+// broadcastLocked sends msg to every peer except from. Peers that cannot
+// keep up are disconnected, and the others told they left. d.mu must be
+// held.
+func (d *document) broadcastLocked(from *Peer, msg Message) {
+	var dropped []*Peer
+	for other := range d.peers {
+		if other == from {
+			continue
+		}
+		select {
+		case other.c <- msg:
+		default:
+			slog.Warn("dropping slow collaboration peer", "workspace", d.workspace, "path", d.path)
+			delete(d.peers, other)
+			close(other.c)
+			dropped = append(dropped, other)
+		}
+	}
+	for _, p := range dropped {
+		d.sendLocked(nil, Message{Type: MsgLeave, Site: p.site})
+	}
+}
+
+// presencesLocked returns everyone on the document, here and on other
+// servers. d.mu must be held.
+func (d *document) presencesLocked() []Presence {
+	out := make([]Presence, 0, len(d.peers)+len(d.remote))
+	for p := range d.peers {
+		out = append(out, p.presenceLocked())
+	}
+	for _, pr := range d.remote {
+		out = append(out, pr)
+	}
+	return out
+}
+
+// close disconnects the peers of an unloaded document, stops relaying it
+// and saves it.
+func (d *document) close() {
+	d.mu.Lock()
+	for p := range d.peers {
+		delete(d.peers, p)
+		close(p.c)
+		d.publish(busMessage{Message: Message{Type: MsgLeave, Site: p.site}})
+	}
+	d.mu.Unlock()
+	if d.unsubscribe != nil {
+		d.unsubscribe()
+	}
+	d.flush()
+}
+
+// flush writes the merged text to the workspace if it has changed.
+func (d *document) flush() {
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+	d.mu.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if !d.dirty {
+		d.mu.Unlock()
+		return
+	}
+	d.dirty = false
+	text := d.doc.Text()
+	editors := slices.Sorted(maps.Keys(d.editors))
+	d.editors = nil
+	d.mu.Unlock()
+
+	data := []byte(text)
+	if d.m.cfg.Normalizer != nil {
+		data, _ = d.m.cfg.Normalizer.Normalize(d.workspace, d.path, data)
+	}
+	if _, err := d.fs.WriteFile(d.path, data); err != nil {
+		slog.Error("save collaborative document", "workspace", d.workspace, "path", d.path, "err", err)
+		return
+	}
+	if d.m.cfg.Saved != nil {
+		d.m.cfg.Saved(d.workspace, d.path, data, editors)
+	}
+}
+
+// Close saves every open document and disconnects all peers.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for key, d := range m.docs {
+		d.close()
+		delete(m.docs, key)
+	}
+}
+
+func newSite() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
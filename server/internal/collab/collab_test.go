@@ -0,0 +1,64 @@
+package collab
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/textnorm"
+)
+
+// policyNormalizer applies one policy to every workspace.
+type policyNormalizer textnorm.Policy
+
+func (p policyNormalizer) Normalize(workspaceID, name string, data []byte) ([]byte, *textnorm.Report) {
+	text, rep := textnorm.Policy(p).Normalize(name, string(data))
+	return []byte(text), rep
+}
+
+func TestNormalize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("\uFEFFa  \r\nb\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var saved string
+	m := NewManager(Config{
+		FlushInterval: time.Hour,
+		Normalizer:    policyNormalizer{EOL: textnorm.EOLLF, TrimTrailingWhitespace: true, StripBOM: true},
+		Saved:         func(_, _ string, data []byte, _ []string) { saved = string(data) },
+	})
+	defer m.Close()
+	p, err := m.Join("ws1", dir, "a.txt", User{Name: "ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Peers start from the normalized text.
+	snap := <-p.Messages()
+	if got := p.d.doc.Text(); snap.Type != MsgSnapshot || got != "a\nb\n" {
+		t.Fatalf("loaded %q, want the normalized text", got)
+	}
+
+	// What they insert stays in the document and is normalized in the file.
+	op := Op{Kind: OpInsert, ID: &ID{Site: p.Site(), Seq: snap.Clock + 1}, Text: "x \r\n\u200B"}
+	if err := p.Apply(op); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-p.Messages():
+		if msg.Type != MsgInvisible || len(msg.Invisible) != 1 || msg.Invisible[0].ID != (ID{Site: p.Site(), Seq: snap.Clock + 5}) {
+			t.Errorf("after inserting a zero width space, got %+v", msg)
+		}
+	default:
+		t.Error("no notice of the zero width space inserted")
+	}
+	if got, want := p.d.doc.Text(), "x \r\n\u200Ba\nb\n"; got != want {
+		t.Errorf("document = %q, want %q", got, want)
+	}
+	p.Leave()
+	data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if want := "x\n\u200Ba\nb\n"; err != nil || string(data) != want || saved != want {
+		t.Errorf("saved %q (Saved got %q), %v; want %q", data, saved, err, want)
+	}
+}
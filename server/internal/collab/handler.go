@@ -0,0 +1,177 @@
+package collab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler connects editors to collaborative documents over WebSocket.
+type Handler struct {
+	m          *Manager
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler serving documents from m.
+func NewHandler(m *Manager, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{m: m, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the collaboration socket and presence API on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /ws/workspaces/{id}/collab/{path...}", h.serve)
+	mux.HandleFunc("GET /api/workspaces/{id}/presence", h.presence)
+}
+
+// clientFrame is a message from the editor: {"type":"op","op":{...}},
+// {"type":"presence","selection":{...}} or
+// {"type":"comment","text":"...","at":{...}}.
+type clientFrame struct {
+	Type      string     `json:"type"`
+	Op        *Op        `json:"op"`
+	Selection *Selection `json:"selection"`
+	Text      string     `json:"text"`
+	At        *Position  `json:"at"`
+}
+
+type commentsKey struct{}
+
+// WithComments returns a context under which viewers may comment on the
+// session, as guests of a comment share link may.
+func WithComments(ctx context.Context) context.Context {
+	return context.WithValue(ctx, commentsKey{}, true)
+}
+
+// canComment reports whether the caller may comment: anyone but viewers,
+// unless the context allows comments.
+func canComment(ctx context.Context) bool {
+	ok, _ := ctx.Value(commentsKey{}).(bool)
+	return ok || workspace.RoleFrom(ctx) != workspace.RoleViewer
+}
+
+type errorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// serve joins the socket to the document for the file at {path...}, with
+// the display name and color from ?name= and ?color=. The first server
+// frame is a snapshot; after that the client receives other peers'
+// operations, presence updates, and join and leave events. An operation
+// the server cannot apply means the client's replica has diverged, so it
+// is told why and disconnected to rejoin with a fresh snapshot. Viewers
+// follow along but are disconnected if they send an operation. The
+// session ends when the request's context does, for example when the
+// share link it was opened with expires.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	q := r.URL.Query()
+	user := User{Name: q.Get("name"), Color: q.Get("color")}
+	peer, err := h.m.Join(id, dir, r.PathValue("path"), user)
+	switch {
+	case errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir), errors.Is(err, ErrBinary):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case err != nil:
+		slog.Error("open collaborative document", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not open document")
+		return
+	}
+	defer peer.Leave()
+
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	events.Publish(r.Context(), events.Event{Type: events.UserJoined, Workspace: id, Data: map[string]any{
+		"path": r.PathValue("path"),
+		"name": user.Name,
+	}})
+
+	stop := context.AfterFunc(r.Context(), func() {
+		conn.CloseWithCode(ws.ClosePolicyViolation, "session ended")
+	})
+	defer stop()
+
+	readOnly := workspace.RoleFrom(r.Context()) == workspace.RoleViewer
+	comments := canComment(r.Context())
+	go func() {
+		defer peer.Leave()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var f clientFrame
+			if json.Unmarshal(data, &f) != nil {
+				continue
+			}
+			if f.Type == "presence" && f.Selection != nil {
+				// A stale caret is harmless; the next update replaces it.
+				peer.SetSelection(*f.Selection)
+				continue
+			}
+			if f.Type == "comment" {
+				if !comments {
+					conn.WriteJSON(errorFrame{Type: "error", Error: "read-only access"})
+				} else if err := peer.Comment(f.Text, f.At); err != nil {
+					conn.WriteJSON(errorFrame{Type: "error", Error: err.Error()})
+				}
+				continue
+			}
+			if f.Type != "op" || f.Op == nil {
+				continue
+			}
+			if readOnly {
+				conn.WriteJSON(errorFrame{Type: "error", Error: "read-only access"})
+				conn.CloseWithCode(ws.ClosePolicyViolation, "read-only access")
+				return
+			}
+			if err := peer.Apply(*f.Op); err != nil {
+				conn.WriteJSON(errorFrame{Type: "error", Error: err.Error()})
+				conn.CloseWithCode(ws.CloseProtocolError, "operation rejected")
+				return
+			}
+		}
+	}()
+
+	for msg := range peer.Messages() {
+		if err := conn.WriteJSON(msg); err != nil {
+			return
+		}
+	}
+	conn.CloseWithCode(ws.CloseTryAgainLater, "resynchronize")
+}
+
+// presence lists who is editing which file in the workspace.
+func (h *Handler) presence(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"users": h.m.Presence(id)})
+}
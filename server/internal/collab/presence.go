@@ -0,0 +1,185 @@
+package collab
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Position is a caret location that survives concurrent edits: it sits
+// immediately after the character After, or at the start when After is nil.
+type Position struct {
+	After *ID `json:"after"`
+}
+
+// Selection is a peer's caret (Head) and the other end of its selection
+// (Anchor). They are equal when nothing is selected.
+type Selection struct {
+	Anchor Position `json:"anchor"`
+	Head   Position `json:"head"`
+}
+
+// User is how a peer is shown to the others.
+type User struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// Presence describes one peer of a document.
+type Presence struct {
+	Site string `json:"site"`
+	User
+	Path      string     `json:"path"`
+	Selection *Selection `json:"selection,omitempty"`
+}
+
+const maxNameLen = 64
+
+var (
+	colorRE = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	palette = []string{
+		"#e06c75", "#98c379", "#e5c07b", "#61afef",
+		"#c678dd", "#56b6c2", "#d19a66", "#be5046",
+	}
+)
+
+// normalize fills in a display name and color for u, derived from site
+// when the client supplied none or invalid ones.
+func (u User) normalize(site string) User {
+	u.Name = strings.TrimSpace(u.Name)
+	if !utf8.ValidString(u.Name) || u.Name == "" {
+		u.Name = "Guest " + site[:4]
+	}
+	if len(u.Name) > maxNameLen {
+		u.Name = u.Name[:maxNameLen]
+		for !utf8.ValidString(u.Name) {
+			u.Name = u.Name[:len(u.Name)-1]
+		}
+	}
+	if !colorRE.MatchString(u.Color) {
+		h := fnv.New32a()
+		h.Write([]byte(site))
+		u.Color = palette[h.Sum32()%uint32(len(palette))]
+	}
+	return u
+}
+
+// presenceLocked returns p's presence; p.d.mu must be held.
+func (p *Peer) presenceLocked() Presence {
+	return Presence{Site: p.site, User: p.user, Path: p.d.path, Selection: p.sel}
+}
+
+// SetSelection records the peer's caret and selection and tells the others.
+func (p *Peer) SetSelection(sel Selection) error {
+	d := p.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.peers[p]; !ok {
+		return ErrClosed
+	}
+	for _, pos := range []Position{sel.Anchor, sel.Head} {
+		if pos.After != nil {
+			if _, ok := d.doc.index[*pos.After]; !ok {
+				return ErrInvalidOp
+			}
+		}
+	}
+	p.sel = &sel
+	pr := p.presenceLocked()
+	d.sendLocked(p, Message{Type: MsgPresence, Presence: &pr})
+	return nil
+}
+
+// Comment is a remark a peer makes during a session, optionally at a
+// position in the document. Comments are relayed to everyone on the
+// document, the sender included, and not stored.
+type Comment struct {
+	Site string `json:"site"`
+	User
+	Text string    `json:"text"`
+	At   *Position `json:"at,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+const maxCommentLen = 2000
+
+// Comment relays a comment from the peer to everyone on the document.
+func (p *Peer) Comment(text string, at *Position) error {
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > maxCommentLen || !utf8.ValidString(text) {
+		return fmt.Errorf("%w: comments must be 1 to %d bytes of text", ErrInvalidOp, maxCommentLen)
+	}
+	d := p.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.peers[p]; !ok {
+		return ErrClosed
+	}
+	if at != nil && at.After != nil {
+		if _, ok := d.doc.index[*at.After]; !ok {
+			return ErrInvalidOp
+		}
+	}
+	c := Comment{Site: p.site, User: p.user, Text: text, At: at, Time: time.Now().UTC()}
+	d.sendLocked(nil, Message{Type: MsgComment, Comment: &c})
+	return nil
+}
+
+// Review tells everyone on the file at path, on every server, about a
+// change to its review threads, which data describes.
+func (m *Manager) Review(workspaceID, path string, data json.RawMessage) {
+	msg := Message{Type: MsgReview, Review: data}
+	m.mu.Lock()
+	d := m.docs[workspaceID+"\x00"+path]
+	m.mu.Unlock()
+	if d != nil {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.sendLocked(nil, msg)
+		return
+	}
+	if m.cfg.Bus == nil {
+		return
+	}
+	// The file is not open here, but may be on other servers.
+	data, err := json.Marshal(busMessage{Node: m.node, Message: msg})
+	if err == nil {
+		_, err = m.cfg.Bus.Publish(channel(workspaceID, path), data)
+	}
+	if err != nil {
+		slog.Error("relay review", "workspace", workspaceID, "path", path, "err", err)
+	}
+}
+
+// Presence returns everyone editing files in the workspace, ordered by path
+// and then by name. Under a bus, it includes the peers on other servers of
+// the files open here.
+func (m *Manager) Presence(workspaceID string) []Presence {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Presence{}
+	for _, d := range m.docs {
+		if d.workspace != workspaceID {
+			continue
+		}
+		d.mu.Lock()
+		out = append(out, d.presencesLocked()...)
+		d.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Site < out[j].Site
+	})
+	return out
+}
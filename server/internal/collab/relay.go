@@ -0,0 +1,197 @@
+package collab
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// Bus relays messages between servers. Each server that has a document
+// open subscribes to the document's channel; what its peers do is
+// published there and replayed to the peers on the other servers.
+type Bus interface {
+	// Publish sends data to every subscription of channel and returns
+	// how many there are, this server's included.
+	Publish(channel string, data []byte) (int, error)
+	// Subscribe calls fn with the messages published to channel, in
+	// order, until cancel is called. fn is called with nil when messages
+	// may have been missed.
+	Subscribe(channel string, fn func(data []byte)) (cancel func(), err error)
+}
+
+// msgSync asks the servers that have a document open for their replica,
+// which they send as a snapshot addressed to the asking server.
+const msgSync MessageType = "sync"
+
+// busMessage is a Message relayed between servers.
+type busMessage struct {
+	// Node is the server that sent the message.
+	Node string `json:"node"`
+	// To, if set, is the only server the message is for.
+	To string `json:"to,omitempty"`
+	Message
+}
+
+// join subscribes a document loaded under a bus to its channel and takes
+// its replica from a server that has it open, or from load if none does.
+// Every server must start from the same replica, or operations would not
+// apply alike everywhere; load gives the same one only where the file is
+// the same.
+//
+// Messages that arrive before the replica are kept and applied after it.
+// Operations the replica already contains are skipped, which the server
+// that sent it guarantees for every one it saw before the request.
+func (d *document) join(load func() (*Doc, error)) error {
+	d.synced = make(chan struct{})
+	cancel, err := d.m.cfg.Bus.Subscribe(d.channel(), d.receive)
+	if err != nil {
+		return err
+	}
+	d.unsubscribe = cancel
+	n, err := d.publish(busMessage{Message: Message{Type: msgSync}})
+	if err != nil {
+		cancel()
+		return err
+	}
+	if n > 1 {
+		select {
+		case <-d.synced:
+		case <-time.After(d.m.cfg.SyncTimeout):
+			slog.Warn("no replica of collaborative document from other servers", "workspace", d.workspace, "path", d.path)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state != nil {
+		d.doc, err = DocFromRuns(d.state.Runs, d.m.cfg.MaxDocBytes)
+		for _, p := range d.state.Peers {
+			d.remote[p.Site] = p
+		}
+	} else {
+		d.doc, err = load()
+	}
+	if err != nil {
+		cancel()
+		return err
+	}
+	for _, msg := range d.backlog {
+		d.handleLocked(msg)
+	}
+	d.state, d.backlog = nil, nil
+	return nil
+}
+
+func (d *document) channel() string {
+	return channel(d.workspace, d.path)
+}
+
+func channel(workspaceID, path string) string {
+	return "collab:" + workspaceID + ":" + path
+}
+
+// receive handles a message from the bus.
+func (d *document) receive(data []byte) {
+	if data == nil {
+		go d.m.resync(d)
+		return
+	}
+	var msg busMessage
+	if json.Unmarshal(data, &msg) != nil || msg.Node == d.m.node {
+		return
+	}
+	if msg.To != "" && msg.To != d.m.node {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.doc == nil {
+		switch {
+		case msg.Type == MsgSnapshot:
+			if d.state == nil {
+				d.state = &msg.Message
+				close(d.synced)
+			}
+		case msg.Type != msgSync:
+			d.backlog = append(d.backlog, msg)
+		}
+		return
+	}
+	d.handleLocked(msg)
+}
+
+// handleLocked applies a message from another server and passes it on to
+// the peers here. d.mu must be held.
+func (d *document) handleLocked(msg busMessage) {
+	switch msg.Type {
+	case MsgOp:
+		op := msg.Op
+		if op == nil || op.Kind == OpInsert && op.ID != nil && d.doc.Has(*op.ID) {
+			return
+		}
+		if err := d.applyLocked(*op, d.remote[msg.Site].Name); err != nil {
+			slog.Warn("collaborative document diverged from other servers", "workspace", d.workspace, "path", d.path, "err", err)
+			go d.m.resync(d)
+			return
+		}
+		d.broadcastLocked(nil, msg.Message)
+		d.changedLocked(d.remote[msg.Site].Name)
+	case MsgJoin, MsgPresence:
+		if msg.Presence == nil {
+			return
+		}
+		d.remote[msg.Presence.Site] = *msg.Presence
+		d.broadcastLocked(nil, msg.Message)
+	case MsgLeave:
+		delete(d.remote, msg.Site)
+		d.broadcastLocked(nil, msg.Message)
+	case MsgComment, MsgReview:
+		d.broadcastLocked(nil, msg.Message)
+	case msgSync:
+		d.publish(busMessage{To: msg.Node, Message: Message{
+			Type:  MsgSnapshot,
+			Clock: d.doc.Clock(),
+			Runs:  d.doc.Runs(),
+			Peers: d.presencesLocked(),
+		}})
+	}
+}
+
+// sendLocked passes msg from a peer here to the other peers here and to
+// the other servers. d.mu must be held, so that messages are published in
+// the order they were applied.
+func (d *document) sendLocked(from *Peer, msg Message) {
+	d.broadcastLocked(from, msg)
+	d.publish(busMessage{Message: msg})
+}
+
+// publish sends msg to the other servers and returns how many servers
+// have the document open, this one included.
+func (d *document) publish(msg busMessage) (int, error) {
+	if d.m.cfg.Bus == nil {
+		return 0, nil
+	}
+	msg.Node = d.m.node
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	n, err := d.m.cfg.Bus.Publish(d.channel(), data)
+	if err != nil {
+		slog.Error("relay collaboration message", "workspace", d.workspace, "path", d.path, "err", err)
+	}
+	return n, err
+}
+
+// resync unloads a document whose replica may have missed operations from
+// other servers. Its peers are disconnected, and rejoining loads it again
+// from a server whose replica is whole.
+func (m *Manager) resync(d *document) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.docs[d.key] != d {
+		return
+	}
+	delete(m.docs, d.key)
+	d.close()
+}
@@ -0,0 +1,345 @@
+package collab
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ID identifies one character of a document. Seq is a Lamport timestamp and
+// Site the replica that created the character; together they are unique.
+type ID struct {
+	Site string `json:"site"`
+	Seq  uint64 `json:"seq"`
+}
+
+// after reports whether id sorts after o. Concurrent inserts at the same
+// position are ordered by descending ID.
+func (id ID) after(o ID) bool {
+	if id.Seq != o.Seq {
+		return id.Seq > o.Seq
+	}
+	return id.Site > o.Site
+}
+
+func (id ID) String() string { return fmt.Sprintf("%s:%d", id.Site, id.Seq) }
+
+// OpKind distinguishes insertions from deletions.
+type OpKind string
+
+const (
+	OpInsert OpKind = "insert"
+	OpDelete OpKind = "delete"
+)
+
+// Op is a replicated edit.
+//
+// An insert places Text immediately after the character After, or at the
+// start of the document when After is nil. Its runes take the IDs
+// {ID.Site, ID.Seq}, {ID.Site, ID.Seq+1}, and so on. ID.Seq must exceed
+// every timestamp the creating replica has seen.
+//
+// A delete removes the characters named by Spans. Deleted characters stay
+// in the document as tombstones so later inserts can still reference them.
+type Op struct {
+	Kind  OpKind `json:"kind"`
+	ID    *ID    `json:"id,omitempty"`
+	After *ID    `json:"after,omitempty"`
+	Text  string `json:"text,omitempty"`
+	Spans []Span `json:"spans,omitempty"`
+}
+
+// Span names Len characters with consecutive timestamps starting at Seq.
+type Span struct {
+	Site string `json:"site"`
+	Seq  uint64 `json:"seq"`
+	Len  int    `json:"len"`
+}
+
+// Run is a stretch of consecutive characters from one insert, as sent in
+// snapshots. Replaying the runs in order rebuilds the document.
+type Run struct {
+	ID      ID     `json:"id"`
+	Text    string `json:"text"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+var (
+	// ErrInvalidOp is returned for operations that are malformed or refer
+	// to characters the document does not contain.
+	ErrInvalidOp = errors.New("collab: invalid operation")
+	// ErrTooLarge is returned when an insert would exceed the size limit.
+	ErrTooLarge = errors.New("collab: document too large")
+)
+
+type node struct {
+	id      ID
+	r       rune
+	deleted bool
+	next    *node
+}
+
+// Doc is a Replicated Growable Array: a sequence CRDT in which every
+// replica that applies the same set of operations, each after the
+// operations it depends on, converges to the same text.
+type Doc struct {
+	head  node // sentinel; head.next is the first character
+	index map[ID]*node
+	clock uint64
+	bytes int // UTF-8 size of the visible text
+	max   int
+}
+
+// NewDoc returns a document holding text, attributed to site. max limits
+// the visible size in bytes; zero means unlimited.
+func NewDoc(site, text string, max int) *Doc {
+	d := &Doc{index: make(map[ID]*node), max: max}
+	prev := &d.head
+	for _, r := range text {
+		d.clock++
+		n := &node{id: ID{Site: site, Seq: d.clock}, r: r}
+		prev.next = n
+		d.index[n.id] = n
+		prev = n
+	}
+	d.bytes = len(text)
+	return d
+}
+
+// DocFromRuns rebuilds a document from the runs of another replica's
+// Runs, as a server joining a document already open elsewhere does.
+func DocFromRuns(runs []Run, max int) (*Doc, error) {
+	d := &Doc{index: make(map[ID]*node), max: max}
+	prev := &d.head
+	for _, run := range runs {
+		if run.ID.Seq == 0 || run.Text == "" || !utf8.ValidString(run.Text) {
+			return nil, fmt.Errorf("%w: malformed run", ErrInvalidOp)
+		}
+		id := run.ID
+		for _, r := range run.Text {
+			if _, dup := d.index[id]; dup {
+				return nil, fmt.Errorf("%w: duplicate id %s", ErrInvalidOp, id)
+			}
+			n := &node{id: id, r: r, deleted: run.Deleted}
+			prev.next = n
+			d.index[id] = n
+			prev = n
+			if id.Seq > d.clock {
+				d.clock = id.Seq
+			}
+			if !run.Deleted {
+				d.bytes += utf8.RuneLen(r)
+			}
+			id.Seq++
+		}
+	}
+	return d, nil
+}
+
+// Has reports whether the document contains the character id, deleted or
+// not.
+func (d *Doc) Has(id ID) bool {
+	_, ok := d.index[id]
+	return ok
+}
+
+// Clock returns the largest timestamp in the document.
+func (d *Doc) Clock() uint64 { return d.clock }
+
+// Len returns the visible size in bytes.
+func (d *Doc) Len() int { return d.bytes }
+
+// Apply integrates op. It fails without modifying the document if op is
+// invalid.
+func (d *Doc) Apply(op Op) error {
+	switch op.Kind {
+	case OpInsert:
+		return d.insert(op)
+	case OpDelete:
+		return d.delete(op.Spans)
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidOp, op.Kind)
+	}
+}
+
+func (d *Doc) insert(op Op) error {
+	if op.ID == nil || op.ID.Seq == 0 || op.Text == "" || !utf8.ValidString(op.Text) {
+		return fmt.Errorf("%w: insert needs an id and valid text", ErrInvalidOp)
+	}
+	if d.max > 0 && d.bytes+len(op.Text) > d.max {
+		return ErrTooLarge
+	}
+	origin := &d.head
+	if op.After != nil {
+		n, ok := d.index[*op.After]
+		if !ok {
+			return fmt.Errorf("%w: unknown character %s", ErrInvalidOp, op.After)
+		}
+		if !op.ID.after(*op.After) {
+			return fmt.Errorf("%w: %s does not follow %s", ErrInvalidOp, op.ID, op.After)
+		}
+		origin = n
+	}
+	count := uint64(utf8.RuneCountInString(op.Text))
+	for i := uint64(0); i < count; i++ {
+		if _, dup := d.index[ID{Site: op.ID.Site, Seq: op.ID.Seq + i}]; dup {
+			return fmt.Errorf("%w: duplicate id %s", ErrInvalidOp, ID{Site: op.ID.Site, Seq: op.ID.Seq + i})
+		}
+	}
+
+	prev := origin
+	seq := op.ID.Seq
+	for _, r := range op.Text {
+		n := &node{id: ID{Site: op.ID.Site, Seq: seq}, r: r}
+		// Skip concurrent inserts at the same position that sort first.
+		// Every character placed after one of them has a larger timestamp,
+		// so their subtrees are skipped too.
+		for prev.next != nil && prev.next.id.after(n.id) {
+			prev = prev.next
+		}
+		n.next = prev.next
+		prev.next = n
+		d.index[n.id] = n
+		prev = n
+		seq++
+	}
+	if last := seq - 1; last > d.clock {
+		d.clock = last
+	}
+	d.bytes += len(op.Text)
+	return nil
+}
+
+func (d *Doc) delete(spans []Span) error {
+	if len(spans) == 0 {
+		return fmt.Errorf("%w: delete needs spans", ErrInvalidOp)
+	}
+	for _, s := range spans {
+		if s.Len <= 0 {
+			return fmt.Errorf("%w: empty span", ErrInvalidOp)
+		}
+		for i := 0; i < s.Len; i++ {
+			id := ID{Site: s.Site, Seq: s.Seq + uint64(i)}
+			if _, ok := d.index[id]; !ok {
+				return fmt.Errorf("%w: unknown character %s", ErrInvalidOp, id)
+			}
+		}
+	}
+	for _, s := range spans {
+		for i := 0; i < s.Len; i++ {
+			n := d.index[ID{Site: s.Site, Seq: s.Seq + uint64(i)}]
+			if !n.deleted {
+				n.deleted = true
+				d.bytes -= utf8.RuneLen(n.r)
+			}
+		}
+	}
+	return nil
+}
+
+// Text returns the visible contents.
+func (d *Doc) Text() string {
+	var b strings.Builder
+	b.Grow(d.bytes)
+	for n := d.head.next; n != nil; n = n.next {
+		if !n.deleted {
+			b.WriteRune(n.r)
+		}
+	}
+	return b.String()
+}
+
+// Runs returns the whole document, tombstones included, as runs in order.
+func (d *Doc) Runs() []Run {
+	var runs []Run
+	var b strings.Builder
+	var cur *Run
+	var last ID
+	for n := d.head.next; n != nil; n = n.next {
+		if cur != nil && n.id.Site == last.Site && n.id.Seq == last.Seq+1 && n.deleted == cur.Deleted {
+			b.WriteRune(n.r)
+			last = n.id
+			continue
+		}
+		if cur != nil {
+			cur.Text = b.String()
+			runs = append(runs, *cur)
+		}
+		b.Reset()
+		b.WriteRune(n.r)
+		cur = &Run{ID: n.id, Deleted: n.deleted}
+		last = n.id
+	}
+	if cur != nil {
+		cur.Text = b.String()
+		runs = append(runs, *cur)
+	}
+	return runs
+}
+
+// Edit is a change to the text by offset, for consumers that do not keep
+// a replica: Delete bytes at byte Offset are replaced with Insert.
+type Edit struct {
+	Offset int    `json:"offset"`
+	Delete int    `json:"delete,omitempty"`
+	Insert string `json:"insert,omitempty"`
+}
+
+// edits returns the offset edits op makes, in the order to apply them.
+// Deletions must be located before op is applied and insertions after.
+func (d *Doc) edits(op Op) []Edit {
+	var edits []Edit
+	off := 0
+	switch op.Kind {
+	case OpInsert:
+		first, last := *op.ID, *op.ID
+		last.Seq += uint64(utf8.RuneCountInString(op.Text)) - 1
+		var b strings.Builder
+		start := -1
+		for n := d.head.next; n != nil; n = n.next {
+			ours := n.id.Site == first.Site && n.id.Seq >= first.Seq && n.id.Seq <= last.Seq
+			if ours {
+				if start < 0 {
+					start = off
+				}
+				b.WriteRune(n.r)
+			} else if start >= 0 {
+				// Concurrent inserts ended up between our characters.
+				edits = append(edits, Edit{Offset: start, Insert: b.String()})
+				b.Reset()
+				start = -1
+			}
+			if !n.deleted {
+				off += utf8.RuneLen(n.r)
+			}
+		}
+		if start >= 0 {
+			edits = append(edits, Edit{Offset: start, Insert: b.String()})
+		}
+	case OpDelete:
+		gone := make(map[*node]bool)
+		for _, s := range op.Spans {
+			for i := 0; i < s.Len; i++ {
+				gone[d.index[ID{Site: s.Site, Seq: s.Seq + uint64(i)}]] = true
+			}
+		}
+		// Offsets are into the text with the earlier edits applied.
+		for n := d.head.next; n != nil; n = n.next {
+			if n.deleted {
+				continue
+			}
+			size := utf8.RuneLen(n.r)
+			if !gone[n] {
+				off += size
+				continue
+			}
+			if k := len(edits) - 1; k >= 0 && edits[k].Offset == off {
+				edits[k].Delete += size
+			} else {
+				edits = append(edits, Edit{Offset: off, Delete: size})
+			}
+		}
+	}
+	return edits
+}
@@ -0,0 +1,351 @@
+package collab
+
+import (
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// chars returns the IDs of the document's characters in order, with the
+// tombstones when all is set.
+func chars(d *Doc, all bool) []ID {
+	var out []ID
+	for n := d.head.next; n != nil; n = n.next {
+		if all || !n.deleted {
+			out = append(out, n.id)
+		}
+	}
+	return out
+}
+
+// replica is a site editing its own copy of a document.
+type replica struct {
+	site  string
+	doc   *Doc
+	inbox []Op
+}
+
+// localEdit makes a random edit at r, as its editor would, checks it
+// against the same edit by offset, and returns the op.
+func (r *replica) localEdit(t *testing.T, rng *rand.Rand, anchor *ID) Op {
+	t.Helper()
+	before := []rune(r.doc.Text())
+	visible := chars(r.doc, false)
+	if anchor != nil || len(visible) == 0 || rng.IntN(3) > 0 {
+		pos := rng.IntN(len(visible) + 1)
+		op := Op{Kind: OpInsert, ID: &ID{Site: r.site, Seq: r.doc.Clock() + 1}, Text: randomText(rng)}
+		switch {
+		case anchor != nil:
+			op.After = anchor
+		case pos > 0:
+			op.After = &visible[pos-1]
+		}
+		if err := r.doc.Apply(op); err != nil {
+			t.Fatalf("%s: local insert: %v", r.site, err)
+		}
+		if anchor == nil {
+			want := string(before[:pos]) + op.Text + string(before[pos:])
+			if got := r.doc.Text(); got != want {
+				t.Fatalf("%s: insert of %q at %d gives %q, want %q", r.site, op.Text, pos, got, want)
+			}
+		}
+		return op
+	}
+	// Delete a range of characters, tombstones included, so that some
+	// spans name characters already deleted here or elsewhere.
+	all := chars(r.doc, true)
+	i := rng.IntN(len(all))
+	j := min(len(all), i+1+rng.IntN(3))
+	var spans []Span
+	for _, id := range all[i:j] {
+		if k := len(spans) - 1; k >= 0 && spans[k].Site == id.Site && spans[k].Seq+uint64(spans[k].Len) == id.Seq {
+			spans[k].Len++
+		} else {
+			spans = append(spans, Span{Site: id.Site, Seq: id.Seq, Len: 1})
+		}
+	}
+	op := Op{Kind: OpDelete, Spans: spans}
+	if err := r.doc.Apply(op); err != nil {
+		t.Fatalf("%s: local delete: %v", r.site, err)
+	}
+	return op
+}
+
+func randomText(rng *rand.Rand) string {
+	const alphabet = "abcxyz é世\n"
+	runes := []rune(alphabet)
+	var b strings.Builder
+	for range 1 + rng.IntN(3) {
+		b.WriteRune(runes[rng.IntN(len(runes))])
+	}
+	return b.String()
+}
+
+// deliver applies one op from r's inbox, picked at random among those
+// whose dependencies r already has, and reports whether there was one.
+func (r *replica) deliver(t *testing.T, rng *rand.Rand) bool {
+	t.Helper()
+	for _, k := range rng.Perm(len(r.inbox)) {
+		op := r.inbox[k]
+		before := r.doc.Runs()
+		err := r.doc.Apply(op)
+		if errors.Is(err, ErrInvalidOp) {
+			// It depends on an op not delivered yet.
+			if !reflect.DeepEqual(r.doc.Runs(), before) {
+				t.Fatalf("%s: failed op %+v changed the document", r.site, op)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: apply %+v: %v", r.site, op, err)
+		}
+		r.inbox = append(r.inbox[:k], r.inbox[k+1:]...)
+		return true
+	}
+	return false
+}
+
+// TestConvergence edits a document at several replicas at once and
+// delivers the ops in a different order to each, respecting only their
+// dependencies; every replica must end with the same document.
+func TestConvergence(t *testing.T) {
+	for seed := range uint64(50) {
+		rng := rand.New(rand.NewPCG(seed, 7))
+		base := NewDoc(fileSite, "héllo\nworld", 0)
+		var rs []*replica
+		for _, site := range []string{"a", "b", "c", "d"} {
+			doc, err := DocFromRuns(base.Runs(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs = append(rs, &replica{site: site, doc: doc})
+		}
+		broadcast := func(from *replica, op Op) {
+			for _, r := range rs {
+				if r != from {
+					r.inbox = append(r.inbox, op)
+				}
+			}
+		}
+		for step := range 300 {
+			if step%50 == 0 {
+				// Every replica inserts after the same character before
+				// seeing the others' inserts.
+				var anchor *ID
+				if ids := chars(rs[0].doc, true); len(ids) > 0 {
+					anchor = &ids[rng.IntN(len(ids))]
+				}
+				for _, r := range rs {
+					if anchor != nil && !r.doc.Has(*anchor) {
+						continue
+					}
+					broadcast(r, r.localEdit(t, rng, anchor))
+				}
+				continue
+			}
+			r := rs[rng.IntN(len(rs))]
+			if rng.IntN(2) == 0 {
+				broadcast(r, r.localEdit(t, rng, nil))
+			} else {
+				r.deliver(t, rng)
+			}
+		}
+		for _, r := range rs {
+			for r.deliver(t, rng) {
+			}
+			if len(r.inbox) > 0 {
+				t.Fatalf("seed %d: %s: %d ops left whose dependencies never arrived", seed, r.site, len(r.inbox))
+			}
+		}
+		want := rs[0].doc
+		for _, r := range rs[1:] {
+			if got := r.doc.Text(); got != want.Text() {
+				t.Fatalf("seed %d: %s has %q, %s has %q", seed, r.site, got, rs[0].site, want.Text())
+			}
+			if !reflect.DeepEqual(r.doc.Runs(), want.Runs()) {
+				t.Fatalf("seed %d: %s and %s have the same text but different runs", seed, r.site, rs[0].site)
+			}
+			if r.doc.Len() != len(r.doc.Text()) {
+				t.Fatalf("seed %d: %s: Len = %d, text has %d bytes", seed, r.site, r.doc.Len(), len(r.doc.Text()))
+			}
+		}
+	}
+}
+
+func TestConcurrentInsertOrder(t *testing.T) {
+	// Inserts at the same place come out in descending ID order at every
+	// replica, whatever order they arrive in.
+	ops := []Op{
+		{Kind: OpInsert, ID: &ID{Site: "a", Seq: 10}, Text: "A"},
+		{Kind: OpInsert, ID: &ID{Site: "b", Seq: 10}, Text: "B"},
+		{Kind: OpInsert, ID: &ID{Site: "c", Seq: 9}, Text: "CC"},
+		{Kind: OpInsert, ID: &ID{Site: "a", Seq: 11}, After: &ID{Site: "c", Seq: 9}, Text: "x"},
+	}
+	for _, order := range [][]int{{0, 1, 2, 3}, {2, 3, 1, 0}, {1, 2, 0, 3}, {2, 1, 3, 0}} {
+		d := NewDoc(fileSite, "", 0)
+		for _, i := range order {
+			if err := d.Apply(ops[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := d.Text(); got != "BACxC" {
+			t.Errorf("order %v: text = %q, want BACxC", order, got)
+		}
+	}
+}
+
+func TestDeleteTwice(t *testing.T) {
+	d := NewDoc(fileSite, "abcd", 0)
+	del := Op{Kind: OpDelete, Spans: []Span{{Site: fileSite, Seq: 2, Len: 2}}}
+	for range 2 {
+		if err := d.Apply(del); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Apply(Op{Kind: OpDelete, Spans: []Span{{Site: fileSite, Seq: 1, Len: 3}}}); err != nil {
+		t.Fatal(err)
+	}
+	if d.Text() != "d" || d.Len() != 1 {
+		t.Errorf("text = %q (len %d), want d", d.Text(), d.Len())
+	}
+	// Inserts may still follow a deleted character.
+	if err := d.Apply(Op{Kind: OpInsert, ID: &ID{Site: "x", Seq: 5}, After: &ID{Site: fileSite, Seq: 2}, Text: "!"}); err != nil {
+		t.Fatal(err)
+	}
+	if d.Text() != "!d" {
+		t.Errorf("text = %q, want !d", d.Text())
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		op   Op
+		err  error
+	}{
+		{"unknown kind", Op{Kind: "move"}, ErrInvalidOp},
+		{"insert without id", Op{Kind: OpInsert, Text: "x"}, ErrInvalidOp},
+		{"zero seq", Op{Kind: OpInsert, ID: &ID{Site: "x"}, Text: "x"}, ErrInvalidOp},
+		{"empty text", Op{Kind: OpInsert, ID: &ID{Site: "x", Seq: 9}}, ErrInvalidOp},
+		{"invalid utf-8", Op{Kind: OpInsert, ID: &ID{Site: "x", Seq: 9}, Text: "\xff"}, ErrInvalidOp},
+		{"unknown anchor", Op{Kind: OpInsert, ID: &ID{Site: "x", Seq: 9}, After: &ID{Site: "y", Seq: 1}, Text: "x"}, ErrInvalidOp},
+		{"id before anchor", Op{Kind: OpInsert, ID: &ID{Site: "x", Seq: 2}, After: &ID{Site: fileSite, Seq: 3}, Text: "x"}, ErrInvalidOp},
+		{"duplicate id", Op{Kind: OpInsert, ID: &ID{Site: fileSite, Seq: 2}, Text: "xy"}, ErrInvalidOp},
+		{"delete without spans", Op{Kind: OpDelete}, ErrInvalidOp},
+		{"empty span", Op{Kind: OpDelete, Spans: []Span{{Site: fileSite, Seq: 1}}}, ErrInvalidOp},
+		{"span past the end", Op{Kind: OpDelete, Spans: []Span{{Site: fileSite, Seq: 1, Len: 1}, {Site: fileSite, Seq: 3, Len: 2}}}, ErrInvalidOp},
+		{"too large", Op{Kind: OpInsert, ID: &ID{Site: "x", Seq: 9}, Text: "xyz"}, ErrTooLarge},
+	}
+	for _, tt := range tests {
+		d := NewDoc(fileSite, "abc", 5)
+		before := d.Runs()
+		if err := d.Apply(tt.op); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Apply = %v, want %v", tt.name, err, tt.err)
+		}
+		if !reflect.DeepEqual(d.Runs(), before) || d.Len() != 3 {
+			t.Errorf("%s: failed Apply changed the document", tt.name)
+		}
+	}
+}
+
+func TestRunsRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(11, 12))
+	r := &replica{site: "a", doc: NewDoc(fileSite, "the quick brown fox", 0)}
+	for range 200 {
+		r.localEdit(t, rng, nil)
+	}
+	runs := r.doc.Runs()
+	cp, err := DocFromRuns(runs, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Text() != r.doc.Text() || cp.Len() != r.doc.Len() || cp.Clock() != r.doc.Clock() {
+		t.Fatalf("copy has %q (len %d, clock %d), want %q (len %d, clock %d)",
+			cp.Text(), cp.Len(), cp.Clock(), r.doc.Text(), r.doc.Len(), r.doc.Clock())
+	}
+	if !reflect.DeepEqual(cp.Runs(), runs) {
+		t.Fatal("runs of the copy differ")
+	}
+	if !reflect.DeepEqual(chars(cp, true), chars(r.doc, true)) {
+		t.Fatal("characters of the copy differ")
+	}
+	// Both take the same later edits to the same result.
+	other := &replica{site: "b", doc: cp}
+	for range 50 {
+		op := other.localEdit(t, rng, nil)
+		if err := r.doc.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cp.Text() != r.doc.Text() {
+		t.Errorf("after the same edits, copy has %q, original %q", cp.Text(), r.doc.Text())
+	}
+	for _, run := range runs {
+		if run.Text == "" || !utf8.ValidString(run.Text) {
+			t.Errorf("malformed run %+v", run)
+		}
+	}
+}
+
+func TestDocFromRunsInvalid(t *testing.T) {
+	for _, runs := range [][]Run{
+		{{ID: ID{Site: "a"}, Text: "x"}},
+		{{ID: ID{Site: "a", Seq: 1}}},
+		{{ID: ID{Site: "a", Seq: 1}, Text: "\xff"}},
+		{{ID: ID{Site: "a", Seq: 1}, Text: "xy"}, {ID: ID{Site: "a", Seq: 2}, Text: "z"}},
+	} {
+		if _, err := DocFromRuns(runs, 0); !errors.Is(err, ErrInvalidOp) {
+			t.Errorf("DocFromRuns(%+v) = %v, want ErrInvalidOp", runs, err)
+		}
+	}
+}
+
+func TestPeerApplySite(t *testing.T) {
+	m := NewManager(Config{})
+	defer m.Close()
+	dir := t.TempDir()
+	a, err := m.Join("ws", dir, "main.go", User{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.Join("ws", dir, "main.go", User{Name: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range []Op{
+		{Kind: OpInsert, ID: &ID{Site: b.Site(), Seq: 1}, Text: "x"},
+		{Kind: OpInsert, ID: &ID{Site: fileSite, Seq: 1}, Text: "x"},
+		{Kind: OpInsert, Text: "x"},
+	} {
+		if err := a.Apply(op); !errors.Is(err, ErrInvalidOp) {
+			t.Errorf("insert with site %v by peer %s = %v, want ErrInvalidOp", op.ID, a.Site(), err)
+		}
+	}
+	if err := a.Apply(Op{Kind: OpInsert, ID: &ID{Site: a.Site(), Seq: 1}, Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	// Deletes may name anyone's characters.
+	if err := b.Apply(Op{Kind: OpDelete, Spans: []Span{{Site: a.Site(), Seq: 2, Len: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []MessageType
+	for len(b.Messages()) > 0 {
+		msg := <-b.Messages()
+		got = append(got, msg.Type)
+		if msg.Type == MsgOp && (msg.Site != a.Site() || msg.Op.Text != "hi") {
+			t.Errorf("relayed op = %+v from %s, want a's insert", msg.Op, msg.Site)
+		}
+	}
+	if want := []MessageType{MsgSnapshot, MsgOp}; !reflect.DeepEqual(got, want) {
+		t.Errorf("b received %v, want %v", got, want)
+	}
+	a.Leave()
+	if err := a.Apply(Op{Kind: OpInsert, ID: &ID{Site: a.Site(), Seq: 3}, Text: "x"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Apply after Leave = %v, want ErrClosed", err)
+	}
+	b.Leave()
+}
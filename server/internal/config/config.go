@@ -0,0 +1,405 @@
+// Package config reads the server's configuration: WEBIDE_* environment
+// variables, layered over an optional YAML file.
+//
+// The file holds the same settings as the environment, with the variable
+// names taken apart into nested keys: quota.cpu_seconds sets
+// WEBIDE_QUOTA_CPU_SECONDS, and a list is what the variable has separated
+// by commas. A variable that is set wins over the file, so a deployment can
+// share one file and override it per server.
+//
+// Settings are read with typed accessors, which report values they cannot
+// use through Err instead of taking them silently. Tunables registered
+// with OnReload are read again when Reload reads the file again, such as on
+// SIGHUP, so they change on a running server; Reload refuses a file with a
+// value that does not validate, and reports the changes that only apply
+// after a restart.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalid is returned by Reload for a file it refuses.
+var ErrInvalid = errors.New("config: invalid configuration")
+
+// Config is the server's configuration. Accessors take the names of
+// environment variables and return "" or the zero value for settings that
+// are unset.
+type Config struct {
+	s *state
+	// track, in the Config passed to a reload hook, collects the keys the
+	// hook reads.
+	track map[string]bool
+}
+
+type state struct {
+	path string
+	env  func(string) (string, bool)
+	now  func() time.Time
+
+	reloading sync.Mutex
+
+	mu       sync.Mutex
+	file     map[string]string
+	loadedAt time.Time
+	// checks has every key read so far and how its values are validated,
+	// nil for keys that take any value.
+	checks map[string]func(string) error
+	live   map[string]bool
+	hooks  []func(*Config) error
+	errs   []error
+}
+
+// Load reads the configuration file at path, which may be "" for the
+// environment alone.
+func Load(path string) (*Config, error) {
+	file, err := readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	s := &state{
+		path:   path,
+		env:    os.LookupEnv,
+		now:    time.Now,
+		file:   file,
+		checks: make(map[string]func(string) error),
+		live:   make(map[string]bool),
+	}
+	s.loadedAt = s.now().UTC()
+	return &Config{s: s}, nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	if path == "" {
+		return map[string]string{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// Path returns the file the configuration is read from, "" for none.
+func (c *Config) Path() string { return c.s.path }
+
+// lookup returns the value of key, from the environment or else the file,
+// and notes how its values are validated.
+func (c *Config) lookup(key string, check func(string) error) string {
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, seen := s.checks[key]; !seen || check != nil {
+		s.checks[key] = check
+	}
+	if c.track != nil {
+		c.track[key] = true
+	}
+	v, _ := s.valueLocked(key)
+	return v
+}
+
+// valueLocked returns the value of key and where it comes from: "env",
+// "file" or "" when it is unset. s.mu must be held.
+func (s *state) valueLocked(key string) (value, source string) {
+	if v, ok := s.env(key); ok && v != "" {
+		return v, "env"
+	}
+	if v := s.file[key]; v != "" {
+		return v, "file"
+	}
+	return "", ""
+}
+
+func (c *Config) fail(err error) {
+	c.s.mu.Lock()
+	c.s.errs = append(c.s.errs, err)
+	c.s.mu.Unlock()
+}
+
+// Err reports the settings whose values could not be used, which were
+// taken as unset.
+func (c *Config) Err() error {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	return errors.Join(c.s.errs...)
+}
+
+// Unused returns the settings of the file nothing has read, which are
+// most likely misspelled.
+func (c *Config) Unused() []string {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	var out []string
+	for k := range c.s.file {
+		if _, ok := c.s.checks[k]; !ok {
+			out = append(out, k)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// String returns the value of key.
+func (c *Config) String(key string) string { return c.lookup(key, nil) }
+
+// StringOr returns the value of key, or def when it is unset.
+func (c *Config) StringOr(key, def string) string {
+	if v := c.String(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// List returns the comma-separated items of key, without blanks.
+func (c *Config) List(key string) []string {
+	var out []string
+	for _, v := range strings.Split(c.String(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Number returns key as a number.
+func (c *Config) Number(key string) float64 {
+	n, _ := Get(c, key, func(v string) (float64, error) {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("want a number, not %q", v)
+		}
+		return n, nil
+	})
+	return n
+}
+
+// Duration returns key as a number of units, such as minutes.
+func (c *Config) Duration(key string, unit time.Duration) time.Duration {
+	return time.Duration(c.Number(key) * float64(unit))
+}
+
+// Bool returns key as 1, true, on or yes, or 0, false, off or no, and def
+// when it is unset.
+func (c *Config) Bool(key string, def bool) bool {
+	b, ok := Get(c, key, parseBool)
+	if !ok {
+		return def
+	}
+	return b
+}
+
+func parseBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "1", "true", "on", "yes":
+		return true, nil
+	case "0", "false", "off", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("want on or off, not %q", v)
+}
+
+// Level returns key as a log level: debug, info, warn or error.
+func (c *Config) Level(key string) slog.Level {
+	l, _ := Get(c, key, func(v string) (slog.Level, error) {
+		var l slog.Level
+		err := l.UnmarshalText([]byte(v))
+		return l, err
+	})
+	return l
+}
+
+// Get returns key parsed with parse, and whether it is set. A value parse
+// rejects is reported by Err, and by Reload before it takes effect, and
+// gives the zero T.
+func Get[T any](c *Config, key string, parse func(string) (T, error)) (T, bool) {
+	var zero T
+	v := c.lookup(key, func(v string) error {
+		_, err := parse(v)
+		return err
+	})
+	if v == "" {
+		return zero, false
+	}
+	t, err := parse(v)
+	if err != nil {
+		c.fail(fmt.Errorf("config: %s: %w", key, err))
+		return zero, false
+	}
+	return t, true
+}
+
+// OnReload calls fn now and after every Reload, to apply tunables that
+// change without a restart. The settings fn reads are reported as live.
+func (c *Config) OnReload(fn func(*Config) error) error {
+	err := c.s.apply(fn)
+	c.s.mu.Lock()
+	c.s.hooks = append(c.s.hooks, fn)
+	c.s.mu.Unlock()
+	return err
+}
+
+func (s *state) apply(fn func(*Config) error) error {
+	view := &Config{s: s, track: make(map[string]bool)}
+	err := fn(view)
+	s.mu.Lock()
+	for k := range view.track {
+		s.live[k] = true
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// Change is a setting Reload found changed in the file.
+type Change struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+	// Live reports that the change took effect. The others wait for a
+	// restart, or, when Overridden, for the variable to be unset.
+	Live       bool `json:"live"`
+	Overridden bool `json:"overridden,omitempty"`
+}
+
+// Result is what a Reload did.
+type Result struct {
+	LoadedAt time.Time `json:"loadedAt"`
+	Changes  []Change  `json:"changes"`
+	// Errors are those of reload hooks that could not apply their
+	// settings; the others did.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Reload reads the file again and applies the tunables registered with
+// OnReload. A file that cannot be read or has a value that does not
+// validate is refused with ErrInvalid, keeping the configuration as it
+// was.
+func (c *Config) Reload() (*Result, error) {
+	s := c.s
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+	next, err := readFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.checks))
+	for k := range s.checks {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var errs []error
+	for _, k := range keys {
+		if v := next[k]; v != "" && s.checks[k] != nil {
+			if err := s.checks[k](v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", k, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, errors.Join(errs...))
+	}
+
+	res := &Result{LoadedAt: s.now().UTC(), Changes: []Change{}}
+	changed := make(map[string]bool)
+	for k, v := range next {
+		if s.file[k] != v {
+			changed[k] = true
+		}
+	}
+	for k, v := range s.file {
+		if next[k] != v {
+			changed[k] = true
+		}
+	}
+	for k := range changed {
+		ev, _ := s.env(k)
+		fromEnv := ev != ""
+		res.Changes = append(res.Changes, Change{
+			Key:        k,
+			Old:        redact(k, s.file[k]),
+			New:        redact(k, next[k]),
+			Live:       s.live[k] && !fromEnv,
+			Overridden: fromEnv,
+		})
+	}
+	slices.SortFunc(res.Changes, func(a, b Change) int { return strings.Compare(a.Key, b.Key) })
+	s.file, s.loadedAt = next, res.LoadedAt
+	hooks := slices.Clone(s.hooks)
+	s.mu.Unlock()
+
+	for _, fn := range hooks {
+		if err := s.apply(fn); err != nil {
+			res.Errors = append(res.Errors, err.Error())
+		}
+	}
+	return res, nil
+}
+
+// Setting is one setting of the configuration.
+type Setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Source is "env", "file" or "" for a setting left to its default.
+	Source string `json:"source"`
+	Live   bool   `json:"live"`
+	// Unused marks settings of the file nothing reads.
+	Unused bool `json:"unused,omitempty"`
+}
+
+// Settings returns the settings the server reads, and those of the file,
+// with secret values hidden.
+func (c *Config) Settings() (settings []Setting, loadedAt time.Time) {
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.checks)+len(s.file))
+	for k := range s.checks {
+		keys = append(keys, k)
+	}
+	for k := range s.file {
+		if _, ok := s.checks[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	out := make([]Setting, 0, len(keys))
+	for _, k := range keys {
+		v, src := s.valueLocked(k)
+		_, read := s.checks[k]
+		out = append(out, Setting{Key: k, Value: redact(k, v), Source: src, Live: s.live[k], Unused: !read})
+	}
+	return out, s.loadedAt
+}
+
+// redact hides the values of keys, secrets and passwords, and the
+// credentials in URLs.
+func redact(key, v string) string {
+	if v == "" {
+		return v
+	}
+	for _, suffix := range []string{"_KEY", "_SECRET", "_TOKEN", "_PASSWORD"} {
+		if strings.HasSuffix(key, suffix) {
+			return "redacted"
+		}
+	}
+	if u, err := url.Parse(v); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return v
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeConfig(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "webide.yaml")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	path := writeConfig(t, "addr: \":8080\"\nquota:\n  sandboxes: 3\nadmins: [a, b]\nlog_level: info\n")
+	t.Setenv("WEBIDE_QUOTA_SANDBOXES", "7")
+	t.Setenv("WEBIDE_ADMINS", "c")
+	// An empty variable does not hide the file.
+	t.Setenv("WEBIDE_LOG_LEVEL", "")
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.String("WEBIDE_ADDR"); got != ":8080" {
+		t.Errorf("WEBIDE_ADDR = %q, want the file's :8080", got)
+	}
+	if got := c.Number("WEBIDE_QUOTA_SANDBOXES"); got != 7 {
+		t.Errorf("WEBIDE_QUOTA_SANDBOXES = %v, want the environment's 7", got)
+	}
+	if got := c.List("WEBIDE_ADMINS"); !slices.Equal(got, []string{"c"}) {
+		t.Errorf("WEBIDE_ADMINS = %q, want the environment's [c]", got)
+	}
+	if got := c.String("WEBIDE_LOG_LEVEL"); got != "info" {
+		t.Errorf("WEBIDE_LOG_LEVEL = %q, want the file's info", got)
+	}
+	if got := c.StringOr("WEBIDE_UNSET", "def"); got != "def" {
+		t.Errorf("StringOr of an unset key = %q, want def", got)
+	}
+}
+
+func TestInvalidValues(t *testing.T) {
+	path := writeConfig(t, "quota:\n  sandboxes: many\ndebug: sure\ntypo: 1\n")
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Number("WEBIDE_QUOTA_SANDBOXES"); got != 0 {
+		t.Errorf("invalid number = %v, want 0", got)
+	}
+	if got := c.Bool("WEBIDE_DEBUG", true); !got {
+		t.Error("invalid bool did not give the default")
+	}
+	if err := c.Err(); err == nil {
+		t.Error("Err = nil after invalid values")
+	}
+	if got := c.Unused(); !slices.Equal(got, []string{"WEBIDE_TYPO"}) {
+		t.Errorf("Unused = %q, want [WEBIDE_TYPO]", got)
+	}
+}
+
+func TestLoadRefusesBadFile(t *testing.T) {
+	if _, err := Load(writeConfig(t, "addr: x\n  port: 1\n")); err == nil {
+		t.Error("Load of a misindented file succeeded")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeConfig(t, "quota:\n  sandboxes: 3\naddr: \":8080\"\n")
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sandboxes float64
+	if err := c.OnReload(func(c *Config) error {
+		sandboxes = c.Number("WEBIDE_QUOTA_SANDBOXES")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.String("WEBIDE_ADDR")
+
+	os.WriteFile(path, []byte("quota:\n  sandboxes: many\naddr: \":9090\"\n"), 0o644)
+	if _, err := c.Reload(); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Reload of an invalid value = %v, want ErrInvalid", err)
+	}
+	if sandboxes != 3 || c.String("WEBIDE_ADDR") != ":8080" {
+		t.Errorf("refused reload changed the configuration: sandboxes %v, addr %q", sandboxes, c.String("WEBIDE_ADDR"))
+	}
+
+	os.WriteFile(path, []byte("quota:\n  sandboxes: 5\naddr: \":9090\"\n"), 0o644)
+	res, err := c.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sandboxes != 5 {
+		t.Errorf("sandboxes after reload = %v, want 5", sandboxes)
+	}
+	want := []Change{
+		{Key: "WEBIDE_ADDR", Old: ":8080", New: ":9090"},
+		{Key: "WEBIDE_QUOTA_SANDBOXES", Old: "3", New: "5", Live: true},
+	}
+	if !slices.Equal(res.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", res.Changes, want)
+	}
+}
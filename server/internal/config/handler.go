@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Accounts tells the server's administrators, as auth.Service does.
+type Accounts interface {
+	IsAdmin(u *auth.User) bool
+}
+
+// Handler serves the configuration to the server's administrators.
+type Handler struct {
+	cfg      *Config
+	accounts Accounts
+}
+
+// NewHandler returns a Handler for cfg.
+func NewHandler(cfg *Config, accounts Accounts) *Handler {
+	return &Handler{cfg: cfg, accounts: accounts}
+}
+
+// Register mounts the configuration routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/admin/config", h.get)
+	mux.HandleFunc("POST /api/admin/config/reload", h.reload)
+}
+
+func (h *Handler) admin(w http.ResponseWriter, r *http.Request) bool {
+	if !h.accounts.IsAdmin(auth.UserFrom(r.Context())) {
+		httpx.Error(w, http.StatusForbidden, "administrators only")
+		return false
+	}
+	return true
+}
+
+type configResponse struct {
+	File     string    `json:"file"`
+	LoadedAt time.Time `json:"loadedAt"`
+	Settings []Setting `json:"settings"`
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	settings, loadedAt := h.cfg.Settings()
+	httpx.JSON(w, http.StatusOK, configResponse{File: h.cfg.Path(), LoadedAt: loadedAt, Settings: settings})
+}
+
+// reload reads the file again, as SIGHUP does.
+func (h *Handler) reload(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	res, err := h.cfg.Reload()
+	if errors.Is(err, ErrInvalid) {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.Error("config reload", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Action:  audit.ActionConfigReload,
+		Target:  h.cfg.Path(),
+		Details: map[string]string{"changes": strconv.Itoa(len(res.Changes))},
+	})
+	httpx.JSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the YAML a config file is written in: nested mappings,
+// lists of scalars, inline or as "- item" lines, and plain or quoted
+// scalars, with comments. It returns the scalars by their variable name,
+// the path of keys joined with underscores, upper-cased and prefixed with
+// WEBIDE_, and lists joined with commas, as in the environment.
+func parseYAML(data []byte) (map[string]string, error) {
+	out := make(map[string]string)
+	// A frame is a mapping being read: the indent of the key it is the
+	// value of and of its own keys, -1 until the first is read.
+	type frame struct {
+		indent, keys int
+		path         string
+	}
+	stack := []*frame{{indent: -1, keys: -1}}
+	// open is a key without a value on its line, whose mapping or list
+	// follows on the next lines.
+	var open *openKey
+	set := func(path, v string, line int) error {
+		if _, dup := out[path]; dup {
+			return fmt.Errorf("line %d: %s is set twice", line, path)
+		}
+		out[path] = v
+		return nil
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, raw := range lines {
+		n := i + 1
+		line := stripComment(strings.TrimRight(raw, "\r"))
+		text := strings.TrimSpace(line)
+		if text == "" || text == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", n)
+		}
+
+		if text == "-" || strings.HasPrefix(text, "- ") {
+			if open == nil || indent < open.indent {
+				return nil, fmt.Errorf("line %d: list item outside a list", n)
+			}
+			item := strings.TrimSpace(strings.TrimPrefix(text, "-"))
+			if _, _, ok := cutKey(item); ok {
+				return nil, fmt.Errorf("line %d: lists of mappings are not supported", n)
+			}
+			v, err := scalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			open.isList = true
+			open.items = append(open.items, v)
+			continue
+		}
+
+		if open != nil {
+			switch {
+			case open.isList:
+				if err := set(open.path, strings.Join(open.items, ","), n); err != nil {
+					return nil, err
+				}
+			case indent > open.indent:
+				stack = append(stack, &frame{indent: open.indent, keys: -1, path: open.path})
+			default:
+				if err := set(open.path, "", n); err != nil {
+					return nil, err
+				}
+			}
+			open = nil
+		}
+		for stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		switch top := stack[len(stack)-1]; {
+		case top.keys < 0:
+			top.keys = indent
+		case top.keys != indent:
+			return nil, fmt.Errorf("line %d: indentation does not match the keys before it", n)
+		}
+
+		key, value, ok := cutKey(text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key: value", n)
+		}
+		key, err := scalar(key)
+		if err != nil || key == "" {
+			return nil, fmt.Errorf("line %d: invalid key", n)
+		}
+		path := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if parent := stack[len(stack)-1].path; parent != "" {
+			path = parent + "_" + path
+		}
+		switch {
+		case value == "":
+			open = &openKey{path: path, indent: indent}
+			continue
+		case strings.HasPrefix(value, "["):
+			items, err := flowList(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = strings.Join(items, ",")
+		case strings.HasPrefix(value, "{"), strings.HasPrefix(value, "|"), strings.HasPrefix(value, ">"),
+			strings.HasPrefix(value, "&"), strings.HasPrefix(value, "*"):
+			return nil, fmt.Errorf("line %d: inline mappings, block scalars and anchors are not supported", n)
+		default:
+			if value, err = scalar(value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+		if err := set(path, value, n); err != nil {
+			return nil, err
+		}
+	}
+	if open != nil {
+		v := ""
+		if open.isList {
+			v = strings.Join(open.items, ",")
+		}
+		if err := set(open.path, v, len(lines)); err != nil {
+			return nil, err
+		}
+	}
+
+	vars := make(map[string]string, len(out))
+	for k, v := range out {
+		vars["WEBIDE_"+k] = v
+	}
+	return vars, nil
+}
+
+type openKey struct {
+	path   string
+	indent int
+	items  []string
+	isList bool
+}
+
+// cutKey splits "key: value" at the first colon outside quotes that ends
+// the line or is followed by a space.
+func cutKey(s string) (key, value string, ok bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' '):
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripComment drops a comment, from a # outside quotes at the start of
+// the line or after a space.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// scalar returns the value of a plain or quoted scalar; null is "".
+func scalar(s string) (string, error) {
+	switch {
+	case s == "~" || s == "null" || s == "Null" || s == "NULL":
+		return "", nil
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+// flowList returns the items of an inline list, "[a, b]".
+func flowList(s string) ([]string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated list %s", s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" {
+		return nil, nil
+	}
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i <= len(inner); i++ {
+		if i < len(inner) {
+			c := inner[i]
+			if quote != 0 {
+				if c == quote {
+					quote = 0
+				}
+				continue
+			}
+			if c == '"' || c == '\'' {
+				quote = c
+				continue
+			}
+			if c == '[' || c == '{' {
+				return nil, fmt.Errorf("nested collections are not supported")
+			}
+			if c != ',' {
+				continue
+			}
+		}
+		v, err := scalar(strings.TrimSpace(inner[start:i]))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		start = i + 1
+	}
+	return items, nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]string
+	}{
+		{"scalars", "addr: \":8080\"\nlog_level: info\n", map[string]string{"WEBIDE_ADDR": ":8080", "WEBIDE_LOG_LEVEL": "info"}},
+		{"nested", "quota:\n  cpu_seconds: 72000\n  sandboxes: 5\nrun:\n  max:\n    memory_mb: 4096\n  timeout_seconds: 20\naddr: x\n", map[string]string{
+			"WEBIDE_QUOTA_CPU_SECONDS":   "72000",
+			"WEBIDE_QUOTA_SANDBOXES":     "5",
+			"WEBIDE_RUN_MAX_MEMORY_MB":   "4096",
+			"WEBIDE_RUN_TIMEOUT_SECONDS": "20",
+			"WEBIDE_ADDR":                "x",
+		}},
+		{"dashes in keys", "rate-limit:\n  run: 1\n", map[string]string{"WEBIDE_RATE_LIMIT_RUN": "1"}},
+		{"flow list", "admins: [ada@example.com, 'grace, hopper', \"x\"]\n", map[string]string{"WEBIDE_ADMINS": "ada@example.com,grace, hopper,x"}},
+		{"empty flow list", "admins: []\n", map[string]string{"WEBIDE_ADMINS": ""}},
+		{"block list", "admins:\n  - ada\n  - \"grace\"\nlog_level: debug\n", map[string]string{"WEBIDE_ADMINS": "ada,grace", "WEBIDE_LOG_LEVEL": "debug"}},
+		{"block list at key indent", "admins:\n- ada\n- grace\n", map[string]string{"WEBIDE_ADMINS": "ada,grace"}},
+		{"nested list", "cors:\n  origins:\n    - https://a\n    - https://b\n  debug: on\n", map[string]string{"WEBIDE_CORS_ORIGINS": "https://a,https://b", "WEBIDE_CORS_DEBUG": "on"}},
+		{"double quoted", `secret: "a \"b\" #c\tx"` + "\n", map[string]string{"WEBIDE_SECRET": "a \"b\" #c\tx"}},
+		{"single quoted", "secret: 'it''s: #1'\n", map[string]string{"WEBIDE_SECRET": "it's: #1"}},
+		{"colon in value", "addr: http://localhost:8080\n", map[string]string{"WEBIDE_ADDR": "http://localhost:8080"}},
+		{"null", "a: ~\nb: null\nc:\n", map[string]string{"WEBIDE_A": "", "WEBIDE_B": "", "WEBIDE_C": ""}},
+		{"comments", "# header\n---\naddr: x # trailing\nfrag: a#b\n  # indented comment\n\nlevel: y\n", map[string]string{"WEBIDE_ADDR": "x", "WEBIDE_FRAG": "a#b", "WEBIDE_LEVEL": "y"}},
+		{"crlf", "quota:\r\n  sandboxes: 3\r\n", map[string]string{"WEBIDE_QUOTA_SANDBOXES": "3"}},
+	}
+	for _, tt := range tests {
+		got, err := parseYAML([]byte(tt.in))
+		if err != nil {
+			t.Errorf("%s: parseYAML: %v", tt.name, err)
+			continue
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%s: parseYAML = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name, in, err string
+	}{
+		{"tab indent", "quota:\n\tsandboxes: 3\n", "line 2: indent with spaces"},
+		{"indented under scalar", "addr: x\n  port: 1\n", "line 2: indentation"},
+		{"dedent to no level", "quota:\n    sandboxes: 3\n  cpu_seconds: 1\n", "line 3: indentation"},
+		{"misaligned siblings", "run:\n  max:\n    memory_mb: 1\n   cpus: 2\n", "line 4: indentation"},
+		{"list item outside list", "- a\n", "line 1: list item outside a list"},
+		{"list of mappings", "users:\n  - name: ada\n", "line 2: lists of mappings"},
+		{"no colon", "just text\n", "line 1: want key: value"},
+		{"duplicate", "quota:\n  sandboxes: 1\nquota_sandboxes: 2\n", "line 3: QUOTA_SANDBOXES is set twice"},
+		{"unterminated quote", "a: \"x\n", "line 1: invalid quoted string"},
+		{"unterminated list", "a: [x, y\n", "line 1: unterminated list"},
+		{"nested flow list", "a: [[x]]\n", "line 1: nested collections"},
+		{"inline mapping", "a: {b: 1}\n", "line 1: inline mappings"},
+		{"block scalar", "a: |\n  text\n", "line 1: inline mappings, block scalars"},
+		{"anchor", "a: &x 1\n", "line 1: inline mappings, block scalars and anchors"},
+	}
+	for _, tt := range tests {
+		_, err := parseYAML([]byte(tt.in))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: parseYAML error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package debug runs Delve as a Debug Adapter Protocol server inside a
+// workspace's environment and bridges it to the editor over WebSocket.
+//
+// Each editor connection gets its own Delve process. Delve only listens on
+// TCP, so the adapter is started through a small shell bridge that waits
+// for Delve to listen on the sandbox's loopback interface and then relays
+// the connection over the command's stdin and stdout. The debugger port
+// is thus never reachable from other sandboxes, and the same bridge works
+// for containers and local development.
+//
+// Delve implements breakpoints, stepping, variable inspection, and lists
+// goroutines as DAP threads, so the proxy passes messages through as they
+// are. Clients address files under VirtualRoot; paths are rewritten to and
+// from the directory the adapter actually sees.
+package debug
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// VirtualRoot is the workspace root as seen by editor clients.
+const VirtualRoot = "/workspace"
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// DelvePackage is installed with go install when dlv is not on PATH.
+	DelvePackage string
+	// MaxSessions caps concurrent debug sessions per workspace; defaults to 4.
+	MaxSessions int
+}
+
+var (
+	// ErrTooManySessions is returned when a workspace is at Config.MaxSessions.
+	ErrTooManySessions = errors.New("debug: too many debug sessions")
+	// ErrClosed is returned after the Service has been closed.
+	ErrClosed = errors.New("debug: service closed")
+)
+
+// Service starts debug sessions through a Launcher.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	mu       sync.Mutex
+	active   map[string]int
+	sessions map[*Session]struct{}
+	closed   bool
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.DelvePackage == "" {
+		cfg.DelvePackage = "github.com/go-delve/delve/cmd/dlv@v1.23.1"
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = 4
+	}
+	return &Service{cfg: cfg, launcher: l, active: make(map[string]int), sessions: make(map[*Session]struct{})}
+}
+
+// bridgeScript installs Delve if needed, starts it on loopback port $1 and
+// relays its single DAP connection over stdio. bash is required for
+// /dev/tcp. The script ends when Delve exits, and Delve is stopped when
+// stdin closes.
+const bridgeScript = `set -u
+bin="${TMPDIR:-/tmp}/webide-dlv"
+PATH="$bin:$PATH"
+if ! command -v dlv >/dev/null 2>&1; then
+	GOBIN="$bin" GOPATH="${TMPDIR:-/tmp}/webide-dlv-gopath" GOFLAGS= go install "$2" >&2 || exit 1
+fi
+log="${TMPDIR:-/tmp}/webide-dlv-$1.log"
+dlv dap --listen="127.0.0.1:$1" >"$log" 2>&1 &
+pid=$!
+while ! grep -q "listening at" "$log" 2>/dev/null; do
+	kill -0 "$pid" 2>/dev/null || { cat "$log" >&2; exit 1; }
+	sleep 0.1
+done
+exec 3<>"/dev/tcp/127.0.0.1/$1"
+cat <&3 &
+reader=$!
+{ cat >&3; kill "$pid" 2>/dev/null; } <&0 &
+writer=$!
+exec 3>&-
+wait "$pid"
+kill "$reader" "$writer" 2>/dev/null
+rm -f "$log"
+`
+
+// Session is one running debug adapter.
+type Session struct {
+	svc         *Service
+	workspaceID string
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	stdout      *bufio.Reader
+	paths       pathRewriter
+
+	wmu       sync.Mutex
+	closeOnce sync.Once
+}
+
+// Start launches a debug adapter for the workspace at dir.
+func (s *Service) Start(ctx context.Context, workspaceID, dir string) (*Session, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if s.active[workspaceID] >= s.cfg.MaxSessions {
+		s.mu.Unlock()
+		return nil, ErrTooManySessions
+	}
+	s.active[workspaceID]++
+	s.mu.Unlock()
+
+	sess, err := s.start(ctx, workspaceID, dir)
+	s.mu.Lock()
+	if err != nil {
+		s.release(workspaceID)
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.sessions[sess] = struct{}{}
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		sess.Close()
+		return nil, ErrClosed
+	}
+	return sess, nil
+}
+
+func (s *Service) start(ctx context.Context, workspaceID, dir string) (*Session, error) {
+	port := strconv.Itoa(20000 + rand.IntN(40000))
+	argv := []string{"bash", "-c", bridgeScript, "webide-dlv", port, s.cfg.DelvePackage}
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = &logWriter{workspace: workspaceID}
+	cmd.WaitDelay = 3 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("debug: start adapter: %w", err)
+	}
+	return &Session{
+		svc:         s,
+		workspaceID: workspaceID,
+		cmd:         cmd,
+		stdin:       stdin,
+		stdout:      bufio.NewReader(stdout),
+		paths:       pathRewriter{virtual: VirtualRoot, real: s.launcher.Root(dir)},
+	}, nil
+}
+
+func (s *Service) release(workspaceID string) {
+	if s.active[workspaceID]--; s.active[workspaceID] <= 0 {
+		delete(s.active, workspaceID)
+	}
+}
+
+// Close stops every running session.
+func (s *Service) Close() {
+	s.mu.Lock()
+	s.closed = true
+	sessions := make([]*Session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+	for _, sess := range sessions {
+		sess.Close()
+	}
+}
+
+// Send delivers one DAP message from the editor to the adapter.
+func (s *Session) Send(msg []byte) error {
+	msg, err := s.paths.toReal(msg)
+	if err != nil {
+		return err
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	return writeFrame(s.stdin, msg)
+}
+
+// Recv returns the next DAP message from the adapter. It returns io.EOF
+// once the adapter has exited.
+func (s *Session) Recv() ([]byte, error) {
+	msg, err := readFrame(s.stdout)
+	if err != nil {
+		return nil, err
+	}
+	return s.paths.toVirtual(msg)
+}
+
+// Close stops the adapter and the program being debugged.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		// Closing stdin makes the bridge stop Delve, which ends the debuggee.
+		s.stdin.Close()
+		done := make(chan struct{})
+		go func() {
+			s.cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			s.cmd.Process.Kill()
+			<-done
+		}
+		s.svc.mu.Lock()
+		delete(s.svc.sessions, s)
+		s.svc.release(s.workspaceID)
+		s.svc.mu.Unlock()
+	})
+	return nil
+}
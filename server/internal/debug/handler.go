@@ -0,0 +1,90 @@
+package debug
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler bridges debug adapters to WebSocket clients.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler starting sessions with svc.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the debug socket on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /ws/debug/{id}", h.serve)
+}
+
+// serve runs one debug adapter for the lifetime of the socket. Each text
+// frame carries one DAP message in either direction.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	sess, err := h.svc.Start(r.Context(), id, dir)
+	switch {
+	case errors.Is(err, ErrTooManySessions):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	case err != nil:
+		slog.Error("start debug adapter", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not start debugger")
+		return
+	}
+	defer sess.Close()
+
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxMessageBytes)
+
+	go func() {
+		defer sess.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := sess.Send(data); err != nil {
+				slog.Debug("send to debug adapter", "workspace", id, "err", err)
+				return
+			}
+		}
+	}()
+
+	for {
+		msg, err := sess.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Debug("read from debug adapter", "workspace", id, "err", err)
+			}
+			conn.CloseWithCode(ws.CloseNormal, "debug session ended")
+			return
+		}
+		if err := conn.WriteMessage(ws.TextMessage, msg); err != nil {
+			return
+		}
+	}
+}
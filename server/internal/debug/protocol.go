@@ -0,0 +1,117 @@
+package debug
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// maxMessageBytes bounds a single message read from the adapter.
+const maxMessageBytes = 64 << 20
+
+// readFrame reads one Content-Length framed DAP message.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	tp := textproto.NewReader(r)
+	hdr, err := tp.ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(hdr) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("debug: read header: %w", err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(hdr.Get("Content-Length")))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("debug: bad Content-Length %q", hdr.Get("Content-Length"))
+	}
+	if n > maxMessageBytes {
+		return nil, fmt.Errorf("debug: message of %d bytes exceeds limit", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("debug: read body: %w", err)
+	}
+	return buf, nil
+}
+
+// writeFrame writes one Content-Length framed DAP message.
+func writeFrame(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// pathRewriter maps file paths in DAP messages between the client's view
+// of the workspace and the adapter's.
+type pathRewriter struct {
+	virtual string
+	real    string
+}
+
+func (p pathRewriter) toReal(msg []byte) ([]byte, error) {
+	return rewritePaths(msg, p.virtual, p.real)
+}
+
+func (p pathRewriter) toVirtual(msg []byte) ([]byte, error) {
+	return rewritePaths(msg, p.real, p.virtual)
+}
+
+// rewritePaths replaces the prefix from with to in every string value of
+// msg that is from itself or a path below it.
+func rewritePaths(msg []byte, from, to string) ([]byte, error) {
+	if from == to || !bytes.Contains(msg, []byte(from)) {
+		return msg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("debug: invalid message: %w", err)
+	}
+	return json.Marshal(rewriteValue(v, from, to))
+}
+
+func rewriteValue(v any, from, to string) any {
+	switch v := v.(type) {
+	case string:
+		if v == from {
+			return to
+		}
+		if rest, ok := strings.CutPrefix(v, from+"/"); ok {
+			return to + "/" + rest
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = rewriteValue(v[i], from, to)
+		}
+		return v
+	case map[string]any:
+		for k := range v {
+			v[k] = rewriteValue(v[k], from, to)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// logWriter forwards the adapter's stderr to the debug log.
+type logWriter struct {
+	workspace string
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		slog.Debug("debug adapter stderr", "workspace", w.workspace, "line", line)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,440 @@
+// Package devcontainer reads a workspace's devcontainer.json, the file VS
+// Code and Codespaces create development containers from, so that
+// projects configured for them work in the IDE unchanged.
+//
+// The image or Dockerfile it names, with the Features it lists, becomes
+// the workspace's custom image (see Plan); its containerEnv is the
+// environment of the workspace container, its forwarded ports are listed
+// with the workspace's ports, and its lifecycle commands are run when the
+// container is created. Settings that only make sense for a local Docker
+// host, such as mounts, runArgs and Docker Compose, are ignored.
+package devcontainer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned for workspaces without a devcontainer.json.
+	ErrNotFound = errors.New("devcontainer: no devcontainer.json")
+	// ErrInvalid is returned for a devcontainer.json that cannot be
+	// parsed or asks for something unsupported.
+	ErrInvalid = errors.New("devcontainer: invalid devcontainer.json")
+)
+
+// Paths are where a devcontainer.json is looked for, relative to the
+// workspace root, in order.
+var Paths = []string{".devcontainer/devcontainer.json", ".devcontainer.json"}
+
+// maxConfigBytes caps the devcontainer.json read.
+const maxConfigBytes = 256 << 10
+
+// Config is the supported part of a devcontainer.json.
+type Config struct {
+	// Path is the workspace-relative path the configuration was read from.
+	Path  string `json:"-"`
+	Name  string `json:"name,omitempty"`
+	Image string `json:"image,omitempty"`
+	Build *Build `json:"build,omitempty"`
+	// DockerFile and Context are the older spelling of Build's fields.
+	DockerFile string `json:"dockerFile,omitempty"`
+	Context    string `json:"context,omitempty"`
+	// Features are installed on top of the image, in the order listed
+	// unless OverrideFeatureInstallOrder says otherwise.
+	Features                    Features          `json:"features,omitempty"`
+	OverrideFeatureInstallOrder []string          `json:"overrideFeatureInstallOrder,omitempty"`
+	ContainerEnv                map[string]string `json:"containerEnv,omitempty"`
+	// The lifecycle commands run when the container is created, in this
+	// order.
+	OnCreateCommand      *Command `json:"onCreateCommand,omitempty"`
+	UpdateContentCommand *Command `json:"updateContentCommand,omitempty"`
+	PostCreateCommand    *Command `json:"postCreateCommand,omitempty"`
+	ForwardPorts         []Port   `json:"forwardPorts,omitempty"`
+	PortsAttributes      map[string]struct {
+		Label string `json:"label,omitempty"`
+	} `json:"portsAttributes,omitempty"`
+}
+
+// Build is the Dockerfile a devcontainer is built from. Paths are relative
+// to the directory of the devcontainer.json.
+type Build struct {
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+	Target     string            `json:"target,omitempty"`
+}
+
+// Feature is a Feature to install, with its options.
+type Feature struct {
+	// ID is an OCI reference, such as ghcr.io/devcontainers/features/go:1,
+	// or a path starting with "./" to a directory next to the
+	// devcontainer.json.
+	ID      string
+	Options map[string]string
+}
+
+// Features are the Features of a devcontainer.json, in the order listed.
+type Features []Feature
+
+// UnmarshalJSON reads the features object, keeping its order. An option
+// value that is not an object is the version option, as in
+// "ghcr.io/devcontainers/features/go:1": "1.22".
+func (f *Features) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return fmt.Errorf("%w: features must be an object", ErrInvalid)
+	}
+	var out Features
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		id, _ := t.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		feat := Feature{ID: id, Options: map[string]string{}}
+		var opts map[string]any
+		if err := json.Unmarshal(raw, &opts); err != nil {
+			var v any
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			if s := optionString(v); s != "" {
+				feat.Options["version"] = s
+			}
+		}
+		for k, v := range opts {
+			feat.Options[k] = optionString(v)
+		}
+		out = append(out, feat)
+	}
+	*f = out
+	return nil
+}
+
+func optionString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// Command is a lifecycle command: a shell command line, a program and
+// its arguments, or named commands of either kind run in parallel.
+type Command struct {
+	Shell    string
+	Args     []string
+	Parallel map[string]Command
+}
+
+// UnmarshalJSON reads a command in any of its three forms.
+func (c *Command) UnmarshalJSON(data []byte) error {
+	switch {
+	case json.Unmarshal(data, &c.Shell) == nil:
+		return nil
+	case json.Unmarshal(data, &c.Args) == nil:
+		return nil
+	case json.Unmarshal(data, &c.Parallel) == nil:
+		return nil
+	}
+	return fmt.Errorf("%w: a command must be a string, an array or an object", ErrInvalid)
+}
+
+// Script returns the command as a shell script. Parallel commands run in
+// the background, and the script fails when any of them does.
+func (c *Command) Script() string {
+	switch {
+	case c == nil:
+		return ""
+	case c.Shell != "":
+		return c.Shell
+	case len(c.Args) > 0:
+		quoted := make([]string, len(c.Args))
+		for i, a := range c.Args {
+			quoted[i] = shellQuote(a)
+		}
+		return strings.Join(quoted, " ")
+	case len(c.Parallel) > 0:
+		names := make([]string, 0, len(c.Parallel))
+		for name := range c.Parallel {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		var b strings.Builder
+		b.WriteString("pids=\n")
+		for _, name := range names {
+			cmd := c.Parallel[name]
+			fmt.Fprintf(&b, "(%s) &\npids=\"$pids $!\"\n", cmd.Script())
+		}
+		b.WriteString("failed=0\nfor p in $pids; do wait $p || failed=1; done\nexit $failed\n")
+		return b.String()
+	}
+	return ""
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Port is a port a devcontainer forwards. Forwards of another host's
+// ports, such as a Compose service's "db:5432", have Host set.
+type Port struct {
+	Host string
+	Port int
+}
+
+// UnmarshalJSON reads a port number or a "host:port" string.
+func (p *Port) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &p.Port); err == nil {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%w: a forwarded port must be a number or \"host:port\"", ErrInvalid)
+	}
+	host, port, ok := strings.Cut(s, ":")
+	n, err := strconv.Atoi(port)
+	if !ok || err != nil {
+		return fmt.Errorf("%w: bad forwarded port %q", ErrInvalid, s)
+	}
+	if host != "localhost" && host != "127.0.0.1" {
+		p.Host = host
+	}
+	p.Port = n
+	return nil
+}
+
+// Load reads the devcontainer.json of the workspace at root.
+func Load(root string) (*Config, error) {
+	for _, p := range Paths {
+		cfg, err := LoadFile(root, p)
+		if !errors.Is(err, ErrNotFound) {
+			return cfg, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// LoadFile reads the devcontainer.json at the workspace-relative path p
+// of the workspace at root.
+func LoadFile(root, p string) (*Config, error) {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(p)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("devcontainer: read %s: %w", p, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxConfigBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("devcontainer: read %s: %w", p, err)
+	}
+	if len(data) > maxConfigBytes {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrInvalid, p, maxConfigBytes)
+	}
+	var cfg Config
+	if err := json.Unmarshal(standardize(data), &cfg); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, p, err)
+	}
+	cfg.Path = p
+	if cfg.Build == nil && cfg.DockerFile != "" {
+		cfg.Build = &Build{Dockerfile: cfg.DockerFile, Context: cfg.Context}
+	}
+	if cfg.Build != nil && cfg.Build.Dockerfile == "" {
+		cfg.Build = nil
+	}
+	if cfg.Image == "" && cfg.Build == nil {
+		return nil, fmt.Errorf("%w: %s names neither an image nor a Dockerfile; Docker Compose is not supported", ErrInvalid, p)
+	}
+	return &cfg, nil
+}
+
+// standardize turns the JSON with comments and trailing commas that
+// devcontainer.json files are written in into plain JSON.
+func standardize(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for j < len(data) && data[j] != '"' {
+				if data[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(data) {
+				return append(out, data[i:]...)
+			}
+			out = append(out, data[i:j+1]...)
+			i = j
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out = append(out, '\n')
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+			out = append(out, ' ')
+		case c == ']' || c == '}':
+			// Drop a comma before the closing bracket.
+			k := len(out) - 1
+			for k >= 0 && (out[k] == ' ' || out[k] == '\t' || out[k] == '\n' || out[k] == '\r') {
+				k--
+			}
+			if k >= 0 && out[k] == ',' {
+				out = append(out[:k], out[k+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Env returns the containerEnv as "KEY=value", sorted, with the workspace
+// folder variables replaced for a container that mounts the workspace at
+// root. Other variables, such as ${localEnv:HOME}, are about the user's
+// own machine and are replaced with their default or dropped.
+func (c *Config) Env(root string) []string {
+	var env []string
+	for k, v := range c.ContainerEnv {
+		if !envName.MatchString(k) {
+			continue
+		}
+		env = append(env, k+"="+substitute(v, root))
+	}
+	slices.Sort(env)
+	return env
+}
+
+var variable = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+func substitute(s, root string) string {
+	return variable.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2 : len(m)-1]
+		switch {
+		case name == "containerWorkspaceFolder":
+			return root
+		case name == "containerWorkspaceFolderBasename":
+			return path.Base(root)
+		case strings.HasPrefix(name, "containerEnv:"):
+			// Left for the shell of the container to expand.
+			v, _, _ := strings.Cut(strings.TrimPrefix(name, "containerEnv:"), ":")
+			return "${" + v + "}"
+		}
+		parts := strings.SplitN(name, ":", 3)
+		if len(parts) == 3 {
+			return parts[2]
+		}
+		return ""
+	})
+}
+
+// Lifecycle returns the lifecycle commands run when a container is
+// created, as one shell script that stops at the first failing command,
+// or "" when there are none.
+func (c *Config) Lifecycle() string {
+	var b strings.Builder
+	for _, step := range []struct {
+		name string
+		cmd  *Command
+	}{
+		{"onCreateCommand", c.OnCreateCommand},
+		{"updateContentCommand", c.UpdateContentCommand},
+		{"postCreateCommand", c.PostCreateCommand},
+	} {
+		if s := step.cmd.Script(); s != "" {
+			fmt.Fprintf(&b, "echo '==> Running %s'\n(\n%s\n) || exit $?\n", step.name, s)
+		}
+	}
+	return b.String()
+}
+
+// Ports returns the forwarded ports of the container itself, by number,
+// with their labels.
+func (c *Config) Ports() map[int]string {
+	ports := map[int]string{}
+	for _, p := range c.ForwardPorts {
+		if p.Host == "" && p.Port >= 1 && p.Port <= 65535 {
+			ports[p.Port] = c.PortsAttributes[strconv.Itoa(p.Port)].Label
+		}
+	}
+	return ports
+}
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// EnvFor returns an environment picker for terminal.DockerLauncher's
+// EnvFor: the containerEnv of the workspace's devcontainer.json, for a
+// container that mounts the workspace at root.
+func EnvFor(wm Workspaces, root string) func(workspaceID string) []string {
+	return func(workspaceID string) []string {
+		cfg := load(wm, workspaceID)
+		if cfg == nil {
+			return nil
+		}
+		return cfg.Env(root)
+	}
+}
+
+// PortsFor returns a lookup of the ports forwarded by the workspace's
+// devcontainer.json, for ports.Config's Forwarded.
+func PortsFor(wm Workspaces) func(workspaceID string) map[int]string {
+	return func(workspaceID string) map[int]string {
+		cfg := load(wm, workspaceID)
+		if cfg == nil {
+			return nil
+		}
+		return cfg.Ports()
+	}
+}
+
+// load returns the devcontainer.json of a workspace, or nil when it has
+// none or it is invalid, which is logged.
+func load(wm Workspaces, workspaceID string) *Config {
+	dir, err := wm.Open(workspaceID)
+	if err != nil {
+		return nil
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			slog.Warn("devcontainer", "workspace", workspaceID, "err", err)
+		}
+		return nil
+	}
+	return cfg
+}
@@ -0,0 +1,484 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrNotAllowed is returned for Features outside Fetcher.Registries.
+var ErrNotAllowed = errors.New("devcontainer: feature registry not allowed")
+
+// FeaturesContext is the name of the build context holding the Features,
+// for the COPY --from of the Dockerfile Plan writes.
+const FeaturesContext = "webide-features"
+
+// Fetcher downloads Features from OCI registries.
+type Fetcher struct {
+	// Registries are where Features may come from, each a registry host
+	// optionally followed by a repository prefix; defaults to
+	// ghcr.io/devcontainers/features.
+	Registries []string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// MaxBytes caps the download of a Feature; defaults to 32 MiB.
+	MaxBytes int64
+}
+
+// Plan is how to make a devcontainer's image.
+type Plan struct {
+	// Pull is the image to pull, when there is nothing to build.
+	Pull string
+	// Base is the image the build starts from, when it is not built from
+	// a Dockerfile of the workspace, whose content is then in
+	// UserDockerfile.
+	Base           string
+	UserDockerfile []byte
+	// Dockerfile is what to build, in Context, with the Features in the
+	// named context FeaturesContext at Features when there are any.
+	Dockerfile []byte
+	Context    string
+	Features   string
+	Args       map[string]string
+	Target     string
+}
+
+// Plan works out how to make the image of the devcontainer of the
+// workspace at root, downloading its Features into tmp.
+func (c *Config) Plan(ctx context.Context, root, tmp string, f *Fetcher) (*Plan, error) {
+	cfgDir := path.Dir(c.Path)
+	p := &Plan{}
+	var dockerfile strings.Builder
+	if c.Build != nil {
+		file, err := workspacePath(cfgDir, c.Build.Dockerfile)
+		if err != nil {
+			return nil, err
+		}
+		context, err := workspacePath(cfgDir, cmp.Or(c.Build.Context, "."))
+		if err != nil {
+			return nil, err
+		}
+		if file, err = resolve(root, file); err != nil {
+			return nil, err
+		}
+		if p.Context, err = resolve(root, context); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: read %s: %v", ErrInvalid, c.Build.Dockerfile, err)
+		}
+		p.UserDockerfile = data
+		p.Args, p.Target = c.Build.Args, c.Build.Target
+		dockerfile.Write(data)
+		dockerfile.WriteString("\n")
+	} else {
+		if len(c.Features) == 0 {
+			p.Pull = c.Image
+			return p, nil
+		}
+		p.Base, p.Context = c.Image, tmp
+		fmt.Fprintf(&dockerfile, "FROM %s\n", c.Image)
+	}
+	if len(c.Features) == 0 {
+		p.Dockerfile = []byte(dockerfile.String())
+		return p, nil
+	}
+
+	p.Features = filepath.Join(tmp, "features")
+	dockerfile.WriteString("USER root\n")
+	for i, feat := range c.installOrder() {
+		dst := filepath.Join(p.Features, fmt.Sprint(i))
+		if err := os.MkdirAll(dst, 0o755); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(feat.ID, "./") || strings.HasPrefix(feat.ID, "../") {
+			src, err := workspacePath(cfgDir, feat.ID)
+			if err != nil {
+				return nil, err
+			}
+			if src, err = resolve(root, src); err != nil {
+				return nil, err
+			}
+			if err := os.CopyFS(dst, os.DirFS(src)); err != nil {
+				return nil, fmt.Errorf("%w: feature %s: %v", ErrInvalid, feat.ID, err)
+			}
+		} else if err := f.fetch(ctx, feat.ID, dst); err != nil {
+			return nil, err
+		}
+		env, containerEnv, err := featureEnv(dst, feat)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dst, "devcontainer-features.env"), []byte(env), 0o644); err != nil {
+			return nil, err
+		}
+		dir := fmt.Sprintf("/tmp/webide-features/%d", i)
+		fmt.Fprintf(&dockerfile, "COPY --from=%s %d/ %s/\n", FeaturesContext, i, dir)
+		fmt.Fprintf(&dockerfile, "RUN cd %s && set -a && . ./devcontainer-features.env && set +a && chmod +x install.sh && ./install.sh && rm -rf %s\n", dir, dir)
+		for _, e := range containerEnv {
+			fmt.Fprintf(&dockerfile, "ENV %s\n", e)
+		}
+	}
+	p.Dockerfile = []byte(dockerfile.String())
+	return p, nil
+}
+
+// installOrder returns the Features in the order they are installed: those
+// named by OverrideFeatureInstallOrder first, then the rest as listed.
+func (c *Config) installOrder() []Feature {
+	var out []Feature
+	done := map[string]bool{}
+	for _, id := range c.OverrideFeatureInstallOrder {
+		for _, f := range c.Features {
+			if !done[f.ID] && (f.ID == id || featureName(f.ID) == featureName(id)) {
+				out, done[f.ID] = append(out, f), true
+			}
+		}
+	}
+	for _, f := range c.Features {
+		if !done[f.ID] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// featureName returns ref without its tag or digest.
+func featureName(ref string) string {
+	name, _, _ := strings.Cut(ref, "@")
+	if i := strings.LastIndexByte(name, ':'); i > strings.LastIndexByte(name, '/') {
+		name = name[:i]
+	}
+	return name
+}
+
+var optionName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// featureEnv returns the options of the Feature downloaded to dir as the
+// environment file its install.sh reads, and the containerEnv the
+// Feature declares.
+func featureEnv(dir string, feat Feature) (string, []string, error) {
+	var meta struct {
+		ID      string `json:"id"`
+		Options map[string]struct {
+			Default any `json:"default"`
+		} `json:"options"`
+		ContainerEnv map[string]string `json:"containerEnv"`
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "devcontainer-feature.json"))
+	if err == nil {
+		err = json.Unmarshal(standardize(data), &meta)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: feature %s: devcontainer-feature.json: %v", ErrInvalid, feat.ID, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "install.sh")); err != nil {
+		return "", nil, fmt.Errorf("%w: feature %s has no install.sh", ErrInvalid, feat.ID)
+	}
+	values := map[string]string{}
+	for name, opt := range meta.Options {
+		values[name] = optionString(opt.Default)
+	}
+	for name, v := range feat.Options {
+		values[name] = v
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var b strings.Builder
+	for _, name := range names {
+		key := strings.ToUpper(optionName.ReplaceAllString(name, "_"))
+		if key == "" || key[0] >= '0' && key[0] <= '9' {
+			key = "_" + key
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, shellQuote(values[name]))
+	}
+	b.WriteString("_REMOTE_USER=root\n_REMOTE_USER_HOME=/workspace\n_CONTAINER_USER=root\n_CONTAINER_USER_HOME=/workspace\n")
+	var env []string
+	for k, v := range meta.ContainerEnv {
+		if envName.MatchString(k) {
+			env = append(env, fmt.Sprintf("%s=%q", k, v))
+		}
+	}
+	slices.Sort(env)
+	return b.String(), env, nil
+}
+
+// workspacePath joins the path p, relative to the directory dir of the
+// devcontainer.json, and checks that it stays in the workspace.
+func workspacePath(dir, p string) (string, error) {
+	c := path.Clean(path.Join(dir, p))
+	if path.IsAbs(p) || strings.ContainsRune(p, 0) || c == ".." || strings.HasPrefix(c, "../") {
+		return "", fmt.Errorf("%w: path %q leaves the workspace", ErrInvalid, p)
+	}
+	return c, nil
+}
+
+// resolve returns the host path of the workspace-relative path p of the
+// workspace at root, refusing one that symlinks lead out of it, as what
+// it names is read into the image.
+func resolve(root, p string) (string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(p)))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrInvalid, p, err)
+	}
+	if real != realRoot && !strings.HasPrefix(real, realRoot+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: path %q leaves the workspace", ErrInvalid, p)
+	}
+	return real, nil
+}
+
+// Media types of a Feature's manifest and layer.
+const (
+	manifestType = "application/vnd.oci.image.manifest.v1+json"
+	layerType    = "application/vnd.devcontainers.layer.v1+tar"
+)
+
+// layer is a layer of an OCI manifest.
+type layer struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// fetch downloads the Feature ref, such as
+// ghcr.io/devcontainers/features/go:1, and unpacks it into dst.
+func (f *Fetcher) fetch(ctx context.Context, ref, dst string) error {
+	host, repo, tag, err := parseRef(ref)
+	if err != nil {
+		return err
+	}
+	if !f.allowed(host + "/" + repo) {
+		return fmt.Errorf("%w: %s", ErrNotAllowed, host+"/"+repo)
+	}
+	reg := &registry{client: f.client(), host: host, repo: repo}
+	var manifest struct {
+		Layers []layer `json:"layers"`
+	}
+	body, err := reg.get(ctx, "manifests/"+tag, manifestType, 1<<20)
+	if err != nil {
+		return fmt.Errorf("devcontainer: feature %s: %w", ref, err)
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return fmt.Errorf("devcontainer: feature %s: manifest: %w", ref, err)
+	}
+	i := slices.IndexFunc(manifest.Layers, func(l layer) bool { return l.MediaType == layerType })
+	if i < 0 {
+		return fmt.Errorf("%w: %s is not a Feature", ErrInvalid, ref)
+	}
+	l := manifest.Layers[i]
+	if l.Size > f.maxBytes() {
+		return fmt.Errorf("%w: feature %s is larger than %d bytes", ErrInvalid, ref, f.maxBytes())
+	}
+	blob, err := reg.get(ctx, "blobs/"+l.Digest, "", f.maxBytes())
+	if err != nil {
+		return fmt.Errorf("devcontainer: feature %s: %w", ref, err)
+	}
+	sum := sha256.Sum256(blob)
+	if l.Digest != "sha256:"+hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("devcontainer: feature %s: digest mismatch", ref)
+	}
+	return untar(blob, dst)
+}
+
+func (f *Fetcher) allowed(repo string) bool {
+	registries := f.Registries
+	if len(registries) == 0 {
+		registries = []string{"ghcr.io/devcontainers/features"}
+	}
+	for _, r := range registries {
+		r = strings.TrimSuffix(r, "/")
+		if repo == r || strings.HasPrefix(repo, r+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fetcher) client() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	return http.DefaultClient
+}
+
+func (f *Fetcher) maxBytes() int64 {
+	if f.MaxBytes <= 0 {
+		return 32 << 20
+	}
+	return f.MaxBytes
+}
+
+var refPattern = regexp.MustCompile(`^([a-z0-9.-]+(?::[0-9]+)?)/([a-z0-9._/-]+?)(?::([A-Za-z0-9._-]+)|@(sha256:[a-f0-9]{64}))?$`)
+
+// parseRef splits an OCI reference into its registry host, repository
+// and tag or digest, which defaults to "latest".
+func parseRef(ref string) (host, repo, tag string, err error) {
+	m := refPattern.FindStringSubmatch(ref)
+	if m == nil || !strings.Contains(m[1], ".") && !strings.HasPrefix(m[1], "localhost") {
+		return "", "", "", fmt.Errorf("%w: bad feature reference %q", ErrInvalid, ref)
+	}
+	tag = cmp.Or(m[3]+m[4], "latest")
+	return m[1], m[2], tag, nil
+}
+
+// registry makes anonymous requests to an OCI registry, fetching a bearer
+// token when the registry asks for one.
+type registry struct {
+	client     *http.Client
+	host, repo string
+	token      string
+}
+
+func (r *registry) get(ctx context.Context, p, accept string, max int64) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+r.host+"/v2/"+r.repo+"/"+p, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if err := r.authenticate(ctx, challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("registry answered %s", resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > max {
+			return nil, fmt.Errorf("response exceeds %d bytes", max)
+		}
+		return data, nil
+	}
+}
+
+// authenticate fetches an anonymous token as a Bearer challenge asks.
+func (r *registry) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return errors.New("registry requires authentication")
+	}
+	fields := map[string]string{}
+	for _, kv := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		fields[k] = strings.Trim(v, `"`)
+	}
+	realm, err := url.Parse(fields["realm"])
+	if err != nil || realm.Scheme != "https" {
+		return errors.New("registry sent an invalid token realm")
+	}
+	q := realm.Query()
+	if fields["service"] != "" {
+		q.Set("service", fields["service"])
+	}
+	q.Set("scope", cmp.Or(fields["scope"], "repository:"+r.repo+":pull"))
+	realm.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request answered %s", resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return err
+	}
+	r.token = cmp.Or(tok.Token, tok.AccessToken)
+	return nil
+}
+
+// untar unpacks the regular files and directories of a tar archive,
+// gzipped or not, into dst.
+func untar(data []byte, dst string) error {
+	var rd io.Reader = bytes.NewReader(data)
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(rd)
+		if err != nil {
+			return err
+		}
+		rd = gz
+	}
+	tr := tar.NewReader(rd)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("devcontainer: unpack feature: %w", err)
+		}
+		name := path.Clean(strings.TrimPrefix(h.Name, "./"))
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			continue
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(h.Mode)&0o755|0o644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,691 @@
+// Package devserve runs a workspace's Go web server in development mode:
+// the server is rebuilt and restarted whenever a source file changes, and
+// the previews of its port reload once it is back up, much like air does
+// on a developer's machine.
+//
+// Each workspace runs at most one dev server. It is supervised by a small
+// shell script inside the workspace's environment, which builds and starts
+// the server, restarts it when told to on stdin, and stops it when stdin
+// closes, so no process outlives the session even in a container. The
+// server's output and the supervisor's build and exit reports are kept
+// for replay and streamed to subscribers.
+package devserve
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/utf8x"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/watcher"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+}
+
+// Reloader reloads the previews of a workspace port, as *ports.Service
+// does.
+type Reloader interface {
+	// LiveReload turns the reload script of the port's previews on or off.
+	LiveReload(workspaceID string, port int, on bool)
+	// Reload waits for the port to accept connections and reloads its
+	// previews.
+	Reload(ctx context.Context, workspaceID string, port int) error
+}
+
+// Config configures a Service.
+type Config struct {
+	// Debounce is how long after the last file change the server is
+	// rebuilt, so saving several files rebuilds once; defaults to 300ms.
+	Debounce time.Duration
+	// StopTimeout is how long a stopping server is waited for before it
+	// is killed; defaults to 10 seconds.
+	StopTimeout time.Duration
+	// ReplayEvents is how many recent events a new subscriber receives;
+	// defaults to 500.
+	ReplayEvents int
+}
+
+var (
+	// ErrRunning is returned when starting a dev server in a workspace
+	// that already runs one.
+	ErrRunning = errors.New("devserve: a dev server is already running")
+	// ErrNotRunning is returned for workspaces without a dev server.
+	ErrNotRunning = errors.New("devserve: no dev server running")
+	// ErrInvalidOptions is returned for unusable Options.
+	ErrInvalidOptions = errors.New("devserve: invalid options")
+	// ErrClosed is returned after the Service has been closed.
+	ErrClosed = errors.New("devserve: service closed")
+)
+
+// DefaultExtensions are the files whose changes restart a dev server when
+// Options.Extensions is empty: Go sources, module files and templates.
+var DefaultExtensions = []string{".go", ".mod", ".sum", ".html", ".tmpl", ".tpl"}
+
+const (
+	maxArgs = 64
+	// subscriberBuffer is how many events a slow subscriber may lag
+	// behind before it is dropped.
+	subscriberBuffer = 256
+)
+
+var envKeyRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Options describe the server a dev session runs.
+type Options struct {
+	// Package is the main package to build, relative to the workspace
+	// root; defaults to ".".
+	Package string `json:"package,omitempty"`
+	// Args are passed to the server.
+	Args []string `json:"args,omitempty"`
+	// Env is extra environment of the server, as "KEY=value".
+	Env []string `json:"env,omitempty"`
+	// Port is the port the server listens on. Its previews are reloaded
+	// after each restart, and PORT is set to it unless Env sets it.
+	Port int `json:"port,omitempty"`
+	// Extensions are the file name extensions whose changes restart the
+	// server; defaults to DefaultExtensions. Test files never do.
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+func (o *Options) normalize() error {
+	pkg := strings.TrimPrefix(o.Package, "./")
+	if pkg == "" {
+		pkg = "."
+	}
+	if pkg != "." {
+		clean, err := files.Clean(pkg)
+		if err != nil {
+			return fmt.Errorf("%w: package %q", ErrInvalidOptions, o.Package)
+		}
+		pkg = "./" + clean
+	}
+	o.Package = pkg
+	if len(o.Args) > maxArgs {
+		return fmt.Errorf("%w: at most %d arguments", ErrInvalidOptions, maxArgs)
+	}
+	for _, e := range o.Env {
+		k, _, ok := strings.Cut(e, "=")
+		if !ok || !envKeyRE.MatchString(k) {
+			return fmt.Errorf("%w: environment entry %q is not KEY=value", ErrInvalidOptions, e)
+		}
+	}
+	if o.Port < 0 || o.Port > 65535 {
+		return fmt.Errorf("%w: port %d", ErrInvalidOptions, o.Port)
+	}
+	if len(o.Extensions) == 0 {
+		o.Extensions = DefaultExtensions
+	}
+	for _, ext := range o.Extensions {
+		if !strings.HasPrefix(ext, ".") || strings.ContainsAny(ext, "/\\") {
+			return fmt.Errorf("%w: extension %q", ErrInvalidOptions, ext)
+		}
+	}
+	return nil
+}
+
+// State is where a dev server is in its cycle.
+type State string
+
+const (
+	StateBuilding State = "building"
+	StateRunning  State = "running"
+	// StateFailed means the last build failed; the next change retries.
+	StateFailed State = "failed"
+	// StateExited means the server exited on its own; the next change
+	// restarts it.
+	StateExited  State = "exited"
+	StateStopped State = "stopped"
+)
+
+// Status describes a workspace's dev server.
+type Status struct {
+	State     State      `json:"state"`
+	Options   *Options   `json:"options,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	// Builds counts the builds of the session, the first included.
+	Builds   int  `json:"builds,omitempty"`
+	ExitCode *int `json:"exitCode,omitempty"`
+}
+
+// Event is something that happened to a dev server. Output events carry
+// the server's and the build's output in Data; exited events carry
+// ExitCode.
+type Event struct {
+	Type     string    `json:"type"` // output, building, failed, started, exited, reloaded, stopped
+	Time     time.Time `json:"time"`
+	Data     string    `json:"data,omitempty"`
+	ExitCode *int      `json:"exitCode,omitempty"`
+}
+
+// Service runs dev servers through a Launcher.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+	hub      *watcher.Hub
+	reloader Reloader
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	closed   bool
+}
+
+// New returns a Service, filling unset Config fields with defaults. File
+// changes are watched through hub; a nil reloader leaves previews alone.
+func New(cfg Config, l Launcher, hub *watcher.Hub, reloader Reloader) *Service {
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = 300 * time.Millisecond
+	}
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = 10 * time.Second
+	}
+	if cfg.ReplayEvents <= 0 {
+		cfg.ReplayEvents = 500
+	}
+	return &Service{cfg: cfg, launcher: l, hub: hub, reloader: reloader, sessions: make(map[string]*session)}
+}
+
+// superviseScript builds package $1 into a binary named $2 and runs it
+// with the remaining arguments, rebuilding and restarting it for every
+// "restart" line on stdin and stopping it when stdin closes. Build and
+// server output goes to stdout; progress is reported on stderr as
+// "@building", "@failed", "@started" and "@exited <status>" lines.
+const superviseScript = `set -u
+pkg=$1 bin="${TMPDIR:-/tmp}/webide-dev/$2"
+shift 2
+mkdir -p "$(dirname "$bin")"
+pid=
+stop() {
+	[ -n "$pid" ] || return 0
+	kill "$pid" 2>/dev/null
+	wait "$pid" 2>/dev/null
+	pid=
+}
+restart() {
+	stop
+	echo @building >&2
+	if ! go build -o "$bin" "$pkg" 2>&1; then
+		echo @failed >&2
+		return
+	fi
+	(
+		"$bin" "$@" 2>&1 &
+		child=$!
+		trap 'kill "$child" 2>/dev/null; { sleep 5; kill -9 "$child"; } >/dev/null 2>&1 &' TERM
+		while :; do
+			wait "$child" 2>/dev/null
+			status=$?
+			kill -0 "$child" 2>/dev/null || break
+		done
+		echo "@exited $status" >&2
+	) &
+	pid=$!
+	echo @started >&2
+}
+restart "$@"
+while read -r line; do
+	[ "$line" = restart ] && restart "$@"
+done
+stop
+rm -f "$bin"
+`
+
+// session is a running dev server.
+type session struct {
+	svc         *Service
+	workspaceID string
+	opts        Options
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        chan struct{}
+
+	wmu sync.Mutex // serializes writes to stdin
+
+	mu        sync.Mutex
+	status    Status
+	events    []Event
+	subs      map[chan Event]struct{}
+	stopping  bool
+	stopOnce  sync.Once
+	reloadGen int
+}
+
+// Start builds and runs the server described by opts in the workspace at
+// dir, restarting it on every relevant file change until Stop.
+func (s *Service) Start(ctx context.Context, workspaceID, dir string, opts Options) (*Status, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if _, ok := s.sessions[workspaceID]; ok {
+		s.mu.Unlock()
+		return nil, ErrRunning
+	}
+	// Reserve the workspace while the session starts.
+	s.sessions[workspaceID] = nil
+	s.mu.Unlock()
+
+	sess, err := s.start(ctx, workspaceID, dir, opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		delete(s.sessions, workspaceID)
+		return nil, err
+	}
+	select {
+	case <-sess.done:
+		// The supervisor already gave up, say because go is missing.
+		delete(s.sessions, workspaceID)
+	default:
+		s.sessions[workspaceID] = sess
+		if s.closed {
+			go sess.stop()
+		}
+	}
+	st := sess.snapshot()
+	return &st, nil
+}
+
+func (s *Service) start(ctx context.Context, workspaceID, dir string, opts Options) (*session, error) {
+	sub, err := s.hub.Subscribe(workspaceID, dir)
+	if err != nil {
+		return nil, fmt.Errorf("devserve: watch workspace: %w", err)
+	}
+	env := slices.Clone(opts.Env)
+	if opts.Port > 0 && !slices.ContainsFunc(env, func(e string) bool { return strings.HasPrefix(e, "PORT=") }) {
+		env = append(env, "PORT="+strconv.Itoa(opts.Port))
+	}
+	argv := append([]string{"sh", "-c", superviseScript, "webide-dev", opts.Package, workspaceID}, opts.Args...)
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, env)
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	cmd.WaitDelay = 3 * time.Second
+	if err := cmd.Start(); err != nil {
+		sub.Close()
+		return nil, fmt.Errorf("devserve: start supervisor: %w", err)
+	}
+	sctx, cancel := context.WithCancel(context.Background())
+	now := time.Now().UTC()
+	sess := &session{
+		svc:         s,
+		workspaceID: workspaceID,
+		opts:        opts,
+		cmd:         cmd,
+		stdin:       stdin,
+		ctx:         sctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		subs:        make(map[chan Event]struct{}),
+		status:      Status{State: StateBuilding, Options: &opts, StartedAt: &now},
+	}
+	if s.reloader != nil && opts.Port > 0 {
+		s.reloader.LiveReload(workspaceID, opts.Port, true)
+	}
+
+	var pipes sync.WaitGroup
+	pipes.Add(2)
+	go func() {
+		defer pipes.Done()
+		sess.readOutput(stdout)
+	}()
+	go func() {
+		defer pipes.Done()
+		sess.readReports(stderr)
+	}()
+	go sess.watch(sub)
+	go func() {
+		pipes.Wait()
+		err := cmd.Wait()
+		sess.ended(err)
+	}()
+	return sess, nil
+}
+
+// readOutput turns the build's and server's output into output events.
+func (sess *session) readOutput(r io.Reader) {
+	var split utf8x.Splitter
+	buf := make([]byte, 16*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if out := split.Push(buf[:n]); len(out) > 0 {
+				sess.emit(Event{Type: "output", Data: string(out)})
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if out := split.Flush(); len(out) > 0 {
+		sess.emit(Event{Type: "output", Data: string(out)})
+	}
+}
+
+// readReports follows the supervisor's progress reports. Anything else on
+// stderr, such as errors of the container runtime, is passed on as output.
+func (sess *session) readReports(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "@building":
+			sess.setState(StateBuilding, nil, func(st *Status) { st.Builds++ })
+			sess.emit(Event{Type: "building"})
+		case line == "@failed":
+			sess.setState(StateFailed, nil, nil)
+			sess.emit(Event{Type: "failed"})
+		case line == "@started":
+			sess.setState(StateRunning, nil, nil)
+			sess.emit(Event{Type: "started"})
+			sess.reload()
+		case strings.HasPrefix(line, "@exited "):
+			code, err := strconv.Atoi(strings.TrimPrefix(line, "@exited "))
+			if err != nil {
+				code = -1
+			}
+			// A server stopped for a restart is followed by a build.
+			sess.setState(StateExited, &code, nil)
+			sess.emit(Event{Type: "exited", ExitCode: &code})
+		default:
+			sess.emit(Event{Type: "output", Data: line + "\n"})
+		}
+	}
+}
+
+// reload reloads the previews once the restarted server listens. A
+// restart before it does supersedes the wait.
+func (sess *session) reload() {
+	r := sess.svc.reloader
+	if r == nil || sess.opts.Port == 0 {
+		return
+	}
+	sess.mu.Lock()
+	sess.reloadGen++
+	gen := sess.reloadGen
+	sess.mu.Unlock()
+	go func() {
+		if err := r.Reload(sess.ctx, sess.workspaceID, sess.opts.Port); err != nil {
+			return
+		}
+		sess.mu.Lock()
+		current := gen == sess.reloadGen
+		sess.mu.Unlock()
+		if current {
+			sess.emit(Event{Type: "reloaded"})
+		}
+	}()
+}
+
+// watch restarts the server after relevant file changes have settled.
+func (sess *session) watch(sub *watcher.Subscription) {
+	defer sub.Close()
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	for {
+		select {
+		case <-sess.ctx.Done():
+			timer.Stop()
+			return
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if sess.relevant(ev) {
+				timer.Reset(sess.svc.cfg.Debounce)
+			}
+		case <-timer.C:
+			if err := sess.restart(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// relevant reports whether a file change should restart the server.
+func (sess *session) relevant(ev watcher.Event) bool {
+	if ev.IsDir {
+		return false
+	}
+	for _, p := range []string{ev.Path, ev.OldPath} {
+		if p == "" || strings.HasSuffix(p, "_test.go") {
+			continue
+		}
+		if slices.Contains(sess.opts.Extensions, path.Ext(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sess *session) restart() error {
+	sess.wmu.Lock()
+	defer sess.wmu.Unlock()
+	_, err := io.WriteString(sess.stdin, "restart\n")
+	return err
+}
+
+func (sess *session) setState(state State, exitCode *int, update func(*Status)) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.status.State = state
+	sess.status.ExitCode = exitCode
+	if update != nil {
+		update(&sess.status)
+	}
+}
+
+func (sess *session) snapshot() Status {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.status
+}
+
+// emit records ev for replay and sends it to every subscriber, dropping
+// those that fall too far behind.
+func (sess *session) emit(ev Event) {
+	ev.Time = time.Now().UTC()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.events = append(sess.events, ev)
+	if n := len(sess.events) - sess.svc.cfg.ReplayEvents; n > 0 {
+		sess.events = slices.Delete(sess.events, 0, n)
+	}
+	for c := range sess.subs {
+		select {
+		case c <- ev:
+		default:
+			close(c)
+			delete(sess.subs, c)
+		}
+	}
+}
+
+// ended records the end of the supervisor, whether stopped or not.
+func (sess *session) ended(err error) {
+	sess.cancel()
+	s := sess.svc
+	if s.reloader != nil && sess.opts.Port > 0 {
+		s.reloader.LiveReload(sess.workspaceID, sess.opts.Port, false)
+	}
+	s.mu.Lock()
+	if s.sessions[sess.workspaceID] == sess {
+		delete(s.sessions, sess.workspaceID)
+	}
+	s.mu.Unlock()
+
+	sess.mu.Lock()
+	stopping := sess.stopping
+	sess.mu.Unlock()
+	if err != nil && !stopping {
+		slog.Warn("dev server supervisor exited", "workspace", sess.workspaceID, "err", err)
+	}
+	sess.setState(StateStopped, nil, nil)
+	sess.emit(Event{Type: "stopped"})
+	sess.mu.Lock()
+	for c := range sess.subs {
+		close(c)
+		delete(sess.subs, c)
+	}
+	close(sess.done)
+	sess.mu.Unlock()
+}
+
+// stop closes the supervisor's stdin, which stops the server, and kills
+// the supervisor if that takes longer than Config.StopTimeout.
+func (sess *session) stop() {
+	sess.stopOnce.Do(func() {
+		sess.mu.Lock()
+		sess.stopping = true
+		sess.mu.Unlock()
+		sess.wmu.Lock()
+		sess.stdin.Close()
+		sess.wmu.Unlock()
+	})
+	select {
+	case <-sess.done:
+	case <-time.After(sess.svc.cfg.StopTimeout):
+		sess.cmd.Process.Kill()
+		<-sess.done
+	}
+}
+
+// lookup returns the running session of a workspace.
+func (s *Service) lookup(workspaceID string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.sessions[workspaceID]
+	if sess == nil {
+		return nil, ErrNotRunning
+	}
+	return sess, nil
+}
+
+// Status returns the state of a workspace's dev server; StateStopped
+// without options when there is none.
+func (s *Service) Status(workspaceID string) Status {
+	sess, err := s.lookup(workspaceID)
+	if err != nil {
+		return Status{State: StateStopped}
+	}
+	return sess.snapshot()
+}
+
+// Running reports whether a workspace runs a dev server.
+func (s *Service) Running(workspaceID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[workspaceID]
+	return ok
+}
+
+// Restart rebuilds and restarts a workspace's dev server now.
+func (s *Service) Restart(workspaceID string) error {
+	sess, err := s.lookup(workspaceID)
+	if err != nil {
+		return err
+	}
+	if err := sess.restart(); err != nil {
+		return ErrNotRunning
+	}
+	return nil
+}
+
+// Stop stops a workspace's dev server and waits for it to exit.
+func (s *Service) Stop(workspaceID string) error {
+	sess, err := s.lookup(workspaceID)
+	if err != nil {
+		return err
+	}
+	sess.stop()
+	return nil
+}
+
+// Subscribe returns the recent events of a workspace's dev server and a
+// channel of those that follow. The channel is closed when the server
+// stops or the subscriber falls behind; cancel ends the subscription.
+func (s *Service) Subscribe(workspaceID string) (replay []Event, events <-chan Event, cancel func(), err error) {
+	sess, err := s.lookup(workspaceID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c := make(chan Event, subscriberBuffer)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	replay = slices.Clone(sess.events)
+	select {
+	case <-sess.done:
+		close(c)
+		return replay, c, func() {}, nil
+	default:
+	}
+	sess.subs[c] = struct{}{}
+	return replay, c, func() {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		if _, ok := sess.subs[c]; ok {
+			delete(sess.subs, c)
+			close(c)
+		}
+	}, nil
+}
+
+// Close stops every dev server.
+func (s *Service) Close() {
+	s.mu.Lock()
+	s.closed = true
+	var all []*session
+	for _, sess := range s.sessions {
+		if sess != nil {
+			all = append(all, sess)
+		}
+	}
+	s.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, sess := range all {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sess.stop()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,166 @@
+package devserve
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the dev server routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the dev server routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/dev", h.status)
+	mux.HandleFunc("POST /api/workspaces/{id}/dev", h.start)
+	mux.HandleFunc("POST /api/workspaces/{id}/dev/restart", h.restart)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/dev", h.stop)
+	mux.HandleFunc("GET /ws/workspaces/{id}/dev", h.events)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", false
+	}
+	return id, dir, true
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, h.svc.Status(id))
+}
+
+// start runs the dev server described by the Options in the body.
+func (h *Handler) start(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var opts Options
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &opts, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	st, err := h.svc.Start(r.Context(), id, dir, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, st)
+}
+
+func (h *Handler) restart(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Restart(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) stop(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Stop(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// events replays the dev server's recent events and streams the rest, one
+// Event per text frame, until the server stops. Client messages are
+// ignored.
+func (h *Handler) events(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	replay, events, cancel, err := h.svc.Subscribe(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer cancel()
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, ev := range replay {
+		if err := conn.WriteJSON(ev); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case <-gone:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				conn.CloseWithCode(ws.CloseNormal, "dev server stopped")
+				return
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidOptions):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotRunning):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrRunning):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrClosed):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		slog.Error("dev server", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "dev server request failed")
+	}
+}
@@ -0,0 +1,117 @@
+// Package drain takes a server out of service before it shuts down. Once
+// draining starts, the readiness probe fails so load balancers stop
+// sending it traffic, and the requests that would start work the server
+// cannot finish, runs and new sockets, are refused with 503 and a
+// Retry-After header. Requests already being served carry on; the server
+// closes them in its own order as it shuts down.
+package drain
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// DefaultRoutes are the routes that compile or run code, as throttled by
+// ratelimit.DefaultRules.
+var DefaultRoutes = []string{
+	"POST /api/run",
+	"GET /ws/run",
+	"POST /api/builds",
+	"POST /embed/{id}/run",
+	"POST /embed/run",
+	"POST /api/workspaces/{id}/tests",
+	"POST /api/workspaces/{id}/benchmarks",
+	"POST /api/workspaces/{id}/lint",
+	"POST /api/workspaces/{id}/wasm/build",
+}
+
+// Config configures a Drainer.
+type Config struct {
+	// Routes are the http.ServeMux patterns of the routes refused while
+	// draining; defaults to DefaultRoutes. WebSocket upgrades are refused
+	// on every route.
+	Routes []string
+	// ReconnectAfter is how long clients are told to wait before they
+	// retry, by when another server should be taking them; defaults to 5
+	// seconds.
+	ReconnectAfter time.Duration
+}
+
+// ErrDraining is what the readiness check fails with while draining.
+var ErrDraining = errors.New("drain: server is shutting down")
+
+// Drainer tells whether the server is draining.
+type Drainer struct {
+	cfg      Config
+	routes   *http.ServeMux
+	draining atomic.Bool
+}
+
+// New returns a Drainer, filling unset Config fields with defaults. It
+// panics on invalid or conflicting route patterns, like http.ServeMux.
+func New(cfg Config) *Drainer {
+	if cfg.Routes == nil {
+		cfg.Routes = DefaultRoutes
+	}
+	if cfg.ReconnectAfter <= 0 {
+		cfg.ReconnectAfter = 5 * time.Second
+	}
+	d := &Drainer{cfg: cfg, routes: http.NewServeMux()}
+	for _, p := range cfg.Routes {
+		d.routes.Handle(p, http.NotFoundHandler())
+	}
+	return d
+}
+
+// Start begins draining. It cannot be undone.
+func (d *Drainer) Start() { d.draining.Store(true) }
+
+// Draining reports whether Start was called.
+func (d *Drainer) Draining() bool { return d.draining.Load() }
+
+// Check fails with ErrDraining once draining starts, for the readiness
+// probe.
+func (d *Drainer) Check(ctx context.Context) error {
+	if d.Draining() {
+		return ErrDraining
+	}
+	return nil
+}
+
+// ReconnectAfter is how long clients are told to wait before they retry.
+func (d *Drainer) ReconnectAfter() time.Duration { return d.cfg.ReconnectAfter }
+
+// refuses reports whether r is refused while draining.
+func (d *Drainer) refuses(r *http.Request) bool {
+	if ws.IsUpgrade(r) {
+		return true
+	}
+	_, pattern := d.routes.Handler(r)
+	return pattern != ""
+}
+
+// Middleware answers the requests refused while draining with 503 and a
+// Retry-After header, and passes the others to next. Without a Drainer
+// it returns next.
+func Middleware(d *Drainer, next http.Handler) http.Handler {
+	if d == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Draining() && d.refuses(r) {
+			secs := int(math.Ceil(d.cfg.ReconnectAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			httpx.Errorf(w, http.StatusServiceUnavailable, "server is restarting; retry in %d s", secs)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,481 @@
+// Package egress is the network policy of sandboxes. Sandboxes with
+// networking sit on a docker network without a route out, and reach the
+// internet only through the HTTP proxy of this package, which lets them
+// connect to the allowlisted hosts and refuses and logs everything else,
+// so a hosted server is not a free proxy.
+//
+// Every sandbox gets proxy credentials naming its workspace, with which
+// the proxy applies the hosts the workspace adds to the allowlist. A
+// workspace may only add hosts the administrator's policy lets it.
+package egress
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Allow are the hosts every sandbox may connect to; defaults to the
+	// Go module proxy and checksum database and the Python and npm
+	// package registries. See Settings for the syntax.
+	Allow []string
+	// Overridable is the administrator's policy on the hosts a workspace
+	// may add, in the same syntax: "*.example.com" lets workspaces add
+	// api.example.com, and "*" any host. Empty means workspaces cannot
+	// add hosts.
+	Overridable []string
+	// ProxyURL is the URL at which sandboxes reach the proxy, such as
+	// "http://webide:3128".
+	ProxyURL string
+	// NoProxy are hosts sandboxes connect to directly, for NO_PROXY, such
+	// as the host of the server's module proxy. localhost is always
+	// included.
+	NoProxy []string
+	// Key signs the proxy credentials; when empty, a random key is
+	// generated and kept in SettingsDir.
+	Key []byte
+	// SettingsDir stores each workspace's settings; defaults to a
+	// directory under the OS temp dir.
+	SettingsDir string
+	// MaxBlocked caps the blocked destinations remembered per workspace;
+	// defaults to 100.
+	MaxBlocked int
+}
+
+// DefaultAllow is the allowlist of a Config without one.
+var DefaultAllow = []string{
+	"proxy.golang.org", "sum.golang.org",
+	"pypi.org", "files.pythonhosted.org",
+	"registry.npmjs.org",
+}
+
+var (
+	// ErrInvalid is returned for malformed host entries.
+	ErrInvalid = errors.New("egress: invalid host")
+	// ErrNotAllowed is returned for hosts the administrator's policy does
+	// not let workspaces add.
+	ErrNotAllowed = errors.New("egress: host not allowed by policy")
+)
+
+// Settings are the hosts a workspace adds to the allowlist. An entry is a
+// host name or IP address, or "*." and a domain for its subdomains,
+// optionally followed by ":port"; without a port it allows ports 80 and
+// 443.
+type Settings struct {
+	Allow []string `json:"allow"`
+}
+
+// Status is the network policy of a workspace.
+type Status struct {
+	Settings
+	// Default is the allowlist every sandbox has.
+	Default []string `json:"default"`
+	// Overridable is what the workspace may add.
+	Overridable []string `json:"overridable"`
+}
+
+// Blocked is a destination the proxy refused a workspace's sandboxes.
+type Blocked struct {
+	Host     string    `json:"host"`
+	Port     int       `json:"port"`
+	Attempts int       `json:"attempts"`
+	FirstAt  time.Time `json:"firstAt"`
+	LastAt   time.Time `json:"lastAt"`
+
+	logged time.Time
+}
+
+// logInterval spaces the log lines of repeated attempts on a destination.
+const logInterval = time.Minute
+
+// anonymous is the proxy user of runs outside any workspace, which no
+// workspace ID can be.
+const anonymous = "_"
+
+// Service decides which connections sandboxes may make, and is the HTTP
+// proxy that enforces it.
+type Service struct {
+	cfg   Config
+	allow []entry
+	over  []entry
+	now   func() time.Time
+
+	mu       sync.Mutex // guards the settings files and the cache
+	settings map[string][]entry
+
+	bmu     sync.Mutex
+	blocked map[string]map[string]*Blocked
+}
+
+// New returns a Service, filling unset Config fields with defaults.
+func New(cfg Config) (*Service, error) {
+	if cfg.Allow == nil {
+		cfg.Allow = DefaultAllow
+	}
+	if cfg.SettingsDir == "" {
+		cfg.SettingsDir = filepath.Join(os.TempDir(), "webide-egress")
+	}
+	if cfg.MaxBlocked <= 0 {
+		cfg.MaxBlocked = 100
+	}
+	if cfg.ProxyURL != "" {
+		if u, err := url.Parse(cfg.ProxyURL); err != nil || u.Scheme != "http" || u.Host == "" {
+			return nil, fmt.Errorf("egress: proxy URL %q: want http://host:port", cfg.ProxyURL)
+		}
+	}
+	allow, err := parseEntries(cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+	over, err := parseEntries(cfg.Overridable)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.SettingsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("egress: create settings dir: %w", err)
+	}
+	if len(cfg.Key) == 0 {
+		if cfg.Key, err = loadKey(filepath.Join(cfg.SettingsDir, "key")); err != nil {
+			return nil, err
+		}
+	}
+	return &Service{
+		cfg:      cfg,
+		allow:    allow,
+		over:     over,
+		now:      time.Now,
+		settings: make(map[string][]entry),
+		blocked:  make(map[string]map[string]*Blocked),
+	}, nil
+}
+
+// Environ returns the environment that sends the HTTP traffic of a
+// sandbox of workspaceID, "" for runs outside any workspace, through the
+// proxy.
+func (s *Service) Environ(workspaceID string) []string {
+	if s.cfg.ProxyURL == "" {
+		return nil
+	}
+	u, _ := url.Parse(s.cfg.ProxyURL)
+	user := workspaceID
+	if user == "" {
+		user = anonymous
+	}
+	u.User = url.UserPassword(user, s.token(user))
+	proxy := u.String()
+	noProxy := strings.Join(append([]string{"localhost", "127.0.0.1", "::1"}, s.cfg.NoProxy...), ",")
+	return []string{
+		"HTTP_PROXY=" + proxy, "HTTPS_PROXY=" + proxy, "http_proxy=" + proxy, "https_proxy=" + proxy,
+		"NO_PROXY=" + noProxy, "no_proxy=" + noProxy,
+	}
+}
+
+// token is the proxy password of user.
+func (s *Service) token(user string) string {
+	mac := hmac.New(sha256.New, s.cfg.Key)
+	mac.Write([]byte("egress\x00" + user))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Allowed reports whether sandboxes of workspaceID may connect to port on
+// host.
+func (s *Service) Allowed(workspaceID, host string, port int) bool {
+	host = normalizeHost(host)
+	for _, e := range s.allow {
+		if e.matches(host, port) {
+			return true
+		}
+	}
+	if workspaceID == "" {
+		return false
+	}
+	extra, err := s.load(workspaceID)
+	if err != nil {
+		slog.Error("load network settings", "workspace", workspaceID, "err", err)
+		return false
+	}
+	for _, e := range extra {
+		if e.matches(host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the network policy of workspaceID.
+func (s *Service) Status(workspaceID string) (*Status, error) {
+	extra, err := s.load(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Default: slices.Clone(s.cfg.Allow), Overridable: slices.Clone(s.cfg.Overridable)}
+	st.Allow = make([]string, 0, len(extra))
+	for _, e := range extra {
+		st.Allow = append(st.Allow, e.String())
+	}
+	if st.Overridable == nil {
+		st.Overridable = []string{}
+	}
+	return st, nil
+}
+
+// SetSettings replaces the hosts workspaceID adds to the allowlist, each
+// of which the administrator's policy must cover. Running sandboxes see
+// the change on their next connection.
+func (s *Service) SetSettings(workspaceID string, set Settings) (*Status, error) {
+	extra, err := parseEntries(set.Allow)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range extra {
+		if !slices.ContainsFunc(s.over, e.within) {
+			return nil, fmt.Errorf("%w: %s", ErrNotAllowed, e)
+		}
+	}
+	s.mu.Lock()
+	err = s.saveLocked(workspaceID, extra)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.Status(workspaceID)
+}
+
+// Blocked returns the destinations refused to workspaceID's sandboxes
+// since the server started, most recent first.
+func (s *Service) Blocked(workspaceID string) []Blocked {
+	s.bmu.Lock()
+	defer s.bmu.Unlock()
+	out := make([]Blocked, 0, len(s.blocked[workspaceID]))
+	for _, b := range s.blocked[workspaceID] {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastAt.After(out[j].LastAt) })
+	return out
+}
+
+// block records and logs a refused connection. Repeated attempts on a
+// destination are logged at most once per logInterval.
+func (s *Service) block(workspaceID, host string, port int) {
+	now := s.now().UTC()
+	s.bmu.Lock()
+	m := s.blocked[workspaceID]
+	if m == nil {
+		m = make(map[string]*Blocked)
+		s.blocked[workspaceID] = m
+	}
+	key := net.JoinHostPort(host, strconv.Itoa(port))
+	b := m[key]
+	if b == nil {
+		if len(m) >= s.cfg.MaxBlocked {
+			evictOldest(m)
+		}
+		b = &Blocked{Host: host, Port: port, FirstAt: now}
+		m[key] = b
+	}
+	b.Attempts++
+	b.LastAt = now
+	log := now.Sub(b.logged) >= logInterval
+	if log {
+		b.logged = now
+	}
+	attempts := b.Attempts
+	s.bmu.Unlock()
+	if log {
+		slog.Warn("sandbox connection blocked", "workspace", workspaceID, "host", host, "port", port, "attempts", attempts)
+	}
+}
+
+func evictOldest(m map[string]*Blocked) {
+	var oldest string
+	for k, b := range m {
+		if oldest == "" || b.LastAt.Before(m[oldest].LastAt) {
+			oldest = k
+		}
+	}
+	delete(m, oldest)
+}
+
+func (s *Service) load(workspaceID string) ([]entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if extra, ok := s.settings[workspaceID]; ok {
+		return extra, nil
+	}
+	data, err := os.ReadFile(s.settingsPath(workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		s.settings[workspaceID] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("egress: read settings: %w", err)
+	}
+	var set Settings
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("egress: read settings: %w", err)
+	}
+	extra, err := parseEntries(set.Allow)
+	if err != nil {
+		return nil, err
+	}
+	s.settings[workspaceID] = extra
+	return extra, nil
+}
+
+func (s *Service) saveLocked(workspaceID string, extra []entry) error {
+	set := Settings{Allow: make([]string, 0, len(extra))}
+	for _, e := range extra {
+		set.Allow = append(set.Allow, e.String())
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+	p := s.settingsPath(workspaceID)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("egress: write settings: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("egress: write settings: %w", err)
+	}
+	s.settings[workspaceID] = extra
+	return nil
+}
+
+func (s *Service) settingsPath(workspaceID string) string {
+	return filepath.Join(s.cfg.SettingsDir, workspaceID+".json")
+}
+
+// loadKey reads the credential key at p, generating it on first use.
+func loadKey(p string) ([]byte, error) {
+	data, err := os.ReadFile(p)
+	if err == nil && len(data) >= 32 {
+		return data, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("egress: read key: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p, key, 0o600); err != nil {
+		return nil, fmt.Errorf("egress: write key: %w", err)
+	}
+	return key, nil
+}
+
+// entry is a parsed allowlist entry.
+type entry struct {
+	// host is a host name, an IP address, "*.domain" or "*".
+	host string
+	// port is 0 for ports 80 and 443.
+	port int
+}
+
+var hostPattern = regexp.MustCompile(`^(\*|(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*)$`)
+
+func parseEntries(list []string) ([]entry, error) {
+	out := make([]entry, 0, len(list))
+	for _, s := range list {
+		e, err := parseEntry(s)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(out, e) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func parseEntry(s string) (entry, error) {
+	raw := s
+	s = strings.ToLower(strings.TrimSpace(s))
+	var e entry
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		port, err := strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return entry{}, fmt.Errorf("%w: %q", ErrInvalid, raw)
+		}
+		s, e.port = h, port
+	}
+	s = strings.TrimSuffix(s, ".")
+	if ip := net.ParseIP(s); ip != nil {
+		e.host = ip.String()
+		return e, nil
+	}
+	if len(s) > 253 || !hostPattern.MatchString(s) {
+		return entry{}, fmt.Errorf("%w: %q", ErrInvalid, raw)
+	}
+	e.host = s
+	return e, nil
+}
+
+func (e entry) String() string {
+	if e.port == 0 {
+		return e.host
+	}
+	return net.JoinHostPort(e.host, strconv.Itoa(e.port))
+}
+
+// matches reports whether e allows port on the normalized host.
+func (e entry) matches(host string, port int) bool {
+	if e.port == 0 {
+		if port != 80 && port != 443 {
+			return false
+		}
+	} else if port != e.port {
+		return false
+	}
+	return hostWithin(host, e.host)
+}
+
+// within reports whether policy covers all e allows.
+func (e entry) within(policy entry) bool {
+	if policy.port != 0 && policy.port != e.port {
+		return false
+	}
+	return hostWithin(e.host, policy.host)
+}
+
+// hostWithin reports whether the host, or host pattern, h is covered by
+// the pattern p.
+func hostWithin(h, p string) bool {
+	switch {
+	case p == "*":
+		return true
+	case strings.HasPrefix(p, "*."):
+		return h != "*" && strings.HasSuffix(h, p[1:])
+	default:
+		return h == p
+	}
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
@@ -0,0 +1,92 @@
+package egress
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the network policy routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the network policy routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/settings/network", h.settings)
+	mux.HandleFunc("PUT /api/workspaces/{id}/settings/network", h.setSettings)
+	mux.HandleFunc("GET /api/workspaces/{id}/network/blocked", h.blocked)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return id, true
+}
+
+func (h *Handler) settings(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	st, err := h.svc.Status(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, st)
+}
+
+func (h *Handler) setSettings(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var set Settings
+	if err := httpx.DecodeJSON(w, r, &set, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	st, err := h.svc.SetSettings(id, set)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, st)
+}
+
+func (h *Handler) blocked(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"blocked": h.svc.Blocked(id)})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotAllowed):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	default:
+		slog.Error("egress", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "network policy request failed")
+	}
+}
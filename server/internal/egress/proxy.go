@@ -0,0 +1,198 @@
+package egress
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// dialTimeout bounds connecting to a destination.
+const dialTimeout = 10 * time.Second
+
+// errForbiddenAddress is returned when an allowed host resolves to an
+// address of the host itself, such as a cloud metadata service.
+var errForbiddenAddress = errors.New("egress: destination address not allowed")
+
+// dialer connects to destinations, refusing loopback, link-local and
+// unspecified addresses whatever name they were reached by, so the
+// allowlist cannot be turned against the server's own network.
+var dialer = &net.Dialer{
+	Timeout: dialTimeout,
+	Control: func(_, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+			ip.IsUnspecified() || ip.IsMulticast() {
+			return errForbiddenAddress
+		}
+		return nil
+	},
+}
+
+// Proxy returns the HTTP proxy sandboxes connect through: CONNECT
+// tunnels for HTTPS and forwarded plain HTTP requests, checked against
+// the policy of the workspace the credentials name. It is meant to be
+// served on a listener of its own, reachable from the sandbox network
+// only.
+func (s *Service) Proxy() http.Handler {
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = pr.In.URL
+			pr.Out.Host = pr.In.Host
+		},
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: dialTimeout,
+			IdleConnTimeout:     90 * time.Second,
+			MaxIdleConns:        100,
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "proxy: "+dialError(err), http.StatusBadGateway)
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("Proxy-Authenticate", `Basic realm="webide sandbox"`)
+			http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+			return
+		}
+		workspaceID := user
+		if user == anonymous {
+			workspaceID = ""
+		}
+		host, port, ok := target(r)
+		if !ok {
+			http.Error(w, "proxy: bad request target", http.StatusBadRequest)
+			return
+		}
+		if !s.Allowed(workspaceID, host, port) {
+			s.block(workspaceID, normalizeHost(host), port)
+			http.Error(w, "blocked by the sandbox network policy: "+host, http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodConnect {
+			s.tunnel(w, r, net.JoinHostPort(host, strconv.Itoa(port)))
+			return
+		}
+		rp.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the proxy user of r, a workspace ID or anonymous,
+// if its credentials are valid.
+func (s *Service) authenticate(r *http.Request) (string, bool) {
+	enc, ok := strings.CutPrefix(r.Header.Get("Proxy-Authorization"), "Basic ")
+	if !ok {
+		return "", false
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", false
+	}
+	user, pass, ok := strings.Cut(string(raw), ":")
+	if !ok || user == "" || !hmac.Equal([]byte(pass), []byte(s.token(user))) {
+		return "", false
+	}
+	return user, true
+}
+
+// target returns the destination of a proxy request.
+func target(r *http.Request) (string, int, bool) {
+	if r.Method == http.MethodConnect {
+		host, p, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			return "", 0, false
+		}
+		port, err := strconv.Atoi(p)
+		return host, port, err == nil && port > 0 && port < 65536
+	}
+	if r.URL.Scheme != "http" || r.URL.Host == "" {
+		// HTTPS goes through CONNECT; anything else is not a proxy
+		// request.
+		return "", 0, false
+	}
+	host, port := r.URL.Hostname(), 80
+	if p := r.URL.Port(); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > 65535 {
+			return "", 0, false
+		}
+		port = n
+	}
+	return host, port, host != ""
+}
+
+// tunnel connects the client to addr and copies bytes both ways until
+// either side closes.
+func (s *Service) tunnel(w http.ResponseWriter, r *http.Request, addr string) {
+	ctx, cancel := context.WithTimeout(r.Context(), dialTimeout)
+	upstream, err := dialer.DialContext(ctx, "tcp", addr)
+	cancel()
+	if err != nil {
+		http.Error(w, "proxy: "+dialError(err), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	client, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		slog.Error("egress tunnel", "err", err)
+		return
+	}
+	defer client.Close()
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+	done := make(chan struct{}, 2)
+	go func() {
+		// Bytes the client sent after its request may already be
+		// buffered.
+		io.Copy(upstream, io.MultiReader(io.LimitReader(buf, int64(buf.Reader.Buffered())), client))
+		closeWrite(upstream)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		closeWrite(client)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+func closeWrite(c net.Conn) {
+	if tc, ok := c.(*net.TCPConn); ok {
+		tc.CloseWrite()
+		return
+	}
+	c.Close()
+}
+
+// dialError describes a failed connection without the proxy's own
+// addresses.
+func dialError(err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, errForbiddenAddress):
+		return "destination address not allowed"
+	case errors.As(err, &dnsErr):
+		return "unknown host " + dnsErr.Name
+	case errors.Is(err, context.DeadlineExceeded):
+		return "connection timed out"
+	default:
+		return "could not connect"
+	}
+}
@@ -0,0 +1,542 @@
+// Package envvars keeps the environment variables of workspaces and adds
+// them to the processes run there: terminals, runs, tests, debug sessions
+// and dev servers.
+//
+// Variables flagged secret are stored encrypted with AES-256-GCM and are
+// never returned by the API once set; only the processes of the workspace
+// see their values. Variables can also be imported from and exported to
+// the .env format.
+package envvars
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir holds the variables, one file per workspace; defaults to a
+	// directory under the OS temp dir.
+	Dir string
+	// Key encrypts secret values. When empty, a random key is generated
+	// and kept in Dir, which protects secrets in copies of the variable
+	// files but not of the whole directory.
+	Key []byte
+	// MaxVariables limits the variables per workspace; defaults to 200.
+	MaxVariables int
+	// MaxValueBytes limits one value; defaults to 32 KiB.
+	MaxValueBytes int
+}
+
+// Roles reports a user's role on a workspace, "" for none.
+type Roles interface {
+	Role(workspaceID, userID string) (workspace.Role, error)
+}
+
+var (
+	// ErrInvalidName is returned for names that are not valid variable
+	// names.
+	ErrInvalidName = errors.New("envvars: invalid variable name")
+	// ErrNotFound is returned for variables that do not exist.
+	ErrNotFound = errors.New("envvars: variable not found")
+	// ErrInvalidValue is returned for values processes cannot be given.
+	ErrInvalidValue = errors.New("envvars: invalid value")
+	// ErrTooLarge is returned for values over Config.MaxValueBytes.
+	ErrTooLarge = errors.New("envvars: value too large")
+	// ErrTooMany is returned when a workspace would have more than
+	// Config.MaxVariables variables.
+	ErrTooMany = errors.New("envvars: too many variables")
+	// ErrInvalidDotenv is returned for .env data that does not parse.
+	ErrInvalidDotenv = errors.New("envvars: invalid .env data")
+)
+
+var nameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+
+// Variable is a workspace environment variable. The Value of a secret is
+// never returned.
+type Variable struct {
+	Name      string    `json:"name"`
+	Value     string    `json:"value"`
+	Secret    bool      `json:"secret"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+}
+
+// stored is a Variable as kept on disk; secrets only have Sealed.
+type stored struct {
+	Name      string    `json:"name"`
+	Value     string    `json:"value,omitempty"`
+	Sealed    string    `json:"sealed,omitempty"`
+	Secret    bool      `json:"secret,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+}
+
+// Store keeps workspace variables.
+type Store struct {
+	cfg   Config
+	aead  cipher.AEAD
+	roles Roles
+
+	mu   sync.Mutex
+	vars map[string][]stored // workspace ID; loaded on first use, sorted by name
+}
+
+// NewStore returns a Store, filling unset Config fields with defaults.
+// roles, when set, limits RunEnviron to the editors of a workspace.
+func NewStore(cfg Config, roles Roles) (*Store, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-env")
+	}
+	if cfg.MaxVariables <= 0 {
+		cfg.MaxVariables = 200
+	}
+	if cfg.MaxValueBytes <= 0 {
+		cfg.MaxValueBytes = 32 << 10
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("envvars: create dir: %w", err)
+	}
+	key := cfg.Key
+	if len(key) == 0 {
+		var err error
+		if key, err = loadKey(filepath.Join(cfg.Dir, "key")); err != nil {
+			return nil, err
+		}
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{cfg: cfg, aead: aead, roles: roles, vars: make(map[string][]stored)}, nil
+}
+
+// loadKey reads the key at p, generating it on first use.
+func loadKey(p string) ([]byte, error) {
+	data, err := os.ReadFile(p)
+	if err == nil && len(data) >= 32 {
+		return data, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("envvars: read key: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p, key, 0o600); err != nil {
+		return nil, fmt.Errorf("envvars: write key: %w", err)
+	}
+	return key, nil
+}
+
+// seal encrypts a secret value, bound to its workspace and name so a
+// sealed value cannot be moved to another variable.
+func (s *Store) seal(workspaceID, name, value string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ad := []byte(workspaceID + "\x00" + name)
+	return base64.RawStdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(value), ad)), nil
+}
+
+func (s *Store) open(workspaceID string, v stored) (string, error) {
+	data, err := base64.RawStdEncoding.DecodeString(v.Sealed)
+	if err != nil || len(data) < s.aead.NonceSize() {
+		return "", fmt.Errorf("envvars: decrypt %s: malformed value", v.Name)
+	}
+	n := s.aead.NonceSize()
+	plain, err := s.aead.Open(nil, data[:n], data[n:], []byte(workspaceID+"\x00"+v.Name))
+	if err != nil {
+		return "", fmt.Errorf("envvars: decrypt %s: %w", v.Name, err)
+	}
+	return string(plain), nil
+}
+
+func public(v stored) Variable {
+	out := Variable{Name: v.Name, Value: v.Value, Secret: v.Secret, UpdatedAt: v.UpdatedAt, UpdatedBy: v.UpdatedBy}
+	if v.Secret {
+		out.Value = ""
+	}
+	return out
+}
+
+// List returns the variables of a workspace by name, secrets without
+// their values.
+func (s *Store) List(workspaceID string) ([]Variable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vars, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Variable, len(vars))
+	for i, v := range vars {
+		out[i] = public(v)
+	}
+	return out, nil
+}
+
+// Set creates or replaces a variable. The caller in ctx is recorded as
+// having updated it.
+func (s *Store) Set(ctx context.Context, workspaceID, name, value string, secret bool) (*Variable, error) {
+	v, err := s.prepare(ctx, workspaceID, name, value, secret)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vars, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	next, err := s.merge(vars, []stored{v})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeLocked(workspaceID, next); err != nil {
+		return nil, err
+	}
+	out := public(v)
+	return &out, nil
+}
+
+// prepare validates a variable and seals it if it is secret.
+func (s *Store) prepare(ctx context.Context, workspaceID, name, value string, secret bool) (stored, error) {
+	if !nameRE.MatchString(name) {
+		return stored{}, fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	if len(value) > s.cfg.MaxValueBytes {
+		return stored{}, fmt.Errorf("%w: %s is over %d bytes", ErrTooLarge, name, s.cfg.MaxValueBytes)
+	}
+	if strings.IndexByte(value, 0) >= 0 {
+		return stored{}, fmt.Errorf("%w: %s contains a NUL byte", ErrInvalidValue, name)
+	}
+	v := stored{Name: name, Secret: secret, UpdatedAt: time.Now().UTC()}
+	if u := auth.UserFrom(ctx); u != nil {
+		v.UpdatedBy = u.ID
+	}
+	if !secret {
+		v.Value = value
+		return v, nil
+	}
+	sealed, err := s.seal(workspaceID, name, value)
+	if err != nil {
+		return stored{}, err
+	}
+	v.Sealed = sealed
+	return v, nil
+}
+
+// merge returns vars with set replacing the variables of the same names.
+func (s *Store) merge(vars, set []stored) ([]stored, error) {
+	next := slices.Clone(vars)
+	for _, v := range set {
+		if i, ok := slices.BinarySearchFunc(next, v.Name, func(e stored, name string) int { return strings.Compare(e.Name, name) }); ok {
+			next[i] = v
+		} else {
+			next = slices.Insert(next, i, v)
+		}
+	}
+	if len(next) > s.cfg.MaxVariables {
+		return nil, fmt.Errorf("%w: at most %d", ErrTooMany, s.cfg.MaxVariables)
+	}
+	return next, nil
+}
+
+// Delete removes a variable.
+func (s *Store) Delete(workspaceID, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vars, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(vars, func(v stored) bool { return v.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return s.storeLocked(workspaceID, slices.Delete(slices.Clone(vars), i, i+1))
+}
+
+// Import sets the variables of .env data, as secrets if secret is set.
+// Variables that are already secret stay secret. Nothing is changed if
+// any of them is invalid. It returns the number of variables set.
+func (s *Store) Import(ctx context.Context, workspaceID string, data []byte, secret bool) (int, error) {
+	pairs, err := ParseDotenv(data)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vars, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return 0, err
+	}
+	set := make([]stored, 0, len(pairs))
+	for _, p := range pairs {
+		wasSecret := slices.ContainsFunc(vars, func(v stored) bool { return v.Name == p[0] && v.Secret })
+		v, err := s.prepare(ctx, workspaceID, p[0], p[1], secret || wasSecret)
+		if err != nil {
+			return 0, err
+		}
+		set = append(set, v)
+	}
+	next, err := s.merge(vars, set)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.storeLocked(workspaceID, next); err != nil {
+		return 0, err
+	}
+	return len(set), nil
+}
+
+// Export returns the variables of a workspace in the .env format. Secrets
+// are listed in comments without their values.
+func (s *Store) Export(workspaceID string) ([]byte, error) {
+	vars, err := s.List(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, v := range vars {
+		if v.Secret {
+			fmt.Fprintf(&b, "# %s is secret\n", v.Name)
+			continue
+		}
+		b.WriteString(v.Name + "=" + quoteDotenv(v.Value) + "\n")
+	}
+	return b.Bytes(), nil
+}
+
+// Environ returns the variables of a workspace as KEY=value, secrets
+// decrypted, for the processes run in it.
+func (s *Store) Environ(workspaceID string) ([]string, error) {
+	s.mu.Lock()
+	vars, err := s.loadLocked(workspaceID)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(vars) == 0 {
+		return nil, nil
+	}
+	env := make([]string, 0, len(vars))
+	for _, v := range vars {
+		value := v.Value
+		if v.Secret {
+			if value, err = s.open(workspaceID, v); err != nil {
+				return nil, err
+			}
+		}
+		env = append(env, v.Name+"="+value)
+	}
+	return env, nil
+}
+
+// RunEnviron is Environ for requests that name a workspace without being
+// routed to it, such as runs, so their caller's access has not been
+// checked. Users without a role on the workspace get workspace.ErrNotFound
+// and viewers no variables.
+func (s *Store) RunEnviron(ctx context.Context, workspaceID string) ([]string, error) {
+	if s.roles != nil {
+		u := auth.UserFrom(ctx)
+		if u == nil {
+			return nil, workspace.ErrNotFound
+		}
+		role, err := s.roles.Role(workspaceID, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		switch role {
+		case "":
+			return nil, workspace.ErrNotFound
+		case workspace.RoleViewer:
+			return nil, nil
+		}
+	}
+	return s.Environ(workspaceID)
+}
+
+func (s *Store) loadLocked(workspaceID string) ([]stored, error) {
+	if !workspace.ValidID(workspaceID) {
+		return nil, workspace.ErrInvalidID
+	}
+	if v, ok := s.vars[workspaceID]; ok {
+		return v, nil
+	}
+	var vars []stored
+	data, err := os.ReadFile(filepath.Join(s.cfg.Dir, workspaceID+".json"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("envvars: load: %w", err)
+	default:
+		if err := json.Unmarshal(data, &vars); err != nil {
+			return nil, fmt.Errorf("envvars: load: %w", err)
+		}
+	}
+	s.vars[workspaceID] = vars
+	return vars, nil
+}
+
+func (s *Store) storeLocked(workspaceID string, vars []stored) error {
+	data, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.cfg.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("envvars: save: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("envvars: save: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("envvars: save: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.cfg.Dir, workspaceID+".json")); err != nil {
+		return fmt.Errorf("envvars: save: %w", err)
+	}
+	s.vars[workspaceID] = vars
+	return nil
+}
+
+// ParseDotenv parses .env data into name and value pairs, in order. Lines
+// are NAME=value, optionally after "export "; blank lines and lines
+// starting with # are skipped. Values may be in single quotes, taken
+// literally, or double quotes, which understand \n, \t, \" and \\ and may
+// span lines. Unquoted values end at " #", which starts a comment.
+func ParseDotenv(data []byte) ([][2]string, error) {
+	var out [][2]string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(strings.TrimSuffix(sc.Text(), "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !nameRE.MatchString(name) {
+			return nil, fmt.Errorf("%w: line %d is not NAME=value", ErrInvalidDotenv, lineNo)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: line %d: unterminated quote", ErrInvalidDotenv, lineNo)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			// A double-quoted value may continue on the following lines.
+			raw := value[1:]
+			for !closedQuote(raw) {
+				if !sc.Scan() {
+					return nil, fmt.Errorf("%w: line %d: unterminated quote", ErrInvalidDotenv, lineNo)
+				}
+				lineNo++
+				raw += "\n" + strings.TrimSuffix(sc.Text(), "\r")
+			}
+			v, err := unquoteDotenv(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidDotenv, lineNo, err)
+			}
+			value = v
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		out = append(out, [2]string{name, value})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDotenv, err)
+	}
+	return out, nil
+}
+
+// closedQuote reports whether s, the rest of a double-quoted value,
+// contains its closing quote.
+func closedQuote(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return true
+		}
+	}
+	return false
+}
+
+// unquoteDotenv decodes the rest of a double-quoted value up to its
+// closing quote; anything after it must be a comment.
+func unquoteDotenv(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			if rest := strings.TrimSpace(s[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected %q after quoted value", rest)
+			}
+			return b.String(), nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated quote")
+}
+
+// quoteDotenv writes a value so ParseDotenv reads it back unchanged.
+func quoteDotenv(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\r\n\"'#\\") {
+		return v
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(v) + `"`
+}
@@ -0,0 +1,147 @@
+package envvars
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// maxDotenvBytes bounds imported .env data.
+const maxDotenvBytes = 1 << 20
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the environment variable routes.
+type Handler struct {
+	store      *Store
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for store.
+func NewHandler(store *Store, wm Workspaces) *Handler {
+	return &Handler{store: store, workspaces: wm}
+}
+
+// Register mounts the environment variable routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/env", h.list)
+	mux.HandleFunc("PUT /api/workspaces/{id}/env/{name}", h.set)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/env/{name}", h.delete)
+	mux.HandleFunc("GET /api/workspaces/{id}/env/dotenv", h.export)
+	mux.HandleFunc("POST /api/workspaces/{id}/env/dotenv", h.importDotenv)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return id, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	vars, err := h.store.List(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"variables": vars})
+}
+
+type setRequest struct {
+	Value  string `json:"value"`
+	Secret bool   `json:"secret"`
+}
+
+func (h *Handler) set(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var req setRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	v, err := h.store.Set(r.Context(), id, r.PathValue("name"), req.Value, req.Secret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, v)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.Delete(id, r.PathValue("name")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// export returns the variables as a .env file, secrets left out.
+func (h *Handler) export(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	data, err := h.store.Export(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename=".env"`)
+	w.Write(data)
+}
+
+// importDotenv sets the variables of the .env file in the body, as
+// secrets with ?secret=1.
+func (h *Handler) importDotenv(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDotenvBytes))
+	if err != nil {
+		httpx.Errorf(w, http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", maxDotenvBytes)
+		return
+	}
+	n, err := h.store.Import(r.Context(), id, data, r.URL.Query().Get("secret") == "1")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"imported": n})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidValue), errors.Is(err, ErrInvalidDotenv):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("environment variables", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "environment variable request failed")
+	}
+}
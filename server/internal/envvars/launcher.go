@@ -0,0 +1,51 @@
+package envvars
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+)
+
+// launcher adds workspace variables to the commands of another Launcher.
+type launcher struct {
+	store *Store
+	next  terminal.Launcher
+}
+
+// Launcher returns a terminal.Launcher that runs commands with l, with
+// the variables of their workspace added to their environment. The
+// environment callers pass takes precedence, so a tool's own settings
+// cannot be broken by a variable of the same name.
+func Launcher(s *Store, l terminal.Launcher) terminal.Launcher {
+	return &launcher{store: s, next: l}
+}
+
+func (l *launcher) env(workspaceID string, env []string) ([]string, error) {
+	vars, err := l.store.Environ(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return append(vars, env...), nil
+}
+
+// Command implements terminal.Launcher.
+func (l *launcher) Command(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error) {
+	env, err := l.env(workspaceID, env)
+	if err != nil {
+		return nil, err
+	}
+	return l.next.Command(ctx, workspaceID, dir, argv, env)
+}
+
+// Exec implements terminal.Launcher.
+func (l *launcher) Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error) {
+	env, err := l.env(workspaceID, env)
+	if err != nil {
+		return nil, err
+	}
+	return l.next.Exec(ctx, workspaceID, dir, argv, env)
+}
+
+// Root implements terminal.Launcher.
+func (l *launcher) Root(dir string) string { return l.next.Root(dir) }
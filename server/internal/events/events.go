@@ -0,0 +1,142 @@
+// Package events is the server's internal event bus: things that happened
+// in workspaces, such as a run finishing or a file being saved, that other
+// subsystems react to. The webhook package sends them to external tools.
+//
+// Middleware puts the Bus in each request's context, and Publish sends an
+// event from the signed-in user of a context. Without a Bus in the context
+// Publish does nothing, so the code that publishes need not check whether
+// anything listens.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+)
+
+// Types of events.
+const (
+	// RunFinished is published when a program run for a workspace exits
+	// or times out.
+	RunFinished = "run.finished"
+	// TestsFailed is published when a workspace's tests ran and some
+	// failed or did not build.
+	TestsFailed = "tests.failed"
+	// FileSaved is published when a file is written through the file API.
+	FileSaved = "file.saved"
+	// UserJoined is published when a user opens a file for collaborative
+	// editing.
+	UserJoined = "user.joined"
+	// ReviewCommented is published when someone starts or replies to a
+	// review thread.
+	ReviewCommented = "review.commented"
+	// ReviewResolved is published when a review thread is resolved.
+	ReviewResolved = "review.resolved"
+	// JobFailed is published when a scheduled job's run fails or cannot
+	// be run.
+	JobFailed = "job.failed"
+)
+
+// Types lists every type of event.
+var Types = []string{RunFinished, TestsFailed, FileSaved, UserJoined, ReviewCommented, ReviewResolved, JobFailed}
+
+// Event is something that happened in a workspace.
+type Event struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Workspace is where it happened, and User and Email who did it;
+	// empty for events without a signed-in user.
+	Workspace string `json:"workspace"`
+	User      string `json:"user,omitempty"`
+	Email     string `json:"email,omitempty"`
+	// Data depends on the type.
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Bus delivers events to its subscribers.
+type Bus struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	subs map[int]func(Event)
+	next int
+}
+
+// NewBus returns a Bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{now: time.Now, subs: make(map[int]func(Event))}
+}
+
+// Subscribe calls fn with every event published from now on, until cancel
+// is called. fn is called from the publisher's goroutine, so it must not
+// block.
+func (b *Bus) Subscribe(fn func(Event)) (cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.subs[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+// Publish sends e to the subscribers, filling in its ID and time.
+func (b *Bus) Publish(e Event) {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if e.Time.IsZero() {
+		e.Time = b.now().UTC()
+	}
+	b.mu.Lock()
+	subs := make([]func(Event), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.Unlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+}
+
+type busKey struct{}
+
+// Middleware makes b available to Publish in the context of each request.
+// Without a Bus it returns next.
+func Middleware(b *Bus, next http.Handler) http.Handler {
+	if b == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), busKey{}, b)))
+	})
+}
+
+// Publish sends e on the Bus of ctx, as done by the user of ctx.
+func Publish(ctx context.Context, e Event) {
+	b, _ := ctx.Value(busKey{}).(*Bus)
+	if b == nil {
+		return
+	}
+	if u := auth.UserFrom(ctx); u != nil && e.User == "" {
+		e.User, e.Email = u.ID, u.Email
+	}
+	b.Publish(e)
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,446 @@
+// Package flags turns features on for some users before all of them:
+// everyone, named users, the members of organizations, or a percentage of
+// users, so a subsystem can be rolled out gradually and turned off again
+// without a redeploy.
+//
+// Subsystems ask Enabled with the request's context. A flag's rule comes
+// from what administrators set through the admin API, else from the
+// server's defaults, which the configuration provides; a flag with neither
+// is off. A user's place in a percentage rollout is fixed per flag, so
+// raising the percentage only adds users.
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+)
+
+// Rule says whom a flag is on for. It is on for a user when any of its
+// fields says so.
+type Rule struct {
+	// Enabled turns the flag on for everyone.
+	Enabled bool `json:"enabled"`
+	// Percentage turns it on for this share of signed-in users, from 0 to
+	// 100.
+	Percentage float64 `json:"percentage,omitempty"`
+	// Users are user IDs or email addresses.
+	Users []string `json:"users,omitempty"`
+	// Orgs are organization IDs whose members have the flag.
+	Orgs []string `json:"orgs,omitempty"`
+}
+
+// Flag is a flag with what it is for and its rule.
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Rule is the rule in effect: the one set, or the default.
+	Rule
+	// Default is the server's rule; Set reports that administrators set
+	// one over it.
+	Default   Rule       `json:"default"`
+	Set       bool       `json:"set"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+}
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the flags set; defaults to a directory under the OS temp
+	// dir.
+	Dir string
+	// Refresh is how often the file is checked for changes made by other
+	// servers sharing the directory; defaults to 5 seconds.
+	Refresh time.Duration
+}
+
+// Orgs lists the organizations a user belongs to, as org.Service does.
+type Orgs interface {
+	List(userID string) []org.Org
+}
+
+var (
+	// ErrInvalid is returned for invalid names and rules.
+	ErrInvalid = errors.New("flags: invalid flag")
+	// ErrNotFound is returned for flags that are neither known nor set.
+	ErrNotFound = errors.New("flags: flag not found")
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// stored is a rule set through the admin API.
+type stored struct {
+	Rule
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+}
+
+// Service keeps the flags and answers whether they are on.
+type Service struct {
+	cfg  Config
+	orgs Orgs
+	now  func() time.Time
+
+	mu           sync.Mutex
+	descriptions map[string]string
+	defaults     map[string]Rule
+	set          map[string]stored
+	modTime      time.Time
+	checked      time.Time
+}
+
+// New returns a Service, filling unset Config fields with defaults and
+// loading the flags set. orgs may be nil, leaving Rule.Orgs unused.
+func New(cfg Config, orgs Orgs) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-flags")
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = 5 * time.Second
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("flags: create dir: %w", err)
+	}
+	s := &Service{
+		cfg:          cfg,
+		orgs:         orgs,
+		now:          time.Now,
+		descriptions: make(map[string]string),
+		defaults:     make(map[string]Rule),
+		set:          make(map[string]stored),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Define makes a flag known, so that the admin API lists it before it is
+// set. Subsystems define the flags they consult when they are wired.
+func (s *Service) Define(name, description string) {
+	s.mu.Lock()
+	s.descriptions[name] = description
+	s.mu.Unlock()
+}
+
+// SetDefaults replaces the server's rules, which apply to the flags not
+// set through the admin API.
+func (s *Service) SetDefaults(rules map[string]Rule) {
+	s.mu.Lock()
+	s.defaults = rules
+	s.mu.Unlock()
+}
+
+// Enabled reports whether flag name is on for the user in ctx. Without a
+// user, as when authentication is off, only Enabled and a Percentage of
+// 100 count.
+func (s *Service) Enabled(ctx context.Context, name string) bool {
+	on, _ := s.Explain(ctx, name)
+	return on
+}
+
+// Check returns a function reporting whether flag name is on, for
+// subsystems configured with one.
+func (s *Service) Check(name string) func(ctx context.Context) bool {
+	return func(ctx context.Context) bool { return s.Enabled(ctx, name) }
+}
+
+// Explain reports whether flag name is on for the user in ctx, and why.
+func (s *Service) Explain(ctx context.Context, name string) (bool, string) {
+	return s.ExplainFor(auth.UserFrom(ctx), name)
+}
+
+// ExplainFor reports whether flag name is on for u, which may be nil, and
+// why.
+func (s *Service) ExplainFor(u *auth.User, name string) (bool, string) {
+	s.mu.Lock()
+	s.refreshLocked()
+	rule, ok := s.ruleLocked(name)
+	s.mu.Unlock()
+	if !ok {
+		return false, "not set"
+	}
+	return s.evaluate(rule, name, u)
+}
+
+func (s *Service) evaluate(r Rule, name string, u *auth.User) (bool, string) {
+	if r.Enabled {
+		return true, "on for everyone"
+	}
+	if r.Percentage >= 100 {
+		return true, "rolled out to 100%"
+	}
+	if u == nil {
+		return false, "no user"
+	}
+	for _, v := range r.Users {
+		if v == u.ID || strings.EqualFold(v, u.Email) {
+			return true, "user listed"
+		}
+	}
+	if len(r.Orgs) > 0 && s.orgs != nil {
+		for _, o := range s.orgs.List(u.ID) {
+			if slices.Contains(r.Orgs, o.ID) {
+				return true, "member of " + o.ID
+			}
+		}
+	}
+	if r.Percentage > 0 && bucket(name, u.ID) < r.Percentage {
+		return true, "in the " + strconv.FormatFloat(r.Percentage, 'f', -1, 64) + "% rollout"
+	}
+	return false, "not targeted"
+}
+
+// bucket places a user in a flag's rollout, from 0 up to 100.
+func bucket(name, userID string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(name + "\x00" + userID))
+	return float64(h.Sum32()%10000) / 100
+}
+
+// ruleLocked returns the rule of flag name. s.mu must be held.
+func (s *Service) ruleLocked(name string) (Rule, bool) {
+	if st, ok := s.set[name]; ok {
+		return st.Rule, true
+	}
+	r, ok := s.defaults[name]
+	return r, ok
+}
+
+// List returns the flags that are known, set or have a default.
+func (s *Service) List() []Flag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
+	var names []string
+	for _, m := range []map[string]bool{keys(s.descriptions), keys(s.defaults), keys(s.set)} {
+		for k := range m {
+			if !slices.Contains(names, k) {
+				names = append(names, k)
+			}
+		}
+	}
+	slices.Sort(names)
+	out := make([]Flag, 0, len(names))
+	for _, n := range names {
+		out = append(out, s.flagLocked(n))
+	}
+	return out
+}
+
+func keys[V any](m map[string]V) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}
+
+// Get returns flag name.
+func (s *Service) Get(name string) (*Flag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
+	if !s.knownLocked(name) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	f := s.flagLocked(name)
+	return &f, nil
+}
+
+func (s *Service) knownLocked(name string) bool {
+	_, described := s.descriptions[name]
+	_, hasDefault := s.defaults[name]
+	_, set := s.set[name]
+	return described || hasDefault || set
+}
+
+func (s *Service) flagLocked(name string) Flag {
+	f := Flag{Name: name, Description: s.descriptions[name], Default: s.defaults[name]}
+	f.Rule = f.Default
+	if st, ok := s.set[name]; ok {
+		at := st.UpdatedAt
+		f.Rule, f.Set, f.UpdatedAt, f.UpdatedBy = st.Rule, true, &at, st.UpdatedBy
+	}
+	return f
+}
+
+// Put sets the rule of flag name, over its default, as by, the
+// administrator's user ID.
+func (s *Service) Put(name string, r Rule, by string) (*Flag, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("%w: names are up to 64 lower-case letters, digits, dots, dashes and underscores", ErrInvalid)
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
+	prev, had := s.set[name]
+	s.set[name] = stored{Rule: r, UpdatedAt: s.now().UTC(), UpdatedBy: by}
+	if err := s.saveLocked(); err != nil {
+		if had {
+			s.set[name] = prev
+		} else {
+			delete(s.set, name)
+		}
+		return nil, err
+	}
+	f := s.flagLocked(name)
+	return &f, nil
+}
+
+// Delete removes the rule set for flag name, returning it to its default.
+func (s *Service) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
+	prev, ok := s.set[name]
+	if !ok {
+		return fmt.Errorf("%w: %s is not set", ErrNotFound, name)
+	}
+	delete(s.set, name)
+	if err := s.saveLocked(); err != nil {
+		s.set[name] = prev
+		return err
+	}
+	return nil
+}
+
+func (r *Rule) validate() error {
+	switch {
+	case r.Percentage < 0 || r.Percentage > 100:
+		return fmt.Errorf("%w: percentage must be 0 to 100", ErrInvalid)
+	case len(r.Users) > 1000 || len(r.Orgs) > 1000:
+		return fmt.Errorf("%w: at most 1000 users and 1000 organizations", ErrInvalid)
+	}
+	return nil
+}
+
+// ParseDefaults parses the server's default rules, written as
+// "name=on,name=off,name=25%".
+func ParseDefaults(s string) (map[string]Rule, error) {
+	rules := make(map[string]Rule)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(item, "=")
+		name, v = strings.TrimSpace(name), strings.TrimSpace(v)
+		if !ok || !validName.MatchString(name) {
+			return nil, fmt.Errorf("want name=on, name=off or name=N%%, not %q", item)
+		}
+		switch {
+		case v == "on":
+			rules[name] = Rule{Enabled: true}
+		case v == "off":
+			rules[name] = Rule{}
+		case strings.HasSuffix(v, "%"):
+			p, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+			if err != nil || p < 0 || p > 100 {
+				return nil, fmt.Errorf("%s: want a percentage from 0 to 100, not %q", name, v)
+			}
+			rules[name] = Rule{Percentage: p}
+		default:
+			return nil, fmt.Errorf("%s: want on, off or N%%, not %q", name, v)
+		}
+	}
+	return rules, nil
+}
+
+func (s *Service) path() string { return filepath.Join(s.cfg.Dir, "flags.json") }
+
+// refreshLocked reloads the file, at most every Config.Refresh, when
+// another server changed it. s.mu must be held.
+func (s *Service) refreshLocked() {
+	now := s.now()
+	if now.Sub(s.checked) < s.cfg.Refresh {
+		return
+	}
+	s.checked = now
+	if fi, err := os.Stat(s.path()); err == nil && !fi.ModTime().Equal(s.modTime) {
+		if err := s.loadLocked(); err != nil {
+			slog.Warn("flags: keeping the flags loaded before", "err", err)
+		}
+	}
+}
+
+func (s *Service) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked()
+}
+
+func (s *Service) loadLocked() error {
+	data, err := os.ReadFile(s.path())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("flags: read: %w", err)
+	}
+	set := make(map[string]stored)
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("flags: read: %w", err)
+	}
+	s.set = set
+	if fi, err := os.Stat(s.path()); err == nil {
+		s.modTime = fi.ModTime()
+	}
+	return nil
+}
+
+func (s *Service) saveLocked() error {
+	data, err := json.MarshalIndent(s.set, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("flags: write: %w", err)
+	}
+	if err := os.Rename(tmp, s.path()); err != nil {
+		return fmt.Errorf("flags: write: %w", err)
+	}
+	if fi, err := os.Stat(s.path()); err == nil {
+		s.modTime = fi.ModTime()
+	}
+	return nil
+}
+
+// Sandbox runs Exec in on for the users flag name is on for, and in off
+// for the others, to roll out a new sandbox.
+func Sandbox(s *Service, name string, on, off runner.Sandbox) runner.Sandbox {
+	return &sandbox{s: s, name: name, on: on, off: off}
+}
+
+type sandbox struct {
+	s       *Service
+	name    string
+	on, off runner.Sandbox
+}
+
+func (sb *sandbox) Exec(ctx context.Context, spec runner.Spec) (*runner.ExecResult, error) {
+	if sb.s.Enabled(ctx, sb.name) {
+		return sb.on.Exec(ctx, spec)
+	}
+	return sb.off.Exec(ctx, spec)
+}
@@ -0,0 +1,190 @@
+package flags
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+)
+
+// fakeOrgs maps a user ID to the IDs of their organizations.
+type fakeOrgs map[string][]string
+
+func (o fakeOrgs) List(userID string) []org.Org {
+	var out []org.Org
+	for _, id := range o[userID] {
+		out = append(out, org.Org{ID: id})
+	}
+	return out
+}
+
+func users(n int) []*auth.User {
+	us := make([]*auth.User, n)
+	for i := range us {
+		us[i] = &auth.User{ID: fmt.Sprintf("u%d", i)}
+	}
+	return us
+}
+
+// enabled returns the IDs of the users flag name is on for.
+func enabled(s *Service, name string, us []*auth.User) []string {
+	var on []string
+	for _, u := range us {
+		if ok, _ := s.ExplainFor(u, name); ok {
+			on = append(on, u.ID)
+		}
+	}
+	return on
+}
+
+func TestBucket(t *testing.T) {
+	// Users keep their place between releases: these change only if the
+	// hash does, which would move users in and out of every rollout.
+	tests := []struct {
+		flag, user string
+		want       float64
+	}{
+		{"beta", "u1", 34.91},
+		{"beta", "u2", 11.1},
+		{"gamma", "u1", 63.68},
+		{"gamma", "u2", 92.25},
+	}
+	for _, tt := range tests {
+		if got := bucket(tt.flag, tt.user); got != tt.want {
+			t.Errorf("bucket(%q, %q) = %v, want %v", tt.flag, tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(Config{Dir: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	us := users(1000)
+	for _, p := range []float64{0, 100} {
+		if _, err := s.Put("beta", Rule{Percentage: p}, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		if p == 100 {
+			want = len(us)
+		}
+		if got := enabled(s, "beta", us); len(got) != want {
+			t.Errorf("at %v%%, on for %d users, want %d", p, len(got), want)
+		}
+		if ok, _ := s.ExplainFor(nil, "beta"); ok != (p == 100) {
+			t.Errorf("at %v%%, ExplainFor(nil) = %v", p, ok)
+		}
+	}
+
+	// Raising the percentage only adds users, about as many as it says.
+	var prev []string
+	for _, p := range []float64{0.01, 10, 25, 50, 99.99} {
+		if _, err := s.Put("beta", Rule{Percentage: p}, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		on := enabled(s, "beta", us)
+		if lo, hi := p*10-50, p*10+50; float64(len(on)) < lo || float64(len(on)) > hi {
+			t.Errorf("at %v%%, on for %d of %d users", p, len(on), len(us))
+		}
+		for _, id := range prev {
+			if ok, _ := s.ExplainFor(&auth.User{ID: id}, "beta"); !ok {
+				t.Errorf("raising the rollout to %v%% dropped %s", p, id)
+			}
+		}
+		prev = on
+	}
+
+	// Another server, or this one restarted, picks the same users.
+	if _, err := s.Put("beta", Rule{Percentage: 30}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	want := enabled(s, "beta", us)
+	again, err := New(Config{Dir: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := enabled(again, "beta", us); !reflect.DeepEqual(got, want) {
+		t.Errorf("after a restart, on for %d users; want the same %d", len(got), len(want))
+	}
+
+	// Each flag places users apart, so the same users are not always first.
+	if _, err := s.Put("gamma", Rule{Percentage: 30}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(enabled(s, "gamma", us), want) {
+		t.Error("two flags at 30% are on for the same users")
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	s, err := New(Config{Dir: t.TempDir()}, fakeOrgs{"u1": {"acme"}, "u2": {"acme", "globex"}, "u3": {"initech"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// u1 and u2 are in the beta rollout below 35%; u3 is not.
+	rule := Rule{Users: []string{"u1", "Carol@Example.com"}, Orgs: []string{"acme"}, Percentage: 35}
+	s.SetDefaults(map[string]Rule{"beta": rule})
+	tests := []struct {
+		user *auth.User
+		want bool
+		why  string
+	}{
+		{&auth.User{ID: "u1"}, true, "user listed"},
+		{&auth.User{ID: "u2"}, true, "member of acme"},
+		{&auth.User{ID: "u4", Email: "carol@example.com"}, true, "user listed"},
+		{&auth.User{ID: "u3"}, false, "not targeted"},
+		{nil, false, "no user"},
+	}
+	for _, tt := range tests {
+		if on, why := s.ExplainFor(tt.user, "beta"); on != tt.want || why != tt.why {
+			t.Errorf("ExplainFor(%+v) = %v, %q; want %v, %q", tt.user, on, why, tt.want, tt.why)
+		}
+	}
+
+	// u2 is in the rollout too, which says so once no organization does.
+	rule.Orgs = nil
+	s.SetDefaults(map[string]Rule{"beta": rule})
+	if on, why := s.ExplainFor(&auth.User{ID: "u2"}, "beta"); !on || why != "in the 35% rollout" {
+		t.Errorf("without orgs, ExplainFor(u2) = %v, %q", on, why)
+	}
+
+	// Everyone beats all of them, and a rule set beats the default.
+	if on, why := s.ExplainFor(&auth.User{ID: "u3"}, "gamma"); on || why != "not set" {
+		t.Errorf("ExplainFor of an unknown flag = %v, %q", on, why)
+	}
+	if _, err := s.Put("beta", Rule{Enabled: true, Users: []string{"u1"}}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []*auth.User{{ID: "u1"}, {ID: "u3"}, nil} {
+		if on, why := s.ExplainFor(u, "beta"); !on || why != "on for everyone" {
+			t.Errorf("set on, ExplainFor(%+v) = %v, %q", u, on, why)
+		}
+	}
+	if err := s.Delete("beta"); err != nil {
+		t.Fatal(err)
+	}
+	if on, _ := s.ExplainFor(&auth.User{ID: "u3"}, "beta"); on {
+		t.Error("after Delete, the rule set still applies")
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	got, err := ParseDefaults("beta=on, gamma=off,delta=12.5%,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Rule{"beta": {Enabled: true}, "gamma": {}, "delta": {Percentage: 12.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDefaults = %+v, want %+v", got, want)
+	}
+	for _, in := range []string{"beta", "Beta=on", "beta=yes", "beta=101%", "beta=-1%", "beta=x%"} {
+		if _, err := ParseDefaults(in); err == nil {
+			t.Errorf("ParseDefaults(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package flags
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Accounts tells the server's administrators and looks up users, as
+// auth.Service does.
+type Accounts interface {
+	IsAdmin(u *auth.User) bool
+	User(id string) (*auth.User, error)
+}
+
+// Handler serves the flags of the current user, and the flags to the
+// server's administrators.
+type Handler struct {
+	svc      *Service
+	accounts Accounts
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, accounts Accounts) *Handler {
+	return &Handler{svc: svc, accounts: accounts}
+}
+
+// Register mounts the flag routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/flags", h.mine)
+	mux.HandleFunc("GET /api/admin/flags", h.list)
+	mux.HandleFunc("GET /api/admin/flags/{name}", h.get)
+	mux.HandleFunc("PUT /api/admin/flags/{name}", h.put)
+	mux.HandleFunc("DELETE /api/admin/flags/{name}", h.delete)
+	mux.HandleFunc("GET /api/admin/flags/{name}/check", h.check)
+}
+
+func (h *Handler) admin(w http.ResponseWriter, r *http.Request) bool {
+	if !h.accounts.IsAdmin(auth.UserFrom(r.Context())) {
+		httpx.Error(w, http.StatusForbidden, "administrators only")
+		return false
+	}
+	return true
+}
+
+// mine reports which flags are on for the current user, for clients that
+// show or hide features.
+func (h *Handler) mine(w http.ResponseWriter, r *http.Request) {
+	on := make(map[string]bool)
+	for _, f := range h.svc.List() {
+		on[f.Name] = h.svc.Enabled(r.Context(), f.Name)
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"flags": on})
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"flags": h.svc.List()})
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	f, err := h.svc.Get(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, f)
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	var rule Rule
+	if err := httpx.DecodeJSON(w, r, &rule, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := r.PathValue("name")
+	f, err := h.svc.Put(name, rule, auth.UserFrom(r.Context()).ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Action: audit.ActionFlagSet,
+		Target: name,
+		Details: map[string]string{
+			"enabled":    strconv.FormatBool(rule.Enabled),
+			"percentage": strconv.FormatFloat(rule.Percentage, 'f', -1, 64),
+			"users":      strings.Join(rule.Users, ","),
+			"orgs":       strings.Join(rule.Orgs, ","),
+		},
+	})
+	httpx.JSON(w, http.StatusOK, f)
+}
+
+// delete returns a flag to the server's default.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	name := r.PathValue("name")
+	if err := h.svc.Delete(name); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionFlagDelete, Target: name})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type checkResponse struct {
+	Flag    string `json:"flag"`
+	User    string `json:"user,omitempty"`
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+// check reports whether a flag is on for ?user=, an ID, and why; without
+// it, for a request that is not signed in.
+func (h *Handler) check(w http.ResponseWriter, r *http.Request) {
+	if !h.admin(w, r) {
+		return
+	}
+	name := r.PathValue("name")
+	if _, err := h.svc.Get(name); err != nil {
+		writeError(w, err)
+		return
+	}
+	var u *auth.User
+	if id := r.URL.Query().Get("user"); id != "" {
+		var err error
+		u, err = h.accounts.User(id)
+		if errors.Is(err, auth.ErrNotFound) {
+			httpx.Error(w, http.StatusNotFound, "user not found")
+			return
+		}
+		if err != nil {
+			slog.Error("flags: look up user", "err", err)
+			httpx.Error(w, http.StatusInternalServerError, "could not look up the user")
+			return
+		}
+	}
+	on, reason := h.svc.ExplainFor(u, name)
+	res := checkResponse{Flag: name, Enabled: on, Reason: reason}
+	if u != nil {
+		res.User = u.ID
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("flags", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "internal error")
+	}
+}
@@ -0,0 +1,208 @@
+// Package fork copies a workspace into a new one owned by the caller, for
+// template galleries and for students starting from an instructor's
+// project. A fork takes the files an export would, the plain environment
+// variables and how the workspace runs: its Go version, custom image and
+// network allowlist. Secret variables and secrets stay behind, as do its
+// members, history and snapshots.
+package fork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/egress"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/envvars"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/images"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/toolchain"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/archive"
+)
+
+// Config configures a Service. The settings services are optional; a
+// fork leaves out what is not set.
+type Config struct {
+	// Limits bound the files of a fork, as those of an export.
+	Limits archive.Limits
+	// Variables copies the plain environment variables.
+	Variables Variables
+	// Toolchains copies the Go version.
+	Toolchains Toolchains
+	// Images copies the custom image.
+	Images Images
+	// Network copies the network allowlist.
+	Network Network
+}
+
+// Workspaces creates and resolves workspaces, as workspace.Manager does.
+type Workspaces interface {
+	Open(id string) (string, error)
+	Create(ctx context.Context, id string) (string, error)
+	Flush(ctx context.Context, id string) error
+}
+
+// Variables keeps environment variables, as envvars.Store does.
+type Variables interface {
+	List(workspaceID string) ([]envvars.Variable, error)
+	Set(ctx context.Context, workspaceID, name, value string, secret bool) (*envvars.Variable, error)
+}
+
+// Toolchains keeps the Go versions of workspaces, as toolchain.Service
+// does.
+type Toolchains interface {
+	Settings(workspaceID string) (toolchain.Settings, error)
+	SetSettings(workspaceID string, st toolchain.Settings) error
+}
+
+// Images keeps the custom images of workspaces, as images.Service does.
+type Images interface {
+	Status(workspaceID string) (*images.Status, error)
+	SetSettings(workspaceID, dir string, set images.Settings) (*images.Status, error)
+}
+
+// Network keeps the network allowlists of workspaces, as egress.Service
+// does.
+type Network interface {
+	Status(workspaceID string) (*egress.Status, error)
+	SetSettings(workspaceID string, set egress.Settings) (*egress.Status, error)
+}
+
+// ErrNotFound is returned for forks of workspaces that do not exist.
+var ErrNotFound = errors.New("fork: workspace not found")
+
+// Settings a fork copies, as listed in Result.Settings.
+const (
+	SettingGoVersion = "goVersion"
+	SettingImage     = "image"
+	SettingNetwork   = "network"
+)
+
+// Request describes a fork.
+type Request struct {
+	// ID is the new workspace's; a random one when empty.
+	ID string `json:"id,omitempty"`
+	// All copies every file, .git and what .gitignore matches included,
+	// rather than what an export takes by default.
+	All bool `json:"all,omitempty"`
+	// Exclude are more .gitignore-style patterns to leave out.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Result describes a new fork.
+type Result struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Files  int    `json:"files"`
+	Bytes  int64  `json:"bytes"`
+	// Variables counts the variables copied, and Secrets the secret ones
+	// left behind.
+	Variables int `json:"variables"`
+	Secrets   int `json:"secrets"`
+	// Settings are the run settings copied.
+	Settings []string `json:"settings"`
+}
+
+// Service forks workspaces.
+type Service struct {
+	cfg        Config
+	workspaces Workspaces
+}
+
+// New returns a Service forking the workspaces of ws.
+func New(cfg Config, ws Workspaces) *Service {
+	return &Service{cfg: cfg, workspaces: ws}
+}
+
+// Fork copies workspace sourceID into a new workspace, owned by the owner
+// ctx carries (see workspace.WithOwner). It fails with workspace.ErrExists
+// or workspace.ErrInvalidID for unusable IDs and archive.ErrTooLarge for
+// sources beyond Config.Limits. Settings that cannot be copied, such as an
+// image from a registry no longer allowed, are left out.
+func (s *Service) Fork(ctx context.Context, sourceID string, req Request) (*Result, error) {
+	src, err := s.workspaces.Open(sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, sourceID)
+	}
+	ignore := &archive.Ignore{}
+	if !req.All {
+		gitignore, _ := os.ReadFile(filepath.Join(src, ".gitignore"))
+		ignore = archive.ParseIgnore(".git/\n" + string(gitignore))
+	}
+	for _, p := range req.Exclude {
+		ignore.Add(p)
+	}
+	plan, err := archive.NewPlan(src, ignore, s.cfg.Limits)
+	if err != nil {
+		return nil, err
+	}
+	if req.ID == "" {
+		req.ID = workspace.NewID()
+	}
+	dir, err := s.workspaces.Create(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.Copy(dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("fork: copy files: %w", err)
+	}
+	res := &Result{ID: req.ID, Source: sourceID, Files: plan.Files, Bytes: plan.Bytes, Settings: []string{}}
+	s.copyVariables(ctx, sourceID, res)
+	s.copySettings(sourceID, dir, res)
+	if err := s.workspaces.Flush(ctx, req.ID); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Service) copyVariables(ctx context.Context, sourceID string, res *Result) {
+	if s.cfg.Variables == nil {
+		return
+	}
+	vars, err := s.cfg.Variables.List(sourceID)
+	if err != nil {
+		slog.Warn("fork: list variables", "workspace", sourceID, "err", err)
+		return
+	}
+	for _, v := range vars {
+		if v.Secret {
+			res.Secrets++
+			continue
+		}
+		if _, err := s.cfg.Variables.Set(ctx, res.ID, v.Name, v.Value, false); err != nil {
+			slog.Warn("fork: copy variable", "workspace", res.ID, "name", v.Name, "err", err)
+			continue
+		}
+		res.Variables++
+	}
+}
+
+func (s *Service) copySettings(sourceID, dir string, res *Result) {
+	copied := func(setting string, err error) {
+		if err != nil {
+			slog.Warn("fork: copy settings", "workspace", res.ID, "setting", setting, "err", err)
+			return
+		}
+		res.Settings = append(res.Settings, setting)
+	}
+	if s.cfg.Toolchains != nil {
+		if st, err := s.cfg.Toolchains.Settings(sourceID); err == nil && st.GoVersion != "" {
+			copied(SettingGoVersion, s.cfg.Toolchains.SetSettings(res.ID, st))
+		}
+	}
+	if s.cfg.Images != nil {
+		if st, err := s.cfg.Images.Status(sourceID); err == nil && st.Settings != (images.Settings{}) {
+			_, err := s.cfg.Images.SetSettings(res.ID, dir, st.Settings)
+			copied(SettingImage, err)
+		}
+	}
+	if s.cfg.Network != nil {
+		if st, err := s.cfg.Network.Status(sourceID); err == nil && len(st.Allow) > 0 {
+			_, err := s.cfg.Network.SetSettings(res.ID, st.Settings)
+			copied(SettingNetwork, err)
+		}
+	}
+}
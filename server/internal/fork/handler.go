@@ -0,0 +1,90 @@
+package fork
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/access"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/archive"
+)
+
+// Links resolves share links, as access.Service does.
+type Links interface {
+	ResolveLink(token string) (*access.Link, error)
+}
+
+// Handler serves the fork routes.
+type Handler struct {
+	svc   *Service
+	links Links
+}
+
+// NewHandler returns a Handler for svc. links may be nil, for a server
+// without share links.
+func NewHandler(svc *Service, links Links) *Handler {
+	return &Handler{svc: svc, links: links}
+}
+
+// Register mounts the fork routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/workspaces/{id}/fork", h.fork)
+	if h.links != nil {
+		mux.HandleFunc("POST /api/share/{token}/fork", h.forkLink)
+	}
+}
+
+// fork copies a workspace into a new one of the caller's. Its viewers may
+// fork it too. As with POST /api/workspaces, the body is optional.
+func (h *Handler) fork(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, r.PathValue("id"))
+}
+
+// forkLink forks the workspace of a fork share link, for handing out a
+// starter project to people who are not its members.
+func (h *Handler) forkLink(w http.ResponseWriter, r *http.Request) {
+	l, err := h.links.ResolveLink(r.PathValue("token"))
+	if err != nil || l.Access != access.LinkFork {
+		httpx.Error(w, http.StatusNotFound, "share link not found")
+		return
+	}
+	h.serve(w, r, l.Workspace)
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, source string) {
+	var req Request
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	res, err := h.svc.Fork(r.Context(), source, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionWorkspaceFork, Workspace: res.ID, Target: source,
+		Details: map[string]string{"files": strconv.Itoa(res.Files), "variables": strconv.Itoa(res.Variables)}})
+	httpx.JSON(w, http.StatusCreated, res)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+	case errors.Is(err, workspace.ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, workspace.ErrExists):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, archive.ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		slog.Error("fork workspace", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not fork workspace")
+	}
+}
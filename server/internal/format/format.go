@@ -0,0 +1,259 @@
+// Package format formats Go source with gofmt or goimports. gofmt runs
+// in-process; goimports needs the workspace's modules to resolve imports,
+// so it runs inside the workspace's environment.
+package format
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	goformat "go/format"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diff"
+)
+
+// Formatter names a formatting tool.
+type Formatter string
+
+const (
+	Gofmt     Formatter = "gofmt"
+	Goimports Formatter = "goimports"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// SettingsDir stores per-workspace settings; defaults to a directory
+	// under the OS temp dir.
+	SettingsDir string
+	// GoimportsPackage is installed with go install when goimports is not
+	// on PATH.
+	GoimportsPackage string
+	// Timeout bounds a goimports run, including a first-time install;
+	// defaults to 2 minutes.
+	Timeout time.Duration
+}
+
+var (
+	// ErrSyntax is returned for source that does not parse.
+	ErrSyntax = errors.New("format: syntax error")
+	// ErrUnknownFormatter is returned for formatters other than gofmt and
+	// goimports.
+	ErrUnknownFormatter = errors.New("format: unknown formatter")
+	// ErrNoWorkspace is returned when goimports is requested without a
+	// workspace to run in.
+	ErrNoWorkspace = errors.New("format: goimports requires a workspace")
+	// ErrUnavailable is returned when goimports is missing and cannot be
+	// installed.
+	ErrUnavailable = errors.New("format: goimports is not available")
+)
+
+// Settings is a workspace's formatting configuration.
+type Settings struct {
+	Formatter Formatter `json:"formatter"`
+}
+
+// Edit replaces the 1-based lines [StartLine, EndLine) with Text. An edit
+// with EndLine == StartLine inserts before StartLine.
+type Edit struct {
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	Text      string `json:"text"`
+}
+
+// Result is the outcome of formatting.
+type Result struct {
+	Formatter Formatter `json:"formatter"`
+	Formatted string    `json:"formatted"`
+	Changed   bool      `json:"changed"`
+	// Edits turn the input into Formatted, applied in order from the
+	// bottom up or with line numbers adjusted as they are applied.
+	Edits []Edit `json:"edits,omitempty"`
+}
+
+// Service formats source for workspaces.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	mu sync.Mutex
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.SettingsDir == "" {
+		cfg.SettingsDir = filepath.Join(os.TempDir(), "webide-format")
+	}
+	if cfg.GoimportsPackage == "" {
+		cfg.GoimportsPackage = "golang.org/x/tools/cmd/goimports@v0.24.0"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Minute
+	}
+	return &Service{cfg: cfg, launcher: l}
+}
+
+// Source formats src with f. goimports runs in the workspace at dir,
+// resolving imports as if src were the workspace file at file, a cleaned
+// relative path; workspaceID may be empty for gofmt.
+func (s *Service) Source(ctx context.Context, f Formatter, workspaceID, dir, file string, src []byte) (*Result, error) {
+	var out []byte
+	var err error
+	switch f {
+	case Gofmt:
+		out, err = goformat.Source(src)
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrSyntax, err)
+		}
+	case Goimports:
+		if workspaceID == "" {
+			return nil, ErrNoWorkspace
+		}
+		out, err = s.goimports(ctx, workspaceID, dir, file, src)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormatter, f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Formatter: f, Formatted: string(out), Changed: !bytes.Equal(src, out)}, nil
+}
+
+// goimportsScript installs goimports if needed and formats stdin. $1 is
+// the directory imports are resolved from.
+const goimportsScript = `bin="${TMPDIR:-/tmp}/webide-tools"
+PATH="$bin:$PATH"
+if ! command -v goimports >/dev/null 2>&1; then
+	GOBIN="$bin" GOFLAGS= go install "$2" >/dev/null 2>&1 || exit 2
+fi
+exec goimports -srcdir "$1"
+`
+
+func (s *Service) goimports(ctx context.Context, workspaceID, dir, file string, src []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	srcdir := s.launcher.Root(dir)
+	if file != "" {
+		srcdir = path.Join(srcdir, path.Dir(file))
+	}
+	argv := []string{"sh", "-c", goimportsScript, "sh", srcdir, s.cfg.GoimportsPackage}
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(src)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = 3 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("format: start goimports: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("format: goimports: %w", ctx.Err())
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 2:
+		return nil, ErrUnavailable
+	case errors.As(err, &exitErr):
+		msg := strings.TrimSpace(stderr.String())
+		if file != "" {
+			// goimports reports parse errors against "<standard input>".
+			msg = strings.ReplaceAll(msg, "<standard input>", path.Base(file))
+		}
+		return nil, fmt.Errorf("%w: %s", ErrSyntax, msg)
+	case err != nil:
+		return nil, fmt.Errorf("format: goimports: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Edits returns the line edits turning oldText into newText.
+func Edits(oldText, newText string) []Edit {
+	lines := diff.SplitLines(newText)
+	edits := []Edit{}
+	for _, h := range diff.Lines(oldText, newText, 0) {
+		e := Edit{StartLine: h.OldStart, EndLine: h.OldStart + h.OldLines}
+		if h.OldLines == 0 {
+			e.StartLine++
+			e.EndLine++
+		}
+		start := h.NewStart - 1
+		if h.NewLines == 0 {
+			start++
+		}
+		e.Text = strings.Join(lines[start:start+h.NewLines], "")
+		edits = append(edits, e)
+	}
+	return edits
+}
+
+// Settings returns the workspace's formatting configuration; gofmt is the
+// default.
+func (s *Service) Settings(workspaceID string) (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.settingsPath(workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return Settings{Formatter: Gofmt}, nil
+	}
+	if err != nil {
+		return Settings{}, fmt.Errorf("format: read settings: %w", err)
+	}
+	var st Settings
+	if err := json.Unmarshal(data, &st); err != nil {
+		return Settings{}, fmt.Errorf("format: read settings: %w", err)
+	}
+	if st.Formatter == "" {
+		st.Formatter = Gofmt
+	}
+	return st, nil
+}
+
+// SetSettings stores the workspace's formatting configuration.
+func (s *Service) SetSettings(workspaceID string, st Settings) error {
+	if st.Formatter != Gofmt && st.Formatter != Goimports {
+		return fmt.Errorf("%w: %q", ErrUnknownFormatter, st.Formatter)
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.cfg.SettingsDir, 0o755); err != nil {
+		return fmt.Errorf("format: write settings: %w", err)
+	}
+	p := s.settingsPath(workspaceID)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("format: write settings: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("format: write settings: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) settingsPath(workspaceID string) string {
+	return filepath.Join(s.cfg.SettingsDir, workspaceID+".json")
+}
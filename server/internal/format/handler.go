@@ -0,0 +1,192 @@
+package format
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the formatting API.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler formatting with svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the formatting routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/format", h.format)
+	mux.HandleFunc("POST /api/workspaces/{id}/format/{path...}", h.formatFile)
+	mux.HandleFunc("GET /api/workspaces/{id}/settings/format", h.settings)
+	mux.HandleFunc("PUT /api/workspaces/{id}/settings/format", h.setSettings)
+}
+
+type formatRequest struct {
+	Source string `json:"source"`
+	// Formatter defaults to the workspace's setting, or gofmt.
+	Formatter Formatter `json:"formatter,omitempty"`
+	// Workspace and Path give goimports the context to resolve imports in.
+	Workspace string `json:"workspace,omitempty"`
+	Path      string `json:"path,omitempty"`
+	// Edits asks for an edit list in addition to the formatted text.
+	Edits bool `json:"edits,omitempty"`
+}
+
+// format formats the submitted source.
+func (h *Handler) format(w http.ResponseWriter, r *http.Request) {
+	var req formatRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var dir, file string
+	if req.Workspace != "" {
+		var err error
+		if dir, err = h.workspaces.Open(req.Workspace); err != nil {
+			httpx.Error(w, http.StatusNotFound, "workspace not found")
+			return
+		}
+		if req.Path != "" {
+			if file, err = files.Clean(req.Path); err != nil {
+				httpx.Error(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	}
+	f, ok := h.formatter(w, req.Formatter, req.Workspace)
+	if !ok {
+		return
+	}
+	res, err := h.svc.Source(r.Context(), f, req.Workspace, dir, file, []byte(req.Source))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Edits {
+		res.Edits = Edits(req.Source, res.Formatted)
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// formatFile formats a workspace file in place with the workspace's
+// formatter, as on save, and returns the edits made so an open editor can
+// apply them to its buffer.
+func (h *Handler) formatFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	fsys, err := files.New(dir)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	file, err := files.Clean(r.PathValue("path"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	src, err := fsys.ReadFile(file)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	f, ok := h.formatter(w, Formatter(r.URL.Query().Get("formatter")), id)
+	if !ok {
+		return
+	}
+	res, err := h.svc.Source(r.Context(), f, id, dir, file, src)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if res.Changed {
+		if _, err := fsys.WriteFile(file, []byte(res.Formatted)); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	res.Edits = Edits(string(src), res.Formatted)
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// formatter resolves the formatter to use, falling back to the
+// workspace's setting.
+func (h *Handler) formatter(w http.ResponseWriter, requested Formatter, workspaceID string) (Formatter, bool) {
+	if requested != "" || workspaceID == "" {
+		if requested == "" {
+			requested = Gofmt
+		}
+		return requested, true
+	}
+	st, err := h.svc.Settings(workspaceID)
+	if err != nil {
+		writeError(w, err)
+		return "", false
+	}
+	return st.Formatter, true
+}
+
+func (h *Handler) settings(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	st, err := h.svc.Settings(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, st)
+}
+
+func (h *Handler) setSettings(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	var st Settings
+	if err := httpx.DecodeJSON(w, r, &st, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.svc.SetSettings(id, st); err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, st)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrSyntax):
+		httpx.Error(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, ErrUnknownFormatter), errors.Is(err, ErrNoWorkspace),
+		errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		httpx.Error(w, http.StatusNotFound, "no such file")
+	case errors.Is(err, ErrUnavailable):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		slog.Error("format failed", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "format failed")
+	}
+}
@@ -0,0 +1,187 @@
+// Package gallery serves the curated starter examples kept in the
+// repository's examples/ directory. The directory's gallery.json lists
+// each example with its title, description, tags and files; the manifest
+// is read on every call, so examples can be added without a restart.
+package gallery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Config configures a Gallery.
+type Config struct {
+	// Dir is the examples directory; defaults to ../examples, which is
+	// where it sits relative to the server module.
+	Dir string
+}
+
+var (
+	// ErrNotFound is returned for unknown example IDs.
+	ErrNotFound = errors.New("gallery: example not found")
+	// ErrInvalidManifest is returned when gallery.json is malformed.
+	ErrInvalidManifest = errors.New("gallery: invalid manifest")
+)
+
+// Example describes one starter.
+type Example struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// Files are the paths the example's files get in a workspace.
+	Files []string `json:"files"`
+}
+
+// File is one file of an example with its contents.
+type File struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// manifest is the format of gallery.json. Each file maps a path in the
+// workspace to a source file relative to the examples directory.
+type manifest struct {
+	Examples []struct {
+		Example
+		Files []struct {
+			Path   string `json:"path"`
+			Source string `json:"source"`
+		} `json:"files"`
+	} `json:"examples"`
+}
+
+// entry is a validated manifest example.
+type entry struct {
+	Example
+	sources []string
+}
+
+// Gallery reads examples from a directory.
+type Gallery struct {
+	cfg Config
+}
+
+// New returns a Gallery, filling unset Config fields with defaults.
+func New(cfg Config) *Gallery {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join("..", "examples")
+	}
+	return &Gallery{cfg: cfg}
+}
+
+// List returns the examples in manifest order, only those tagged tag when
+// it is not empty.
+func (g *Gallery) List(tag string) ([]Example, error) {
+	entries, err := g.load()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Example, 0, len(entries))
+	for _, e := range entries {
+		if tag == "" || slices.Contains(e.Tags, tag) {
+			out = append(out, e.Example)
+		}
+	}
+	return out, nil
+}
+
+// Get returns an example and the files a workspace created from it gets.
+// Go examples without a go.mod get one named after the example, so the
+// workspace's tools treat it as a module.
+func (g *Gallery) Get(id string) (*Example, []File, error) {
+	entries, err := g.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	i := slices.IndexFunc(entries, func(e entry) bool { return e.ID == id })
+	if i < 0 {
+		return nil, nil, ErrNotFound
+	}
+	e := entries[i]
+	files := make([]File, 0, len(e.Files)+1)
+	for j, p := range e.Files {
+		data, err := os.ReadFile(filepath.Join(g.cfg.Dir, filepath.FromSlash(e.sources[j])))
+		if err != nil {
+			return nil, nil, fmt.Errorf("gallery: read %s: %w", e.sources[j], err)
+		}
+		files = append(files, File{Path: p, Content: string(data)})
+	}
+	if e.Language == "go" && !slices.Contains(e.Files, "go.mod") {
+		files = append(files, File{Path: "go.mod", Content: "module example.com/" + e.ID + "\n\ngo 1.22\n"})
+	}
+	return &e.Example, files, nil
+}
+
+// Copy writes the files of example id into dir.
+func (g *Gallery) Copy(id, dir string) (*Example, error) {
+	ex, files, err := g.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		dst := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return nil, fmt.Errorf("gallery: copy %s: %w", f.Path, err)
+		}
+		if err := os.WriteFile(dst, []byte(f.Content), 0o644); err != nil {
+			return nil, fmt.Errorf("gallery: copy %s: %w", f.Path, err)
+		}
+	}
+	return ex, nil
+}
+
+// load reads and validates the manifest.
+func (g *Gallery) load() ([]entry, error) {
+	data, err := os.ReadFile(filepath.Join(g.cfg.Dir, "gallery.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gallery: read manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	entries := make([]entry, 0, len(m.Examples))
+	seen := make(map[string]bool, len(m.Examples))
+	for _, me := range m.Examples {
+		if me.ID == "" || seen[me.ID] {
+			return nil, fmt.Errorf("%w: missing or duplicate id %q", ErrInvalidManifest, me.ID)
+		}
+		seen[me.ID] = true
+		e := entry{Example: me.Example}
+		e.Files = nil
+		for _, f := range me.Files {
+			p, ok := cleanPath(f.Path)
+			src, srcOK := cleanPath(f.Source)
+			if !ok || !srcOK {
+				return nil, fmt.Errorf("%w: example %s: bad file %q from %q", ErrInvalidManifest, me.ID, f.Path, f.Source)
+			}
+			e.Files = append(e.Files, p)
+			e.sources = append(e.sources, src)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// cleanPath returns p in canonical slash form if it stays below its root.
+func cleanPath(p string) (string, bool) {
+	if p == "" || path.IsAbs(p) || strings.ContainsAny(p, "\\\x00") {
+		return "", false
+	}
+	p = path.Clean(p)
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+	return p, true
+}
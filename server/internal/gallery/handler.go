@@ -0,0 +1,107 @@
+package gallery
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Workspaces creates workspace directories.
+type Workspaces interface {
+	Create(ctx context.Context, id string) (string, error)
+}
+
+// Handler serves the example gallery.
+type Handler struct {
+	gallery    *Gallery
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for g that creates workspaces through ws.
+func NewHandler(g *Gallery, ws Workspaces) *Handler {
+	return &Handler{gallery: g, workspaces: ws}
+}
+
+// Register mounts the gallery routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/examples", h.list)
+	mux.HandleFunc("GET /api/examples/{id}", h.get)
+	mux.HandleFunc("POST /api/examples/{id}/workspaces", h.open)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	examples, err := h.gallery.List(r.URL.Query().Get("tag"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"examples": examples})
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	ex, files, err := h.gallery.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"example": ex, "files": files})
+}
+
+type openRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+// open copies an example into a new workspace. As with POST
+// /api/workspaces, the body is optional and without an ID a random one
+// is assigned.
+func (h *Handler) open(w http.ResponseWriter, r *http.Request) {
+	var req openRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	id := r.PathValue("id")
+	if _, _, err := h.gallery.Get(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.ID == "" {
+		req.ID = workspace.NewID()
+	}
+	dir, err := h.workspaces.Create(r.Context(), req.ID)
+	switch {
+	case errors.Is(err, workspace.ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, workspace.ErrExists):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("create workspace", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not create workspace")
+		return
+	}
+	ex, err := h.gallery.Copy(id, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, map[string]any{"id": req.ID, "example": ex})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		httpx.Error(w, http.StatusNotFound, "example not found")
+		return
+	}
+	slog.Error("read examples", "err", err)
+	httpx.Error(w, http.StatusInternalServerError, "could not read examples")
+}
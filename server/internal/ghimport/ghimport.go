@@ -0,0 +1,347 @@
+// Package ghimport opens GitHub repositories as workspaces. An import
+// clones the repository, public or private with a token, into a new
+// workspace and detects its Go module layout; the modules' dependencies
+// are then downloaded inside the workspace's environment in the
+// background, so the first build or language server start finds them in
+// the module cache.
+package ghimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/git"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// Host is the GitHub host; defaults to github.com.
+	Host string
+	// CloneTimeout bounds the clone; defaults to 5 minutes.
+	CloneTimeout time.Duration
+	// WarmTimeout bounds the module download; defaults to 10 minutes.
+	WarmTimeout time.Duration
+}
+
+var (
+	// ErrInvalidURL is returned for anything other than a repository on
+	// Config.Host.
+	ErrInvalidURL = errors.New("ghimport: not a GitHub repository URL")
+	// ErrNotFound is returned for workspaces without a recorded import.
+	ErrNotFound = errors.New("ghimport: no import for workspace")
+)
+
+// Request asks for a repository to be imported.
+type Request struct {
+	// URL is https://github.com/owner/repo, optionally with .git or
+	// /tree/<branch>, github.com/owner/repo, or just owner/repo.
+	URL string `json:"url"`
+	// Ref is the branch to check out; defaults to the one in URL, then to
+	// the repository's default branch.
+	Ref string `json:"ref,omitempty"`
+	// Token authenticates the clone of a private repository, such as an
+	// OAuth or personal access token.
+	Token string `json:"token,omitempty"`
+	// Depth makes a shallow clone when positive.
+	Depth int `json:"depth,omitempty"`
+}
+
+// Repository identifies a GitHub repository.
+type Repository struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	Ref   string `json:"ref,omitempty"`
+}
+
+// Module is a Go module found in the repository.
+type Module struct {
+	// Dir is the module's directory relative to the workspace root, "."
+	// for the root.
+	Dir       string `json:"dir"`
+	Path      string `json:"path"`
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+// Layout describes how the repository is organized as Go code.
+type Layout struct {
+	Modules []Module `json:"modules"`
+	// Work reports a go.work file at the root.
+	Work bool `json:"work"`
+}
+
+// Warmup states.
+const (
+	WarmRunning = "running"
+	WarmDone    = "done"
+	WarmFailed  = "failed"
+	WarmSkipped = "skipped"
+)
+
+// Warmup is the progress of downloading dependencies.
+type Warmup struct {
+	State      string `json:"state"`
+	Output     string `json:"output,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// Import is the outcome of importing a repository.
+type Import struct {
+	WorkspaceID string     `json:"workspaceId"`
+	Repository  Repository `json:"repository"`
+	Layout      Layout     `json:"layout"`
+	Warmup      Warmup     `json:"warmup"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Service imports repositories and tracks their warmups.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	imports map[string]*Import
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.Host == "" {
+		cfg.Host = "github.com"
+	}
+	if cfg.CloneTimeout <= 0 {
+		cfg.CloneTimeout = 5 * time.Minute
+	}
+	if cfg.WarmTimeout <= 0 {
+		cfg.WarmTimeout = 10 * time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{cfg: cfg, launcher: l, ctx: ctx, cancel: cancel, imports: make(map[string]*Import)}
+}
+
+var (
+	ownerPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+	repoPattern  = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+)
+
+// ParseURL parses the repository forms Request.URL accepts.
+func (s *Service) ParseURL(raw string) (Repository, error) {
+	raw = strings.TrimSpace(raw)
+	rest := raw
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "https" || !strings.EqualFold(u.Host, s.cfg.Host) || u.User != nil || u.RawQuery != "" {
+			return Repository{}, fmt.Errorf("%w: %q", ErrInvalidURL, raw)
+		}
+		rest = u.Path
+	} else if after, ok := strings.CutPrefix(raw, s.cfg.Host+"/"); ok {
+		rest = after
+	}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	var repo Repository
+	switch {
+	case len(parts) == 2:
+	case len(parts) == 4 && parts[2] == "tree":
+		repo.Ref = parts[3]
+	default:
+		return Repository{}, fmt.Errorf("%w: %q", ErrInvalidURL, raw)
+	}
+	repo.Owner, repo.Name = parts[0], strings.TrimSuffix(parts[1], ".git")
+	if !ownerPattern.MatchString(repo.Owner) || !repoPattern.MatchString(repo.Name) || strings.Trim(repo.Name, ".") == "" {
+		return Repository{}, fmt.Errorf("%w: %q", ErrInvalidURL, raw)
+	}
+	return repo, nil
+}
+
+// Import clones the repository of req into the empty workspace directory
+// dir and starts downloading its modules' dependencies.
+func (s *Service) Import(ctx context.Context, workspaceID, dir string, req Request) (*Import, error) {
+	repo, err := s.ParseURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if req.Ref != "" {
+		repo.Ref = req.Ref
+	}
+	opts := git.CloneOptions{Branch: repo.Ref, Depth: req.Depth}
+	if req.Token != "" {
+		opts.Auth = &git.Credentials{Password: req.Token}
+	}
+	cloneCtx, cancel := context.WithTimeout(ctx, s.cfg.CloneTimeout)
+	defer cancel()
+	remote := fmt.Sprintf("https://%s/%s/%s.git", s.cfg.Host, repo.Owner, repo.Name)
+	if _, err := git.Clone(cloneCtx, remote, dir, opts); err != nil {
+		return nil, err
+	}
+	layout, err := DetectLayout(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := &Import{
+		WorkspaceID: workspaceID,
+		Repository:  repo,
+		Layout:      *layout,
+		Warmup:      Warmup{State: WarmRunning},
+		CreatedAt:   time.Now().UTC(),
+	}
+	if len(layout.Modules) == 0 {
+		imp.Warmup.State = WarmSkipped
+	}
+	s.mu.Lock()
+	s.imports[workspaceID] = imp
+	out := *imp
+	s.mu.Unlock()
+	if imp.Warmup.State == WarmRunning {
+		go s.warm(workspaceID, dir, layout.Modules)
+	}
+	return &out, nil
+}
+
+// Status returns the import recorded for a workspace.
+func (s *Service) Status(workspaceID string) (*Import, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	imp, ok := s.imports[workspaceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	out := *imp
+	return &out, nil
+}
+
+// Close stops running warmups.
+func (s *Service) Close() {
+	s.cancel()
+}
+
+// warmScript downloads the dependencies of each module directory given
+// as an argument, carrying on past failures.
+const warmScript = `status=0
+for dir in "$@"; do
+	echo "go mod download in $dir"
+	(cd "$dir" && go mod download) || status=1
+done
+exit $status
+`
+
+func (s *Service) warm(workspaceID, dir string, modules []Module) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.WarmTimeout)
+	defer cancel()
+	root := s.launcher.Root(dir)
+	argv := []string{"sh", "-c", warmScript, "webide-warm"}
+	for _, m := range modules {
+		argv = append(argv, path.Join(root, m.Dir))
+	}
+	state, output := WarmDone, ""
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err == nil {
+		cmd.WaitDelay = 5 * time.Second
+		var out []byte
+		out, err = cmd.CombinedOutput()
+		output = tail(string(out), 8<<10)
+	}
+	if err != nil {
+		state = WarmFailed
+		if output == "" {
+			output = err.Error()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if imp, ok := s.imports[workspaceID]; ok {
+		imp.Warmup = Warmup{State: state, Output: output, DurationMS: time.Since(start).Milliseconds()}
+	}
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
+
+// skipDirs are never searched for modules.
+var skipDirs = map[string]bool{"vendor": true, "testdata": true, "node_modules": true}
+
+// DetectLayout finds the Go modules below dir. Hidden directories and
+// those the go command ignores are not searched.
+func DetectLayout(dir string) (*Layout, error) {
+	layout := &Layout{Modules: []Module{}}
+	if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+		layout.Work = true
+	}
+	err := filepath.WalkDir(dir, func(p string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := de.Name()
+		if de.IsDir() {
+			if p != dir && (skipDirs[name] || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name != "go.mod" || !de.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		m := parseGoMod(string(data))
+		m.Dir = filepath.ToSlash(rel)
+		layout.Modules = append(layout.Modules, m)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ghimport: detect layout: %w", err)
+	}
+	sort.Slice(layout.Modules, func(i, j int) bool { return layout.Modules[i].Dir < layout.Modules[j].Dir })
+	return layout, nil
+}
+
+// parseGoMod reads the module path and go directive of a go.mod file.
+func parseGoMod(data string) Module {
+	var m Module
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		switch f[0] {
+		case "module":
+			m.Path = strings.Trim(f[1], "\"`")
+		case "go":
+			m.GoVersion = f[1]
+		}
+	}
+	return m
+}
@@ -0,0 +1,128 @@
+package ghimport
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/git"
+)
+
+// Workspaces resolves and creates workspace directories.
+type Workspaces interface {
+	Open(id string) (string, error)
+	Create(ctx context.Context, id string) (string, error)
+}
+
+// Tokens returns the GitHub token linked to the signed-in user, or "".
+type Tokens interface {
+	ProviderToken(ctx context.Context, provider string) (string, error)
+}
+
+// Handler serves GitHub imports.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	tokens     Tokens
+}
+
+// NewHandler returns a Handler that imports through svc into ws. Imports
+// without a token clone with the one tokens returns, so users who signed
+// in with GitHub can import their private repositories.
+func NewHandler(svc *Service, ws Workspaces, tokens Tokens) *Handler {
+	return &Handler{svc: svc, workspaces: ws, tokens: tokens}
+}
+
+// Register mounts the import routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/workspaces/github", h.create)
+	mux.HandleFunc("GET /api/workspaces/{id}/github", h.status)
+}
+
+type createRequest struct {
+	Request
+	// ID names the new workspace; a random one is assigned without it.
+	ID string `json:"id,omitempty"`
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Reject bad URLs before a workspace is created for them.
+	if _, err := h.svc.ParseURL(req.URL); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// The linked token is for github.com, not an Enterprise host.
+	if req.Token == "" && h.svc.cfg.Host == "github.com" {
+		token, err := h.tokens.ProviderToken(r.Context(), "github")
+		if err != nil {
+			slog.Warn("github token", "err", err)
+		}
+		req.Token = token
+	}
+	if req.ID == "" {
+		req.ID = workspace.NewID()
+	}
+	dir, err := h.workspaces.Create(r.Context(), req.ID)
+	switch {
+	case errors.Is(err, workspace.ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, workspace.ErrExists):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("create workspace", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not create workspace")
+		return
+	}
+	imp, err := h.svc.Import(r.Context(), req.ID, dir, req.Request)
+	if err != nil {
+		os.RemoveAll(dir)
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, imp)
+}
+
+// status reports the import of a workspace, including whether its
+// dependencies have finished downloading.
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	imp, err := h.svc.Status(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, imp)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var gitErr *git.Error
+	switch {
+	case errors.Is(err, ErrInvalidURL), errors.Is(err, git.ErrInvalidArg), errors.Is(err, git.ErrInvalidURL):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.As(err, &gitErr):
+		// Mostly a missing repository or branch, or a rejected token.
+		httpx.Error(w, http.StatusBadGateway, gitErr.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		httpx.Error(w, http.StatusGatewayTimeout, "clone timed out")
+	default:
+		slog.Error("github import", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "import failed")
+	}
+}
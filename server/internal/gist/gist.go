@@ -0,0 +1,285 @@
+// Package gist exchanges files with the GitHub Gist API. Workspace files
+// and snippets can be published as gists, and gists imported into
+// workspaces or stored as snippets. Creating a gist needs the user's
+// token; reading public gists does not.
+package gist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config configures a Client.
+type Config struct {
+	// APIURL is the GitHub API root; defaults to https://api.github.com.
+	APIURL string
+	// MaxBytes caps the total size of a gist's files; defaults to 10 MiB.
+	MaxBytes int64
+	// Timeout bounds one API call; defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+var (
+	// ErrInvalid is returned for malformed gist IDs and for file sets a
+	// gist cannot hold.
+	ErrInvalid = errors.New("gist: invalid request")
+	// ErrNotFound is returned for gists that do not exist or are not
+	// visible with the given token.
+	ErrNotFound = errors.New("gist: not found")
+	// ErrUnauthorized is returned when GitHub rejects the token, or a
+	// token is needed and none was given.
+	ErrUnauthorized = errors.New("gist: unauthorized")
+	// ErrTooLarge is returned for gists over Config.MaxBytes.
+	ErrTooLarge = errors.New("gist: too large")
+	// ErrUpstream is returned when GitHub cannot be reached or fails.
+	ErrUpstream = errors.New("gist: GitHub request failed")
+)
+
+// File is one file of a gist.
+type File struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// Gist is a gist as the API returns it, with its files in name order.
+type Gist struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	Public      bool   `json:"public"`
+	Owner       string `json:"owner,omitempty"`
+	Files       []File `json:"files"`
+}
+
+// NewGist is a gist to create.
+type NewGist struct {
+	Description string
+	Public      bool
+	Files       []File
+}
+
+// Client talks to the Gist API.
+type Client struct {
+	cfg  Config
+	http *http.Client
+}
+
+// NewClient returns a Client, filling unset Config fields with defaults.
+func NewClient(cfg Config) *Client {
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.github.com"
+	}
+	cfg.APIURL = strings.TrimSuffix(cfg.APIURL, "/")
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 10 << 20
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &Client{cfg: cfg, http: &http.Client{Timeout: cfg.Timeout}}
+}
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,64}$`)
+
+// ParseID returns the gist ID of s, which is an ID or a gist URL such as
+// https://gist.github.com/user/ID.
+func ParseID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		s = u.Path
+	}
+	s = strings.Trim(s, "/")
+	if i := strings.LastIndexByte(s, '/'); i >= 0 {
+		s = s[i+1:]
+	}
+	s = strings.TrimSuffix(s, ".git")
+	if !idPattern.MatchString(s) {
+		return "", fmt.Errorf("%w: bad gist ID", ErrInvalid)
+	}
+	return s, nil
+}
+
+// apiGist is the API's representation of a gist.
+type apiGist struct {
+	ID          string `json:"id"`
+	HTMLURL     string `json:"html_url"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+	Owner       *struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	Files map[string]*struct {
+		Content   string `json:"content"`
+		Truncated bool   `json:"truncated"`
+		RawURL    string `json:"raw_url"`
+		Size      int64  `json:"size"`
+	} `json:"files"`
+}
+
+// Create publishes a new gist with the given token.
+func (c *Client) Create(ctx context.Context, token string, g NewGist) (*Gist, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: creating a gist needs a GitHub token", ErrUnauthorized)
+	}
+	if len(g.Files) == 0 {
+		return nil, fmt.Errorf("%w: no files", ErrInvalid)
+	}
+	files := make(map[string]map[string]string, len(g.Files))
+	var total int64
+	for _, f := range g.Files {
+		switch {
+		case f.Name == "" || strings.ContainsAny(f.Name, `/\`):
+			return nil, fmt.Errorf("%w: gists cannot hold directories, got %q", ErrInvalid, f.Name)
+		case strings.TrimSpace(f.Content) == "":
+			return nil, fmt.Errorf("%w: %s is empty, which gists do not allow", ErrInvalid, f.Name)
+		case files[f.Name] != nil:
+			return nil, fmt.Errorf("%w: duplicate file %s", ErrInvalid, f.Name)
+		}
+		total += int64(len(f.Content))
+		files[f.Name] = map[string]string{"content": f.Content}
+	}
+	if total > c.cfg.MaxBytes {
+		return nil, fmt.Errorf("%w: files exceed %d bytes", ErrTooLarge, c.cfg.MaxBytes)
+	}
+	body, err := json.Marshal(map[string]any{"description": g.Description, "public": g.Public, "files": files})
+	if err != nil {
+		return nil, err
+	}
+	var out apiGist
+	if err := c.do(ctx, token, http.MethodPost, c.cfg.APIURL+"/gists", body, &out); err != nil {
+		return nil, err
+	}
+	return c.convert(ctx, token, &out)
+}
+
+// Get fetches a gist. token may be empty for public gists.
+func (c *Client) Get(ctx context.Context, token, id string) (*Gist, error) {
+	id, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+	var out apiGist
+	if err := c.do(ctx, token, http.MethodGet, c.cfg.APIURL+"/gists/"+id, nil, &out); err != nil {
+		return nil, err
+	}
+	return c.convert(ctx, token, &out)
+}
+
+// convert turns an API gist into a Gist, fetching the contents of files
+// the API truncated.
+func (c *Client) convert(ctx context.Context, token string, in *apiGist) (*Gist, error) {
+	g := &Gist{ID: in.ID, URL: in.HTMLURL, Description: in.Description, Public: in.Public}
+	if in.Owner != nil {
+		g.Owner = in.Owner.Login
+	}
+	var total int64
+	for name, f := range in.Files {
+		if f == nil {
+			continue
+		}
+		if name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+			return nil, fmt.Errorf("%w: unsafe file name %q", ErrInvalid, name)
+		}
+		if total += f.Size; total > c.cfg.MaxBytes {
+			return nil, fmt.Errorf("%w: files exceed %d bytes", ErrTooLarge, c.cfg.MaxBytes)
+		}
+		content := f.Content
+		if f.Truncated {
+			raw, err := c.raw(ctx, token, f.RawURL)
+			if err != nil {
+				return nil, err
+			}
+			content = raw
+		}
+		g.Files = append(g.Files, File{Name: name, Content: content})
+	}
+	sort.Slice(g.Files, func(i, j int) bool { return g.Files[i].Name < g.Files[j].Name })
+	return g, nil
+}
+
+// raw downloads a truncated file from its raw URL.
+func (c *Client) raw(ctx context.Context, token, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("gist: raw file: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: raw file: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: raw file: %s", ErrUpstream, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.cfg.MaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("%w: raw file: %v", ErrUpstream, err)
+	}
+	if int64(len(data)) > c.cfg.MaxBytes {
+		return "", fmt.Errorf("%w: files exceed %d bytes", ErrTooLarge, c.cfg.MaxBytes)
+	}
+	return string(data), nil
+}
+
+// do sends an API request and decodes the JSON response into out.
+func (c *Client) do(ctx context.Context, token, method, u string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("gist: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.cfg.MaxBytes+1<<20))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrUnauthorized, apiMessage(data, resp.Status))
+	case resp.StatusCode == http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", ErrInvalid, apiMessage(data, resp.Status))
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("%w: %s", ErrUpstream, apiMessage(data, resp.Status))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%w: decode response: %v", ErrUpstream, err)
+	}
+	return nil
+}
+
+// apiMessage extracts the message of a GitHub error response.
+func apiMessage(data []byte, status string) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &e) == nil && e.Message != "" {
+		return e.Message
+	}
+	return status
+}
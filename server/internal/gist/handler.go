@@ -0,0 +1,260 @@
+package gist
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/snippet"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Tokens returns the GitHub token linked to the signed-in user, or "".
+type Tokens interface {
+	ProviderToken(ctx context.Context, provider string) (string, error)
+}
+
+// Handler serves gist export and import for workspaces and snippets.
+type Handler struct {
+	client     *Client
+	workspaces Workspaces
+	snippets   *snippet.Store
+	tokens     Tokens
+}
+
+// NewHandler returns a Handler that talks to GitHub through c. Requests
+// without a token use the one tokens returns.
+func NewHandler(c *Client, ws Workspaces, snippets *snippet.Store, tokens Tokens) *Handler {
+	return &Handler{client: c, workspaces: ws, snippets: snippets, tokens: tokens}
+}
+
+// Register mounts the gist routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/workspaces/{id}/gist", h.exportFiles)
+	mux.HandleFunc("POST /api/workspaces/{id}/gist/import", h.importFiles)
+	mux.HandleFunc("POST /api/snippets/{id}/gist", h.exportSnippet)
+	mux.HandleFunc("POST /api/snippets/gist", h.importSnippet)
+}
+
+type exportRequest struct {
+	Token       string `json:"token"`
+	Description string `json:"description,omitempty"`
+	Public      bool   `json:"public,omitempty"`
+	// Paths are the workspace files to publish; gists are flat, so they
+	// become files named after their base names.
+	Paths []string `json:"paths,omitempty"`
+}
+
+type importRequest struct {
+	// Gist is a gist ID or URL.
+	Gist  string `json:"gist"`
+	Token string `json:"token,omitempty"`
+	// Dir is the workspace directory the files are written to.
+	Dir string `json:"dir,omitempty"`
+	// Overwrite replaces existing files instead of failing.
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+func (h *Handler) fsFor(w http.ResponseWriter, r *http.Request) (*files.FS, bool) {
+	dir, err := h.workspaces.Open(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return nil, false
+	}
+	f, err := files.New(dir)
+	if err != nil {
+		slog.Error("open workspace root", "workspace", r.PathValue("id"), "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "workspace unavailable")
+		return nil, false
+	}
+	return f, true
+}
+
+func (h *Handler) exportFiles(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.fsFor(w, r)
+	if !ok {
+		return
+	}
+	var req exportRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Paths) == 0 {
+		httpx.Error(w, http.StatusBadRequest, "no paths")
+		return
+	}
+	g := NewGist{Description: req.Description, Public: req.Public}
+	for _, p := range req.Paths {
+		data, err := f.ReadFile(p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		g.Files = append(g.Files, File{Name: path.Base(p), Content: string(data)})
+	}
+	h.create(r.Context(), w, h.token(r.Context(), req.Token), g)
+}
+
+func (h *Handler) exportSnippet(w http.ResponseWriter, r *http.Request) {
+	s, err := h.snippets.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req exportRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Paths) > 0 {
+		httpx.Error(w, http.StatusBadRequest, "paths only apply to workspaces")
+		return
+	}
+	g := NewGist{Description: req.Description, Public: req.Public}
+	if g.Description == "" {
+		g.Description = s.Title
+	}
+	if s.Source != "" {
+		g.Files = []File{{Name: "main.go", Content: s.Source}}
+	}
+	for _, sf := range s.Files {
+		g.Files = append(g.Files, File{Name: sf.Path, Content: sf.Content})
+	}
+	h.create(r.Context(), w, h.token(r.Context(), req.Token), g)
+}
+
+// token returns given, or else the user's linked GitHub token.
+func (h *Handler) token(ctx context.Context, given string) string {
+	if given != "" {
+		return given
+	}
+	token, err := h.tokens.ProviderToken(ctx, "github")
+	if err != nil {
+		slog.Warn("github token", "err", err)
+	}
+	return token
+}
+
+func (h *Handler) create(ctx context.Context, w http.ResponseWriter, token string, g NewGist) {
+	out, err := h.client.Create(ctx, token, g)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, out)
+}
+
+// importFiles writes the files of a gist into the workspace.
+func (h *Handler) importFiles(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.fsFor(w, r)
+	if !ok {
+		return
+	}
+	var req importRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dir, err := files.Clean(req.Dir)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g, err := h.client.Get(r.Context(), h.token(r.Context(), req.Token), req.Gist)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// Check every destination first so a conflict leaves the workspace
+	// untouched.
+	paths := make([]string, len(g.Files))
+	for i, gf := range g.Files {
+		paths[i] = path.Join(dir, gf.Name)
+		if _, err := f.Stat(paths[i]); err == nil && !req.Overwrite {
+			httpx.Errorf(w, http.StatusConflict, "%s already exists", paths[i])
+			return
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			writeError(w, err)
+			return
+		}
+	}
+	entries := make([]files.Entry, 0, len(g.Files))
+	for i, gf := range g.Files {
+		e, err := f.WriteFile(paths[i], []byte(gf.Content))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		entries = append(entries, e)
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"gist": g.ID, "files": entries})
+}
+
+// importSnippet stores the files of a gist as a snippet.
+func (h *Handler) importSnippet(w http.ResponseWriter, r *http.Request) {
+	var req importRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Dir != "" || req.Overwrite {
+		httpx.Error(w, http.StatusBadRequest, "dir and overwrite only apply to workspaces")
+		return
+	}
+	g, err := h.client.Get(r.Context(), h.token(r.Context(), req.Token), req.Gist)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s := snippet.Snippet{Title: g.Description}
+	for _, gf := range g.Files {
+		s.Files = append(s.Files, runner.File{Path: gf.Name, Content: gf.Content})
+	}
+	if len(s.Files) == 1 && path.Ext(s.Files[0].Path) == ".go" {
+		s.Source, s.Files = s.Files[0].Content, nil
+	}
+	saved, created, err := h.snippets.Create(s)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	httpx.JSON(w, status, saved)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid), errors.Is(err, snippet.ErrInvalid),
+		errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir), errors.Is(err, files.ErrNotDir):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrUnauthorized):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "gist not found")
+	case errors.Is(err, snippet.ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "snippet not found")
+	case errors.Is(err, fs.ErrNotExist):
+		httpx.Error(w, http.StatusNotFound, "no such file or directory")
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrUpstream):
+		httpx.Error(w, http.StatusBadGateway, err.Error())
+	default:
+		slog.Error("gist", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "gist operation failed")
+	}
+}
@@ -0,0 +1,329 @@
+package gotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoRun is returned for benchmark runs that are not in the history.
+var ErrNoRun = errors.New("gotest: benchmark run not found")
+
+var (
+	benchTimePattern = regexp.MustCompile(`^([0-9]+x|[0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m))$`)
+	runIDPattern     = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}-[0-9a-f]{6}$`)
+)
+
+// BenchRequest selects the benchmarks to run.
+type BenchRequest struct {
+	// Module and Packages select packages as in Request.
+	Module   string   `json:"module,omitempty"`
+	Packages []string `json:"packages,omitempty"`
+	// Bench is passed to -bench; defaults to ".".
+	Bench string `json:"bench,omitempty"`
+	// Count is passed to -count; defaults to 1. Several samples per
+	// benchmark are needed for Compare to judge significance.
+	Count int `json:"count,omitempty"`
+	// BenchTime is passed to -benchtime, e.g. "2s" or "1000x".
+	BenchTime string `json:"benchTime,omitempty"`
+	// TimeoutMS overrides Config.DefaultTimeout, up to Config.MaxTimeout.
+	TimeoutMS int64 `json:"timeoutMs,omitempty"`
+}
+
+// Metric is one measured unit of a benchmark, such as "ns/op", "B/op",
+// "allocs/op", "MB/s" or a custom b.ReportMetric unit, with one value per
+// sample.
+type Metric struct {
+	Unit   string    `json:"unit"`
+	Values []float64 `json:"values"`
+	Mean   float64   `json:"mean"`
+}
+
+// Benchmark collects the samples of one benchmark. Name excludes the
+// GOMAXPROCS suffix, which is reported as Procs.
+type Benchmark struct {
+	Package    string   `json:"package"`
+	Name       string   `json:"name"`
+	Procs      int      `json:"procs"`
+	Iterations []int64  `json:"iterations"`
+	Metrics    []Metric `json:"metrics"`
+}
+
+// BenchRun is one stored execution of the benchmarks. Output holds build
+// errors and the output of failed packages.
+type BenchRun struct {
+	ID         string       `json:"id"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	Request    BenchRequest `json:"request"`
+	Goos       string       `json:"goos,omitempty"`
+	Goarch     string       `json:"goarch,omitempty"`
+	CPU        string       `json:"cpu,omitempty"`
+	Passed     bool         `json:"passed"`
+	ExitCode   int          `json:"exitCode"`
+	TimedOut   bool         `json:"timedOut"`
+	DurationMS int64        `json:"durationMs"`
+	Benchmarks []Benchmark  `json:"benchmarks"`
+	Output     string       `json:"output"`
+}
+
+// Bench runs benchmarks for the workspace at dir and stores the run in
+// the workspace's history.
+func (s *Service) Bench(ctx context.Context, workspaceID, dir string, req BenchRequest) (*BenchRun, error) {
+	timeout, err := s.timeout(req.TimeoutMS)
+	if err != nil {
+		return nil, err
+	}
+	module, pkgs, err := scope(dir, req.Module, req.Packages)
+	if err != nil {
+		return nil, err
+	}
+	if req.Bench == "" {
+		req.Bench = "."
+	}
+	if req.Count <= 0 {
+		req.Count = 1
+	}
+	if req.Count > 20 {
+		return nil, fmt.Errorf("%w: count exceeds 20", ErrInvalidRequest)
+	}
+	if req.BenchTime != "" && !benchTimePattern.MatchString(req.BenchTime) {
+		return nil, fmt.Errorf("%w: benchTime %q", ErrInvalidRequest, req.BenchTime)
+	}
+	req.Packages = pkgs
+
+	argv := []string{"go", "test", "-C", module, "-json", "-run=^$", "-bench=" + req.Bench, "-benchmem",
+		"-count=" + strconv.Itoa(req.Count), "-timeout=" + timeout.String()}
+	if req.BenchTime != "" {
+		argv = append(argv, "-benchtime="+req.BenchTime)
+	}
+	argv = append(argv, pkgs...)
+
+	run := &BenchRun{ID: newRunID(), CreatedAt: time.Now().UTC(), Request: req}
+	br := &benchReader{run: run, index: make(map[string]int)}
+	rep, err := s.exec(ctx, workspaceID, dir, argv, timeout, br.line)
+	if err != nil {
+		return nil, err
+	}
+	run.Passed, run.ExitCode, run.TimedOut, run.DurationMS = rep.Passed, rep.ExitCode, rep.TimedOut, rep.DurationMS
+	var out strings.Builder
+	out.WriteString(rep.Output)
+	for _, p := range rep.Packages {
+		if p.Status == StatusFail {
+			for _, t := range p.Tests {
+				out.WriteString(t.Output)
+			}
+			out.WriteString(p.Output)
+		}
+	}
+	run.Output = out.String()
+	if run.Benchmarks == nil {
+		run.Benchmarks = []Benchmark{}
+	}
+	if err := s.saveRun(workspaceID, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// benchReader picks benchmark results out of go test -json output.
+type benchReader struct {
+	run   *BenchRun
+	index map[string]int
+}
+
+// line records benchmark results in b; it never consumes the line, so
+// the collector still sees every event.
+func (r *benchReader) line(b []byte) bool {
+	var ev event
+	if json.Unmarshal(b, &ev) != nil || ev.Action != "output" {
+		return false
+	}
+	line := strings.TrimSuffix(ev.Output, "\n")
+	for _, h := range []struct {
+		prefix string
+		field  *string
+	}{{"goos: ", &r.run.Goos}, {"goarch: ", &r.run.Goarch}, {"cpu: ", &r.run.CPU}} {
+		if v, ok := strings.CutPrefix(line, h.prefix); ok && *h.field == "" {
+			*h.field = v
+			return false
+		}
+	}
+	r.result(ev.Package, line)
+	return false
+}
+
+// result parses "BenchmarkX-8  1000  123 ns/op  16 B/op  1 allocs/op".
+func (r *benchReader) result(pkg, line string) {
+	f := strings.Fields(line)
+	if len(f) < 4 || len(f)%2 != 0 || !strings.HasPrefix(f[0], "Benchmark") {
+		return
+	}
+	iters, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil {
+		return
+	}
+	values := make([]float64, 0, len(f)/2-1)
+	for i := 2; i < len(f); i += 2 {
+		v, err := strconv.ParseFloat(f[i], 64)
+		if err != nil {
+			return
+		}
+		values = append(values, v)
+	}
+
+	name, procs := f[0], 1
+	if i := strings.LastIndexByte(name, '-'); i > 0 {
+		if n, err := strconv.Atoi(name[i+1:]); err == nil && n > 0 {
+			name, procs = name[:i], n
+		}
+	}
+	key := pkg + "\x00" + name + "\x00" + strconv.Itoa(procs)
+	i, ok := r.index[key]
+	if !ok {
+		i = len(r.run.Benchmarks)
+		r.index[key] = i
+		r.run.Benchmarks = append(r.run.Benchmarks, Benchmark{Package: pkg, Name: name, Procs: procs})
+	}
+	bm := &r.run.Benchmarks[i]
+	bm.Iterations = append(bm.Iterations, iters)
+	for j, v := range values {
+		unit := f[3+2*j]
+		k := 0
+		for k < len(bm.Metrics) && bm.Metrics[k].Unit != unit {
+			k++
+		}
+		if k == len(bm.Metrics) {
+			bm.Metrics = append(bm.Metrics, Metric{Unit: unit})
+		}
+		m := &bm.Metrics[k]
+		m.Values = append(m.Values, v)
+		m.Mean = mean(m.Values)
+	}
+}
+
+// newRunID returns a sortable, unique benchmark run ID.
+func newRunID() string {
+	var b [3]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b[:])
+}
+
+// historyDir returns the directory holding a workspace's benchmark runs.
+func (s *Service) historyDir(workspaceID string) string {
+	return filepath.Join(s.cfg.HistoryDir, workspaceID)
+}
+
+// saveRun stores run and drops the oldest runs beyond Config.MaxHistory.
+func (s *Service) saveRun(workspaceID string, run *BenchRun) error {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	dir := s.historyDir(workspaceID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("gotest: create history dir: %w", err)
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".run-*")
+	if err != nil {
+		return fmt.Errorf("gotest: save run: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("gotest: save run: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("gotest: save run: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, run.ID+".json")); err != nil {
+		return fmt.Errorf("gotest: save run: %w", err)
+	}
+
+	ids, err := runIDs(dir)
+	if err != nil {
+		return err
+	}
+	for len(ids) > s.cfg.MaxHistory {
+		os.Remove(filepath.Join(dir, ids[0]+".json"))
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// runIDs lists the stored run IDs in dir, oldest first.
+func runIDs(dir string) ([]string, error) {
+	des, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gotest: read history: %w", err)
+	}
+	var ids []string
+	for _, de := range des {
+		if id, ok := strings.CutSuffix(de.Name(), ".json"); ok && runIDPattern.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// BenchRuns returns the workspace's stored runs, newest first.
+func (s *Service) BenchRuns(workspaceID string) ([]*BenchRun, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	ids, err := runIDs(s.historyDir(workspaceID))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*BenchRun, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		run, err := s.loadRun(workspaceID, ids[i])
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// BenchRun returns one stored run.
+func (s *Service) BenchRun(workspaceID, id string) (*BenchRun, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	return s.loadRun(workspaceID, id)
+}
+
+func (s *Service) loadRun(workspaceID, id string) (*BenchRun, error) {
+	if !runIDPattern.MatchString(id) {
+		return nil, ErrNoRun
+	}
+	data, err := os.ReadFile(filepath.Join(s.historyDir(workspaceID), id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoRun
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gotest: load run: %w", err)
+	}
+	var run BenchRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("gotest: load run %s: %w", id, err)
+	}
+	return &run, nil
+}
@@ -0,0 +1,207 @@
+package gotest
+
+import (
+	"math"
+	"sort"
+)
+
+// alpha is the significance level for Compare, as in benchstat.
+const alpha = 0.05
+
+// Stats summarizes the samples of one metric.
+type Stats struct {
+	Mean float64 `json:"mean"`
+	// Variation is the sample standard deviation as a percentage of Mean.
+	Variation float64 `json:"variation"`
+	N         int     `json:"n"`
+}
+
+// Delta compares one metric of one benchmark between two runs. Old or
+// New is nil when the benchmark only appears in one of them.
+type Delta struct {
+	Package string `json:"package"`
+	Name    string `json:"name"`
+	Procs   int    `json:"procs"`
+	Unit    string `json:"unit"`
+	Old     *Stats `json:"old"`
+	New     *Stats `json:"new"`
+	// Change is the relative change of the mean in percent.
+	Change *float64 `json:"change,omitempty"`
+	// PValue is from a two-sided Mann-Whitney U test; it needs at least
+	// two samples on each side.
+	PValue *float64 `json:"pValue,omitempty"`
+	// Significant reports PValue <= 0.05. Changes that are not
+	// significant are within the noise of the measurements.
+	Significant bool `json:"significant"`
+}
+
+// Comparison is the benchstat-style difference between two runs.
+type Comparison struct {
+	Old    string  `json:"old"`
+	New    string  `json:"new"`
+	Deltas []Delta `json:"deltas"`
+}
+
+// Compare computes the change of every metric from old to new.
+func Compare(old, new *BenchRun) *Comparison {
+	type key struct {
+		pkg, name string
+		procs     int
+		unit      string
+	}
+	olds := make(map[key][]float64)
+	for _, b := range old.Benchmarks {
+		for _, m := range b.Metrics {
+			olds[key{b.Package, b.Name, b.Procs, m.Unit}] = m.Values
+		}
+	}
+
+	cmp := &Comparison{Old: old.ID, New: new.ID, Deltas: []Delta{}}
+	seen := make(map[key]bool)
+	for _, b := range new.Benchmarks {
+		for _, m := range b.Metrics {
+			k := key{b.Package, b.Name, b.Procs, m.Unit}
+			seen[k] = true
+			d := Delta{Package: b.Package, Name: b.Name, Procs: b.Procs, Unit: m.Unit, New: stats(m.Values)}
+			if ov, ok := olds[k]; ok {
+				d.Old = stats(ov)
+				if d.Old.Mean != 0 {
+					c := round((d.New.Mean - d.Old.Mean) / d.Old.Mean * 100)
+					d.Change = &c
+				}
+				if len(ov) >= 2 && len(m.Values) >= 2 {
+					p := mannWhitney(ov, m.Values)
+					d.PValue = &p
+					d.Significant = p <= alpha
+				}
+			}
+			cmp.Deltas = append(cmp.Deltas, d)
+		}
+	}
+	for _, b := range old.Benchmarks {
+		for _, m := range b.Metrics {
+			if k := (key{b.Package, b.Name, b.Procs, m.Unit}); !seen[k] {
+				cmp.Deltas = append(cmp.Deltas, Delta{Package: b.Package, Name: b.Name, Procs: b.Procs, Unit: m.Unit, Old: stats(m.Values)})
+			}
+		}
+	}
+	return cmp
+}
+
+func stats(values []float64) *Stats {
+	s := &Stats{Mean: mean(values), N: len(values)}
+	if len(values) > 1 && s.Mean != 0 {
+		var sum float64
+		for _, v := range values {
+			sum += (v - s.Mean) * (v - s.Mean)
+		}
+		s.Variation = round(math.Sqrt(sum/float64(len(values)-1)) / math.Abs(s.Mean) * 100)
+	}
+	return s
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// round rounds a percentage to two decimals.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// mannWhitney returns the two-sided p-value of the Mann-Whitney U test
+// for samples x and y. Without ties the exact distribution of U is used;
+// with ties, the normal approximation with tie correction.
+func mannWhitney(x, y []float64) float64 {
+	m, n := len(x), len(y)
+	all := make([]float64, 0, m+n)
+	all = append(append(all, x...), y...)
+	sort.Float64s(all)
+
+	// Rank the pooled samples, averaging the ranks of ties.
+	ranks := make(map[float64]float64)
+	var tieTerm float64
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j] == all[i] {
+			j++
+		}
+		ranks[all[i]] = float64(i+j+1) / 2
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieTerm += t*t*t - t
+		}
+		i = j
+	}
+	var rx float64
+	for _, v := range x {
+		rx += ranks[v]
+	}
+	u := rx - float64(m*(m+1))/2
+
+	if !ties {
+		dist := uDistribution(m, n)
+		total := 0.0
+		for _, c := range dist {
+			total += c
+		}
+		ui := int(u)
+		var lower, upper float64
+		for i, c := range dist {
+			if i <= ui {
+				lower += c
+			}
+			if i >= ui {
+				upper += c
+			}
+		}
+		return math.Min(1, 2*math.Min(lower, upper)/total)
+	}
+
+	N := float64(m + n)
+	mu := float64(m*n) / 2
+	sigma := math.Sqrt(float64(m*n) / 12 * ((N + 1) - tieTerm/(N*(N-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	if z < 0 {
+		return 1
+	}
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// uDistribution returns, for each u in 0..m*n, the number of orderings of
+// m and n distinct values whose U statistic is u.
+func uDistribution(m, n int) []float64 {
+	// prev[j] holds the distribution for (i-1, j) while i advances.
+	prev := make([][]float64, n+1)
+	for j := 0; j <= n; j++ {
+		prev[j] = []float64{1}
+	}
+	for i := 1; i <= m; i++ {
+		cur := make([][]float64, n+1)
+		cur[0] = []float64{1}
+		for j := 1; j <= n; j++ {
+			// The largest value comes from x, adding j to U, or from y.
+			d := make([]float64, i*j+1)
+			for u, c := range prev[j] {
+				d[u+j] += c
+			}
+			for u, c := range cur[j-1] {
+				d[u] += c
+			}
+			cur[j] = d
+		}
+		prev = cur
+	}
+	return prev[n]
+}
@@ -0,0 +1,230 @@
+package gotest
+
+import (
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// coverScript runs go test in module directory $1 with a coverage
+// profile and then prints, after the test events, the main modules and
+// the profile itself so they can be read back without access to the
+// sandbox's filesystem.
+const coverScript = `mod=$1
+shift
+prof=$(mktemp) || exit 1
+go test -C "$mod" -coverprofile="$prof" "$@"
+rc=$?
+echo ` + modulesMarker + `
+go list -C "$mod" -m -f '{{.Path}}{{"\t"}}{{.Dir}}' 2>/dev/null
+echo ` + profileMarker + `
+cat "$prof" 2>/dev/null
+rm -f "$prof"
+exit $rc
+`
+
+const (
+	modulesMarker = "webide-coverage-modules"
+	profileMarker = "webide-coverage-profile"
+)
+
+// Block is one basic block of a coverage profile. Lines and columns are
+// 1-based; Count is how often the block ran, or 1 in "set" mode.
+type Block struct {
+	StartLine  int `json:"startLine"`
+	StartCol   int `json:"startCol"`
+	EndLine    int `json:"endLine"`
+	EndCol     int `json:"endCol"`
+	Statements int `json:"statements"`
+	Count      int `json:"count"`
+}
+
+// FileCoverage is the coverage of one source file. Covered lines are only
+// in blocks that ran, uncovered lines only in blocks that did not, and
+// partial lines in both.
+type FileCoverage struct {
+	Path       string  `json:"path"`
+	Statements int     `json:"statements"`
+	Covered    int     `json:"covered"`
+	Percent    float64 `json:"percent"`
+	Blocks     []Block `json:"blocks"`
+
+	CoveredLines   []int `json:"coveredLines"`
+	UncoveredLines []int `json:"uncoveredLines"`
+	PartialLines   []int `json:"partialLines"`
+}
+
+// Coverage is the parsed profile of a test run. Paths are relative to the
+// workspace root when the file belongs to one of its modules, and import
+// paths otherwise.
+type Coverage struct {
+	Mode       string         `json:"mode"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	Statements int            `json:"statements"`
+	Covered    int            `json:"covered"`
+	Percent    float64        `json:"percent"`
+	Files      []FileCoverage `json:"files"`
+}
+
+// module maps a module path to its directory inside the sandbox.
+type module struct {
+	path, dir string
+}
+
+// coverageReader collects the trailer coverScript prints after go test.
+type coverageReader struct {
+	section string
+	modules []module
+	mode    string
+	blocks  map[string]map[[4]int]Block
+}
+
+// line consumes b if it belongs to the trailer and reports whether it did.
+func (r *coverageReader) line(b []byte) bool {
+	switch s := string(b); {
+	case s == modulesMarker || s == profileMarker:
+		r.section = s
+	case r.section == modulesMarker:
+		if p, d, ok := strings.Cut(s, "\t"); ok {
+			r.modules = append(r.modules, module{path: p, dir: d})
+		}
+	case r.section == profileMarker:
+		r.profileLine(s)
+	default:
+		return false
+	}
+	return true
+}
+
+// profileLine parses "file.go:line.col,line.col statements count". The
+// same block appears once per test binary with -coverpkg, so repeated
+// blocks are merged.
+func (r *coverageReader) profileLine(s string) {
+	if mode, ok := strings.CutPrefix(s, "mode: "); ok {
+		r.mode = mode
+		return
+	}
+	colon := strings.LastIndexByte(s, ':')
+	if colon < 0 {
+		return
+	}
+	f := strings.FieldsFunc(s[colon+1:], func(c rune) bool { return c == '.' || c == ',' || c == ' ' })
+	if len(f) != 6 {
+		return
+	}
+	var n [6]int
+	for i, v := range f {
+		var err error
+		if n[i], err = strconv.Atoi(v); err != nil {
+			return
+		}
+	}
+	b := Block{StartLine: n[0], StartCol: n[1], EndLine: n[2], EndCol: n[3], Statements: n[4], Count: n[5]}
+	if r.blocks == nil {
+		r.blocks = make(map[string]map[[4]int]Block)
+	}
+	file := s[:colon]
+	if r.blocks[file] == nil {
+		r.blocks[file] = make(map[[4]int]Block)
+	}
+	key := [4]int{b.StartLine, b.StartCol, b.EndLine, b.EndCol}
+	if prev, ok := r.blocks[file][key]; ok {
+		if r.mode == "set" {
+			b.Count = max(b.Count, prev.Count)
+		} else {
+			b.Count += prev.Count
+		}
+	}
+	r.blocks[file][key] = b
+}
+
+// coverage returns the collected profile, or nil if go test wrote none.
+// root is the workspace directory as seen inside the sandbox.
+func (r *coverageReader) coverage(root string) *Coverage {
+	if r.mode == "" {
+		return nil
+	}
+	cov := &Coverage{Mode: r.mode, CreatedAt: time.Now().UTC(), Files: []FileCoverage{}}
+	for file, blocks := range r.blocks {
+		fc := FileCoverage{Path: r.relPath(file, root), Blocks: make([]Block, 0, len(blocks))}
+		for _, b := range blocks {
+			fc.Blocks = append(fc.Blocks, b)
+		}
+		sort.Slice(fc.Blocks, func(i, j int) bool {
+			a, b := fc.Blocks[i], fc.Blocks[j]
+			if a.StartLine != b.StartLine {
+				return a.StartLine < b.StartLine
+			}
+			return a.StartCol < b.StartCol
+		})
+		fileLines(&fc)
+		cov.Statements += fc.Statements
+		cov.Covered += fc.Covered
+		cov.Files = append(cov.Files, fc)
+	}
+	sort.Slice(cov.Files, func(i, j int) bool { return cov.Files[i].Path < cov.Files[j].Path })
+	cov.Percent = percent(cov.Covered, cov.Statements)
+	return cov
+}
+
+// fileLines fills in the statement counts and line sets of fc.
+func fileLines(fc *FileCoverage) {
+	const hit, miss = 1, 2
+	state := make(map[int]int)
+	for _, b := range fc.Blocks {
+		fc.Statements += b.Statements
+		bit := miss
+		if b.Count > 0 {
+			fc.Covered += b.Statements
+			bit = hit
+		}
+		for l := b.StartLine; l <= b.EndLine; l++ {
+			state[l] |= bit
+		}
+	}
+	fc.CoveredLines, fc.UncoveredLines, fc.PartialLines = []int{}, []int{}, []int{}
+	for l, s := range state {
+		switch s {
+		case hit:
+			fc.CoveredLines = append(fc.CoveredLines, l)
+		case miss:
+			fc.UncoveredLines = append(fc.UncoveredLines, l)
+		default:
+			fc.PartialLines = append(fc.PartialLines, l)
+		}
+	}
+	sort.Ints(fc.CoveredLines)
+	sort.Ints(fc.UncoveredLines)
+	sort.Ints(fc.PartialLines)
+	fc.Percent = percent(fc.Covered, fc.Statements)
+}
+
+// relPath maps a profile's import-path file name into the workspace using
+// the longest matching main module.
+func (r *coverageReader) relPath(file, root string) string {
+	best := -1
+	for i, m := range r.modules {
+		if (file == m.path || strings.HasPrefix(file, m.path+"/")) && (best < 0 || len(m.path) > len(r.modules[best].path)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return file
+	}
+	m := r.modules[best]
+	dir, ok := strings.CutPrefix(m.dir, root)
+	if !ok || (dir != "" && dir[0] != '/') {
+		return file
+	}
+	return strings.TrimPrefix(path.Join(dir, strings.TrimPrefix(file, m.path)), "/")
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(covered)*1000/float64(total)) / 10
+}
@@ -0,0 +1,111 @@
+package gotest
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHTMLSource bounds the source of one file shown in a coverage
+// report; larger files are listed without it.
+const maxHTMLSource = 1 << 20
+
+// reportFile is a file of a coverage report with its source lines.
+type reportFile struct {
+	FileCoverage
+	Anchor string
+	Lines  []reportLine
+}
+
+type reportLine struct {
+	N     int
+	Text  string
+	Class string
+}
+
+var reportTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Coverage {{printf "%.1f" .Percent}}%</title>
+<style>
+body { font: 14px system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 2px 12px; text-align: left; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+h2 { font-size: 15px; margin-top: 2em; }
+pre { font: 12px ui-monospace, monospace; margin: 0; border: 1px solid #ddd; overflow-x: auto; }
+pre span { display: block; white-space: pre; }
+pre span::before { content: attr(data-n); display: inline-block; width: 4em; color: #999; text-align: right; margin-right: 1em; }
+.covered { background: #dcf5dc; }
+.uncovered { background: #f8d7d7; }
+.partial { background: #fbf1c7; }
+</style>
+</head>
+<body>
+<h1>Coverage: {{printf "%.1f" .Percent}}% of {{.Statements}} statements</h1>
+<p>Mode {{.Mode}}, recorded {{.CreatedAt.Format "2006-01-02 15:04:05 MST"}}.</p>
+<table>
+<tr><th>File</th><th>Statements</th><th>Covered</th><th>Percent</th></tr>
+{{range .Files}}<tr><td><a href="#{{.Anchor}}">{{.Path}}</a></td><td class="num">{{.Statements}}</td><td class="num">{{.Covered}}</td><td class="num">{{printf "%.1f" .Percent}}%</td></tr>
+{{end}}</table>
+{{range .Files}}<h2 id="{{.Anchor}}">{{.Path}} ({{printf "%.1f" .Percent}}%)</h2>
+{{if .Lines}}<pre>{{range .Lines}}<span data-n="{{.N}}"{{with .Class}} class="{{.}}"{{end}}>{{.Text}}</span>{{end}}</pre>
+{{else}}<p>Source not available.</p>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// CoverageHTML renders cov as a standalone HTML page, with the source of the
+// files inside the workspace at dir annotated line by line.
+func CoverageHTML(cov *Coverage, dir string) ([]byte, error) {
+	files := make([]reportFile, len(cov.Files))
+	for i, f := range cov.Files {
+		files[i] = reportFile{FileCoverage: f, Anchor: fmt.Sprintf("file%d", i), Lines: sourceLines(dir, f)}
+	}
+	var buf bytes.Buffer
+	err := reportTemplate.Execute(&buf, struct {
+		*Coverage
+		Files []reportFile
+	}{cov, files})
+	if err != nil {
+		return nil, fmt.Errorf("gotest: render coverage: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sourceLines returns the lines of f's source classed by their coverage,
+// or nil for files outside the workspace or too large to show.
+func sourceLines(dir string, f FileCoverage) []reportLine {
+	p := filepath.FromSlash(f.Path)
+	if !filepath.IsLocal(p) {
+		return nil
+	}
+	fi, err := os.Stat(filepath.Join(dir, p))
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() > maxHTMLSource {
+		return nil
+	}
+	src, err := os.ReadFile(filepath.Join(dir, p))
+	if err != nil {
+		return nil
+	}
+	class := make(map[int]string)
+	for _, n := range f.CoveredLines {
+		class[n] = "covered"
+	}
+	for _, n := range f.UncoveredLines {
+		class[n] = "uncovered"
+	}
+	for _, n := range f.PartialLines {
+		class[n] = "partial"
+	}
+	text := strings.Split(strings.TrimSuffix(string(src), "\n"), "\n")
+	lines := make([]reportLine, len(text))
+	for i, t := range text {
+		lines[i] = reportLine{N: i + 1, Text: strings.TrimSuffix(t, "\r"), Class: class[i+1]}
+	}
+	return lines
+}
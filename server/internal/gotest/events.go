@@ -0,0 +1,192 @@
+package gotest
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Status is the outcome of a test or package.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// TestResult is the outcome of one test or subtest. Subtests are reported
+// under their full name, e.g. "TestParse/empty".
+type TestResult struct {
+	Name       string `json:"name"`
+	Status     Status `json:"status"`
+	DurationMS int64  `json:"durationMs"`
+	Output     string `json:"output"`
+	Truncated  bool   `json:"truncated,omitempty"`
+}
+
+// PackageResult collects the tests of one package. Output holds what the
+// package printed outside of any test, such as the final ok/FAIL line.
+type PackageResult struct {
+	Package    string       `json:"package"`
+	Status     Status       `json:"status"`
+	DurationMS int64        `json:"durationMs"`
+	Output     string       `json:"output"`
+	Truncated  bool         `json:"truncated,omitempty"`
+	Tests      []TestResult `json:"tests"`
+}
+
+// Summary counts the tests of a run by outcome.
+type Summary struct {
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+}
+
+// Report is the structured outcome of a go test run. Output holds build
+// errors and anything else go test printed outside of a package.
+type Report struct {
+	Passed     bool            `json:"passed"`
+	ExitCode   int             `json:"exitCode"`
+	TimedOut   bool            `json:"timedOut"`
+	DurationMS int64           `json:"durationMs"`
+	Summary    Summary         `json:"summary"`
+	Packages   []PackageResult `json:"packages"`
+	Output     string          `json:"output"`
+	Truncated  bool            `json:"truncated,omitempty"`
+	// Coverage is the percentage of statements covered, set for runs
+	// with Request.Cover.
+	Coverage *float64 `json:"coverage,omitempty"`
+}
+
+// event is one line of go test -json output; see cmd/test2json.
+type event struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// collector assembles a Report from go test -json output.
+type collector struct {
+	max    int
+	pkgs   []*pkgState
+	byPkg  map[string]*pkgState
+	output *limitedBuffer
+}
+
+type pkgState struct {
+	result PackageResult
+	out    *limitedBuffer
+	tests  []*testState
+	byName map[string]*testState
+}
+
+type testState struct {
+	result TestResult
+	out    *limitedBuffer
+}
+
+func newCollector(max int) *collector {
+	return &collector{max: max, byPkg: make(map[string]*pkgState), output: &limitedBuffer{max: max}}
+}
+
+// line consumes one line of output. Lines that are not events, which go
+// test prints for some build failures, are kept as plain output.
+func (c *collector) line(b []byte) {
+	var ev event
+	if !bytes.HasPrefix(b, []byte("{")) || json.Unmarshal(b, &ev) != nil {
+		c.output.Write(b)
+		c.output.Write([]byte("\n"))
+		return
+	}
+	switch {
+	case ev.Action == "build-output":
+		c.output.Write([]byte(ev.Output))
+	case ev.Package == "":
+	case ev.Test == "":
+		p := c.pkg(ev.Package)
+		switch ev.Action {
+		case "output":
+			p.out.Write([]byte(ev.Output))
+		case "pass", "fail", "skip":
+			p.result.Status = Status(ev.Action)
+			p.result.DurationMS = millis(ev.Elapsed)
+		}
+	default:
+		t := c.pkg(ev.Package).test(ev.Test)
+		switch ev.Action {
+		case "output":
+			t.out.Write([]byte(ev.Output))
+		case "pass", "fail", "skip":
+			t.result.Status = Status(ev.Action)
+			t.result.DurationMS = millis(ev.Elapsed)
+		}
+	}
+}
+
+func (c *collector) pkg(name string) *pkgState {
+	p, ok := c.byPkg[name]
+	if !ok {
+		p = &pkgState{
+			result: PackageResult{Package: name},
+			out:    &limitedBuffer{max: c.max},
+			byName: make(map[string]*testState),
+		}
+		c.byPkg[name] = p
+		c.pkgs = append(c.pkgs, p)
+	}
+	return p
+}
+
+func (p *pkgState) test(name string) *testState {
+	t, ok := p.byName[name]
+	if !ok {
+		t = &testState{result: TestResult{Name: name}, out: &limitedBuffer{max: p.out.max}}
+		p.byName[name] = t
+		p.tests = append(p.tests, t)
+	}
+	return t
+}
+
+// report returns the results in the order packages and tests started.
+// Tests without a result of their own take that of a passing package;
+// otherwise they never finished, because the binary panicked or was
+// killed, and count as failed.
+func (c *collector) report() *Report {
+	rep := &Report{Packages: make([]PackageResult, 0, len(c.pkgs))}
+	for _, p := range c.pkgs {
+		res := p.result
+		if res.Status == "" {
+			res.Status = StatusFail
+		}
+		res.Output, res.Truncated = p.out.String(), p.out.truncated
+		res.Tests = make([]TestResult, 0, len(p.tests))
+		for _, t := range p.tests {
+			tr := t.result
+			if tr.Status == "" {
+				tr.Status = StatusFail
+				if res.Status == StatusPass {
+					tr.Status = StatusPass // benchmarks only report the package result
+				}
+			}
+			tr.Output, tr.Truncated = t.out.String(), t.out.truncated
+			switch tr.Status {
+			case StatusPass:
+				rep.Summary.Passed++
+			case StatusFail:
+				rep.Summary.Failed++
+			case StatusSkip:
+				rep.Summary.Skipped++
+			}
+			res.Tests = append(res.Tests, tr)
+		}
+		rep.Packages = append(rep.Packages, res)
+	}
+	rep.Output, rep.Truncated = c.output.String(), c.output.truncated
+	return rep
+}
+
+func millis(seconds float64) int64 {
+	return int64(seconds * 1000)
+}
@@ -0,0 +1,431 @@
+package gotest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+)
+
+// CorpusDir is the workspace directory keeping the inputs fuzzing found
+// interesting, one subdirectory per package directory and fuzz target.
+// go test keeps them in its cache, which does not outlive the workspace's
+// container; they are copied in before each run and back out after it.
+const CorpusDir = ".fuzz"
+
+// FuzzRequest selects the fuzz target to run.
+type FuzzRequest struct {
+	// Module is the module directory as in Request, and Package the
+	// package of the target relative to it, such as "./parser"; it
+	// defaults to ".".
+	Module  string `json:"module,omitempty"`
+	Package string `json:"package,omitempty"`
+	// Target is the fuzz test, such as "FuzzParse".
+	Target string `json:"target"`
+	// DurationMS is how long to fuzz, passed to -fuzztime; defaults to
+	// Config.DefaultFuzzTime, up to Config.MaxFuzzTime.
+	DurationMS int64 `json:"durationMs,omitempty"`
+	// Parallel sets -parallel, the number of fuzzing workers; defaults to
+	// GOMAXPROCS.
+	Parallel int `json:"parallel,omitempty"`
+}
+
+// FuzzProgress is what go test last reported of a fuzzing run.
+type FuzzProgress struct {
+	ElapsedMS      int64 `json:"elapsedMs"`
+	Execs          int64 `json:"execs"`
+	ExecsPerSec    int64 `json:"execsPerSec"`
+	NewInteresting int   `json:"newInteresting"`
+	// TotalInteresting counts the corpus, seeds included.
+	TotalInteresting int `json:"totalInteresting"`
+	// Baseline is set while go test runs the existing corpus before
+	// fuzzing: how many entries it has run of how many.
+	Baseline *[2]int `json:"baseline,omitempty"`
+}
+
+// Crasher is an input that made the fuzz target fail. go test writes it
+// to the package's testdata/fuzz directory, where it reproduces the
+// failure on every go test run until it is fixed or removed.
+type Crasher struct {
+	ID string `json:"id"`
+	// File is the reproduction file relative to the workspace root, and
+	// Input its content: the values passed to the fuzz target.
+	File  string `json:"file"`
+	Input string `json:"input"`
+	// Output is what the failing target printed, its error included.
+	Output string `json:"output"`
+	// Command reruns the target on this input alone.
+	Command string `json:"command"`
+}
+
+// FuzzResult reports a finished fuzzing run.
+type FuzzResult struct {
+	Package    string `json:"package"`
+	Target     string `json:"target"`
+	Passed     bool   `json:"passed"`
+	ExitCode   int    `json:"exitCode"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+	// Progress is the last progress report, missing when fuzzing never
+	// started, as when the package does not build or a seed fails.
+	Progress *FuzzProgress `json:"progress,omitempty"`
+	Crashers []Crasher     `json:"crashers"`
+	// Corpus is the target's corpus after the run.
+	Corpus    *Corpus `json:"corpus,omitempty"`
+	Output    string  `json:"output"`
+	Truncated bool    `json:"truncated,omitempty"`
+}
+
+// FuzzEventType names a fuzzing run event.
+type FuzzEventType string
+
+const (
+	// FuzzProgressEvent carries each progress report.
+	FuzzProgressEvent FuzzEventType = "progress"
+	// FuzzInteresting is sent, with the progress, when fuzzing adds
+	// inputs to the corpus.
+	FuzzInteresting FuzzEventType = "interesting"
+	FuzzCrasher     FuzzEventType = "crasher"
+	// FuzzOutput carries output other than progress reports.
+	FuzzOutput FuzzEventType = "output"
+	FuzzDone   FuzzEventType = "done"
+)
+
+// FuzzEvent is one item of a fuzzing run's stream.
+type FuzzEvent struct {
+	Type     FuzzEventType `json:"type"`
+	Progress *FuzzProgress `json:"progress,omitempty"`
+	Crasher  *Crasher      `json:"crasher,omitempty"`
+	Data     string        `json:"data,omitempty"`
+	Result   *FuzzResult   `json:"result,omitempty"`
+}
+
+// CorpusFile is a file of a fuzz corpus, its Path relative to the
+// workspace root.
+type CorpusFile struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Corpus lists the inputs of a fuzz target: the seeds in testdata/fuzz,
+// crashers among them, and those fuzzing found, in CorpusDir.
+type Corpus struct {
+	Package   string       `json:"package"`
+	Target    string       `json:"target"`
+	Seeds     []CorpusFile `json:"seeds"`
+	Generated []CorpusFile `json:"generated"`
+	// GeneratedBytes totals the generated files.
+	GeneratedBytes int64 `json:"generatedBytes"`
+}
+
+var fuzzTargetRE = regexp.MustCompile(`^Fuzz[A-Za-z0-9_]*$`)
+
+// fuzzScript fuzzes target $3 of package $2 in module directory $1, with
+// the corpus in workspace directory $4 put in go test's cache first and
+// copied back after. go test's own flags follow.
+const fuzzScript = `mod=$1 pkg=$2 target=$3 corpus=$4
+shift 4
+cache=
+if imp=$(go list -C "$mod" "$pkg" 2>/dev/null) && gocache=$(go env GOCACHE) && [ -n "$gocache" ]; then
+	cache="$gocache/fuzz/$imp/$target"
+	rm -rf "$cache" && mkdir -p "$cache" || cache=
+	[ -n "$cache" ] && [ -d "$corpus" ] && cp -R "$corpus/." "$cache/"
+fi
+go test -C "$mod" -json -run='^$' -fuzz="^$target\$" "$@" "$pkg"
+rc=$?
+if [ -n "$cache" ] && [ -d "$cache" ]; then
+	mkdir -p "$corpus" && cp -R "$cache/." "$corpus/"
+fi
+exit $rc`
+
+// fuzzTarget validates a request's module, package and target in the
+// workspace at dir, returning the module directory, the package pattern
+// and the package's directory relative to the workspace root.
+func fuzzTarget(dir, module, pkg, target string) (mod, pattern, pkgDir string, err error) {
+	if pkg == "" {
+		pkg = "."
+	}
+	if !validPattern(pkg) || strings.Contains(pkg, "...") {
+		return "", "", "", fmt.Errorf("%w: package %q", ErrInvalidRequest, pkg)
+	}
+	if !fuzzTargetRE.MatchString(target) {
+		return "", "", "", fmt.Errorf("%w: fuzz target %q", ErrInvalidRequest, target)
+	}
+	if mod, err = moduleDir(dir, module); err != nil {
+		return "", "", "", err
+	}
+	return mod, pkg, path.Join(mod, pkg), nil
+}
+
+// Fuzz runs a fuzz target of the workspace at dir for the requested time,
+// passing its events to emit, which may be nil. A failing target is
+// reported through the result's Crashers; a non-nil error means fuzzing
+// could not be run.
+func (s *Service) Fuzz(ctx context.Context, workspaceID, dir string, req FuzzRequest, emit func(FuzzEvent)) (*FuzzResult, error) {
+	module, pkg, pkgDir, err := fuzzTarget(dir, req.Module, req.Package, req.Target)
+	if err != nil {
+		return nil, err
+	}
+	fuzztime := s.cfg.DefaultFuzzTime
+	if req.DurationMS > 0 {
+		fuzztime = time.Duration(req.DurationMS) * time.Millisecond
+	}
+	if fuzztime > s.cfg.MaxFuzzTime {
+		return nil, fmt.Errorf("%w: duration exceeds %s", ErrInvalidRequest, s.cfg.MaxFuzzTime)
+	}
+	if req.Parallel < 0 {
+		return nil, fmt.Errorf("%w: parallel %d", ErrInvalidRequest, req.Parallel)
+	}
+	if emit == nil {
+		emit = func(FuzzEvent) {}
+	}
+
+	if err := s.acquire(workspaceID); err != nil {
+		return nil, err
+	}
+	defer s.release(workspaceID)
+	if s.cfg.Queue != nil {
+		done, err := s.cfg.Queue.Acquire(ctx, runner.PriorityBatch, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+	}
+
+	// Besides fuzzing, a run compiles the package and minimizes what it
+	// finds, for up to a minute by default.
+	ctx, cancel := context.WithTimeout(ctx, fuzztime+3*time.Minute)
+	defer cancel()
+	corpus := path.Join(CorpusDir, pkgDir, req.Target)
+	args := []string{"-fuzztime=" + fuzztime.String()}
+	if req.Parallel > 0 {
+		args = append(args, "-parallel="+strconv.Itoa(req.Parallel))
+	}
+	argv := append([]string{"sh", "-c", fuzzScript, "sh", module, pkg, req.Target, corpus}, args...)
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &limitedBuffer{max: s.cfg.MaxOutputBytes}
+	cmd.Stderr = stderr
+	cmd.WaitDelay = 3 * time.Second
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("gotest: start go test: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+
+	f := &fuzzReader{
+		dir: dir, module: module, pkgDir: pkgDir, pkg: pkg, target: req.Target,
+		output: &limitedBuffer{max: s.cfg.MaxOutputBytes},
+		failed: &limitedBuffer{max: s.cfg.MaxOutputBytes},
+		emit:   emit,
+	}
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for sc.Scan() {
+		f.line(sc.Bytes())
+	}
+	io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+
+	res := &FuzzResult{
+		Package:    pkg,
+		Target:     req.Target,
+		DurationMS: time.Since(start).Milliseconds(),
+		Progress:   f.progress,
+		Crashers:   f.crashers,
+		Output:     f.output.String() + stderr.String(),
+		Truncated:  f.output.truncated || stderr.truncated,
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		res.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if !res.TimedOut {
+			return nil, ctx.Err()
+		}
+		res.ExitCode = -1
+	case err == nil:
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	default:
+		return nil, fmt.Errorf("gotest: go test: %w", err)
+	}
+	res.Passed = res.ExitCode == 0 && len(res.Crashers) == 0
+	if c, err := s.Corpus(dir, req.Module, pkg, req.Target); err == nil {
+		res.Corpus = c
+	}
+	emit(FuzzEvent{Type: FuzzDone, Result: res})
+	return res, nil
+}
+
+var (
+	fuzzProgressRE = regexp.MustCompile(`^fuzz: elapsed: (\d+)s, execs: (\d+) \((\d+)/sec\), new interesting: (\d+) \(total: (\d+)\)`)
+	fuzzBaselineRE = regexp.MustCompile(`^fuzz: elapsed: (\d+)s, gathering baseline coverage: (\d+)/(\d+) completed`)
+	fuzzFailingRE  = regexp.MustCompile(`Failing input written to (testdata/fuzz/\S+)`)
+)
+
+// fuzzReader turns the go test -json output of a fuzzing run into events.
+type fuzzReader struct {
+	dir, module, pkgDir, pkg, target string
+
+	output   *limitedBuffer // everything but progress reports
+	failed   *limitedBuffer // the target's output, for a crasher
+	progress *FuzzProgress
+	crashers []Crasher
+	emit     func(FuzzEvent)
+}
+
+func (f *fuzzReader) line(b []byte) {
+	var ev event
+	if !bytes.HasPrefix(b, []byte("{")) || json.Unmarshal(b, &ev) != nil {
+		f.text(string(b)+"\n", false)
+		return
+	}
+	switch ev.Action {
+	case "output", "build-output":
+		f.text(ev.Output, ev.Test == f.target || strings.HasPrefix(ev.Test, f.target+"/"))
+	}
+}
+
+func (f *fuzzReader) text(s string, ofTarget bool) {
+	line := strings.TrimSpace(s)
+	if p := parseFuzzProgress(line); p != nil {
+		prev := f.progress
+		f.progress = p
+		typ := FuzzProgressEvent
+		if p.Baseline == nil && (prev == nil && p.NewInteresting > 0 || prev != nil && p.NewInteresting > prev.NewInteresting) {
+			typ = FuzzInteresting
+		}
+		f.emit(FuzzEvent{Type: typ, Progress: p})
+		return
+	}
+	f.output.Write([]byte(s))
+	f.emit(FuzzEvent{Type: FuzzOutput, Data: s})
+	if m := fuzzFailingRE.FindStringSubmatch(line); m != nil {
+		f.crasher(m[1])
+		return
+	}
+	if ofTarget {
+		f.failed.Write([]byte(s))
+	}
+}
+
+// crasher reports the input go test wrote to file, relative to the
+// package directory.
+func (f *fuzzReader) crasher(file string) {
+	id := path.Base(file)
+	c := Crasher{
+		ID:      id,
+		File:    path.Join(f.pkgDir, file),
+		Output:  f.failed.String(),
+		Command: fmt.Sprintf("go test -run=%s/%s %s", f.target, id, f.pkg),
+	}
+	if f.module != "." {
+		c.Command = fmt.Sprintf("go test -C %s -run=%s/%s %s", f.module, f.target, id, f.pkg)
+	}
+	if data, err := os.ReadFile(filepath.Join(f.dir, filepath.FromSlash(c.File))); err == nil {
+		c.Input = string(data)
+	}
+	f.crashers = append(f.crashers, c)
+	f.emit(FuzzEvent{Type: FuzzCrasher, Crasher: &c})
+}
+
+func parseFuzzProgress(line string) *FuzzProgress {
+	atoi := func(s string) int64 { n, _ := strconv.ParseInt(s, 10, 64); return n }
+	if m := fuzzProgressRE.FindStringSubmatch(line); m != nil {
+		return &FuzzProgress{
+			ElapsedMS:        atoi(m[1]) * 1000,
+			Execs:            atoi(m[2]),
+			ExecsPerSec:      atoi(m[3]),
+			NewInteresting:   int(atoi(m[4])),
+			TotalInteresting: int(atoi(m[5])),
+		}
+	}
+	if m := fuzzBaselineRE.FindStringSubmatch(line); m != nil {
+		return &FuzzProgress{ElapsedMS: atoi(m[1]) * 1000, Baseline: &[2]int{int(atoi(m[2])), int(atoi(m[3]))}}
+	}
+	return nil
+}
+
+// Corpus lists the corpus of a fuzz target of the workspace at dir.
+func (s *Service) Corpus(dir, module, pkg, target string) (*Corpus, error) {
+	_, pkg, pkgDir, err := fuzzTarget(dir, module, pkg, target)
+	if err != nil {
+		return nil, err
+	}
+	s.corpusMu.Lock()
+	defer s.corpusMu.Unlock()
+	c := &Corpus{Package: pkg, Target: target}
+	if c.Seeds, _, err = corpusFiles(dir, path.Join(pkgDir, "testdata", "fuzz", target)); err != nil {
+		return nil, err
+	}
+	if c.Generated, c.GeneratedBytes, err = corpusFiles(dir, path.Join(CorpusDir, pkgDir, target)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ClearCorpus removes the inputs fuzzing found for a target, leaving its
+// seeds and crashers.
+func (s *Service) ClearCorpus(dir, module, pkg, target string) error {
+	_, _, pkgDir, err := fuzzTarget(dir, module, pkg, target)
+	if err != nil {
+		return err
+	}
+	s.corpusMu.Lock()
+	defer s.corpusMu.Unlock()
+	if err := os.RemoveAll(filepath.Join(dir, CorpusDir, filepath.FromSlash(pkgDir), target)); err != nil {
+		return fmt.Errorf("gotest: remove corpus: %w", err)
+	}
+	return nil
+}
+
+// corpusFiles lists the files of a corpus directory relative to the
+// workspace at dir, with their total size.
+func corpusFiles(dir, rel string) ([]CorpusFile, int64, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, filepath.FromSlash(rel)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []CorpusFile{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("gotest: read corpus: %w", err)
+	}
+	out := []CorpusFile{}
+	var total int64
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, CorpusFile{Name: e.Name(), Path: path.Join(rel, e.Name()), Size: fi.Size(), ModTime: fi.ModTime().UTC()})
+		total += fi.Size()
+	}
+	return out, total, nil
+}
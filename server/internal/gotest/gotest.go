@@ -0,0 +1,389 @@
+// Package gotest runs go test inside a workspace's environment and turns
+// its -json event stream into structured per-test results for the IDE's
+// test explorer, and runs benchmarks and fuzz targets.
+package gotest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gomod"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// MaxRuns caps concurrent test runs per workspace; defaults to 2.
+	MaxRuns int
+	// DefaultTimeout and MaxTimeout bound a run's wall-clock time;
+	// they default to 2 and 10 minutes.
+	DefaultTimeout time.Duration
+	MaxTimeout     time.Duration
+	// MaxOutputBytes caps the output kept per test and per package;
+	// defaults to 64 KiB.
+	MaxOutputBytes int
+	// HistoryDir stores benchmark runs, one subdirectory per workspace;
+	// defaults to a directory under the OS temp dir.
+	HistoryDir string
+	// MaxHistory is the number of benchmark runs kept per workspace;
+	// defaults to 50.
+	MaxHistory int
+	// DefaultFuzzTime and MaxFuzzTime bound how long a fuzz target runs;
+	// they default to 1 and 30 minutes.
+	DefaultFuzzTime time.Duration
+	MaxFuzzTime     time.Duration
+	// Queue, when set, admits test, benchmark and fuzzing runs as batch
+	// jobs, behind interactive runs.
+	Queue *runner.Queue
+}
+
+var (
+	// ErrInvalidRequest is returned for malformed package patterns or flags.
+	ErrInvalidRequest = errors.New("gotest: invalid request")
+	// ErrTooManyRuns is returned when a workspace is at Config.MaxRuns.
+	ErrTooManyRuns = errors.New("gotest: too many test runs")
+	// ErrNoCoverage is returned by Coverage before any run with Cover.
+	ErrNoCoverage = errors.New("gotest: no coverage recorded")
+)
+
+// Request selects the tests to run.
+type Request struct {
+	// Module is the directory of the module to test relative to the
+	// workspace root, for workspaces holding several; defaults to the
+	// root.
+	Module string `json:"module,omitempty"`
+	// Packages are package patterns relative to the module, such as
+	// "./..." or "./internal/store"; defaults to "./...", or at the root
+	// of a go.work workspace to every module it uses.
+	Packages []string `json:"packages,omitempty"`
+	// Run and Skip are passed to -run and -skip.
+	Run  string `json:"run,omitempty"`
+	Skip string `json:"skip,omitempty"`
+	// Short sets -short.
+	Short bool `json:"short,omitempty"`
+	// Cover records a coverage profile, which replaces the workspace's
+	// previous one in Service.Coverage.
+	Cover bool `json:"cover,omitempty"`
+	// TimeoutMS overrides Config.DefaultTimeout, up to Config.MaxTimeout.
+	TimeoutMS int64 `json:"timeoutMs,omitempty"`
+}
+
+// Service runs tests through a Launcher.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	mu       sync.Mutex
+	active   map[string]int
+	coverage map[string]*Coverage
+
+	historyMu sync.Mutex
+	corpusMu  sync.Mutex // removing fuzz corpora while listing them
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.MaxRuns <= 0 {
+		cfg.MaxRuns = 2
+	}
+	if cfg.DefaultTimeout <= 0 {
+		cfg.DefaultTimeout = 2 * time.Minute
+	}
+	if cfg.MaxTimeout <= 0 {
+		cfg.MaxTimeout = 10 * time.Minute
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 64 << 10
+	}
+	if cfg.HistoryDir == "" {
+		cfg.HistoryDir = filepath.Join(os.TempDir(), "webide-benchmarks")
+	}
+	if cfg.MaxHistory <= 0 {
+		cfg.MaxHistory = 50
+	}
+	if cfg.DefaultFuzzTime <= 0 {
+		cfg.DefaultFuzzTime = time.Minute
+	}
+	if cfg.MaxFuzzTime <= 0 {
+		cfg.MaxFuzzTime = 30 * time.Minute
+	}
+	return &Service{cfg: cfg, launcher: l, active: make(map[string]int), coverage: make(map[string]*Coverage)}
+}
+
+// Run runs go test for the workspace at dir. Failing tests and build
+// errors are reported through the Report; a non-nil error means the tests
+// could not be run at all.
+func (s *Service) Run(ctx context.Context, workspaceID, dir string, req Request) (*Report, error) {
+	timeout, err := s.timeout(req.TimeoutMS)
+	if err != nil {
+		return nil, err
+	}
+	module, pkgs, err := scope(dir, req.Module, req.Packages)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-json", "-timeout=" + timeout.String()}
+	if req.Run != "" {
+		args = append(args, "-run="+req.Run)
+	}
+	if req.Skip != "" {
+		args = append(args, "-skip="+req.Skip)
+	}
+	if req.Short {
+		args = append(args, "-short")
+	}
+	args = append(args, pkgs...)
+
+	argv := append([]string{"go", "test", "-C", module}, args...)
+	var cov *coverageReader
+	var hook func([]byte) bool
+	if req.Cover {
+		argv = append([]string{"sh", "-c", coverScript, "sh", module}, args...)
+		cov = &coverageReader{}
+		hook = cov.line
+	}
+	rep, err := s.exec(ctx, workspaceID, dir, argv, timeout, hook)
+	if err != nil {
+		return nil, err
+	}
+	if cov != nil && !rep.TimedOut {
+		if c := cov.coverage(s.launcher.Root(dir)); c != nil {
+			s.mu.Lock()
+			s.coverage[workspaceID] = c
+			s.mu.Unlock()
+			rep.Coverage = &c.Percent
+		}
+	}
+	if !rep.Passed {
+		failed := []string{}
+		for _, p := range rep.Packages {
+			if p.Status == StatusFail {
+				failed = append(failed, p.Package)
+			}
+		}
+		events.Publish(ctx, events.Event{Type: events.TestsFailed, Workspace: workspaceID, Data: map[string]any{
+			"summary":  rep.Summary,
+			"packages": failed,
+			"exitCode": rep.ExitCode,
+			"timedOut": rep.TimedOut,
+		}})
+	}
+	return rep, nil
+}
+
+// exec runs argv, a go test invocation with -json, and collects its
+// events. hook sees every line of output first; lines it reports as
+// consumed are not treated as events.
+func (s *Service) exec(ctx context.Context, workspaceID, dir string, argv []string, timeout time.Duration, hook func([]byte) bool) (*Report, error) {
+	if err := s.acquire(workspaceID); err != nil {
+		return nil, err
+	}
+	defer s.release(workspaceID)
+	if s.cfg.Queue != nil {
+		done, err := s.cfg.Queue.Acquire(ctx, runner.PriorityBatch, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+	}
+
+	// go test enforces -timeout on each test binary; the outer deadline
+	// also covers compilation.
+	ctx, cancel := context.WithTimeout(ctx, timeout+time.Minute)
+	defer cancel()
+
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &limitedBuffer{max: s.cfg.MaxOutputBytes}
+	cmd.Stderr = stderr
+	cmd.WaitDelay = 3 * time.Second
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("gotest: start go test: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+
+	c := newCollector(s.cfg.MaxOutputBytes)
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for sc.Scan() {
+		if hook != nil && hook(sc.Bytes()) {
+			continue
+		}
+		c.line(sc.Bytes())
+	}
+	io.Copy(io.Discard, stdout) // keep go test from blocking after a scan error
+	err = cmd.Wait()
+
+	rep := c.report()
+	rep.DurationMS = time.Since(start).Milliseconds()
+	rep.Output += stderr.String()
+	rep.Truncated = rep.Truncated || stderr.truncated
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		rep.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if !rep.TimedOut {
+			return nil, ctx.Err()
+		}
+		rep.ExitCode = -1
+	case err == nil:
+	case errors.As(err, &exitErr):
+		rep.ExitCode = exitErr.ExitCode()
+	default:
+		return nil, fmt.Errorf("gotest: go test: %w", err)
+	}
+	rep.Passed = rep.ExitCode == 0 && !rep.TimedOut && rep.Summary.Failed == 0
+	return rep, nil
+}
+
+// Coverage returns the profile of the workspace's latest run with Cover.
+func (s *Service) Coverage(workspaceID string) (*Coverage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.coverage[workspaceID]
+	if !ok {
+		return nil, ErrNoCoverage
+	}
+	return c, nil
+}
+
+// timeout resolves a requested timeout against the configured bounds.
+func (s *Service) timeout(ms int64) (time.Duration, error) {
+	timeout := s.cfg.DefaultTimeout
+	if ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	if timeout > s.cfg.MaxTimeout {
+		return 0, fmt.Errorf("%w: timeout exceeds %s", ErrInvalidRequest, s.cfg.MaxTimeout)
+	}
+	return timeout, nil
+}
+
+// scope validates a request's module and package patterns. go test runs
+// in the module's directory, passed to -C, which must be go test's first
+// flag. Without patterns it tests the whole module or, at the root of a
+// go.work workspace, every module the go.work uses: ./... matches nothing
+// there when the root is not a module itself.
+func scope(dir, module string, pkgs []string) (string, []string, error) {
+	module, err := moduleDir(dir, module)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, p := range pkgs {
+		if !validPattern(p) {
+			return "", nil, fmt.Errorf("%w: package %q", ErrInvalidRequest, p)
+		}
+	}
+	if len(pkgs) > 0 {
+		return module, pkgs, nil
+	}
+	if module == "." {
+		w, err := gomod.ReadWork(dir)
+		if err != nil {
+			return "", nil, err
+		}
+		if w != nil && len(w.Uses) > 0 {
+			return module, w.Patterns(), nil
+		}
+	}
+	return module, []string{"./..."}, nil
+}
+
+// moduleDir validates a module directory relative to the workspace at
+// dir, defaulting to the root.
+func moduleDir(dir, module string) (string, error) {
+	if module == "" || module == "." {
+		return ".", nil
+	}
+	m := path.Clean(strings.TrimPrefix(module, "./"))
+	if !validPattern("./"+m) || strings.Contains(m, "...") {
+		return "", fmt.Errorf("%w: module %q", ErrInvalidRequest, module)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(m), "go.mod")); err != nil {
+		return "", fmt.Errorf("%w: no go.mod in module %q", ErrInvalidRequest, m)
+	}
+	return m, nil
+}
+
+// validPattern reports whether p is a relative package pattern that go
+// test cannot mistake for a flag.
+func validPattern(p string) bool {
+	if p != "." && !strings.HasPrefix(p, "./") {
+		return false
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return false
+		}
+	}
+	for _, r := range p {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Service) acquire(workspaceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[workspaceID] >= s.cfg.MaxRuns {
+		return ErrTooManyRuns
+	}
+	s.active[workspaceID]++
+	return nil
+}
+
+func (s *Service) release(workspaceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[workspaceID]--; s.active[workspaceID] <= 0 {
+		delete(s.active, workspaceID)
+	}
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	max       int
+	buf       strings.Builder
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string { return b.buf.String() }
@@ -0,0 +1,403 @@
+package gotest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/artifact"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Artifacts stores coverage reports; *artifact.Store implements it.
+type Artifacts interface {
+	Put(ctx context.Context, a artifact.Artifact, ttl time.Duration, r io.Reader) (*artifact.Artifact, error)
+}
+
+// Handler serves the test API for workspaces.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	artifacts  Artifacts
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler running tests with svc and storing coverage
+// reports in artifacts. wsOpts configures the WebSocket upgrade for
+// streaming fuzzing runs and may be nil.
+func NewHandler(svc *Service, wm Workspaces, artifacts Artifacts, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, artifacts: artifacts, wsOpts: wsOpts}
+}
+
+// Register mounts the test routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/workspaces/{id}/tests", h.run)
+	mux.HandleFunc("GET /api/workspaces/{id}/coverage", h.coverage)
+	mux.HandleFunc("POST /api/workspaces/{id}/coverage/html", h.coverageReport)
+	mux.HandleFunc("POST /api/workspaces/{id}/benchmarks", h.bench)
+	mux.HandleFunc("GET /api/workspaces/{id}/benchmarks", h.benchRuns)
+	mux.HandleFunc("GET /api/workspaces/{id}/benchmarks/compare", h.compare)
+	mux.HandleFunc("GET /api/workspaces/{id}/benchmarks/{run}", h.benchRun)
+	mux.HandleFunc("POST /api/workspaces/{id}/fuzz", h.fuzz)
+	mux.HandleFunc("GET /ws/workspaces/{id}/fuzz", h.fuzzStream)
+	mux.HandleFunc("GET /api/workspaces/{id}/fuzz/corpus", h.corpus)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/fuzz/corpus", h.clearCorpus)
+}
+
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	var req Request
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rep, err := h.svc.Run(r.Context(), id, dir, req)
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, ErrTooManyRuns):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	case errors.Is(err, runner.ErrQueueFull):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		slog.Error("run tests", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not run tests")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, rep)
+}
+
+// coverage returns the latest coverage profile. ?path= limits it to one
+// file, for rendering the gutter of an open editor.
+func (h *Handler) coverage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	cov, err := h.svc.Coverage(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if p := r.URL.Query().Get("path"); p != "" {
+		filtered := *cov
+		filtered.Files = []FileCoverage{}
+		for _, f := range cov.Files {
+			if f.Path == p {
+				filtered.Files = append(filtered.Files, f)
+			}
+		}
+		cov = &filtered
+	}
+	httpx.JSON(w, http.StatusOK, cov)
+}
+
+// coverageReport renders the latest coverage profile as an HTML page and
+// stores it as an artifact, to download or share.
+func (h *Handler) coverageReport(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	cov, err := h.svc.Coverage(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	page, err := CoverageHTML(cov, dir)
+	if err == nil {
+		var a *artifact.Artifact
+		a, err = h.artifacts.Put(r.Context(), artifact.Artifact{
+			Kind:        artifact.KindCoverage,
+			Name:        "coverage.html",
+			ContentType: "text/html; charset=utf-8",
+			Workspace:   id,
+		}, 0, bytes.NewReader(page))
+		if err == nil {
+			httpx.JSON(w, http.StatusCreated, a)
+			return
+		}
+	}
+	slog.Error("store coverage report", "workspace", id, "err", err)
+	httpx.Error(w, http.StatusInternalServerError, "could not store coverage report")
+}
+
+func (h *Handler) bench(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	var req BenchRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	run, err := h.svc.Bench(r.Context(), id, dir, req)
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, ErrTooManyRuns):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	case errors.Is(err, runner.ErrQueueFull):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		slog.Error("run benchmarks", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not run benchmarks")
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, run)
+}
+
+func (h *Handler) benchRuns(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	runs, err := h.svc.BenchRuns(id)
+	if err != nil {
+		slog.Error("list benchmark runs", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not list benchmark runs")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"runs": runs})
+}
+
+func (h *Handler) benchRun(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	run, err := h.svc.BenchRun(id, r.PathValue("run"))
+	if !h.writeRunError(w, id, err) {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, run)
+}
+
+// compare diffs ?old= against ?new=. new defaults to the latest run and
+// old to the run before new.
+func (h *Handler) compare(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	q := r.URL.Query()
+	oldID, newID := q.Get("old"), q.Get("new")
+	if oldID == "" || newID == "" {
+		runs, err := h.svc.BenchRuns(id)
+		if !h.writeRunError(w, id, err) {
+			return
+		}
+		i := 0
+		if newID == "" {
+			if len(runs) > 0 {
+				newID = runs[0].ID
+			}
+		} else {
+			for i < len(runs) && runs[i].ID != newID {
+				i++
+			}
+		}
+		if oldID == "" && i+1 < len(runs) {
+			oldID = runs[i+1].ID
+		}
+		if oldID == "" || newID == "" {
+			httpx.Error(w, http.StatusNotFound, "need two benchmark runs to compare")
+			return
+		}
+	}
+	oldRun, err := h.svc.BenchRun(id, oldID)
+	if !h.writeRunError(w, id, err) {
+		return
+	}
+	newRun, err := h.svc.BenchRun(id, newID)
+	if !h.writeRunError(w, id, err) {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, Compare(oldRun, newRun))
+}
+
+// writeRunError reports err, if any, and returns whether the caller may
+// continue.
+func (h *Handler) writeRunError(w http.ResponseWriter, workspaceID string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrNoRun):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("load benchmark run", "workspace", workspaceID, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not load benchmark run")
+	}
+	return false
+}
+
+// fuzz runs the fuzz target in the body and returns its result once the
+// time is up or it fails.
+func (h *Handler) fuzz(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	var req FuzzRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res, err := h.svc.Fuzz(r.Context(), id, dir, req, nil)
+	if err != nil {
+		writeFuzzError(w, id, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// fuzzError reports a fuzzing run that could not be run.
+type fuzzError struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// fuzzStream runs ?module=&package=&target=&durationMs=&parallel= and sends its
+// FuzzEvents, one per text frame, closing the socket after the done
+// event. Closing the socket stops the run.
+func (h *Handler) fuzzStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	q := r.URL.Query()
+	req := FuzzRequest{Module: q.Get("module"), Package: q.Get("package"), Target: q.Get("target")}
+	if v := q.Get("durationMs"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			httpx.Error(w, http.StatusBadRequest, "invalid durationMs")
+			return
+		}
+		req.DurationMS = n
+	}
+	if v := q.Get("parallel"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpx.Error(w, http.StatusBadRequest, "invalid parallel")
+			return
+		}
+		req.Parallel = n
+	}
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	_, err = h.svc.Fuzz(ctx, id, dir, req, func(ev FuzzEvent) {
+		if werr := conn.WriteJSON(ev); werr != nil {
+			cancel()
+		}
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+		return
+	case errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrTooManyRuns), errors.Is(err, runner.ErrQueueFull):
+		conn.WriteJSON(fuzzError{Type: "error", Error: err.Error()})
+		return
+	case err != nil:
+		slog.Error("run fuzz target", "workspace", id, "err", err)
+		conn.WriteJSON(fuzzError{Type: "error", Error: "could not run fuzz target"})
+		return
+	}
+	conn.CloseWithCode(ws.CloseNormal, "fuzzing done")
+}
+
+// corpus lists the corpus of ?module=&package=&target=.
+func (h *Handler) corpus(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	q := r.URL.Query()
+	c, err := h.svc.Corpus(dir, q.Get("module"), q.Get("package"), q.Get("target"))
+	if err != nil {
+		writeFuzzError(w, id, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, c)
+}
+
+// clearCorpus removes the generated corpus of ?module=&package=&target=.
+func (h *Handler) clearCorpus(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	q := r.URL.Query()
+	if err := h.svc.ClearCorpus(dir, q.Get("module"), q.Get("package"), q.Get("target")); err != nil {
+		writeFuzzError(w, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeFuzzError(w http.ResponseWriter, workspaceID string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooManyRuns):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, runner.ErrQueueFull):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		slog.Error("fuzz", "workspace", workspaceID, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not run fuzz target")
+	}
+}
@@ -0,0 +1,224 @@
+package grpc
+
+// The messages and methods of package webide.v1, as proto/webide/v1 in
+// the server's tree defines them. A field's name is that of its REST
+// counterpart, which is also its JSON name in the proto definitions.
+
+var entry = &message{name: "Entry", fields: []field{
+	{num: 1, name: "name", kind: kindString},
+	{num: 2, name: "path", kind: kindString},
+	{num: 3, name: "type", kind: kindString},
+	{num: 4, name: "size", kind: kindInt64},
+	{num: 5, name: "mode", kind: kindString},
+	{num: 6, name: "modTime", kind: kindTimestamp},
+	{num: 7, name: "etag", kind: kindString, in: inHeader, param: "ETag"},
+}}
+
+var file = &message{name: "File", fields: []field{
+	{num: 1, name: "name", kind: kindString},
+	{num: 2, name: "path", kind: kindString},
+	{num: 3, name: "type", kind: kindString},
+	{num: 4, name: "size", kind: kindInt64},
+	{num: 5, name: "mode", kind: kindString},
+	{num: 6, name: "modTime", kind: kindTimestamp},
+	{num: 7, name: "etag", kind: kindString, in: inHeader, param: "ETag"},
+	{num: 8, name: "contentType", kind: kindString},
+	{num: 9, name: "binary", kind: kindBool},
+	{num: 10, name: "width", kind: kindInt64},
+	{num: 11, name: "height", kind: kindInt64},
+	{num: 12, name: "data", kind: kindBytes},
+}}
+
+var workspaceInfo = &message{name: "Workspace", fields: []field{
+	{num: 1, name: "id", kind: kindString},
+	{num: 2, name: "template", kind: kindString},
+	{num: 3, name: "files", kind: kindString, repeated: true},
+	{num: 4, name: "setup", kind: kindString, repeated: true},
+}}
+
+var limits = &message{name: "Limits", fields: []field{
+	{num: 1, name: "cpus", kind: kindDouble},
+	{num: 2, name: "memoryMb", kind: kindInt64},
+	{num: 3, name: "timeoutMs", kind: kindInt64},
+	{num: 4, name: "maxProcs", kind: kindInt64},
+	{num: 5, name: "outputBytes", kind: kindInt64},
+}}
+
+var position = &message{name: "Position", fields: []field{
+	{num: 1, name: "line", kind: kindInt64},
+	{num: 2, name: "column", kind: kindInt64},
+}}
+
+var diagnostic = &message{name: "Diagnostic", fields: []field{
+	{num: 1, name: "file", kind: kindString},
+	{num: 2, name: "range", kind: kindMessage, msg: &message{name: "Range", fields: []field{
+		{num: 1, name: "start", kind: kindMessage, msg: position},
+		{num: 2, name: "end", kind: kindMessage, msg: position},
+	}}},
+	{num: 3, name: "severity", kind: kindString},
+	{num: 4, name: "source", kind: kindString},
+	{num: 5, name: "code", kind: kindString},
+	{num: 6, name: "message", kind: kindString},
+}}
+
+var frame = &message{name: "Frame", fields: []field{
+	{num: 1, name: "function", kind: kindString},
+	{num: 2, name: "file", kind: kindString},
+	{num: 3, name: "line", kind: kindInt64},
+}}
+
+var runResult = &message{name: "RunResult", fields: []field{
+	{num: 1, name: "phase", kind: kindString},
+	{num: 2, name: "language", kind: kindString},
+	{num: 3, name: "goVersion", kind: kindString},
+	{num: 4, name: "stdout", kind: kindString},
+	{num: 5, name: "stderr", kind: kindString},
+	{num: 6, name: "exitCode", kind: kindInt64},
+	{num: 7, name: "timedOut", kind: kindBool},
+	{num: 8, name: "durationMs", kind: kindInt64},
+	{num: 9, name: "limits", kind: kindMessage, msg: limits},
+	{num: 10, name: "killed", kind: kindString},
+	{num: 11, name: "message", kind: kindString},
+	{num: 12, name: "outputTruncated", kind: kindBool},
+	{num: 13, name: "cached", kind: kindString},
+	{num: 14, name: "diagnostics", kind: kindMessage, repeated: true, msg: diagnostic},
+	{num: 15, name: "profile", kind: kindMessage, msg: &message{name: "Profile", fields: []field{
+		{num: 1, name: "id", kind: kindString},
+		{num: 2, name: "kind", kind: kindString},
+		{num: 3, name: "size", kind: kindInt64},
+		{num: 4, name: "sha256", kind: kindString},
+		{num: 5, name: "createdAt", kind: kindTimestamp},
+		{num: 6, name: "expiresAt", kind: kindTimestamp},
+	}}},
+	{num: 16, name: "panic", kind: kindMessage, msg: &message{name: "Panic", fields: []field{
+		{num: 1, name: "message", kind: kindString},
+		{num: 2, name: "fatal", kind: kindBool},
+		{num: 3, name: "signal", kind: kindString},
+		{num: 4, name: "goroutines", kind: kindMessage, repeated: true, msg: &message{name: "Goroutine", fields: []field{
+			{num: 1, name: "id", kind: kindInt64},
+			{num: 2, name: "state", kind: kindString},
+			{num: 3, name: "frames", kind: kindMessage, repeated: true, msg: frame},
+			{num: 4, name: "elided", kind: kindBool},
+			{num: 5, name: "createdBy", kind: kindMessage, msg: frame},
+			{num: 6, name: "parent", kind: kindInt64},
+		}}},
+	}}},
+}}
+
+var runRequest = &message{name: "RunRequest", fields: []field{
+	{num: 1, name: "language", kind: kindString},
+	{num: 2, name: "source", kind: kindString},
+	{num: 3, name: "files", kind: kindMessage, repeated: true, msg: &message{name: "ProjectFile", fields: []field{
+		{num: 1, name: "path", kind: kindString},
+		{num: 2, name: "content", kind: kindString},
+	}}},
+	{num: 4, name: "module", kind: kindString},
+	{num: 5, name: "main", kind: kindString},
+	{num: 6, name: "limits", kind: kindMessage, msg: limits},
+	{num: 7, name: "goVersion", kind: kindString},
+	{num: 8, name: "workspace", kind: kindString},
+	{num: 9, name: "options", kind: kindMessage, msg: &message{name: "BuildOptions", fields: []field{
+		{num: 1, name: "race", kind: kindBool},
+		{num: 2, name: "trimpath", kind: kindBool},
+		{num: 3, name: "tags", kind: kindString, repeated: true},
+		{num: 4, name: "gcflags", kind: kindString, repeated: true},
+		{num: 5, name: "ldflags", kind: kindString, repeated: true},
+	}}},
+	{num: 10, name: "profile", kind: kindString},
+	{num: 11, name: "output", kind: kindString},
+	{num: 12, name: "cacheOutput", kind: kindBool},
+}}
+
+var runEvent = &message{name: "RunEvent", fields: []field{
+	{num: 1, name: "type", kind: kindString},
+	{num: 2, name: "phase", kind: kindString},
+	{num: 3, name: "data", kind: kindString},
+	{num: 4, name: "rewrite", kind: kindBool},
+	{num: 5, name: "position", kind: kindInt64},
+	{num: 6, name: "result", kind: kindMessage, msg: runResult},
+}}
+
+// workspacePath is the field of requests naming their workspace.
+var workspacePath = field{num: 1, name: "workspace", kind: kindString, in: inPath}
+
+// filePath is the field of file requests naming the file.
+var filePath = field{num: 2, name: "path", kind: kindString, in: inPath}
+
+// methods are the RPCs of the API, by their gRPC path.
+var methods = map[string]*method{
+	"/webide.v1.Workspaces/ListWorkspaces": {
+		verb: "GET", path: "/api/workspaces",
+		in: &message{name: "ListWorkspacesRequest"},
+		out: &message{name: "ListWorkspacesResponse", fields: []field{
+			{num: 1, name: "workspaces", kind: kindMessage, repeated: true, msg: workspaceInfo},
+		}},
+	},
+	"/webide.v1.Workspaces/CreateWorkspace": {
+		verb: "POST", path: "/api/workspaces",
+		in: &message{name: "CreateWorkspaceRequest", fields: []field{
+			{num: 1, name: "id", kind: kindString},
+			{num: 2, name: "template", kind: kindString, in: inQuery},
+			{num: 3, name: "module", kind: kindString},
+			{num: 4, name: "goVersion", kind: kindString},
+		}},
+		out: workspaceInfo,
+	},
+	"/webide.v1.Files/ListFiles": {
+		verb: "GET", path: "/api/workspaces/{workspace}/files/{path...}",
+		in: &message{name: "ListFilesRequest", fields: []field{workspacePath, filePath}},
+		out: &message{name: "ListFilesResponse", fields: []field{
+			{num: 1, name: "path", kind: kindString},
+			{num: 2, name: "entries", kind: kindMessage, repeated: true, msg: entry},
+		}},
+	},
+	"/webide.v1.Files/GetFile": {
+		verb: "GET", path: "/api/workspaces/{workspace}/files/{path...}", query: "encoding=base64",
+		in:  &message{name: "GetFileRequest", fields: []field{workspacePath, filePath}},
+		out: file,
+	},
+	"/webide.v1.Files/PutFile": {
+		verb: "PUT", path: "/api/workspaces/{workspace}/files/{path...}",
+		in: &message{name: "PutFileRequest", fields: []field{
+			workspacePath, filePath,
+			{num: 3, name: "data", kind: kindBytes, in: inRaw},
+			{num: 4, name: "ifMatch", kind: kindString, in: inHeader, param: "If-Match"},
+			{num: 5, name: "ifNoneMatch", kind: kindString, in: inHeader, param: "If-None-Match"},
+		}},
+		out: entry,
+	},
+	"/webide.v1.Files/CreateDirectory": {
+		verb: "PUT", path: "/api/workspaces/{workspace}/files/{path...}", query: "type=dir",
+		in:  &message{name: "CreateDirectoryRequest", fields: []field{workspacePath, filePath}},
+		out: entry,
+	},
+	"/webide.v1.Files/DeleteFile": {
+		verb: "DELETE", path: "/api/workspaces/{workspace}/files/{path...}",
+		in: &message{name: "DeleteFileRequest", fields: []field{
+			workspacePath, filePath,
+			{num: 3, name: "recursive", kind: kindBool, in: inQuery},
+			{num: 4, name: "permanent", kind: kindBool, in: inQuery},
+		}},
+		out: &message{name: "DeleteFileResponse", fields: []field{
+			{num: 1, name: "path", kind: kindString},
+			{num: 2, name: "trash", kind: kindString},
+		}},
+	},
+	"/webide.v1.Files/MoveFile": {
+		verb: "POST", path: "/api/workspaces/{workspace}/move",
+		in: &message{name: "MoveFileRequest", fields: []field{
+			workspacePath,
+			{num: 2, name: "from", kind: kindString},
+			{num: 3, name: "to", kind: kindString},
+			{num: 4, name: "overwrite", kind: kindBool},
+		}},
+		out: entry,
+	},
+	"/webide.v1.Runs/Run": {
+		verb: "POST", path: "/api/run",
+		in: runRequest, out: runResult,
+	},
+	"/webide.v1.Runs/StreamRun": {
+		verb: "POST", path: "/api/run",
+		in: runRequest, out: runEvent, stream: true,
+	},
+}
@@ -0,0 +1,145 @@
+package grpc
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// protoField is a field of a message of webide.proto.
+type protoField struct {
+	typ      string
+	repeated bool
+	num      int
+}
+
+var (
+	protoMessage = regexp.MustCompile(`^message (\w+) \{(\})?$`)
+	protoFieldRe = regexp.MustCompile(`^(repeated )?([\w.]+) (\w+) = (\d+);$`)
+	protoRPC     = regexp.MustCompile(`^rpc (\w+)\((\w+)\) returns \((stream )?(\w+)\) \{$`)
+	protoHTTP    = regexp.MustCompile(`^option \(google\.api\.http\) = \{(\w+): "([^"]+)"`)
+	protoService = regexp.MustCompile(`^service (\w+) \{$`)
+)
+
+// readProto reads the messages and RPCs of webide.proto: the fields of
+// messages by their JSON names, and the RPCs as methods without messages.
+func readProto(t *testing.T) (map[string]map[string]protoField, map[string]method, map[string][2]string) {
+	t.Helper()
+	data, err := os.ReadFile("../../proto/webide/v1/webide.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make(map[string]map[string]protoField)
+	rpcs := make(map[string]method)
+	types := make(map[string][2]string)
+	var msg, service, rpc string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if m := protoService.FindStringSubmatch(line); m != nil {
+			service = m[1]
+		} else if m := protoRPC.FindStringSubmatch(line); m != nil {
+			rpc = "/webide.v1." + service + "/" + m[1]
+			rpcs[rpc] = method{stream: m[3] != ""}
+			types[rpc] = [2]string{m[2], m[4]}
+		} else if m := protoHTTP.FindStringSubmatch(line); m != nil {
+			r := rpcs[rpc]
+			r.verb, r.path = strings.ToUpper(m[1]), strings.ReplaceAll(m[2], "=**}", "...}")
+			rpcs[rpc] = r
+		} else if m := protoMessage.FindStringSubmatch(line); m != nil {
+			msg = m[1]
+			messages[msg] = make(map[string]protoField)
+		} else if m := protoFieldRe.FindStringSubmatch(line); m != nil && msg != "" {
+			num, _ := strconv.Atoi(m[4])
+			messages[msg][jsonName(m[3])] = protoField{typ: m[2], repeated: m[1] != "", num: num}
+		} else if line == "}" {
+			msg = ""
+		}
+	}
+	return messages, rpcs, types
+}
+
+// jsonName returns the JSON name protoc gives the field name.
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(c)))
+			upper = false
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+var protoKinds = map[string]kind{
+	"string": kindString, "bool": kindBool, "int64": kindInt64, "double": kindDouble,
+	"bytes": kindBytes, "google.protobuf.Timestamp": kindTimestamp,
+}
+
+// checkMessage checks m against its definition in webide.proto, and the
+// messages of its fields.
+func checkMessage(t *testing.T, protos map[string]map[string]protoField, m *message, seen map[*message]bool) {
+	if seen[m] {
+		return
+	}
+	seen[m] = true
+	want, ok := protos[m.name]
+	if !ok {
+		t.Errorf("message %s is not in webide.proto", m.name)
+		return
+	}
+	if len(m.fields) != len(want) {
+		t.Errorf("message %s has %d fields, webide.proto %d", m.name, len(m.fields), len(want))
+	}
+	for _, f := range m.fields {
+		p, ok := want[f.name]
+		switch {
+		case !ok:
+			t.Errorf("field %s.%s is not in webide.proto", m.name, f.name)
+			continue
+		case p.num != f.num:
+			t.Errorf("field %s.%s is number %d, webide.proto %d", m.name, f.name, f.num, p.num)
+		case p.repeated != f.repeated:
+			t.Errorf("field %s.%s repeated = %v, webide.proto %v", m.name, f.name, f.repeated, p.repeated)
+		}
+		if f.kind == kindMessage {
+			if p.typ != f.msg.name {
+				t.Errorf("field %s.%s is a %s, webide.proto a %s", m.name, f.name, f.msg.name, p.typ)
+			}
+			checkMessage(t, protos, f.msg, seen)
+		} else if k, ok := protoKinds[p.typ]; !ok || k != f.kind {
+			t.Errorf("field %s.%s has kind %d, webide.proto type %s", m.name, f.name, f.kind, p.typ)
+		}
+	}
+}
+
+func TestProtoDefinitions(t *testing.T) {
+	protos, rpcs, types := readProto(t)
+	if len(rpcs) != len(methods) {
+		t.Errorf("webide.proto has %d RPCs, methods %d", len(rpcs), len(methods))
+	}
+	seen := make(map[*message]bool)
+	for name, m := range methods {
+		rpc, ok := rpcs[name]
+		if !ok {
+			t.Errorf("%s is not in webide.proto", name)
+			continue
+		}
+		if rpc.verb != m.verb || rpc.path != m.path || rpc.stream != m.stream {
+			t.Errorf("%s maps to %s %s (stream %v), webide.proto %s %s (stream %v)",
+				name, m.verb, m.path, m.stream, rpc.verb, rpc.path, rpc.stream)
+		}
+		if types[name] != [2]string{m.in.name, m.out.name} {
+			t.Errorf("%s is %s to %s, webide.proto %v", name, m.in.name, m.out.name, types[name])
+		}
+		checkMessage(t, protos, m.in, seen)
+		checkMessage(t, protos, m.out, seen)
+	}
+}
@@ -0,0 +1,326 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// kind is the protocol buffer type of a field.
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt64
+	kindDouble
+	kindBytes
+	kindMessage
+	// kindTimestamp is a google.protobuf.Timestamp, an RFC 3339 string in
+	// JSON.
+	kindTimestamp
+)
+
+// place is where a field of a request goes in the REST request.
+type place int
+
+const (
+	inBody place = iota
+	inPath
+	inQuery
+	inHeader
+	// inRaw is a bytes field sent as the whole request body.
+	inRaw
+)
+
+// field is a field of a message, with the name of its REST counterpart.
+type field struct {
+	num      int
+	name     string
+	kind     kind
+	repeated bool
+	msg      *message
+	// in and param place the fields of requests: param is the name of the
+	// query parameter or header, and defaults to name.
+	in    place
+	param string
+}
+
+// message is a message type of the API.
+type message struct {
+	name   string
+	fields []field
+}
+
+func (m *message) field(num int) *field {
+	for i := range m.fields {
+		if m.fields[i].num == num {
+			return &m.fields[i]
+		}
+	}
+	return nil
+}
+
+// decode reads a message into the values of its fields by name: strings,
+// bools, int64s, float64s, []byte, time.Time, nested maps and slices of
+// them. Unknown fields are skipped.
+func (m *message) decode(data []byte) (map[string]any, error) {
+	out := make(map[string]any)
+	r := &reader{b: data}
+	for !r.done() {
+		num, wire, err := r.tag()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.name, err)
+		}
+		f := m.field(num)
+		if f == nil {
+			if err := r.skip(wire); err != nil {
+				return nil, fmt.Errorf("%s: %w", m.name, err)
+			}
+			continue
+		}
+		if f.repeated && wire == wireBytes && f.packable() {
+			b, err := r.bytes()
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", m.name, f.name, err)
+			}
+			items, _ := out[f.name].([]any)
+			for pr := (&reader{b: b}); !pr.done(); {
+				v, err := f.scalar(pr, f.wire())
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", m.name, f.name, err)
+				}
+				items = append(items, v)
+			}
+			out[f.name] = items
+			continue
+		}
+		if wire != f.wire() {
+			return nil, fmt.Errorf("%s.%s: wrong wire type %d", m.name, f.name, wire)
+		}
+		v, err := f.scalar(r, wire)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", m.name, f.name, err)
+		}
+		if f.repeated {
+			items, _ := out[f.name].([]any)
+			out[f.name] = append(items, v)
+		} else {
+			out[f.name] = v
+		}
+	}
+	return out, nil
+}
+
+func (f *field) packable() bool {
+	return f.kind == kindBool || f.kind == kindInt64 || f.kind == kindDouble
+}
+
+func (f *field) wire() int {
+	switch f.kind {
+	case kindBool, kindInt64:
+		return wireVarint
+	case kindDouble:
+		return wireFixed64
+	}
+	return wireBytes
+}
+
+// scalar reads one value of f.
+func (f *field) scalar(r *reader, wire int) (any, error) {
+	switch f.kind {
+	case kindBool:
+		v, err := r.varint()
+		return v != 0, err
+	case kindInt64:
+		v, err := r.varint()
+		return int64(v), err
+	case kindDouble:
+		v, err := r.fixed64()
+		return math.Float64frombits(v), err
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return nil, err
+	}
+	switch f.kind {
+	case kindString:
+		return string(b), nil
+	case kindBytes:
+		return append([]byte(nil), b...), nil
+	case kindTimestamp:
+		return decodeTimestamp(b)
+	}
+	return f.msg.decode(b)
+}
+
+func decodeTimestamp(b []byte) (time.Time, error) {
+	var secs, nanos int64
+	r := &reader{b: b}
+	for !r.done() {
+		num, wire, err := r.tag()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if wire != wireVarint {
+			if err := r.skip(wire); err != nil {
+				return time.Time{}, err
+			}
+			continue
+		}
+		v, err := r.varint()
+		if err != nil {
+			return time.Time{}, err
+		}
+		switch num {
+		case 1:
+			secs = int64(v)
+		case 2:
+			nanos = int64(int32(v))
+		}
+	}
+	return time.Unix(secs, nanos).UTC(), nil
+}
+
+// encode writes the JSON object v, decoded with UseNumber, as the message.
+// Fields the message lacks are dropped, and zero values left out, as
+// proto3 does.
+func (m *message) encode(b []byte, v map[string]any) ([]byte, error) {
+	for i := range m.fields {
+		f := &m.fields[i]
+		val, ok := v[f.name]
+		if !ok || val == nil {
+			continue
+		}
+		var err error
+		if !f.repeated {
+			b, err = f.append(b, val)
+		} else if items, ok := val.([]any); !ok {
+			err = fmt.Errorf("want a list")
+		} else if f.packable() {
+			var packed []byte
+			for _, item := range items {
+				if packed, err = f.appendValue(packed, item); err != nil {
+					break
+				}
+			}
+			if err == nil && len(packed) > 0 {
+				b = appendBytes(b, f.num, packed)
+			}
+		} else {
+			for _, item := range items {
+				if b, err = f.appendField(b, item); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", m.name, f.name, err)
+		}
+	}
+	return b, nil
+}
+
+// append writes a singular field unless it holds the zero value.
+func (f *field) append(b []byte, v any) ([]byte, error) {
+	if f.kind != kindMessage {
+		switch v {
+		case "", false, json.Number("0"):
+			return b, nil
+		}
+	}
+	return f.appendField(b, v)
+}
+
+// appendField writes one value of f with its tag.
+func (f *field) appendField(b []byte, v any) ([]byte, error) {
+	switch f.kind {
+	case kindBool, kindInt64:
+		b = appendTag(b, f.num, wireVarint)
+		return f.appendValue(b, v)
+	case kindDouble:
+		b = appendTag(b, f.num, wireFixed64)
+		return f.appendValue(b, v)
+	case kindString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("want a string")
+		}
+		return appendBytes(b, f.num, []byte(s)), nil
+	case kindBytes:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("want base64")
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("want base64")
+		}
+		return appendBytes(b, f.num, data), nil
+	case kindTimestamp:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("want a time")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("want a time")
+		}
+		var ts []byte
+		if secs := t.Unix(); secs != 0 {
+			ts = appendTag(ts, 1, wireVarint)
+			ts = appendVarint(ts, uint64(secs))
+		}
+		if nanos := t.Nanosecond(); nanos != 0 {
+			ts = appendTag(ts, 2, wireVarint)
+			ts = appendVarint(ts, uint64(nanos))
+		}
+		return appendBytes(b, f.num, ts), nil
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("want an object")
+	}
+	inner, err := f.msg.encode(nil, obj)
+	if err != nil {
+		return nil, err
+	}
+	return appendBytes(b, f.num, inner), nil
+}
+
+// appendValue writes a varint or fixed64 value of f without a tag.
+func (f *field) appendValue(b []byte, v any) ([]byte, error) {
+	switch f.kind {
+	case kindBool:
+		t, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("want a bool")
+		}
+		if t {
+			return appendVarint(b, 1), nil
+		}
+		return appendVarint(b, 0), nil
+	case kindInt64:
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("want a number")
+		}
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("want an integer, not %s", n)
+		}
+		return appendVarint(b, uint64(i)), nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("want a number")
+	}
+	d, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("want a number, not %s", n)
+	}
+	return appendFixed64(b, math.Float64bits(d)), nil
+}
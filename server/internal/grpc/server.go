@@ -0,0 +1,533 @@
+// Package grpc serves the core of the REST API, workspaces, files and
+// runs, over gRPC, for internal tools and clients that want a typed
+// interface. The messages and services are defined in proto/webide/v1.
+//
+// Each RPC is answered by the REST route it maps to, which its
+// google.api.http option names, so the two APIs cannot drift apart: the
+// request message is turned into the route's path, query, headers and
+// body, and passed through the server's handlers, authentication, access
+// checks and rate limits included, and the response is turned back into
+// the reply message. An HTTP error status becomes the matching gRPC code.
+// Runs stream their events with StreamRun, which reads the route's
+// newline-delimited JSON.
+//
+// gRPC needs HTTP/2, which the server speaks over plain TCP to clients
+// that start with it, and over TLS from a terminating proxy that forwards
+// HTTP/2. Messages are not compressed: a grpc-encoding other than identity
+// is refused.
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/apiversion"
+)
+
+// maxMessage caps the messages of requests and replies: a file written
+// with PutFile and its framing, or read with GetFile.
+const maxMessage = 33 << 20
+
+// Status codes of gRPC.
+const (
+	codeOK                 = 0
+	codeCanceled           = 1
+	codeUnknown            = 2
+	codeInvalidArgument    = 3
+	codeDeadlineExceeded   = 4
+	codeNotFound           = 5
+	codeAlreadyExists      = 6
+	codePermissionDenied   = 7
+	codeResourceExhausted  = 8
+	codeFailedPrecondition = 9
+	codeUnimplemented      = 12
+	codeInternal           = 13
+	codeUnavailable        = 14
+	codeUnauthenticated    = 16
+)
+
+// method is an RPC and the REST route it maps to.
+type method struct {
+	verb string
+	// path is the route's, with the request's path fields in braces;
+	// {name...} keeps the slashes of its value.
+	path string
+	// query is added to the route's query, such as to select an encoding.
+	query   string
+	in, out *message
+	// stream marks server-streaming RPCs, whose replies are the lines of
+	// the route's newline-delimited JSON.
+	stream bool
+}
+
+// Middleware serves the RPCs of the API, with next answering the REST
+// requests they map to, and passes other requests on to next.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isGRPC(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		serve(w, r, next)
+	})
+}
+
+func isGRPC(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return r.Method == http.MethodPost && r.ProtoMajor == 2 &&
+		(ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+proto") || strings.HasPrefix(ct, "application/grpc;"))
+}
+
+// status is the outcome of an RPC.
+type status struct {
+	code    int
+	message string
+}
+
+func serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	w.Header().Set("Content-Type", "application/grpc")
+	out := &replies{w: w}
+	m := methods[r.URL.Path]
+	if m == nil {
+		out.finish(status{codeUnimplemented, "unknown method " + r.URL.Path})
+		return
+	}
+	if enc := r.Header.Get("Grpc-Encoding"); enc != "" && enc != "identity" {
+		out.finish(status{codeUnimplemented, "compressed messages are not supported"})
+		return
+	}
+	ctx := r.Context()
+	if v := r.Header.Get("Grpc-Timeout"); v != "" {
+		d, err := parseTimeout(v)
+		if err != nil {
+			out.finish(status{codeInvalidArgument, err.Error()})
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	data, err := readMessage(r.Body)
+	if err != nil {
+		out.finish(status{codeInvalidArgument, err.Error()})
+		return
+	}
+	values, err := m.in.decode(data)
+	if err != nil {
+		out.finish(status{codeInvalidArgument, err.Error()})
+		return
+	}
+	req, err := m.request(ctx, r, values)
+	if err != nil {
+		out.finish(status{codeInvalidArgument, err.Error()})
+		return
+	}
+
+	rw := &transcoder{m: m, out: out, header: make(http.Header)}
+	next.ServeHTTP(rw, req)
+	st := rw.end()
+	if st.code != codeOK && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		st = status{codeDeadlineExceeded, "deadline exceeded"}
+	}
+	out.finish(st)
+}
+
+// readMessage reads the request's message, the only one of the unary and
+// server-streaming RPCs.
+func readMessage(body io.Reader) ([]byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(body, head[:]); err != nil {
+		return nil, errors.New("missing request message")
+	}
+	if head[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(head[1:])
+	if n > maxMessage {
+		return nil, fmt.Errorf("request message exceeds %d bytes", maxMessage)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(body, data); err != nil {
+		return nil, errors.New("truncated request message")
+	}
+	return data, nil
+}
+
+// parseTimeout reads a grpc-timeout, such as 100m for 100 milliseconds.
+func parseTimeout(v string) (time.Duration, error) {
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", v)
+	}
+	unit, ok := units[v[len(v)-1]]
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// request returns the REST request of the RPC r with the given values.
+func (m *method) request(ctx context.Context, r *http.Request, values map[string]any) (*http.Request, error) {
+	var path strings.Builder
+	rest := m.path
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			path.WriteString(rest)
+			break
+		}
+		end := strings.IndexByte(rest, '}')
+		path.WriteString(rest[:open])
+		name, wild := strings.CutSuffix(rest[open+1:end], "...")
+		v, _ := values[name].(string)
+		switch {
+		case wild:
+			segs := strings.Split(strings.Trim(v, "/"), "/")
+			for i, s := range segs {
+				segs[i] = url.PathEscape(s)
+			}
+			path.WriteString(strings.Join(segs, "/"))
+		case v == "":
+			return nil, fmt.Errorf("%s is required", name)
+		default:
+			path.WriteString(url.PathEscape(v))
+		}
+		rest = rest[end+1:]
+	}
+
+	query, _ := url.ParseQuery(m.query)
+	header := make(http.Header)
+	body := make(map[string]any)
+	var raw []byte
+	for _, f := range m.in.fields {
+		v, ok := values[f.name]
+		param := f.param
+		if param == "" {
+			param = f.name
+		}
+		switch {
+		case f.in == inPath:
+		case f.in == inRaw:
+			raw, _ = v.([]byte)
+		case !ok:
+		case f.in == inQuery:
+			for _, s := range queryValues(v) {
+				query.Add(param, s)
+			}
+		case f.in == inHeader:
+			for _, s := range queryValues(v) {
+				header.Add(param, s)
+			}
+		default:
+			body[f.name] = v
+		}
+	}
+
+	target := path.String()
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var content io.Reader = http.NoBody
+	contentType := ""
+	switch {
+	case m.hasRaw():
+		content, contentType = bytes.NewReader(raw), "application/octet-stream"
+	case m.hasBody():
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		content, contentType = bytes.NewReader(data), "application/json"
+	}
+	req, err := http.NewRequestWithContext(ctx, m.verb, target, content)
+	if err != nil {
+		return nil, err
+	}
+	// The request keeps its credentials and the client's address, for
+	// authentication and rate limits, and drops what describes gRPC.
+	for k, vs := range r.Header {
+		switch {
+		case k == "Content-Type", k == "Content-Length", k == "Te", k == "Trailer", strings.HasPrefix(k, "Grpc-"):
+		default:
+			req.Header[k] = vs
+		}
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	// The RPCs are those of v1 of the REST API, whichever version
+	// unversioned routes default to.
+	req.Header.Set(apiversion.Header, "v1")
+	if m.stream {
+		req.Header.Set("Accept", "application/x-ndjson")
+	}
+	req.Host, req.RemoteAddr, req.TLS = r.Host, r.RemoteAddr, r.TLS
+	req.Proto, req.ProtoMajor, req.ProtoMinor = r.Proto, r.ProtoMajor, r.ProtoMinor
+	return req, nil
+}
+
+func (m *method) hasRaw() bool {
+	for _, f := range m.in.fields {
+		if f.in == inRaw {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *method) hasBody() bool {
+	for _, f := range m.in.fields {
+		if f.in == inBody {
+			return true
+		}
+	}
+	return false
+}
+
+func queryValues(v any) []string {
+	switch v := v.(type) {
+	case []any:
+		var out []string
+		for _, item := range v {
+			out = append(out, queryValues(item)...)
+		}
+		return out
+	case string:
+		return []string{v}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'g', -1, 64)}
+	}
+	return nil
+}
+
+// replies writes the response of an RPC: its messages, then its status in
+// the trailers, or in the headers when it has no message.
+type replies struct {
+	w    http.ResponseWriter
+	sent bool
+}
+
+func (o *replies) send(msg []byte) error {
+	if !o.sent {
+		o.w.WriteHeader(http.StatusOK)
+		o.sent = true
+	}
+	var head [5]byte
+	binary.BigEndian.PutUint32(head[1:], uint32(len(msg)))
+	if _, err := o.w.Write(head[:]); err != nil {
+		return err
+	}
+	if _, err := o.w.Write(msg); err != nil {
+		return err
+	}
+	return http.NewResponseController(o.w).Flush()
+}
+
+func (o *replies) finish(s status) {
+	prefix := http.TrailerPrefix
+	if !o.sent {
+		prefix = ""
+	}
+	h := o.w.Header()
+	h.Set(prefix+"Grpc-Status", strconv.Itoa(s.code))
+	if s.message != "" {
+		h.Set(prefix+"Grpc-Message", encodeMessage(s.message))
+	}
+	if !o.sent {
+		o.w.WriteHeader(http.StatusOK)
+	}
+}
+
+// encodeMessage percent-encodes a grpc-message.
+func encodeMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// transcoder is the ResponseWriter of the REST request of an RPC. It
+// turns a successful response into the reply, as it is written for
+// streaming RPCs, and an error into the RPC's status.
+type transcoder struct {
+	m      *method
+	out    *replies
+	header http.Header
+	// code is the HTTP status, 0 until it is written.
+	code   int
+	body   bytes.Buffer
+	status status
+}
+
+func (t *transcoder) Header() http.Header { return t.header }
+
+func (t *transcoder) WriteHeader(code int) {
+	if t.code == 0 {
+		t.code = code
+	}
+}
+
+func (t *transcoder) Write(p []byte) (int, error) {
+	t.WriteHeader(http.StatusOK)
+	if t.body.Len()+len(p) > maxMessage {
+		return 0, errors.New("grpc: reply exceeds the message size limit")
+	}
+	t.body.Write(p)
+	if t.m.stream && t.lines() {
+		t.sendLines()
+	}
+	return len(p), nil
+}
+
+// Flush is a no-op: replies are sent as their lines are complete.
+func (t *transcoder) Flush() {}
+
+// lines reports that the response is the newline-delimited JSON of a
+// streaming RPC.
+func (t *transcoder) lines() bool {
+	return t.code == http.StatusOK && strings.HasPrefix(t.header.Get("Content-Type"), "application/x-ndjson")
+}
+
+// sendLines sends the complete lines written so far as replies. An error
+// line ends the RPC with the status it carries.
+func (t *transcoder) sendLines() {
+	for t.status.code == codeOK {
+		line, err := t.body.ReadBytes('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write.
+			rest := append(line, t.body.Bytes()...)
+			t.body.Reset()
+			t.body.Write(rest)
+			return
+		}
+		var v struct {
+			Type   string `json:"type"`
+			Error  string `json:"error"`
+			Status int    `json:"status"`
+		}
+		if json.Unmarshal(line, &v) == nil && v.Type == "error" {
+			code := codeUnknown
+			if v.Status != 0 {
+				code = codeFor(v.Status)
+			}
+			t.status = status{code, v.Error}
+			return
+		}
+		if err := t.reply(line); err != nil {
+			t.status = status{codeInternal, err.Error()}
+		}
+	}
+}
+
+// reply sends the JSON object data as a reply message.
+func (t *transcoder) reply(data []byte) error {
+	obj := make(map[string]any)
+	if len(bytes.TrimSpace(data)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&obj); err != nil {
+			return fmt.Errorf("decode %s: %w", t.m.out.name, err)
+		}
+	}
+	for _, f := range t.m.out.fields {
+		if f.in == inHeader {
+			if v := t.header.Get(f.param); v != "" {
+				obj[f.name] = v
+			}
+		}
+	}
+	msg, err := t.m.out.encode(nil, obj)
+	if err != nil {
+		return err
+	}
+	return t.out.send(msg)
+}
+
+// end returns the status of the RPC once the REST handler returned,
+// sending the reply of unary RPCs.
+func (t *transcoder) end() status {
+	switch {
+	case t.status.code != codeOK:
+		return t.status
+	case t.code == 0:
+		t.code = http.StatusOK
+	}
+	if t.code >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(t.body.Bytes(), &e) != nil || e.Error == "" {
+			e.Error = http.StatusText(t.code)
+		}
+		return status{codeFor(t.code), e.Error}
+	}
+	if t.m.stream && t.lines() {
+		if rest := bytes.TrimSpace(t.body.Bytes()); len(rest) > 0 {
+			t.body.WriteByte('\n')
+			t.sendLines()
+		}
+		return t.status
+	}
+	if err := t.reply(t.body.Bytes()); err != nil {
+		return status{codeInternal, err.Error()}
+	}
+	return status{}
+}
+
+// codeFor maps an HTTP status to a gRPC code, as gRPC gateways do.
+func codeFor(httpStatus int) int {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+		return codeInvalidArgument
+	case http.StatusUnauthorized:
+		return codeUnauthenticated
+	case http.StatusForbidden:
+		return codePermissionDenied
+	case http.StatusNotFound, http.StatusGone:
+		return codeNotFound
+	case http.StatusConflict:
+		return codeAlreadyExists
+	case http.StatusPreconditionFailed:
+		return codeFailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codeResourceExhausted
+	case 499:
+		return codeCanceled
+	case http.StatusNotImplemented:
+		return codeUnimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codeUnavailable
+	case http.StatusGatewayTimeout:
+		return codeDeadlineExceeded
+	}
+	if httpStatus >= 500 {
+		return codeInternal
+	}
+	return codeUnknown
+}
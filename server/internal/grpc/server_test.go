@@ -0,0 +1,253 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCodeFor(t *testing.T) {
+	tests := []struct {
+		status, want int
+	}{
+		{400, codeInvalidArgument},
+		{401, codeUnauthenticated},
+		{403, codePermissionDenied},
+		{404, codeNotFound},
+		{409, codeAlreadyExists},
+		{410, codeNotFound},
+		{412, codeFailedPrecondition},
+		{413, codeResourceExhausted},
+		{415, codeInvalidArgument},
+		{418, codeUnknown},
+		{429, codeResourceExhausted},
+		{499, codeCanceled},
+		{500, codeInternal},
+		{501, codeUnimplemented},
+		{502, codeUnavailable},
+		{503, codeUnavailable},
+		{504, codeDeadlineExceeded},
+		{507, codeInternal},
+	}
+	for _, tt := range tests {
+		if got := codeFor(tt.status); got != tt.want {
+			t.Errorf("codeFor(%d) = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeMessage(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"not found", "not found"},
+		{"100% done", "100%25 done"},
+		{"line\nbreak", "line%0Abreak"},
+		{"é", "%C3%A9"},
+	}
+	for _, tt := range tests {
+		if got := encodeMessage(tt.in); got != tt.want {
+			t.Errorf("encodeMessage(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"100m", 100 * time.Millisecond},
+		{"1H", time.Hour},
+		{"5S", 5 * time.Second},
+		{"99999999n", 99999999},
+	}
+	for _, tt := range tests {
+		if got, err := parseTimeout(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseTimeout(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "m", "10", "10s", "-1S", "1000000000S"} {
+		if _, err := parseTimeout(in); err == nil {
+			t.Errorf("parseTimeout(%q) succeeded", in)
+		}
+	}
+}
+
+// call makes the RPC name with the request message msg against next.
+func call(t *testing.T, next http.Handler, name string, msg []byte) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	var head [5]byte
+	binary.BigEndian.PutUint32(head[1:], uint32(len(msg)))
+	body.Write(head[:])
+	body.Write(msg)
+	r := httptest.NewRequest(http.MethodPost, name, &body)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0
+	r.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	Middleware(next).ServeHTTP(w, r)
+	return w.Result()
+}
+
+// replyMessages splits the body of an RPC's response into its messages.
+func replyMessages(t *testing.T, resp *http.Response) [][]byte {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out [][]byte
+	for len(data) > 0 {
+		if len(data) < 5 || data[0] != 0 {
+			t.Fatalf("bad reply framing % X", data)
+		}
+		n := int(binary.BigEndian.Uint32(data[1:5]))
+		out = append(out, data[5:5+n])
+		data = data[5+n:]
+	}
+	return out
+}
+
+func grpcStatus(h http.Header) (int, string) {
+	code, err := strconv.Atoi(h.Get("Grpc-Status"))
+	if err != nil {
+		return -1, ""
+	}
+	return code, h.Get("Grpc-Message")
+}
+
+func getFileRequest() []byte {
+	b := appendBytes(nil, 1, []byte("ws1"))
+	return appendBytes(b, 2, []byte("src/main.go"))
+}
+
+func TestUnary(t *testing.T) {
+	var got *http.Request
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+		fmt.Fprint(w, `{"name":"main.go","path":"src/main.go","size":12,"data":"cGFja2FnZSBtYWlu","binary":false}`)
+	})
+	resp := call(t, next, "/webide.v1.Files/GetFile", getFileRequest())
+	if got == nil {
+		t.Fatal("REST handler not called")
+	}
+	if got.Method != "GET" || got.URL.Path != "/api/workspaces/ws1/files/src/main.go" || got.URL.Query().Get("encoding") != "base64" {
+		t.Errorf("REST request = %s %s", got.Method, got.URL)
+	}
+	if code, msg := grpcStatus(resp.Header); code != -1 {
+		t.Errorf("status %d %q in the headers of a reply", code, msg)
+	}
+	msgs := replyMessages(t, resp)
+	if len(msgs) != 1 {
+		t.Fatalf("%d replies, want 1", len(msgs))
+	}
+	if code, msg := grpcStatus(resp.Trailer); code != codeOK {
+		t.Errorf("trailer status = %d %q, want OK", code, msg)
+	}
+	v, err := file.decode(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"name": "main.go", "path": "src/main.go", "size": int64(12), "data": []byte("package main"), "etag": `"abc"`}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("reply = %v, want %v", v, want)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		code   int
+		msg    string
+	}{
+		{404, `{"error":"file not found"}`, codeNotFound, "file not found"},
+		{412, `{"error":"etag mismatch"}`, codeFailedPrecondition, "etag mismatch"},
+		{429, `{"error":"100% of quota"}`, codeResourceExhausted, "100%25 of quota"},
+		{500, `not json`, codeInternal, "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(tt.status)
+			io.WriteString(w, tt.body)
+		})
+		resp := call(t, next, "/webide.v1.Files/GetFile", getFileRequest())
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("HTTP %d: status %d, want 200", tt.status, resp.StatusCode)
+		}
+		if code, msg := grpcStatus(resp.Header); code != tt.code || msg != tt.msg {
+			t.Errorf("HTTP %d: status = %d %q, want %d %q", tt.status, code, msg, tt.code, tt.msg)
+		}
+		if msgs := replyMessages(t, resp); len(msgs) != 0 {
+			t.Errorf("HTTP %d: %d replies, want none", tt.status, len(msgs))
+		}
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("REST handler called for %s", r.URL)
+	})
+	tests := []struct {
+		name string
+		msg  []byte
+		code int
+	}{
+		{"/webide.v1.Files/Nope", nil, codeUnimplemented},
+		{"/webide.v1.Files/GetFile", appendTag(nil, 1, wireVarint), codeInvalidArgument},
+		{"/webide.v1.Files/GetFile", append(appendTag(nil, 1, wireVarint), 1), codeInvalidArgument},
+	}
+	for _, tt := range tests {
+		resp := call(t, next, tt.name, tt.msg)
+		if code, msg := grpcStatus(resp.Header); code != tt.code {
+			t.Errorf("%s % X: status = %d %q, want %d", tt.name, tt.msg, code, msg, tt.code)
+		}
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"type":"phase","phase":"build"}`+"\n")
+		io.WriteString(w, `{"type":"stdout","data":"hi`)
+		io.WriteString(w, `"}`+"\n"+`{"type":"error","error":"run queue full","status":503}`+"\n")
+		io.WriteString(w, `{"type":"stdout","data":"never"}`+"\n")
+	})
+	resp := call(t, next, "/webide.v1.Runs/StreamRun", appendBytes(nil, 2, []byte("package main")))
+	msgs := replyMessages(t, resp)
+	var got []map[string]any
+	for _, m := range msgs {
+		v, err := runEvent.decode(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	want := []map[string]any{{"type": "phase", "phase": "build"}, {"type": "stdout", "data": "hi"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replies = %v, want %v", got, want)
+	}
+	if code, msg := grpcStatus(resp.Trailer); code != codeUnavailable || msg != "run queue full" {
+		t.Errorf("trailer status = %d %q, want %d", code, msg, codeUnavailable)
+	}
+}
+
+func TestNotGRPC(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	r := httptest.NewRequest(http.MethodPost, "/webide.v1.Files/GetFile", nil)
+	r.Header.Set("Content-Type", "application/grpc")
+	Middleware(next).ServeHTTP(httptest.NewRecorder(), r)
+	if !called {
+		t.Error("HTTP/1.1 request not passed on")
+	}
+}
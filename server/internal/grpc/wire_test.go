@@ -0,0 +1,208 @@
+package grpc
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVarint(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, 1<<32 - 1, 1 << 35, math.MaxInt64, math.MaxUint64} {
+		b := appendVarint(nil, v)
+		r := &reader{b: append(b, 0xAA)}
+		got, err := r.varint()
+		if err != nil || got != v {
+			t.Errorf("varint %d round trip = %d, %v", v, got, err)
+		}
+		if len(r.b) != 1 {
+			t.Errorf("varint %d left %d bytes, want 1", v, len(r.b))
+		}
+	}
+	// 300 is the example of the encoding documentation.
+	if b := appendVarint(nil, 300); !reflect.DeepEqual(b, []byte{0xAC, 0x02}) {
+		t.Errorf("varint 300 = % X, want AC 02", b)
+	}
+	for _, b := range [][]byte{nil, {0x80}, {0xFF, 0xFF}, {0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}} {
+		if _, err := (&reader{b: b}).varint(); err == nil {
+			t.Errorf("varint of % X succeeded", b)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	b := appendTag(nil, 12, wireBytes)
+	num, wire, err := (&reader{b: b}).tag()
+	if err != nil || num != 12 || wire != wireBytes {
+		t.Errorf("tag = %d, %d, %v", num, wire, err)
+	}
+	if _, _, err := (&reader{b: []byte{0x02}}).tag(); err == nil {
+		t.Error("field number 0 accepted")
+	}
+}
+
+func TestLengthDelimited(t *testing.T) {
+	b := appendBytes(nil, 1, []byte("hello"))
+	b = appendBytes(b, 2, nil)
+	r := &reader{b: b}
+	for _, want := range []string{"hello", ""} {
+		if _, _, err := r.tag(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.bytes()
+		if err != nil || string(got) != want {
+			t.Errorf("bytes = %q, %v, want %q", got, err, want)
+		}
+	}
+	if !r.done() {
+		t.Error("bytes left over")
+	}
+	if _, err := (&reader{b: []byte{5, 'a', 'b'}}).bytes(); err == nil {
+		t.Error("length beyond the message accepted")
+	}
+}
+
+func TestSkip(t *testing.T) {
+	var b []byte
+	b = appendTag(b, 90, wireVarint)
+	b = appendVarint(b, 1<<40)
+	b = appendTag(b, 91, wireFixed64)
+	b = appendFixed64(b, 7)
+	b = appendBytes(b, 92, []byte("unknown"))
+	b = appendTag(b, 93, wireFixed32)
+	b = append(b, 1, 2, 3, 4)
+	b = appendBytes(b, 2, []byte("main.go"))
+	got, err := entry.decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"path": "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decode with unknown fields = %v, want %v", got, want)
+	}
+	for _, bad := range [][]byte{
+		append(appendTag(nil, 90, wireFixed32), 1, 2),
+		appendTag(nil, 90, wireFixed64),
+		appendTag(nil, 90, 3), // groups
+		append(appendTag(nil, 90, wireBytes), 9, 'x'),
+	} {
+		if _, err := entry.decode(bad); err == nil {
+			t.Errorf("decode of % X succeeded", bad)
+		}
+	}
+}
+
+func TestDecodeWrongWire(t *testing.T) {
+	b := appendTag(nil, 4, wireBytes) // size is a varint
+	b = append(b, 0)
+	if _, err := entry.decode(b); err == nil {
+		t.Error("decode of a field with the wrong wire type succeeded")
+	}
+}
+
+// jsonObject decodes s as the transcoder decodes REST responses.
+func jsonObject(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var v map[string]any
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	v := jsonObject(t, `{
+		"phase": "run", "exitCode": -1, "timedOut": true, "durationMs": 1500, "stdout": "héllo\n",
+		"limits": {"cpus": 0.5, "memoryMb": 256},
+		"diagnostics": [
+			{"file": "main.go", "range": {"start": {"line": 3, "column": 1}, "end": {"line": 3, "column": 9}}, "message": "unused"},
+			{"file": "x.go", "severity": "warning"}
+		],
+		"profile": {"id": "p1", "createdAt": "2026-10-14T10:12:03.5Z", "expiresAt": "1969-12-31T23:59:58Z"},
+		"panic": {"goroutines": [{"id": 1, "frames": [{"function": "main.main", "line": 7}], "createdBy": {"function": "f"}}]},
+		"extra": "dropped", "message": "", "outputTruncated": false
+	}`)
+	b, err := runResult.encode(nil, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := runResult.decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"phase": "run", "exitCode": int64(-1), "timedOut": true, "durationMs": int64(1500), "stdout": "héllo\n",
+		"limits": map[string]any{"cpus": 0.5, "memoryMb": int64(256)},
+		"diagnostics": []any{
+			map[string]any{"file": "main.go", "message": "unused", "range": map[string]any{
+				"start": map[string]any{"line": int64(3), "column": int64(1)},
+				"end":   map[string]any{"line": int64(3), "column": int64(9)},
+			}},
+			map[string]any{"file": "x.go", "severity": "warning"},
+		},
+		"profile": map[string]any{
+			"id":        "p1",
+			"createdAt": time.Date(2026, 10, 14, 10, 12, 3, 5e8, time.UTC),
+			"expiresAt": time.Date(1969, 12, 31, 23, 59, 58, 0, time.UTC),
+		},
+		"panic": map[string]any{"goroutines": []any{map[string]any{
+			"id":        int64(1),
+			"frames":    []any{map[string]any{"function": "main.main", "line": int64(7)}},
+			"createdBy": map[string]any{"function": "f"},
+		}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestPackedRepeated(t *testing.T) {
+	m := &message{name: "M", fields: []field{
+		{num: 1, name: "n", kind: kindInt64, repeated: true},
+		{num: 2, name: "s", kind: kindString, repeated: true},
+	}}
+	b, err := m.encode(nil, jsonObject(t, `{"n": [1, 300, -2], "s": ["a", "", "b"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"n": []any{int64(1), int64(300), int64(-2)}, "s": []any{"a", "", "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %v, want %v", got, want)
+	}
+	// Decoders must also take unpacked repeated scalars.
+	var unpacked []byte
+	for _, n := range []uint64{4, 5} {
+		unpacked = appendTag(unpacked, 1, wireVarint)
+		unpacked = appendVarint(unpacked, n)
+	}
+	got, err = m.decode(unpacked)
+	if err != nil || !reflect.DeepEqual(got["n"], []any{int64(4), int64(5)}) {
+		t.Errorf("unpacked decode = %v, %v", got, err)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, s := range []string{
+		`{"exitCode": "1"}`,
+		`{"exitCode": 1.5}`,
+		`{"timedOut": "yes"}`,
+		`{"stdout": 3}`,
+		`{"limits": [1]}`,
+		`{"diagnostics": {"file": "x"}}`,
+		`{"profile": {"createdAt": "yesterday"}}`,
+	} {
+		if _, err := runResult.encode(nil, jsonObject(t, s)); err == nil {
+			t.Errorf("encode of %s succeeded", s)
+		}
+	}
+	if _, err := file.encode(nil, jsonObject(t, `{"data": "not base64!"}`)); err == nil {
+		t.Error("encode of invalid base64 succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+// Package health serves the probes of load balancers and orchestrators:
+// /healthz answers while the process serves requests at all, and /readyz
+// only while the services it depends on, such as the database, answer it.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// timeout bounds each readiness check.
+const timeout = 3 * time.Second
+
+// Checker runs the readiness checks of the server's dependencies.
+type Checker struct {
+	mu     sync.Mutex
+	checks []check
+}
+
+type check struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// New returns a Checker without checks.
+func New() *Checker { return &Checker{} }
+
+// Add adds a readiness check named name. fn fails when the dependency
+// cannot be used.
+func (c *Checker) Add(name string, fn func(ctx context.Context) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name, fn})
+}
+
+// Register mounts /healthz and /readyz on mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		httpx.JSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /readyz", c.ready)
+}
+
+// Check runs the checks at once and returns the error of each, "ok" for
+// those that pass, and whether all did.
+func (c *Checker) Check(ctx context.Context) (map[string]string, bool) {
+	c.mu.Lock()
+	checks := c.checks
+	c.mu.Unlock()
+
+	results := make(map[string]string, len(checks))
+	ok := true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ch := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			err := ch.fn(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			results[ch.name] = "ok"
+			if err != nil {
+				results[ch.name] = err.Error()
+				ok = false
+			}
+		}()
+	}
+	wg.Wait()
+	return results, ok
+}
+
+func (c *Checker) ready(w http.ResponseWriter, r *http.Request) {
+	results, ok := c.Check(r.Context())
+	if !ok {
+		httpx.JSON(w, http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "checks": results})
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"status": "ok", "checks": results})
+}
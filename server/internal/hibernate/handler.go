@@ -0,0 +1,37 @@
+package hibernate
+
+import (
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves workspace lifecycle status.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler reporting on svc's workspaces.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the lifecycle route on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/lifecycle", h.status)
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, h.svc.Status(id))
+}
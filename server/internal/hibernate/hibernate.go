@@ -0,0 +1,340 @@
+// Package hibernate stops the containers of idle workspaces, so abandoned
+// sessions do not hold on to a server's CPU and memory, and lets them
+// resume on the next request.
+//
+// A workspace is busy while a request for it is being served, which
+// includes its open editor, terminal, debugger and language server
+// sockets, and while Config.Busy says so. Once it has been idle for
+// Config.IdleTimeout its container is stopped. A stopped container keeps
+// its file system, which is the workspace's snapshot: tools installed
+// outside the workspace directory survive and only processes are lost.
+// The next command run in the workspace starts the container again.
+package hibernate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the list of hibernated workspaces; defaults to a directory
+	// under the OS temp dir.
+	Dir string
+	// IdleTimeout is how long a workspace may be idle before it
+	// hibernates; defaults to 30 minutes.
+	IdleTimeout time.Duration
+	// Interval is how often containers are checked; defaults to a minute.
+	Interval time.Duration
+	// Busy, when set, reports activity that is not a request, such as
+	// terminal sessions left running without a client.
+	Busy func(workspaceID string) bool
+}
+
+// Containers manages workspace containers. *terminal.DockerLauncher and
+// *terminal.KubernetesLauncher implement it.
+type Containers interface {
+	// Running lists the workspaces whose containers are running.
+	Running(ctx context.Context) ([]string, error)
+	// Stop stops a workspace's container, keeping its file system.
+	Stop(ctx context.Context, workspaceID string) error
+}
+
+// States of a workspace, for Status.
+const (
+	StateActive     = "active"
+	StateIdle       = "idle"
+	StateHibernated = "hibernated"
+)
+
+// Status describes the lifecycle of a workspace.
+type Status struct {
+	State string `json:"state"`
+	// LastActive is when the workspace was last seen busy since the
+	// server started.
+	LastActive   *time.Time `json:"lastActive,omitempty"`
+	HibernatedAt *time.Time `json:"hibernatedAt,omitempty"`
+}
+
+// Service tracks workspace activity and hibernates idle workspaces.
+type Service struct {
+	cfg        Config
+	containers Containers
+	now        func() time.Time
+
+	mu         sync.Mutex
+	workspaces map[string]*activity
+	hibernated map[string]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type activity struct {
+	requests int
+	last     time.Time
+	// stopping is closed once the container being stopped has stopped.
+	stopping chan struct{}
+}
+
+// New returns a Service, filling unset Config fields with defaults, and
+// starts checking c's containers.
+func New(cfg Config, c Containers) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-hibernate")
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 30 * time.Minute
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("hibernate: create dir: %w", err)
+	}
+	s := &Service{
+		cfg:        cfg,
+		containers: c,
+		now:        time.Now,
+		workspaces: make(map[string]*activity),
+		hibernated: make(map[string]time.Time),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	data, err := os.ReadFile(s.path())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("hibernate: read state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &s.hibernated); err != nil {
+			return nil, fmt.Errorf("hibernate: read state: %w", err)
+		}
+	}
+	go s.loop()
+	return s, nil
+}
+
+// Close stops checking containers. Hibernated workspaces stay so.
+func (s *Service) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+// Status returns the lifecycle of a workspace.
+func (s *Service) Status(workspaceID string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Status{State: StateIdle}
+	if a, ok := s.workspaces[workspaceID]; ok {
+		last := a.last
+		st.LastActive = &last
+		if a.requests > 0 {
+			st.State = StateActive
+		}
+	}
+	if at, ok := s.hibernated[workspaceID]; ok {
+		st.State, st.HibernatedAt = StateHibernated, &at
+	}
+	return st
+}
+
+// Middleware counts every request for a workspace as activity. A
+// request that arrives while its workspace's container is being stopped
+// waits for that to finish, so the container is started again rather
+// than stopped under it.
+func Middleware(s *Service, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := workspaceOf(r)
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.begin(id)
+		defer s.end(id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// workspaceOf returns the workspace r is for, if any. Lifecycle queries
+// are not activity, so a client may poll them.
+func workspaceOf(r *http.Request) string {
+	seg := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 4)
+	switch {
+	case len(seg) == 4 && seg[0] == "api" && seg[1] == "workspaces":
+		if seg[3] == "lifecycle" {
+			return ""
+		}
+		return seg[2]
+	case len(seg) == 4 && seg[0] == "ws" && seg[1] == "workspaces":
+		return seg[2]
+	case len(seg) == 3 && seg[0] == "ws" && seg[1] == "lsp":
+		return r.URL.Query().Get("workspace")
+	case len(seg) >= 3 && seg[0] == "ws":
+		// /ws/terminal/{id}, /ws/debug/{id} and the like.
+		return seg[2]
+	case len(seg) >= 2 && seg[0] == "preview":
+		return seg[1]
+	}
+	return ""
+}
+
+func (s *Service) begin(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.activityLocked(id)
+	for a.stopping != nil {
+		ch := a.stopping
+		s.mu.Unlock()
+		<-ch
+		s.mu.Lock()
+		a = s.activityLocked(id)
+	}
+	a.requests++
+	a.last = s.now()
+}
+
+func (s *Service) end(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.activityLocked(id)
+	a.requests--
+	a.last = s.now()
+}
+
+func (s *Service) activityLocked(id string) *activity {
+	a, ok := s.workspaces[id]
+	if !ok {
+		a = &activity{last: s.now()}
+		s.workspaces[id] = a
+	}
+	return a
+}
+
+func (s *Service) loop() {
+	defer close(s.done)
+	t := time.NewTicker(s.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			s.check()
+		}
+	}
+}
+
+// check hibernates the running workspaces that have been idle for long
+// enough.
+func (s *Service) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ids, err := s.containers.Running(ctx)
+	if err != nil {
+		slog.Warn("list workspace containers", "err", err)
+		return
+	}
+	running := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		running[id] = true
+		if s.idle(id) {
+			s.hibernate(ctx, id)
+		}
+	}
+
+	// Forget workspaces that have neither a container nor recent requests.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for id, a := range s.workspaces {
+		if !running[id] && a.requests == 0 && a.stopping == nil && now.Sub(a.last) > s.cfg.IdleTimeout {
+			delete(s.workspaces, id)
+		}
+	}
+}
+
+// idle reports whether the running workspace id should hibernate, and if
+// so marks it as stopping.
+func (s *Service) idle(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hibernated[id]; ok {
+		// Resumed since it hibernated.
+		delete(s.hibernated, id)
+		s.saveLocked()
+	}
+	// A container first seen now, such as after a restart, gets a full
+	// IdleTimeout.
+	a := s.activityLocked(id)
+	now := s.now()
+	if a.requests > 0 || (s.cfg.Busy != nil && s.cfg.Busy(id)) {
+		a.last = now
+		return false
+	}
+	if now.Sub(a.last) < s.cfg.IdleTimeout {
+		return false
+	}
+	a.stopping = make(chan struct{})
+	return true
+}
+
+func (s *Service) hibernate(ctx context.Context, id string) {
+	err := s.containers.Stop(ctx, id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.workspaces[id]
+	close(a.stopping)
+	a.stopping = nil
+	if err != nil {
+		slog.Warn("hibernate workspace", "workspace", id, "err", err)
+		return
+	}
+	slog.Info("workspace hibernated", "workspace", id, "idle", s.now().Sub(a.last).Round(time.Second))
+	s.hibernated[id] = s.now()
+	s.saveLocked()
+}
+
+func (s *Service) path() string {
+	return filepath.Join(s.cfg.Dir, "hibernated.json")
+}
+
+// saveLocked writes the hibernated workspaces; failures are logged, as
+// the list only feeds Status.
+func (s *Service) saveLocked() {
+	if err := s.writeLocked(); err != nil {
+		slog.Warn("save hibernated workspaces", "err", err)
+	}
+}
+
+func (s *Service) writeLocked() error {
+	data, err := json.Marshal(s.hibernated)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.cfg.Dir, ".hibernated-*")
+	if err != nil {
+		return fmt.Errorf("hibernate: write state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("hibernate: write state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("hibernate: write state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path()); err != nil {
+		return fmt.Errorf("hibernate: write state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package history
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diff"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the file history routes.
+type Handler struct {
+	store      *Store
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for the history kept in store.
+func NewHandler(store *Store, wm Workspaces) *Handler {
+	return &Handler{store: store, workspaces: wm}
+}
+
+// Register mounts the history routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/history", h.files)
+	mux.HandleFunc("GET /api/workspaces/{id}/history/{path...}", h.get)
+	mux.HandleFunc("POST /api/workspaces/{id}/history/{path...}", h.restore)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (id, dir string, ok bool) {
+	id = r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", false
+	}
+	return id, dir, true
+}
+
+// files lists the paths with history, including deleted files.
+func (h *Handler) files(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.store.Files(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"files": list})
+}
+
+// get lists the versions of a file. With ?version=N it returns the
+// contents of that version instead, and with ?version=N&diff=M the changes
+// from version N to version M, or to the file as it is now for
+// diff=current.
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	p := r.PathValue("path")
+	q := r.URL.Query()
+	if !q.Has("version") {
+		versions, err := h.store.Versions(id, p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpx.JSON(w, http.StatusOK, map[string]any{"path": p, "versions": versions})
+		return
+	}
+	n, ok := versionParam(w, q.Get("version"))
+	if !ok {
+		return
+	}
+	v, data, err := h.store.Read(id, p, n)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !q.Has("diff") {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("ETag", `"`+v.Hash+`"`)
+		w.Write(data)
+		return
+	}
+
+	var other []byte
+	if to := q.Get("diff"); to == "current" {
+		fsys, err := files.New(dir)
+		if err == nil {
+			other, err = fsys.ReadFile(p)
+		}
+		// A deleted file shows as all lines removed.
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			writeError(w, err)
+			return
+		}
+	} else {
+		m, ok := versionParam(w, to)
+		if !ok {
+			return
+		}
+		if _, other, err = h.store.Read(id, p, m); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if !utf8.Valid(data) || !utf8.Valid(other) {
+		httpx.Error(w, http.StatusUnprocessableEntity, "binary files cannot be compared")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{
+		"path":  p,
+		"from":  n,
+		"to":    q.Get("diff"),
+		"hunks": diff.Lines(string(data), string(other), 3),
+	})
+}
+
+// restore writes ?version=N back to the file, which becomes the newest
+// version. Restoring is itself recorded, so it can be undone.
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	n, ok := versionParam(w, r.URL.Query().Get("version"))
+	if !ok {
+		return
+	}
+	e, v, err := h.store.Restore(r.Context(), id, dir, r.PathValue("path"), n)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", e.ETag())
+	httpx.JSON(w, http.StatusOK, map[string]any{"file": e, "version": v})
+}
+
+func versionParam(w http.ResponseWriter, s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		httpx.Error(w, http.StatusBadRequest, "version must be a positive integer")
+		return 0, false
+	}
+	return n, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "no history for this version")
+	case errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		slog.Error("file history", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "file history failed")
+	}
+}
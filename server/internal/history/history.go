@@ -0,0 +1,385 @@
+// Package history keeps past versions of workspace files, independent of
+// Git, so a bad edit can be undone by someone who has never made a
+// commit. Every save through the file API records a version with its
+// author; collaborative sessions, which save every few seconds, record one
+// version per author and Config.Coalesce window.
+//
+// History is bounded per file by Config.MaxVersions and Config.MaxAge,
+// and files above Config.MaxBytes are not recorded. It outlives the file,
+// so a deleted file can be brought back too.
+package history
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir holds the history, one subdirectory per workspace; defaults to a
+	// directory under the OS temp dir.
+	Dir string
+	// MaxVersions is how many versions are kept per file; defaults to 50.
+	MaxVersions int
+	// MaxAge is how long versions are kept; defaults to 30 days. The
+	// newest version of a file is always kept.
+	MaxAge time.Duration
+	// MaxBytes is the largest file recorded; defaults to 1 MiB.
+	MaxBytes int
+	// Coalesce is the window within which collaborative saves by one
+	// author replace each other; defaults to 5 minutes.
+	Coalesce time.Duration
+}
+
+// Source is how a version was saved.
+type Source string
+
+const (
+	SourceSave    Source = "save"
+	SourceCollab  Source = "collab"
+	SourceRestore Source = "restore"
+)
+
+var (
+	// ErrNotFound is returned for files and versions without history.
+	ErrNotFound = errors.New("history: no such version")
+	// ErrTooLarge is returned by Record for files over Config.MaxBytes.
+	ErrTooLarge = errors.New("history: file too large")
+)
+
+// Author is who saved a version.
+type Author struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Version is one recorded state of a file.
+type Version struct {
+	ID     int       `json:"id"`
+	Time   time.Time `json:"time"`
+	Author Author    `json:"author"`
+	Source Source    `json:"source"`
+	Size   int       `json:"size"`
+	Hash   string    `json:"hash"`
+	// RestoredFrom is the version a restore brought back.
+	RestoredFrom int `json:"restoredFrom,omitempty"`
+}
+
+// File summarizes the history of one path.
+type File struct {
+	Path      string    `json:"path"`
+	Versions  int       `json:"versions"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// index is the history of one file, oldest version first.
+type index struct {
+	Path     string    `json:"path"`
+	Next     int       `json:"next"`
+	Versions []Version `json:"versions"`
+}
+
+// Store records and serves file versions.
+type Store struct {
+	cfg Config
+	now func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewStore returns a Store, filling unset Config fields with defaults.
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-history")
+	}
+	if cfg.MaxVersions <= 0 {
+		cfg.MaxVersions = 50
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 30 * 24 * time.Hour
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+	if cfg.Coalesce <= 0 {
+		cfg.Coalesce = 5 * time.Minute
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("history: create dir: %w", err)
+	}
+	return &Store{cfg: cfg, now: time.Now}, nil
+}
+
+// Records reports whether files of size bytes are kept. With Saved it
+// implements files.History.
+func (s *Store) Records(size int64) bool { return size <= int64(s.cfg.MaxBytes) }
+
+// Saved records a file saved through the file API by the user in ctx.
+// Failures are logged, as they must not fail the save.
+func (s *Store) Saved(ctx context.Context, workspaceID, path string, data []byte) {
+	if _, err := s.Record(workspaceID, path, authorFrom(ctx), SourceSave, data); err != nil && !errors.Is(err, ErrTooLarge) {
+		slog.Error("record file version", "workspace", workspaceID, "path", path, "err", err)
+	}
+}
+
+// authorFrom returns the signed-in user of ctx as an Author.
+func authorFrom(ctx context.Context) Author {
+	u := auth.UserFrom(ctx)
+	if u == nil {
+		return Author{}
+	}
+	a := Author{ID: u.ID, Name: u.Name}
+	if a.Name == "" {
+		a.Name = u.Email
+	}
+	return a
+}
+
+// Edited records a collaborative save of path by editors. It has the
+// signature of collab.Config.Saved.
+func (s *Store) Edited(workspaceID, path string, data []byte, editors []string) {
+	by := Author{Name: strings.Join(editors, ", ")}
+	if _, err := s.Record(workspaceID, path, by, SourceCollab, data); err != nil && !errors.Is(err, ErrTooLarge) {
+		slog.Error("record file version", "workspace", workspaceID, "path", path, "err", err)
+	}
+}
+
+// fileDir is where the history of path is kept. Paths are hashed so that
+// any path maps to one flat, safe name.
+func (s *Store) fileDir(workspaceID, path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(s.cfg.Dir, workspaceID, hex.EncodeToString(sum[:16]))
+}
+
+// Record adds data as the newest version of path. Data identical to the
+// newest version adds nothing, and a collaborative save replaces a
+// collaborative newest version by the same author within Config.Coalesce.
+// It returns the version that holds data.
+func (s *Store) Record(workspaceID, path string, by Author, source Source, data []byte) (*Version, error) {
+	return s.record(workspaceID, path, by, source, data, 0)
+}
+
+func (s *Store) record(workspaceID, path string, by Author, source Source, data []byte, from int) (*Version, error) {
+	p, err := files.Clean(path)
+	if err != nil || p == "" {
+		return nil, fmt.Errorf("%w: %q", files.ErrInvalidPath, path)
+	}
+	if len(data) > s.cfg.MaxBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, s.cfg.MaxBytes)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	now := s.now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := s.fileDir(workspaceID, p)
+	idx, err := s.readIndex(dir)
+	if errors.Is(err, ErrNotFound) {
+		idx, err = &index{Path: p}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	v := Version{Time: now, Author: by, Source: source, Size: len(data), Hash: hash, RestoredFrom: from}
+	if n := len(idx.Versions); n > 0 {
+		newest := &idx.Versions[n-1]
+		if newest.Hash == hash {
+			return newest, nil
+		}
+		if source == SourceCollab && newest.Source == SourceCollab && newest.Author == by && now.Sub(newest.Time) < s.cfg.Coalesce {
+			idx.Versions = idx.Versions[:n-1]
+		}
+	}
+	idx.Next++
+	v.ID = idx.Next
+	idx.Versions = append(idx.Versions, v)
+
+	// Drop what is over the bounds, always keeping the newest.
+	keep := idx.Versions[:0]
+	for i, old := range idx.Versions {
+		last := i == len(idx.Versions)-1
+		if !last && (len(idx.Versions)-i > s.cfg.MaxVersions || now.Sub(old.Time) > s.cfg.MaxAge) {
+			continue
+		}
+		keep = append(keep, old)
+	}
+	idx.Versions = keep
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	blob := filepath.Join(dir, hash)
+	if _, err := os.Stat(blob); err != nil {
+		if err := writeAtomic(blob, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.writeIndex(dir, idx); err != nil {
+		return nil, err
+	}
+	s.collect(dir, idx)
+	return &v, nil
+}
+
+// collect removes the contents no version of idx refers to.
+func (s *Store) collect(dir string, idx *index) {
+	used := make(map[string]bool, len(idx.Versions))
+	for _, v := range idx.Versions {
+		used[v.Hash] = true
+	}
+	des, _ := os.ReadDir(dir)
+	for _, de := range des {
+		if len(de.Name()) == sha256.Size*2 && !used[de.Name()] {
+			os.Remove(filepath.Join(dir, de.Name()))
+		}
+	}
+}
+
+func (s *Store) readIndex(dir string) (*index, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: read index: %w", err)
+	}
+	var idx index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("history: read index: %w", err)
+	}
+	return &idx, nil
+}
+
+func (s *Store) writeIndex(dir string, idx *index) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(filepath.Join(dir, "index.json"), data)
+}
+
+func writeAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+// Files lists the paths of a workspace that have history, deleted files
+// included, most recently changed first.
+func (s *Store) Files(workspaceID string) ([]File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	des, err := os.ReadDir(filepath.Join(s.cfg.Dir, workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return []File{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: list: %w", err)
+	}
+	out := make([]File, 0, len(des))
+	for _, de := range des {
+		if !de.IsDir() {
+			continue
+		}
+		idx, err := s.readIndex(filepath.Join(s.cfg.Dir, workspaceID, de.Name()))
+		if err != nil || len(idx.Versions) == 0 {
+			continue
+		}
+		out = append(out, File{Path: idx.Path, Versions: len(idx.Versions), UpdatedAt: idx.Versions[len(idx.Versions)-1].Time})
+	}
+	slices.SortFunc(out, func(a, b File) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return out, nil
+}
+
+// Versions returns the versions of path, newest first.
+func (s *Store) Versions(workspaceID, path string) ([]Version, error) {
+	p, err := files.Clean(path)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.readIndex(s.fileDir(workspaceID, p))
+	if err != nil {
+		return nil, err
+	}
+	out := slices.Clone(idx.Versions)
+	slices.Reverse(out)
+	return out, nil
+}
+
+// Read returns version id of path and its contents.
+func (s *Store) Read(workspaceID, path string, id int) (*Version, []byte, error) {
+	p, err := files.Clean(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := s.fileDir(workspaceID, p)
+	idx, err := s.readIndex(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	i := slices.IndexFunc(idx.Versions, func(v Version) bool { return v.ID == id })
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%w: %s version %d", ErrNotFound, p, id)
+	}
+	v := idx.Versions[i]
+	data, err := os.ReadFile(filepath.Join(dir, v.Hash))
+	if err != nil {
+		return nil, nil, fmt.Errorf("history: read %s version %d: %w", p, id, err)
+	}
+	return &v, data, nil
+}
+
+// Restore writes version id back to path in the workspace rooted at dir,
+// recreating the file if it was deleted, and records that as a new
+// version by the user in ctx.
+func (s *Store) Restore(ctx context.Context, workspaceID, dir, path string, id int) (files.Entry, *Version, error) {
+	_, data, err := s.Read(workspaceID, path, id)
+	if err != nil {
+		return files.Entry{}, nil, err
+	}
+	fsys, err := files.New(dir)
+	if err != nil {
+		return files.Entry{}, nil, err
+	}
+	e, err := fsys.WriteFile(path, data)
+	if err != nil {
+		return files.Entry{}, nil, err
+	}
+	v, err := s.record(workspaceID, path, authorFrom(ctx), SourceRestore, data, id)
+	if err != nil {
+		return e, nil, err
+	}
+	return e, v, nil
+}
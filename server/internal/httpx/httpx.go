@@ -0,0 +1,74 @@
+// Package httpx holds the small JSON helpers shared by every HTTP handler in
+// the Go backend. Responses mirror the Node API: successful calls return the
+// payload as-is, failures return {"success": false, "error": "..."}.
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBody caps request bodies decoded by DecodeJSON.
+const DefaultMaxBody = 4 << 20
+
+// JSON writes v as a JSON response with the given status code.
+func JSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("httpx: encode response", "err", err)
+	}
+}
+
+// Error writes a JSON error body with the given status code.
+func Error(w http.ResponseWriter, status int, msg string) {
+	JSON(w, status, map[string]any{"success": false, "error": msg})
+}
+
+// Errorf is Error with fmt-style formatting.
+func Errorf(w http.ResponseWriter, status int, format string, args ...any) {
+	Error(w, status, fmt.Sprintf(format, args...))
+}
+
+// DecodeJSON decodes the request body into v, rejecting unknown fields and
+// bodies larger than max bytes (DefaultMaxBody when max <= 0).
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any, max int64) error {
+	if max <= 0 {
+		max = DefaultMaxBody
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, max))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit)
+		}
+		if errors.Is(err, io.EOF) {
+			return errors.New("request body is empty")
+		}
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// ClientIP returns the address r came from. With trustProxy, that is the
+// first address in X-Forwarded-For, for servers behind a reverse proxy.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
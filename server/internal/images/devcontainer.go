@@ -0,0 +1,148 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/devcontainer"
+)
+
+// checkDevcontainer checks that the devcontainer.json at the clean path p
+// of the workspace at dir can be built, and that the image it starts from
+// or the images its Dockerfile uses are allowed. Its Features are checked
+// when it is built.
+func (s *Service) checkDevcontainer(dir, p string) error {
+	cfg, err := devcontainer.LoadFile(dir, p)
+	if err != nil {
+		return devcontainerError(err)
+	}
+	if cfg.Build == nil {
+		return allowed(s.cfg.Registries, cfg.Image)
+	}
+	tmp, err := os.MkdirTemp("", "webide-devcontainer-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	// Without Features, planning only reads the Dockerfile.
+	bare := *cfg
+	bare.Features = nil
+	plan, err := bare.Plan(context.Background(), dir, tmp, s.fetcher())
+	if err != nil {
+		return devcontainerError(err)
+	}
+	return checkDockerfile(s.cfg.Registries, string(plan.UserDockerfile))
+}
+
+// buildDevcontainer pulls or builds the image of the workspace's
+// devcontainer.json, downloading its Features.
+func (s *Service) buildDevcontainer(ctx context.Context, workspaceID, dir string, st *stored, emit func(Event)) (*Result, error) {
+	cfg, err := devcontainer.LoadFile(dir, st.Devcontainer)
+	if err != nil {
+		return nil, devcontainerError(err)
+	}
+	tmp, err := os.MkdirTemp("", "webide-devcontainer-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	plan, err := cfg.Plan(ctx, dir, tmp, s.fetcher())
+	if err != nil {
+		return nil, devcontainerError(err)
+	}
+	if plan.Pull != "" {
+		if err := allowed(s.cfg.Registries, plan.Pull); err != nil {
+			return nil, err
+		}
+		return s.run(ctx, workspaceID, st, plan.Pull, []string{"pull", plan.Pull}, nil, emit)
+	}
+	if plan.Base != "" {
+		err = allowed(s.cfg.Registries, plan.Base)
+	} else {
+		err = checkDockerfile(s.cfg.Registries, string(plan.UserDockerfile))
+	}
+	if err != nil {
+		return nil, err
+	}
+	ref := "ai-ide-workspace:" + workspaceID
+	args := []string{"build", "--progress=plain", "--label", "ai-ide.workspace=" + workspaceID,
+		"--tag", ref, "--file", "-"}
+	if plan.Features != "" {
+		args = append(args, "--build-context", devcontainer.FeaturesContext+"="+plan.Features)
+	}
+	names := make([]string, 0, len(plan.Args))
+	for k := range plan.Args {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	for _, k := range names {
+		args = append(args, "--build-arg", k+"="+plan.Args[k])
+	}
+	if plan.Target != "" {
+		args = append(args, "--target", plan.Target)
+	}
+	args = append(args, plan.Context)
+	return s.run(ctx, workspaceID, st, ref, args, plan.Dockerfile, emit)
+}
+
+func (s *Service) fetcher() *devcontainer.Fetcher {
+	return &devcontainer.Fetcher{Registries: s.cfg.FeatureRegistries}
+}
+
+// devcontainerError reports the errors of reading and planning a
+// devcontainer.json as the package's own.
+func devcontainerError(err error) error {
+	switch {
+	case errors.Is(err, devcontainer.ErrNotAllowed):
+		return fmt.Errorf("%w: %v", ErrNotAllowed, err)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrInvalid, err)
+}
+
+// detect sets a workspace with a devcontainer.json and no image settings
+// to use it, and starts building its image, the first time it is looked
+// at with one.
+func (s *Service) detect(workspaceID string) {
+	s.mu.Lock()
+	st, err := s.loadLocked(workspaceID)
+	if err != nil || st.Detected || !st.empty() {
+		s.mu.Unlock()
+		return
+	}
+	dir, err := s.cfg.Dir(workspaceID)
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+	i := slices.IndexFunc(devcontainer.Paths, func(p string) bool {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p)))
+		return err == nil
+	})
+	if i < 0 {
+		s.mu.Unlock()
+		return
+	}
+	st.Devcontainer, st.Detected = devcontainer.Paths[i], true
+	err = s.saveLocked(workspaceID, st)
+	s.mu.Unlock()
+	if err != nil {
+		slog.Error("images: detect devcontainer", "workspace", workspaceID, "err", err)
+		return
+	}
+	go func() {
+		res, err := s.Build(context.Background(), workspaceID, dir, nil)
+		switch {
+		case err != nil:
+			slog.Warn("images: build devcontainer", "workspace", workspaceID, "err", err)
+		case !res.Ready:
+			slog.Warn("images: build devcontainer", "workspace", workspaceID, "exitCode", res.ExitCode, "error", res.Error)
+		}
+	}()
+}
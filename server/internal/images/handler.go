@@ -0,0 +1,168 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the custom image routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the custom image routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/settings/image", h.settings)
+	mux.HandleFunc("PUT /api/workspaces/{id}/settings/image", h.setSettings)
+	mux.HandleFunc("POST /api/workspaces/{id}/image/build", h.build)
+	mux.HandleFunc("GET /ws/workspaces/{id}/image/build", h.stream)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", false
+	}
+	return id, dir, true
+}
+
+// statusResponse is a Status with the registries images may come from.
+type statusResponse struct {
+	*Status
+	Registries []string `json:"registries"`
+}
+
+func (h *Handler) settings(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	st, err := h.svc.Status(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, statusResponse{Status: st, Registries: h.svc.Registries()})
+}
+
+func (h *Handler) setSettings(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var set Settings
+	if err := httpx.DecodeJSON(w, r, &set, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	st, err := h.svc.SetSettings(id, dir, set)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, statusResponse{Status: st, Registries: h.svc.Registries()})
+}
+
+// build pulls or builds the workspace's image and returns the Result once
+// docker exits.
+func (h *Handler) build(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.svc.Build(r.Context(), id, dir, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// errorFrame reports a build that could not start or failed to run.
+type errorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// stream pulls or builds the workspace's image and sends its Events, one
+// per text frame, closing the socket after the exited event. Closing the
+// socket stops the build.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	_, err = h.svc.Build(ctx, id, dir, func(ev Event) {
+		if werr := conn.WriteJSON(ev); werr != nil {
+			cancel()
+		}
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		msg := err.Error()
+		if !isRequestError(err) {
+			slog.Error("build image", "workspace", id, "err", err)
+			msg = "could not build image"
+		}
+		conn.WriteJSON(errorFrame{Type: "error", Error: msg})
+		return
+	}
+	conn.CloseWithCode(ws.CloseNormal, "build finished")
+}
+
+func isRequestError(err error) bool {
+	return errors.Is(err, ErrInvalid) || errors.Is(err, ErrNotAllowed) ||
+		errors.Is(err, ErrNotConfigured) || errors.Is(err, ErrBusy)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid), errors.Is(err, ErrNotConfigured):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotAllowed):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrBusy):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("images", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "image request failed")
+	}
+}
@@ -0,0 +1,605 @@
+// Package images lets a workspace replace the stock toolchain images with
+// its own: an image from an allowlisted registry, or one built from a
+// Dockerfile or a devcontainer.json in the workspace, for projects that
+// need system packages.
+// Pulling or building it streams the log, and images over the size limit
+// are refused. Once ready, the image is the workspace container's, so
+// terminals and tools use it, and that of the workspace's Go runs.
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/toolchain"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/utf8x"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Binary is the docker executable; defaults to "docker".
+	Binary string
+	// Registries are where images may come from, each a registry host
+	// optionally followed by a repository prefix, such as "ghcr.io/acme";
+	// defaults to Docker Hub's official images, "docker.io/library".
+	Registries []string
+	// MaxBytes caps the size of an image; defaults to 4 GiB.
+	MaxBytes int64
+	// BuildTimeout bounds a pull or build; defaults to 20 minutes.
+	BuildTimeout time.Duration
+	// MaxDockerfileBytes caps the Dockerfile read; defaults to 256 KiB.
+	MaxDockerfileBytes int64
+	// MaxOutputBytes caps the log kept in a Result; defaults to 64 KiB.
+	// Streamed output is not capped.
+	MaxOutputBytes int
+	// SettingsDir stores each workspace's image settings; defaults to a
+	// directory under the OS temp dir.
+	SettingsDir string
+	// FeatureRegistries are where the Features of a devcontainer.json may
+	// come from; defaults to ghcr.io/devcontainers/features.
+	FeatureRegistries []string
+	// Dir, when set, resolves a workspace ID to its root directory, so
+	// that a workspace with a devcontainer.json and no image settings is
+	// set to use it the first time its image is looked up, and the image
+	// is built in the background.
+	Dir func(workspaceID string) (string, error)
+}
+
+var (
+	// ErrInvalid is returned for malformed settings.
+	ErrInvalid = errors.New("images: invalid image settings")
+	// ErrNotAllowed is returned for images outside Config.Registries.
+	ErrNotAllowed = errors.New("images: registry not allowed")
+	// ErrNotConfigured is returned when building for a workspace without
+	// a custom image.
+	ErrNotConfigured = errors.New("images: no custom image configured")
+	// ErrBusy is returned while the workspace's image is being built.
+	ErrBusy = errors.New("images: build in progress")
+)
+
+// Settings is a workspace's custom image: a registry image in Image, the
+// workspace-relative path of a Dockerfile, whose build context is the
+// workspace root, or that of a devcontainer.json. None means the stock
+// images.
+type Settings struct {
+	Image        string `json:"image,omitempty"`
+	Dockerfile   string `json:"dockerfile,omitempty"`
+	Devcontainer string `json:"devcontainer,omitempty"`
+}
+
+// empty reports whether the settings ask for the stock images.
+func (st Settings) empty() bool {
+	return st.Image == "" && st.Dockerfile == "" && st.Devcontainer == ""
+}
+
+// source identifies what an image was made from, to tell whether it is
+// still the one the settings ask for.
+func (st Settings) source() string {
+	switch {
+	case st.Devcontainer != "":
+		return "devcontainer:" + st.Devcontainer
+	case st.Dockerfile != "":
+		return "dockerfile:" + st.Dockerfile
+	}
+	return "image:" + st.Image
+}
+
+// Built is a pulled or built image.
+type Built struct {
+	Ref     string    `json:"ref"`
+	ID      string    `json:"id"`
+	Size    int64     `json:"size"`
+	BuiltAt time.Time `json:"builtAt"`
+	Source  string    `json:"source"`
+}
+
+// Status is a workspace's custom image configuration and state.
+type Status struct {
+	Settings
+	// Active is the image the workspace uses, nil for the stock ones
+	// until the configured image has been pulled or built.
+	Active   *Built `json:"active,omitempty"`
+	Building bool   `json:"building"`
+}
+
+// stored is the settings file of a workspace. Detected is set once the
+// workspace has been looked at for a devcontainer.json, so that settings
+// cleared afterwards stay cleared.
+type stored struct {
+	Settings
+	Built    *Built `json:"built,omitempty"`
+	Detected bool   `json:"detected,omitempty"`
+}
+
+// Result reports a finished pull or build. Ready reports whether the
+// workspace now uses the image; Error explains why an image that was
+// pulled or built is not used, as when it is too large.
+type Result struct {
+	Ref        string    `json:"ref"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMS int64     `json:"durationMs"`
+	ExitCode   int       `json:"exitCode"`
+	TimedOut   bool      `json:"timedOut,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	Ready      bool      `json:"ready"`
+	Error      string    `json:"error,omitempty"`
+	// Output is the docker log, up to Config.MaxOutputBytes.
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// EventType names a build event.
+type EventType string
+
+const (
+	EventStarted EventType = "started"
+	EventOutput  EventType = "output"
+	EventExited  EventType = "exited"
+)
+
+// Event is one item of a build's stream. Output events carry Data, and
+// the final exited event the Result.
+type Event struct {
+	Type   EventType `json:"type"`
+	Ref    string    `json:"ref,omitempty"`
+	Data   string    `json:"data,omitempty"`
+	Result *Result   `json:"result,omitempty"`
+}
+
+// Service keeps workspace image settings and pulls and builds images with
+// the docker CLI.
+type Service struct {
+	cfg Config
+
+	mu       sync.Mutex // guards the settings files
+	bmu      sync.Mutex
+	building map[string]bool
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config) *Service {
+	if cfg.Binary == "" {
+		cfg.Binary = "docker"
+	}
+	if len(cfg.Registries) == 0 {
+		cfg.Registries = []string{"docker.io/library"}
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 4 << 30
+	}
+	if cfg.BuildTimeout <= 0 {
+		cfg.BuildTimeout = 20 * time.Minute
+	}
+	if cfg.MaxDockerfileBytes <= 0 {
+		cfg.MaxDockerfileBytes = 256 << 10
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 64 << 10
+	}
+	if cfg.SettingsDir == "" {
+		cfg.SettingsDir = filepath.Join(os.TempDir(), "webide-images")
+	}
+	return &Service{cfg: cfg, building: make(map[string]bool)}
+}
+
+// Registries returns the registries images may come from.
+func (s *Service) Registries() []string {
+	return append([]string(nil), s.cfg.Registries...)
+}
+
+// Status returns the custom image state of workspaceID.
+func (s *Service) Status(workspaceID string) (*Status, error) {
+	st, err := s.load(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	s.bmu.Lock()
+	building := s.building[workspaceID]
+	s.bmu.Unlock()
+	return &Status{Settings: st.Settings, Active: st.active(), Building: building}, nil
+}
+
+// active returns the built image when it is what the settings ask for.
+func (st *stored) active() *Built {
+	if st.Built == nil || st.empty() || st.Built.Source != st.source() {
+		return nil
+	}
+	return st.Built
+}
+
+// SetSettings stores the custom image of the workspace at dir, checking
+// that the image or the images the Dockerfile or devcontainer.json uses
+// are allowed. The workspace keeps its current image until the new one is
+// built.
+func (s *Service) SetSettings(workspaceID, dir string, set Settings) (*Status, error) {
+	n := 0
+	for _, v := range []string{set.Image, set.Dockerfile, set.Devcontainer} {
+		if v != "" {
+			n++
+		}
+	}
+	switch {
+	case n > 1:
+		return nil, fmt.Errorf("%w: image, dockerfile and devcontainer are mutually exclusive", ErrInvalid)
+	case set.Image != "":
+		if err := allowed(s.cfg.Registries, set.Image); err != nil {
+			return nil, err
+		}
+	case set.Dockerfile != "":
+		p, err := cleanPath(set.Dockerfile)
+		if err != nil {
+			return nil, err
+		}
+		set.Dockerfile = p
+		if _, err := s.dockerfile(dir, p); err != nil {
+			return nil, err
+		}
+	case set.Devcontainer != "":
+		p, err := cleanPath(set.Devcontainer)
+		if err != nil {
+			return nil, err
+		}
+		set.Devcontainer = p
+		if err := s.checkDevcontainer(dir, p); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	st, err := s.loadLocked(workspaceID)
+	if err == nil {
+		st.Settings = set
+		if set.empty() {
+			st.Built = nil
+		}
+		err = s.saveLocked(workspaceID, st)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.Status(workspaceID)
+}
+
+// Image returns the custom image workspaceID uses, or "" for the stock
+// ones.
+func (s *Service) Image(workspaceID string) string {
+	st, err := s.load(workspaceID)
+	if err != nil {
+		return ""
+	}
+	if b := st.active(); b != nil {
+		return b.Ref
+	}
+	return ""
+}
+
+// WorkspaceImage returns an image picker for terminal.DockerLauncher's
+// ImageFor: the workspace's custom image, or what fallback picks.
+func (s *Service) WorkspaceImage(fallback func(workspaceID string) string) func(string) string {
+	return func(workspaceID string) string {
+		if s.cfg.Dir != nil {
+			s.detect(workspaceID)
+		}
+		if img := s.Image(workspaceID); img != "" {
+			return img
+		}
+		return fallback(workspaceID)
+	}
+}
+
+// Toolchains resolves the toolchain of a run, as toolchain.Service does.
+type Toolchains interface {
+	Resolve(workspaceID, version string) (toolchain.Toolchain, error)
+}
+
+// Toolchains wraps tc so that the runs of a workspace with a custom image
+// use it, unless they ask for a Go version of their own. The image then
+// has to provide the Go toolchain itself.
+func (s *Service) Toolchains(tc Toolchains) Toolchains {
+	return customToolchains{s: s, tc: tc}
+}
+
+type customToolchains struct {
+	s  *Service
+	tc Toolchains
+}
+
+func (c customToolchains) Resolve(workspaceID, version string) (toolchain.Toolchain, error) {
+	t, err := c.tc.Resolve(workspaceID, version)
+	if err != nil || workspaceID == "" || version != "" {
+		return t, err
+	}
+	if img := c.s.Image(workspaceID); img != "" {
+		t.Image, t.WorkspaceImage = img, img
+	}
+	return t, nil
+}
+
+// Build pulls the configured image of the workspace at dir, or builds its
+// Dockerfile or devcontainer.json, passing the log to emit, which may be nil. A failed or
+// refused build is reported through the Result; a non-nil error means it
+// could not be run.
+func (s *Service) Build(ctx context.Context, workspaceID, dir string, emit func(Event)) (*Result, error) {
+	st, err := s.load(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case st.Devcontainer != "":
+		return s.buildDevcontainer(ctx, workspaceID, dir, st, emit)
+	case st.Dockerfile != "":
+		// The Dockerfile is passed on stdin, so what is built is what was
+		// checked.
+		content, err := s.dockerfile(dir, st.Dockerfile)
+		if err != nil {
+			return nil, err
+		}
+		// Tags, unlike repository names, may hold the upper-case letters
+		// of workspace IDs.
+		ref := "ai-ide-workspace:" + workspaceID
+		args := []string{"build", "--progress=plain", "--label", "ai-ide.workspace=" + workspaceID,
+			"--tag", ref, "--file", "-", dir}
+		return s.run(ctx, workspaceID, st, ref, args, content, emit)
+	case st.Image != "":
+		if err := allowed(s.cfg.Registries, st.Image); err != nil {
+			return nil, err
+		}
+		return s.run(ctx, workspaceID, st, st.Image, []string{"pull", st.Image}, nil, emit)
+	default:
+		return nil, ErrNotConfigured
+	}
+}
+
+// run runs docker with args, feeding it stdin, and records ref as the
+// workspace's image if that succeeds and it is within the size limit.
+func (s *Service) run(ctx context.Context, workspaceID string, st *stored, ref string, args []string, stdin []byte, emit func(Event)) (*Result, error) {
+	if err := s.acquire(workspaceID); err != nil {
+		return nil, err
+	}
+	defer s.release(workspaceID)
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.BuildTimeout)
+	defer cancel()
+	cmd := exec.Command(s.cfg.Binary, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	out := &output{max: s.cfg.MaxOutputBytes, emit: emit}
+	w := &streamWriter{out: out}
+	cmd.Stdout, cmd.Stderr = w, w
+	cmd.WaitDelay = 3 * time.Second
+
+	out.send(Event{Type: EventStarted, Ref: ref})
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("images: start docker %s: %w", args[0], err)
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+	err := cmd.Wait()
+	w.flush()
+
+	res := &Result{Ref: ref, StartedAt: start.UTC()}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		res.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if !res.TimedOut {
+			return nil, ctx.Err()
+		}
+		res.ExitCode = -1
+	case err == nil:
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	default:
+		return nil, fmt.Errorf("images: docker %s: %w", args[0], err)
+	}
+	if res.ExitCode == 0 && !res.TimedOut {
+		if err := s.record(ctx, workspaceID, st, res); err != nil {
+			return nil, err
+		}
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	out.mu.Lock()
+	res.Output, res.Truncated = out.buf.String(), out.truncated
+	out.mu.Unlock()
+	out.send(Event{Type: EventExited, Result: res})
+	return res, nil
+}
+
+// record checks the size of the image res made and, if it is within the
+// limit, makes it the workspace's image; a larger one is removed again.
+func (s *Service) record(ctx context.Context, workspaceID string, st *stored, res *Result) error {
+	data, err := exec.CommandContext(ctx, s.cfg.Binary, "image", "inspect", "--format", "{{.Id}} {{.Size}}", res.Ref).Output()
+	if err != nil {
+		return fmt.Errorf("images: inspect %s: %w", res.Ref, err)
+	}
+	f := strings.Fields(string(data))
+	if len(f) != 2 {
+		return fmt.Errorf("images: inspect %s: unexpected output %q", res.Ref, data)
+	}
+	if res.Size, err = strconv.ParseInt(f[1], 10, 64); err != nil {
+		return fmt.Errorf("images: inspect %s: %w", res.Ref, err)
+	}
+	if res.Size > s.cfg.MaxBytes {
+		res.Error = fmt.Sprintf("image is %d MiB, over the limit of %d MiB", res.Size>>20, s.cfg.MaxBytes>>20)
+		exec.Command(s.cfg.Binary, "image", "rm", res.Ref).Run()
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return err
+	}
+	if cur.source() != st.source() {
+		res.Error = "the image settings changed during the build"
+		return nil
+	}
+	cur.Built = &Built{Ref: res.Ref, ID: f[0], Size: res.Size, BuiltAt: time.Now().UTC(), Source: st.source()}
+	if err := s.saveLocked(workspaceID, cur); err != nil {
+		return err
+	}
+	res.Ready = true
+	return nil
+}
+
+func (s *Service) acquire(workspaceID string) error {
+	s.bmu.Lock()
+	defer s.bmu.Unlock()
+	if s.building[workspaceID] {
+		return ErrBusy
+	}
+	s.building[workspaceID] = true
+	return nil
+}
+
+func (s *Service) release(workspaceID string) {
+	s.bmu.Lock()
+	defer s.bmu.Unlock()
+	delete(s.building, workspaceID)
+}
+
+// dockerfile reads the Dockerfile at the clean path p of the workspace at
+// dir and checks the images it uses.
+func (s *Service) dockerfile(dir, p string) ([]byte, error) {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(p)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no file %s", ErrInvalid, p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("images: read %s: %w", p, err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(f, s.cfg.MaxDockerfileBytes+1)); err != nil {
+		return nil, fmt.Errorf("images: read %s: %w", p, err)
+	}
+	if int64(buf.Len()) > s.cfg.MaxDockerfileBytes {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrInvalid, p, s.cfg.MaxDockerfileBytes)
+	}
+	if err := checkDockerfile(s.cfg.Registries, buf.String()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cleanPath validates a workspace-relative path.
+func cleanPath(p string) (string, error) {
+	p = strings.TrimPrefix(p, "./")
+	c := path.Clean(p)
+	if p == "" || strings.ContainsRune(p, 0) || strings.Contains(p, `\`) || path.IsAbs(c) || c == "." || c == ".." || strings.HasPrefix(c, "../") {
+		return "", fmt.Errorf("%w: bad path %q", ErrInvalid, p)
+	}
+	return c, nil
+}
+
+func (s *Service) load(workspaceID string) (*stored, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(workspaceID)
+}
+
+func (s *Service) loadLocked(workspaceID string) (*stored, error) {
+	data, err := os.ReadFile(s.settingsPath(workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return &stored{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("images: read settings: %w", err)
+	}
+	var st stored
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("images: read settings: %w", err)
+	}
+	return &st, nil
+}
+
+func (s *Service) saveLocked(workspaceID string, st *stored) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.cfg.SettingsDir, 0o755); err != nil {
+		return fmt.Errorf("images: write settings: %w", err)
+	}
+	p := s.settingsPath(workspaceID)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("images: write settings: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("images: write settings: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) settingsPath(workspaceID string) string {
+	return filepath.Join(s.cfg.SettingsDir, workspaceID+".json")
+}
+
+// output collects a build's log up to max bytes and passes it on as
+// events. It serializes the events, as docker's stdout and stderr are
+// written from separate goroutines.
+type output struct {
+	mu        sync.Mutex
+	max       int
+	buf       strings.Builder
+	truncated bool
+	emit      func(Event)
+}
+
+func (o *output) send(ev Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if ev.Type == EventOutput {
+		if room := o.max - o.buf.Len(); len(ev.Data) > room {
+			o.buf.WriteString(ev.Data[:max(room, 0)])
+			o.truncated = true
+		} else {
+			o.buf.WriteString(ev.Data)
+		}
+	}
+	if o.emit != nil {
+		o.emit(ev)
+	}
+}
+
+// streamWriter turns the log into events, never splitting a multi-byte
+// character across two.
+type streamWriter struct {
+	out   *output
+	mu    sync.Mutex
+	split utf8x.Splitter
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if b := w.split.Push(p); len(b) > 0 {
+		w.out.send(Event{Type: EventOutput, Data: string(b)})
+	}
+	return len(p), nil
+}
+
+func (w *streamWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if b := w.split.Flush(); len(b) > 0 {
+		w.out.send(Event{Type: EventOutput, Data: string(b)})
+	}
+}
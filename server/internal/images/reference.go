@@ -0,0 +1,132 @@
+package images
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// refPattern matches the characters of an image reference; repository
+// names are checked to be lower-case separately, as tags need not be.
+var refPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/:@+-]*$`)
+
+// repository returns the registry and repository of the image reference
+// ref, without its tag or digest, the way docker expands it: a first
+// component that is not a host name means Docker Hub, and a single
+// component an official image, so "sqlite" is docker.io/library/sqlite.
+func repository(ref string) (string, error) {
+	if len(ref) > 255 || !refPattern.MatchString(ref) {
+		return "", fmt.Errorf("%w: bad image reference %q", ErrInvalid, ref)
+	}
+	name, _, _ := strings.Cut(ref, "@")
+	if i := strings.LastIndexByte(name, ':'); i > strings.LastIndexByte(name, '/') {
+		name = name[:i]
+	}
+	if name == "" || name != strings.ToLower(name) || strings.Contains(name, "//") || strings.HasSuffix(name, "/") {
+		return "", fmt.Errorf("%w: bad image reference %q", ErrInvalid, ref)
+	}
+	first, rest, ok := strings.Cut(name, "/")
+	switch {
+	case !ok:
+		return "docker.io/library/" + name, nil
+	case strings.ContainsAny(first, ".:") || first == "localhost":
+		if first == "index.docker.io" {
+			first = "docker.io"
+		}
+		if first == "docker.io" && !strings.Contains(rest, "/") {
+			rest = "library/" + rest
+		}
+		return first + "/" + rest, nil
+	default:
+		return "docker.io/" + name, nil
+	}
+}
+
+// allowed reports whether the image reference ref is in one of the
+// registries, each a registry host optionally followed by a repository
+// prefix, such as "ghcr.io" or "docker.io/library".
+func allowed(registries []string, ref string) error {
+	repo, err := repository(ref)
+	if err != nil {
+		return err
+	}
+	for _, r := range registries {
+		r = strings.TrimSuffix(r, "/")
+		if repo == r || strings.HasPrefix(repo, r+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotAllowed, repo)
+}
+
+// checkDockerfile checks that every image the Dockerfile content builds
+// from or copies out of is allowed: the FROM of each stage other than
+// scratch or an earlier stage, and the --from of COPY and of RUN
+// --mount. References built from ARG values cannot be checked and are
+// refused.
+func checkDockerfile(registries []string, content string) error {
+	stages := map[string]bool{}
+	check := func(ref string) error {
+		switch l := strings.ToLower(ref); {
+		case l == "scratch" || stages[l]:
+			return nil
+		case strings.Trim(ref, "0123456789") == "":
+			return nil // a stage index
+		case strings.Contains(ref, "$"):
+			return fmt.Errorf("%w: image %q depends on a build argument", ErrNotAllowed, ref)
+		}
+		return allowed(registries, ref)
+	}
+	sc := bufio.NewScanner(strings.NewReader(joinContinuations(content)))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "FROM":
+			args := flagless(fields[1:])
+			if len(args) == 0 {
+				return fmt.Errorf("%w: FROM without an image", ErrInvalid)
+			}
+			if err := check(args[0]); err != nil {
+				return err
+			}
+			if len(args) == 3 && strings.EqualFold(args[1], "AS") {
+				stages[strings.ToLower(args[2])] = true
+			}
+		case "COPY", "ADD", "RUN":
+			for _, f := range fields[1:] {
+				if !strings.HasPrefix(f, "--") {
+					break
+				}
+				for _, opt := range strings.Split(strings.TrimPrefix(f, "--mount="), ",") {
+					if ref, ok := strings.CutPrefix(opt, "--from="); ok {
+						opt = "from=" + ref
+					}
+					if ref, ok := strings.CutPrefix(opt, "from="); ok {
+						if err := check(ref); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// joinContinuations joins the lines a backslash continues.
+func joinContinuations(content string) string {
+	return strings.NewReplacer("\\\r\n", " ", "\\\n", " ").Replace(content)
+}
+
+// flagless drops the leading --flag arguments of an instruction.
+func flagless(args []string) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		args = args[1:]
+	}
+	return args
+}
@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Handler serves the job routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the job routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/jobs", h.list)
+	mux.HandleFunc("POST /api/workspaces/{id}/jobs", h.create)
+	mux.HandleFunc("GET /api/workspaces/{id}/jobs/{job}", h.get)
+	mux.HandleFunc("PUT /api/workspaces/{id}/jobs/{job}", h.update)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/jobs/{job}", h.delete)
+	mux.HandleFunc("GET /api/workspaces/{id}/jobs/{job}/runs", h.runs)
+	mux.HandleFunc("POST /api/workspaces/{id}/jobs/{job}/runs", h.trigger)
+	mux.HandleFunc("GET /api/workspaces/{id}/jobs/{job}/runs/{run}", h.run)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return id, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"jobs": h.svc.List(id)})
+}
+
+// create adds a job, which runs as the caller.
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var spec Spec
+	if err := httpx.DecodeJSON(w, r, &spec, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	job, err := h.svc.Create(r.Context(), id, spec)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionJobCreate, Workspace: id, Target: job.ID,
+		Details: map[string]string{"name": job.Name, "kind": string(job.Kind), "schedule": job.Schedule}})
+	httpx.JSON(w, http.StatusCreated, job)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	job, err := h.svc.Get(id, r.PathValue("job"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, job)
+}
+
+// update replaces what a job runs and when. It keeps running as its
+// creator.
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var spec Spec
+	if err := httpx.DecodeJSON(w, r, &spec, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	job, err := h.svc.Update(id, r.PathValue("job"), spec)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, job)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	job := r.PathValue("job")
+	if err := h.svc.Delete(id, job); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionJobDelete, Workspace: id, Target: job})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) runs(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	runs, err := h.svc.Runs(id, r.PathValue("job"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if runs == nil {
+		runs = []Run{}
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"runs": runs})
+}
+
+// trigger queues a run of the job now; its outcome shows in the runs.
+func (h *Handler) trigger(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	run, err := h.svc.Trigger(id, r.PathValue("job"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusAccepted, run)
+}
+
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	run, err := h.svc.GetRun(id, r.PathValue("job"), r.PathValue("run"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, run)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooMany), errors.Is(err, ErrBusy):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("jobs", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "job request failed")
+	}
+}
@@ -0,0 +1,869 @@
+// Package jobs runs workspace chores on a schedule, without anyone signed
+// in: nightly test runs, dependency update checks and snapshots. A job
+// pairs a cron expression with what to run; when it is due, it runs in the
+// workspace's sandbox as the user who created it, and the outcome is kept
+// in the job's history. Runs that fail publish a job.failed event, which
+// webhooks deliver to chat or email bridges.
+//
+// Jobs are kept in Config.Dir, one file per workspace with the recent runs
+// of its jobs. Runs missed while the server was down are not made up for.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/gotest"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/snapshot"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gomod"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the jobs, one file per workspace; defaults to a directory
+	// under the OS temp dir.
+	Dir string
+	// MaxJobs caps the jobs per workspace; defaults to 10.
+	MaxJobs int
+	// History is how many runs are kept per job; defaults to 20.
+	History int
+	// MinInterval is the shortest time a schedule may leave between runs;
+	// defaults to 15 minutes.
+	MinInterval time.Duration
+	// Timeout bounds each run; defaults to 30 minutes.
+	Timeout time.Duration
+	// Workers is how many jobs run at once; defaults to 2.
+	Workers int
+
+	// Tests, Dependencies and Snapshots run the jobs of their kinds; jobs
+	// of a kind without one cannot be created.
+	Tests        Tests
+	Dependencies Dependencies
+	Snapshots    Snapshots
+	// Users looks up the creators of jobs, who the runs act as. Without
+	// it, as when authentication is off, runs act as no one.
+	Users Users
+	// Roles, when set, stops running the jobs of creators who are no
+	// longer editors or owners of the workspace.
+	Roles Roles
+	// Bus receives the job.failed events.
+	Bus Bus
+}
+
+// Tests runs a workspace's tests, as gotest.Service does.
+type Tests interface {
+	Run(ctx context.Context, workspaceID, dir string, req gotest.Request) (*gotest.Report, error)
+}
+
+// Dependencies lists the dependencies of a workspace's module, as
+// gomod.Service does.
+type Dependencies interface {
+	List(ctx context.Context, workspaceID, dir, module string, updates bool) (*gomod.Dependencies, error)
+}
+
+// Snapshots takes snapshots, as snapshot.Service does.
+type Snapshots interface {
+	Create(ctx context.Context, workspaceID, dir string, trigger snapshot.Trigger, label string) (*snapshot.Snapshot, error)
+}
+
+// Users looks up users by ID, as auth.Service does.
+type Users interface {
+	User(id string) (*auth.User, error)
+}
+
+// Roles reports a user's role on a workspace, "" for none.
+type Roles interface {
+	Role(workspaceID, userID string) (workspace.Role, error)
+}
+
+// Bus delivers events to subscribers, as events.Bus does.
+type Bus interface {
+	Publish(e events.Event)
+}
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Kind is what a job runs.
+type Kind string
+
+const (
+	// KindTests runs go test; the run fails when tests fail or do not
+	// build.
+	KindTests Kind = "tests"
+	// KindDependencies looks up newer versions of a module's
+	// dependencies; the run fails when there are updates or the module
+	// depends on deprecated or retracted versions.
+	KindDependencies Kind = "dependencies"
+	// KindSnapshot snapshots the workspace, unless nothing changed since
+	// the last snapshot.
+	KindSnapshot Kind = "snapshot"
+)
+
+// Status is how a run went.
+type Status string
+
+const (
+	StatusQueued  Status = "queued"
+	StatusRunning Status = "running"
+	StatusPassed  Status = "passed"
+	// StatusFailed is a run that found something to act on, such as a
+	// failing test, and StatusError one that could not be run.
+	StatusFailed Status = "failed"
+	StatusError  Status = "error"
+)
+
+// Trigger is what started a run.
+type Trigger string
+
+const (
+	TriggerSchedule Trigger = "schedule"
+	TriggerManual   Trigger = "manual"
+)
+
+var (
+	// ErrNotFound is returned for jobs that do not exist.
+	ErrNotFound = errors.New("jobs: not found")
+	// ErrInvalid is returned for jobs with an invalid schedule or kind.
+	ErrInvalid = errors.New("jobs: invalid job")
+	// ErrTooMany is returned when a workspace is at Config.MaxJobs.
+	ErrTooMany = errors.New("jobs: too many jobs")
+	// ErrBusy is returned when a job is run while a run of it is queued
+	// or running.
+	ErrBusy = errors.New("jobs: job is already running")
+)
+
+// Spec is what a job runs and when.
+type Spec struct {
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+	// Schedule is a cron expression, such as "0 2 * * *" for 02:00 every
+	// day, or a macro such as @daily; see ParseSchedule.
+	Schedule string `json:"schedule"`
+	// TimeZone is the IANA time zone of Schedule; defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Paused stops scheduled runs; the job can still be run by hand.
+	Paused bool `json:"paused,omitempty"`
+	// Tests configures tests jobs.
+	Tests *gotest.Request `json:"tests,omitempty"`
+	// Module is the directory of the module whose dependencies a
+	// dependencies job checks; defaults to the workspace root.
+	Module string `json:"module,omitempty"`
+}
+
+// Job is a scheduled chore of a workspace.
+type Job struct {
+	ID        string `json:"id"`
+	Workspace string `json:"workspace"`
+	Spec
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// NextRun is when the job runs next, unset while paused, and LastRun
+	// its latest run.
+	NextRun *time.Time `json:"nextRun,omitempty"`
+	LastRun *Run       `json:"lastRun,omitempty"`
+}
+
+// Run is one run of a job.
+type Run struct {
+	ID         string     `json:"id"`
+	Job        string     `json:"job"`
+	Trigger    Trigger    `json:"trigger"`
+	Status     Status     `json:"status"`
+	QueuedAt   time.Time  `json:"queuedAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	DurationMS int64      `json:"durationMs,omitempty"`
+	// Summary says in a line what the run found, and Error why it could
+	// not be run.
+	Summary string `json:"summary,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Result is the outcome of the run: the test report, the dependency
+	// list or the snapshot. Results over 256 KiB are dropped.
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// maxResultBytes bounds the result kept of a run.
+const maxResultBytes = 256 << 10
+
+// checkInterval is how often the schedules are checked.
+const checkInterval = 15 * time.Second
+
+// gapRuns is how many upcoming runs of a schedule are checked against
+// Config.MinInterval.
+const gapRuns = 100
+
+// file is the stored form of a workspace's jobs.
+type file struct {
+	Jobs []*Job           `json:"jobs"`
+	Runs map[string][]Run `json:"runs,omitempty"` // job ID, newest first
+}
+
+// entry is a job with its parsed schedule.
+type entry struct {
+	job      *Job
+	schedule *Schedule
+	next     time.Time
+}
+
+// task is a run waiting for a worker.
+type task struct {
+	workspace string
+	job       string
+	run       string
+}
+
+// Service keeps the jobs of workspaces and runs them when due.
+type Service struct {
+	cfg        Config
+	workspaces Workspaces
+	now        func() time.Time
+
+	mu      sync.Mutex
+	files   map[string]*file
+	entries map[string]*entry // job ID
+	busy    map[string]bool   // job ID
+
+	queue  chan task
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a Service, filling unset Config fields with defaults, loads
+// the jobs stored before and starts running them on schedule.
+func New(cfg Config, ws Workspaces) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-jobs")
+	}
+	if cfg.MaxJobs <= 0 {
+		cfg.MaxJobs = 10
+	}
+	if cfg.History <= 0 {
+		cfg.History = 20
+	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = 15 * time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Minute
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("jobs: create dir: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		cfg:        cfg,
+		workspaces: ws,
+		now:        time.Now,
+		files:      make(map[string]*file),
+		entries:    make(map[string]*entry),
+		busy:       make(map[string]bool),
+		queue:      make(chan task, 64),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	if err := s.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+	for range cfg.Workers {
+		s.wg.Add(1)
+		go s.work()
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s, nil
+}
+
+// Close stops scheduling and cancels the runs in progress, which are
+// recorded as errors.
+func (s *Service) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Service) path(workspaceID string) string {
+	return filepath.Join(s.cfg.Dir, workspaceID+".json")
+}
+
+// load reads the jobs of every workspace. Runs a stopped server left
+// queued or running are recorded as interrupted.
+func (s *Service) load() error {
+	des, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("jobs: load: %w", err)
+	}
+	now := s.now().UTC()
+	for _, de := range des {
+		id, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok || !workspace.ValidID(id) {
+			continue
+		}
+		f, err := s.read(id)
+		if err != nil {
+			return err
+		}
+		interrupted := false
+		for job, runs := range f.Runs {
+			for i := range runs {
+				if r := &runs[i]; r.Status == StatusQueued || r.Status == StatusRunning {
+					r.Status, r.Error, r.FinishedAt = StatusError, "interrupted by a server restart", &now
+					interrupted = true
+				}
+			}
+			f.Runs[job] = runs
+		}
+		s.files[id] = f
+		for _, j := range f.Jobs {
+			if err := s.schedule(j); err != nil {
+				slog.Warn("jobs: not scheduling job", "workspace", id, "job", j.ID, "err", err)
+			}
+		}
+		if interrupted {
+			if err := s.writeLocked(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Service) read(workspaceID string) (*file, error) {
+	data, err := os.ReadFile(s.path(workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return &file{Runs: make(map[string][]Run)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: read %s: %w", workspaceID, err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("jobs: read %s: %w", workspaceID, err)
+	}
+	if f.Runs == nil {
+		f.Runs = make(map[string][]Run)
+	}
+	return &f, nil
+}
+
+// fileLocked returns the jobs of a workspace, empty for one without.
+func (s *Service) fileLocked(workspaceID string) *file {
+	f, ok := s.files[workspaceID]
+	if !ok {
+		f = &file{Runs: make(map[string][]Run)}
+		s.files[workspaceID] = f
+	}
+	return f
+}
+
+func (s *Service) writeLocked(workspaceID string) error {
+	f := s.fileLocked(workspaceID)
+	if len(f.Jobs) == 0 {
+		if err := os.Remove(s.path(workspaceID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("jobs: write %s: %w", workspaceID, err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path(workspaceID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("jobs: write %s: %w", workspaceID, err)
+	}
+	if err := os.Rename(tmp, s.path(workspaceID)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("jobs: write %s: %w", workspaceID, err)
+	}
+	return nil
+}
+
+// parse validates spec and returns its schedule.
+func (s *Service) parse(spec *Spec) (*Schedule, error) {
+	spec.Name = strings.TrimSpace(spec.Name)
+	if spec.Name == "" || len(spec.Name) > 100 {
+		return nil, fmt.Errorf("%w: name must be 1-100 characters", ErrInvalid)
+	}
+	switch spec.Kind {
+	case KindTests:
+		if s.cfg.Tests == nil {
+			return nil, fmt.Errorf("%w: tests jobs are not available", ErrInvalid)
+		}
+		if spec.Tests == nil {
+			spec.Tests = &gotest.Request{}
+		}
+	case KindDependencies:
+		if s.cfg.Dependencies == nil {
+			return nil, fmt.Errorf("%w: dependencies jobs are not available", ErrInvalid)
+		}
+	case KindSnapshot:
+		if s.cfg.Snapshots == nil {
+			return nil, fmt.Errorf("%w: snapshot jobs are not available", ErrInvalid)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q, want tests, dependencies or snapshot", ErrInvalid, spec.Kind)
+	}
+	if spec.Kind != KindTests {
+		spec.Tests = nil
+	}
+	if spec.Kind != KindDependencies {
+		spec.Module = ""
+	}
+	loc := time.UTC
+	if spec.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalid, spec.TimeZone)
+		}
+	}
+	sched, err := ParseSchedule(spec.Schedule, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if gap := sched.minGap(s.now(), gapRuns); gap < s.cfg.MinInterval {
+		return nil, fmt.Errorf("%w: schedule %q runs %s apart, more often than every %s", ErrInvalid, spec.Schedule, gap, s.cfg.MinInterval)
+	}
+	return sched, nil
+}
+
+// schedule parses the schedule of j and plans its next run.
+func (s *Service) schedule(j *Job) error {
+	sched, err := s.parse(&j.Spec)
+	if err != nil {
+		return err
+	}
+	e := &entry{job: j, schedule: sched}
+	if !j.Paused {
+		e.next = sched.Next(s.now())
+	}
+	s.entries[j.ID] = e
+	return nil
+}
+
+// view returns a copy of j with its next and last run.
+func (s *Service) view(j *Job) *Job {
+	c := *j
+	if e, ok := s.entries[j.ID]; ok && !e.next.IsZero() {
+		next := e.next.UTC()
+		c.NextRun = &next
+	}
+	if runs := s.fileLocked(j.Workspace).Runs[j.ID]; len(runs) > 0 {
+		last := runs[0]
+		last.Result = nil
+		c.LastRun = &last
+	}
+	return &c
+}
+
+// List returns the jobs of a workspace, oldest first.
+func (s *Service) List(workspaceID string) []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fileLocked(workspaceID)
+	jobs := make([]*Job, len(f.Jobs))
+	for i, j := range f.Jobs {
+		jobs[i] = s.view(j)
+	}
+	return jobs
+}
+
+// Get returns a job of a workspace.
+func (s *Service) Get(workspaceID, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, err := s.getLocked(workspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.view(j), nil
+}
+
+func (s *Service) getLocked(workspaceID, id string) (*Job, error) {
+	for _, j := range s.fileLocked(workspaceID).Jobs {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Create adds a job to a workspace, created by the user of ctx.
+func (s *Service) Create(ctx context.Context, workspaceID string, spec Spec) (*Job, error) {
+	now := s.now().UTC()
+	j := &Job{ID: newID(), Workspace: workspaceID, Spec: spec, CreatedAt: now, UpdatedAt: now}
+	if u := auth.UserFrom(ctx); u != nil {
+		j.CreatedBy = u.ID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fileLocked(workspaceID)
+	if len(f.Jobs) >= s.cfg.MaxJobs {
+		return nil, fmt.Errorf("%w: at most %d per workspace", ErrTooMany, s.cfg.MaxJobs)
+	}
+	if err := s.schedule(j); err != nil {
+		return nil, err
+	}
+	f.Jobs = append(f.Jobs, j)
+	if err := s.writeLocked(workspaceID); err != nil {
+		f.Jobs = f.Jobs[:len(f.Jobs)-1]
+		delete(s.entries, j.ID)
+		return nil, err
+	}
+	return s.view(j), nil
+}
+
+// Update replaces what a job runs and when, keeping its history.
+func (s *Service) Update(workspaceID, id string, spec Spec) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, err := s.getLocked(workspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	prev, prevEntry := *j, s.entries[id]
+	j.Spec, j.UpdatedAt = spec, s.now().UTC()
+	if err := s.schedule(j); err != nil {
+		*j = prev
+		s.entries[id] = prevEntry
+		return nil, err
+	}
+	if err := s.writeLocked(workspaceID); err != nil {
+		*j = prev
+		s.entries[id] = prevEntry
+		return nil, err
+	}
+	return s.view(j), nil
+}
+
+// Delete removes a job and its history. A run in progress finishes
+// without being recorded.
+func (s *Service) Delete(workspaceID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fileLocked(workspaceID)
+	i := slices.IndexFunc(f.Jobs, func(j *Job) bool { return j.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	j := f.Jobs[i]
+	f.Jobs = slices.Delete(f.Jobs, i, i+1)
+	runs := f.Runs[id]
+	delete(f.Runs, id)
+	if err := s.writeLocked(workspaceID); err != nil {
+		f.Jobs = slices.Insert(f.Jobs, i, j)
+		f.Runs[id] = runs
+		return err
+	}
+	delete(s.entries, id)
+	return nil
+}
+
+// Runs returns the recent runs of a job, newest first, without their
+// results.
+func (s *Service) Runs(workspaceID, id string) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getLocked(workspaceID, id); err != nil {
+		return nil, err
+	}
+	runs := slices.Clone(s.fileLocked(workspaceID).Runs[id])
+	for i := range runs {
+		runs[i].Result = nil
+	}
+	return runs, nil
+}
+
+// GetRun returns a run of a job with its result.
+func (s *Service) GetRun(workspaceID, id, runID string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getLocked(workspaceID, id); err != nil {
+		return nil, err
+	}
+	for _, r := range s.fileLocked(workspaceID).Runs[id] {
+		if r.ID == runID {
+			return &r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Trigger queues a run of a job now, outside its schedule.
+func (s *Service) Trigger(workspaceID, id string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getLocked(workspaceID, id); err != nil {
+		return nil, err
+	}
+	return s.enqueueLocked(workspaceID, id, TriggerManual)
+}
+
+// enqueueLocked records a queued run of a job and hands it to the
+// workers.
+func (s *Service) enqueueLocked(workspaceID, id string, trigger Trigger) (*Run, error) {
+	if s.busy[id] {
+		return nil, ErrBusy
+	}
+	r := Run{ID: newID(), Job: id, Trigger: trigger, Status: StatusQueued, QueuedAt: s.now().UTC()}
+	select {
+	case s.queue <- task{workspace: workspaceID, job: id, run: r.ID}:
+	default:
+		return nil, fmt.Errorf("%w: too many jobs waiting to run", ErrBusy)
+	}
+	s.busy[id] = true
+	s.recordLocked(workspaceID, r)
+	return &r, nil
+}
+
+// recordLocked adds or updates a run in its job's history and writes it.
+func (s *Service) recordLocked(workspaceID string, r Run) {
+	f := s.fileLocked(workspaceID)
+	if !slices.ContainsFunc(f.Jobs, func(j *Job) bool { return j.ID == r.Job }) {
+		return
+	}
+	runs := f.Runs[r.Job]
+	if i := slices.IndexFunc(runs, func(o Run) bool { return o.ID == r.ID }); i >= 0 {
+		runs[i] = r
+	} else {
+		runs = slices.Insert(runs, 0, r)
+		if len(runs) > s.cfg.History {
+			runs = runs[:s.cfg.History]
+		}
+	}
+	f.Runs[r.Job] = runs
+	if err := s.writeLocked(workspaceID); err != nil {
+		slog.Error("jobs: record run", "workspace", workspaceID, "job", r.Job, "err", err)
+	}
+}
+
+// loop queues the jobs that are due.
+func (s *Service) loop() {
+	defer s.wg.Done()
+	t := time.NewTicker(checkInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+		}
+		s.due()
+	}
+}
+
+func (s *Service) due() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for id, e := range s.entries {
+		if e.next.IsZero() || now.Before(e.next) {
+			continue
+		}
+		e.next = e.schedule.Next(now)
+		if _, err := s.workspaces.Open(e.job.Workspace); err != nil {
+			// The jobs of deleted workspaces do not run.
+			continue
+		}
+		if _, err := s.enqueueLocked(e.job.Workspace, id, TriggerSchedule); err != nil {
+			// A run that is still going covers this one.
+			slog.Warn("jobs: skipping scheduled run", "workspace", e.job.Workspace, "job", id, "err", err)
+		}
+	}
+}
+
+func (s *Service) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case t := <-s.queue:
+			s.execute(t)
+		}
+	}
+}
+
+// execute runs a queued run and records its outcome.
+func (s *Service) execute(t task) {
+	s.mu.Lock()
+	j, err := s.getLocked(t.workspace, t.job)
+	if err != nil {
+		delete(s.busy, t.job)
+		s.mu.Unlock()
+		return
+	}
+	job := *j
+	var r Run
+	for _, o := range s.fileLocked(t.workspace).Runs[t.job] {
+		if o.ID == t.run {
+			r = o
+		}
+	}
+	start := s.now().UTC()
+	r.Status, r.StartedAt = StatusRunning, &start
+	s.recordLocked(t.workspace, r)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.Timeout)
+	status, summary, result, err := s.run(ctx, &job)
+	cancel()
+	end := s.now().UTC()
+	r.Status, r.Summary, r.FinishedAt = status, summary, &end
+	r.DurationMS = end.Sub(start).Milliseconds()
+	if err != nil {
+		r.Status, r.Error = StatusError, err.Error()
+		switch {
+		case s.ctx.Err() != nil:
+			r.Error = "canceled by a server shutdown"
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			r.Error = fmt.Sprintf("timed out after %s", s.cfg.Timeout)
+		}
+	}
+	if result != nil {
+		if data, merr := json.Marshal(result); merr == nil && len(data) <= maxResultBytes {
+			r.Result = data
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.busy, t.job)
+	s.recordLocked(t.workspace, r)
+	s.mu.Unlock()
+	if r.Status == StatusFailed || r.Status == StatusError {
+		s.notify(&job, r)
+	}
+}
+
+// run runs a job as its creator.
+func (s *Service) run(ctx context.Context, j *Job) (Status, string, any, error) {
+	dir, err := s.workspaces.Open(j.Workspace)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("workspace %s not found", j.Workspace)
+	}
+	if ctx, err = s.actAs(ctx, j); err != nil {
+		return "", "", nil, err
+	}
+	switch j.Kind {
+	case KindTests:
+		rep, err := s.cfg.Tests.Run(ctx, j.Workspace, dir, *j.Tests)
+		if err != nil {
+			return "", "", nil, err
+		}
+		summary := fmt.Sprintf("%d passed, %d failed, %d skipped", rep.Summary.Passed, rep.Summary.Failed, rep.Summary.Skipped)
+		switch {
+		case rep.TimedOut:
+			summary = "tests timed out; " + summary
+		case !rep.Passed && rep.Summary.Failed == 0:
+			summary = "tests did not build"
+		}
+		if rep.Coverage != nil {
+			summary += fmt.Sprintf(", %.1f%% coverage", *rep.Coverage)
+		}
+		if !rep.Passed {
+			return StatusFailed, summary, rep, nil
+		}
+		return StatusPassed, summary, rep, nil
+	case KindDependencies:
+		deps, err := s.cfg.Dependencies.List(ctx, j.Workspace, dir, j.Module, true)
+		if err != nil {
+			return "", "", nil, err
+		}
+		var updates, deprecated, retracted int
+		for _, d := range slices.Concat(deps.Direct, deps.Indirect) {
+			if d.Update != "" {
+				updates++
+			}
+			if d.Deprecated != "" {
+				deprecated++
+			}
+			if len(d.Retracted) > 0 {
+				retracted++
+			}
+		}
+		summary := fmt.Sprintf("%d updates available", updates)
+		if deprecated+retracted > 0 {
+			summary += fmt.Sprintf(", %d deprecated, %d retracted", deprecated, retracted)
+		}
+		if updates+deprecated+retracted > 0 {
+			return StatusFailed, summary, deps, nil
+		}
+		return StatusPassed, "dependencies are up to date", deps, nil
+	case KindSnapshot:
+		sn, err := s.cfg.Snapshots.Create(ctx, j.Workspace, dir, snapshot.TriggerJob, j.Name)
+		if errors.Is(err, snapshot.ErrUnchanged) {
+			return StatusPassed, "unchanged since the last snapshot", nil, nil
+		}
+		if err != nil {
+			return "", "", nil, err
+		}
+		return StatusPassed, fmt.Sprintf("snapshot %s of %d files", sn.ID, sn.Files), sn, nil
+	}
+	return "", "", nil, fmt.Errorf("%w: unknown kind %q", ErrInvalid, j.Kind)
+}
+
+// actAs returns ctx carrying the creator of j, who must still be allowed
+// to run it.
+func (s *Service) actAs(ctx context.Context, j *Job) (context.Context, error) {
+	if s.cfg.Users == nil || j.CreatedBy == "" {
+		return ctx, nil
+	}
+	u, err := s.cfg.Users.User(j.CreatedBy)
+	if err != nil {
+		return nil, fmt.Errorf("creator %s of the job not found", j.CreatedBy)
+	}
+	if s.cfg.Roles != nil {
+		role, err := s.cfg.Roles.Role(j.Workspace, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		if role != workspace.RoleOwner && role != workspace.RoleEditor {
+			return nil, fmt.Errorf("creator %s of the job can no longer edit the workspace", u.Email)
+		}
+	}
+	return auth.WithUser(ctx, u), nil
+}
+
+// notify publishes the failure of a run.
+func (s *Service) notify(j *Job, r Run) {
+	if s.cfg.Bus == nil {
+		return
+	}
+	data := map[string]any{
+		"job": j.ID, "name": j.Name, "kind": j.Kind, "run": r.ID,
+		"trigger": r.Trigger, "status": r.Status, "summary": r.Summary,
+	}
+	if r.Error != "" {
+		data["error"] = r.Error
+	}
+	s.cfg.Bus.Publish(events.Event{Type: events.JobFailed, Workspace: j.Workspace, User: j.CreatedBy, Data: data})
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
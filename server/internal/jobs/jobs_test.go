@@ -0,0 +1,258 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/gotest"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+type fakeWorkspaces map[string]string
+
+func (w fakeWorkspaces) Open(id string) (string, error) {
+	dir, ok := w[id]
+	if !ok {
+		return "", errors.New("no such workspace")
+	}
+	return dir, nil
+}
+
+// fakeTests answers each run with the next of its reports, and with an
+// error once they run out.
+type fakeTests struct {
+	mu      sync.Mutex
+	reports []*gotest.Report
+	users   []string // who each run acted as
+}
+
+func (f *fakeTests) Run(ctx context.Context, workspaceID, dir string, req gotest.Request) (*gotest.Report, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u := ""
+	if user := auth.UserFrom(ctx); user != nil {
+		u = user.ID
+	}
+	f.users = append(f.users, u)
+	if len(f.reports) == 0 {
+		return nil, errors.New("go: not found")
+	}
+	rep := f.reports[0]
+	f.reports = f.reports[1:]
+	return rep, nil
+}
+
+type fakeBus struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (b *fakeBus) Publish(e events.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, e)
+}
+
+func (b *fakeBus) published() []events.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]events.Event(nil), b.events...)
+}
+
+type fakeUsers struct{}
+
+func (fakeUsers) User(id string) (*auth.User, error) {
+	return &auth.User{ID: id, Email: id + "@example.com"}, nil
+}
+
+type fakeRoles map[[2]string]workspace.Role
+
+func (r fakeRoles) Role(workspaceID, userID string) (workspace.Role, error) {
+	return r[[2]string{workspaceID, userID}], nil
+}
+
+// wait returns the runs of a job once the newest has finished.
+func wait(t *testing.T, s *Service, workspaceID, id string) []Run {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		runs, err := s.Runs(workspaceID, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) > 0 && runs[0].FinishedAt != nil {
+			return runs
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("run did not finish")
+	return nil
+}
+
+func TestRunHistory(t *testing.T) {
+	tests := &fakeTests{reports: []*gotest.Report{
+		{Passed: true, Summary: gotest.Summary{Passed: 3}},
+		{Passed: false, Summary: gotest.Summary{Passed: 2, Failed: 1}},
+	}}
+	bus := &fakeBus{}
+	dir := t.TempDir()
+	s, err := New(Config{Dir: dir, History: 2, Tests: tests, Bus: bus, Users: fakeUsers{}}, fakeWorkspaces{"ws1": t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := auth.WithUser(context.Background(), &auth.User{ID: "u1"})
+	j, err := s.Create(ctx, "ws1", Spec{Name: "nightly", Kind: KindTests, Schedule: "@daily"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A passing run is recorded and tells no one.
+	if _, err := s.Trigger("ws1", j.ID); err != nil {
+		t.Fatal(err)
+	}
+	runs := wait(t, s, "ws1", j.ID)
+	if r := runs[0]; r.Status != StatusPassed || r.Trigger != TriggerManual || r.Summary != "3 passed, 0 failed, 0 skipped" || r.StartedAt == nil {
+		t.Errorf("passing run = %+v", r)
+	}
+	if got := bus.published(); len(got) != 0 {
+		t.Errorf("a passing run published %v", got)
+	}
+
+	// A failing one publishes job.failed.
+	if _, err := s.Trigger("ws1", j.ID); err != nil {
+		t.Fatal(err)
+	}
+	runs = wait(t, s, "ws1", j.ID)
+	failed := runs[0]
+	if failed.Status != StatusFailed || failed.Summary != "2 passed, 1 failed, 0 skipped" {
+		t.Errorf("failing run = %+v", failed)
+	}
+	got := bus.published()
+	if len(got) != 1 || got[0].Type != events.JobFailed || got[0].Workspace != "ws1" || got[0].User != "u1" ||
+		got[0].Data["run"] != failed.ID || got[0].Data["status"] != StatusFailed || got[0].Data["name"] != "nightly" {
+		t.Errorf("after a failing run, published %+v", got)
+	}
+	if run, err := s.GetRun("ws1", j.ID, failed.ID); err != nil || len(run.Result) == 0 {
+		t.Errorf("GetRun = %+v, %v; want the report kept", run, err)
+	}
+
+	// One that cannot run is an error, with why, and History keeps the
+	// newest runs.
+	if _, err := s.Trigger("ws1", j.ID); err != nil {
+		t.Fatal(err)
+	}
+	runs = wait(t, s, "ws1", j.ID)
+	if len(runs) != 2 || runs[0].Status != StatusError || runs[0].Error != "go: not found" || runs[1].ID != failed.ID {
+		t.Errorf("runs = %+v", runs)
+	}
+	if got := bus.published(); len(got) != 2 || got[1].Data["error"] != "go: not found" {
+		t.Errorf("after a run that could not run, published %+v", got)
+	}
+	if got, err := s.Get("ws1", j.ID); err != nil || got.LastRun == nil || got.LastRun.ID != runs[0].ID {
+		t.Errorf("Get = %+v, %v; want the last run", got, err)
+	}
+	tests.mu.Lock()
+	if want := []string{"u1", "u1", "u1"}; !slices.Equal(tests.users, want) {
+		t.Errorf("runs acted as %v, want %v", tests.users, want)
+	}
+	tests.mu.Unlock()
+
+	// The history outlives a restart.
+	s.Close()
+	s, err = New(Config{Dir: dir, History: 2, Tests: tests}, fakeWorkspaces{"ws1": t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if again, err := s.Runs("ws1", j.ID); err != nil || len(again) != 2 || again[0].ID != runs[0].ID {
+		t.Errorf("after a restart, Runs = %+v, %v", again, err)
+	}
+}
+
+func TestScheduledRun(t *testing.T) {
+	tests := &fakeTests{reports: []*gotest.Report{{Passed: true}}}
+	s, err := New(Config{Dir: t.TempDir(), Tests: tests}, fakeWorkspaces{"ws1": t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	now := time.Date(2026, 1, 1, 1, 59, 0, 0, time.UTC)
+	s.mu.Lock()
+	s.now = func() time.Time { return now }
+	s.mu.Unlock()
+	j, err := s.Create(context.Background(), "ws1", Spec{Name: "nightly", Kind: KindTests, Schedule: "0 2 * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.NextRun == nil || !j.NextRun.Equal(now.Add(time.Minute)) {
+		t.Errorf("NextRun = %v, want %v", j.NextRun, now.Add(time.Minute))
+	}
+
+	s.due()
+	if runs, _ := s.Runs("ws1", j.ID); len(runs) != 0 {
+		t.Fatalf("ran before it was due: %+v", runs)
+	}
+	s.mu.Lock()
+	now = now.Add(time.Minute)
+	s.mu.Unlock()
+	s.due()
+	if runs := wait(t, s, "ws1", j.ID); len(runs) != 1 || runs[0].Trigger != TriggerSchedule || runs[0].Status != StatusPassed {
+		t.Errorf("runs = %+v", runs)
+	}
+	if got, _ := s.Get("ws1", j.ID); got.NextRun == nil || !got.NextRun.Equal(now.Add(24*time.Hour)) {
+		t.Errorf("after the run, NextRun = %v", got.NextRun)
+	}
+}
+
+func TestRunCreatorNoLongerEditor(t *testing.T) {
+	tests := &fakeTests{reports: []*gotest.Report{{Passed: true}}}
+	bus := &fakeBus{}
+	roles := fakeRoles{{"ws1", "u1"}: workspace.RoleViewer}
+	s, err := New(Config{Dir: t.TempDir(), Tests: tests, Bus: bus, Users: fakeUsers{}, Roles: roles}, fakeWorkspaces{"ws1": t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	j, err := s.Create(auth.WithUser(context.Background(), &auth.User{ID: "u1"}), "ws1", Spec{Name: "nightly", Kind: KindTests, Schedule: "@daily"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Trigger("ws1", j.ID); err != nil {
+		t.Fatal(err)
+	}
+	runs := wait(t, s, "ws1", j.ID)
+	tests.mu.Lock()
+	if runs[0].Status != StatusError || len(tests.users) != 0 {
+		t.Errorf("run by a creator who now views the workspace = %+v, ran as %v", runs[0], tests.users)
+	}
+	tests.mu.Unlock()
+	if got := bus.published(); len(got) != 1 || got[0].Type != events.JobFailed {
+		t.Errorf("published %+v, want job.failed", got)
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	s, err := New(Config{Dir: t.TempDir(), Tests: &fakeTests{}}, fakeWorkspaces{"ws1": t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, spec := range []Spec{
+		{Name: "", Kind: KindTests, Schedule: "@daily"},
+		{Name: "x", Kind: KindSnapshot, Schedule: "@daily"},
+		{Name: "x", Kind: "lint", Schedule: "@daily"},
+		{Name: "x", Kind: KindTests, Schedule: "*/5 * * * *"},
+		{Name: "x", Kind: KindTests, Schedule: "0 0 30 2 *"},
+		{Name: "x", Kind: KindTests, Schedule: "@daily", TimeZone: "Mars/Olympus"},
+	} {
+		if _, err := s.Create(context.Background(), "ws1", spec); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Create(%+v) = %v, want ErrInvalid", spec, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// at parses a time in loc in the layout "2006-01-02 15:04".
+func at(t *testing.T, loc *time.Location, s string) time.Time {
+	t.Helper()
+	tm, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr  string
+		after string   // UTC
+		want  []string // the next runs, UTC
+	}{
+		{"30 2 * * *", "2026-01-01 00:00", []string{"2026-01-01 02:30", "2026-01-02 02:30"}},
+		{"30 2 * * *", "2026-01-01 02:30", []string{"2026-01-02 02:30"}},
+		{"*/20 * * * *", "2026-01-01 10:05", []string{"2026-01-01 10:20", "2026-01-01 10:40", "2026-01-01 11:00"}},
+		// a/n steps from a to the end of the field.
+		{"5/20 * * * *", "2026-01-01 10:00", []string{"2026-01-01 10:05", "2026-01-01 10:25", "2026-01-01 10:45", "2026-01-01 11:05"}},
+		{"0 9-17/4 * * *", "2026-01-01 00:00", []string{"2026-01-01 09:00", "2026-01-01 13:00", "2026-01-01 17:00", "2026-01-02 09:00"}},
+		{"0 8,12 * * *", "2026-01-01 09:00", []string{"2026-01-01 12:00", "2026-01-02 08:00"}},
+		// 2026-01-01 is a Thursday.
+		{"0 0 * * 1-5", "2026-01-02 12:00", []string{"2026-01-05 00:00"}},
+		{"0 0 * * mon-FRI", "2026-01-02 12:00", []string{"2026-01-05 00:00"}},
+		{"0 0 1 jan,Jul *", "2026-01-01 00:00", []string{"2026-07-01 00:00", "2027-01-01 00:00"}},
+		// Sunday is 0 or 7.
+		{"0 0 * * 7", "2026-01-01 00:00", []string{"2026-01-04 00:00", "2026-01-11 00:00"}},
+		{"0 0 * * 0", "2026-01-01 00:00", []string{"2026-01-04 00:00"}},
+		{"0 0 * * 5-7", "2026-01-01 00:00", []string{"2026-01-02 00:00", "2026-01-03 00:00", "2026-01-04 00:00", "2026-01-09 00:00"}},
+		// Restricting both days runs on either; a field starting with *
+		// counts as unrestricted, as in cron, and both must match.
+		{"0 0 13 * 5", "2026-01-01 00:00", []string{"2026-01-02 00:00", "2026-01-09 00:00", "2026-01-13 00:00", "2026-01-16 00:00"}},
+		{"0 0 13 * *", "2026-01-01 00:00", []string{"2026-01-13 00:00", "2026-02-13 00:00"}},
+		{"0 0 */10 * 5", "2026-01-01 00:00", []string{"2026-05-01 00:00", "2026-07-31 00:00"}},
+		// February 29 waits for a leap year.
+		{"0 12 29 2 *", "2026-03-01 00:00", []string{"2028-02-29 12:00", "2032-02-29 12:00"}},
+		{"0 0 31 * *", "2026-04-01 00:00", []string{"2026-05-31 00:00", "2026-07-31 00:00"}},
+		{"@hourly", "2026-01-01 10:30", []string{"2026-01-01 11:00"}},
+		{"@weekly", "2026-01-01 10:30", []string{"2026-01-04 00:00"}},
+		{"@YEARLY", "2026-01-01 10:30", []string{"2027-01-01 00:00"}},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr, time.UTC)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tt.expr, err)
+			continue
+		}
+		prev := at(t, time.UTC, tt.after)
+		for _, w := range tt.want {
+			next := s.Next(prev)
+			if want := at(t, time.UTC, w); !next.Equal(want) {
+				t.Errorf("%q: Next(%s) = %s, want %s", tt.expr, prev.Format(time.DateTime), next.Format(time.DateTime), w)
+				break
+			}
+			prev = next
+		}
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name  string
+		expr  string
+		after string   // New York
+		want  []string // New York, the clock's first pass at a repeated time
+	}{
+		// Clocks go from 01:59 to 03:00 on 2026-03-08, so 02:30 does not
+		// come that day.
+		{"gap", "30 2 * * *", "2026-03-07 12:00", []string{"2026-03-09 02:30"}},
+		{"around the gap", "30 * * * *", "2026-03-08 00:45", []string{"2026-03-08 01:30", "2026-03-08 03:30"}},
+		{"daily through the gap", "0 9 * * *", "2026-03-07 12:00", []string{"2026-03-08 09:00", "2026-03-09 09:00"}},
+		// Clocks go from 01:59 back to 01:00 on 2026-11-01, and 01:30
+		// runs once.
+		{"repeat", "30 1 * * *", "2026-10-31 12:00", []string{"2026-11-01 01:30", "2026-11-02 01:30"}},
+		{"hourly through the repeat", "0 * * * *", "2026-11-01 00:30", []string{"2026-11-01 01:00", "2026-11-01 02:00"}},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev := at(t, ny, tt.after)
+		for _, w := range tt.want {
+			next := s.Next(prev)
+			if want := at(t, ny, w); !next.Equal(want) {
+				t.Errorf("%s: %q Next(%s) = %s, want %s", tt.name, tt.expr, prev.Format(time.DateTime+" MST"), next.Format(time.DateTime+" MST"), want.Format(time.DateTime+" MST"))
+				break
+			}
+			prev = next
+		}
+	}
+
+	// The runs are an hour apart across the repeat, not less.
+	s, _ := ParseSchedule("0 * * * *", ny)
+	if gap := s.minGap(at(t, ny, "2026-10-31 23:30"), 5); gap != time.Hour {
+		t.Errorf("minGap across the repeat = %s, want 1h", gap)
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // in the error
+	}{
+		{"0 0 * *", "want 5 fields"},
+		{"0 0 * * * *", "want 5 fields"},
+		{"@often", "want 5 fields"},
+		{"60 * * * *", "minute"},
+		{"* 24 * * *", "hour"},
+		{"* * 0 * *", "day of month"},
+		{"* * 32 * *", "day of month"},
+		{"* * * 13 *", "month"},
+		{"* * * jun-mar *", "month"},
+		{"* * * * 8", "day of week"},
+		{"* * * * sunday", "day of week"},
+		{"*/0 * * * *", "invalid step"},
+		{"*/x * * * *", "invalid step"},
+		{"30-10 * * * *", "invalid range"},
+		{"1,,2 * * * *", "minute"},
+		// Days that never come.
+		{"0 0 30 2 *", "never runs"},
+		{"0 0 31 4,6,9,11 *", "never runs"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseSchedule(tt.expr, time.UTC); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseSchedule(%q) = %v, want an error about %s", tt.expr, err, tt.want)
+		}
+	}
+	// Restricting the day of the week too makes it run.
+	if _, err := ParseSchedule("0 0 30 2 1", time.UTC); err != nil {
+		t.Errorf("ParseSchedule(0 0 30 2 1): %v", err)
+	}
+}
+
+func TestMinGap(t *testing.T) {
+	from := at(t, time.UTC, "2026-01-01 00:00")
+	tests := []struct {
+		expr string
+		want time.Duration
+	}{
+		{"*/5 * * * *", 5 * time.Minute},
+		{"0,50 * * * *", 10 * time.Minute},
+		{"0 2 * * *", 24 * time.Hour},
+		{"0 0 * * 1,2", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.minGap(from, gapRuns); got != tt.want {
+			t.Errorf("minGap(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package kube drives a Kubernetes cluster through kubectl, as the docker
+// drivers drive the docker CLI. Run inside the cluster, kubectl uses the
+// server pod's service account; outside it, the kubeconfig.
+//
+// The account needs to create, get, list and delete pods, create and get
+// pods/exec, and apply network policies in its namespace.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for objects that do not exist.
+var ErrNotFound = errors.New("kube: not found")
+
+// Client runs kubectl against one namespace.
+type Client struct {
+	// Binary is the kubectl executable; defaults to "kubectl".
+	Binary string
+	// Namespace holds the IDE's pods; defaults to kubectl's, which is
+	// the server pod's own inside the cluster.
+	Namespace string
+	// Context selects a kubeconfig context; defaults to the current one.
+	Context string
+}
+
+// Command returns kubectl with args, for the namespace and context of c.
+func (c *Client) Command(args ...string) *exec.Cmd {
+	return exec.Command(c.binary(), c.args(args)...)
+}
+
+// Exec returns a command running argv in the first container of pod,
+// with its standard input attached if stdin is set and a terminal
+// allocated if tty is.
+func (c *Client) Exec(pod string, stdin, tty bool, argv ...string) *exec.Cmd {
+	args := []string{"exec", pod}
+	if stdin {
+		args = append(args, "--stdin")
+	}
+	if tty {
+		args = append(args, "--tty")
+	}
+	return c.Command(append(append(args, "--"), argv...)...)
+}
+
+// Create creates obj, which is marshalled to JSON.
+func (c *Client) Create(ctx context.Context, obj any) error {
+	return c.send(ctx, "create", obj)
+}
+
+// Apply creates obj or updates it to match.
+func (c *Client) Apply(ctx context.Context, obj any) error {
+	return c.send(ctx, "apply", obj)
+}
+
+func (c *Client) send(ctx context.Context, verb string, obj any) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = c.run(ctx, data, verb, "--filename", "-")
+	return err
+}
+
+// Get decodes the object of kind named name into out, or returns
+// ErrNotFound.
+func (c *Client) Get(ctx context.Context, kind, name string, out any) error {
+	data, err := c.run(ctx, nil, "get", kind, name, "--output", "json")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// List decodes the objects of kind whose labels match selector into out,
+// a list such as *PodList.
+func (c *Client) List(ctx context.Context, kind, selector string, out any) error {
+	data, err := c.run(ctx, nil, "get", kind, "--selector", selector, "--output", "json")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Delete deletes the object of kind named name, if there is one, and
+// with wait returns once it is gone. Pods are given a second to stop.
+func (c *Client) Delete(ctx context.Context, kind, name string, wait bool) error {
+	_, err := c.run(ctx, nil, "delete", kind, name, "--ignore-not-found",
+		"--grace-period", "1", "--wait="+fmt.Sprint(wait))
+	return err
+}
+
+// WaitReady waits up to timeout for pod's containers to start. A pod that
+// cannot start, such as for an image that cannot be pulled, fails with
+// the reason its container is waiting.
+func (c *Client) WaitReady(ctx context.Context, pod string, timeout time.Duration) error {
+	_, err := c.run(ctx, nil, "wait", "pod/"+pod, "--for", "condition=Ready", "--timeout", timeout.String())
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	var p Pod
+	if c.Get(ctx, "pod", pod, &p) == nil {
+		for _, s := range p.Status.ContainerStatuses {
+			if w := s.State.Waiting; w != nil && w.Reason != "" {
+				return fmt.Errorf("kube: pod %s is not ready: %s: %s", pod, w.Reason, w.Message)
+			}
+		}
+	}
+	return err
+}
+
+// Ping reports whether the cluster's API server is ready.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.run(ctx, nil, "get", "--raw", "/readyz")
+	return err
+}
+
+// run runs kubectl with args and input on its standard input, and
+// returns its output.
+func (c *Client) run(ctx context.Context, input []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.binary(), c.args(args)...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "(NotFound)") {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, msg)
+		}
+		return nil, fmt.Errorf("kube: kubectl %s: %v: %s", args[0], err, msg)
+	}
+	return out, nil
+}
+
+func (c *Client) args(args []string) []string {
+	var global []string
+	if c.Context != "" {
+		global = append(global, "--context", c.Context)
+	}
+	if c.Namespace != "" {
+		global = append(global, "--namespace", c.Namespace)
+	}
+	return append(global, args...)
+}
+
+func (c *Client) binary() string {
+	if c.Binary == "" {
+		return "kubectl"
+	}
+	return c.Binary
+}
@@ -0,0 +1,160 @@
+package kube
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// The objects below carry only the fields the IDE sets or reads; see the
+// Kubernetes API reference for their meaning.
+
+// ObjectMeta is the metadata of an object.
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	DeletionTimestamp string            `json:"deletionTimestamp,omitempty"`
+}
+
+// Pod is a core/v1 Pod.
+type Pod struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       PodSpec    `json:"spec"`
+	Status     PodStatus  `json:"status,omitempty"`
+}
+
+// NewPod returns a Pod with meta and spec.
+func NewPod(meta ObjectMeta, spec PodSpec) *Pod {
+	return &Pod{APIVersion: "v1", Kind: "Pod", Metadata: meta, Spec: spec}
+}
+
+// PodList is a list of pods, from Client.List.
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// PodSpec describes a pod's containers and volumes.
+type PodSpec struct {
+	Containers                    []Container `json:"containers"`
+	Volumes                       []Volume    `json:"volumes,omitempty"`
+	RestartPolicy                 string      `json:"restartPolicy,omitempty"`
+	RuntimeClassName              string      `json:"runtimeClassName,omitempty"`
+	AutomountServiceAccountToken  *bool       `json:"automountServiceAccountToken,omitempty"`
+	EnableServiceLinks            *bool       `json:"enableServiceLinks,omitempty"`
+	TerminationGracePeriodSeconds *int64      `json:"terminationGracePeriodSeconds,omitempty"`
+}
+
+// Container is a container of a pod.
+type Container struct {
+	Name            string               `json:"name"`
+	Image           string               `json:"image"`
+	Command         []string             `json:"command,omitempty"`
+	WorkingDir      string               `json:"workingDir,omitempty"`
+	Env             []EnvVar             `json:"env,omitempty"`
+	Resources       ResourceRequirements `json:"resources,omitempty"`
+	VolumeMounts    []VolumeMount        `json:"volumeMounts,omitempty"`
+	SecurityContext *SecurityContext     `json:"securityContext,omitempty"`
+}
+
+// EnvVar is a variable of a container's environment.
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ResourceRequirements holds the requests and limits of a container, by
+// resource name, as quantities such as "500m" or "512Mi".
+type ResourceRequirements struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+// Resources returns the quantities for cpus cores and memoryMB megabytes
+// of memory; zero values are left out.
+func Resources(cpus float64, memoryMB int64) map[string]string {
+	r := make(map[string]string)
+	if cpus > 0 {
+		r["cpu"] = fmt.Sprintf("%dm", int64(cpus*1000))
+	}
+	if memoryMB > 0 {
+		r["memory"] = strconv.FormatInt(memoryMB, 10) + "Mi"
+	}
+	return r
+}
+
+// VolumeMount mounts a volume, or the directory SubPath of it, in a
+// container.
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	SubPath   string `json:"subPath,omitempty"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// Volume is a volume of a pod: an empty directory, or a claim.
+type Volume struct {
+	Name                  string             `json:"name"`
+	EmptyDir              *EmptyDirSource    `json:"emptyDir,omitempty"`
+	PersistentVolumeClaim *ClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+}
+
+// EmptyDirSource is a volume that starts empty and lives as long as the
+// pod. Medium "Memory" keeps it in memory, like a tmpfs.
+type EmptyDirSource struct {
+	Medium    string `json:"medium,omitempty"`
+	SizeLimit string `json:"sizeLimit,omitempty"`
+}
+
+// ClaimVolumeSource is a volume of a persistent volume claim.
+type ClaimVolumeSource struct {
+	ClaimName string `json:"claimName"`
+}
+
+// SecurityContext is the security settings of a container.
+type SecurityContext struct {
+	AllowPrivilegeEscalation *bool         `json:"allowPrivilegeEscalation,omitempty"`
+	ReadOnlyRootFilesystem   *bool         `json:"readOnlyRootFilesystem,omitempty"`
+	Capabilities             *Capabilities `json:"capabilities,omitempty"`
+}
+
+// Capabilities are Linux capabilities removed from and added to a
+// container's.
+type Capabilities struct {
+	Drop []string `json:"drop,omitempty"`
+	Add  []string `json:"add,omitempty"`
+}
+
+// PodStatus is the observed state of a pod.
+type PodStatus struct {
+	Phase             string            `json:"phase,omitempty"`
+	PodIP             string            `json:"podIP,omitempty"`
+	ContainerStatuses []ContainerStatus `json:"containerStatuses,omitempty"`
+}
+
+// ContainerStatus is the observed state of a container.
+type ContainerStatus struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	State struct {
+		Waiting *struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"waiting,omitempty"`
+	} `json:"state"`
+}
+
+// Pod phases.
+const (
+	PodPending   = "Pending"
+	PodRunning   = "Running"
+	PodSucceeded = "Succeeded"
+	PodFailed    = "Failed"
+)
+
+// Bool returns a pointer to v, for the optional fields of objects.
+func Bool(v bool) *bool { return &v }
+
+// Int64 returns a pointer to v.
+func Int64(v int64) *int64 { return &v }
@@ -0,0 +1,92 @@
+package kube
+
+import "context"
+
+// Labels of the IDE's pods, which its network policies select on.
+const (
+	// LabelType is "workspace" for workspace pods and "run" for run pods.
+	LabelType = "ai-ide.type"
+	// LabelNetwork is "none" for pods without networking, and "on" for
+	// the others.
+	LabelNetwork = "ai-ide.network"
+)
+
+// Isolation configures the network policies of the IDE's pods. Policies
+// are only enforced by network plugins that implement them.
+type Isolation struct {
+	// Server labels the server's pods, which alone may connect to
+	// workspace pods, as port previews do.
+	Server map[string]string
+	// Restrict limits the pods with networking to connecting to the
+	// server's pods, where the network policy proxy runs, and to DNS.
+	// Otherwise they may connect anywhere.
+	Restrict bool
+}
+
+// ApplyPolicies creates or updates the network policies of the IDE's
+// pods: those without networking can neither connect nor be connected
+// to, and the others as iso allows.
+func (c *Client) ApplyPolicies(ctx context.Context, iso Isolation) error {
+	offline := newPolicy("ai-ide-offline", map[string]string{LabelNetwork: "none"})
+	if err := c.Apply(ctx, offline); err != nil {
+		return err
+	}
+	online := newPolicy("ai-ide-online", map[string]string{LabelNetwork: "on"})
+	server := []policyPeer{{PodSelector: &labelSelector{MatchLabels: iso.Server}}}
+	online.Spec.Ingress = []policyRule{{From: server}}
+	if iso.Restrict {
+		online.Spec.Egress = []policyRule{
+			{To: server},
+			{Ports: []policyPort{{Protocol: "UDP", Port: 53}, {Protocol: "TCP", Port: 53}}},
+		}
+	} else {
+		online.Spec.Egress = []policyRule{{}}
+	}
+	return c.Apply(ctx, online)
+}
+
+// policy is a networking.k8s.io/v1 NetworkPolicy.
+type policy struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       struct {
+		PodSelector labelSelector `json:"podSelector"`
+		PolicyTypes []string      `json:"policyTypes"`
+		Ingress     []policyRule  `json:"ingress"`
+		Egress      []policyRule  `json:"egress"`
+	} `json:"spec"`
+}
+
+// newPolicy returns a policy named name that, until rules are added,
+// denies all connections to and from the pods labelled labels.
+func newPolicy(name string, labels map[string]string) *policy {
+	p := &policy{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"}
+	p.Metadata.Name = name
+	p.Spec.PodSelector.MatchLabels = labels
+	p.Spec.PolicyTypes = []string{"Ingress", "Egress"}
+	p.Spec.Ingress = []policyRule{}
+	p.Spec.Egress = []policyRule{}
+	return p
+}
+
+type labelSelector struct {
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+// policyRule allows connections from or to peers on ports; a rule
+// without either allows all.
+type policyRule struct {
+	From  []policyPeer `json:"from,omitempty"`
+	To    []policyPeer `json:"to,omitempty"`
+	Ports []policyPort `json:"ports,omitempty"`
+}
+
+type policyPeer struct {
+	PodSelector *labelSelector `json:"podSelector,omitempty"`
+}
+
+type policyPort struct {
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+}
@@ -0,0 +1,127 @@
+package lint
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the lint API for workspaces.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler linting with svc. wsOpts configures the
+// WebSocket upgrade for streamed runs and may be nil.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the lint routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/workspaces/{id}/lint", h.lint)
+	mux.HandleFunc("GET /ws/lint/{id}", h.serveStream)
+}
+
+func (h *Handler) lint(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	var req Request
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res, err := h.svc.Lint(r.Context(), id, dir, req, nil)
+	if err != nil {
+		status, msg := errorStatus(id, err)
+		httpx.Error(w, status, msg)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// errorStatus maps a Lint error to an HTTP status and client message.
+func errorStatus(id string, err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrTooManyRuns):
+		return http.StatusTooManyRequests, err.Error()
+	}
+	slog.Error("lint", "workspace", id, "err", err)
+	return http.StatusInternalServerError, "could not lint workspace"
+}
+
+// clientFrame is the opening message sent by the editor over /ws/lint.
+type clientFrame struct {
+	Type string `json:"type"`
+	Request
+}
+
+// errorFrame reports a request-level failure.
+type errorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// serveStream handles /ws/lint/{id}. The client sends a
+// {"type":"lint", ...Request} frame; the server replies with Events as
+// each linter reports, and closes the socket after the done event.
+func (h *Handler) serveStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var first clientFrame
+	if err := conn.ReadJSON(&first); err != nil || first.Type != "lint" {
+		conn.WriteJSON(errorFrame{Type: "error", Error: "expected a lint frame"})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// A disconnecting client cancels the run.
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	_, err = h.svc.Lint(ctx, id, dir, first.Request, func(ev Event) {
+		if werr := conn.WriteJSON(ev); werr != nil {
+			cancel()
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		_, msg := errorStatus(id, err)
+		conn.WriteJSON(errorFrame{Type: "error", Error: msg})
+		return
+	}
+	conn.CloseWithCode(ws.CloseNormal, "lint finished")
+}
@@ -0,0 +1,351 @@
+// Package lint runs go vet, staticcheck, and golangci-lint inside a
+// workspace's environment and normalizes their findings into diag
+// diagnostics, reported as they are found.
+package lint
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+)
+
+// Linter names a supported tool.
+type Linter string
+
+const (
+	Vet          Linter = "vet"
+	Staticcheck  Linter = "staticcheck"
+	GolangciLint Linter = "golangci-lint"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// StaticcheckPackage is installed with go install when staticcheck is
+	// not on PATH. golangci-lint is only used when the image provides it.
+	StaticcheckPackage string
+	// Timeout bounds one lint request; defaults to 5 minutes.
+	Timeout time.Duration
+	// MaxRuns caps concurrent lint requests per workspace; defaults to 2.
+	MaxRuns int
+}
+
+var (
+	// ErrInvalidRequest is returned for unknown linters or malformed
+	// package patterns.
+	ErrInvalidRequest = errors.New("lint: invalid request")
+	// ErrTooManyRuns is returned when a workspace is at Config.MaxRuns.
+	ErrTooManyRuns = errors.New("lint: too many lint runs")
+)
+
+// Request selects what to lint.
+type Request struct {
+	// Linters defaults to vet alone.
+	Linters []Linter `json:"linters,omitempty"`
+	// Packages are package patterns relative to the workspace root;
+	// defaults to "./...".
+	Packages []string `json:"packages,omitempty"`
+}
+
+// Status is the outcome of one linter.
+type Status string
+
+const (
+	StatusOK          Status = "ok"
+	StatusFailed      Status = "failed"
+	StatusUnavailable Status = "unavailable"
+)
+
+// LinterResult reports how one linter ran. Error explains a failed or
+// unavailable linter.
+type LinterResult struct {
+	Linter      Linter `json:"linter"`
+	Status      Status `json:"status"`
+	Diagnostics int    `json:"diagnostics"`
+	Error       string `json:"error,omitempty"`
+	DurationMS  int64  `json:"durationMs"`
+}
+
+// Result collects every finding of a lint request.
+type Result struct {
+	Diagnostics []diag.Diagnostic `json:"diagnostics"`
+	Linters     []LinterResult    `json:"linters"`
+}
+
+// EventType names a lint progress event.
+type EventType string
+
+const (
+	EventStarted    EventType = "started"
+	EventDiagnostic EventType = "diagnostic"
+	EventFinished   EventType = "finished"
+	EventDone       EventType = "done"
+)
+
+// Event is one item of a lint stream: started and finished bracket each
+// linter, diagnostic carries one finding, and done ends the stream.
+type Event struct {
+	Type       EventType        `json:"type"`
+	Linter     Linter           `json:"linter,omitempty"`
+	Diagnostic *diag.Diagnostic `json:"diagnostic,omitempty"`
+	Result     *LinterResult    `json:"result,omitempty"`
+}
+
+// Emitter receives lint events.
+type Emitter func(Event)
+
+// Service runs linters through a Launcher.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.StaticcheckPackage == "" {
+		cfg.StaticcheckPackage = "honnef.co/go/tools/cmd/staticcheck@2024.1.1"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	if cfg.MaxRuns <= 0 {
+		cfg.MaxRuns = 2
+	}
+	return &Service{cfg: cfg, launcher: l, active: make(map[string]int)}
+}
+
+// Lint runs the requested linters one after another on the workspace at
+// dir, reporting progress through emit, which may be nil. A linter that
+// fails or is missing does not stop the others.
+func (s *Service) Lint(ctx context.Context, workspaceID, dir string, req Request, emit Emitter) (*Result, error) {
+	linters, pkgs, err := resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	if emit == nil {
+		emit = func(Event) {}
+	}
+	if err := s.acquire(workspaceID); err != nil {
+		return nil, err
+	}
+	defer s.release(workspaceID)
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	res := &Result{Diagnostics: []diag.Diagnostic{}, Linters: []LinterResult{}}
+	paths := &pathMapper{root: s.launcher.Root(dir), dir: dir, files: make(map[string][]byte)}
+	for _, l := range linters {
+		emit(Event{Type: EventStarted, Linter: l})
+		lr := s.run(ctx, workspaceID, dir, l, pkgs, paths, func(d diag.Diagnostic) {
+			res.Diagnostics = append(res.Diagnostics, d)
+			emit(Event{Type: EventDiagnostic, Linter: l, Diagnostic: &d})
+		})
+		res.Linters = append(res.Linters, lr)
+		emit(Event{Type: EventFinished, Linter: l, Result: &lr})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	emit(Event{Type: EventDone})
+	return res, nil
+}
+
+// resolve validates req and applies its defaults.
+func resolve(req Request) ([]Linter, []string, error) {
+	linters := req.Linters
+	if len(linters) == 0 {
+		linters = []Linter{Vet}
+	}
+	seen := make(map[Linter]bool)
+	for _, l := range linters {
+		if _, ok := scripts[l]; !ok {
+			return nil, nil, fmt.Errorf("%w: linter %q", ErrInvalidRequest, l)
+		}
+		if seen[l] {
+			return nil, nil, fmt.Errorf("%w: linter %q given twice", ErrInvalidRequest, l)
+		}
+		seen[l] = true
+	}
+	pkgs := req.Packages
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+	for _, p := range pkgs {
+		if !validPattern(p) {
+			return nil, nil, fmt.Errorf("%w: package %q", ErrInvalidRequest, p)
+		}
+	}
+	return linters, pkgs, nil
+}
+
+// Each script prints the linter's findings, merged with its errors, on
+// stdout, and exits 127 when the tool is not available. $1 is the
+// package to install if needed; the package patterns follow.
+var scripts = map[Linter]string{
+	Vet: `shift; exec go vet -json "$@" 2>&1`,
+	Staticcheck: `bin="${TMPDIR:-/tmp}/webide-tools"
+PATH="$bin:$PATH"
+if ! command -v staticcheck >/dev/null 2>&1; then
+	GOBIN="$bin" GOFLAGS= go install "$1" >/dev/null 2>&1 || exit 127
+fi
+shift
+exec staticcheck -f json "$@" 2>&1`,
+	GolangciLint: `command -v golangci-lint >/dev/null 2>&1 || exit 127
+shift
+case "$(golangci-lint version --short 2>/dev/null)" in
+1.*|v1.*) exec golangci-lint run --out-format=json "$@" 2>/dev/null ;;
+*) exec golangci-lint run --output.json.path=stdout --output.text.path=stderr --show-stats=false "$@" 2>/dev/null ;;
+esac`,
+}
+
+// run executes one linter, passing each diagnostic to report.
+func (s *Service) run(ctx context.Context, workspaceID, dir string, l Linter, pkgs []string, paths *pathMapper, report func(diag.Diagnostic)) LinterResult {
+	res := LinterResult{Linter: l, Status: StatusOK}
+	start := time.Now()
+	fail := func(status Status, msg string) LinterResult {
+		res.Status, res.Error = status, msg
+		res.DurationMS = time.Since(start).Milliseconds()
+		return res
+	}
+
+	argv := append([]string{"sh", "-c", scripts[l], "sh", s.cfg.StaticcheckPackage}, pkgs...)
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return fail(StatusFailed, err.Error())
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fail(StatusFailed, err.Error())
+	}
+	cmd.WaitDelay = 3 * time.Second
+	if err := cmd.Start(); err != nil {
+		return fail(StatusFailed, err.Error())
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+
+	p := newParser(l, paths, func(d diag.Diagnostic) {
+		res.Diagnostics++
+		report(d)
+	})
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64<<10), 64<<20)
+	for sc.Scan() {
+		p.line(sc.Text())
+	}
+	io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+	p.flush()
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return fail(StatusFailed, "lint timed out")
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 127:
+		return fail(StatusUnavailable, string(l)+" is not installed")
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && res.Diagnostics > 0:
+		// Linters exit 1 when they report findings.
+	case errors.As(err, &exitErr):
+		msg := strings.TrimSpace(p.unparsed.String())
+		if msg == "" {
+			msg = fmt.Sprintf("%s exited with status %d", l, exitErr.ExitCode())
+		}
+		return fail(StatusFailed, msg)
+	default:
+		return fail(StatusFailed, err.Error())
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	return res
+}
+
+// pathMapper turns the paths linters print into workspace-relative ones
+// and loads files to resolve byte offsets.
+type pathMapper struct {
+	root  string // the workspace as seen by the linters
+	dir   string // the workspace on the host
+	files map[string][]byte
+}
+
+// rel returns p relative to the workspace. Paths outside it, such as
+// files in the module cache, are returned unchanged.
+func (m *pathMapper) rel(p string) string {
+	if rest, ok := strings.CutPrefix(p, m.root+"/"); ok {
+		return rest
+	}
+	return strings.TrimPrefix(p, "./")
+}
+
+// offset converts a byte offset in the workspace file rel to a position.
+func (m *pathMapper) offset(rel string, off int) (diag.Position, bool) {
+	src, ok := m.files[rel]
+	if !ok {
+		if !path.IsAbs(rel) && !strings.HasPrefix(rel, "../") {
+			// A missing file leaves src nil, which is cached too.
+			src, _ = os.ReadFile(filepath.Join(m.dir, filepath.FromSlash(rel)))
+		}
+		m.files[rel] = src
+	}
+	if src == nil {
+		return diag.Position{}, false
+	}
+	return diag.OffsetPosition(src, off), true
+}
+
+// validPattern reports whether p is a relative package pattern that a
+// linter cannot mistake for a flag.
+func validPattern(p string) bool {
+	if p != "." && !strings.HasPrefix(p, "./") {
+		return false
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return false
+		}
+	}
+	for _, r := range p {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Service) acquire(workspaceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[workspaceID] >= s.cfg.MaxRuns {
+		return ErrTooManyRuns
+	}
+	s.active[workspaceID]++
+	return nil
+}
+
+func (s *Service) release(workspaceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[workspaceID]--; s.active[workspaceID] <= 0 {
+		delete(s.active, workspaceID)
+	}
+}
@@ -0,0 +1,294 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+)
+
+// maxUnparsed caps the output kept to explain a failed linter.
+const maxUnparsed = 16 << 10
+
+// parser turns one linter's output into diagnostics, one line at a time.
+// Lines it does not recognize are kept in unparsed.
+type parser struct {
+	linter   Linter
+	paths    *pathMapper
+	report   func(diag.Diagnostic)
+	unparsed strings.Builder
+
+	// buf accumulates a multi-line JSON document.
+	buf bytes.Buffer
+}
+
+func newParser(l Linter, paths *pathMapper, report func(diag.Diagnostic)) *parser {
+	return &parser{linter: l, paths: paths, report: report}
+}
+
+func (p *parser) line(s string) {
+	switch p.linter {
+	case Vet:
+		p.vetLine(s)
+	case Staticcheck:
+		p.staticcheckLine(s)
+	case GolangciLint:
+		p.buf.WriteString(s)
+		p.buf.WriteByte('\n')
+	}
+}
+
+// flush handles output buffered until the linter exits.
+func (p *parser) flush() {
+	if p.linter == GolangciLint {
+		p.golangci(p.buf.Bytes())
+	} else if p.buf.Len() > 0 {
+		p.keep(p.buf.String())
+	}
+	p.buf.Reset()
+}
+
+func (p *parser) keep(s string) {
+	if p.unparsed.Len()+len(s) < maxUnparsed {
+		p.unparsed.WriteString(s)
+		p.unparsed.WriteByte('\n')
+	}
+}
+
+// vetLine handles go vet -json output: an indented JSON object per
+// package, "# pkg" headers, and "vet: file:line:col: msg" type errors.
+func (p *parser) vetLine(s string) {
+	if p.buf.Len() > 0 || s == "{" {
+		p.buf.WriteString(s)
+		p.buf.WriteByte('\n')
+		if s == "}" {
+			p.vetJSON(p.buf.Bytes())
+			p.buf.Reset()
+		}
+		return
+	}
+	if strings.HasPrefix(s, "# ") {
+		return
+	}
+	if d, ok := diag.ParseLine(strings.TrimPrefix(s, "vet: ")); ok {
+		d.File = p.paths.rel(d.File)
+		d.Source, d.Code = string(Vet), "typecheck"
+		p.report(d)
+		return
+	}
+	p.keep(s)
+}
+
+type vetDiagnostic struct {
+	Posn           string `json:"posn"`
+	End            string `json:"end"`
+	Message        string `json:"message"`
+	SuggestedFixes []struct {
+		Message string `json:"message"`
+		Edits   []struct {
+			Filename string `json:"filename"`
+			Start    int    `json:"start"`
+			End      int    `json:"end"`
+			New      string `json:"new"`
+		} `json:"edits"`
+	} `json:"suggested_fixes"`
+}
+
+func (p *parser) vetJSON(data []byte) {
+	// Each analyzer maps to its diagnostics, or to {"error": ...} when it
+	// could not run.
+	var pkgs map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &pkgs); err != nil {
+		p.keep(string(data))
+		return
+	}
+	for _, pkg := range sortedKeys(pkgs) {
+		for _, analyzer := range sortedKeys(pkgs[pkg]) {
+			var ds []vetDiagnostic
+			if err := json.Unmarshal(pkgs[pkg][analyzer], &ds); err != nil {
+				var e struct {
+					Error string `json:"error"`
+				}
+				if json.Unmarshal(pkgs[pkg][analyzer], &e) == nil && e.Error != "" {
+					p.keep(pkg + ": " + analyzer + ": " + e.Error)
+				}
+				continue
+			}
+			for _, vd := range ds {
+				if d, ok := p.vetDiagnostic(analyzer, vd); ok {
+					p.report(d)
+				}
+			}
+		}
+	}
+}
+
+func (p *parser) vetDiagnostic(analyzer string, vd vetDiagnostic) (diag.Diagnostic, bool) {
+	file, start, ok := diag.ParsePosition(vd.Posn)
+	if !ok {
+		return diag.Diagnostic{}, false
+	}
+	d := diag.Diagnostic{
+		File:     p.paths.rel(file),
+		Range:    diag.Range{Start: start, End: start},
+		Severity: diag.SeverityWarning,
+		Source:   string(Vet),
+		Code:     analyzer,
+		Message:  vd.Message,
+	}
+	if _, end, ok := diag.ParsePosition(vd.End); ok {
+		d.Range.End = end
+	}
+	for _, sf := range vd.SuggestedFixes {
+		fix := diag.Fix{Message: sf.Message}
+		for _, e := range sf.Edits {
+			rel := p.paths.rel(e.Filename)
+			s, ok1 := p.paths.offset(rel, e.Start)
+			en, ok2 := p.paths.offset(rel, e.End)
+			if !ok1 || !ok2 {
+				fix.Edits = nil
+				break
+			}
+			fix.Edits = append(fix.Edits, diag.Edit{File: rel, Range: diag.Range{Start: s, End: en}, NewText: e.New})
+		}
+		if len(fix.Edits) > 0 {
+			d.Fixes = append(d.Fixes, fix)
+		}
+	}
+	return d, true
+}
+
+type staticcheckPosition struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// staticcheckLine handles staticcheck -f json output, one object per line.
+func (p *parser) staticcheckLine(s string) {
+	var v struct {
+		Code     string              `json:"code"`
+		Severity string              `json:"severity"`
+		Location staticcheckPosition `json:"location"`
+		End      staticcheckPosition `json:"end"`
+		Message  string              `json:"message"`
+	}
+	if !strings.HasPrefix(s, "{") || json.Unmarshal([]byte(s), &v) != nil || v.Location.File == "" {
+		p.keep(s)
+		return
+	}
+	if v.Severity == "ignored" {
+		return
+	}
+	start := diag.Position{Line: v.Location.Line, Column: max(v.Location.Column, 1)}
+	d := diag.Diagnostic{
+		File:     p.paths.rel(v.Location.File),
+		Range:    diag.Range{Start: start, End: start},
+		Severity: severity(v.Severity),
+		Source:   string(Staticcheck),
+		Code:     v.Code,
+		Message:  v.Message,
+	}
+	if v.End.Line > 0 {
+		d.Range.End = diag.Position{Line: v.End.Line, Column: max(v.End.Column, 1)}
+	}
+	p.report(d)
+}
+
+// golangci handles golangci-lint's JSON report, a single document.
+func (p *parser) golangci(data []byte) {
+	var v struct {
+		Issues []struct {
+			FromLinter  string `json:"FromLinter"`
+			Text        string `json:"Text"`
+			Severity    string `json:"Severity"`
+			Replacement *struct {
+				NeedOnlyDelete bool     `json:"NeedOnlyDelete"`
+				NewLines       []string `json:"NewLines"`
+				Inline         *struct {
+					StartCol  int    `json:"StartCol"`
+					Length    int    `json:"Length"`
+					NewString string `json:"NewString"`
+				} `json:"Inline"`
+			} `json:"Replacement"`
+			LineRange *struct {
+				From int `json:"From"`
+				To   int `json:"To"`
+			} `json:"LineRange"`
+			Pos struct {
+				Filename string `json:"Filename"`
+				Line     int    `json:"Line"`
+				Column   int    `json:"Column"`
+			} `json:"Pos"`
+		} `json:"Issues"`
+	}
+	i := bytes.IndexByte(data, '{')
+	if i < 0 {
+		p.keep(string(bytes.TrimSpace(data)))
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(data[i:]))
+	if err := dec.Decode(&v); err != nil {
+		p.keep(string(bytes.TrimSpace(data)))
+		return
+	}
+	for _, is := range v.Issues {
+		start := diag.Position{Line: is.Pos.Line, Column: max(is.Pos.Column, 1)}
+		d := diag.Diagnostic{
+			File:     p.paths.rel(is.Pos.Filename),
+			Range:    diag.Range{Start: start, End: start},
+			Severity: severity(is.Severity),
+			Source:   string(GolangciLint),
+			Code:     is.FromLinter,
+			Message:  is.Text,
+		}
+		if r := is.Replacement; r != nil {
+			from, to := is.Pos.Line, is.Pos.Line
+			if is.LineRange != nil && is.LineRange.From > 0 {
+				from, to = is.LineRange.From, max(is.LineRange.To, is.LineRange.From)
+			}
+			edit := diag.Edit{
+				File:  d.File,
+				Range: diag.Range{Start: diag.Position{Line: from, Column: 1}, End: diag.Position{Line: to + 1, Column: 1}},
+			}
+			switch {
+			case r.Inline != nil:
+				// Inline columns are 0-based byte offsets within the line.
+				edit.Range = diag.Range{
+					Start: diag.Position{Line: is.Pos.Line, Column: r.Inline.StartCol + 1},
+					End:   diag.Position{Line: is.Pos.Line, Column: r.Inline.StartCol + r.Inline.Length + 1},
+				}
+				edit.NewText = r.Inline.NewString
+			case r.NeedOnlyDelete:
+			default:
+				for _, l := range r.NewLines {
+					edit.NewText += l + "\n"
+				}
+			}
+			d.Fixes = []diag.Fix{{Message: "Apply " + is.FromLinter + " fix", Edits: []diag.Edit{edit}}}
+		}
+		p.report(d)
+	}
+}
+
+// severity maps a tool's severity name, treating unknown ones as warnings.
+func severity(s string) diag.Severity {
+	switch strings.ToLower(s) {
+	case "error":
+		return diag.SeverityError
+	case "info", "information", "hint":
+		return diag.SeverityInfo
+	}
+	return diag.SeverityWarning
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
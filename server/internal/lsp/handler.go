@@ -0,0 +1,113 @@
+package lsp
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Handler exposes a Manager's language servers over WebSocket.
+type Handler struct {
+	mgr        *Manager
+	workspaces *workspace.Manager
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler proxying to mgr for workspaces in wm.
+func NewHandler(mgr *Manager, wm *workspace.Manager, wsOpts *ws.Options) *Handler {
+	return &Handler{mgr: mgr, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the LSP routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/lsp/servers", h.servers)
+	mux.HandleFunc("GET /ws/lsp/{server}", h.serve)
+}
+
+// servers lists the registered language servers with the files they
+// handle, so the editor knows which one to connect to for a file; ?file=
+// keeps those handling that path.
+func (h *Handler) servers(w http.ResponseWriter, r *http.Request) {
+	servers := h.mgr.Servers()
+	if file := r.URL.Query().Get("file"); file != "" {
+		servers = slices.DeleteFunc(servers, func(s Server) bool { return !s.Matches(file) })
+	}
+	out := make([]serverInfo, len(servers))
+	for i, s := range servers {
+		out[i] = serverInfo{Name: s.Name, Languages: s.Languages, Files: s.Files}
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"servers": out})
+}
+
+// serverInfo is what clients learn of a Server; its command and
+// environment stay on the server.
+type serverInfo struct {
+	Name      string   `json:"name"`
+	Languages []string `json:"languages"`
+	Files     []string `json:"files"`
+}
+
+// serve proxies one editor session with the server in the path. The
+// workspace is selected with the ?workspace= query parameter; each text
+// frame carries one JSON-RPC message.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("server")
+	if _, ok := h.mgr.Server(name); !ok {
+		httpx.Error(w, http.StatusNotFound, "unknown language server")
+		return
+	}
+	id := r.URL.Query().Get("workspace")
+	dir, err := h.workspaces.Open(id)
+	switch {
+	case errors.Is(err, workspace.ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, workspace.ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		httpx.Error(w, http.StatusInternalServerError, "workspace unavailable")
+		return
+	}
+
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxMessageBytes)
+
+	sess, err := h.mgr.Attach(id, dir, name, wsClient{conn})
+	if err != nil {
+		slog.Error("attach language server", "workspace", id, "server", name, "err", err)
+		conn.CloseWithCode(ws.CloseInternalError, "language server unavailable")
+		return
+	}
+	defer sess.Detach()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		sess.Deliver(data)
+	}
+}
+
+// wsClient adapts a WebSocket connection to Client.
+type wsClient struct {
+	conn *ws.Conn
+}
+
+func (c wsClient) Send(msg []byte) error {
+	return c.conn.WriteMessage(ws.TextMessage, msg)
+}
+
+func (c wsClient) Close(reason string) {
+	c.conn.CloseWithCode(ws.CloseGoingAway, reason)
+}
@@ -0,0 +1,399 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Internal request IDs the proxy uses when it talks to the server itself.
+const (
+	reinitID   = `"webide-reinitialize"`
+	shutdownID = `"webide-shutdown"`
+)
+
+// instance is the language server of one workspace plus the proxy state
+// needed to survive client reconnects and server crashes.
+type instance struct {
+	m   *Manager
+	srv *server
+	id  string
+	dir string
+	uri uriRewriter
+
+	mu              sync.Mutex
+	proc            *process
+	client          *Session
+	initParams      json.RawMessage
+	initResult      json.RawMessage
+	initializedSent bool
+	openDocs        map[string]bool
+	pending         map[string]string // client request ID -> method
+	restarts        []time.Time
+	idle            *time.Timer
+	stopped         bool
+}
+
+// process is a single run of the language server binary.
+type process struct {
+	cmd  *exec.Cmd
+	wmu  sync.Mutex
+	in   io.WriteCloser
+	done chan struct{}
+	// work is the go.work it was started in workspace mode for, if any.
+	work string
+}
+
+func (p *process) write(msg []byte) error {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	return writeFrame(p.in, msg)
+}
+
+func newInstance(m *Manager, srv *server, id, dir string) *instance {
+	return &instance{
+		m:        m,
+		srv:      srv,
+		id:       id,
+		dir:      dir,
+		uri:      newURIRewriter(dir),
+		openDocs: make(map[string]bool),
+		pending:  make(map[string]string),
+	}
+}
+
+func (inst *instance) attach(s *Session) error {
+	inst.mu.Lock()
+	if inst.stopped {
+		inst.mu.Unlock()
+		return ErrClosed
+	}
+	if inst.idle != nil {
+		inst.idle.Stop()
+		inst.idle = nil
+	}
+	prev := inst.client
+	inst.client = s
+	if prev != nil {
+		inst.closeDocsLocked()
+	}
+	if p := inst.proc; p != nil && p.work != inst.workFile() {
+		// A go.work was added or removed since the server started. The
+		// new client's initialize goes to a fresh server instead of being
+		// answered from the cache; the old one exits unnoticed, as it is
+		// no longer inst.proc.
+		slog.Info("restarting language server for go.work change", "workspace", inst.id, "server", inst.srv.Name)
+		inst.proc = nil
+		inst.initParams, inst.initResult = nil, nil
+		p.in.Close()
+		p.cmd.Process.Kill()
+	}
+	var err error
+	if inst.proc == nil {
+		err = inst.startLocked()
+	}
+	inst.mu.Unlock()
+
+	if prev != nil {
+		prev.client.Close("another editor attached to this workspace")
+	}
+	return err
+}
+
+func (inst *instance) detach(s *Session) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	if inst.client != s {
+		return
+	}
+	inst.client = nil
+	inst.closeDocsLocked()
+	inst.idle = time.AfterFunc(inst.m.cfg.IdleTimeout, inst.idleExpired)
+}
+
+// closeDocsLocked tells the server the departing client's documents are
+// closed, so the next client can open them again.
+func (inst *instance) closeDocsLocked() {
+	for uri := range inst.openDocs {
+		if inst.proc != nil {
+			inst.proc.write(notification("textDocument/didClose",
+				map[string]any{"textDocument": map[string]string{"uri": uri}}))
+		}
+	}
+	clear(inst.openDocs)
+	clear(inst.pending)
+}
+
+func (inst *instance) idleExpired() {
+	inst.mu.Lock()
+	idle := inst.client == nil && !inst.stopped
+	inst.mu.Unlock()
+	if idle {
+		slog.Info("stopping idle language server", "workspace", inst.id, "server", inst.srv.Name)
+		inst.m.remove(inst)
+		inst.stop()
+	}
+}
+
+// workFile returns the go.work the server is to start in workspace mode
+// for, if any.
+func (inst *instance) workFile() string {
+	if !inst.srv.GoWork {
+		return ""
+	}
+	return workFile(inst.dir)
+}
+
+// startLocked launches a new server process.
+func (inst *instance) startLocked() error {
+	args := make([]string, len(inst.srv.Command))
+	for i, a := range inst.srv.Command {
+		args[i] = strings.ReplaceAll(a, "{dir}", inst.dir)
+	}
+	cmd := exec.Command(inst.srv.executable(args[0]), args[1:]...)
+	cmd.Dir = inst.dir
+	cmd.Stderr = logWriter{workspace: inst.id, server: inst.srv.Name}
+	work := inst.workFile()
+	cmd.Env = inst.srv.environ(work)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("lsp: stdin pipe: %w", err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("lsp: stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("lsp: start %s: %w", args[0], err)
+	}
+	p := &process{cmd: cmd, in: in, done: make(chan struct{}), work: work}
+	inst.proc = p
+	inst.initializedSent = false
+	inst.m.starts.Inc(inst.srv.Name)
+	slog.Info("language server started", "workspace", inst.id, "server", inst.srv.Name, "pid", cmd.Process.Pid, "goWork", work != "")
+
+	go inst.readLoop(p, bufio.NewReader(out))
+	return nil
+}
+
+// readLoop forwards server output to the attached client until the process
+// exits, then hands over to onExit.
+func (inst *instance) readLoop(p *process, out *bufio.Reader) {
+	for {
+		body, err := readFrame(out)
+		if err != nil {
+			break
+		}
+		inst.fromServer(p, inst.uri.toClient(body))
+	}
+	err := p.cmd.Wait()
+	close(p.done)
+	inst.onExit(p, err)
+}
+
+func (inst *instance) fromServer(p *process, body []byte) {
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return
+	}
+
+	inst.mu.Lock()
+	if msg.isResponse() {
+		id := string(msg.ID)
+		switch id {
+		case reinitID:
+			inst.initializedSent = true
+			client := inst.client
+			inst.mu.Unlock()
+			p.write(notification("initialized", struct{}{}))
+			if client != nil {
+				client.client.Send(notification("window/showMessage", map[string]any{
+					"type": 2, "message": "The language server restarted; reopen files to refresh diagnostics.",
+				}))
+				client.client.Send(notification("webide/serverRestarted", nil))
+			}
+			return
+		case shutdownID:
+			inst.mu.Unlock()
+			return
+		}
+		if method, ok := inst.pending[id]; ok {
+			delete(inst.pending, id)
+			if method == "initialize" && inst.initResult == nil && len(msg.Error) == 0 {
+				inst.initResult = msg.Result
+			}
+		}
+	}
+	client := inst.client
+	inst.mu.Unlock()
+
+	if client != nil {
+		client.client.Send(body)
+	}
+}
+
+func (inst *instance) fromClient(s *Session, body []byte) {
+	body = inst.uri.toServer(body)
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return
+	}
+
+	inst.mu.Lock()
+	if inst.client != s {
+		inst.mu.Unlock()
+		return
+	}
+	var reply []byte
+	forward := true
+	switch {
+	case msg.Method == "initialize" && msg.isRequest():
+		if inst.initResult != nil {
+			reply, forward = resultResponse(msg.ID, inst.initResult), false
+		} else {
+			inst.initParams = msg.Params
+			inst.pending[string(msg.ID)] = msg.Method
+		}
+	case msg.Method == "initialized":
+		forward = !inst.initializedSent
+		inst.initializedSent = true
+	case msg.Method == "shutdown" && msg.isRequest():
+		// The proxy decides when the server shuts down; the client only
+		// learns that its own session is over.
+		reply, forward = resultResponse(msg.ID, nil), false
+	case msg.Method == "exit":
+		forward = false
+	case msg.Method == "textDocument/didOpen" || msg.Method == "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if json.Unmarshal(msg.Params, &params) == nil {
+			if msg.Method == "textDocument/didOpen" {
+				inst.openDocs[params.TextDocument.URI] = true
+			} else {
+				delete(inst.openDocs, params.TextDocument.URI)
+			}
+		}
+	case msg.isRequest():
+		inst.pending[string(msg.ID)] = msg.Method
+	}
+	p := inst.proc
+	if forward && p == nil && msg.isRequest() {
+		delete(inst.pending, string(msg.ID))
+		reply, forward = errorResponse(msg.ID, codeServerGone, "language server is not running"), false
+	}
+	inst.mu.Unlock()
+
+	if reply != nil {
+		s.client.Send(inst.uri.toClient(reply))
+	}
+	if forward && p != nil {
+		if err := p.write(body); err != nil {
+			slog.Debug("write to language server", "workspace", inst.id, "err", err)
+		}
+	}
+}
+
+// onExit handles a server process ending, restarting it unless the instance
+// is being stopped or has crashed too often.
+func (inst *instance) onExit(p *process, exitErr error) {
+	inst.mu.Lock()
+	if inst.proc != p {
+		inst.mu.Unlock()
+		return
+	}
+	inst.proc = nil
+	client := inst.client
+	var failed [][]byte
+	for id := range inst.pending {
+		failed = append(failed, errorResponse(json.RawMessage(id), codeServerGone, "language server exited"))
+	}
+	clear(inst.pending)
+	clear(inst.openDocs)
+	if inst.stopped {
+		inst.mu.Unlock()
+		return
+	}
+
+	slog.Warn("language server exited", "workspace", inst.id, "server", inst.srv.Name, "err", exitErr)
+	inst.m.crashes.Inc(inst.srv.Name)
+	now := time.Now()
+	recent := inst.restarts[:0]
+	for _, t := range inst.restarts {
+		if now.Sub(t) < inst.m.cfg.RestartWindow {
+			recent = append(recent, t)
+		}
+	}
+	inst.restarts = append(recent, now)
+	giveUp := len(inst.restarts) > inst.m.cfg.MaxRestarts
+
+	var startErr error
+	if !giveUp {
+		startErr = inst.startLocked()
+		if startErr == nil && inst.initParams != nil {
+			req, _ := json.Marshal(struct {
+				JSONRPC string          `json:"jsonrpc"`
+				ID      json.RawMessage `json:"id"`
+				Method  string          `json:"method"`
+				Params  json.RawMessage `json:"params"`
+			}{"2.0", json.RawMessage(reinitID), "initialize", inst.initParams})
+			inst.proc.write(req)
+		}
+	}
+	if giveUp || startErr != nil {
+		inst.stopped = true
+	}
+	inst.mu.Unlock()
+
+	if client != nil {
+		for _, f := range failed {
+			client.client.Send(f)
+		}
+	}
+	if giveUp || startErr != nil {
+		slog.Error("language server abandoned", "workspace", inst.id, "server", inst.srv.Name, "restarts", len(inst.restarts), "err", startErr)
+		inst.m.remove(inst)
+		if client != nil {
+			client.client.Send(notification("window/showMessage", map[string]any{
+				"type": 1, "message": "The language server keeps crashing and has been stopped.",
+			}))
+			client.client.Close("language server unavailable")
+		}
+	}
+}
+
+// stop shuts the server down politely, killing it if it does not exit.
+func (inst *instance) stop() {
+	inst.mu.Lock()
+	inst.stopped = true
+	if inst.idle != nil {
+		inst.idle.Stop()
+	}
+	p, client := inst.proc, inst.client
+	inst.client = nil
+	inst.mu.Unlock()
+
+	if client != nil {
+		client.client.Close("language server stopped")
+	}
+	if p == nil {
+		return
+	}
+	p.write([]byte(`{"jsonrpc":"2.0","id":` + shutdownID + `,"method":"shutdown"}`))
+	p.write(notification("exit", nil))
+	p.in.Close()
+	select {
+	case <-p.done:
+	case <-time.After(3 * time.Second):
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+}
@@ -0,0 +1,105 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// maxMessageBytes bounds a single message read from a language server.
+const maxMessageBytes = 64 << 20
+
+// message is the subset of a JSON-RPC 2.0 message the proxy inspects. Params,
+// result and error are kept raw and forwarded unchanged.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+func (m *message) isRequest() bool      { return m.Method != "" && len(m.ID) > 0 }
+func (m *message) isNotification() bool { return m.Method != "" && len(m.ID) == 0 }
+func (m *message) isResponse() bool     { return m.Method == "" && len(m.ID) > 0 }
+
+// rpcError is a JSON-RPC error object.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes used by the proxy.
+const (
+	codeInternalError = -32603
+	codeServerGone    = -32099
+)
+
+func errorResponse(id json.RawMessage, code int, msg string) []byte {
+	data, _ := json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   rpcError        `json:"error"`
+	}{"2.0", id, rpcError{code, msg}})
+	return data
+}
+
+func resultResponse(id json.RawMessage, result json.RawMessage) []byte {
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	data, _ := json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  json.RawMessage `json:"result"`
+	}{"2.0", id, result})
+	return data
+}
+
+func notification(method string, params any) []byte {
+	data, _ := json.Marshal(struct {
+		JSONRPC string `json:"jsonrpc"`
+		Method  string `json:"method"`
+		Params  any    `json:"params,omitempty"`
+	}{"2.0", method, params})
+	return data
+}
+
+// readFrame reads one Content-Length framed message from a language server.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	tp := textproto.NewReader(r)
+	hdr, err := tp.ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(hdr) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("lsp: read header: %w", err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(hdr.Get("Content-Length")))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("lsp: bad Content-Length %q", hdr.Get("Content-Length"))
+	}
+	if n > maxMessageBytes {
+		return nil, fmt.Errorf("lsp: message of %d bytes exceeds limit", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("lsp: read body: %w", err)
+	}
+	return buf, nil
+}
+
+// writeFrame writes one Content-Length framed message to a language server.
+func writeFrame(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
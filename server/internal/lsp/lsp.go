@@ -0,0 +1,282 @@
+// Package lsp runs language servers per workspace and proxies Language
+// Server Protocol traffic between them and editor clients over WebSocket.
+// gopls is built in; Config.Servers registers others, such as pyright or
+// clangd, which are installed the first time they are needed and, like
+// gopls, started on demand for each workspace.
+//
+// The proxy owns the server's lifecycle rather than the client: a server is
+// kept alive across client reconnects (the cached initialize result is
+// replayed to the new client), restarted if it crashes, and shut down after
+// it has had no client for Config.IdleTimeout. Clients address files under
+// VirtualRoot; the proxy rewrites URIs to and from the real workspace
+// directory so host paths never reach the browser.
+//
+// A workspace with a go.work at its root gets gopls in workspace mode,
+// which sees every module the go.work uses as one build.
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/metrics"
+)
+
+// VirtualRoot is the workspace root as seen by editor clients.
+const VirtualRoot = "/workspace"
+
+// Config configures a Manager.
+type Config struct {
+	// Command starts gopls speaking LSP on stdio. Any "{dir}" in an
+	// argument is replaced with the workspace directory, which allows
+	// running the server inside a container with the workspace mounted.
+	Command []string
+	// Servers are further language servers. One named "go" replaces the
+	// gopls that Command starts.
+	Servers []Server
+	// ToolsDir holds the servers the Manager installs, one directory per
+	// server; defaults to a directory under the OS temp dir.
+	ToolsDir string
+	// IdleTimeout is how long a server is kept after its last client leaves.
+	IdleTimeout time.Duration
+	// MaxRestarts crash restarts are allowed within RestartWindow before the
+	// server is given up on.
+	MaxRestarts   int
+	RestartWindow time.Duration
+	// Metrics, when set, receives the servers running and how often each
+	// kind started and exited unasked.
+	Metrics *metrics.Registry
+}
+
+// ErrClosed is returned by Attach after the Manager has been closed.
+var ErrClosed = errors.New("lsp: manager closed")
+
+// Manager tracks the running language servers of every workspace.
+type Manager struct {
+	cfg     Config
+	servers map[string]*server
+
+	mu        sync.Mutex
+	instances map[string]*instance // by workspace ID and server name
+	closed    bool
+
+	starts  *metrics.Counter
+	crashes *metrics.Counter
+}
+
+// NewManager returns a Manager, filling unset Config fields with defaults
+// suitable for gopls.
+func NewManager(cfg Config) *Manager {
+	if len(cfg.Command) == 0 {
+		cfg.Command = []string{"gopls", "serve"}
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 5 * time.Minute
+	}
+	if cfg.MaxRestarts <= 0 {
+		cfg.MaxRestarts = 3
+	}
+	if cfg.RestartWindow <= 0 {
+		cfg.RestartWindow = time.Minute
+	}
+	if cfg.ToolsDir == "" {
+		cfg.ToolsDir = filepath.Join(os.TempDir(), "webide-lsp")
+	}
+	m := &Manager{cfg: cfg, servers: make(map[string]*server), instances: make(map[string]*instance)}
+	for _, s := range append([]Server{goServer(cfg.Command)}, cfg.Servers...) {
+		m.servers[s.Name] = &server{Server: s, dir: filepath.Join(cfg.ToolsDir, s.Name)}
+	}
+	m.starts = cfg.Metrics.Counter("webide_lsp_starts_total", "Language server processes started, by server.", "server")
+	m.crashes = cfg.Metrics.Counter("webide_lsp_crashes_total", "Language server processes that exited unasked, by server.", "server")
+	cfg.Metrics.GaugeFunc("webide_lsp_servers", "Language servers running, one per workspace and server.", func() float64 {
+		return float64(m.Running())
+	})
+	return m
+}
+
+// Servers returns the registered language servers, sorted by name.
+func (m *Manager) Servers() []Server {
+	out := make([]Server, 0, len(m.servers))
+	for _, s := range m.servers {
+		out = append(out, s.Server)
+	}
+	slices.SortFunc(out, func(a, b Server) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// Client is the editor side of a proxied session.
+type Client interface {
+	// Send delivers one JSON-RPC message to the editor.
+	Send(msg []byte) error
+	// Close terminates the session, e.g. when another client takes over.
+	Close(reason string)
+}
+
+// Session is a client's attachment to a workspace language server.
+type Session struct {
+	inst   *instance
+	client Client
+}
+
+// Server returns the registered language server called name.
+func (m *Manager) Server(name string) (Server, bool) {
+	s, ok := m.servers[name]
+	if !ok {
+		return Server{}, false
+	}
+	return s.Server, true
+}
+
+// Attach connects client to the language server name of workspace id
+// rooted at dir, installing and starting the server if needed. A client
+// already attached to the same server of the workspace is closed, since
+// LSP allows only one client per server.
+func (m *Manager) Attach(id, dir, name string, client Client) (*Session, error) {
+	srv, ok := m.servers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownServer, name)
+	}
+	if err := srv.ensureInstalled(); err != nil {
+		return nil, err
+	}
+	key := id + "\x00" + name
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	inst, ok := m.instances[key]
+	if !ok {
+		inst = newInstance(m, srv, id, dir)
+		m.instances[key] = inst
+	}
+	m.mu.Unlock()
+
+	s := &Session{inst: inst, client: client}
+	if err := inst.attach(s); err != nil {
+		m.remove(inst)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Deliver forwards one message from the editor to the language server.
+func (s *Session) Deliver(msg []byte) {
+	s.inst.fromClient(s, msg)
+}
+
+// Detach disconnects the session. The server keeps running until the idle
+// timeout elapses without a new client.
+func (s *Session) Detach() {
+	s.inst.detach(s)
+}
+
+// Close shuts down every running language server.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	insts := make([]*instance, 0, len(m.instances))
+	for _, inst := range m.instances {
+		insts = append(insts, inst)
+	}
+	m.instances = make(map[string]*instance)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, inst := range insts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inst.stop()
+		}()
+	}
+	wg.Wait()
+}
+
+// Running returns the number of live language servers.
+func (m *Manager) Running() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.instances)
+}
+
+func (m *Manager) remove(inst *instance) {
+	key := inst.id + "\x00" + inst.srv.Name
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.instances[key] == inst {
+		delete(m.instances, key)
+	}
+}
+
+// uriRewriter translates document URIs between the client's virtual root and
+// the workspace directory on the host.
+type uriRewriter struct {
+	virtual, host string
+}
+
+func newURIRewriter(dir string) uriRewriter {
+	return uriRewriter{
+		virtual: "file://" + VirtualRoot,
+		host:    (&url.URL{Scheme: "file", Path: dir}).String(),
+	}
+}
+
+func (u uriRewriter) toServer(msg []byte) []byte {
+	return []byte(strings.ReplaceAll(string(msg), u.virtual, u.host))
+}
+
+func (u uriRewriter) toClient(msg []byte) []byte {
+	return []byte(strings.ReplaceAll(string(msg), u.host, u.virtual))
+}
+
+// workFile returns the path of the go.work at the root of the workspace
+// at dir, or "" when there is none.
+func workFile(dir string) string {
+	p := filepath.Join(dir, "go.work")
+	if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+	return p
+}
+
+// workspaceEnv returns the server's environment for the go.work at work:
+// the server's own, with GOWORK pointing at it and any -mod flag dropped
+// from GOFLAGS. An inherited GOWORK=off would turn workspace mode off, and
+// the go command rejects -mod=mod in it. A Command starting the server in
+// a container passes these on with, for docker, -e GOWORK -e GOFLAGS.
+func workspaceEnv(work string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOWORK=") && !strings.HasPrefix(kv, "GOFLAGS=") {
+			env = append(env, kv)
+		}
+	}
+	var flags []string
+	for _, f := range strings.Fields(os.Getenv("GOFLAGS")) {
+		if !strings.HasPrefix(f, "-mod=") {
+			flags = append(flags, f)
+		}
+	}
+	return append(env, "GOWORK="+work, "GOFLAGS="+strings.Join(flags, " "))
+}
+
+// logWriter forwards a language server's stderr to the debug log.
+type logWriter struct {
+	workspace, server string
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		slog.Debug("language server stderr", "workspace", w.workspace, "server", w.server, "line", line)
+	}
+	return len(p), nil
+}
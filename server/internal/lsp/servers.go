@@ -0,0 +1,264 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a language server the Manager can run for workspaces.
+type Server struct {
+	// Name identifies the server in the /ws/lsp/{name} route, such as
+	// "python".
+	Name string `json:"name"`
+	// Command starts the server speaking LSP on stdio, as Config.Command.
+	Command []string `json:"command"`
+	// Env is added to the server's environment, as KEY=value.
+	Env []string `json:"env,omitempty"`
+	// Languages are the LSP language IDs the server handles, such as
+	// "typescript".
+	Languages []string `json:"languages"`
+	// Files are patterns on file base names, in path.Match syntax, that
+	// select the server, such as "*.py" or "go.mod".
+	Files []string `json:"files"`
+	// Install, when set, installs the server the first time it is needed.
+	Install *Install `json:"install,omitempty"`
+	// GoWork starts the server in workspace mode when the workspace has a
+	// go.work at its root, as gopls needs.
+	GoWork bool `json:"goWork,omitempty"`
+}
+
+// Install is how a Server is installed into its directory under
+// Config.ToolsDir, whose bin directory is put first on the server's PATH.
+type Install struct {
+	// Method is "npm", "pip", "go" or "command".
+	Method string `json:"method"`
+	// Packages are what npm install, pip install or go install install.
+	Packages []string `json:"packages,omitempty"`
+	// Command is the installer for the "command" method. It runs in the
+	// server's directory, with WEBIDE_TOOLS_DIR set to it, and puts the
+	// executables in bin beneath it.
+	Command []string `json:"command,omitempty"`
+}
+
+// ErrUnknownServer is returned for language server names no Server has.
+var ErrUnknownServer = errors.New("lsp: unknown language server")
+
+// installTimeout bounds installing one server.
+const installTimeout = 10 * time.Minute
+
+var serverNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// goServer is the gopls entry, started with command.
+func goServer(command []string) Server {
+	return Server{
+		Name:      "go",
+		Command:   command,
+		Languages: []string{"go", "go.mod", "go.sum", "go.work"},
+		Files:     []string{"*.go", "go.mod", "go.sum", "go.work"},
+		GoWork:    true,
+	}
+}
+
+// LoadServers reads a JSON array of Servers from the file at name.
+func LoadServers(name string) ([]Server, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("lsp: read servers: %w", err)
+	}
+	var servers []Server
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("lsp: parse %s: %w", name, err)
+	}
+	for _, s := range servers {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("lsp: %s: %w", name, err)
+		}
+	}
+	return servers, nil
+}
+
+func (s Server) validate() error {
+	if !serverNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("server name %q: want lower-case letters, digits and dashes", s.Name)
+	}
+	if len(s.Command) == 0 {
+		return fmt.Errorf("server %s: no command", s.Name)
+	}
+	for _, p := range s.Files {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("server %s: file pattern %q: %w", s.Name, p, err)
+		}
+	}
+	if in := s.Install; in != nil {
+		switch in.Method {
+		case "npm", "pip", "go":
+			if len(in.Packages) == 0 {
+				return fmt.Errorf("server %s: %s install without packages", s.Name, in.Method)
+			}
+		case "command":
+			if len(in.Command) == 0 {
+				return fmt.Errorf("server %s: command install without a command", s.Name)
+			}
+		default:
+			return fmt.Errorf("server %s: unknown install method %q", s.Name, in.Method)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the file at the slash-separated path p selects
+// the server.
+func (s Server) Matches(p string) bool {
+	base := path.Base(p)
+	for _, pattern := range s.Files {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// server is a registered Server with its install state.
+type server struct {
+	Server
+	// dir is the server's directory under Config.ToolsDir.
+	dir string
+
+	mu        sync.Mutex
+	installed bool
+}
+
+// installedMarker is created in a server's directory once it is
+// installed, so later processes do not install it again.
+const installedMarker = ".installed"
+
+// bin returns the directory the server's executables are installed in, or
+// "" when it is not installed by the Manager.
+func (s *server) bin() string {
+	switch {
+	case s.Install == nil:
+		return ""
+	case s.Install.Method == "npm":
+		return filepath.Join(s.dir, "node_modules", ".bin")
+	default:
+		return filepath.Join(s.dir, "bin")
+	}
+}
+
+// ensureInstalled installs the server unless that has been done, with
+// concurrent callers waiting for a single install. A failed install is
+// retried by the next caller.
+func (s *server) ensureInstalled() error {
+	if s.Install == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.installed {
+		return nil
+	}
+	marker := filepath.Join(s.dir, installedMarker)
+	if _, err := os.Stat(marker); err == nil {
+		s.installed = true
+		return nil
+	}
+	// A previous attempt may have left a partial install behind.
+	if err := os.RemoveAll(s.dir); err != nil {
+		return fmt.Errorf("lsp: install %s: %w", s.Name, err)
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("lsp: install %s: %w", s.Name, err)
+	}
+	slog.Info("installing language server", "server", s.Name, "method", s.Install.Method)
+	ctx, cancel := context.WithTimeout(context.Background(), installTimeout)
+	defer cancel()
+	for _, argv := range s.installCommands() {
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Dir = s.dir
+		cmd.Env = append(os.Environ(), "WEBIDE_TOOLS_DIR="+s.dir, "GOBIN="+filepath.Join(s.dir, "bin"))
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("lsp: install %s: %s: %w: %s", s.Name, argv[0], err, lastLine(out.String()))
+		}
+	}
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		return fmt.Errorf("lsp: install %s: %w", s.Name, err)
+	}
+	s.installed = true
+	slog.Info("language server installed", "server", s.Name)
+	return nil
+}
+
+// installCommands returns the commands that install the server into
+// s.dir, run in order.
+func (s *server) installCommands() [][]string {
+	in := s.Install
+	switch in.Method {
+	case "npm":
+		return [][]string{append([]string{"npm", "install", "--prefix", s.dir, "--no-audit", "--no-fund"}, in.Packages...)}
+	case "pip":
+		return [][]string{
+			{"python3", "-m", "venv", s.dir},
+			append([]string{filepath.Join(s.dir, "bin", "pip"), "install", "--disable-pip-version-check"}, in.Packages...),
+		}
+	case "go":
+		return [][]string{append([]string{"go", "install"}, in.Packages...)}
+	default:
+		return [][]string{in.Command}
+	}
+}
+
+// environ returns the environment of a run of the server, in workspace
+// mode for the go.work at work unless that is "", or nil to inherit the
+// proxy's own.
+func (s *server) environ(work string) []string {
+	bin := s.bin()
+	if work == "" && bin == "" && len(s.Env) == 0 {
+		return nil
+	}
+	env := os.Environ()
+	if work != "" {
+		env = workspaceEnv(work)
+	}
+	if bin != "" {
+		env = slices.DeleteFunc(env, func(kv string) bool { return strings.HasPrefix(kv, "PATH=") })
+		env = append(env, "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	return append(env, s.Env...)
+}
+
+// executable resolves the command name argv0 against the server's bin
+// directory first, as exec.Command looks names up on the proxy's PATH.
+func (s *server) executable(argv0 string) string {
+	bin := s.bin()
+	if bin == "" || strings.ContainsRune(argv0, filepath.Separator) {
+		return argv0
+	}
+	p := filepath.Join(bin, argv0)
+	if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+		return p
+	}
+	return argv0
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
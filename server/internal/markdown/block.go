@@ -0,0 +1,610 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+type blockKind int
+
+const (
+	paragraphBlock blockKind = iota
+	headingBlock
+	codeBlock
+	quoteBlock
+	listBlock
+	itemBlock
+	ruleBlock
+	tableBlock
+	htmlBlock
+)
+
+// block is a node of the document's block structure. Paragraph and heading
+// text stays unparsed until every reference definition has been seen.
+type block struct {
+	kind     blockKind
+	level    int    // heading level
+	text     string // inline source, code, or raw HTML
+	info     string // fenced code info string
+	children []*block
+
+	ordered bool // lists
+	start   int
+	tight   bool
+	task    int // items: 0 plain, 1 unchecked, 2 checked
+
+	align []string   // tables: per column "", "left", "center" or "right"
+	rows  [][]string // tables: the header row, then the body
+}
+
+type ref struct {
+	dest, title string
+}
+
+// maxDepth bounds the nesting of block quotes and lists. Deeper markers
+// are read as text, since each level parses the lines inside it again.
+const maxDepth = 32
+
+type parser struct {
+	refs  map[string]ref
+	depth int
+}
+
+// parse splits lines into blocks. loose reports whether blank lines
+// separate any of them, which makes a list item containing them loose.
+func (p *parser) parse(lines []string) (blocks []*block, loose bool) {
+	p.depth++
+	defer func() { p.depth-- }()
+	blank := false
+	for i := 0; i < len(lines); {
+		if isBlank(lines[i]) {
+			blank = true
+			i++
+			continue
+		}
+		if blank && len(blocks) > 0 {
+			loose = true
+		}
+		blank = false
+		var b *block
+		b, i = p.block(lines, i)
+		if b != nil {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, loose
+}
+
+// block parses the block starting at the non-blank line i and returns it
+// with the index of the line after it.
+func (p *parser) block(lines []string, i int) (*block, int) {
+	line := lines[i]
+	ind := indentOf(line)
+	if ind >= 4 {
+		return indentedCode(lines, i)
+	}
+	s := line[ind:]
+	if _, _, _, ok := fence(s); ok {
+		return fenced(lines, i, ind)
+	}
+	if level, text := atx(s); level > 0 {
+		return &block{kind: headingBlock, level: level, text: text}, i + 1
+	}
+	if isRule(s) {
+		return &block{kind: ruleBlock}, i + 1
+	}
+	if s[0] == '>' && p.depth < maxDepth {
+		return p.quote(lines, i)
+	}
+	if _, ok := listMarker(line); ok && p.depth < maxDepth {
+		return p.list(lines, i)
+	}
+	if kind := htmlStart(s); kind != 0 {
+		return rawBlock(lines, i, kind)
+	}
+	if tableAt(lines, i) {
+		return table(lines, i, p)
+	}
+	return p.paragraph(lines, i)
+}
+
+// interrupts reports whether line starts a block that ends a paragraph
+// without a blank line in between.
+func (p *parser) interrupts(line string) bool {
+	ind := indentOf(line)
+	if ind >= 4 || ind == len(line) {
+		return false
+	}
+	s := line[ind:]
+	if _, _, _, ok := fence(s); ok {
+		return true
+	}
+	if level, _ := atx(s); level > 0 {
+		return true
+	}
+	if isRule(s) || s[0] == '>' || htmlStart(s) == htmlComment || htmlStart(s) == htmlBlockTag {
+		return true
+	}
+	m, ok := listMarker(line)
+	return ok && !m.empty && (!m.ordered || m.start == 1)
+}
+
+func (p *parser) paragraph(lines []string, i int) (*block, int) {
+	var text []string
+	j := i
+	for ; j < len(lines); j++ {
+		line := lines[j]
+		if isBlank(line) {
+			break
+		}
+		if len(text) > 0 {
+			if level := setext(line); level > 0 {
+				body := p.definitions(strings.Join(text, "\n"))
+				if body == "" {
+					break
+				}
+				return &block{kind: headingBlock, level: level, text: strings.TrimSpace(body)}, j + 1
+			}
+			if p.interrupts(line) || tableAt(lines, j) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+	body := p.definitions(strings.Join(text, "\n"))
+	if body == "" {
+		return nil, j
+	}
+	return &block{kind: paragraphBlock, text: strings.TrimRight(body, " ")}, j
+}
+
+// definitions records the link reference definitions at the start of a
+// paragraph and returns the text that follows them.
+func (p *parser) definitions(s string) string {
+	for strings.HasPrefix(s, "[") {
+		rest, ok := p.definition(s)
+		if !ok {
+			break
+		}
+		s = rest
+	}
+	return s
+}
+
+func (p *parser) definition(s string) (string, bool) {
+	end := labelEnd(s, 0)
+	if end < 0 || end+1 >= len(s) || s[end+1] != ':' {
+		return "", false
+	}
+	label := normalizeLabel(s[1:end])
+	if label == "" {
+		return "", false
+	}
+	pos := skipSpace(s, end+2)
+	dest, pos, ok := linkDest(s, pos)
+	if !ok {
+		return "", false
+	}
+	title := ""
+	if t := skipSpace(s, pos); t > pos {
+		if tt, np, ok := linkTitle(s, t); ok && lineRestBlank(s, np) {
+			title, pos = tt, np
+		}
+	}
+	if !lineRestBlank(s, pos) {
+		return "", false
+	}
+	if _, ok := p.refs[label]; !ok {
+		p.refs[label] = ref{dest: dest, title: title}
+	}
+	if nl := strings.IndexByte(s[pos:], '\n'); nl >= 0 {
+		return s[pos+nl+1:], true
+	}
+	return "", true
+}
+
+func (p *parser) quote(lines []string, i int) (*block, int) {
+	var inner []string
+	j := i
+	for ; j < len(lines); j++ {
+		line := lines[j]
+		ind := indentOf(line)
+		if ind < 4 && ind < len(line) && line[ind] == '>' {
+			rest := line[ind+1:]
+			inner = append(inner, strings.TrimPrefix(rest, " "))
+			continue
+		}
+		// A paragraph continues lazily onto lines without the marker.
+		if isBlank(line) || isBlank(inner[len(inner)-1]) || p.interrupts(line) || setext(line) > 0 {
+			break
+		}
+		inner = append(inner, line)
+	}
+	children, _ := p.parse(inner)
+	return &block{kind: quoteBlock, children: children}, j
+}
+
+type marker struct {
+	ordered bool
+	ch      byte // bullet, or the delimiter after an ordered number
+	start   int
+	indent  int // column of the item's content
+	empty   bool
+}
+
+func listMarker(line string) (marker, bool) {
+	var m marker
+	ind := indentOf(line)
+	if ind >= 4 || ind == len(line) {
+		return m, false
+	}
+	s := line[ind:]
+	w := 0
+	switch s[0] {
+	case '-', '+', '*':
+		m.ch, w = s[0], 1
+	default:
+		for w < len(s) && w < 9 && isDigit(s[w]) {
+			w++
+		}
+		if w == 0 || w >= len(s) || s[w] != '.' && s[w] != ')' {
+			return m, false
+		}
+		m.ordered, m.ch = true, s[w]
+		m.start, _ = strconv.Atoi(s[:w])
+		w++
+	}
+	rest := s[w:]
+	if rest != "" && rest[0] != ' ' {
+		return m, false
+	}
+	sp := indentOf(rest)
+	if isBlank(rest) {
+		m.empty, sp = true, 1
+	} else if sp > 4 {
+		// The content is indented code; one space belongs to the marker.
+		sp = 1
+	}
+	m.indent = ind + w + sp
+	return m, true
+}
+
+func (p *parser) list(lines []string, i int) (*block, int) {
+	first, _ := listMarker(lines[i])
+	l := &block{kind: listBlock, ordered: first.ordered, start: first.start, tight: true}
+	j := i
+	for j < len(lines) {
+		m, ok := listMarker(lines[j])
+		if !ok || m.ordered != first.ordered || m.ch != first.ch || isRule(strings.TrimLeft(lines[j], " ")) {
+			break
+		}
+		item := []string{lines[j][min(m.indent, len(lines[j])):]}
+		for j++; j < len(lines); j++ {
+			line := lines[j]
+			if isBlank(line) {
+				item = append(item, "")
+				continue
+			}
+			if indentOf(line) >= m.indent {
+				item = append(item, line[m.indent:])
+				continue
+			}
+			if _, sibling := listMarker(line); sibling || isBlank(item[len(item)-1]) || p.interrupts(line) || setext(line) > 0 {
+				break
+			}
+			item = append(item, strings.TrimLeft(line, " "))
+		}
+		// The blank lines after the item may belong to the enclosing
+		// block; the rest of the marker's line, blank for an item that
+		// starts empty, does not.
+		trailing := 0
+		for len(item) > 1 && isBlank(item[len(item)-1]) {
+			item = item[:len(item)-1]
+			trailing++
+		}
+		if len(item) == 1 && isBlank(item[0]) {
+			item = nil
+		}
+		children, loose := p.parse(item)
+		if loose {
+			l.tight = false
+		}
+		it := &block{kind: itemBlock, children: children}
+		taskItem(it)
+		l.children = append(l.children, it)
+		if trailing > 0 {
+			if next, ok := listMarker(lineAt(lines, j)); ok && next.ordered == first.ordered && next.ch == first.ch {
+				l.tight = false
+			} else {
+				// Leave the blank lines to the enclosing block.
+				j -= trailing
+				break
+			}
+		}
+	}
+	return l, j
+}
+
+// taskItem turns an item starting with "[ ]" or "[x]" into a task.
+func taskItem(it *block) {
+	if len(it.children) == 0 || it.children[0].kind != paragraphBlock {
+		return
+	}
+	para := it.children[0]
+	t := para.text
+	if len(t) < 3 || t[0] != '[' || t[2] != ']' || len(t) > 3 && t[3] != ' ' && t[3] != '\n' {
+		return
+	}
+	switch t[1] {
+	case ' ':
+		it.task = 1
+	case 'x', 'X':
+		it.task = 2
+	default:
+		return
+	}
+	para.text = strings.TrimLeft(t[3:], " \n")
+}
+
+func fence(s string) (ch byte, n int, info string, ok bool) {
+	if len(s) < 3 || s[0] != '`' && s[0] != '~' {
+		return 0, 0, "", false
+	}
+	ch = s[0]
+	for n < len(s) && s[n] == ch {
+		n++
+	}
+	if n < 3 {
+		return 0, 0, "", false
+	}
+	info = strings.TrimSpace(s[n:])
+	if ch == '`' && strings.IndexByte(info, '`') >= 0 {
+		return 0, 0, "", false
+	}
+	return ch, n, info, true
+}
+
+func fenced(lines []string, i, ind int) (*block, int) {
+	ch, n, info, _ := fence(lines[i][ind:])
+	var body []string
+	j := i + 1
+	for ; j < len(lines); j++ {
+		line := lines[j]
+		if li := indentOf(line); li < 4 {
+			if c, m, rest, ok := fence(line[li:]); ok && c == ch && m >= n && rest == "" {
+				j++
+				break
+			}
+		}
+		body = append(body, trimIndent(line, ind))
+	}
+	return &block{kind: codeBlock, info: unescape(info), text: joinLines(body)}, j
+}
+
+func indentedCode(lines []string, i int) (*block, int) {
+	var body []string
+	j := i
+	for ; j < len(lines) && (isBlank(lines[j]) || indentOf(lines[j]) >= 4); j++ {
+		body = append(body, trimIndent(lines[j], 4))
+	}
+	for len(body) > 0 && isBlank(body[len(body)-1]) {
+		body = body[:len(body)-1]
+		j--
+	}
+	return &block{kind: codeBlock, text: joinLines(body)}, j
+}
+
+func atx(s string) (int, string) {
+	n := 0
+	for n < len(s) && s[n] == '#' {
+		n++
+	}
+	if n == 0 || n > 6 || n < len(s) && s[n] != ' ' {
+		return 0, ""
+	}
+	t := strings.TrimSpace(s[n:])
+	if e := strings.TrimRight(t, "#"); e == "" {
+		t = ""
+	} else if e[len(e)-1] == ' ' {
+		t = strings.TrimSpace(e)
+	}
+	return n, t
+}
+
+// setext returns the level of a setext heading underline, or 0.
+func setext(line string) int {
+	ind := indentOf(line)
+	if ind >= 4 || ind == len(line) {
+		return 0
+	}
+	s := strings.TrimRight(line[ind:], " ")
+	if strings.Trim(s, "=") == "" {
+		return 1
+	}
+	if strings.Trim(s, "-") == "" {
+		return 2
+	}
+	return 0
+}
+
+func isRule(s string) bool {
+	if s == "" || s[0] != '*' && s[0] != '-' && s[0] != '_' {
+		return false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case s[0]:
+			n++
+		case ' ':
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
+
+// HTML block kinds; 0 means the line does not start one.
+const (
+	htmlComment = 1 + iota
+	htmlBlockTag
+	htmlTagLine
+)
+
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"center": true, "details": true, "dialog": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true,
+	"ol": true, "p": true, "picture": true, "section": true, "summary": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true, "th": true,
+	"thead": true, "tr": true, "ul": true,
+}
+
+func htmlStart(s string) int {
+	if !strings.HasPrefix(s, "<") {
+		return 0
+	}
+	if strings.HasPrefix(s, "<!--") {
+		return htmlComment
+	}
+	t, end, ok := parseTag(s, 0)
+	if !ok {
+		return 0
+	}
+	if blockTags[t.name] {
+		return htmlBlockTag
+	}
+	if isBlank(s[end:]) {
+		return htmlTagLine
+	}
+	return 0
+}
+
+func rawBlock(lines []string, i, kind int) (*block, int) {
+	j := i
+	if kind == htmlComment {
+		for j < len(lines) && !strings.Contains(lines[j], "-->") {
+			j++
+		}
+		j = min(j+1, len(lines))
+	} else {
+		for j < len(lines) && !isBlank(lines[j]) {
+			j++
+		}
+	}
+	return &block{kind: htmlBlock, text: joinLines(lines[i:j])}, j
+}
+
+// tableAt reports whether lines[i] is the header row of a table.
+func tableAt(lines []string, i int) bool {
+	if i+1 >= len(lines) || indentOf(lines[i]) >= 4 || !strings.Contains(lines[i], "|") {
+		return false
+	}
+	align, ok := delimiterRow(lines[i+1])
+	return ok && len(align) == len(splitRow(lines[i]))
+}
+
+func delimiterRow(line string) ([]string, bool) {
+	if indentOf(line) >= 4 || !strings.Contains(line, "|") {
+		return nil, false
+	}
+	var align []string
+	for _, c := range splitRow(line) {
+		left, right := strings.HasPrefix(c, ":"), strings.HasSuffix(c, ":")
+		if strings.Trim(c, ":") == "" || strings.Trim(strings.Trim(c, ":"), "-") != "" {
+			return nil, false
+		}
+		switch {
+		case left && right:
+			align = append(align, "center")
+		case left:
+			align = append(align, "left")
+		case right:
+			align = append(align, "right")
+		default:
+			align = append(align, "")
+		}
+	}
+	return align, true
+}
+
+func table(lines []string, i int, p *parser) (*block, int) {
+	align, _ := delimiterRow(lines[i+1])
+	t := &block{kind: tableBlock, align: align, rows: [][]string{splitRow(lines[i])}}
+	j := i + 2
+	for ; j < len(lines) && !isBlank(lines[j]) && !p.interrupts(lines[j]); j++ {
+		row := splitRow(lines[j])
+		for len(row) < len(align) {
+			row = append(row, "")
+		}
+		t.rows = append(t.rows, row[:len(align)])
+	}
+	return t, j
+}
+
+// splitRow splits a table row on the pipes not escaped with a backslash.
+func splitRow(line string) []string {
+	s := strings.TrimSpace(line)
+	s = strings.TrimPrefix(s, "|")
+	if strings.HasSuffix(s, "|") && !strings.HasSuffix(s, `\|`) {
+		s = s[:len(s)-1]
+	}
+	var cells []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, cell(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(cells, cell(s[start:]))
+}
+
+func cell(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), `\|`, "|")
+}
+
+func indentOf(s string) int {
+	n := 0
+	for n < len(s) && s[n] == ' ' {
+		n++
+	}
+	return n
+}
+
+func trimIndent(s string, n int) string {
+	return s[min(indentOf(s), n):]
+}
+
+func isBlank(s string) bool {
+	return strings.Trim(s, " ") == ""
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+func lineRestBlank(s string, i int) bool {
+	rest := s[i:]
+	if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+		rest = rest[:nl]
+	}
+	return isBlank(rest)
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
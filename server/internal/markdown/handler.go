@@ -0,0 +1,218 @@
+package markdown
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Config configures a Handler.
+type Config struct {
+	// MaxBytes caps the size of a document to render; defaults to 2 MiB.
+	MaxBytes int64
+	// MaxImageBytes caps the workspace images inlined into one preview.
+	// Images past the budget are linked to the file API instead. Defaults
+	// to 4 MiB.
+	MaxImageBytes int64
+}
+
+// Handler serves Markdown previews of workspace files.
+type Handler struct {
+	cfg        Config
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler rendering the files of wm's workspaces.
+func NewHandler(cfg Config, wm Workspaces) *Handler {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 2 << 20
+	}
+	if cfg.MaxImageBytes <= 0 {
+		cfg.MaxImageBytes = 4 << 20
+	}
+	return &Handler{cfg: cfg, workspaces: wm}
+}
+
+// Register mounts the preview routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/markdown/{path...}", h.file)
+	mux.HandleFunc("POST /api/workspaces/{id}/markdown/{path...}", h.source)
+}
+
+type preview struct {
+	Path string `json:"path"`
+	Result
+}
+
+// file renders a saved file.
+func (h *Handler) file(w http.ResponseWriter, r *http.Request) {
+	f, p, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	file, e, err := f.Open(p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer file.Close()
+	if e.Size > h.cfg.MaxBytes {
+		httpx.Error(w, http.StatusRequestEntityTooLarge, "file is too large to preview")
+		return
+	}
+	src, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.render(w, r, f, p, src)
+}
+
+type sourceRequest struct {
+	Source string `json:"source"`
+}
+
+// source renders an editor's unsaved buffer for the file at the path, so
+// the preview follows typing. The file need not exist yet.
+func (h *Handler) source(w http.ResponseWriter, r *http.Request) {
+	f, p, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	var req sourceRequest
+	if err := httpx.DecodeJSON(w, r, &req, 2*h.cfg.MaxBytes); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if int64(len(req.Source)) > h.cfg.MaxBytes {
+		httpx.Error(w, http.StatusRequestEntityTooLarge, "source is too large to preview")
+		return
+	}
+	h.render(w, r, f, p, []byte(req.Source))
+}
+
+func (h *Handler) open(w http.ResponseWriter, r *http.Request) (*files.FS, string, bool) {
+	dir, err := h.workspaces.Open(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return nil, "", false
+	}
+	p, err := files.Clean(r.PathValue("path"))
+	if err != nil || p == "" {
+		httpx.Error(w, http.StatusBadRequest, "invalid path")
+		return nil, "", false
+	}
+	f, err := files.New(dir)
+	if err != nil {
+		writeError(w, err)
+		return nil, "", false
+	}
+	return f, p, true
+}
+
+// render writes the preview as JSON, or with ?format=html as an HTML
+// fragment that may not run scripts even if opened on its own.
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, f *files.FS, p string, src []byte) {
+	res := Render(src, Options{Resolve: h.resolver(r.PathValue("id"), f, p)})
+	if r.URL.Query().Get("format") == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self' data: http: https:; style-src 'unsafe-inline'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		io.WriteString(w, res.HTML)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, preview{Path: p, Result: res})
+}
+
+// resolver resolves the relative links of the document at doc against
+// its directory, or the workspace root for paths starting with "/", as
+// GitHub does. Links point at the file API; images are inlined as data
+// URLs while they fit the budget, since the preview pane may not be able
+// to authenticate a plain image request. Links escaping the workspace are
+// dropped.
+func (h *Handler) resolver(id string, f *files.FS, doc string) func(string, bool) string {
+	budget := h.cfg.MaxImageBytes
+	base := "/api/workspaces/" + url.PathEscape(id) + "/files/"
+	return func(dest string, image bool) string {
+		p, suffix := dest, ""
+		if i := strings.IndexAny(p, "?#"); i >= 0 {
+			p, suffix = p[:i], p[i:]
+		}
+		if u, err := url.PathUnescape(p); err == nil {
+			p = u
+		}
+		if strings.HasPrefix(p, "/") {
+			p = path.Clean(p)
+		} else {
+			p = path.Join(path.Dir(doc), p)
+		}
+		rel, err := files.Clean(p)
+		if err != nil {
+			return ""
+		}
+		if image {
+			if data, ok := h.inline(f, rel, budget); ok {
+				budget -= int64(len(data))
+				return data
+			}
+		}
+		return escapeURL(base + escapePath(rel) + suffix)
+	}
+}
+
+// inline returns the image at p as a data URL if it is no larger than
+// budget.
+func (h *Handler) inline(f *files.FS, p string, budget int64) (string, bool) {
+	typ, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(p)))
+	if !strings.HasPrefix(typ, "image/") {
+		return "", false
+	}
+	file, e, err := f.Open(p)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+	if e.Type != files.TypeFile || base64.StdEncoding.EncodedLen(int(e.Size)) > int(budget) {
+		return "", false
+	}
+	data, err := io.ReadAll(io.LimitReader(file, e.Size))
+	if err != nil {
+		return "", false
+	}
+	return "data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(data), true
+}
+
+func escapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		httpx.Error(w, http.StatusNotFound, "file not found")
+	case errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("markdown preview", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "preview failed")
+	}
+}
@@ -0,0 +1,336 @@
+package markdown
+
+import (
+	"go/scanner"
+	"go/token"
+	"html"
+	"strings"
+)
+
+// Highlighted code marks tokens with these classes, which the preview
+// pane's stylesheet colors.
+const (
+	classKeyword  = "hl-keyword"
+	classBuiltin  = "hl-builtin"
+	classString   = "hl-string"
+	classNumber   = "hl-number"
+	classComment  = "hl-comment"
+	classInserted = "hl-inserted"
+	classDeleted  = "hl-deleted"
+	classMeta     = "hl-meta"
+)
+
+// language describes the tokens of a language well enough to color them.
+type language struct {
+	keywords map[string]bool
+	builtins map[string]bool
+	comments []string  // line comment prefixes
+	block    [2]string // block comment delimiters
+	quotes   string
+	fold     bool // keywords are case-insensitive
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cLike = &language{
+		keywords: words("auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while class namespace template typename public private protected virtual override new delete this throw try catch using bool true false nullptr"),
+		builtins: words("NULL size_t std printf malloc free"),
+		comments: []string{"//"},
+		block:    [2]string{"/*", "*/"},
+		quotes:   `"'`,
+	}
+	javaLike = &language{
+		keywords: words("abstract assert boolean break byte case catch char class const continue default do double else enum extends final finally float for fun if implements import instanceof int interface long native new package private protected public return short static super switch synchronized this throw throws transient try val var void volatile when while"),
+		builtins: words("true false null String Object System"),
+		comments: []string{"//"},
+		block:    [2]string{"/*", "*/"},
+		quotes:   `"'`,
+	}
+	languages = map[string]*language{
+		"javascript": {
+			keywords: words("as async await break case catch class const continue debugger default delete do else enum export extends finally for from function if implements import in instanceof interface let new of private protected public readonly return static super switch this throw try type typeof var void while with yield"),
+			builtins: words("true false null undefined NaN Infinity console window document require module"),
+			comments: []string{"//"},
+			block:    [2]string{"/*", "*/"},
+			quotes:   "\"'`",
+		},
+		"python": {
+			keywords: words("and as assert async await break case class continue def del elif else except finally for from global if import in is lambda match nonlocal not or pass raise return try while with yield"),
+			builtins: words("True False None self print len range str int float list dict set tuple bool open super isinstance"),
+			comments: []string{"#"},
+			quotes:   `"'`,
+		},
+		"shell": {
+			keywords: words("if then else elif fi case esac for while until do done in function return export local readonly set unset shift exit source"),
+			builtins: words("echo cd printf read test true false"),
+			comments: []string{"#"},
+			quotes:   `"'`,
+		},
+		"json": {
+			builtins: words("true false null"),
+			quotes:   `"`,
+		},
+		"yaml": {
+			builtins: words("true false null yes no on off"),
+			comments: []string{"#"},
+			quotes:   `"'`,
+		},
+		"toml": {
+			builtins: words("true false"),
+			comments: []string{"#"},
+			quotes:   `"'`,
+		},
+		"rust": {
+			keywords: words("as async await break const continue crate dyn else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while"),
+			builtins: words("true false Some None Ok Err Option Result String Vec Box i8 i16 i32 i64 u8 u16 u32 u64 usize isize f32 f64 bool char str"),
+			comments: []string{"//"},
+			block:    [2]string{"/*", "*/"},
+			quotes:   `"`,
+		},
+		"c":    cLike,
+		"java": javaLike,
+		"sql": {
+			keywords: words("select from where insert into values update set delete create table drop alter add column index view join left right inner outer full on group by order having limit offset as and or not null is in like between exists case when then else end union all distinct primary key foreign references default unique check begin commit rollback returning with"),
+			builtins: words("true false count sum avg min max coalesce now"),
+			comments: []string{"--"},
+			block:    [2]string{"/*", "*/"},
+			quotes:   `'"`,
+			fold:     true,
+		},
+		"dockerfile": {
+			keywords: words("from run cmd label expose env add copy entrypoint volume user workdir arg onbuild stopsignal healthcheck shell as"),
+			comments: []string{"#"},
+			quotes:   `"'`,
+			fold:     true,
+		},
+		"css": {
+			block:  [2]string{"/*", "*/"},
+			quotes: `"'`,
+		},
+		"makefile": {
+			comments: []string{"#"},
+			quotes:   `"'`,
+		},
+		"protobuf": {
+			keywords: words("syntax package import option message enum service rpc returns repeated optional required oneof map reserved stream"),
+			builtins: words("double float int32 int64 uint32 uint64 sint32 sint64 fixed32 fixed64 bool string bytes true false"),
+			comments: []string{"//"},
+			block:    [2]string{"/*", "*/"},
+			quotes:   `"'`,
+		},
+	}
+	aliases = map[string]string{
+		"js": "javascript", "jsx": "javascript", "mjs": "javascript", "cjs": "javascript",
+		"ts": "javascript", "tsx": "javascript", "typescript": "javascript",
+		"py": "python", "python3": "python",
+		"sh": "shell", "bash": "shell", "zsh": "shell", "console": "shell", "shell-session": "shell",
+		"jsonc": "json", "json5": "json", "yml": "yaml", "ini": "toml",
+		"rs": "rust", "h": "c", "cpp": "c", "c++": "c", "cc": "c", "hpp": "c",
+		"kotlin": "java", "kt": "java", "cs": "java", "csharp": "java",
+		"postgres": "sql", "postgresql": "sql", "mysql": "sql", "sqlite": "sql",
+		"docker": "dockerfile", "scss": "css", "less": "css", "make": "makefile",
+		"proto": "protobuf",
+	}
+)
+
+// highlight returns code as HTML with its tokens marked, or just escaped
+// when the language is not known.
+func highlight(lang, code string) string {
+	lang = strings.ToLower(lang)
+	if a, ok := aliases[lang]; ok {
+		lang = a
+	}
+	switch lang {
+	case "go", "golang":
+		return highlightGo(code)
+	case "diff", "patch":
+		return highlightDiff(code)
+	}
+	if l, ok := languages[lang]; ok {
+		return l.highlight(code)
+	}
+	return html.EscapeString(code)
+}
+
+func span(b *strings.Builder, class, text string) {
+	b.WriteString(`<span class="` + class + `">`)
+	b.WriteString(html.EscapeString(text))
+	b.WriteString("</span>")
+}
+
+var goBuiltins = words("append cap clear close complex copy delete imag len make max min new panic print println real recover " +
+	"any bool byte comparable complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr " +
+	"true false iota nil")
+
+// highlightGo colors Go with the standard library's scanner, which copes
+// with incomplete code by reporting illegal tokens.
+func highlightGo(src string) string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), func(token.Position, string) {}, scanner.ScanComments)
+	var b strings.Builder
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		class := ""
+		switch {
+		case tok == token.COMMENT:
+			class = classComment
+		case tok == token.STRING || tok == token.CHAR:
+			class = classString
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class = classNumber
+		case tok.IsKeyword():
+			class = classKeyword
+		case tok == token.IDENT && goBuiltins[lit]:
+			class = classBuiltin
+		}
+		off := file.Offset(pos)
+		if class == "" || off < last || off+len(lit) > len(src) || src[off:off+len(lit)] != lit {
+			continue
+		}
+		b.WriteString(html.EscapeString(src[last:off]))
+		span(&b, class, lit)
+		last = off + len(lit)
+	}
+	b.WriteString(html.EscapeString(src[last:]))
+	return b.String()
+}
+
+func highlightDiff(src string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(src, "\n") {
+		class := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "@@"),
+			strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+			class = classMeta
+		case strings.HasPrefix(line, "+"):
+			class = classInserted
+		case strings.HasPrefix(line, "-"):
+			class = classDeleted
+		}
+		if class == "" {
+			b.WriteString(html.EscapeString(line))
+			continue
+		}
+		text := strings.TrimSuffix(line, "\n")
+		span(&b, class, text)
+		b.WriteString(line[len(text):])
+	}
+	return b.String()
+}
+
+func (l *language) highlight(src string) string {
+	var b strings.Builder
+	last := 0
+	mark := func(class string, i, j int) {
+		b.WriteString(html.EscapeString(src[last:i]))
+		span(&b, class, src[i:j])
+		last = j
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		wordStart := i == 0 || !isWord(src[i-1])
+		switch {
+		case l.lineComment(src, i):
+			j := strings.IndexByte(src[i:], '\n')
+			if j < 0 {
+				j = len(src) - i
+			}
+			mark(classComment, i, i+j)
+			i += j
+		case l.block[0] != "" && strings.HasPrefix(src[i:], l.block[0]):
+			j := strings.Index(src[i+len(l.block[0]):], l.block[1])
+			end := len(src)
+			if j >= 0 {
+				end = i + len(l.block[0]) + j + len(l.block[1])
+			}
+			mark(classComment, i, end)
+			i = end
+		case strings.IndexByte(l.quotes, c) >= 0:
+			end := quoted(src, i)
+			mark(classString, i, end)
+			i = end
+		case isDigit(c) && wordStart:
+			j := i
+			for j < len(src) && (isWord(src[j]) || src[j] == '.') {
+				j++
+			}
+			mark(classNumber, i, j)
+			i = j
+		case isWord(c) && wordStart:
+			j := i
+			for j < len(src) && isWord(src[j]) {
+				j++
+			}
+			w := src[i:j]
+			if l.fold {
+				w = strings.ToLower(w)
+			}
+			switch {
+			case l.keywords[w]:
+				mark(classKeyword, i, j)
+			case l.builtins[w]:
+				mark(classBuiltin, i, j)
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	b.WriteString(html.EscapeString(src[last:]))
+	return b.String()
+}
+
+// lineComment reports whether a line comment starts at src[i]. A "#"
+// only starts one at the start of a line or after a space, so that shell
+// variables such as $# are not mistaken for comments.
+func (l *language) lineComment(src string, i int) bool {
+	for _, p := range l.comments {
+		if strings.HasPrefix(src[i:], p) && (p != "#" || i == 0 || src[i-1] == ' ' || src[i-1] == '\t' || src[i-1] == '\n') {
+			return true
+		}
+	}
+	return false
+}
+
+// quoted returns the offset after the string starting at src[i]. Strings
+// other than backquoted and triple-quoted ones end at the line's end.
+func quoted(src string, i int) int {
+	q := src[i]
+	if triple := strings.Repeat(string(q), 3); strings.HasPrefix(src[i:], triple) {
+		if j := strings.Index(src[i+3:], triple); j >= 0 {
+			return i + 3 + j + 3
+		}
+		return len(src)
+	}
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case q:
+			return j + 1
+		case '\n':
+			if q != '`' {
+				return j
+			}
+		}
+	}
+	return len(src)
+}
+
+func isWord(c byte) bool {
+	return c == '_' || isAlnum(string(c)) || c >= 0x80
+}
@@ -0,0 +1,285 @@
+package markdown
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+type attr struct {
+	name, value string
+}
+
+type tag struct {
+	name    string
+	closing bool
+	attrs   []attr
+}
+
+// parseTag parses the HTML open or closing tag at s[i], returning the
+// offset after it.
+func parseTag(s string, i int) (tag, int, bool) {
+	var t tag
+	j := i + 1
+	if j < len(s) && s[j] == '/' {
+		t.closing = true
+		j++
+	}
+	start := j
+	for j < len(s) && (isAlnum(s[j:j+1]) || j > start && s[j] == '-') {
+		j++
+	}
+	if j == start || !('a' <= s[start]|0x20 && s[start]|0x20 <= 'z') {
+		return t, i, false
+	}
+	t.name = strings.ToLower(s[start:j])
+	for {
+		k := skipSpace(s, j)
+		if k < len(s) && s[k] == '>' {
+			return t, k + 1, true
+		}
+		if !t.closing && strings.HasPrefix(s[k:], "/>") {
+			return t, k + 2, true
+		}
+		if k == j || t.closing || k >= len(s) {
+			return t, i, false
+		}
+		a, end, ok := parseAttr(s, k)
+		if !ok {
+			return t, i, false
+		}
+		t.attrs = append(t.attrs, a)
+		j = end
+	}
+}
+
+func parseAttr(s string, i int) (attr, int, bool) {
+	j := i
+	for j < len(s) && (isAlnum(s[j:j+1]) || strings.IndexByte("_:.-", s[j]) >= 0) {
+		j++
+	}
+	if j == i {
+		return attr{}, i, false
+	}
+	a := attr{name: strings.ToLower(s[i:j])}
+	k := skipSpace(s, j)
+	if k >= len(s) || s[k] != '=' {
+		return a, j, true
+	}
+	k = skipSpace(s, k+1)
+	if k >= len(s) {
+		return a, i, false
+	}
+	if q := s[k]; q == '"' || q == '\'' {
+		end := strings.IndexByte(s[k+1:min(len(s), k+1+maxScan)], q)
+		if end < 0 {
+			return a, i, false
+		}
+		a.value = html.UnescapeString(s[k+1 : k+1+end])
+		return a, k + end + 2, true
+	}
+	end := k
+	for end < len(s) && strings.IndexByte(" \t\n\"'=<>`", s[end]) < 0 {
+		end++
+	}
+	if end == k {
+		return a, i, false
+	}
+	a.value = html.UnescapeString(s[k:end])
+	return a, end, true
+}
+
+// allowed lists the raw HTML tags kept in the output and the attributes
+// kept on each. Everything else, including scripts, styles, forms, event
+// handlers and inline styles, is dropped.
+var allowed = map[string][]string{
+	"a":          {"href", "title", "name"},
+	"abbr":       {"title"},
+	"b":          nil,
+	"blockquote": nil,
+	"br":         nil,
+	"code":       nil,
+	"dd":         nil,
+	"del":        nil,
+	"details":    {"open"},
+	"div":        {"align"},
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"h1":         {"align"},
+	"h2":         {"align"},
+	"h3":         {"align"},
+	"h4":         {"align"},
+	"h5":         {"align"},
+	"h6":         {"align"},
+	"hr":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "title", "width", "height", "align"},
+	"ins":        nil,
+	"kbd":        nil,
+	"li":         nil,
+	"mark":       nil,
+	"ol":         {"start"},
+	"p":          {"align"},
+	"picture":    nil,
+	"pre":        nil,
+	"s":          nil,
+	"samp":       nil,
+	"small":      nil,
+	"source":     {"srcset", "media"},
+	"span":       nil,
+	"strong":     nil,
+	"sub":        nil,
+	"summary":    nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"align", "colspan", "rowspan"},
+	"tfoot":      nil,
+	"th":         {"align", "colspan", "rowspan"},
+	"thead":      nil,
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+	"var":        nil,
+}
+
+var void = map[string]bool{"br": true, "hr": true, "img": true, "source": true}
+
+// tag renders t with its allowed attributes, or returns "" for a tag that
+// is not allowed.
+func (r *renderer) tag(t tag) string {
+	names, ok := allowed[t.name]
+	if !ok {
+		return ""
+	}
+	if t.closing {
+		if void[t.name] {
+			return ""
+		}
+		return "</" + t.name + ">"
+	}
+	var b strings.Builder
+	b.WriteString("<" + t.name)
+	for _, a := range t.attrs {
+		if !slices.Contains(names, a.name) {
+			continue
+		}
+		v := a.value
+		switch a.name {
+		case "href":
+			v = r.url(v, false)
+		case "src":
+			v = r.url(v, true)
+		case "srcset":
+			v = r.srcset(v)
+		}
+		if v == "" && a.name != "alt" && a.name != "open" {
+			continue
+		}
+		b.WriteString(" " + a.name + `="` + html.EscapeString(v) + `"`)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+func (r *renderer) srcset(v string) string {
+	var out []string
+	for _, c := range strings.Split(v, ",") {
+		f := strings.Fields(c)
+		if len(f) == 0 {
+			continue
+		}
+		u := r.url(f[0], true)
+		if u == "" {
+			continue
+		}
+		out = append(out, strings.Join(append([]string{u}, f[1:]...), " "))
+	}
+	return strings.Join(out, ", ")
+}
+
+// rawHTML renders an HTML block: allowed tags are kept, others dropped,
+// comments removed and the text between them escaped.
+func (r *renderer) rawHTML(b *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		j := strings.IndexByte(s[i:], '<')
+		if j < 0 {
+			b.WriteString(escapeRaw(s[i:]))
+			return
+		}
+		b.WriteString(escapeRaw(s[i : i+j]))
+		i += j
+		if strings.HasPrefix(s[i:], "<!--") {
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				return
+			}
+			i += 4 + end + 3
+			continue
+		}
+		if t, end, ok := parseTag(s, i); ok {
+			b.WriteString(r.tag(t))
+			i = end
+			continue
+		}
+		b.WriteString("&lt;")
+		i++
+	}
+}
+
+// escapeRaw escapes raw HTML text, keeping the entities written in it.
+func escapeRaw(s string) string {
+	return html.EscapeString(html.UnescapeString(s))
+}
+
+// url returns the URL to emit for a link or image destination, or "" when
+// it must be dropped. Only http, https and mailto URLs, fragments and
+// relative references are kept; relative ones go through Options.Resolve.
+func (r *renderer) url(dest string, image bool) string {
+	dest = strings.TrimSpace(dest)
+	switch {
+	case dest == "":
+		return ""
+	case strings.HasPrefix(dest, "#"):
+		return escapeURL(dest)
+	case strings.HasPrefix(dest, "//"):
+		return escapeURL("https:" + dest)
+	}
+	if colon := strings.IndexByte(dest, ':'); colon > 0 && !strings.ContainsAny(dest[:colon], "/?#") {
+		switch strings.ToLower(dest[:colon]) {
+		case "http", "https", "mailto":
+			return escapeURL(dest)
+		}
+		return ""
+	}
+	if r.opts.Resolve != nil {
+		return r.opts.Resolve(dest, image)
+	}
+	return escapeURL(dest)
+}
+
+// escapeURL percent-encodes the bytes of u that may not appear in a URL,
+// leaving existing escapes alone.
+func escapeURL(u string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(u); i++ {
+		c := u[i]
+		switch {
+		case isAlnum(u[i:i+1]) || strings.IndexByte("-_.!~*'();/?:@&=+$,#", c) >= 0:
+			b.WriteByte(c)
+		case c == '%' && i+2 < len(u) && isHex(u[i+1]) && isHex(u[i+2]):
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || 'a' <= c|0x20 && c|0x20 <= 'f'
+}
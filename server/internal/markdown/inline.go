@@ -0,0 +1,734 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	codeNode
+	emphNode
+	strongNode
+	strikeNode
+	linkNode
+	imageNode
+	htmlNode
+	softBreak
+	hardBreak
+	delimNode
+)
+
+// node is an inline element. Runs of *, _ and ~ are kept as delimiter
+// nodes until emphasis is resolved.
+type node struct {
+	kind     nodeKind
+	text     string
+	children []*node
+	dest     string
+	title    string
+
+	ch          byte
+	n, orig     int
+	open, close bool
+}
+
+// bracket is an unmatched "[" or "![" that may still start a link.
+type bracket struct {
+	at    int // index of its text node
+	pos   int // offset just after it
+	image bool
+}
+
+type inliner struct {
+	p        *parser
+	r        *renderer
+	src      string
+	pos      int
+	text     []byte
+	nodes    []*node
+	brackets []bracket
+	// Brackets below inactive may no longer start links; openLinks counts
+	// the link brackets above it.
+	inactive  int
+	openLinks int
+	// ticks holds the offset of the last backtick run of each length once
+	// a code span has been searched for to the end.
+	ticks map[int]int
+	// unclosedComment is set once an HTML comment is found not to end.
+	unclosedComment bool
+}
+
+// inline parses s into inline nodes.
+func (r *renderer) inline(s string) []*node {
+	in := &inliner{p: r.p, r: r, src: s}
+	for in.pos < len(s) {
+		switch c := s[in.pos]; c {
+		case '\\':
+			in.escape()
+		case '`':
+			in.codeSpan()
+		case '*', '_', '~':
+			in.delim()
+		case '[':
+			in.openBracket(false, 1)
+		case '!':
+			if strings.HasPrefix(s[in.pos:], "![") {
+				in.openBracket(true, 2)
+			} else {
+				in.text = append(in.text, c)
+				in.pos++
+			}
+		case ']':
+			in.closeBracket()
+		case '<':
+			in.angle()
+		case '&':
+			in.entity()
+		case '\n':
+			in.newline()
+		default:
+			if !in.bareLink() {
+				in.text = append(in.text, c)
+				in.pos++
+			}
+		}
+	}
+	in.flush()
+	return emphasis(in.nodes)
+}
+
+func (in *inliner) flush() {
+	if len(in.text) > 0 {
+		in.nodes = append(in.nodes, &node{kind: textNode, text: string(in.text)})
+		in.text = in.text[:0]
+	}
+}
+
+func (in *inliner) push(n *node) {
+	in.flush()
+	in.nodes = append(in.nodes, n)
+}
+
+func (in *inliner) escape() {
+	if in.pos+1 < len(in.src) {
+		switch c := in.src[in.pos+1]; {
+		case c == '\n':
+			in.push(&node{kind: hardBreak})
+			in.pos += 2
+			in.pos = skipLeading(in.src, in.pos)
+			return
+		case isPunct(c):
+			in.text = append(in.text, c)
+			in.pos += 2
+			return
+		}
+	}
+	in.text = append(in.text, '\\')
+	in.pos++
+}
+
+func (in *inliner) codeSpan() {
+	s := in.src
+	n := run(s, in.pos, '`')
+	if in.ticks != nil && in.ticks[n] <= in.pos {
+		in.text = append(in.text, s[in.pos:in.pos+n]...)
+		in.pos += n
+		return
+	}
+	last := make(map[int]int)
+	for i := in.pos + n; i < len(s); {
+		j := strings.IndexByte(s[i:], '`')
+		if j < 0 {
+			break
+		}
+		i += j
+		m := run(s, i, '`')
+		last[m] = i
+		if m == n {
+			code := strings.ReplaceAll(s[in.pos+n:i], "\n", " ")
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			in.push(&node{kind: codeNode, text: code})
+			in.pos = i + m
+			return
+		}
+		i += m
+	}
+	in.ticks = last
+	in.text = append(in.text, s[in.pos:in.pos+n]...)
+	in.pos += n
+}
+
+func (in *inliner) delim() {
+	s := in.src
+	c := s[in.pos]
+	n := run(s, in.pos, c)
+	if c == '~' && n > 2 {
+		in.text = append(in.text, s[in.pos:in.pos+n]...)
+		in.pos += n
+		return
+	}
+	before, _ := utf8.DecodeLastRuneInString(s[:in.pos])
+	after, _ := utf8.DecodeRuneInString(s[in.pos+n:])
+	if in.pos == 0 {
+		before = ' '
+	}
+	if in.pos+n == len(s) {
+		after = ' '
+	}
+	spaceBefore, spaceAfter := unicode.IsSpace(before), unicode.IsSpace(after)
+	punctBefore, punctAfter := isPunctRune(before), isPunctRune(after)
+	left := !spaceAfter && (!punctAfter || spaceBefore || punctBefore)
+	right := !spaceBefore && (!punctBefore || spaceAfter || punctAfter)
+	d := &node{kind: delimNode, ch: c, n: n, orig: n, open: left, close: right}
+	if c == '_' {
+		d.open = left && (!right || punctBefore)
+		d.close = right && (!left || punctAfter)
+	}
+	in.push(d)
+	in.pos += n
+}
+
+func (in *inliner) openBracket(image bool, n int) {
+	in.flush()
+	in.inactive = min(in.inactive, len(in.brackets))
+	if !image {
+		in.openLinks++
+	}
+	in.brackets = append(in.brackets, bracket{at: len(in.nodes), pos: in.pos + n, image: image})
+	in.nodes = append(in.nodes, &node{kind: textNode, text: in.src[in.pos : in.pos+n]})
+	in.pos += n
+}
+
+func (in *inliner) closeBracket() {
+	in.pos++
+	if len(in.brackets) == 0 {
+		in.text = append(in.text, ']')
+		return
+	}
+	top := len(in.brackets) - 1
+	b := in.brackets[top]
+	in.brackets = in.brackets[:top]
+	active := b.image || top >= in.inactive
+	if !b.image && top >= in.inactive {
+		in.openLinks--
+	}
+	if !active {
+		in.text = append(in.text, ']')
+		return
+	}
+	dest, title, end, ok := in.linkTail(in.src[b.pos : in.pos-1])
+	if !ok {
+		in.text = append(in.text, ']')
+		return
+	}
+	in.flush()
+	kind := linkNode
+	if b.image {
+		kind = imageNode
+	}
+	children := emphasis(append([]*node(nil), in.nodes[b.at+1:]...))
+	in.nodes = append(in.nodes[:b.at], &node{kind: kind, children: children, dest: dest, title: title})
+	in.pos = end
+	if !b.image {
+		// Links may not contain other links.
+		in.inactive, in.openLinks = len(in.brackets), 0
+	}
+}
+
+// linkTail parses what follows the "]" of a link with the given text: an
+// inline destination and title, or a reference to a definition.
+func (in *inliner) linkTail(text string) (dest, title string, end int, ok bool) {
+	s, pos := in.src, in.pos
+	if pos < len(s) && s[pos] == '(' {
+		i := skipSpace(s, pos+1)
+		if i < len(s) && s[i] == ')' {
+			return "", "", i + 1, true
+		}
+		if d, j, ok := linkDest(s, i); ok {
+			t := ""
+			if k := skipSpace(s, j); k > j {
+				if tt, e, ok := linkTitle(s, k); ok {
+					t, j = tt, e
+				}
+			}
+			j = skipSpace(s, j)
+			if j < len(s) && s[j] == ')' {
+				return d, t, j + 1, true
+			}
+		}
+	}
+	label, end := text, pos
+	if pos < len(s) && s[pos] == '[' {
+		if e := labelEnd(s, pos); e >= 0 {
+			if e > pos+1 {
+				label = s[pos+1 : e]
+			}
+			end = e + 1
+		}
+	}
+	if len(label) > 999 {
+		return "", "", 0, false
+	}
+	r, found := in.p.refs[normalizeLabel(label)]
+	if !found {
+		return "", "", 0, false
+	}
+	return r.dest, r.title, end, true
+}
+
+func (in *inliner) angle() {
+	s := in.src
+	if end := strings.IndexAny(s[in.pos+1:], "<> \t\n") + 1; end > 0 && s[in.pos+end] == '>' {
+		inner := s[in.pos+1 : in.pos+end]
+		switch {
+		case isAbsoluteURI(inner):
+			in.push(&node{kind: linkNode, dest: inner, children: []*node{{kind: textNode, text: inner}}})
+			in.pos += end + 1
+			return
+		case isEmail(inner):
+			in.push(&node{kind: linkNode, dest: "mailto:" + inner, children: []*node{{kind: textNode, text: inner}}})
+			in.pos += end + 1
+			return
+		}
+	}
+	if strings.HasPrefix(s[in.pos:], "<!--") && !in.unclosedComment {
+		if end := strings.Index(s[in.pos+4:], "-->"); end >= 0 {
+			in.pos += 4 + end + 3
+			return
+		}
+		in.unclosedComment = true
+	}
+	if t, end, ok := parseTag(s, in.pos); ok {
+		in.push(&node{kind: htmlNode, text: in.r.tag(t)})
+		in.pos = end
+		return
+	}
+	in.text = append(in.text, '<')
+	in.pos++
+}
+
+func (in *inliner) entity() {
+	s := in.src[in.pos:]
+	if end := strings.IndexByte(s, ';'); end > 1 && end < 34 {
+		ent := s[:end+1]
+		if dec := html.UnescapeString(ent); dec != ent && validEntity(s[1:end]) {
+			in.text = append(in.text, dec...)
+			in.pos += end + 1
+			return
+		}
+	}
+	in.text = append(in.text, '&')
+	in.pos++
+}
+
+func (in *inliner) newline() {
+	t := strings.TrimRight(string(in.text), " ")
+	hard := len(in.text)-len(t) >= 2
+	in.text = append(in.text[:0], t...)
+	if hard {
+		in.push(&node{kind: hardBreak})
+	} else {
+		in.push(&node{kind: softBreak})
+	}
+	in.pos = skipLeading(in.src, in.pos+1)
+}
+
+// bareLink turns a URL or www. address written without angle brackets into
+// a link, as GitHub does.
+func (in *inliner) bareLink() bool {
+	s, pos := in.src, in.pos
+	if c := s[pos]; c != 'h' && c != 'w' && c != 'H' && c != 'W' {
+		return false
+	}
+	if pos > 0 && !strings.ContainsRune(" \t\n*_~(", rune(s[pos-1])) {
+		return false
+	}
+	if in.openLinks > 0 {
+		return false
+	}
+	rest := s[pos:]
+	lower := strings.ToLower(rest[:min(len(rest), 8)])
+	scheme, skip := "", 0
+	switch {
+	case strings.HasPrefix(lower, "https://"):
+		skip = 8
+	case strings.HasPrefix(lower, "http://"):
+		skip = 7
+	case strings.HasPrefix(lower, "www."):
+		scheme, skip = "http://", 4
+	default:
+		return false
+	}
+	end := strings.IndexAny(rest, " \t\n<")
+	if end < 0 {
+		end = len(rest)
+	}
+	u := trimLinkEnd(rest[:end])
+	if len(u) <= skip {
+		return false
+	}
+	host := u[skip:]
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if host == "" || scheme != "" && !strings.Contains(host, ".") {
+		return false
+	}
+	in.push(&node{kind: linkNode, dest: scheme + u, children: []*node{{kind: textNode, text: u}}})
+	in.pos += len(u)
+	return true
+}
+
+// trimLinkEnd drops the punctuation that ends a sentence rather than a
+// URL, and closing parentheses without an opening one in the URL.
+func trimLinkEnd(u string) string {
+	for u != "" {
+		switch c := u[len(u)-1]; {
+		case strings.IndexByte("?!.,:*_~'\"", c) >= 0:
+			u = u[:len(u)-1]
+		case c == ')' && strings.Count(u, ")") > strings.Count(u, "("):
+			u = u[:len(u)-1]
+		case c == ';':
+			if amp := strings.LastIndexByte(u, '&'); amp >= 0 && isAlnum(u[amp+1:len(u)-1]) {
+				u = u[:amp]
+			} else {
+				return u
+			}
+		default:
+			return u
+		}
+	}
+	return u
+}
+
+// elem links the nodes being resolved by emphasis, so that wrapping a
+// span does not copy the rest of the paragraph.
+type elem struct {
+	n          *node
+	prev, next *elem
+	seq        int
+}
+
+// emphasis resolves the delimiter runs in nodes into emphasis, strong
+// emphasis and strikethrough, following CommonMark's algorithm. Runs left
+// unmatched become text.
+func emphasis(nodes []*node) []*node {
+	head := &elem{seq: -1}
+	tail := head
+	for i, n := range nodes {
+		e := &elem{n: n, prev: tail, seq: i}
+		tail.next = e
+		tail = e
+	}
+	type key struct {
+		ch        byte
+		mod       int
+		canOpener bool
+	}
+	// bottom holds, per kind of closer, the element below which a
+	// previous search found no opener.
+	bottom := map[key]*elem{}
+	for c := head.next; c != nil; c = c.next {
+		cl := c.n
+		if cl.kind != delimNode || !cl.close {
+			continue
+		}
+		for cl.n > 0 {
+			k := key{cl.ch, cl.orig % 3, cl.open}
+			var o *elem
+			for e := c.prev; e != head; e = e.prev {
+				if op := e.n; op.kind == delimNode && op.ch == cl.ch && op.open && op.n > 0 &&
+					!((op.close || cl.open) && (op.orig+cl.orig)%3 == 0 && (op.orig%3 != 0 || cl.orig%3 != 0)) &&
+					(cl.ch != '~' || op.n == cl.n) {
+					o = e
+					break
+				}
+				if e == bottom[k] {
+					break
+				}
+			}
+			if o == nil {
+				bottom[k] = c
+				break
+			}
+			op := o.n
+			use, kind := 1, emphNode
+			switch {
+			case cl.ch == '~':
+				use, kind = cl.n, strikeNode
+			case op.n >= 2 && cl.n >= 2:
+				use, kind = 2, strongNode
+			}
+			op.n -= use
+			cl.n -= use
+			var inner []*node
+			for e := o.next; e != c; e = e.next {
+				inner = append(inner, e.n)
+			}
+			w := &elem{n: &node{kind: kind, children: literal(inner)}, prev: o, next: c, seq: o.seq}
+			o.next, c.prev = w, w
+			for k, b := range bottom {
+				if o.seq < b.seq && b.seq < c.seq {
+					bottom[k] = o
+				}
+			}
+			if op.n == 0 {
+				o.prev.next, w.prev = w, o.prev
+				for k, b := range bottom {
+					if b == o {
+						bottom[k] = o.prev
+					}
+				}
+			}
+		}
+		if cl.n == 0 {
+			prev := c.prev
+			prev.next = c.next
+			if c.next != nil {
+				c.next.prev = prev
+			}
+			for k, b := range bottom {
+				if b == c {
+					bottom[k] = prev
+				}
+			}
+			c = prev
+		}
+	}
+	var out []*node
+	for e := head.next; e != nil; e = e.next {
+		out = append(out, e.n)
+	}
+	return literal(out)
+}
+
+// literal turns the remaining delimiter runs in nodes into text.
+func literal(nodes []*node) []*node {
+	out := make([]*node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.kind == delimNode {
+			if n.n == 0 {
+				continue
+			}
+			n = &node{kind: textNode, text: strings.Repeat(string(n.ch), n.n)}
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// plain returns the text of nodes without markup, as used for alt text
+// and heading anchors.
+func plain(nodes []*node) string {
+	var b strings.Builder
+	var walk func([]*node)
+	walk = func(nodes []*node) {
+		for _, n := range nodes {
+			switch n.kind {
+			case textNode, codeNode:
+				b.WriteString(n.text)
+			case softBreak, hardBreak:
+				b.WriteByte(' ')
+			default:
+				walk(n.children)
+			}
+		}
+	}
+	walk(nodes)
+	return b.String()
+}
+
+// maxScan bounds how far a link destination, title or attribute value is
+// searched for its end, so that inputs with many unterminated ones do not
+// take quadratic time.
+const maxScan = 8 << 10
+
+// linkDest parses a link destination at s[i]: either <...> or a run
+// without spaces and with balanced parentheses.
+func linkDest(s string, i int) (string, int, bool) {
+	if i >= len(s) {
+		return "", i, false
+	}
+	if s[i] == '<' {
+		for j := i + 1; j < len(s) && j-i < maxScan; j++ {
+			switch s[j] {
+			case '\\':
+				j++
+			case '\n', '<':
+				return "", i, false
+			case '>':
+				return unescape(s[i+1 : j]), j + 1, true
+			}
+		}
+		return "", i, false
+	}
+	depth, j := 0, i
+loop:
+	for ; j < len(s); j++ {
+		switch c := s[j]; {
+		case c == '\\' && j+1 < len(s) && isPunct(s[j+1]):
+			j++
+		case c == '(':
+			if depth++; depth > 32 {
+				return "", i, false
+			}
+		case c == ')':
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case c <= ' ':
+			break loop
+		}
+	}
+	if j == i || depth != 0 {
+		return "", i, false
+	}
+	return unescape(s[i:j]), j, true
+}
+
+func linkTitle(s string, i int) (string, int, bool) {
+	if i >= len(s) {
+		return "", i, false
+	}
+	closer := s[i]
+	switch closer {
+	case '"', '\'':
+	case '(':
+		closer = ')'
+	default:
+		return "", i, false
+	}
+	for j := i + 1; j < len(s) && j-i < maxScan; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case closer:
+			return unescape(s[i+1 : j]), j + 1, true
+		}
+	}
+	return "", i, false
+}
+
+// labelEnd returns the index of the "]" closing the link label opened at
+// s[i], or -1.
+func labelEnd(s string, i int) int {
+	for j := i + 1; j < len(s) && j-i <= 1000; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			return -1
+		case ']':
+			return j
+		}
+	}
+	return -1
+}
+
+func normalizeLabel(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// unescape resolves backslash escapes and entity references.
+func unescape(s string) string {
+	if !strings.ContainsAny(s, `\&`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return html.UnescapeString(b.String())
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func skipLeading(s string, i int) int {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+func run(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// validEntity reports whether name, between "&" and ";", is a named or
+// numeric character reference.
+func validEntity(name string) bool {
+	if rest, ok := strings.CutPrefix(name, "#"); ok {
+		if hex, ok := strings.CutPrefix(strings.ToLower(rest), "x"); ok {
+			return hex != "" && len(hex) <= 6 && strings.Trim(hex, "0123456789abcdef") == ""
+		}
+		return rest != "" && len(rest) <= 7 && strings.Trim(rest, "0123456789") == ""
+	}
+	return isAlnum(name)
+}
+
+func isAbsoluteURI(s string) bool {
+	colon := strings.IndexByte(s, ':')
+	if colon < 2 || colon > 32 || !isScheme(s[:colon]) {
+		return false
+	}
+	return !strings.ContainsAny(s, " \t\n<>")
+}
+
+func isEmail(s string) bool {
+	at := strings.IndexByte(s, '@')
+	if at < 1 || at == len(s)-1 || strings.ContainsAny(s, " \t\n<>\\") {
+		return false
+	}
+	return strings.Contains(s[at+1:], ".") && !strings.HasPrefix(s[at+1:], ".")
+}
+
+func isScheme(s string) bool {
+	if s == "" || !('a' <= s[0] && s[0] <= 'z' || 'A' <= s[0] && s[0] <= 'Z') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if !(isAlnum(string(c)) || c == '+' || c == '.' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || isDigit(c)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isPunctRune(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
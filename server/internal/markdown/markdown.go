@@ -0,0 +1,276 @@
+// Package markdown renders workspace Markdown to HTML for the editor's
+// preview pane. It implements the CommonMark blocks and inlines and the
+// GitHub extensions READMEs rely on: tables, task lists, strikethrough and
+// bare links. Fenced code is syntax-highlighted.
+//
+// The output is safe to insert into the IDE's page. Raw HTML is reduced
+// to an allowlist of presentational tags and attributes, and links and
+// images keep only http, https and mailto URLs, fragments and relative
+// references, which the caller resolves against the workspace.
+package markdown
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Options configures a rendering.
+type Options struct {
+	// Resolve maps a relative link or image destination, as written in
+	// the document, to the URL to emit, or "" to drop it. When nil,
+	// relative destinations are kept as written.
+	Resolve func(dest string, image bool) string
+}
+
+// Heading is an entry of a document's outline.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// Result is a rendered document.
+type Result struct {
+	HTML     string    `json:"html"`
+	Headings []Heading `json:"headings"`
+}
+
+type renderer struct {
+	p        *parser
+	opts     Options
+	b        strings.Builder
+	headings []Heading
+	ids      map[string]int
+}
+
+// Render renders the Markdown document src.
+func Render(src []byte, opts Options) Result {
+	p := &parser{refs: make(map[string]ref)}
+	blocks, _ := p.parse(splitLines(string(src)))
+	r := &renderer{p: p, opts: opts, headings: []Heading{}, ids: make(map[string]int)}
+	r.blocks(blocks)
+	return Result{HTML: r.b.String(), Headings: r.headings}
+}
+
+// splitLines normalizes line endings, replaces NUL characters and expands
+// tabs to the next multiple of four columns.
+func splitLines(s string) []string {
+	s = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\x00", "\uFFFD").Replace(s)
+	s = strings.TrimSuffix(s, "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "\t") {
+			continue
+		}
+		var b strings.Builder
+		col := 0
+		for _, c := range line {
+			if c == '\t' {
+				n := 4 - col%4
+				b.WriteString(strings.Repeat(" ", n))
+				col += n
+				continue
+			}
+			b.WriteRune(c)
+			col++
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+func (r *renderer) blocks(blocks []*block) {
+	for _, b := range blocks {
+		r.block(b)
+	}
+}
+
+func (r *renderer) block(b *block) {
+	w := &r.b
+	switch b.kind {
+	case paragraphBlock:
+		w.WriteString("<p>")
+		r.inlines(r.inline(b.text))
+		w.WriteString("</p>\n")
+	case headingBlock:
+		nodes := r.inline(b.text)
+		text := strings.TrimSpace(plain(nodes))
+		id := r.anchor(text)
+		r.headings = append(r.headings, Heading{Level: b.level, Text: text, ID: id})
+		level := strconv.Itoa(b.level)
+		w.WriteString("<h" + level + ` id="` + html.EscapeString(id) + `">`)
+		r.inlines(nodes)
+		w.WriteString("</h" + level + ">\n")
+	case codeBlock:
+		lang, _, _ := strings.Cut(b.info, " ")
+		if lang != "" {
+			w.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
+			w.WriteString(highlight(lang, b.text))
+		} else {
+			w.WriteString("<pre><code>")
+			w.WriteString(html.EscapeString(b.text))
+		}
+		w.WriteString("</code></pre>\n")
+	case quoteBlock:
+		w.WriteString("<blockquote>\n")
+		r.blocks(b.children)
+		w.WriteString("</blockquote>\n")
+	case listBlock:
+		tag := "ul"
+		if b.ordered {
+			tag = "ol"
+		}
+		w.WriteString("<" + tag)
+		if b.ordered && b.start != 1 {
+			w.WriteString(` start="` + strconv.Itoa(b.start) + `"`)
+		}
+		w.WriteString(">\n")
+		for _, it := range b.children {
+			r.item(it, b.tight)
+		}
+		w.WriteString("</" + tag + ">\n")
+	case ruleBlock:
+		w.WriteString("<hr>\n")
+	case tableBlock:
+		r.table(b)
+	case htmlBlock:
+		r.rawHTML(w, b.text)
+	}
+}
+
+func (r *renderer) item(it *block, tight bool) {
+	w := &r.b
+	switch it.task {
+	case 0:
+		w.WriteString("<li>")
+	case 1:
+		w.WriteString(`<li class="task-list-item"><input type="checkbox" disabled> `)
+	default:
+		w.WriteString(`<li class="task-list-item"><input type="checkbox" checked disabled> `)
+	}
+	// Paragraphs of tight lists are written without <p>.
+	for i, c := range it.children {
+		if tight && c.kind == paragraphBlock {
+			r.inlines(r.inline(c.text))
+			if i < len(it.children)-1 {
+				w.WriteString("\n")
+			}
+			continue
+		}
+		if i == 0 {
+			w.WriteString("\n")
+		}
+		r.block(c)
+	}
+	w.WriteString("</li>\n")
+}
+
+func (r *renderer) table(t *block) {
+	w := &r.b
+	w.WriteString("<table>\n<thead>\n")
+	for i, row := range t.rows {
+		if i == 1 {
+			w.WriteString("<tbody>\n")
+		}
+		cell := "td"
+		if i == 0 {
+			cell = "th"
+		}
+		w.WriteString("<tr>\n")
+		for j, c := range row {
+			w.WriteString("<" + cell)
+			if t.align[j] != "" {
+				w.WriteString(` align="` + t.align[j] + `"`)
+			}
+			w.WriteString(">")
+			r.inlines(r.inline(c))
+			w.WriteString("</" + cell + ">\n")
+		}
+		w.WriteString("</tr>\n")
+		if i == 0 {
+			w.WriteString("</thead>\n")
+		}
+	}
+	if len(t.rows) > 1 {
+		w.WriteString("</tbody>\n")
+	}
+	w.WriteString("</table>\n")
+}
+
+func (r *renderer) inlines(nodes []*node) {
+	w := &r.b
+	for _, n := range nodes {
+		switch n.kind {
+		case textNode:
+			w.WriteString(html.EscapeString(n.text))
+		case codeNode:
+			w.WriteString("<code>" + html.EscapeString(n.text) + "</code>")
+		case emphNode:
+			r.wrap("em", n.children)
+		case strongNode:
+			r.wrap("strong", n.children)
+		case strikeNode:
+			r.wrap("del", n.children)
+		case linkNode:
+			href := r.url(n.dest, false)
+			if href == "" {
+				r.inlines(n.children)
+				continue
+			}
+			w.WriteString(`<a href="` + html.EscapeString(href) + `"`)
+			r.title(n.title)
+			w.WriteString(">")
+			r.inlines(n.children)
+			w.WriteString("</a>")
+		case imageNode:
+			w.WriteString("<img")
+			if src := r.url(n.dest, true); src != "" {
+				w.WriteString(` src="` + html.EscapeString(src) + `"`)
+			}
+			w.WriteString(` alt="` + html.EscapeString(plain(n.children)) + `"`)
+			r.title(n.title)
+			w.WriteString(">")
+		case htmlNode:
+			w.WriteString(n.text)
+		case softBreak:
+			w.WriteString("\n")
+		case hardBreak:
+			w.WriteString("<br>\n")
+		}
+	}
+}
+
+func (r *renderer) wrap(tag string, children []*node) {
+	r.b.WriteString("<" + tag + ">")
+	r.inlines(children)
+	r.b.WriteString("</" + tag + ">")
+}
+
+func (r *renderer) title(t string) {
+	if t != "" {
+		r.b.WriteString(` title="` + html.EscapeString(t) + `"`)
+	}
+}
+
+// anchor returns the id of a heading, derived from its text the way
+// GitHub does so that links to sections of a README keep working.
+func (r *renderer) anchor(text string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(text) {
+		switch {
+		case c == ' ':
+			b.WriteByte('-')
+		case c == '-' || c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.Is(unicode.Mn, c):
+			b.WriteRune(c)
+		}
+	}
+	id := b.String()
+	n := r.ids[id]
+	r.ids[id]++
+	if n > 0 {
+		id += "-" + strconv.Itoa(n)
+	}
+	return id
+}
@@ -0,0 +1,208 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func render(s string) string {
+	return Render([]byte(s), Options{}).HTML
+}
+
+func TestBlocks(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"atx headings", "# Title\n## Sub *em*\n###### six\n",
+			"<h1 id=\"title\">Title</h1>\n<h2 id=\"sub-em\">Sub <em>em</em></h2>\n<h6 id=\"six\">six</h6>\n"},
+		{"not headings", "####### seven\n#no\n", "<p>####### seven\n#no</p>\n"},
+		{"closing hashes", "## Title ##\n", "<h2 id=\"title\">Title</h2>\n"},
+		{"setext headings", "Setext\n===\n\nTwo\n---\n", "<h1 id=\"setext\">Setext</h1>\n<h2 id=\"two\">Two</h2>\n"},
+		{"duplicate anchors", "# A\n# A\n", "<h1 id=\"a\">A</h1>\n<h1 id=\"a-1\">A</h1>\n"},
+		{"tight list", "- a\n- b\n", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"loose list", "- a\n\n- b\n", "<ul>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n</ul>\n"},
+		{"nested list", "- a\n  - b\n", "<ul>\n<li>a\n<ul>\n<li>b</li>\n</ul>\n</li>\n</ul>\n"},
+		{"ordered list start", "3) three\n4) four\n", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"empty item", "- a\n-\n", "<ul>\n<li>a</li>\n<li></li>\n</ul>\n"},
+		{"empty last item after a block", "<b>\n\n*", "<b>\n<ul>\n<li></li>\n</ul>\n"},
+		{"empty item before blank lines", "-\n\n\nx\n", "<ul>\n<li></li>\n</ul>\n<p>x</p>\n"},
+		{"task list", "- [ ] todo\n- [x] done\n",
+			"<ul>\n<li class=\"task-list-item\"><input type=\"checkbox\" disabled> todo</li>\n" +
+				"<li class=\"task-list-item\"><input type=\"checkbox\" checked disabled> done</li>\n</ul>\n"},
+		{"fence", "~~~\n<b>x</b>\n~~~\n", "<pre><code>&lt;b&gt;x&lt;/b&gt;\n</code></pre>\n"},
+		{"longer fence", "````\n```\nnested\n````\n", "<pre><code>```\nnested\n</code></pre>\n"},
+		{"unclosed fence", "```\ncode\n", "<pre><code>code\n</code></pre>\n"},
+		{"fence language", "```go\nx\n```\n", "<pre><code class=\"language-go\">x\n</code></pre>\n"},
+		{"indented code", "    a <b>\n", "<pre><code>a &lt;b&gt;\n</code></pre>\n"},
+		{"quote", "> quote\n> *x*\n", "<blockquote>\n<p>quote\n<em>x</em></p>\n</blockquote>\n"},
+		{"lazy quote", "> a\nb\n", "<blockquote>\n<p>a\nb</p>\n</blockquote>\n"},
+		{"rule", "***\n", "<hr>\n"},
+		{"table", "| a | b |\n| :- | -: |\n| 1 | 2 |\n",
+			"<table>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td align=\"left\">1</td>\n<td align=\"right\">2</td>\n</tr>\n</tbody>\n</table>\n"},
+		{"hard breaks", "a  \nb\\\nc\n", "<p>a<br>\nb<br>\nc</p>\n"},
+		{"crlf", "a\r\nb\r\n", "<p>a\nb</p>\n"},
+	}
+	for _, tt := range tests {
+		if got := render(tt.in); got != tt.want {
+			t.Errorf("%s: Render(%q) =\n%s\nwant\n%s", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInlines(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"*a* _b_", "<em>a</em> <em>b</em>"},
+		{"**a** __b__", "<strong>a</strong> <strong>b</strong>"},
+		{"*a **b** c*", "<em>a <strong>b</strong> c</em>"},
+		{"***both***", "<em><strong>both</strong></em>"},
+		{"**a *b***", "<strong>a <em>b</em></strong>"},
+		{"*foo**bar**baz*", "<em>foo<strong>bar</strong>baz</em>"},
+		{"_a_b_", "<em>a_b</em>"},
+		{"snake_case_name", "snake_case_name"},
+		{"*a*b*", "<em>a</em>b*"},
+		{"a * not emphasis *", "a * not emphasis *"},
+		{"**unclosed", "**unclosed"},
+		{"~~gone~~", "<del>gone</del>"},
+		{"`code *x*` ``a`b``", "<code>code *x*</code> <code>a`b</code>"},
+		{`\*literal\*`, "*literal*"},
+		{"&amp; &copy; &#65; &bogus;", "&amp; © A &amp;bogus;"},
+		{"[x](https://e.com \"T\")", `<a href="https://e.com" title="T">x</a>`},
+		{"![i](/p.png)", `<img src="/p.png" alt="i">`},
+		{"[x](</a b>)", `<a href="/a%20b">x</a>`},
+		{"[*x*](/a)", `<a href="/a"><em>x</em></a>`},
+		{"[no link]", "[no link]"},
+		{"<https://a.b/c> <me@x.com>", `<a href="https://a.b/c">https://a.b/c</a> <a href="mailto:me@x.com">me@x.com</a>`},
+		{"see https://example.com/a_b.", `see <a href="https://example.com/a_b">https://example.com/a_b</a>.`},
+		{"(www.x.org)", `(<a href="http://www.x.org">www.x.org</a>)`},
+	}
+	for _, tt := range tests {
+		want := "<p>" + tt.want + "</p>\n"
+		if got := render(tt.in); got != want {
+			t.Errorf("Render(%q) = %q, want %q", tt.in, got, want)
+		}
+	}
+}
+
+func TestReferenceLinks(t *testing.T) {
+	got := render("[ref] and [Other][REF]\n\n[ref]: /url 'title'\n")
+	want := "<p><a href=\"/url\" title=\"title\">ref</a> and <a href=\"/url\" title=\"title\">Other</a></p>\n"
+	if got != want {
+		t.Errorf("reference links = %q, want %q", got, want)
+	}
+}
+
+func TestHeadings(t *testing.T) {
+	res := Render([]byte("# Intro\n\ntext\n\n## Set `up`\n"), Options{})
+	want := []Heading{{Level: 1, Text: "Intro", ID: "intro"}, {Level: 2, Text: "Set up", ID: "set-up"}}
+	if len(res.Headings) != len(want) {
+		t.Fatalf("headings = %+v, want %+v", res.Headings, want)
+	}
+	for i := range want {
+		if res.Headings[i] != want[i] {
+			t.Errorf("heading %d = %+v, want %+v", i, res.Headings[i], want[i])
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	opts := Options{Resolve: func(dest string, image bool) string {
+		if dest == "secret" {
+			return ""
+		}
+		if image {
+			return "/raw/" + dest
+		}
+		return "/view/" + dest
+	}}
+	got := Render([]byte("[a](docs/a.md) ![b](img.png) [c](secret) [d](https://x.org) [e](#top)"), opts).HTML
+	want := `<p><a href="/view/docs/a.md">a</a> <img src="/raw/img.png" alt="b"> c <a href="https://x.org">d</a> <a href="#top">e</a></p>` + "\n"
+	if got != want {
+		t.Errorf("Render with Resolve = %q, want %q", got, want)
+	}
+}
+
+// TestUnsafe renders documents that try to run script in the IDE's page
+// and checks that nothing of it survives.
+func TestUnsafe(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		// Link and image schemes.
+		{"[a](javascript:alert(1))", "<p>a</p>\n"},
+		{"[a](JaVaScRiPt:alert(1))", "<p>a</p>\n"},
+		{"[a](&#106;avascript:alert(1))", "<p>a</p>\n"},
+		{"[a](&#x6A;avascript&colon;alert(1))", "<p>a</p>\n"},
+		{"[a](<javascript:alert(1)>)", "<p>a</p>\n"},
+		{"[a](java\\script:alert(1))", "<p>a</p>\n"},
+		{"[a](data:text/html;base64,PHNjcmlwdD4=)", "<p>a</p>\n"},
+		{"[a](vbscript:msgbox)", "<p>a</p>\n"},
+		{"[r]\n\n[r]: javascript:alert(1)", "<p>r</p>\n"},
+		{"![i](javascript:alert(1))", "<p><img alt=\"i\"></p>\n"},
+		{"![i](DATA:image/png;base64,AAAA)", "<p><img alt=\"i\"></p>\n"},
+		// Autolinks.
+		{"<javascript:alert(1)>", "<p>javascript:alert(1)</p>\n"},
+		{"<JAVASCRIPT:alert(1)>", "<p>JAVASCRIPT:alert(1)</p>\n"},
+		{"<data:text/html,x>", "<p>data:text/html,x</p>\n"},
+		// Raw HTML.
+		{"<script>alert(1)</script>", "<p>alert(1)</p>\n"},
+		{"<SCRIPT SRC=//x.js></SCRIPT>", "<p></p>\n"},
+		{"x <script>alert(1)</script> y", "<p>x alert(1) y</p>\n"},
+		{"<scr<script>ipt>alert(1)</script>", "<p>&lt;scr" + "ipt&gt;alert(1)</p>\n"},
+		{"<iframe src=\"https://evil\"></iframe>", "<p></p>\n"},
+		{"<svg><script>alert(1)</script></svg>", "<p>alert(1)</p>\n"},
+		{"<object data=x></object><embed src=x>", "<p></p>\n"},
+		{"<style>body{}</style>", "<p>body{}</p>\n"},
+		{"<form action=x><input></form>", "<p></p>\n"},
+		// Attributes.
+		{"<img src=x onerror=alert(1)>", "<img src=\"x\">\n"},
+		{"<img src=\"x\" ONERROR=\"alert(1)\">", "<img src=\"x\">\n"},
+		{"<div onclick=\"alert(1)\" style=\"color:red\">d</div>", "<div>d</div>\n"},
+		{"<p onmouseover=alert(1)>p</p>", "<p>p</p>\n"},
+		{"x <b onclick=alert(1)>b</b>", "<p>x <b>b</b></p>\n"},
+		{"<a href=\"javascript:alert(1)\">x</a>", "<p><a>x</a></p>\n"},
+		{"<a href=\"JavaScript:alert(1)\">x</a>", "<p><a>x</a></p>\n"},
+		{"<a href=\"&#106;avascript:alert(1)\">x</a>", "<p><a>x</a></p>\n"},
+		{"<a href=\"java&#x09;script:alert(1)\">x</a>", "<p><a>x</a></p>\n"},
+		{"<a href=\"jav&Tab;ascript:alert(1)\">x</a>", "<p><a>x</a></p>\n"},
+		{"<a href=\" javascript:alert(1)\">x</a>", "<p><a>x</a></p>\n"},
+		{"<img src=\"data:image/png;base64,AAAA\">", "<img>\n"},
+		{"<a href=x title='\"><script>'>t</a>", "<p><a href=\"x\" title=\"&#34;&gt;&lt;script&gt;\">t</a></p>\n"},
+		{"<source srcset=\"javascript:alert(1) 1x, /ok.png 2x\">", "<source srcset=\"/ok.png 2x\">\n"},
+		// Markdown that ends up in attributes.
+		{"```\"><script>\nx\n```", "<pre><code class=\"language-&#34;&gt;&lt;script&gt;\">x\n</code></pre>\n"},
+		{"[x](/a \"\\\"><script>\")", "<p><a href=\"/a\" title=\"&#34;&gt;&lt;script&gt;\">x</a></p>\n"},
+		{"![\"><script>](/a.png)", "<p><img src=\"/a.png\" alt=\"&#34;&gt;\"></p>\n"},
+		{"# <script>alert(1)</script>", "<h1 id=\"alert1\">alert(1)</h1>\n"},
+	}
+	for _, tt := range tests {
+		if got := render(tt.in); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestUnsafeNoScript checks combinations of the constructs above for the
+// markup a browser would run.
+func TestUnsafeNoScript(t *testing.T) {
+	pieces := []string{
+		"<script>", "</script>", "<img src=x onerror=alert(1)>", "[a](javascript:x)", "<a href='javascript:x'>",
+		"<iframe>", "`", "*", "<!--", "-->", "\n", "\n\n", "> ", "- ", "```", "<", ">", "\"", "'", "&#106;",
+		"javascript:", "<svg onload=x>", "[", "](", ")", "<a href=\"", "\">", "onerror=", "<div ", "data:",
+	}
+	bad := regexp.MustCompile(`<(script|iframe|svg|object|embed|style)|<[a-z][^>]*\son[a-z]+\s*=|(href|src)="\s*(javascript|data):`)
+	for i := range pieces {
+		for j := range pieces {
+			for k := range pieces {
+				in := pieces[i] + pieces[j] + pieces[k]
+				out := strings.ToLower(render(in))
+				if m := bad.FindString(out); m != "" {
+					t.Fatalf("Render(%q) = %q, which has %s", in, out, m)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text exposition format.
+//
+// Metrics are registered on a Registry by the subsystems that update them.
+// A nil *Registry registers nothing and returns nil metrics, and updating
+// a nil metric does nothing, so subsystems need not check whether metrics
+// are collected.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry holds the metrics of a server.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+// NewRegistry returns a Registry with the process's goroutine count and
+// start time.
+func NewRegistry() *Registry {
+	r := &Registry{metrics: make(map[string]*metric)}
+	r.GaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	start := float64(time.Now().Unix())
+	r.GaugeFunc("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", func() float64 {
+		return start
+	})
+	return r
+}
+
+// Kinds of metric.
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// metric is a named metric and its series, one per combination of label
+// values.
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64      // of histograms
+	fn      func() float64 // of gauge functions
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64  // counters and gauges; the sum of histograms
+	counts []uint64 // per bucket, not cumulative, then +Inf
+	count  uint64
+}
+
+// register adds a metric, or returns the one of the same name, which must
+// be of the same kind and labels.
+func (r *Registry) register(m *metric) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, ok := r.metrics[m.name]; ok {
+		if prev.kind != m.kind || !slices.Equal(prev.labels, m.labels) {
+			panic("metrics: " + m.name + " registered twice differently")
+		}
+		return prev
+	}
+	m.series = make(map[string]*series)
+	r.metrics[m.name] = m
+	return m
+}
+
+// getLocked returns the series of values; m.mu must be held.
+func (m *metric) getLocked(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s := m.series[key]
+	if s == nil {
+		s = &series{values: slices.Clone(values)}
+		if m.kind == kindHistogram {
+			s.counts = make([]uint64, len(m.buckets)+1)
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+func (m *metric) add(delta float64, values []string) {
+	m.mu.Lock()
+	m.getLocked(values).value += delta
+	m.mu.Unlock()
+}
+
+// Counter is a value that only goes up, such as a count of requests.
+type Counter struct{ m *metric }
+
+// Counter registers a counter whose series are told apart by labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	if r == nil {
+		return nil
+	}
+	return &Counter{r.register(&metric{name: name, help: help, kind: kindCounter, labels: labels})}
+}
+
+// Inc adds 1 to the series of the label values.
+func (c *Counter) Inc(values ...string) { c.Add(1, values...) }
+
+// Add adds delta, which must not be negative, to the series of the label
+// values.
+func (c *Counter) Add(delta float64, values ...string) {
+	if c != nil && delta >= 0 {
+		c.m.add(delta, values)
+	}
+}
+
+// Gauge is a value that goes up and down, such as a count of connections.
+type Gauge struct{ m *metric }
+
+// Gauge registers a gauge whose series are told apart by labels.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	if r == nil {
+		return nil
+	}
+	return &Gauge{r.register(&metric{name: name, help: help, kind: kindGauge, labels: labels})}
+}
+
+// Inc adds 1 to the series of the label values.
+func (g *Gauge) Inc(values ...string) { g.Add(1, values...) }
+
+// Dec subtracts 1 from the series of the label values.
+func (g *Gauge) Dec(values ...string) { g.Add(-1, values...) }
+
+// Add adds delta to the series of the label values.
+func (g *Gauge) Add(delta float64, values ...string) {
+	if g != nil {
+		g.m.add(delta, values)
+	}
+}
+
+// Set sets the series of the label values to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	if g == nil {
+		return
+	}
+	g.m.mu.Lock()
+	g.m.getLocked(values).value = v
+	g.m.mu.Unlock()
+}
+
+// GaugeFunc registers a gauge without labels whose value fn returns when
+// the metrics are read, for values a subsystem already keeps, such as the
+// length of a queue. fn must be safe to call from any goroutine.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	if r != nil {
+		r.register(&metric{name: name, help: help, kind: kindGauge, fn: fn})
+	}
+}
+
+// Histogram counts observations, such as latencies, in buckets.
+type Histogram struct{ m *metric }
+
+// DefBuckets are buckets for latencies from 5ms to 10s.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram registers a histogram with buckets, the ascending upper
+// bounds of its buckets, whose series are told apart by labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if r == nil {
+		return nil
+	}
+	return &Histogram{r.register(&metric{name: name, help: help, kind: kindHistogram, labels: labels, buckets: buckets})}
+}
+
+// Observe records v in the series of the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	if h == nil {
+		return
+	}
+	m := h.m
+	i, _ := slices.BinarySearch(m.buckets, v)
+	m.mu.Lock()
+	s := m.getLocked(values)
+	s.counts[i]++
+	s.count++
+	s.value += v
+	m.mu.Unlock()
+}
+
+// Since records the seconds since start in the series of the label values.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format, ordered by
+// name and then label values.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	ms := make([]*metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		ms = append(ms, m)
+	}
+	r.mu.Unlock()
+	slices.SortFunc(ms, func(a, b *metric) int { return strings.Compare(a.name, b.name) })
+
+	var b strings.Builder
+	for _, m := range ms {
+		m.write(&b)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (m *metric) write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, escapeHelp(m.help), m.name, m.kind)
+	if m.fn != nil {
+		fmt.Fprintf(b, "%s %s\n", m.name, formatValue(m.fn()))
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		s := m.series[k]
+		if m.kind != kindHistogram {
+			fmt.Fprintf(b, "%s%s %s\n", m.name, m.labelSet(s.values, ""), formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, c := range s.counts {
+			cumulative += c
+			le := "+Inf"
+			if i < len(m.buckets) {
+				le = formatValue(m.buckets[i])
+			}
+			fmt.Fprintf(b, "%s_bucket%s %d\n", m.name, m.labelSet(s.values, le), cumulative)
+		}
+		fmt.Fprintf(b, "%s_sum%s %s\n", m.name, m.labelSet(s.values, ""), formatValue(s.value))
+		fmt.Fprintf(b, "%s_count%s %d\n", m.name, m.labelSet(s.values, ""), s.count)
+	}
+}
+
+// labelSet formats the labels of a series, with le for histogram buckets.
+func (m *metric) labelSet(values []string, le string) string {
+	if len(values) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range m.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", l, escapeLabel(values[i]))
+	}
+	if le != "" {
+		if len(m.labels) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "le=\"%s\"", le)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,300 @@
+// Package modproxy is a caching Go module proxy. Sandboxes use it as their
+// GOPROXY, so a module downloaded for one build is served from disk to
+// every later one instead of being fetched from the upstream proxy again.
+//
+// Versioned files (.info, .mod and .zip) never change and are kept until
+// the cache is full. Version lists and @latest queries are refreshed after
+// Config.ListTTL, and served stale while the upstream proxy cannot be
+// reached. Checksum database requests (/sumdb/...) are passed through the
+// same way, so the go command verifies modules without a direct connection
+// to sum.golang.org.
+package modproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures a Proxy.
+type Config struct {
+	// Upstream is the proxy modules are fetched from; defaults to
+	// https://proxy.golang.org.
+	Upstream string
+	// Dir holds the cache; defaults to a directory under the OS temp dir.
+	Dir string
+	// ListTTL is how long version lists and @latest answers are reused;
+	// defaults to ten minutes.
+	ListTTL time.Duration
+	// MaxBytes caps the size of the cache; defaults to 10 GiB. The files
+	// used least recently are evicted first.
+	MaxBytes int64
+	// Timeout bounds one upstream request, download included; defaults to
+	// five minutes.
+	Timeout time.Duration
+}
+
+// maxFileBytes bounds one file, above the go command's 500 MiB limit on
+// module zips.
+const maxFileBytes = 512 << 20
+
+// errNotFound is returned for files the upstream proxy does not have.
+var errNotFound = errors.New("modproxy: not found")
+
+// Proxy serves the GOPROXY protocol from its cache.
+type Proxy struct {
+	cfg  Config
+	http *http.Client
+	now  func() time.Time
+
+	mu       sync.Mutex
+	inflight map[string]*fetch
+	size     int64
+	measured bool
+}
+
+// fetch is a download in progress; concurrent requests for the same file
+// wait for it instead of starting their own.
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// New returns a Proxy, filling unset Config fields with defaults.
+func New(cfg Config) (*Proxy, error) {
+	if cfg.Upstream == "" {
+		cfg.Upstream = "https://proxy.golang.org"
+	}
+	cfg.Upstream = strings.TrimSuffix(cfg.Upstream, "/")
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-modproxy")
+	}
+	if cfg.ListTTL <= 0 {
+		cfg.ListTTL = 10 * time.Minute
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 10 << 30
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("modproxy: create dir: %w", err)
+	}
+	return &Proxy{
+		cfg:      cfg,
+		http:     &http.Client{Timeout: cfg.Timeout},
+		now:      time.Now,
+		inflight: make(map[string]*fetch),
+	}, nil
+}
+
+// Register mounts the proxy at /goproxy/ on mux, so GOPROXY is the
+// server's URL followed by /goproxy.
+func (p *Proxy) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /goproxy/{path...}", p.serve)
+}
+
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("path")
+	if !validPath(name) {
+		http.Error(w, "invalid path", http.StatusNotFound)
+		return
+	}
+	file, err := p.get(r.Context(), name)
+	switch {
+	case errors.Is(err, errNotFound):
+		// The go command takes 404 and 410 as "not here" and moves on.
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	case err != nil:
+		slog.Error("module proxy", "path", name, "err", err)
+		http.Error(w, "upstream proxy failed", http.StatusBadGateway)
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		// Evicted between the download and now.
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", contentType(name))
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+// validPath accepts the paths of the GOPROXY protocol: escaped module
+// paths with @v/ or @latest, and checksum database paths.
+func validPath(name string) bool {
+	if name == "" || strings.ContainsFunc(name, func(c rune) bool {
+		return !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.ContainsRune("-._~!+@/", c))
+	}) {
+		return false
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return strings.HasPrefix(name, "sumdb/") || strings.Contains(name, "/@v/") || strings.HasSuffix(name, "/@latest")
+}
+
+// mutable reports whether the answer to name can change over time.
+func mutable(name string) bool {
+	return strings.HasSuffix(name, "/@v/list") || strings.HasSuffix(name, "/@latest") ||
+		strings.HasSuffix(name, "/latest") || strings.HasSuffix(name, "/supported")
+}
+
+func contentType(name string) string {
+	switch path.Ext(name) {
+	case ".info":
+		return "application/json"
+	case ".zip":
+		return "application/zip"
+	}
+	if strings.HasSuffix(name, "/@latest") {
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// get returns the cached file for name, downloading it first when it is
+// missing or, for mutable answers, older than ListTTL.
+func (p *Proxy) get(ctx context.Context, name string) (string, error) {
+	file := filepath.Join(p.cfg.Dir, filepath.FromSlash(name))
+	fi, err := os.Stat(file)
+	fresh := err == nil && (!mutable(name) || p.now().Sub(fi.ModTime()) < p.cfg.ListTTL)
+	if fresh {
+		if !mutable(name) {
+			// The modification time of immutable files orders them for
+			// eviction.
+			now := p.now()
+			os.Chtimes(file, now, now)
+		}
+		return file, nil
+	}
+
+	p.mu.Lock()
+	f, ok := p.inflight[name]
+	if !ok {
+		f = &fetch{done: make(chan struct{})}
+		p.inflight[name] = f
+		go func() {
+			f.err = p.download(name, file)
+			p.mu.Lock()
+			delete(p.inflight, name)
+			p.mu.Unlock()
+			close(f.done)
+		}()
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if f.err != nil && err == nil && !errors.Is(f.err, errNotFound) {
+		slog.Warn("module proxy serving stale answer", "path", name, "err", f.err)
+		return file, nil
+	}
+	return file, f.err
+}
+
+// download fetches name from upstream into file. It runs detached from
+// the request, so a client that gives up does not waste the download for
+// those that wait for it.
+func (p *Proxy) download(name, file string) error {
+	resp, err := p.http.Get(p.cfg.Upstream + "/" + name)
+	if err != nil {
+		return fmt.Errorf("modproxy: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("modproxy: upstream answered %s", resp.Status)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("modproxy: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".download-*")
+	if err != nil {
+		return fmt.Errorf("modproxy: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, io.LimitReader(resp.Body, maxFileBytes+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("modproxy: download %s: %w", name, err)
+	}
+	if n > maxFileBytes {
+		return fmt.Errorf("modproxy: %s is larger than %d bytes", name, maxFileBytes)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("modproxy: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("modproxy: %w", err)
+	}
+	p.added(n)
+	return nil
+}
+
+// added counts n more bytes in the cache and evicts files once it is
+// over MaxBytes.
+func (p *Proxy) added(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.size += n
+	if p.measured && p.size <= p.cfg.MaxBytes {
+		return
+	}
+	type entry struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var files []entry
+	var total int64
+	filepath.WalkDir(p.cfg.Dir, func(name string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if fi, err := d.Info(); err == nil {
+			files = append(files, entry{name, fi.Size(), fi.ModTime()})
+			total += fi.Size()
+		}
+		return nil
+	})
+	slices.SortFunc(files, func(a, b entry) int { return a.used.Compare(b.used) })
+	// Evict down to nine tenths, so the next downloads do not walk the
+	// cache again right away.
+	for _, f := range files {
+		if total <= p.cfg.MaxBytes/10*9 {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+	p.size, p.measured = total, true
+}
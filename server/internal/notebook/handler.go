@@ -0,0 +1,361 @@
+package notebook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/repl"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// maxBytes caps the size of a notebook, outputs included.
+const maxBytes = 32 << 20
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves notebook documents and their kernels.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler running notebooks with svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the notebook routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/notebooks/{path...}", h.get)
+	mux.HandleFunc("PUT /api/workspaces/{id}/notebooks/{path...}", h.put)
+	mux.HandleFunc("POST /api/workspaces/{id}/notebooks/{path...}", h.run)
+	mux.HandleFunc("GET /api/workspaces/{id}/kernels", h.list)
+	mux.HandleFunc("GET /api/workspaces/{id}/kernels/{path...}", h.status)
+	mux.HandleFunc("POST /api/workspaces/{id}/kernels/{path...}", h.control)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/kernels/{path...}", h.shutdown)
+}
+
+func (h *Handler) open(w http.ResponseWriter, r *http.Request) (*files.FS, string, string, bool) {
+	dir, err := h.workspaces.Open(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return nil, "", "", false
+	}
+	p, err := files.Clean(r.PathValue("path"))
+	if err != nil || p == "" {
+		httpx.Error(w, http.StatusBadRequest, "invalid path")
+		return nil, "", "", false
+	}
+	f, err := files.New(dir)
+	if err != nil {
+		writeError(w, err)
+		return nil, "", "", false
+	}
+	return f, dir, p, true
+}
+
+// load reads the notebook at p along with the ETag of the file.
+func load(f *files.FS, p string) (*Notebook, string, error) {
+	file, e, err := f.Open(p)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxBytes {
+		return nil, "", errTooLarge
+	}
+	nb, err := Parse(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return nb, e.ETag(), nil
+}
+
+var errTooLarge = errors.New("notebook: notebook is too large")
+
+// save writes nb to p and announces it as a file save.
+func save(ctx context.Context, workspaceID string, f *files.FS, p string, nb *Notebook, created bool) (files.Entry, error) {
+	data, err := nb.Marshal()
+	if err != nil {
+		return files.Entry{}, err
+	}
+	e, err := f.WriteFile(p, data)
+	if err != nil {
+		return files.Entry{}, err
+	}
+	events.Publish(ctx, events.Event{Type: events.FileSaved, Workspace: workspaceID, Data: map[string]any{
+		"path":    e.Path,
+		"size":    e.Size,
+		"created": created,
+	}})
+	return e, nil
+}
+
+// get returns the notebook at the path, normalized as it would be saved.
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	f, _, p, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	nb, tag, err := load(f, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", tag)
+	httpx.JSON(w, http.StatusOK, nb)
+}
+
+// put saves a notebook. Like file saves, If-Match and If-None-Match: *
+// guard against lost updates.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	f, _, p, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	var nb Notebook
+	if err := httpx.DecodeJSON(w, r, &nb, maxBytes); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := nb.Validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+	nb.normalize()
+	cur, err := f.Target(p)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		writeError(w, err)
+		return
+	}
+	if m := r.Header.Get("If-Match"); m != "" && (!exists || m != cur.ETag()) {
+		httpx.Error(w, http.StatusPreconditionFailed, "notebook changed since it was read")
+		return
+	}
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		httpx.Error(w, http.StatusPreconditionFailed, "notebook already exists")
+		return
+	}
+	e, err := save(r.Context(), r.PathValue("id"), f, p, &nb, !exists)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+	}
+	w.Header().Set("ETag", e.ETag())
+	httpx.JSON(w, status, &nb)
+}
+
+type runRequest struct {
+	// Notebook, if set, is saved before running, so unsaved edits run.
+	Notebook *Notebook `json:"notebook"`
+	// Cells are the IDs of the cells to run; all code cells when empty.
+	Cells []string `json:"cells"`
+	// Restart starts the kernel afresh, discarding its state.
+	Restart bool `json:"restart"`
+}
+
+// run executes cells and saves their outputs. The cells run against the
+// notebook as it was when the request arrived; their outputs are merged
+// by cell ID into the file as it is when they finish, so edits saved in
+// the meantime are kept.
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	f, dir, p, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	var req runRequest
+	if err := httpx.DecodeJSON(w, r, &req, maxBytes); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	nb := req.Notebook
+	if nb != nil {
+		if err := nb.Validate(); err != nil {
+			writeError(w, err)
+			return
+		}
+		nb.normalize()
+		_, err := f.Stat(p)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			writeError(w, err)
+			return
+		}
+		if _, err := save(r.Context(), id, f, p, nb, err != nil); err != nil {
+			writeError(w, err)
+			return
+		}
+	} else {
+		var err error
+		if nb, _, err = load(f, p); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	for _, c := range req.Cells {
+		if _, ok := nb.Cell(c); !ok {
+			httpx.Errorf(w, http.StatusBadRequest, "no cell %q", c)
+			return
+		}
+	}
+
+	if err := h.svc.Run(r.Context(), id, dir, p, nb, req.Cells, req.Restart); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Save under a context that outlives a client that gave up waiting,
+	// so the outputs are not lost.
+	ctx := context.WithoutCancel(r.Context())
+	latest, _, err := load(f, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, c := range nb.Cells {
+		if c.Type != CellCode || len(req.Cells) > 0 && !slices.Contains(req.Cells, c.ID) {
+			continue
+		}
+		if lc, ok := latest.Cell(c.ID); ok && lc.Type == CellCode {
+			lc.ExecutionCount, lc.Outputs = c.ExecutionCount, c.Outputs
+		}
+	}
+	e, err := save(ctx, id, f, p, latest, false)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", e.ETag())
+	httpx.JSON(w, http.StatusOK, latest)
+}
+
+func (h *Handler) kernelPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if _, err := h.workspaces.Open(r.PathValue("id")); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	p, err := files.Clean(r.PathValue("path"))
+	if err != nil || p == "" {
+		httpx.Error(w, http.StatusBadRequest, "invalid path")
+		return "", false
+	}
+	return p, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.workspaces.Open(r.PathValue("id")); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, h.svc.Kernels(r.PathValue("id")))
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.kernelPath(w, r)
+	if !ok {
+		return
+	}
+	st, err := h.svc.Status(r.PathValue("id"), p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, st)
+}
+
+type controlRequest struct {
+	Action string `json:"action"`
+}
+
+// control interrupts the running cell, or restarts the kernel so the next
+// run starts from a clean state.
+func (h *Handler) control(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.kernelPath(w, r)
+	if !ok {
+		return
+	}
+	var req controlRequest
+	if err := httpx.DecodeJSON(w, r, &req, 1<<10); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var err error
+	switch req.Action {
+	case "interrupt":
+		err = h.svc.Interrupt(r.PathValue("id"), p)
+	case "restart":
+		err = h.svc.Shutdown(r.PathValue("id"), p)
+		if errors.Is(err, ErrNoKernel) {
+			err = nil
+		}
+	default:
+		httpx.Errorf(w, http.StatusBadRequest, "unknown action %q", req.Action)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) shutdown(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.kernelPath(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Shutdown(r.PathValue("id"), p); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var syntax *json.SyntaxError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		httpx.Error(w, http.StatusNotFound, "notebook not found")
+	case errors.Is(err, ErrNoKernel):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalid), errors.As(err, &syntax),
+		errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, errTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrBusy):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, repl.ErrTooManySessions):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, context.Canceled):
+		// The client went away.
+	case errors.Is(err, ErrKernel):
+		httpx.Error(w, http.StatusBadGateway, strings.TrimSpace(err.Error()))
+	case errors.Is(err, ErrClosed), errors.Is(err, repl.ErrClosed):
+		httpx.Error(w, http.StatusServiceUnavailable, "server is shutting down")
+	default:
+		slog.Error("notebook", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "notebook request failed")
+	}
+}
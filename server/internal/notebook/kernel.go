@@ -0,0 +1,457 @@
+package notebook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/repl"
+)
+
+var (
+	// ErrNoKernel is returned for notebooks without a running kernel.
+	ErrNoKernel = errors.New("notebook: no kernel running")
+	// ErrBusy is returned when cells are run while the kernel is running
+	// others.
+	ErrBusy = errors.New("notebook: kernel is busy")
+	// ErrKernel is returned when a kernel fails to start.
+	ErrKernel = errors.New("notebook: kernel failed to start")
+	// ErrClosed is returned after the Service has been closed.
+	ErrClosed = errors.New("notebook: service closed")
+)
+
+// Config configures a Service.
+type Config struct {
+	// StartTimeout bounds the wait for a new kernel, including the first
+	// build of the interpreter; defaults to 5 minutes.
+	StartTimeout time.Duration
+	// CellTimeout bounds one cell, after which it is interrupted; defaults
+	// to 5 minutes.
+	CellTimeout time.Duration
+	// IdleTimeout shuts down kernels that have not run a cell for this
+	// long; defaults to 30 minutes.
+	IdleTimeout time.Duration
+	// MaxOutputBytes caps the output kept for one cell; defaults to 4 MiB.
+	MaxOutputBytes int
+}
+
+// Service runs notebook kernels on top of REPL sessions.
+type Service struct {
+	cfg   Config
+	repls *repl.Service
+
+	mu      sync.Mutex
+	kernels map[kernelKey]*Kernel
+	closed  bool
+}
+
+type kernelKey struct {
+	workspace, path string
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, repls *repl.Service) *Service {
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = 5 * time.Minute
+	}
+	if cfg.CellTimeout <= 0 {
+		cfg.CellTimeout = 5 * time.Minute
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 30 * time.Minute
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 4 << 20
+	}
+	return &Service{cfg: cfg, repls: repls, kernels: make(map[kernelKey]*Kernel)}
+}
+
+// Kernel is the interpreter of one notebook.
+type Kernel struct {
+	svc *Service
+	key kernelKey
+
+	ready chan struct{} // closed once started, or failed to
+	err   error
+	sess  *repl.Session
+	msgs  chan []byte // interpreter messages; closed when it exits
+
+	run    sync.Mutex // held while cells run
+	mu     sync.Mutex
+	count  int
+	evalID int64
+	cell   string
+	since  time.Time
+	used   time.Time
+	idle   *time.Timer
+}
+
+// Status describes a running kernel.
+type Status struct {
+	Path           string    `json:"path"`
+	StartedAt      time.Time `json:"startedAt"`
+	LastRunAt      time.Time `json:"lastRunAt"`
+	ExecutionCount int       `json:"executionCount"`
+	// Running is the ID of the cell being executed.
+	Running string `json:"running,omitempty"`
+}
+
+func (k *Kernel) status() Status {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return Status{Path: k.key.path, StartedAt: k.since, LastRunAt: k.used, ExecutionCount: k.count, Running: k.cell}
+}
+
+// Kernels lists the workspace's running kernels by notebook path.
+func (s *Service) Kernels(workspaceID string) []Status {
+	s.mu.Lock()
+	var ks []*Kernel
+	for key, k := range s.kernels {
+		if key.workspace == workspaceID {
+			ks = append(ks, k)
+		}
+	}
+	s.mu.Unlock()
+	out := []Status{}
+	for _, k := range ks {
+		if k.started() {
+			out = append(out, k.status())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// Status reports the kernel of the notebook at path.
+func (s *Service) Status(workspaceID, path string) (Status, error) {
+	k := s.lookup(workspaceID, path)
+	if k == nil || !k.started() {
+		return Status{}, ErrNoKernel
+	}
+	return k.status(), nil
+}
+
+// Interrupt cancels the cell the notebook's kernel is running.
+func (s *Service) Interrupt(workspaceID, path string) error {
+	k := s.lookup(workspaceID, path)
+	if k == nil || !k.started() {
+		return ErrNoKernel
+	}
+	return k.sess.Send(repl.Request{Type: "interrupt"})
+}
+
+// Shutdown stops the notebook's kernel, discarding its state.
+func (s *Service) Shutdown(workspaceID, path string) error {
+	k := s.lookup(workspaceID, path)
+	if k == nil {
+		return ErrNoKernel
+	}
+	k.shutdown()
+	return nil
+}
+
+// Close stops every kernel.
+func (s *Service) Close() {
+	s.mu.Lock()
+	s.closed = true
+	ks := make([]*Kernel, 0, len(s.kernels))
+	for _, k := range s.kernels {
+		ks = append(ks, k)
+	}
+	s.mu.Unlock()
+	for _, k := range ks {
+		k.shutdown()
+	}
+}
+
+func (s *Service) lookup(workspaceID, path string) *Kernel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.kernels[kernelKey{workspaceID, path}]
+}
+
+// kernel returns the notebook's kernel, starting one if needed.
+func (s *Service) kernel(ctx context.Context, workspaceID, dir, path string) (*Kernel, error) {
+	key := kernelKey{workspaceID, path}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	k, ok := s.kernels[key]
+	if !ok {
+		k = &Kernel{svc: s, key: key, ready: make(chan struct{})}
+		s.kernels[key] = k
+		go k.start(dir)
+	}
+	s.mu.Unlock()
+	select {
+	case <-k.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if k.err != nil {
+		return nil, k.err
+	}
+	return k, nil
+}
+
+// start launches the interpreter and waits for it to report ready. A
+// failed kernel is forgotten so the next run tries again.
+func (k *Kernel) start(dir string) {
+	defer close(k.ready)
+	fail := func(err error) {
+		k.err = err
+		k.svc.forget(k)
+	}
+	sess, err := k.svc.repls.Start(context.Background(), k.key.workspace, dir)
+	if err != nil {
+		fail(err)
+		return
+	}
+	k.sess = sess
+	k.msgs = make(chan []byte, 64)
+	go func() {
+		defer close(k.msgs)
+		for {
+			msg, err := sess.Recv()
+			if err != nil {
+				return
+			}
+			k.msgs <- msg
+		}
+	}()
+	timer := time.NewTimer(k.svc.cfg.StartTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case msg, ok := <-k.msgs:
+			if !ok {
+				sess.Close()
+				fail(fmt.Errorf("%w: %s", ErrKernel, strings.TrimSpace(sess.Output())))
+				return
+			}
+			var m message
+			if json.Unmarshal(msg, &m) == nil && m.Type == "ready" {
+				now := time.Now()
+				k.mu.Lock()
+				k.since, k.used = now, now
+				k.idle = time.AfterFunc(k.svc.cfg.IdleTimeout, k.shutdown)
+				k.mu.Unlock()
+				return
+			}
+		case <-timer.C:
+			sess.Close()
+			fail(fmt.Errorf("%w: timed out", ErrKernel))
+			return
+		}
+	}
+}
+
+func (k *Kernel) started() bool {
+	select {
+	case <-k.ready:
+		return k.err == nil
+	default:
+		return false
+	}
+}
+
+func (s *Service) forget(k *Kernel) {
+	s.mu.Lock()
+	if s.kernels[k.key] == k {
+		delete(s.kernels, k.key)
+	}
+	s.mu.Unlock()
+}
+
+func (k *Kernel) shutdown() {
+	k.svc.forget(k)
+	<-k.ready
+	if k.err != nil {
+		return
+	}
+	k.mu.Lock()
+	k.idle.Stop()
+	k.mu.Unlock()
+	k.sess.Close()
+}
+
+// message is an interpreter message; see repl.Request for the protocol.
+type message struct {
+	Type  string `json:"type"`
+	ID    int64  `json:"id"`
+	Data  string `json:"data"`
+	Mime  string `json:"mime"`
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+// Run executes code cells of nb in its kernel, in document order,
+// replacing their outputs and execution counts. ids selects the cells to
+// run; when empty, every code cell runs. As in Jupyter, a cell that fails
+// stops the run. With restart, the kernel is started afresh first.
+func (s *Service) Run(ctx context.Context, workspaceID, dir, path string, nb *Notebook, ids []string, restart bool) error {
+	if restart {
+		s.Shutdown(workspaceID, path)
+	}
+	k, err := s.kernel(ctx, workspaceID, dir, path)
+	if err != nil {
+		return err
+	}
+	if !k.run.TryLock() {
+		return ErrBusy
+	}
+	defer k.run.Unlock()
+	for i := range nb.Cells {
+		c := &nb.Cells[i]
+		if c.Type != CellCode || len(ids) > 0 && !slices.Contains(ids, c.ID) {
+			continue
+		}
+		if !k.execute(ctx, c) || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// execute runs one cell and reports whether it succeeded.
+func (k *Kernel) execute(ctx context.Context, c *Cell) bool {
+	k.mu.Lock()
+	k.count++
+	k.evalID++
+	n, id := k.count, k.evalID
+	k.cell = c.ID
+	k.idle.Stop()
+	k.mu.Unlock()
+	defer func() {
+		k.mu.Lock()
+		k.cell, k.used = "", time.Now()
+		k.idle.Reset(k.svc.cfg.IdleTimeout)
+		k.mu.Unlock()
+	}()
+
+	out := &collector{max: k.svc.cfg.MaxOutputBytes}
+	c.ExecutionCount = &n
+	defer func() { c.Outputs = out.outputs }()
+	if err := k.sess.Send(repl.Request{Type: "eval", ID: id, Code: string(c.Source)}); err != nil {
+		out.fail("KernelError", err.Error())
+		return false
+	}
+	timeout := time.NewTimer(k.svc.cfg.CellTimeout)
+	defer timeout.Stop()
+	var deadline <-chan time.Time
+	interrupt := func(reason string) {
+		if deadline == nil {
+			k.sess.Send(repl.Request{Type: "interrupt"})
+			out.stream("stderr", reason+"\n")
+			deadline = time.After(5 * time.Second)
+		}
+	}
+	done := ctx.Done()
+	for {
+		select {
+		case msg, ok := <-k.msgs:
+			if !ok {
+				out.fail("KernelDied", "the kernel exited; its state is lost")
+				k.shutdown()
+				return false
+			}
+			var m message
+			if json.Unmarshal(msg, &m) != nil {
+				continue
+			}
+			switch m.Type {
+			case "stdout", "stderr":
+				out.stream(m.Type, m.Data)
+			case "display":
+				out.display(m.Mime, m.Data)
+			case "result":
+				if m.ID != id {
+					continue
+				}
+				if m.Error != "" {
+					out.fail("Error", m.Error)
+					return false
+				}
+				if m.Value != "" {
+					out.result(n, m.Value)
+				}
+				return deadline == nil
+			}
+		case <-timeout.C:
+			interrupt(fmt.Sprintf("cell interrupted after %s", k.svc.cfg.CellTimeout))
+		case <-done:
+			done = nil
+			interrupt("cell interrupted: the request was canceled")
+		case <-deadline:
+			// The program ignores cancellation, such as in a busy loop.
+			out.fail("KernelRestarted", "the cell did not stop when interrupted, so its kernel was shut down")
+			k.shutdown()
+			return false
+		}
+	}
+}
+
+// collector gathers a cell's outputs, merging consecutive writes to the
+// same stream, up to max bytes.
+type collector struct {
+	max       int
+	size      int
+	truncated bool
+	outputs   []Output
+}
+
+func (o *collector) fits(n int) bool {
+	if o.size+n > o.max {
+		if !o.truncated {
+			o.truncated = true
+			o.outputs = append(o.outputs, Output{Type: OutputStream, Name: "stderr", Text: "[output truncated]\n"})
+		}
+		return false
+	}
+	o.size += n
+	return true
+}
+
+func (o *collector) stream(name, text string) {
+	if !o.fits(len(text)) {
+		return
+	}
+	if n := len(o.outputs); n > 0 && o.outputs[n-1].Type == OutputStream && o.outputs[n-1].Name == name {
+		o.outputs[n-1].Text += Text(text)
+		return
+	}
+	o.outputs = append(o.outputs, Output{Type: OutputStream, Name: name, Text: Text(text)})
+}
+
+func (o *collector) display(mime, data string) {
+	if mime == "" || !o.fits(len(data)) {
+		return
+	}
+	var v any = data
+	if mime == "application/json" {
+		var j any
+		if json.Unmarshal([]byte(data), &j) == nil {
+			v = j
+		}
+	}
+	o.outputs = append(o.outputs, Output{Type: OutputDisplay, Data: map[string]any{mime: v}, Metadata: map[string]any{}})
+}
+
+func (o *collector) result(n int, value string) {
+	if !o.fits(len(value)) {
+		return
+	}
+	o.outputs = append(o.outputs, Output{Type: OutputResult, ExecutionCount: &n, Data: map[string]any{"text/plain": value}, Metadata: map[string]any{}})
+}
+
+func (o *collector) fail(name, msg string) {
+	o.outputs = append(o.outputs, Output{Type: OutputError, EName: name, EValue: msg, Traceback: []string{msg}})
+}
@@ -0,0 +1,257 @@
+// Package notebook implements Go notebooks: Jupyter's .ipynb documents
+// whose code cells run in order in a persistent yaegi kernel, one per open
+// notebook, so declarations carry over from cell to cell. Cell output is
+// captured as Jupyter outputs, including images and HTML shown with the
+// interpreter's "webide/display" package, and saved back into the file.
+package notebook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalid is returned for documents that are not version 4 notebooks.
+var ErrInvalid = errors.New("notebook: invalid notebook")
+
+// Cell types.
+const (
+	CellCode     = "code"
+	CellMarkdown = "markdown"
+	CellRaw      = "raw"
+)
+
+// Output types.
+const (
+	OutputStream  = "stream"
+	OutputDisplay = "display_data"
+	OutputResult  = "execute_result"
+	OutputError   = "error"
+)
+
+// Text is a multiline string. Jupyter writes these as a string or as a
+// list of lines; they are read either way and written as a string.
+type Text string
+
+func (t *Text) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = Text(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(b, &lines); err != nil {
+		return err
+	}
+	*t = Text(strings.Join(lines, ""))
+	return nil
+}
+
+// Notebook is an nbformat 4 document.
+type Notebook struct {
+	Cells         []Cell         `json:"cells"`
+	Metadata      map[string]any `json:"metadata"`
+	NBFormat      int            `json:"nbformat"`
+	NBFormatMinor int            `json:"nbformat_minor"`
+}
+
+// Cell is a code, Markdown or raw cell. Only code cells have an
+// execution count and outputs.
+type Cell struct {
+	ID             string         `json:"id"`
+	Type           string         `json:"cell_type"`
+	Source         Text           `json:"source"`
+	Metadata       map[string]any `json:"metadata"`
+	ExecutionCount *int           `json:"execution_count"`
+	Outputs        []Output       `json:"outputs"`
+	Attachments    map[string]any `json:"attachments,omitempty"`
+}
+
+type codeCell struct {
+	ID             string         `json:"id"`
+	Type           string         `json:"cell_type"`
+	Source         Text           `json:"source"`
+	Metadata       map[string]any `json:"metadata"`
+	ExecutionCount *int           `json:"execution_count"`
+	Outputs        []Output       `json:"outputs"`
+}
+
+type textCell struct {
+	ID          string         `json:"id"`
+	Type        string         `json:"cell_type"`
+	Source      Text           `json:"source"`
+	Metadata    map[string]any `json:"metadata"`
+	Attachments map[string]any `json:"attachments,omitempty"`
+}
+
+func (c Cell) MarshalJSON() ([]byte, error) {
+	if c.Type == CellCode {
+		outputs := c.Outputs
+		if outputs == nil {
+			outputs = []Output{}
+		}
+		return json.Marshal(codeCell{c.ID, c.Type, c.Source, c.Metadata, c.ExecutionCount, outputs})
+	}
+	return json.Marshal(textCell{c.ID, c.Type, c.Source, c.Metadata, c.Attachments})
+}
+
+// Output is one output of a code cell: a stream of text, display data or
+// an execution result keyed by MIME type, or an error.
+type Output struct {
+	Type           string         `json:"output_type"`
+	Name           string         `json:"name,omitempty"`
+	Text           Text           `json:"text,omitempty"`
+	Data           map[string]any `json:"data,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+	ExecutionCount *int           `json:"execution_count,omitempty"`
+	EName          string         `json:"ename,omitempty"`
+	EValue         string         `json:"evalue,omitempty"`
+	Traceback      []string       `json:"traceback,omitempty"`
+}
+
+type richOutput struct {
+	Type           string         `json:"output_type"`
+	Data           map[string]any `json:"data"`
+	Metadata       map[string]any `json:"metadata"`
+	ExecutionCount *int           `json:"execution_count,omitempty"`
+}
+
+// MarshalJSON writes display data and results with their bundles even when
+// empty, as nbformat requires.
+func (o Output) MarshalJSON() ([]byte, error) {
+	type output Output
+	if o.Type != OutputDisplay && o.Type != OutputResult {
+		return json.Marshal(output(o))
+	}
+	r := richOutput{o.Type, o.Data, o.Metadata, o.ExecutionCount}
+	if r.Data == nil {
+		r.Data = map[string]any{}
+	}
+	if r.Metadata == nil {
+		r.Metadata = map[string]any{}
+	}
+	return json.Marshal(r)
+}
+
+// New returns an empty notebook with a single code cell.
+func New() *Notebook {
+	nb := &Notebook{Cells: []Cell{{Type: CellCode}}}
+	nb.normalize()
+	return nb
+}
+
+// Parse reads a notebook, giving cells without one an ID.
+func Parse(data []byte) (*Notebook, error) {
+	var nb Notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := nb.Validate(); err != nil {
+		return nil, err
+	}
+	nb.normalize()
+	return &nb, nil
+}
+
+// Validate checks the notebook's version and cell types.
+func (nb *Notebook) Validate() error {
+	if nb.NBFormat != 4 {
+		return fmt.Errorf("%w: nbformat %d is not supported, only 4", ErrInvalid, nb.NBFormat)
+	}
+	for i, c := range nb.Cells {
+		switch c.Type {
+		case CellCode, CellMarkdown, CellRaw:
+		default:
+			return fmt.Errorf("%w: cell %d has type %q", ErrInvalid, i, c.Type)
+		}
+	}
+	return nil
+}
+
+var cellID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// normalize fills in what nbformat 4.5 requires: unique cell IDs,
+// metadata maps, and a kernelspec naming Go for notebooks that have none.
+func (nb *Notebook) normalize() {
+	nb.NBFormat = 4
+	nb.NBFormatMinor = max(nb.NBFormatMinor, 5)
+	if nb.Cells == nil {
+		nb.Cells = []Cell{}
+	}
+	if nb.Metadata == nil {
+		nb.Metadata = map[string]any{}
+	}
+	if _, ok := nb.Metadata["kernelspec"]; !ok {
+		nb.Metadata["kernelspec"] = map[string]any{"name": "webide-go", "display_name": "Go", "language": "go"}
+		nb.Metadata["language_info"] = map[string]any{"name": "go", "file_extension": ".go", "mimetype": "text/x-go"}
+	}
+	seen := make(map[string]bool)
+	for i := range nb.Cells {
+		c := &nb.Cells[i]
+		for !cellID.MatchString(c.ID) || seen[c.ID] {
+			c.ID = newID()
+		}
+		seen[c.ID] = true
+		if c.Metadata == nil {
+			c.Metadata = map[string]any{}
+		}
+		if c.Type != CellCode {
+			c.ExecutionCount, c.Outputs = nil, nil
+		}
+		for j := range c.Outputs {
+			joinData(c.Outputs[j].Data)
+		}
+	}
+}
+
+// joinData turns MIME bundle values written as lists of lines into
+// strings.
+func joinData(data map[string]any) {
+	for mime, v := range data {
+		lines, ok := v.([]any)
+		if !ok {
+			continue
+		}
+		var b strings.Builder
+		for _, l := range lines {
+			s, ok := l.(string)
+			if !ok {
+				b.Reset()
+				break
+			}
+			b.WriteString(s)
+		}
+		if b.Len() > 0 {
+			data[mime] = b.String()
+		}
+	}
+}
+
+// Marshal encodes the notebook as Jupyter does, indented by one space.
+func (nb *Notebook) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(nb, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Cell returns the cell with the given ID.
+func (nb *Notebook) Cell(id string) (*Cell, bool) {
+	for i := range nb.Cells {
+		if nb.Cells[i].ID == id {
+			return &nb.Cells[i], true
+		}
+	}
+	return nil, false
+}
+
+func newID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
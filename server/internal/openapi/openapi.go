@@ -0,0 +1,44 @@
+// Package openapi serves the OpenAPI 3 definition of the server's HTTP
+// API at /api/openapi.json. The definition is generated from the handlers
+// by cmd/webide-openapi, along with the Go client of pkg/client, and
+// embedded in the binary; go generate rewrites both after the routes
+// change.
+package openapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+//go:generate go run ../../cmd/webide-openapi
+
+//go:embed openapi.json
+var spec []byte
+
+// Handler serves the definition.
+type Handler struct {
+	etag string
+}
+
+// NewHandler returns a Handler.
+func NewHandler() *Handler {
+	sum := sha256.Sum256(spec)
+	return &Handler{etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+}
+
+// Register mounts the definition's route on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/openapi.json", h.serve)
+}
+
+// serve returns the OpenAPI definition of the API.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", h.etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(spec))
+}
@@ -0,0 +1,377 @@
+package org
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Users finds accounts to add to organizations.
+type Users interface {
+	UserByEmail(email string) (*auth.User, error)
+}
+
+// Handler serves the organization routes.
+type Handler struct {
+	svc   *Service
+	users Users
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, users Users) *Handler {
+	return &Handler{svc: svc, users: users}
+}
+
+// Register mounts the organization routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/orgs", h.list)
+	mux.HandleFunc("POST /api/orgs", h.create)
+	mux.HandleFunc("GET /api/orgs/{org}", h.get)
+	mux.HandleFunc("PUT /api/orgs/{org}", h.update)
+	mux.HandleFunc("DELETE /api/orgs/{org}", h.delete)
+	mux.HandleFunc("GET /api/orgs/{org}/members", h.members)
+	mux.HandleFunc("POST /api/orgs/{org}/members", h.addMember)
+	mux.HandleFunc("PUT /api/orgs/{org}/members/{user}", h.setMemberRole)
+	mux.HandleFunc("DELETE /api/orgs/{org}/members/{user}", h.removeMember)
+	mux.HandleFunc("GET /api/orgs/{org}/teams", h.teams)
+	mux.HandleFunc("POST /api/orgs/{org}/teams", h.putTeam)
+	mux.HandleFunc("PUT /api/orgs/{org}/teams/{team}", h.putTeam)
+	mux.HandleFunc("DELETE /api/orgs/{org}/teams/{team}", h.deleteTeam)
+	mux.HandleFunc("GET /api/orgs/{org}/workspaces", h.workspaces)
+	mux.HandleFunc("POST /api/orgs/{org}/workspaces", h.addWorkspace)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"orgs": h.svc.List(u.ID)})
+}
+
+type createRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req createRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	o, err := h.svc.Create(req.ID, req.Name, Member{UserID: u.ID, Email: u.Email})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, o)
+}
+
+// get returns an organization with the caller's role, its quota and what
+// it uses of it.
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("org")
+	o, role, err := h.svc.Get(id, u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	usage, err := h.svc.Usage(id, u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"org": o, "role": role, "quota": h.svc.Quota(), "usage": usage})
+}
+
+type updateRequest struct {
+	Name        string         `json:"name"`
+	DefaultRole workspace.Role `json:"defaultRole"`
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req updateRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	o, err := h.svc.Update(r.PathValue("org"), u.ID, req.Name, req.DefaultRole)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(r.PathValue("org"), u.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionOrgDelete, Org: r.PathValue("org")})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) members(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	members, err := h.svc.Members(r.PathValue("org"), u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"members": members})
+}
+
+type memberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// addMember adds an existing account, found by its email address.
+func (h *Handler) addMember(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req memberRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Role == "" {
+		req.Role = RoleMember
+	}
+	added, err := h.users.UserByEmail(req.Email)
+	if errors.Is(err, auth.ErrNotFound) {
+		httpx.Error(w, http.StatusNotFound, "no account with that email address")
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	m, err := h.svc.AddMember(r.PathValue("org"), u.ID, Member{UserID: added.ID, Email: added.Email, Role: req.Role})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, m)
+}
+
+func (h *Handler) setMemberRole(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req memberRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	m, err := h.svc.SetMemberRole(r.PathValue("org"), u.ID, r.PathValue("user"), req.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, m)
+}
+
+func (h *Handler) removeMember(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.RemoveMember(r.PathValue("org"), u.ID, r.PathValue("user")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) teams(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	teams, err := h.svc.Teams(r.PathValue("org"), u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"teams": teams})
+}
+
+type teamRequest struct {
+	Name       string         `json:"name"`
+	Role       workspace.Role `json:"role"`
+	Members    []string       `json:"members"`
+	Workspaces []string       `json:"workspaces"`
+}
+
+// putTeam creates a team on POST and replaces one on PUT.
+func (h *Handler) putTeam(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req teamRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	t, err := h.svc.PutTeam(r.PathValue("org"), u.ID, Team{
+		ID:         r.PathValue("team"),
+		Name:       req.Name,
+		Role:       req.Role,
+		Members:    req.Members,
+		Workspaces: req.Workspaces,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	httpx.JSON(w, status, t)
+}
+
+func (h *Handler) deleteTeam(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteTeam(r.PathValue("org"), u.ID, r.PathValue("team")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// workspaces lists the organization's workspaces with the caller's role
+// on each.
+func (h *Handler) workspaces(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("org")
+	if _, _, err := h.svc.Get(id, u.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	ids, err := h.svc.Workspaces(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	type entry struct {
+		ID   string         `json:"id"`
+		Role workspace.Role `json:"role,omitempty"`
+	}
+	out := make([]entry, 0, len(ids))
+	for _, ws := range ids {
+		role, err := h.svc.Role(id, ws, u.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		out = append(out, entry{ID: ws, Role: role})
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"workspaces": out})
+}
+
+type workspaceRequest struct {
+	// ID names the workspace; a random one is generated when empty.
+	ID string `json:"id,omitempty"`
+	// Existing transfers the caller's workspace ID instead of creating
+	// one.
+	Existing bool `json:"existing,omitempty"`
+}
+
+// addWorkspace creates an organization workspace or transfers one of the
+// caller's own workspaces to the organization.
+func (h *Handler) addWorkspace(w http.ResponseWriter, r *http.Request) {
+	u, ok := user(w, r)
+	if !ok {
+		return
+	}
+	var req workspaceRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	id := r.PathValue("org")
+	if req.Existing {
+		if err := h.svc.TransferWorkspace(id, u.ID, req.ID); err != nil {
+			writeError(w, err)
+			return
+		}
+		httpx.JSON(w, http.StatusOK, map[string]string{"id": req.ID, "owner": OwnerPrefix + id})
+		return
+	}
+	if req.ID == "" {
+		req.ID = workspace.NewID()
+	}
+	if _, err := h.svc.CreateWorkspace(r.Context(), id, u.ID, req.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, map[string]string{"id": req.ID, "owner": OwnerPrefix + id})
+}
+
+// user returns the signed-in caller. Organizations need accounts, so
+// without one the request fails with 401.
+func user(w http.ResponseWriter, r *http.Request) (*auth.User, bool) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "sign in to use organizations")
+		return nil, false
+	}
+	return u, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid), errors.Is(err, workspace.ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound), errors.Is(err, workspace.ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrForbidden):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrExists), errors.Is(err, workspace.ErrExists),
+		errors.Is(err, ErrLastAdmin), errors.Is(err, ErrNotEmpty), errors.Is(err, ErrQuota):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("organizations", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not update organization")
+	}
+}
@@ -0,0 +1,698 @@
+// Package org groups users into organizations, such as a company or a
+// classroom, that own workspaces together. Admins manage the members and
+// the teams; a team gives its members a role on all or some of the
+// organization's workspaces, on top of the default role every member has.
+// Admins are owners of every organization workspace.
+//
+// Organization workspaces are workspaces whose owner is "org:<id>". Limits
+// from Config cap how many workspaces and members an organization has and
+// how much storage its workspaces use.
+package org
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the organizations file; defaults to a directory under the
+	// OS temp dir.
+	Dir string
+	// Quota caps every organization; zero fields get defaults.
+	Quota Quota
+}
+
+// Quota caps an organization's resources.
+type Quota struct {
+	// MaxWorkspaces defaults to 100.
+	MaxWorkspaces int `json:"maxWorkspaces"`
+	// MaxMembers defaults to 200.
+	MaxMembers int `json:"maxMembers"`
+	// MaxStorageBytes bounds the size of all workspaces together; defaults
+	// to 10 GiB. It is checked when workspaces are added.
+	MaxStorageBytes int64 `json:"maxStorageBytes"`
+}
+
+// Usage is what an organization uses of its Quota.
+type Usage struct {
+	Workspaces   int   `json:"workspaces"`
+	Members      int   `json:"members"`
+	StorageBytes int64 `json:"storageBytes"`
+}
+
+var (
+	// ErrInvalid is returned for bad IDs, names and roles.
+	ErrInvalid = errors.New("org: invalid request")
+	// ErrNotFound is returned for unknown organizations, members and teams.
+	ErrNotFound = errors.New("org: not found")
+	// ErrExists is returned when an organization ID or member is taken.
+	ErrExists = errors.New("org: already exists")
+	// ErrForbidden is returned when a member without admin rights changes
+	// the organization.
+	ErrForbidden = errors.New("org: admin rights required")
+	// ErrQuota is returned when a change would exceed the quota.
+	ErrQuota = errors.New("org: quota exceeded")
+	// ErrLastAdmin is returned when the last admin would leave or lose
+	// admin rights.
+	ErrLastAdmin = errors.New("org: an organization needs an admin")
+	// ErrNotEmpty is returned when deleting an organization that still
+	// owns workspaces.
+	ErrNotEmpty = errors.New("org: organization still owns workspaces")
+)
+
+// Member roles.
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// OwnerPrefix starts the owner of organization workspaces.
+const OwnerPrefix = "org:"
+
+// Org is an organization.
+type Org struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// DefaultRole is every member's role on the organization's
+	// workspaces; "" gives members access only through teams.
+	DefaultRole workspace.Role `json:"defaultRole"`
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+// Member is a user in an organization.
+type Member struct {
+	UserID  string    `json:"userId"`
+	Email   string    `json:"email,omitempty"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Team gives its members Role on the organization's Workspaces, or on all
+// of them when Workspaces is empty.
+type Team struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Role       workspace.Role `json:"role"`
+	Members    []string       `json:"members"`
+	Workspaces []string       `json:"workspaces,omitempty"`
+}
+
+type orgRecord struct {
+	Org
+	Members map[string]*Member `json:"members"`
+	Teams   map[string]*Team   `json:"teams"`
+}
+
+// Workspaces creates workspaces and records their owners.
+type Workspaces interface {
+	Open(id string) (string, error)
+	Create(ctx context.Context, id string) (string, error)
+	List() ([]string, error)
+	Owner(id string) (string, error)
+	SetOwner(id, owner string) error
+}
+
+// Service manages organizations.
+type Service struct {
+	cfg        Config
+	workspaces Workspaces
+	now        func() time.Time
+
+	mu   sync.Mutex
+	orgs map[string]*orgRecord
+}
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,38}$`)
+
+// NewService returns a Service, filling unset Config fields with defaults
+// and loading stored organizations.
+func NewService(cfg Config, ws Workspaces) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-orgs")
+	}
+	if cfg.Quota.MaxWorkspaces <= 0 {
+		cfg.Quota.MaxWorkspaces = 100
+	}
+	if cfg.Quota.MaxMembers <= 0 {
+		cfg.Quota.MaxMembers = 200
+	}
+	if cfg.Quota.MaxStorageBytes <= 0 {
+		cfg.Quota.MaxStorageBytes = 10 << 30
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("org: create dir: %w", err)
+	}
+	s := &Service{cfg: cfg, workspaces: ws, now: time.Now, orgs: make(map[string]*orgRecord)}
+	data, err := os.ReadFile(s.path())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("org: read organizations: %w", err)
+	default:
+		if err := json.Unmarshal(data, &s.orgs); err != nil {
+			return nil, fmt.Errorf("org: read organizations: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Quota returns the quota every organization has.
+func (s *Service) Quota() Quota { return s.cfg.Quota }
+
+// Create makes an organization with the creator as its admin.
+func (s *Service) Create(id, name string, creator Member) (*Org, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case !idPattern.MatchString(id):
+		return nil, fmt.Errorf("%w: id must be 2 to 39 lowercase letters, digits or dashes", ErrInvalid)
+	case name == "" || len(name) > 100:
+		return nil, fmt.Errorf("%w: name must have 1 to 100 characters", ErrInvalid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orgs[id]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrExists, id)
+	}
+	now := s.now().UTC()
+	creator.Role, creator.AddedAt = RoleAdmin, now
+	o := &orgRecord{
+		Org:     Org{ID: id, Name: name, DefaultRole: workspace.RoleEditor, CreatedAt: now},
+		Members: map[string]*Member{creator.UserID: &creator},
+		Teams:   make(map[string]*Team),
+	}
+	s.orgs[id] = o
+	if err := s.save(); err != nil {
+		delete(s.orgs, id)
+		return nil, err
+	}
+	out := o.Org
+	return &out, nil
+}
+
+// Get returns an organization and the caller's member role in it.
+func (s *Service) Get(id, userID string) (*Org, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, m, err := s.member(id, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	out := o.Org
+	return &out, m.Role, nil
+}
+
+// Exists reports whether organization id exists, for callers who check
+// access themselves.
+func (s *Service) Exists(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.orgs[id]
+	return ok
+}
+
+// List returns the organizations userID belongs to.
+func (s *Service) List(userID string) []Org {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Org{}
+	for _, o := range s.orgs {
+		if _, ok := o.Members[userID]; ok {
+			out = append(out, o.Org)
+		}
+	}
+	slices.SortFunc(out, func(a, b Org) int { return strings.Compare(a.ID, b.ID) })
+	return out
+}
+
+// Update changes an organization's name and default role.
+func (s *Service) Update(id, userID, name string, defaultRole workspace.Role) (*Org, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, fmt.Errorf("%w: name must have 1 to 100 characters", ErrInvalid)
+	}
+	if defaultRole != "" {
+		if err := checkRole(defaultRole); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.admin(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	o.Name, o.DefaultRole = name, defaultRole
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	out := o.Org
+	return &out, nil
+}
+
+// Delete removes an organization that owns no workspaces.
+func (s *Service) Delete(id, userID string) error {
+	s.mu.Lock()
+	if _, err := s.admin(id, userID); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+	ids, err := s.Workspaces(id)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return ErrNotEmpty
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.orgs, id)
+	return s.save()
+}
+
+// Members returns an organization's members, ordered by when they joined.
+func (s *Service) Members(id, userID string) ([]Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, _, err := s.member(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Member, 0, len(o.Members))
+	for _, m := range o.Members {
+		out = append(out, *m)
+	}
+	slices.SortFunc(out, func(a, b Member) int { return a.AddedAt.Compare(b.AddedAt) })
+	return out, nil
+}
+
+// AddMember adds a user to an organization.
+func (s *Service) AddMember(id, by string, m Member) (*Member, error) {
+	if m.Role != RoleAdmin && m.Role != RoleMember {
+		return nil, fmt.Errorf("%w: role must be %s or %s", ErrInvalid, RoleAdmin, RoleMember)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.admin(id, by)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := o.Members[m.UserID]; ok {
+		return nil, fmt.Errorf("%w: %s is already a member", ErrExists, m.Email)
+	}
+	if len(o.Members) >= s.cfg.Quota.MaxMembers {
+		return nil, fmt.Errorf("%w: at most %d members", ErrQuota, s.cfg.Quota.MaxMembers)
+	}
+	m.AddedAt = s.now().UTC()
+	o.Members[m.UserID] = &m
+	if err := s.save(); err != nil {
+		delete(o.Members, m.UserID)
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SetMemberRole makes a member an admin or takes admin rights away.
+func (s *Service) SetMemberRole(id, by, userID, role string) (*Member, error) {
+	if role != RoleAdmin && role != RoleMember {
+		return nil, fmt.Errorf("%w: role must be %s or %s", ErrInvalid, RoleAdmin, RoleMember)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.admin(id, by)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := o.Members[userID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a member", ErrNotFound, userID)
+	}
+	if m.Role == RoleAdmin && role != RoleAdmin && admins(o) == 1 {
+		return nil, ErrLastAdmin
+	}
+	prev := m.Role
+	m.Role = role
+	if err := s.save(); err != nil {
+		m.Role = prev
+		return nil, err
+	}
+	out := *m
+	return &out, nil
+}
+
+// RemoveMember takes a user out of an organization and its teams. Admins
+// remove anyone; members may remove themselves.
+func (s *Service) RemoveMember(id, by, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var o *orgRecord
+	var err error
+	if by == userID {
+		o, _, err = s.member(id, by)
+	} else {
+		o, err = s.admin(id, by)
+	}
+	if err != nil {
+		return err
+	}
+	m, ok := o.Members[userID]
+	if !ok {
+		return fmt.Errorf("%w: %s is not a member", ErrNotFound, userID)
+	}
+	if m.Role == RoleAdmin && admins(o) == 1 {
+		return ErrLastAdmin
+	}
+	delete(o.Members, userID)
+	for _, t := range o.Teams {
+		t.Members = slices.DeleteFunc(t.Members, func(u string) bool { return u == userID })
+	}
+	return s.save()
+}
+
+// Teams returns an organization's teams, sorted by name.
+func (s *Service) Teams(id, userID string) ([]Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, _, err := s.member(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Team, 0, len(o.Teams))
+	for _, t := range o.Teams {
+		out = append(out, *t)
+	}
+	slices.SortFunc(out, func(a, b Team) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+// PutTeam creates a team, when t.ID is empty, or replaces one.
+func (s *Service) PutTeam(id, by string, t Team) (*Team, error) {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" || len(t.Name) > 100 {
+		return nil, fmt.Errorf("%w: name must have 1 to 100 characters", ErrInvalid)
+	}
+	if err := checkRole(t.Role); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.admin(id, by)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range t.Members {
+		if _, ok := o.Members[u]; !ok {
+			return nil, fmt.Errorf("%w: %s is not a member of %s", ErrInvalid, u, id)
+		}
+	}
+	for _, ws := range t.Workspaces {
+		if !workspace.ValidID(ws) {
+			return nil, fmt.Errorf("%w: bad workspace id %q", ErrInvalid, ws)
+		}
+	}
+	if t.Members == nil {
+		t.Members = []string{}
+	}
+	slices.Sort(t.Members)
+	t.Members = slices.Compact(t.Members)
+	if t.ID == "" {
+		t.ID = newID()
+	} else if _, ok := o.Teams[t.ID]; !ok {
+		return nil, fmt.Errorf("%w: no team %s", ErrNotFound, t.ID)
+	}
+	prev := o.Teams[t.ID]
+	o.Teams[t.ID] = &t
+	if err := s.save(); err != nil {
+		if prev != nil {
+			o.Teams[t.ID] = prev
+		} else {
+			delete(o.Teams, t.ID)
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteTeam removes a team.
+func (s *Service) DeleteTeam(id, by, teamID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.admin(id, by)
+	if err != nil {
+		return err
+	}
+	if _, ok := o.Teams[teamID]; !ok {
+		return fmt.Errorf("%w: no team %s", ErrNotFound, teamID)
+	}
+	delete(o.Teams, teamID)
+	return s.save()
+}
+
+// Role returns userID's role on workspace wsID of organization id, or ""
+// for none: owner for admins, otherwise the most access the default role
+// and the user's teams give.
+func (s *Service) Role(id, wsID, userID string) (workspace.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orgs[id]
+	if !ok {
+		return "", nil
+	}
+	m, ok := o.Members[userID]
+	if !ok {
+		return "", nil
+	}
+	if m.Role == RoleAdmin {
+		return workspace.RoleOwner, nil
+	}
+	role := o.DefaultRole
+	for _, t := range o.Teams {
+		if slices.Contains(t.Members, userID) && (len(t.Workspaces) == 0 || slices.Contains(t.Workspaces, wsID)) {
+			role = workspace.MaxRole(role, t.Role)
+		}
+	}
+	return role, nil
+}
+
+// Workspaces returns the IDs of the workspaces organization id owns.
+func (s *Service) Workspaces(id string) ([]string, error) {
+	all, err := s.workspaces.List()
+	if err != nil {
+		return nil, fmt.Errorf("org: list workspaces: %w", err)
+	}
+	var out []string
+	for _, ws := range all {
+		owner, err := s.workspaces.Owner(ws)
+		if err != nil {
+			return nil, err
+		}
+		if owner == OwnerPrefix+id {
+			out = append(out, ws)
+		}
+	}
+	return out, nil
+}
+
+// Usage returns what organization id uses of its quota.
+func (s *Service) Usage(id, userID string) (*Usage, error) {
+	s.mu.Lock()
+	o, _, err := s.member(id, userID)
+	var members int
+	if err == nil {
+		members = len(o.Members)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.Workspaces(id)
+	if err != nil {
+		return nil, err
+	}
+	u := &Usage{Workspaces: len(ids), Members: members}
+	for _, ws := range ids {
+		n, err := s.size(ws)
+		if err != nil {
+			return nil, err
+		}
+		u.StorageBytes += n
+	}
+	return u, nil
+}
+
+// CreateWorkspace makes an empty workspace owned by organization id.
+func (s *Service) CreateWorkspace(ctx context.Context, id, userID, wsID string) (string, error) {
+	if err := s.checkAdd(id, userID, ""); err != nil {
+		return "", err
+	}
+	return s.workspaces.Create(workspace.WithOwner(ctx, OwnerPrefix+id), wsID)
+}
+
+// TransferWorkspace hands a workspace userID owns to organization id.
+// Like CreateWorkspace, it needs an admin.
+func (s *Service) TransferWorkspace(id, userID, wsID string) error {
+	owner, err := s.workspaces.Owner(wsID)
+	if err != nil {
+		return err
+	}
+	if owner != userID {
+		return fmt.Errorf("%w: only a workspace's owner can transfer it", ErrForbidden)
+	}
+	if err := s.checkAdd(id, userID, wsID); err != nil {
+		return err
+	}
+	return s.workspaces.SetOwner(wsID, OwnerPrefix+id)
+}
+
+// checkAdd checks that userID is an admin and the organization has room
+// for another workspace, optionally the existing workspace wsID.
+func (s *Service) checkAdd(id, userID, wsID string) error {
+	s.mu.Lock()
+	_, err := s.admin(id, userID)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	ids, err := s.Workspaces(id)
+	if err != nil {
+		return err
+	}
+	if len(ids) >= s.cfg.Quota.MaxWorkspaces {
+		return fmt.Errorf("%w: at most %d workspaces", ErrQuota, s.cfg.Quota.MaxWorkspaces)
+	}
+	var total int64
+	if wsID != "" {
+		ids = append(ids, wsID)
+	}
+	for _, ws := range ids {
+		n, err := s.size(ws)
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+	if total > s.cfg.Quota.MaxStorageBytes {
+		return fmt.Errorf("%w: workspaces would use %d of %d bytes", ErrQuota, total, s.cfg.Quota.MaxStorageBytes)
+	}
+	return nil
+}
+
+// size returns the bytes the files of a workspace take.
+func (s *Service) size(wsID string) (int64, error) {
+	dir, err := s.workspaces.Open(wsID)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			n += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("org: measure %s: %w", wsID, err)
+	}
+	return n, nil
+}
+
+// member returns an organization and userID's membership. s.mu must be
+// held. Non-members get ErrNotFound, so organizations are not revealed.
+func (s *Service) member(id, userID string) (*orgRecord, *Member, error) {
+	o, ok := s.orgs[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no organization %s", ErrNotFound, id)
+	}
+	m, ok := o.Members[userID]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no organization %s", ErrNotFound, id)
+	}
+	return o, m, nil
+}
+
+// admin is member for changes only admins may make. s.mu must be held.
+func (s *Service) admin(id, userID string) (*orgRecord, error) {
+	o, m, err := s.member(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Role != RoleAdmin {
+		return nil, ErrForbidden
+	}
+	return o, nil
+}
+
+func admins(o *orgRecord) int {
+	n := 0
+	for _, m := range o.Members {
+		if m.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+func checkRole(role workspace.Role) error {
+	if role != workspace.RoleEditor && role != workspace.RoleViewer {
+		return fmt.Errorf("%w: role must be %s or %s", ErrInvalid, workspace.RoleEditor, workspace.RoleViewer)
+	}
+	return nil
+}
+
+func (s *Service) path() string {
+	return filepath.Join(s.cfg.Dir, "orgs.json")
+}
+
+// save atomically writes the organizations file. s.mu must be held.
+func (s *Service) save() error {
+	data, err := json.Marshal(s.orgs)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.cfg.Dir, ".orgs-*")
+	if err != nil {
+		return fmt.Errorf("org: write organizations: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("org: write organizations: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("org: write organizations: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path()); err != nil {
+		return fmt.Errorf("org: write organizations: %w", err)
+	}
+	return nil
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return "team-" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,316 @@
+// Package playground serves the run-only API that documentation sites
+// embed, so readers can run Go examples in place. It needs no sign-in and
+// keeps nothing: each request carries its program, which is compiled and
+// run in a sandbox with small limits, and only the output and compile
+// errors come back. Cross-origin callers must be on the configured
+// allowlist.
+//
+// The route is throttled by its own ratelimit rule, tighter than that of
+// signed-in runs.
+package playground
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+)
+
+// Runner runs programs.
+type Runner interface {
+	Run(ctx context.Context, req runner.Request) (*runner.Result, error)
+}
+
+// Config configures a Handler.
+type Config struct {
+	// Origins are the origins of the pages that may call the API from a
+	// browser, such as "https://blog.example.com"; "*" allows any. Pages
+	// of the server's own origin always may.
+	Origins []string
+	// MaxSourceBytes bounds the program; defaults to 64 KiB.
+	MaxSourceBytes int
+	// MaxFiles bounds the files of a program; defaults to 20.
+	MaxFiles int
+	// Limits are the run's limits; unset fields default to those of
+	// DefaultLimits. Requests cannot change them.
+	Limits runner.Limits
+}
+
+// DefaultLimits are smaller than the runner's own: examples in
+// documentation print something and exit.
+var DefaultLimits = runner.Limits{
+	CPUs:        0.5,
+	MemoryMB:    128,
+	TimeoutMS:   5_000,
+	MaxProcs:    32,
+	OutputBytes: 64 << 10,
+}
+
+// Handler serves the playground routes.
+type Handler struct {
+	cfg    Config
+	runner Runner
+}
+
+// NewHandler returns a Handler running programs through run, filling
+// unset Config fields with defaults.
+func NewHandler(cfg Config, run Runner) *Handler {
+	if cfg.MaxSourceBytes <= 0 {
+		cfg.MaxSourceBytes = 64 << 10
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = 20
+	}
+	if cfg.Limits.CPUs <= 0 {
+		cfg.Limits.CPUs = DefaultLimits.CPUs
+	}
+	if cfg.Limits.MemoryMB <= 0 {
+		cfg.Limits.MemoryMB = DefaultLimits.MemoryMB
+	}
+	if cfg.Limits.TimeoutMS <= 0 {
+		cfg.Limits.TimeoutMS = DefaultLimits.TimeoutMS
+	}
+	if cfg.Limits.MaxProcs <= 0 {
+		cfg.Limits.MaxProcs = DefaultLimits.MaxProcs
+	}
+	if cfg.Limits.OutputBytes <= 0 {
+		cfg.Limits.OutputBytes = DefaultLimits.OutputBytes
+	}
+	return &Handler{cfg: cfg, runner: run}
+}
+
+// Register mounts the playground routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /embed/run", h.run)
+	mux.HandleFunc("GET /embed/play.js", h.script)
+}
+
+// Middleware answers the CORS preflight requests of /embed/run, refuses
+// its requests from origins not allowed, and adds the CORS headers to the
+// others. It goes ahead of the rate limit, so throttled callers see the
+// 429 rather than a CORS error. Without a Handler it returns next.
+func Middleware(h *Handler, next http.Handler) http.Handler {
+	if h == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed/run" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		origin, ok := h.allowed(r)
+		if !ok {
+			httpx.Error(w, http.StatusForbidden, "origin not allowed")
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
+		}
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "86400")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Request is a program to run.
+type Request struct {
+	// Language defaults to "go".
+	Language string `json:"language,omitempty"`
+	// Source is the program's main file, or Files its project, as in
+	// runner.Request.
+	Source string        `json:"source,omitempty"`
+	Files  []runner.File `json:"files,omitempty"`
+	// Stdin is the program's standard input.
+	Stdin string `json:"stdin,omitempty"`
+}
+
+// Response is the outcome of a run.
+type Response struct {
+	// Phase is where it stopped: "build" for programs that did not
+	// compile, with their Diagnostics, or "run".
+	Phase           runner.Phase      `json:"phase"`
+	Stdout          string            `json:"stdout"`
+	Stderr          string            `json:"stderr"`
+	ExitCode        int               `json:"exitCode"`
+	TimedOut        bool              `json:"timedOut"`
+	DurationMS      int64             `json:"durationMs"`
+	Killed          runner.KillReason `json:"killed,omitempty"`
+	Message         string            `json:"message,omitempty"`
+	OutputTruncated bool              `json:"outputTruncated,omitempty"`
+	Diagnostics     []diag.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// allowed reports whether r may be served: with no Origin header, from
+// the server's own origin or from one of Config.Origins. The origin it
+// returns, if any, goes in Access-Control-Allow-Origin.
+func (h *Handler) allowed(r *http.Request) (string, bool) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return "", true
+	}
+	for _, o := range h.cfg.Origins {
+		if o == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(o, origin) {
+			return origin, true
+		}
+	}
+	if host, ok := strings.CutPrefix(origin, "https://"); ok && strings.EqualFold(host, r.Host) {
+		return origin, true
+	}
+	if host, ok := strings.CutPrefix(origin, "http://"); ok && strings.EqualFold(host, r.Host) {
+		return origin, true
+	}
+	return "", false
+}
+
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	limit := int64(h.cfg.MaxSourceBytes)*2 + 4<<10
+	if err := httpx.DecodeJSON(w, r, &req, limit); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.check(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rr := runner.Request{
+		Language: req.Language,
+		Source:   req.Source,
+		Files:    req.Files,
+		Limits:   h.cfg.Limits,
+	}
+	if req.Stdin != "" {
+		rr.Stdin = strings.NewReader(req.Stdin)
+	}
+	res, err := h.runner.Run(r.Context(), rr)
+	switch {
+	case runner.IsRequestError(err):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, runner.ErrQuotaExceeded):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	case errors.Is(err, runner.ErrQueueFull):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, runner.ErrStopped):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("playground run failed", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "execution failed")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, Response{
+		Phase:           res.Phase,
+		Stdout:          res.Stdout,
+		Stderr:          res.Stderr,
+		ExitCode:        res.ExitCode,
+		TimedOut:        res.TimedOut,
+		DurationMS:      res.DurationMS,
+		Killed:          res.Killed,
+		Message:         res.Message,
+		OutputTruncated: res.OutputTruncated,
+		Diagnostics:     res.Diagnostics,
+	})
+}
+
+// check bounds the size of req.
+func (h *Handler) check(req *Request) error {
+	if req.Source == "" && len(req.Files) == 0 {
+		return errors.New("source or files is required")
+	}
+	if len(req.Files) > h.cfg.MaxFiles {
+		return fmt.Errorf("at most %d files", h.cfg.MaxFiles)
+	}
+	n := len(req.Source) + len(req.Stdin)
+	for _, f := range req.Files {
+		n += len(f.Content)
+	}
+	if n > h.cfg.MaxSourceBytes {
+		return fmt.Errorf("program and stdin exceed %d bytes", h.cfg.MaxSourceBytes)
+	}
+	return nil
+}
+
+// script serves play.js, which makes code blocks of a page runnable.
+func (h *Handler) script(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("Content-Length", strconv.Itoa(len(playScript)))
+	w.Write([]byte(playScript))
+}
+
+// playScript adds a Run button to each pre element with a
+// data-webide-run attribute, whose text is the program; the attribute's
+// value, if any, is its language. Output goes below the block.
+const playScript = `(() => {
+	const base = new URL(document.currentScript.src).origin;
+	const run = async (pre, button, output) => {
+		button.disabled = true;
+		output.hidden = false;
+		output.textContent = "Running...";
+		try {
+			const res = await fetch(base + "/embed/run", {
+				method: "POST",
+				headers: {"Content-Type": "application/json"},
+				body: JSON.stringify({language: pre.dataset.webideRun || undefined, source: pre.textContent}),
+			});
+			const body = await res.json();
+			output.textContent = "";
+			const show = (text, color) => {
+				const span = document.createElement("span");
+				span.textContent = text;
+				if (color) span.style.color = color;
+				output.append(span);
+			};
+			if (!res.ok) {
+				show(body.error || res.statusText, "#b00");
+			} else if (body.phase === "build") {
+				show(body.stderr, "#b00");
+			} else {
+				show(body.stdout);
+				show(body.stderr, "#b00");
+				show(body.timedOut ? "\nProgram timed out." : "\nProgram exited with status " + body.exitCode + ".", "#777");
+			}
+		} catch (err) {
+			output.textContent = String(err);
+		} finally {
+			button.disabled = false;
+		}
+	};
+	const init = () => {
+		for (const pre of document.querySelectorAll("pre[data-webide-run]")) {
+			const button = document.createElement("button");
+			button.textContent = "Run";
+			const output = document.createElement("pre");
+			output.hidden = true;
+			output.className = "webide-output";
+			button.onclick = () => run(pre, button, output);
+			pre.after(button, output);
+		}
+	};
+	if (document.readyState === "loading") {
+		document.addEventListener("DOMContentLoaded", init);
+	} else {
+		init();
+	}
+})();
+`
@@ -0,0 +1,155 @@
+package ports
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// maxLabelLen bounds port labels.
+const maxLabelLen = 100
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the port routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, ws Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: ws}
+}
+
+// Register mounts the port routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/ports", h.list)
+	mux.HandleFunc("PUT /api/workspaces/{id}/ports/{port}", h.declare)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/ports/{port}", h.undeclare)
+	mux.HandleFunc("GET /api/workspaces/{id}/ports/{port}/url", h.url)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return id, true
+}
+
+func portParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	port, err := strconv.Atoi(r.PathValue("port"))
+	if err != nil || validPort(port) != nil {
+		httpx.Error(w, http.StatusBadRequest, "port must be between 1 and 65535")
+		return 0, false
+	}
+	return port, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	ports, err := h.svc.List(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"ports": ports})
+}
+
+type declareRequest struct {
+	Label string `json:"label"`
+}
+
+// declare adds the port to the workspace's ports; the body, optional,
+// labels it.
+func (h *Handler) declare(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	port, ok := portParam(w, r)
+	if !ok {
+		return
+	}
+	var req declareRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if len(req.Label) > maxLabelLen {
+		httpx.Errorf(w, http.StatusBadRequest, "label is longer than %d bytes", maxLabelLen)
+		return
+	}
+	if err := h.svc.Declare(id, port, req.Label); err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, Port{Port: port, Label: req.Label, Declared: true, URL: h.svc.URL(id, port)})
+}
+
+func (h *Handler) undeclare(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	port, ok := portParam(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Undeclare(id, port); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// url returns a preview URL that signs the caller in to the port's
+// preview. Any member may open previews, viewers included.
+func (h *Handler) url(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	port, ok := portParam(w, r)
+	if !ok {
+		return
+	}
+	var userID string
+	if u := auth.UserFrom(r.Context()); u != nil {
+		userID = u.ID
+	}
+	u, err := h.svc.OpenURL(id, port, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"url": u, "expiresIn": int(h.svc.cfg.TicketTTL.Seconds())})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPort):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotDeclared):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("ports", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "ports request failed")
+	}
+}
@@ -0,0 +1,203 @@
+package ports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reloadPath is where, below a preview's root, the live reload script
+// listens for reloads. It is served by the proxy, not the app.
+const reloadPath = "__webide/livereload"
+
+const (
+	// maxInjectBytes bounds the pages the reload script is added to;
+	// larger ones are passed on as they are.
+	maxInjectBytes = 8 << 20
+	// reloadWait bounds how long Reload waits for a restarted server.
+	reloadWait = 30 * time.Second
+	// reloadHeartbeat keeps idle reload streams from being timed out by
+	// intermediaries.
+	reloadHeartbeat = 25 * time.Second
+)
+
+// liveReload is the reload state of one workspace port.
+type liveReload struct {
+	on   bool
+	subs map[chan struct{}]struct{}
+}
+
+func reloadKey(workspaceID string, port int) string {
+	return workspaceID + ":" + strconv.Itoa(port)
+}
+
+// LiveReload turns live reload on or off for a workspace port. While on,
+// HTML pages of its preview get a script that reloads them when Reload is
+// called.
+func (s *Service) LiveReload(workspaceID string, port int, on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := reloadKey(workspaceID, port)
+	lr := s.reloads[key]
+	switch {
+	case on && lr == nil:
+		s.reloads[key] = &liveReload{on: true, subs: make(map[chan struct{}]struct{})}
+	case on:
+		lr.on = true
+	case lr != nil:
+		lr.on = false
+		if len(lr.subs) == 0 {
+			delete(s.reloads, key)
+		}
+	}
+}
+
+// Reload waits for something to accept connections on a workspace port,
+// as a restarted server does once it is ready, and then reloads the
+// port's open previews. It gives up after 30 seconds or when ctx ends.
+func (s *Service) Reload(ctx context.Context, workspaceID string, port int) error {
+	ctx, cancel := context.WithTimeout(ctx, reloadWait)
+	defer cancel()
+	for {
+		addr, err := s.address(ctx, workspaceID)
+		if err == nil {
+			var d net.Dialer
+			c, err := d.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+			if err == nil {
+				c.Close()
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("ports: port %d did not come up: %w", port, ctx.Err())
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	s.mu.Lock()
+	if lr := s.reloads[reloadKey(workspaceID, port)]; lr != nil {
+		for c := range lr.subs {
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// liveReloadOn reports whether previews of a workspace port get the
+// reload script.
+func (s *Service) liveReloadOn(t target) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lr := s.reloads[reloadKey(t.workspaceID, t.port)]
+	return lr != nil && lr.on
+}
+
+// serveReload streams a "reload" event each time the preview should
+// reload, as server-sent events.
+func (s *Service) serveReload(w http.ResponseWriter, r *http.Request, t target) {
+	key := reloadKey(t.workspaceID, t.port)
+	c := make(chan struct{}, 1)
+	s.mu.Lock()
+	lr := s.reloads[key]
+	if lr == nil {
+		lr = &liveReload{subs: make(map[chan struct{}]struct{})}
+		s.reloads[key] = lr
+	}
+	lr.subs[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(lr.subs, c)
+		if !lr.on && len(lr.subs) == 0 && s.reloads[key] == lr {
+			delete(s.reloads, key)
+		}
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	rc.Flush()
+	heartbeat := time.NewTicker(reloadHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-c:
+			io.WriteString(w, "event: reload\ndata: {}\n\n")
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// reloadScript returns the script added to pages of a preview.
+func reloadScript(t target) string {
+	root := t.prefix
+	if root == "" {
+		root = "/"
+	}
+	return fmt.Sprintf(`<script>(function(){var es=new EventSource(%q);es.addEventListener("reload",function(){location.reload()})})()</script>`, root+reloadPath)
+}
+
+// injectReload adds the reload script to an uncompressed HTML page, before
+// its closing body tag if it has one.
+func injectReload(resp *http.Response, t target) error {
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mt != "text/html" || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" && ce != "identity" {
+		return nil
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxInjectBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(page) > maxInjectBytes {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(page), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+	script := reloadScript(t)
+	if i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>")); i >= 0 {
+		page = append(page[:i:i], append([]byte(script), page[i:]...)...)
+	} else {
+		page = append(page, script...)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(page))
+	resp.ContentLength = int64(len(page))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(page)))
+	// The page changed, so validators of the original do not apply.
+	resp.Header.Del("ETag")
+	resp.Header.Del("Last-Modified")
+	return nil
+}
+
+// isReloadPath reports whether r is for the reload stream of a preview.
+func isReloadPath(r *http.Request, t target) bool {
+	root := t.prefix
+	if root == "" {
+		root = "/"
+	}
+	return r.URL.Path == root+reloadPath && strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
@@ -0,0 +1,432 @@
+// Package ports exposes HTTP servers running in a workspace through
+// preview URLs. Listening ports are detected in the workspace container,
+// and users may declare others, with a label; each workspace and port
+// gets its own URL, proxied with WebSocket upgrades to the process.
+//
+// With Config.Domain set a preview is served on its own host,
+// <port>-<workspace>.<domain>, so the previewed app runs on an origin
+// apart from the IDE's and cannot act with its users' credentials.
+// Without it previews are served below /ports/<workspace>/<port>/ on the
+// IDE's own origin, which only suits single-user development.
+//
+// Previews authenticate with a session cookie of their own. The IDE asks
+// for a preview URL, which carries a short-lived signed ticket; the proxy
+// exchanges the ticket for the cookie and redirects to the bare URL. The
+// user's role on the workspace is checked again on every request.
+package ports
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the declared ports and the signing secret; defaults to a
+	// directory under the OS temp dir.
+	Dir string
+	// Domain, when set, serves previews on subdomains of it, which must
+	// resolve to this server.
+	Domain string
+	// Scheme is the scheme of preview URLs on Domain; defaults to https.
+	Scheme string
+	// BaseURL is the public URL of the server, prefixed to path-based
+	// preview URLs; they are relative when it is empty.
+	BaseURL string
+	// Secret signs tickets and session cookies. When empty, a random key
+	// is generated and kept in Dir.
+	Secret []byte
+	// TicketTTL is how long a preview URL can be opened; defaults to one
+	// minute.
+	TicketTTL time.Duration
+	// SessionTTL is how long a preview stays signed in; defaults to 12
+	// hours.
+	SessionTTL time.Duration
+	// MaxDeclared limits the declared ports per workspace; defaults to 20.
+	MaxDeclared int
+	// Forwarded, when set, returns the ports a workspace's configuration
+	// forwards, such as those of its devcontainer.json, with their
+	// labels. They are listed like declared ports.
+	Forwarded func(workspaceID string) map[int]string
+}
+
+// Sandboxes locates the processes of workspaces.
+type Sandboxes interface {
+	// Address returns the host at which the workspace's ports are
+	// reachable.
+	Address(ctx context.Context, workspaceID string) (string, error)
+	// Listening lists the ports listened on in the workspace.
+	Listening(ctx context.Context, workspaceID string) ([]terminal.Listener, error)
+}
+
+// Roles reports a user's role on a workspace, "" for none.
+type Roles interface {
+	Role(workspaceID, userID string) (workspace.Role, error)
+}
+
+var (
+	// ErrInvalidPort is returned for ports outside 1-65535.
+	ErrInvalidPort = errors.New("ports: invalid port")
+	// ErrTooMany is returned when a workspace declares too many ports.
+	ErrTooMany = errors.New("ports: too many declared ports")
+	// ErrNotDeclared is returned when undeclaring a port that was not.
+	ErrNotDeclared = errors.New("ports: port not declared")
+	// errBadToken is returned for tickets and sessions that do not verify.
+	errBadToken = errors.New("ports: invalid or expired token")
+)
+
+// Port is a port of a workspace. Declared ports were added by a user, and
+// forwarded ones by the workspace's configuration; both are listed
+// whether or not anything listens on them. Loopback is set
+// when the process only listens on localhost, which the proxy cannot
+// reach.
+type Port struct {
+	Port      int    `json:"port"`
+	Label     string `json:"label,omitempty"`
+	Declared  bool   `json:"declared"`
+	Forwarded bool   `json:"forwarded,omitempty"`
+	Listening bool   `json:"listening"`
+	Loopback  bool   `json:"loopback,omitempty"`
+	URL       string `json:"url"`
+}
+
+// declared is a port a user added.
+type declared struct {
+	Port  int    `json:"port"`
+	Label string `json:"label,omitempty"`
+}
+
+// Service tracks workspace ports and proxies previews to them.
+type Service struct {
+	cfg       Config
+	sandboxes Sandboxes
+	roles     Roles
+	secret    []byte
+
+	mu       sync.Mutex
+	declared map[string][]declared // workspace ID; loaded on first use
+	addrs    map[string]cachedAddr
+	reloads  map[string]*liveReload // by reloadKey
+}
+
+type cachedAddr struct {
+	addr string
+	at   time.Time
+}
+
+// addrTTL is how long a workspace's address is reused before it is looked
+// up again.
+const addrTTL = 15 * time.Second
+
+// New returns a Service, filling unset Config fields with defaults. With
+// a nil roles, previews only require a valid session, as when
+// authentication is off.
+func New(cfg Config, sandboxes Sandboxes, roles Roles) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-ports")
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = "https"
+	}
+	if cfg.TicketTTL <= 0 {
+		cfg.TicketTTL = time.Minute
+	}
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = 12 * time.Hour
+	}
+	if cfg.MaxDeclared <= 0 {
+		cfg.MaxDeclared = 20
+	}
+	cfg.Domain = strings.ToLower(strings.Trim(cfg.Domain, "."))
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("ports: create dir: %w", err)
+	}
+	s := &Service{
+		cfg:       cfg,
+		sandboxes: sandboxes,
+		roles:     roles,
+		secret:    cfg.Secret,
+		declared:  make(map[string][]declared),
+		addrs:     make(map[string]cachedAddr),
+		reloads:   make(map[string]*liveReload),
+	}
+	if len(s.secret) == 0 {
+		secret, err := loadSecret(filepath.Join(cfg.Dir, "secret"))
+		if err != nil {
+			return nil, err
+		}
+		s.secret = secret
+	}
+	return s, nil
+}
+
+// loadSecret reads the signing key at p, generating it on first use.
+func loadSecret(p string) ([]byte, error) {
+	data, err := os.ReadFile(p)
+	if err == nil && len(data) >= 32 {
+		return data, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("ports: read secret: %w", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p, secret, 0o600); err != nil {
+		return nil, fmt.Errorf("ports: write secret: %w", err)
+	}
+	return secret, nil
+}
+
+func validPort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%w: %d", ErrInvalidPort, port)
+	}
+	return nil
+}
+
+// List returns the declared ports of a workspace and those detected as
+// listening, by port number.
+func (s *Service) List(ctx context.Context, workspaceID string) ([]Port, error) {
+	decl, err := s.declaredPorts(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	listening, err := s.sandboxes.Listening(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("ports: detect: %w", err)
+	}
+	byPort := make(map[int]*Port)
+	if s.cfg.Forwarded != nil {
+		for port, label := range s.cfg.Forwarded(workspaceID) {
+			byPort[port] = &Port{Port: port, Label: label, Forwarded: true}
+		}
+	}
+	for _, d := range decl {
+		p := byPort[d.Port]
+		if p == nil {
+			p = &Port{Port: d.Port}
+			byPort[d.Port] = p
+		}
+		p.Declared = true
+		if d.Label != "" {
+			p.Label = d.Label
+		}
+	}
+	for _, l := range listening {
+		p := byPort[l.Port]
+		if p == nil {
+			p = &Port{Port: l.Port}
+			byPort[l.Port] = p
+		}
+		p.Listening, p.Loopback = true, l.Loopback
+	}
+	out := make([]Port, 0, len(byPort))
+	for _, p := range byPort {
+		p.URL = s.URL(workspaceID, p.Port)
+		out = append(out, *p)
+	}
+	slices.SortFunc(out, func(a, b Port) int { return a.Port - b.Port })
+	return out, nil
+}
+
+// Declare adds port to a workspace's ports, or relabels it.
+func (s *Service) Declare(workspaceID string, port int, label string) error {
+	if err := validPort(port); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	decl, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return err
+	}
+	next := slices.Clone(decl)
+	if i := slices.IndexFunc(next, func(d declared) bool { return d.Port == port }); i >= 0 {
+		next[i].Label = label
+	} else {
+		if len(next) >= s.cfg.MaxDeclared {
+			return fmt.Errorf("%w: at most %d", ErrTooMany, s.cfg.MaxDeclared)
+		}
+		next = append(next, declared{Port: port, Label: label})
+		slices.SortFunc(next, func(a, b declared) int { return a.Port - b.Port })
+	}
+	return s.storeLocked(workspaceID, next)
+}
+
+// Undeclare removes a declared port.
+func (s *Service) Undeclare(workspaceID string, port int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	decl, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(decl, func(d declared) bool { return d.Port == port })
+	if i < 0 {
+		return fmt.Errorf("%w: %d", ErrNotDeclared, port)
+	}
+	return s.storeLocked(workspaceID, slices.Delete(slices.Clone(decl), i, i+1))
+}
+
+func (s *Service) declaredPorts(workspaceID string) ([]declared, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(workspaceID)
+}
+
+func (s *Service) loadLocked(workspaceID string) ([]declared, error) {
+	if d, ok := s.declared[workspaceID]; ok {
+		return d, nil
+	}
+	var d []declared
+	data, err := os.ReadFile(filepath.Join(s.cfg.Dir, workspaceID+".json"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("ports: load: %w", err)
+	default:
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, fmt.Errorf("ports: load: %w", err)
+		}
+	}
+	s.declared[workspaceID] = d
+	return d, nil
+}
+
+func (s *Service) storeLocked(workspaceID string, d []declared) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.cfg.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("ports: save: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ports: save: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ports: save: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.cfg.Dir, workspaceID+".json")); err != nil {
+		return fmt.Errorf("ports: save: %w", err)
+	}
+	s.declared[workspaceID] = d
+	return nil
+}
+
+// hostLabel returns the subdomain of a preview. Workspace IDs that are
+// not valid lower-case host names are previewed by path instead.
+func (s *Service) hostLabel(workspaceID string, port int) (string, bool) {
+	label := strconv.Itoa(port) + "-" + workspaceID
+	if s.cfg.Domain == "" || len(label) > 63 || strings.ContainsAny(workspaceID, "_") || strings.ToLower(workspaceID) != workspaceID {
+		return "", false
+	}
+	return label, true
+}
+
+// URL returns the preview URL of a workspace port, without a ticket.
+func (s *Service) URL(workspaceID string, port int) string {
+	if label, ok := s.hostLabel(workspaceID, port); ok {
+		return s.cfg.Scheme + "://" + label + "." + s.cfg.Domain + "/"
+	}
+	return s.cfg.BaseURL + pathPrefix(workspaceID, port)
+}
+
+func pathPrefix(workspaceID string, port int) string {
+	return "/ports/" + workspaceID + "/" + strconv.Itoa(port) + "/"
+}
+
+// OpenURL returns the preview URL of a workspace port with a ticket that
+// signs userID in, valid for Config.TicketTTL.
+func (s *Service) OpenURL(workspaceID string, port int, userID string) (string, error) {
+	if err := validPort(port); err != nil {
+		return "", err
+	}
+	t := s.sign("ticket", workspaceID, port, userID, time.Now().Add(s.cfg.TicketTTL))
+	return s.URL(workspaceID, port) + "?" + ticketParam + "=" + t, nil
+}
+
+// sign returns a token binding a user to a workspace port until exp.
+func (s *Service) sign(kind, workspaceID string, port int, userID string, exp time.Time) string {
+	payload := strings.Join([]string{kind, workspaceID, strconv.Itoa(port), userID, strconv.FormatInt(exp.Unix(), 10)}, "|")
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks a token of kind for the workspace port and returns its
+// user.
+func (s *Service) verify(token, kind, workspaceID string, port int) (string, error) {
+	p64, m64, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errBadToken
+	}
+	payload, err1 := base64.RawURLEncoding.DecodeString(p64)
+	sum, err2 := base64.RawURLEncoding.DecodeString(m64)
+	if err1 != nil || err2 != nil {
+		return "", errBadToken
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return "", errBadToken
+	}
+	f := strings.Split(string(payload), "|")
+	if len(f) != 5 || f[0] != kind || f[1] != workspaceID || f[2] != strconv.Itoa(port) {
+		return "", errBadToken
+	}
+	exp, err := strconv.ParseInt(f[4], 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return "", errBadToken
+	}
+	return f[3], nil
+}
+
+// address returns where a workspace's ports are reachable, caching it
+// briefly so each proxied request does not ask the container runtime.
+func (s *Service) address(ctx context.Context, workspaceID string) (string, error) {
+	s.mu.Lock()
+	c, ok := s.addrs[workspaceID]
+	s.mu.Unlock()
+	if ok && time.Since(c.at) < addrTTL {
+		return c.addr, nil
+	}
+	addr, err := s.sandboxes.Address(ctx, workspaceID)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.addrs[workspaceID] = cachedAddr{addr: addr, at: time.Now()}
+	s.mu.Unlock()
+	return addr, nil
+}
+
+// forget drops the cached address of a workspace, after a proxied request
+// failed to reach it.
+func (s *Service) forget(workspaceID string) {
+	s.mu.Lock()
+	delete(s.addrs, workspaceID)
+	s.mu.Unlock()
+}
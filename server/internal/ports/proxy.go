@@ -0,0 +1,219 @@
+package ports
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+const (
+	// ticketParam carries the ticket of a preview URL.
+	ticketParam = "webide_ticket"
+	// sessionCookie holds a preview's session.
+	sessionCookie = "webide_preview"
+)
+
+// target is the workspace port a request is for.
+type target struct {
+	workspaceID string
+	port        int
+	// prefix is the path the preview is served below, "" on its own host.
+	prefix string
+}
+
+// Middleware serves preview requests and passes all others to next. It
+// belongs outside the IDE's own middleware, since a previewed app's paths
+// are its own and may look like the IDE's.
+func Middleware(s *Service, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := s.targetOf(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.serve(w, r, t)
+	})
+}
+
+// targetOf returns the workspace port r is for, if it is a preview
+// request.
+func (s *Service) targetOf(r *http.Request) (target, bool) {
+	if s.cfg.Domain != "" {
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if label, ok := strings.CutSuffix(host, "."+s.cfg.Domain); ok && !strings.Contains(label, ".") {
+			// Workspace IDs may contain '-', port numbers do not.
+			p, id, _ := strings.Cut(label, "-")
+			port, err := strconv.Atoi(p)
+			if err == nil && validPort(port) == nil && workspace.ValidID(id) {
+				return target{workspaceID: id, port: port}, true
+			}
+		}
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/ports/")
+	if !ok {
+		return target{}, false
+	}
+	seg := strings.SplitN(rest, "/", 3)
+	if len(seg) < 2 || !workspace.ValidID(seg[0]) {
+		return target{}, false
+	}
+	port, err := strconv.Atoi(seg[1])
+	if err != nil || validPort(port) != nil {
+		return target{}, false
+	}
+	return target{workspaceID: seg[0], port: port, prefix: pathPrefix(seg[0], port)}, true
+}
+
+func (s *Service) serve(w http.ResponseWriter, r *http.Request, t target) {
+	if t.prefix != "" && r.URL.Path == strings.TrimSuffix(t.prefix, "/") {
+		http.Redirect(w, r, t.prefix, http.StatusMovedPermanently)
+		return
+	}
+	if ticket := r.URL.Query().Get(ticketParam); ticket != "" {
+		s.signIn(w, r, t, ticket)
+		return
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		httpx.Error(w, http.StatusUnauthorized, "open this preview from the IDE to sign in")
+		return
+	}
+	userID, err := s.verify(c.Value, "session", t.workspaceID, t.port)
+	if err != nil {
+		httpx.Error(w, http.StatusUnauthorized, "preview session expired; open it from the IDE again")
+		return
+	}
+	if !s.allowed(t.workspaceID, userID) {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	if isReloadPath(r, t) {
+		s.serveReload(w, r, t)
+		return
+	}
+	s.proxy(w, r, t)
+}
+
+// signIn exchanges a ticket for a session cookie and redirects to the URL
+// without the ticket, so it is not left in history or sent on.
+func (s *Service) signIn(w http.ResponseWriter, r *http.Request, t target, ticket string) {
+	userID, err := s.verify(ticket, "ticket", t.workspaceID, t.port)
+	if err != nil || !s.allowed(t.workspaceID, userID) {
+		httpx.Error(w, http.StatusUnauthorized, "preview link expired; open it from the IDE again")
+		return
+	}
+	path := t.prefix
+	if path == "" {
+		path = "/"
+	}
+	secure := r.TLS != nil || strings.HasPrefix(s.cfg.BaseURL, "https:")
+	if t.prefix == "" {
+		secure = s.cfg.Scheme == "https"
+	}
+	exp := time.Now().Add(s.cfg.SessionTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.sign("session", t.workspaceID, t.port, userID, exp),
+		Path:     path,
+		Expires:  exp,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	u := *r.URL
+	q := u.Query()
+	q.Del(ticketParam)
+	u.RawQuery = q.Encode()
+	u.Scheme, u.Host = "", ""
+	http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+}
+
+// allowed reports whether userID may still use a workspace's previews.
+func (s *Service) allowed(workspaceID, userID string) bool {
+	if s.roles == nil {
+		return true
+	}
+	role, err := s.roles.Role(workspaceID, userID)
+	if err != nil {
+		slog.Error("preview role", "workspace", workspaceID, "err", err)
+		return false
+	}
+	return role != ""
+}
+
+// proxy forwards r to the workspace port, WebSocket upgrades included.
+func (s *Service) proxy(w http.ResponseWriter, r *http.Request, t target) {
+	addr, err := s.address(r.Context(), t.workspaceID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadGateway, "the workspace is not running")
+		return
+	}
+	backend := &url.URL{Scheme: "http", Host: net.JoinHostPort(addr, strconv.Itoa(t.port))}
+	reload := s.liveReloadOn(t)
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(backend)
+			pr.SetXForwarded()
+			if t.prefix != "" {
+				pr.Out.URL.Path = "/" + strings.TrimPrefix(pr.In.URL.Path, t.prefix)
+				pr.Out.URL.RawPath = ""
+				pr.Out.Header.Set("X-Forwarded-Prefix", strings.TrimSuffix(t.prefix, "/"))
+			}
+			// The app sees the host it is previewed at, as behind any
+			// other reverse proxy.
+			pr.Out.Host = pr.In.Host
+			stripCookies(pr.Out)
+			if reload {
+				// Pages are edited to add the reload script, which
+				// needs them uncompressed.
+				pr.Out.Header.Del("Accept-Encoding")
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			var op *net.OpError
+			if errors.As(err, &op) {
+				s.forget(t.workspaceID)
+			}
+			httpx.Errorf(w, http.StatusBadGateway, "nothing is listening on port %d", t.port)
+		},
+	}
+	rp.ModifyResponse = func(resp *http.Response) error {
+		// Keep the app's absolute redirects inside its preview.
+		loc := resp.Header.Get("Location")
+		if t.prefix != "" && strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") && !strings.HasPrefix(loc, t.prefix) {
+			resp.Header.Set("Location", strings.TrimSuffix(t.prefix, "/")+loc)
+		}
+		if reload {
+			return injectReload(resp, t)
+		}
+		return nil
+	}
+	rp.ServeHTTP(w, r)
+}
+
+// stripCookies removes the preview session and the IDE's auth cookies from
+// a request before it reaches the previewed app.
+func stripCookies(r *http.Request) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		switch c.Name {
+		case sessionCookie, auth.AccessCookie, auth.RefreshCookie, auth.CSRFCookie:
+			continue
+		}
+		r.AddCookie(c)
+	}
+}
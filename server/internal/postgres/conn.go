@@ -0,0 +1,639 @@
+package postgres
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMessage bounds a message from the server, a data row in practice.
+const maxMessage = 256 << 20
+
+// errRolledBack is returned by Commit when the server rolled the
+// transaction back, as it does after an error inside it.
+var errRolledBack = errors.New("postgres: transaction rolled back")
+
+// conn is one session with the server. database/sql never uses it from
+// two goroutines at once.
+type conn struct {
+	nc  net.Conn
+	r   *bufio.Reader
+	buf []byte
+	// in holds the body of the last message read.
+	in []byte
+	// broken is set after an I/O or protocol error, which leaves the
+	// session in an unknown state.
+	broken bool
+}
+
+// startup negotiates TLS, authenticates and waits for the session to
+// become ready.
+func startup(ctx context.Context, nc net.Conn, cfg Config) (*conn, error) {
+	if dl, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(dl)
+		defer nc.SetDeadline(time.Time{})
+	}
+	if cfg.SSLMode != "disable" {
+		var err error
+		if nc, err = negotiateTLS(nc, cfg); err != nil {
+			return nil, err
+		}
+	}
+	c := &conn{nc: nc, r: bufio.NewReader(nc)}
+
+	var e encoder
+	e.int32(196608) // protocol version 3.0
+	for _, kv := range [][2]string{
+		{"user", cfg.User},
+		{"database", cfg.Database},
+		{"application_name", cfg.ApplicationName},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"TimeZone", "UTC"},
+	} {
+		e.str(kv[0])
+		e.str(kv[1])
+	}
+	e.byte(0)
+	c.buf = binary.BigEndian.AppendUint32(c.buf[:0], uint32(len(e)+4))
+	c.buf = append(c.buf, e...)
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+	if err := c.authenticate(cfg); err != nil {
+		return nil, err
+	}
+	for {
+		typ, body, err := c.recv()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case 'Z':
+			return c, nil
+		case 'K':
+			// The key for cancel requests, which are not used.
+		case 'E':
+			return nil, parseError(body)
+		default:
+			return nil, c.unexpected(typ)
+		}
+	}
+}
+
+// negotiateTLS asks the server for TLS as cfg.SSLMode requires.
+func negotiateTLS(nc net.Conn, cfg Config) (net.Conn, error) {
+	var req [8]byte
+	binary.BigEndian.PutUint32(req[0:], 8)
+	binary.BigEndian.PutUint32(req[4:], 80877103)
+	if _, err := nc.Write(req[:]); err != nil {
+		return nil, fmt.Errorf("postgres: connect: %w", err)
+	}
+	var resp [1]byte
+	if _, err := io.ReadFull(nc, resp[:]); err != nil {
+		return nil, fmt.Errorf("postgres: connect: %w", err)
+	}
+	if resp[0] != 'S' {
+		if cfg.SSLMode == "prefer" {
+			return nc, nil
+		}
+		return nil, errors.New("postgres: server does not support TLS")
+	}
+	tc := tls.Client(nc, &tls.Config{
+		ServerName: cfg.Host,
+		// Like libpq, require asks for encryption but not for a
+		// certificate that verifies.
+		InsecureSkipVerify: cfg.SSLMode != "verify-full",
+	})
+	if err := tc.Handshake(); err != nil {
+		return nil, fmt.Errorf("postgres: tls: %w", err)
+	}
+	return tc, nil
+}
+
+// authenticate answers the server's authentication requests.
+func (c *conn) authenticate(cfg Config) error {
+	var sc *scram
+	for {
+		typ, body, err := c.recv()
+		if err != nil {
+			return err
+		}
+		if typ == 'E' {
+			return parseError(body)
+		}
+		if typ != 'R' {
+			return c.unexpected(typ)
+		}
+		d := decoder{b: body}
+		switch code := d.int32(); code {
+		case 0:
+			return nil
+		case 3:
+			var e encoder
+			e.str(cfg.Password)
+			c.queue('p', e)
+		case 5:
+			salt := d.next(4)
+			inner := md5.Sum([]byte(cfg.Password + cfg.User))
+			outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+			var e encoder
+			e.str("md5" + hex.EncodeToString(outer[:]))
+			c.queue('p', e)
+		case 10:
+			var mechanisms []string
+			for len(d.b) > 1 {
+				mechanisms = append(mechanisms, d.str())
+			}
+			if !slices.Contains(mechanisms, "SCRAM-SHA-256") {
+				return fmt.Errorf("postgres: unsupported SASL mechanisms %v", mechanisms)
+			}
+			sc = newSCRAM(cfg.Password)
+			first := sc.clientFirst()
+			var e encoder
+			e.str("SCRAM-SHA-256")
+			e.int32(int32(len(first)))
+			e = append(e, first...)
+			c.queue('p', e)
+		case 11:
+			if sc == nil {
+				return c.unexpected(typ)
+			}
+			final, err := sc.clientFinal(string(d.b))
+			if err != nil {
+				return err
+			}
+			c.queue('p', encoder(final))
+		case 12:
+			if sc == nil {
+				return c.unexpected(typ)
+			}
+			if err := sc.verify(string(d.b)); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("postgres: unsupported authentication method %d", code)
+		}
+		if err := c.flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// queue adds a message to those flush sends.
+func (c *conn) queue(typ byte, body encoder) {
+	c.buf = append(c.buf, typ)
+	c.buf = binary.BigEndian.AppendUint32(c.buf, uint32(len(body)+4))
+	c.buf = append(c.buf, body...)
+}
+
+// flush sends the queued messages.
+func (c *conn) flush() error {
+	_, err := c.nc.Write(c.buf)
+	c.buf = c.buf[:0]
+	if err != nil {
+		c.broken = true
+		return fmt.Errorf("postgres: %w", err)
+	}
+	return nil
+}
+
+// recv reads the next message, skipping notices and the parameter
+// reports the server may send at any time. The body is valid until the
+// next call.
+func (c *conn) recv() (byte, []byte, error) {
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+			c.broken = true
+			return 0, nil, fmt.Errorf("postgres: %w", err)
+		}
+		n := int(binary.BigEndian.Uint32(hdr[1:])) - 4
+		if n < 0 || n > maxMessage {
+			c.broken = true
+			return 0, nil, fmt.Errorf("postgres: message of %d bytes", n)
+		}
+		if cap(c.in) < n {
+			c.in = make([]byte, n)
+		}
+		body := c.in[:n]
+		if _, err := io.ReadFull(c.r, body); err != nil {
+			c.broken = true
+			return 0, nil, fmt.Errorf("postgres: %w", err)
+		}
+		switch hdr[0] {
+		case 'N', 'S', 'A':
+			continue
+		}
+		return hdr[0], body, nil
+	}
+}
+
+func (c *conn) unexpected(typ byte) error {
+	c.broken = true
+	return fmt.Errorf("postgres: unexpected message %q", typ)
+}
+
+// watch interrupts reads and writes on c once ctx is done. The returned
+// function stops watching.
+func (c *conn) watch(ctx context.Context) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		c.nc.SetDeadline(dl)
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			c.nc.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		c.nc.SetDeadline(time.Time{})
+	}
+}
+
+// ctxErr returns ctx's error in place of err when ctx ended the call.
+func ctxErr(ctx context.Context, err error) error {
+	if cerr := ctx.Err(); cerr != nil && err != nil {
+		return cerr
+	}
+	return err
+}
+
+// sendQuery queues the extended-protocol messages that run query with
+// args and flushes them.
+func (c *conn) sendQuery(query string, args []driver.NamedValue) error {
+	var e encoder
+	e.str("")
+	e.str(query)
+	e.int16(0)
+	c.queue('P', e)
+
+	e = e[:0]
+	e.str("")
+	e.str("")
+	e.int16(0) // every parameter in text
+	e.int16(int16(len(args)))
+	for _, a := range args {
+		if a.Name != "" {
+			c.buf = c.buf[:0]
+			return fmt.Errorf("postgres: named argument %s: use $%d", a.Name, a.Ordinal)
+		}
+		v, ok, err := encodeValue(a.Value)
+		if err != nil {
+			c.buf = c.buf[:0]
+			return err
+		}
+		if !ok {
+			e.int32(-1)
+			continue
+		}
+		e.int32(int32(len(v)))
+		e = append(e, v...)
+	}
+	e.int16(0) // every result in text
+	c.queue('B', e)
+
+	e = e[:0]
+	e.byte('P')
+	e.str("")
+	c.queue('D', e)
+
+	e = e[:0]
+	e.str("")
+	e.int32(0)
+	c.queue('E', e)
+	c.queue('S', nil)
+	return c.flush()
+}
+
+// finish reads up to the end of the current query and returns the last
+// command tag and the error the server reported, if any.
+func (c *conn) finish() (tag string, err error) {
+	for {
+		typ, body, rerr := c.recv()
+		if rerr != nil {
+			return "", rerr
+		}
+		switch typ {
+		case 'Z':
+			return tag, err
+		case 'C':
+			d := decoder{b: body}
+			tag = d.str()
+		case 'E':
+			if err == nil {
+				err = parseError(body)
+			}
+		case '1', '2', 'T', 'n', 'D', 'I':
+		default:
+			return "", c.unexpected(typ)
+		}
+	}
+}
+
+// ExecContext implements driver.ExecerContext. Without arguments the
+// query may hold several statements, as migrations do.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) == 0 {
+		tag, err := c.simpleQuery(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return result(tag), nil
+	}
+	if c.broken {
+		return nil, driver.ErrBadConn
+	}
+	defer c.watch(ctx)()
+	if err := c.sendQuery(query, args); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	tag, err := c.finish()
+	if err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	return result(tag), nil
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.broken {
+		return nil, driver.ErrBadConn
+	}
+	stop := c.watch(ctx)
+	if err := c.sendQuery(query, args); err != nil {
+		stop()
+		return nil, ctxErr(ctx, err)
+	}
+	for {
+		typ, body, err := c.recv()
+		if err != nil {
+			stop()
+			return nil, ctxErr(ctx, err)
+		}
+		switch typ {
+		case '1', '2':
+		case 'T':
+			return &rows{c: c, ctx: ctx, stop: stop, cols: parseColumns(body)}, nil
+		case 'n':
+			return &rows{c: c, ctx: ctx, stop: stop}, nil
+		case 'E':
+			var qerr error = parseError(body)
+			if _, err := c.finish(); err != nil {
+				qerr = err
+			}
+			stop()
+			return nil, ctxErr(ctx, qerr)
+		default:
+			stop()
+			return nil, c.unexpected(typ)
+		}
+	}
+}
+
+// simpleQuery runs a query without parameters, such as BEGIN, and returns
+// its command tag.
+func (c *conn) simpleQuery(ctx context.Context, query string) (string, error) {
+	if c.broken {
+		return "", driver.ErrBadConn
+	}
+	defer c.watch(ctx)()
+	var e encoder
+	e.str(query)
+	c.queue('Q', e)
+	if err := c.flush(); err != nil {
+		return "", ctxErr(ctx, err)
+	}
+	tag, err := c.finish()
+	return tag, ctxErr(ctx, err)
+}
+
+// result is the outcome of a statement, read from its command tag such as
+// "INSERT 0 1" or "UPDATE 3".
+type result string
+
+func (r result) LastInsertId() (int64, error) {
+	return 0, errors.New("postgres: LastInsertId is not supported; use RETURNING")
+}
+
+func (r result) RowsAffected() (int64, error) {
+	s := string(r)
+	n, err := strconv.ParseInt(s[strings.LastIndexByte(s, ' ')+1:], 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	q := "BEGIN"
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault:
+	case sql.LevelReadUncommitted:
+		q += " ISOLATION LEVEL READ UNCOMMITTED"
+	case sql.LevelReadCommitted:
+		q += " ISOLATION LEVEL READ COMMITTED"
+	case sql.LevelRepeatableRead, sql.LevelSnapshot:
+		q += " ISOLATION LEVEL REPEATABLE READ"
+	case sql.LevelSerializable:
+		q += " ISOLATION LEVEL SERIALIZABLE"
+	default:
+		return nil, fmt.Errorf("postgres: unsupported isolation level %v", sql.IsolationLevel(opts.Isolation))
+	}
+	if opts.ReadOnly {
+		q += " READ ONLY"
+	}
+	if _, err := c.simpleQuery(ctx, q); err != nil {
+		return nil, err
+	}
+	return tx{c}, nil
+}
+
+// Begin implements driver.Conn.
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+type tx struct{ c *conn }
+
+func (t tx) Commit() error {
+	tag, err := t.c.simpleQuery(context.Background(), "COMMIT")
+	if err == nil && tag == "ROLLBACK" {
+		err = errRolledBack
+	}
+	return err
+}
+
+func (t tx) Rollback() error {
+	_, err := t.c.simpleQuery(context.Background(), "ROLLBACK")
+	return err
+}
+
+// Prepare implements driver.Conn. Statements are not prepared on the
+// server; each execution parses the query again.
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+// Ping implements driver.Pinger.
+func (c *conn) Ping(ctx context.Context) error {
+	_, err := c.simpleQuery(ctx, "")
+	return err
+}
+
+// ResetSession implements driver.SessionResetter.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.broken {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// IsValid implements driver.Validator.
+func (c *conn) IsValid() bool { return !c.broken }
+
+// Close implements driver.Conn.
+func (c *conn) Close() error {
+	if !c.broken {
+		c.nc.SetDeadline(time.Now().Add(time.Second))
+		c.queue('X', nil)
+		c.flush()
+	}
+	return c.nc.Close()
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, s.query, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	out := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		out[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return out
+}
+
+// parseError reads an ErrorResponse.
+func parseError(body []byte) *Error {
+	e := &Error{}
+	d := decoder{b: body}
+	for len(d.b) > 0 {
+		field := d.byte()
+		if field == 0 {
+			break
+		}
+		v := d.str()
+		switch field {
+		case 'S':
+			e.Severity = v
+		case 'C':
+			e.Code = v
+		case 'M':
+			e.Message = v
+		case 'D':
+			e.Detail = v
+		case 'n':
+			e.Constraint = v
+		}
+	}
+	return e
+}
+
+// encoder builds a message body.
+type encoder []byte
+
+func (e *encoder) byte(b byte)   { *e = append(*e, b) }
+func (e *encoder) int16(n int16) { *e = binary.BigEndian.AppendUint16(*e, uint16(n)) }
+func (e *encoder) int32(n int32) { *e = binary.BigEndian.AppendUint32(*e, uint32(n)) }
+func (e *encoder) str(s string)  { *e = append(append(*e, s...), 0) }
+
+// decoder reads a message body. Reading past its end yields zero values.
+type decoder struct {
+	b []byte
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) < 1 {
+		return 0
+	}
+	b := d.b[0]
+	d.b = d.b[1:]
+	return b
+}
+
+func (d *decoder) int16() int16 {
+	return int16(binary.BigEndian.Uint16(d.next(2)))
+}
+
+func (d *decoder) int32() int32 {
+	return int32(binary.BigEndian.Uint32(d.next(4)))
+}
+
+func (d *decoder) str() string {
+	i := slices.Index(d.b, 0)
+	if i < 0 {
+		s := string(d.b)
+		d.b = nil
+		return s
+	}
+	s := string(d.b[:i])
+	d.b = d.b[i+1:]
+	return s
+}
+
+// next returns the next n bytes, padded with zeros past the end.
+func (d *decoder) next(n int) []byte {
+	if len(d.b) < n {
+		b := make([]byte, n)
+		copy(b, d.b)
+		d.b = nil
+		return b
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
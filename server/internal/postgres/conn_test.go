@@ -0,0 +1,604 @@
+package postgres
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	stdpbkdf2 "crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// server is the server end of a connection to a fake PostgreSQL server,
+// which a test scripts message by message.
+type server struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+// readStartup reads a message without a type, a startup message or an
+// SSLRequest, and returns its code and parameters.
+func (s *server) readStartup() (int32, map[string]string) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(s.r, hdr[:]); err != nil {
+		s.t.Errorf("server: read startup: %v", err)
+		return 0, nil
+	}
+	body := make([]byte, binary.BigEndian.Uint32(hdr[:4])-8)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		s.t.Errorf("server: read startup: %v", err)
+		return 0, nil
+	}
+	params := make(map[string]string)
+	d := decoder{b: body}
+	for len(d.b) > 1 {
+		k := d.str()
+		params[k] = d.str()
+	}
+	return int32(binary.BigEndian.Uint32(hdr[4:])), params
+}
+
+// recv reads a message from the client.
+func (s *server) recv() (byte, []byte) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(s.r, hdr[:]); err != nil {
+		s.t.Errorf("server: read: %v", err)
+		return 0, nil
+	}
+	body := make([]byte, binary.BigEndian.Uint32(hdr[1:])-4)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		s.t.Errorf("server: read: %v", err)
+		return 0, nil
+	}
+	return hdr[0], body
+}
+
+// expect reads a message from the client, which must be of type typ.
+func (s *server) expect(typ byte) []byte {
+	got, body := s.recv()
+	if got != typ {
+		s.t.Errorf("server: got message %q, want %q", got, typ)
+	}
+	return body
+}
+
+func (s *server) send(typ byte, body []byte) {
+	msg := append([]byte{typ}, binary.BigEndian.AppendUint32(nil, uint32(len(body)+4))...)
+	if _, err := s.nc.Write(append(msg, body...)); err != nil {
+		s.t.Errorf("server: write: %v", err)
+	}
+}
+
+func (s *server) auth(code int32, data []byte) {
+	var e encoder
+	e.int32(code)
+	s.send('R', append(e, data...))
+}
+
+// ready finishes authentication and reports the session ready, with the
+// messages a real server sends in between.
+func (s *server) ready() {
+	s.auth(0, nil)
+	var e encoder
+	e.str("server_version")
+	e.str("17.0")
+	s.send('S', e)
+	s.send('K', make([]byte, 8))
+	s.readyForQuery()
+}
+
+func (s *server) readyForQuery() { s.send('Z', []byte{'I'}) }
+
+func (s *server) error(code, msg string) {
+	var e encoder
+	for _, f := range [][2]string{{"S", "ERROR"}, {"C", code}, {"M", msg}} {
+		e.byte(f[0][0])
+		e.str(f[1])
+	}
+	e.byte(0)
+	s.send('E', e)
+}
+
+func (s *server) rowDescription(cols ...column) {
+	var e encoder
+	e.int16(int16(len(cols)))
+	for _, c := range cols {
+		e.str(c.name)
+		e = append(e, make([]byte, 6)...)
+		e.int32(int32(c.oid))
+		e = append(e, make([]byte, 8)...)
+	}
+	s.send('T', e)
+}
+
+// dataRow sends a row of the given values, nil for NULL.
+func (s *server) dataRow(values ...any) {
+	var e encoder
+	e.int16(int16(len(values)))
+	for _, v := range values {
+		if v == nil {
+			e.int32(-1)
+			continue
+		}
+		e.int32(int32(len(v.(string))))
+		e = append(e, v.(string)...)
+	}
+	s.send('D', e)
+}
+
+func (s *server) complete(tag string) {
+	var e encoder
+	e.str(tag)
+	s.send('C', e)
+}
+
+// serve plays script as the server of a new connection and returns the
+// client end, which the test must close. The server reads whatever the
+// client still sends once the script ends.
+func serve(t *testing.T, script func(s *server)) net.Conn {
+	t.Helper()
+	cc, sc := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer sc.Close()
+		s := &server{t: t, nc: sc, r: bufio.NewReader(sc)}
+		script(s)
+		io.Copy(io.Discard, s.r)
+	}()
+	t.Cleanup(func() {
+		cc.Close()
+		<-done
+	})
+	return cc
+}
+
+func testConfig() Config {
+	return Config{Host: "localhost", User: "alice", Password: "secret", Database: "app", SSLMode: "disable", ApplicationName: "test"}
+}
+
+func connect(t *testing.T, cfg Config, script func(s *server)) (*conn, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return startup(ctx, serve(t, script), cfg)
+}
+
+func TestStartup(t *testing.T) {
+	c, err := connect(t, testConfig(), func(s *server) {
+		code, params := s.readStartup()
+		if code != 196608 {
+			s.t.Errorf("protocol version %d, want 196608", code)
+		}
+		for k, want := range map[string]string{"user": "alice", "database": "app", "application_name": "test", "client_encoding": "UTF8"} {
+			if params[k] != want {
+				s.t.Errorf("startup parameter %s = %q, want %q", k, params[k], want)
+			}
+		}
+		s.ready()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestPasswordAuth(t *testing.T) {
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	salt := []byte{1, 2, 3, 4}
+	tests := []struct {
+		name string
+		code int32
+		data []byte
+		want string
+	}{
+		{"cleartext", 3, nil, "secret"},
+		{"md5", 5, salt, "md5" + md5Hex(md5Hex("secretalice")+string(salt))},
+	}
+	for _, tt := range tests {
+		c, err := connect(t, testConfig(), func(s *server) {
+			s.readStartup()
+			s.auth(tt.code, tt.data)
+			d := decoder{b: s.expect('p')}
+			if got := d.str(); got != tt.want {
+				s.t.Errorf("%s: password message %q, want %q", tt.name, got, tt.want)
+				s.error("28P01", "password authentication failed")
+				return
+			}
+			s.ready()
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		c.Close()
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	_, err := connect(t, testConfig(), func(s *server) {
+		s.readStartup()
+		s.auth(3, nil)
+		s.expect('p')
+		s.error("28P01", `password authentication failed for user "alice"`)
+	})
+	var pgErr *Error
+	if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Errorf("startup with a wrong password = %v, want SQLSTATE 28P01", err)
+	}
+	_, err = connect(t, testConfig(), func(s *server) {
+		s.readStartup()
+		s.auth(7, nil) // GSSAPI
+	})
+	if err == nil || !strings.Contains(err.Error(), "unsupported authentication method") {
+		t.Errorf("startup with GSSAPI = %v, want unsupported method", err)
+	}
+}
+
+// scramServer plays the server side of SCRAM-SHA-256 for password. With
+// forge set it signs its final message with the wrong key, and stops
+// there as the client does.
+func scramServer(s *server, password string, forge bool) {
+	s.auth(10, []byte("SCRAM-SHA-256-PLUS\x00SCRAM-SHA-256\x00\x00"))
+	d := decoder{b: s.expect('p')}
+	if mech := d.str(); mech != "SCRAM-SHA-256" {
+		s.t.Errorf("SASL mechanism %q", mech)
+	}
+	clientFirst := string(d.next(int(d.int32())))
+	clientBare, ok := strings.CutPrefix(clientFirst, "n,,")
+	if !ok {
+		s.t.Errorf("client-first-message %q", clientFirst)
+	}
+	salt := []byte("0123456789abcdef")
+	const iter = 4096
+	serverFirst := "r=" + scramAttrs(clientBare)["r"] + "srv,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+	s.auth(11, []byte(serverFirst))
+
+	clientFinal := string(s.expect('p'))
+	withoutProof, proof64, _ := strings.Cut(clientFinal, ",p=")
+	salted, _ := stdpbkdf2.Key(sha256.New, password, salt, iter, sha256.Size)
+	clientKey := hmacSum(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	authMessage := clientBare + "," + serverFirst + "," + withoutProof
+	proof, _ := base64.StdEncoding.DecodeString(proof64)
+	sig := hmacSum(storedKey[:], authMessage)
+	if len(proof) != len(sig) {
+		s.t.Errorf("client proof of %d bytes", len(proof))
+		return
+	}
+	for i := range proof {
+		proof[i] ^= sig[i]
+	}
+	if got := sha256.Sum256(proof); !hmac.Equal(got[:], storedKey[:]) {
+		s.error("28P01", "password authentication failed")
+		return
+	}
+	serverKey := hmacSum(salted, "Server Key")
+	if forge {
+		serverKey = hmacSum([]byte("not the password"), "Server Key")
+	}
+	s.auth(12, []byte("v="+base64.StdEncoding.EncodeToString(hmacSum(serverKey, authMessage))))
+	if !forge {
+		s.ready()
+	}
+}
+
+func TestSCRAM(t *testing.T) {
+	c, err := connect(t, testConfig(), func(s *server) {
+		s.readStartup()
+		scramServer(s, "secret", false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	cfg := testConfig()
+	cfg.Password = "wrong"
+	_, err = connect(t, cfg, func(s *server) {
+		s.readStartup()
+		scramServer(s, "secret", false)
+	})
+	var pgErr *Error
+	if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Errorf("SCRAM with a wrong password = %v, want SQLSTATE 28P01", err)
+	}
+
+	_, err = connect(t, testConfig(), func(s *server) {
+		s.readStartup()
+		scramServer(s, "secret", true)
+	})
+	if err == nil || !strings.Contains(err.Error(), "server signature does not match") {
+		t.Errorf("SCRAM with a forged server signature = %v", err)
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	for _, iter := range []int{1, 2, 4096} {
+		want, err := stdpbkdf2.Key(sha256.New, "password", []byte("salt"), iter, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pbkdf2([]byte("password"), []byte("salt"), iter, 40); !reflect.DeepEqual(got, want) {
+			t.Errorf("pbkdf2 with %d iterations = %x, want %x", iter, got, want)
+		}
+	}
+}
+
+// selfSigned returns a TLS certificate for localhost that no root
+// verifies.
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestSSLMode(t *testing.T) {
+	cert := selfSigned(t)
+	tests := []struct {
+		mode string
+		// offer is the server's answer to an SSLRequest, 0 for none
+		// expected.
+		offer byte
+		// tls says the session is encrypted, and err that startup fails
+		// with an error containing it.
+		tls bool
+		err string
+	}{
+		{mode: "disable"},
+		{mode: "prefer", offer: 'N'},
+		{mode: "prefer", offer: 'S', tls: true},
+		{mode: "require", offer: 'N', err: "does not support TLS"},
+		{mode: "require", offer: 'S', tls: true},
+		{mode: "verify-full", offer: 'S', err: "certificate"},
+		{mode: "verify-full", offer: 'N', err: "does not support TLS"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		cfg.SSLMode = tt.mode
+		encrypted := false
+		c, err := connect(t, cfg, func(s *server) {
+			code, _ := s.readStartup()
+			if tt.offer == 0 {
+				if code != 196608 {
+					s.t.Errorf("sslmode=%s: startup code %d, want a startup message", tt.mode, code)
+				}
+				s.ready()
+				return
+			}
+			if code != 80877103 {
+				s.t.Errorf("sslmode=%s: startup code %d, want an SSLRequest", tt.mode, code)
+				return
+			}
+			s.nc.Write([]byte{tt.offer})
+			if tt.offer == 'S' {
+				tc := tls.Server(s.nc, &tls.Config{Certificates: []tls.Certificate{cert}})
+				if tc.Handshake() != nil {
+					return
+				}
+				s.nc, s.r = tc, bufio.NewReader(tc)
+				encrypted = true
+			}
+			if tt.err != "" {
+				return
+			}
+			if code, _ := s.readStartup(); code != 196608 {
+				s.t.Errorf("sslmode=%s: startup code %d after the SSLRequest", tt.mode, code)
+			}
+			s.ready()
+		})
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("sslmode=%s offered %q: startup = %v, want an error about %q", tt.mode, tt.offer, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("sslmode=%s offered %q: %v", tt.mode, tt.offer, err)
+			continue
+		}
+		_, isTLS := c.nc.(*tls.Conn)
+		if isTLS != tt.tls || encrypted != tt.tls {
+			t.Errorf("sslmode=%s offered %q: TLS = %v, want %v", tt.mode, tt.offer, isTLS, tt.tls)
+		}
+		c.Close()
+	}
+}
+
+// connected returns a session with a fake server, which then plays
+// script.
+func connected(t *testing.T, script func(s *server)) *conn {
+	t.Helper()
+	c, err := connect(t, testConfig(), func(s *server) {
+		s.readStartup()
+		s.ready()
+		script(s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// expectQuery reads the extended-protocol messages of a query and returns
+// its text and parameters, nil for NULL.
+func (s *server) expectQuery() (string, []any) {
+	d := decoder{b: s.expect('P')}
+	d.str()
+	query := d.str()
+	d = decoder{b: s.expect('B')}
+	d.str()
+	d.str()
+	d.int16()
+	var params []any
+	for n := d.int16(); n > 0; n-- {
+		size := d.int32()
+		if size < 0 {
+			params = append(params, nil)
+			continue
+		}
+		params = append(params, string(d.next(int(size))))
+	}
+	s.expect('D')
+	s.expect('E')
+	s.expect('S')
+	s.send('1', nil)
+	s.send('2', nil)
+	return query, params
+}
+
+func nextRow(t *testing.T, r driver.Rows) ([]driver.Value, error) {
+	t.Helper()
+	dest := make([]driver.Value, len(r.Columns()))
+	err := r.Next(dest)
+	return dest, err
+}
+
+func TestQueryNulls(t *testing.T) {
+	c := connected(t, func(s *server) {
+		query, params := s.expectQuery()
+		if query != "SELECT $1::int4, $2" || !reflect.DeepEqual(params, []any{nil, "x"}) {
+			s.t.Errorf("query %q with %v", query, params)
+		}
+		s.rowDescription(column{"n", oidInt4}, column{"s", oidText})
+		s.dataRow(nil, "x")
+		s.dataRow("1", nil)
+		s.complete("SELECT 2")
+		s.readyForQuery()
+	})
+	defer c.Close()
+	r, err := c.QueryContext(context.Background(), "SELECT $1::int4, $2", []driver.NamedValue{{Ordinal: 1}, {Ordinal: 2, Value: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Columns(); !reflect.DeepEqual(got, []string{"n", "s"}) {
+		t.Errorf("columns = %v", got)
+	}
+	for _, want := range [][]driver.Value{{nil, "x"}, {int64(1), nil}} {
+		got, err := nextRow(t, r)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("row = %v, %v, want %v", got, err, want)
+		}
+	}
+	if _, err := nextRow(t, r); err != io.EOF {
+		t.Errorf("after the last row: %v, want EOF", err)
+	}
+	r.Close()
+}
+
+func TestQueryErrorMidRows(t *testing.T) {
+	c := connected(t, func(s *server) {
+		s.expectQuery()
+		s.rowDescription(column{"q", oidInt4})
+		s.dataRow("1")
+		s.error("22012", "division by zero")
+		s.readyForQuery()
+
+		// The session is usable after the error.
+		s.expect('Q')
+		s.complete("UPDATE 3")
+		s.readyForQuery()
+	})
+	defer c.Close()
+	r, err := c.QueryContext(context.Background(), "SELECT 1/(2-n) FROM t", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := nextRow(t, r); err != nil || got[0] != int64(1) {
+		t.Errorf("first row = %v, %v", got, err)
+	}
+	_, err = nextRow(t, r)
+	var pgErr *Error
+	if !errors.As(err, &pgErr) || pgErr.Code != "22012" || pgErr.Message != "division by zero" {
+		t.Errorf("second row: %v, want SQLSTATE 22012", err)
+	}
+	r.Close()
+	if c.broken {
+		t.Fatal("session broken by a query error")
+	}
+	res, err := c.ExecContext(context.Background(), "UPDATE t SET n = 0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("RowsAffected = %d, want 3", n)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	c := connected(t, func(s *server) {
+		s.expectQuery()
+		s.error("42P01", `relation "nope" does not exist`)
+		s.readyForQuery()
+		s.expect('Q')
+		s.complete("SELECT 0")
+		s.readyForQuery()
+	})
+	defer c.Close()
+	_, err := c.QueryContext(context.Background(), "SELECT * FROM nope", nil)
+	var pgErr *Error
+	if !errors.As(err, &pgErr) || pgErr.Code != "42P01" {
+		t.Errorf("query = %v, want SQLSTATE 42P01", err)
+	}
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Ping after a query error: %v", err)
+	}
+}
+
+func TestDroppedConnection(t *testing.T) {
+	c := connected(t, func(s *server) {
+		s.expectQuery()
+		s.rowDescription(column{"n", oidInt4})
+		s.nc.Close()
+	})
+	defer c.Close()
+	r, err := c.QueryContext(context.Background(), "SELECT n FROM t", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nextRow(t, r); err == nil || err == io.EOF {
+		t.Errorf("Next on a dropped connection = %v", err)
+	}
+	if c.IsValid() {
+		t.Error("dropped session still valid")
+	}
+	if _, err := c.QueryContext(context.Background(), "SELECT 1", nil); err != driver.ErrBadConn {
+		t.Errorf("query on a dropped session = %v, want ErrBadConn", err)
+	}
+}
@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Type OIDs of the columns decoded into Go values.
+const (
+	oidBool        = 16
+	oidBytea       = 17
+	oidName        = 19
+	oidInt8        = 20
+	oidInt2        = 21
+	oidInt4        = 23
+	oidText        = 25
+	oidOID         = 26
+	oidFloat4      = 700
+	oidFloat8      = 701
+	oidBpchar      = 1042
+	oidVarchar     = 1043
+	oidDate        = 1082
+	oidTimestamp   = 1114
+	oidTimestamptz = 1184
+	oidUUID        = 2950
+)
+
+type column struct {
+	name string
+	oid  uint32
+}
+
+// parseColumns reads a RowDescription.
+func parseColumns(body []byte) []column {
+	d := decoder{b: body}
+	cols := make([]column, d.int16())
+	for i := range cols {
+		cols[i].name = d.str()
+		d.next(6) // table OID and attribute number
+		cols[i].oid = uint32(d.int32())
+		d.next(8) // type size, modifier and format
+	}
+	return cols
+}
+
+// rows reads the rows of a query as they arrive. The session is busy
+// until they are read or closed.
+type rows struct {
+	c    *conn
+	ctx  context.Context
+	stop func()
+	cols []column
+	done bool
+	err  error
+}
+
+func (r *rows) Columns() []string {
+	names := make([]string, len(r.cols))
+	for i, c := range r.cols {
+		names[i] = c.name
+	}
+	return names
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	for {
+		typ, body, err := r.c.recv()
+		if err != nil {
+			r.end()
+			return ctxErr(r.ctx, err)
+		}
+		switch typ {
+		case 'D':
+			if err := decodeRow(body, r.cols, dest); err != nil {
+				r.err = err
+				continue
+			}
+			if r.err != nil {
+				continue
+			}
+			return nil
+		case 'C', 'I':
+		case 'E':
+			if r.err == nil {
+				r.err = parseError(body)
+			}
+		case 'Z':
+			r.end()
+			if r.err != nil {
+				return r.err
+			}
+			return io.EOF
+		default:
+			r.end()
+			return r.c.unexpected(typ)
+		}
+	}
+}
+
+// end stops watching the query's context once the session is free.
+func (r *rows) end() {
+	if !r.done {
+		r.done = true
+		r.stop()
+	}
+}
+
+func (r *rows) Close() error {
+	if r.done {
+		return nil
+	}
+	defer r.end()
+	if _, err := r.c.finish(); err != nil && r.c.broken {
+		return err
+	}
+	return nil
+}
+
+// decodeRow reads a DataRow into dest.
+func decodeRow(body []byte, cols []column, dest []driver.Value) error {
+	d := decoder{b: body}
+	n := int(d.int16())
+	if n != len(cols) || n != len(dest) {
+		return fmt.Errorf("postgres: row of %d columns, want %d", n, len(cols))
+	}
+	for i := range dest {
+		size := d.int32()
+		if size < 0 {
+			dest[i] = nil
+			continue
+		}
+		v, err := decodeValue(cols[i].oid, d.next(int(size)))
+		if err != nil {
+			return fmt.Errorf("postgres: column %s: %w", cols[i].name, err)
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+// decodeValue converts the text form of a value of type oid.
+func decodeValue(oid uint32, b []byte) (driver.Value, error) {
+	s := string(b)
+	switch oid {
+	case oidBool:
+		return s == "t", nil
+	case oidInt2, oidInt4, oidInt8, oidOID:
+		return strconv.ParseInt(s, 10, 64)
+	case oidFloat4, oidFloat8:
+		return strconv.ParseFloat(s, 64)
+	case oidText, oidVarchar, oidBpchar, oidName, oidUUID:
+		return s, nil
+	case oidBytea:
+		if len(s) < 2 || s[:2] != `\x` {
+			return nil, fmt.Errorf("bytea not in hex format")
+		}
+		return hex.DecodeString(s[2:])
+	case oidDate:
+		return parseTime("2006-01-02", s)
+	case oidTimestamp:
+		return parseTime("2006-01-02 15:04:05", s)
+	case oidTimestamptz:
+		t, err := parseTime("2006-01-02 15:04:05-07", s)
+		return t.UTC(), err
+	}
+	return []byte(s), nil
+}
+
+// parseTime parses a date or time the server printed in the ISO date
+// style. Infinite ones become the extremes time.Time can hold.
+func parseTime(layout, s string) (time.Time, error) {
+	switch s {
+	case "infinity":
+		return time.Unix(math.MaxInt64/2, 0).UTC(), nil
+	case "-infinity":
+		return time.Unix(-math.MaxInt64/2, 0).UTC(), nil
+	}
+	return time.Parse(layout, s)
+}
+
+// encodeValue returns the text form of a parameter, or false for NULL.
+func encodeValue(v driver.Value) ([]byte, bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, false, nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), true, nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), true, nil
+	case bool:
+		if v {
+			return []byte("t"), true, nil
+		}
+		return []byte("f"), true, nil
+	case string:
+		return []byte(v), true, nil
+	case []byte:
+		out := make([]byte, 2+hex.EncodedLen(len(v)))
+		copy(out, `\x`)
+		hex.Encode(out[2:], v)
+		return out, true, nil
+	case time.Time:
+		return []byte(v.Format("2006-01-02 15:04:05.999999999-07:00")), true, nil
+	}
+	return nil, false, fmt.Errorf("postgres: unsupported argument type %T", v)
+}
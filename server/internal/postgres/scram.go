@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scram is the client side of a SCRAM-SHA-256 exchange (RFC 5802, RFC
+// 7677), without channel binding.
+type scram struct {
+	password    string
+	nonce       string
+	clientBare  string
+	authMessage string
+	salted      []byte
+}
+
+func newSCRAM(password string) *scram {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return &scram{password: password, nonce: base64.StdEncoding.EncodeToString(b)}
+}
+
+// clientFirst returns the client-first-message. The server takes the
+// user name from the startup message, so it is left empty.
+func (s *scram) clientFirst() string {
+	s.clientBare = "n=,r=" + s.nonce
+	return "n,," + s.clientBare
+}
+
+// clientFinal answers server-first-message with the client's proof.
+func (s *scram) clientFinal(serverFirst string) (string, error) {
+	attrs := scramAttrs(serverFirst)
+	nonce, salt64, iter := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, s.nonce) || len(nonce) == len(s.nonce) {
+		return "", errors.New("postgres: scram: server nonce does not extend ours")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return "", fmt.Errorf("postgres: scram: bad salt: %w", err)
+	}
+	n, err := strconv.Atoi(iter)
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("postgres: scram: bad iteration count %q", iter)
+	}
+	s.salted = pbkdf2([]byte(s.password), salt, n, sha256.Size)
+	clientKey := hmacSum(s.salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	withoutProof := "c=biws,r=" + nonce
+	s.authMessage = s.clientBare + "," + serverFirst + "," + withoutProof
+	proof := hmacSum(storedKey[:], s.authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verify checks the server's signature in server-final-message, which
+// proves that it knows the password too.
+func (s *scram) verify(serverFinal string) error {
+	attrs := scramAttrs(serverFinal)
+	if e := attrs["e"]; e != "" {
+		return fmt.Errorf("postgres: scram: %s", e)
+	}
+	sig, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil {
+		return fmt.Errorf("postgres: scram: bad server signature: %w", err)
+	}
+	if !hmac.Equal(sig, hmacSum(hmacSum(s.salted, "Server Key"), s.authMessage)) {
+		return errors.New("postgres: scram: server signature does not match")
+	}
+	return nil
+}
+
+// scramAttrs splits a SCRAM message into its attributes.
+func scramAttrs(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, kv := range strings.Split(msg, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+func hmacSum(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// pbkdf2 implements RFC 8018 PBKDF2 with HMAC-SHA256.
+func pbkdf2(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	var block [4]byte
+	for i := uint32(1); len(key) < keyLen; i++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block[:], i)
+		prf.Write(block[:])
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for n := 1; n < iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,49 @@
+// Package pty allocates pseudo-terminals for interactive shells, and puts
+// the terminal of a client attaching to one in raw mode.
+package pty
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// ErrUnsupported is returned on platforms without PTY support.
+var ErrUnsupported = errors.New("pty: unsupported on this platform")
+
+// Size is a terminal size in character cells.
+type Size struct {
+	Cols uint16 `json:"cols"`
+	Rows uint16 `json:"rows"`
+}
+
+// Start runs cmd with a new PTY as its controlling terminal and returns the
+// master side. Reads return the terminal's output and writes are delivered
+// as keyboard input. Closing the master hangs up the session.
+func Start(cmd *exec.Cmd, size Size) (*os.File, error) {
+	return start(cmd, size)
+}
+
+// Resize changes the window size of the terminal behind master; the
+// foreground process receives SIGWINCH.
+func Resize(master *os.File, size Size) error {
+	return resize(master, size)
+}
+
+// MakeRaw puts the terminal f in raw mode, so keystrokes are delivered as
+// they are typed and without echo, and returns a func that restores its
+// previous state. It fails if f is not a terminal.
+func MakeRaw(f *os.File) (restore func() error, err error) {
+	return makeRaw(f)
+}
+
+// GetSize returns the window size of the terminal f.
+func GetSize(f *os.File) (Size, error) {
+	return getSize(f)
+}
+
+// NotifySize calls fn with the new size of the terminal f each time its
+// window is resized, until stop is called.
+func NotifySize(f *os.File, fn func(Size)) (stop func()) {
+	return notifySize(f, fn)
+}
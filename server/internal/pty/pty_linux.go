@@ -0,0 +1,149 @@
+//go:build linux
+
+package pty
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(fd, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func open() (master *os.File, slave *os.File, err error) {
+	fd, err := syscall.Open("/dev/ptmx", syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("pty: open ptmx: %w", err)
+	}
+	var unlock int32
+	if err := ioctl(uintptr(fd), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		syscall.Close(fd)
+		return nil, nil, fmt.Errorf("pty: unlock: %w", err)
+	}
+	var n uint32
+	if err := ioctl(uintptr(fd), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		syscall.Close(fd)
+		return nil, nil, fmt.Errorf("pty: get number: %w", err)
+	}
+	name := "/dev/pts/" + strconv.Itoa(int(n))
+	sfd, err := syscall.Open(name, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, nil, fmt.Errorf("pty: open %s: %w", name, err)
+	}
+	// Non-blocking mode lets the runtime poller service the master, so a
+	// Close from another goroutine interrupts a pending Read.
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		syscall.Close(sfd)
+		return nil, nil, fmt.Errorf("pty: set nonblock: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "/dev/ptmx"), os.NewFile(uintptr(sfd), name), nil
+}
+
+func start(cmd *exec.Cmd, size Size) (*os.File, error) {
+	master, slave, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+	if err := resize(master, size); err != nil {
+		master.Close()
+		return nil, err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
+
+func resize(master *os.File, size Size) error {
+	if size.Cols == 0 || size.Rows == 0 {
+		return nil
+	}
+	ws := struct{ Row, Col, X, Y uint16 }{Row: size.Rows, Col: size.Cols}
+	rc, err := master.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ierr error
+	if err := rc.Control(func(fd uintptr) {
+		ierr = ioctl(fd, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+	}); err != nil {
+		return err
+	}
+	if ierr != nil {
+		return fmt.Errorf("pty: resize: %w", ierr)
+	}
+	return nil
+}
+
+func makeRaw(f *os.File) (func() error, error) {
+	fd := f.Fd()
+	var old syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&old))); err != nil {
+		return nil, fmt.Errorf("pty: get attributes: %w", err)
+	}
+	// As cfmakeraw(3).
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&raw))); err != nil {
+		return nil, fmt.Errorf("pty: set attributes: %w", err)
+	}
+	return func() error {
+		return ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&old)))
+	}, nil
+}
+
+func getSize(f *os.File) (Size, error) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if err := ioctl(f.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+		return Size{}, fmt.Errorf("pty: get size: %w", err)
+	}
+	return Size{Cols: ws.Col, Rows: ws.Row}, nil
+}
+
+func notifySize(f *os.File, fn func(Size)) func() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ch:
+				if size, err := getSize(f); err == nil {
+					fn(size)
+				}
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
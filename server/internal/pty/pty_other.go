@@ -0,0 +1,18 @@
+//go:build !linux
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+)
+
+func start(*exec.Cmd, Size) (*os.File, error) { return nil, ErrUnsupported }
+
+func resize(*os.File, Size) error { return ErrUnsupported }
+
+func makeRaw(*os.File) (func() error, error) { return nil, ErrUnsupported }
+
+func getSize(*os.File) (Size, error) { return Size{}, ErrUnsupported }
+
+func notifySize(*os.File, func(Size)) func() { return func() {} }
@@ -0,0 +1,40 @@
+package quota
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Handler serves the usage report.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts the usage route on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/usage", h.usage)
+}
+
+// usage reports the caller's usage this month and their limits.
+func (h *Handler) usage(w http.ResponseWriter, r *http.Request) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "usage is metered per account; sign in")
+		return
+	}
+	rep, err := h.svc.Usage(u.ID)
+	if err != nil {
+		slog.Error("usage", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not read usage")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, rep)
+}
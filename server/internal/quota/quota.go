@@ -0,0 +1,482 @@
+// Package quota meters what each user's sandboxes use and stops users
+// from starting more once they reach their limits, so one user cannot
+// exhaust a shared deployment.
+//
+// Sandboxes are charged for what they reserve rather than what they
+// measurably consume: a run with 2 CPUs and 512 MB for 3 seconds costs 6
+// CPU-seconds and 0.5 GB × 3 s of memory. Usage accrues per calendar month
+// (UTC) and is checked when a run or terminal starts; a sandbox that is
+// already running is never stopped. Storage is the size of the workspaces
+// a user owns, their trash included.
+//
+// Administrators may give a user limits of their own, which replace the
+// server's until they are removed.
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the usage file; defaults to a directory under the OS temp
+	// dir.
+	Dir string
+	// Limits apply to every user without limits of their own; zero fields
+	// get defaults.
+	Limits Limits
+	// TerminalCPUs and TerminalMemoryMB are what a terminal session is
+	// charged for; they default to the workspace container's 2 CPUs and
+	// 2048 MB.
+	TerminalCPUs     float64
+	TerminalMemoryMB int64
+	// Trash, when set, reports the bytes kept in each workspace's trash,
+	// which count as storage too.
+	Trash Trash
+}
+
+// Trash reports the storage a workspace's deleted files take.
+type Trash interface {
+	Size(workspaceID string) (int64, error)
+}
+
+// Limits bounds a user's monthly usage and concurrent sandboxes. Negative
+// values disable a limit.
+type Limits struct {
+	// CPUSeconds defaults to 36000, ten CPU-hours.
+	CPUSeconds float64 `json:"cpuSeconds"`
+	// MemoryGBHours defaults to 50.
+	MemoryGBHours float64 `json:"memoryGbHours"`
+	// StorageBytes bounds the size of the user's workspaces; defaults to
+	// 1 GiB.
+	StorageBytes int64 `json:"storageBytes"`
+	// Sandboxes bounds concurrent runs and terminals; defaults to 3.
+	Sandboxes int `json:"sandboxes"`
+}
+
+// Usage is what a user has used this month, and is using now.
+type Usage struct {
+	CPUSeconds    float64 `json:"cpuSeconds"`
+	MemoryGBHours float64 `json:"memoryGbHours"`
+	StorageBytes  int64   `json:"storageBytes"`
+	Sandboxes     int     `json:"sandboxes"`
+}
+
+// Report is a user's usage with their limits.
+type Report struct {
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	Usage       Usage     `json:"usage"`
+	Limits      Limits    `json:"limits"`
+	// Custom reports that the limits are the user's own rather than the
+	// server's.
+	Custom bool `json:"custom,omitempty"`
+}
+
+// ErrExceeded is returned when a user may not start another sandbox. It
+// is the runner's error, so the runner's handlers recognize it.
+var ErrExceeded = runner.ErrQuotaExceeded
+
+// storageTTL is how long a user's measured storage is reused.
+const storageTTL = time.Minute
+
+// Workspaces lists workspaces, their owners and their directories.
+type Workspaces interface {
+	List() ([]string, error)
+	Owner(id string) (string, error)
+	Open(id string) (string, error)
+}
+
+// record is what the usage file holds for a user.
+type record struct {
+	// Period is the month usage accrued in, as "2006-01".
+	Period        string  `json:"period"`
+	CPUSeconds    float64 `json:"cpuSeconds"`
+	MemoryGBHours float64 `json:"memoryGbHours"`
+}
+
+type storage struct {
+	bytes int64
+	at    time.Time
+}
+
+// Service keeps users' usage.
+type Service struct {
+	cfg        Config
+	workspaces Workspaces
+	now        func() time.Time
+
+	mu      sync.Mutex
+	usage   map[string]*record
+	active  map[string]int
+	storage map[string]storage
+	// custom are the limits administrators set for single users.
+	custom map[string]Limits
+}
+
+// NewService returns a Service, filling unset Config fields with defaults
+// and loading recorded usage.
+func NewService(cfg Config, ws Workspaces) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-quota")
+	}
+	cfg.Limits = cfg.Limits.withDefaults()
+	if cfg.TerminalCPUs <= 0 {
+		cfg.TerminalCPUs = 2
+	}
+	if cfg.TerminalMemoryMB <= 0 {
+		cfg.TerminalMemoryMB = 2048
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("quota: create dir: %w", err)
+	}
+	s := &Service{
+		cfg:        cfg,
+		workspaces: ws,
+		now:        time.Now,
+		usage:      make(map[string]*record),
+		active:     make(map[string]int),
+		storage:    make(map[string]storage),
+		custom:     make(map[string]Limits),
+	}
+	if err := readJSON(s.path(), &s.usage); err != nil {
+		return nil, fmt.Errorf("quota: read usage: %w", err)
+	}
+	if err := readJSON(s.limitsPath(), &s.custom); err != nil {
+		return nil, fmt.Errorf("quota: read limits: %w", err)
+	}
+	return s, nil
+}
+
+// withDefaults fills the zero fields of l with the default limits.
+func (l Limits) withDefaults() Limits {
+	if l.CPUSeconds == 0 {
+		l.CPUSeconds = 36000
+	}
+	if l.MemoryGBHours == 0 {
+		l.MemoryGBHours = 50
+	}
+	if l.StorageBytes == 0 {
+		l.StorageBytes = 1 << 30
+	}
+	if l.Sandboxes == 0 {
+		l.Sandboxes = 3
+	}
+	return l
+}
+
+// readJSON decodes the file at p into v, leaving v as it is when there is
+// no file.
+func readJSON(p string, v any) error {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Start admits a sandbox reserving cpus and memoryMB for the user in ctx,
+// or returns ErrExceeded. The returned function charges the user for the
+// time until it is called, and must be called once the sandbox is done.
+// Requests without a user, as when authentication is off, are not
+// metered.
+func (s *Service) Start(ctx context.Context, cpus float64, memoryMB int64) (stop func(), err error) {
+	u := auth.UserFrom(ctx)
+	if u == nil {
+		return func() {}, nil
+	}
+	stored, err := s.storageOf(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lim, _ := s.limitsLocked(u.ID)
+	rec := s.recordLocked(u.ID)
+	switch {
+	case lim.Sandboxes >= 0 && s.active[u.ID] >= lim.Sandboxes:
+		return nil, fmt.Errorf("%w: limit of %d concurrent sandboxes reached", ErrExceeded, lim.Sandboxes)
+	case lim.CPUSeconds >= 0 && rec.CPUSeconds >= lim.CPUSeconds:
+		return nil, fmt.Errorf("%w: all %g CPU-seconds of this month used", ErrExceeded, lim.CPUSeconds)
+	case lim.MemoryGBHours >= 0 && rec.MemoryGBHours >= lim.MemoryGBHours:
+		return nil, fmt.Errorf("%w: all %g memory GB-hours of this month used", ErrExceeded, lim.MemoryGBHours)
+	case lim.StorageBytes >= 0 && stored > lim.StorageBytes:
+		return nil, fmt.Errorf("%w: workspaces use %d of %d bytes", ErrExceeded, stored, lim.StorageBytes)
+	}
+	s.active[u.ID]++
+	start := s.now()
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.charge(u.ID, cpus, memoryMB, s.now().Sub(start)) })
+	}, nil
+}
+
+// StartTerminal is Start for a terminal session.
+func (s *Service) StartTerminal(ctx context.Context) (stop func(), err error) {
+	return s.Start(ctx, s.cfg.TerminalCPUs, s.cfg.TerminalMemoryMB)
+}
+
+// charge ends one of the user's sandboxes, adding what it reserved for d.
+func (s *Service) charge(userID string, cpus float64, memoryMB int64, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[userID]--; s.active[userID] <= 0 {
+		delete(s.active, userID)
+	}
+	rec := s.recordLocked(userID)
+	rec.CPUSeconds += cpus * d.Seconds()
+	rec.MemoryGBHours += float64(memoryMB) / 1024 * d.Hours()
+	if err := s.save(); err != nil {
+		// The usage stays counted in memory and is saved with the next
+		// charge.
+		slog.Error("save usage", "err", err)
+	}
+}
+
+// Usage reports a user's usage this month with their limits.
+func (s *Service) Usage(userID string) (*Report, error) {
+	stored, err := s.storageOf(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := s.recordLocked(userID)
+	lim, custom := s.limitsLocked(userID)
+	start := periodStart(s.now())
+	return &Report{
+		PeriodStart: start,
+		PeriodEnd:   start.AddDate(0, 1, 0),
+		Usage: Usage{
+			CPUSeconds:    rec.CPUSeconds,
+			MemoryGBHours: rec.MemoryGBHours,
+			StorageBytes:  stored,
+			Sandboxes:     s.active[userID],
+		},
+		Limits: lim,
+		Custom: custom,
+	}, nil
+}
+
+// SetDefaults replaces the server's limits, those of users without limits
+// of their own, as Config.Limits does. They apply to the next sandbox
+// started.
+func (s *Service) SetDefaults(l Limits) {
+	s.mu.Lock()
+	s.cfg.Limits = l.withDefaults()
+	s.mu.Unlock()
+}
+
+// SetLimits gives a user limits of their own, effective for the next
+// sandbox they start. Zero fields keep the server's limit and negative
+// ones disable it, as in Config. A nil l returns the user to the server's
+// limits.
+func (s *Service) SetLimits(userID string, l *Limits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, had := s.custom[userID]
+	if l == nil {
+		delete(s.custom, userID)
+	} else {
+		s.custom[userID] = *l
+	}
+	if err := s.saveLimits(); err != nil {
+		if had {
+			s.custom[userID] = prev
+		} else {
+			delete(s.custom, userID)
+		}
+		return err
+	}
+	return nil
+}
+
+// limitsLocked returns the limits that apply to a user and whether they
+// are the user's own. s.mu must be held.
+func (s *Service) limitsLocked(userID string) (Limits, bool) {
+	lim := s.cfg.Limits
+	c, ok := s.custom[userID]
+	if !ok {
+		return lim, false
+	}
+	if c.CPUSeconds != 0 {
+		lim.CPUSeconds = c.CPUSeconds
+	}
+	if c.MemoryGBHours != 0 {
+		lim.MemoryGBHours = c.MemoryGBHours
+	}
+	if c.StorageBytes != 0 {
+		lim.StorageBytes = c.StorageBytes
+	}
+	if c.Sandboxes != 0 {
+		lim.Sandboxes = c.Sandboxes
+	}
+	return lim, true
+}
+
+// recordLocked returns the user's record for the current month, starting
+// a new one when the month has changed. s.mu must be held.
+func (s *Service) recordLocked(userID string) *record {
+	period := periodStart(s.now()).Format("2006-01")
+	rec, ok := s.usage[userID]
+	if !ok || rec.Period != period {
+		rec = &record{Period: period}
+		s.usage[userID] = rec
+	}
+	return rec
+}
+
+// storageOf returns the bytes the workspaces userID owns take, measured
+// at most once per storageTTL.
+func (s *Service) storageOf(userID string) (int64, error) {
+	s.mu.Lock()
+	st, ok := s.storage[userID]
+	s.mu.Unlock()
+	if ok && s.now().Sub(st.at) < storageTTL {
+		return st.bytes, nil
+	}
+	ids, err := s.workspaces.List()
+	if err != nil {
+		return 0, fmt.Errorf("quota: list workspaces: %w", err)
+	}
+	var total int64
+	for _, id := range ids {
+		owner, err := s.workspaces.Owner(id)
+		if err != nil {
+			return 0, err
+		}
+		if owner != userID {
+			continue
+		}
+		dir, err := s.workspaces.Open(id)
+		if err != nil {
+			return 0, err
+		}
+		n, err := dirSize(dir)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+		if s.cfg.Trash != nil {
+			n, err := s.cfg.Trash.Size(id)
+			if err != nil {
+				return 0, err
+			}
+			total += n
+		}
+	}
+	s.mu.Lock()
+	s.storage[userID] = storage{bytes: total, at: s.now()}
+	s.mu.Unlock()
+	return total, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var n int64
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removed while walking.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			n += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("quota: measure %s: %w", dir, err)
+	}
+	return n, nil
+}
+
+func periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *Service) path() string {
+	return filepath.Join(s.cfg.Dir, "usage.json")
+}
+
+func (s *Service) limitsPath() string {
+	return filepath.Join(s.cfg.Dir, "limits.json")
+}
+
+// save atomically writes the usage file. s.mu must be held.
+func (s *Service) save() error {
+	if err := s.writeJSON(s.path(), s.usage); err != nil {
+		return fmt.Errorf("quota: write usage: %w", err)
+	}
+	return nil
+}
+
+// saveLimits atomically writes the users' own limits. s.mu must be held.
+func (s *Service) saveLimits() error {
+	if err := s.writeJSON(s.limitsPath(), s.custom); err != nil {
+		return fmt.Errorf("quota: write limits: %w", err)
+	}
+	return nil
+}
+
+// writeJSON atomically replaces the file at p with v.
+func (s *Service) writeJSON(p string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.cfg.Dir, "."+filepath.Base(p)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Sandbox meters every Exec of inner against the quota of the user in
+// its context.
+func Sandbox(s *Service, inner runner.Sandbox) runner.Sandbox {
+	return &sandbox{s: s, inner: inner}
+}
+
+type sandbox struct {
+	s     *Service
+	inner runner.Sandbox
+}
+
+func (sb *sandbox) Exec(ctx context.Context, spec runner.Spec) (*runner.ExecResult, error) {
+	stop, err := sb.s.Start(ctx, spec.Limits.CPUs, spec.Limits.MemoryMB)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	return sb.inner.Exec(ctx, spec)
+}
@@ -0,0 +1,262 @@
+// Package ratelimit throttles requests with token buckets, so a single
+// client cannot swamp the execution backend. Each client has one bucket
+// per policy: expensive routes such as runs get a small bucket that
+// refills slowly, and everything else a generous default.
+//
+// Clients are told apart by the personal access token or the user a
+// request was authenticated with, falling back to the client IP address.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Policy is a token bucket: Burst requests at once, refilled at Rate per
+// second.
+type Policy struct {
+	Rate  float64
+	Burst int
+}
+
+// Rule applies a policy to the routes matching Pattern, an http.ServeMux
+// pattern such as "POST /api/workspaces/{id}/tests". The most specific
+// pattern wins, as in a ServeMux. Rules with the same Name share buckets.
+type Rule struct {
+	Name    string
+	Pattern string
+	Policy  Policy
+}
+
+// Config configures a Limiter.
+type Config struct {
+	// Default applies to routes no rule matches; defaults to 20 requests
+	// a second with bursts of 100.
+	Default Policy
+	// Rules defaults to DefaultRules.
+	Rules []Rule
+	// TrustProxy takes client addresses from X-Forwarded-For, for servers
+	// behind a reverse proxy.
+	TrustProxy bool
+}
+
+var (
+	runPolicy  = Policy{Rate: 0.5, Burst: 10}
+	authPolicy = Policy{Rate: 0.2, Burst: 10}
+	// Anonymous runs from embedded examples, per client address.
+	embedPolicy = Policy{Rate: 0.1, Burst: 5}
+)
+
+// DefaultRules throttle what compiles or runs code, most of all the
+// anonymous playground, and sign-in attempts,
+// and let builds download modules from the module proxy.
+var DefaultRules = []Rule{
+	{Name: "run", Pattern: "POST /api/run", Policy: runPolicy},
+	{Name: "run", Pattern: "GET /ws/run", Policy: runPolicy},
+	{Name: "run", Pattern: "POST /api/builds", Policy: runPolicy},
+	{Name: "run", Pattern: "POST /embed/{id}/run", Policy: runPolicy},
+	{Name: "embed", Pattern: "POST /embed/run", Policy: embedPolicy},
+	{Name: "run", Pattern: "POST /api/workspaces/{id}/tests", Policy: runPolicy},
+	{Name: "run", Pattern: "POST /api/workspaces/{id}/benchmarks", Policy: runPolicy},
+	{Name: "run", Pattern: "POST /api/workspaces/{id}/lint", Policy: runPolicy},
+	{Name: "run", Pattern: "POST /api/workspaces/{id}/wasm/build", Policy: runPolicy},
+	{Name: "auth", Pattern: "POST /api/auth/login", Policy: authPolicy},
+	{Name: "auth", Pattern: "POST /api/auth/signup", Policy: authPolicy},
+	// A build downloads its modules in one burst.
+	{Name: "goproxy", Pattern: "GET /goproxy/", Policy: Policy{Rate: 200, Burst: 2000}},
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps the buckets.
+type Limiter struct {
+	cfg   Config
+	rules *http.ServeMux
+	byPat map[string]Rule
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter returns a Limiter, filling unset Config fields with
+// defaults. It panics on invalid or conflicting rule patterns, like
+// http.ServeMux.
+func NewLimiter(cfg Config) *Limiter {
+	if cfg.Default.Rate <= 0 || cfg.Default.Burst <= 0 {
+		cfg.Default = Policy{Rate: 20, Burst: 100}
+	}
+	if cfg.Rules == nil {
+		cfg.Rules = DefaultRules
+	}
+	// SetPolicy changes the rules in place.
+	cfg.Rules = slices.Clone(cfg.Rules)
+	l := &Limiter{
+		cfg:     cfg,
+		rules:   http.NewServeMux(),
+		byPat:   make(map[string]Rule),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+	for _, rule := range cfg.Rules {
+		l.rules.Handle(rule.Pattern, http.NotFoundHandler())
+		l.byPat[rule.Pattern] = rule
+	}
+	return l
+}
+
+// Allow takes a token from the bucket of r's client under the policy for
+// r's route. When there is none, it returns how long until there will be.
+func (l *Limiter) Allow(r *http.Request) (ok bool, retryAfter time.Duration) {
+	_, pattern := l.rules.Handler(r)
+	client := l.client(r)
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	name, p := "default", l.cfg.Default
+	if rule, ok := l.byPat[pattern]; ok && pattern != "" {
+		name, p = rule.Name, rule.Policy
+	}
+	key := name + "\x00" + client
+	l.sweepLocked(now)
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(p.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(p.Burst), b.tokens+now.Sub(b.last).Seconds()*p.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / p.Rate * float64(time.Second))
+}
+
+// SetPolicy replaces the policy of the rules named name, or the default
+// policy for "default", on a running Limiter. Buckets keep their tokens,
+// up to the new burst.
+func (l *Limiter) SetPolicy(name string, p Policy) error {
+	if p.Rate <= 0 || p.Burst <= 0 {
+		return fmt.Errorf("ratelimit: %s: rate and burst must be positive", name)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if name == "default" {
+		l.cfg.Default = p
+		return nil
+	}
+	found := false
+	for i, rule := range l.cfg.Rules {
+		if rule.Name == name {
+			l.cfg.Rules[i].Policy = p
+			l.byPat[rule.Pattern] = l.cfg.Rules[i]
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("ratelimit: no rule named %q", name)
+	}
+	return nil
+}
+
+// Policy returns the policy of the rules named name, or the default
+// policy for "default" and names no rule has.
+func (l *Limiter) Policy(name string) Policy {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rule := range l.cfg.Rules {
+		if rule.Name == name {
+			return rule.Policy
+		}
+	}
+	return l.cfg.Default
+}
+
+// Names returns the names SetPolicy takes: "default" and those of the
+// rules.
+func (l *Limiter) Names() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := []string{"default"}
+	for _, rule := range l.cfg.Rules {
+		if !slices.Contains(names, rule.Name) {
+			names = append(names, rule.Name)
+		}
+	}
+	return names
+}
+
+// ParsePolicy parses a policy written "rate,burst", such as "0.5,10" for
+// bursts of 10 requests refilled at one every two seconds.
+func ParsePolicy(s string) (Policy, error) {
+	rate, burst, ok := strings.Cut(s, ",")
+	r, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+	if err != nil || !ok || r <= 0 {
+		return Policy{}, fmt.Errorf("want rate,burst with a positive rate per second, not %q", s)
+	}
+	b, err := strconv.Atoi(strings.TrimSpace(burst))
+	if err != nil || b <= 0 {
+		return Policy{}, fmt.Errorf("want rate,burst with a positive burst, not %q", s)
+	}
+	return Policy{Rate: r, Burst: b}, nil
+}
+
+// sweepLocked drops, at most once a minute, the buckets that have been
+// idle long enough to be full again. l.mu must be held.
+func (l *Limiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	longest := float64(l.cfg.Default.Burst) / l.cfg.Default.Rate
+	for _, rule := range l.cfg.Rules {
+		longest = math.Max(longest, float64(rule.Policy.Burst)/rule.Policy.Rate)
+	}
+	for k, b := range l.buckets {
+		if now.Sub(b.last).Seconds() > longest {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// client names who sent r: their token, their account or their address.
+func (l *Limiter) client(r *http.Request) string {
+	if t := auth.TokenFrom(r.Context()); t != nil {
+		return "token:" + t.ID
+	}
+	if u := auth.UserFrom(r.Context()); u != nil {
+		return "user:" + u.ID
+	}
+	return "ip:" + httpx.ClientIP(r, l.cfg.TrustProxy)
+}
+
+// Middleware answers requests over their limit with 429 and a
+// Retry-After header, and passes the others to next. It belongs inside
+// the auth middleware, so authenticated clients are limited by account.
+func Middleware(l *Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.Allow(r)
+		if !ok {
+			secs := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			httpx.Errorf(w, http.StatusTooManyRequests, "rate limit exceeded; retry in %d s", secs)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,271 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+)
+
+// clock is a time the tests move by hand.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// testLimiter returns a Limiter on a clock the test moves.
+func testLimiter(cfg Config) (*Limiter, *clock) {
+	c := &clock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(cfg)
+	l.now = c.now
+	return l, c
+}
+
+func request(method, path string) *http.Request {
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	return r
+}
+
+func TestAllowRefill(t *testing.T) {
+	l, c := testLimiter(Config{Default: Policy{Rate: 2, Burst: 3}, Rules: []Rule{}})
+	allow := func(want bool, retry time.Duration) {
+		t.Helper()
+		ok, wait := l.Allow(request("GET", "/api/workspaces"))
+		if ok != want || wait != retry {
+			t.Fatalf("Allow at %s = %v, %s; want %v, %s", c.t.Format(time.TimeOnly+".000"), ok, wait, want, retry)
+		}
+	}
+
+	// The burst goes at once, and then a token comes every half second.
+	allow(true, 0)
+	allow(true, 0)
+	allow(true, 0)
+	allow(false, 500*time.Millisecond)
+	c.advance(200 * time.Millisecond)
+	allow(false, 300*time.Millisecond)
+	c.advance(300 * time.Millisecond)
+	allow(true, 0)
+	allow(false, 500*time.Millisecond)
+
+	// Denied requests take nothing, and an idle bucket fills up to the
+	// burst only.
+	c.advance(time.Hour)
+	allow(true, 0)
+	allow(true, 0)
+	allow(true, 0)
+	allow(false, 500*time.Millisecond)
+}
+
+func TestAllowRules(t *testing.T) {
+	l, _ := testLimiter(Config{
+		Default: Policy{Rate: 1, Burst: 1},
+		Rules: []Rule{
+			{Name: "run", Pattern: "POST /api/run", Policy: Policy{Rate: 1, Burst: 2}},
+			{Name: "run", Pattern: "POST /api/workspaces/{id}/tests", Policy: Policy{Rate: 1, Burst: 2}},
+			{Name: "auth", Pattern: "POST /api/auth/login", Policy: Policy{Rate: 1, Burst: 1}},
+		},
+	})
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/api/workspaces", true},
+		{"GET", "/api/workspaces/ws1/files", false},
+		// Rules have their own buckets, and share them by name.
+		{"POST", "/api/run", true},
+		{"POST", "/api/workspaces/ws1/tests", true},
+		{"POST", "/api/workspaces/ws2/tests", false},
+		{"POST", "/api/auth/login", true},
+		{"POST", "/api/auth/login", false},
+		// Other methods on a rule's path are not its.
+		{"GET", "/api/run", false},
+	}
+	for _, tt := range tests {
+		if ok, _ := l.Allow(request(tt.method, tt.path)); ok != tt.want {
+			t.Errorf("Allow(%s %s) = %v, want %v", tt.method, tt.path, ok, tt.want)
+		}
+	}
+}
+
+func TestClientKeys(t *testing.T) {
+	l, _ := testLimiter(Config{Default: Policy{Rate: 1, Burst: 1}, Rules: []Rule{}})
+	alice := &auth.User{ID: "alice"}
+	as := func(r *http.Request, u *auth.User, tok *auth.APIToken) *http.Request {
+		ctx := r.Context()
+		if u != nil {
+			ctx = auth.WithUser(ctx, u)
+		}
+		if tok != nil {
+			ctx = auth.WithToken(ctx, tok)
+		}
+		return r.WithContext(ctx)
+	}
+	forwarded := func(ip string) *http.Request {
+		r := request("GET", "/")
+		r.Header.Set("X-Forwarded-For", ip+", 10.0.0.1")
+		return r
+	}
+	tests := []struct {
+		name string
+		r    *http.Request
+		want string
+	}{
+		{"address", request("GET", "/"), "ip:192.0.2.1"},
+		{"forwarded, not trusted", forwarded("198.51.100.7"), "ip:192.0.2.1"},
+		{"user", as(request("GET", "/"), alice, nil), "user:alice"},
+		{"token over user", as(request("GET", "/"), alice, &auth.APIToken{ID: "t1"}), "token:t1"},
+	}
+	for _, tt := range tests {
+		if got := l.client(tt.r); got != tt.want {
+			t.Errorf("%s: client = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	trusting, _ := testLimiter(Config{TrustProxy: true})
+	if got := trusting.client(forwarded("198.51.100.7")); got != "ip:198.51.100.7" {
+		t.Errorf("client behind a trusted proxy = %q", got)
+	}
+
+	// Each key has its own bucket: the address's is spent, the user's and
+	// each of their tokens' are not.
+	for _, tt := range []struct {
+		name string
+		r    *http.Request
+		want bool
+	}{
+		{"address", request("GET", "/"), true},
+		{"address again", request("GET", "/"), false},
+		{"user", as(request("GET", "/"), alice, nil), true},
+		{"token", as(request("GET", "/"), alice, &auth.APIToken{ID: "t1"}), true},
+		{"other token", as(request("GET", "/"), alice, &auth.APIToken{ID: "t2"}), true},
+		{"user again", as(request("GET", "/"), alice, nil), false},
+		{"token again", as(request("GET", "/"), alice, &auth.APIToken{ID: "t1"}), false},
+		{"other user", as(request("GET", "/"), &auth.User{ID: "bob"}, nil), true},
+	} {
+		if ok, _ := l.Allow(tt.r); ok != tt.want {
+			t.Errorf("%s: Allow = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l, c := testLimiter(Config{Default: Policy{Rate: 0.4, Burst: 1}, Rules: []Rule{}})
+	h := Middleware(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, request("GET", "/api/workspaces"))
+		return rec
+	}
+	if rec := serve(); rec.Code != http.StatusNoContent || rec.Header().Get("Retry-After") != "" {
+		t.Fatalf("first request = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// The wait is rounded up to whole seconds.
+	for _, want := range []string{"3", "2", "1"} {
+		rec := serve()
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != want {
+			t.Errorf("at %s, request over the limit = %d, Retry-After %q; want 429, %s", c.t.Format(time.TimeOnly), rec.Code, rec.Header().Get("Retry-After"), want)
+		}
+		c.advance(time.Second)
+	}
+	c.advance(time.Second / 2)
+	if rec := serve(); rec.Code != http.StatusNoContent {
+		t.Errorf("request once refilled = %d", rec.Code)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, c := testLimiter(Config{
+		Default: Policy{Rate: 1, Burst: 5},
+		Rules:   []Rule{{Name: "slow", Pattern: "POST /api/run", Policy: Policy{Rate: 0.01, Burst: 1}}},
+	})
+	from := func(ip, method, path string) *http.Request {
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = ip + ":1234"
+		return r
+	}
+	l.Allow(from("192.0.2.1", "GET", "/"))
+	l.Allow(from("192.0.2.1", "POST", "/api/run"))
+	l.Allow(from("192.0.2.2", "GET", "/"))
+	if len(l.buckets) != 3 {
+		t.Fatalf("%d buckets, want 3", len(l.buckets))
+	}
+
+	// Buckets are kept until they could have refilled under the slowest
+	// policy, 100 s, and looked at no more than once a minute.
+	c.advance(90 * time.Second)
+	l.Allow(from("192.0.2.3", "GET", "/"))
+	if len(l.buckets) != 4 {
+		t.Errorf("after 90 s, %d buckets, want 4", len(l.buckets))
+	}
+	if ok, _ := l.Allow(from("192.0.2.1", "POST", "/api/run")); ok {
+		t.Error("a bucket still refilling was dropped")
+	}
+	c.advance(30 * time.Second)
+	l.Allow(from("192.0.2.3", "GET", "/"))
+	if len(l.buckets) != 4 {
+		t.Errorf("30 s after a sweep, %d buckets, want 4", len(l.buckets))
+	}
+	c.advance(31 * time.Second)
+	l.Allow(from("192.0.2.3", "GET", "/"))
+	if _, ok := l.buckets["default\x00ip:192.0.2.2"]; ok || len(l.buckets) != 2 {
+		t.Errorf("after 151 s, buckets %v; want those used in the last 100 s", keys(l.buckets))
+	}
+}
+
+func keys(m map[string]*bucket) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Policy
+		ok   bool
+	}{
+		{"0.5,10", Policy{Rate: 0.5, Burst: 10}, true},
+		{" 20 , 100 ", Policy{Rate: 20, Burst: 100}, true},
+		{"20", Policy{}, false},
+		{"0,10", Policy{}, false},
+		{"1,0", Policy{}, false},
+		{"1,1.5", Policy{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParsePolicy(%q) = %+v, %v; want %+v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	l, _ := testLimiter(Config{Rules: []Rule{{Name: "run", Pattern: "POST /api/run", Policy: Policy{Rate: 1, Burst: 1}}}})
+	r := func() *http.Request { return request("POST", "/api/run") }
+	l.Allow(r())
+	if ok, _ := l.Allow(r()); ok {
+		t.Fatal("second run allowed")
+	}
+	if err := l.SetPolicy("run", Policy{Rate: 1, Burst: 3}); err != nil {
+		t.Fatal(err)
+	}
+	// The bucket keeps its tokens: none.
+	if ok, _ := l.Allow(r()); ok {
+		t.Error("run allowed straight after raising the burst")
+	}
+	if got := l.Policy("run"); got != (Policy{Rate: 1, Burst: 3}) {
+		t.Errorf("Policy(run) = %+v", got)
+	}
+	if err := l.SetPolicy("lint", Policy{Rate: 1, Burst: 1}); err == nil {
+		t.Error("SetPolicy of an unknown rule succeeded")
+	}
+	if err := l.SetPolicy("default", Policy{Rate: 0, Burst: 1}); err == nil {
+		t.Error("SetPolicy with a zero rate succeeded")
+	}
+}
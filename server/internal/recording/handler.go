@@ -0,0 +1,349 @@
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// tick is how often playback hands out the entries that are due.
+const tick = 50 * time.Millisecond
+
+// Handler serves the recording API for workspaces.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler for svc. wsOpts configures the WebSocket
+// upgrade for playback and may be nil.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the recording routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/recordings", h.list)
+	mux.HandleFunc("POST /api/workspaces/{id}/recordings", h.start)
+	mux.HandleFunc("GET /api/workspaces/{id}/recordings/{rec}", h.get)
+	mux.HandleFunc("POST /api/workspaces/{id}/recordings/{rec}/stop", h.stop)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/recordings/{rec}", h.delete)
+	mux.HandleFunc("GET /api/workspaces/{id}/recordings/{rec}/timeline", h.timeline)
+	mux.HandleFunc("GET /ws/workspaces/{id}/recordings/{rec}", h.play)
+}
+
+func (h *Handler) open(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := h.workspaces.Open(r.PathValue("id")); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	recs, err := h.svc.List(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, recs)
+}
+
+type startRequest struct {
+	Title string `json:"title"`
+	// Input asks for terminal input to be recorded.
+	Input bool `json:"input"`
+}
+
+func (h *Handler) start(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	var req startRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rec, err := h.svc.Start(r.Context(), r.PathValue("id"), req.Title, req.Input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, rec)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	rec, err := h.svc.Get(r.PathValue("id"), r.PathValue("rec"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, rec)
+}
+
+func (h *Handler) stop(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	rec, err := h.svc.Stop(r.PathValue("id"), r.PathValue("rec"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, rec)
+}
+
+// delete removes a recording, which only the user who started it and the
+// workspace's owner may.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	id, recID := r.PathValue("id"), r.PathValue("rec")
+	rec, err := h.svc.Get(id, recID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if workspace.RoleFrom(r.Context()) != workspace.RoleOwner {
+		if u := auth.UserFrom(r.Context()); u == nil || u.ID != rec.UserID {
+			httpx.Error(w, http.StatusForbidden, "only the recording's author and the workspace owner may delete it")
+			return
+		}
+	}
+	if err := h.svc.Delete(id, recID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// timeline sends the timeline as JSON Lines, for clients that replay it
+// themselves.
+func (h *Handler) timeline(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	f, _, err := h.svc.Timeline(r.PathValue("id"), r.PathValue("rec"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	io.Copy(w, f)
+}
+
+// clientFrame controls playback: {"type": "pause"}, {"type": "resume"},
+// {"type": "speed", "speed": 2} or {"type": "seek", "t": 60000}.
+type clientFrame struct {
+	Type  string  `json:"type"`
+	Speed float64 `json:"speed,omitempty"`
+	T     float64 `json:"t,omitempty"`
+}
+
+// startFrame opens playback with the recording played.
+type startFrame struct {
+	Type      string     `json:"type"`
+	Recording *Recording `json:"recording"`
+}
+
+// entriesFrame carries the entries that came due, and the position.
+type entriesFrame struct {
+	Type    string            `json:"type"`
+	T       int64             `json:"t"`
+	Entries []json.RawMessage `json:"entries"`
+}
+
+// stateFrame reports the position after a control frame, a seek ("reset",
+// after which the client starts over from empty files and terminals) or
+// the end of the timeline ("end").
+type stateFrame struct {
+	Type   string  `json:"type"`
+	T      int64   `json:"t"`
+	Speed  float64 `json:"speed"`
+	Paused bool    `json:"paused"`
+}
+
+// errorFrame reports a failure.
+type errorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// play handles /ws/workspaces/{id}/recordings/{rec}, which replays the
+// recording at ?speed= (default 1) from ?from= milliseconds, waiting at
+// most ?maxGap= milliseconds of recorded time between entries when set.
+// Entries before the starting position are sent at once. At the end of
+// the timeline playback pauses rather than closing, so the client can
+// seek back; a recording in progress plays on as it is recorded.
+func (h *Handler) play(w http.ResponseWriter, r *http.Request) {
+	id, recID := r.PathValue("id"), r.PathValue("rec")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	q := r.URL.Query()
+	speed, from, maxGap := 1.0, 0.0, int64(0)
+	var err error
+	if v := q.Get("speed"); v != "" {
+		if speed, err = strconv.ParseFloat(v, 64); err != nil || !validSpeed(speed) {
+			httpx.Errorf(w, http.StatusBadRequest, "speed must be between %g and %g", minSpeed, maxSpeed)
+			return
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if from, err = strconv.ParseFloat(v, 64); err != nil || from < 0 {
+			httpx.Error(w, http.StatusBadRequest, "from must be a number of milliseconds")
+			return
+		}
+	}
+	if v := q.Get("maxGap"); v != "" {
+		if maxGap, err = strconv.ParseInt(v, 10, 64); err != nil || maxGap < 0 {
+			httpx.Error(w, http.StatusBadRequest, "maxGap must be a number of milliseconds")
+			return
+		}
+	}
+	f, rec, err := h.svc.Timeline(id, recID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	controls := make(chan clientFrame, 16)
+	go func() {
+		defer cancel()
+		for {
+			var c clientFrame
+			if err := conn.ReadJSON(&c); err != nil {
+				return
+			}
+			select {
+			case controls <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	p := newPlayer(f)
+	p.speed, p.pos, p.maxGap = speed, from, maxGap
+	if conn.WriteJSON(startFrame{Type: "start", Recording: rec}) != nil {
+		return
+	}
+	state := func(typ string) error {
+		return conn.WriteJSON(stateFrame{Type: typ, T: int64(p.pos), Speed: p.speed, Paused: p.paused})
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	last, ended := time.Now(), false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case c := <-controls:
+			var err error
+			switch c.Type {
+			case "pause":
+				p.paused = true
+				err = state("state")
+			case "resume":
+				p.paused, ended = false, false
+				err = state("state")
+			case "speed":
+				if !validSpeed(c.Speed) {
+					err = conn.WriteJSON(errorFrame{Type: "error", Error: fmt.Sprintf("speed must be between %g and %g", minSpeed, maxSpeed)})
+					break
+				}
+				p.speed = c.Speed
+				err = state("state")
+			case "seek":
+				if err = p.seek(c.T); err != nil {
+					slog.Error("seek recording", "workspace", id, "id", recID, "err", err)
+					return
+				}
+				ended = false
+				err = state("reset")
+			default:
+				err = conn.WriteJSON(errorFrame{Type: "error", Error: fmt.Sprintf("unknown frame type %q", c.Type)})
+			}
+			if err != nil {
+				return
+			}
+		case now := <-ticker.C:
+			p.advance(float64(now.Sub(last)) / float64(time.Millisecond))
+			last = now
+			for {
+				batch, err := p.due()
+				if err != nil {
+					slog.Error("read recording", "workspace", id, "id", recID, "err", err)
+					conn.WriteJSON(errorFrame{Type: "error", Error: "could not read the recording"})
+					return
+				}
+				if len(batch) == 0 {
+					break
+				}
+				if conn.WriteJSON(entriesFrame{Type: "entries", T: int64(p.pos), Entries: batch}) != nil {
+					return
+				}
+			}
+			if ended || p.paused {
+				continue
+			}
+			done, err := p.atEnd()
+			if err != nil {
+				slog.Error("read recording", "workspace", id, "id", recID, "err", err)
+				return
+			}
+			if done && !h.svc.recording(id, recID) {
+				p.pos = min(p.pos, float64(max(rec.DurationMs, p.nextT)))
+				p.paused, ended = true, true
+				if state("end") != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func validSpeed(v float64) bool { return v >= minSpeed && v <= maxSpeed }
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "recording not found")
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrActive), errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("recording", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "recording request failed")
+	}
+}
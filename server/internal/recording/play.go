@@ -0,0 +1,123 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// Playback speeds accepted, as multiples of the recorded pace.
+const (
+	minSpeed = 0.1
+	maxSpeed = 64.0
+)
+
+// maxBatch caps the entries of one frame sent to a player.
+const maxBatch = 256
+
+// player reads a timeline in order, handing out the entries that are due
+// as the playback position advances.
+type player struct {
+	f       *os.File
+	r       *bufio.Reader
+	partial []byte // a line still being written
+	next    json.RawMessage
+	nextT   int64
+
+	// pos is the playback position in recorded milliseconds.
+	pos    float64
+	speed  float64
+	paused bool
+	// maxGap, when positive, caps the recorded milliseconds waited between
+	// entries, skipping idle stretches.
+	maxGap int64
+}
+
+func newPlayer(f *os.File) *player {
+	return &player{f: f, r: bufio.NewReaderSize(f, 64<<10), speed: 1}
+}
+
+// seek restarts the timeline so that the entries up to t are due.
+func (p *player) seek(t float64) error {
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	p.r.Reset(p.f)
+	p.partial, p.next = nil, nil
+	p.pos = max(t, 0)
+	return nil
+}
+
+// peek reads the next entry, if it has been written. It reports false at
+// the end of what has been written so far.
+func (p *player) peek() (bool, error) {
+	if p.next != nil {
+		return true, nil
+	}
+	for {
+		line, err := p.r.ReadBytes('\n')
+		if len(p.partial) > 0 {
+			line = append(p.partial, line...)
+			p.partial = nil
+		}
+		if errors.Is(err, io.EOF) {
+			// The rest of the line may not be written yet.
+			p.partial = bytes.Clone(line)
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var e struct {
+			T int64 `json:"t"`
+		}
+		if err := json.Unmarshal(line, &e); err != nil {
+			// Skip what a crash left half written.
+			continue
+		}
+		p.next, p.nextT = json.RawMessage(line), e.T
+		return true, nil
+	}
+}
+
+// due returns up to maxBatch entries at or before the position.
+func (p *player) due() ([]json.RawMessage, error) {
+	var out []json.RawMessage
+	for len(out) < maxBatch {
+		ok, err := p.peek()
+		if err != nil || !ok {
+			return out, err
+		}
+		if float64(p.nextT) > p.pos {
+			break
+		}
+		out = append(out, p.next)
+		p.next = nil
+	}
+	return out, nil
+}
+
+// advance moves the position on by elapsed wall milliseconds at the
+// playback speed, skipping ahead past gaps longer than maxGap.
+func (p *player) advance(elapsed float64) {
+	if p.paused {
+		return
+	}
+	p.pos += elapsed * p.speed
+	if p.maxGap > 0 && p.next != nil && float64(p.nextT)-p.pos > float64(p.maxGap) {
+		p.pos = float64(p.nextT - p.maxGap)
+	}
+}
+
+// atEnd reports whether every entry written has been handed out.
+func (p *player) atEnd() (bool, error) {
+	ok, err := p.peek()
+	return !ok, err
+}
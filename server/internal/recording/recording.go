@@ -0,0 +1,665 @@
+// Package recording records workspace sessions for playback: the edits
+// made in collaborative editing, the traffic of terminal sessions and the
+// events of the workspace, such as runs finishing and files being saved.
+// Instructors use recordings for walkthroughs, and to see how a student
+// arrived at a solution.
+//
+// Recording is opt-in: nothing is recorded until someone starts a
+// recording of the workspace, and terminal input, which may hold
+// passwords, only when asked for.
+//
+// A recording's timeline is JSON Lines, one Entry per line in the order
+// things happened, each stamped with the milliseconds since the recording
+// started. Edits are byte offsets into the file's text, and the text of a
+// file is recorded once, before its first edit, so the timeline is small
+// and replaying it needs no CRDT.
+package recording
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/collab"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the recordings; defaults to a directory under the OS temp
+	// dir.
+	Dir string
+	// MaxBytes caps the timeline of a recording, which stops when it is
+	// reached; defaults to 64 MiB.
+	MaxBytes int64
+	// MaxDuration caps the length of a recording; defaults to 4 hours.
+	MaxDuration time.Duration
+	// MaxRecordings caps the recordings kept per workspace; defaults to
+	// 100.
+	MaxRecordings int
+	// MaxFileBytes caps the saved files whose text is recorded; larger
+	// saves are recorded as events only. Defaults to 1 MiB.
+	MaxFileBytes int
+	// FlushInterval is how often timelines are written to disk; defaults
+	// to 1 second.
+	FlushInterval time.Duration
+}
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Bus is where workspace events come from; see events.Bus.
+type Bus interface {
+	Subscribe(fn func(events.Event)) (cancel func())
+}
+
+var (
+	// ErrNotFound is returned for recordings that do not exist.
+	ErrNotFound = errors.New("recording: not found")
+	// ErrInvalid is returned for malformed requests.
+	ErrInvalid = errors.New("recording: invalid request")
+	// ErrActive is returned when starting a recording of a workspace
+	// that is being recorded.
+	ErrActive = errors.New("recording: the workspace is already being recorded")
+	// ErrTooMany is returned when a workspace has Config.MaxRecordings.
+	ErrTooMany = errors.New("recording: too many recordings")
+)
+
+// Kind says what an Entry records.
+type Kind string
+
+const (
+	// KindOpen is the text of a file before its first edit.
+	KindOpen Kind = "open"
+	// KindEdit replaces Delete bytes at Offset of the file with Text.
+	KindEdit Kind = "edit"
+	// KindFile is the text of a file saved through the file API.
+	KindFile Kind = "file"
+	// KindInput and KindOutput are the traffic of a terminal session.
+	KindInput  Kind = "input"
+	KindOutput Kind = "output"
+	// KindEvent is another event of the workspace.
+	KindEvent Kind = "event"
+)
+
+// Entry is one line of a timeline. The short keys keep timelines small.
+type Entry struct {
+	// T is the milliseconds since the recording started.
+	T    int64  `json:"t"`
+	Kind Kind   `json:"k"`
+	Path string `json:"p,omitempty"`
+	// Session names the terminal session.
+	Session string `json:"s,omitempty"`
+	// User is who made the edit, or the email of who caused the event.
+	User   string `json:"u,omitempty"`
+	Offset int    `json:"o,omitempty"`
+	Delete int    `json:"d,omitempty"`
+	// Text is the text of a file, inserted by an edit, or of terminal
+	// traffic.
+	Text string `json:"x,omitempty"`
+	// Event and Data are the type and data of an event.
+	Event string         `json:"e,omitempty"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+// Why a recording ended.
+const (
+	EndStopped  = "stopped"
+	EndSize     = "size limit"
+	EndDuration = "time limit"
+	EndShutdown = "server shutdown"
+	// EndInterrupted is reported for recordings the server was stopped
+	// during without a chance to end them.
+	EndInterrupted = "interrupted"
+)
+
+// Recording describes a recording.
+type Recording struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// UserID and By are who started it.
+	UserID string `json:"userId,omitempty"`
+	By     string `json:"by,omitempty"`
+	// Input reports whether terminal input is recorded.
+	Input     bool       `json:"input"`
+	Active    bool       `json:"active"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	// Ended says why it ended; see EndStopped and the others.
+	Ended      string `json:"ended,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Entries    int64  `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// Service records workspaces. It implements collab.Recorder and
+// terminal.Recorder.
+type Service struct {
+	cfg         Config
+	workspaces  Workspaces
+	now         func() time.Time
+	unsubscribe func()
+
+	mu     sync.Mutex
+	active map[string]*active // by workspace
+}
+
+// active is a recording in progress.
+type active struct {
+	workspaceID string
+	timeline    *os.File
+	start       time.Time
+	stop        chan struct{}
+	done        chan struct{}
+	stopOnce    sync.Once
+
+	mu    sync.Mutex
+	rec   Recording
+	buf   bytes.Buffer
+	seen  map[string]bool // files whose text is recorded
+	ended bool
+}
+
+// New returns a Service recording the events of bus, filling unset Config
+// fields with defaults.
+func New(cfg Config, wm Workspaces, bus Bus) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-recordings")
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	if cfg.MaxDuration <= 0 {
+		cfg.MaxDuration = 4 * time.Hour
+	}
+	if cfg.MaxRecordings <= 0 {
+		cfg.MaxRecordings = 100
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = 1 << 20
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("recording: create dir: %w", err)
+	}
+	s := &Service{cfg: cfg, workspaces: wm, now: time.Now, active: make(map[string]*active)}
+	s.unsubscribe = bus.Subscribe(s.receive)
+	return s, nil
+}
+
+// Close ends the recordings in progress.
+func (s *Service) Close() {
+	s.unsubscribe()
+	s.mu.Lock()
+	all := make([]*active, 0, len(s.active))
+	for _, a := range s.active {
+		all = append(all, a)
+	}
+	s.mu.Unlock()
+	for _, a := range all {
+		s.end(a, EndShutdown)
+	}
+}
+
+const maxTitleLen = 200
+
+// Start starts recording the workspace, as the user of ctx. input asks
+// for terminal input to be recorded too.
+func (s *Service) Start(ctx context.Context, workspaceID, title string, input bool) (*Recording, error) {
+	title = strings.TrimSpace(title)
+	if len(title) > maxTitleLen || !utf8.ValidString(title) {
+		return nil, fmt.Errorf("%w: titles are at most %d bytes of text", ErrInvalid, maxTitleLen)
+	}
+	now := s.now().UTC()
+	if title == "" {
+		title = "Recording of " + now.Format("2006-01-02 15:04")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[workspaceID] != nil {
+		return nil, ErrActive
+	}
+	recs, err := s.listLocked(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) >= s.cfg.MaxRecordings {
+		return nil, fmt.Errorf("%w: a workspace keeps at most %d; delete some first", ErrTooMany, s.cfg.MaxRecordings)
+	}
+	if err := os.MkdirAll(s.dir(workspaceID), 0o700); err != nil {
+		return nil, fmt.Errorf("recording: create dir: %w", err)
+	}
+	rec := Recording{ID: newID(), Title: title, Input: input, Active: true, StartedAt: now}
+	if u := auth.UserFrom(ctx); u != nil {
+		rec.UserID, rec.By = u.ID, u.Name
+		if rec.By == "" {
+			rec.By = u.Email
+		}
+	}
+	f, err := os.OpenFile(s.timelinePath(workspaceID, rec.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("recording: create timeline: %w", err)
+	}
+	if err := s.writeMeta(workspaceID, rec); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	a := &active{
+		workspaceID: workspaceID,
+		timeline:    f,
+		start:       now,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		rec:         rec,
+		seen:        make(map[string]bool),
+	}
+	s.active[workspaceID] = a
+	go s.run(a)
+	return &rec, nil
+}
+
+// Stop ends the recording id of the workspace.
+func (s *Service) Stop(workspaceID, id string) (*Recording, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	s.mu.Lock()
+	a := s.active[workspaceID]
+	s.mu.Unlock()
+	if a == nil || a.rec.ID != id {
+		// It has ended already; report how.
+		return s.Get(workspaceID, id)
+	}
+	return s.end(a, EndStopped), nil
+}
+
+// Get returns the recording id of the workspace.
+func (s *Service) Get(workspaceID, id string) (*Recording, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.active[workspaceID]; a != nil && a.rec.ID == id {
+		rec := a.snapshot(s.now())
+		return &rec, nil
+	}
+	return s.readMeta(workspaceID, id)
+}
+
+// List returns the recordings of the workspace, newest first.
+func (s *Service) List(workspaceID string) ([]Recording, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked(workspaceID)
+}
+
+func (s *Service) listLocked(workspaceID string) ([]Recording, error) {
+	entries, err := os.ReadDir(s.dir(workspaceID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Recording{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("recording: list: %w", err)
+	}
+	out := []Recording{}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validID(id) {
+			continue
+		}
+		if a := s.active[workspaceID]; a != nil && a.rec.ID == id {
+			out = append(out, a.snapshot(s.now()))
+			continue
+		}
+		rec, err := s.readMeta(workspaceID, id)
+		if err != nil {
+			slog.Warn("skipping unreadable recording", "workspace", workspaceID, "id", id, "err", err)
+			continue
+		}
+		out = append(out, *rec)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	return out, nil
+}
+
+// Delete removes the recording id of the workspace, ending it first if it
+// is in progress.
+func (s *Service) Delete(workspaceID, id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	a := s.active[workspaceID]
+	s.mu.Unlock()
+	if a != nil && a.rec.ID == id {
+		s.end(a, EndStopped)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.metaPath(workspaceID, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("recording: delete: %w", err)
+	}
+	if err := os.Remove(s.timelinePath(workspaceID, id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("recording: delete: %w", err)
+	}
+	return nil
+}
+
+// Timeline opens the timeline of the recording id of the workspace. The
+// timeline of a recording in progress grows as it is read.
+func (s *Service) Timeline(workspaceID, id string) (*os.File, *Recording, error) {
+	rec, err := s.Get(workspaceID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(s.timelinePath(workspaceID, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("recording: open timeline: %w", err)
+	}
+	return f, rec, nil
+}
+
+// recording reports whether the recording id of the workspace is in
+// progress.
+func (s *Service) recording(workspaceID, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.active[workspaceID]
+	return a != nil && a.rec.ID == id
+}
+
+// Recording implements collab.Recorder.
+func (s *Service) Recording(workspaceID, path string) (edits, text bool) {
+	a := s.lookup(workspaceID)
+	if a == nil {
+		return false, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ended {
+		return false, false
+	}
+	return true, !a.seen[path]
+}
+
+// Edited implements collab.Recorder.
+func (s *Service) Edited(workspaceID, path, editor, text string, edits []collab.Edit) {
+	a := s.lookup(workspaceID)
+	if a == nil {
+		return
+	}
+	now := s.now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.seen[path] {
+		a.seen[path] = true
+		a.addLocked(s, now, Entry{Kind: KindOpen, Path: path, Text: text})
+	}
+	for _, e := range edits {
+		a.addLocked(s, now, Entry{Kind: KindEdit, Path: path, User: editor, Offset: e.Offset, Delete: e.Delete, Text: e.Insert})
+	}
+}
+
+// Terminal implements terminal.Recorder.
+func (s *Service) Terminal(workspaceID, session string, input bool, data []byte) {
+	a := s.lookup(workspaceID)
+	if a == nil {
+		return
+	}
+	kind := KindOutput
+	if input {
+		if !a.rec.Input {
+			return
+		}
+		kind = KindInput
+	}
+	now := s.now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.addLocked(s, now, Entry{Kind: kind, Session: session, Text: string(data)})
+}
+
+// receive records an event of a workspace being recorded. Saved files are
+// recorded with their text, which later edits apply to.
+func (s *Service) receive(e events.Event) {
+	a := s.lookup(e.Workspace)
+	if a == nil {
+		return
+	}
+	entry := Entry{Kind: KindEvent, User: e.Email, Event: e.Type, Data: e.Data}
+	if p, _ := e.Data["path"].(string); e.Type == events.FileSaved && p != "" {
+		if text, ok := s.read(e.Workspace, p); ok {
+			entry = Entry{Kind: KindFile, Path: p, User: e.Email, Text: text}
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if entry.Kind == KindFile {
+		a.seen[entry.Path] = true
+	}
+	a.addLocked(s, e.Time, entry)
+}
+
+// read returns the text of a saved file, if it is small enough.
+func (s *Service) read(workspaceID, p string) (string, bool) {
+	dir, err := s.workspaces.Open(workspaceID)
+	if err != nil {
+		return "", false
+	}
+	fsys, err := files.New(dir)
+	if err != nil {
+		return "", false
+	}
+	if e, err := fsys.Stat(p); err != nil || e.Type != files.TypeFile || e.Size > int64(s.cfg.MaxFileBytes) {
+		return "", false
+	}
+	data, err := fsys.ReadFile(p)
+	if err != nil || !utf8.Valid(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+func (s *Service) lookup(workspaceID string) *active {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active[workspaceID]
+}
+
+// addLocked appends e, as of now, to the timeline, ending the recording
+// once it is full. a.mu must be held.
+func (a *active) addLocked(s *Service, now time.Time, e Entry) {
+	if a.ended {
+		return
+	}
+	e.T = max(now.Sub(a.start).Milliseconds(), 0)
+	data, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("encode recording entry", "err", err)
+		return
+	}
+	if a.rec.Bytes+int64(len(data))+1 > s.cfg.MaxBytes {
+		a.ended = true
+		go s.end(a, EndSize)
+		return
+	}
+	a.buf.Write(data)
+	a.buf.WriteByte('\n')
+	a.rec.Bytes += int64(len(data)) + 1
+	a.rec.Entries++
+}
+
+// snapshot returns the recording as of now. a.mu must not be held.
+func (a *active) snapshot(now time.Time) Recording {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec := a.rec
+	rec.DurationMs = now.Sub(a.start).Milliseconds()
+	return rec
+}
+
+// run writes the timeline of a to disk until the recording ends.
+func (s *Service) run(a *active) {
+	defer close(a.done)
+	tick := time.NewTicker(s.cfg.FlushInterval)
+	defer tick.Stop()
+	limit := time.NewTimer(s.cfg.MaxDuration)
+	defer limit.Stop()
+	for {
+		select {
+		case <-tick.C:
+			a.flush()
+		case <-limit.C:
+			go s.end(a, EndDuration)
+		case <-a.stop:
+			a.flush()
+			if err := a.timeline.Close(); err != nil {
+				slog.Error("close recording", "workspace", a.workspaceID, "id", a.rec.ID, "err", err)
+			}
+			return
+		}
+	}
+}
+
+// flush writes the buffered entries to the timeline.
+func (a *active) flush() {
+	a.mu.Lock()
+	data := bytes.Clone(a.buf.Bytes())
+	a.buf.Reset()
+	a.mu.Unlock()
+	if len(data) == 0 {
+		return
+	}
+	if _, err := a.timeline.Write(data); err != nil {
+		slog.Error("write recording", "workspace", a.workspaceID, "id", a.rec.ID, "err", err)
+	}
+}
+
+// end stops recording a for reason and returns the finished recording.
+// Only the first reason counts.
+func (s *Service) end(a *active, reason string) *Recording {
+	s.mu.Lock()
+	if s.active[a.workspaceID] == a {
+		delete(s.active, a.workspaceID)
+	}
+	s.mu.Unlock()
+	a.mu.Lock()
+	a.ended = true
+	if a.rec.Ended == "" {
+		a.rec.Ended = reason
+	}
+	a.mu.Unlock()
+	a.stopOnce.Do(func() { close(a.stop) })
+	<-a.done
+
+	now := s.now().UTC()
+	rec := a.snapshot(now)
+	rec.Active, rec.EndedAt = false, &now
+	if err := s.writeMeta(a.workspaceID, rec); err != nil {
+		slog.Error("save recording", "workspace", a.workspaceID, "id", rec.ID, "err", err)
+	}
+	return &rec
+}
+
+func (s *Service) dir(workspaceID string) string {
+	return filepath.Join(s.cfg.Dir, workspaceID)
+}
+
+func (s *Service) metaPath(workspaceID, id string) string {
+	return filepath.Join(s.dir(workspaceID), id+".json")
+}
+
+func (s *Service) timelinePath(workspaceID, id string) string {
+	return filepath.Join(s.dir(workspaceID), id+".jsonl")
+}
+
+// readMeta reads a recording that is not in progress here. One that still
+// claims to be was cut short by the server stopping.
+func (s *Service) readMeta(workspaceID, id string) (*Recording, error) {
+	data, err := os.ReadFile(s.metaPath(workspaceID, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("recording: read: %w", err)
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("recording: read: %w", err)
+	}
+	if rec.Active {
+		rec.Active, rec.Ended = false, EndInterrupted
+		if fi, err := os.Stat(s.timelinePath(workspaceID, id)); err == nil {
+			end := fi.ModTime().UTC()
+			rec.EndedAt = &end
+			rec.DurationMs = max(end.Sub(rec.StartedAt).Milliseconds(), 0)
+			rec.Bytes = fi.Size()
+		}
+	}
+	return &rec, nil
+}
+
+// writeMeta atomically writes the description of a recording.
+func (s *Service) writeMeta(workspaceID string, rec Recording) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir(workspaceID), ".recording-*")
+	if err != nil {
+		return fmt.Errorf("recording: write: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("recording: write: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("recording: write: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.metaPath(workspaceID, rec.ID)); err != nil {
+		return fmt.Errorf("recording: write: %w", err)
+	}
+	return nil
+}
+
+var idRE = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+func validID(id string) bool { return idRE.MatchString(id) }
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
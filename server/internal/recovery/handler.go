@@ -0,0 +1,140 @@
+package recovery
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the draft routes.
+type Handler struct {
+	store      *Store
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler keeping drafts in store.
+func NewHandler(store *Store, wm Workspaces) *Handler {
+	return &Handler{store: store, workspaces: wm}
+}
+
+// Register mounts the draft routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/drafts", h.list)
+	mux.HandleFunc("GET /api/workspaces/{id}/drafts/{path...}", h.get)
+	mux.HandleFunc("PUT /api/workspaces/{id}/drafts/{path...}", h.save)
+	mux.HandleFunc("POST /api/workspaces/{id}/drafts/{path...}", h.restore)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/drafts/{path...}", h.discard)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (id, dir string, ok bool) {
+	id = r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", false
+	}
+	return id, dir, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	drafts, err := h.store.List(id, dir)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"drafts": drafts})
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.store.Get(id, dir, r.PathValue("path"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, d)
+}
+
+type saveRequest struct {
+	Base    string `json:"base"`
+	Content string `json:"content"`
+}
+
+// save records a dirty buffer. Editors call it as the buffer changes, at
+// most every second or so; the server coalesces the writes.
+func (h *Handler) save(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var req saveRequest
+	// Escaping may make the JSON of a draft up to six times its size.
+	if err := httpx.DecodeJSON(w, r, &req, int64(h.store.cfg.MaxBytes)*6+4096); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.store.Save(id, r.PathValue("path"), req.Base, req.Content); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// restore writes a draft into its file. A draft whose file changed since
+// it was started is refused with 409 unless ?force=1.
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.store.Restore(id, dir, r.PathValue("path"), r.URL.Query().Get("force") == "1")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", e.ETag())
+	httpx.JSON(w, http.StatusOK, e)
+}
+
+func (h *Handler) discard(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.Discard(id, r.PathValue("path")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "no draft for this file")
+	case errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrTooMany), errors.Is(err, ErrConflict):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("draft", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "draft failed")
+	}
+}
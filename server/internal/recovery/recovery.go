@@ -0,0 +1,396 @@
+// Package recovery keeps the unsaved contents of editor buffers, so work
+// survives a browser crash or a dropped connection. Editors send a dirty
+// buffer as it changes; the latest contents are written to a store outside
+// the workspace once the buffer has been quiet for Config.Debounce, and
+// never touch the file itself until the user restores them.
+//
+// Each draft remembers the ETag of the file it was edited from. A draft
+// whose file changed since is reported as conflicting, and a draft whose
+// contents were saved to the file is dropped.
+package recovery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir holds the drafts, one subdirectory per workspace; defaults to a
+	// directory under the OS temp dir.
+	Dir string
+	// Debounce is how long a buffer must go without changes before its
+	// draft is written; defaults to 2 seconds.
+	Debounce time.Duration
+	// MaxBytes caps one draft; defaults to 1 MiB.
+	MaxBytes int
+	// MaxDrafts caps the drafts per workspace; defaults to 100.
+	MaxDrafts int
+	// MaxAge is how long drafts are kept; defaults to 7 days.
+	MaxAge time.Duration
+}
+
+var (
+	// ErrNotFound is returned for paths without a draft.
+	ErrNotFound = errors.New("recovery: no draft")
+	// ErrTooLarge is returned for drafts over Config.MaxBytes.
+	ErrTooLarge = errors.New("recovery: draft too large")
+	// ErrTooMany is returned when a workspace is at Config.MaxDrafts.
+	ErrTooMany = errors.New("recovery: too many drafts")
+	// ErrConflict is returned by Restore when the file changed since the
+	// draft's base.
+	ErrConflict = errors.New("recovery: file changed since the draft was started")
+)
+
+// Draft is the unsaved contents of a buffer.
+type Draft struct {
+	Path string `json:"path"`
+	// Base is the ETag of the file the buffer was opened from, or "" for
+	// a new file.
+	Base      string    `json:"base,omitempty"`
+	Content   string    `json:"content,omitempty"`
+	Size      int       `json:"size"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Conflict reports that the file changed since Base, so restoring the
+	// draft would overwrite someone else's save.
+	Conflict bool `json:"conflict"`
+}
+
+// Store holds the drafts of all workspaces.
+type Store struct {
+	cfg Config
+	now func() time.Time
+
+	writeMu sync.Mutex // orders writes and removals of draft files
+
+	mu      sync.Mutex
+	pending map[string]*pending // workspace ID + "\x00" + path
+	closed  bool
+}
+
+// pending is a draft not yet written.
+type pending struct {
+	workspace string
+	draft     Draft
+	timer     *time.Timer
+}
+
+// NewStore returns a Store, filling unset Config fields with defaults.
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-recovery")
+	}
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = 2 * time.Second
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+	if cfg.MaxDrafts <= 0 {
+		cfg.MaxDrafts = 100
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 7 * 24 * time.Hour
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("recovery: create dir: %w", err)
+	}
+	return &Store{cfg: cfg, now: time.Now, pending: make(map[string]*pending)}, nil
+}
+
+// Save records the contents of the buffer for path, edited from the file
+// version base. The draft is written once the buffer has been quiet for
+// Config.Debounce.
+func (s *Store) Save(workspaceID, path, base, content string) error {
+	p, err := files.Clean(path)
+	if err != nil || p == "" {
+		return fmt.Errorf("%w: %q", files.ErrInvalidPath, path)
+	}
+	if len(content) > s.cfg.MaxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, s.cfg.MaxBytes)
+	}
+	key := workspaceID + "\x00" + p
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("recovery: store closed")
+	}
+	pd, ok := s.pending[key]
+	if !ok {
+		if _, err := os.Stat(s.file(workspaceID, p)); err != nil {
+			if n := s.countLocked(workspaceID); n >= s.cfg.MaxDrafts {
+				return fmt.Errorf("%w: %d drafts", ErrTooMany, n)
+			}
+		}
+		pd = &pending{workspace: workspaceID}
+		pd.timer = time.AfterFunc(s.cfg.Debounce, func() { s.flush(key) })
+		s.pending[key] = pd
+	} else {
+		pd.timer.Reset(s.cfg.Debounce)
+	}
+	pd.draft = Draft{Path: p, Base: base, Content: content, Size: len(content), UpdatedAt: s.now().UTC()}
+	return nil
+}
+
+// countLocked counts the drafts of a workspace, written or pending.
+func (s *Store) countLocked(workspaceID string) int {
+	des, _ := os.ReadDir(filepath.Join(s.cfg.Dir, workspaceID))
+	n := 0
+	for _, de := range des {
+		if strings.HasSuffix(de.Name(), ".json") {
+			n++
+		}
+	}
+	for _, pd := range s.pending {
+		if pd.workspace == workspaceID {
+			if _, err := os.Stat(s.file(workspaceID, pd.draft.Path)); err != nil {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// flush writes the pending draft key.
+func (s *Store) flush(key string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.Lock()
+	pd, ok := s.pending[key]
+	if ok {
+		delete(s.pending, key)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := s.write(pd.workspace, pd.draft); err != nil {
+		slog.Error("write draft", "workspace", pd.workspace, "path", pd.draft.Path, "err", err)
+	}
+}
+
+// Close writes every pending draft.
+func (s *Store) Close() {
+	s.mu.Lock()
+	s.closed = true
+	keys := make([]string, 0, len(s.pending))
+	for key, pd := range s.pending {
+		pd.timer.Stop()
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+	for _, key := range keys {
+		s.flush(key)
+	}
+}
+
+// file is where the draft for path is written. Paths are hashed so that
+// any path maps to one flat, safe name.
+func (s *Store) file(workspaceID, path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(s.cfg.Dir, workspaceID, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (s *Store) write(workspaceID string, d Draft) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(s.cfg.Dir, workspaceID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("recovery: write draft: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".draft-*")
+	if err != nil {
+		return fmt.Errorf("recovery: write draft: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("recovery: write draft: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("recovery: write draft: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.file(workspaceID, d.Path)); err != nil {
+		return fmt.Errorf("recovery: write draft: %w", err)
+	}
+	return nil
+}
+
+// read returns the latest draft for path, pending or written.
+func (s *Store) read(workspaceID, path string) (Draft, error) {
+	s.mu.Lock()
+	pd, ok := s.pending[workspaceID+"\x00"+path]
+	var d Draft
+	if ok {
+		d = pd.draft
+	}
+	s.mu.Unlock()
+	if ok {
+		return d, nil
+	}
+	return s.readFile(s.file(workspaceID, path))
+}
+
+func (s *Store) readFile(name string) (Draft, error) {
+	var d Draft
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, ErrNotFound
+	}
+	if err != nil {
+		return d, fmt.Errorf("recovery: read draft: %w", err)
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return d, fmt.Errorf("recovery: read draft: %w", err)
+	}
+	return d, nil
+}
+
+// List returns the drafts of the workspace rooted at dir, without their
+// contents, newest first. Drafts that match their file, or are older than
+// Config.MaxAge, are dropped.
+func (s *Store) List(workspaceID, dir string) ([]Draft, error) {
+	paths := make(map[string]bool)
+	des, err := os.ReadDir(filepath.Join(s.cfg.Dir, workspaceID))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("recovery: list drafts: %w", err)
+	}
+	for _, de := range des {
+		if !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		d, err := s.readFile(filepath.Join(s.cfg.Dir, workspaceID, de.Name()))
+		if err == nil {
+			paths[d.Path] = true
+		}
+	}
+	s.mu.Lock()
+	for _, pd := range s.pending {
+		if pd.workspace == workspaceID {
+			paths[pd.draft.Path] = true
+		}
+	}
+	s.mu.Unlock()
+
+	out := []Draft{}
+	for p := range paths {
+		d, err := s.check(workspaceID, dir, p)
+		if err != nil {
+			continue
+		}
+		d.Content = ""
+		out = append(out, d)
+	}
+	slices.SortFunc(out, func(a, b Draft) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return out, nil
+}
+
+// Get returns the draft for path with its contents.
+func (s *Store) Get(workspaceID, dir, path string) (Draft, error) {
+	p, err := files.Clean(path)
+	if err != nil {
+		return Draft{}, err
+	}
+	return s.check(workspaceID, dir, p)
+}
+
+// check returns the draft for path with Conflict set, discarding it and
+// returning ErrNotFound when it is stale.
+func (s *Store) check(workspaceID, dir, path string) (Draft, error) {
+	d, err := s.read(workspaceID, path)
+	if err != nil {
+		return d, err
+	}
+	if s.now().Sub(d.UpdatedAt) > s.cfg.MaxAge {
+		s.Discard(workspaceID, path)
+		return d, ErrNotFound
+	}
+	fsys, err := files.New(dir)
+	if err != nil {
+		return d, err
+	}
+	cur, err := fsys.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		d.Conflict = d.Base != ""
+	case err != nil:
+		return d, err
+	default:
+		if cur.Size == int64(d.Size) {
+			if data, err := fsys.ReadFile(path); err == nil && bytes.Equal(data, []byte(d.Content)) {
+				// Saved since.
+				s.Discard(workspaceID, path)
+				return d, ErrNotFound
+			}
+		}
+		d.Conflict = cur.ETag() != d.Base
+	}
+	return d, nil
+}
+
+// Restore writes the draft for path into the file and discards it. A
+// draft whose file changed since its base is only restored with force.
+func (s *Store) Restore(workspaceID, dir, path string, force bool) (files.Entry, error) {
+	d, err := s.Get(workspaceID, dir, path)
+	if err != nil {
+		return files.Entry{}, err
+	}
+	if d.Conflict && !force {
+		return files.Entry{}, ErrConflict
+	}
+	fsys, err := files.New(dir)
+	if err != nil {
+		return files.Entry{}, err
+	}
+	e, err := fsys.WriteFile(d.Path, []byte(d.Content))
+	if err != nil {
+		return files.Entry{}, err
+	}
+	s.Discard(workspaceID, d.Path)
+	return e, nil
+}
+
+// Discard drops the draft for path, as editors do once the buffer is
+// saved.
+func (s *Store) Discard(workspaceID, path string) error {
+	p, err := files.Clean(path)
+	if err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.Lock()
+	key := workspaceID + "\x00" + p
+	pd, pendingDraft := s.pending[key]
+	if pendingDraft {
+		pd.timer.Stop()
+		delete(s.pending, key)
+	}
+	s.mu.Unlock()
+	err = os.Remove(s.file(workspaceID, p))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if !pendingDraft {
+			return ErrNotFound
+		}
+	case err != nil:
+		return fmt.Errorf("recovery: discard draft: %w", err)
+	}
+	return nil
+}
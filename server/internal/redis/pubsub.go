@@ -0,0 +1,323 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Subscriptions share one connection, which is pinged every pingInterval
+// and considered lost when nothing arrives for twice as long.
+const pingInterval = 20 * time.Second
+
+// Publish sends data to a channel and returns how many subscribers, across
+// all clients of the server, received it.
+func (c *Client) Publish(ctx context.Context, channel string, data []byte) (int, error) {
+	n, err := Int(c.Do(ctx, "PUBLISH", channel, data))
+	return int(n), err
+}
+
+// Subscribe calls fn with each message published to channel until cancel
+// is called. It returns once the server has confirmed the subscription, so
+// messages published after it returns are delivered.
+//
+// Messages are delivered in the order the server received them, on one
+// goroutine shared by all subscriptions, so fn must not block or call
+// Subscribe. When the connection is lost, it is reopened and every
+// subscription renewed; since messages published in between were missed,
+// fn is then called with nil. A message being dispatched as cancel is
+// called may still reach fn.
+func (c *Client) Subscribe(channel string, fn func(data []byte)) (cancel func(), err error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if c.sub == nil {
+		c.sub = newSubscriber(c)
+	}
+	s := c.sub
+	c.mu.Unlock()
+	return s.subscribe(channel, fn)
+}
+
+// subscriber holds the pub/sub connection and dispatches its messages.
+type subscriber struct {
+	c *Client
+
+	mu       sync.Mutex
+	cn       *conn         // nil while disconnected
+	up       chan struct{} // closed once cn is set
+	handlers map[string]map[int]func([]byte)
+	pending  map[string]*pendingSub
+	seq      int
+	closed   bool
+
+	wmu sync.Mutex // serializes writes to cn
+}
+
+// pendingSub counts the SUBSCRIBE commands for a channel that the server
+// has yet to confirm.
+type pendingSub struct {
+	n    int
+	done chan struct{}
+	err  error
+}
+
+func newSubscriber(c *Client) *subscriber {
+	s := &subscriber{
+		c:        c,
+		up:       make(chan struct{}),
+		handlers: make(map[string]map[int]func([]byte)),
+		pending:  make(map[string]*pendingSub),
+	}
+	go s.run()
+	return s
+}
+
+func (s *subscriber) subscribe(channel string, fn func([]byte)) (func(), error) {
+	deadline := time.NewTimer(s.c.cfg.Timeout)
+	defer deadline.Stop()
+	s.mu.Lock()
+	for s.cn == nil && !s.closed {
+		up := s.up
+		s.mu.Unlock()
+		select {
+		case <-up:
+		case <-deadline.C:
+			return nil, errors.New("redis: subscribe: not connected")
+		}
+		s.mu.Lock()
+	}
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	s.seq++
+	id := s.seq
+	hs := s.handlers[channel]
+	first := len(hs) == 0
+	if first {
+		hs = make(map[int]func([]byte))
+		s.handlers[channel] = hs
+	}
+	hs[id] = fn
+	p := s.pending[channel]
+	if first {
+		if p == nil {
+			p = &pendingSub{done: make(chan struct{})}
+			s.pending[channel] = p
+		}
+		p.n++
+	}
+	cn := s.cn
+	s.mu.Unlock()
+
+	cancel := func() { s.unsubscribe(channel, id) }
+	if first {
+		if err := s.write(cn, "SUBSCRIBE", channel); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	if p == nil {
+		return cancel, nil
+	}
+	select {
+	case <-p.done:
+		if p.err != nil {
+			cancel()
+			return nil, p.err
+		}
+		return cancel, nil
+	case <-deadline.C:
+		cancel()
+		return nil, errors.New("redis: subscribe: not confirmed in time")
+	}
+}
+
+func (s *subscriber) unsubscribe(channel string, id int) {
+	s.mu.Lock()
+	hs := s.handlers[channel]
+	if _, ok := hs[id]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(hs, id)
+	last := len(hs) == 0
+	if last {
+		delete(s.handlers, channel)
+	}
+	cn := s.cn
+	s.mu.Unlock()
+	if last && cn != nil {
+		// A lost connection drops the subscription anyway.
+		s.write(cn, "UNSUBSCRIBE", channel)
+	}
+}
+
+func (s *subscriber) write(cn *conn, args ...any) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	cn.nc.SetWriteDeadline(time.Now().Add(s.c.cfg.Timeout))
+	return cn.send(args)
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.cn != nil {
+		s.cn.nc.Close()
+	} else {
+		close(s.up)
+	}
+}
+
+// run keeps the connection open, renewing the subscriptions each time it
+// is reopened.
+func (s *subscriber) run() {
+	backoff := 100 * time.Millisecond
+	for reconnect := false; ; reconnect = true {
+		cn, err := s.c.dial(context.Background())
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			slog.Warn("redis subscriber connect", "err", err, "retry", backoff)
+			time.Sleep(backoff)
+			backoff = min(2*backoff, 10*time.Second)
+			continue
+		}
+		backoff = 100 * time.Millisecond
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			cn.nc.Close()
+			return
+		}
+		s.cn = cn
+		channels := make([]any, 0, len(s.handlers)+1)
+		channels = append(channels, "SUBSCRIBE")
+		for ch := range s.handlers {
+			channels = append(channels, ch)
+			p := s.pending[ch]
+			if p == nil {
+				p = &pendingSub{done: make(chan struct{})}
+				s.pending[ch] = p
+			}
+			p.n++
+		}
+		close(s.up)
+		s.mu.Unlock()
+
+		if len(channels) > 1 {
+			s.write(cn, channels...)
+		}
+		if reconnect {
+			s.reset()
+		}
+		stop := make(chan struct{})
+		go s.ping(cn, stop)
+		err = s.read(cn)
+		close(stop)
+		cn.nc.Close()
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		s.cn = nil
+		s.up = make(chan struct{})
+		for ch, p := range s.pending {
+			p.err = fmt.Errorf("redis: subscribe: %w", err)
+			close(p.done)
+			delete(s.pending, ch)
+		}
+		s.mu.Unlock()
+		slog.Warn("redis subscriber disconnected", "err", err)
+	}
+}
+
+// reset tells every subscription that messages may have been missed.
+func (s *subscriber) reset() {
+	s.mu.Lock()
+	var fns []func([]byte)
+	for _, hs := range s.handlers {
+		for _, fn := range hs {
+			fns = append(fns, fn)
+		}
+	}
+	s.mu.Unlock()
+	for _, fn := range fns {
+		fn(nil)
+	}
+}
+
+func (s *subscriber) ping(cn *conn, stop <-chan struct{}) {
+	t := time.NewTicker(pingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if s.write(cn, "PING") != nil {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// read dispatches pushed messages until the connection fails.
+func (s *subscriber) read(cn *conn) error {
+	for {
+		cn.nc.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		v, err := cn.read()
+		if err != nil {
+			return err
+		}
+		msg, _ := v.([]any)
+		if len(msg) < 2 {
+			continue
+		}
+		kind, _ := msg[0].(string)
+		channel, _ := msg[1].(string)
+		switch kind {
+		case "message":
+			if len(msg) < 3 {
+				continue
+			}
+			data, _ := msg[2].(string)
+			s.mu.Lock()
+			fns := make([]func([]byte), 0, len(s.handlers[channel]))
+			for _, fn := range s.handlers[channel] {
+				fns = append(fns, fn)
+			}
+			s.mu.Unlock()
+			for _, fn := range fns {
+				fn([]byte(data))
+			}
+		case "subscribe":
+			s.mu.Lock()
+			if p := s.pending[channel]; p != nil {
+				if p.n--; p.n <= 0 {
+					close(p.done)
+					delete(s.pending, channel)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,405 @@
+// Package redis is a small Redis client. It speaks RESP2 itself, with TLS
+// and password authentication, and covers what the server needs to share
+// state between replicas: plain commands over a pool of connections, and
+// publish/subscribe over one dedicated connection that reconnects on its
+// own.
+//
+// Create a Client with NewClient and a Config from ParseURL, which takes a
+// URL such as "redis://:password@host:6379/0" or "rediss://host:6380".
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxBulk is the longest bulk string Redis stores, its default
+// proto-max-bulk-len.
+const maxBulk = 512 << 20
+
+// ErrNil is returned by String and Int for a nil reply, such as GET of a
+// missing key.
+var ErrNil = errors.New("redis: nil reply")
+
+// ErrClosed is returned once the Client has been closed.
+var ErrClosed = errors.New("redis: client closed")
+
+// Error is an error reply from the server.
+type Error struct {
+	// Kind is the reply's first word, such as ERR or WRONGTYPE.
+	Kind    string
+	Message string
+}
+
+func (e *Error) Error() string { return "redis: " + e.Kind + " " + e.Message }
+
+// Config configures a Client.
+type Config struct {
+	// Addr is the server's host:port.
+	Addr     string
+	Username string
+	Password string
+	// DB is the database selected on each connection.
+	DB int
+	// TLS, if set, secures the connections.
+	TLS *tls.Config
+	// PoolSize caps connections for commands; defaults to 10. Pub/sub
+	// uses one more.
+	PoolSize int
+	// Timeout bounds connecting and each command without a deadline of
+	// its own; defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+// ParseURL parses a redis:// or, for TLS, rediss:// URL. The path selects
+// the database.
+func ParseURL(raw string) (Config, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Config{}, fmt.Errorf("redis: parse url: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return Config{}, fmt.Errorf("redis: url scheme must be redis or rediss, not %q", u.Scheme)
+	}
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "6379"
+	}
+	cfg := Config{Addr: net.JoinHostPort(host, port), Username: u.User.Username()}
+	cfg.Password, _ = u.User.Password()
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if cfg.DB, err = strconv.Atoi(db); err != nil || cfg.DB < 0 {
+			return Config{}, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+	if u.Scheme == "rediss" {
+		cfg.TLS = &tls.Config{ServerName: host}
+	}
+	return cfg, nil
+}
+
+// Client sends commands to one server. It is safe for concurrent use.
+type Client struct {
+	cfg  Config
+	sem  chan struct{} // one token per open pool connection
+	idle chan *conn
+
+	mu     sync.Mutex
+	sub    *subscriber // started by the first Subscribe
+	closed bool
+}
+
+// NewClient returns a Client, filling unset Config fields with defaults.
+// Connections are opened as they are needed.
+func NewClient(cfg Config) *Client {
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 10
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &Client{
+		cfg:  cfg,
+		sem:  make(chan struct{}, cfg.PoolSize),
+		idle: make(chan *conn, cfg.PoolSize),
+	}
+}
+
+// Close closes the Client's connections and ends its subscriptions.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	sub := c.sub
+	c.mu.Unlock()
+	if sub != nil {
+		sub.close()
+	}
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.nc.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Do sends a command and returns its reply: a string for simple and bulk
+// strings, an int64 for integers, a []any for arrays and nil for nil
+// replies. Error replies are returned as *Error. Arguments may be strings,
+// []byte or integers.
+func (c *Client) Do(ctx context.Context, args ...any) (any, error) {
+	for retried := false; ; retried = true {
+		cn, pooled, err := c.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		v, err := cn.do(ctx, c.cfg.Timeout, args)
+		c.put(cn, err)
+		// An idle connection the server has since closed fails at once,
+		// so the command is tried once more on a new one.
+		if pooled && !retried && closedByServer(err) {
+			continue
+		}
+		return v, err
+	}
+}
+
+func closedByServer(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// Ping checks that the server answers.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// String returns a reply as a string, or ErrNil for a nil reply.
+func String(v any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case nil:
+		return "", ErrNil
+	}
+	return "", fmt.Errorf("redis: unexpected %T reply", v)
+}
+
+// Int returns a reply as an integer, or ErrNil for a nil reply.
+func Int(v any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case nil:
+		return 0, ErrNil
+	}
+	return 0, fmt.Errorf("redis: unexpected %T reply", v)
+}
+
+// get takes an idle connection or opens one, waiting while the pool is
+// full. pooled reports whether the connection was idle.
+func (c *Client) get(ctx context.Context) (cn *conn, pooled bool, err error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, false, ErrClosed
+	}
+	select {
+	case cn := <-c.idle:
+		return cn, true, nil
+	default:
+	}
+	select {
+	case cn := <-c.idle:
+		return cn, true, nil
+	case c.sem <- struct{}{}:
+		cn, err := c.dial(ctx)
+		if err != nil {
+			<-c.sem
+			return nil, false, err
+		}
+		return cn, false, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// put returns a connection to the pool unless the command left it
+// unusable.
+func (c *Client) put(cn *conn, err error) {
+	var re *Error
+	if err != nil && !errors.As(err, &re) {
+		cn.nc.Close()
+		<-c.sem
+		return
+	}
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		cn.nc.Close()
+		<-c.sem
+		return
+	}
+	c.idle <- cn
+}
+
+// dial connects, authenticates and selects the database.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: connect: %w", err)
+	}
+	if c.cfg.TLS != nil {
+		tc := tls.Client(nc, c.cfg.TLS)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis: tls: %w", err)
+		}
+		nc = tc
+	}
+	cn := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if c.cfg.Password != "" {
+		args := []any{"AUTH", c.cfg.Password}
+		if c.cfg.Username != "" {
+			args = []any{"AUTH", c.cfg.Username, c.cfg.Password}
+		}
+		if _, err := cn.do(ctx, c.cfg.Timeout, args); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis: auth: %w", err)
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err := cn.do(ctx, c.cfg.Timeout, []any{"SELECT", c.cfg.DB}); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis: select: %w", err)
+		}
+	}
+	return cn, nil
+}
+
+// conn is one connection to the server.
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// do sends a command and reads its reply within ctx's deadline, or
+// timeout if it has none.
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args []any) (any, error) {
+	dl, ok := ctx.Deadline()
+	if !ok {
+		dl = time.Now().Add(timeout)
+	}
+	cn.nc.SetDeadline(dl)
+	defer cn.nc.SetDeadline(time.Time{})
+	if err := cn.send(args); err != nil {
+		return nil, err
+	}
+	return cn.read()
+}
+
+// send writes a command as an array of bulk strings.
+func (cn *conn) send(args []any) error {
+	cn.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		var b []byte
+		switch a := a.(type) {
+		case string:
+			b = []byte(a)
+		case []byte:
+			b = a
+		case int:
+			b = strconv.AppendInt(nil, int64(a), 10)
+		case int64:
+			b = strconv.AppendInt(nil, a, 10)
+		default:
+			return fmt.Errorf("redis: unsupported argument type %T", a)
+		}
+		cn.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+		cn.w.Write(b)
+		cn.w.WriteString("\r\n")
+	}
+	if err := cn.w.Flush(); err != nil {
+		return fmt.Errorf("redis: write: %w", err)
+	}
+	return nil
+}
+
+// read reads one reply. An error reply is returned as the error.
+func (cn *conn) read() (any, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: read: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		k, msg, _ := strings.Cut(body, " ")
+		return nil, &Error{Kind: k, Message: msg}
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 || n > maxBulk {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, b); err != nil {
+			return nil, fmt.Errorf("redis: read: %w", err)
+		}
+		if b[n] != '\r' || b[n+1] != '\n' {
+			return nil, errors.New("redis: malformed reply")
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		// The length is not trusted for the allocation: a reply that
+		// claims more elements than it has fails as it is read.
+		out := make([]any, 0, min(n, 1024))
+		// An error inside an array does not end the reply, so it is kept
+		// as an element.
+		for range n {
+			v, err := cn.read()
+			var re *Error
+			switch {
+			case errors.As(err, &re):
+				out = append(out, re)
+			case err != nil:
+				return nil, err
+			default:
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -0,0 +1,261 @@
+// Package redistest runs an in-memory Redis server for tests, with the
+// commands the redis client and its users send: AUTH, PING, SELECT, SET,
+// GET, GETDEL, MGET, PUBLISH, SUBSCRIBE and UNSUBSCRIBE. Keys never
+// expire.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server is an in-memory Redis server listening on a loopback port.
+type Server struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	password string
+	data     map[string]string
+	conns    map[*conn]bool
+	dials    int
+	cmds     map[string]int
+}
+
+type conn struct {
+	nc   net.Conn
+	wmu  sync.Mutex
+	subs map[string]bool // guarded by Server.mu
+}
+
+func (c *conn) write(reply string) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	io.WriteString(c.nc, reply)
+}
+
+// NewServer starts a Server, which is stopped when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{ln: ln, data: make(map[string]string), conns: make(map[*conn]bool), cmds: make(map[string]int)}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c := &conn{nc: nc, subs: make(map[string]bool)}
+			s.mu.Lock()
+			s.conns[c] = true
+			s.dials++
+			s.mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.serve(c)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		s.Drop()
+		wg.Wait()
+	})
+	return s
+}
+
+// Addr returns the server's host:port.
+func (s *Server) Addr() string { return s.ln.Addr().String() }
+
+// URL returns the server's redis:// URL.
+func (s *Server) URL() string { return "redis://" + s.Addr() }
+
+// Drop closes every open connection, as a server that restarts does.
+func (s *Server) Drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.nc.Close()
+	}
+}
+
+// SetPassword makes connections opened from now on send password with
+// AUTH before other commands.
+func (s *Server) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// Dials returns how many connections the server has accepted.
+func (s *Server) Dials() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+// Count returns how many times the server received cmd, such as "AUTH".
+func (s *Server) Count(cmd string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cmds[cmd]
+}
+
+// Get returns the value stored under key.
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	return v, ok
+}
+
+func (s *Server) serve(c *conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.nc.Close()
+	}()
+	r := bufio.NewReader(c.nc)
+	s.mu.Lock()
+	password := s.password
+	s.mu.Unlock()
+	authed := password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		s.mu.Lock()
+		s.cmds[cmd]++
+		s.mu.Unlock()
+		if !authed && cmd != "AUTH" {
+			c.write("-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		switch cmd {
+		case "AUTH":
+			if args[len(args)-1] != password {
+				c.write("-WRONGPASS invalid username-password pair or user is disabled.\r\n")
+				continue
+			}
+			authed = true
+			c.write("+OK\r\n")
+		case "PING":
+			c.write("+PONG\r\n")
+		case "SELECT":
+			c.write("+OK\r\n")
+		case "SET":
+			if len(args) < 3 {
+				c.write("-ERR wrong number of arguments for 'set' command\r\n")
+				continue
+			}
+			s.mu.Lock()
+			s.data[args[1]] = args[2]
+			s.mu.Unlock()
+			c.write("+OK\r\n")
+		case "GET", "GETDEL":
+			s.mu.Lock()
+			v, ok := s.data[args[1]]
+			if cmd == "GETDEL" {
+				delete(s.data, args[1])
+			}
+			s.mu.Unlock()
+			c.write(bulk(v, ok))
+		case "MGET":
+			reply := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+			s.mu.Lock()
+			for _, k := range args[1:] {
+				v, ok := s.data[k]
+				reply += bulk(v, ok)
+			}
+			s.mu.Unlock()
+			c.write(reply)
+		case "PUBLISH":
+			msg := "*3\r\n" + bulk("message", true) + bulk(args[1], true) + bulk(args[2], true)
+			n := 0
+			s.mu.Lock()
+			for sub := range s.conns {
+				if sub.subs[args[1]] {
+					sub.write(msg)
+					n++
+				}
+			}
+			s.mu.Unlock()
+			c.write(":" + strconv.Itoa(n) + "\r\n")
+		case "SUBSCRIBE", "UNSUBSCRIBE":
+			for _, ch := range args[1:] {
+				s.mu.Lock()
+				if cmd == "SUBSCRIBE" {
+					c.subs[ch] = true
+				} else {
+					delete(c.subs, ch)
+				}
+				n := len(c.subs)
+				s.mu.Unlock()
+				c.write(fmt.Sprintf("*3\r\n%s%s:%d\r\n", bulk(strings.ToLower(cmd), true), bulk(ch, true), n))
+			}
+		default:
+			c.write("-ERR unknown command '" + args[0] + "'\r\n")
+		}
+	}
+}
+
+func bulk(v string, ok bool) string {
+	if !ok {
+		return "$-1\r\n"
+	}
+	return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+}
+
+// readCommand reads a command, an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	n, err := readLength(r, '*')
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, errors.New("redistest: empty command")
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readLength(r, '$')
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+// readLength reads a line of the given kind, such as "*3", and returns
+// its length.
+func readLength(r *bufio.Reader, kind byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+	if line[0] != kind || err != nil || n < 0 {
+		return 0, fmt.Errorf("redistest: malformed command line %q", line)
+	}
+	return n, nil
+}
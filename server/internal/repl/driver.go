@@ -0,0 +1,196 @@
+package repl
+
+// driverSource is the REPL driver built inside the workspace environment.
+// It evaluates code with yaegi and speaks line-delimited JSON on stdio:
+// eval and interrupt requests in, ready, stdout, stderr, display and
+// result messages out. Its only argument is the workspace root; when that
+// holds a Go module, the module is mapped into yaegi's GOPATH so its
+// packages can be imported by their module path. Interpreted code can
+// import "webide/display" to show images, HTML and other rich output.
+const driverSource = `package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+type request struct {
+	Type string ` + "`json:\"type\"`" + `
+	ID   int64  ` + "`json:\"id\"`" + `
+	Code string ` + "`json:\"code\"`" + `
+}
+
+type reply struct {
+	Type      string ` + "`json:\"type\"`" + `
+	ID        int64  ` + "`json:\"id,omitempty\"`" + `
+	Data      string ` + "`json:\"data,omitempty\"`" + `
+	Mime      string ` + "`json:\"mime,omitempty\"`" + `
+	Value     string ` + "`json:\"value,omitempty\"`" + `
+	ValueType string ` + "`json:\"valueType,omitempty\"`" + `
+	Error     string ` + "`json:\"error,omitempty\"`" + `
+}
+
+var (
+	mu  sync.Mutex
+	enc = json.NewEncoder(os.Stdout)
+)
+
+func send(r reply) {
+	mu.Lock()
+	defer mu.Unlock()
+	enc.Encode(r)
+}
+
+// stream forwards the interpreted program's output as messages.
+type stream string
+
+func (s stream) Write(p []byte) (int, error) {
+	send(reply{Type: string(s), Data: string(p)})
+	return len(p), nil
+}
+
+// display sends rich output; binary data is base64-encoded.
+func display(mime string, data []byte) {
+	s := string(data)
+	if strings.HasPrefix(mime, "image/") && mime != "image/svg+xml" {
+		s = base64.StdEncoding.EncodeToString(data)
+	}
+	send(reply{Type: "display", Mime: mime, Data: s})
+}
+
+// displayPackage is importable by interpreted code as "webide/display".
+var displayPackage = map[string]reflect.Value{
+	"PNG":      reflect.ValueOf(func(b []byte) { display("image/png", b) }),
+	"JPEG":     reflect.ValueOf(func(b []byte) { display("image/jpeg", b) }),
+	"GIF":      reflect.ValueOf(func(b []byte) { display("image/gif", b) }),
+	"SVG":      reflect.ValueOf(func(s string) { display("image/svg+xml", []byte(s)) }),
+	"HTML":     reflect.ValueOf(func(s string) { display("text/html", []byte(s)) }),
+	"Markdown": reflect.ValueOf(func(s string) { display("text/markdown", []byte(s)) }),
+	"Text":     reflect.ValueOf(func(s string) { display("text/plain", []byte(s)) }),
+	"Data":     reflect.ValueOf(display),
+	"Image": reflect.ValueOf(func(img image.Image) error {
+		var b bytes.Buffer
+		if err := png.Encode(&b, img); err != nil {
+			return err
+		}
+		display("image/png", b.Bytes())
+		return nil
+	}),
+	"JSON": reflect.ValueOf(func(v any) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		display("application/json", b)
+		return nil
+	}),
+}
+
+func main() {
+	gopath := moduleGopath(os.Args[1])
+	if gopath != "" {
+		defer os.RemoveAll(gopath)
+	}
+	i := interp.New(interp.Options{
+		GoPath: gopath,
+		Stdin:  strings.NewReader(""),
+		Stdout: stream("stdout"),
+		Stderr: stream("stderr"),
+	})
+	if err := i.Use(stdlib.Symbols); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := i.Use(interp.Exports{"webide/display/display": displayPackage}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var cmu sync.Mutex
+	cancel := context.CancelFunc(func() {})
+	evals := make(chan request, 64)
+	go func() {
+		defer close(evals)
+		sc := bufio.NewScanner(os.Stdin)
+		sc.Buffer(make([]byte, 64<<10), 1<<20)
+		for sc.Scan() {
+			var req request
+			if json.Unmarshal(sc.Bytes(), &req) != nil {
+				continue
+			}
+			switch req.Type {
+			case "interrupt":
+				cmu.Lock()
+				cancel()
+				cmu.Unlock()
+			case "eval":
+				evals <- req
+			}
+		}
+	}()
+
+	send(reply{Type: "ready"})
+	for req := range evals {
+		ctx, c := context.WithCancel(context.Background())
+		cmu.Lock()
+		cancel = c
+		cmu.Unlock()
+		v, err := i.EvalWithContext(ctx, req.Code)
+		c()
+		res := reply{Type: "result", ID: req.ID}
+		switch {
+		case err != nil:
+			res.Error = err.Error()
+		case v.IsValid() && v.CanInterface():
+			res.ValueType = v.Type().String()
+			if v.Kind() != reflect.Func {
+				res.Value = fmt.Sprintf("%v", v.Interface())
+			}
+		}
+		send(res)
+	}
+}
+
+// moduleGopath returns a GOPATH holding the module at root under its
+// module path, or "" when root is not a module.
+func moduleGopath(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	var mod string
+	for _, line := range strings.Split(string(data), "\n") {
+		if f := strings.Fields(line); len(f) >= 2 && f[0] == "module" {
+			mod = strings.Trim(f[1], "\"")
+			break
+		}
+	}
+	if mod == "" {
+		return ""
+	}
+	gopath, err := os.MkdirTemp("", "webide-repl-")
+	if err != nil {
+		return ""
+	}
+	link := filepath.Join(gopath, "src", filepath.FromSlash(mod))
+	if os.MkdirAll(filepath.Dir(link), 0o755) != nil || os.Symlink(root, link) != nil {
+		os.RemoveAll(gopath)
+		return ""
+	}
+	return gopath
+}
+`
@@ -0,0 +1,122 @@
+package repl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler bridges REPL sessions to WebSocket clients.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler starting sessions with svc.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the REPL socket on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /ws/repl/{id}", h.serve)
+}
+
+type errorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// exitedFrame ends the stream; Output is the interpreter's error output,
+// which explains a failed start.
+type exitedFrame struct {
+	Type   string `json:"type"`
+	Output string `json:"output,omitempty"`
+}
+
+// serve runs one interpreter for the lifetime of the socket. Clients send
+// Requests; every interpreter message is forwarded as one text frame.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	sess, err := h.svc.Start(r.Context(), id, dir)
+	switch {
+	case errors.Is(err, ErrTooManySessions):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	case err != nil:
+		slog.Error("start repl", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not start repl")
+		return
+	}
+	defer sess.Close()
+
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxCodeBytes + 4<<10)
+
+	// Interpreter messages and request errors share the socket.
+	var wmu sync.Mutex
+	write := func(f func() error) error {
+		wmu.Lock()
+		defer wmu.Unlock()
+		return f()
+	}
+
+	go func() {
+		defer sess.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req Request
+			err = json.Unmarshal(data, &req)
+			if err != nil {
+				err = fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+			} else {
+				err = sess.Send(req)
+			}
+			if err != nil {
+				msg := err.Error()
+				write(func() error { return conn.WriteJSON(errorFrame{Type: "error", Error: msg}) })
+			}
+		}
+	}()
+
+	for {
+		msg, err := sess.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Debug("read from repl", "workspace", id, "err", err)
+			}
+			sess.Close()
+			write(func() error { return conn.WriteJSON(exitedFrame{Type: "exited", Output: sess.Output()}) })
+			conn.CloseWithCode(ws.CloseNormal, "repl session ended")
+			return
+		}
+		if err := write(func() error { return conn.WriteMessage(ws.TextMessage, msg) }); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Package repl runs interactive Go sessions, interpreted by yaegi, inside
+// a workspace's environment and bridges them to the editor over
+// WebSocket.
+//
+// Each connection gets its own interpreter process, so declarations and
+// imports persist for the lifetime of the socket. The interpreter is a
+// small driver around yaegi's library that is built with the workspace's
+// Go toolchain on first use and reused afterwards; it evaluates code and
+// reports results as structured messages rather than emulating a
+// terminal. Packages of the workspace module can be imported by their
+// module path, as long as they only depend on the standard library.
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// YaegiModule is the yaegi version the driver is built against.
+	YaegiModule string
+	// MaxSessions caps concurrent sessions per workspace; defaults to 4.
+	MaxSessions int
+}
+
+var (
+	// ErrTooManySessions is returned when a workspace is at Config.MaxSessions.
+	ErrTooManySessions = errors.New("repl: too many sessions")
+	// ErrClosed is returned after the Service has been closed.
+	ErrClosed = errors.New("repl: service closed")
+	// ErrInvalidMessage is returned for client messages other than eval
+	// and interrupt requests.
+	ErrInvalidMessage = errors.New("repl: invalid message")
+)
+
+// maxCodeBytes bounds one eval request; the driver reads lines of up to
+// 1 MiB.
+const maxCodeBytes = 512 << 10
+
+// Request is a message from the editor: {"type":"eval","id":1,"code":"..."}
+// evaluates code, and {"type":"interrupt"} cancels the running evaluation.
+type Request struct {
+	Type string `json:"type"`
+	ID   int64  `json:"id,omitempty"`
+	Code string `json:"code,omitempty"`
+}
+
+// Service starts REPL sessions through a Launcher.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+	// driver names the built driver binary after its source and yaegi
+	// version, so a change to either rebuilds it.
+	driver string
+
+	mu       sync.Mutex
+	active   map[string]int
+	sessions map[*Session]struct{}
+	closed   bool
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.YaegiModule == "" {
+		cfg.YaegiModule = "github.com/traefik/yaegi@v0.16.1"
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = 4
+	}
+	sum := sha256.Sum256([]byte(cfg.YaegiModule + "\x00" + driverSource))
+	return &Service{
+		cfg:      cfg,
+		launcher: l,
+		driver:   "webide-repl-" + hex.EncodeToString(sum[:6]),
+		active:   make(map[string]int),
+		sessions: make(map[*Session]struct{}),
+	}
+}
+
+// startScript builds the driver from source $1 against yaegi $2 into
+// binary $3 unless it exists, then runs it on workspace root $4. Build
+// output goes to stderr.
+const startScript = `set -u
+tools="${TMPDIR:-/tmp}/webide-tools"
+bin="$tools/$3"
+if [ ! -x "$bin" ]; then
+	mkdir -p "$tools"
+	src="$(mktemp -d)"
+	printf '%s' "$1" >"$src/main.go"
+	(
+		cd "$src" &&
+		export GOPATH="${TMPDIR:-/tmp}/webide-tools-gopath" GOFLAGS= &&
+		go mod init webide.local/repl &&
+		go get "$2" &&
+		go build -o "$bin.$$" . &&
+		mv "$bin.$$" "$bin"
+	) >&2
+	status=$?
+	rm -rf "$src"
+	[ "$status" -eq 0 ] || exit 1
+fi
+exec "$bin" "$4"
+`
+
+// Session is one running interpreter.
+type Session struct {
+	svc         *Service
+	workspaceID string
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	stdout      *bufio.Reader
+	stderr      *tailBuffer
+
+	wmu       sync.Mutex
+	closeOnce sync.Once
+}
+
+// Start launches an interpreter for the workspace at dir.
+func (s *Service) Start(ctx context.Context, workspaceID, dir string) (*Session, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if s.active[workspaceID] >= s.cfg.MaxSessions {
+		s.mu.Unlock()
+		return nil, ErrTooManySessions
+	}
+	s.active[workspaceID]++
+	s.mu.Unlock()
+
+	sess, err := s.start(ctx, workspaceID, dir)
+	s.mu.Lock()
+	if err != nil {
+		s.release(workspaceID)
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.sessions[sess] = struct{}{}
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		sess.Close()
+		return nil, ErrClosed
+	}
+	return sess, nil
+}
+
+func (s *Service) start(ctx context.Context, workspaceID, dir string) (*Session, error) {
+	argv := []string{"sh", "-c", startScript, "webide-repl", driverSource, s.cfg.YaegiModule, s.driver, s.launcher.Root(dir)}
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &tailBuffer{max: 8 << 10}
+	cmd.Stderr = stderr
+	cmd.WaitDelay = 3 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("repl: start interpreter: %w", err)
+	}
+	return &Session{
+		svc:         s,
+		workspaceID: workspaceID,
+		cmd:         cmd,
+		stdin:       stdin,
+		stdout:      bufio.NewReader(stdout),
+		stderr:      stderr,
+	}, nil
+}
+
+func (s *Service) release(workspaceID string) {
+	if s.active[workspaceID]--; s.active[workspaceID] <= 0 {
+		delete(s.active, workspaceID)
+	}
+}
+
+// Close stops every running session.
+func (s *Service) Close() {
+	s.mu.Lock()
+	s.closed = true
+	sessions := make([]*Session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+	for _, sess := range sessions {
+		sess.Close()
+	}
+}
+
+// Send delivers one request from the editor to the interpreter.
+func (s *Session) Send(req Request) error {
+	switch {
+	case req.Type != "eval" && req.Type != "interrupt":
+		return fmt.Errorf("%w: type %q", ErrInvalidMessage, req.Type)
+	case len(req.Code) > maxCodeBytes:
+		return fmt.Errorf("%w: code exceeds %d bytes", ErrInvalidMessage, maxCodeBytes)
+	}
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_, err = s.stdin.Write(append(line, '\n'))
+	return err
+}
+
+// Recv returns the next message from the interpreter: ready once it has
+// started, stdout and stderr for program output, and a result for each
+// eval. It returns io.EOF once the interpreter has exited.
+func (s *Session) Recv() ([]byte, error) {
+	line, err := s.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(line, []byte("\n")), nil
+}
+
+// Output returns the end of the interpreter's own error output, such as
+// the log of a failed driver build.
+func (s *Session) Output() string { return s.stderr.String() }
+
+// Close stops the interpreter.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		// The driver exits when its input ends.
+		s.stdin.Close()
+		done := make(chan struct{})
+		go func() {
+			s.cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			s.cmd.Process.Kill()
+			<-done
+		}
+		s.svc.mu.Lock()
+		delete(s.svc.sessions, s)
+		s.svc.release(s.workspaceID)
+		s.svc.mu.Unlock()
+	})
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,265 @@
+package review
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the review API for workspaces.
+type Handler struct {
+	store      *Store
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler keeping threads in store.
+func NewHandler(store *Store, wm Workspaces) *Handler {
+	return &Handler{store: store, workspaces: wm}
+}
+
+// Register mounts the review routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/reviews", h.list)
+	mux.HandleFunc("POST /api/workspaces/{id}/reviews", h.create)
+	mux.HandleFunc("GET /api/workspaces/{id}/reviews/{thread}", h.get)
+	mux.HandleFunc("PATCH /api/workspaces/{id}/reviews/{thread}", h.resolve)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/reviews/{thread}", h.delete)
+	mux.HandleFunc("POST /api/workspaces/{id}/reviews/{thread}/comments", h.reply)
+	mux.HandleFunc("PATCH /api/workspaces/{id}/reviews/{thread}/comments/{comment}", h.edit)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/reviews/{thread}/comments/{comment}", h.deleteComment)
+}
+
+func (h *Handler) open(w http.ResponseWriter, r *http.Request) (string, bool) {
+	dir, err := h.workspaces.Open(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return dir, true
+}
+
+// list returns the threads of ?path=, or of the whole workspace, with
+// ?resolved=true or false keeping only resolved or open ones.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	var resolved *bool
+	if v := q.Get("resolved"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, "resolved must be true or false")
+			return
+		}
+		resolved = &b
+	}
+	threads, err := h.store.List(r.PathValue("id"), dir, q.Get("path"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if resolved != nil {
+		kept := threads[:0]
+		for _, t := range threads {
+			if t.Resolved == *resolved {
+				kept = append(kept, t)
+			}
+		}
+		threads = kept
+	}
+	httpx.JSON(w, http.StatusOK, threads)
+}
+
+type createRequest struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"startLine"`
+	// EndLine defaults to StartLine.
+	EndLine int    `json:"endLine"`
+	Body    string `json:"body"`
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	var req createRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.EndLine == 0 {
+		req.EndLine = req.StartLine
+	}
+	t, err := h.store.Create(r.Context(), r.PathValue("id"), dir, req.Path, req.StartLine, req.EndLine, req.Body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	publish(r, events.ReviewCommented, t)
+	httpx.JSON(w, http.StatusCreated, t)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	t, err := h.store.Thread(r.PathValue("id"), dir, r.PathValue("thread"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, t)
+}
+
+type resolveRequest struct {
+	Resolved bool `json:"resolved"`
+}
+
+// resolve resolves or reopens a thread.
+func (h *Handler) resolve(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	var req resolveRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	t, err := h.store.SetResolved(r.Context(), r.PathValue("id"), dir, r.PathValue("thread"), req.Resolved)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Resolved {
+		publish(r, events.ReviewResolved, t)
+	}
+	httpx.JSON(w, http.StatusOK, t)
+}
+
+// delete removes a thread, which only the user who started it and the
+// workspace's owner may.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	id, thread := r.PathValue("id"), r.PathValue("thread")
+	starter, err := h.store.Starter(id, thread)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if workspace.RoleFrom(r.Context()) != workspace.RoleOwner {
+		if u := auth.UserFrom(r.Context()); u == nil || u.ID != starter {
+			httpx.Error(w, http.StatusForbidden, "only the thread's author and the workspace owner may delete it")
+			return
+		}
+	}
+	if err := h.store.Delete(r.Context(), id, dir, thread); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type commentRequest struct {
+	Body string `json:"body"`
+}
+
+func (h *Handler) reply(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	var req commentRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	t, err := h.store.Reply(r.Context(), r.PathValue("id"), dir, r.PathValue("thread"), req.Body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	publish(r, events.ReviewCommented, t)
+	httpx.JSON(w, http.StatusCreated, t)
+}
+
+func (h *Handler) edit(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	var req commentRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	t, err := h.store.Edit(r.Context(), r.PathValue("id"), dir, r.PathValue("thread"), r.PathValue("comment"), req.Body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, t)
+}
+
+// deleteComment removes a comment, and its thread with the last one.
+func (h *Handler) deleteComment(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.store.DeleteComment(r.Context(), r.PathValue("id"), dir, r.PathValue("thread"), r.PathValue("comment")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publish announces a comment on, or resolution of, t. Comment events
+// carry the newest comment.
+func publish(r *http.Request, typ string, t *Thread) {
+	data := map[string]any{
+		"path":      t.Path,
+		"thread":    t.ID,
+		"startLine": t.StartLine,
+		"endLine":   t.EndLine,
+	}
+	if typ == events.ReviewCommented && len(t.Comments) > 0 {
+		c := t.Comments[len(t.Comments)-1]
+		data["author"], data["body"] = c.Author.Name, c.Body
+	}
+	events.Publish(r.Context(), events.Event{Type: typ, Workspace: r.PathValue("id"), Data: data})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrForbidden):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("review", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "review request failed")
+	}
+}
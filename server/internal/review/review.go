@@ -0,0 +1,679 @@
+// Package review keeps code review threads on the files of a workspace.
+// A thread is anchored to a range of lines and holds a conversation that
+// can be resolved, and reopened, once addressed.
+//
+// Anchors follow the code as the file changes. The store keeps the text
+// of each reviewed file as of its threads' anchors and, when it next finds
+// the file different, diffs the two and moves every range to where its
+// lines went. A thread whose lines were all replaced is looked for by its
+// quoted text, as after a block is moved, and marked outdated if it is
+// gone; it keeps its last range.
+package review
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diff"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir holds the threads, one subdirectory per workspace; defaults to a
+	// directory under the OS temp dir.
+	Dir string
+	// MaxFileBytes caps the files that can be reviewed; defaults to 1 MiB.
+	MaxFileBytes int
+	// MaxThreads caps the threads per workspace; defaults to 1000.
+	MaxThreads int
+	// MaxCommentBytes caps one comment; defaults to 16 KiB.
+	MaxCommentBytes int
+	// Notify, if set, is called after each change with the path of the
+	// file and a description of the change.
+	Notify func(workspaceID, path string, c Change)
+}
+
+var (
+	// ErrNotFound is returned for unknown threads and comments.
+	ErrNotFound = errors.New("review: not found")
+	// ErrInvalid is returned for malformed ranges and comments.
+	ErrInvalid = errors.New("review: invalid request")
+	// ErrForbidden is returned when someone else's comment is edited or
+	// deleted.
+	ErrForbidden = errors.New("review: only the author may change a comment")
+	// ErrTooMany is returned when a workspace is at Config.MaxThreads.
+	ErrTooMany = errors.New("review: too many threads")
+)
+
+// Author is who wrote a comment. Both fields are empty when
+// authentication is off.
+type Author struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Comment is one message of a thread.
+type Comment struct {
+	ID        string     `json:"id"`
+	Author    Author     `json:"author"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+}
+
+// Thread is a conversation about lines StartLine to EndLine of a file,
+// 1-based and inclusive.
+type Thread struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	// Quote is the text of the lines when the thread was started.
+	Quote string `json:"quote"`
+	// Outdated reports that the lines were changed or removed, so the
+	// range is where they last were.
+	Outdated   bool       `json:"outdated"`
+	Resolved   bool       `json:"resolved"`
+	ResolvedBy *Author    `json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	Comments   []Comment  `json:"comments"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// ChangeType names a change to a thread.
+type ChangeType string
+
+const (
+	ThreadCreated  ChangeType = "thread.created"
+	ThreadResolved ChangeType = "thread.resolved"
+	ThreadReopened ChangeType = "thread.reopened"
+	ThreadDeleted  ChangeType = "thread.deleted"
+	CommentAdded   ChangeType = "comment.added"
+	CommentEdited  ChangeType = "comment.edited"
+	CommentDeleted ChangeType = "comment.deleted"
+)
+
+// Change describes a change to a thread, by whom, and which comment it
+// concerns, if any. Thread is the thread after the change.
+type Change struct {
+	Type    ChangeType `json:"type"`
+	Thread  *Thread    `json:"thread"`
+	Comment string     `json:"comment,omitempty"`
+	By      Author     `json:"by"`
+}
+
+// file is what the store holds for a reviewed file: its threads, and the
+// text their ranges refer to.
+type file struct {
+	Path    string    `json:"path"`
+	Base    string    `json:"base"`
+	Threads []*Thread `json:"threads"`
+}
+
+// Store holds the threads of all workspaces.
+type Store struct {
+	cfg Config
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+// NewStore returns a Store, filling unset Config fields with defaults.
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-reviews")
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = 1 << 20
+	}
+	if cfg.MaxThreads <= 0 {
+		cfg.MaxThreads = 1000
+	}
+	if cfg.MaxCommentBytes <= 0 {
+		cfg.MaxCommentBytes = 16 << 10
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("review: create dir: %w", err)
+	}
+	return &Store{cfg: cfg, now: time.Now}, nil
+}
+
+// List returns the threads of the file at path in the workspace at dir,
+// or of every file when path is empty, ordered by path and line.
+func (s *Store) List(workspaceID, dir, path string) ([]Thread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var recs []*file
+	if path != "" {
+		p, err := cleanPath(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := s.loadLocked(workspaceID, p)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, f)
+	} else {
+		var err error
+		if recs, err = s.filesLocked(workspaceID); err != nil {
+			return nil, err
+		}
+	}
+	out := []Thread{}
+	for _, f := range recs {
+		if err := s.remapLocked(workspaceID, dir, f); err != nil {
+			return nil, err
+		}
+		for _, t := range f.Threads {
+			out = append(out, *t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		if out[i].StartLine != out[j].StartLine {
+			return out[i].StartLine < out[j].StartLine
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+// Thread returns one thread.
+func (s *Store) Thread(workspaceID, dir, id string) (*Thread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, t, err := s.findLocked(workspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.remapLocked(workspaceID, dir, f); err != nil {
+		return nil, err
+	}
+	c := *t
+	return &c, nil
+}
+
+// Create starts a thread on lines start to end of the file at path with a
+// first comment by the user of ctx.
+func (s *Store) Create(ctx context.Context, workspaceID, dir, path string, start, end int, body string) (*Thread, error) {
+	p, err := cleanPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBody(body); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.filesLocked(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, f := range all {
+		n += len(f.Threads)
+	}
+	if n >= s.cfg.MaxThreads {
+		return nil, fmt.Errorf("%w: %d threads", ErrTooMany, n)
+	}
+	f, err := s.loadLocked(workspaceID, p)
+	if err != nil {
+		return nil, err
+	}
+	text, err := s.read(dir, p)
+	if err != nil {
+		return nil, err
+	}
+	// Bring the other threads up to date first, so that all ranges refer
+	// to the same text.
+	s.rebase(f, text)
+	lines := diff.SplitLines(text)
+	if start < 1 || end < start || end > max(len(lines), 1) {
+		return nil, fmt.Errorf("%w: lines %d to %d are not in a file of %d lines", ErrInvalid, start, end, len(lines))
+	}
+	now := s.now().UTC()
+	by := author(ctx)
+	t := &Thread{
+		ID:        newID(),
+		Path:      p,
+		StartLine: start,
+		EndLine:   end,
+		Quote:     quote(lines, start, end),
+		Comments:  []Comment{{ID: newID(), Author: by, Body: body, CreatedAt: now}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	f.Threads = append(f.Threads, t)
+	if err := s.saveLocked(workspaceID, f); err != nil {
+		return nil, err
+	}
+	return s.changed(workspaceID, Change{Type: ThreadCreated, Thread: t, Comment: t.Comments[0].ID, By: by}), nil
+}
+
+// Reply adds a comment by the user of ctx to a thread.
+func (s *Store) Reply(ctx context.Context, workspaceID, dir, id, body string) (*Thread, error) {
+	if err := s.checkBody(body); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, workspaceID, dir, id, func(t *Thread, by Author, now time.Time) (Change, error) {
+		c := Comment{ID: newID(), Author: by, Body: body, CreatedAt: now}
+		t.Comments = append(t.Comments, c)
+		return Change{Type: CommentAdded, Comment: c.ID}, nil
+	})
+}
+
+// Edit replaces the body of a comment, which must be by the user of ctx.
+func (s *Store) Edit(ctx context.Context, workspaceID, dir, id, commentID, body string) (*Thread, error) {
+	if err := s.checkBody(body); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, workspaceID, dir, id, func(t *Thread, by Author, now time.Time) (Change, error) {
+		i, err := commentIndex(t, commentID, by)
+		if err != nil {
+			return Change{}, err
+		}
+		t.Comments[i].Body = body
+		t.Comments[i].EditedAt = &now
+		return Change{Type: CommentEdited, Comment: commentID}, nil
+	})
+}
+
+// DeleteComment removes a comment by the user of ctx. Removing the last
+// one removes the thread.
+func (s *Store) DeleteComment(ctx context.Context, workspaceID, dir, id, commentID string) (*Thread, error) {
+	return s.update(ctx, workspaceID, dir, id, func(t *Thread, by Author, now time.Time) (Change, error) {
+		i, err := commentIndex(t, commentID, by)
+		if err != nil {
+			return Change{}, err
+		}
+		t.Comments = append(t.Comments[:i], t.Comments[i+1:]...)
+		if len(t.Comments) == 0 {
+			return Change{Type: ThreadDeleted}, nil
+		}
+		return Change{Type: CommentDeleted, Comment: commentID}, nil
+	})
+}
+
+// SetResolved resolves or reopens a thread.
+func (s *Store) SetResolved(ctx context.Context, workspaceID, dir, id string, resolved bool) (*Thread, error) {
+	return s.update(ctx, workspaceID, dir, id, func(t *Thread, by Author, now time.Time) (Change, error) {
+		t.Resolved = resolved
+		if !resolved {
+			t.ResolvedBy, t.ResolvedAt = nil, nil
+			return Change{Type: ThreadReopened}, nil
+		}
+		t.ResolvedBy, t.ResolvedAt = &by, &now
+		return Change{Type: ThreadResolved}, nil
+	})
+}
+
+// Delete removes a thread.
+func (s *Store) Delete(ctx context.Context, workspaceID, dir, id string) error {
+	_, err := s.update(ctx, workspaceID, dir, id, func(t *Thread, by Author, now time.Time) (Change, error) {
+		return Change{Type: ThreadDeleted}, nil
+	})
+	return err
+}
+
+// Starter returns the ID of the user who started a thread.
+func (s *Store) Starter(workspaceID, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, t, err := s.findLocked(workspaceID, id)
+	if err != nil {
+		return "", err
+	}
+	if len(t.Comments) == 0 {
+		return "", nil
+	}
+	return t.Comments[0].Author.ID, nil
+}
+
+// update applies fn to a thread and saves it, removing the thread when
+// fn reports ThreadDeleted.
+func (s *Store) update(ctx context.Context, workspaceID, dir, id string, fn func(t *Thread, by Author, now time.Time) (Change, error)) (*Thread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, t, err := s.findLocked(workspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.remapLocked(workspaceID, dir, f); err != nil {
+		return nil, err
+	}
+	old := *t
+	old.Comments = append([]Comment(nil), t.Comments...)
+	now := s.now().UTC()
+	by := author(ctx)
+	c, err := fn(t, by, now)
+	if err != nil {
+		return nil, err
+	}
+	t.UpdatedAt = now
+	if c.Type == ThreadDeleted {
+		for i, o := range f.Threads {
+			if o == t {
+				f.Threads = append(f.Threads[:i], f.Threads[i+1:]...)
+				break
+			}
+		}
+	}
+	if err := s.saveLocked(workspaceID, f); err != nil {
+		// Undo the change, so memory matches the disk.
+		*t = old
+		if c.Type == ThreadDeleted {
+			f.Threads = append(f.Threads, t)
+		}
+		return nil, err
+	}
+	c.Thread, c.By = t, by
+	return s.changed(workspaceID, c), nil
+}
+
+// changed notifies about c and returns a copy of its thread.
+func (s *Store) changed(workspaceID string, c Change) *Thread {
+	t := *c.Thread
+	t.Comments = append([]Comment(nil), t.Comments...)
+	c.Thread = &t
+	if s.cfg.Notify != nil {
+		s.cfg.Notify(workspaceID, t.Path, c)
+	}
+	return &t
+}
+
+func commentIndex(t *Thread, commentID string, by Author) (int, error) {
+	for i, c := range t.Comments {
+		if c.ID != commentID {
+			continue
+		}
+		if c.Author.ID != by.ID {
+			return 0, ErrForbidden
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("%w: no comment %s", ErrNotFound, commentID)
+}
+
+func (s *Store) checkBody(body string) error {
+	if strings.TrimSpace(body) == "" || len(body) > s.cfg.MaxCommentBytes || !utf8.ValidString(body) {
+		return fmt.Errorf("%w: comments must be 1 to %d bytes of text", ErrInvalid, s.cfg.MaxCommentBytes)
+	}
+	return nil
+}
+
+// remapLocked moves the ranges of f's threads to the file's current text,
+// if it changed. s.mu must be held.
+func (s *Store) remapLocked(workspaceID, dir string, f *file) error {
+	if len(f.Threads) == 0 {
+		return nil
+	}
+	text, err := s.read(dir, f.Path)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// The file was removed, perhaps to be restored; its threads stay
+		// where they were until it exists again.
+		return nil
+	case errors.Is(err, ErrInvalid):
+		return nil
+	case err != nil:
+		return err
+	}
+	if text == f.Base {
+		return nil
+	}
+	s.rebase(f, text)
+	return s.saveLocked(workspaceID, f)
+}
+
+// rebase moves the ranges of f's threads from f.Base to text.
+func (s *Store) rebase(f *file, text string) {
+	if text == f.Base {
+		return
+	}
+	lines := diff.MapLines(f.Base, text)
+	newLines := diff.SplitLines(text)
+	for _, t := range f.Threads {
+		if t.Outdated {
+			continue
+		}
+		start, end := 0, 0
+		for l := t.StartLine; l <= t.EndLine && l <= len(lines); l++ {
+			if n := lines[l-1]; n > 0 {
+				if start == 0 {
+					start = n
+				}
+				end = n
+			}
+		}
+		if start == 0 {
+			start, end = find(newLines, t.Quote, t.StartLine)
+		}
+		if start == 0 {
+			t.Outdated = true
+			continue
+		}
+		t.StartLine, t.EndLine = start, end
+	}
+	f.Base = text
+}
+
+// find looks for the lines of quote in lines, returning the occurrence
+// nearest to line, or 0, 0.
+func find(lines []string, quote string, line int) (int, int) {
+	q := diff.SplitLines(quote)
+	if len(q) == 0 || strings.TrimSpace(quote) == "" {
+		return 0, 0
+	}
+	best, dist := 0, 0
+	for i := 0; i+len(q) <= len(lines); i++ {
+		if !matches(lines[i:i+len(q)], q) {
+			continue
+		}
+		d := max(i+1-line, line-i-1)
+		if best == 0 || d < dist {
+			best, dist = i+1, d
+		}
+	}
+	if best == 0 {
+		return 0, 0
+	}
+	return best, best + len(q) - 1
+}
+
+func matches(a, b []string) bool {
+	for i := range a {
+		if strings.TrimRight(a[i], "\r\n") != strings.TrimRight(b[i], "\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+func quote(lines []string, start, end int) string {
+	if start > len(lines) {
+		return ""
+	}
+	return strings.Join(lines[start-1:min(end, len(lines))], "")
+}
+
+// read returns the text of the file at p.
+func (s *Store) read(dir, p string) (string, error) {
+	fsys, err := files.New(dir)
+	if err != nil {
+		return "", err
+	}
+	e, err := fsys.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: no file %s", ErrNotFound, p)
+	}
+	if err != nil {
+		return "", err
+	}
+	if e.Type != files.TypeFile || e.Size > int64(s.cfg.MaxFileBytes) {
+		return "", fmt.Errorf("%w: %s is not a text file of at most %d bytes", ErrInvalid, p, s.cfg.MaxFileBytes)
+	}
+	data, err := fsys.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: no file %s", ErrNotFound, p)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("%w: %s is not UTF-8 text", ErrInvalid, p)
+	}
+	return string(data), nil
+}
+
+func cleanPath(path string) (string, error) {
+	p, err := files.Clean(path)
+	if err != nil || p == "" {
+		return "", fmt.Errorf("%w: invalid path %q", ErrInvalid, path)
+	}
+	return p, nil
+}
+
+func (s *Store) dir(workspaceID string) string {
+	return filepath.Join(s.cfg.Dir, workspaceID)
+}
+
+func (s *Store) file(workspaceID, p string) string {
+	sum := sha256.Sum256([]byte(p))
+	return filepath.Join(s.dir(workspaceID), hex.EncodeToString(sum[:16])+".json")
+}
+
+// loadLocked reads the threads of the file at p, or returns an empty
+// record. s.mu must be held.
+func (s *Store) loadLocked(workspaceID, p string) (*file, error) {
+	f, err := readFile(s.file(workspaceID, p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &file{Path: p}, nil
+	}
+	return f, err
+}
+
+func readFile(name string) (*file, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("review: read %s: %w", name, err)
+	}
+	return &f, nil
+}
+
+// filesLocked reads the threads of every reviewed file of a workspace.
+// s.mu must be held.
+func (s *Store) filesLocked(workspaceID string) ([]*file, error) {
+	ents, err := os.ReadDir(s.dir(workspaceID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []*file
+	for _, e := range ents {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		f, err := readFile(filepath.Join(s.dir(workspaceID), e.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// findLocked returns the thread id and its file. s.mu must be held.
+func (s *Store) findLocked(workspaceID, id string) (*file, *Thread, error) {
+	all, err := s.filesLocked(workspaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range all {
+		for _, t := range f.Threads {
+			if t.ID == id {
+				return f, t, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: no thread %s", ErrNotFound, id)
+}
+
+// saveLocked atomically writes the threads of f, removing the record once
+// it has none. s.mu must be held.
+func (s *Store) saveLocked(workspaceID string, f *file) error {
+	name := s.file(workspaceID, f.Path)
+	if len(f.Threads) == 0 {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("review: remove threads: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(s.dir(workspaceID), 0o700); err != nil {
+		return fmt.Errorf("review: create dir: %w", err)
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir(workspaceID), ".thread-*")
+	if err != nil {
+		return fmt.Errorf("review: write threads: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("review: write threads: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("review: write threads: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("review: write threads: %w", err)
+	}
+	return nil
+}
+
+func author(ctx context.Context) Author {
+	u := auth.UserFrom(ctx)
+	if u == nil {
+		return Author{}
+	}
+	name := u.Name
+	if name == "" {
+		name = u.Email
+	}
+	return Author{ID: u.ID, Name: name}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,257 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diff"
+)
+
+// testStore returns a Store and a workspace directory holding main.go with
+// text.
+func testStore(t *testing.T, text string) (*Store, string) {
+	t.Helper()
+	s, err := NewStore(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write(t, dir, text)
+	return s, dir
+}
+
+func write(t *testing.T, dir, text string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	const base = "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name       string
+		text       string
+		start, end int
+		outdated   bool
+	}{
+		{"unchanged", base, 2, 3, false},
+		{"line inserted above", "x\na\nb\nc\nd\ne\n", 3, 4, false},
+		{"lines inserted above", "x\ny\nz\na\nb\nc\nd\ne\n", 5, 6, false},
+		{"line deleted above", "b\nc\nd\ne\n", 1, 2, false},
+		{"line inserted below", "a\nb\nc\nd\nx\ne\n", 2, 3, false},
+		{"lines deleted below", "a\nb\nc\n", 2, 3, false},
+		{"line inserted inside", "a\nb\nx\nc\nd\ne\n", 2, 4, false},
+		{"first line deleted", "a\nc\nd\ne\n", 2, 2, false},
+		{"last line deleted", "a\nb\nd\ne\n", 2, 2, false},
+		{"line endings changed", "a\r\nb\r\nc\r\nd\r\ne\r\n", 2, 3, false},
+		{"block moved", "a\nd\ne\nb\nc\n", 4, 5, false},
+		// The lines are gone: the thread keeps where they were.
+		{"range deleted", "a\nd\ne\n", 2, 3, true},
+		{"range replaced", "a\nx\ny\nd\ne\n", 2, 3, true},
+		{"file emptied", "", 2, 3, true},
+	}
+	for _, tt := range tests {
+		s, dir := testStore(t, base)
+		th, err := s.Create(context.Background(), "ws1", dir, "main.go", 2, 3, "why?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if th.Quote != "b\nc\n" {
+			t.Fatalf("Quote = %q", th.Quote)
+		}
+		write(t, dir, tt.text)
+		got, err := s.Thread("ws1", dir, th.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.StartLine != tt.start || got.EndLine != tt.end || got.Outdated != tt.outdated {
+			t.Errorf("%s: thread at %d-%d, outdated %v; want %d-%d, outdated %v",
+				tt.name, got.StartLine, got.EndLine, got.Outdated, tt.start, tt.end, tt.outdated)
+		}
+	}
+}
+
+func TestRebaseAcrossEdits(t *testing.T) {
+	// Each edit is mapped from the text the anchors were last moved to.
+	s, dir := testStore(t, "a\nb\nc\n")
+	th, err := s.Create(context.Background(), "ws1", dir, "main.go", 3, 3, "here")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, step := range []struct {
+		text string
+		line int
+	}{
+		{"x\na\nb\nc\n", 4},
+		{"x\na\nc\n", 3},
+		{"x\na\nc\ny\n", 3},
+		{"c\n", 1},
+	} {
+		write(t, dir, step.text)
+		threads, err := s.List("ws1", dir, "main.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(threads) != 1 || threads[0].ID != th.ID || threads[0].StartLine != step.line || threads[0].Outdated {
+			t.Fatalf("after edit %d, List = %+v; want line %d", i, threads, step.line)
+		}
+	}
+
+	// An outdated thread stays so, and where it was, even when its lines
+	// come back.
+	write(t, dir, "x\n")
+	if got, _ := s.Thread("ws1", dir, th.ID); !got.Outdated || got.StartLine != 1 {
+		t.Fatalf("after deleting the line, thread = %+v", got)
+	}
+	write(t, dir, "y\nz\nc\n")
+	if got, _ := s.Thread("ws1", dir, th.ID); !got.Outdated || got.StartLine != 1 {
+		t.Errorf("after restoring the line, thread = %+v; want it left outdated", got)
+	}
+}
+
+func TestCreateRange(t *testing.T) {
+	s, dir := testStore(t, "a\nb\n")
+	for _, r := range [][2]int{{0, 1}, {2, 1}, {1, 3}} {
+		if _, err := s.Create(context.Background(), "ws1", dir, "main.go", r[0], r[1], "x"); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Create(%d-%d) = %v, want ErrInvalid", r[0], r[1], err)
+		}
+	}
+	if _, err := s.Create(context.Background(), "ws1", dir, "missing.go", 1, 1, "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Create on a missing file = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Create(context.Background(), "ws1", dir, "main.go", 1, 1, "  "); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Create with a blank comment = %v, want ErrInvalid", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	lines := diff.SplitLines("x\nb\nc\ny\nb\nc\nz\n")
+	tests := []struct {
+		quote      string
+		line       int
+		start, end int
+	}{
+		{"b\nc\n", 1, 2, 3},
+		// A tie goes to the earlier one.
+		{"b\nc\n", 3, 2, 3},
+		{"b\nc\n", 4, 5, 6},
+		{"b\nc\n", 5, 5, 6},
+		{"b\nc\n", 7, 5, 6},
+		{"b\r\nc\r\n", 1, 2, 3},
+		{"z", 1, 7, 7},
+		{"c\nz\n", 1, 6, 7},
+		{"q\n", 1, 0, 0},
+		{"c\nb\nq\n", 1, 0, 0},
+		{"", 1, 0, 0},
+		{"  \n", 1, 0, 0},
+		{strings.Repeat("b\n", 10), 1, 0, 0},
+	}
+	for _, tt := range tests {
+		if start, end := find(lines, tt.quote, tt.line); start != tt.start || end != tt.end {
+			t.Errorf("find(%q, near %d) = %d, %d; want %d, %d", tt.quote, tt.line, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+type fakeWorkspaces map[string]string
+
+func (w fakeWorkspaces) Open(id string) (string, error) {
+	dir, ok := w[id]
+	if !ok {
+		return "", errors.New("no such workspace")
+	}
+	return dir, nil
+}
+
+// as serves requests as the user named by the X-User header, with the
+// workspace role in X-Role, as auth.Middleware would.
+func as(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := auth.WithUser(r.Context(), &auth.User{ID: r.Header.Get("X-User"), Name: r.Header.Get("X-User")})
+		ctx = workspace.WithRole(ctx, workspace.Role(r.Header.Get("X-Role")))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func TestHandlerRoles(t *testing.T) {
+	s, dir := testStore(t, "a\nb\nc\n")
+	mux := http.NewServeMux()
+	NewHandler(s, fakeWorkspaces{"ws1": dir}).Register(mux)
+	h := as(mux)
+	do := func(user string, role workspace.Role, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/workspaces/ws1/reviews"+target, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		req.Header.Set("X-Role", string(role))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Viewers review: they start threads, reply and resolve.
+	rec := do("viv", workspace.RoleViewer, "POST", "", `{"path": "main.go", "startLine": 2, "body": "typo"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("viewer POST thread = %d %s", rec.Code, rec.Body)
+	}
+	var th Thread
+	if err := json.Unmarshal(rec.Body.Bytes(), &th); err != nil {
+		t.Fatal(err)
+	}
+	if th.StartLine != 2 || th.EndLine != 2 || th.Comments[0].Author.ID != "viv" {
+		t.Errorf("created %+v", th)
+	}
+	rec = do("ed", workspace.RoleEditor, "POST", "/"+th.ID+"/comments", `{"body": "fixed"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("editor reply = %d %s", rec.Code, rec.Body)
+	}
+	var replied Thread
+	json.Unmarshal(rec.Body.Bytes(), &replied)
+	reply := replied.Comments[1].ID
+	if rec := do("viv", workspace.RoleViewer, "PATCH", "/"+th.ID, `{"resolved": true}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"resolvedBy":{"id":"viv"`) {
+		t.Errorf("viewer resolve = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("viv", workspace.RoleViewer, "GET", "?resolved=false", ""); rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+		t.Errorf("GET ?resolved=false = %d %s", rec.Code, rec.Body)
+	}
+
+	// Comments are changed by their authors only.
+	if rec := do("viv", workspace.RoleViewer, "PATCH", "/"+th.ID+"/comments/"+reply, `{"body": "not fixed"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("editing another's comment = %d, want 403", rec.Code)
+	}
+	if rec := do("ed", workspace.RoleEditor, "PATCH", "/"+th.ID+"/comments/"+reply, `{"body": "fixed now"}`); rec.Code != http.StatusOK {
+		t.Errorf("editing one's comment = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("viv", workspace.RoleViewer, "DELETE", "/"+th.ID+"/comments/"+reply, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("deleting another's comment = %d, want 403", rec.Code)
+	}
+
+	// Threads are deleted by who started them and the owner.
+	if rec := do("ed", workspace.RoleEditor, "DELETE", "/"+th.ID, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("editor deleting a viewer's thread = %d, want 403", rec.Code)
+	}
+	if rec := do("own", workspace.RoleOwner, "DELETE", "/"+th.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("owner deleting a thread = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("viv", workspace.RoleViewer, "GET", "/"+th.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of a deleted thread = %d, want 404", rec.Code)
+	}
+	rec = do("viv", workspace.RoleViewer, "POST", "", `{"path": "main.go", "startLine": 1, "endLine": 3, "body": "hm"}`)
+	json.Unmarshal(rec.Body.Bytes(), &th)
+	if rec := do("viv", workspace.RoleViewer, "DELETE", "/"+th.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("viewer deleting their thread = %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do("viv", workspace.RoleViewer, "POST", "", `{"path": "main.go", "startLine": 9, "body": "x"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST outside the file = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,404 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputMode selects what a run's output events carry of the escape
+// sequences and carriage returns programs print.
+type OutputMode string
+
+const (
+	// OutputRaw passes output through unchanged, for clients that render
+	// it with a terminal emulator. It is the default.
+	OutputRaw OutputMode = "raw"
+	// OutputPlain strips escape sequences and applies carriage returns
+	// and line erasing, so a progress bar redrawn in place is one line.
+	OutputPlain OutputMode = "plain"
+	// OutputHTML is OutputPlain with the text HTML-escaped and colors and
+	// text attributes turned into spans: classes ansi-fg-N and ansi-bg-N
+	// for the 16 basic colors, ansi-bold, ansi-dim, ansi-italic,
+	// ansi-underline, ansi-strike and ansi-inverse, and inline styles for
+	// the other 256 colors and 24-bit ones.
+	OutputHTML OutputMode = "html"
+)
+
+// ErrInvalidOutputMode is returned for unknown output modes.
+var ErrInvalidOutputMode = errors.New("runner: invalid output mode")
+
+func (m OutputMode) check() error {
+	switch m {
+	case "", OutputRaw, OutputPlain, OutputHTML:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidOutputMode, m)
+}
+
+// Limits on escape sequences held back until they are complete; longer
+// ones are dropped.
+const (
+	maxCSI = 64
+	maxOSC = 4096
+)
+
+// color is a foreground or background color: none, an index into the
+// 256-color palette, or 24-bit RGB.
+type color struct {
+	kind uint8 // 0 default, 1 palette, 2 RGB
+	v    uint32
+}
+
+// style is the SGR state text is printed in.
+type style struct {
+	fg, bg                                           color
+	bold, dim, italic, underline, inverse, strikeout bool
+}
+
+type cell struct {
+	r  rune
+	st style
+}
+
+// termFilter interprets the output of one stream as a terminal would,
+// line by line. The line being written is kept, so a carriage return can
+// overwrite it; once sent, a line that changes other than by growing is
+// sent again whole, flagged as replacing the client's last line.
+type termFilter struct {
+	html bool
+	st   style
+	line []cell
+	col  int
+	// sent is the rendering of line the client has.
+	sent string
+	// esc holds an escape sequence cut off at the end of a write.
+	esc string
+}
+
+func newTermFilter(mode OutputMode) *termFilter {
+	if mode == "" || mode == OutputRaw {
+		return nil
+	}
+	return &termFilter{html: mode == OutputHTML}
+}
+
+// write interprets text and returns what to send: data to append, or, when
+// rewrite is set, to put in place of the client's unterminated last line.
+func (f *termFilter) write(text string) (data string, rewrite bool) {
+	if f.esc != "" {
+		text, f.esc = f.esc+text, ""
+	}
+	var done strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == 0x1b:
+			n, complete := f.escape(text[i:])
+			if !complete {
+				f.esc = text[i:]
+				i = len(text)
+				continue
+			}
+			i += n
+			continue
+		case c == '\n':
+			done.WriteString(f.render())
+			done.WriteByte('\n')
+			f.line, f.col = f.line[:0], 0
+		case c == '\r':
+			f.col = 0
+		case c == '\b':
+			if f.col > 0 {
+				f.col--
+			}
+		case c == '\t':
+			f.put('\t')
+		case c < 0x20 || c == 0x7f:
+			// Other control characters, such as the bell, do not print.
+		default:
+			r, size := utf8.DecodeRuneInString(text[i:])
+			f.put(r)
+			i += size
+			continue
+		}
+		i++
+	}
+	line := f.render()
+	full := done.String() + line
+	prev := f.sent
+	f.sent = line
+	if strings.HasPrefix(full, prev) {
+		return full[len(prev):], false
+	}
+	return full, true
+}
+
+// put writes r at the cursor.
+func (f *termFilter) put(r rune) {
+	for len(f.line) < f.col {
+		f.line = append(f.line, cell{r: ' '})
+	}
+	c := cell{r: r, st: f.st}
+	if f.col < len(f.line) {
+		f.line[f.col] = c
+	} else {
+		f.line = append(f.line, c)
+	}
+	f.col++
+}
+
+// escape interprets the escape sequence s starts with, returning its
+// length, or complete false if s ends before it does.
+func (f *termFilter) escape(s string) (n int, complete bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s) && i < maxCSI; i++ {
+			if c := s[i]; c >= 0x40 && c <= 0x7e {
+				f.csi(s[2:i], c)
+				return i + 1, true
+			}
+		}
+		if len(s) >= maxCSI {
+			return maxCSI, true
+		}
+		return 0, false
+	case ']':
+		// Operating system commands, such as window titles, end in BEL
+		// or ST.
+		for i := 2; i < len(s) && i < maxOSC; i++ {
+			if s[i] == 0x07 {
+				return i + 1, true
+			}
+			if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2, true
+			}
+		}
+		if len(s) >= maxOSC {
+			return maxOSC, true
+		}
+		return 0, false
+	case '(', ')', '*', '+':
+		// Character set designations take one more byte.
+		if len(s) < 3 {
+			return 0, false
+		}
+		return 3, true
+	default:
+		return 2, true
+	}
+}
+
+// csi applies the control sequence with parameters params and final byte
+// final. Cursor movement within the line and erasing are applied; other
+// sequences are dropped.
+func (f *termFilter) csi(params string, final byte) {
+	if params != "" && params[0] >= 0x3c && params[0] <= 0x3f {
+		// Private sequences, such as showing the cursor, take no part.
+		return
+	}
+	args := csiArgs(params)
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return def
+	}
+	switch final {
+	case 'm':
+		f.sgr(args)
+	case 'K':
+		switch arg(0, 0) {
+		case 0:
+			if f.col < len(f.line) {
+				f.line = f.line[:f.col]
+			}
+		case 1:
+			for i := 0; i <= f.col && i < len(f.line); i++ {
+				f.line[i] = cell{r: ' '}
+			}
+		case 2:
+			f.line = f.line[:0]
+		}
+	case 'G':
+		f.col = arg(0, 1) - 1
+	case 'C':
+		f.col += arg(0, 1)
+	case 'D':
+		f.col = max(f.col-arg(0, 1), 0)
+	}
+}
+
+// csiArgs parses the numeric parameters of a control sequence; missing
+// ones are 0, and subparameters count as parameters.
+func csiArgs(params string) []int {
+	if params == "" {
+		return nil
+	}
+	parts := strings.Split(strings.ReplaceAll(params, ":", ";"), ";")
+	args := make([]int, len(parts))
+	for i, p := range parts {
+		args[i], _ = strconv.Atoi(p)
+	}
+	return args
+}
+
+// sgr applies Select Graphic Rendition parameters.
+func (f *termFilter) sgr(args []int) {
+	if len(args) == 0 {
+		args = []int{0}
+	}
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == 0:
+			f.st = style{}
+		case a == 1:
+			f.st.bold = true
+		case a == 2:
+			f.st.dim = true
+		case a == 3:
+			f.st.italic = true
+		case a == 4:
+			f.st.underline = true
+		case a == 7:
+			f.st.inverse = true
+		case a == 9:
+			f.st.strikeout = true
+		case a == 22:
+			f.st.bold, f.st.dim = false, false
+		case a == 23:
+			f.st.italic = false
+		case a == 24:
+			f.st.underline = false
+		case a == 27:
+			f.st.inverse = false
+		case a == 29:
+			f.st.strikeout = false
+		case a >= 30 && a <= 37:
+			f.st.fg = color{1, uint32(a - 30)}
+		case a >= 90 && a <= 97:
+			f.st.fg = color{1, uint32(a - 90 + 8)}
+		case a == 39:
+			f.st.fg = color{}
+		case a >= 40 && a <= 47:
+			f.st.bg = color{1, uint32(a - 40)}
+		case a >= 100 && a <= 107:
+			f.st.bg = color{1, uint32(a - 100 + 8)}
+		case a == 49:
+			f.st.bg = color{}
+		case a == 38 || a == 48:
+			c, n := extendedColor(args[i+1:])
+			i += n
+			if a == 38 {
+				f.st.fg = c
+			} else {
+				f.st.bg = c
+			}
+		}
+	}
+}
+
+// extendedColor parses the "5;n" or "2;r;g;b" following 38 or 48, returning
+// the color and how many arguments it took.
+func extendedColor(args []int) (color, int) {
+	switch {
+	case len(args) >= 2 && args[0] == 5:
+		return color{1, uint32(args[1] & 0xff)}, 2
+	case len(args) >= 4 && args[0] == 2:
+		return color{2, uint32(args[1]&0xff)<<16 | uint32(args[2]&0xff)<<8 | uint32(args[3]&0xff)}, 4
+	}
+	return color{}, len(args)
+}
+
+// render returns the current line as text or HTML.
+func (f *termFilter) render() string {
+	var b strings.Builder
+	if !f.html {
+		for _, c := range f.line {
+			b.WriteRune(c.r)
+		}
+		return b.String()
+	}
+	for i := 0; i < len(f.line); {
+		j := i
+		var run strings.Builder
+		for ; j < len(f.line) && f.line[j].st == f.line[i].st; j++ {
+			run.WriteRune(f.line[j].r)
+		}
+		text := html.EscapeString(run.String())
+		if open := spanOpen(f.line[i].st); open != "" {
+			b.WriteString(open + text + "</span>")
+		} else {
+			b.WriteString(text)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// spanOpen returns the opening tag of a span in st, or "" for the default
+// style.
+func spanOpen(st style) string {
+	if st == (style{}) {
+		return ""
+	}
+	var classes, css []string
+	fg, bg := st.fg, st.bg
+	if st.inverse {
+		if fg.kind == 0 && bg.kind == 0 {
+			classes = append(classes, "ansi-inverse")
+		}
+		fg, bg = bg, fg
+	}
+	for _, c := range []struct {
+		col        color
+		class, css string
+	}{{fg, "ansi-fg-", "color:"}, {bg, "ansi-bg-", "background-color:"}} {
+		switch {
+		case c.col.kind == 1 && c.col.v < 16:
+			classes = append(classes, c.class+strconv.Itoa(int(c.col.v)))
+		case c.col.kind != 0:
+			css = append(css, fmt.Sprintf("%s#%06x", c.css, rgb(c.col)))
+		}
+	}
+	for _, a := range []struct {
+		on    bool
+		class string
+	}{{st.bold, "ansi-bold"}, {st.dim, "ansi-dim"}, {st.italic, "ansi-italic"}, {st.underline, "ansi-underline"}, {st.strikeout, "ansi-strike"}} {
+		if a.on {
+			classes = append(classes, a.class)
+		}
+	}
+	var b strings.Builder
+	b.WriteString("<span")
+	if len(classes) > 0 {
+		b.WriteString(` class="` + strings.Join(classes, " ") + `"`)
+	}
+	if len(css) > 0 {
+		b.WriteString(` style="` + strings.Join(css, ";") + `"`)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// rgb returns the 24-bit value of an RGB color or of palette colors 16 and
+// up, which are a 6×6×6 cube from 16 to 231 and a gray ramp from 232.
+func rgb(c color) uint32 {
+	if c.kind == 2 {
+		return c.v
+	}
+	n := c.v
+	if n >= 232 {
+		g := 8 + 10*(n-232)
+		return g<<16 | g<<8 | g
+	}
+	levels := [6]uint32{0, 95, 135, 175, 215, 255}
+	n -= 16
+	return levels[n/36]<<16 | levels[n/6%6]<<8 | levels[n%6]
+}
@@ -0,0 +1,268 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/artifact"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+)
+
+// Target is a GOOS/GOARCH pair to compile for.
+type Target struct {
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+}
+
+func (t Target) String() string { return t.GOOS + "/" + t.GOARCH }
+
+// Targets are the platforms Build accepts.
+var Targets = []Target{
+	{"linux", "amd64"}, {"linux", "arm64"}, {"linux", "386"}, {"linux", "arm"}, {"linux", "riscv64"},
+	{"darwin", "amd64"}, {"darwin", "arm64"},
+	{"windows", "amd64"}, {"windows", "arm64"}, {"windows", "386"},
+	{"freebsd", "amd64"}, {"freebsd", "arm64"},
+}
+
+var (
+	// ErrUnsupportedTarget is returned for GOOS/GOARCH pairs not in Targets.
+	ErrUnsupportedTarget = errors.New("runner: unsupported build target")
+	// ErrNoArtifact is returned for unknown or expired build artifacts.
+	ErrNoArtifact = errors.New("runner: artifact not found")
+	// ErrNoArtifactStore is returned by Build and profiled runs when the
+	// Runner has no Config.Artifacts to store their outputs in.
+	ErrNoArtifactStore = errors.New("runner: no artifact store")
+)
+
+// BuildRequest is a program to compile without running it.
+type BuildRequest struct {
+	Source    string `json:"source,omitempty"`
+	Files     []File `json:"files,omitempty"`
+	Module    string `json:"module,omitempty"`
+	Main      string `json:"main,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	// GOOS and GOARCH default to linux/amd64.
+	GOOS   string `json:"goos,omitempty"`
+	GOARCH string `json:"goarch,omitempty"`
+	// CGO enables cgo. Cross-compiling with cgo needs a C cross compiler in
+	// the toolchain image; without one the build fails.
+	CGO bool `json:"cgo,omitempty"`
+	// Options are extra go build flags, as for runs.
+	Options BuildOptions `json:"options,omitempty"`
+}
+
+// Artifact is a stored build output, downloadable until ExpiresAt.
+type Artifact struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Target    Target    `json:"target"`
+	GoVersion string    `json:"goVersion,omitempty"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// URL downloads the binary without an access token until it expires.
+	URL string `json:"url,omitempty"`
+}
+
+// BuildResult is the outcome of a Build. Artifact is set when the build
+// succeeded.
+type BuildResult struct {
+	Target      Target            `json:"target"`
+	GoVersion   string            `json:"goVersion,omitempty"`
+	ExitCode    int               `json:"exitCode"`
+	TimedOut    bool              `json:"timedOut"`
+	DurationMS  int64             `json:"durationMs"`
+	Stderr      string            `json:"stderr"`
+	Diagnostics []diag.Diagnostic `json:"diagnostics,omitempty"`
+	Artifact    *Artifact         `json:"artifact,omitempty"`
+	// Cached reports that the binary of an identical earlier build was
+	// reused.
+	Cached bool `json:"cached,omitempty"`
+}
+
+// crossBuildScript compiles the main package $1 into /out/$2; the build
+// flags follow.
+const crossBuildScript = `pkg="$1" out="/out/$2"; shift 2; go build -trimpath "$@" -o "$out" "$pkg"`
+
+// Build compiles req for its target in the sandbox and stores the binary
+// as an Artifact. As with Run, compile errors are reported through the
+// result rather than as an error.
+func (r *Runner) Build(ctx context.Context, req BuildRequest) (*BuildResult, error) {
+	if r.cfg.Artifacts == nil {
+		return nil, ErrNoArtifactStore
+	}
+	target, err := req.target()
+	if err != nil {
+		return nil, err
+	}
+	prog := Request{Source: req.Source, Files: req.Files, Module: req.Module, Main: req.Main}
+	files, err := prog.files("main.go")
+	if err != nil {
+		return nil, err
+	}
+	module, err := prog.module(files)
+	if err != nil {
+		return nil, err
+	}
+	mainPkg, err := prog.mainPackage()
+	if err != nil {
+		return nil, err
+	}
+	tc, err := r.toolchain(req.Workspace, req.GoVersion)
+	if err != nil {
+		return nil, err
+	}
+	// Cross builds always trim paths.
+	req.Options.Trimpath = false
+	flags, err := req.Options.args()
+	if err != nil {
+		return nil, err
+	}
+	files, pr := goProject(files)
+
+	release, err := r.admit(ctx, newSyncEmitter(nil))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	sc, err := r.scratch(files)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(sc.dir)
+
+	name := binaryName(req.Main, target)
+	spec := r.buildSpec(tc.Image, sc, path.Join("/workspace", module))
+	spec.Cmd = append([]string{"sh", "-c", crossBuildScript, "sh", mainPkg, name}, flags...)
+	spec.Network = pr.needsModules
+	cgo := "0"
+	if req.CGO {
+		cgo = "1"
+	}
+	spec.Env = r.buildEnv(pr.workspace, "CGO_ENABLED="+cgo, "GOOS="+target.GOOS, "GOARCH="+target.GOARCH)
+	spec.Env = req.Options.env(spec.Env)
+	var stderr buildOutput
+	spec.Stdout, spec.Stderr = io.Discard, &stderr
+
+	key := buildKey(spec, files)
+	spec.Workspace = req.Workspace
+	r.egress(&spec, req.Workspace)
+	if bin, ok := r.cached(key + ".bin"); ok {
+		res := &BuildResult{Target: target, GoVersion: tc.Version, Cached: true}
+		if res.Artifact, err = r.storeArtifact(ctx, bin, name, req.Workspace, target, tc.Version); err == nil {
+			return res, nil
+		}
+	}
+	er, err := r.sandbox.Exec(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	res := &BuildResult{
+		Target:     target,
+		GoVersion:  tc.Version,
+		ExitCode:   er.ExitCode,
+		TimedOut:   er.TimedOut,
+		DurationMS: er.Duration.Milliseconds(),
+		Stderr:     stderr.buf.String(),
+	}
+	if er.ExitCode != 0 || er.TimedOut {
+		res.Diagnostics = parseBuildErrors(res.Stderr)
+		return res, nil
+	}
+	bin := filepath.Join(sc.outDir, name)
+	if res.Artifact, err = r.storeArtifact(ctx, bin, name, req.Workspace, target, tc.Version); err != nil {
+		return nil, err
+	}
+	r.cacheBinary(key, bin)
+	return res, nil
+}
+
+func (req BuildRequest) target() (Target, error) {
+	t := Target{GOOS: req.GOOS, GOARCH: req.GOARCH}
+	if t.GOOS == "" {
+		t.GOOS = "linux"
+	}
+	if t.GOARCH == "" {
+		t.GOARCH = "amd64"
+	}
+	for _, s := range Targets {
+		if s == t {
+			return t, nil
+		}
+	}
+	return Target{}, fmt.Errorf("%w: %s", ErrUnsupportedTarget, t)
+}
+
+// binaryName names the executable after the main package's directory.
+func binaryName(main string, t Target) string {
+	name := "program"
+	if p, err := cleanRelPath(main); err == nil && main != "" {
+		name = path.Base(p)
+	}
+	if t.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// storeArtifact stores the binary at src as an artifact of the workspace
+// it was built from, if any.
+func (r *Runner) storeArtifact(ctx context.Context, src, name, workspace string, t Target, goVersion string) (*Artifact, error) {
+	a, err := r.cfg.Artifacts.PutFile(ctx, artifact.Artifact{
+		Kind:      artifact.KindBinary,
+		Name:      name,
+		Workspace: workspace,
+		Labels:    map[string]string{"goos": t.GOOS, "goarch": t.GOARCH, "goVersion": goVersion},
+	}, r.cfg.ArtifactTTL, src)
+	if err != nil {
+		return nil, fmt.Errorf("runner: store artifact: %w", err)
+	}
+	return binaryArtifact(a), nil
+}
+
+func binaryArtifact(a *artifact.Artifact) *Artifact {
+	return &Artifact{
+		ID:        a.ID,
+		Name:      a.Name,
+		Target:    Target{GOOS: a.Labels["goos"], GOARCH: a.Labels["goarch"]},
+		GoVersion: a.Labels["goVersion"],
+		Size:      a.Size,
+		SHA256:    a.SHA256,
+		CreatedAt: a.CreatedAt,
+		ExpiresAt: a.ExpiresAt,
+		URL:       a.URL,
+	}
+}
+
+// Artifact returns a stored build output the user of ctx may see and the
+// path of its binary.
+func (r *Runner) Artifact(ctx context.Context, id string) (*Artifact, string, error) {
+	a, ok := r.stored(ctx, id, artifact.KindBinary)
+	if !ok {
+		return nil, "", ErrNoArtifact
+	}
+	return binaryArtifact(a), r.cfg.Artifacts.Path(a), nil
+}
+
+// stored returns the artifact id if it is of one of kinds and the user of
+// ctx may see it.
+func (r *Runner) stored(ctx context.Context, id string, kinds ...artifact.Kind) (*artifact.Artifact, bool) {
+	if r.cfg.Artifacts == nil {
+		return nil, false
+	}
+	a, err := r.cfg.Artifacts.Get(id)
+	if err != nil || !slices.Contains(kinds, a.Kind) || !r.cfg.Access.Reads(ctx, a) {
+		return nil, false
+	}
+	return a, true
+}
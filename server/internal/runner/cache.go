@@ -0,0 +1,252 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gopanic"
+)
+
+// maxCachedOutput bounds the output of a run that is cached for replay.
+const maxCachedOutput = 1 << 20
+
+// What a result was served from, for Result.Cached.
+const (
+	// CachedBuild means the binary of an identical earlier build was run.
+	CachedBuild = "build"
+	// CachedOutput means the output of an identical earlier run was
+	// replayed without running the program.
+	CachedOutput = "output"
+)
+
+// cachedRun is the output of a run, as cached for replay.
+type cachedRun struct {
+	Events   []Event        `json:"events"`
+	ExitCode int            `json:"exitCode"`
+	Panic    *gopanic.Panic `json:"panic,omitempty"`
+}
+
+// outputRecorder collects a run's output events for the cache, up to
+// maxCachedOutput bytes.
+type outputRecorder struct {
+	events    []Event
+	size      int
+	truncated bool
+}
+
+func (o *outputRecorder) add(ev Event) {
+	if o.truncated {
+		return
+	}
+	if o.size += len(ev.Data); o.size > maxCachedOutput {
+		o.truncated, o.events = true, nil
+		return
+	}
+	o.events = append(o.events, ev)
+}
+
+// buildKey addresses the binary that building files with spec produces:
+// the hash of the image, command, environment and network mode of the
+// build and of every file.
+func buildKey(spec Spec, files []File) string {
+	h := sha256.New()
+	writeField(h, spec.Image)
+	writeField(h, strconv.FormatBool(spec.Network))
+	writeField(h, strings.Join(spec.Cmd, "\x00"))
+	writeField(h, strings.Join(spec.Env, "\x00"))
+	writeField(h, spec.WorkDir)
+	sorted := slices.Clone(files)
+	slices.SortFunc(sorted, func(a, b File) int { return strings.Compare(a.Path, b.Path) })
+	for _, f := range sorted {
+		writeField(h, f.Path)
+		writeField(h, f.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// runKey addresses the output of running the binary of build under
+// limits with env.
+func runKey(build string, limits Limits, output OutputMode, env []string) string {
+	h := sha256.New()
+	writeField(h, build)
+	data, _ := json.Marshal(limits)
+	writeField(h, string(data))
+	writeField(h, string(output))
+	for _, e := range env {
+		writeField(h, e)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeField writes s to h with its length, so fields cannot run into
+// each other.
+func writeField(h hash.Hash, s string) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(s)))
+	h.Write(n[:])
+	io.WriteString(h, s)
+}
+
+// cached returns the path of the cache entry name when caching is on and
+// the entry has not expired, marking it as used.
+func (r *Runner) cached(name string) (string, bool) {
+	if r.cfg.CacheTTL < 0 {
+		return "", false
+	}
+	p := filepath.Join(r.cfg.CacheDir, name)
+	fi, err := os.Stat(p)
+	if err != nil {
+		return "", false
+	}
+	now := time.Now()
+	if now.Sub(fi.ModTime()) > r.cfg.CacheTTL {
+		os.Remove(p)
+		return "", false
+	}
+	// The modification time orders entries for eviction.
+	os.Chtimes(p, now, now)
+	return p, true
+}
+
+// cachedBinary copies the cached binary of build key to dst.
+func (r *Runner) cachedBinary(key, dst string) bool {
+	p, ok := r.cached(key + ".bin")
+	return ok && copyFile(dst, p, 0o755) == nil
+}
+
+// cachedOutput returns the cached output of run key.
+func (r *Runner) cachedOutput(key string) (*cachedRun, bool) {
+	p, ok := r.cached(key + ".run.json")
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	var run cachedRun
+	if json.Unmarshal(data, &run) != nil {
+		return nil, false
+	}
+	return &run, true
+}
+
+// cacheBinary stores the binary at src as the output of build key.
+// Caching is best effort, so failures are ignored.
+func (r *Runner) cacheBinary(key, src string) {
+	if r.cfg.CacheTTL < 0 {
+		return
+	}
+	r.storeCached(key+".bin", func(w io.Writer) error {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
+
+// cacheOutput stores the output of run key.
+func (r *Runner) cacheOutput(key string, run *cachedRun) {
+	if r.cfg.CacheTTL < 0 {
+		return
+	}
+	r.storeCached(key+".run.json", func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(run)
+	})
+}
+
+// storeCached atomically writes the cache entry name and prunes the
+// cache.
+func (r *Runner) storeCached(name string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(r.cfg.CacheDir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(r.cfg.CacheDir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(r.cfg.CacheDir, name)); err != nil {
+		return err
+	}
+	r.pruneCache()
+	return nil
+}
+
+// pruneCache removes expired entries and, while the cache is over
+// CacheBytes, those used least recently.
+func (r *Runner) pruneCache() {
+	des, err := os.ReadDir(r.cfg.CacheDir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	var live []os.FileInfo
+	var total int64
+	for _, de := range des {
+		fi, err := de.Info()
+		if err != nil || strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		if now.Sub(fi.ModTime()) > r.cfg.CacheTTL {
+			os.Remove(filepath.Join(r.cfg.CacheDir, de.Name()))
+			continue
+		}
+		live = append(live, fi)
+		total += fi.Size()
+	}
+	slices.SortFunc(live, func(a, b os.FileInfo) int { return a.ModTime().Compare(b.ModTime()) })
+	for _, fi := range live {
+		if total <= r.cfg.CacheBytes {
+			break
+		}
+		os.Remove(filepath.Join(r.cfg.CacheDir, fi.Name()))
+		total -= fi.Size()
+	}
+}
+
+// copyFile copies src to the new file dst.
+func copyFile(dst, src string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("runner: copy %s: %w", filepath.Base(src), err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gopanic"
+)
+
+// maxBuildOutput caps the build stderr kept for parsing diagnostics.
+const maxBuildOutput = 256 << 10
+
+// buildOutput keeps the first maxBuildOutput bytes of the build phase's
+// stderr.
+type buildOutput struct {
+	buf strings.Builder
+}
+
+func (b *buildOutput) Write(p []byte) (int, error) {
+	if n := maxBuildOutput - b.buf.Len(); n > 0 {
+		b.buf.Write(p[:min(n, len(p))])
+	}
+	return len(p), nil
+}
+
+// parseBuildErrors extracts compiler errors from go build output. Paths
+// are made relative to the module root; indented lines that follow an
+// error, such as the have/want lines of a call mismatch, are appended to
+// its message.
+func parseBuildErrors(out string) []diag.Diagnostic {
+	var ds []diag.Diagnostic
+	last := -1
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "\t") && last >= 0 {
+			ds[last].Message += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		last = -1
+		d, ok := diag.ParseLine(line)
+		if !ok || d.Message == "too many errors" {
+			continue
+		}
+		d.File = strings.TrimPrefix(d.File, "/workspace/")
+		d.Source = "compiler"
+		ds = append(ds, d)
+		last = len(ds) - 1
+	}
+	return ds
+}
+
+// maxPanicOutput caps the run stderr kept for parsing a panic report,
+// which the runtime prints last.
+const maxPanicOutput = 256 << 10
+
+// panicOutput keeps the last maxPanicOutput bytes of the run phase's
+// stderr.
+type panicOutput struct {
+	buf []byte
+}
+
+func (t *panicOutput) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if n := len(t.buf) - maxPanicOutput; n > 0 {
+		t.buf = append(t.buf[:0], t.buf[n:]...)
+	}
+	return len(p), nil
+}
+
+// parsePanic extracts the panic report of a Go program that crashed.
+// Paths of the project's files are made relative to its root; those of
+// the standard library and module cache stay absolute.
+func parsePanic(out string) *gopanic.Panic {
+	p := gopanic.Parse(out)
+	if p == nil {
+		return nil
+	}
+	rel := func(f *gopanic.Frame) {
+		f.File = strings.TrimPrefix(f.File, "/workspace/")
+	}
+	for i := range p.Goroutines {
+		g := &p.Goroutines[i]
+		for j := range g.Frames {
+			rel(&g.Frames[j])
+		}
+		if g.CreatedBy != nil {
+			rel(g.CreatedBy)
+		}
+	}
+	return p
+}
@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DockerSandbox runs each Spec in a throwaway container through the docker
+// CLI. Setting Runtime to "runsc" runs containers under gVisor.
+type DockerSandbox struct {
+	// Binary is the docker executable; defaults to "docker".
+	Binary string
+	// Runtime selects an OCI runtime such as "runsc"; empty uses the daemon default.
+	Runtime string
+	// TmpSize is the size of the writable /tmp tmpfs, e.g. "256m".
+	TmpSize string
+	// Hosts are extra host name mappings, as "name:ip" for --add-host.
+	Hosts []string
+	// Network is the docker network of specs with networking; defaults
+	// to "bridge". A network without a route out keeps them to the
+	// network policy proxy.
+	Network string
+}
+
+// NewDockerSandbox returns a DockerSandbox using the given OCI runtime.
+func NewDockerSandbox(runtime string) *DockerSandbox {
+	return &DockerSandbox{Binary: "docker", Runtime: runtime, TmpSize: "256m"}
+}
+
+// Exec implements Sandbox. The container is removed once it has exited,
+// rather than with --rm, so that a process killed by SIGKILL can be told
+// apart from one killed for running out of memory.
+func (d *DockerSandbox) Exec(ctx context.Context, spec Spec) (*ExecResult, error) {
+	name := "ai-ide-run-" + randomID()
+	defer func() {
+		go exec.Command(d.binary(), "rm", "--force", name).Run()
+	}()
+	res, err := runCLI(ctx, spec, d.binary(), d.runArgs(name, spec), func() error {
+		return exec.Command(d.binary(), "kill", name).Run()
+	})
+	if err == nil && res.ExitCode == sigkillExit && !res.TimedOut {
+		out, ierr := exec.Command(d.binary(), "inspect", "--format", "{{.State.OOMKilled}}", name).Output()
+		res.OOMKilled = ierr == nil && strings.TrimSpace(string(out)) == "true"
+	}
+	return res, err
+}
+
+// sigkillExit is the exit code docker reports for a process killed by
+// SIGKILL, which is how the kernel stops one over its memory limit.
+const sigkillExit = 128 + 9
+
+// runCLI starts the CLI binary, docker or kubectl, with args, which runs
+// spec's command, and waits for it. Killing the CLI process would leave
+// the command running, so cancellation calls kill instead, which must
+// stop it.
+func runCLI(ctx context.Context, spec Spec, binary string, args []string, kill func() error) (*ExecResult, error) {
+	runCtx := ctx
+	if t := spec.Limits.Timeout(); t > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(runCtx, binary, args...)
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = spec.Stderr
+	// Give the CLI a moment to exit once kill has stopped the command.
+	cmd.Cancel = kill
+	cmd.WaitDelay = 5 * time.Second
+
+	// Stdin is copied by hand rather than through cmd.Stdin: an interactive
+	// reader may never reach EOF, and Wait would block on it until WaitDelay.
+	var stdin io.WriteCloser
+	if spec.Stdin != nil {
+		var err error
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return nil, fmt.Errorf("runner: stdin pipe: %w", err)
+		}
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("runner: %s %s: %w", filepath.Base(binary), args[0], err)
+	}
+	if stdin != nil {
+		go func() {
+			io.Copy(stdin, spec.Stdin)
+			stdin.Close()
+		}()
+	}
+	err := cmd.Wait()
+	res := &ExecResult{Duration: time.Since(start), ExitCode: -1}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		res.TimedOut = true
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		res.ExitCode = 0
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	case res.TimedOut:
+	case ctx.Err() != nil:
+		return res, ctx.Err()
+	default:
+		return res, fmt.Errorf("runner: %s %s: %w", filepath.Base(binary), args[0], err)
+	}
+	return res, nil
+}
+
+func (d *DockerSandbox) runArgs(name string, spec Spec) []string {
+	l := spec.Limits
+	args := []string{
+		"run", "--name", name,
+		"--label", "ai-ide.type=run",
+		"--cpus", strconv.FormatFloat(l.CPUs, 'f', -1, 64),
+		"--memory", fmt.Sprintf("%dm", l.MemoryMB),
+		"--memory-swap", fmt.Sprintf("%dm", l.MemoryMB),
+		"--pids-limit", strconv.FormatInt(l.MaxProcs, 10),
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges:true",
+		"--read-only",
+		"--tmpfs", "/tmp:rw,exec,nosuid,size=" + d.tmpSize(),
+	}
+	if !spec.Network {
+		args = append(args, "--network", "none")
+	} else if d.Network != "" {
+		args = append(args, "--network", d.Network)
+	}
+	if d.Runtime != "" {
+		args = append(args, "--runtime", d.Runtime)
+	}
+	for _, h := range d.Hosts {
+		args = append(args, "--add-host", h)
+	}
+	if spec.Stdin != nil {
+		args = append(args, "--interactive")
+	}
+	if spec.WorkDir != "" {
+		args = append(args, "--workdir", spec.WorkDir)
+	}
+	for _, m := range spec.Mounts {
+		v := m.Source + ":" + m.Target
+		if m.ReadOnly {
+			v += ":ro"
+		}
+		args = append(args, "--volume", v)
+	}
+	for _, e := range spec.Env {
+		args = append(args, "--env", e)
+	}
+	args = append(args, spec.Image)
+	return append(args, spec.Cmd...)
+}
+
+// Ping reports whether the docker daemon answers.
+func (d *DockerSandbox) Ping(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, d.binary(), "version", "--format", "{{.Server.Version}}").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("runner: docker version: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (d *DockerSandbox) binary() string {
+	if d.Binary == "" {
+		return "docker"
+	}
+	return d.Binary
+}
+
+func (d *DockerSandbox) tmpSize() string {
+	if d.TmpSize == "" {
+		return "256m"
+	}
+	return d.TmpSize
+}
+
+func randomID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,186 @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/utf8x"
+)
+
+// EventType names a run lifecycle or output event.
+type EventType string
+
+const (
+	EventQueued   EventType = "queued"
+	EventStarted  EventType = "started"
+	EventCompiled EventType = "compiled"
+	EventStdout   EventType = "stdout"
+	EventStderr   EventType = "stderr"
+	EventExited   EventType = "exited"
+	EventTimedOut EventType = "timed-out"
+)
+
+// Event is a single item in a run's progress stream. Output events carry
+// Data; the terminal exited/timed-out event carries the final Result.
+// Queued events, sent while the run waits for a worker, carry its Position
+// in the queue.
+//
+// Outside OutputRaw, an output event with Rewrite set replaces the last
+// line of its stream that did not end in a newline, as when a carriage
+// return redraws a progress bar; its Data starts with the new line.
+type Event struct {
+	Type     EventType `json:"type"`
+	Phase    Phase     `json:"phase,omitempty"`
+	Data     string    `json:"data,omitempty"`
+	Rewrite  bool      `json:"rewrite,omitempty"`
+	Position int       `json:"position,omitempty"`
+	Result   *Result   `json:"result,omitempty"`
+	// Seq numbers the events streamed on /ws/run, from 1.
+	Seq int64 `json:"seq,omitempty"`
+}
+
+// Emitter receives run events.
+type Emitter func(Event)
+
+// syncEmitter serializes calls to an Emitter, since a sandbox writes stdout
+// and stderr from separate goroutines.
+type syncEmitter struct {
+	mu  sync.Mutex
+	fn  Emitter
+	rec *outputRecorder
+	// output is how the writers render output.
+	output OutputMode
+}
+
+func newSyncEmitter(fn Emitter) *syncEmitter {
+	if fn == nil {
+		fn = func(Event) {}
+	}
+	return &syncEmitter{fn: fn}
+}
+
+func (e *syncEmitter) emit(ev Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.rec != nil && (ev.Type == EventStdout || ev.Type == EventStderr) {
+		e.rec.add(ev)
+	}
+	e.fn(ev)
+}
+
+// record has the output events emitted from now on added to rec.
+func (e *syncEmitter) record(rec *outputRecorder) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rec = rec
+}
+
+func (e *syncEmitter) writer(typ EventType, phase Phase) *eventWriter {
+	return &eventWriter{em: e, typ: typ, phase: phase, term: newTermFilter(e.output)}
+}
+
+// eventWriter turns process output into events. It holds back a trailing
+// partial UTF-8 sequence so multi-byte characters are never split across
+// two events.
+type eventWriter struct {
+	em     *syncEmitter
+	typ    EventType
+	phase  Phase
+	split  utf8x.Splitter
+	budget *outputBudget
+	// term interprets escape sequences and carriage returns, unless nil.
+	term *termFilter
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	var over bool
+	if w.budget != nil {
+		if p, over = w.budget.take(p); len(p) == 0 && !over {
+			return n, nil
+		}
+	}
+	out := w.split.Push(p)
+	if over {
+		// A character cut short by the limit is dropped.
+		w.split.Flush()
+		out = append(out, w.budget.marker()...)
+	}
+	w.send(out)
+	return n, nil
+}
+
+// Flush emits any held-back bytes.
+func (w *eventWriter) Flush() {
+	w.send(w.split.Flush())
+}
+
+func (w *eventWriter) send(out []byte) {
+	if len(out) == 0 {
+		return
+	}
+	if w.term == nil {
+		w.em.emit(Event{Type: w.typ, Phase: w.phase, Data: string(out)})
+		return
+	}
+	if data, rewrite := w.term.write(string(out)); data != "" || rewrite {
+		w.em.emit(Event{Type: w.typ, Phase: w.phase, Data: data, Rewrite: rewrite})
+	}
+}
+
+// outputBudget is the output a process may still write, shared by its
+// stdout and stderr writers. The first write over it ends in the marker
+// and calls stop; later output is discarded.
+type outputBudget struct {
+	mu       sync.Mutex
+	limit    int64
+	left     int64
+	exceeded bool
+	stop     func()
+}
+
+func newOutputBudget(limit int64, stop func()) *outputBudget {
+	return &outputBudget{limit: limit, left: limit, stop: stop}
+}
+
+// take returns the part of p within the budget, and whether p went over
+// it for the first time. Once over, it returns nothing.
+func (b *outputBudget) take(p []byte) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.exceeded {
+		return nil, false
+	}
+	if int64(len(p)) <= b.left {
+		b.left -= int64(len(p))
+		return p, false
+	}
+	p = p[:b.left]
+	b.left, b.exceeded = 0, true
+	b.stop()
+	return p, true
+}
+
+// over reports whether the process wrote more than the budget.
+func (b *outputBudget) over() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
+
+// marker ends the output of a process stopped for writing too much.
+func (b *outputBudget) marker() string {
+	return fmt.Sprintf("\n[output truncated: the limit is %s]\n", formatBytes(b.limit))
+}
+
+// formatBytes formats n as a whole number of MiB or KiB where it is one.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KiB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
@@ -0,0 +1,247 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/toolchain"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Handler exposes the Runner over HTTP.
+type Handler struct {
+	runner *Runner
+	wsOpts *ws.Options
+
+	mu      sync.Mutex
+	streams map[string]*stream // by token
+}
+
+// NewHandler returns a Handler serving r. wsOpts configures the WebSocket
+// upgrade for streaming runs and may be nil.
+func NewHandler(r *Runner, wsOpts *ws.Options) *Handler {
+	return &Handler{runner: r, wsOpts: wsOpts, streams: make(map[string]*stream)}
+}
+
+// Register mounts the execution routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/run", h.run)
+	mux.HandleFunc("GET /ws/run", h.serveStream)
+	mux.HandleFunc("GET /api/run/languages", h.languages)
+	mux.HandleFunc("POST /api/builds", h.build)
+	mux.HandleFunc("GET /api/builds/targets", h.targets)
+	mux.HandleFunc("GET /api/builds/{id}", h.artifact)
+	mux.HandleFunc("GET /api/builds/{id}/download", h.download)
+	mux.HandleFunc("GET /api/profiles/{id}", h.profile)
+	mux.HandleFunc("GET /api/profiles/{id}/download", h.downloadProfile)
+}
+
+// run executes a request and answers with its Result. A client accepting
+// application/x-ndjson receives the run's Events instead, one per line as
+// they happen, as on /ws/run.
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		h.runEvents(w, r, req)
+		return
+	}
+	res, err := h.runner.Run(r.Context(), req)
+	if err != nil {
+		status, msg := runStatus(err)
+		httpx.Error(w, status, msg)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// runEvents streams the Events of a run as JSON lines. The response starts
+// with the first event, so a run refused before it has one fails with an
+// error status; later failures end the stream with an error line, which
+// carries the status the run would have failed with.
+func (h *Handler) runEvents(w http.ResponseWriter, r *http.Request, req Request) {
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	started := false
+	_, err := h.runner.Stream(r.Context(), req, func(ev Event) {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if enc.Encode(ev) == nil {
+			rc.Flush()
+		}
+	})
+	if err == nil || r.Context().Err() != nil {
+		return
+	}
+	status, msg := runStatus(err)
+	if !started {
+		httpx.Error(w, status, msg)
+		return
+	}
+	enc.Encode(errorFrame{Type: "error", Error: msg, Status: status})
+}
+
+// runStatus maps a Run error to an HTTP status and client message, logging
+// unexpected failures.
+func runStatus(err error) (int, string) {
+	switch {
+	case IsRequestError(err):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusTooManyRequests, err.Error()
+	case errors.Is(err, ErrQueueFull):
+		return http.StatusServiceUnavailable, err.Error()
+	case errors.Is(err, ErrStopped):
+		return http.StatusConflict, err.Error()
+	}
+	slog.Error("run failed", "err", err)
+	return http.StatusInternalServerError, "execution failed"
+}
+
+func (h *Handler) build(w http.ResponseWriter, r *http.Request) {
+	var req BuildRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res, err := h.runner.Build(r.Context(), req)
+	switch {
+	case IsRequestError(err) || errors.Is(err, ErrUnsupportedTarget):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, ErrQuotaExceeded):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	case errors.Is(err, ErrQueueFull):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, ErrStopped):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("build failed", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "build failed")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+func (h *Handler) languages(w http.ResponseWriter, r *http.Request) {
+	httpx.JSON(w, http.StatusOK, map[string]any{"languages": h.runner.Languages()})
+}
+
+func (h *Handler) targets(w http.ResponseWriter, r *http.Request) {
+	httpx.JSON(w, http.StatusOK, map[string]any{"targets": Targets})
+}
+
+func (h *Handler) artifact(w http.ResponseWriter, r *http.Request) {
+	a, _, err := h.runner.Artifact(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeArtifactError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, a)
+}
+
+// download serves a built binary as an attachment.
+func (h *Handler) download(w http.ResponseWriter, r *http.Request) {
+	a, p, err := h.runner.Artifact(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeArtifactError(w, err)
+		return
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		writeArtifactError(w, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Sha256", a.SHA256)
+	http.ServeContent(w, r, a.Name, a.CreatedAt, f)
+}
+
+// profile returns a stored profile as a flame graph and a function table.
+// The sample query parameter picks the sample type and top bounds the
+// table, 50 rows by default.
+func (h *Handler) profile(w http.ResponseWriter, r *http.Request) {
+	top := 50
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpx.Error(w, http.StatusBadRequest, "invalid top")
+			return
+		}
+		top = n
+	}
+	view, err := h.runner.ViewProfile(r.Context(), r.PathValue("id"), r.URL.Query().Get("sample"), top)
+	switch {
+	case errors.Is(err, ErrNoProfile):
+		httpx.Error(w, http.StatusNotFound, "profile not found")
+		return
+	case errors.Is(err, ErrInvalidProfile):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		slog.Error("read profile", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not read profile")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, view)
+}
+
+// downloadProfile serves the raw profile for go tool pprof.
+func (h *Handler) downloadProfile(w http.ResponseWriter, r *http.Request) {
+	p, file, err := h.runner.Profile(r.Context(), r.PathValue("id"))
+	if err == nil {
+		var f *os.File
+		if f, err = os.Open(file); err == nil {
+			defer f.Close()
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": p.Name()}))
+			http.ServeContent(w, r, p.Name(), p.CreatedAt, f)
+			return
+		}
+	}
+	if errors.Is(err, ErrNoProfile) || errors.Is(err, os.ErrNotExist) {
+		httpx.Error(w, http.StatusNotFound, "profile not found")
+		return
+	}
+	slog.Error("read profile", "err", err)
+	httpx.Error(w, http.StatusInternalServerError, "could not read profile")
+}
+
+func writeArtifactError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNoArtifact) || errors.Is(err, os.ErrNotExist) {
+		httpx.Error(w, http.StatusNotFound, "artifact not found")
+		return
+	}
+	slog.Error("read artifact", "err", err)
+	httpx.Error(w, http.StatusInternalServerError, "could not read artifact")
+}
+
+// IsRequestError reports whether err was caused by the client's request
+// rather than by the sandbox.
+func IsRequestError(err error) bool {
+	return errors.Is(err, ErrEmptySource) || errors.Is(err, ErrLimitExceeded) || errors.Is(err, ErrInvalidProject) || errors.Is(err, ErrInvalidOptions) ||
+		errors.Is(err, ErrInvalidProfile) || errors.Is(err, ErrUnknownLanguage) || errors.Is(err, ErrInvalidOutputMode) ||
+		errors.Is(err, toolchain.ErrUnknownVersion) || errors.Is(err, workspace.ErrInvalidID) ||
+		errors.Is(err, workspace.ErrNotFound)
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/artifact"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// fakeRoles maps a workspace ID and user ID to a role.
+type fakeRoles map[[2]string]workspace.Role
+
+func (r fakeRoles) Role(workspaceID, userID string) (workspace.Role, error) {
+	return r[[2]string{workspaceID, userID}], nil
+}
+
+type fakeAccounts map[string]bool
+
+func (a fakeAccounts) IsAdmin(u *auth.User) bool { return a[u.ID] }
+
+func TestStoredAccess(t *testing.T) {
+	store, err := artifact.New(artifact.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	access := artifact.Access{
+		Roles:    fakeRoles{{"ws1", "member"}: workspace.RoleViewer},
+		Accounts: fakeAccounts{"admin": true},
+	}
+	r := New(Config{TempDir: t.TempDir(), Artifacts: store, Access: access}, nil)
+	mux := http.NewServeMux()
+	NewHandler(r, nil).Register(mux)
+
+	ctx := auth.WithUser(context.Background(), &auth.User{ID: "owner"})
+	put := func(a artifact.Artifact) string {
+		t.Helper()
+		stored, err := store.Put(ctx, a, 0, strings.NewReader("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored.ID
+	}
+	bin := put(artifact.Artifact{Kind: artifact.KindBinary, Name: "program", Workspace: "ws1"})
+	prof := put(artifact.Artifact{Kind: artifact.KindProfile, Name: "cpu.pprof", Workspace: "ws1", Labels: map[string]string{"profile": "cpu"}})
+
+	routes := []string{
+		"/api/builds/" + bin,
+		"/api/builds/" + bin + "/download",
+		"/api/profiles/" + prof + "/download",
+	}
+	tests := []struct {
+		user string // "" for no sign-in
+		want int
+	}{
+		{"owner", http.StatusOK},
+		{"member", http.StatusOK},
+		{"admin", http.StatusOK},
+		{"", http.StatusOK},
+		{"other", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		for _, route := range routes {
+			req := httptest.NewRequest("GET", route, nil)
+			if tt.user != "" {
+				req = req.WithContext(auth.WithUser(req.Context(), &auth.User{ID: tt.user}))
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("GET %s as %q = %d %s, want %d", route, tt.user, rec.Code, rec.Body, tt.want)
+			}
+		}
+	}
+
+	// The profile summary is not found for others either, rather than
+	// failing to parse what it may not read.
+	req := httptest.NewRequest("GET", "/api/profiles/"+prof, nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &auth.User{ID: "other"}))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /api/profiles/{id} as another user = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/kube"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/tracing"
+)
+
+// KubernetesSandbox runs each Spec in a throwaway pod on a cluster. Mounts
+// are directories of the server that a pod cannot see, so they are
+// copied into empty volumes of the pod before the command runs, and the
+// writable ones are copied back once it has exited. A read-only mount is
+// writable in the pod, but what is written there is not copied back.
+//
+// The pod's image needs sh, sleep and tar. Its processes are not limited
+// in number, as pods only are by the node's pod PID limit.
+type KubernetesSandbox struct {
+	// Kube is the cluster the pods run on.
+	Kube *kube.Client
+	// RuntimeClass names the pods' RuntimeClass, such as one for gVisor.
+	RuntimeClass string
+	// TmpSize is the size of the writable /tmp, such as "256Mi".
+	TmpSize string
+}
+
+// podStartTimeout bounds how long a pod may take to start, pulling its
+// image included.
+const podStartTimeout = 2 * time.Minute
+
+// controlDir is where run pods keep the exit code of the command.
+const controlDir = "/run/ai-ide"
+
+// Exec implements Sandbox.
+func (k *KubernetesSandbox) Exec(ctx context.Context, spec Spec) (*ExecResult, error) {
+	name := "ai-ide-run-" + randomID()
+	defer func() {
+		go k.Kube.Delete(context.Background(), "pod", name, false)
+	}()
+	if err := k.start(ctx, name, spec); err != nil {
+		return nil, err
+	}
+
+	// The command runs under a shell that records its exit code and exits
+	// 0 itself, so that kubectl does not add its own report of the exit
+	// code to the command's stderr. $0 is the working directory, if any.
+	argv := append([]string{"sh", "-c", `cd "${0:-.}" && "$@"; echo $? >` + controlDir + "/exit", spec.WorkDir}, spec.Cmd...)
+	cmd := k.Kube.Exec(name, spec.Stdin != nil, false, argv...)
+	res, err := runCLI(ctx, spec, cmd.Path, cmd.Args[1:], func() error {
+		return k.Kube.Delete(context.Background(), "pod", name, false)
+	})
+	if err != nil || res.TimedOut {
+		return res, err
+	}
+
+	code, oomKills, ok := k.status(ctx, name)
+	res.OOMKilled = oomKills > 0
+	switch {
+	case ok:
+		res.ExitCode = code
+	case res.OOMKilled:
+		// The shell was killed along with the command.
+		res.ExitCode = sigkillExit
+	default:
+		return res, fmt.Errorf("runner: kubectl exec exited with %d before the command finished", res.ExitCode)
+	}
+	for _, m := range spec.Mounts {
+		if m.ReadOnly {
+			continue
+		}
+		if err := k.Kube.CopyOut(ctx, name, m.Target, m.Source); err != nil {
+			return res, fmt.Errorf("runner: copy %s out of pod: %w", m.Target, err)
+		}
+	}
+	return res, nil
+}
+
+// start creates the pod and copies the mounts into it.
+func (k *KubernetesSandbox) start(ctx context.Context, name string, spec Spec) (err error) {
+	ctx, span := tracing.Start(ctx, "sandbox.start")
+	defer func() {
+		span.Fail(err)
+		span.End()
+	}()
+	span.Set("sandbox.pod", name)
+	if err := k.Kube.Create(ctx, k.pod(name, spec)); err != nil {
+		return fmt.Errorf("runner: create pod: %w", err)
+	}
+	if err := k.Kube.WaitReady(ctx, name, podStartTimeout); err != nil {
+		return fmt.Errorf("runner: start pod: %w", err)
+	}
+	for _, m := range spec.Mounts {
+		if err := k.Kube.CopyIn(ctx, name, m.Source, m.Target); err != nil {
+			return fmt.Errorf("runner: copy %s into pod: %w", m.Target, err)
+		}
+	}
+	return nil
+}
+
+// status reads the exit code the command's shell recorded, if it did, and
+// the number of processes the kernel has killed in the pod for exceeding
+// its memory limit, from cgroup v2 or v1.
+func (k *KubernetesSandbox) status(ctx context.Context, pod string) (code, oomKills int, ok bool) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cmd := k.Kube.Exec(pod, false, false, "sh", "-c",
+		"cat "+controlDir+"/exit 2>/dev/null; cat /sys/fs/cgroup/memory.events 2>/dev/null || cat /sys/fs/cgroup/memory/memory.oom_control")
+	out, _ := exec.CommandContext(ctx, cmd.Path, cmd.Args[1:]...).Output()
+	for i, line := range strings.Split(string(out), "\n") {
+		if v, found := strings.CutPrefix(line, "oom_kill "); found {
+			oomKills, _ = strconv.Atoi(strings.TrimSpace(v))
+		} else if n, err := strconv.Atoi(strings.TrimSpace(line)); i == 0 && err == nil {
+			code, ok = n, true
+		}
+	}
+	return code, oomKills, ok
+}
+
+func (k *KubernetesSandbox) pod(name string, spec Spec) *kube.Pod {
+	network := "none"
+	if spec.Network {
+		network = "on"
+	}
+	volumes := []kube.Volume{
+		{Name: "tmp", EmptyDir: &kube.EmptyDirSource{Medium: "Memory", SizeLimit: k.tmpSize()}},
+		{Name: "control", EmptyDir: &kube.EmptyDirSource{Medium: "Memory", SizeLimit: "1Mi"}},
+	}
+	mounts := []kube.VolumeMount{{Name: "tmp", MountPath: "/tmp"}, {Name: "control", MountPath: controlDir}}
+	for i, m := range spec.Mounts {
+		v := "mount-" + strconv.Itoa(i)
+		volumes = append(volumes, kube.Volume{Name: v, EmptyDir: &kube.EmptyDirSource{}})
+		mounts = append(mounts, kube.VolumeMount{Name: v, MountPath: m.Target})
+	}
+	var env []kube.EnvVar
+	for _, e := range spec.Env {
+		if key, value, ok := strings.Cut(e, "="); ok {
+			env = append(env, kube.EnvVar{Name: key, Value: value})
+		}
+	}
+	resources := kube.Resources(spec.Limits.CPUs, spec.Limits.MemoryMB)
+	return kube.NewPod(kube.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{kube.LabelType: "run", kube.LabelNetwork: network},
+	}, kube.PodSpec{
+		Containers: []kube.Container{{
+			Name:         "run",
+			Image:        spec.Image,
+			Command:      []string{"sleep", "infinity"},
+			Env:          env,
+			Resources:    kube.ResourceRequirements{Requests: resources, Limits: resources},
+			VolumeMounts: mounts,
+			SecurityContext: &kube.SecurityContext{
+				AllowPrivilegeEscalation: kube.Bool(false),
+				ReadOnlyRootFilesystem:   kube.Bool(true),
+				Capabilities:             &kube.Capabilities{Drop: []string{"ALL"}},
+			},
+		}},
+		Volumes:                       volumes,
+		RestartPolicy:                 "Never",
+		RuntimeClassName:              k.RuntimeClass,
+		AutomountServiceAccountToken:  kube.Bool(false),
+		EnableServiceLinks:            kube.Bool(false),
+		TerminationGracePeriodSeconds: kube.Int64(0),
+	})
+}
+
+func (k *KubernetesSandbox) tmpSize() string {
+	if k.TmpSize == "" {
+		return "256Mi"
+	}
+	return k.TmpSize
+}
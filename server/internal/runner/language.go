@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gopanic"
+)
+
+// ErrUnknownLanguage is returned for requests naming a language the
+// Runner does not have.
+var ErrUnknownLanguage = errors.New("runner: unknown language")
+
+// Language builds and runs the programs of one programming language. Go
+// is built in; Config.Languages adds the others.
+type Language interface {
+	// Name is the Request.Language that selects the language, such as
+	// "python".
+	Name() string
+	// SourceFile is the file a request's bare Source is saved as.
+	SourceFile() string
+	// Prepare checks a request whose file set is files and returns how
+	// to build and run it.
+	Prepare(req Request, files []File) (*Plan, error)
+	// Diagnostics extracts the errors a failed build phase printed to
+	// stderr, with paths relative to the project root.
+	Diagnostics(stderr string) []diag.Diagnostic
+}
+
+// panicParser is implemented by languages whose crashed programs print a
+// report worth parsing onto the result. Only Go does.
+type panicParser interface {
+	// Panic extracts the report a failed run phase printed to stderr,
+	// or returns nil if there is none.
+	Panic(stderr string) *gopanic.Panic
+}
+
+// Plan is how a Language builds and runs one submission. Both phases see
+// the project at /workspace and the build output at /out, and only the
+// build phase can write to them.
+type Plan struct {
+	// Image is the sandbox image of both phases.
+	Image string
+	// Version is the Go version of a toolchain image, reported as
+	// Result.GoVersion.
+	Version string
+	// Files are written to /workspace: the request's files, plus any the
+	// language adds, such as a default go.mod.
+	Files []File
+	// Build and BuildEnv are the command and environment of the build
+	// phase, which compiles or checks the sources and installs
+	// dependencies.
+	Build    []string
+	BuildEnv []string
+	// Network gives the build phase networking, to download
+	// dependencies; the run phase stays offline regardless.
+	Network bool
+	// Run and RunEnv are the command and environment of the run phase.
+	Run    []string
+	RunEnv []string
+	// WorkDir is the working directory of both phases.
+	WorkDir string
+	// Artifact, when set, names the one file in /out the build produces,
+	// which is cached so an unchanged program is not built again.
+	Artifact string
+}
+
+// language returns the language a request's Language names, Go by default.
+func (r *Runner) language(name string) (Language, error) {
+	if name == "" {
+		name = "go"
+	}
+	l, ok := r.languages[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownLanguage, name)
+	}
+	return l, nil
+}
+
+// LanguageInfo describes a language a request may select.
+type LanguageInfo struct {
+	Name       string `json:"name"`
+	SourceFile string `json:"sourceFile"`
+}
+
+// Languages returns the languages of the Runner, sorted by name.
+func (r *Runner) Languages() []LanguageInfo {
+	out := make([]LanguageInfo, 0, len(r.languages))
+	for _, l := range r.languages {
+		out = append(out, LanguageInfo{Name: l.Name(), SourceFile: l.SourceFile()})
+	}
+	slices.SortFunc(out, func(a, b LanguageInfo) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// goLanguage builds Go programs with the toolchain of the request's Go
+// version. Only Go programs take build options and profiles.
+type goLanguage struct {
+	r *Runner
+}
+
+func (goLanguage) Name() string       { return "go" }
+func (goLanguage) SourceFile() string { return "main.go" }
+
+func (goLanguage) Diagnostics(stderr string) []diag.Diagnostic {
+	return parseBuildErrors(stderr)
+}
+
+func (goLanguage) Panic(stderr string) *gopanic.Panic {
+	return parsePanic(stderr)
+}
+
+func (g goLanguage) Prepare(req Request, files []File) (*Plan, error) {
+	module, err := req.module(files)
+	if err != nil {
+		return nil, err
+	}
+	mainPkg, err := req.mainPackage()
+	if err != nil {
+		return nil, err
+	}
+	tc, err := g.r.toolchain(req.Workspace, req.GoVersion)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := req.Options.args()
+	if err != nil {
+		return nil, err
+	}
+	if req.Profile != "" {
+		if files, err = instrument(files, path.Join(module, mainPkg), req.Profile); err != nil {
+			return nil, err
+		}
+	}
+	files, pr := goProject(files)
+	return &Plan{
+		Image:    tc.Image,
+		Version:  tc.Version,
+		Files:    files,
+		Build:    append([]string{"sh", "-c", buildScript, "sh", mainPkg}, flags...),
+		BuildEnv: req.Options.env(g.r.buildEnv(pr.workspace, "CGO_ENABLED=0")),
+		// Modules with requirements have to be downloaded during the build.
+		Network:  pr.needsModules,
+		Run:      []string{"/out/prog"},
+		WorkDir:  path.Join("/workspace", module),
+		Artifact: "prog",
+	}, nil
+}
+
+// checkGoOnly rejects the request fields that only apply to Go programs.
+func checkGoOnly(req Request, lang string) error {
+	var field string
+	switch {
+	case req.Module != "":
+		field = "module"
+	case req.GoVersion != "":
+		field = "goVersion"
+	case req.Options.set():
+		field = "options"
+	case req.Profile != "":
+		field = "profile"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %s does not apply to %s programs", ErrInvalidProject, field, lang)
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Limits bounds the resources a single sandboxed run may consume.
+type Limits struct {
+	// CPUs is the number of CPU cores available to the run (fractions allowed).
+	CPUs float64 `json:"cpus,omitempty"`
+	// MemoryMB is the hard memory limit in megabytes, swap included.
+	MemoryMB int64 `json:"memoryMb,omitempty"`
+	// TimeoutMS is the wall-clock budget for the run phase in milliseconds.
+	TimeoutMS int64 `json:"timeoutMs,omitempty"`
+	// MaxProcs is the maximum number of processes and threads in the sandbox.
+	MaxProcs int64 `json:"maxProcs,omitempty"`
+	// OutputBytes caps stdout and stderr together. A program writing more
+	// is stopped, and its output ends in a truncation marker. Zero means
+	// no cap, as for builds.
+	OutputBytes int64 `json:"outputBytes,omitempty"`
+}
+
+// DefaultLimits applies when a request does not specify its own limits.
+var DefaultLimits = Limits{
+	CPUs:        1,
+	MemoryMB:    512,
+	TimeoutMS:   10_000,
+	MaxProcs:    64,
+	OutputBytes: 1 << 20,
+}
+
+// MaxLimits is the ceiling a request may raise its limits to.
+var MaxLimits = Limits{
+	CPUs:        2,
+	MemoryMB:    2048,
+	TimeoutMS:   60_000,
+	MaxProcs:    256,
+	OutputBytes: 8 << 20,
+}
+
+// ErrLimitExceeded is returned when requested limits exceed the configured maximum.
+var ErrLimitExceeded = errors.New("runner: requested limits exceed maximum")
+
+// Timeout returns the wall-clock budget as a time.Duration.
+func (l Limits) Timeout() time.Duration {
+	return time.Duration(l.TimeoutMS) * time.Millisecond
+}
+
+// Resolve fills zero fields of l from def and checks the result against max.
+func (l Limits) Resolve(def, max Limits) (Limits, error) {
+	if l.CPUs <= 0 {
+		l.CPUs = def.CPUs
+	}
+	if l.MemoryMB <= 0 {
+		l.MemoryMB = def.MemoryMB
+	}
+	if l.TimeoutMS <= 0 {
+		l.TimeoutMS = def.TimeoutMS
+	}
+	if l.MaxProcs <= 0 {
+		l.MaxProcs = def.MaxProcs
+	}
+	if l.OutputBytes <= 0 {
+		l.OutputBytes = def.OutputBytes
+	}
+	switch {
+	case max.CPUs > 0 && l.CPUs > max.CPUs:
+		return l, fmt.Errorf("%w: cpus %.2f > %.2f", ErrLimitExceeded, l.CPUs, max.CPUs)
+	case max.MemoryMB > 0 && l.MemoryMB > max.MemoryMB:
+		return l, fmt.Errorf("%w: memoryMb %d > %d", ErrLimitExceeded, l.MemoryMB, max.MemoryMB)
+	case max.TimeoutMS > 0 && l.TimeoutMS > max.TimeoutMS:
+		return l, fmt.Errorf("%w: timeoutMs %d > %d", ErrLimitExceeded, l.TimeoutMS, max.TimeoutMS)
+	case max.MaxProcs > 0 && l.MaxProcs > max.MaxProcs:
+		return l, fmt.Errorf("%w: maxProcs %d > %d", ErrLimitExceeded, l.MaxProcs, max.MaxProcs)
+	case max.OutputBytes > 0 && l.OutputBytes > max.OutputBytes:
+		return l, fmt.Errorf("%w: outputBytes %d > %d", ErrLimitExceeded, l.OutputBytes, max.OutputBytes)
+	}
+	return l, nil
+}
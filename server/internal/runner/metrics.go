@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/metrics"
+)
+
+// phaseBuckets are the upper bounds, in seconds, of the phase duration
+// histogram; phases are limited to a minute by default.
+var phaseBuckets = []float64{.1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+
+// runMetrics are the Runner's metrics, nil when it has no registry.
+type runMetrics struct {
+	phases   *metrics.Histogram
+	outcomes *metrics.Counter
+}
+
+func newRunMetrics(reg *metrics.Registry) runMetrics {
+	return runMetrics{
+		phases: reg.Histogram("webide_run_phase_duration_seconds",
+			"Time builds and runs of programs took in the sandbox, by phase.", phaseBuckets, "phase"),
+		outcomes: reg.Counter("webide_run_phases_total",
+			"Builds and runs of programs, by phase and outcome: ok, failed (non-zero exit), timeout, memory, output or error.", "phase", "outcome"),
+	}
+}
+
+// observe records the outcome of a phase.
+func (m runMetrics) observe(phase Phase, res *ExecResult, err error) {
+	outcome := "ok"
+	switch {
+	case err != nil || res == nil:
+		m.outcomes.Inc(string(phase), "error")
+		return
+	case res.TimedOut:
+		outcome = "timeout"
+	case res.OOMKilled:
+		outcome = "memory"
+	case res.OutputExceeded:
+		outcome = "output"
+	case res.ExitCode != 0:
+		outcome = "failed"
+	}
+	m.phases.Observe(res.Duration.Seconds(), string(phase))
+	m.outcomes.Inc(string(phase), outcome)
+}
+
+// countedSandbox counts the sandboxes executing at once.
+type countedSandbox struct {
+	Sandbox
+	active *metrics.Gauge
+}
+
+func (s countedSandbox) Exec(ctx context.Context, spec Spec) (*ExecResult, error) {
+	s.active.Inc()
+	defer s.active.Dec()
+	return s.Sandbox.Exec(ctx, spec)
+}
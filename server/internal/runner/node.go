@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+)
+
+// nodeBuildScript installs the dependencies of package.json when $1 is
+// set, without running package scripts, then checks the syntax of every
+// script outside node_modules, reporting all files that fail.
+const nodeBuildScript = `if [ -n "$1" ]; then npm install --no-audit --no-fund --ignore-scripts --loglevel=error || exit 1; fi
+find . -path ./node_modules -prune -o -type f \( -name '*.js' -o -name '*.mjs' -o -name '*.cjs' \) -exec sh -c 'for f; do node --check "$f" || s=1; done; exit ${s:-0}' sh {} +`
+
+// node runs JavaScript programs with the Node.js of its image.
+type node struct {
+	image string
+}
+
+// Node returns the Language running JavaScript with Node.js in image,
+// which defaults to node:20-alpine. Its requests select it as
+// "javascript". A project runs the file Request.Main names, or the main of
+// its package.json, or index.js. The dependencies package.json declares
+// are installed with npm in the build phase, which also checks the syntax
+// of every .js, .mjs and .cjs file.
+func Node(image string) Language {
+	if image == "" {
+		image = "node:20-alpine"
+	}
+	return &node{image: image}
+}
+
+func (*node) Name() string       { return "javascript" }
+func (*node) SourceFile() string { return "index.js" }
+
+// packageJSON is the part of a package.json the runner reads.
+type packageJSON struct {
+	Main            string            `json:"main"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+func (n *node) Prepare(req Request, files []File) (*Plan, error) {
+	if err := checkGoOnly(req, "javascript"); err != nil {
+		return nil, err
+	}
+	var pkg packageJSON
+	if f, ok := findFile(files, "package.json"); ok {
+		if err := json.Unmarshal([]byte(f.Content), &pkg); err != nil {
+			return nil, fmt.Errorf("%w: package.json: %v", ErrInvalidProject, err)
+		}
+	}
+	def := "index.js"
+	if pkg.Main != "" {
+		def = pkg.Main
+	}
+	main, err := entryFile(req.Main, def, files)
+	if err != nil {
+		return nil, err
+	}
+	var deps string
+	if len(pkg.Dependencies)+len(pkg.DevDependencies) > 0 {
+		deps = "1"
+	}
+	return &Plan{
+		Image: n.image,
+		Files: files,
+		Build: []string{"sh", "-c", nodeBuildScript, "sh", deps},
+		BuildEnv: []string{
+			"HOME=/tmp", "npm_config_cache=/tmp/npm-cache", "npm_config_update_notifier=false",
+		},
+		Network: deps != "",
+		Run:     []string{"node", main},
+		WorkDir: "/workspace",
+	}, nil
+}
+
+func (*node) Diagnostics(stderr string) []diag.Diagnostic {
+	return parseNodeErrors(stderr)
+}
+
+var (
+	nodeLocationPattern = regexp.MustCompile(`^(\S.*\.[cm]?js):(\d+)$`)
+	nodeErrorPattern    = regexp.MustCompile(`^[A-Z][A-Za-z]*Error: `)
+)
+
+// parseNodeErrors extracts the syntax errors node --check prints: a
+// "file:line" line, the offending source line, a line of carets under the
+// offending text, which is blank at the end of the input, and after a
+// blank line the error itself. The end of a diagnostic's range is the
+// column after the carets.
+func parseNodeErrors(out string) []diag.Diagnostic {
+	lines := strings.Split(out, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	var ds []diag.Diagnostic
+	for i := 0; i < len(lines); i++ {
+		m := nodeLocationPattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		start := diag.Position{Line: atoi(m[2]), Column: 1}
+		end := start
+		// The source line follows the location; the carets and the error
+		// come within the next three lines.
+		for j := i + 2; j < len(lines) && j <= i+4; j++ {
+			l := lines[j]
+			if c := strings.IndexByte(l, '^'); c >= 0 && strings.Trim(l, " ^") == "" {
+				start.Column = c + 1
+				end.Column = c + 1 + strings.Count(l, "^")
+				continue
+			}
+			if nodeErrorPattern.MatchString(l) {
+				ds = append(ds, diag.Diagnostic{
+					File:     strings.TrimPrefix(strings.TrimPrefix(m[1], "/workspace/"), "./"),
+					Range:    diag.Range{Start: start, End: end},
+					Severity: diag.SeverityError,
+					Source:   "node",
+					Message:  l,
+				})
+				i = j
+				break
+			}
+		}
+	}
+	return ds
+}
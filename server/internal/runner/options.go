@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// BuildOptions are the go build flags a request may set. Every value is
+// checked against an allowlist, since the flags reach the build command.
+type BuildOptions struct {
+	// Race builds with the race detector. It needs cgo and a C compiler in
+	// the toolchain image, and makes programs several times slower and
+	// larger; a detected race exits with status 66.
+	Race bool `json:"race,omitempty"`
+	// Trimpath removes file system paths from the binary.
+	Trimpath bool `json:"trimpath,omitempty"`
+	// Tags are build tags.
+	Tags []string `json:"tags,omitempty"`
+	// GCFlags are compiler flags for the packages being built, such as
+	// "-N", "-l" or "-m".
+	GCFlags []string `json:"gcflags,omitempty"`
+	// LDFlags are linker flags: "-s", "-w", or "-X importpath.name=value".
+	LDFlags []string `json:"ldflags,omitempty"`
+}
+
+// ErrInvalidOptions is returned for build options outside the allowlist.
+var ErrInvalidOptions = errors.New("runner: invalid build options")
+
+// maxOptionValues bounds each list in BuildOptions.
+const maxOptionValues = 16
+
+// allowedGCFlags are the compiler flags that only affect optimization and
+// diagnostics output.
+var allowedGCFlags = map[string]bool{
+	"-N": true, "-l": true, "-B": true, "-m": true, "-m=1": true, "-m=2": true,
+	"-d=ssa/check_bce": true, "-d=ssa/check_bce/debug=1": true,
+}
+
+var (
+	tagPattern      = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+	ldSymbolPattern = regexp.MustCompile(`^-X [A-Za-z0-9_./-]*[A-Za-z0-9_]\.[A-Za-z_][A-Za-z0-9_]*=[A-Za-z0-9_.:+@/-]*$`)
+)
+
+// args validates o and returns the corresponding go build flags.
+func (o BuildOptions) args() ([]string, error) {
+	var args []string
+	if o.Race {
+		args = append(args, "-race")
+	}
+	if o.Trimpath {
+		args = append(args, "-trimpath")
+	}
+	if len(o.Tags) > maxOptionValues || len(o.GCFlags) > maxOptionValues || len(o.LDFlags) > maxOptionValues {
+		return nil, fmt.Errorf("%w: at most %d values per option", ErrInvalidOptions, maxOptionValues)
+	}
+	if len(o.Tags) > 0 {
+		for _, t := range o.Tags {
+			if !tagPattern.MatchString(t) {
+				return nil, fmt.Errorf("%w: build tag %q", ErrInvalidOptions, t)
+			}
+		}
+		args = append(args, "-tags="+strings.Join(o.Tags, ","))
+	}
+	if len(o.GCFlags) > 0 {
+		for _, f := range o.GCFlags {
+			if !allowedGCFlags[f] {
+				return nil, fmt.Errorf("%w: gcflag %q", ErrInvalidOptions, f)
+			}
+		}
+		args = append(args, "-gcflags="+strings.Join(o.GCFlags, " "))
+	}
+	if len(o.LDFlags) > 0 {
+		for _, f := range o.LDFlags {
+			if f != "-s" && f != "-w" && !ldSymbolPattern.MatchString(f) {
+				return nil, fmt.Errorf("%w: ldflag %q", ErrInvalidOptions, f)
+			}
+		}
+		args = append(args, "-ldflags="+strings.Join(o.LDFlags, " "))
+	}
+	return args, nil
+}
+
+// set reports whether any option is set.
+func (o BuildOptions) set() bool {
+	return o.Race || o.Trimpath || len(o.Tags) > 0 || len(o.GCFlags) > 0 || len(o.LDFlags) > 0
+}
+
+// env applies the environment the options need to env.
+func (o BuildOptions) env(env []string) []string {
+	if o.Race {
+		return setEnv(env, "CGO_ENABLED", "1")
+	}
+	return env
+}
+
+// setEnv returns env with key set to value, replacing an earlier setting.
+func setEnv(env []string, key, value string) []string {
+	out := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, key+"=") {
+			out = append(out, kv)
+		}
+	}
+	return append(out, key+"="+value)
+}
@@ -0,0 +1,567 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/tracing"
+)
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// Dir holds the host directories mounted into warm containers. Files
+	// are moved rather than copied in and out of them, so Dir must be on
+	// the same file system as the mounted directories, such as the
+	// runner's TempDir; defaults to a directory under the OS temp dir.
+	Dir string
+	// ModuleCache, when set, is a host directory mounted as the module
+	// cache of every warm container, so builds reuse downloaded modules.
+	ModuleCache string
+	// MinIdle is how many containers are kept warm for each kind of
+	// sandbox in recent use; defaults to 1.
+	MinIdle int
+	// MaxIdle caps the warm containers of one kind; defaults to 8.
+	MaxIdle int
+	// Max caps all containers of the pool, warm or in use; defaults to 16.
+	// Runs beyond it get a cold container.
+	Max int
+	// MaxUses is how many runs a container serves before it is replaced;
+	// defaults to 100.
+	MaxUses int
+	// Window is how far back demand is measured; defaults to five minutes.
+	Window time.Duration
+	// IdleTimeout is how long a kind of sandbox keeps warm containers
+	// after its last run; defaults to 15 minutes.
+	IdleTimeout time.Duration
+}
+
+const (
+	// moduleCacheDir is where the toolchain images keep the module cache.
+	moduleCacheDir = "/go/pkg/mod"
+	// poolPrefix names the pool's containers and their directories.
+	poolPrefix = "ai-ide-pool-"
+	// scaleEvery is how often the pool is resized.
+	scaleEvery = 5 * time.Second
+	// startTimeout bounds starting a container, image pull included.
+	startTimeout = 2 * time.Minute
+	// scrubTimeout bounds cleaning a container between runs.
+	scrubTimeout = 10 * time.Second
+)
+
+// scrubScript fails when a process other than the container's init and
+// the script itself is left, running or not, and otherwise empties /tmp.
+// Containers that fail it are thrown away rather than reused.
+const scrubScript = `for p in /proc/[0-9]*; do p=${p#/proc/}; [ "$p" = 1 ] || [ "$p" = $$ ] || exit 1; done; find /tmp -mindepth 1 -delete`
+
+// Pool is a Sandbox that runs specs in pre-started containers instead of
+// starting one per run. Containers come in kinds by image, network mode
+// and mount targets; each one mounts its own host directories at the
+// targets, and a run's mounted files are moved into them for the run and
+// back out after it. Limits are applied with docker update and the command
+// runs with docker exec. Between runs a container is checked for leftover
+// processes and its /tmp emptied, and containers that time out, fail or
+// keep processes around are thrown away.
+//
+// The pool of each kind follows demand: it keeps as many idle containers
+// as the most runs of that kind at once over the last Window, less those
+// running, bounded by MinIdle and MaxIdle. A kind is created on its first
+// run, which like any run finding no idle container gets a cold one.
+type Pool struct {
+	cfg    PoolConfig
+	cold   *DockerSandbox
+	now    func() time.Time
+	ctx    context.Context
+	cancel context.CancelFunc
+	wake   chan struct{}
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	kinds  map[string]*kind
+	total  int
+	closed bool
+}
+
+// kind is the pool of containers for one image, network mode and mount
+// layout.
+type kind struct {
+	image   string
+	network bool
+	// targets are the mount points, with the mount's ReadOnly.
+	targets []Mount
+
+	idle     []*warm
+	starting int
+	// active counts runs in progress, warm or cold.
+	active int
+	// peak and prevPeak are the most active runs in this window and the
+	// last one.
+	peak, prevPeak int
+	windowStart    time.Time
+	lastUsed       time.Time
+}
+
+// warm is a pool container.
+type warm struct {
+	name string
+	// dir holds one directory per mount, named by its index.
+	dir    string
+	uses   int
+	limits Limits
+	// oomKills is the container's count of processes killed for memory
+	// as of its last run.
+	oomKills int
+}
+
+// NewPool returns a Pool starting its containers like cold, which also
+// runs what the pool cannot, filling unset Config fields with defaults.
+// Containers a previous server left behind are removed. Close the Pool to
+// remove its containers.
+func NewPool(cfg PoolConfig, cold *DockerSandbox) (*Pool, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-pool")
+	}
+	if cfg.MinIdle <= 0 {
+		cfg.MinIdle = 1
+	}
+	if cfg.MaxIdle <= 0 {
+		cfg.MaxIdle = 8
+	}
+	if cfg.Max <= 0 {
+		cfg.Max = 16
+	}
+	if cfg.MaxUses <= 0 {
+		cfg.MaxUses = 100
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 5 * time.Minute
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 15 * time.Minute
+	}
+	// Docker needs absolute bind-mount sources.
+	if abs, err := filepath.Abs(cfg.Dir); err == nil {
+		cfg.Dir = abs
+	}
+	if cfg.ModuleCache != "" {
+		if abs, err := filepath.Abs(cfg.ModuleCache); err == nil {
+			cfg.ModuleCache = abs
+		}
+		if err := os.MkdirAll(cfg.ModuleCache, 0o755); err != nil {
+			return nil, fmt.Errorf("runner: create module cache: %w", err)
+		}
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("runner: create pool dir: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		cfg:    cfg,
+		cold:   cold,
+		now:    time.Now,
+		ctx:    ctx,
+		cancel: cancel,
+		wake:   make(chan struct{}, 1),
+		kinds:  make(map[string]*kind),
+	}
+	p.removeStale()
+	p.wg.Add(1)
+	go p.scale()
+	return p, nil
+}
+
+// Exec implements Sandbox.
+func (p *Pool) Exec(ctx context.Context, spec Spec) (*ExecResult, error) {
+	k, w := p.checkout(spec)
+	if k == nil {
+		return p.cold.Exec(ctx, spec)
+	}
+	defer p.release(k)
+	if w == nil {
+		return p.cold.Exec(ctx, spec)
+	}
+	_, span := tracing.Start(ctx, "sandbox.prepare")
+	span.Set("sandbox.container", w.name)
+	err := p.prepare(ctx, w, spec)
+	span.Fail(err)
+	span.End()
+	if err != nil {
+		slog.Warn("warm sandbox unusable; starting a cold one", "container", w.name, "err", err)
+		p.discard(w)
+		return p.cold.Exec(ctx, spec)
+	}
+	res, err := runCLI(ctx, spec, p.cold.binary(), p.execArgs(w, spec), func() error {
+		return exec.Command(p.cold.binary(), "rm", "--force", w.name).Run()
+	})
+	if err == nil && res.ExitCode == sigkillExit && !res.TimedOut {
+		// The container outlives the process, so its count of OOM kills
+		// tells whether this one was.
+		if n, ok := p.oomKills(ctx, w); ok {
+			res.OOMKilled = n > w.oomKills
+			w.oomKills = n
+		}
+	}
+	moveErr := p.moveOut(w, spec)
+	if moveErr != nil && err == nil {
+		err = fmt.Errorf("runner: move files out of sandbox: %w", moveErr)
+	}
+	if err != nil || res.TimedOut || moveErr != nil {
+		p.discard(w)
+	} else {
+		p.checkin(k, w)
+	}
+	return res, err
+}
+
+// Close removes the pool's containers and waits for those starting.
+// Runs in progress finish in their containers, which are then removed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	for _, k := range p.kinds {
+		for _, w := range k.idle {
+			p.discardLocked(w)
+		}
+		k.idle = nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
+
+// kindKey identifies the kind of container spec needs.
+func kindKey(spec Spec) string {
+	var b strings.Builder
+	b.WriteString(spec.Image)
+	if spec.Network {
+		b.WriteString("\x00net")
+	}
+	for _, m := range spec.Mounts {
+		b.WriteString("\x00" + m.Target)
+		if m.ReadOnly {
+			b.WriteString(":ro")
+		}
+	}
+	return b.String()
+}
+
+// checkout counts a run of spec's kind and takes an idle container for
+// it, if there is one. It returns a nil kind once the pool is closed.
+func (p *Pool) checkout(spec Spec) (*kind, *warm) {
+	key := kindKey(spec)
+	now := p.now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, nil
+	}
+	k, ok := p.kinds[key]
+	if !ok {
+		k = &kind{image: spec.Image, network: spec.Network, windowStart: now}
+		for _, m := range spec.Mounts {
+			k.targets = append(k.targets, Mount{Target: m.Target, ReadOnly: m.ReadOnly})
+		}
+		p.kinds[key] = k
+	}
+	k.active++
+	k.peak = max(k.peak, k.active)
+	k.lastUsed = now
+	if n := len(k.idle); n > 0 {
+		w := k.idle[n-1]
+		k.idle = k.idle[:n-1]
+		return k, w
+	}
+	// Start warming up now rather than at the next tick.
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return k, nil
+}
+
+// release ends a run of kind k.
+func (p *Pool) release(k *kind) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.active--
+}
+
+// prepare readies w for spec: it applies spec's limits and moves the
+// files of spec's mounts into w's directories.
+func (p *Pool) prepare(ctx context.Context, w *warm, spec Spec) error {
+	l := spec.Limits
+	l.TimeoutMS = 0
+	if l != w.limits {
+		out, err := exec.CommandContext(ctx, p.cold.binary(), "update",
+			"--cpus", strconv.FormatFloat(l.CPUs, 'f', -1, 64),
+			"--memory", fmt.Sprintf("%dm", l.MemoryMB),
+			"--memory-swap", fmt.Sprintf("%dm", l.MemoryMB),
+			"--pids-limit", strconv.FormatInt(l.MaxProcs, 10),
+			w.name).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("docker update: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+		w.limits = l
+	}
+	for i, m := range spec.Mounts {
+		if err := moveEntries(m.Source, slotDir(w, i)); err != nil {
+			// Putting back what was moved cannot fail where moving it
+			// in did not, short of the disk going away.
+			for j := range i + 1 {
+				moveEntries(slotDir(w, j), spec.Mounts[j].Source)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// moveOut moves the files in w's directories back to spec's mounts.
+func (p *Pool) moveOut(w *warm, spec Spec) error {
+	var errs []error
+	for i, m := range spec.Mounts {
+		errs = append(errs, moveEntries(slotDir(w, i), m.Source))
+	}
+	return errors.Join(errs...)
+}
+
+// oomKills reads the number of processes the kernel has killed in w for
+// exceeding its memory limit, from cgroup v2 or v1.
+func (p *Pool) oomKills(ctx context.Context, w *warm) (int, bool) {
+	ctx, cancel := context.WithTimeout(ctx, scrubTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, p.cold.binary(), "exec", w.name, "sh", "-c",
+		"cat /sys/fs/cgroup/memory.events 2>/dev/null || cat /sys/fs/cgroup/memory/memory.oom_control").Output()
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if v, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+func (p *Pool) execArgs(w *warm, spec Spec) []string {
+	args := []string{"exec"}
+	if spec.Stdin != nil {
+		args = append(args, "--interactive")
+	}
+	if spec.WorkDir != "" {
+		args = append(args, "--workdir", spec.WorkDir)
+	}
+	for _, e := range spec.Env {
+		args = append(args, "--env", e)
+	}
+	args = append(args, w.name)
+	return append(args, spec.Cmd...)
+}
+
+// checkin scrubs w in the background and returns it to k's idle
+// containers, unless it has served MaxUses runs or fails the scrub.
+func (p *Pool) checkin(k *kind, w *warm) {
+	w.uses++
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || w.uses >= p.cfg.MaxUses {
+		p.discardLocked(w)
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ctx, cancel := context.WithTimeout(p.ctx, scrubTimeout)
+		defer cancel()
+		err := exec.CommandContext(ctx, p.cold.binary(), "exec", w.name, "sh", "-c", scrubScript).Run()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err != nil || p.closed {
+			p.discardLocked(w)
+			return
+		}
+		k.idle = append(k.idle, w)
+	}()
+}
+
+// discard removes w in the background.
+func (p *Pool) discard(w *warm) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.discardLocked(w)
+}
+
+// discardLocked is discard with p.mu held. Once the pool is closed,
+// Close no longer waits for the removal.
+func (p *Pool) discardLocked(w *warm) {
+	p.total--
+	if p.closed {
+		go p.destroy(w.name)
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.destroy(w.name)
+	}()
+}
+
+// destroy removes a container and its directories.
+func (p *Pool) destroy(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrubTimeout)
+	defer cancel()
+	exec.CommandContext(ctx, p.cold.binary(), "rm", "--force", name).Run()
+	os.RemoveAll(filepath.Join(p.cfg.Dir, name))
+}
+
+// removeStale removes the containers and directories of pools that were
+// not closed, such as those of a server that crashed.
+func (p *Pool) removeStale() {
+	out, err := exec.Command(p.cold.binary(), "ps", "--all", "--quiet", "--filter", "label=ai-ide.pool").Output()
+	if err != nil {
+		slog.Warn("list stale warm sandboxes", "err", err)
+	} else if ids := strings.Fields(string(out)); len(ids) > 0 {
+		exec.Command(p.cold.binary(), append([]string{"rm", "--force"}, ids...)...).Run()
+	}
+	entries, _ := os.ReadDir(p.cfg.Dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), poolPrefix) {
+			os.RemoveAll(filepath.Join(p.cfg.Dir, e.Name()))
+		}
+	}
+}
+
+// scale resizes the pool every scaleEvery, and when a run finds no idle
+// container, until the pool is closed.
+func (p *Pool) scale() {
+	defer p.wg.Done()
+	t := time.NewTicker(scaleEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-t.C:
+		case <-p.wake:
+		}
+		p.resize()
+	}
+}
+
+// resize starts and removes containers so each kind has the idle
+// containers its demand calls for.
+func (p *Pool) resize() {
+	now := p.now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	for key, k := range p.kinds {
+		if now.Sub(k.windowStart) >= p.cfg.Window {
+			k.prevPeak, k.peak, k.windowStart = k.peak, k.active, now
+		}
+		target := 0
+		if now.Sub(k.lastUsed) < p.cfg.IdleTimeout {
+			target = max(k.peak, k.prevPeak) - k.active
+			target = min(max(target, p.cfg.MinIdle), p.cfg.MaxIdle)
+		}
+		for len(k.idle) > target {
+			p.discardLocked(k.idle[0])
+			k.idle = k.idle[1:]
+		}
+		for len(k.idle)+k.starting < target && p.total < p.cfg.Max {
+			k.starting++
+			p.total++
+			p.wg.Add(1)
+			go p.start(k)
+		}
+		if target == 0 && k.active == 0 && k.starting == 0 && len(k.idle) == 0 {
+			delete(p.kinds, key)
+		}
+	}
+}
+
+// start starts a container of kind k and adds it to k's idle containers.
+func (p *Pool) start(k *kind) {
+	defer p.wg.Done()
+	w, err := p.create(k)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.starting--
+	switch {
+	case err != nil:
+		p.total--
+		if p.ctx.Err() == nil {
+			slog.Error("start warm sandbox", "image", k.image, "err", err)
+		}
+	case p.closed:
+		p.discardLocked(w)
+	default:
+		k.idle = append(k.idle, w)
+	}
+}
+
+// create starts an idle container of kind k, with the default limits
+// until its first run.
+func (p *Pool) create(k *kind) (*warm, error) {
+	w := &warm{name: poolPrefix + randomID(), limits: DefaultLimits}
+	w.limits.TimeoutMS = 0
+	w.dir = filepath.Join(p.cfg.Dir, w.name)
+	base := Spec{
+		Image:   k.image,
+		Cmd:     []string{"sleep", "infinity"},
+		Limits:  w.limits,
+		Network: k.network,
+	}
+	for i, t := range k.targets {
+		if err := os.MkdirAll(slotDir(w, i), 0o700); err != nil {
+			os.RemoveAll(w.dir)
+			return nil, err
+		}
+		base.Mounts = append(base.Mounts, Mount{Source: slotDir(w, i), Target: t.Target, ReadOnly: t.ReadOnly})
+	}
+	if p.cfg.ModuleCache != "" && !slices.ContainsFunc(k.targets, func(m Mount) bool { return m.Target == moduleCacheDir }) {
+		base.Mounts = append(base.Mounts, Mount{Source: p.cfg.ModuleCache, Target: moduleCacheDir})
+	}
+	args := p.cold.runArgs(w.name, base)
+	args = slices.Insert(args, 1, "--detach", "--label", "ai-ide.pool="+k.image)
+
+	ctx, cancel := context.WithTimeout(p.ctx, startTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, p.cold.binary(), args...).CombinedOutput(); err != nil {
+		p.destroy(w.name)
+		return nil, fmt.Errorf("runner: docker run: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return w, nil
+}
+
+func slotDir(w *warm, i int) string {
+	return filepath.Join(w.dir, strconv.Itoa(i))
+}
+
+// moveEntries renames every entry of dir from into dir to. On failure the
+// entries already moved stay in to.
+func moveEntries(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.Rename(filepath.Join(from, e.Name()), filepath.Join(to, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/artifact"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/pprof"
+)
+
+// ProfileKind selects what a profiled run records.
+type ProfileKind string
+
+const (
+	ProfileCPU  ProfileKind = "cpu"
+	ProfileHeap ProfileKind = "heap"
+	// ProfileTrace records an execution trace with runtime/trace rather
+	// than a pprof profile.
+	ProfileTrace ProfileKind = "trace"
+)
+
+var (
+	// ErrInvalidProfile is returned for unknown profile kinds and sample
+	// types.
+	ErrInvalidProfile = errors.New("runner: invalid profile")
+	// ErrNoProfile is returned for unknown or expired profiles.
+	ErrNoProfile = errors.New("runner: profile not found")
+)
+
+// Profile is a stored pprof profile or execution trace, downloadable
+// until ExpiresAt.
+type Profile struct {
+	ID        string      `json:"id"`
+	Kind      ProfileKind `json:"kind"`
+	Size      int64       `json:"size"`
+	SHA256    string      `json:"sha256"`
+	CreatedAt time.Time   `json:"createdAt"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+	// URL downloads the profile without an access token until it
+	// expires.
+	URL string `json:"url,omitempty"`
+}
+
+// Name is the file name the profile is downloaded as.
+func (p *Profile) Name() string {
+	if p.Kind == ProfileTrace {
+		return "trace.out"
+	}
+	return string(p.Kind) + ".pprof"
+}
+
+// ProfileView is a profile summarized for one sample type: the flame
+// graph of all stacks and the functions with the most samples.
+type ProfileView struct {
+	Profile     *Profile          `json:"profile"`
+	SampleTypes []pprof.ValueType `json:"sampleTypes"`
+	SampleType  pprof.ValueType   `json:"sampleType"`
+	DurationMS  int64             `json:"durationMs"`
+	Flame       *pprof.Node       `json:"flame"`
+	Top         []pprof.Func      `json:"top"`
+}
+
+// maxProfileBytes bounds the profile a run may leave behind.
+const maxProfileBytes = 64 << 20
+
+// profileMain is what the program's main function is renamed to in a
+// profiled build, so the injected main can wrap it.
+const profileMain = "webideUserMain"
+
+// profileMains are the injected main functions. Each records into
+// /prof/profile and then calls the program's own main; the profile is
+// written when main returns or panics, but not on os.Exit.
+var profileMains = map[ProfileKind]string{
+	ProfileCPU: `package main
+
+import (
+	"os"
+	"runtime/pprof"
+)
+
+func main() {
+	if f, err := os.Create("/prof/profile"); err == nil {
+		if pprof.StartCPUProfile(f) == nil {
+			defer func() {
+				pprof.StopCPUProfile()
+				f.Close()
+			}()
+		}
+	}
+	` + profileMain + `()
+}
+`,
+	ProfileHeap: `package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+func main() {
+	// Sample small allocations too; short programs rarely reach the
+	// default rate of one sample per 512 KiB.
+	runtime.MemProfileRate = 4096
+	defer func() {
+		f, err := os.Create("/prof/profile")
+		if err != nil {
+			return
+		}
+		runtime.GC()
+		pprof.WriteHeapProfile(f)
+		f.Close()
+	}()
+	` + profileMain + `()
+}
+`,
+	ProfileTrace: `package main
+
+import (
+	"os"
+	"runtime/trace"
+)
+
+func main() {
+	if f, err := os.Create("/prof/profile"); err == nil {
+		if trace.Start(f) == nil {
+			defer func() {
+				trace.Stop()
+				f.Close()
+			}()
+		}
+	}
+	` + profileMain + `()
+}
+`,
+}
+
+// instrument returns files with the main package's func main renamed to
+// profileMain and a wrapping main of the given kind added. The rename is
+// done in place, so compiler positions still match the submitted files.
+func instrument(files []File, mainPkg string, kind ProfileKind) ([]File, error) {
+	wrapper, ok := profileMains[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: kind %q", ErrInvalidProfile, kind)
+	}
+	dir := strings.TrimPrefix(mainPkg, "./")
+	out := slices.Clone(files)
+	var found bool
+	for i, f := range out {
+		p, err := cleanRelPath(f.Path)
+		if err != nil || path.Dir(p) != dir || !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			continue
+		}
+		if src, ok := renameMain(p, f.Content); ok {
+			out[i].Content, found = src, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no func main in %s", ErrInvalidProject, mainPkg)
+	}
+	return append(out, File{Path: path.Join(dir, "webide_profile.go"), Content: wrapper}), nil
+}
+
+// renameMain renames func main in a package main source file. Files that
+// do not parse are left for the compiler to report.
+func renameMain(name, src string) (string, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.SkipObjectResolution)
+	if err != nil || f.Name.Name != "main" {
+		return src, false
+	}
+	for _, d := range f.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			off := fset.Position(fn.Name.Pos()).Offset
+			return src[:off] + profileMain + src[off+len("main"):], true
+		}
+	}
+	return src, false
+}
+
+// storeProfile stores the profile a run left in dir as an artifact of the
+// workspace it ran in, if any. It returns nil when there is none, as when
+// the program exited through os.Exit or was killed.
+func (r *Runner) storeProfile(ctx context.Context, dir string, kind ProfileKind, workspace string) (*Profile, error) {
+	src := filepath.Join(dir, "profile")
+	fi, err := os.Stat(src)
+	if err != nil || fi.Size() == 0 || fi.Size() > maxProfileBytes || !fi.Mode().IsRegular() {
+		return nil, nil
+	}
+	p := &Profile{Kind: kind}
+	a := artifact.Artifact{
+		Kind:      artifact.KindProfile,
+		Name:      p.Name(),
+		Workspace: workspace,
+		Labels:    map[string]string{"profile": string(kind)},
+	}
+	if kind == ProfileTrace {
+		a.Kind = artifact.KindTrace
+	}
+	stored, err := r.cfg.Artifacts.PutFile(ctx, a, r.cfg.ArtifactTTL, src)
+	if err != nil {
+		return nil, fmt.Errorf("runner: store profile: %w", err)
+	}
+	return storedProfile(stored), nil
+}
+
+func storedProfile(a *artifact.Artifact) *Profile {
+	return &Profile{
+		ID:        a.ID,
+		Kind:      ProfileKind(a.Labels["profile"]),
+		Size:      a.Size,
+		SHA256:    a.SHA256,
+		CreatedAt: a.CreatedAt,
+		ExpiresAt: a.ExpiresAt,
+		URL:       a.URL,
+	}
+}
+
+// Profile returns a stored profile the user of ctx may see and the path of
+// its data.
+func (r *Runner) Profile(ctx context.Context, id string) (*Profile, string, error) {
+	a, ok := r.stored(ctx, id, artifact.KindProfile, artifact.KindTrace)
+	if !ok {
+		return nil, "", ErrNoProfile
+	}
+	return storedProfile(a), r.cfg.Artifacts.Path(a), nil
+}
+
+// ViewProfile decodes a stored profile and summarizes it for the named
+// sample type, such as "cpu" or "inuse_space"; empty selects cpu time or
+// allocated bytes. top bounds the function table.
+func (r *Runner) ViewProfile(ctx context.Context, id, sampleType string, top int) (*ProfileView, error) {
+	meta, file, err := r.Profile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Kind == ProfileTrace {
+		return nil, fmt.Errorf("%w: %s is an execution trace", ErrInvalidProfile, id)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("runner: read profile: %w", err)
+	}
+	defer f.Close()
+	p, err := pprof.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("runner: read profile %s: %w", id, err)
+	}
+	// Everything a finished program allocated is garbage by the time the
+	// profile is written, so allocations say more than the live heap.
+	if sampleType == "" && meta.Kind == ProfileHeap {
+		sampleType = "alloc_space"
+	}
+	// Paths inside the module are shown relative to its root, as in
+	// build diagnostics.
+	for _, s := range p.Samples {
+		for j := range s.Stack {
+			s.Stack[j].File = strings.TrimPrefix(s.Stack[j].File, "/workspace/")
+		}
+	}
+	i, err := p.SampleIndex(sampleType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+	}
+	return &ProfileView{
+		Profile:     meta,
+		SampleTypes: p.SampleTypes,
+		SampleType:  p.SampleTypes[i],
+		DurationMS:  p.DurationNanos / int64(time.Millisecond),
+		Flame:       p.Flame(i),
+		Top:         p.Top(i, top),
+	}, nil
+}
@@ -0,0 +1,224 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// File is one source file of a submitted project.
+type File struct {
+	// Path is slash-separated and relative to the module root.
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Project size bounds for a single submission.
+const (
+	MaxProjectFiles = 500
+	MaxProjectBytes = 10 << 20
+)
+
+// defaultGoMod is used when a submission has no go.mod of its own, so single
+// files and loose packages build the same way as full modules.
+const defaultGoMod = "module webide.local/app\n\ngo 1.21\n"
+
+// ErrInvalidProject is returned for malformed project submissions.
+var ErrInvalidProject = errors.New("runner: invalid project")
+
+// files returns the request's file set, treating a bare Source as the
+// language's source file.
+func (req Request) files(sourceFile string) ([]File, error) {
+	switch {
+	case len(req.Files) > 0 && req.Source != "":
+		return nil, fmt.Errorf("%w: source and files are mutually exclusive", ErrInvalidProject)
+	case len(req.Files) > 0:
+		return req.Files, nil
+	case strings.TrimSpace(req.Source) == "":
+		return nil, ErrEmptySource
+	default:
+		return []File{{Path: sourceFile, Content: req.Source}}, nil
+	}
+}
+
+// module returns the directory of the module to build, checking that
+// files has its go.mod. The root of a go.work project is only a module
+// when it has a go.mod of its own.
+func (req Request) module(files []File) (string, error) {
+	m := "."
+	if req.Module != "" && req.Module != "." {
+		var err error
+		if m, err = cleanRelPath(req.Module); err != nil {
+			return "", err
+		}
+	}
+	var work bool
+	for _, f := range files {
+		switch p, _ := cleanRelPath(f.Path); p {
+		case path.Join(m, "go.mod"):
+			return m, nil
+		case "go.work":
+			work = true
+		}
+	}
+	if m == "." && !work {
+		return m, nil // goProject adds the go.mod
+	}
+	return "", fmt.Errorf("%w: no go.mod in module %q", ErrInvalidProject, m)
+}
+
+// mainPackage returns the package to build into the executable.
+func (req Request) mainPackage() (string, error) {
+	if req.Main == "" {
+		return ".", nil
+	}
+	p, err := cleanRelPath(req.Main)
+	if err != nil {
+		return "", err
+	}
+	return "./" + p, nil
+}
+
+// project is what goProject learns of a submission's layout.
+type project struct {
+	// needsModules reports whether a module declares external
+	// requirements.
+	needsModules bool
+	// workspace reports whether there is a go.work at the root.
+	workspace bool
+}
+
+// goProject inspects the go.mod and go.work files of a Go submission,
+// adding a go.mod when there is neither a go.mod nor a go.work at the
+// root.
+func goProject(files []File) ([]File, project) {
+	var pr project
+	var haveGoMod bool
+	for _, f := range files {
+		// Bad paths are rejected by materialize.
+		p, _ := cleanRelPath(f.Path)
+		switch {
+		case p == "go.work":
+			pr.workspace = true
+		case path.Base(p) == "go.mod":
+			haveGoMod = haveGoMod || p == "go.mod"
+			pr.needsModules = pr.needsModules || requires(f.Content)
+		}
+	}
+	if !haveGoMod && !pr.workspace {
+		files = append(slices.Clip(files), File{Path: "go.mod", Content: defaultGoMod})
+	}
+	return files, pr
+}
+
+// requires reports whether the go.mod gomod has a require directive with
+// at least one module, on its own line or in a require block.
+func requires(gomod string) bool {
+	block := false
+	for line := range strings.Lines(gomod) {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case block && fields[0] == ")":
+			block = false
+		case block:
+			return true
+		case fields[0] == "require" && len(fields) > 1 && fields[1] == "(":
+			// An empty block, "require ()", has a third field of ")".
+			block = len(fields) == 2
+		case fields[0] == "require" && len(fields) > 1 && fields[1] != "()":
+			return true
+		}
+	}
+	return false
+}
+
+// materialize writes files beneath dir.
+func materialize(dir string, files []File) error {
+	if len(files) > MaxProjectFiles {
+		return fmt.Errorf("%w: more than %d files", ErrInvalidProject, MaxProjectFiles)
+	}
+	var total int
+	seen := make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		p, err := cleanRelPath(f.Path)
+		if err != nil {
+			return err
+		}
+		if seen[p] {
+			return fmt.Errorf("%w: duplicate path %q", ErrInvalidProject, p)
+		}
+		if dirs[p] {
+			return fmt.Errorf("%w: %q is both a file and a directory", ErrInvalidProject, p)
+		}
+		seen[p] = true
+		for d := path.Dir(p); d != "."; d = path.Dir(d) {
+			if seen[d] {
+				return fmt.Errorf("%w: %q is both a file and a directory", ErrInvalidProject, d)
+			}
+			dirs[d] = true
+		}
+		if total += len(f.Content); total > MaxProjectBytes {
+			return fmt.Errorf("%w: project exceeds %d bytes", ErrInvalidProject, MaxProjectBytes)
+		}
+
+		dst := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return fmt.Errorf("runner: materialize %s: %w", p, err)
+		}
+		if err := os.WriteFile(dst, []byte(f.Content), 0o644); err != nil {
+			return fmt.Errorf("runner: materialize %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// entryFile returns the file a Python or JavaScript program starts in:
+// main, or def when main is empty. It must be one of files.
+func entryFile(main, def string, files []File) (string, error) {
+	if main == "" {
+		main = def
+	}
+	p, err := cleanRelPath(main)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := findFile(files, p); !ok {
+		return "", fmt.Errorf("%w: no file %q to run", ErrInvalidProject, p)
+	}
+	return p, nil
+}
+
+// findFile returns the file of files at the clean path p.
+func findFile(files []File, p string) (File, bool) {
+	for _, f := range files {
+		if q, _ := cleanRelPath(f.Path); q == p {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// cleanRelPath validates a client-supplied relative path and returns it in
+// canonical slash form. Absolute paths and paths escaping the root are rejected.
+func cleanRelPath(p string) (string, error) {
+	if p == "" || strings.ContainsRune(p, 0) || strings.Contains(p, `\`) {
+		return "", fmt.Errorf("%w: bad path %q", ErrInvalidProject, p)
+	}
+	if path.IsAbs(p) {
+		return "", fmt.Errorf("%w: absolute path %q", ErrInvalidProject, p)
+	}
+	clean := path.Clean(p)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: path %q escapes the project root", ErrInvalidProject, p)
+	}
+	return clean, nil
+}
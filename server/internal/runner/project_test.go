@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRequires(t *testing.T) {
+	tests := []struct {
+		gomod string
+		want  bool
+	}{
+		{"module example.com/app\n\ngo 1.21\n", false},
+		{"module example.com/require\n", false},
+		{"module example.com/app\n// require example.com/dep v1.0.0\n", false},
+		{"module example.com/app\nrequire example.com/dep v1.0.0\n", true},
+		{"module example.com/app\nrequire example.com/dep v1.0.0 // indirect\n", true},
+		{"module example.com/app\nrequire (\n\texample.com/dep v1.0.0\n)\n", true},
+		{"module example.com/app\nrequire (\n\t// none yet\n)\n", false},
+		{"module example.com/app\nrequire ()\n", false},
+		{"module example.com/app\nrequire ( )\n", false},
+	}
+	for _, tt := range tests {
+		if got := requires(tt.gomod); got != tt.want {
+			t.Errorf("requires(%q) = %v, want %v", tt.gomod, got, tt.want)
+		}
+	}
+}
+
+func TestMaterializePathConflict(t *testing.T) {
+	for _, files := range [][]File{
+		{{Path: "a", Content: "x"}, {Path: "a/b.go", Content: "package a"}},
+		{{Path: "a/b/c.go", Content: "package b"}, {Path: "a/b", Content: "x"}},
+	} {
+		err := materialize(t.TempDir(), files)
+		if !errors.Is(err, ErrInvalidProject) {
+			t.Errorf("materialize(%v) = %v, want ErrInvalidProject", files, err)
+		}
+	}
+	if err := materialize(t.TempDir(), []File{{Path: "a/b.go"}, {Path: "a/c/d.go"}}); err != nil {
+		t.Errorf("materialize of nested files: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+)
+
+// pythonCheck compiles every .py file of the working directory without
+// running it and prints each syntax error as
+// "file:line:col[-endline:endcol]: Kind: message" to stderr, exiting 1 when
+// there was any.
+const pythonCheck = `import os, sys
+bad = 0
+for root, dirs, names in os.walk("."):
+    dirs[:] = sorted(d for d in dirs if not d.startswith(".") and d != "__pycache__")
+    for name in sorted(names):
+        if not name.endswith(".py"):
+            continue
+        p = os.path.relpath(os.path.join(root, name))
+        try:
+            with open(p, "rb") as f:
+                compile(f.read(), p, "exec", dont_inherit=True)
+        except SyntaxError as e:
+            bad = 1
+            pos = "%d:%d" % (e.lineno or 1, e.offset or 1)
+            if e.end_lineno and e.end_offset:
+                pos += "-%d:%d" % (e.end_lineno, e.end_offset)
+            print("%s:%s: %s: %s" % (p, pos, type(e).__name__, e.msg), file=sys.stderr)
+        except ValueError as e:
+            bad = 1
+            print("%s:1:1: %s" % (p, e), file=sys.stderr)
+sys.exit(bad)
+`
+
+// pythonBuildScript installs requirements.txt into /out/site-packages when
+// $2 is set, then runs the check program $1.
+const pythonBuildScript = `if [ -n "$2" ]; then pip install --quiet --target /out/site-packages -r requirements.txt || exit 1; fi
+exec python -c "$1"`
+
+// python runs Python programs with the interpreter of its image.
+type python struct {
+	image string
+}
+
+// Python returns the Language running Python programs in image, which
+// defaults to python:3.12-slim. A project runs main.py, or the file
+// Request.Main names. The packages its requirements.txt lists are
+// installed with pip in the build phase, which also compiles every .py
+// file, so syntax errors come back as diagnostics without running
+// anything.
+func Python(image string) Language {
+	if image == "" {
+		image = "python:3.12-slim"
+	}
+	return &python{image: image}
+}
+
+func (*python) Name() string       { return "python" }
+func (*python) SourceFile() string { return "main.py" }
+
+func (py *python) Prepare(req Request, files []File) (*Plan, error) {
+	if err := checkGoOnly(req, "python"); err != nil {
+		return nil, err
+	}
+	main, err := entryFile(req.Main, "main.py", files)
+	if err != nil {
+		return nil, err
+	}
+	var deps string
+	if f, ok := findFile(files, "requirements.txt"); ok && hasRequirements(f.Content) {
+		deps = "1"
+	}
+	return &Plan{
+		Image: py.image,
+		Files: files,
+		Build: []string{"sh", "-c", pythonBuildScript, "sh", pythonCheck, deps},
+		BuildEnv: []string{
+			"HOME=/tmp", "PYTHONDONTWRITEBYTECODE=1",
+			"PIP_NO_CACHE_DIR=1", "PIP_DISABLE_PIP_VERSION_CHECK=1", "PIP_ROOT_USER_ACTION=ignore",
+		},
+		Network: deps != "",
+		Run:     []string{"python", main},
+		// Output is unbuffered so it streams as the program prints it.
+		RunEnv:  []string{"PYTHONPATH=/out/site-packages", "PYTHONDONTWRITEBYTECODE=1", "PYTHONUNBUFFERED=1"},
+		WorkDir: "/workspace",
+	}, nil
+}
+
+func (*python) Diagnostics(stderr string) []diag.Diagnostic {
+	return parsePythonErrors(stderr)
+}
+
+// hasRequirements reports whether a requirements.txt names any package,
+// rather than only comments and blank lines.
+func hasRequirements(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if line, _, _ = strings.Cut(line, "#"); strings.TrimSpace(line) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var pythonErrorPattern = regexp.MustCompile(`^(.+?\.py):(\d+):(\d+)(?:-(\d+):(\d+))?: (.+)$`)
+
+// parsePythonErrors extracts the syntax errors pythonCheck prints. The
+// end of a diagnostic's range is the column after the offending text, as
+// Python reports it.
+func parsePythonErrors(out string) []diag.Diagnostic {
+	var ds []diag.Diagnostic
+	for _, line := range strings.Split(out, "\n") {
+		m := pythonErrorPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		start := diag.Position{Line: atoi(m[2]), Column: atoi(m[3])}
+		end := start
+		if m[4] != "" {
+			end = diag.Position{Line: atoi(m[4]), Column: atoi(m[5])}
+		}
+		ds = append(ds, diag.Diagnostic{
+			File:     strings.TrimPrefix(m[1], "./"),
+			Range:    diag.Range{Start: start, End: end},
+			Severity: diag.SeverityError,
+			Source:   "python",
+			Message:  m[6],
+		})
+	}
+	return ds
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
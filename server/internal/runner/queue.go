@@ -0,0 +1,274 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/metrics"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/tracing"
+)
+
+// Priority orders the jobs waiting in a Queue.
+type Priority int
+
+const (
+	// PriorityBatch is for work nobody watches as it happens, such as
+	// test runs.
+	PriorityBatch Priority = iota
+	// PriorityInteractive is for runs and builds someone is waiting on.
+	PriorityInteractive
+)
+
+func (p Priority) String() string {
+	if p == PriorityInteractive {
+		return "interactive"
+	}
+	return "batch"
+}
+
+// QueueConfig configures a Queue.
+type QueueConfig struct {
+	// Workers is how many jobs run at once; defaults to 4.
+	Workers int
+	// MaxDepth is how many jobs may wait; defaults to 64. Jobs beyond it
+	// fail with ErrQueueFull.
+	MaxDepth int
+	// MaxWait is how long a job waits before it gives up with
+	// ErrQueueFull; defaults to two minutes.
+	MaxWait time.Duration
+	// Owner names whom a job is for, such as the user in ctx. Waiting
+	// jobs of owners with fewer running are admitted first; by default
+	// all jobs have the same owner.
+	Owner func(ctx context.Context) string
+	// Metrics, when set, receives the jobs running and waiting, how long
+	// jobs waited, and those turned away.
+	Metrics *metrics.Registry
+}
+
+// ErrQueueFull is returned for jobs the Queue cannot hold or did not
+// admit in time.
+var ErrQueueFull = errors.New("runner: execution queue is full")
+
+// Queue admits jobs into a limited number of workers. Waiting jobs are
+// admitted by priority, then fairly across owners, then in arrival order.
+type Queue struct {
+	cfg QueueConfig
+
+	mu       sync.Mutex
+	running  int
+	owners   map[string]int
+	waiting  []*queuedJob
+	sequence uint64
+
+	waited   *metrics.Histogram
+	rejected *metrics.Counter
+}
+
+type queuedJob struct {
+	owner    string
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	admitted bool
+	position int
+	// positions carries the latest position to the waiting goroutine.
+	positions chan int
+}
+
+// NewQueue returns a Queue, filling unset Config fields with defaults.
+func NewQueue(cfg QueueConfig) *Queue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = 64
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = 2 * time.Minute
+	}
+	if cfg.Owner == nil {
+		cfg.Owner = func(context.Context) string { return "" }
+	}
+	q := &Queue{cfg: cfg, owners: make(map[string]int)}
+	q.waited = cfg.Metrics.Histogram("webide_queue_wait_seconds",
+		"Time jobs waited in the execution queue before starting.", metrics.DefBuckets)
+	q.rejected = cfg.Metrics.Counter("webide_queue_rejected_total",
+		"Jobs the execution queue turned away, by reason: full or timeout.", "reason")
+	cfg.Metrics.GaugeFunc("webide_queue_running", "Jobs running in execution queue workers.", func() float64 {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return float64(q.running)
+	})
+	cfg.Metrics.GaugeFunc("webide_queue_depth", "Jobs waiting in the execution queue.", func() float64 {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return float64(len(q.waiting))
+	})
+	return q
+}
+
+// Acquire waits until the job may run and returns the function that ends
+// it. While the job waits, report, when set, is called with its position,
+// 1 being next, whenever that changes.
+func (q *Queue) Acquire(ctx context.Context, p Priority, report func(position int)) (release func(), err error) {
+	owner := q.cfg.Owner(ctx)
+	start := time.Now()
+	_, span := tracing.Start(ctx, "queue.wait")
+	defer func() {
+		span.Fail(err)
+		span.End()
+	}()
+	span.Set("user.id", owner)
+	span.Set("queue.priority", p.String())
+	q.mu.Lock()
+	span.Set("queue.waiting", len(q.waiting))
+	if q.running < q.cfg.Workers && len(q.waiting) == 0 {
+		q.startLocked(owner)
+		q.mu.Unlock()
+		q.waited.Observe(0)
+		return q.releaser(owner), nil
+	}
+	if len(q.waiting) >= q.cfg.MaxDepth {
+		q.mu.Unlock()
+		q.rejected.Inc("full")
+		return nil, fmt.Errorf("%w: %d jobs waiting", ErrQueueFull, q.cfg.MaxDepth)
+	}
+	q.sequence++
+	j := &queuedJob{
+		owner:     owner,
+		priority:  p,
+		seq:       q.sequence,
+		ready:     make(chan struct{}),
+		positions: make(chan int, 1),
+	}
+	q.waiting = append(q.waiting, j)
+	q.updateLocked()
+	q.mu.Unlock()
+
+	timer := time.NewTimer(q.cfg.MaxWait)
+	defer timer.Stop()
+wait:
+	for {
+		select {
+		case <-j.ready:
+			q.waited.Since(start)
+			return q.releaser(owner), nil
+		case pos := <-j.positions:
+			if report != nil {
+				report(pos)
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+			break wait
+		case <-timer.C:
+			q.rejected.Inc("timeout")
+			err = fmt.Errorf("%w: not started within %s", ErrQueueFull, q.cfg.MaxWait)
+			break wait
+		}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if j.admitted {
+		// Admitted just as the wait ended.
+		q.finishLocked(owner)
+		return nil, err
+	}
+	for i, w := range q.waiting {
+		if w == j {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			break
+		}
+	}
+	q.updateLocked()
+	return nil, err
+}
+
+func (q *Queue) releaser(owner string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.finishLocked(owner)
+		})
+	}
+}
+
+func (q *Queue) startLocked(owner string) {
+	q.running++
+	q.owners[owner]++
+}
+
+// finishLocked ends a job of owner and admits waiting jobs into the
+// freed worker.
+func (q *Queue) finishLocked(owner string) {
+	q.running--
+	if q.owners[owner]--; q.owners[owner] <= 0 {
+		delete(q.owners, owner)
+	}
+	q.updateLocked()
+}
+
+// updateLocked admits waiting jobs while workers are free and tells the
+// others their new positions. q.mu must be held.
+func (q *Queue) updateLocked() {
+	order := q.orderLocked()
+	for len(order) > 0 && q.running < q.cfg.Workers {
+		j := order[0]
+		order = order[1:]
+		q.startLocked(j.owner)
+		j.admitted = true
+		close(j.ready)
+	}
+	q.waiting = order
+	for i, j := range order {
+		if j.position == i+1 {
+			continue
+		}
+		j.position = i + 1
+		// Only the latest position matters.
+		select {
+		case <-j.positions:
+		default:
+		}
+		j.positions <- j.position
+	}
+}
+
+// orderLocked returns the waiting jobs in the order they would be
+// admitted: by priority, then the owner with the fewest jobs running or
+// admitted before, then arrival.
+func (q *Queue) orderLocked() []*queuedJob {
+	counts := make(map[string]int, len(q.owners))
+	for o, n := range q.owners {
+		counts[o] = n
+	}
+	rest := append([]*queuedJob(nil), q.waiting...)
+	order := make([]*queuedJob, 0, len(rest))
+	for len(rest) > 0 {
+		best := 0
+		for i, j := range rest[1:] {
+			b := rest[best]
+			switch {
+			case j.priority != b.priority:
+				if j.priority > b.priority {
+					best = i + 1
+				}
+			case counts[j.owner] != counts[b.owner]:
+				if counts[j.owner] < counts[b.owner] {
+					best = i + 1
+				}
+			case j.seq < b.seq:
+				best = i + 1
+			}
+		}
+		j := rest[best]
+		rest = append(rest[:best], rest[best+1:]...)
+		counts[j.owner]++
+		order = append(order, j)
+	}
+	return order
+}
@@ -0,0 +1,232 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// resumeWindow is how long a streamed run outlives its socket, waiting for
+// the client to resume it, and how long an ended run stays resumable.
+const resumeWindow = 30 * time.Second
+
+// maxResumeBytes bounds the frames a stream keeps for its client to resume
+// from. Past it the oldest are dropped, acknowledged or not.
+const maxResumeBytes = 1 << 20
+
+// streamFrame is a frame sent on /ws/run, numbered in the order sent.
+type streamFrame struct {
+	seq  int64
+	data []byte
+}
+
+// stream is a streamed run, which the client that started it may resume
+// from another socket with its token. It keeps the frames the client has
+// not acknowledged.
+type stream struct {
+	token  string
+	cancel context.CancelFunc
+	stdin  *StdinBuffer
+
+	mu     sync.Mutex
+	frames []streamFrame // oldest first
+	bytes  int
+	next   int64         // seq of the next frame; they start at 1
+	acked  int64         // the last frame the client acknowledged
+	ended  bool          // the last frame has been added
+	wake   chan struct{} // closed when a frame is added or the owner changes
+	owner  *ws.Conn      // the socket following the stream, if any
+	idle   *time.Timer
+}
+
+func newStream(cancel context.CancelFunc, stdin *StdinBuffer) *stream {
+	return &stream{
+		token:  newStreamToken(),
+		cancel: cancel,
+		stdin:  stdin,
+		next:   1,
+		wake:   make(chan struct{}),
+	}
+}
+
+// push adds a frame, giving frame the seq to number it with, and drops
+// the oldest frames beyond maxResumeBytes.
+func (st *stream) push(frame func(seq int64) any) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	data, err := json.Marshal(frame(st.next))
+	if err != nil {
+		return
+	}
+	st.frames = append(st.frames, streamFrame{seq: st.next, data: data})
+	st.bytes += len(data)
+	st.next++
+	for st.bytes > maxResumeBytes && len(st.frames) > 1 {
+		st.bytes -= len(st.frames[0].data)
+		st.frames = st.frames[1:]
+	}
+	st.wakeLocked()
+}
+
+// end marks the last frame added.
+func (st *stream) end() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.ended = true
+	st.wakeLocked()
+}
+
+func (st *stream) wakeLocked() {
+	close(st.wake)
+	st.wake = make(chan struct{})
+}
+
+// ack drops the frames up to seq, which the client has received.
+func (st *stream) ack(seq int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.acked = max(st.acked, seq)
+	for len(st.frames) > 0 && st.frames[0].seq <= seq {
+		st.bytes -= len(st.frames[0].data)
+		st.frames = st.frames[1:]
+	}
+}
+
+// since returns the frames after seq, how many of those were dropped
+// before the client could receive them, and whether the stream has ended.
+func (st *stream) since(seq int64) (frames []streamFrame, missed int64, ended bool, wake <-chan struct{}) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := 0
+	for i < len(st.frames) && st.frames[i].seq <= seq {
+		i++
+	}
+	oldest := st.next
+	if len(st.frames) > 0 {
+		oldest = st.frames[0].seq
+	}
+	missed = max(oldest-max(seq, st.acked)-1, 0)
+	return st.frames[i:], missed, st.ended && i == len(st.frames), st.wake
+}
+
+// newStreamToken returns the secret a client resumes a stream with.
+func newStreamToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// stream returns the stream with token, or nil.
+func (h *Handler) stream(token string) *stream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.streams[token]
+}
+
+func (h *Handler) addStream(st *stream) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.streams[st.token] = st
+}
+
+// resumedFrame answers a resume frame. Missed counts the frames after the
+// client's seq that were dropped before it could receive them.
+type resumedFrame struct {
+	Type   string `json:"type"`
+	Token  string `json:"token"`
+	Missed int64  `json:"missed,omitempty"`
+}
+
+// follow sends the frames of st after seq on conn, then those added, until
+// the stream ends, conn closes or another socket resumes the stream. A
+// resumed stream first gets a resumedFrame. Frames from the client are
+// handled meanwhile. The stream is forgotten, and its run canceled if it
+// is still executing, once no socket has followed it for resumeWindow.
+func (h *Handler) follow(conn *ws.Conn, st *stream, seq int64, resumed bool) {
+	st.mu.Lock()
+	if st.idle != nil {
+		st.idle.Stop()
+		st.idle = nil
+	}
+	st.owner = conn
+	st.wakeLocked()
+	st.mu.Unlock()
+	defer func() {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		if st.owner != conn {
+			return
+		}
+		st.owner = nil
+		st.idle = time.AfterFunc(resumeWindow, func() {
+			st.cancel()
+			h.mu.Lock()
+			delete(h.streams, st.token)
+			h.mu.Unlock()
+		})
+	}()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var f inputFrame
+			if json.Unmarshal(data, &f) != nil {
+				continue
+			}
+			switch f.Type {
+			case frameStdin:
+				if f.Data != "" {
+					st.stdin.Write([]byte(f.Data))
+				}
+				if f.EOF {
+					st.stdin.CloseWrite()
+				}
+			case frameAck:
+				st.ack(f.Seq)
+			}
+		}
+	}()
+
+	for {
+		frames, missed, ended, wake := st.since(seq)
+		if resumed {
+			if conn.WriteJSON(resumedFrame{Type: "resumed", Token: st.token, Missed: missed}) != nil {
+				return
+			}
+			resumed = false
+		}
+		for _, f := range frames {
+			if conn.WriteMessage(ws.TextMessage, f.data) != nil {
+				return
+			}
+			seq = f.seq
+		}
+		if ended {
+			return
+		}
+		select {
+		case <-wake:
+		case <-gone:
+			return
+		}
+		st.mu.Lock()
+		taken := st.owner != conn
+		st.mu.Unlock()
+		if taken {
+			conn.CloseWithCode(ws.CloseNormal, "resumed on another connection")
+			return
+		}
+	}
+}
@@ -0,0 +1,679 @@
+// Package runner compiles and executes user programs inside an isolated
+// sandbox. Each run happens in two phases: a build phase that compiles the
+// sources into a binary, or for interpreted languages checks them and
+// installs their dependencies, and a run phase that executes the program
+// under the caller's resource limits with networking disabled. Go is built
+// in; a Language adds another programming language.
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/artifact"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/metrics"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/toolchain"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/tracing"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gopanic"
+)
+
+// Phase identifies the stage a run stopped in.
+type Phase string
+
+const (
+	PhaseBuild Phase = "build"
+	PhaseRun   Phase = "run"
+)
+
+// Config configures a Runner.
+type Config struct {
+	// Image is the container image holding the Go toolchain. It is used
+	// when Toolchains is nil.
+	Image string
+	// Toolchains, when set, selects the image for each request's Go
+	// version.
+	Toolchains Toolchains
+	// Variables, when set, supplies the environment variables of the
+	// workspace a run names to its program.
+	Variables Variables
+	// Secrets, when set, supplies the secrets of that workspace. They come
+	// after Variables, so a secret replaces a variable of the same name.
+	Secrets Variables
+	// TempDir is where per-run scratch directories are created.
+	TempDir string
+	// DefaultLimits and MaxLimits bound the run phase.
+	DefaultLimits Limits
+	MaxLimits     Limits
+	// BuildLimits bounds the compile phase, which is not user-configurable.
+	BuildLimits Limits
+	// Artifacts stores the binaries produced by Build and the profiles of
+	// profiled runs; without it, both fail.
+	Artifacts Artifacts
+	// ArtifactTTL is how long a built binary or profile stays
+	// downloadable; defaults to one hour.
+	ArtifactTTL time.Duration
+	// Access says which signed-in users besides their owners reach the
+	// stored binaries and profiles; others are told they do not exist.
+	Access artifact.Access
+	// CacheDir keeps the binaries of recent builds, so an unchanged
+	// program is not compiled again, and the cached output of runs;
+	// defaults to a directory under TempDir.
+	CacheDir string
+	// CacheTTL is how long an unused cache entry is kept; defaults to one
+	// hour. Negative values turn caching off.
+	CacheTTL time.Duration
+	// CacheBytes caps the size of the cache; defaults to 1 GiB.
+	CacheBytes int64
+	// GOPROXY, when set, is the module proxy builds download from.
+	GOPROXY string
+	// Egress, when set, supplies the environment that sends the traffic of
+	// builds with networking through the network policy proxy.
+	Egress Egress
+	// Queue, when set, admits runs and builds as interactive jobs.
+	Queue *Queue
+	// Languages are the languages besides Go that requests may select;
+	// nil means Python and Node with their default images.
+	Languages []Language
+	// Metrics, when set, receives the sandboxes executing and the
+	// duration and outcome of each phase.
+	Metrics *metrics.Registry
+}
+
+// Request is a program submitted for execution: either a single source
+// file in Source, or a whole project tree in Files.
+type Request struct {
+	// Language names the program's Language; defaults to "go".
+	Language string `json:"language,omitempty"`
+	// Source is the contents of the language's source file, such as
+	// main.go.
+	Source string `json:"source,omitempty"`
+	// Files is a project manifest; for Go it may include go.mod and nested
+	// packages, or a go.work and the modules it uses.
+	Files []File `json:"files,omitempty"`
+	// Module is the directory of the module to build, relative to the
+	// project root; defaults to the root. It selects one of the modules of
+	// a go.work project, whose root need not be a module.
+	Module string `json:"module,omitempty"`
+	// Main is the module-relative directory of the package to run; defaults
+	// to the module root. For other languages it is the file to run.
+	Main string `json:"main,omitempty"`
+	// Limits overrides DefaultLimits; zero fields keep the default.
+	Limits Limits `json:"limits,omitempty"`
+	// GoVersion selects the toolchain. Without it, the version declared
+	// by Workspace is used, or the server default.
+	GoVersion string `json:"goVersion,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	// Options are extra go build flags, such as the race detector.
+	Options BuildOptions `json:"options,omitempty"`
+	// Profile, when set, runs the program under the profiler of that kind.
+	Profile ProfileKind `json:"profile,omitempty"`
+	// Output selects how escape sequences and carriage returns in the
+	// output are delivered; defaults to OutputRaw.
+	Output OutputMode `json:"output,omitempty"`
+	// Stdin, when set, is connected to the program's standard input during
+	// the run phase. The build phase never sees it.
+	Stdin io.Reader `json:"-"`
+	// CacheOutput declares the program deterministic: the output of an
+	// identical earlier run, with the same files, options and limits, is
+	// replayed instead of running it again. It is ignored with Stdin or
+	// Profile.
+	CacheOutput bool `json:"cacheOutput,omitempty"`
+}
+
+// KillReason names the limit a program was stopped for.
+type KillReason string
+
+const (
+	KilledTimeout KillReason = "timeout"
+	KilledMemory  KillReason = "memory"
+	KilledOutput  KillReason = "output"
+)
+
+// Result is the structured outcome of a run.
+type Result struct {
+	Phase    Phase  `json:"phase"`
+	Language string `json:"language"`
+	// GoVersion is the toolchain's Go version, for Go programs.
+	GoVersion  string `json:"goVersion,omitempty"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exitCode"`
+	TimedOut   bool   `json:"timedOut"`
+	DurationMS int64  `json:"durationMs"`
+	// BuildMS is how long the build took, included in DurationMS; 0 when
+	// it was cached.
+	BuildMS int64  `json:"buildMs,omitempty"`
+	Limits  Limits `json:"limits"`
+	// Killed says which limit the sandbox stopped the phase for, if any,
+	// and Message explains it in words for the user.
+	Killed  KillReason `json:"killed,omitempty"`
+	Message string     `json:"message,omitempty"`
+	// OutputTruncated reports that output beyond Limits.OutputBytes was
+	// dropped.
+	OutputTruncated bool `json:"outputTruncated,omitempty"`
+	// Cached is CachedBuild or CachedOutput when the result reused an
+	// earlier build or run.
+	Cached string `json:"cached,omitempty"`
+	// Diagnostics are the compiler or syntax errors of a failed build,
+	// with paths relative to the module root, or for other languages the
+	// project root.
+	Diagnostics []diag.Diagnostic `json:"diagnostics,omitempty"`
+	// Profile is the recorded profile of a profiled run. It is missing
+	// when the program did not return from main, such as after os.Exit
+	// or a timeout.
+	Profile *Profile `json:"profile,omitempty"`
+	// Panic is the parsed panic or fatal error report of a Go program
+	// that crashed, with paths relative to the project root like
+	// Diagnostics.
+	Panic *gopanic.Panic `json:"panic,omitempty"`
+}
+
+// ErrEmptySource is returned for requests without any code.
+var ErrEmptySource = errors.New("runner: source is empty")
+
+// Toolchains resolves the Go toolchain for a request.
+type Toolchains interface {
+	Resolve(workspaceID, version string) (toolchain.Toolchain, error)
+}
+
+// Egress returns the proxy environment of a workspace's sandboxes, as
+// egress.Service does.
+type Egress interface {
+	Environ(workspaceID string) []string
+}
+
+// Variables returns the environment variables or secrets of a workspace
+// for a run by the caller in ctx.
+type Variables interface {
+	RunEnviron(ctx context.Context, workspaceID string) ([]string, error)
+}
+
+// Artifacts stores build outputs; *artifact.Store implements it.
+type Artifacts interface {
+	PutFile(ctx context.Context, a artifact.Artifact, ttl time.Duration, path string) (*artifact.Artifact, error)
+	Get(id string) (*artifact.Artifact, error)
+	Path(a *artifact.Artifact) string
+}
+
+// Runner builds and runs programs in a Sandbox.
+type Runner struct {
+	cfg       Config
+	sandbox   Sandbox
+	languages map[string]Language
+	metrics   runMetrics
+
+	// limitsMu guards cfg.DefaultLimits and cfg.MaxLimits, which
+	// SetLimits changes.
+	limitsMu sync.RWMutex
+}
+
+// New returns a Runner, filling unset Config fields with defaults.
+func New(cfg Config, sb Sandbox) *Runner {
+	if cfg.Image == "" {
+		cfg.Image = "golang:1.22-alpine"
+	}
+	if cfg.TempDir == "" {
+		cfg.TempDir = os.TempDir()
+	}
+	// Scratch directories are bind-mounted, which requires absolute paths.
+	if abs, err := filepath.Abs(cfg.TempDir); err == nil {
+		cfg.TempDir = abs
+	}
+	if cfg.DefaultLimits == (Limits{}) {
+		cfg.DefaultLimits = DefaultLimits
+	}
+	if cfg.MaxLimits == (Limits{}) {
+		cfg.MaxLimits = MaxLimits
+	}
+	if cfg.BuildLimits == (Limits{}) {
+		cfg.BuildLimits = Limits{CPUs: 2, MemoryMB: 1024, TimeoutMS: 60_000, MaxProcs: 256}
+	}
+	if cfg.ArtifactTTL <= 0 {
+		cfg.ArtifactTTL = time.Hour
+	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = filepath.Join(cfg.TempDir, "webide-cache")
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = time.Hour
+	}
+	if cfg.CacheBytes <= 0 {
+		cfg.CacheBytes = 1 << 30
+	}
+	if cfg.Languages == nil {
+		cfg.Languages = []Language{Python(""), Node("")}
+	}
+	if cfg.Metrics != nil {
+		sb = countedSandbox{sb, cfg.Metrics.Gauge("webide_sandboxes_active", "Sandboxes executing builds and runs.")}
+	}
+	r := &Runner{cfg: cfg, sandbox: sb, languages: make(map[string]Language), metrics: newRunMetrics(cfg.Metrics)}
+	for _, l := range cfg.Languages {
+		r.languages[l.Name()] = l
+	}
+	r.languages["go"] = goLanguage{r}
+	return r
+}
+
+// SetLimits replaces the default and maximum limits of runs, as
+// Config.DefaultLimits and Config.MaxLimits do, for the runs started from
+// now on. Zero fields take those of DefaultLimits and MaxLimits. Defaults
+// above the maximum are refused with ErrLimitExceeded.
+func (r *Runner) SetLimits(def, max Limits) error {
+	def, err := def.Resolve(DefaultLimits, Limits{})
+	if err != nil {
+		return err
+	}
+	if max, err = max.Resolve(MaxLimits, Limits{}); err != nil {
+		return err
+	}
+	if _, err := def.Resolve(def, max); err != nil {
+		return fmt.Errorf("default limits above the maximum: %w", err)
+	}
+	r.limitsMu.Lock()
+	r.cfg.DefaultLimits, r.cfg.MaxLimits = def, max
+	r.limitsMu.Unlock()
+	return nil
+}
+
+// Run compiles req.Source and executes the resulting binary, collecting the
+// output of the phase it stopped in. A non-nil error means the sandbox itself
+// failed; compile errors and non-zero exits are reported through the Result.
+func (r *Runner) Run(ctx context.Context, req Request) (*Result, error) {
+	var stdout, stderr bytes.Buffer
+	res, err := r.Stream(ctx, req, func(ev Event) {
+		switch ev.Type {
+		case EventCompiled:
+			stdout.Reset()
+			stderr.Reset()
+		case EventStdout:
+			appendOutput(&stdout, ev)
+		case EventStderr:
+			appendOutput(&stderr, ev)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	return res, nil
+}
+
+// appendOutput adds the data of an output event to buf, first dropping the
+// unterminated last line it replaces, if any.
+func appendOutput(buf *bytes.Buffer, ev Event) {
+	if ev.Rewrite {
+		buf.Truncate(bytes.LastIndexByte(buf.Bytes(), '\n') + 1)
+	}
+	buf.WriteString(ev.Data)
+}
+
+// Stream is like Run but reports progress through emit as it happens. Output
+// is delivered only as stdout/stderr events, so the returned Result carries
+// no captured output. emit is never called concurrently.
+func (r *Runner) Stream(ctx context.Context, req Request, emit Emitter) (*Result, error) {
+	ctx, span := tracing.Start(ctx, "runner.run")
+	defer span.End()
+	span.Set("workspace.id", req.Workspace)
+	res, err := r.stream(ctx, req, emit)
+	if err != nil {
+		span.Fail(err)
+		return nil, err
+	}
+	span.Set("run.language", res.Language)
+	span.Set("run.phase", string(res.Phase))
+	span.Set("run.exit_code", res.ExitCode)
+	if res.Cached != "" {
+		span.Set("run.cached", res.Cached)
+	}
+	if res.Killed != "" {
+		span.Set("run.killed", string(res.Killed))
+	}
+	if req.Workspace != "" {
+		events.Publish(ctx, events.Event{Type: events.RunFinished, Workspace: req.Workspace, Data: map[string]any{
+			"language":   res.Language,
+			"phase":      res.Phase,
+			"exitCode":   res.ExitCode,
+			"timedOut":   res.TimedOut,
+			"durationMs": res.DurationMS,
+			"buildMs":    res.BuildMS,
+		}})
+	}
+	return res, nil
+}
+
+func (r *Runner) stream(ctx context.Context, req Request, emit Emitter) (*Result, error) {
+	lang, err := r.language(req.Language)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Output.check(); err != nil {
+		return nil, err
+	}
+	files, err := req.files(lang.SourceFile())
+	if err != nil {
+		return nil, err
+	}
+	r.limitsMu.RLock()
+	def, max := r.cfg.DefaultLimits, r.cfg.MaxLimits
+	r.limitsMu.RUnlock()
+	limits, err := req.Limits.Resolve(def, max)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := lang.Prepare(req, files)
+	if err != nil {
+		return nil, err
+	}
+	var vars []string
+	if req.Workspace != "" && r.cfg.Variables != nil {
+		if vars, err = r.cfg.Variables.RunEnviron(ctx, req.Workspace); err != nil {
+			return nil, err
+		}
+	}
+	if req.Workspace != "" && r.cfg.Secrets != nil {
+		secrets, err := r.cfg.Secrets.RunEnviron(ctx, req.Workspace)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, secrets...)
+	}
+	if req.Profile != "" && r.cfg.Artifacts == nil {
+		return nil, ErrNoArtifactStore
+	}
+
+	em := newSyncEmitter(emit)
+	em.output = req.Output
+	release, err := r.admit(ctx, em)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	sc, err := r.scratch(plan.Files)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(sc.dir)
+
+	res := &Result{Phase: PhaseBuild, Language: lang.Name(), GoVersion: plan.Version, Limits: limits}
+	start := time.Now()
+	em.emit(Event{Type: EventStarted, Phase: PhaseBuild})
+
+	var buildErrs buildOutput
+	spec := r.buildSpec(plan.Image, sc, plan.WorkDir)
+	spec.Cmd, spec.Env, spec.Network = plan.Build, plan.BuildEnv, plan.Network
+	spec.Stderr = &buildErrs
+	key := buildKey(spec, plan.Files)
+	spec.Workspace = req.Workspace
+	r.egress(&spec, req.Workspace)
+	artifact := filepath.Join(sc.outDir, plan.Artifact)
+	if plan.Artifact != "" && r.cachedBinary(key, artifact) {
+		res.Cached = CachedBuild
+	} else {
+		build, err := r.exec(ctx, em, PhaseBuild, spec)
+		if err != nil {
+			return nil, err
+		}
+		res.BuildMS = time.Since(start).Milliseconds()
+		if build.ExitCode != 0 || build.TimedOut {
+			res.Diagnostics = lang.Diagnostics(buildErrs.buf.String())
+			return finish(em, res, build, r.cfg.BuildLimits, start), nil
+		}
+		if plan.Artifact != "" {
+			r.cacheBinary(key, artifact)
+		}
+	}
+
+	res.Phase = PhaseRun
+	em.emit(Event{Type: EventCompiled, Phase: PhaseRun})
+	outKey := ""
+	if req.CacheOutput && req.Stdin == nil && req.Profile == "" {
+		outKey = runKey(key, limits, req.Output, vars)
+		if out, ok := r.cachedOutput(outKey); ok {
+			for _, ev := range out.Events {
+				em.emit(ev)
+			}
+			res.Cached, res.Panic = CachedOutput, out.Panic
+			return finish(em, res, &ExecResult{ExitCode: out.ExitCode}, limits, start), nil
+		}
+	}
+	spec = r.runSpec(plan, sc, limits)
+	spec.Workspace = req.Workspace
+	spec.Env = append(vars, spec.Env...)
+	spec.Stdin = req.Stdin
+	profDir := filepath.Join(sc.dir, "prof")
+	if req.Profile != "" {
+		if err := os.Mkdir(profDir, 0o755); err != nil {
+			return nil, fmt.Errorf("runner: create scratch dir: %w", err)
+		}
+		spec.Mounts = append(spec.Mounts, Mount{Source: profDir, Target: "/prof"})
+	}
+	var rec *outputRecorder
+	if outKey != "" {
+		rec = &outputRecorder{}
+		em.record(rec)
+	}
+	pp, parsePanics := lang.(panicParser)
+	var crash panicOutput
+	if parsePanics {
+		spec.Stderr = &crash
+	}
+	run, err := r.exec(ctx, em, PhaseRun, spec)
+	if err != nil {
+		return nil, err
+	}
+	if parsePanics && run.ExitCode != 0 && !run.TimedOut && !run.OOMKilled && !run.OutputExceeded {
+		res.Panic = pp.Panic(string(crash.buf))
+	}
+	if rec != nil && !run.TimedOut && !run.OOMKilled && !run.OutputExceeded && !rec.truncated {
+		r.cacheOutput(outKey, &cachedRun{Events: rec.events, ExitCode: run.ExitCode, Panic: res.Panic})
+	}
+	if req.Profile != "" && !run.TimedOut {
+		if res.Profile, err = r.storeProfile(ctx, profDir, req.Profile, req.Workspace); err != nil {
+			return nil, err
+		}
+	}
+	return finish(em, res, run, limits, start), nil
+}
+
+// admit waits for the queue, if there is one, to admit an interactive job,
+// emitting its position as it waits.
+func (r *Runner) admit(ctx context.Context, em *syncEmitter) (release func(), err error) {
+	if r.cfg.Queue == nil {
+		return func() {}, nil
+	}
+	return r.cfg.Queue.Acquire(ctx, PriorityInteractive, func(pos int) {
+		em.emit(Event{Type: EventQueued, Phase: PhaseBuild, Position: pos})
+	})
+}
+
+// toolchain resolves the toolchain for a request's Go version.
+func (r *Runner) toolchain(workspaceID, version string) (toolchain.Toolchain, error) {
+	if r.cfg.Toolchains != nil {
+		return r.cfg.Toolchains.Resolve(workspaceID, version)
+	}
+	if version != "" {
+		return toolchain.Toolchain{}, fmt.Errorf("%w: %q", toolchain.ErrUnknownVersion, version)
+	}
+	return toolchain.Toolchain{Image: r.cfg.Image}, nil
+}
+
+// scratchDir is the per-request directory holding the sources in srcDir
+// and the build output in outDir.
+type scratchDir struct {
+	dir, srcDir, outDir string
+}
+
+// scratch creates a scratch directory and writes files into it. The
+// caller removes dir.
+func (r *Runner) scratch(files []File) (*scratchDir, error) {
+	dir, err := os.MkdirTemp(r.cfg.TempDir, "webide-run-")
+	if err != nil {
+		return nil, fmt.Errorf("runner: create scratch dir: %w", err)
+	}
+	sc := &scratchDir{dir: dir, srcDir: filepath.Join(dir, "src"), outDir: filepath.Join(dir, "out")}
+	for _, d := range []string{sc.srcDir, sc.outDir} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("runner: create scratch dir: %w", err)
+		}
+	}
+	if err := materialize(sc.srcDir, files); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return sc, nil
+}
+
+// exec runs spec with its output wired to stdout/stderr events for phase.
+// A Stderr already set on spec receives a copy of the error output. A
+// process writing more than spec.Limits.OutputBytes is stopped.
+func (r *Runner) exec(ctx context.Context, em *syncEmitter, phase Phase, spec Spec) (*ExecResult, error) {
+	ctx, span := tracing.Start(ctx, "sandbox."+string(phase))
+	defer span.End()
+	span.Set("sandbox.image", spec.Image)
+	execCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stdout, stderr := em.writer(EventStdout, phase), em.writer(EventStderr, phase)
+	var budget *outputBudget
+	if spec.Limits.OutputBytes > 0 {
+		budget = newOutputBudget(spec.Limits.OutputBytes, cancel)
+		stdout.budget, stderr.budget = budget, budget
+	}
+	spec.Stdout = stdout
+	if spec.Stderr != nil {
+		spec.Stderr = io.MultiWriter(stderr, spec.Stderr)
+	} else {
+		spec.Stderr = stderr
+	}
+	res, err := r.sandbox.Exec(execCtx, spec)
+	stdout.Flush()
+	stderr.Flush()
+	if budget != nil && budget.over() && ctx.Err() == nil {
+		// The sandbox was cancelled for the output, not by the caller.
+		if res == nil {
+			res = &ExecResult{ExitCode: -1}
+		}
+		res.OutputExceeded, err = true, nil
+	}
+	r.metrics.observe(phase, res, err)
+	if err != nil {
+		span.Fail(err)
+	} else {
+		span.Set("process.exit_code", res.ExitCode)
+		span.Set("sandbox.timed_out", res.TimedOut)
+		span.Set("sandbox.oom_killed", res.OOMKilled)
+		span.Set("sandbox.output_exceeded", res.OutputExceeded)
+	}
+	return res, err
+}
+
+// finish records the exec outcome on res, limited by l, and emits the
+// terminal event.
+func finish(em *syncEmitter, res *Result, er *ExecResult, l Limits, start time.Time) *Result {
+	res.ExitCode, res.TimedOut = er.ExitCode, er.TimedOut
+	res.OutputTruncated = er.OutputExceeded
+	switch {
+	case er.TimedOut:
+		res.Killed = KilledTimeout
+	case er.OOMKilled:
+		res.Killed = KilledMemory
+	case er.OutputExceeded:
+		res.Killed = KilledOutput
+	}
+	if res.Killed != "" {
+		res.Message = killMessage(res.Phase, res.Killed, l)
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	typ := EventExited
+	if res.TimedOut {
+		typ = EventTimedOut
+	}
+	em.emit(Event{Type: typ, Phase: res.Phase, Result: res})
+	return res
+}
+
+// killMessage explains to the user why the phase was stopped.
+func killMessage(phase Phase, reason KillReason, l Limits) string {
+	what := "The program"
+	if phase == PhaseBuild {
+		what = "The build"
+	}
+	switch reason {
+	case KilledTimeout:
+		return fmt.Sprintf("%s was stopped after %s, its time limit. Look for a loop that never ends or a read of input that never comes.", what, l.Timeout())
+	case KilledMemory:
+		return fmt.Sprintf("%s was stopped for using more than its %d MB of memory.", what, l.MemoryMB)
+	case KilledOutput:
+		return fmt.Sprintf("%s was stopped for writing more than %s of output. Look for a loop that prints without end.", what, formatBytes(l.OutputBytes))
+	}
+	return ""
+}
+
+// buildScript type-checks every package in the module, then links the main
+// package. The package path is passed as $1 and the build flags follow, so
+// they are never shell-interpreted.
+const buildScript = `pkg="$1"; shift; go build "$@" ./... && go build "$@" -o /out/prog "$pkg"`
+
+// buildSpec is the build phase in image, with the sources of sc and the
+// working directory workDir. The caller sets the command.
+func (r *Runner) buildSpec(image string, sc *scratchDir, workDir string) Spec {
+	return Spec{
+		Image:   image,
+		WorkDir: workDir,
+		// The scratch copy is writable so -mod=mod can record go.sum
+		// entries and dependencies can be installed next to the sources.
+		Mounts: []Mount{
+			{Source: sc.srcDir, Target: "/workspace"},
+			{Source: sc.outDir, Target: "/out"},
+		},
+		Limits: r.cfg.BuildLimits,
+	}
+}
+
+// buildEnv returns the environment of a build, with extra appended. The
+// go command refuses -mod=mod in workspace mode, so go.work projects
+// build with the go.sum files they come with.
+func (r *Runner) buildEnv(workspace bool, extra ...string) []string {
+	env := []string{"HOME=/tmp", "GOCACHE=/tmp/go-cache", "GOFLAGS=-mod=mod"}
+	if workspace {
+		env[2] = "GOFLAGS="
+	}
+	if r.cfg.GOPROXY != "" {
+		env = append(env, "GOPROXY="+r.cfg.GOPROXY)
+	}
+	return append(env, extra...)
+}
+
+// egress adds the proxy environment of workspaceID to a spec with
+// networking. It is added after the build key is taken, as the
+// credentials differ between workspaces but not the build.
+func (r *Runner) egress(spec *Spec, workspaceID string) {
+	if spec.Network && r.cfg.Egress != nil {
+		spec.Env = append(slices.Clip(spec.Env), r.cfg.Egress.Environ(workspaceID)...)
+	}
+}
+
+func (r *Runner) runSpec(plan *Plan, sc *scratchDir, limits Limits) Spec {
+	return Spec{
+		Image:   plan.Image,
+		Cmd:     plan.Run,
+		Env:     append([]string{"HOME=/tmp"}, plan.RunEnv...),
+		WorkDir: plan.WorkDir,
+		Mounts: []Mount{
+			{Source: sc.srcDir, Target: "/workspace", ReadOnly: true},
+			{Source: sc.outDir, Target: "/out", ReadOnly: true},
+		},
+		Limits: limits,
+	}
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// Mount binds a host directory into the sandbox.
+type Mount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// Spec describes a single process to execute inside a sandbox.
+type Spec struct {
+	Image   string
+	Cmd     []string
+	Env     []string
+	WorkDir string
+	Mounts  []Mount
+	Limits  Limits
+	// Network enables outbound networking; sandboxes are offline by default.
+	Network bool
+	// Workspace is the workspace the process runs for, if any.
+	Workspace string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ExecResult is the outcome of a sandboxed process.
+type ExecResult struct {
+	ExitCode int
+	Duration time.Duration
+	// TimedOut reports that the wall-clock limit expired and the sandbox was killed.
+	TimedOut bool
+	// OOMKilled reports that the process was killed for exceeding its
+	// memory limit.
+	OOMKilled bool
+	// OutputExceeded reports that the process was stopped for writing
+	// more than Limits.OutputBytes. The Runner sets it, not sandboxes.
+	OutputExceeded bool
+}
+
+// ErrQuotaExceeded is wrapped by the errors of sandboxes that refuse to
+// start because the caller has used up their quota.
+var ErrQuotaExceeded = errors.New("runner: quota exceeded")
+
+// ErrStopped is wrapped by the errors of sandboxes an administrator
+// stopped while they ran.
+var ErrStopped = errors.New("runner: stopped by an administrator")
+
+// Sandbox executes processes in isolation from the host. Implementations
+// must enforce Spec.Limits and release all resources once Exec returns.
+type Sandbox interface {
+	Exec(ctx context.Context, spec Spec) (*ExecResult, error)
+}
@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"io"
+	"sync"
+)
+
+// StdinBuffer is an unbounded in-memory pipe feeding a sandboxed process's
+// stdin. Unlike io.Pipe, writes never block, so input typed before the
+// program starts reading (or while it is still compiling) is kept.
+type StdinBuffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	closed bool
+}
+
+// NewStdinBuffer returns an empty, open StdinBuffer.
+func NewStdinBuffer() *StdinBuffer {
+	b := &StdinBuffer{}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Write appends p to the buffer. Writing after CloseWrite returns io.ErrClosedPipe.
+func (b *StdinBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	b.buf = append(b.buf, p...)
+	b.cond.Broadcast()
+	return len(p), nil
+}
+
+// CloseWrite signals EOF: readers drain what is buffered and then see io.EOF.
+func (b *StdinBuffer) CloseWrite() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
+
+// Read blocks until input is available or the writer side is closed.
+func (b *StdinBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.buf) == 0 && !b.closed {
+		b.cond.Wait()
+	}
+	if len(b.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
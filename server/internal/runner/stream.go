@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Client frame types accepted on /ws/run.
+const (
+	frameRun    = "run"
+	frameResume = "resume"
+	frameStdin  = "stdin"
+	frameAck    = "ack"
+)
+
+// clientFrame is the opening message sent by the editor over /ws/run: a
+// run frame with a Request, or a resume frame with the token of a stream
+// and the seq of the last frame the client received on it.
+type clientFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token,omitempty"`
+	After int64  `json:"after,omitempty"`
+	Request
+}
+
+// inputFrame is a message the client sends during a run. A stdin frame
+// forwards console input to the running program: Data is written verbatim
+// and EOF closes the program's stdin after Data has been delivered. An
+// ack frame tells the server the client has the frames up to Seq, which
+// it then need not keep for a resume.
+type inputFrame struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	EOF  bool   `json:"eof,omitempty"`
+	Seq  int64  `json:"seq,omitempty"`
+}
+
+// sessionFrame gives the client the token it resumes the run with.
+type sessionFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+// errorFrame reports a request-level failure before or during a run.
+// Status, on the lines of POST /api/run, is the HTTP status of the
+// failure.
+type errorFrame struct {
+	Type   string `json:"type"`
+	Error  string `json:"error"`
+	Seq    int64  `json:"seq,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+// serveStream handles /ws/run. The client opens the socket and sends a
+// {"type":"run", ...Request} frame, optionally followed by stdin frames; the
+// server replies with a session frame, then a stream of Events, and closes
+// the socket after the exited or timed-out event.
+//
+// Events and the error frame are numbered with seq. The run outlives its
+// socket by resumeWindow: a client that lost it opens another and sends
+// {"type":"resume","token":...,"after":seq} to receive the frames after
+// seq and the rest of the run. A run nobody resumes is canceled.
+func (h *Handler) serveStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var first clientFrame
+	if err := conn.ReadJSON(&first); err != nil || (first.Type != frameRun && first.Type != frameResume) {
+		conn.WriteJSON(errorFrame{Type: "error", Error: "expected a run frame"})
+		return
+	}
+	if first.Type == frameResume {
+		st := h.stream(first.Token)
+		if st == nil {
+			conn.WriteJSON(errorFrame{Type: "error", Error: "run not found; it ended too long ago or never started"})
+			return
+		}
+		h.follow(conn, st, first.After, true)
+		return
+	}
+
+	stdin := NewStdinBuffer()
+	// Programs whose output may be replayed read no console input.
+	if !first.CacheOutput {
+		first.Request.Stdin = stdin
+	}
+
+	// The run is not tied to the socket, which may be resumed by another,
+	// but keeps the request's values, such as the user.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	st := newStream(cancel, stdin)
+	h.addStream(st)
+	if conn.WriteJSON(sessionFrame{Type: "session", Token: st.token}) != nil {
+		cancel()
+		return
+	}
+
+	go func() {
+		defer cancel()
+		defer stdin.CloseWrite()
+		defer st.end()
+		_, err := h.runner.Stream(ctx, first.Request, func(ev Event) {
+			st.push(func(seq int64) any {
+				ev.Seq = seq
+				return ev
+			})
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			msg := err.Error()
+			if !IsRequestError(err) && !errors.Is(err, ErrQuotaExceeded) && !errors.Is(err, ErrQueueFull) && !errors.Is(err, ErrStopped) {
+				slog.Error("streamed run failed", "err", err)
+				msg = "execution failed"
+			}
+			st.push(func(seq int64) any { return errorFrame{Type: "error", Error: msg, Seq: seq} })
+		}
+	}()
+	h.follow(conn, st, 0, false)
+}
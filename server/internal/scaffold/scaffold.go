@@ -0,0 +1,198 @@
+// Package scaffold generates new projects from parameterized templates.
+//
+// Templates live in templates/<name>/ and are embedded in the binary.
+// Every file ending in .tmpl is rendered with text/template, as is its
+// path, and written without the suffix; other files are copied as they
+// are. Templates see the Params of the request.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	// ErrUnknownTemplate is returned for template names not in Templates.
+	ErrUnknownTemplate = errors.New("scaffold: unknown template")
+	// ErrInvalidParams is returned for malformed module paths or Go
+	// versions.
+	ErrInvalidParams = errors.New("scaffold: invalid parameters")
+)
+
+// Template describes one project template.
+type Template struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Setup lists the commands to run in the new workspace before the
+	// project builds, typically to fetch its dependencies.
+	Setup []string `json:"setup,omitempty"`
+}
+
+// Templates are the available templates, in display order.
+var Templates = []Template{
+	{
+		Name:        "cli",
+		Title:       "Command-line app",
+		Description: "A flag-based command with a testable run function.",
+	},
+	{
+		Name:        "http-chi",
+		Title:       "HTTP server with chi",
+		Description: "A JSON API on the chi router with logging, recovery and graceful shutdown.",
+		Setup:       []string{"go mod tidy"},
+	},
+	{
+		Name:        "grpc",
+		Title:       "gRPC service",
+		Description: "A gRPC server with health checks and reflection, and a protobuf definition to generate the service from.",
+		Setup:       []string{"go mod tidy"},
+	},
+	{
+		Name:        "library",
+		Title:       "Library with tests",
+		Description: "A reusable package with table-driven tests and a runnable example.",
+	},
+}
+
+// Params parameterize a template.
+type Params struct {
+	// Module is the module path written to go.mod; required.
+	Module string
+	// GoVersion is the go directive; defaults to 1.22.
+	GoVersion string
+}
+
+// data is what templates see.
+type data struct {
+	Module    string
+	GoVersion string
+	// Name is the last element of the module path, ignoring a major
+	// version suffix.
+	Name string
+	// Package is Name reduced to a valid package name.
+	Package string
+}
+
+var (
+	modulePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._~-]*(/[A-Za-z0-9._~-]+)*$`)
+	goVersionPattern = regexp.MustCompile(`^1\.[0-9]+(\.[0-9]+)?$`)
+	majorPattern     = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+// Lookup returns the template with the given name.
+func Lookup(name string) (*Template, error) {
+	for i := range Templates {
+		if Templates[i].Name == name {
+			return &Templates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownTemplate, name)
+}
+
+// Generate renders the template name into dir, which should be empty,
+// and returns the paths it wrote.
+func Generate(name, dir string, p Params) ([]string, error) {
+	if _, err := Lookup(name); err != nil {
+		return nil, err
+	}
+	d, err := newData(p)
+	if err != nil {
+		return nil, err
+	}
+	root := path.Join("templates", name)
+	var written []string
+	err = fs.WalkDir(templateFS, root, func(p string, de fs.DirEntry, err error) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+		content, err := templateFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(p, root+"/")
+		if strings.HasSuffix(rel, ".tmpl") {
+			if rel, err = render(rel, strings.TrimSuffix(rel, ".tmpl"), d); err != nil {
+				return err
+			}
+			s, err := render(rel, string(content), d)
+			if err != nil {
+				return err
+			}
+			content = []byte(s)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, content, 0o644); err != nil {
+			return err
+		}
+		written = append(written, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scaffold: generate %s: %w", name, err)
+	}
+	return written, nil
+}
+
+func newData(p Params) (data, error) {
+	if p.GoVersion == "" {
+		p.GoVersion = "1.22"
+	}
+	switch {
+	case p.Module == "":
+		return data{}, fmt.Errorf("%w: module path is required", ErrInvalidParams)
+	case len(p.Module) > 200 || !modulePattern.MatchString(p.Module) || strings.Contains(p.Module, ".."):
+		return data{}, fmt.Errorf("%w: bad module path %q", ErrInvalidParams, p.Module)
+	case !goVersionPattern.MatchString(p.GoVersion):
+		return data{}, fmt.Errorf("%w: bad Go version %q", ErrInvalidParams, p.GoVersion)
+	}
+	elems := strings.Split(p.Module, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && majorPattern.MatchString(name) {
+		name = elems[len(elems)-2]
+	}
+	return data{Module: p.Module, GoVersion: p.GoVersion, Name: name, Package: packageName(name)}, nil
+}
+
+// packageName turns a path element such as go-Widgets into a package name
+// such as widgets.
+func packageName(elem string) string {
+	elem = strings.TrimPrefix(strings.ToLower(elem), "go-")
+	var b strings.Builder
+	for _, r := range elem {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' && b.Len() > 0 {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "lib"
+	}
+	return b.String()
+}
+
+func render(name, text string, d data) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,261 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// maxQueryLen bounds search patterns and symbol queries.
+const maxQueryLen = 1000
+
+// maxUndoBytes bounds the body of an undo request.
+const maxUndoBytes = 64 << 20
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the search routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the search routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/search", h.search)
+	mux.HandleFunc("GET /api/workspaces/{id}/symbols", h.symbols)
+	mux.HandleFunc("POST /api/workspaces/{id}/replace/preview", h.preview)
+	mux.HandleFunc("POST /api/workspaces/{id}/replace", h.replace)
+	mux.HandleFunc("POST /api/workspaces/{id}/replace/undo", h.undo)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	dir, err := h.workspaces.Open(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return dir, true
+}
+
+// event is one line of a streamed search.
+type event struct {
+	Type string `json:"type"`
+	*Match
+	*Summary
+	Error string `json:"error,omitempty"`
+}
+
+// search runs a text search. The response is a single JSON object unless
+// the client accepts application/x-ndjson, in which case each match is
+// written as it is found, as a "match" event, followed by a "done" event
+// with the summary or an "error" event.
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	query := Query{
+		Pattern:       q.Get("q"),
+		Regex:         q.Get("regex") == "1",
+		CaseSensitive: q.Get("case") == "1",
+		WholeWord:     q.Get("word") == "1",
+		Include:       listParam(q["include"]),
+		Exclude:       listParam(q["exclude"]),
+	}
+	if len(query.Pattern) > maxQueryLen {
+		httpx.Errorf(w, http.StatusBadRequest, "pattern is longer than %d bytes", maxQueryLen)
+		return
+	}
+	if s := q.Get("max"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			httpx.Error(w, http.StatusBadRequest, "max must be a positive integer")
+			return
+		}
+		query.MaxResults = n
+	}
+	if _, err := compile(query); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		matches := []Match{}
+		sum, err := h.svc.Search(r.Context(), dir, query, func(m Match) error {
+			matches = append(matches, m)
+			return nil
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpx.JSON(w, http.StatusOK, map[string]any{"results": matches, "summary": sum})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	sum, err := h.svc.Search(r.Context(), dir, query, func(m Match) error {
+		if err := enc.Encode(event{Type: "match", Match: &m}); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if r.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		slog.Error("search", "err", err)
+		enc.Encode(event{Type: "error", Error: "search failed"})
+		return
+	}
+	enc.Encode(event{Type: "done", Summary: &sum})
+}
+
+// symbols runs a Go symbol search: ?q= is the name to look for and
+// ?kind= optionally restricts the kinds, comma-separated.
+func (h *Handler) symbols(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	query := SymbolQuery{Query: q.Get("q")}
+	if len(query.Query) > maxQueryLen {
+		httpx.Errorf(w, http.StatusBadRequest, "query is longer than %d bytes", maxQueryLen)
+		return
+	}
+	for _, k := range listParam(q["kind"]) {
+		query.Kinds = append(query.Kinds, SymbolKind(k))
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			httpx.Error(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		query.Limit = n
+	}
+	syms, err := h.svc.Symbols(r.Context(), dir, query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"symbols": syms})
+}
+
+func (h *Handler) decodeReplace(w http.ResponseWriter, r *http.Request) (ReplaceRequest, bool) {
+	var req ReplaceRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return req, false
+	}
+	if len(req.Pattern) > maxQueryLen {
+		httpx.Errorf(w, http.StatusBadRequest, "pattern is longer than %d bytes", maxQueryLen)
+		return req, false
+	}
+	return req, true
+}
+
+// preview shows the edits a replace would make, with each file's ETag
+// for the replace to check.
+func (h *Handler) preview(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	req, ok := h.decodeReplace(w, r)
+	if !ok {
+		return
+	}
+	p, err := h.svc.Preview(r.Context(), dir, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, p)
+}
+
+func (h *Handler) replace(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	req, ok := h.decodeReplace(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.svc.Replace(r.Context(), r.PathValue("id"), dir, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// undo takes the undo bundle of a replace and restores the files in it.
+func (h *Handler) undo(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var u Undo
+	// The bundle holds whole files, so it may be far larger than a query.
+	if err := httpx.DecodeJSON(w, r, &u, maxUndoBytes); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	refs, err := h.svc.Undo(r.Context(), r.PathValue("id"), dir, u)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"files": refs})
+}
+
+// listParam splits repeated and comma-separated query values.
+func listParam(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidQuery), errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		httpx.Error(w, http.StatusNotFound, "file not found")
+	case errors.Is(err, ErrConflict), errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("search", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "search failed")
+	}
+}
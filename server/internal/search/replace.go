@@ -0,0 +1,381 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+var (
+	// ErrConflict is returned when a file changed since the preview or the
+	// replace an undo bundle came from.
+	ErrConflict = errors.New("search: file changed")
+	// ErrTooMany is returned when a replace would make more than
+	// Config.MaxResults replacements.
+	ErrTooMany = errors.New("search: too many replacements")
+)
+
+// ReplaceRequest describes a find-and-replace. The matches of Query are
+// replaced by Replacement, in which $1 or ${name} refer to the groups of
+// a regular expression.
+type ReplaceRequest struct {
+	Query
+	Replacement string `json:"replacement"`
+	// Files, when set, restricts the replace to these files, each of which
+	// must still have the ETag the preview showed.
+	Files []FileRef `json:"files,omitempty"`
+}
+
+// FileRef names a file in the state identified by its ETag.
+type FileRef struct {
+	Path string `json:"path"`
+	ETag string `json:"etag"`
+}
+
+// Edit is one changed line. Line is 1-based.
+type Edit struct {
+	Line   int    `json:"line"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// FileChange is what a replace changes in one file.
+type FileChange struct {
+	Path         string `json:"path"`
+	ETag         string `json:"etag"`
+	Replacements int    `json:"replacements"`
+	Edits        []Edit `json:"edits"`
+
+	abs    string
+	mode   fs.FileMode
+	before []byte
+	after  []byte
+}
+
+// Preview lists what a replace would change.
+type Preview struct {
+	Files        []FileChange `json:"files"`
+	Replacements int          `json:"replacements"`
+}
+
+// Undo holds the previous contents of the files a replace changed. Passing
+// it to Service.Undo puts them back, provided the files were not changed
+// again since.
+type Undo struct {
+	Files []UndoFile `json:"files"`
+}
+
+// UndoFile is one file of an Undo: the contents to restore and the ETag
+// the file must have for that.
+type UndoFile struct {
+	Path    string `json:"path"`
+	ETag    string `json:"etag"`
+	Content string `json:"content"`
+}
+
+// Result describes an applied replace; Files have their new ETags.
+type Result struct {
+	Files        []FileRef `json:"files"`
+	Replacements int       `json:"replacements"`
+	Undo         Undo      `json:"undo"`
+}
+
+// Preview computes the edits of req without changing any file.
+func (s *Service) Preview(ctx context.Context, dir string, req ReplaceRequest) (*Preview, error) {
+	changes, n, err := s.plan(ctx, dir, req)
+	if err != nil {
+		return nil, err
+	}
+	return &Preview{Files: changes, Replacements: n}, nil
+}
+
+// Replace applies req to workspace workspaceID, rooted at dir. Either
+// every file is changed or, if writing one fails, none is: the new
+// contents are all written beside their files first and only then moved
+// into place, and files already replaced are put back if a move fails.
+func (s *Service) Replace(ctx context.Context, workspaceID, dir string, req ReplaceRequest) (*Result, error) {
+	unlock := s.lockDir(dir)
+	defer unlock()
+	changes, n, err := s.plan(ctx, dir, req)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := s.commit(ctx, workspaceID, dir, changes)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Files: refs, Replacements: n, Undo: Undo{Files: make([]UndoFile, len(changes))}}
+	for i, c := range changes {
+		res.Undo.Files[i] = UndoFile{Path: c.Path, ETag: refs[i].ETag, Content: string(c.before)}
+	}
+	return res, nil
+}
+
+// Undo restores the files of u. It fails with ErrConflict, changing
+// nothing, if any of them was modified after the replace.
+func (s *Service) Undo(ctx context.Context, workspaceID, dir string, u Undo) ([]FileRef, error) {
+	fsys, err := files.New(dir)
+	if err != nil {
+		return nil, err
+	}
+	unlock := s.lockDir(dir)
+	defer unlock()
+	changes := make([]FileChange, 0, len(u.Files))
+	for _, f := range u.Files {
+		c, err := loadFile(fsys, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if c.ETag != f.ETag {
+			return nil, fmt.Errorf("%w: %s", ErrConflict, f.Path)
+		}
+		c.after = []byte(f.Content)
+		changes = append(changes, *c)
+	}
+	return s.commit(ctx, workspaceID, dir, changes)
+}
+
+// lockDir serializes replaces within one workspace.
+func (s *Service) lockDir(dir string) func() {
+	s.mu.Lock()
+	if s.replacing == nil {
+		s.replacing = make(map[string]chan struct{})
+	}
+	for {
+		ch, busy := s.replacing[dir]
+		if !busy {
+			break
+		}
+		s.mu.Unlock()
+		<-ch
+		s.mu.Lock()
+	}
+	ch := make(chan struct{})
+	s.replacing[dir] = ch
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		delete(s.replacing, dir)
+		s.mu.Unlock()
+		close(ch)
+	}
+}
+
+// loadFile reads a file to be changed.
+func loadFile(fsys *files.FS, p string) (*FileChange, error) {
+	abs, err := fsys.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	e, err := fsys.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if e.Type != files.TypeFile {
+		return nil, fmt.Errorf("%w: %s", files.ErrIsDir, p)
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	return &FileChange{Path: e.Path, ETag: e.ETag(), abs: abs, mode: fi.Mode().Perm(), before: data}, nil
+}
+
+// plan computes the changes of req and the number of replacements.
+func (s *Service) plan(ctx context.Context, dir string, req ReplaceRequest) ([]FileChange, int, error) {
+	re, err := compile(req.Query)
+	if err != nil {
+		return nil, 0, err
+	}
+	fsys, err := files.New(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var paths []string
+	expect := make(map[string]string)
+	if len(req.Files) > 0 {
+		for _, f := range req.Files {
+			p, err := files.Clean(f.Path)
+			if err != nil {
+				return nil, 0, err
+			}
+			paths = append(paths, p)
+			expect[p] = f.ETag
+		}
+	} else {
+		w, err := newWalker(dir, req.Include, req.Exclude, s.cfg.MaxFileBytes)
+		if err != nil {
+			return nil, 0, err
+		}
+		err = w.walk(ctx, func(rel, _ string) error {
+			paths = append(paths, rel)
+			return nil
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("search: walk: %w", err)
+		}
+	}
+
+	changes := []FileChange{}
+	total := 0
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		c, err := loadFile(fsys, p)
+		if errors.Is(err, fs.ErrNotExist) && len(expect) == 0 {
+			continue // removed since the walk
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if tag, ok := expect[c.Path]; ok && tag != c.ETag {
+			return nil, 0, fmt.Errorf("%w: %s", ErrConflict, c.Path)
+		}
+		// Binary and oversized files, and text that would not survive the
+		// undo bundle's JSON, are never changed.
+		if int64(len(c.before)) > s.cfg.MaxFileBytes || bytes.IndexByte(c.before, 0) >= 0 || !utf8.Valid(c.before) {
+			continue
+		}
+		n := replaceLines(c, re, req)
+		if n == 0 {
+			continue
+		}
+		if total += n; total > s.cfg.MaxResults {
+			return nil, 0, fmt.Errorf("%w: more than %d", ErrTooMany, s.cfg.MaxResults)
+		}
+		changes = append(changes, *c)
+	}
+	return changes, total, nil
+}
+
+// replaceLines fills in c.after and c.Edits and returns the number of
+// replacements. Like search, matching is line by line, so a pattern never
+// spans a line break.
+func replaceLines(c *FileChange, re *regexp.Regexp, req ReplaceRequest) int {
+	var out bytes.Buffer
+	total := 0
+	rest := c.before
+	for n := 1; len(rest) > 0; n++ {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+		body := bytes.TrimRight(line, "\r\n")
+		eol := line[len(body):]
+
+		locs := re.FindAllSubmatchIndex(body, -1)
+		if len(locs) == 0 {
+			out.Write(line)
+			continue
+		}
+		var next []byte
+		last := 0
+		for _, loc := range locs {
+			next = append(next, body[last:loc[0]]...)
+			if req.Regex {
+				next = re.Expand(next, []byte(req.Replacement), body, loc)
+			} else {
+				next = append(next, req.Replacement...)
+			}
+			last = loc[1]
+		}
+		next = append(next, body[last:]...)
+		if !bytes.Equal(next, body) {
+			total += len(locs)
+			c.Edits = append(c.Edits, Edit{Line: n, Before: string(body), After: string(next)})
+		}
+		out.Write(next)
+		out.Write(eol)
+	}
+	c.Replacements = total
+	c.after = out.Bytes()
+	return total
+}
+
+// commit writes the after contents of changes, all or nothing, and returns
+// the files with their new ETags.
+func (s *Service) commit(ctx context.Context, workspaceID, dir string, changes []FileChange) ([]FileRef, error) {
+	fsys, err := files.New(dir)
+	if err != nil {
+		return nil, err
+	}
+	temps := make([]string, 0, len(changes))
+	defer func() {
+		for _, t := range temps {
+			os.Remove(t)
+		}
+	}()
+	for _, c := range changes {
+		t, err := writeTemp(c.abs, c.mode, c.after)
+		if err != nil {
+			return nil, fmt.Errorf("search: write %s: %w", c.Path, err)
+		}
+		temps = append(temps, t)
+	}
+	for i, c := range changes {
+		if err := os.Rename(temps[i], c.abs); err != nil {
+			// Put back what was already replaced.
+			for _, done := range slices.Backward(changes[:i]) {
+				fsys.WriteFile(done.Path, done.before)
+			}
+			return nil, fmt.Errorf("search: replace %s: %w", c.Path, err)
+		}
+	}
+
+	refs := make([]FileRef, len(changes))
+	for i, c := range changes {
+		refs[i] = FileRef{Path: c.Path}
+		e, err := fsys.Stat(c.Path)
+		if err != nil {
+			continue
+		}
+		refs[i].ETag = e.ETag()
+		if h := s.cfg.History; h != nil && h.Records(e.Size) {
+			h.Saved(ctx, workspaceID, c.Path, c.after)
+		}
+	}
+	return refs, nil
+}
+
+// writeTemp writes data to a temporary file beside abs and returns its
+// name.
+func writeTemp(abs string, mode fs.FileMode, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(abs), files.TempPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,341 @@
+// Package search finds text and Go symbols across a workspace. Text search
+// walks the tree, skipping what .gitignore excludes, and matches each line
+// of every text file against a regular expression on several goroutines,
+// reporting matches as they are found. Symbol search parses Go files and
+// keeps their declarations cached until the files change.
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/archive"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Service.
+type Config struct {
+	// MaxFileBytes is the largest file searched; defaults to 1 MiB.
+	MaxFileBytes int64
+	// MaxResults caps the matches of one search; defaults to 2000.
+	MaxResults int
+	// Workers is how many files are searched at once; defaults to
+	// GOMAXPROCS.
+	Workers int
+	// History, if set, records the files changed by a replace.
+	History files.History
+}
+
+// ErrInvalidQuery is returned for an empty or malformed pattern.
+var ErrInvalidQuery = errors.New("search: invalid query")
+
+// maxLineBytes is how much of a matching line is returned.
+const maxLineBytes = 500
+
+// Query describes a text search.
+type Query struct {
+	Pattern string `json:"pattern"`
+	// Regex treats Pattern as an RE2 regular expression rather than a
+	// literal.
+	Regex         bool `json:"regex,omitempty"`
+	CaseSensitive bool `json:"caseSensitive,omitempty"`
+	// WholeWord only matches Pattern between word boundaries.
+	WholeWord bool `json:"wholeWord,omitempty"`
+	// Include restricts the search to files matching one of these
+	// .gitignore-style globs; Exclude skips files and directories
+	// matching any of them.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// MaxResults lowers Config.MaxResults for this search.
+	MaxResults int `json:"maxResults,omitempty"`
+}
+
+// Match is one matching line. Line is 1-based; Start and End are the byte
+// offsets of the first match within Text.
+type Match struct {
+	Path  string `json:"path"`
+	Line  int    `json:"line"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// Summary describes a finished search.
+type Summary struct {
+	Files   int `json:"files"`
+	Matches int `json:"matches"`
+	// Truncated is set when the search stopped at MaxResults.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Service searches workspaces.
+type Service struct {
+	cfg Config
+
+	mu        sync.Mutex
+	symbols   map[string]*fileSymbols  // absolute path
+	replacing map[string]chan struct{} // workspace dir; closed when done
+}
+
+// New returns a Service, filling unset Config fields with defaults.
+func New(cfg Config) *Service {
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = 1 << 20
+	}
+	if cfg.MaxResults <= 0 {
+		cfg.MaxResults = 2000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.GOMAXPROCS(0)
+	}
+	return &Service{cfg: cfg, symbols: make(map[string]*fileSymbols)}
+}
+
+// compile turns q into the regular expression matched against each line.
+func compile(q Query) (*regexp.Regexp, error) {
+	if q.Pattern == "" {
+		return nil, fmt.Errorf("%w: empty pattern", ErrInvalidQuery)
+	}
+	expr := q.Pattern
+	if !q.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if q.WholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if !q.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	return re, nil
+}
+
+// walker lists the files of a workspace that a search looks at.
+type walker struct {
+	root    string
+	ignore  *archive.Ignore
+	include *archive.Ignore // nil matches every file
+	exclude *archive.Ignore
+	maxSize int64
+}
+
+func newWalker(root string, include, exclude []string, maxSize int64) (*walker, error) {
+	w := &walker{root: root, maxSize: maxSize, exclude: &archive.Ignore{}}
+	gitignore, err := os.ReadFile(filepath.Join(root, ".gitignore"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	w.ignore = archive.ParseIgnore(".git/\nnode_modules/\n" + string(gitignore))
+	for _, g := range include {
+		if strings.TrimSpace(g) == "" {
+			continue
+		}
+		if w.include == nil {
+			w.include = &archive.Ignore{}
+		}
+		w.include.Add(g)
+	}
+	for _, g := range exclude {
+		w.exclude.Add(g)
+	}
+	return w, nil
+}
+
+// walk calls fn with the slash-separated and absolute paths of every
+// regular file to search, in lexical order.
+func (w *walker) walk(ctx context.Context, fn func(rel, abs string) error) error {
+	return filepath.WalkDir(w.root, func(abs string, de fs.DirEntry, err error) error {
+		if err != nil {
+			if abs == w.root {
+				return err
+			}
+			// Unreadable entries are skipped rather than failing the search.
+			if de != nil && de.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if abs == w.root {
+			return nil
+		}
+		rel, err := filepath.Rel(w.root, abs)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		skip := strings.HasPrefix(de.Name(), files.TempPrefix) ||
+			w.ignore.Match(rel, de.IsDir()) || w.exclude.Match(rel, de.IsDir())
+		if de.IsDir() {
+			if skip {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if skip || !de.Type().IsRegular() || (w.include != nil && !w.include.Match(rel, false)) {
+			return nil
+		}
+		if fi, err := de.Info(); err != nil || fi.Size() > w.maxSize {
+			return nil
+		}
+		return fn(rel, abs)
+	})
+}
+
+// errEnough stops a search that reached its result limit.
+var errEnough = errors.New("search: enough results")
+
+// Search finds the lines matching q in the workspace rooted at dir,
+// calling emit with each one as it is found. Files are searched
+// concurrently, so matches of different files may interleave; the
+// matches of one file are emitted together and in order. An error from
+// emit stops the search and is returned.
+func (s *Service) Search(ctx context.Context, dir string, q Query, emit func(Match) error) (Summary, error) {
+	re, err := compile(q)
+	if err != nil {
+		return Summary{}, err
+	}
+	w, err := newWalker(dir, q.Include, q.Exclude, s.cfg.MaxFileBytes)
+	if err != nil {
+		return Summary{}, err
+	}
+	limit := s.cfg.MaxResults
+	if q.MaxResults > 0 && q.MaxResults < limit {
+		limit = q.MaxResults
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	type job struct{ rel, abs string }
+	jobs := make(chan job)
+	var (
+		mu  sync.Mutex
+		sum Summary
+		wg  sync.WaitGroup
+	)
+	for range s.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				found, err := searchFile(j.abs, j.rel, re, limit)
+				if err != nil || ctx.Err() != nil {
+					continue
+				}
+				mu.Lock()
+				sum.Files++
+				for _, m := range found {
+					if sum.Matches == limit {
+						sum.Truncated = true
+						cancel(errEnough)
+						break
+					}
+					if err := emit(m); err != nil {
+						cancel(err)
+						break
+					}
+					sum.Matches++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	werr := w.walk(ctx, func(rel, abs string) error {
+		select {
+		case jobs <- job{rel, abs}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		if errors.Is(cause, errEnough) {
+			return sum, nil
+		}
+		return sum, cause
+	}
+	if werr != nil {
+		if ctx.Err() != nil {
+			return sum, ctx.Err()
+		}
+		return sum, fmt.Errorf("search: walk: %w", werr)
+	}
+	return sum, nil
+}
+
+// searchFile returns up to limit matching lines of a file. Files that
+// look binary, having a NUL byte near the start, yield none.
+func searchFile(abs, rel string, re *regexp.Regexp, limit int) ([]Match, error) {
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReaderSize(f, 8192)
+	if head, _ := br.Peek(8000); bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+	var out []Match
+	for n := 1; len(out) < limit; n++ {
+		line, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// A very long line is matched in full but reported truncated.
+			rest, rerr := br.ReadBytes('\n')
+			line, err = append(bytes.Clone(line), rest...), rerr
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if loc := re.FindIndex(line); loc != nil {
+			out = append(out, lineMatch(rel, n, line, loc))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// lineMatch builds a Match, cutting long lines down to maxLineBytes
+// around the match.
+func lineMatch(rel string, n int, line []byte, loc []int) Match {
+	start, end := 0, len(line)
+	if end > maxLineBytes {
+		start = max(0, loc[0]-maxLineBytes/4)
+		end = min(len(line), start+maxLineBytes)
+		// Keep whole UTF-8 sequences at both ends.
+		for start > 0 && start < len(line) && line[start]&0xC0 == 0x80 {
+			start--
+		}
+		for end < len(line) && line[end]&0xC0 == 0x80 {
+			end--
+		}
+	}
+	return Match{
+		Path:  rel,
+		Line:  n,
+		Start: max(loc[0]-start, 0),
+		End:   min(loc[1], end) - start,
+		Text:  strings.ToValidUTF8(string(line[start:end]), "�"),
+	}
+}
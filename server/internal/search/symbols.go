@@ -0,0 +1,291 @@
+package search
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SymbolKind classifies a Go declaration.
+type SymbolKind string
+
+const (
+	KindFunc      SymbolKind = "func"
+	KindMethod    SymbolKind = "method"
+	KindStruct    SymbolKind = "struct"
+	KindInterface SymbolKind = "interface"
+	KindType      SymbolKind = "type"
+	KindConst     SymbolKind = "const"
+	KindVar       SymbolKind = "var"
+)
+
+// defaultSymbols is how many symbols a search returns unless asked for
+// fewer.
+const defaultSymbols = 200
+
+// maxCachedFiles bounds the parsed files kept across all workspaces; the
+// cache starts over when it is full.
+const maxCachedFiles = 50000
+
+// SymbolQuery describes a symbol search.
+type SymbolQuery struct {
+	// Query is matched against symbol names, ignoring case: exact names
+	// rank first, then prefixes, substrings, and names whose capitals
+	// or leading letters spell it ("NR" or "newre" for NewReader).
+	Query string `json:"query"`
+	// Kinds restricts the search to these kinds; empty means all.
+	Kinds []SymbolKind `json:"kinds,omitempty"`
+	Limit int          `json:"limit,omitempty"`
+}
+
+// Symbol is a top-level Go declaration. Container is the receiver type of
+// a method. Line and Column are 1-based.
+type Symbol struct {
+	Name      string     `json:"name"`
+	Kind      SymbolKind `json:"kind"`
+	Container string     `json:"container,omitempty"`
+	Package   string     `json:"package"`
+	Path      string     `json:"path"`
+	Line      int        `json:"line"`
+	Column    int        `json:"column"`
+}
+
+// fileSymbols caches the declarations of one file while it is unchanged.
+type fileSymbols struct {
+	size    int64
+	modTime time.Time
+	symbols []Symbol
+}
+
+// Symbols finds the Go declarations in the workspace rooted at dir whose
+// names match q, best matches first. Files that fail to parse contribute
+// whatever declarations precede the error.
+func (s *Service) Symbols(ctx context.Context, dir string, q SymbolQuery) ([]Symbol, error) {
+	query := strings.ToLower(strings.TrimSpace(q.Query))
+	if query == "" {
+		return nil, fmt.Errorf("%w: empty query", ErrInvalidQuery)
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > defaultSymbols {
+		limit = defaultSymbols
+	}
+	w, err := newWalker(dir, []string{"*.go"}, nil, s.cfg.MaxFileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	type ranked struct {
+		Symbol
+		score int
+	}
+	var found []ranked
+	seen := make(map[string]bool)
+	err = w.walk(ctx, func(rel, abs string) error {
+		seen[abs] = true
+		for _, sym := range s.fileSymbols(rel, abs) {
+			if len(q.Kinds) > 0 && !slices.Contains(q.Kinds, sym.Kind) {
+				continue
+			}
+			if score, ok := matchName(sym.Name, query); ok {
+				found = append(found, ranked{sym, score})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("search: walk: %w", err)
+	}
+	s.forget(dir, seen)
+
+	slices.SortFunc(found, func(a, b ranked) int {
+		return cmp.Or(
+			cmp.Compare(a.score, b.score),
+			cmp.Compare(exportRank(a.Name), exportRank(b.Name)),
+			cmp.Compare(len(a.Name), len(b.Name)),
+			cmp.Compare(a.Path, b.Path),
+			cmp.Compare(a.Line, b.Line),
+		)
+	})
+	out := make([]Symbol, 0, min(len(found), limit))
+	for _, r := range found[:min(len(found), limit)] {
+		out = append(out, r.Symbol)
+	}
+	return out, nil
+}
+
+func exportRank(name string) int {
+	if ast.IsExported(name) {
+		return 0
+	}
+	return 1
+}
+
+// matchName scores how well name matches the lower-case query; lower is
+// better.
+func matchName(name, query string) (int, bool) {
+	lower := strings.ToLower(name)
+	switch {
+	case lower == query:
+		return 0, true
+	case strings.HasPrefix(lower, query):
+		return 1, true
+	case strings.Contains(lower, query):
+		return 2, true
+	case matchWords(name, query):
+		return 3, true
+	}
+	return 0, false
+}
+
+// matchWords reports whether query can be spelled by taking a prefix of
+// each of name's camel-case or underscore-separated words in turn.
+func matchWords(name, query string) bool {
+	var words []string
+	start := 0
+	rs := []rune(name)
+	for i := 1; i <= len(rs); i++ {
+		if i == len(rs) || rs[i] == '_' || (unicode.IsUpper(rs[i]) && !unicode.IsUpper(rs[i-1])) {
+			if w := strings.Trim(string(rs[start:i]), "_"); w != "" {
+				words = append(words, strings.ToLower(w))
+			}
+			start = i
+		}
+	}
+	var spell func(q string, ws []string) bool
+	spell = func(q string, ws []string) bool {
+		if q == "" {
+			return true
+		}
+		for i, w := range ws {
+			for n := min(len(w), len(q)); n > 0; n-- {
+				if w[:n] == q[:n] && spell(q[n:], ws[i+1:]) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return spell(query, words)
+}
+
+// fileSymbols returns the declarations of a file, parsing it only if it
+// changed since it was last seen.
+func (s *Service) fileSymbols(rel, abs string) []Symbol {
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return nil
+	}
+	s.mu.Lock()
+	c := s.symbols[abs]
+	s.mu.Unlock()
+	if c != nil && c.size == fi.Size() && c.modTime.Equal(fi.ModTime()) {
+		return c.symbols
+	}
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return nil
+	}
+	syms := parseSymbols(rel, src)
+	s.mu.Lock()
+	if len(s.symbols) >= maxCachedFiles {
+		clear(s.symbols)
+	}
+	s.symbols[abs] = &fileSymbols{size: fi.Size(), modTime: fi.ModTime(), symbols: syms}
+	s.mu.Unlock()
+	return syms
+}
+
+// forget drops cached files below dir that a walk no longer saw.
+func (s *Service) forget(dir string, seen map[string]bool) {
+	prefix := filepath.Clean(dir) + string(filepath.Separator)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for abs := range s.symbols {
+		if strings.HasPrefix(abs, prefix) && !seen[abs] {
+			delete(s.symbols, abs)
+		}
+	}
+}
+
+// parseSymbols lists the top-level declarations of a Go source file.
+func parseSymbols(rel string, src []byte) []Symbol {
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, rel, src, parser.SkipObjectResolution)
+	if f == nil || f.Name == nil {
+		return nil
+	}
+	var out []Symbol
+	add := func(id *ast.Ident, kind SymbolKind, container string) {
+		if id == nil || id.Name == "_" {
+			return
+		}
+		pos := fset.Position(id.Pos())
+		out = append(out, Symbol{
+			Name: id.Name, Kind: kind, Container: container, Package: f.Name.Name,
+			Path: rel, Line: pos.Line, Column: pos.Column,
+		})
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				add(d.Name, KindFunc, "")
+				continue
+			}
+			add(d.Name, KindMethod, receiverName(d.Recv.List[0].Type))
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					kind := KindType
+					switch sp.Type.(type) {
+					case *ast.StructType:
+						kind = KindStruct
+					case *ast.InterfaceType:
+						kind = KindInterface
+					}
+					add(sp.Name, kind, "")
+				case *ast.ValueSpec:
+					kind := KindVar
+					if d.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, id := range sp.Names {
+						add(id, kind, "")
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// receiverName returns the type name of a method receiver, without
+// pointer or type parameters.
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
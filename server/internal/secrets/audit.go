@@ -0,0 +1,147 @@
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+)
+
+// Audit actions.
+const (
+	ActionSet    = "set"
+	ActionDelete = "delete"
+	// ActionInject records secrets given to a process in a workspace.
+	ActionInject = "inject"
+)
+
+// Entry is one access to a scope's secrets.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Names  []string  `json:"names"`
+	User   string    `json:"user,omitempty"`
+	// Workspace and Via, for injections, are the workspace the process
+	// runs in and what kind of process it is: "terminal", "process" or
+	// "run".
+	Workspace string `json:"workspace,omitempty"`
+	Via       string `json:"via,omitempty"`
+}
+
+func (v *Vault) auditPath(sc Scope) string {
+	return filepath.Join(v.cfg.Dir, "audit", sc.key()+".jsonl")
+}
+
+// appendAudit adds e to the audit log of sc, rotating the log once it
+// reaches Config.MaxAuditBytes.
+func (v *Vault) appendAudit(sc Scope, e Entry) error {
+	e.Time = v.now().UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	v.auditMu.Lock()
+	defer v.auditMu.Unlock()
+	p := v.auditPath(sc)
+	if fi, err := os.Stat(p); err == nil && fi.Size() >= v.cfg.MaxAuditBytes {
+		if err := os.Rename(p, p+".1"); err != nil {
+			return fmt.Errorf("secrets: rotate audit log: %w", err)
+		}
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("secrets: write audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("secrets: write audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("secrets: write audit log: %w", err)
+	}
+	return nil
+}
+
+// record is appendAudit for changes that are already made, which a
+// failure to log does not undo.
+func (v *Vault) record(ctx context.Context, sc Scope, e Entry) {
+	if err := v.appendAudit(sc, e); err != nil {
+		slog.Error("secrets: audit", "scope", sc.key(), "action", e.Action, "err", err)
+	}
+	serverAudit(ctx, sc, e)
+}
+
+// serverActions name the vault's actions in the server's audit log.
+var serverActions = map[string]string{
+	ActionSet:    audit.ActionSecretSet,
+	ActionDelete: audit.ActionSecretDelete,
+	ActionInject: audit.ActionSecretInject,
+}
+
+// serverAudit adds e to the server's audit log as well as the scope's.
+func serverAudit(ctx context.Context, sc Scope, e Entry) {
+	ae := audit.Entry{Action: serverActions[e.Action], Target: strings.Join(e.Names, ","), Workspace: e.Workspace}
+	if sc.Kind == KindOrg {
+		ae.Org = sc.ID
+	} else {
+		ae.Workspace = sc.ID
+	}
+	if e.Via != "" {
+		ae.Details = map[string]string{"via": e.Via}
+	}
+	audit.Record(ctx, ae)
+}
+
+// Audit returns the last limit entries of the audit log of sc, newest
+// first.
+func (v *Vault) Audit(sc Scope, limit int) ([]Entry, error) {
+	if err := sc.valid(); err != nil {
+		return nil, err
+	}
+	v.auditMu.Lock()
+	defer v.auditMu.Unlock()
+	var entries []Entry
+	for _, p := range []string{v.auditPath(sc) + ".1", v.auditPath(sc)} {
+		if err := readAudit(p, &entries); err != nil {
+			return nil, err
+		}
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+func readAudit(p string, entries *[]Entry) error {
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("secrets: read audit log: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e Entry
+		// A line cut short by a crash is skipped.
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			*entries = append(*entries, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("secrets: read audit log: %w", err)
+	}
+	return nil
+}
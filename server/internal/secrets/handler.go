@@ -0,0 +1,224 @@
+package secrets
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+const (
+	defaultAuditEntries = 100
+	maxAuditEntries     = 1000
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the secret routes.
+type Handler struct {
+	vault      *Vault
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for vault.
+func NewHandler(vault *Vault, wm Workspaces) *Handler {
+	return &Handler{vault: vault, workspaces: wm}
+}
+
+// Register mounts the secret routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/secrets", h.listWorkspace)
+	mux.HandleFunc("PUT /api/workspaces/{id}/secrets/{name}", h.setWorkspace)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/secrets/{name}", h.deleteWorkspace)
+	mux.HandleFunc("GET /api/workspaces/{id}/secrets/audit", h.auditWorkspace)
+	mux.HandleFunc("GET /api/orgs/{org}/secrets", h.listOrg)
+	mux.HandleFunc("PUT /api/orgs/{org}/secrets/{name}", h.setOrg)
+	mux.HandleFunc("DELETE /api/orgs/{org}/secrets/{name}", h.deleteOrg)
+	mux.HandleFunc("GET /api/orgs/{org}/secrets/audit", h.auditOrg)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return id, true
+}
+
+// orgScope returns the scope of the organization in the path if the
+// caller is a member, and an admin when admin is set.
+func (h *Handler) orgScope(w http.ResponseWriter, r *http.Request, admin bool) (Scope, bool) {
+	id := r.PathValue("org")
+	role, err := h.vault.OrgRole(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return Scope{}, false
+	}
+	if admin && role != org.RoleAdmin {
+		writeError(w, ErrForbidden)
+		return Scope{}, false
+	}
+	return Org(id), true
+}
+
+// listWorkspace returns a workspace's secrets and, as inherited, those of
+// the organization that owns it.
+func (h *Handler) listWorkspace(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	own, err := h.vault.List(Workspace(id))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	orgID, err := h.vault.WorkspaceOrg(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	inherited := []Secret{}
+	if orgID != "" {
+		if inherited, err = h.vault.List(Org(orgID)); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"secrets": own, "org": orgID, "inherited": inherited})
+}
+
+type setRequest struct {
+	Value string `json:"value"`
+}
+
+func (h *Handler) set(w http.ResponseWriter, r *http.Request, sc Scope) {
+	var req setRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s, err := h.vault.Set(r.Context(), sc, r.PathValue("name"), req.Value)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, s)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, sc Scope) {
+	if err := h.vault.Delete(r.Context(), sc, r.PathValue("name")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) audit(w http.ResponseWriter, r *http.Request, sc Scope) {
+	limit := defaultAuditEntries
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxAuditEntries {
+			httpx.Errorf(w, http.StatusBadRequest, "limit must be between 1 and %d", maxAuditEntries)
+			return
+		}
+		limit = n
+	}
+	entries, err := h.vault.Audit(sc, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	e := audit.Entry{Action: audit.ActionSecretAudit, Workspace: sc.ID}
+	if sc.Kind == KindOrg {
+		e = audit.Entry{Action: audit.ActionSecretAudit, Org: sc.ID}
+	}
+	audit.Record(r.Context(), e)
+	if entries == nil {
+		entries = []Entry{}
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"entries": entries})
+}
+
+func (h *Handler) setWorkspace(w http.ResponseWriter, r *http.Request) {
+	if id, ok := h.workspace(w, r); ok {
+		h.set(w, r, Workspace(id))
+	}
+}
+
+func (h *Handler) deleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	if id, ok := h.workspace(w, r); ok {
+		h.delete(w, r, Workspace(id))
+	}
+}
+
+// auditWorkspace serves a workspace's audit log to its owners.
+func (h *Handler) auditWorkspace(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	if workspace.RoleFrom(r.Context()) != workspace.RoleOwner {
+		writeError(w, ErrForbidden)
+		return
+	}
+	h.audit(w, r, Workspace(id))
+}
+
+func (h *Handler) listOrg(w http.ResponseWriter, r *http.Request) {
+	sc, ok := h.orgScope(w, r, false)
+	if !ok {
+		return
+	}
+	list, err := h.vault.List(sc)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"secrets": list})
+}
+
+func (h *Handler) setOrg(w http.ResponseWriter, r *http.Request) {
+	if sc, ok := h.orgScope(w, r, true); ok {
+		h.set(w, r, sc)
+	}
+}
+
+func (h *Handler) deleteOrg(w http.ResponseWriter, r *http.Request) {
+	if sc, ok := h.orgScope(w, r, true); ok {
+		h.delete(w, r, sc)
+	}
+}
+
+func (h *Handler) auditOrg(w http.ResponseWriter, r *http.Request) {
+	if sc, ok := h.orgScope(w, r, true); ok {
+		h.audit(w, r, sc)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidValue), errors.Is(err, workspace.ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrForbidden):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrNotFound), errors.Is(err, org.ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("secrets", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "secret request failed")
+	}
+}
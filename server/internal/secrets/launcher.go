@@ -0,0 +1,44 @@
+package secrets
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+)
+
+// launcher adds secrets to the commands of another Launcher.
+type launcher struct {
+	vault *Vault
+	next  terminal.Launcher
+}
+
+// Launcher returns a terminal.Launcher that runs commands with l, with
+// the secrets of their workspace added to their environment. The
+// environment callers pass takes precedence; whatever l itself adds, such
+// as plain workspace variables, comes before the secrets and so gives way
+// to them.
+func Launcher(v *Vault, l terminal.Launcher) terminal.Launcher {
+	return &launcher{vault: v, next: l}
+}
+
+// Command implements terminal.Launcher.
+func (l *launcher) Command(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error) {
+	secrets, err := l.vault.Environ(ctx, workspaceID, "terminal")
+	if err != nil {
+		return nil, err
+	}
+	return l.next.Command(ctx, workspaceID, dir, argv, append(secrets, env...))
+}
+
+// Exec implements terminal.Launcher.
+func (l *launcher) Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error) {
+	secrets, err := l.vault.Environ(ctx, workspaceID, "process")
+	if err != nil {
+		return nil, err
+	}
+	return l.next.Exec(ctx, workspaceID, dir, argv, append(secrets, env...))
+}
+
+// Root implements terminal.Launcher.
+func (l *launcher) Root(dir string) string { return l.next.Root(dir) }
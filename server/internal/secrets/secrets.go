@@ -0,0 +1,622 @@
+// Package secrets is a vault for the credentials programs in workspaces
+// need, such as API tokens and deploy keys. A secret belongs to a
+// workspace or to an organization, whose secrets every workspace it owns
+// gets.
+//
+// Secrets are write-only: they can be set, replaced and deleted, and their
+// names listed, but their values are never returned. Values only leave the
+// vault as environment variables of the processes started in a workspace.
+//
+// Each value is encrypted with AES-256-GCM under a data key of its own,
+// and the data key under the vault's master key, so the master key can be
+// rotated by re-encrypting data keys alone. Every change and every
+// injection into a workspace is recorded in an audit log.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/org"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Config configures a Vault.
+type Config struct {
+	// Dir holds the secrets and their audit logs; defaults to a directory
+	// under the OS temp dir.
+	Dir string
+	// Key is the master key. When empty, a random key is generated and
+	// kept in Dir, which protects secrets in copies of the secret files
+	// but not of the whole directory.
+	Key []byte
+	// PreviousKeys are master keys Key replaced. Data keys encrypted with
+	// one of them are re-encrypted with Key the next time their scope is
+	// loaded.
+	PreviousKeys [][]byte
+	// MaxSecrets limits the secrets per workspace and per organization;
+	// defaults to 100.
+	MaxSecrets int
+	// MaxValueBytes limits one value; defaults to 64 KiB.
+	MaxValueBytes int
+	// MaxAuditBytes is the size at which an audit log is rotated; one
+	// rotated log is kept. Defaults to 4 MiB.
+	MaxAuditBytes int64
+}
+
+// Owners reports the owner of a workspace, "org:<id>" for organization
+// workspaces.
+type Owners interface {
+	Owner(id string) (string, error)
+}
+
+// Roles reports a user's role on a workspace, "" for none.
+type Roles interface {
+	Role(workspaceID, userID string) (workspace.Role, error)
+}
+
+// Orgs reports a user's membership of an organization.
+type Orgs interface {
+	Get(id, userID string) (*org.Org, string, error)
+}
+
+var (
+	// ErrInvalidName is returned for names that are not valid variable
+	// names.
+	ErrInvalidName = errors.New("secrets: invalid secret name")
+	// ErrInvalidValue is returned for values processes cannot be given.
+	ErrInvalidValue = errors.New("secrets: invalid value")
+	// ErrNotFound is returned for secrets that do not exist.
+	ErrNotFound = errors.New("secrets: secret not found")
+	// ErrTooLarge is returned for values over Config.MaxValueBytes.
+	ErrTooLarge = errors.New("secrets: value too large")
+	// ErrTooMany is returned when a scope would have more than
+	// Config.MaxSecrets secrets.
+	ErrTooMany = errors.New("secrets: too many secrets")
+	// ErrForbidden is returned when the caller may not manage a scope's
+	// secrets or read its audit log.
+	ErrForbidden = errors.New("secrets: not allowed")
+	// ErrUnknownKey is returned for secrets whose data key was encrypted
+	// with a master key the vault no longer has.
+	ErrUnknownKey = errors.New("secrets: encrypted with an unknown master key")
+)
+
+// Scope kinds.
+const (
+	KindWorkspace = "workspace"
+	KindOrg       = "org"
+)
+
+// Scope is what secrets belong to: a workspace or an organization.
+type Scope struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// Workspace returns the scope of workspace id.
+func Workspace(id string) Scope { return Scope{Kind: KindWorkspace, ID: id} }
+
+// Org returns the scope of organization id.
+func Org(id string) Scope { return Scope{Kind: KindOrg, ID: id} }
+
+func (sc Scope) key() string { return sc.Kind + "-" + sc.ID }
+
+func (sc Scope) valid() error {
+	switch sc.Kind {
+	case KindWorkspace, KindOrg:
+	default:
+		return fmt.Errorf("secrets: unknown scope %q", sc.Kind)
+	}
+	if !workspace.ValidID(sc.ID) {
+		return workspace.ErrInvalidID
+	}
+	return nil
+}
+
+var nameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+
+// Secret describes a secret. Its value is never returned.
+type Secret struct {
+	Name string `json:"name"`
+	// Version counts the times the secret was set.
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+}
+
+// record is a Secret as kept on disk.
+type record struct {
+	Secret
+	// DataKey is the secret's data key, encrypted with the master key
+	// KeyID names.
+	DataKey string `json:"dataKey"`
+	KeyID   string `json:"keyId"`
+	// Value is the value, encrypted with the data key.
+	Value string `json:"value"`
+}
+
+// masterKey is a master key and the ID recorded with the data keys it
+// encrypts.
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Vault keeps secrets.
+type Vault struct {
+	cfg      Config
+	key      masterKey
+	previous []masterKey
+	owners   Owners
+	roles    Roles
+	orgs     Orgs
+	now      func() time.Time
+
+	mu      sync.Mutex
+	scopes  map[string][]record // Scope.key(); loaded on first use, sorted by name
+	auditMu sync.Mutex
+}
+
+// New returns a Vault, filling unset Config fields with defaults. roles,
+// when set, limits RunEnviron to the editors of a workspace; orgs, when
+// set, gives organization admins their organization's secrets to manage.
+func New(cfg Config, owners Owners, roles Roles, orgs Orgs) (*Vault, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-secrets")
+	}
+	if cfg.MaxSecrets <= 0 {
+		cfg.MaxSecrets = 100
+	}
+	if cfg.MaxValueBytes <= 0 {
+		cfg.MaxValueBytes = 64 << 10
+	}
+	if cfg.MaxAuditBytes <= 0 {
+		cfg.MaxAuditBytes = 4 << 20
+	}
+	for _, d := range []string{KindWorkspace, KindOrg, "audit"} {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, d), 0o700); err != nil {
+			return nil, fmt.Errorf("secrets: create dir: %w", err)
+		}
+	}
+	raw := cfg.Key
+	if len(raw) == 0 {
+		var err error
+		if raw, err = loadKey(filepath.Join(cfg.Dir, "master.key")); err != nil {
+			return nil, err
+		}
+	}
+	key, err := newMasterKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	v := &Vault{
+		cfg:    cfg,
+		key:    key,
+		owners: owners,
+		roles:  roles,
+		orgs:   orgs,
+		now:    time.Now,
+		scopes: make(map[string][]record),
+	}
+	for _, raw := range cfg.PreviousKeys {
+		k, err := newMasterKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		v.previous = append(v.previous, k)
+	}
+	return v, nil
+}
+
+func newMasterKey(raw []byte) (masterKey, error) {
+	sum := sha256.Sum256(raw)
+	aead, err := newAEAD(sum[:])
+	if err != nil {
+		return masterKey{}, err
+	}
+	// The ID is a hash of the derived key, so it reveals nothing usable.
+	id := sha256.Sum256(sum[:])
+	return masterKey{id: hex.EncodeToString(id[:6]), aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadKey reads the key at p, generating it on first use.
+func loadKey(p string) ([]byte, error) {
+	data, err := os.ReadFile(p)
+	if err == nil && len(data) >= 32 {
+		return data, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("secrets: read master key: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p, key, 0o600); err != nil {
+		return nil, fmt.Errorf("secrets: write master key: %w", err)
+	}
+	return key, nil
+}
+
+// ad binds ciphertexts to their secret, so none can be moved to another.
+func ad(sc Scope, name, part string) []byte {
+	return []byte(sc.Kind + "\x00" + sc.ID + "\x00" + name + "\x00" + part)
+}
+
+func seal(aead cipher.AEAD, plain, ad []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, ad)), nil
+}
+
+func open(aead cipher.AEAD, sealed string, ad []byte) ([]byte, error) {
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, data[:n], data[n:], ad)
+}
+
+// encrypt returns r with value encrypted under a new data key.
+func (v *Vault) encrypt(sc Scope, r record, value string) (record, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return record{}, err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return record{}, err
+	}
+	if r.Value, err = seal(aead, []byte(value), ad(sc, r.Name, "value")); err != nil {
+		return record{}, err
+	}
+	if r.DataKey, err = seal(v.key.aead, dek, ad(sc, r.Name, "key")); err != nil {
+		return record{}, err
+	}
+	r.KeyID = v.key.id
+	return r, nil
+}
+
+// dataKey decrypts the data key of r.
+func (v *Vault) dataKey(sc Scope, r record) ([]byte, error) {
+	k, ok := v.key, r.KeyID == v.key.id
+	for _, p := range v.previous {
+		if !ok && p.id == r.KeyID {
+			k, ok = p, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, r.Name)
+	}
+	dek, err := open(k.aead, r.DataKey, ad(sc, r.Name, "key"))
+	if err != nil {
+		return nil, fmt.Errorf("secrets: decrypt %s: %w", r.Name, err)
+	}
+	return dek, nil
+}
+
+func (v *Vault) decrypt(sc Scope, r record) (string, error) {
+	dek, err := v.dataKey(sc, r)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, r.Value, ad(sc, r.Name, "value"))
+	if err != nil {
+		return "", fmt.Errorf("secrets: decrypt %s: %w", r.Name, err)
+	}
+	return string(plain), nil
+}
+
+// List returns the secrets of a scope by name.
+func (v *Vault) List(sc Scope) ([]Secret, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	recs, err := v.loadLocked(sc)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Secret, len(recs))
+	for i, r := range recs {
+		out[i] = r.Secret
+	}
+	return out, nil
+}
+
+// WorkspaceOrg returns the organization that owns a workspace, "" for
+// none.
+func (v *Vault) WorkspaceOrg(workspaceID string) (string, error) {
+	owner, err := v.owners.Owner(workspaceID)
+	if err != nil {
+		return "", err
+	}
+	id, ok := strings.CutPrefix(owner, org.OwnerPrefix)
+	if !ok {
+		return "", nil
+	}
+	return id, nil
+}
+
+// Set creates or replaces a secret. The caller in ctx is recorded as
+// having set it.
+func (v *Vault) Set(ctx context.Context, sc Scope, name, value string) (*Secret, error) {
+	if err := sc.valid(); err != nil {
+		return nil, err
+	}
+	if !nameRE.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	if len(value) > v.cfg.MaxValueBytes {
+		return nil, fmt.Errorf("%w: %s is over %d bytes", ErrTooLarge, name, v.cfg.MaxValueBytes)
+	}
+	if strings.IndexByte(value, 0) >= 0 {
+		return nil, fmt.Errorf("%w: %s contains a NUL byte", ErrInvalidValue, name)
+	}
+	user := userID(ctx)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	recs, err := v.loadLocked(sc)
+	if err != nil {
+		return nil, err
+	}
+	now := v.now().UTC()
+	r := record{Secret: Secret{Name: name, Version: 1, CreatedAt: now, UpdatedAt: now, UpdatedBy: user}}
+	i, found := slices.BinarySearchFunc(recs, name, func(e record, name string) int { return strings.Compare(e.Name, name) })
+	if found {
+		r.Version = recs[i].Version + 1
+		r.CreatedAt = recs[i].CreatedAt
+	} else if len(recs) >= v.cfg.MaxSecrets {
+		return nil, fmt.Errorf("%w: at most %d", ErrTooMany, v.cfg.MaxSecrets)
+	}
+	if r, err = v.encrypt(sc, r, value); err != nil {
+		return nil, err
+	}
+	next := slices.Clone(recs)
+	if found {
+		next[i] = r
+	} else {
+		next = slices.Insert(next, i, r)
+	}
+	if err := v.storeLocked(sc, next); err != nil {
+		return nil, err
+	}
+	v.record(ctx, sc, Entry{Action: ActionSet, Names: []string{name}, User: user})
+	out := r.Secret
+	return &out, nil
+}
+
+// Delete removes a secret.
+func (v *Vault) Delete(ctx context.Context, sc Scope, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	recs, err := v.loadLocked(sc)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(recs, func(r record) bool { return r.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err := v.storeLocked(sc, slices.Delete(slices.Clone(recs), i, i+1)); err != nil {
+		return err
+	}
+	v.record(ctx, sc, Entry{Action: ActionDelete, Names: []string{name}, User: userID(ctx)})
+	return nil
+}
+
+// Environ returns the secrets of a workspace and of the organization that
+// owns it as KEY=value, for a process the caller in ctx starts there; via
+// says what kind of process it is for the audit log. A workspace secret
+// replaces an organization secret of the same name.
+//
+// The injection is recorded in the audit log of each scope whose secrets
+// it uses, and no secrets are returned if that fails.
+func (v *Vault) Environ(ctx context.Context, workspaceID, via string) ([]string, error) {
+	orgID, err := v.WorkspaceOrg(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []Scope{Workspace(workspaceID)}
+	if orgID != "" {
+		scopes = []Scope{Org(orgID), Workspace(workspaceID)}
+	}
+	values := make(map[string]string)
+	from := make(map[string]Scope)
+	v.mu.Lock()
+	for _, sc := range scopes {
+		recs, err := v.loadLocked(sc)
+		if err != nil {
+			v.mu.Unlock()
+			return nil, err
+		}
+		for _, r := range recs {
+			value, err := v.decrypt(sc, r)
+			if err != nil {
+				v.mu.Unlock()
+				return nil, err
+			}
+			values[r.Name], from[r.Name] = value, sc
+		}
+	}
+	v.mu.Unlock()
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	user := userID(ctx)
+	for _, sc := range scopes {
+		var used []string
+		for _, name := range names {
+			if from[name] == sc {
+				used = append(used, name)
+			}
+		}
+		if len(used) == 0 {
+			continue
+		}
+		e := Entry{Action: ActionInject, Names: used, User: user, Workspace: workspaceID, Via: via}
+		if err := v.appendAudit(sc, e); err != nil {
+			return nil, err
+		}
+		serverAudit(ctx, sc, e)
+	}
+	env := make([]string, len(names))
+	for i, name := range names {
+		env[i] = name + "=" + values[name]
+	}
+	return env, nil
+}
+
+// RunEnviron is Environ for requests that name a workspace without being
+// routed to it, such as runs, so their caller's access has not been
+// checked. Users without a role on the workspace get workspace.ErrNotFound
+// and viewers no secrets.
+func (v *Vault) RunEnviron(ctx context.Context, workspaceID string) ([]string, error) {
+	if v.roles != nil {
+		u := auth.UserFrom(ctx)
+		if u == nil {
+			return nil, workspace.ErrNotFound
+		}
+		role, err := v.roles.Role(workspaceID, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		switch role {
+		case "":
+			return nil, workspace.ErrNotFound
+		case workspace.RoleViewer:
+			return nil, nil
+		}
+	}
+	return v.Environ(ctx, workspaceID, "run")
+}
+
+// OrgRole returns the caller's role in organization id, org.RoleAdmin or
+// org.RoleMember. Callers who are not members get org.ErrNotFound.
+func (v *Vault) OrgRole(ctx context.Context, id string) (string, error) {
+	u := auth.UserFrom(ctx)
+	if v.orgs == nil || u == nil {
+		return "", fmt.Errorf("%w: no organization %s", org.ErrNotFound, id)
+	}
+	_, role, err := v.orgs.Get(id, u.ID)
+	return role, err
+}
+
+func userID(ctx context.Context) string {
+	if u := auth.UserFrom(ctx); u != nil {
+		return u.ID
+	}
+	return ""
+}
+
+func (v *Vault) path(sc Scope) string {
+	return filepath.Join(v.cfg.Dir, sc.Kind, sc.ID+".json")
+}
+
+// loadLocked returns the secrets of a scope, re-encrypting data keys
+// that use a previous master key.
+func (v *Vault) loadLocked(sc Scope) ([]record, error) {
+	if err := sc.valid(); err != nil {
+		return nil, err
+	}
+	if recs, ok := v.scopes[sc.key()]; ok {
+		return recs, nil
+	}
+	var recs []record
+	data, err := os.ReadFile(v.path(sc))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("secrets: load: %w", err)
+	default:
+		if err := json.Unmarshal(data, &recs); err != nil {
+			return nil, fmt.Errorf("secrets: load: %w", err)
+		}
+	}
+	rotated := false
+	for i, r := range recs {
+		if r.KeyID == v.key.id {
+			continue
+		}
+		dek, err := v.dataKey(sc, r)
+		if err != nil {
+			// Left as it is; using the secret reports the error.
+			slog.Warn("secrets: cannot re-encrypt data key", "scope", sc.key(), "name", r.Name, "err", err)
+			continue
+		}
+		if recs[i].DataKey, err = seal(v.key.aead, dek, ad(sc, r.Name, "key")); err != nil {
+			return nil, err
+		}
+		recs[i].KeyID = v.key.id
+		rotated = true
+	}
+	if rotated {
+		if err := v.storeLocked(sc, recs); err != nil {
+			return nil, err
+		}
+	}
+	v.scopes[sc.key()] = recs
+	return recs, nil
+}
+
+func (v *Vault) storeLocked(sc Scope, recs []record) error {
+	data, err := json.MarshalIndent(recs, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(v.path(sc))
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("secrets: save: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("secrets: save: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("secrets: save: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.path(sc)); err != nil {
+		return fmt.Errorf("secrets: save: %w", err)
+	}
+	v.scopes[sc.key()] = recs
+	return nil
+}
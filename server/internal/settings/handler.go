@@ -0,0 +1,269 @@
+package settings
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Handler serves the settings API of the signed-in user.
+type Handler struct {
+	store *Store
+}
+
+// NewHandler returns a Handler for store.
+func NewHandler(store *Store) *Handler {
+	return &Handler{store: store}
+}
+
+// Register mounts the settings routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/settings", h.get)
+	mux.HandleFunc("PUT /api/settings", h.put)
+	mux.HandleFunc("PATCH /api/settings", h.patch)
+	mux.HandleFunc("GET /api/settings/effective", h.effective)
+	mux.HandleFunc("GET /api/settings/versions", h.versions)
+	mux.HandleFunc("GET /api/settings/versions/{version}", h.version)
+	mux.HandleFunc("POST /api/settings/versions/{version}/restore", h.restore)
+	mux.HandleFunc("GET /api/settings/devices", h.devices)
+	mux.HandleFunc("GET /api/settings/devices/{device}", h.getDevice)
+	mux.HandleFunc("PUT /api/settings/devices/{device}", h.putDevice)
+	mux.HandleFunc("PATCH /api/settings/devices/{device}", h.patchDevice)
+	mux.HandleFunc("DELETE /api/settings/devices/{device}", h.deleteDevice)
+}
+
+func (h *Handler) user(w http.ResponseWriter, r *http.Request) (*auth.User, bool) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "authentication required")
+		return nil, false
+	}
+	return u, true
+}
+
+// body reads a settings document or merge patch.
+func body(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxDocument))
+	if err != nil {
+		httpx.Errorf(w, http.StatusRequestEntityTooLarge, "settings are up to %d bytes", MaxDocument)
+		return nil, false
+	}
+	return data, true
+}
+
+// writeDocument answers with d and its ETag, or with 304 when the
+// request's If-None-Match already has it.
+func writeDocument(w http.ResponseWriter, r *http.Request, status int, d *Document) {
+	w.Header().Set("ETag", d.ETag())
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Method == http.MethodGet && d.Version > 0 && r.Header.Get("If-None-Match") == d.ETag() {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	httpx.JSON(w, status, d)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.store.Get(u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeDocument(w, r, http.StatusOK, d)
+}
+
+// put replaces the settings. ?device= notes which device the change was
+// made from.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	data, ok := body(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.store.Put(u.ID, data, r.Header.Get("If-Match"), r.URL.Query().Get("device"))
+	writeResult(w, r, d, err)
+}
+
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	data, ok := body(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.store.Patch(u.ID, data, r.Header.Get("If-Match"), r.URL.Query().Get("device"))
+	writeResult(w, r, d, err)
+}
+
+// writeResult answers a write. A conflict carries the current version's
+// ETag so that the client can fetch it and merge.
+func writeResult(w http.ResponseWriter, r *http.Request, d *Document, err error) {
+	if errors.Is(err, ErrConflict) {
+		w.Header().Set("ETag", d.ETag())
+		httpx.Error(w, http.StatusPreconditionFailed, "settings changed since they were read")
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeDocument(w, r, http.StatusOK, d)
+}
+
+// effective returns the settings ?device= should use.
+func (h *Handler) effective(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	eff, err := h.store.Effective(u.ID, r.URL.Query().Get("device"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	httpx.JSON(w, http.StatusOK, eff)
+}
+
+func (h *Handler) versions(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	vs, err := h.store.Versions(u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, vs)
+}
+
+func (h *Handler) lookup(w http.ResponseWriter, r *http.Request, userID string) (*Document, bool) {
+	n, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || n < 1 {
+		httpx.Error(w, http.StatusBadRequest, "version must be a positive number")
+		return nil, false
+	}
+	d, err := h.store.Version(userID, n)
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return d, true
+}
+
+func (h *Handler) version(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	if d, ok := h.lookup(w, r, u.ID); ok {
+		httpx.JSON(w, http.StatusOK, d)
+	}
+}
+
+// restore puts an earlier version back, as a new version.
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	old, ok := h.lookup(w, r, u.ID)
+	if !ok {
+		return
+	}
+	d, err := h.store.Put(u.ID, old.Settings, r.Header.Get("If-Match"), r.URL.Query().Get("device"))
+	writeResult(w, r, d, err)
+}
+
+func (h *Handler) devices(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	ds, err := h.store.Devices(u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, ds)
+}
+
+func (h *Handler) getDevice(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.store.Device(u.ID, r.PathValue("device"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeDocument(w, r, http.StatusOK, d)
+}
+
+func (h *Handler) putDevice(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	data, ok := body(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.store.PutDevice(u.ID, r.PathValue("device"), data, r.Header.Get("If-Match"))
+	writeResult(w, r, d, err)
+}
+
+func (h *Handler) patchDevice(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	data, ok := body(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.store.PatchDevice(u.ID, r.PathValue("device"), data, r.Header.Get("If-Match"))
+	writeResult(w, r, d, err)
+}
+
+func (h *Handler) deleteDevice(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteDevice(u.ID, r.PathValue("device")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("settings", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "settings request failed")
+	}
+}
@@ -0,0 +1,238 @@
+// Package settings keeps users' editor preferences on the server, so that
+// they follow the user from one browser or machine to the next.
+//
+// A user has one settings document, plus an override document for each
+// device that needs something different, such as a larger font on a
+// laptop. Overrides are JSON merge patches (RFC 7386) over the settings:
+// the effective settings of a device are the settings with its override
+// applied, and null in an override removes a setting on that device.
+//
+// Every change bumps the document's version, which is its ETag; writes
+// with a stale If-Match fail instead of overwriting a change made on
+// another device. The last Config.MaxVersions versions of the settings are
+// kept so that an earlier one can be looked up and put back.
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+)
+
+// Settings are a user's preferences. Every field is optional; clients use
+// their defaults for what is not set.
+type Settings struct {
+	Editor      *Editor      `json:"editor,omitempty"`
+	Keybindings *Keybindings `json:"keybindings,omitempty"`
+	Run         *Run         `json:"run,omitempty"`
+	// Extensions holds settings of the client's own, by name, which the
+	// server stores without interpreting.
+	Extensions map[string]json.RawMessage `json:"extensions,omitempty"`
+}
+
+// Editor is how the editor looks and behaves.
+type Editor struct {
+	Theme        string `json:"theme,omitempty"`
+	FontFamily   string `json:"fontFamily,omitempty"`
+	FontSize     int    `json:"fontSize,omitempty"`
+	LineHeight   int    `json:"lineHeight,omitempty"`
+	TabSize      int    `json:"tabSize,omitempty"`
+	InsertSpaces *bool  `json:"insertSpaces,omitempty"`
+	WordWrap     *bool  `json:"wordWrap,omitempty"`
+	Minimap      *bool  `json:"minimap,omitempty"`
+	LineNumbers  string `json:"lineNumbers,omitempty"`
+	FormatOnSave *bool  `json:"formatOnSave,omitempty"`
+	// AutoSave is "off", "afterDelay" or "onFocusChange".
+	AutoSave string `json:"autoSave,omitempty"`
+}
+
+// Keybindings are the editor's key bindings.
+type Keybindings struct {
+	// Preset is "default", "vim" or "emacs".
+	Preset string `json:"preset,omitempty"`
+	// Custom bindings apply on top of the preset, later ones first.
+	Custom []Keybinding `json:"custom,omitempty"`
+}
+
+// Keybinding binds a key chord to an editor command, optionally only
+// where the when clause holds. A command starting with "-" removes the
+// preset's binding of it.
+type Keybinding struct {
+	Key     string `json:"key"`
+	Command string `json:"command"`
+	When    string `json:"when,omitempty"`
+}
+
+// Run are the defaults of the run button, in the terms of runner.Request.
+type Run struct {
+	Language  string              `json:"language,omitempty"`
+	GoVersion string              `json:"goVersion,omitempty"`
+	Output    runner.OutputMode   `json:"output,omitempty"`
+	Options   runner.BuildOptions `json:"options,omitempty"`
+	Limits    runner.Limits       `json:"limits,omitempty"`
+	// Args are passed to the program.
+	Args []string `json:"args,omitempty"`
+}
+
+// Limits on settings documents.
+const (
+	// MaxDocument caps a settings or override document in bytes.
+	MaxDocument = 64 << 10
+	// MaxKeybindings caps Keybindings.Custom.
+	MaxKeybindings = 500
+)
+
+var (
+	// ErrInvalid is returned for settings that do not validate.
+	ErrInvalid = errors.New("settings: invalid settings")
+	// ErrNotFound is returned for unknown devices and versions.
+	ErrNotFound = errors.New("settings: not found")
+	// ErrConflict is returned for writes whose If-Match is not the current
+	// version.
+	ErrConflict = errors.New("settings: changed since it was read")
+	// ErrTooMany is returned when a user has Config.MaxDevices overrides.
+	ErrTooMany = errors.New("settings: too many devices")
+)
+
+var (
+	validDevice = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	validName   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+)
+
+// validate checks what a settings document holds. doc must be a JSON
+// object.
+func validate(doc json.RawMessage) error {
+	if len(doc) > MaxDocument {
+		return fmt.Errorf("%w: more than %d bytes", ErrInvalid, MaxDocument)
+	}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	var s Settings
+	if err := dec.Decode(&s); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return s.validate()
+}
+
+func (s *Settings) validate() error {
+	if e := s.Editor; e != nil {
+		switch {
+		case len(e.Theme) > 64 || len(e.FontFamily) > 200:
+			return fmt.Errorf("%w: editor theme or font family too long", ErrInvalid)
+		case e.FontSize != 0 && (e.FontSize < 6 || e.FontSize > 72):
+			return fmt.Errorf("%w: editor.fontSize must be 6 to 72", ErrInvalid)
+		case e.LineHeight != 0 && (e.LineHeight < 8 || e.LineHeight > 150):
+			return fmt.Errorf("%w: editor.lineHeight must be 8 to 150", ErrInvalid)
+		case e.TabSize != 0 && (e.TabSize < 1 || e.TabSize > 16):
+			return fmt.Errorf("%w: editor.tabSize must be 1 to 16", ErrInvalid)
+		case !oneOf(e.LineNumbers, "on", "off", "relative"):
+			return fmt.Errorf("%w: editor.lineNumbers must be on, off or relative", ErrInvalid)
+		case !oneOf(e.AutoSave, "off", "afterDelay", "onFocusChange"):
+			return fmt.Errorf("%w: editor.autoSave must be off, afterDelay or onFocusChange", ErrInvalid)
+		}
+	}
+	if k := s.Keybindings; k != nil {
+		if !oneOf(k.Preset, "default", "vim", "emacs") {
+			return fmt.Errorf("%w: keybindings.preset must be default, vim or emacs", ErrInvalid)
+		}
+		if len(k.Custom) > MaxKeybindings {
+			return fmt.Errorf("%w: more than %d custom keybindings", ErrInvalid, MaxKeybindings)
+		}
+		for i, b := range k.Custom {
+			if b.Key == "" || b.Command == "" || len(b.Key) > 100 || len(b.Command) > 200 || len(b.When) > 500 {
+				return fmt.Errorf("%w: keybinding %d needs a key and a command", ErrInvalid, i)
+			}
+		}
+	}
+	if r := s.Run; r != nil {
+		switch {
+		case len(r.Language) > 32 || len(r.GoVersion) > 32:
+			return fmt.Errorf("%w: run language or Go version too long", ErrInvalid)
+		case !oneOf(string(r.Output), string(runner.OutputRaw), string(runner.OutputPlain), string(runner.OutputHTML)):
+			return fmt.Errorf("%w: run.output must be raw, plain or html", ErrInvalid)
+		case r.Limits.CPUs < 0 || r.Limits.MemoryMB < 0 || r.Limits.TimeoutMS < 0 || r.Limits.MaxProcs < 0 || r.Limits.OutputBytes < 0:
+			return fmt.Errorf("%w: run limits must not be negative", ErrInvalid)
+		case len(r.Args) > 100:
+			return fmt.Errorf("%w: more than 100 run arguments", ErrInvalid)
+		}
+	}
+	for name := range s.Extensions {
+		if !validName.MatchString(name) {
+			return fmt.Errorf("%w: extension name %q", ErrInvalid, name)
+		}
+	}
+	return nil
+}
+
+// oneOf reports whether v is empty or one of allowed.
+func oneOf(v string, allowed ...string) bool {
+	return v == "" || slices.Contains(allowed, v)
+}
+
+// object checks that doc is a JSON object and returns it compacted, with
+// "{}" for an empty document.
+func object(doc []byte) (json.RawMessage, error) {
+	doc = bytes.TrimSpace(doc)
+	if len(doc) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &m); err != nil || m == nil {
+		return nil, fmt.Errorf("%w: settings must be a JSON object", ErrInvalid)
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// mergePatch applies the JSON merge patch patch to doc, both JSON
+// objects, as RFC 7386 describes.
+func mergePatch(doc, patch json.RawMessage) (json.RawMessage, error) {
+	var d, p any
+	if err := unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+	if err := unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	out, err := json.Marshal(merge(d, p))
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func unmarshal(data []byte, v *any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return nil
+}
+
+func merge(doc, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]any)
+	if !ok {
+		d = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = merge(d[k], v)
+	}
+	return d
+}
@@ -0,0 +1,408 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir holds one file per user; defaults to a directory under the OS
+	// temp dir.
+	Dir string
+	// MaxVersions is how many past versions of the settings are kept;
+	// defaults to 20.
+	MaxVersions int
+	// MaxDevices caps a user's device overrides; defaults to 20.
+	MaxDevices int
+}
+
+// schema is the version of the layout of users' files.
+const schema = 1
+
+// Document is a settings or override document at one version.
+type Document struct {
+	// Version is 0 for a document never written.
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Device is the device of an override, or the device a change of the
+	// settings was made from.
+	Device   string          `json:"device,omitempty"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+}
+
+// ETag returns the validator of the document's version.
+func (d *Document) ETag() string { return fmt.Sprintf(`"%d"`, d.Version) }
+
+// Effective are the settings of a device, with its override applied.
+type Effective struct {
+	Settings json.RawMessage `json:"settings"`
+	// Version is the version of the settings, and DeviceVersion that of
+	// the device's override, 0 without one.
+	Version       int    `json:"version"`
+	Device        string `json:"device,omitempty"`
+	DeviceVersion int    `json:"deviceVersion"`
+}
+
+// record is what a user's file holds.
+type record struct {
+	Schema   int                  `json:"schema"`
+	Settings Document             `json:"settings"`
+	Devices  map[string]*Document `json:"devices,omitempty"`
+	// History holds past versions of the settings, oldest first.
+	History []Document `json:"history,omitempty"`
+}
+
+// Store keeps the settings of all users.
+type Store struct {
+	cfg Config
+	now func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewStore returns a Store, filling unset Config fields with defaults.
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-settings")
+	}
+	if cfg.MaxVersions <= 0 {
+		cfg.MaxVersions = 20
+	}
+	if cfg.MaxDevices <= 0 {
+		cfg.MaxDevices = 20
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("settings: create dir: %w", err)
+	}
+	return &Store{cfg: cfg, now: time.Now}, nil
+}
+
+// Get returns the user's settings.
+func (s *Store) Get(userID string) (*Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Settings, nil
+}
+
+// Put replaces the user's settings with doc, a JSON object, made from
+// device, which may be "". ifMatch, when set, must be the ETag of the
+// current version, or "*" for settings that have been written.
+func (s *Store) Put(userID string, doc []byte, ifMatch, device string) (*Document, error) {
+	next, err := object(doc)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateSettings(userID, ifMatch, device, func(json.RawMessage) (json.RawMessage, error) { return next, nil })
+}
+
+// Patch applies the JSON merge patch patch to the user's settings, as
+// Put does.
+func (s *Store) Patch(userID string, patch []byte, ifMatch, device string) (*Document, error) {
+	p, err := object(patch)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateSettings(userID, ifMatch, device, func(cur json.RawMessage) (json.RawMessage, error) {
+		return mergePatch(cur, p)
+	})
+}
+
+func (s *Store) updateSettings(userID, ifMatch, device string, change func(json.RawMessage) (json.RawMessage, error)) (*Document, error) {
+	if device != "" && !validDevice.MatchString(device) {
+		return nil, fmt.Errorf("%w: device must be 1 to 64 letters, digits, dots, dashes or underscores", ErrInvalid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !matches(&rec.Settings, ifMatch) {
+		return &rec.Settings, ErrConflict
+	}
+	next, err := change(rec.Settings.Settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(next); err != nil {
+		return nil, err
+	}
+	if rec.Settings.Version > 0 {
+		rec.History = append(rec.History, rec.Settings)
+		if n := len(rec.History) - s.cfg.MaxVersions; n > 0 {
+			rec.History = slices.Delete(rec.History, 0, n)
+		}
+	}
+	rec.Settings = Document{
+		Version:   rec.Settings.Version + 1,
+		UpdatedAt: s.now().UTC(),
+		Device:    device,
+		Settings:  next,
+	}
+	if err := s.save(userID, rec); err != nil {
+		return nil, err
+	}
+	return &rec.Settings, nil
+}
+
+// Versions returns the versions of the user's settings that are kept,
+// newest first, without their contents.
+func (s *Store) Versions(userID string) ([]Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := []Document{}
+	if rec.Settings.Version > 0 {
+		out = append(out, rec.Settings)
+	}
+	for i := len(rec.History) - 1; i >= 0; i-- {
+		out = append(out, rec.History[i])
+	}
+	for i := range out {
+		out[i].Settings = nil
+	}
+	return out, nil
+}
+
+// Version returns version n of the user's settings, if it is kept.
+func (s *Store) Version(userID string, n int) (*Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	if n == rec.Settings.Version && n > 0 {
+		return &rec.Settings, nil
+	}
+	i := slices.IndexFunc(rec.History, func(d Document) bool { return d.Version == n })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: version %d", ErrNotFound, n)
+	}
+	return &rec.History[i], nil
+}
+
+// Devices returns the user's device overrides, most recently changed
+// first, without their contents.
+func (s *Store) Devices(userID string) ([]Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Document, 0, len(rec.Devices))
+	for _, d := range rec.Devices {
+		c := *d
+		c.Settings = nil
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b Document) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return out, nil
+}
+
+// Device returns the override of device, at version 0 and empty when it
+// has none.
+func (s *Store) Device(userID, device string) (*Document, error) {
+	if !validDevice.MatchString(device) {
+		return nil, fmt.Errorf("%w: device %q", ErrNotFound, device)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	return deviceDoc(rec, device), nil
+}
+
+// PutDevice replaces the override of device with doc, a JSON merge patch
+// over the settings. ifMatch is as for Put.
+func (s *Store) PutDevice(userID, device string, doc []byte, ifMatch string) (*Document, error) {
+	next, err := object(doc)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateDevice(userID, device, ifMatch, func(json.RawMessage) (json.RawMessage, error) { return next, nil })
+}
+
+// PatchDevice applies the JSON merge patch patch to the override of
+// device. A null in patch removes the setting from the override, so that
+// the device goes back to the user's settings for it.
+func (s *Store) PatchDevice(userID, device string, patch []byte, ifMatch string) (*Document, error) {
+	p, err := object(patch)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateDevice(userID, device, ifMatch, func(cur json.RawMessage) (json.RawMessage, error) {
+		return mergePatch(cur, p)
+	})
+}
+
+func (s *Store) updateDevice(userID, device, ifMatch string, change func(json.RawMessage) (json.RawMessage, error)) (*Document, error) {
+	if !validDevice.MatchString(device) {
+		return nil, fmt.Errorf("%w: device must be 1 to 64 letters, digits, dots, dashes or underscores", ErrInvalid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	cur := deviceDoc(rec, device)
+	if !matches(cur, ifMatch) {
+		return cur, ErrConflict
+	}
+	if cur.Version == 0 && len(rec.Devices) >= s.cfg.MaxDevices {
+		return nil, fmt.Errorf("%w: %d devices", ErrTooMany, len(rec.Devices))
+	}
+	next, err := change(cur.Settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(next); err != nil {
+		return nil, err
+	}
+	if rec.Devices == nil {
+		rec.Devices = make(map[string]*Document)
+	}
+	d := &Document{Version: cur.Version + 1, UpdatedAt: s.now().UTC(), Device: device, Settings: next}
+	rec.Devices[device] = d
+	if err := s.save(userID, rec); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// DeleteDevice removes the override of device.
+func (s *Store) DeleteDevice(userID, device string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return err
+	}
+	if _, ok := rec.Devices[device]; !ok {
+		return fmt.Errorf("%w: device %q", ErrNotFound, device)
+	}
+	delete(rec.Devices, device)
+	return s.save(userID, rec)
+}
+
+// Effective returns the settings of device with its override applied, or
+// the user's settings when device is "".
+func (s *Store) Effective(userID, device string) (*Effective, error) {
+	if device != "" && !validDevice.MatchString(device) {
+		return nil, fmt.Errorf("%w: device must be 1 to 64 letters, digits, dots, dashes or underscores", ErrInvalid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	eff := &Effective{Settings: rec.Settings.Settings, Version: rec.Settings.Version, Device: device}
+	if d := rec.Devices[device]; d != nil {
+		merged, err := mergePatch(rec.Settings.Settings, d.Settings)
+		if err != nil {
+			return nil, err
+		}
+		eff.Settings, eff.DeviceVersion = merged, d.Version
+	}
+	return eff, nil
+}
+
+// Delete removes everything kept for the user, such as when the account
+// is deleted.
+func (s *Store) Delete(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.file(userID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func deviceDoc(rec *record, device string) *Document {
+	if d := rec.Devices[device]; d != nil {
+		return d
+	}
+	return &Document{Device: device, Settings: json.RawMessage("{}")}
+}
+
+// matches reports whether ifMatch, an If-Match header, allows writing
+// over d.
+func matches(d *Document, ifMatch string) bool {
+	switch ifMatch {
+	case "":
+		return true
+	case "*":
+		return d.Version > 0
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == d.ETag() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) file(userID string) string {
+	return filepath.Join(s.cfg.Dir, userID+".json")
+}
+
+// load reads a user's file. s.mu must be held.
+func (s *Store) load(userID string) (*record, error) {
+	if userID == "" || strings.ContainsAny(userID, `/\`) || strings.HasPrefix(userID, ".") {
+		return nil, fmt.Errorf("%w: user %q", ErrInvalid, userID)
+	}
+	rec := &record{Schema: schema, Settings: Document{Settings: json.RawMessage("{}")}}
+	data, err := os.ReadFile(s.file(userID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return rec, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("settings: read %s: %w", userID, err)
+	}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, fmt.Errorf("settings: read %s: %w", userID, err)
+	}
+	if rec.Schema > schema {
+		return nil, fmt.Errorf("settings: %s has schema %d, newer than this server's %d", userID, rec.Schema, schema)
+	}
+	return rec, nil
+}
+
+// save writes a user's file. s.mu must be held.
+func (s *Store) save(userID string, rec *record) error {
+	rec.Schema = schema
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp := s.file(userID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("settings: write %s: %w", userID, err)
+	}
+	if err := os.Rename(tmp, s.file(userID)); err != nil {
+		return fmt.Errorf("settings: write %s: %w", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Directories resolves and creates workspace directories.
+type Directories interface {
+	Open(id string) (string, error)
+	Create(ctx context.Context, id string) (string, error)
+}
+
+// Handler serves the snapshot routes.
+type Handler struct {
+	svc        *Service
+	workspaces Directories
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Directories) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the snapshot routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/snapshots", h.list)
+	mux.HandleFunc("POST /api/workspaces/{id}/snapshots", h.create)
+	mux.HandleFunc("GET /api/workspaces/{id}/snapshots/{snap}", h.get)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/snapshots/{snap}", h.remove)
+	mux.HandleFunc("POST /api/workspaces/{id}/snapshots/{snap}/restore", h.restore)
+	mux.HandleFunc("POST /api/workspaces/{id}/snapshots/{snap}/fork", h.fork)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (id, dir string, ok bool) {
+	id = r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", false
+	}
+	return id, dir, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	snaps, err := h.svc.List(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"snapshots": snaps})
+}
+
+type createRequest struct {
+	Label string `json:"label,omitempty"`
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var req createRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if len(req.Label) > 200 {
+		httpx.Error(w, http.StatusBadRequest, "label is longer than 200 bytes")
+		return
+	}
+	sn, err := h.svc.Create(r.Context(), id, dir, TriggerManual, req.Label)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, sn)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	sn, err := h.svc.Get(id, r.PathValue("snap"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, sn)
+}
+
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(id, r.PathValue("snap")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restore rolls the workspace back and returns the snapshot taken of it
+// just before, for undoing the restore.
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	snap := r.PathValue("snap")
+	backup, err := h.svc.Restore(r.Context(), id, dir, snap)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"restored": snap, "backup": backup})
+}
+
+type forkRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+// fork creates a new workspace from a snapshot. As with POST
+// /api/workspaces, the body is optional and without an ID a random one is
+// assigned.
+func (h *Handler) fork(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var req forkRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	snap := r.PathValue("snap")
+	if _, err := h.svc.Get(id, snap); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.ID == "" {
+		req.ID = workspace.NewID()
+	}
+	dir, err := h.workspaces.Create(r.Context(), req.ID)
+	switch {
+	case errors.Is(err, workspace.ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, workspace.ErrExists):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("create workspace", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not create workspace")
+		return
+	}
+	sn, err := h.svc.Extract(id, snap, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, map[string]any{"id": req.ID, "snapshot": sn})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "snapshot not found")
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("snapshot", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "snapshot failed")
+	}
+}
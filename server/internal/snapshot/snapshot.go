@@ -0,0 +1,720 @@
+// Package snapshot keeps restore points of workspaces. A snapshot is an
+// immutable copy of a workspace's file tree, taken on demand or on a
+// schedule. A workspace can be rolled back to any of its snapshots, and a
+// new workspace forked from one, so a mass deletion or a bad refactor is
+// never more than a restore away.
+//
+// File contents are stored once per workspace, named by their SHA-256, so
+// a snapshot of a mostly unchanged tree costs little more than its
+// manifest. Files whose size and modification time match the previous
+// snapshot are not read again. A scheduled snapshot, or one taken as the
+// server shuts down, is skipped when nothing changed since the last one.
+package snapshot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the snapshots, one subdirectory per workspace; defaults to
+	// a directory under the OS temp dir.
+	Dir string
+	// Interval is how often every workspace is snapshotted; defaults to an
+	// hour. Negative disables scheduled snapshots.
+	Interval time.Duration
+	// Keep is how many automatic snapshots, scheduled, taken at shutdown,
+	// before a restore or by jobs, are kept per workspace; defaults to 24.
+	// Older ones are deleted.
+	Keep int
+	// MaxManual caps the snapshots users take per workspace; defaults to
+	// 50.
+	MaxManual int
+	// MaxBytes and MaxFiles bound the tree a snapshot copies; they default
+	// to 256 MiB and 20000 entries, like exports.
+	MaxBytes int64
+	MaxFiles int
+}
+
+// Trigger is what took a snapshot.
+type Trigger string
+
+const (
+	TriggerManual    Trigger = "manual"
+	TriggerScheduled Trigger = "scheduled"
+	// TriggerRestore marks the snapshot taken of a workspace just before
+	// it is restored, so a restore can be undone.
+	TriggerRestore Trigger = "restore"
+	// TriggerShutdown marks the snapshots taken of the workspaces in use
+	// when the server shuts down.
+	TriggerShutdown Trigger = "shutdown"
+	// TriggerJob marks the snapshots taken by scheduled jobs.
+	TriggerJob Trigger = "job"
+)
+
+var (
+	// ErrNotFound is returned for snapshots that do not exist.
+	ErrNotFound = errors.New("snapshot: not found")
+	// ErrTooLarge is returned when a tree exceeds Config.MaxBytes or
+	// Config.MaxFiles.
+	ErrTooLarge = errors.New("snapshot: workspace too large")
+	// ErrTooMany is returned when a workspace is at Config.MaxManual.
+	ErrTooMany = errors.New("snapshot: too many snapshots")
+	// ErrUnchanged is returned for scheduled, shutdown and job snapshots
+	// of trees that did not change.
+	ErrUnchanged = errors.New("snapshot: unchanged")
+)
+
+// Snapshot describes a restore point. Entries is only filled by Get.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Workspace string    `json:"workspace"`
+	CreatedAt time.Time `json:"createdAt"`
+	Trigger   Trigger   `json:"trigger"`
+	Label     string    `json:"label,omitempty"`
+	Files     int       `json:"files"`
+	Bytes     int64     `json:"bytes"`
+	Entries   []Entry   `json:"entries,omitempty"`
+}
+
+// Entry is a file or directory in a snapshot.
+type Entry struct {
+	Path    string    `json:"path"`
+	Dir     bool      `json:"dir,omitempty"`
+	Exec    bool      `json:"exec,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// Workspaces lists and resolves workspaces.
+type Workspaces interface {
+	List() ([]string, error)
+	Open(id string) (string, error)
+}
+
+// Service takes, lists and restores snapshots.
+type Service struct {
+	cfg        Config
+	workspaces Workspaces
+	now        func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a Service, filling unset Config fields with defaults, and
+// starts taking scheduled snapshots of ws.
+func New(cfg Config, ws Workspaces) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-snapshots")
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = 24
+	}
+	if cfg.MaxManual <= 0 {
+		cfg.MaxManual = 50
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 256 << 20
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = 20000
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("snapshot: create dir: %w", err)
+	}
+	s := &Service{
+		cfg:        cfg,
+		workspaces: ws,
+		now:        time.Now,
+		locks:      make(map[string]*sync.Mutex),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go s.loop()
+	return s, nil
+}
+
+// Close stops taking scheduled snapshots.
+func (s *Service) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Service) loop() {
+	defer close(s.done)
+	if s.cfg.Interval < 0 {
+		return
+	}
+	t := time.NewTicker(s.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			s.scheduled()
+		}
+	}
+}
+
+// scheduled snapshots every workspace that changed since its last
+// snapshot.
+func (s *Service) scheduled() {
+	ids, err := s.workspaces.List()
+	if err != nil {
+		slog.Warn("list workspaces for snapshots", "err", err)
+		return
+	}
+	for _, id := range ids {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		s.auto(context.Background(), id, TriggerScheduled)
+	}
+}
+
+// Shutdown snapshots each of the workspaces ids that changed since its
+// last snapshot, until ctx is done, and returns how many it took. The
+// server calls it for the workspaces in use as it shuts down.
+func (s *Service) Shutdown(ctx context.Context, ids []string) int {
+	n := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		if s.auto(ctx, id, TriggerShutdown) {
+			n++
+		}
+	}
+	return n
+}
+
+// auto takes an automatic snapshot of a workspace, unless it is
+// unchanged, and reports whether it did.
+func (s *Service) auto(ctx context.Context, id string, trigger Trigger) bool {
+	dir, err := s.workspaces.Open(id)
+	if err != nil {
+		return false
+	}
+	_, err = s.Create(ctx, id, dir, trigger, "")
+	if err != nil && !errors.Is(err, ErrUnchanged) {
+		slog.Warn("automatic snapshot", "trigger", trigger, "workspace", id, "err", err)
+	}
+	return err == nil
+}
+
+// lock serializes the operations on one workspace's snapshots.
+func (s *Service) lock(workspaceID string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[workspaceID]
+	if !ok {
+		l = new(sync.Mutex)
+		s.locks[workspaceID] = l
+	}
+	s.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// Create snapshots the workspace at dir.
+func (s *Service) Create(ctx context.Context, workspaceID, dir string, trigger Trigger, label string) (*Snapshot, error) {
+	defer s.lock(workspaceID)()
+	return s.createLocked(ctx, workspaceID, dir, trigger, label)
+}
+
+func (s *Service) createLocked(ctx context.Context, workspaceID, dir string, trigger Trigger, label string) (*Snapshot, error) {
+	all, err := s.listLocked(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if trigger == TriggerManual {
+		n := 0
+		for _, sn := range all {
+			if sn.Trigger == TriggerManual {
+				n++
+			}
+		}
+		if n >= s.cfg.MaxManual {
+			return nil, fmt.Errorf("%w: %d manual snapshots", ErrTooMany, n)
+		}
+	}
+	var prev *Snapshot
+	if len(all) > 0 {
+		if prev, err = s.loadLocked(workspaceID, all[0].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := s.capture(ctx, workspaceID, dir, prev)
+	if err != nil {
+		return nil, err
+	}
+	if (trigger == TriggerScheduled || trigger == TriggerShutdown || trigger == TriggerJob) && prev != nil && sameTree(prev.Entries, entries) {
+		return nil, ErrUnchanged
+	}
+	sn := &Snapshot{
+		ID:        newID(),
+		Workspace: workspaceID,
+		CreatedAt: s.now().UTC(),
+		Trigger:   trigger,
+		Label:     label,
+		Entries:   entries,
+	}
+	for _, e := range entries {
+		if !e.Dir {
+			sn.Files++
+			sn.Bytes += e.Size
+		}
+	}
+	if err := s.writeManifest(sn); err != nil {
+		return nil, err
+	}
+	if trigger != TriggerManual {
+		s.pruneLocked(workspaceID, append(all, *sn))
+	}
+	summary := *sn
+	summary.Entries = nil
+	return &summary, nil
+}
+
+// capture copies the files of dir into the object store and returns the
+// tree's entries. Files unchanged since prev reuse its hashes.
+func (s *Service) capture(ctx context.Context, workspaceID, dir string, prev *Snapshot) ([]Entry, error) {
+	known := make(map[string]Entry)
+	if prev != nil {
+		for _, e := range prev.Entries {
+			known[e.Path] = e
+		}
+	}
+	var entries []Entry
+	var total int64
+	err := filepath.WalkDir(dir, func(abs string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if abs == dir {
+			return nil
+		}
+		if strings.HasPrefix(de.Name(), files.TempPrefix) || (!de.IsDir() && !de.Type().IsRegular()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			return err
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return err
+		}
+		if len(entries) >= s.cfg.MaxFiles {
+			return fmt.Errorf("%w: more than %d entries", ErrTooLarge, s.cfg.MaxFiles)
+		}
+		e := Entry{Path: filepath.ToSlash(rel), Dir: de.IsDir(), ModTime: fi.ModTime().UTC()}
+		if !e.Dir {
+			if total += fi.Size(); total > s.cfg.MaxBytes {
+				return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, s.cfg.MaxBytes)
+			}
+			e.Size, e.Exec = fi.Size(), fi.Mode()&0o111 != 0
+			if k, ok := known[e.Path]; ok && !k.Dir && k.Size == e.Size && k.ModTime.Equal(e.ModTime) && s.hasObject(workspaceID, k.Hash) {
+				e.Hash = k.Hash
+			} else if e.Hash, err = s.store(workspaceID, abs); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// sameTree reports whether two snapshots hold the same files.
+func sameTree(a, b []Entry) bool {
+	return slices.EqualFunc(a, b, func(x, y Entry) bool {
+		return x.Path == y.Path && x.Dir == y.Dir && x.Exec == y.Exec && x.Hash == y.Hash
+	})
+}
+
+func (s *Service) objectPath(workspaceID, hash string) string {
+	return filepath.Join(s.cfg.Dir, workspaceID, "objects", hash[:2], hash)
+}
+
+func (s *Service) hasObject(workspaceID, hash string) bool {
+	if len(hash) < 2 {
+		return false
+	}
+	_, err := os.Stat(s.objectPath(workspaceID, hash))
+	return err == nil
+}
+
+// store copies the file at src into the object store and returns its
+// hash.
+func (s *Service) store(workspaceID, src string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+	defer in.Close()
+	objects := filepath.Join(s.cfg.Dir, workspaceID, "objects")
+	if err := os.MkdirAll(objects, 0o700); err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+	tmp, err := os.CreateTemp(objects, ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("snapshot: copy %s: %w", filepath.Base(src), err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	dst := s.objectPath(workspaceID, hash)
+	if _, err := os.Stat(dst); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o444); err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+	return hash, nil
+}
+
+func (s *Service) manifestDir(workspaceID string) string {
+	return filepath.Join(s.cfg.Dir, workspaceID, "snapshots")
+}
+
+func (s *Service) writeManifest(sn *Snapshot) error {
+	data, err := json.Marshal(sn)
+	if err != nil {
+		return err
+	}
+	dir := s.manifestDir(sn.Workspace)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("snapshot: write manifest: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("snapshot: write manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("snapshot: write manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("snapshot: write manifest: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o444); err != nil {
+		return fmt.Errorf("snapshot: write manifest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, sn.ID+".json")); err != nil {
+		return fmt.Errorf("snapshot: write manifest: %w", err)
+	}
+	return nil
+}
+
+// List returns the snapshots of a workspace, newest first, without their
+// entries.
+func (s *Service) List(workspaceID string) ([]Snapshot, error) {
+	defer s.lock(workspaceID)()
+	return s.listLocked(workspaceID)
+}
+
+func (s *Service) listLocked(workspaceID string) ([]Snapshot, error) {
+	des, err := os.ReadDir(s.manifestDir(workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: list: %w", err)
+	}
+	out := make([]Snapshot, 0, len(des))
+	for _, de := range des {
+		id, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok || strings.HasPrefix(id, ".") {
+			continue
+		}
+		sn, err := s.loadLocked(workspaceID, id)
+		if err != nil {
+			return nil, err
+		}
+		sn.Entries = nil
+		out = append(out, *sn)
+	}
+	slices.SortFunc(out, func(a, b Snapshot) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+// Get returns a snapshot with its entries.
+func (s *Service) Get(workspaceID, id string) (*Snapshot, error) {
+	defer s.lock(workspaceID)()
+	return s.loadLocked(workspaceID, id)
+}
+
+func (s *Service) loadLocked(workspaceID, id string) (*Snapshot, error) {
+	if !validID(id) {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, id)
+	}
+	data, err := os.ReadFile(filepath.Join(s.manifestDir(workspaceID), id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: read %s: %w", id, err)
+	}
+	var sn Snapshot
+	if err := json.Unmarshal(data, &sn); err != nil {
+		return nil, fmt.Errorf("snapshot: read %s: %w", id, err)
+	}
+	return &sn, nil
+}
+
+// Delete removes a snapshot and the file contents only it held.
+func (s *Service) Delete(workspaceID, id string) error {
+	defer s.lock(workspaceID)()
+	if _, err := s.loadLocked(workspaceID, id); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.manifestDir(workspaceID), id+".json")); err != nil {
+		return fmt.Errorf("snapshot: delete %s: %w", id, err)
+	}
+	s.collectLocked(workspaceID)
+	return nil
+}
+
+// pruneLocked deletes the automatic snapshots beyond Config.Keep.
+func (s *Service) pruneLocked(workspaceID string, all []Snapshot) {
+	slices.SortFunc(all, func(a, b Snapshot) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	kept, pruned := 0, false
+	for _, sn := range all {
+		if sn.Trigger == TriggerManual {
+			continue
+		}
+		if kept++; kept <= s.cfg.Keep {
+			continue
+		}
+		if os.Remove(filepath.Join(s.manifestDir(workspaceID), sn.ID+".json")) == nil {
+			pruned = true
+		}
+	}
+	if pruned {
+		s.collectLocked(workspaceID)
+	}
+}
+
+// collectLocked deletes the objects no snapshot refers to.
+func (s *Service) collectLocked(workspaceID string) {
+	all, err := s.listLocked(workspaceID)
+	if err != nil {
+		slog.Warn("collect snapshot objects", "workspace", workspaceID, "err", err)
+		return
+	}
+	used := make(map[string]bool)
+	for _, sum := range all {
+		sn, err := s.loadLocked(workspaceID, sum.ID)
+		if err != nil {
+			slog.Warn("collect snapshot objects", "workspace", workspaceID, "err", err)
+			return
+		}
+		for _, e := range sn.Entries {
+			used[e.Hash] = true
+		}
+	}
+	objects := filepath.Join(s.cfg.Dir, workspaceID, "objects")
+	filepath.WalkDir(objects, func(p string, de fs.DirEntry, err error) error {
+		if err == nil && !de.IsDir() && !strings.HasPrefix(de.Name(), ".") && !used[de.Name()] {
+			os.Remove(p)
+		}
+		return nil
+	})
+}
+
+// Restore makes the workspace at dir match snapshot id: files are
+// rewritten as they were, and files created since are deleted. The tree
+// is snapshotted first, and that snapshot is returned so the restore can
+// be undone.
+func (s *Service) Restore(ctx context.Context, workspaceID, dir, id string) (*Snapshot, error) {
+	defer s.lock(workspaceID)()
+	sn, err := s.loadLocked(workspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := s.createLocked(ctx, workspaceID, dir, TriggerRestore, "before restoring "+id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.extract(workspaceID, sn, dir, true); err != nil {
+		return backup, err
+	}
+	return backup, nil
+}
+
+// Extract writes the files of snapshot id into dir, which should be
+// empty, as when forking a workspace.
+func (s *Service) Extract(workspaceID, id, dir string) (*Snapshot, error) {
+	defer s.lock(workspaceID)()
+	sn, err := s.loadLocked(workspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.extract(workspaceID, sn, dir, false); err != nil {
+		return nil, err
+	}
+	sn.Entries = nil
+	return sn, nil
+}
+
+// extract writes sn into dir. With prune, what sn does not hold is
+// deleted first, including a file where sn has a directory or the
+// reverse.
+func (s *Service) extract(workspaceID string, sn *Snapshot, dir string, prune bool) error {
+	want := make(map[string]Entry, len(sn.Entries))
+	for _, e := range sn.Entries {
+		want[e.Path] = e
+	}
+	if prune {
+		err := filepath.WalkDir(dir, func(abs string, de fs.DirEntry, err error) error {
+			if err != nil || abs == dir || strings.HasPrefix(de.Name(), files.TempPrefix) {
+				return err
+			}
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				return err
+			}
+			if e, ok := want[filepath.ToSlash(rel)]; ok && e.Dir == de.IsDir() {
+				return nil
+			}
+			if err := os.RemoveAll(abs); err != nil {
+				return fmt.Errorf("snapshot: restore: %w", err)
+			}
+			if de.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// Entries are in walk order, so directories come before their files.
+	for _, e := range sn.Entries {
+		if _, err := files.Clean(e.Path); err != nil {
+			return fmt.Errorf("snapshot: restore %s: %w", e.Path, err)
+		}
+		abs := filepath.Join(dir, filepath.FromSlash(e.Path))
+		if e.Dir {
+			if err := os.MkdirAll(abs, 0o755); err != nil {
+				return fmt.Errorf("snapshot: restore: %w", err)
+			}
+			continue
+		}
+		if fi, err := os.Stat(abs); err == nil && fi.Size() == e.Size && fi.ModTime().Equal(e.ModTime) {
+			continue
+		}
+		if err := s.restoreFile(workspaceID, e, abs); err != nil {
+			return err
+		}
+	}
+	// Writing files moved the modification times of their directories.
+	for _, e := range slices.Backward(sn.Entries) {
+		if e.Dir {
+			os.Chtimes(filepath.Join(dir, filepath.FromSlash(e.Path)), e.ModTime, e.ModTime)
+		}
+	}
+	return nil
+}
+
+// restoreFile atomically replaces abs with the contents of e.
+func (s *Service) restoreFile(workspaceID string, e Entry, abs string) error {
+	in, err := os.Open(s.objectPath(workspaceID, e.Hash))
+	if err != nil {
+		return fmt.Errorf("snapshot: restore %s: %w", e.Path, err)
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(abs), files.TempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("snapshot: restore %s: %w", e.Path, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot: restore %s: %w", e.Path, err)
+	}
+	mode := os.FileMode(0o644)
+	if e.Exec {
+		mode = 0o755
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("snapshot: restore %s: %w", e.Path, err)
+	}
+	// Keeping the time lets the next snapshot reuse the stored contents.
+	if err := os.Chtimes(tmp.Name(), e.ModTime, e.ModTime); err != nil {
+		return fmt.Errorf("snapshot: restore %s: %w", e.Path, err)
+	}
+	if err := os.Rename(tmp.Name(), abs); err != nil {
+		return fmt.Errorf("snapshot: restore %s: %w", e.Path, err)
+	}
+	return nil
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return "snap-" + hex.EncodeToString(b[:])
+}
+
+func validID(id string) bool {
+	rest, ok := strings.CutPrefix(id, "snap-")
+	if !ok || len(rest) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(rest)
+	return err == nil
+}
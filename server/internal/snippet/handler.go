@@ -0,0 +1,192 @@
+package snippet
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+)
+
+// Runner runs programs for embeds.
+type Runner interface {
+	Run(ctx context.Context, req runner.Request) (*runner.Result, error)
+}
+
+// Handler serves the snippet API and embeddable snippet pages.
+type Handler struct {
+	store  *Store
+	runner Runner
+}
+
+// NewHandler returns a Handler for store whose embeds run through run.
+func NewHandler(store *Store, run Runner) *Handler {
+	return &Handler{store: store, runner: run}
+}
+
+// Register mounts the snippet routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/snippets", h.create)
+	mux.HandleFunc("GET /api/snippets/{id}", h.get)
+	mux.HandleFunc("GET /embed/{id}", h.embed)
+	mux.HandleFunc("POST /embed/{id}/run", h.run)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var s Snippet
+	if err := httpx.DecodeJSON(w, r, &s, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	saved, created, err := h.store.Create(s)
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		slog.Error("create snippet", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not save snippet")
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	httpx.JSON(w, status, saved)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	s, err := h.store.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, s)
+}
+
+// embed renders a read-only page for an iframe, with a button that runs
+// the snippet through /embed/{id}/run.
+func (h *Handler) embed(w http.ResponseWriter, r *http.Request) {
+	s, err := h.store.Get(r.PathValue("id"))
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("read snippet", "err", err)
+		http.Error(w, "could not read snippet", http.StatusInternalServerError)
+		return
+	}
+	files := s.Files
+	if len(files) == 0 {
+		files = []runner.File{{Path: "main.go", Content: s.Source}}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; frame-ancestors *")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := embedPage.Execute(w, map[string]any{"Snippet": s, "Files": files}); err != nil {
+		slog.Debug("render snippet", "id", s.ID, "err", err)
+	}
+}
+
+// run runs a stored snippet. Unlike /api/run it needs no sign-in, since
+// the program has already been shared, so embeds work for anyone.
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	s, err := h.store.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res, err := h.runner.Run(r.Context(), s.Request())
+	switch {
+	case runner.IsRequestError(err):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, runner.ErrQuotaExceeded):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	case errors.Is(err, runner.ErrQueueFull):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, runner.ErrStopped):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("run snippet", "id", s.ID, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "execution failed")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		httpx.Error(w, http.StatusNotFound, "snippet not found")
+		return
+	}
+	slog.Error("read snippet", "err", err)
+	httpx.Error(w, http.StatusInternalServerError, "could not read snippet")
+}
+
+var embedPage = template.Must(template.New("embed").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{with .Snippet.Title}}{{.}}{{else}}Go snippet{{end}}</title>
+<style>
+body { margin: 0; font: 14px system-ui, sans-serif; }
+header { display: flex; align-items: center; gap: 8px; padding: 6px 10px; border-bottom: 1px solid #ddd; }
+header h1 { flex: 1; margin: 0; font-size: 14px; }
+h2 { margin: 0; padding: 4px 10px; font: 12px monospace; color: #555; background: #f4f4f4; }
+pre { margin: 0; padding: 8px 10px; overflow: auto; font: 13px/1.4 monospace; }
+#output { border-top: 1px solid #ddd; background: #fafafa; }
+.stderr { color: #b00; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{with .Snippet.Title}}{{.}}{{else}}Go snippet{{end}}</h1>
+<button id="run">Run</button>
+</header>
+{{range .Files}}{{if gt (len $.Files) 1}}<h2>{{.Path}}</h2>
+{{end}}<pre><code>{{.Content}}</code></pre>
+{{end}}<pre id="output" hidden></pre>
+<script>
+(() => {
+	const id = {{.Snippet.ID}};
+	const button = document.getElementById("run");
+	const output = document.getElementById("output");
+	const show = (text, cls) => {
+		const span = document.createElement("span");
+		span.textContent = text;
+		if (cls) span.className = cls;
+		output.append(span);
+	};
+	button.onclick = async () => {
+		button.disabled = true;
+		output.hidden = false;
+		output.textContent = "";
+		try {
+			const res = await fetch("/embed/" + encodeURIComponent(id) + "/run", {method: "POST"});
+			const body = await res.json();
+			if (!res.ok) {
+				show(body.error || res.statusText, "stderr");
+				return;
+			}
+			show(body.stdout);
+			show(body.stderr, "stderr");
+			show(body.timedOut ? "\nProgram timed out." : "\nProgram exited with status " + body.exitCode + ".");
+		} catch (err) {
+			show(String(err), "stderr");
+		} finally {
+			button.disabled = false;
+		}
+	};
+})();
+</script>
+</body>
+</html>
+`))
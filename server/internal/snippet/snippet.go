@@ -0,0 +1,203 @@
+// Package snippet stores shareable programs, playground style. A snippet
+// is the code of a run request plus its run options, addressed by a short
+// ID derived from its content, so sharing the same program twice yields
+// the same link.
+package snippet
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir holds one JSON file per snippet; defaults to a directory under
+	// the OS temp dir.
+	Dir string
+	// MaxBytes caps the code of one snippet; defaults to 1 MiB.
+	MaxBytes int
+	// Backend keeps the snippets; defaults to JSON files in Dir.
+	Backend Backend
+}
+
+// Backend keeps snippets for a Store.
+type Backend interface {
+	// Put stores s, unless a snippet with its ID is stored already, in
+	// which case it returns that one and false.
+	Put(s *Snippet) (_ *Snippet, created bool, err error)
+	// Get returns the snippet with the given ID, or ErrNotFound.
+	Get(id string) (*Snippet, error)
+}
+
+var (
+	// ErrInvalid is returned for empty, oversized or malformed snippets.
+	ErrInvalid = errors.New("snippet: invalid snippet")
+	// ErrNotFound is returned for unknown IDs.
+	ErrNotFound = errors.New("snippet: not found")
+)
+
+// Snippet is a shared program. The fields other than ID and CreatedAt
+// mirror runner.Request, so a snippet can be run as it is.
+type Snippet struct {
+	ID        string              `json:"id"`
+	Title     string              `json:"title,omitempty"`
+	Language  string              `json:"language,omitempty"`
+	Source    string              `json:"source,omitempty"`
+	Files     []runner.File       `json:"files,omitempty"`
+	Main      string              `json:"main,omitempty"`
+	GoVersion string              `json:"goVersion,omitempty"`
+	Limits    runner.Limits       `json:"limits,omitempty"`
+	Options   runner.BuildOptions `json:"options,omitempty"`
+	CreatedAt time.Time           `json:"createdAt"`
+}
+
+// Request returns the run request the snippet describes.
+func (s *Snippet) Request() runner.Request {
+	return runner.Request{
+		Language:  s.Language,
+		Source:    s.Source,
+		Files:     s.Files,
+		Main:      s.Main,
+		GoVersion: s.GoVersion,
+		Limits:    s.Limits,
+		Options:   s.Options,
+	}
+}
+
+// idPattern matches the IDs NewID produces.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// Store validates and addresses snippets, and keeps them in a Backend.
+type Store struct {
+	cfg Config
+}
+
+// NewStore returns a Store, filling unset Config fields with defaults.
+func NewStore(cfg Config) *Store {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-snippets")
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+	if cfg.Backend == nil {
+		cfg.Backend = fileBackend{dir: cfg.Dir}
+	}
+	return &Store{cfg: cfg}
+}
+
+// Create stores s and returns it with its ID set. created is false when
+// the same snippet was stored before, in which case the earlier one is
+// returned.
+func (st *Store) Create(s Snippet) (_ *Snippet, created bool, err error) {
+	if err := st.validate(&s); err != nil {
+		return nil, false, err
+	}
+	s.CreatedAt = time.Time{}
+	key, err := json.Marshal(s)
+	if err != nil {
+		return nil, false, err
+	}
+	sum := sha256.Sum256(key)
+	s.ID = base64.RawURLEncoding.EncodeToString(sum[:8])
+	s.CreatedAt = time.Now().UTC()
+	return st.cfg.Backend.Put(&s)
+}
+
+// Get returns the snippet with the given ID.
+func (st *Store) Get(id string) (*Snippet, error) {
+	if !idPattern.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	return st.cfg.Backend.Get(id)
+}
+
+// fileBackend keeps each snippet as a JSON file in dir.
+type fileBackend struct {
+	dir string
+}
+
+func (b fileBackend) Put(s *Snippet) (*Snippet, bool, error) {
+	if prev, err := b.Get(s.ID); err == nil {
+		return prev, false, nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return nil, false, fmt.Errorf("snippet: create: %w", err)
+	}
+	p := b.path(s.ID)
+	tmp, err := os.CreateTemp(b.dir, ".snippet-*")
+	if err != nil {
+		return nil, false, fmt.Errorf("snippet: create: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, false, fmt.Errorf("snippet: create: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, false, fmt.Errorf("snippet: create: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return nil, false, fmt.Errorf("snippet: create: %w", err)
+	}
+	return s, true, nil
+}
+
+func (b fileBackend) Get(id string) (*Snippet, error) {
+	data, err := os.ReadFile(b.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snippet: read %s: %w", id, err)
+	}
+	var s Snippet
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("snippet: read %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+func (b fileBackend) path(id string) string {
+	return filepath.Join(b.dir, id+".json")
+}
+
+// validate checks the code of s. The run options are checked when the
+// snippet runs, like those of any other request.
+func (st *Store) validate(s *Snippet) error {
+	switch {
+	case s.Source != "" && len(s.Files) > 0:
+		return fmt.Errorf("%w: source and files are mutually exclusive", ErrInvalid)
+	case strings.TrimSpace(s.Source) == "" && len(s.Files) == 0:
+		return fmt.Errorf("%w: no code", ErrInvalid)
+	case len(s.Files) > runner.MaxProjectFiles:
+		return fmt.Errorf("%w: more than %d files", ErrInvalid, runner.MaxProjectFiles)
+	case len(s.Title) > 200:
+		return fmt.Errorf("%w: title too long", ErrInvalid)
+	}
+	total := len(s.Source)
+	for _, f := range s.Files {
+		if f.Path == "" {
+			return fmt.Errorf("%w: file without a path", ErrInvalid)
+		}
+		total += len(f.Path) + len(f.Content)
+	}
+	if total > st.cfg.MaxBytes {
+		return fmt.Errorf("%w: code exceeds %d bytes", ErrInvalid, st.cfg.MaxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package sshd
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Handler serves the routes users manage their SSH keys with.
+type Handler struct {
+	keys *Keys
+}
+
+// NewHandler returns a Handler for keys.
+func NewHandler(keys *Keys) *Handler {
+	return &Handler{keys: keys}
+}
+
+// Register mounts the key routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/ssh-keys", h.list)
+	mux.HandleFunc("POST /api/ssh-keys", h.add)
+	mux.HandleFunc("DELETE /api/ssh-keys/{id}", h.delete)
+}
+
+// user returns the signed-in user. Keys open shells in every workspace
+// the user may edit, so like the account itself they are not managed with
+// personal access tokens.
+func (h *Handler) user(w http.ResponseWriter, r *http.Request) (*auth.User, bool) {
+	u := auth.UserFrom(r.Context())
+	if u == nil {
+		httpx.Error(w, http.StatusUnauthorized, "authentication required")
+		return nil, false
+	}
+	if auth.TokenFrom(r.Context()) != nil {
+		httpx.Error(w, http.StatusForbidden, "personal access tokens cannot manage SSH keys")
+		return nil, false
+	}
+	return u, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	keys, err := h.keys.List(u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+type addRequest struct {
+	Name string `json:"name"`
+	// PublicKey is a line of an authorized_keys file, such as the
+	// contents of ~/.ssh/id_ed25519.pub.
+	PublicKey string `json:"publicKey"`
+}
+
+func (h *Handler) add(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	var req addRequest
+	if err := httpx.DecodeJSON(w, r, &req, 16<<10); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	key, err := h.keys.Add(u.ID, req.Name, req.PublicKey)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionSSHKeyAdd, Target: key.Fingerprint,
+		Details: map[string]string{"name": key.Name}})
+	httpx.JSON(w, http.StatusCreated, key)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	if err := h.keys.Delete(u.ID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionSSHKeyDelete, Target: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrExists), errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("ssh keys", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "ssh key request failed")
+	}
+}
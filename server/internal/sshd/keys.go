@@ -0,0 +1,373 @@
+package sshd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for keys that do not exist.
+	ErrNotFound = errors.New("sshd: key not found")
+	// ErrInvalid is returned for public keys that cannot be parsed or are
+	// of an unsupported type.
+	ErrInvalid = errors.New("sshd: invalid public key")
+	// ErrExists is returned when a key is already registered, by anyone.
+	ErrExists = errors.New("sshd: key already registered")
+	// ErrTooMany is returned when a user is at maxKeys.
+	ErrTooMany = errors.New("sshd: too many keys")
+)
+
+// maxKeys caps the keys per user.
+const maxKeys = 50
+
+// Key is a public key a user signs in to the gateway with.
+type Key struct {
+	ID   string `json:"id"`
+	User string `json:"user"`
+	Name string `json:"name"`
+	// Type is the key's algorithm, such as ssh-ed25519.
+	Type string `json:"type"`
+	// Fingerprint is the SHA256 fingerprint ssh-keygen -l prints.
+	Fingerprint string `json:"fingerprint"`
+	// PublicKey is the key in authorized_keys form, without a comment.
+	PublicKey  string     `json:"publicKey"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Keys stores the public keys of users, in one file in its directory.
+type Keys struct {
+	dir string
+	now func() time.Time
+
+	mu   sync.Mutex
+	keys []Key // loaded on first use
+}
+
+// NewKeys returns a Keys storing in dir.
+func NewKeys(dir string) (*Keys, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("sshd: create dir: %w", err)
+	}
+	return &Keys{dir: dir, now: time.Now}, nil
+}
+
+// List returns the keys of a user, oldest first.
+func (k *Keys) List(userID string) ([]Key, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys, err := k.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	out := []Key{}
+	for _, key := range keys {
+		if key.User == userID {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// Add registers a key for a user. line is a public key as in an
+// authorized_keys file or a .pub file: the type, the base64 key and an
+// optional comment, which names the key when name is empty.
+func (k *Keys) Add(userID, name, line string) (Key, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Key{}, fmt.Errorf("%w: want \"TYPE BASE64 [COMMENT]\"", ErrInvalid)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	pub, err := parsePublicKey(blob)
+	if err != nil {
+		return Key{}, err
+	}
+	if pub.typ != fields[0] {
+		return Key{}, fmt.Errorf("%w: key is %s, not %s", ErrInvalid, pub.typ, fields[0])
+	}
+	if name == "" && len(fields) > 2 {
+		name = strings.Join(fields[2:], " ")
+	}
+	if name == "" {
+		name = pub.typ
+	}
+	if len(name) > 100 {
+		return Key{}, fmt.Errorf("%w: name is longer than 100 bytes", ErrInvalid)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys, err := k.loadLocked()
+	if err != nil {
+		return Key{}, err
+	}
+	fp := fingerprint(blob)
+	n := 0
+	for _, key := range keys {
+		if key.Fingerprint == fp {
+			return Key{}, ErrExists
+		}
+		if key.User == userID {
+			n++
+		}
+	}
+	if n >= maxKeys {
+		return Key{}, ErrTooMany
+	}
+	key := Key{
+		ID:          newID(),
+		User:        userID,
+		Name:        name,
+		Type:        pub.typ,
+		Fingerprint: fp,
+		PublicKey:   pub.typ + " " + fields[1],
+		CreatedAt:   k.now().UTC(),
+	}
+	if err := k.saveLocked(append(slices.Clip(keys), key)); err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// Delete removes a key of a user.
+func (k *Keys) Delete(userID, id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys, err := k.loadLocked()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(keys, func(key Key) bool { return key.ID == id && key.User == userID })
+	if i < 0 {
+		return ErrNotFound
+	}
+	return k.saveLocked(slices.Delete(slices.Clone(keys), i, i+1))
+}
+
+// lookup returns the key with the given wire encoding.
+func (k *Keys) lookup(blob []byte) (Key, error) {
+	fp := fingerprint(blob)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys, err := k.loadLocked()
+	if err != nil {
+		return Key{}, err
+	}
+	for _, key := range keys {
+		if key.Fingerprint == fp {
+			return key, nil
+		}
+	}
+	return Key{}, ErrNotFound
+}
+
+// used records that a key signed in. It only matters to the user, so a
+// failure to save is ignored.
+func (k *Keys) used(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys, err := k.loadLocked()
+	if err != nil {
+		return
+	}
+	i := slices.IndexFunc(keys, func(key Key) bool { return key.ID == id })
+	if i < 0 {
+		return
+	}
+	keys = slices.Clone(keys)
+	now := k.now().UTC()
+	keys[i].LastUsedAt = &now
+	k.saveLocked(keys)
+}
+
+func (k *Keys) path() string { return filepath.Join(k.dir, "keys.json") }
+
+// loadLocked returns the keys. k.mu must be held.
+func (k *Keys) loadLocked() ([]Key, error) {
+	if k.keys != nil {
+		return k.keys, nil
+	}
+	keys := []Key{}
+	data, err := os.ReadFile(k.path())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("sshd: read keys: %w", err)
+	default:
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("sshd: read keys: %w", err)
+		}
+	}
+	k.keys = keys
+	return keys, nil
+}
+
+// saveLocked replaces the keys. k.mu must be held.
+func (k *Keys) saveLocked(keys []Key) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := k.path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("sshd: write keys: %w", err)
+	}
+	if err := os.Rename(tmp, k.path()); err != nil {
+		return fmt.Errorf("sshd: write keys: %w", err)
+	}
+	k.keys = keys
+	return nil
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// fingerprint returns the SHA256 fingerprint of a key's wire encoding.
+func fingerprint(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// signatureAlgos are the signature algorithms accepted from clients, sent
+// to them as server-sig-algs.
+var signatureAlgos = []string{
+	"ssh-ed25519",
+	"ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521",
+	"rsa-sha2-256", "rsa-sha2-512",
+}
+
+// publicKey is a parsed client key.
+type publicKey struct {
+	typ string
+	key crypto.PublicKey
+}
+
+// parsePublicKey decodes the wire encoding of a public key (RFC 4253 6.6,
+// RFC 5656 3.1).
+func parsePublicKey(blob []byte) (*publicKey, error) {
+	r := reader{b: blob}
+	typ := r.string()
+	var key crypto.PublicKey
+	switch typ {
+	case "ssh-ed25519":
+		b := r.bytes()
+		if len(b) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: bad ed25519 key", ErrInvalid)
+		}
+		key = ed25519.PublicKey(b)
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		curve := curveOf(typ)
+		if r.string() != strings.TrimPrefix(typ, "ecdsa-sha2-") {
+			return nil, fmt.Errorf("%w: curve does not match the key type", ErrInvalid)
+		}
+		x, y := elliptic.Unmarshal(curve, r.bytes())
+		if x == nil {
+			return nil, fmt.Errorf("%w: bad ecdsa point", ErrInvalid)
+		}
+		key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "ssh-rsa":
+		e, n := r.mpint(), r.mpint()
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 || n.BitLen() < 2048 {
+			return nil, fmt.Errorf("%w: RSA keys must have at least 2048 bits", ErrInvalid)
+		}
+		key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %q", ErrInvalid, typ)
+	}
+	if !r.ok() || len(r.b) != 0 {
+		return nil, fmt.Errorf("%w: malformed key", ErrInvalid)
+	}
+	return &publicKey{typ: typ, key: key}, nil
+}
+
+func curveOf(typ string) elliptic.Curve {
+	switch typ {
+	case "ecdsa-sha2-nistp384":
+		return elliptic.P384()
+	case "ecdsa-sha2-nistp521":
+		return elliptic.P521()
+	}
+	return elliptic.P256()
+}
+
+// verify checks a signature, in its wire encoding, by the key over data.
+// algo is the signature algorithm the client named, which for RSA keys
+// picks the hash.
+func (k *publicKey) verify(algo string, data, sig []byte) bool {
+	r := reader{b: sig}
+	sigAlgo, blob := r.string(), r.bytes()
+	if !r.ok() || sigAlgo != algo {
+		return false
+	}
+	switch key := k.key.(type) {
+	case ed25519.PublicKey:
+		return algo == "ssh-ed25519" && ed25519.Verify(key, data, blob)
+	case *ecdsa.PublicKey:
+		if algo != k.typ {
+			return false
+		}
+		r := reader{b: blob}
+		sr, ss := r.mpint(), r.mpint()
+		if !r.ok() {
+			return false
+		}
+		return ecdsa.Verify(key, ecdsaDigest(key.Curve, data), sr, ss)
+	case *rsa.PublicKey:
+		switch algo {
+		case "rsa-sha2-256":
+			sum := sha256.Sum256(data)
+			return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], blob) == nil
+		case "rsa-sha2-512":
+			sum := sha512.Sum512(data)
+			return rsa.VerifyPKCS1v15(key, crypto.SHA512, sum[:], blob) == nil
+		}
+	}
+	return false
+}
+
+// ecdsaDigest hashes data with the hash the curve's key type uses.
+func ecdsaDigest(curve elliptic.Curve, data []byte) []byte {
+	switch curve.Params().BitSize {
+	case 384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case 521:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// algoKeyType returns the key type a signature algorithm is made with.
+func algoKeyType(algo string) string {
+	if algo == "rsa-sha2-256" || algo == "rsa-sha2-512" {
+		return "ssh-rsa"
+	}
+	return algo
+}
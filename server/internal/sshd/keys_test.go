@@ -0,0 +1,237 @@
+package sshd
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// testKey is a client key, with its public key's wire encoding and a
+// signer making signatures in their wire encoding.
+type testKey struct {
+	algo string
+	blob []byte
+	sign func(data []byte) []byte
+}
+
+// line returns the key as in an authorized_keys file.
+func (k testKey) line() string {
+	return algoKeyType(k.algo) + " " + base64.StdEncoding.EncodeToString(k.blob) + " test@example"
+}
+
+func ed25519Key(t testing.TB) testKey {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{
+		algo: "ssh-ed25519",
+		blob: *new(builder).string("ssh-ed25519").bytes(pub),
+		sign: func(data []byte) []byte {
+			return *new(builder).string("ssh-ed25519").bytes(ed25519.Sign(priv, data))
+		},
+	}
+}
+
+func ecdsaKey(t testing.TB, curve elliptic.Curve) testKey {
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point, err := priv.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := map[int]string{256: "nistp256", 384: "nistp384", 521: "nistp521"}[curve.Params().BitSize]
+	algo := "ecdsa-sha2-" + name
+	return testKey{
+		algo: algo,
+		blob: *new(builder).string(algo).string(name).bytes(point.Bytes()),
+		sign: func(data []byte) []byte {
+			r, s, err := ecdsa.Sign(rand.Reader, priv, ecdsaDigest(curve, data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig := new(builder).mpintBytes(r.Bytes()).mpintBytes(s.Bytes())
+			return *new(builder).string(algo).bytes(*sig)
+		},
+	}
+}
+
+// rsaKey returns an RSA key signing with algo, rsa-sha2-256 or
+// rsa-sha2-512.
+func rsaKey(t testing.TB, priv *rsa.PrivateKey, algo string) testKey {
+	e := big.NewInt(int64(priv.E))
+	return testKey{
+		algo: algo,
+		blob: *new(builder).string("ssh-rsa").mpintBytes(e.Bytes()).mpintBytes(priv.N.Bytes()),
+		sign: func(data []byte) []byte {
+			hash, digest := crypto.SHA256, sha256.Sum256(data)
+			sum := digest[:]
+			if algo == "rsa-sha2-512" {
+				d := sha512.Sum512(data)
+				hash, sum = crypto.SHA512, d[:]
+			}
+			sig, err := rsa.SignPKCS1v15(rand.Reader, priv, hash, sum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return *new(builder).string(algo).bytes(sig)
+		},
+	}
+}
+
+var testRSAKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+func testKeys(t *testing.T) []testKey {
+	return []testKey{
+		ed25519Key(t),
+		ecdsaKey(t, elliptic.P256()),
+		ecdsaKey(t, elliptic.P384()),
+		ecdsaKey(t, elliptic.P521()),
+		rsaKey(t, testRSAKey, "rsa-sha2-256"),
+		rsaKey(t, testRSAKey, "rsa-sha2-512"),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := []byte("session id and request")
+	for _, k := range testKeys(t) {
+		pub, err := parsePublicKey(k.blob)
+		if err != nil {
+			t.Fatalf("parsePublicKey(%s): %v", k.algo, err)
+		}
+		if pub.typ != algoKeyType(k.algo) {
+			t.Errorf("parsePublicKey(%s).typ = %s", k.algo, pub.typ)
+		}
+		sig := k.sign(data)
+		if !pub.verify(k.algo, data, sig) {
+			t.Errorf("%s: signature does not verify", k.algo)
+		}
+		if pub.verify(k.algo, []byte("other data"), sig) {
+			t.Errorf("%s: signature verifies other data", k.algo)
+		}
+		tampered := append([]byte(nil), sig...)
+		tampered[len(tampered)-1] ^= 1
+		if pub.verify(k.algo, data, tampered) {
+			t.Errorf("%s: tampered signature verifies", k.algo)
+		}
+		for _, other := range signatureAlgos {
+			if other != k.algo && pub.verify(other, data, sig) {
+				t.Errorf("%s: signature verifies as %s", k.algo, other)
+			}
+		}
+	}
+
+	// A signature by another key of the same type does not verify.
+	a, b := ed25519Key(t), ed25519Key(t)
+	pub, _ := parsePublicKey(a.blob)
+	if pub.verify("ssh-ed25519", data, b.sign(data)) {
+		t.Error("signature by another key verifies")
+	}
+	// Nor does the right signature under a name of another algorithm.
+	sig := a.sign(data)
+	renamed := *new(builder).string("ssh-rsa").bytes(sig[4+len("ssh-ed25519")+4:])
+	if pub.verify("ssh-rsa", data, renamed) {
+		t.Error("ed25519 signature verifies as ssh-rsa")
+	}
+}
+
+func TestParsePublicKeyInvalid(t *testing.T) {
+	small := new(big.Int).Lsh(big.NewInt(1), 1023)
+	small.Add(small, big.NewInt(1))
+	p256 := ecdsaKey(t, elliptic.P256())
+	point, _ := ecdh.P256().GenerateKey(rand.Reader)
+	ed := ed25519Key(t)
+	tests := []struct {
+		name string
+		blob []byte
+	}{
+		{"empty", nil},
+		{"unknown type", *new(builder).string("ssh-dss").bytes(make([]byte, 32))},
+		{"short ed25519", *new(builder).string("ssh-ed25519").bytes(make([]byte, 31))},
+		{"trailing data", append(append([]byte(nil), ed.blob...), 0)},
+		{"truncated", ed.blob[:len(ed.blob)-1]},
+		{"small RSA", *new(builder).string("ssh-rsa").mpintBytes([]byte{1, 0, 1}).mpintBytes(small.Bytes())},
+		{"RSA exponent 1", *new(builder).string("ssh-rsa").mpintBytes([]byte{1}).mpintBytes(testRSAKey.N.Bytes())},
+		{"curve mismatch", *new(builder).string("ecdsa-sha2-nistp384").string("nistp256").bytes(point.PublicKey().Bytes())},
+		{"point off the curve", *new(builder).string("ecdsa-sha2-nistp256").string("nistp256").bytes(append([]byte{4}, make([]byte, 64)...))},
+		{"P-256 point as P-384", append(*new(builder).string("ecdsa-sha2-nistp384").string("nistp384"), p256.blob[4+19+4+8:]...)},
+	}
+	for _, tt := range tests {
+		if _, err := parsePublicKey(tt.blob); !errors.Is(err, ErrInvalid) {
+			t.Errorf("parsePublicKey(%s) = %v, want ErrInvalid", tt.name, err)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := NewKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, rk := ed25519Key(t), rsaKey(t, testRSAKey, "rsa-sha2-256")
+	key, err := keys.Add("u1", "", ed.line())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Name != "test@example" || key.Type != "ssh-ed25519" || !strings.HasPrefix(key.Fingerprint, "SHA256:") {
+		t.Errorf("Add = %+v", key)
+	}
+	if _, err := keys.Add("u1", "laptop", rk.line()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Add("u2", "", ed.line()); !errors.Is(err, ErrExists) {
+		t.Errorf("Add of a key another user has = %v, want ErrExists", err)
+	}
+	for _, line := range []string{
+		"ssh-ed25519",
+		"ssh-ed25519 !!!",
+		"ssh-rsa " + strings.Fields(ed.line())[1],
+		"ssh-ed25519 " + base64.StdEncoding.EncodeToString([]byte("junk")),
+	} {
+		if _, err := keys.Add("u1", "", line); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Add(%q) = %v, want ErrInvalid", line, err)
+		}
+	}
+
+	// The keys are kept on disk.
+	keys, err = NewKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := keys.List("u1")
+	if err != nil || len(list) != 2 || list[0].ID != key.ID || list[1].Name != "laptop" {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	if got, err := keys.lookup(ed.blob); err != nil || got.ID != key.ID {
+		t.Errorf("lookup = %+v, %v", got, err)
+	}
+	if err := keys.Delete("u2", key.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of another user's key = %v, want ErrNotFound", err)
+	}
+	if err := keys.Delete("u1", key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.lookup(ed.blob); !errors.Is(err, ErrNotFound) {
+		t.Errorf("lookup of a deleted key = %v, want ErrNotFound", err)
+	}
+}
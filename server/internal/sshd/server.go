@@ -0,0 +1,414 @@
+// Package sshd is an SSH gateway into workspaces, for editors, scripts
+// and tools that speak SSH rather than the IDE's API. A user signs in with
+// one of the public keys they registered (see Keys) and the workspace ID
+// as the user name:
+//
+//	ssh -p 2222 WORKSPACE@ide.example.com
+//
+// and gets a shell in the workspace sandbox, started through the same
+// Launcher as the IDE's terminals, so it sees the workspace's variables
+// and secrets and counts against the user's quota. Commands given to ssh
+// run without a terminal unless one is asked for, and the sftp subsystem
+// serves the workspace's files, so sftp, scp and tools built on them work
+// with the workspace as the root of the file system.
+//
+// Only editors and owners of a workspace may sign in to it. The gateway
+// implements the parts of SSH modern clients need: curve25519 key
+// exchange, the ed25519 host key, AES-GCM and public key authentication
+// with ed25519, ECDSA and RSA keys. Port and agent forwarding are refused.
+package sshd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Config configures a Server.
+type Config struct {
+	// HostKey identifies the server to clients; see LoadHostKey.
+	HostKey ed25519.PrivateKey
+	// Shell is the command interactive sessions run; defaults to a login
+	// bash, or sh where there is no bash.
+	Shell []string
+	// Env is added to every session's environment.
+	Env []string
+	// AuthTimeout bounds the time from connecting to signing in; defaults
+	// to 2 minutes.
+	AuthTimeout time.Duration
+	// MaxConns caps the connections served at once; defaults to 256.
+	MaxConns int
+	// Audit, when set, records each sign-in.
+	Audit *audit.Log
+}
+
+// Users looks up the accounts keys belong to.
+type Users interface {
+	User(id string) (*auth.User, error)
+}
+
+// Roles reports a user's role on a workspace, "" for none.
+type Roles interface {
+	Role(workspaceID, userID string) (workspace.Role, error)
+}
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Quota admits new sessions for the user in ctx. The returned function is
+// called when the session ends.
+type Quota interface {
+	StartTerminal(ctx context.Context) (stop func(), err error)
+}
+
+// maxAuthTries is how many failed attempts to sign in a connection gets.
+const maxAuthTries = 10
+
+// Server accepts SSH connections into workspaces.
+type Server struct {
+	cfg        Config
+	keys       *Keys
+	users      Users
+	roles      Roles
+	workspaces Workspaces
+	launcher   terminal.Launcher
+	quota      Quota
+
+	mu     sync.Mutex
+	lns    map[net.Listener]struct{}
+	conns  map[*conn]struct{}
+	busy   map[string]int // workspace ID -> connections signed in
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New returns a Server signing users in with keys and starting their
+// sessions through launcher, filling unset Config fields with defaults.
+// quota may be nil.
+func New(cfg Config, keys *Keys, users Users, roles Roles, wm Workspaces, launcher terminal.Launcher, quota Quota) *Server {
+	if len(cfg.Shell) == 0 {
+		cfg.Shell = []string{"sh", "-c", "if command -v bash >/dev/null; then exec bash -l; else exec sh -l; fi"}
+	}
+	if cfg.AuthTimeout <= 0 {
+		cfg.AuthTimeout = 2 * time.Minute
+	}
+	if cfg.MaxConns <= 0 {
+		cfg.MaxConns = 256
+	}
+	return &Server{
+		cfg:        cfg,
+		keys:       keys,
+		users:      users,
+		roles:      roles,
+		workspaces: wm,
+		launcher:   launcher,
+		quota:      quota,
+		lns:        make(map[net.Listener]struct{}),
+		conns:      make(map[*conn]struct{}),
+		busy:       make(map[string]int),
+	}
+}
+
+// LoadHostKey reads the ed25519 host key in the PKCS #8 PEM file at path,
+// generating one there if the file does not exist. Clients remember the
+// key, so it must be kept across restarts.
+func LoadHostKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("sshd: write host key: %w", err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, fmt.Errorf("sshd: write host key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sshd: read host key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("sshd: host key %s is not PEM", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("sshd: host key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("sshd: host key %s is not an ed25519 key", path)
+	}
+	return key, nil
+}
+
+// Serve accepts connections on ln until the Server is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	s.lns[ln] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.lns, ln)
+		s.mu.Unlock()
+	}()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		c := &conn{srv: s, nc: nc, t: newTransport(nc, s.cfg.HostKey), channels: make(map[uint32]*channel)}
+		s.mu.Lock()
+		if s.closed || len(s.conns) >= s.cfg.MaxConns {
+			s.mu.Unlock()
+			nc.Close()
+			continue
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting connections and ends the open ones, killing their
+// sessions.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for ln := range s.lns {
+		ln.Close()
+	}
+	for c := range s.conns {
+		c.nc.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// Busy reports whether anyone is signed in to a workspace, so it is not
+// hibernated under them.
+func (s *Server) Busy(workspaceID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.busy[workspaceID] > 0
+}
+
+// conn is one client connection.
+type conn struct {
+	srv *Server
+	nc  net.Conn
+	t   *transport
+
+	// Set once signed in.
+	workspaceID string
+	dir         string
+	user        *auth.User
+	ctx         context.Context
+
+	mu       sync.Mutex
+	channels map[uint32]*channel
+	nextID   uint32
+}
+
+func (c *conn) serve() {
+	defer c.nc.Close()
+	c.nc.SetDeadline(time.Now().Add(c.srv.cfg.AuthTimeout))
+	if err := c.t.handshake(); err != nil {
+		slog.Debug("ssh handshake", "remote", c.nc.RemoteAddr(), "err", err)
+		return
+	}
+	if err := c.authenticate(); err != nil {
+		slog.Debug("ssh authentication", "remote", c.nc.RemoteAddr(), "err", err)
+		return
+	}
+	c.nc.SetDeadline(time.Time{})
+
+	s := c.srv
+	s.mu.Lock()
+	s.busy[c.workspaceID]++
+	s.mu.Unlock()
+	ctx, cancel := context.WithCancel(auth.WithUser(context.Background(), c.user))
+	c.ctx = workspace.WithRole(workspace.WithOwner(ctx, c.user.ID), workspace.RoleEditor)
+	defer func() {
+		cancel()
+		c.closeChannels()
+		s.mu.Lock()
+		if s.busy[c.workspaceID]--; s.busy[c.workspaceID] <= 0 {
+			delete(s.busy, c.workspaceID)
+		}
+		s.mu.Unlock()
+	}()
+	if err := c.loop(); err != nil {
+		slog.Debug("ssh connection", "remote", c.nc.RemoteAddr(), "workspace", c.workspaceID, "err", err)
+	}
+}
+
+// authenticate runs the user authentication protocol (RFC 4252) until
+// the client signs in with a registered key for a workspace they may
+// edit.
+func (c *conn) authenticate() error {
+	p, err := c.t.readPacket()
+	if err != nil {
+		return err
+	}
+	r := reader{b: p[1:]}
+	if p[0] != msgServiceRequest || r.string() != "ssh-userauth" || !r.ok() {
+		c.t.disconnect(disconnectProtocolError, "expected ssh-userauth")
+		return errors.New("sshd: expected ssh-userauth")
+	}
+	if err := c.t.writePacket(*message(msgServiceAccept).string("ssh-userauth")); err != nil {
+		return err
+	}
+
+	failures := 0
+	for {
+		p, err := c.t.readPacket()
+		if err != nil {
+			return err
+		}
+		if p[0] != msgUserAuthRequest {
+			return fmt.Errorf("sshd: expected USERAUTH_REQUEST, got message %d", p[0])
+		}
+		res, err := c.tryKey(p)
+		switch {
+		case err != nil:
+			return err
+		case res == authSuccess:
+			return nil
+		case res == authKeyOK:
+			continue
+		}
+		if failures++; failures >= maxAuthTries {
+			c.t.disconnect(disconnectNoMoreAuth, "too many authentication failures")
+			return errors.New("sshd: too many authentication failures")
+		}
+		if err := c.t.writePacket(*message(msgUserAuthFailure).nameList([]string{"publickey"}).bool(false)); err != nil {
+			return err
+		}
+	}
+}
+
+// Outcomes of a USERAUTH_REQUEST.
+const (
+	authFailure = iota // to be answered with USERAUTH_FAILURE
+	authKeyOK          // a key the client may sign with, answered with PK_OK
+	authSuccess        // signed in
+)
+
+// tryKey answers a USERAUTH_REQUEST, but for failures.
+func (c *conn) tryKey(p []byte) (int, error) {
+	r := reader{b: p[1:]}
+	userName, service, method := r.string(), r.string(), r.string()
+	if method != "publickey" {
+		return authFailure, nil
+	}
+	signed, algo, blob := r.bool(), r.string(), r.bytes()
+	var sig []byte
+	if signed {
+		sig = r.bytes()
+	}
+	if !r.ok() || len(r.b) != 0 || service != "ssh-connection" {
+		return authFailure, nil
+	}
+	pub, err := parsePublicKey(blob)
+	if err != nil || algoKeyType(algo) != pub.typ {
+		return authFailure, nil
+	}
+	key, err := c.srv.keys.lookup(blob)
+	if err != nil {
+		return authFailure, nil
+	}
+	if !workspace.ValidID(userName) {
+		return authFailure, nil
+	}
+	role, err := c.srv.roles.Role(userName, key.User)
+	if err != nil {
+		slog.Error("ssh workspace role", "workspace", userName, "err", err)
+		return authFailure, nil
+	}
+	if role != workspace.RoleOwner && role != workspace.RoleEditor {
+		return authFailure, nil
+	}
+
+	if !signed {
+		return authKeyOK, c.t.writePacket(*message(msgUserAuthPKOK).string(algo).bytes(blob))
+	}
+	data := new(builder).bytes(c.t.sessionID)
+	*data = append(*data, p...)
+	*data = (*data)[:len(*data)-4-len(sig)]
+	if !pub.verify(algo, *data, sig) {
+		return authFailure, nil
+	}
+
+	u, err := c.srv.users.User(key.User)
+	if err != nil {
+		slog.Error("ssh user", "user", key.User, "err", err)
+		return authFailure, nil
+	}
+	dir, err := c.srv.workspaces.Open(userName)
+	if err != nil {
+		return authFailure, nil
+	}
+	c.workspaceID, c.dir, c.user = userName, dir, u
+	c.srv.keys.used(key.ID)
+	if c.srv.cfg.Audit != nil {
+		host, _, _ := net.SplitHostPort(c.nc.RemoteAddr().String())
+		err := c.srv.cfg.Audit.Append(audit.Entry{
+			Action: audit.ActionSSHLogin, User: u.ID, Email: u.Email, IP: host,
+			Workspace: userName, Target: key.Fingerprint,
+		})
+		if err != nil {
+			slog.Error("audit: record", "action", audit.ActionSSHLogin, "user", u.ID, "err", err)
+		}
+	}
+	return authSuccess, c.t.writePacket([]byte{msgUserAuthSuccess})
+}
@@ -0,0 +1,278 @@
+package sshd
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/terminal"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+type fakeUsers struct{}
+
+func (fakeUsers) User(id string) (*auth.User, error) {
+	return &auth.User{ID: id, Email: id + "@example.com"}, nil
+}
+
+// fakeRoles maps a workspace ID and user ID to a role.
+type fakeRoles map[[2]string]workspace.Role
+
+func (r fakeRoles) Role(workspaceID, userID string) (workspace.Role, error) {
+	return r[[2]string{workspaceID, userID}], nil
+}
+
+// fakeWorkspaces maps workspace IDs to their directories.
+type fakeWorkspaces map[string]string
+
+func (w fakeWorkspaces) Open(id string) (string, error) {
+	dir, ok := w[id]
+	if !ok {
+		return "", errors.New("no such workspace")
+	}
+	return dir, nil
+}
+
+// testServer is a Server on a loopback port where u1 edits ws1 and views
+// ws2, and u1's key is registered.
+type testServer struct {
+	addr string
+	dir  string // ws1's directory
+	key  testKey
+	keys *Keys
+}
+
+func startServer(t *testing.T) *testServer {
+	t.Helper()
+	keys, err := NewKeys(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &testServer{dir: t.TempDir(), key: ed25519Key(t), keys: keys}
+	if _, err := keys.Add("u1", "", ts.key.line()); err != nil {
+		t.Fatal(err)
+	}
+	roles := fakeRoles{{"ws1", "u1"}: workspace.RoleEditor, {"ws2", "u1"}: workspace.RoleViewer}
+	wm := fakeWorkspaces{"ws1": ts.dir, "ws2": t.TempDir()}
+	srv := New(Config{HostKey: testHostKey}, keys, fakeUsers{}, roles, wm, terminal.LocalLauncher{}, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	ts.addr = ln.Addr().String()
+	return ts
+}
+
+// client is the client side of a connection to a testServer.
+type client struct {
+	t  *testing.T
+	nc net.Conn
+	ct *transport
+}
+
+// dial connects to ts and requests the user authentication service.
+func (ts *testServer) dial(t *testing.T) *client {
+	t.Helper()
+	nc, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nc.Close() })
+	nc.SetDeadline(time.Now().Add(10 * time.Second))
+	ct, err := clientHandshake(nc, clientKex, cipherAlgos)
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	c := &client{t: t, nc: nc, ct: ct}
+	c.expect(msgExtInfo)
+	c.send(*message(msgServiceRequest).string("ssh-userauth"))
+	c.expect(msgServiceAccept)
+	return c
+}
+
+func (c *client) send(p []byte) {
+	c.t.Helper()
+	if err := c.ct.writePacket(p); err != nil {
+		c.t.Fatalf("send message %d: %v", p[0], err)
+	}
+}
+
+func (c *client) recv() []byte {
+	c.t.Helper()
+	p, err := c.ct.readRaw()
+	if err != nil {
+		c.t.Fatalf("recv: %v", err)
+	}
+	return p
+}
+
+// expect receives a message of type typ and returns a reader of its
+// fields.
+func (c *client) expect(typ byte) *reader {
+	c.t.Helper()
+	p := c.recv()
+	if p[0] != typ {
+		c.t.Fatalf("got message %d %q, want %d", p[0], p[1:], typ)
+	}
+	return &reader{b: p[1:]}
+}
+
+// authRequest returns a publickey USERAUTH_REQUEST for user with key,
+// signed if sign is set, and with a flipped bit in the signature if
+// tamper is.
+func (c *client) authRequest(user string, key testKey, sign, tamper bool) []byte {
+	if !sign {
+		return *message(msgUserAuthRequest).string(user).string("ssh-connection").string("publickey").
+			bool(false).string(key.algo).bytes(key.blob)
+	}
+	p := c.signedRequest(user, key, key)
+	if tamper {
+		p[len(p)-1] ^= 1
+	}
+	return p
+}
+
+// signedRequest returns a publickey USERAUTH_REQUEST for user with key,
+// signed by signer.
+func (c *client) signedRequest(user string, key, signer testKey) []byte {
+	p := message(msgUserAuthRequest).string(user).string("ssh-connection").string("publickey").
+		bool(true).string(key.algo).bytes(key.blob)
+	data := new(builder).bytes(c.ct.sessionID)
+	*data = append(*data, *p...)
+	return *p.bytes(signer.sign(*data))
+}
+
+// login signs in to ws1 with the server's key.
+func (ts *testServer) login(t *testing.T) *client {
+	t.Helper()
+	c := ts.dial(t)
+	c.send(c.authRequest("ws1", ts.key, true, false))
+	c.expect(msgUserAuthSuccess)
+	return c
+}
+
+func TestAuthenticate(t *testing.T) {
+	ts := startServer(t)
+	other := ed25519Key(t)
+	c := ts.dial(t)
+	tests := []struct {
+		name    string
+		request []byte
+		want    byte
+	}{
+		{"tampered signature", c.authRequest("ws1", ts.key, true, true), msgUserAuthFailure},
+		{"signature by another key", c.signedRequest("ws1", ts.key, other), msgUserAuthFailure},
+		{"unregistered key", c.authRequest("ws1", other, true, false), msgUserAuthFailure},
+		{"unregistered key query", c.authRequest("ws1", other, false, false), msgUserAuthFailure},
+		{"viewer", c.authRequest("ws2", ts.key, true, false), msgUserAuthFailure},
+		{"no role", c.authRequest("ws3", ts.key, true, false), msgUserAuthFailure},
+		{"invalid workspace ID", c.authRequest("../ws1", ts.key, true, false), msgUserAuthFailure},
+		{"password", *message(msgUserAuthRequest).string("ws1").string("ssh-connection").string("password").bool(false).string("secret"), msgUserAuthFailure},
+		{"query", c.authRequest("ws1", ts.key, false, false), msgUserAuthPKOK},
+		{"signed", c.authRequest("ws1", ts.key, true, false), msgUserAuthSuccess},
+	}
+	for _, tt := range tests {
+		c.send(tt.request)
+		if p := c.recv(); p[0] != tt.want {
+			t.Errorf("%s: got message %d, want %d", tt.name, p[0], tt.want)
+		}
+	}
+	list, err := ts.keys.List("u1")
+	if err != nil || len(list) != 1 || list[0].LastUsedAt == nil {
+		t.Errorf("after signing in, List = %+v, %v, want the key's use recorded", list, err)
+	}
+}
+
+func TestAuthenticateSignatureOverSession(t *testing.T) {
+	// A signature made for one connection does not sign in another.
+	ts := startServer(t)
+	a, b := ts.dial(t), ts.dial(t)
+	b.send(a.authRequest("ws1", ts.key, true, false))
+	b.expect(msgUserAuthFailure)
+}
+
+func TestAuthenticateTooManyFailures(t *testing.T) {
+	ts := startServer(t)
+	c := ts.dial(t)
+	for range maxAuthTries - 1 {
+		c.send(c.authRequest("ws1", ts.key, true, true))
+		c.expect(msgUserAuthFailure)
+	}
+	c.send(c.authRequest("ws1", ts.key, true, true))
+	c.expect(msgDisconnect)
+}
+
+func TestAuthenticateKeyTypes(t *testing.T) {
+	ts := startServer(t)
+	for _, key := range testKeys(t) {
+		if _, err := ts.keys.Add("u1", "", key.line()); err != nil && !errors.Is(err, ErrExists) {
+			t.Fatal(err)
+		}
+		c := ts.dial(t)
+		c.send(c.authRequest("ws1", key, true, false))
+		if p := c.recv(); p[0] != msgUserAuthSuccess {
+			t.Errorf("%s: got message %d, want USERAUTH_SUCCESS", key.algo, p[0])
+		}
+	}
+}
+
+func TestOpenSSH(t *testing.T) {
+	for _, tool := range []string{"ssh", "ssh-keygen", "sftp"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("no %s", tool)
+		}
+	}
+	ts := startServer(t)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", keyFile).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v\n%s", err, out)
+	}
+	pub, err := os.ReadFile(keyFile + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.keys.Add("u1", "", string(pub)); err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(ts.addr)
+	opts := []string{
+		"-F", "/dev/null", "-i", keyFile, "-o", "BatchMode=yes", "-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR",
+	}
+
+	ssh := exec.Command("ssh", append(opts, "-p", port, "ws1@"+host, "echo hello; pwd")...)
+	out, err := ssh.CombinedOutput()
+	if err != nil || string(out) != "hello\n"+ts.dir+"\n" {
+		t.Errorf("ssh = %q, %v", out, err)
+	}
+	// The user only views ws2.
+	if out, err := exec.Command("ssh", append(opts, "-p", port, "ws2@"+host, "true")...).CombinedOutput(); err == nil {
+		t.Errorf("ssh into a workspace the user views = %q, want an error", out)
+	}
+
+	batch := filepath.Join(dir, "batch")
+	if err := os.WriteFile(filepath.Join(dir, "up.txt"), []byte("uploaded"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmds := "put " + filepath.Join(dir, "up.txt") + " /up.txt\nmkdir sub\nrename up.txt sub/up.txt\nls sub\n"
+	if err := os.WriteFile(batch, []byte(cmds), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sftp := exec.Command("sftp", append(opts, "-P", port, "-b", batch, "ws1@"+host)...)
+	out, err = sftp.CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "sub/up.txt") {
+		t.Errorf("sftp = %q, %v", out, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(ts.dir, "sub", "up.txt")); string(data) != "uploaded" {
+		t.Errorf("uploaded file = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,521 @@
+package sshd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/pty"
+)
+
+// Channel limits: the receive window granted to clients, the largest data
+// packet taken and sent, and the channels a connection may have open.
+const (
+	channelWindow    = 2 << 20
+	channelMaxPacket = 32 << 10
+	maxChannels      = 10
+)
+
+// channel is a session channel (RFC 4254 6): one shell, command or sftp
+// server, with the client's data buffered up to the window.
+type channel struct {
+	c    *conn
+	id   uint32
+	peer uint32
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	window     uint32 // what may be sent to the client
+	peerMax    uint32
+	in         bytes.Buffer
+	inWindow   uint32 // what the client may still send
+	inEOF      bool
+	closed     bool // the client closed the channel or the connection ended
+	sentEOF    bool
+	sentClose  bool
+	started    bool
+	wantReply  bool // the start request wants a reply
+	term       string
+	size       pty.Size
+	pty        bool
+	env        []string
+	master     *os.File
+	cmd        *exec.Cmd
+	stopQuotas func()
+}
+
+// loop serves the connection protocol until the client disconnects.
+func (c *conn) loop() error {
+	for {
+		p, err := c.t.readPacket()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r := reader{b: p[1:]}
+		switch p[0] {
+		case msgGlobalRequest:
+			// No global requests, such as port forwarding, are served.
+			r.string()
+			if r.bool() {
+				err = c.t.writePacket([]byte{msgRequestFailure})
+			}
+		case msgChannelOpen:
+			err = c.open(&r)
+		case msgChannelWindowAdjust, msgChannelData, msgChannelExtendedData, msgChannelEOF,
+			msgChannelClose, msgChannelRequest, msgChannelSuccess, msgChannelFailure:
+			id := r.uint32()
+			c.mu.Lock()
+			ch := c.channels[id]
+			c.mu.Unlock()
+			if ch == nil || !r.ok() {
+				c.t.disconnect(disconnectProtocolError, "unknown channel")
+				return fmt.Errorf("sshd: message %d for unknown channel %d", p[0], id)
+			}
+			err = ch.handle(p[0], &r)
+		case msgUserAuthRequest:
+			// Late authentication requests are ignored (RFC 4252 5.1).
+		default:
+			err = c.t.writePacket(*message(msgUnimplemented).uint32(c.t.rd.seq - 1))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// open answers a CHANNEL_OPEN.
+func (c *conn) open(r *reader) error {
+	typ, peer, window, maxPacket := r.string(), r.uint32(), r.uint32(), r.uint32()
+	if !r.ok() {
+		return errMalformed
+	}
+	reject := func(reason uint32, msg string) error {
+		return c.t.writePacket(*message(msgChannelOpenFailure).uint32(peer).uint32(reason).string(msg).string(""))
+	}
+	if typ != "session" {
+		return reject(openUnknownChannelType, "only session channels are supported")
+	}
+	c.mu.Lock()
+	if len(c.channels) >= maxChannels {
+		c.mu.Unlock()
+		return reject(openAdministrativelyProhibited, "too many channels")
+	}
+	ch := &channel{
+		c:        c,
+		id:       c.nextID,
+		peer:     peer,
+		window:   window,
+		peerMax:  min(max(maxPacket, 1024), channelMaxPacket),
+		inWindow: channelWindow,
+		size:     pty.Size{Cols: 80, Rows: 24},
+	}
+	ch.cond = sync.NewCond(&ch.mu)
+	c.channels[ch.id] = ch
+	c.nextID++
+	c.mu.Unlock()
+	return c.t.writePacket(*message(msgChannelOpenConfirm).uint32(peer).uint32(ch.id).uint32(channelWindow).uint32(channelMaxPacket))
+}
+
+// closeChannels ends every channel, as when the connection is lost.
+func (c *conn) closeChannels() {
+	c.mu.Lock()
+	channels := c.channels
+	c.channels = make(map[uint32]*channel)
+	c.mu.Unlock()
+	for _, ch := range channels {
+		ch.hangUp()
+	}
+}
+
+// handle processes a message for the channel.
+func (ch *channel) handle(typ byte, r *reader) error {
+	switch typ {
+	case msgChannelWindowAdjust:
+		n := r.uint32()
+		ch.mu.Lock()
+		if uint64(ch.window)+uint64(n) > 1<<32-1 {
+			ch.window = 1<<32 - 1
+		} else {
+			ch.window += n
+		}
+		ch.cond.Broadcast()
+		ch.mu.Unlock()
+	case msgChannelData, msgChannelExtendedData:
+		if typ == msgChannelExtendedData {
+			r.uint32()
+		}
+		data := r.bytes()
+		if !r.ok() {
+			return errMalformed
+		}
+		ch.mu.Lock()
+		if uint32(len(data)) > ch.inWindow || len(data) > channelMaxPacket {
+			ch.mu.Unlock()
+			ch.c.t.disconnect(disconnectProtocolError, "channel window exceeded")
+			return errors.New("sshd: channel window exceeded")
+		}
+		ch.inWindow -= uint32(len(data))
+		if typ == msgChannelData && !ch.inEOF {
+			ch.in.Write(data)
+			ch.cond.Broadcast()
+			ch.mu.Unlock()
+			return nil
+		}
+		// Nothing reads the client's stderr, so its window is given back
+		// at once.
+		ch.inWindow += uint32(len(data))
+		ch.mu.Unlock()
+		return ch.c.t.writePacket(*message(msgChannelWindowAdjust).uint32(ch.peer).uint32(uint32(len(data))))
+	case msgChannelEOF:
+		ch.mu.Lock()
+		ch.inEOF = true
+		ch.cond.Broadcast()
+		ch.mu.Unlock()
+	case msgChannelClose:
+		ch.c.mu.Lock()
+		delete(ch.c.channels, ch.id)
+		ch.c.mu.Unlock()
+		ch.mu.Lock()
+		sent := ch.sentClose
+		ch.sentClose = true
+		ch.mu.Unlock()
+		ch.hangUp()
+		if !sent {
+			return ch.c.t.writePacket(*message(msgChannelClose).uint32(ch.peer))
+		}
+	case msgChannelRequest:
+		name, wantReply := r.string(), r.bool()
+		if !r.ok() {
+			return errMalformed
+		}
+		ok, replied := ch.request(name, wantReply, r)
+		if wantReply && !replied {
+			return ch.reply(ok)
+		}
+	}
+	return nil
+}
+
+// reply answers a channel request.
+func (ch *channel) reply(ok bool) error {
+	if ok {
+		return ch.c.t.writePacket(*message(msgChannelSuccess).uint32(ch.peer))
+	}
+	return ch.c.t.writePacket(*message(msgChannelFailure).uint32(ch.peer))
+}
+
+// answer replies to the request that started the session, if it wants a
+// reply.
+func (ch *channel) answer(ok bool) error {
+	ch.mu.Lock()
+	want := ch.wantReply
+	ch.mu.Unlock()
+	if !want {
+		return nil
+	}
+	return ch.reply(ok)
+}
+
+// request handles a channel request. It reports whether the request
+// succeeded, and replied for requests answered once their session starts.
+func (ch *channel) request(name string, wantReply bool, r *reader) (ok, replied bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	switch name {
+	case "pty-req":
+		term, cols, rows := r.string(), r.uint32(), r.uint32()
+		if !r.ok() || ch.started {
+			return false, false
+		}
+		ch.pty, ch.term = true, term
+		ch.size = pty.Size{Cols: dim(cols, 80), Rows: dim(rows, 24)}
+		return true, false
+	case "window-change":
+		cols, rows := r.uint32(), r.uint32()
+		if !r.ok() {
+			return false, false
+		}
+		ch.size = pty.Size{Cols: dim(cols, 80), Rows: dim(rows, 24)}
+		if ch.master != nil {
+			pty.Resize(ch.master, ch.size)
+		}
+		return true, false
+	case "env":
+		// Only the locale is taken from the client, as OpenSSH does by
+		// default; the sandbox sets the rest.
+		k, v := r.string(), r.string()
+		if !r.ok() || ch.started || (k != "LANG" && !strings.HasPrefix(k, "LC_")) || strings.ContainsRune(v, 0) {
+			return false, false
+		}
+		ch.env = append(ch.env, k+"="+v)
+		return true, false
+	case "shell", "exec", "subsystem":
+		var arg string
+		if name != "shell" {
+			arg = r.string()
+		}
+		if !r.ok() || ch.started || (name == "subsystem" && arg != "sftp") {
+			return false, false
+		}
+		ch.started, ch.wantReply = true, wantReply
+		go ch.run(name, arg)
+		return true, true
+	}
+	return false, false
+}
+
+// dim clamps a terminal dimension, using def for 0.
+func dim(n uint32, def uint16) uint16 {
+	switch {
+	case n == 0:
+		return def
+	case n > 1000:
+		return 1000
+	}
+	return uint16(n)
+}
+
+// run serves the session the client asked for, kind being shell, exec or
+// subsystem, until it ends.
+func (ch *channel) run(kind, arg string) {
+	c := ch.c
+	if kind == "subsystem" {
+		if ch.answer(true) != nil {
+			return
+		}
+		err := serveSFTP(c.dir, ch, ch.writer(false))
+		if err != nil && !errors.Is(err, io.EOF) {
+			slog.Debug("sftp", "workspace", c.workspaceID, "err", err)
+		}
+		ch.exit(0)
+		return
+	}
+
+	if c.srv.quota != nil {
+		stop, err := c.srv.quota.StartTerminal(c.ctx)
+		if err != nil {
+			fmt.Fprintf(ch.writer(true), "webide: %v\r\n", err)
+			ch.answer(false)
+			ch.exit(1)
+			return
+		}
+		ch.mu.Lock()
+		ch.stopQuotas = stop
+		ch.mu.Unlock()
+	}
+	code, err := ch.start(kind, arg)
+	if err != nil {
+		slog.Error("ssh session", "workspace", c.workspaceID, "err", err)
+		fmt.Fprintf(ch.writer(true), "webide: could not start the session\r\n")
+		ch.answer(false)
+		code = 1
+	}
+	ch.exit(code)
+}
+
+// start runs the shell or command and returns its exit status.
+func (ch *channel) start(kind, command string) (int, error) {
+	c := ch.c
+	argv := c.srv.cfg.Shell
+	if kind == "exec" {
+		argv = []string{"sh", "-c", command}
+	}
+	ch.mu.Lock()
+	env := append(append([]string{}, c.srv.cfg.Env...), ch.env...)
+	usePTY, size := ch.pty, ch.size
+	if usePTY {
+		term := ch.term
+		if term == "" {
+			term = "xterm-256color"
+		}
+		env = append(env, "TERM="+term)
+	}
+	ch.mu.Unlock()
+
+	if !usePTY {
+		cmd, err := c.srv.launcher.Exec(c.ctx, c.workspaceID, c.dir, argv, env)
+		if err != nil {
+			return 0, err
+		}
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return 0, err
+		}
+		cmd.Stdout, cmd.Stderr = ch.writer(false), ch.writer(true)
+		if err := ch.startCmd(cmd); err != nil {
+			return 0, err
+		}
+		go func() {
+			io.Copy(stdin, ch)
+			stdin.Close()
+		}()
+		return exitCode(cmd.Wait()), nil
+	}
+
+	cmd, err := c.srv.launcher.Command(c.ctx, c.workspaceID, c.dir, argv, env)
+	if err != nil {
+		return 0, err
+	}
+	master, err := pty.Start(cmd, size)
+	if err != nil {
+		return 0, err
+	}
+	defer master.Close()
+	ch.mu.Lock()
+	ch.master, ch.cmd = master, cmd
+	closed := ch.closed
+	ch.mu.Unlock()
+	if closed {
+		cmd.Process.Kill()
+	}
+	if err := ch.answer(true); err != nil {
+		cmd.Process.Kill()
+	}
+	go io.Copy(master, ch)
+	// The copy ends once every process holding the terminal has exited.
+	io.Copy(ch.writer(false), master)
+	return exitCode(cmd.Wait()), nil
+}
+
+// startCmd starts a command without a terminal and answers the request.
+func (ch *channel) startCmd(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	ch.mu.Lock()
+	ch.cmd = cmd
+	closed := ch.closed
+	ch.mu.Unlock()
+	if closed || ch.answer(true) != nil {
+		cmd.Process.Kill()
+	}
+	return nil
+}
+
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		return 255
+	}
+	if err != nil {
+		return 255
+	}
+	return 0
+}
+
+// exit reports the session's exit status and closes the channel.
+func (ch *channel) exit(code int) {
+	ch.mu.Lock()
+	stop := ch.stopQuotas
+	ch.stopQuotas = nil
+	done := ch.sentClose
+	ch.sentEOF, ch.sentClose = true, true
+	ch.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	if done {
+		return
+	}
+	t := ch.c.t
+	t.writePacket(*message(msgChannelRequest).uint32(ch.peer).string("exit-status").bool(false).uint32(uint32(code)))
+	t.writePacket(*message(msgChannelEOF).uint32(ch.peer))
+	t.writePacket(*message(msgChannelClose).uint32(ch.peer))
+}
+
+// hangUp ends the channel's session, as the client closed the channel
+// or the connection was lost.
+func (ch *channel) hangUp() {
+	ch.mu.Lock()
+	ch.closed = true
+	ch.cond.Broadcast()
+	cmd, master := ch.cmd, ch.master
+	ch.mu.Unlock()
+	if master != nil {
+		master.Close()
+	}
+	if cmd != nil && cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// Read returns the data the client sent, giving the window back as it is
+// consumed.
+func (ch *channel) Read(p []byte) (int, error) {
+	ch.mu.Lock()
+	for ch.in.Len() == 0 && !ch.inEOF && !ch.closed {
+		ch.cond.Wait()
+	}
+	if ch.in.Len() == 0 {
+		ch.mu.Unlock()
+		return 0, io.EOF
+	}
+	n, _ := ch.in.Read(p)
+	var adjust uint32
+	if consumed := channelWindow - ch.inWindow - uint32(ch.in.Len()); consumed >= channelWindow/2 {
+		adjust = consumed
+		ch.inWindow += consumed
+	}
+	ch.mu.Unlock()
+	if adjust > 0 {
+		if err := ch.c.t.writePacket(*message(msgChannelWindowAdjust).uint32(ch.peer).uint32(adjust)); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// writer returns a writer sending to the client's stdout, or stderr.
+func (ch *channel) writer(stderr bool) io.Writer {
+	return channelWriter{ch, stderr}
+}
+
+type channelWriter struct {
+	ch     *channel
+	stderr bool
+}
+
+// Write sends p as data packets within the client's window, waiting for
+// the window to open.
+func (w channelWriter) Write(p []byte) (int, error) {
+	ch := w.ch
+	written := 0
+	for len(p) > 0 {
+		ch.mu.Lock()
+		for ch.window == 0 && !ch.closed && !ch.sentEOF {
+			ch.cond.Wait()
+		}
+		if ch.closed || ch.sentEOF {
+			ch.mu.Unlock()
+			return written, io.ErrClosedPipe
+		}
+		n := min(uint32(len(p)), ch.window, ch.peerMax)
+		ch.window -= n
+		ch.mu.Unlock()
+
+		var m *builder
+		if w.stderr {
+			m = message(msgChannelExtendedData).uint32(ch.peer).uint32(1).bytes(p[:n])
+		} else {
+			m = message(msgChannelData).uint32(ch.peer).bytes(p[:n])
+		}
+		if err := ch.c.t.writePacket(*m); err != nil {
+			return written, err
+		}
+		written += int(n)
+		p = p[n:]
+	}
+	return written, nil
+}
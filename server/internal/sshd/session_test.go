@@ -0,0 +1,238 @@
+package sshd
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// session is the client side of a session channel.
+type session struct {
+	c      *client
+	id     uint32 // the server's channel ID
+	window uint32 // what may still be sent to the server
+
+	stdout, stderr bytes.Buffer
+	packets        []int // the sizes of the data packets received
+	status         int   // the exit status, -1 until it arrives
+	closed         bool
+}
+
+// openSession opens a session channel granting the server window and
+// maxPacket.
+func (c *client) openSession(window, maxPacket uint32) *session {
+	c.t.Helper()
+	c.send(*message(msgChannelOpen).string("session").uint32(7).uint32(window).uint32(maxPacket))
+	r := c.expect(msgChannelOpenConfirm)
+	peer, id, serverWindow, serverMax := r.uint32(), r.uint32(), r.uint32(), r.uint32()
+	if peer != 7 || serverWindow != channelWindow || serverMax != channelMaxPacket {
+		c.t.Fatalf("CHANNEL_OPEN_CONFIRMATION for %d with window %d and max packet %d", peer, serverWindow, serverMax)
+	}
+	return &session{c: c, id: id, window: serverWindow, status: -1}
+}
+
+// exec starts command in the session.
+func (s *session) exec(command string) {
+	s.c.send(*message(msgChannelRequest).uint32(s.id).string("exec").bool(true).string(command))
+}
+
+// data sends p to the session's stdin.
+func (s *session) data(p []byte) {
+	s.c.send(*message(msgChannelData).uint32(s.id).bytes(p))
+}
+
+// next handles the next message for the session.
+func (s *session) next() {
+	t := s.c.t
+	t.Helper()
+	p := s.c.recv()
+	r := reader{b: p[1:]}
+	if p[0] == msgDisconnect {
+		t.Fatalf("disconnected: %q", p[1:])
+	}
+	if to := r.uint32(); to != 7 {
+		t.Fatalf("message %d for channel %d", p[0], to)
+	}
+	switch p[0] {
+	case msgChannelSuccess, msgChannelEOF:
+	case msgChannelWindowAdjust:
+		s.window += r.uint32()
+	case msgChannelData:
+		data := r.bytes()
+		s.stdout.Write(data)
+		s.packets = append(s.packets, len(data))
+	case msgChannelExtendedData:
+		r.uint32()
+		s.stderr.Write(r.bytes())
+	case msgChannelRequest:
+		if r.string() == "exit-status" && !r.bool() {
+			s.status = int(r.uint32())
+		}
+	case msgChannelClose:
+		s.closed = true
+	default:
+		t.Fatalf("got message %d %q", p[0], p[1:])
+	}
+}
+
+// until handles messages until done reports true.
+func (s *session) until(done func() bool) {
+	s.c.t.Helper()
+	for !done() {
+		if s.closed {
+			s.c.t.Fatalf("channel closed early; stdout %q, stderr %q", s.stdout.Bytes(), s.stderr.Bytes())
+		}
+		s.next()
+	}
+}
+
+func (s *session) done() bool { return s.closed }
+
+// quiet checks that no data arrives for the session for a while.
+func (s *session) quiet() {
+	s.c.t.Helper()
+	n := s.stdout.Len()
+	s.c.nc.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	defer s.c.nc.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		p, err := s.c.ct.readRaw()
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return
+		}
+		if err != nil {
+			s.c.t.Fatal(err)
+		}
+		if p[0] == msgChannelData {
+			s.c.t.Fatalf("got %d bytes past the window, after %d", len(p)-9, n)
+		}
+		if p[0] != msgChannelSuccess {
+			s.c.t.Fatalf("got message %d", p[0])
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	ts := startServer(t)
+	c := ts.login(t)
+	tests := []struct {
+		command        string
+		stdin          string
+		stdout, stderr string
+		status         int
+	}{
+		{"pwd", "", ts.dir + "\n", "", 0},
+		{"cat; echo oops >&2; exit 3", "hello", "hello", "oops\n", 3},
+	}
+	for _, tt := range tests {
+		s := c.openSession(channelWindow, channelMaxPacket)
+		s.exec(tt.command)
+		s.data([]byte(tt.stdin))
+		c.send(*message(msgChannelEOF).uint32(s.id))
+		s.until(s.done)
+		if s.stdout.String() != tt.stdout || s.stderr.String() != tt.stderr || s.status != tt.status {
+			t.Errorf("%q: stdout %q, stderr %q, status %d; want %q, %q, %d",
+				tt.command, s.stdout.Bytes(), s.stderr.Bytes(), s.status, tt.stdout, tt.stderr, tt.status)
+		}
+	}
+}
+
+func TestChannelWindow(t *testing.T) {
+	ts := startServer(t)
+	c := ts.login(t)
+
+	// The server sends no more than the window it was given, in packets
+	// no larger than the client takes, though never below 1024 bytes.
+	s := c.openSession(1000, 100)
+	s.exec("head -c 5000 /dev/zero")
+	s.until(func() bool { return s.stdout.Len() >= 1000 })
+	s.quiet()
+	if s.stdout.Len() != 1000 {
+		t.Fatalf("got %d bytes with a window of 1000", s.stdout.Len())
+	}
+	c.send(*message(msgChannelWindowAdjust).uint32(s.id).uint32(4000))
+	s.until(s.done)
+	if s.stdout.Len() != 5000 || s.status != 0 {
+		t.Errorf("got %d bytes and status %d, want 5000 and 0", s.stdout.Len(), s.status)
+	}
+	for _, n := range s.packets {
+		if n > 1024 {
+			t.Errorf("got a packet of %d bytes, want at most 1024", n)
+		}
+	}
+
+	// Nor packets larger than its own limit.
+	s = c.openSession(1<<20, 1<<20)
+	s.exec("head -c 200000 /dev/zero")
+	s.until(s.done)
+	if s.stdout.Len() != 200000 {
+		t.Errorf("got %d bytes, want 200000", s.stdout.Len())
+	}
+	for _, n := range s.packets {
+		if n > channelMaxPacket {
+			t.Errorf("got a packet of %d bytes, want at most %d", n, channelMaxPacket)
+		}
+	}
+}
+
+func TestChannelWindowReturned(t *testing.T) {
+	// More than the window goes through as the command consumes it.
+	ts := startServer(t)
+	c := ts.login(t)
+	s := c.openSession(channelWindow, channelMaxPacket)
+	s.exec("wc -c")
+	const total = 3 << 20
+	chunk := bytes.Repeat([]byte{'x'}, channelMaxPacket)
+	for sent := 0; sent < total; {
+		s.until(func() bool { return s.window > 0 })
+		n := min(uint32(len(chunk)), s.window, uint32(total-sent))
+		s.data(chunk[:n])
+		s.window -= n
+		sent += int(n)
+	}
+	c.send(*message(msgChannelEOF).uint32(s.id))
+	s.until(s.done)
+	if got := strings.TrimSpace(s.stdout.String()); got != "3145728" || s.status != 0 {
+		t.Errorf("wc -c = %q, status %d", got, s.status)
+	}
+}
+
+func TestChannelWindowExceeded(t *testing.T) {
+	ts := startServer(t)
+	chunk := make([]byte, channelMaxPacket)
+	tests := []struct {
+		name string
+		send func(s *session)
+	}{
+		{"past the window", func(s *session) {
+			// Nothing reads the data, so the window is never returned.
+			for range channelWindow / channelMaxPacket {
+				s.data(chunk)
+			}
+			s.data([]byte{'x'})
+		}},
+		{"packet too large", func(s *session) {
+			s.data(make([]byte, channelMaxPacket+1))
+		}},
+	}
+	for _, tt := range tests {
+		c := ts.login(t)
+		s := c.openSession(channelWindow, channelMaxPacket)
+		tt.send(s)
+		p := c.recv()
+		r := reader{b: p[1:]}
+		if p[0] != msgDisconnect || r.uint32() != disconnectProtocolError || r.string() != "channel window exceeded" {
+			t.Errorf("%s: got message %d %q, want DISCONNECT", tt.name, p[0], p[1:])
+		}
+	}
+}
+
+func TestUnknownChannel(t *testing.T) {
+	ts := startServer(t)
+	c := ts.login(t)
+	c.send(*message(msgChannelData).uint32(42).string("x"))
+	c.expect(msgDisconnect)
+}
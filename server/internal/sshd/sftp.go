@@ -0,0 +1,594 @@
+package sshd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// SFTP version 3 packet types, status codes and flags
+// (draft-ietf-secsh-filexfer-02), as OpenSSH implements them.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpFstat    = 8
+	sftpSetstat  = 9
+	sftpFsetstat = 10
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRmdir    = 15
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpReadlink = 19
+	sftpSymlink  = 20
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+	sftpExtended = 200
+
+	statusOK               = 0
+	statusEOF              = 1
+	statusNoSuchFile       = 2
+	statusPermissionDenied = 3
+	statusFailure          = 4
+	statusBadMessage       = 5
+	statusOpUnsupported    = 8
+
+	attrSize        = 0x1
+	attrUIDGID      = 0x2
+	attrPermissions = 0x4
+	attrTimes       = 0x8
+	attrExtended    = 0x80000000
+
+	openRead   = 0x1
+	openWrite  = 0x2
+	openAppend = 0x4
+	openCreate = 0x8
+	openTrunc  = 0x10
+	openExcl   = 0x20
+)
+
+// SFTP limits: the largest request taken, the most read at once and the
+// handles a session may have open.
+const (
+	sftpMaxPacket  = 512 << 10
+	sftpMaxRead    = 256 << 10
+	sftpMaxHandles = 256
+	sftpDirBatch   = 100
+)
+
+// errUnsupported answers the requests the server does not implement.
+var errUnsupported = errors.New("sshd: operation not supported")
+
+// sftpServer serves the files of one workspace, with its root as "/".
+type sftpServer struct {
+	fs      *files.FS
+	w       *bufio.Writer
+	handles map[string]*openFile
+	next    int
+}
+
+type openFile struct {
+	f      *os.File
+	append bool
+	dir    string // the directory's path, for directory handles
+	ents   []os.DirEntry
+}
+
+// serveSFTP serves the SFTP requests read from r, answering on w, until
+// the client is done.
+func serveSFTP(root string, r io.Reader, w io.Writer) error {
+	fsys, err := files.New(root)
+	if err != nil {
+		return err
+	}
+	s := &sftpServer{fs: fsys, w: bufio.NewWriterSize(w, 64<<10), handles: make(map[string]*openFile)}
+	defer func() {
+		for _, h := range s.handles {
+			h.f.Close()
+		}
+	}()
+	br := bufio.NewReaderSize(r, 64<<10)
+	for {
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
+			return err
+		}
+		n := binary.BigEndian.Uint32(lenBuf[:])
+		if n < 1 || n > sftpMaxPacket {
+			return fmt.Errorf("sshd: bad sftp packet length %d", n)
+		}
+		p := make([]byte, n)
+		if _, err := io.ReadFull(br, p); err != nil {
+			return err
+		}
+		if p[0] == sftpInit {
+			s.send(*message(sftpVersion).uint32(3).string("posix-rename@openssh.com").string("1"))
+		} else {
+			rd := reader{b: p[1:]}
+			id := rd.uint32()
+			s.send(s.handle(p[0], id, &rd))
+		}
+		if br.Buffered() == 0 {
+			// Replies are flushed once the requests in hand are answered.
+			if err := s.w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send writes a reply packet.
+func (s *sftpServer) send(p []byte) {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(p)))
+	s.w.Write(lenBuf[:])
+	s.w.Write(p)
+}
+
+func status(id, code uint32, msg string) []byte {
+	return *message(sftpStatus).uint32(id).uint32(code).string(msg).string("en")
+}
+
+// errStatus maps err to the status reply for request id.
+func errStatus(id uint32, err error) []byte {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	msg := err.Error()
+	switch {
+	case errors.As(err, &pathErr):
+		msg = pathErr.Err.Error()
+	case errors.As(err, &linkErr):
+		msg = linkErr.Err.Error()
+	}
+	switch {
+	case errors.Is(err, io.EOF):
+		return status(id, statusEOF, "end of file")
+	case errors.Is(err, fs.ErrNotExist):
+		return status(id, statusNoSuchFile, "no such file")
+	case errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrRoot), errors.Is(err, fs.ErrPermission):
+		return status(id, statusPermissionDenied, "permission denied")
+	case errors.Is(err, errUnsupported):
+		return status(id, statusOpUnsupported, "operation not supported")
+	case errors.Is(err, errMalformed):
+		return status(id, statusBadMessage, "malformed request")
+	}
+	return status(id, statusFailure, msg)
+}
+
+// resolve maps a client path to a host path in the workspace. Paths are
+// relative to "/", the workspace root, and ".." stops there.
+func (s *sftpServer) resolve(p string) (string, error) {
+	return s.fs.Resolve(path.Clean("/" + p))
+}
+
+// resolveLink is resolve without following a symlink in the last element,
+// for the operations on links themselves.
+func (s *sftpServer) resolveLink(p string) (string, error) {
+	return s.fs.ResolveLink(path.Clean("/" + p))
+}
+
+// notRoot rejects operations that would remove or move the workspace.
+func (s *sftpServer) notRoot(abs string) error {
+	if abs == s.fs.Root() {
+		return files.ErrRoot
+	}
+	return nil
+}
+
+// handle answers one request.
+func (s *sftpServer) handle(typ byte, id uint32, r *reader) []byte {
+	var reply []byte
+	var err error
+	switch typ {
+	case sftpOpen:
+		reply, err = s.open(id, r)
+	case sftpClose:
+		name := r.string()
+		h := s.handles[name]
+		if h == nil {
+			return status(id, statusFailure, "invalid handle")
+		}
+		delete(s.handles, name)
+		err = h.f.Close()
+	case sftpRead:
+		reply, err = s.read(id, r)
+	case sftpWrite:
+		h, off, data := s.handles[r.string()], r.uint64(), r.bytes()
+		switch {
+		case !r.ok():
+			err = errMalformed
+		case h == nil || h.dir != "":
+			return status(id, statusFailure, "invalid handle")
+		case h.append:
+			_, err = h.f.Write(data)
+		default:
+			_, err = h.f.WriteAt(data, int64(off))
+		}
+	case sftpStat, sftpLstat:
+		p := r.string()
+		var abs string
+		if typ == sftpStat {
+			abs, err = s.resolve(p)
+		} else {
+			abs, err = s.resolveLink(p)
+		}
+		if err == nil {
+			var fi fs.FileInfo
+			if fi, err = os.Lstat(abs); err == nil {
+				reply = *attrs(message(sftpAttrs).uint32(id), fi)
+			}
+		}
+	case sftpFstat:
+		h := s.handles[r.string()]
+		if h == nil {
+			return status(id, statusFailure, "invalid handle")
+		}
+		var fi fs.FileInfo
+		if fi, err = h.f.Stat(); err == nil {
+			reply = *attrs(message(sftpAttrs).uint32(id), fi)
+		}
+	case sftpSetstat:
+		var abs string
+		if abs, err = s.resolve(r.string()); err == nil {
+			err = setAttrs(abs, nil, r)
+		}
+	case sftpFsetstat:
+		h := s.handles[r.string()]
+		if h == nil {
+			return status(id, statusFailure, "invalid handle")
+		}
+		err = setAttrs(h.f.Name(), h.f, r)
+	case sftpOpendir:
+		reply, err = s.opendir(id, r.string())
+	case sftpReaddir:
+		reply, err = s.readdir(id, r.string())
+	case sftpRemove:
+		var abs string
+		if abs, err = s.resolveLink(r.string()); err == nil {
+			if err = s.notRoot(abs); err == nil {
+				var fi fs.FileInfo
+				if fi, err = os.Lstat(abs); err == nil && fi.IsDir() {
+					err = files.ErrIsDir
+				} else if err == nil {
+					err = os.Remove(abs)
+				}
+			}
+		}
+	case sftpMkdir:
+		p := r.string()
+		mode := fs.FileMode(0o755)
+		if a := readAttrs(r); a.flags&attrPermissions != 0 {
+			mode = fs.FileMode(a.perm) & fs.ModePerm
+		}
+		var abs string
+		if abs, err = s.resolve(p); err == nil {
+			err = os.Mkdir(abs, mode)
+		}
+	case sftpRmdir:
+		var abs string
+		if abs, err = s.resolveLink(r.string()); err == nil {
+			if err = s.notRoot(abs); err == nil {
+				var fi fs.FileInfo
+				if fi, err = os.Lstat(abs); err == nil && !fi.IsDir() {
+					err = files.ErrNotDir
+				} else if err == nil {
+					err = os.Remove(abs)
+				}
+			}
+		}
+	case sftpRealpath:
+		p := path.Clean("/" + r.string())
+		reply = *message(sftpName).uint32(id).uint32(1).string(p).string(p).uint32(0)
+	case sftpRename:
+		err = s.rename(r.string(), r.string(), false)
+	case sftpReadlink:
+		var abs, target string
+		if abs, err = s.resolveLink(r.string()); err == nil {
+			if target, err = os.Readlink(abs); err == nil {
+				reply = *message(sftpName).uint32(id).uint32(1).string(target).string(target).uint32(0)
+			}
+		}
+	case sftpExtended:
+		switch r.string() {
+		case "posix-rename@openssh.com":
+			err = s.rename(r.string(), r.string(), true)
+		default:
+			err = errUnsupported
+		}
+	case sftpSymlink:
+		// A link could name anything on the host, so none are created.
+		err = errUnsupported
+	default:
+		err = errUnsupported
+	}
+	if err == nil && !r.ok() {
+		err = errMalformed
+	}
+	if err != nil {
+		return errStatus(id, err)
+	}
+	if reply == nil {
+		return status(id, statusOK, "")
+	}
+	return reply
+}
+
+func (s *sftpServer) newHandle(h *openFile) ([]byte, error) {
+	if len(s.handles) >= sftpMaxHandles {
+		h.f.Close()
+		return nil, errors.New("too many open handles")
+	}
+	s.next++
+	name := strconv.Itoa(s.next)
+	s.handles[name] = h
+	return []byte(name), nil
+}
+
+func (s *sftpServer) open(id uint32, r *reader) ([]byte, error) {
+	p, pflags, a := r.string(), r.uint32(), readAttrs(r)
+	if !r.ok() {
+		return nil, errMalformed
+	}
+	abs, err := s.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	var flag int
+	switch {
+	case pflags&openRead != 0 && pflags&openWrite != 0:
+		flag = os.O_RDWR
+	case pflags&openWrite != 0 || pflags&openAppend != 0:
+		flag = os.O_WRONLY
+	default:
+		flag = os.O_RDONLY
+	}
+	if pflags&openAppend != 0 {
+		flag |= os.O_APPEND
+	}
+	if pflags&openCreate != 0 {
+		flag |= os.O_CREATE
+	}
+	if pflags&openTrunc != 0 {
+		flag |= os.O_TRUNC
+	}
+	if pflags&openExcl != 0 {
+		flag |= os.O_EXCL
+	}
+	mode := fs.FileMode(0o644)
+	if a.flags&attrPermissions != 0 {
+		mode = fs.FileMode(a.perm) & fs.ModePerm
+	}
+	f, err := os.OpenFile(abs, flag, mode)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err == nil && fi.IsDir() {
+		f.Close()
+		return nil, files.ErrIsDir
+	}
+	name, err := s.newHandle(&openFile{f: f, append: pflags&openAppend != 0})
+	if err != nil {
+		return nil, err
+	}
+	return *message(sftpHandle).uint32(id).bytes(name), nil
+}
+
+func (s *sftpServer) read(id uint32, r *reader) ([]byte, error) {
+	h, off, n := s.handles[r.string()], r.uint64(), r.uint32()
+	if !r.ok() {
+		return nil, errMalformed
+	}
+	if h == nil || h.dir != "" {
+		return status(id, statusFailure, "invalid handle"), nil
+	}
+	buf := make([]byte, min(n, sftpMaxRead))
+	m, err := h.f.ReadAt(buf, int64(off))
+	if m == 0 && err != nil {
+		return nil, err
+	}
+	return *message(sftpData).uint32(id).bytes(buf[:m]), nil
+}
+
+func (s *sftpServer) opendir(id uint32, p string) ([]byte, error) {
+	abs, err := s.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := f.ReadDir(-1)
+	if err != nil {
+		f.Close()
+		if fi, statErr := os.Stat(abs); statErr == nil && !fi.IsDir() {
+			return nil, files.ErrNotDir
+		}
+		return nil, err
+	}
+	name, err := s.newHandle(&openFile{f: f, dir: abs, ents: ents})
+	if err != nil {
+		return nil, err
+	}
+	return *message(sftpHandle).uint32(id).bytes(name), nil
+}
+
+// readdir returns the next batch of a directory's entries, leaving out
+// those of writes in flight.
+func (s *sftpServer) readdir(id uint32, name string) ([]byte, error) {
+	h := s.handles[name]
+	if h == nil || h.dir == "" {
+		return status(id, statusFailure, "invalid handle"), nil
+	}
+	b := message(sftpName).uint32(id).uint32(0)
+	count := 0
+	for len(h.ents) > 0 && count < sftpDirBatch {
+		e := h.ents[0]
+		h.ents = h.ents[1:]
+		if strings.HasPrefix(e.Name(), files.TempPrefix) {
+			continue
+		}
+		fi, err := os.Lstat(filepath.Join(h.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		b.string(e.Name()).string(longName(fi))
+		attrs(b, fi)
+		count++
+	}
+	if count == 0 {
+		return nil, io.EOF
+	}
+	binary.BigEndian.PutUint32((*b)[5:], uint32(count))
+	return *b, nil
+}
+
+// rename moves from to to. Version 3 renames fail when to exists;
+// posix-rename@openssh.com replaces it.
+func (s *sftpServer) rename(from, to string, replace bool) error {
+	src, err := s.resolveLink(from)
+	if err != nil {
+		return err
+	}
+	dst, err := s.resolveLink(to)
+	if err != nil {
+		return err
+	}
+	if err := s.notRoot(src); err != nil {
+		return err
+	}
+	if err := s.notRoot(dst); err != nil {
+		return err
+	}
+	if !replace {
+		if _, err := os.Lstat(dst); err == nil {
+			return files.ErrExists
+		}
+	}
+	return os.Rename(src, dst)
+}
+
+// fileAttrs are the attributes of a request.
+type fileAttrs struct {
+	flags        uint32
+	size         uint64
+	perm         uint32
+	atime, mtime uint32
+}
+
+func readAttrs(r *reader) fileAttrs {
+	var a fileAttrs
+	a.flags = r.uint32()
+	if a.flags&attrSize != 0 {
+		a.size = r.uint64()
+	}
+	if a.flags&attrUIDGID != 0 {
+		r.uint32()
+		r.uint32()
+	}
+	if a.flags&attrPermissions != 0 {
+		a.perm = r.uint32()
+	}
+	if a.flags&attrTimes != 0 {
+		a.atime, a.mtime = r.uint32(), r.uint32()
+	}
+	if a.flags&attrExtended != 0 {
+		for n := r.uint32(); n > 0 && r.ok(); n-- {
+			r.string()
+			r.string()
+		}
+	}
+	return a
+}
+
+// setAttrs applies the attributes in r to the file at abs, or to f if it
+// is open. Owners are not changed.
+func setAttrs(abs string, f *os.File, r *reader) error {
+	a := readAttrs(r)
+	if !r.ok() {
+		return errMalformed
+	}
+	if a.flags&attrSize != 0 {
+		var err error
+		if f != nil {
+			err = f.Truncate(int64(a.size))
+		} else {
+			err = os.Truncate(abs, int64(a.size))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if a.flags&attrPermissions != 0 {
+		if err := os.Chmod(abs, fs.FileMode(a.perm)&fs.ModePerm); err != nil {
+			return err
+		}
+	}
+	if a.flags&attrTimes != 0 {
+		if err := os.Chtimes(abs, time.Unix(int64(a.atime), 0), time.Unix(int64(a.mtime), 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unix file type bits, which SFTP clients expect in the permissions.
+const (
+	typeDir     = 0o040000
+	typeFile    = 0o100000
+	typeSymlink = 0o120000
+)
+
+// attrs appends the attributes of fi to b.
+func attrs(b *builder, fi fs.FileInfo) *builder {
+	mode := uint32(fi.Mode().Perm())
+	switch {
+	case fi.IsDir():
+		mode |= typeDir
+	case fi.Mode()&fs.ModeSymlink != 0:
+		mode |= typeSymlink
+	default:
+		mode |= typeFile
+	}
+	mtime := uint32(fi.ModTime().Unix())
+	return b.uint32(attrSize | attrPermissions | attrTimes).uint64(uint64(fi.Size())).uint32(mode).uint32(mtime).uint32(mtime)
+}
+
+// longName formats fi as ls -l does, which is how sftp lists files.
+func longName(fi fs.FileInfo) string {
+	typ := "-"
+	switch {
+	case fi.IsDir():
+		typ = "d"
+	case fi.Mode()&fs.ModeSymlink != 0:
+		typ = "l"
+	}
+	layout := "Jan _2 15:04"
+	if time.Since(fi.ModTime()) > 180*24*time.Hour {
+		layout = "Jan _2  2006"
+	}
+	return fmt.Sprintf("%s%s    1 webide   webide   %8d %s %s",
+		typ, fi.Mode().Perm().String()[1:], fi.Size(), fi.ModTime().Format(layout), fi.Name())
+}
@@ -0,0 +1,285 @@
+package sshd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// sftpClient speaks SFTP to serveSFTP over pipes.
+type sftpClient struct {
+	t  *testing.T
+	w  io.Writer
+	r  *bufio.Reader
+	id uint32
+}
+
+func newSFTP(t *testing.T, root string) *sftpClient {
+	t.Helper()
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveSFTP(root, reqR, respW)
+		respW.Close()
+	}()
+	t.Cleanup(func() {
+		reqW.Close()
+		<-done
+	})
+	c := &sftpClient{t: t, w: reqW, r: bufio.NewReader(respR)}
+	c.write(*message(sftpInit).uint32(3))
+	if p := c.read(); p[0] != sftpVersion || binary.BigEndian.Uint32(p[1:]) != 3 {
+		t.Fatalf("INIT answered with %q", p)
+	}
+	return c
+}
+
+func (c *sftpClient) write(p []byte) {
+	c.t.Helper()
+	if _, err := c.w.Write(binary.BigEndian.AppendUint32(nil, uint32(len(p)))); err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.w.Write(p); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *sftpClient) read() []byte {
+	c.t.Helper()
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(c.r, lenBuf[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	p := make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+	if _, err := io.ReadFull(c.r, p); err != nil {
+		c.t.Fatal(err)
+	}
+	return p
+}
+
+// call sends a request of type typ with fields and returns the type of
+// the reply and a reader of its fields.
+func (c *sftpClient) call(typ byte, fields func(b *builder)) (byte, *reader) {
+	c.t.Helper()
+	c.id++
+	b := message(typ).uint32(c.id)
+	fields(b)
+	c.write(*b)
+	p := c.read()
+	r := &reader{b: p[1:]}
+	if id := r.uint32(); id != c.id {
+		c.t.Fatalf("reply for request %d, want %d", id, c.id)
+	}
+	return p[0], r
+}
+
+// status sends a request answered with a status and returns its code, or
+// -1 for any other reply.
+func (c *sftpClient) status(typ byte, fields func(b *builder)) int {
+	c.t.Helper()
+	reply, r := c.call(typ, fields)
+	if reply != sftpStatus {
+		return -1
+	}
+	return int(r.uint32())
+}
+
+// open opens p with the given flags and returns the handle, or "" and the
+// status code.
+func (c *sftpClient) open(p string, flags uint32) (string, int) {
+	c.t.Helper()
+	reply, r := c.call(sftpOpen, func(b *builder) { b.string(p).uint32(flags).uint32(0) })
+	if reply == sftpHandle {
+		return r.string(), statusOK
+	}
+	return "", int(r.uint32())
+}
+
+func path1(p string) func(b *builder) {
+	return func(b *builder) { b.string(p) }
+}
+
+func path2(from, to string) func(b *builder) {
+	return func(b *builder) { b.string(from).string(to) }
+}
+
+// sftpTree returns a workspace root and a directory outside it, which the
+// root's "out" links to and its "dangling" links to a file to be in.
+func sftpTree(t *testing.T) (root, outside string) {
+	root, outside = t.TempDir(), t.TempDir()
+	for name, data := range map[string]string{
+		filepath.Join(root, "file.txt"):      "hello",
+		filepath.Join(outside, "secret.txt"): "secret",
+	} {
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "new.txt"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/../dangling", filepath.Join(root, "relative")); err != nil {
+		t.Fatal(err)
+	}
+	return root, outside
+}
+
+func TestSFTPConfinement(t *testing.T) {
+	root, outside := sftpTree(t)
+	c := newSFTP(t, root)
+
+	// The root is "/", and ".." stops there.
+	for _, p := range []string{"..", "/../..", "dir/../../.."} {
+		reply, r := c.call(sftpRealpath, path1(p))
+		if n := r.uint32(); reply != sftpName || n != 1 || r.string() != "/" {
+			t.Errorf("REALPATH(%q) is not /", p)
+		}
+	}
+	if _, code := c.open("../../"+filepath.Join(outside, "secret.txt"), openRead); code != statusNoSuchFile {
+		t.Errorf("OPEN of the outside path within the root = %d, want no such file", code)
+	}
+
+	tests := []struct {
+		name string
+		typ  byte
+		req  func(b *builder)
+	}{
+		{"OPEN through a link", sftpOpen, func(b *builder) { b.string("out/secret.txt").uint32(openRead).uint32(0) }},
+		{"create through a dangling link", sftpOpen, func(b *builder) { b.string("dangling").uint32(openWrite | openCreate).uint32(0) }},
+		{"create through a relative dangling link", sftpOpen, func(b *builder) { b.string("relative").uint32(openWrite | openCreate).uint32(0) }},
+		{"OPENDIR through a link", sftpOpendir, path1("out")},
+		{"STAT through a link", sftpStat, path1("out/secret.txt")},
+		{"SETSTAT through a link", sftpSetstat, func(b *builder) { b.string("out/secret.txt").uint32(attrPermissions).uint32(0o777) }},
+		{"MKDIR through a link", sftpMkdir, func(b *builder) { b.string("out/dir").uint32(0) }},
+		{"REMOVE through a link", sftpRemove, path1("out/secret.txt")},
+		{"REMOVE of the root", sftpRemove, path1("/")},
+		{"RMDIR of the root", sftpRmdir, path1("..")},
+		{"RENAME of the root", sftpRename, path2("/", "moved")},
+		{"RENAME onto the root", sftpRename, path2("dir", "/")},
+		{"RENAME into a link", sftpRename, path2("file.txt", "out/file.txt")},
+		{"RENAME out of a link", sftpRename, path2("out/secret.txt", "stolen.txt")},
+	}
+	for _, tt := range tests {
+		if code := c.status(tt.typ, tt.req); code != statusPermissionDenied {
+			t.Errorf("%s = %d, want permission denied", tt.name, code)
+		}
+	}
+	if code := c.status(sftpSymlink, path2("link", "/etc/passwd")); code != statusOpUnsupported {
+		t.Errorf("SYMLINK = %d, want unsupported", code)
+	}
+
+	ents, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 1 || ents[0].Name() != "secret.txt" {
+		t.Errorf("outside directory has %v", ents)
+	}
+	if fi, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil || fi.Mode().Perm() != 0o644 {
+		t.Errorf("outside file: %v, %v", fi, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "file.txt")); err != nil {
+		t.Errorf("file.txt was moved: %v", err)
+	}
+
+	// Links themselves can be looked at and removed.
+	reply, r := c.call(sftpLstat, path1("out"))
+	a := readAttrs(r)
+	if reply != sftpAttrs || a.perm&0o170000 != typeSymlink {
+		t.Errorf("LSTAT of a link = %d, mode %o, want a symlink", reply, a.perm)
+	}
+	if code := c.status(sftpRemove, path1("out")); code != statusOK {
+		t.Errorf("REMOVE of a link = %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil {
+		t.Errorf("REMOVE of a link removed its target: %v", err)
+	}
+}
+
+func TestSFTPFiles(t *testing.T) {
+	root, _ := sftpTree(t)
+	c := newSFTP(t, root)
+
+	h, code := c.open("dir/new.txt", openWrite|openCreate|openTrunc)
+	if code != statusOK {
+		t.Fatalf("OPEN for writing = %d", code)
+	}
+	if code := c.status(sftpWrite, func(b *builder) { b.string(h).uint64(0).string("hello, world") }); code != statusOK {
+		t.Errorf("WRITE = %d", code)
+	}
+	if code := c.status(sftpWrite, func(b *builder) { b.string(h).uint64(7).string("sftp!") }); code != statusOK {
+		t.Errorf("WRITE at an offset = %d", code)
+	}
+	if code := c.status(sftpClose, path1(h)); code != statusOK {
+		t.Errorf("CLOSE = %d", code)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "dir", "new.txt")); string(data) != "hello, sftp!" {
+		t.Errorf("written file = %q, %v", data, err)
+	}
+
+	h, code = c.open("/dir/../dir/new.txt", openRead)
+	if code != statusOK {
+		t.Fatalf("OPEN for reading = %d", code)
+	}
+	reply, r := c.call(sftpRead, func(b *builder) { b.string(h).uint64(7).uint32(100) })
+	if reply != sftpData || r.string() != "sftp!" {
+		t.Errorf("READ did not return the end of the file")
+	}
+	if code := c.status(sftpRead, func(b *builder) { b.string(h).uint64(12).uint32(100) }); code != statusEOF {
+		t.Errorf("READ at the end = %d, want EOF", code)
+	}
+	c.status(sftpClose, path1(h))
+	if code := c.status(sftpClose, path1(h)); code != statusFailure {
+		t.Errorf("second CLOSE = %d, want failure", code)
+	}
+
+	reply, r = c.call(sftpOpendir, path1("/"))
+	if reply != sftpHandle {
+		t.Fatalf("OPENDIR = %d", reply)
+	}
+	h = r.string()
+	var names []string
+	for {
+		reply, r := c.call(sftpReaddir, path1(h))
+		if reply == sftpStatus {
+			if code := r.uint32(); code != statusEOF {
+				t.Errorf("READDIR = %d", code)
+			}
+			break
+		}
+		for n := r.uint32(); n > 0; n-- {
+			names = append(names, r.string())
+			r.string()
+			readAttrs(r)
+		}
+	}
+	slices.Sort(names)
+	if want := []string{"dangling", "dir", "file.txt", "out", "relative"}; !slices.Equal(names, want) {
+		t.Errorf("READDIR = %v, want %v", names, want)
+	}
+
+	if code := c.status(sftpRename, path2("dir/new.txt", "file.txt")); code != statusFailure {
+		t.Errorf("RENAME onto a file = %d, want failure", code)
+	}
+	if code := c.status(sftpExtended, func(b *builder) { b.string("posix-rename@openssh.com").string("dir/new.txt").string("file.txt") }); code != statusOK {
+		t.Errorf("posix-rename onto a file = %d", code)
+	}
+	if code := c.status(sftpRmdir, path1("dir")); code != statusOK {
+		t.Errorf("RMDIR = %d", code)
+	}
+	if code := c.status(sftpStat, path1("dir")); code != statusNoSuchFile {
+		t.Errorf("STAT of a removed directory = %d, want no such file", code)
+	}
+}
@@ -0,0 +1,441 @@
+package sshd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// serverVersion is sent in the version exchange.
+const serverVersion = "SSH-2.0-webide"
+
+// maxPacket bounds a received packet, above the 32 KiB plus headers every
+// implementation must accept.
+const maxPacket = 256 << 10
+
+// The algorithms offered, in order of preference. Only AEAD ciphers are
+// offered, so no MAC is ever used; the MAC list is sent because the
+// negotiation requires one.
+var (
+	kexAlgos     = []string{"curve25519-sha256", "curve25519-sha256@libssh.org"}
+	hostKeyAlgos = []string{"ssh-ed25519"}
+	cipherAlgos  = []string{"aes128-gcm@openssh.com", "aes256-gcm@openssh.com"}
+	macAlgos     = []string{"hmac-sha2-256"}
+	compAlgos    = []string{"none"}
+)
+
+// strictKex and extInfo are pseudo-algorithms of the kex list: strict key
+// exchange, which closes the prefix truncation attack on the handshake,
+// and the client's support for the EXT_INFO message.
+const (
+	strictKexClient = "kex-strict-c-v00@openssh.com"
+	strictKexServer = "kex-strict-s-v00@openssh.com"
+	extInfoClient   = "ext-info-c"
+)
+
+// transport is the server side of the SSH transport protocol (RFC 4253):
+// the version exchange, key exchange and binary packets, encrypted once
+// keys are in place. Key re-exchanges the client starts are handled
+// within readPacket.
+type transport struct {
+	conn    net.Conn
+	br      *bufio.Reader
+	hostKey ed25519.PrivateKey
+
+	clientVersion []byte
+	sessionID     []byte
+	strict        bool
+	extInfo       bool
+
+	rd  direction // only used by the reading goroutine
+	wmu sync.Mutex
+	wr  direction
+}
+
+// direction is the packet cipher of one direction; a nil aead means none.
+type direction struct {
+	aead  cipher.AEAD
+	nonce [12]byte
+	seq   uint32
+}
+
+func newTransport(conn net.Conn, hostKey ed25519.PrivateKey) *transport {
+	return &transport{conn: conn, br: bufio.NewReaderSize(conn, 64<<10), hostKey: hostKey}
+}
+
+// handshake exchanges versions and keys.
+func (t *transport) handshake() error {
+	if _, err := io.WriteString(t.conn, serverVersion+"\r\n"); err != nil {
+		return err
+	}
+	// Lines before the version are allowed, within reason.
+	for i := 0; ; i++ {
+		line, err := t.br.ReadSlice('\n')
+		if err != nil {
+			return fmt.Errorf("sshd: read version: %w", err)
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			if !bytes.HasPrefix(line, []byte("SSH-2.0-")) && !bytes.HasPrefix(line, []byte("SSH-1.99-")) {
+				return fmt.Errorf("sshd: unsupported version %q", line)
+			}
+			t.clientVersion = bytes.Clone(line)
+			break
+		}
+		if i == 20 {
+			return errors.New("sshd: no version line")
+		}
+	}
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	return t.kexLocked(nil)
+}
+
+// kexInit returns the KEXINIT message of the server.
+func kexInit() []byte {
+	var cookie [16]byte
+	rand.Read(cookie[:])
+	b := message(msgKexInit)
+	*b = append(*b, cookie[:]...)
+	b.nameList(append(slices.Clip(kexAlgos), strictKexServer)).nameList(hostKeyAlgos).
+		nameList(cipherAlgos).nameList(cipherAlgos).
+		nameList(macAlgos).nameList(macAlgos).
+		nameList(compAlgos).nameList(compAlgos).
+		nameList(nil).nameList(nil).
+		bool(false).uint32(0)
+	return *b
+}
+
+// negotiate returns the first of the client's algorithms the server
+// supports.
+func negotiate(client, server []string) (string, bool) {
+	for _, c := range client {
+		if slices.Contains(server, c) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// kexLocked performs a key exchange, the initial one when peerInit is nil,
+// else a re-exchange the client started with that KEXINIT. The write lock
+// is held throughout, so nothing else is sent meanwhile.
+func (t *transport) kexLocked(peerInit []byte) error {
+	first := t.sessionID == nil
+	ours := kexInit()
+	if err := t.writeLocked(ours); err != nil {
+		return err
+	}
+	if peerInit == nil {
+		p, err := t.readKexPacket(first)
+		if err != nil {
+			return err
+		}
+		if p[0] != msgKexInit {
+			return fmt.Errorf("sshd: expected KEXINIT, got message %d", p[0])
+		}
+		peerInit = p
+	}
+
+	r := reader{b: peerInit[17:]}
+	kexList, hostKeyList := r.nameList(), r.nameList()
+	cipherC2S, cipherS2C := r.nameList(), r.nameList()
+	r.nameList() // MACs, unused with AEAD ciphers
+	r.nameList()
+	compC2S, compS2C := r.nameList(), r.nameList()
+	r.nameList()
+	r.nameList()
+	guessed := r.bool()
+	if !r.ok() {
+		return errMalformed
+	}
+	if first {
+		t.strict = slices.Contains(kexList, strictKexClient)
+		t.extInfo = slices.Contains(kexList, extInfoClient)
+	}
+	kexAlgo, ok1 := negotiate(kexList, kexAlgos)
+	_, ok2 := negotiate(hostKeyList, hostKeyAlgos)
+	c2s, ok3 := negotiate(cipherC2S, cipherAlgos)
+	s2c, ok4 := negotiate(cipherS2C, cipherAlgos)
+	_, ok5 := negotiate(compC2S, compAlgos)
+	_, ok6 := negotiate(compS2C, compAlgos)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 {
+		t.disconnectLocked(disconnectKexFailed, "no common algorithms")
+		return errors.New("sshd: no common algorithms")
+	}
+	if guessed && (kexList[0] != kexAlgo || hostKeyList[0] != hostKeyAlgos[0]) {
+		// The client's guessed first packet is for another algorithm.
+		if _, err := t.readKexPacket(first); err != nil {
+			return err
+		}
+	}
+
+	p, err := t.readKexPacket(first)
+	if err != nil {
+		return err
+	}
+	r = reader{b: p[1:]}
+	clientPub := r.bytes()
+	if p[0] != msgKexECDHInit || !r.ok() {
+		return fmt.Errorf("sshd: expected KEX_ECDH_INIT, got message %d", p[0])
+	}
+	peer, err := ecdh.X25519().NewPublicKey(clientPub)
+	if err != nil {
+		return fmt.Errorf("sshd: client key: %w", err)
+	}
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	secret, err := priv.ECDH(peer)
+	if err != nil {
+		return fmt.Errorf("sshd: key exchange: %w", err)
+	}
+	serverPub := priv.PublicKey().Bytes()
+	hostKeyBlob := *new(builder).string("ssh-ed25519").bytes(t.hostKey.Public().(ed25519.PublicKey))
+	k := *new(builder).mpintBytes(secret)
+
+	h := sha256.New()
+	h.Write(*new(builder).bytes(t.clientVersion))
+	h.Write(*new(builder).string(serverVersion))
+	h.Write(*new(builder).bytes(peerInit))
+	h.Write(*new(builder).bytes(ours))
+	h.Write(*new(builder).bytes(hostKeyBlob))
+	h.Write(*new(builder).bytes(clientPub))
+	h.Write(*new(builder).bytes(serverPub))
+	h.Write(k)
+	exchangeHash := h.Sum(nil)
+	if first {
+		t.sessionID = exchangeHash
+	}
+	sig := *new(builder).string("ssh-ed25519").bytes(ed25519.Sign(t.hostKey, exchangeHash))
+	reply := message(msgKexECDHReply).bytes(hostKeyBlob).bytes(serverPub).bytes(sig)
+	if err := t.writeLocked(*reply); err != nil {
+		return err
+	}
+	if err := t.writeLocked([]byte{msgNewKeys}); err != nil {
+		return err
+	}
+	wr, err := t.keys(k, exchangeHash, 'B', 'D', s2c)
+	if err != nil {
+		return err
+	}
+	t.wr.aead, t.wr.nonce = wr.aead, wr.nonce
+	if t.strict {
+		t.wr.seq = 0
+	}
+
+	p, err = t.readKexPacket(first)
+	if err != nil {
+		return err
+	}
+	if p[0] != msgNewKeys {
+		return fmt.Errorf("sshd: expected NEWKEYS, got message %d", p[0])
+	}
+	rd, err := t.keys(k, exchangeHash, 'A', 'C', c2s)
+	if err != nil {
+		return err
+	}
+	t.rd.aead, t.rd.nonce = rd.aead, rd.nonce
+	if t.strict {
+		t.rd.seq = 0
+	}
+
+	if first && t.extInfo {
+		algos := strings.Join(signatureAlgos, ",")
+		return t.writeLocked(*message(msgExtInfo).uint32(1).string("server-sig-algs").string(algos))
+	}
+	return nil
+}
+
+// keys derives the cipher of one direction from the shared secret k and
+// the exchange hash, with the letters of its IV and key (RFC 4253 7.2).
+func (t *transport) keys(k, exchangeHash []byte, ivLetter, keyLetter byte, algo string) (*direction, error) {
+	derive := func(letter byte, n int) []byte {
+		h := sha256.New()
+		h.Write(k)
+		h.Write(exchangeHash)
+		h.Write([]byte{letter})
+		h.Write(t.sessionID)
+		out := h.Sum(nil)
+		for len(out) < n {
+			h := sha256.New()
+			h.Write(k)
+			h.Write(exchangeHash)
+			h.Write(out)
+			out = h.Sum(out)
+		}
+		return out[:n]
+	}
+	size := 16
+	if algo == "aes256-gcm@openssh.com" {
+		size = 32
+	}
+	block, err := aes.NewCipher(derive(keyLetter, size))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	d := &direction{aead: aead}
+	copy(d.nonce[:], derive(ivLetter, 12))
+	return d, nil
+}
+
+// readKexPacket reads a packet during a key exchange, skipping those the
+// protocol allows in between. In the initial exchange under strict kex,
+// anything unexpected is fatal.
+func (t *transport) readKexPacket(first bool) ([]byte, error) {
+	for {
+		p, err := t.readRaw()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case p[0] == msgDisconnect:
+			return nil, io.EOF
+		case p[0] >= msgKexInit && p[0] <= 49:
+			return p, nil
+		case first && t.strict:
+			return nil, fmt.Errorf("sshd: message %d during strict key exchange", p[0])
+		case p[0] == msgIgnore, p[0] == msgDebug, p[0] == msgUnimplemented:
+			continue
+		default:
+			return nil, fmt.Errorf("sshd: message %d during key exchange", p[0])
+		}
+	}
+}
+
+// readPacket returns the next packet for the layers above the transport,
+// answering key re-exchanges and dropping the messages that carry nothing.
+// A client disconnecting yields io.EOF.
+func (t *transport) readPacket() ([]byte, error) {
+	for {
+		p, err := t.readRaw()
+		if err != nil {
+			return nil, err
+		}
+		switch p[0] {
+		case msgDisconnect:
+			return nil, io.EOF
+		case msgIgnore, msgDebug, msgUnimplemented:
+			continue
+		case msgKexInit:
+			t.wmu.Lock()
+			err := t.kexLocked(p)
+			t.wmu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return p, nil
+	}
+}
+
+// readRaw reads and decrypts one packet and returns its payload.
+func (t *transport) readRaw() ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(t.br, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	d := &t.rd
+	// Packets are padded to the cipher's block, which with AEAD ciphers
+	// leaves out the length.
+	aligned := (n+4)%8 == 0
+	if d.aead != nil {
+		aligned = n%16 == 0
+	}
+	if n < 5 || n > maxPacket || !aligned {
+		return nil, fmt.Errorf("sshd: bad packet length %d", n)
+	}
+	var plain []byte
+	if d.aead == nil {
+		plain = make([]byte, n)
+		if _, err := io.ReadFull(t.br, plain); err != nil {
+			return nil, err
+		}
+	} else {
+		sealed := make([]byte, int(n)+d.aead.Overhead())
+		if _, err := io.ReadFull(t.br, sealed); err != nil {
+			return nil, err
+		}
+		var err error
+		if plain, err = d.aead.Open(sealed[:0], d.nonce[:], sealed, lenBuf[:]); err != nil {
+			return nil, errors.New("sshd: packet failed authentication")
+		}
+		incNonce(&d.nonce)
+	}
+	d.seq++
+	pad := int(plain[0])
+	if pad < 4 || pad >= len(plain)-1 {
+		return nil, errors.New("sshd: bad packet padding")
+	}
+	return plain[1 : len(plain)-pad], nil
+}
+
+func (t *transport) writePacket(payload []byte) error {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	return t.writeLocked(payload)
+}
+
+// writeLocked encrypts and sends one packet.
+func (t *transport) writeLocked(payload []byte) error {
+	d := &t.wr
+	block, aad := 8, 4
+	if d.aead != nil {
+		// The length is sent in the clear as associated data, so it is
+		// not part of the padded plaintext.
+		block, aad = 16, 0
+	}
+	pad := block - (aad+1+len(payload))%block
+	if pad < 4 {
+		pad += block
+	}
+	n := 1 + len(payload) + pad
+	buf := make([]byte, 4+n, 4+n+16)
+	binary.BigEndian.PutUint32(buf, uint32(n))
+	buf[4] = byte(pad)
+	copy(buf[5:], payload)
+	rand.Read(buf[5+len(payload):])
+	if d.aead != nil {
+		buf = d.aead.Seal(buf[:4], d.nonce[:], buf[4:], buf[:4])
+		incNonce(&d.nonce)
+	}
+	d.seq++
+	_, err := t.conn.Write(buf)
+	return err
+}
+
+// incNonce advances the invocation counter of an AES-GCM nonce (RFC 5647).
+func incNonce(n *[12]byte) {
+	binary.BigEndian.PutUint64(n[4:], binary.BigEndian.Uint64(n[4:])+1)
+}
+
+// disconnect tells the client why the connection ends.
+func (t *transport) disconnect(reason uint32, msg string) {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	t.disconnectLocked(reason, msg)
+}
+
+func (t *transport) disconnectLocked(reason uint32, msg string) {
+	t.writeLocked(*message(msgDisconnect).uint32(reason).string(msg).string(""))
+}
@@ -0,0 +1,393 @@
+package sshd
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clientKex are the key exchange algorithms the test client offers, as
+// OpenSSH does.
+var clientKex = []string{"curve25519-sha256", extInfoClient, strictKexClient}
+
+// clientKexInit returns the KEXINIT of the test client.
+func clientKexInit(kex, ciphers []string) []byte {
+	var cookie [16]byte
+	rand.Read(cookie[:])
+	b := message(msgKexInit)
+	*b = append(*b, cookie[:]...)
+	b.nameList(kex).nameList([]string{"ssh-ed25519"}).
+		nameList(ciphers).nameList(ciphers).
+		nameList([]string{"hmac-sha2-256"}).nameList([]string{"hmac-sha2-256"}).
+		nameList(compAlgos).nameList(compAlgos).
+		nameList(nil).nameList(nil).
+		bool(false).uint32(0)
+	return *b
+}
+
+// clientHandshake runs the client side of the version exchange and key
+// exchange on nc, checking the server's host key signature as a client
+// does, and returns a transport for the client's packets: it reads with
+// the server-to-client keys and writes with the client-to-server ones.
+func clientHandshake(nc net.Conn, kex, ciphers []string) (*transport, error) {
+	ct := newTransport(nc, nil)
+	ct.clientVersion = []byte("SSH-2.0-webide-test")
+	if _, err := nc.Write(append(ct.clientVersion, "\r\n"...)); err != nil {
+		return nil, err
+	}
+	line, err := ct.br.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	serverVer := string(bytes.TrimRight(line, "\r\n"))
+	if serverVer != serverVersion {
+		return nil, fmt.Errorf("server version %q", serverVer)
+	}
+	return ct, ct.clientKex(serverVer, kex, ciphers)
+}
+
+// clientKex performs a key exchange as the client of ct.
+func (ct *transport) clientKex(serverVer string, kex, ciphers []string) error {
+	ours := clientKexInit(kex, ciphers)
+	if err := ct.writePacket(ours); err != nil {
+		return err
+	}
+	peerInit, err := ct.readRaw()
+	if err != nil {
+		return err
+	}
+	if peerInit[0] == msgDisconnect {
+		return errors.New("disconnected")
+	}
+	if peerInit[0] != msgKexInit {
+		return fmt.Errorf("got message %d, want KEXINIT", peerInit[0])
+	}
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	clientPub := priv.PublicKey().Bytes()
+	if err := ct.writePacket(*message(msgKexECDHInit).bytes(clientPub)); err != nil {
+		return err
+	}
+	p, err := ct.readRaw()
+	if err != nil {
+		return err
+	}
+	r := reader{b: p[1:]}
+	hostKeyBlob, serverPub, sig := r.bytes(), r.bytes(), r.bytes()
+	if p[0] != msgKexECDHReply || !r.ok() {
+		return fmt.Errorf("got message %d, want KEX_ECDH_REPLY", p[0])
+	}
+	peer, err := ecdh.X25519().NewPublicKey(serverPub)
+	if err != nil {
+		return err
+	}
+	secret, err := priv.ECDH(peer)
+	if err != nil {
+		return err
+	}
+	k := *new(builder).mpintBytes(secret)
+	h := sha256.New()
+	h.Write(*new(builder).bytes(ct.clientVersion))
+	h.Write(*new(builder).string(serverVer))
+	h.Write(*new(builder).bytes(ours))
+	h.Write(*new(builder).bytes(peerInit))
+	h.Write(*new(builder).bytes(hostKeyBlob))
+	h.Write(*new(builder).bytes(clientPub))
+	h.Write(*new(builder).bytes(serverPub))
+	h.Write(k)
+	exchangeHash := h.Sum(nil)
+	hostKey, err := parsePublicKey(hostKeyBlob)
+	if err != nil {
+		return err
+	}
+	if !hostKey.verify("ssh-ed25519", exchangeHash, sig) {
+		return errors.New("host key signature does not verify")
+	}
+
+	if p, err = ct.readRaw(); err != nil {
+		return err
+	}
+	if p[0] != msgNewKeys {
+		return fmt.Errorf("got message %d, want NEWKEYS", p[0])
+	}
+	if err := ct.writePacket([]byte{msgNewKeys}); err != nil {
+		return err
+	}
+	first := ct.sessionID == nil
+	if first {
+		ct.sessionID = exchangeHash
+		ct.strict = strings.Contains(strings.Join(kex, ","), strictKexClient)
+	}
+	wr, err := ct.keys(k, exchangeHash, 'A', 'C', ciphers[0])
+	if err != nil {
+		return err
+	}
+	rd, err := ct.keys(k, exchangeHash, 'B', 'D', ciphers[0])
+	if err != nil {
+		return err
+	}
+	ct.wr.aead, ct.wr.nonce = wr.aead, wr.nonce
+	ct.rd.aead, ct.rd.nonce = rd.aead, rd.nonce
+	if ct.strict {
+		ct.wr.seq, ct.rd.seq = 0, 0
+	}
+	return nil
+}
+
+// tcpPair returns the two ends of a loopback TCP connection.
+func tcpPair(t *testing.T) (client, server net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		nc, _ := ln.Accept()
+		accepted <- nc
+	}()
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server = <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+	for _, nc := range []net.Conn{client, server} {
+		nc.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// testHostKey is the host key of the servers of the tests.
+var testHostKey = func() ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// keyed returns both ends of a connection past the key exchange.
+func keyed(t *testing.T) (client, server *transport) {
+	t.Helper()
+	cc, sc := tcpPair(t)
+	server = newTransport(sc, testHostKey)
+	done := make(chan error, 1)
+	go func() { done <- server.handshake() }()
+	client, err := clientHandshake(cc, clientKex, []string{"aes128-gcm@openssh.com"})
+	if err != nil {
+		t.Fatalf("client handshake: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("server handshake: %v", err)
+	}
+	return client, server
+}
+
+func TestHandshake(t *testing.T) {
+	for _, cipher := range cipherAlgos {
+		cc, sc := tcpPair(t)
+		st := newTransport(sc, testHostKey)
+		done := make(chan error, 1)
+		go func() { done <- st.handshake() }()
+		ct, err := clientHandshake(cc, clientKex, []string{cipher})
+		if err != nil {
+			t.Fatalf("%s: client handshake: %v", cipher, err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("%s: server handshake: %v", cipher, err)
+		}
+		if !st.strict || !st.extInfo || !bytes.Equal(st.sessionID, ct.sessionID) {
+			t.Errorf("%s: strict %v, ext-info %v, session IDs equal %v", cipher, st.strict, st.extInfo, bytes.Equal(st.sessionID, ct.sessionID))
+		}
+		// The server announces the signature algorithms it takes.
+		p, err := ct.readRaw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := reader{b: p[1:]}
+		if p[0] != msgExtInfo || r.uint32() != 1 || r.string() != "server-sig-algs" || !strings.Contains(r.string(), "rsa-sha2-256") {
+			t.Errorf("%s: first message %d %q, want EXT_INFO with server-sig-algs", cipher, p[0], p[1:])
+		}
+		// Packets of every size come through in both directions.
+		for _, n := range []int{0, 1, 15, 16, 17, 1000, 32 << 10} {
+			payload := append([]byte{msgIgnore + 200}, bytes.Repeat([]byte{'x'}, n)...)
+			go ct.writePacket(payload)
+			got, err := st.readRaw()
+			if err != nil || !bytes.Equal(got, payload) {
+				t.Fatalf("%s: packet of %d bytes = %d bytes, %v", cipher, len(payload), len(got), err)
+			}
+			go st.writePacket(payload)
+			if got, err = ct.readRaw(); err != nil || !bytes.Equal(got, payload) {
+				t.Fatalf("%s: reply of %d bytes = %d bytes, %v", cipher, len(payload), len(got), err)
+			}
+		}
+	}
+}
+
+func TestRekey(t *testing.T) {
+	ct, st := keyed(t)
+	if _, err := ct.readRaw(); err != nil { // EXT_INFO
+		t.Fatal(err)
+	}
+	sessionID := bytes.Clone(ct.sessionID)
+	done := make(chan error, 1)
+	go func() {
+		// readPacket answers the client's KEXINIT and returns the packet
+		// sent under the new keys.
+		p, err := st.readPacket()
+		if err == nil && string(p) != "\xf0after" {
+			err = fmt.Errorf("packet %q after the re-exchange", p)
+		}
+		done <- err
+	}()
+	if err := ct.clientKex(serverVersion, []string{"curve25519-sha256"}, []string{"aes256-gcm@openssh.com"}); err != nil {
+		t.Fatalf("re-exchange: %v", err)
+	}
+	if err := ct.writePacket([]byte("\xf0after")); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(st.sessionID, sessionID) {
+		t.Error("session ID changed on re-exchange")
+	}
+}
+
+func TestNoCommonAlgorithms(t *testing.T) {
+	cc, sc := tcpPair(t)
+	st := newTransport(sc, testHostKey)
+	done := make(chan error, 1)
+	go func() { done <- st.handshake() }()
+	if _, err := clientHandshake(cc, clientKex, []string{"aes128-ctr"}); err == nil {
+		t.Error("client handshake without a common cipher succeeded")
+	}
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "no common algorithms") {
+		t.Errorf("server handshake = %v", err)
+	}
+}
+
+func TestStrictKexRejectsIgnore(t *testing.T) {
+	cc, sc := tcpPair(t)
+	st := newTransport(sc, testHostKey)
+	done := make(chan error, 1)
+	go func() { done <- st.handshake() }()
+	ct := newTransport(cc, nil)
+	io.WriteString(cc, "SSH-2.0-webide-test\r\n")
+	if _, err := ct.br.ReadSlice('\n'); err != nil {
+		t.Fatal(err)
+	}
+	ct.writePacket(clientKexInit(clientKex, cipherAlgos))
+	// Under strict kex an IGNORE before NEWKEYS is what the prefix
+	// truncation attack injects.
+	ct.writePacket(*message(msgIgnore).string("x"))
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "strict key exchange") {
+		t.Errorf("handshake with an IGNORE = %v, want a strict kex error", err)
+	}
+}
+
+// sealRaw sends plain, the padding length, payload and padding of a
+// packet, encrypted with ct's keys as they are, without checking it.
+func (ct *transport) sealRaw(plain []byte, tamper bool) error {
+	d := &ct.wr
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(plain)))
+	buf = d.aead.Seal(buf, d.nonce[:], plain, buf[:4])
+	incNonce(&d.nonce)
+	d.seq++
+	if tamper {
+		buf[len(buf)-1] ^= 1
+	}
+	_, err := ct.conn.Write(buf)
+	return err
+}
+
+func TestBadMAC(t *testing.T) {
+	ct, st := keyed(t)
+	plain := append([]byte{10, msgIgnore + 200, 'x', 'y', 'z', 'w', 'v'}, make([]byte, 9)...)
+	go ct.sealRaw(plain, true)
+	if _, err := st.readRaw(); err == nil || !strings.Contains(err.Error(), "failed authentication") {
+		t.Errorf("readRaw of a tampered packet = %v", err)
+	}
+
+	ct, st = keyed(t)
+	// A packet sealed for another sequence number fails too, which is
+	// what stops replays and reordering.
+	incNonce(&ct.wr.nonce)
+	go ct.sealRaw(plain, false)
+	if _, err := st.readRaw(); err == nil || !strings.Contains(err.Error(), "failed authentication") {
+		t.Errorf("readRaw of a packet with the wrong nonce = %v", err)
+	}
+}
+
+func TestBadPadding(t *testing.T) {
+	tests := []struct {
+		name  string
+		plain []byte
+		want  string
+	}{
+		{"short padding", append([]byte{3}, make([]byte, 15)...), "bad packet padding"},
+		{"padding past the payload", append([]byte{15}, make([]byte, 15)...), "bad packet padding"},
+		{"unaligned", append([]byte{4}, make([]byte, 20)...), "bad packet length"},
+	}
+	for _, tt := range tests {
+		ct, st := keyed(t)
+		go ct.sealRaw(tt.plain, false)
+		if _, err := st.readRaw(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: readRaw = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	// Before the keys are in place, packets are plain.
+	cc, sc := tcpPair(t)
+	st := newTransport(sc, testHostKey)
+	go func() {
+		pkt := binary.BigEndian.AppendUint32(nil, 12)
+		cc.Write(append(pkt, 2, msgKexInit, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0))
+	}()
+	if _, err := st.readRaw(); err == nil || !strings.Contains(err.Error(), "bad packet padding") {
+		t.Errorf("readRaw of a plain packet with 2 bytes of padding = %v", err)
+	}
+	for _, n := range []uint32{0, 4, maxPacket + 4} {
+		cc, sc := tcpPair(t)
+		st := newTransport(sc, testHostKey)
+		go cc.Write(binary.BigEndian.AppendUint32(nil, n))
+		if _, err := st.readRaw(); err == nil || !strings.Contains(err.Error(), "bad packet length") {
+			t.Errorf("readRaw of a packet of length %d = %v", n, err)
+		}
+	}
+}
+
+func TestVersionExchange(t *testing.T) {
+	for _, lines := range []string{
+		"SSH-1.5-old\r\n",
+		strings.Repeat("banner\r\n", 25),
+	} {
+		cc, sc := tcpPair(t)
+		st := newTransport(sc, testHostKey)
+		go io.WriteString(cc, lines)
+		go io.Copy(io.Discard, cc)
+		if err := st.handshake(); err == nil {
+			t.Errorf("handshake after %q succeeded", lines[:12])
+		}
+	}
+}
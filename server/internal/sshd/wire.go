@@ -0,0 +1,180 @@
+package sshd
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Message numbers and reason codes, from RFC 4250.
+const (
+	msgDisconnect     = 1
+	msgIgnore         = 2
+	msgUnimplemented  = 3
+	msgDebug          = 4
+	msgServiceRequest = 5
+	msgServiceAccept  = 6
+	msgExtInfo        = 7
+	msgKexInit        = 20
+	msgNewKeys        = 21
+	msgKexECDHInit    = 30
+	msgKexECDHReply   = 31
+
+	msgUserAuthRequest = 50
+	msgUserAuthFailure = 51
+	msgUserAuthSuccess = 52
+	msgUserAuthPKOK    = 60
+
+	msgGlobalRequest       = 80
+	msgRequestFailure      = 82
+	msgChannelOpen         = 90
+	msgChannelOpenConfirm  = 91
+	msgChannelOpenFailure  = 92
+	msgChannelWindowAdjust = 93
+	msgChannelData         = 94
+	msgChannelExtendedData = 95
+	msgChannelEOF          = 96
+	msgChannelClose        = 97
+	msgChannelRequest      = 98
+	msgChannelSuccess      = 99
+	msgChannelFailure      = 100
+
+	disconnectProtocolError        = 2
+	disconnectKexFailed            = 3
+	disconnectNoMoreAuth           = 14
+	openAdministrativelyProhibited = 1
+	openUnknownChannelType         = 3
+)
+
+var errMalformed = errors.New("sshd: malformed message")
+
+// reader decodes the fields of a message. A read past the end fails every
+// later one, so a decoder checks ok once at the end.
+type reader struct {
+	b   []byte
+	bad bool
+}
+
+func (r *reader) byte() byte {
+	if len(r.b) < 1 {
+		r.bad = true
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *reader) bool() bool { return r.byte() != 0 }
+
+func (r *reader) uint32() uint32 {
+	if len(r.b) < 4 {
+		r.bad, r.b = true, nil
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *reader) uint64() uint64 {
+	if len(r.b) < 8 {
+		r.bad, r.b = true, nil
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uint32()
+	if r.bad || uint64(n) > uint64(len(r.b)) {
+		r.bad, r.b = true, nil
+		return nil
+	}
+	v := r.b[:n:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) string() string { return string(r.bytes()) }
+
+func (r *reader) nameList() []string {
+	s := r.string()
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func (r *reader) mpint() *big.Int {
+	b := r.bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		// Negative numbers are of no use in keys or signatures.
+		r.bad = true
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func (r *reader) ok() bool { return !r.bad }
+
+// builder encodes the fields of a message.
+type builder []byte
+
+func message(typ byte) *builder {
+	b := builder{typ}
+	return &b
+}
+
+func (b *builder) byte(v byte) *builder {
+	*b = append(*b, v)
+	return b
+}
+
+func (b *builder) bool(v bool) *builder {
+	if v {
+		return b.byte(1)
+	}
+	return b.byte(0)
+}
+
+func (b *builder) uint32(v uint32) *builder {
+	*b = binary.BigEndian.AppendUint32(*b, v)
+	return b
+}
+
+func (b *builder) uint64(v uint64) *builder {
+	*b = binary.BigEndian.AppendUint64(*b, v)
+	return b
+}
+
+func (b *builder) bytes(v []byte) *builder {
+	b.uint32(uint32(len(v)))
+	*b = append(*b, v...)
+	return b
+}
+
+func (b *builder) string(v string) *builder {
+	b.uint32(uint32(len(v)))
+	*b = append(*b, v...)
+	return b
+}
+
+func (b *builder) nameList(v []string) *builder {
+	return b.string(strings.Join(v, ","))
+}
+
+// mpintBytes encodes the unsigned big-endian number v as an mpint.
+func (b *builder) mpintBytes(v []byte) *builder {
+	for len(v) > 0 && v[0] == 0 {
+		v = v[1:]
+	}
+	if len(v) > 0 && v[0]&0x80 != 0 {
+		b.uint32(uint32(len(v) + 1)).byte(0)
+		*b = append(*b, v...)
+		return b
+	}
+	return b.bytes(v)
+}
@@ -0,0 +1,363 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3 backend.
+type S3Config struct {
+	// Endpoint is the server's base URL, such as "http://minio:9000";
+	// defaults to Amazon S3 in Region.
+	Endpoint string
+	// Region is the bucket's region; defaults to "us-east-1".
+	Region string
+	Bucket string
+	// Prefix, when set, is prepended to every key, so several
+	// deployments can share a bucket.
+	Prefix string
+	// AccessKey and SecretKey are the credentials requests are signed
+	// with, and SessionToken the token of temporary ones.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// PathStyle addresses the bucket in the path rather than the host
+	// name. It is implied by a custom Endpoint, as MinIO and most other
+	// servers need it.
+	PathStyle bool
+	// Client sends the requests; defaults to a client with a long
+	// timeout, as objects are streamed.
+	Client *http.Client
+}
+
+// S3 is a Backend keeping objects in a bucket of Amazon S3 or a server
+// speaking its API. Requests are signed with Signature Version 4; object
+// bodies are streamed unsigned, so the endpoint should be HTTPS outside a
+// trusted network.
+type S3 struct {
+	cfg  S3Config
+	base *url.URL
+	now  func() time.Time
+}
+
+// NewS3 returns an S3 backend, filling unset S3Config fields with
+// defaults.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("storage: s3 bucket is not set")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	} else {
+		cfg.PathStyle = true
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 30 * time.Minute}
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	base, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("storage: invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if !cfg.PathStyle {
+		base.Host = cfg.Bucket + "." + base.Host
+	}
+	return &S3{cfg: cfg, base: base, now: time.Now}, nil
+}
+
+// objectPath is the request path of key, prefix included.
+func (s *S3) objectPath(key string) string {
+	p := "/"
+	if s.cfg.PathStyle {
+		p += s.cfg.Bucket + "/"
+	}
+	if s.cfg.Prefix != "" {
+		p += s.cfg.Prefix + "/"
+	}
+	return p + key
+}
+
+// Put implements Backend.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if !validKey(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	body := &countingReader{r: r}
+	resp, err := s.do(ctx, http.MethodPut, s.objectPath(key), nil, body, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if body.n != size {
+		return fmt.Errorf("storage: write %s: read %d bytes, want %d", key, body.n, size)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Get implements Backend.
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectPath(key), nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Exists implements Backend.
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	if !validKey(key) {
+		return false, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	resp, err := s.do(ctx, http.MethodHead, s.objectPath(key), nil, nil, 0)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Delete implements Backend.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.objectPath(key), nil, nil, 0)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is the part of a ListObjectsV2 response List reads.
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List implements Backend.
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	full := prefix
+	if s.cfg.Prefix != "" {
+		full = s.cfg.Prefix + "/" + prefix
+	}
+	bucket := "/"
+	if s.cfg.PathStyle {
+		bucket += s.cfg.Bucket
+	}
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {full}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, bucket, q, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var res listResult
+		err = xml.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("storage: list %s: %w", prefix, err)
+		}
+		for _, c := range res.Contents {
+			if s.cfg.Prefix != "" {
+				c.Key = strings.TrimPrefix(c.Key, s.cfg.Prefix+"/")
+			}
+			keys = append(keys, c.Key)
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			break
+		}
+		token = res.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// s3Error is the body of an error response.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do sends a signed request and returns a successful response; the
+// caller closes its body. A missing object is ErrNotFound.
+func (s *S3) do(ctx context.Context, method, p string, q url.Values, body io.Reader, size int64) (*http.Response, error) {
+	u := *s.base
+	u.Path = p
+	u.RawPath = uriEncode(p, false)
+	u.RawQuery = canonicalQuery(q)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			// A zero length with a body means unknown to net/http.
+			req.Body = http.NoBody
+		}
+	}
+	s.sign(req, body != nil)
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("storage: s3 %s: %w", method, err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var e s3Error
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+	if resp.StatusCode == http.StatusNotFound && (e.Code == "" || e.Code == "NoSuchKey") {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, p)
+	}
+	if e.Code == "" {
+		e.Code = resp.Status
+	}
+	return nil, fmt.Errorf("storage: s3 %s %s: %s %s", method, p, e.Code, e.Message)
+}
+
+// unsignedPayload is the payload hash of requests whose body is streamed
+// without hashing it first.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// emptyHash is the SHA-256 of an empty body.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds a Signature Version 4 Authorization header to req.
+func (s *S3) sign(req *http.Request, streamed bool) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := emptyHash
+	if streamed {
+		payload = unsignedPayload
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	for _, part := range []string{s.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// canonicalQuery encodes q sorted by key, as signing requires.
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and
+// slashes unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,599 @@
+// Package storage persists workspace files in a backend shared by every
+// server, so a workspace can be opened on any node and a node can be
+// replaced without losing work. The working copy stays on local disk,
+// where editors, terminals and sandboxes use it as before: a node
+// hydrates a workspace from the backend the first time it is opened
+// there, and pushes it back every Config.Interval and when asked to.
+//
+// A backend is a flat store of objects named by slash-separated keys.
+// Disk keeps them in a directory, NFS in a directory on a network mount,
+// and S3 in a bucket of Amazon S3 or a compatible server such as MinIO.
+//
+// Each workspace is one manifest, listing its files and directories, and
+// the contents of its files, stored once and named by their SHA-256.
+// Contents are streamed in both directions and checked against their
+// hash when read back, so a corrupted object fails the hydration rather
+// than reaching the workspace. Files whose size and modification time
+// match the last push are not read again, and a push of a tree that did
+// not change writes nothing.
+//
+// A workspace is expected to be open on one node at a time: two nodes
+// pushing the same workspace overwrite each other's manifest.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Backend stores objects by key. Keys are slash-separated paths without
+// empty, "." or ".." elements.
+type Backend interface {
+	// Put stores the size bytes read from r as key, replacing any object
+	// already there. A reader that ends early or runs long fails the put.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get streams the object at key, or returns ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Exists reports whether there is an object at key.
+	Exists(ctx context.Context, key string) (bool, error)
+	// List returns the keys that start with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the object at key, if there is one.
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	// ErrNotFound is returned for keys without an object.
+	ErrNotFound = errors.New("storage: not found")
+	// ErrInvalidKey is returned for keys a backend cannot store.
+	ErrInvalidKey = errors.New("storage: invalid key")
+	// ErrChecksum is returned when stored contents do not match their
+	// hash.
+	ErrChecksum = errors.New("storage: checksum mismatch")
+	// ErrTooLarge is returned when a tree exceeds Config.MaxBytes or
+	// Config.MaxFiles.
+	ErrTooLarge = errors.New("storage: workspace too large")
+	// errChanged is returned when a file changes while it is pushed; the
+	// next push picks it up.
+	errChanged = errors.New("storage: file changed during push")
+)
+
+// validKey reports whether key is one a backend accepts.
+func validKey(key string) bool {
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return false
+	}
+	for _, el := range strings.Split(key, "/") {
+		if el == "" || el == "." || el == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Config configures a Store.
+type Config struct {
+	// Interval is how often the open workspaces are pushed; defaults to
+	// 30 seconds. Negative disables scheduled pushes.
+	Interval time.Duration
+	// Workers is how many objects are transferred at once; defaults to 8.
+	Workers int
+	// MaxBytes and MaxFiles bound the tree of a workspace; they default
+	// to 1 GiB and 100000 entries.
+	MaxBytes int64
+	MaxFiles int
+}
+
+// Workspaces lists the workspaces open on this node and pushes one, as
+// *workspace.Manager does.
+type Workspaces interface {
+	List() ([]string, error)
+	Flush(ctx context.Context, id string) error
+}
+
+// Entry is a file or directory of a workspace.
+type Entry struct {
+	Path    string    `json:"path"`
+	Dir     bool      `json:"dir,omitempty"`
+	Exec    bool      `json:"exec,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// manifest is the stored state of a workspace. Meta is the workspace
+// manager's own record of it, kept opaque.
+type manifest struct {
+	Workspace string          `json:"workspace"`
+	PushedAt  time.Time       `json:"pushedAt"`
+	Meta      json.RawMessage `json:"meta,omitempty"`
+	Entries   []Entry         `json:"entries"`
+}
+
+// Store pushes workspaces to a Backend and hydrates them from it.
+type Store struct {
+	cfg     Config
+	backend Backend
+	now     func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+	// last is the manifest of each workspace as last pushed or pulled
+	// here, guarded by mu.
+	last map[string]*manifest
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a Store on backend, filling unset Config fields with
+// defaults, and starts pushing the workspaces of ws.
+func New(cfg Config, backend Backend, ws Workspaces) *Store {
+	if cfg.Interval == 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 8
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 30
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = 100000
+	}
+	s := &Store{
+		cfg:     cfg,
+		backend: backend,
+		now:     time.Now,
+		locks:   make(map[string]*sync.Mutex),
+		last:    make(map[string]*manifest),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.loop(ws)
+	return s
+}
+
+// Close stops the scheduled pushes, after pushing every workspace one
+// last time so a node shutting down loses nothing.
+func (s *Store) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Store) loop(ws Workspaces) {
+	defer close(s.done)
+	if s.cfg.Interval < 0 {
+		<-s.stop
+		s.flushAll(ws)
+		return
+	}
+	t := time.NewTicker(s.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			s.flushAll(ws)
+			return
+		case <-t.C:
+			s.flushAll(ws)
+		}
+	}
+}
+
+// flushAll pushes every open workspace; unchanged ones cost a walk of
+// their tree.
+func (s *Store) flushAll(ws Workspaces) {
+	ids, err := ws.List()
+	if err != nil {
+		slog.Warn("list workspaces to push", "err", err)
+		return
+	}
+	for _, id := range ids {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		err := ws.Flush(ctx, id)
+		cancel()
+		if err != nil && !errors.Is(err, errChanged) {
+			slog.Warn("push workspace", "workspace", id, "err", err)
+		}
+	}
+}
+
+// lock serializes the pushes and pulls of one workspace.
+func (s *Store) lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = new(sync.Mutex)
+		s.locks[id] = l
+	}
+	s.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// Manifests are kept apart from the objects, so listing the workspaces
+// does not list their contents.
+func manifestKey(id string) string {
+	return path.Join("manifests", id+".json")
+}
+
+func objectKey(id, hash string) string {
+	return path.Join("objects", id, hash[:2], hash)
+}
+
+// Has reports whether workspace id is stored.
+func (s *Store) Has(ctx context.Context, id string) (bool, error) {
+	return s.backend.Exists(ctx, manifestKey(id))
+}
+
+// List returns the IDs of the stored workspaces.
+func (s *Store) List(ctx context.Context) ([]string, error) {
+	keys, err := s.backend.List(ctx, "manifests/")
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if id, ok := strings.CutSuffix(strings.TrimPrefix(k, "manifests/"), ".json"); ok && !strings.Contains(id, "/") {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Push stores the tree at dir and meta as workspace id. Contents the
+// backend already has are not uploaded again, and those the previous
+// manifest held and this one does not are deleted.
+func (s *Store) Push(ctx context.Context, id, dir string, meta []byte) error {
+	defer s.lock(id)()
+	prev, err := s.lastLocked(ctx, id)
+	if err != nil {
+		return err
+	}
+	entries, err := s.scan(ctx, dir, prev)
+	if err != nil {
+		return err
+	}
+	if prev != nil && sameTree(prev.Entries, entries) && string(prev.Meta) == string(meta) {
+		return nil
+	}
+
+	have := make(map[string]bool)
+	if prev != nil {
+		for _, e := range prev.Entries {
+			have[e.Hash] = true
+		}
+	}
+	var upload []Entry
+	queued := make(map[string]bool)
+	for _, e := range entries {
+		if !e.Dir && !have[e.Hash] && !queued[e.Hash] {
+			queued[e.Hash] = true
+			upload = append(upload, e)
+		}
+	}
+	err = s.parallel(upload, func(e Entry) error {
+		return s.upload(ctx, id, dir, e)
+	})
+	if err != nil {
+		return err
+	}
+
+	m := &manifest{Workspace: id, PushedAt: s.now().UTC(), Meta: meta, Entries: entries}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := s.backend.Put(ctx, manifestKey(id), strings.NewReader(string(data)), int64(len(data))); err != nil {
+		return fmt.Errorf("storage: write manifest: %w", err)
+	}
+	s.setLast(id, m)
+
+	if prev != nil {
+		used := make(map[string]bool, len(entries))
+		for _, e := range entries {
+			used[e.Hash] = true
+		}
+		for h := range have {
+			if h != "" && !used[h] {
+				if err := s.backend.Delete(ctx, objectKey(id, h)); err != nil {
+					slog.Warn("delete workspace object", "workspace", id, "err", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lastLocked returns the manifest of id as this node last saw it, or as
+// stored when it has not seen one; nil for a workspace never pushed.
+func (s *Store) lastLocked(ctx context.Context, id string) (*manifest, error) {
+	s.mu.Lock()
+	m, ok := s.last[id]
+	s.mu.Unlock()
+	if ok {
+		return m, nil
+	}
+	m, err := s.readManifest(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.setLast(id, m)
+	return m, nil
+}
+
+func (s *Store) setLast(id string, m *manifest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[id] = m
+}
+
+func (s *Store) readManifest(ctx context.Context, id string) (*manifest, error) {
+	rc, err := s.backend.Get(ctx, manifestKey(id))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var m manifest
+	if err := json.NewDecoder(rc).Decode(&m); err != nil {
+		return nil, fmt.Errorf("storage: read manifest of %s: %w", id, err)
+	}
+	return &m, nil
+}
+
+// scan walks dir and returns its entries. Files unchanged since prev
+// reuse its hashes.
+func (s *Store) scan(ctx context.Context, dir string, prev *manifest) ([]Entry, error) {
+	known := make(map[string]Entry)
+	if prev != nil {
+		for _, e := range prev.Entries {
+			known[e.Path] = e
+		}
+	}
+	var entries []Entry
+	var total int64
+	err := filepath.WalkDir(dir, func(abs string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if abs == dir {
+			return nil
+		}
+		if strings.HasPrefix(de.Name(), files.TempPrefix) || (!de.IsDir() && !de.Type().IsRegular()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			return err
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return err
+		}
+		if len(entries) >= s.cfg.MaxFiles {
+			return fmt.Errorf("%w: more than %d entries", ErrTooLarge, s.cfg.MaxFiles)
+		}
+		e := Entry{Path: filepath.ToSlash(rel), Dir: de.IsDir(), ModTime: fi.ModTime().UTC()}
+		if !e.Dir {
+			if total += fi.Size(); total > s.cfg.MaxBytes {
+				return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, s.cfg.MaxBytes)
+			}
+			e.Size, e.Exec = fi.Size(), fi.Mode()&0o111 != 0
+			if k, ok := known[e.Path]; ok && !k.Dir && k.Size == e.Size && k.ModTime.Equal(e.ModTime) {
+				e.Hash = k.Hash
+			} else if e.Hash, err = hashFile(abs); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// sameTree reports whether two manifests hold the same files.
+func sameTree(a, b []Entry) bool {
+	return slices.EqualFunc(a, b, func(x, y Entry) bool {
+		return x.Path == y.Path && x.Dir == y.Dir && x.Exec == y.Exec && x.Hash == y.Hash && x.ModTime.Equal(y.ModTime)
+	})
+}
+
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("storage: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("storage: read %s: %w", filepath.Base(name), err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// upload stores the contents of e unless the backend has them. The file
+// is hashed again as it is streamed; if it changed since it was scanned
+// the object is deleted and the push fails, to be retried.
+func (s *Store) upload(ctx context.Context, id, dir string, e Entry) error {
+	key := objectKey(id, e.Hash)
+	if ok, err := s.backend.Exists(ctx, key); err != nil {
+		return fmt.Errorf("storage: %w", err)
+	} else if ok {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(e.Path)))
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if err := s.backend.Put(ctx, key, io.TeeReader(f, h), e.Size); err != nil {
+		return fmt.Errorf("storage: upload %s: %w", e.Path, err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != e.Hash {
+		s.backend.Delete(ctx, key)
+		return fmt.Errorf("%w: %s", errChanged, e.Path)
+	}
+	return nil
+}
+
+// Pull writes the stored tree of workspace id into dir, which should be
+// empty, and returns the meta it was pushed with. It returns ErrNotFound
+// for a workspace that is not stored.
+func (s *Store) Pull(ctx context.Context, id, dir string) ([]byte, error) {
+	defer s.lock(id)()
+	m, err := s.readManifest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range m.Entries {
+		if e.Dir {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(e.Path)), 0o755); err != nil {
+				return nil, fmt.Errorf("storage: %w", err)
+			}
+		}
+	}
+	var download []Entry
+	for _, e := range m.Entries {
+		if !e.Dir {
+			download = append(download, e)
+		}
+	}
+	err = s.parallel(download, func(e Entry) error {
+		return s.download(ctx, id, dir, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Directory times last, as writing their files changed them.
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		if e := m.Entries[i]; e.Dir {
+			os.Chtimes(filepath.Join(dir, filepath.FromSlash(e.Path)), e.ModTime, e.ModTime)
+		}
+	}
+	s.setLast(id, m)
+	return m.Meta, nil
+}
+
+// download writes the contents of e into dir, checking them against
+// their hash.
+func (s *Store) download(ctx context.Context, id, dir string, e Entry) error {
+	if len(e.Hash) < 2 || strings.ContainsAny(e.Path, "\\\x00") || !filepath.IsLocal(filepath.FromSlash(e.Path)) {
+		return fmt.Errorf("storage: invalid manifest entry %q", e.Path)
+	}
+	rc, err := s.backend.Get(ctx, objectKey(id, e.Hash))
+	if err != nil {
+		return fmt.Errorf("storage: download %s: %w", e.Path, err)
+	}
+	defer rc.Close()
+	abs := filepath.Join(dir, filepath.FromSlash(e.Path))
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	mode := os.FileMode(0o644)
+	if e.Exec {
+		mode = 0o755
+	}
+	f, err := os.OpenFile(abs, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	_, err = io.Copy(f, newVerifier(rc, e.Hash))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(abs)
+		return fmt.Errorf("storage: download %s: %w", e.Path, err)
+	}
+	return os.Chtimes(abs, e.ModTime, e.ModTime)
+}
+
+// verifier passes a stream through, failing at its end if its SHA-256 is
+// not want.
+type verifier struct {
+	r    io.Reader
+	h    hash.Hash
+	want string
+}
+
+func newVerifier(r io.Reader, want string) *verifier {
+	return &verifier{r: r, h: sha256.New(), want: want}
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF {
+		if got := hex.EncodeToString(v.h.Sum(nil)); got != v.want {
+			return n, fmt.Errorf("%w: got %s, want %s", ErrChecksum, got, v.want)
+		}
+	}
+	return n, err
+}
+
+// parallel calls fn for each entry, Config.Workers at a time, and
+// returns the first error. Once one fails, the entries not yet started
+// are skipped.
+func (s *Store) parallel(entries []Entry, fn func(Entry) error) error {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+	work := make(chan Entry)
+	for range min(s.cfg.Workers, len(entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range work {
+				mu.Lock()
+				failed := first != nil
+				mu.Unlock()
+				if failed {
+					continue
+				}
+				if err := fn(e); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, e := range entries {
+		work <- e
+	}
+	close(work)
+	wg.Wait()
+	return first
+}
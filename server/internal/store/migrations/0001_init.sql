@@ -0,0 +1,61 @@
+-- Users, how they sign in and the workspaces and snippets they keep.
+
+CREATE TABLE users (
+	id            text PRIMARY KEY,
+	email         text NOT NULL UNIQUE,
+	name          text NOT NULL DEFAULT '',
+	password_hash text NOT NULL DEFAULT '',
+	created_at    timestamptz NOT NULL
+);
+
+-- Provider accounts linked to users, one per provider. Tokens are sealed
+-- by the auth service before they get here.
+CREATE TABLE identities (
+	user_id       text NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	provider      text NOT NULL,
+	subject       text NOT NULL,
+	login         text NOT NULL DEFAULT '',
+	email         text NOT NULL DEFAULT '',
+	scopes        text NOT NULL DEFAULT '',
+	access_token  text NOT NULL DEFAULT '',
+	refresh_token text NOT NULL DEFAULT '',
+	expiry        timestamptz,
+	linked_at     timestamptz NOT NULL,
+	PRIMARY KEY (user_id, provider),
+	UNIQUE (provider, subject)
+);
+
+CREATE TABLE sessions (
+	id         text PRIMARY KEY,
+	user_id    text NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	expires_at timestamptz NOT NULL
+);
+CREATE INDEX sessions_expires_at ON sessions (expires_at);
+
+CREATE TABLE api_tokens (
+	id           text PRIMARY KEY,
+	user_id      text NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name         text NOT NULL,
+	scope        text NOT NULL,
+	prefix       text NOT NULL,
+	hash         text NOT NULL UNIQUE,
+	created_at   timestamptz NOT NULL,
+	last_used_at timestamptz,
+	expires_at   timestamptz
+);
+CREATE INDEX api_tokens_user_id ON api_tokens (user_id);
+
+-- Owners are opaque user or organization IDs, so they carry no foreign
+-- key.
+CREATE TABLE workspaces (
+	id         text PRIMARY KEY,
+	owner      text NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL
+);
+CREATE INDEX workspaces_owner ON workspaces (owner);
+
+CREATE TABLE snippets (
+	id         text PRIMARY KEY,
+	snippet    jsonb NOT NULL,
+	created_at timestamptz NOT NULL
+);
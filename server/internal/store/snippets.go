@@ -0,0 +1,54 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/snippet"
+)
+
+// Snippets returns the store as a snippet.Backend.
+func (s *Store) Snippets() snippet.Backend { return snippets{s} }
+
+// snippets implements snippet.Backend.
+type snippets struct{ s *Store }
+
+func (b snippets) Put(sn *snippet.Snippet) (*snippet.Snippet, bool, error) {
+	data, err := json.Marshal(sn)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx, cancel := b.s.context()
+	defer cancel()
+	// JSON goes as a string: []byte arguments are bytea.
+	res, err := b.s.db.ExecContext(ctx, `INSERT INTO snippets (id, snippet, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING`, sn.ID, string(data), sn.CreatedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("store: create snippet: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		prev, err := b.Get(sn.ID)
+		return prev, false, err
+	}
+	return sn, true, nil
+}
+
+func (b snippets) Get(id string) (*snippet.Snippet, error) {
+	ctx, cancel := b.s.context()
+	defer cancel()
+	var data []byte
+	err := b.s.db.QueryRowContext(ctx, "SELECT snippet FROM snippets WHERE id = $1", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, snippet.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: read snippet %s: %w", id, err)
+	}
+	var sn snippet.Snippet
+	if err := json.Unmarshal(data, &sn); err != nil {
+		return nil, fmt.Errorf("store: read snippet %s: %w", id, err)
+	}
+	return &sn, nil
+}
@@ -0,0 +1,247 @@
+// Package store keeps the server's bookkeeping in PostgreSQL: users and
+// how they sign in, refresh sessions, personal access tokens, workspace
+// ownership and shared snippets. It implements auth.Records,
+// workspace.Catalog and snippet.Backend, so several servers can share one
+// database where each would otherwise keep its own files.
+//
+// Open applies the migrations embedded under migrations/ that the database
+// has not seen, in order, each in its own transaction. An advisory lock
+// keeps servers starting together from applying them twice.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/postgres"
+)
+
+// Config configures a Store.
+type Config struct {
+	// URL is the database's postgres:// connection URL.
+	URL string
+	// MaxConns caps open connections; defaults to 10.
+	MaxConns int
+	// Timeout bounds each operation; defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+// Store is a PostgreSQL database holding the server's records.
+type Store struct {
+	cfg Config
+	db  *sql.DB
+}
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the advisory lock key held while migrating.
+const migrationLock = 0x77656269646531 // "webide1"
+
+// maxRetries bounds how often a transaction is retried after a
+// serialization failure or deadlock.
+const maxRetries = 3
+
+// Open connects to the database and migrates its schema, filling unset
+// Config fields with defaults.
+func Open(ctx context.Context, cfg Config) (*Store, error) {
+	if cfg.MaxConns <= 0 {
+		cfg.MaxConns = 10
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	connector, err := postgres.NewConnector(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(cfg.MaxConns)
+	db.SetMaxIdleConns(cfg.MaxConns)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+	s := &Store{cfg: cfg, db: db}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: connect: %w", err)
+	}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database's connections.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database answers.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// migration is one embedded schema change, such as 0002_tokens.sql.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+func loadMigrations() ([]migration, error) {
+	paths, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var out []migration
+	for _, p := range paths {
+		name := strings.TrimSuffix(path.Base(p), ".sql")
+		num, _, _ := strings.Cut(name, "_")
+		v, err := strconv.Atoi(num)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("store: migration %s: name must start with its version", p)
+		}
+		if len(out) > 0 && out[len(out)-1].version == v {
+			return nil, fmt.Errorf("store: two migrations with version %d", v)
+		}
+		data, err := migrations.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, migration{version: v, name: name, sql: string(data)})
+	}
+	return out, nil
+}
+
+// migrate applies the migrations the database lacks.
+func (s *Store) migrate(ctx context.Context) error {
+	all, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	// The lock belongs to the session, so every statement must go
+	// through the same connection.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", int64(migrationLock)); err != nil {
+		return fmt.Errorf("store: migrate: lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", int64(migrationLock))
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	applied := make(map[int]bool)
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return fmt.Errorf("store: migrate: %w", err)
+		}
+		applied[v] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+
+	for _, m := range all {
+		if applied[m.version] {
+			continue
+		}
+		start := time.Now()
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("store: migrate %s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: migrate %s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: migrate %s: %w", m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("store: migrate %s: %w", m.name, err)
+		}
+		slog.Info("database migrated", "migration", m.name, "took", time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// context returns the context of one operation, bounded by cfg.Timeout.
+func (s *Store) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.cfg.Timeout)
+}
+
+// tx runs fn in a transaction, committing it if fn succeeds. It is
+// retried from the start when the server aborts it for a serialization
+// failure or deadlock.
+func (s *Store) tx(ctx context.Context, fn func(*sql.Tx) error) error {
+	for attempt := 0; ; attempt++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err = fn(tx); err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+		if attempt < maxRetries && (isCode(err, postgres.SerializationFailure) || isCode(err, postgres.DeadlockDetected)) {
+			continue
+		}
+		return err
+	}
+}
+
+// isCode reports whether err is a server error with SQLSTATE code.
+func isCode(err error, code string) bool {
+	var pe *postgres.Error
+	return errors.As(err, &pe) && pe.Code == code
+}
+
+// nullTime returns t for a nullable column, NULL for the zero time.
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// nullTimePtr returns *t for a nullable column, NULL for nil.
+func nullTimePtr(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// timePtr returns a pointer to t's time, or nil for NULL.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
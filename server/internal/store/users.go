@@ -0,0 +1,299 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/postgres"
+)
+
+// Users returns the store as auth.Records.
+func (s *Store) Users() auth.Records { return users{s} }
+
+// users implements auth.Records.
+type users struct{ s *Store }
+
+// queryer is what both *sql.DB and *sql.Tx offer.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (u users) CreateUser(rec *auth.UserRecord) error {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	err := u.s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO users (id, email, name, password_hash, created_at) VALUES ($1, $2, $3, $4, $5)",
+			rec.ID, rec.Email, rec.Name, rec.PasswordHash, rec.CreatedAt); err != nil {
+			return err
+		}
+		for _, i := range rec.Identities {
+			if err := insertIdentity(ctx, tx, rec.ID, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	switch {
+	case isCode(err, postgres.UniqueViolation) && strings.HasPrefix(constraint(err), "identities"):
+		return auth.ErrIdentityTaken
+	case isCode(err, postgres.UniqueViolation):
+		return auth.ErrEmailTaken
+	case err != nil:
+		return fmt.Errorf("store: create user: %w", err)
+	}
+	return nil
+}
+
+// constraint returns the constraint a server error names.
+func constraint(err error) string {
+	var pe *postgres.Error
+	if errors.As(err, &pe) {
+		return pe.Constraint
+	}
+	return ""
+}
+
+func (u users) User(id string) (*auth.UserRecord, error) {
+	return u.user("id = $1", id)
+}
+
+func (u users) UserByEmail(email string) (*auth.UserRecord, error) {
+	return u.user("email = $1", email)
+}
+
+func (u users) UserByIdentity(provider, subject string) (*auth.UserRecord, error) {
+	return u.user("id = (SELECT user_id FROM identities WHERE provider = $1 AND subject = $2)", provider, subject)
+}
+
+// user returns the user matching where, with its identities.
+func (u users) user(where string, args ...any) (*auth.UserRecord, error) {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	var rec auth.UserRecord
+	err := u.s.tx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "SELECT id, email, name, password_hash, created_at FROM users WHERE "+where, args...).
+			Scan(&rec.ID, &rec.Email, &rec.Name, &rec.PasswordHash, &rec.CreatedAt)
+		if err != nil {
+			return err
+		}
+		rec.Identities, err = identities(ctx, tx, rec.ID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: read user: %w", err)
+	}
+	return &rec, nil
+}
+
+func identities(ctx context.Context, q queryer, userID string) ([]auth.IdentityRecord, error) {
+	rows, err := q.QueryContext(ctx, `SELECT provider, subject, login, email, scopes, access_token, refresh_token, expiry, linked_at
+		FROM identities WHERE user_id = $1 ORDER BY linked_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []auth.IdentityRecord
+	for rows.Next() {
+		var i auth.IdentityRecord
+		var scopes string
+		var expiry sql.NullTime
+		if err := rows.Scan(&i.Provider, &i.Subject, &i.Login, &i.Email, &scopes, &i.AccessToken, &i.RefreshToken, &expiry, &i.LinkedAt); err != nil {
+			return nil, err
+		}
+		i.Scopes = strings.Fields(scopes)
+		i.Expiry = expiry.Time
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+func insertIdentity(ctx context.Context, tx *sql.Tx, userID string, i auth.IdentityRecord) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO identities
+		(user_id, provider, subject, login, email, scopes, access_token, refresh_token, expiry, linked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		userID, i.Provider, i.Subject, i.Login, i.Email, strings.Join(i.Scopes, " "), i.AccessToken, i.RefreshToken, nullTime(i.Expiry), i.LinkedAt)
+	return err
+}
+
+func (u users) PutIdentity(userID string, rec auth.IdentityRecord) error {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	err := u.s.tx(ctx, func(tx *sql.Tx) error {
+		var owner string
+		err := tx.QueryRowContext(ctx, "SELECT user_id FROM identities WHERE provider = $1 AND subject = $2", rec.Provider, rec.Subject).Scan(&owner)
+		switch {
+		case err == nil && owner != userID:
+			return auth.ErrIdentityTaken
+		case err != nil && !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM identities WHERE user_id = $1 AND provider = $2", userID, rec.Provider); err != nil {
+			return err
+		}
+		return insertIdentity(ctx, tx, userID, rec)
+	})
+	switch {
+	case errors.Is(err, auth.ErrIdentityTaken), isCode(err, postgres.UniqueViolation):
+		return auth.ErrIdentityTaken
+	case isCode(err, postgres.ForeignKeyViolation):
+		return fmt.Errorf("%w: no user %s", auth.ErrNotFound, userID)
+	case err != nil:
+		return fmt.Errorf("store: link identity: %w", err)
+	}
+	return nil
+}
+
+func (u users) DeleteIdentity(userID, provider string) error {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	res, err := u.s.db.ExecContext(ctx, "DELETE FROM identities WHERE user_id = $1 AND provider = $2", userID, provider)
+	if err != nil {
+		return fmt.Errorf("store: unlink identity: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s is not linked", auth.ErrNotFound, provider)
+	}
+	return nil
+}
+
+func (u users) CreateSession(id string, sess auth.Session) error {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	err := u.s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at <= now()"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO sessions (id, user_id, expires_at) VALUES ($1, $2, $3)", id, sess.UserID, sess.ExpiresAt)
+		return err
+	})
+	if isCode(err, postgres.ForeignKeyViolation) {
+		return fmt.Errorf("%w: no user %s", auth.ErrNotFound, sess.UserID)
+	}
+	if err != nil {
+		return fmt.Errorf("store: create session: %w", err)
+	}
+	return nil
+}
+
+func (u users) TakeSession(id string) (auth.Session, error) {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	var sess auth.Session
+	err := u.s.db.QueryRowContext(ctx, "DELETE FROM sessions WHERE id = $1 RETURNING user_id, expires_at", id).
+		Scan(&sess.UserID, &sess.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sess, fmt.Errorf("%w: no session %s", auth.ErrNotFound, id)
+	}
+	if err != nil {
+		return sess, fmt.Errorf("store: take session: %w", err)
+	}
+	return sess, nil
+}
+
+const tokenColumns = "id, user_id, name, scope, prefix, hash, created_at, last_used_at, expires_at"
+
+func scanToken(sc interface{ Scan(...any) error }) (auth.TokenRecord, error) {
+	var t auth.TokenRecord
+	var used, expires sql.NullTime
+	err := sc.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Prefix, &t.Hash, &t.CreatedAt, &used, &expires)
+	t.LastUsedAt, t.ExpiresAt = timePtr(used), timePtr(expires)
+	return t, err
+}
+
+func (u users) CreateToken(t *auth.TokenRecord, max int) error {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	err := u.s.tx(ctx, func(tx *sql.Tx) error {
+		// Locking the user serializes the count with other creations.
+		var id string
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", t.UserID).Scan(&id); err != nil {
+			return err
+		}
+		var n int
+		if err := tx.QueryRowContext(ctx, "SELECT count(*) FROM api_tokens WHERE user_id = $1", t.UserID).Scan(&n); err != nil {
+			return err
+		}
+		if n >= max {
+			return fmt.Errorf("%w: at most %d tokens per user", auth.ErrInvalidInput, max)
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO api_tokens ("+tokenColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+			t.ID, t.UserID, t.Name, t.Scope, t.Prefix, t.Hash, t.CreatedAt, nullTimePtr(t.LastUsedAt), nullTimePtr(t.ExpiresAt))
+		return err
+	})
+	switch {
+	case errors.Is(err, auth.ErrInvalidInput):
+		return err
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: no user %s", auth.ErrNotFound, t.UserID)
+	case err != nil:
+		return fmt.Errorf("store: create token: %w", err)
+	}
+	return nil
+}
+
+func (u users) Tokens(userID string) ([]auth.TokenRecord, error) {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	rows, err := u.s.db.QueryContext(ctx, "SELECT "+tokenColumns+" FROM api_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, fmt.Errorf("store: list tokens: %w", err)
+	}
+	defer rows.Close()
+	var out []auth.TokenRecord
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("store: list tokens: %w", err)
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: list tokens: %w", err)
+	}
+	return out, nil
+}
+
+func (u users) TokenByHash(hash string) (*auth.TokenRecord, error) {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	t, err := scanToken(u.s.db.QueryRowContext(ctx, "SELECT "+tokenColumns+" FROM api_tokens WHERE hash = $1", hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: unknown token", auth.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: read token: %w", err)
+	}
+	return &t, nil
+}
+
+func (u users) DeleteToken(userID, id string) error {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	res, err := u.s.db.ExecContext(ctx, "DELETE FROM api_tokens WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("store: delete token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: no token %s", auth.ErrNotFound, id)
+	}
+	return nil
+}
+
+func (u users) TouchToken(id string, at time.Time) error {
+	ctx, cancel := u.s.context()
+	defer cancel()
+	if _, err := u.s.db.ExecContext(ctx, "UPDATE api_tokens SET last_used_at = $2 WHERE id = $1", id, at); err != nil {
+		return fmt.Errorf("store: touch token: %w", err)
+	}
+	return nil
+}
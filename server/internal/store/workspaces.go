@@ -0,0 +1,65 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/postgres"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Workspaces returns the store as a workspace.Catalog.
+func (s *Store) Workspaces() workspace.Catalog { return workspaces{s} }
+
+// workspaces implements workspace.Catalog.
+type workspaces struct{ s *Store }
+
+func (w workspaces) Create(id string, rec workspace.Record) error {
+	ctx, cancel := w.s.context()
+	defer cancel()
+	_, err := w.s.db.ExecContext(ctx, "INSERT INTO workspaces (id, owner, created_at) VALUES ($1, $2, $3)", id, rec.Owner, rec.CreatedAt)
+	if isCode(err, postgres.UniqueViolation) {
+		return fmt.Errorf("%w: %s", workspace.ErrExists, id)
+	}
+	if err != nil {
+		return fmt.Errorf("store: create workspace %s: %w", id, err)
+	}
+	return nil
+}
+
+func (w workspaces) Record(id string) (workspace.Record, error) {
+	ctx, cancel := w.s.context()
+	defer cancel()
+	var rec workspace.Record
+	err := w.s.db.QueryRowContext(ctx, "SELECT owner, created_at FROM workspaces WHERE id = $1", id).Scan(&rec.Owner, &rec.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, fmt.Errorf("%w: %s", workspace.ErrNotFound, id)
+	}
+	if err != nil {
+		return rec, fmt.Errorf("store: read workspace %s: %w", id, err)
+	}
+	return rec, nil
+}
+
+// SetOwner implements workspace.Catalog. Workspaces created before the
+// database was used have no record yet and get one.
+func (w workspaces) SetOwner(id, owner string) error {
+	ctx, cancel := w.s.context()
+	defer cancel()
+	_, err := w.s.db.ExecContext(ctx, `INSERT INTO workspaces (id, owner, created_at) VALUES ($1, $2, now())
+		ON CONFLICT (id) DO UPDATE SET owner = EXCLUDED.owner`, id, owner)
+	if err != nil {
+		return fmt.Errorf("store: set owner of %s: %w", id, err)
+	}
+	return nil
+}
+
+func (w workspaces) Delete(id string) error {
+	ctx, cancel := w.s.context()
+	defer cancel()
+	if _, err := w.s.db.ExecContext(ctx, "DELETE FROM workspaces WHERE id = $1", id); err != nil {
+		return fmt.Errorf("store: delete workspace %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package tasks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/archive"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Kinds of task.
+const (
+	KindGenerate = "generate"
+	KindMake     = "make"
+	KindTaskfile = "task"
+)
+
+// Task is a runnable project chore found in the workspace.
+type Task struct {
+	// ID names the task in run requests. It is derived from where the
+	// task is defined, so it stays the same while the file does.
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Dir is the directory the task runs in, relative to the workspace
+	// root.
+	Dir string `json:"dir"`
+	// File and Line locate the definition; Line is 0 for tasks not
+	// defined on one line.
+	File        string `json:"file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Command is the command line run, for display.
+	Command string `json:"command"`
+
+	argv []string
+}
+
+var (
+	makefiles = []string{"GNUmakefile", "makefile", "Makefile"}
+	taskfiles = []string{"Taskfile.yml", "Taskfile.yaml", "taskfile.yml", "taskfile.yaml", "Taskfile.dist.yml", "Taskfile.dist.yaml"}
+)
+
+// Discover lists the tasks of the workspace at root: a task for each
+// //go:generate directive and one running them all, the targets of each
+// Makefile and the tasks of each Taskfile. Files .gitignore excludes are
+// skipped.
+func (s *Service) Discover(ctx context.Context, root string) ([]Task, error) {
+	gitignore, err := os.ReadFile(filepath.Join(root, ".gitignore"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ignore := archive.ParseIgnore(".git/\nnode_modules/\nvendor/\n" + string(gitignore))
+
+	var generate, others []Task
+	err = filepath.WalkDir(root, func(abs string, de fs.DirEntry, err error) error {
+		if err != nil {
+			if abs == root {
+				return err
+			}
+			if de != nil && de.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if abs == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(de.Name(), files.TempPrefix) || ignore.Match(rel, de.IsDir()) {
+			if de.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if de.IsDir() || !de.Type().IsRegular() {
+			return nil
+		}
+		name := de.Name()
+		isGo := strings.HasSuffix(name, ".go")
+		if !isGo && !slices.Contains(makefiles, name) && !slices.Contains(taskfiles, name) {
+			return nil
+		}
+		if fi, err := de.Info(); err != nil || fi.Size() > s.cfg.MaxFileBytes {
+			return nil
+		}
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			return nil
+		}
+		switch {
+		case isGo:
+			generate = append(generate, generateTasks(rel, data)...)
+		case slices.Contains(makefiles, name):
+			others = append(others, makeTasks(rel, data)...)
+		default:
+			others = append(others, taskfileTasks(rel, data)...)
+		}
+		if len(generate)+len(others) > s.cfg.MaxTasks {
+			return errTooManyTasks
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errTooManyTasks) {
+		return nil, err
+	}
+
+	out := []Task{}
+	if len(generate) > 0 {
+		out = append(out, Task{
+			ID:          KindGenerate + ":./...",
+			Kind:        KindGenerate,
+			Name:        "go generate ./...",
+			Dir:         ".",
+			Description: "Run every //go:generate directive",
+			Command:     "go generate ./...",
+			argv:        []string{"go", "generate", "./..."},
+		})
+	}
+	out = append(out, generate...)
+	out = append(out, others...)
+	if len(out) > s.cfg.MaxTasks {
+		out = out[:s.cfg.MaxTasks]
+	}
+	return out, nil
+}
+
+var errTooManyTasks = errors.New("tasks: too many tasks")
+
+// generateTasks returns a task per //go:generate directive of a Go file.
+// Each runs its directive alone, selected with -run by its full text.
+func generateTasks(rel string, data []byte) []Task {
+	var out []Task
+	dir, file := path.Dir(rel), path.Base(rel)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for ln := 1; sc.Scan(); ln++ {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		cmd, ok := strings.CutPrefix(line, "//go:generate ")
+		if !ok || strings.TrimSpace(cmd) == "" {
+			continue
+		}
+		run := "^" + regexp.QuoteMeta(line) + "$"
+		out = append(out, Task{
+			ID:      KindGenerate + ":" + rel + ":" + strconv.Itoa(ln),
+			Kind:    KindGenerate,
+			Name:    strings.TrimSpace(cmd),
+			Dir:     dir,
+			File:    rel,
+			Line:    ln,
+			Command: "go generate -run " + strconv.Quote(run) + " " + file,
+			argv:    []string{"go", "generate", "-run", run, file},
+		})
+	}
+	return out
+}
+
+// makeTargetRE matches a rule's targets and what follows its colon, and
+// rejects variable assignments.
+var makeTargetRE = regexp.MustCompile(`^([A-Za-z0-9_][A-Za-z0-9_./+-]*(?:[ \t]+[A-Za-z0-9_][A-Za-z0-9_./+-]*)*)[ \t]*::?(?:[^=]|$)(.*)$`)
+
+// makeTasks returns a task per explicit target of a Makefile, described by
+// a "## text" comment on the rule's line or the comment line above it.
+func makeTasks(rel string, data []byte) []Task {
+	var out []Task
+	seen := make(map[string]bool)
+	dir := path.Dir(rel)
+	comment := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for ln := 1; sc.Scan(); ln++ {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if c, ok := strings.CutPrefix(line, "#"); ok {
+			comment = strings.TrimSpace(strings.TrimLeft(c, "#"))
+			continue
+		}
+		m := makeTargetRE.FindStringSubmatch(line)
+		if m == nil {
+			if !strings.HasPrefix(line, "\t") {
+				comment = ""
+			}
+			continue
+		}
+		desc := comment
+		if _, c, ok := strings.Cut(m[2], "##"); ok {
+			desc = strings.TrimSpace(c)
+		}
+		comment = ""
+		for _, target := range strings.Fields(m[1]) {
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+			out = append(out, Task{
+				ID:          KindMake + ":" + rel + ":" + target,
+				Kind:        KindMake,
+				Name:        target,
+				Dir:         dir,
+				File:        rel,
+				Line:        ln,
+				Description: desc,
+				Command:     "make -f " + path.Base(rel) + " " + target,
+				argv:        []string{"make", "-f", path.Base(rel), target},
+			})
+		}
+	}
+	return out
+}
+
+// taskfileTasks returns the tasks of a Taskfile, read as just enough YAML
+// to find the keys of its top-level tasks map and their desc fields.
+// Internal tasks are left out.
+func taskfileTasks(rel string, data []byte) []Task {
+	var out []Task
+	dir := path.Dir(rel)
+	inTasks := false
+	indent := 0 // of task names
+	var cur *Task
+	internal := false
+	flush := func() {
+		if cur != nil && !internal {
+			out = append(out, *cur)
+		}
+		cur, internal = nil, false
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for ln := 1; sc.Scan(); ln++ {
+		raw := strings.TrimRight(sc.Text(), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		depth := len(raw) - len(text)
+		if depth == 0 {
+			flush()
+			inTasks = text == "tasks:"
+			indent = 0
+			continue
+		}
+		if !inTasks {
+			continue
+		}
+		if indent == 0 {
+			indent = depth
+		}
+		key, value, ok := strings.Cut(text, ":")
+		switch {
+		case depth == indent && ok:
+			flush()
+			name := yamlScalar(key)
+			if name == "" {
+				continue
+			}
+			cur = &Task{
+				ID:      KindTaskfile + ":" + rel + ":" + name,
+				Kind:    KindTaskfile,
+				Name:    name,
+				Dir:     dir,
+				File:    rel,
+				Line:    ln,
+				Command: "task -t " + path.Base(rel) + " " + name,
+				argv:    []string{"task", "-t", path.Base(rel), name},
+			}
+		case cur != nil && depth > indent && ok:
+			switch strings.TrimSpace(key) {
+			case "desc":
+				cur.Description = yamlScalar(value)
+			case "summary":
+				if cur.Description == "" {
+					cur.Description = yamlScalar(value)
+				}
+			case "internal":
+				internal = yamlScalar(value) == "true"
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+// yamlScalar returns a plain or quoted YAML scalar, dropping a trailing
+// comment. Block scalars read as empty.
+func yamlScalar(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || s[0] == '|' || s[0] == '>' {
+		return ""
+	}
+	switch s[0] {
+	case '"':
+		if end := strings.LastIndexByte(s, '"'); end > 0 {
+			if u, err := strconv.Unquote(s[:end+1]); err == nil {
+				return u
+			}
+		}
+	case '\'':
+		if end := strings.LastIndexByte(s, '\''); end > 0 {
+			return strings.ReplaceAll(s[1:end], "''", "'")
+		}
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
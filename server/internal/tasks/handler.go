@@ -0,0 +1,156 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the task routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the task routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/tasks", h.list)
+	mux.HandleFunc("POST /api/workspaces/{id}/tasks/run", h.run)
+	mux.HandleFunc("GET /ws/workspaces/{id}/tasks/run", h.stream)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", false
+	}
+	return id, dir, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	_, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := h.svc.Discover(r.Context(), dir)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"tasks": tasks})
+}
+
+// run runs the task named in the body and returns its Result once it
+// exits.
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var req Request
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res, err := h.svc.Run(r.Context(), id, dir, req, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// errorFrame reports a run that could not start or failed to run.
+type errorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// stream runs ?task= and sends its Events, one per text frame, closing the
+// socket after the exited event. Closing the socket stops the task.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	req := Request{Task: r.URL.Query().Get("task")}
+	if v := r.URL.Query().Get("timeoutMs"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms < 0 {
+			httpx.Error(w, http.StatusBadRequest, "invalid timeoutMs")
+			return
+		}
+		req.TimeoutMS = ms
+	}
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	_, err = h.svc.Run(ctx, id, dir, req, func(ev Event) {
+		if werr := conn.WriteJSON(ev); werr != nil {
+			cancel()
+		}
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		msg := err.Error()
+		if !isRequestError(err) {
+			slog.Error("run task", "workspace", id, "err", err)
+			msg = "could not run task"
+		}
+		conn.WriteJSON(errorFrame{Type: "error", Error: msg})
+		return
+	}
+	conn.CloseWithCode(ws.CloseNormal, "task exited")
+}
+
+func isRequestError(err error) bool {
+	return errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrNotFound) || errors.Is(err, ErrTooManyRuns)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrTooManyRuns):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+	default:
+		slog.Error("tasks", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "task request failed")
+	}
+}
@@ -0,0 +1,288 @@
+// Package tasks finds a workspace's project chores — go generate
+// directives, Makefile targets and Taskfile tasks — and runs them inside
+// the workspace's environment, streaming their output.
+//
+// Only discovered tasks run: a request names a task by ID, and the
+// command comes from the file defining it as it is on disk.
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/utf8x"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+}
+
+// Config configures a Service.
+type Config struct {
+	// TaskPackage is installed with go install to run Taskfile tasks when
+	// task is not on PATH.
+	TaskPackage string
+	// MaxRuns caps concurrent task runs per workspace; defaults to 2.
+	MaxRuns int
+	// DefaultTimeout and MaxTimeout bound a run's wall-clock time; they
+	// default to 10 and 30 minutes.
+	DefaultTimeout time.Duration
+	MaxTimeout     time.Duration
+	// MaxOutputBytes caps the output kept in a Result; defaults to 64 KiB.
+	// Streamed output is not capped.
+	MaxOutputBytes int
+	// MaxFileBytes is the largest file read for tasks; defaults to 1 MiB.
+	MaxFileBytes int64
+	// MaxTasks caps the tasks listed; defaults to 500.
+	MaxTasks int
+}
+
+var (
+	// ErrInvalidRequest is returned for malformed timeouts.
+	ErrInvalidRequest = errors.New("tasks: invalid request")
+	// ErrNotFound is returned for task IDs the workspace does not define.
+	ErrNotFound = errors.New("tasks: task not found")
+	// ErrTooManyRuns is returned when a workspace is at Config.MaxRuns.
+	ErrTooManyRuns = errors.New("tasks: too many task runs")
+)
+
+// Request selects the task to run.
+type Request struct {
+	Task string `json:"task"`
+	// TimeoutMS overrides Config.DefaultTimeout, up to Config.MaxTimeout.
+	TimeoutMS int64 `json:"timeoutMs,omitempty"`
+}
+
+// Result reports a finished run.
+type Result struct {
+	Task       Task      `json:"task"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMS int64     `json:"durationMs"`
+	// ExitCode is -1 when the task timed out. 127 means the tool it
+	// needs, such as make, is not installed.
+	ExitCode int  `json:"exitCode"`
+	TimedOut bool `json:"timedOut,omitempty"`
+	// Output is stdout and stderr as they were written, up to
+	// Config.MaxOutputBytes.
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// EventType names a run event.
+type EventType string
+
+const (
+	EventStarted EventType = "started"
+	EventStdout  EventType = "stdout"
+	EventStderr  EventType = "stderr"
+	EventExited  EventType = "exited"
+)
+
+// Event is one item of a run's stream. The started event carries the
+// Task, output events Data, and the final exited event the Result.
+type Event struct {
+	Type   EventType `json:"type"`
+	Task   *Task     `json:"task,omitempty"`
+	Data   string    `json:"data,omitempty"`
+	Result *Result   `json:"result,omitempty"`
+}
+
+// Service discovers and runs tasks through a Launcher.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.TaskPackage == "" {
+		cfg.TaskPackage = "github.com/go-task/task/v3/cmd/task@v3.39.2"
+	}
+	if cfg.MaxRuns <= 0 {
+		cfg.MaxRuns = 2
+	}
+	if cfg.DefaultTimeout <= 0 {
+		cfg.DefaultTimeout = 10 * time.Minute
+	}
+	if cfg.MaxTimeout <= 0 {
+		cfg.MaxTimeout = 30 * time.Minute
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 64 << 10
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = 1 << 20
+	}
+	if cfg.MaxTasks <= 0 {
+		cfg.MaxTasks = 500
+	}
+	return &Service{cfg: cfg, launcher: l, active: make(map[string]int)}
+}
+
+// script runs a task's command in its directory $1. $2 is the package
+// installing task, needed for Taskfile tasks when it is not on PATH.
+const script = `cd "$1" || exit 2
+pkg=$2
+shift 2
+if [ "$1" = task ]; then
+	bin="${TMPDIR:-/tmp}/webide-tools"
+	PATH="$bin:$PATH"
+	if ! command -v task >/dev/null 2>&1; then
+		GOBIN="$bin" GOFLAGS= go install "$pkg" >/dev/null 2>&1 || { echo "task is not available" >&2; exit 127; }
+	fi
+fi
+exec "$@"`
+
+// Run runs a task of the workspace at dir, passing its events to emit,
+// which may be nil. A task that fails is reported through the Result; a
+// non-nil error means it could not be run.
+func (s *Service) Run(ctx context.Context, workspaceID, dir string, req Request, emit func(Event)) (*Result, error) {
+	timeout := s.cfg.DefaultTimeout
+	if req.TimeoutMS > 0 {
+		timeout = time.Duration(req.TimeoutMS) * time.Millisecond
+	}
+	if timeout > s.cfg.MaxTimeout {
+		return nil, fmt.Errorf("%w: timeout exceeds %s", ErrInvalidRequest, s.cfg.MaxTimeout)
+	}
+	all, err := s.Discover(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	var task *Task
+	for i := range all {
+		if all[i].ID == req.Task {
+			task = &all[i]
+			break
+		}
+	}
+	if task == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, req.Task)
+	}
+	if err := s.acquire(workspaceID); err != nil {
+		return nil, err
+	}
+	defer s.release(workspaceID)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	argv := append([]string{"sh", "-c", script, "sh", task.Dir, s.cfg.TaskPackage}, task.argv...)
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &output{max: s.cfg.MaxOutputBytes, emit: emit}
+	stdout, stderr := out.writer(EventStdout), out.writer(EventStderr)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = 3 * time.Second
+
+	out.send(Event{Type: EventStarted, Task: task})
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("tasks: start %s: %w", task.Kind, err)
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+	err = cmd.Wait()
+	stdout.flush()
+	stderr.flush()
+
+	res := &Result{Task: *task, StartedAt: start.UTC(), DurationMS: time.Since(start).Milliseconds()}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		res.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if !res.TimedOut {
+			return nil, ctx.Err()
+		}
+		res.ExitCode = -1
+	case err == nil:
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	default:
+		return nil, fmt.Errorf("tasks: %s: %w", task.Kind, err)
+	}
+	out.mu.Lock()
+	res.Output, res.Truncated = out.buf.String(), out.truncated
+	out.mu.Unlock()
+	out.send(Event{Type: EventExited, Result: res})
+	return res, nil
+}
+
+func (s *Service) acquire(workspaceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[workspaceID] >= s.cfg.MaxRuns {
+		return ErrTooManyRuns
+	}
+	s.active[workspaceID]++
+	return nil
+}
+
+func (s *Service) release(workspaceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[workspaceID]--; s.active[workspaceID] <= 0 {
+		delete(s.active, workspaceID)
+	}
+}
+
+// output collects a run's stdout and stderr in the order written, up to
+// max bytes, and passes them on as events. It serializes the events, as
+// the two streams are written from separate goroutines.
+type output struct {
+	mu        sync.Mutex
+	max       int
+	buf       strings.Builder
+	truncated bool
+	emit      func(Event)
+}
+
+func (o *output) send(ev Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if ev.Type == EventStdout || ev.Type == EventStderr {
+		if room := o.max - o.buf.Len(); len(ev.Data) > room {
+			o.buf.WriteString(ev.Data[:max(room, 0)])
+			o.truncated = true
+		} else {
+			o.buf.WriteString(ev.Data)
+		}
+	}
+	if o.emit != nil {
+		o.emit(ev)
+	}
+}
+
+func (o *output) writer(typ EventType) *streamWriter {
+	return &streamWriter{out: o, typ: typ}
+}
+
+// streamWriter turns one stream's output into events, never splitting a
+// multi-byte character across two.
+type streamWriter struct {
+	out   *output
+	typ   EventType
+	split utf8x.Splitter
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if b := w.split.Push(p); len(b) > 0 {
+		w.out.send(Event{Type: w.typ, Data: string(b)})
+	}
+	return len(p), nil
+}
+
+func (w *streamWriter) flush() {
+	if b := w.split.Flush(); len(b) > 0 {
+		w.out.send(Event{Type: w.typ, Data: string(b)})
+	}
+}
@@ -0,0 +1,204 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/pty"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Quota admits new sessions for the caller in ctx. The returned function
+// is called when the session ends.
+type Quota interface {
+	StartTerminal(ctx context.Context) (stop func(), err error)
+}
+
+// Handler bridges terminal sessions to WebSocket clients.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	quota      Quota
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler starting sessions with svc. quota may be
+// nil.
+func NewHandler(svc *Service, wm Workspaces, quota Quota, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, quota: quota, wsOpts: wsOpts}
+}
+
+// Register mounts the terminal socket and session API on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /ws/terminal/{id}", h.serve)
+	mux.HandleFunc("GET /api/workspaces/{id}/terminals", h.list)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/terminals/{name}", h.kill)
+}
+
+// frame is the JSON message exchanged in both directions.
+//
+// Client to server: {"type":"input","data":...} and
+// {"type":"resize","cols":...,"rows":...}.
+// Server to client: {"type":"attached","session":...,"created":...},
+// {"type":"scrollback","data":...}, {"type":"output","data":...} and
+// {"type":"exit","exitCode":...}. Scrollback and output frames carry the
+// seq a client resumes from: the offset in the session's output of the end
+// of their data.
+type frame struct {
+	Type     string `json:"type"`
+	Session  string `json:"session,omitempty"`
+	Created  bool   `json:"created,omitempty"`
+	Resumed  bool   `json:"resumed,omitempty"`
+	Seq      int64  `json:"seq,omitempty"`
+	Data     string `json:"data,omitempty"`
+	Cols     uint16 `json:"cols,omitempty"`
+	Rows     uint16 `json:"rows,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+}
+
+// serve attaches the socket to a terminal session. ?session= names the
+// session to reattach to or create; without it a new session is started.
+// The shell is chosen with ?shell= and the size with ?cols= and ?rows=.
+// ?after= is the seq of the last frame a reconnecting client received; the
+// replay then holds only the output it missed.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	q := r.URL.Query()
+	size := pty.Size{Cols: parseDim(q.Get("cols"), 80), Rows: parseDim(q.Get("rows"), 24)}
+	after := int64(-1)
+	if q.Has("after") {
+		n, err := strconv.ParseInt(q.Get("after"), 10, 64)
+		if err != nil || n < 0 {
+			httpx.Error(w, http.StatusBadRequest, "after must be a non-negative integer")
+			return
+		}
+		after = n
+	}
+
+	att, created, err := h.svc.Attach(r.Context(), id, dir, q.Get("session"), q.Get("shell"), size, after)
+	switch {
+	case errors.Is(err, ErrUnknownShell), errors.Is(err, ErrInvalidName):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, ErrTooManySessions):
+		httpx.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	case err != nil:
+		slog.Error("start terminal", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not start terminal")
+		return
+	}
+	defer att.Detach()
+	if !created && q.Has("cols") && q.Has("rows") {
+		att.Resize(size)
+	}
+	if created && h.quota != nil {
+		stop, err := h.quota.StartTerminal(r.Context())
+		if err != nil {
+			h.svc.Kill(id, att.Name())
+			if errors.Is(err, runner.ErrQuotaExceeded) {
+				httpx.Error(w, http.StatusTooManyRequests, err.Error())
+			} else {
+				slog.Error("start terminal", "workspace", id, "err", err)
+				httpx.Error(w, http.StatusInternalServerError, "could not start terminal")
+			}
+			return
+		}
+		go func() {
+			<-att.Done()
+			stop()
+		}()
+	}
+
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	go func() {
+		defer att.Detach()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var f frame
+			if json.Unmarshal(data, &f) != nil {
+				continue
+			}
+			switch f.Type {
+			case "input":
+				att.Write([]byte(f.Data))
+			case "resize":
+				att.Resize(pty.Size{Cols: f.Cols, Rows: f.Rows})
+			}
+		}
+	}()
+
+	seq := att.Offset()
+	if conn.WriteJSON(frame{Type: "attached", Session: att.Name(), Created: created, Resumed: att.Resumed()}) != nil {
+		return
+	}
+	if replay := att.Scrollback(); len(replay) > 0 {
+		if conn.WriteJSON(frame{Type: "scrollback", Data: string(replay), Seq: seq}) != nil {
+			return
+		}
+	}
+	for out := range att.Output() {
+		seq += int64(len(out))
+		if conn.WriteJSON(frame{Type: "output", Data: string(out), Seq: seq}) != nil {
+			return
+		}
+	}
+	select {
+	case <-att.Done():
+		code := att.ExitCode()
+		conn.WriteJSON(frame{Type: "exit", ExitCode: &code})
+	default:
+		// Detached for falling behind; the client may reattach.
+		conn.CloseWithCode(ws.CloseTryAgainLater, "terminal output overflow")
+	}
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"terminals": h.svc.List(id)})
+}
+
+func (h *Handler) kill(w http.ResponseWriter, r *http.Request) {
+	err := h.svc.Kill(r.PathValue("id"), r.PathValue("name"))
+	if errors.Is(err, ErrNoSession) {
+		httpx.Error(w, http.StatusNotFound, "terminal session not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseDim(s string, def uint16) uint16 {
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || n == 0 {
+		return def
+	}
+	return uint16(n)
+}
@@ -0,0 +1,307 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/kube"
+)
+
+// KubernetesLauncher keeps one long-lived pod per workspace on a cluster,
+// and opens shells in it with kubectl exec. The pod mounts the workspace
+// from Claim, a ReadWriteMany volume claim that the server mounts at
+// ClaimRoot, so that both see the same files.
+//
+// A pod cannot be stopped, so stopping a workspace deletes its pod; the
+// files are on the claim and stay. The next command started in the
+// workspace creates the pod again.
+type KubernetesLauncher struct {
+	// Kube is the cluster the pods run on.
+	Kube *kube.Client
+	// Claim names the persistent volume claim holding the workspaces.
+	Claim string
+	// ClaimRoot is where the server mounts Claim. Workspace directories
+	// must be under it.
+	ClaimRoot string
+	// Image is the workspace pod image.
+	Image string
+	// ImageFor, when set, picks the image per workspace. An empty result
+	// falls back to Image.
+	ImageFor func(workspaceID string) string
+	// RuntimeClass names the pods' RuntimeClass, such as one for gVisor.
+	RuntimeClass string
+	// Env is extra environment of the pod, as "KEY=value".
+	Env []string
+	// EnvFor, when set, adds environment per workspace.
+	EnvFor func(workspaceID string) []string
+	// CPUs and MemoryMB limit the pod. RequestCPUs and RequestMemoryMB are
+	// what the scheduler reserves for it, and default to the limits.
+	CPUs            float64
+	MemoryMB        int64
+	RequestCPUs     float64
+	RequestMemoryMB int64
+	// Created, when set, is called with the workspace of each pod created
+	// once it has started, with the launcher locked: it must not start
+	// commands.
+	Created func(workspaceID string)
+
+	mu sync.Mutex
+}
+
+// podStartTimeout bounds how long a workspace pod may take to start,
+// pulling its image included.
+const podStartTimeout = 3 * time.Minute
+
+// controlDir holds the environment files of commands in workspace pods.
+const controlDir = "/run/ai-ide"
+
+// Command implements Launcher.
+func (k *KubernetesLauncher) Command(ctx context.Context, id, dir string, argv, env []string) (*exec.Cmd, error) {
+	return k.exec(ctx, id, dir, argv, env, true)
+}
+
+// Exec implements Launcher.
+func (k *KubernetesLauncher) Exec(ctx context.Context, id, dir string, argv, env []string) (*exec.Cmd, error) {
+	return k.exec(ctx, id, dir, argv, env, false)
+}
+
+// Root implements Launcher.
+func (k *KubernetesLauncher) Root(string) string { return "/workspace" }
+
+func (k *KubernetesLauncher) exec(ctx context.Context, id, dir string, argv, env []string, tty bool) (*exec.Cmd, error) {
+	name := ContainerName(id)
+	if err := k.ensure(ctx, name, id, dir); err != nil {
+		return nil, err
+	}
+	// kubectl exec takes no environment, and values passed as arguments
+	// would show in the host's process list. They are written to a file
+	// in the pod instead, which the command's shell reads and removes.
+	if len(env) > 0 {
+		file := controlDir + "/env-" + randomHex(8)
+		if err := k.writeEnv(ctx, name, file, env); err != nil {
+			return nil, err
+		}
+		argv = append([]string{"sh", "-c", `. "$0" && rm -f "$0" && exec "$@"`, file}, argv...)
+	}
+	return k.Kube.Exec(name, true, tty, argv...), nil
+}
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// writeEnv writes env to file in pod as shell exports, readable by root
+// only. Variables whose names the shell cannot export are left out.
+func (k *KubernetesLauncher) writeEnv(ctx context.Context, pod, file string, env []string) error {
+	var script strings.Builder
+	for _, e := range env {
+		key, value, _ := strings.Cut(e, "=")
+		if envName.MatchString(key) {
+			fmt.Fprintf(&script, "export %s='%s'\n", key, strings.ReplaceAll(value, "'", `'\''`))
+		}
+	}
+	c := k.Kube.Exec(pod, true, false, "sh", "-c", `umask 077 && cat >"$0"`, file)
+	cmd := exec.CommandContext(ctx, c.Path, c.Args[1:]...)
+	cmd.Stdin = strings.NewReader(script.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("terminal: write environment to pod: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ensure creates the workspace pod unless it is already there, and waits
+// for it to start. A pod that has ended, or runs another image than the
+// workspace now uses, is replaced.
+func (k *KubernetesLauncher) ensure(ctx context.Context, name, id, dir string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	image := k.image(id)
+	var pod kube.Pod
+	err := k.Kube.Get(ctx, "pod", name, &pod)
+	created := false
+	switch {
+	case errors.Is(err, kube.ErrNotFound):
+		err, created = k.create(ctx, name, id, dir, image), true
+	case err != nil:
+		return fmt.Errorf("terminal: get pod: %w", err)
+	case pod.Metadata.DeletionTimestamp != "",
+		pod.Status.Phase == kube.PodSucceeded, pod.Status.Phase == kube.PodFailed,
+		len(pod.Spec.Containers) == 0, pod.Spec.Containers[0].Image != image:
+		if err := k.Kube.Delete(ctx, "pod", name, true); err != nil {
+			return fmt.Errorf("terminal: delete pod: %w", err)
+		}
+		err, created = k.create(ctx, name, id, dir, image), true
+	case pod.Status.Phase == kube.PodRunning && len(pod.Status.ContainerStatuses) > 0 && pod.Status.ContainerStatuses[0].Ready:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := k.Kube.WaitReady(ctx, name, podStartTimeout); err != nil {
+		return fmt.Errorf("terminal: start pod: %w", err)
+	}
+	if created && k.Created != nil {
+		k.Created(id)
+	}
+	return nil
+}
+
+func (k *KubernetesLauncher) create(ctx context.Context, name, id, dir, image string) error {
+	sub, err := k.subPath(id, dir)
+	if err != nil {
+		return err
+	}
+	env := []kube.EnvVar{{Name: "HOME", Value: "/workspace"}, {Name: "TERM", Value: "xterm-256color"}}
+	extra := k.Env
+	if k.EnvFor != nil {
+		extra = append(extra[:len(extra):len(extra)], k.EnvFor(id)...)
+	}
+	for _, e := range extra {
+		if key, value, ok := strings.Cut(e, "="); ok {
+			env = append(env, kube.EnvVar{Name: key, Value: value})
+		}
+	}
+	requests := kube.Resources(k.RequestCPUs, k.RequestMemoryMB)
+	limits := kube.Resources(k.CPUs, k.MemoryMB)
+	for r, q := range limits {
+		if _, ok := requests[r]; !ok {
+			requests[r] = q
+		}
+	}
+	pod := kube.NewPod(kube.ObjectMeta{
+		Name: name,
+		Labels: map[string]string{
+			kube.LabelType:     "workspace",
+			kube.LabelNetwork:  "on",
+			"ai-ide.workspace": id,
+		},
+	}, kube.PodSpec{
+		Containers: []kube.Container{{
+			Name:       "workspace",
+			Image:      image,
+			Command:    []string{"sleep", "infinity"},
+			WorkingDir: "/workspace",
+			Env:        env,
+			Resources:  kube.ResourceRequirements{Requests: requests, Limits: limits},
+			VolumeMounts: []kube.VolumeMount{
+				{Name: "workspace", MountPath: "/workspace", SubPath: sub},
+				{Name: "tmp", MountPath: "/tmp"},
+				{Name: "control", MountPath: controlDir},
+			},
+			SecurityContext: &kube.SecurityContext{
+				AllowPrivilegeEscalation: kube.Bool(false),
+				Capabilities: &kube.Capabilities{
+					Drop: []string{"ALL"},
+					Add:  []string{"SETUID", "SETGID", "CHOWN", "DAC_OVERRIDE", "FOWNER", "NET_BIND_SERVICE"},
+				},
+			},
+		}},
+		Volumes: []kube.Volume{
+			{Name: "workspace", PersistentVolumeClaim: &kube.ClaimVolumeSource{ClaimName: k.Claim}},
+			{Name: "tmp", EmptyDir: &kube.EmptyDirSource{Medium: "Memory", SizeLimit: "500Mi"}},
+			{Name: "control", EmptyDir: &kube.EmptyDirSource{Medium: "Memory", SizeLimit: "1Mi"}},
+		},
+		RestartPolicy:                 "Always",
+		RuntimeClassName:              k.RuntimeClass,
+		AutomountServiceAccountToken:  kube.Bool(false),
+		EnableServiceLinks:            kube.Bool(false),
+		TerminationGracePeriodSeconds: kube.Int64(2),
+	})
+	if err := k.Kube.Create(ctx, pod); err != nil {
+		return fmt.Errorf("terminal: create pod: %w", err)
+	}
+	return nil
+}
+
+// subPath returns where dir is on the claim.
+func (k *KubernetesLauncher) subPath(id, dir string) (string, error) {
+	if k.Claim == "" {
+		return "", errors.New("terminal: no volume claim for workspace pods")
+	}
+	root, err := filepath.Abs(k.ClaimRoot)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("terminal: workspace %s is not on the volume claim", id)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Running lists the workspaces whose pods are running.
+func (k *KubernetesLauncher) Running(ctx context.Context) ([]string, error) {
+	var pods kube.PodList
+	if err := k.Kube.List(ctx, "pods", kube.LabelType+"=workspace", &pods); err != nil {
+		return nil, fmt.Errorf("terminal: list pods: %w", err)
+	}
+	var ids []string
+	for _, p := range pods.Items {
+		if p.Status.Phase == kube.PodRunning && p.Metadata.DeletionTimestamp == "" {
+			ids = append(ids, p.Metadata.Labels["ai-ide.workspace"])
+		}
+	}
+	return ids, nil
+}
+
+// Stop deletes the workspace pod for id. Its files are kept on the claim.
+func (k *KubernetesLauncher) Stop(ctx context.Context, id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.Kube.Delete(ctx, "pod", ContainerName(id), false); err != nil {
+		return fmt.Errorf("terminal: delete pod: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the workspace pod for id, like Stop: pods keep nothing
+// of their own.
+func (k *KubernetesLauncher) Remove(ctx context.Context, id string) error {
+	return k.Stop(ctx, id)
+}
+
+// Address returns the IP address of the workspace pod for id, or
+// ErrNotRunning if it is not running.
+func (k *KubernetesLauncher) Address(ctx context.Context, id string) (string, error) {
+	var pod kube.Pod
+	if err := k.Kube.Get(ctx, "pod", ContainerName(id), &pod); err != nil || pod.Status.Phase != kube.PodRunning {
+		return "", ErrNotRunning
+	}
+	if pod.Status.PodIP == "" {
+		return "", errors.New("terminal: workspace pod has no network address")
+	}
+	return pod.Status.PodIP, nil
+}
+
+// Listening lists the TCP ports listened on in the workspace pod for id.
+// A missing pod has none; it is not created.
+func (k *KubernetesLauncher) Listening(ctx context.Context, id string) ([]Listener, error) {
+	c := k.Kube.Exec(ContainerName(id), false, false, "cat", "/proc/net/tcp", "/proc/net/tcp6")
+	out, err := exec.CommandContext(ctx, c.Path, c.Args[1:]...).Output()
+	if len(out) == 0 && err != nil {
+		return nil, nil
+	}
+	return ParseProcNet(string(out)), nil
+}
+
+func (k *KubernetesLauncher) image(id string) string {
+	if k.ImageFor != nil {
+		if img := k.ImageFor(id); img != "" {
+			return img
+		}
+	}
+	if k.Image == "" {
+		return "golang:1.22"
+	}
+	return k.Image
+}
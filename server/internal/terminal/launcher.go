@@ -0,0 +1,343 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Launcher builds the commands that run inside a workspace's environment.
+type Launcher interface {
+	// Command returns a command meant to be started with a PTY attached.
+	Command(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	// Exec is like Command but without a terminal, for programs that
+	// speak a protocol over stdin and stdout.
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	// Root returns the path at which commands see the workspace directory.
+	Root(dir string) string
+}
+
+// LocalLauncher runs shells directly on the host inside the workspace
+// directory. It offers no isolation and is meant for local development.
+type LocalLauncher struct{}
+
+// Command implements Launcher.
+func (LocalLauncher) Command(ctx context.Context, _, dir string, argv, env []string) (*exec.Cmd, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	return cmd, nil
+}
+
+// Exec implements Launcher.
+func (l LocalLauncher) Exec(ctx context.Context, id, dir string, argv, env []string) (*exec.Cmd, error) {
+	return l.Command(ctx, id, dir, argv, env)
+}
+
+// Root implements Launcher.
+func (LocalLauncher) Root(dir string) string { return dir }
+
+// DockerLauncher keeps one long-lived container per workspace, with the
+// workspace mounted at /workspace, and opens shells in it with docker exec.
+type DockerLauncher struct {
+	// Binary is the docker executable; defaults to "docker".
+	Binary string
+	// Image is the workspace container image.
+	Image string
+	// ImageFor, when set, picks the image per workspace, such as the one
+	// for the workspace's Go version. An empty result falls back to Image.
+	ImageFor func(workspaceID string) string
+	// Runtime selects an OCI runtime such as "runsc".
+	Runtime string
+	// Network is the docker network mode; defaults to "bridge".
+	Network string
+	// Env is extra environment of the container, as "KEY=value".
+	Env []string
+	// EnvFor, when set, adds environment per workspace, such as its
+	// network policy proxy credentials.
+	EnvFor func(workspaceID string) []string
+	// Hosts are extra host name mappings, as "name:ip" for --add-host.
+	Hosts []string
+	// Created, when set, is called with the workspace of each container
+	// created, with the launcher locked: it must not start commands.
+	Created   func(workspaceID string)
+	CPUs      float64
+	MemoryMB  int64
+	PidsLimit int64
+
+	mu sync.Mutex
+}
+
+// ContainerName returns the name of the workspace container for id.
+func ContainerName(id string) string {
+	return "ai-ide-ws-" + id
+}
+
+// Command implements Launcher.
+func (d *DockerLauncher) Command(ctx context.Context, id, dir string, argv, env []string) (*exec.Cmd, error) {
+	return d.exec(ctx, id, dir, argv, env, true)
+}
+
+// Exec implements Launcher.
+func (d *DockerLauncher) Exec(ctx context.Context, id, dir string, argv, env []string) (*exec.Cmd, error) {
+	return d.exec(ctx, id, dir, argv, env, false)
+}
+
+// Root implements Launcher.
+func (d *DockerLauncher) Root(string) string { return "/workspace" }
+
+func (d *DockerLauncher) exec(ctx context.Context, id, dir string, argv, env []string, tty bool) (*exec.Cmd, error) {
+	name := ContainerName(id)
+	if err := d.ensure(ctx, name, id, dir); err != nil {
+		return nil, err
+	}
+	args := []string{"exec", "--interactive", "--workdir", "/workspace"}
+	if tty {
+		args = append(args, "--tty")
+	}
+	// Values are passed in docker's own environment and named with
+	// --env, so they do not show in the host's process list.
+	var values []string
+	for _, e := range env {
+		k, _, ok := strings.Cut(e, "=")
+		if !ok {
+			continue
+		}
+		args = append(args, "--env", k)
+		values = append(values, e)
+	}
+	args = append(args, name)
+	cmd := exec.Command(d.binary(), append(args, argv...)...)
+	if len(values) > 0 {
+		cmd.Env = append(os.Environ(), values...)
+	}
+	return cmd, nil
+}
+
+// ensure starts the workspace container unless it is already running. A
+// container created from another image than the workspace now uses is
+// replaced.
+func (d *DockerLauncher) ensure(ctx context.Context, name, id, dir string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	image := d.image(id)
+	out, err := exec.CommandContext(ctx, d.binary(), "inspect", "--format",
+		`{{.State.Running}} {{index .Config.Labels "ai-ide.image"}}`, name).Output()
+	if err == nil {
+		running, current, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+		if current != image {
+			if out, err := exec.CommandContext(ctx, d.binary(), "rm", "--force", name).CombinedOutput(); err != nil {
+				return fmt.Errorf("terminal: remove container: %v: %s", err, strings.TrimSpace(string(out)))
+			}
+			return d.create(ctx, name, id, dir, image)
+		}
+		if running == "true" {
+			return nil
+		}
+		if out, err := exec.CommandContext(ctx, d.binary(), "start", name).CombinedOutput(); err != nil {
+			return fmt.Errorf("terminal: start container: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	return d.create(ctx, name, id, dir, image)
+}
+
+// Running lists the workspaces whose containers are running.
+func (d *DockerLauncher) Running(ctx context.Context) ([]string, error) {
+	out, err := exec.CommandContext(ctx, d.binary(), "ps",
+		"--filter", "label=ai-ide.type=workspace", "--filter", "status=running",
+		"--format", `{{.Label "ai-ide.workspace"}}`).Output()
+	if err != nil {
+		return nil, fmt.Errorf("terminal: list containers: %w", err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// Stop stops the workspace container for id. The container and its file
+// system are kept, and the next command started in the workspace starts
+// it again.
+func (d *DockerLauncher) Stop(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if out, err := exec.CommandContext(ctx, d.binary(), "stop", "--time", "2", ContainerName(id)).CombinedOutput(); err != nil {
+		return fmt.Errorf("terminal: stop container: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Remove deletes the workspace container for id with its file system,
+// for sandboxes used once, such as those grading a submission.
+func (d *DockerLauncher) Remove(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if out, err := exec.CommandContext(ctx, d.binary(), "rm", "--force", ContainerName(id)).CombinedOutput(); err != nil {
+		return fmt.Errorf("terminal: remove container: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (d *DockerLauncher) create(ctx context.Context, name, id, dir, image string) error {
+	args := []string{
+		"run", "--detach", "--name", name,
+		"--label", "ai-ide.type=workspace",
+		"--label", "ai-ide.workspace=" + id,
+		"--label", "ai-ide.image=" + image,
+		"--network", d.network(),
+		"--cap-drop", "ALL",
+		"--cap-add", "SETUID", "--cap-add", "SETGID", "--cap-add", "CHOWN",
+		"--cap-add", "DAC_OVERRIDE", "--cap-add", "FOWNER", "--cap-add", "NET_BIND_SERVICE",
+		"--security-opt", "no-new-privileges:true",
+		"--tmpfs", "/tmp:rw,exec,nosuid,size=500m",
+		"--volume", dir + ":/workspace",
+		"--workdir", "/workspace",
+		"--env", "HOME=/workspace",
+		"--env", "TERM=xterm-256color",
+	}
+	if d.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(d.CPUs, 'f', -1, 64))
+	}
+	if d.MemoryMB > 0 {
+		args = append(args, "--memory", fmt.Sprintf("%dm", d.MemoryMB))
+	}
+	if d.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.FormatInt(d.PidsLimit, 10))
+	}
+	if d.Runtime != "" {
+		args = append(args, "--runtime", d.Runtime)
+	}
+	for _, e := range d.Env {
+		args = append(args, "--env", e)
+	}
+	if d.EnvFor != nil {
+		for _, e := range d.EnvFor(id) {
+			args = append(args, "--env", e)
+		}
+	}
+	for _, h := range d.Hosts {
+		args = append(args, "--add-host", h)
+	}
+	args = append(args, image, "sleep", "infinity")
+	if out, err := exec.CommandContext(ctx, d.binary(), args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("terminal: create container: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if d.Created != nil {
+		d.Created(id)
+	}
+	return nil
+}
+
+func (d *DockerLauncher) binary() string {
+	if d.Binary == "" {
+		return "docker"
+	}
+	return d.Binary
+}
+
+func (d *DockerLauncher) image(id string) string {
+	if d.ImageFor != nil {
+		if img := d.ImageFor(id); img != "" {
+			return img
+		}
+	}
+	if d.Image == "" {
+		return "golang:1.22"
+	}
+	return d.Image
+}
+
+func (d *DockerLauncher) network() string {
+	if d.Network == "" {
+		return "bridge"
+	}
+	return d.Network
+}
+
+// Listener is a TCP port a process in a workspace listens on. Loopback
+// listeners only accept connections from inside the workspace.
+type Listener struct {
+	Port     int  `json:"port"`
+	Loopback bool `json:"loopback,omitempty"`
+}
+
+// ErrNotRunning is returned by Address when the workspace container is
+// not running.
+var ErrNotRunning = errors.New("terminal: workspace container is not running")
+
+// Address returns the IP address of the workspace container for id, or
+// an error if it is not running or has no network.
+func (d *DockerLauncher) Address(ctx context.Context, id string) (string, error) {
+	out, err := exec.CommandContext(ctx, d.binary(), "inspect", "--format",
+		`{{.State.Running}}{{range .NetworkSettings.Networks}} {{.IPAddress}}{{end}}`, ContainerName(id)).Output()
+	if err != nil {
+		return "", ErrNotRunning
+	}
+	f := strings.Fields(string(out))
+	if len(f) == 0 || f[0] != "true" {
+		return "", ErrNotRunning
+	}
+	for _, ip := range f[1:] {
+		if ip != "" {
+			return ip, nil
+		}
+	}
+	return "", errors.New("terminal: workspace container has no network address")
+}
+
+// Listening lists the TCP ports listened on in the workspace container
+// for id. A stopped container has none; it is not started.
+func (d *DockerLauncher) Listening(ctx context.Context, id string) ([]Listener, error) {
+	out, err := exec.CommandContext(ctx, d.binary(), "exec", ContainerName(id),
+		"cat", "/proc/net/tcp", "/proc/net/tcp6").Output()
+	if len(out) == 0 && err != nil {
+		return nil, nil
+	}
+	return ParseProcNet(string(out)), nil
+}
+
+// ParseProcNet returns the listening sockets in the contents of
+// /proc/net/tcp and /proc/net/tcp6, one per port, sorted by port.
+func ParseProcNet(data string) []Listener {
+	ports := make(map[int]bool) // port -> loopback only
+	for _, line := range strings.Split(data, "\n") {
+		f := strings.Fields(line)
+		// sl local_address rem_address st ...; 0A is TCP_LISTEN.
+		if len(f) < 4 || f[3] != "0A" {
+			continue
+		}
+		addr, portHex, ok := strings.Cut(f[1], ":")
+		if !ok {
+			continue
+		}
+		port, err := strconv.ParseUint(portHex, 16, 16)
+		if err != nil || port == 0 {
+			continue
+		}
+		loopback := addr == "0100007F" || addr == "00000000000000000000000001000000"
+		if prev, seen := ports[int(port)]; seen {
+			loopback = prev && loopback
+		}
+		ports[int(port)] = loopback
+	}
+	out := make([]Listener, 0, len(ports))
+	for p, lo := range ports {
+		out = append(out, Listener{Port: p, Loopback: lo})
+	}
+	slices.SortFunc(out, func(a, b Listener) int { return a.Port - b.Port })
+	return out
+}
+
+// Address implements the port proxy's view of a local workspace, whose
+// processes listen on the host itself.
+func (LocalLauncher) Address(context.Context, string) (string, error) { return "127.0.0.1", nil }
+
+// Listening returns no ports: on the host every process shares one
+// network, so ports cannot be attributed to a workspace and have to be
+// declared.
+func (LocalLauncher) Listening(context.Context, string) ([]Listener, error) { return nil, nil }
@@ -0,0 +1,434 @@
+package terminal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/pty"
+)
+
+// Bus relays messages between servers, so a client connected to one
+// server can use a session running on another. Each server subscribes to
+// the channels of the sessions it runs and answers requests for them
+// there.
+type Bus interface {
+	// Publish sends data to every subscription of channel and returns
+	// how many there are.
+	Publish(channel string, data []byte) (int, error)
+	// Subscribe calls fn with the messages published to channel, in
+	// order, until cancel is called. fn is called with nil when messages
+	// may have been missed.
+	Subscribe(channel string, fn func(data []byte)) (cancel func(), err error)
+}
+
+// relayTimeout bounds how long a server waits for another to answer.
+const relayTimeout = 5 * time.Second
+
+// busMessage is a request to the server running a session, or an answer
+// on the requester's reply channel.
+//
+// Requests on a session's channel: attach (with Reply, and After when the
+// client resumes), input, resize, detach and kill. Requests on a
+// workspace's channel: list (with Reply). Answers: attached (with the
+// replay in Data, its Offset and Resumed), output, exit, closed and
+// sessions.
+type busMessage struct {
+	Type     string `json:"type"`
+	Node     string `json:"node,omitempty"`
+	Reply    string `json:"reply,omitempty"`
+	After    *int64 `json:"after,omitempty"`
+	Offset   int64  `json:"offset,omitempty"`
+	Resumed  bool   `json:"resumed,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+	Cols     uint16 `json:"cols,omitempty"`
+	Rows     uint16 `json:"rows,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Sessions []Info `json:"sessions,omitempty"`
+}
+
+func sessionChannel(workspaceID, name string) string {
+	return "terminal:" + workspaceID + ":" + name
+}
+
+func workspaceChannel(workspaceID string) string {
+	return "terminals:" + workspaceID
+}
+
+func (s *Service) publish(channel string, msg busMessage) (int, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	return s.cfg.Bus.Publish(channel, data)
+}
+
+// replyChannel returns a new channel for answers to this server.
+func (s *Service) replyChannel() string {
+	return "terminal-reply:" + s.node + ":" + randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// workspaceSub is this server's subscription to a workspace's channel,
+// shared by its sessions there.
+type workspaceSub struct {
+	n      int
+	cancel func()
+}
+
+// serve subscribes a started session to its channel, and the server to
+// the session's workspace channel, so other servers can reach it.
+func (s *Service) serve(sess *Session) error {
+	sess.inbox = make(chan busMessage, clientBuffer)
+	cancel, err := s.cfg.Bus.Subscribe(sessionChannel(sess.workspaceID, sess.name), sess.receive)
+	if err != nil {
+		return err
+	}
+	go sess.input()
+
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	sub := s.workspaceSubs[sess.workspaceID]
+	if sub == nil {
+		wsID := sess.workspaceID
+		wsCancel, err := s.cfg.Bus.Subscribe(workspaceChannel(wsID), func(data []byte) { s.receive(wsID, data) })
+		if err != nil {
+			cancel()
+			return err
+		}
+		sub = &workspaceSub{cancel: wsCancel}
+		s.workspaceSubs[wsID] = sub
+	}
+	sub.n++
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			cancel()
+			s.subMu.Lock()
+			defer s.subMu.Unlock()
+			if sub.n--; sub.n == 0 {
+				sub.cancel()
+				delete(s.workspaceSubs, sess.workspaceID)
+			}
+		})
+	}
+	sess.mu.Lock()
+	sess.unsubscribe = unsubscribe
+	sess.mu.Unlock()
+	// The session may have ended already, too early for remove to see
+	// the subscription.
+	s.mu.Lock()
+	gone := s.sessions[sess.workspaceID][sess.name] != sess
+	s.mu.Unlock()
+	if gone {
+		go unsubscribe()
+	}
+	return nil
+}
+
+// receive answers a request on a workspace's channel.
+func (s *Service) receive(workspaceID string, data []byte) {
+	var msg busMessage
+	if data == nil || json.Unmarshal(data, &msg) != nil || msg.Node == s.node || msg.Type != "list" {
+		return
+	}
+	// Publishing waits on the network, so it leaves the bus's goroutine.
+	go s.publish(msg.Reply, busMessage{Type: "sessions", Sessions: s.localList(workspaceID)})
+}
+
+// receive handles a request for a session that runs here. Input is
+// written by the session's input goroutine, as the PTY may block.
+func (sess *Session) receive(data []byte) {
+	if data == nil {
+		// Detach requests may have been missed; remote clients reattach.
+		sess.mu.Lock()
+		for _, a := range sess.remotes {
+			sess.detachLocked(a)
+		}
+		sess.mu.Unlock()
+		return
+	}
+	var msg busMessage
+	if json.Unmarshal(data, &msg) != nil {
+		return
+	}
+	switch msg.Type {
+	case "attach":
+		if msg.Reply != "" {
+			after := int64(-1)
+			if msg.After != nil {
+				after = *msg.After
+			}
+			go sess.serveRemote(msg.Reply, after)
+		}
+	case "input", "resize":
+		select {
+		case sess.inbox <- msg:
+		default:
+			slog.Warn("dropping remote terminal input", "workspace", sess.workspaceID, "session", sess.name)
+		}
+	case "detach":
+		sess.mu.Lock()
+		if a := sess.remotes[msg.Reply]; a != nil {
+			sess.detachLocked(a)
+		}
+		sess.mu.Unlock()
+	case "kill":
+		go func() {
+			sess.svc.remove(sess)
+			sess.Close()
+		}()
+	}
+}
+
+// input writes remote input to the PTY until the shell exits.
+func (sess *Session) input() {
+	for {
+		select {
+		case msg := <-sess.inbox:
+			if msg.Type == "resize" {
+				sess.Resize(pty.Size{Cols: msg.Cols, Rows: msg.Rows})
+			} else {
+				sess.Write(msg.Data)
+			}
+		case <-sess.done:
+			return
+		}
+	}
+}
+
+// serveRemote attaches a client of another server, which has the output
+// up to after, and relays the session's output to it on reply.
+func (sess *Session) serveRemote(reply string, after int64) {
+	s := sess.svc
+	a := sess.attach(after)
+	if a == nil {
+		s.publish(reply, busMessage{Type: "closed"})
+		return
+	}
+	sess.mu.Lock()
+	sess.remotes[reply] = a
+	sess.mu.Unlock()
+	defer func() {
+		sess.mu.Lock()
+		delete(sess.remotes, reply)
+		sess.mu.Unlock()
+	}()
+
+	attached := busMessage{Type: "attached", Data: a.replay, Offset: a.offset, Resumed: a.resumed}
+	if n, err := s.publish(reply, attached); err != nil || n == 0 {
+		a.Detach()
+		return
+	}
+	for out := range a.c {
+		// A client whose server has gone has no subscription left.
+		if n, err := s.publish(reply, busMessage{Type: "output", Data: out}); err != nil || n == 0 {
+			a.Detach()
+			return
+		}
+	}
+	select {
+	case <-sess.done:
+		code := sess.ExitCode()
+		s.publish(reply, busMessage{Type: "exit", ExitCode: &code})
+	default:
+		s.publish(reply, busMessage{Type: "closed"})
+	}
+}
+
+// detachLocked detaches a client from the session; sess.mu must be held.
+func (sess *Session) detachLocked(a *Attachment) {
+	if _, ok := sess.clients[a]; ok {
+		delete(sess.clients, a)
+		close(a.c)
+	}
+	sess.armIdleLocked()
+}
+
+// remote is a session running on another server, as seen by a client
+// attached here.
+type remote struct {
+	svc     *Service
+	name    string
+	channel string // the session's
+	reply   string // this attachment's
+	cancel  func()
+	done    chan struct{}
+
+	mu       sync.Mutex
+	exitCode int
+	ended    bool // Output is closed
+}
+
+// attachRemote attaches to a session running on another server, or
+// returns ErrNoSession if no server runs it. after is as for Attach.
+func (s *Service) attachRemote(workspaceID, name string, after int64) (*Attachment, error) {
+	r := &remote{
+		svc:      s,
+		name:     name,
+		channel:  sessionChannel(workspaceID, name),
+		reply:    s.replyChannel(),
+		done:     make(chan struct{}),
+		exitCode: -1,
+	}
+	a := &Attachment{remote: r, c: make(chan []byte, clientBuffer)}
+	attached := make(chan *busMessage, 1)
+	cancel, err := s.cfg.Bus.Subscribe(r.reply, func(data []byte) { a.receive(data, attached) })
+	if err != nil {
+		return nil, err
+	}
+	r.cancel = cancel
+	req := busMessage{Type: "attach", Reply: r.reply}
+	if after >= 0 {
+		req.After = &after
+	}
+	n, err := s.publish(r.channel, req)
+	if err != nil || n == 0 {
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrNoSession
+	}
+	select {
+	case msg := <-attached:
+		if msg == nil {
+			cancel()
+			return nil, ErrNoSession
+		}
+		a.replay, a.offset, a.resumed = msg.Data, msg.Offset, msg.Resumed
+		return a, nil
+	case <-time.After(relayTimeout):
+		a.Detach()
+		return nil, ErrNoSession
+	}
+}
+
+// receive handles an answer from the server running the session.
+// attached receives the first answer: the attachment, or nil when the
+// session ended first.
+func (a *Attachment) receive(data []byte, attached chan<- *busMessage) {
+	r := a.remote
+	var msg busMessage
+	if data != nil && json.Unmarshal(data, &msg) != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return
+	}
+	switch {
+	case data == nil:
+		// Output may have been missed; the client must reattach.
+		r.endLocked(a)
+		go r.svc.publish(r.channel, busMessage{Type: "detach", Reply: r.reply})
+	case msg.Type == "attached":
+		select {
+		case attached <- &msg:
+		default:
+		}
+	case msg.Type == "output":
+		select {
+		case a.c <- msg.Data:
+		default:
+			r.endLocked(a)
+			go r.svc.publish(r.channel, busMessage{Type: "detach", Reply: r.reply})
+		}
+	case msg.Type == "exit":
+		if msg.ExitCode != nil {
+			r.exitCode = *msg.ExitCode
+		}
+		close(r.done)
+		r.endLocked(a)
+	case msg.Type == "closed":
+		select {
+		case attached <- nil:
+		default:
+		}
+		r.endLocked(a)
+	}
+}
+
+// endLocked closes the attachment's output; r.mu must be held.
+func (r *remote) endLocked(a *Attachment) {
+	if !r.ended {
+		r.ended = true
+		close(a.c)
+	}
+}
+
+// listRemote returns the sessions of a workspace that run on other
+// servers.
+func (s *Service) listRemote(workspaceID string) []Info {
+	reply := s.replyChannel()
+	answers := make(chan []Info, 16)
+	cancel, err := s.cfg.Bus.Subscribe(reply, func(data []byte) {
+		var msg busMessage
+		if data != nil && json.Unmarshal(data, &msg) == nil && msg.Type == "sessions" {
+			select {
+			case answers <- msg.Sessions:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("list remote terminals", "workspace", workspaceID, "err", err)
+		return nil
+	}
+	defer cancel()
+	n, err := s.publish(workspaceChannel(workspaceID), busMessage{Type: "list", Node: s.node, Reply: reply})
+	if err != nil {
+		slog.Error("list remote terminals", "workspace", workspaceID, "err", err)
+		return nil
+	}
+	// This server ignores its own request.
+	s.subMu.Lock()
+	if s.workspaceSubs[workspaceID] != nil {
+		n--
+	}
+	s.subMu.Unlock()
+
+	var out []Info
+	timeout := time.After(relayTimeout)
+	for ; n > 0; n-- {
+		select {
+		case infos := <-answers:
+			out = append(out, infos...)
+		case <-timeout:
+			return out
+		}
+	}
+	return out
+}
+
+// killRemote ends a session running on another server.
+func (s *Service) killRemote(workspaceID, name string) error {
+	n, err := s.publish(sessionChannel(workspaceID, name), busMessage{Type: "kill"})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoSession
+	}
+	return nil
+}
+
+// sortInfo orders sessions oldest first.
+func sortInfo(out []Info) {
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].Name < out[j].Name
+	})
+}
@@ -0,0 +1,69 @@
+package terminal
+
+import "unicode/utf8"
+
+// scrollback keeps the most recent output of a session in a fixed-size ring
+// so a reattaching client can redraw the screen, or resume where it left
+// off.
+type scrollback struct {
+	buf   []byte
+	size  int
+	head  int // next write position
+	full  bool
+	total int64 // bytes written in all
+}
+
+func newScrollback(size int) *scrollback {
+	return &scrollback{buf: make([]byte, size), size: size}
+}
+
+func (s *scrollback) Write(p []byte) {
+	s.total += int64(len(p))
+	if s.size == 0 {
+		return
+	}
+	if len(p) >= s.size {
+		copy(s.buf, p[len(p)-s.size:])
+		s.head, s.full = 0, true
+		return
+	}
+	n := copy(s.buf[s.head:], p)
+	if n < len(p) {
+		copy(s.buf, p[n:])
+		s.full = true
+	}
+	if s.head+len(p) >= s.size {
+		s.full = true
+	}
+	s.head = (s.head + len(p)) % s.size
+}
+
+// Bytes returns the buffered output, oldest first. When the ring has wrapped
+// the result starts at a rune boundary.
+func (s *scrollback) Bytes() []byte {
+	if !s.full {
+		return append([]byte(nil), s.buf[:s.head]...)
+	}
+	out := make([]byte, 0, s.size)
+	out = append(out, s.buf[s.head:]...)
+	out = append(out, s.buf[:s.head]...)
+	for len(out) > 0 && !utf8.RuneStart(out[0]) {
+		out = out[1:]
+	}
+	return out
+}
+
+// Offset returns how many bytes of output have been written in all: the
+// offset, in the session's output, of the end of the buffer.
+func (s *scrollback) Offset() int64 { return s.total }
+
+// Since returns the output written after offset, and true, when the ring
+// still holds all of it. Otherwise it returns the whole buffer and false.
+func (s *scrollback) Since(offset int64) ([]byte, bool) {
+	out := s.Bytes()
+	missing := s.total - offset
+	if offset < 0 || missing < 0 || missing > int64(len(out)) {
+		return out, false
+	}
+	return out[int64(len(out))-missing:], true
+}
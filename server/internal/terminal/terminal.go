@@ -0,0 +1,676 @@
+// Package terminal provides interactive shells in workspace sandboxes. Each
+// session owns a PTY whose output is streamed to the editor over WebSocket
+// and whose input, including window resizes, comes back the same way.
+//
+// Sessions are named and outlive the socket that created them: a client
+// that reloads the page reattaches by name and receives the session's
+// scrollback before live output resumes. A client that lost its socket
+// tells how much output it has, and receives only what it missed if the
+// scrollback still holds it. A session with no attached client is killed
+// after Config.DetachedTimeout.
+//
+// With a Bus (see Config.Bus), a client may reattach through any server:
+// the server running the session relays its output to the client's server
+// and takes input back. Config.MaxSessions still counts the sessions each
+// server runs.
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/metrics"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/pty"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/utf8x"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Shells maps the names clients may request to the command to run.
+	Shells map[string][]string
+	// DefaultShell names the entry of Shells used when none is requested.
+	DefaultShell string
+	// Env is added to every shell's environment.
+	Env []string
+	// ScrollbackBytes is how much recent output each session keeps for
+	// replay on reattach; defaults to 64 KiB.
+	ScrollbackBytes int
+	// MaxSessions caps concurrent sessions per workspace; defaults to 8.
+	MaxSessions int
+	// DetachedTimeout is how long a session survives without any attached
+	// client; defaults to 30 minutes.
+	DetachedTimeout time.Duration
+	// Bus, if set, lets clients of other servers attach to the sessions
+	// running here, and clients here to theirs.
+	Bus Bus
+	// Metrics, when set, receives the sessions running here and those
+	// started.
+	Metrics *metrics.Registry
+	// Recorder, if set, is given the input and output of the sessions
+	// running here.
+	Recorder Recorder
+}
+
+// Recorder follows the traffic of terminal sessions, as session
+// recordings do. Its method must not block.
+type Recorder interface {
+	Terminal(workspaceID, session string, input bool, data []byte)
+}
+
+var (
+	// ErrUnknownShell is returned when a client asks for a shell not in Config.Shells.
+	ErrUnknownShell = errors.New("terminal: unknown shell")
+	// ErrInvalidName is returned for session names that are not 1-32
+	// letters, digits, '-' or '_'.
+	ErrInvalidName = errors.New("terminal: invalid session name")
+	// ErrNoSession is returned when a named session does not exist.
+	ErrNoSession = errors.New("terminal: no such session")
+	// ErrTooManySessions is returned when a workspace is at Config.MaxSessions.
+	ErrTooManySessions = errors.New("terminal: too many sessions")
+	// ErrClosed is returned once the Service has been closed.
+	ErrClosed = errors.New("terminal: service closed")
+)
+
+var nameRE = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// clientBuffer is how many output chunks an attached client may lag behind
+// before it is disconnected. Dropping terminal output would corrupt the
+// screen, so a slow client is detached instead and can reattach.
+const clientBuffer = 256
+
+// Service starts terminal sessions through a Launcher and keeps track of
+// them per workspace.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	mu       sync.Mutex
+	sessions map[string]map[string]*Session // workspace ID -> name -> session
+	seq      int
+	closed   bool
+
+	node          string // identifies this server on the bus
+	subMu         sync.Mutex
+	workspaceSubs map[string]*workspaceSub
+
+	started *metrics.Counter
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if len(cfg.Shells) == 0 {
+		cfg.Shells = map[string][]string{
+			"bash": {"/bin/bash", "-l"},
+			"sh":   {"/bin/sh"},
+		}
+	}
+	if cfg.DefaultShell == "" {
+		cfg.DefaultShell = "bash"
+		if _, ok := cfg.Shells["bash"]; !ok {
+			names := make([]string, 0, len(cfg.Shells))
+			for n := range cfg.Shells {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			cfg.DefaultShell = names[0]
+		}
+	}
+	if cfg.ScrollbackBytes <= 0 {
+		cfg.ScrollbackBytes = 64 << 10
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = 8
+	}
+	if cfg.DetachedTimeout <= 0 {
+		cfg.DetachedTimeout = 30 * time.Minute
+	}
+	cfg.Env = append([]string{"TERM=xterm-256color", "LANG=C.UTF-8"}, cfg.Env...)
+	s := &Service{
+		cfg:           cfg,
+		launcher:      l,
+		sessions:      make(map[string]map[string]*Session),
+		node:          randomHex(8),
+		workspaceSubs: make(map[string]*workspaceSub),
+	}
+	s.started = cfg.Metrics.Counter("webide_terminal_sessions_started_total", "Terminal sessions started.")
+	cfg.Metrics.GaugeFunc("webide_terminal_sessions", "Terminal sessions running on this server.", func() float64 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		n := 0
+		for _, ws := range s.sessions {
+			n += len(ws)
+		}
+		return float64(n)
+	})
+	return s
+}
+
+// Info describes a session for listings.
+type Info struct {
+	Name      string    `json:"name"`
+	Shell     string    `json:"shell"`
+	CreatedAt time.Time `json:"createdAt"`
+	Clients   int       `json:"clients"`
+	Cols      uint16    `json:"cols"`
+	Rows      uint16    `json:"rows"`
+}
+
+// Session is a running shell attached to a PTY.
+type Session struct {
+	svc         *Service
+	workspaceID string
+	name        string
+	shell       string
+	created     time.Time
+	master      *os.File
+	cmd         *exec.Cmd
+	started     chan struct{} // closed once the shell has started or failed to
+	done        chan struct{}
+
+	mu       sync.Mutex
+	exitCode int
+	closed   bool
+	ended    bool // output has reached EOF
+	size     pty.Size
+	scroll   *scrollback
+	clients  map[*Attachment]struct{}
+	idle     *time.Timer
+	// remotes are the clients of other servers, by reply channel.
+	remotes map[string]*Attachment
+
+	inbox       chan busMessage // input from other servers
+	unsubscribe func()          // set while the session is on the bus
+}
+
+// Attachment is one client's view of a session. Output delivers live
+// terminal output after the replay returned by Scrollback.
+type Attachment struct {
+	sess    *Session
+	remote  *remote // set instead of sess for a session on another server
+	replay  []byte
+	offset  int64 // of the end of replay in the session's output
+	resumed bool  // replay starts at the offset asked for
+	c       chan []byte
+}
+
+// Attach connects to the session called name in the workspace at dir,
+// starting it with shell if it does not exist. An empty name starts a new
+// session with a generated name. created reports whether a shell was
+// started.
+//
+// after, unless negative, is the offset in the session's output up to
+// which the client already has it, as reported by Offset. The replay then
+// starts there if the scrollback still holds it; see Resumed.
+func (s *Service) Attach(ctx context.Context, workspaceID, dir, name, shell string, size pty.Size, after int64) (a *Attachment, created bool, err error) {
+	if name != "" && !nameRE.MatchString(name) {
+		return nil, false, ErrInvalidName
+	}
+	if shell == "" {
+		shell = s.cfg.DefaultShell
+	}
+	argv, ok := s.cfg.Shells[shell]
+	if !ok || len(argv) == 0 {
+		return nil, false, fmt.Errorf("%w: %q", ErrUnknownShell, shell)
+	}
+	if s.cfg.Bus != nil && name != "" && !s.runs(workspaceID, name) {
+		a, err := s.attachRemote(workspaceID, name, after)
+		if err == nil {
+			return a, false, nil
+		}
+		if !errors.Is(err, ErrNoSession) {
+			return nil, false, err
+		}
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, false, ErrClosed
+	}
+	if sess := s.sessions[workspaceID][name]; sess != nil {
+		s.mu.Unlock()
+		if a := sess.attach(after); a != nil {
+			return a, false, nil
+		}
+		// The session ended while we looked it up; start a fresh one.
+		s.mu.Lock()
+	}
+	ws := s.sessions[workspaceID]
+	if len(ws) >= s.cfg.MaxSessions {
+		s.mu.Unlock()
+		return nil, false, ErrTooManySessions
+	}
+	if name == "" {
+		name = s.nextNameLocked(ws)
+	}
+	// Reserve the name while the shell starts so concurrent attaches
+	// cannot start it twice.
+	sess := &Session{
+		svc:         s,
+		workspaceID: workspaceID,
+		name:        name,
+		shell:       shell,
+		created:     time.Now().UTC(),
+		started:     make(chan struct{}),
+		done:        make(chan struct{}),
+		exitCode:    -1,
+		size:        size,
+		scroll:      newScrollback(s.cfg.ScrollbackBytes),
+		clients:     make(map[*Attachment]struct{}),
+		remotes:     make(map[string]*Attachment),
+	}
+	if ws == nil {
+		ws = make(map[string]*Session)
+		s.sessions[workspaceID] = ws
+	}
+	ws[name] = sess
+	s.mu.Unlock()
+
+	err = s.start(ctx, sess, dir, argv)
+	if err != nil {
+		s.remove(sess)
+		sess.mu.Lock()
+		sess.ended, sess.closed = true, true
+		sess.mu.Unlock()
+	}
+	close(sess.started)
+	if err != nil {
+		return nil, false, err
+	}
+	// Attach before any output is read so the first client sees it all.
+	a = sess.attach(-1)
+	go sess.pump()
+	if s.cfg.Bus != nil {
+		if err := s.serve(sess); err != nil {
+			slog.Error("relay terminal", "workspace", workspaceID, "session", name, "err", err)
+		}
+	}
+	return a, true, nil
+}
+
+// runs reports whether the named session runs here.
+func (s *Service) runs(workspaceID, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[workspaceID][name] != nil
+}
+
+// nextNameLocked generates a session name. Under a bus the names are
+// random, since other servers pick theirs too.
+func (s *Service) nextNameLocked(ws map[string]*Session) string {
+	for {
+		s.seq++
+		name := "term-" + strconv.Itoa(s.seq)
+		if s.cfg.Bus != nil {
+			name = "term-" + randomHex(4)
+		}
+		if _, taken := ws[name]; !taken {
+			return name
+		}
+	}
+}
+
+func (s *Service) start(ctx context.Context, sess *Session, dir string, argv []string) error {
+	cmd, err := s.launcher.Command(ctx, sess.workspaceID, dir, argv, s.cfg.Env)
+	if err != nil {
+		return err
+	}
+	master, err := pty.Start(cmd, sess.size)
+	if err != nil {
+		return fmt.Errorf("terminal: start shell: %w", err)
+	}
+	sess.master, sess.cmd = master, cmd
+	s.started.Inc()
+	go func() {
+		err := cmd.Wait()
+		code := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+		sess.mu.Lock()
+		sess.exitCode = code
+		sess.mu.Unlock()
+		close(sess.done)
+	}()
+	return nil
+}
+
+// List returns the sessions of a workspace, oldest first, including
+// those running on other servers under a bus.
+func (s *Service) List(workspaceID string) []Info {
+	out := s.localList(workspaceID)
+	if s.cfg.Bus != nil {
+		out = append(out, s.listRemote(workspaceID)...)
+		sortInfo(out)
+	}
+	return out
+}
+
+// localList returns the sessions running here, oldest first.
+func (s *Service) localList(workspaceID string) []Info {
+	s.mu.Lock()
+	sessions := make([]*Session, 0, len(s.sessions[workspaceID]))
+	for _, sess := range s.sessions[workspaceID] {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	out := make([]Info, 0, len(sessions))
+	for _, sess := range sessions {
+		out = append(out, sess.Info())
+	}
+	sortInfo(out)
+	return out
+}
+
+// Kill terminates the named session.
+func (s *Service) Kill(workspaceID, name string) error {
+	s.mu.Lock()
+	sess := s.sessions[workspaceID][name]
+	s.mu.Unlock()
+	if sess == nil && s.cfg.Bus != nil {
+		return s.killRemote(workspaceID, name)
+	}
+	if sess == nil {
+		return ErrNoSession
+	}
+	<-sess.started
+	s.remove(sess)
+	return sess.Close()
+}
+
+// Close kills every session and refuses new ones.
+func (s *Service) Close() {
+	s.mu.Lock()
+	s.closed = true
+	var all []*Session
+	for _, ws := range s.sessions {
+		for _, sess := range ws {
+			all = append(all, sess)
+		}
+	}
+	s.sessions = make(map[string]map[string]*Session)
+	s.mu.Unlock()
+	for _, sess := range all {
+		<-sess.started
+		sess.Close()
+	}
+}
+
+func (s *Service) remove(sess *Session) {
+	s.mu.Lock()
+	ws := s.sessions[sess.workspaceID]
+	if ws[sess.name] != sess {
+		s.mu.Unlock()
+		return
+	}
+	delete(ws, sess.name)
+	if len(ws) == 0 {
+		delete(s.sessions, sess.workspaceID)
+	}
+	s.mu.Unlock()
+	sess.mu.Lock()
+	unsubscribe := sess.unsubscribe
+	sess.unsubscribe = nil
+	sess.mu.Unlock()
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+}
+
+// pump reads the PTY for the life of the session, recording output in the
+// scrollback and fanning it out to attached clients.
+func (s *Session) pump() {
+	var split utf8x.Splitter
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.master.Read(buf)
+		if n > 0 {
+			if out := split.Push(buf[:n]); len(out) > 0 {
+				s.broadcast(out)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if out := split.Flush(); len(out) > 0 {
+		s.broadcast(out)
+	}
+	// The PTY reports EOF slightly before the shell has been reaped.
+	select {
+	case <-s.done:
+	case <-time.After(2 * time.Second):
+	}
+	s.svc.remove(s)
+	s.Close()
+
+	s.mu.Lock()
+	s.ended = true
+	for a := range s.clients {
+		close(a.c)
+		delete(s.clients, a)
+	}
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+	s.mu.Unlock()
+}
+
+func (s *Session) broadcast(p []byte) {
+	if r := s.svc.cfg.Recorder; r != nil {
+		r.Terminal(s.workspaceID, s.name, false, p)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scroll.Write(p)
+	for a := range s.clients {
+		select {
+		case a.c <- p:
+		default:
+			close(a.c)
+			delete(s.clients, a)
+		}
+	}
+	s.armIdleLocked()
+}
+
+// attach connects a client that has the output up to after, or none of
+// it when after is negative.
+func (s *Session) attach(after int64) *Attachment {
+	<-s.started
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return nil
+	}
+	a := &Attachment{sess: s, offset: s.scroll.Offset(), c: make(chan []byte, clientBuffer)}
+	if after >= 0 {
+		a.replay, a.resumed = s.scroll.Since(after)
+	} else {
+		a.replay = s.scroll.Bytes()
+	}
+	s.clients[a] = struct{}{}
+	if s.idle != nil {
+		s.idle.Stop()
+		s.idle = nil
+	}
+	return a
+}
+
+// armIdleLocked starts the detached timer once the last client is gone.
+func (s *Session) armIdleLocked() {
+	if len(s.clients) > 0 || s.idle != nil || s.ended {
+		return
+	}
+	s.idle = time.AfterFunc(s.svc.cfg.DetachedTimeout, func() {
+		s.svc.remove(s)
+		s.Close()
+	})
+}
+
+// Name returns the session's name.
+func (s *Session) Name() string { return s.name }
+
+// Info returns a snapshot of the session's state.
+func (s *Session) Info() Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Info{
+		Name:      s.name,
+		Shell:     s.shell,
+		CreatedAt: s.created,
+		Clients:   len(s.clients),
+		Cols:      s.size.Cols,
+		Rows:      s.size.Rows,
+	}
+}
+
+// Write sends keyboard input to the terminal.
+func (s *Session) Write(p []byte) (int, error) {
+	if r := s.svc.cfg.Recorder; r != nil {
+		r.Terminal(s.workspaceID, s.name, true, p)
+	}
+	return s.master.Write(p)
+}
+
+// Resize changes the terminal window size.
+func (s *Session) Resize(size pty.Size) error {
+	if size.Cols == 0 || size.Rows == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	s.size = size
+	s.mu.Unlock()
+	return pty.Resize(s.master, size)
+}
+
+// Done is closed when the shell process exits.
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+// ExitCode returns the shell's exit status, or -1 while it is running.
+func (s *Session) ExitCode() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitCode
+}
+
+// Close hangs up the terminal and kills the shell if it is still running.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	err := s.master.Close()
+	select {
+	case <-s.done:
+	default:
+		if s.cmd.Process != nil {
+			s.cmd.Process.Kill()
+		}
+	}
+	return err
+}
+
+// Session returns the attached session, or nil if it runs on another
+// server.
+func (a *Attachment) Session() *Session { return a.sess }
+
+// Name returns the session's name.
+func (a *Attachment) Name() string {
+	if a.remote != nil {
+		return a.remote.name
+	}
+	return a.sess.name
+}
+
+// Write sends keyboard input to the terminal.
+func (a *Attachment) Write(p []byte) (int, error) {
+	if r := a.remote; r != nil {
+		_, err := r.svc.publish(r.channel, busMessage{Type: "input", Data: p})
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return a.sess.Write(p)
+}
+
+// Resize changes the terminal window size.
+func (a *Attachment) Resize(size pty.Size) error {
+	if r := a.remote; r != nil {
+		if size.Cols == 0 || size.Rows == 0 {
+			return nil
+		}
+		_, err := r.svc.publish(r.channel, busMessage{Type: "resize", Cols: size.Cols, Rows: size.Rows})
+		return err
+	}
+	return a.sess.Resize(size)
+}
+
+// Done is closed when the shell process exits.
+func (a *Attachment) Done() <-chan struct{} {
+	if a.remote != nil {
+		return a.remote.done
+	}
+	return a.sess.done
+}
+
+// ExitCode returns the shell's exit status, or -1 while it is running.
+func (a *Attachment) ExitCode() int {
+	if r := a.remote; r != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.exitCode
+	}
+	return a.sess.ExitCode()
+}
+
+// Scrollback returns the output recorded before the client attached.
+func (a *Attachment) Scrollback() []byte { return a.replay }
+
+// Offset returns the offset in the session's output of the end of the
+// replay, where Output starts.
+func (a *Attachment) Offset() int64 { return a.offset }
+
+// Resumed reports whether the replay starts at the offset the client
+// asked to resume from. Otherwise it is the whole scrollback, and the
+// client lost output and should redraw the screen from it.
+func (a *Attachment) Resumed() bool { return a.resumed }
+
+// Output delivers live output. It is closed when the session ends or when
+// the client falls too far behind.
+func (a *Attachment) Output() <-chan []byte { return a.c }
+
+// Detach disconnects the client, leaving the session running.
+func (a *Attachment) Detach() {
+	if r := a.remote; r != nil {
+		r.cancel()
+		r.mu.Lock()
+		ended := r.ended
+		r.endLocked(a)
+		r.mu.Unlock()
+		if !ended {
+			r.svc.publish(r.channel, busMessage{Type: "detach", Reply: r.reply})
+		}
+		return
+	}
+	s := a.sess
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detachLocked(a)
+}
@@ -0,0 +1,114 @@
+package textnorm
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the text normalization API.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the text normalization routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/settings/text", h.settings)
+	mux.HandleFunc("PUT /api/workspaces/{id}/settings/text", h.setSettings)
+	mux.HandleFunc("POST /api/workspaces/{id}/sanitize", h.sanitize)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return id, true
+}
+
+func (h *Handler) settings(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	p, err := h.svc.Settings(id)
+	if err != nil {
+		slog.Error("read text settings", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not read settings")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, p)
+}
+
+// setSettings sets how the workspace's files are normalized when they are
+// saved from now on; files already saved are left as they are.
+func (h *Handler) setSettings(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var p Policy
+	if err := httpx.DecodeJSON(w, r, &p, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	p, err := h.svc.SetSettings(id, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, p)
+}
+
+type sanitizeRequest struct {
+	// Path is the file the text is pasted into, for the policy's
+	// exceptions such as Markdown's trailing spaces.
+	Path string `json:"path"`
+	Text string `json:"text"`
+}
+
+type sanitized struct {
+	Text   string  `json:"text"`
+	Report *Report `json:"report"`
+}
+
+// sanitize cleans up text an editor is about to paste, with the
+// workspace's policy: invisible characters are removed and line endings
+// and whitespace normalized.
+func (h *Handler) sanitize(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var req sanitizeRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	text, rep := h.svc.Sanitize(id, req.Path, req.Text)
+	httpx.JSON(w, http.StatusOK, sanitized{Text: text, Report: rep})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("write text settings", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not save settings")
+	}
+}
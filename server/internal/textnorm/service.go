@@ -0,0 +1,120 @@
+package textnorm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Config configures a Service.
+type Config struct {
+	// SettingsDir stores per-workspace policies; defaults to a directory
+	// under the OS temp dir.
+	SettingsDir string
+	// Default is the policy of workspaces without one of their own.
+	Default Policy
+}
+
+// ErrInvalid is returned for policies Normalize does not know.
+var ErrInvalid = errors.New("textnorm: invalid policy")
+
+// Service keeps the policies of workspaces and applies them.
+type Service struct {
+	cfg Config
+
+	mu sync.Mutex
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config) *Service {
+	if cfg.SettingsDir == "" {
+		cfg.SettingsDir = filepath.Join(os.TempDir(), "webide-textnorm")
+	}
+	cfg.Default.EOL, _ = ParseEOL(string(cfg.Default.EOL))
+	return &Service{cfg: cfg}
+}
+
+// Default returns the policy of workspaces without one of their own.
+func (s *Service) Default() Policy { return s.cfg.Default }
+
+// Settings returns the workspace's policy.
+func (s *Service) Settings(workspaceID string) (Policy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.settingsPath(workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return s.cfg.Default, nil
+	}
+	if err != nil {
+		return Policy{}, fmt.Errorf("textnorm: read settings: %w", err)
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Policy{}, fmt.Errorf("textnorm: read settings: %w", err)
+	}
+	return p, nil
+}
+
+// SetSettings stores the workspace's policy and returns it as stored.
+func (s *Service) SetSettings(workspaceID string, p Policy) (Policy, error) {
+	eol, err := ParseEOL(string(p.EOL))
+	if err != nil {
+		return Policy{}, err
+	}
+	p.EOL = eol
+	data, err := json.Marshal(p)
+	if err != nil {
+		return Policy{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.cfg.SettingsDir, 0o755); err != nil {
+		return Policy{}, fmt.Errorf("textnorm: write settings: %w", err)
+	}
+	path := s.settingsPath(workspaceID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return Policy{}, fmt.Errorf("textnorm: write settings: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return Policy{}, fmt.Errorf("textnorm: write settings: %w", err)
+	}
+	return p, nil
+}
+
+func (s *Service) settingsPath(workspaceID string) string {
+	return filepath.Join(s.cfg.SettingsDir, workspaceID+".json")
+}
+
+// policy returns the workspace's policy, or the default one when it cannot
+// be read.
+func (s *Service) policy(workspaceID string) Policy {
+	p, err := s.Settings(workspaceID)
+	if err != nil {
+		slog.Warn("textnorm: read settings", "workspace", workspaceID, "err", err)
+		return s.cfg.Default
+	}
+	return p
+}
+
+// Normalize applies the workspace's policy to data, the contents of the
+// file at name, before it is saved. Data that is not text is returned as
+// it is, with a nil Report.
+func (s *Service) Normalize(workspaceID, name string, data []byte) ([]byte, *Report) {
+	if !IsText(data) {
+		return data, nil
+	}
+	text, rep := s.policy(workspaceID).Normalize(name, string(data))
+	return []byte(text), rep
+}
+
+// Sanitize cleans up text pasted into the file at name in the workspace,
+// as Policy.Sanitize does with the workspace's policy.
+func (s *Service) Sanitize(workspaceID, name, text string) (string, *Report) {
+	return s.policy(workspaceID).Sanitize(name, text)
+}
@@ -0,0 +1,311 @@
+// Package textnorm normalizes the text saved to workspaces, so that
+// collaborators on different platforms do not rewrite each other's line
+// endings and whitespace, and finds the invisible characters that come
+// along with code pasted from web pages, chats and documents.
+//
+// A Policy says what saving does: convert line endings to LF or CRLF, or to
+// whichever of the two a text mostly uses; trim trailing whitespace; and
+// strip a UTF-8 byte order mark. Each workspace has its own policy, kept by
+// Service, the file API and collaborative documents apply it when they
+// save, and Sanitize cleans up pasted text before it is inserted.
+// Invisible characters are reported but only Sanitize removes them: a
+// zero-width space in a string may be wanted.
+package textnorm
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EOL names a line-ending policy.
+type EOL string
+
+const (
+	// EOLKeep leaves line endings as they are.
+	EOLKeep EOL = ""
+	EOLLF   EOL = "lf"
+	EOLCRLF EOL = "crlf"
+	// EOLAuto makes every line end as most of the text's lines do, LF on
+	// a tie, which mends files a paste left with both.
+	EOLAuto EOL = "auto"
+)
+
+// ParseEOL parses a line-ending policy: lf, crlf, auto, or keep or empty
+// to leave line endings alone.
+func ParseEOL(s string) (EOL, error) {
+	switch e := EOL(strings.ToLower(s)); e {
+	case EOLKeep, EOLLF, EOLCRLF, EOLAuto:
+		return e, nil
+	case "keep":
+		return EOLKeep, nil
+	}
+	return "", fmt.Errorf("%w: line endings must be lf, crlf, auto or keep, not %q", ErrInvalid, s)
+}
+
+// Policy says how text is normalized when it is saved.
+type Policy struct {
+	EOL EOL `json:"eol"`
+	// TrimTrailingWhitespace removes spaces and tabs at the ends of
+	// lines, except in Markdown, where two of them break the line.
+	TrimTrailingWhitespace bool `json:"trimTrailingWhitespace"`
+	// StripBOM removes a UTF-8 byte order mark from the start of a file.
+	StripBOM bool `json:"stripBom"`
+}
+
+// Validate reports whether p is a policy Normalize knows.
+func (p Policy) Validate() error {
+	_, err := ParseEOL(string(p.EOL))
+	return err
+}
+
+// Diagnostic is an invisible character found in text.
+type Diagnostic struct {
+	// Line and Column are 1-based; Column counts runes. Offset is in
+	// bytes from the start of the text.
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+	// Code is the character's code point, such as U+200B, and Name its
+	// name.
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// MaxDiagnostics bounds the invisible characters reported for one text.
+const MaxDiagnostics = 100
+
+// Report tells what normalizing a text did.
+type Report struct {
+	// EOL counts the line endings converted, and Trimmed the lines
+	// trailing whitespace was removed from.
+	EOL     int `json:"eol,omitempty"`
+	Trimmed int `json:"trimmed,omitempty"`
+	// BOM says a byte order mark was removed.
+	BOM bool `json:"bom,omitempty"`
+	// Removed counts the invisible characters Sanitize removed or
+	// replaced.
+	Removed int `json:"removed,omitempty"`
+	// Invisible are the first MaxDiagnostics invisible characters left in
+	// the text.
+	Invisible []Diagnostic `json:"invisible,omitempty"`
+}
+
+// Empty reports whether r has nothing to tell: the text was unchanged and
+// holds no invisible characters.
+func (r *Report) Empty() bool {
+	return r.EOL == 0 && r.Trimmed == 0 && !r.BOM && r.Removed == 0 && len(r.Invisible) == 0
+}
+
+const bom = "\uFEFF"
+
+// IsText reports whether data is text Normalize may rewrite: valid UTF-8
+// without NUL bytes.
+func IsText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// Normalize applies p to the text of the file at name, a slash-separated
+// path, and scans the result for invisible characters.
+func (p Policy) Normalize(name, text string) (string, *Report) {
+	rep := &Report{}
+	if strings.HasPrefix(text, bom) && p.StripBOM {
+		text = text[len(bom):]
+		rep.BOM = true
+	}
+	trim := p.TrimTrailingWhitespace && !markdown(name)
+	eol := p.EOL
+	if eol == EOLAuto {
+		eol = dominant(text)
+	}
+	if eol != EOLKeep || trim {
+		text = rewrite(text, eol, trim, rep)
+	}
+	rep.Invisible = Scan(text)
+	return text, rep
+}
+
+func markdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown", ".mdx":
+		return true
+	}
+	return false
+}
+
+// nextLine splits the first line off text, returning it without its line
+// ending, the ending ("\n", "\r\n", a lone "\r", or "" at the end of the
+// text) and the rest.
+func nextLine(text string) (line, end, rest string) {
+	i := strings.IndexAny(text, "\r\n")
+	if i < 0 {
+		return text, "", ""
+	}
+	n := 1
+	if text[i] == '\r' && i+1 < len(text) && text[i+1] == '\n' {
+		n = 2
+	}
+	return text[:i], text[i : i+n], text[i+n:]
+}
+
+// dominant returns the line ending most of the lines of text end with.
+func dominant(text string) EOL {
+	lf, crlf := 0, 0
+	for text != "" {
+		var end string
+		_, end, text = nextLine(text)
+		switch end {
+		case "\n":
+			lf++
+		case "\r\n":
+			crlf++
+		}
+	}
+	if crlf > lf {
+		return EOLCRLF
+	}
+	return EOLLF
+}
+
+func rewrite(text string, eol EOL, trim bool, rep *Report) string {
+	want := map[EOL]string{EOLLF: "\n", EOLCRLF: "\r\n"}[eol]
+	var b strings.Builder
+	b.Grow(len(text))
+	for text != "" {
+		var line, end string
+		line, end, text = nextLine(text)
+		if trim {
+			if t := strings.TrimRight(line, " \t"); len(t) < len(line) {
+				line = t
+				rep.Trimmed++
+			}
+		}
+		b.WriteString(line)
+		if end != "" && want != "" && end != want {
+			end = want
+			rep.EOL++
+		}
+		b.WriteString(end)
+	}
+	return b.String()
+}
+
+// Sanitize cleans up pasted text: it replaces the unusual spaces in it with
+// plain ones and the Unicode line and paragraph separators with newlines,
+// removes the other invisible characters and byte order marks, and then
+// normalizes it as Normalize does.
+func (p Policy) Sanitize(name, text string) (string, *Report) {
+	text = strings.TrimPrefix(text, bom)
+	var b strings.Builder
+	b.Grow(len(text))
+	removed := 0
+	prev := rune(-1)
+	for _, r := range text {
+		_, ok := invisibleName(prev, r)
+		prev = r
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		removed++
+		b.WriteString(replacement(r))
+	}
+	out, rep := p.Normalize(name, b.String())
+	rep.Removed = removed
+	return out, rep
+}
+
+// Scan finds the first MaxDiagnostics invisible characters in text. A byte
+// order mark at its start is not one.
+func Scan(text string) []Diagnostic {
+	var out []Diagnostic
+	line, col := 1, 0
+	prev := rune(-1)
+	for i, r := range text {
+		col++
+		if i == 0 && r == '\uFEFF' {
+			prev = r
+			continue
+		}
+		if name, ok := invisibleName(prev, r); ok {
+			out = append(out, Diagnostic{Line: line, Column: col, Offset: i, Code: fmt.Sprintf("U+%04X", r), Name: name})
+			if len(out) == MaxDiagnostics {
+				break
+			}
+		}
+		if r == '\n' {
+			line, col = line+1, 0
+		}
+		prev = r
+	}
+	return out
+}
+
+// replacement is what Sanitize puts in place of the invisible character r.
+func replacement(r rune) string {
+	switch {
+	case r == '\u2028', r == '\u2029':
+		return "\n"
+	case r == '\u00A0', r == '\u202F', r == '\u205F', r >= '\u2000' && r <= '\u200A':
+		return " "
+	}
+	return ""
+}
+
+// invisibleName names r if it is an invisible character. A zero width
+// joiner after a symbol is taken to join an emoji sequence and is not one.
+func invisibleName(prev, r rune) (string, bool) {
+	switch {
+	case r == '\t', r == '\n', r == '\r':
+		return "", false
+	case r < 0x20, r == 0x7f, r >= 0x80 && r < 0xa0:
+		return "control character", true
+	case r == '\u200D' && prev >= 0 && unicode.Is(unicode.So, prev):
+		return "", false
+	case r >= '\u2000' && r <= '\u200A':
+		return "unusual space", true
+	case r >= 0xE0000 && r <= 0xE007F:
+		return "tag character", true
+	}
+	name, ok := invisible[r]
+	return name, ok
+}
+
+var invisible = map[rune]string{
+	'\u00A0': "no-break space",
+	'\u00AD': "soft hyphen",
+	'\u034F': "combining grapheme joiner",
+	'\u061C': "arabic letter mark",
+	'\u115F': "hangul choseong filler",
+	'\u1160': "hangul jungseong filler",
+	'\u180E': "mongolian vowel separator",
+	'\u200B': "zero width space",
+	'\u200C': "zero width non-joiner",
+	'\u200D': "zero width joiner",
+	'\u200E': "left-to-right mark",
+	'\u200F': "right-to-left mark",
+	'\u2028': "line separator",
+	'\u2029': "paragraph separator",
+	'\u202A': "left-to-right embedding",
+	'\u202B': "right-to-left embedding",
+	'\u202C': "pop directional formatting",
+	'\u202D': "left-to-right override",
+	'\u202E': "right-to-left override",
+	'\u202F': "narrow no-break space",
+	'\u205F': "medium mathematical space",
+	'\u2060': "word joiner",
+	'\u2061': "function application",
+	'\u2062': "invisible times",
+	'\u2063': "invisible separator",
+	'\u2064': "invisible plus",
+	'\u2066': "left-to-right isolate",
+	'\u2067': "right-to-left isolate",
+	'\u2068': "first strong isolate",
+	'\u2069': "pop directional isolate",
+	'\u3164': "hangul filler",
+	'\uFEFF': "zero width no-break space",
+	'\uFFA0': "halfwidth hangul filler",
+}
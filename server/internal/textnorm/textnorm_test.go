@@ -0,0 +1,155 @@
+package textnorm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		file   string
+		in     string
+		want   string
+		report Report
+	}{
+		{"keep", Policy{}, "a.go", "a \r\nb\nc\r", "a \r\nb\nc\r", Report{}},
+		{"lf", Policy{EOL: EOLLF}, "a.go", "a\r\nb\nc\rd", "a\nb\nc\nd", Report{EOL: 2}},
+		{"crlf", Policy{EOL: EOLCRLF}, "a.go", "a\r\nb\nc\rd\n", "a\r\nb\r\nc\r\nd\r\n", Report{EOL: 3}},
+		{"auto, mostly crlf", Policy{EOL: EOLAuto}, "a.go", "a\r\nb\r\nc\n", "a\r\nb\r\nc\r\n", Report{EOL: 1}},
+		{"auto, mostly lf", Policy{EOL: EOLAuto}, "a.go", "a\nb\nc\r\n", "a\nb\nc\n", Report{EOL: 1}},
+		{"auto, tie", Policy{EOL: EOLAuto}, "a.go", "a\nb\r\n", "a\nb\n", Report{EOL: 1}},
+		{"no line endings", Policy{EOL: EOLCRLF}, "a.go", "abc", "abc", Report{}},
+		{"trim", Policy{TrimTrailingWhitespace: true}, "a.go", "a \t\r\nb\n  \nc ", "a\r\nb\n\nc", Report{Trimmed: 3}},
+		{"trim and lf", Policy{EOL: EOLLF, TrimTrailingWhitespace: true}, "a.go", "a  \r\nb\r\n", "a\nb\n", Report{EOL: 2, Trimmed: 1}},
+		{"trim keeps leading space", Policy{TrimTrailingWhitespace: true}, "a.go", "  a\n\tb", "  a\n\tb", Report{}},
+		{"markdown line breaks", Policy{TrimTrailingWhitespace: true}, "docs/README.md", "a  \nb", "a  \nb", Report{}},
+		{"markdown line endings", Policy{EOL: EOLLF, TrimTrailingWhitespace: true}, "a.MDX", "a  \r\nb", "a  \nb", Report{EOL: 1}},
+		{"strip bom", Policy{StripBOM: true}, "a.go", "\uFEFFa\n", "a\n", Report{BOM: true}},
+		{"keep bom", Policy{EOL: EOLLF}, "a.go", "\uFEFFa\r\n", "\uFEFFa\n", Report{EOL: 1}},
+		{"bom only at the start", Policy{StripBOM: true}, "a.go", "a\uFEFF", "a\uFEFF", Report{Invisible: []Diagnostic{
+			{Line: 1, Column: 2, Offset: 1, Code: "U+FEFF", Name: "zero width no-break space"},
+		}}},
+	}
+	for _, tt := range tests {
+		got, rep := tt.policy.Normalize(tt.file, tt.in)
+		if got != tt.want {
+			t.Errorf("%s: Normalize(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+		if !reflect.DeepEqual(*rep, tt.report) {
+			t.Errorf("%s: Normalize(%q) reports %+v, want %+v", tt.name, tt.in, *rep, tt.report)
+		}
+		if rep.Empty() != (got == tt.in && len(tt.report.Invisible) == 0) {
+			t.Errorf("%s: Report.Empty() = %v", tt.name, rep.Empty())
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Diagnostic
+	}{
+		{"plain\ttext\r\n", nil},
+		{"\uFEFFstart", nil},
+		{"a = \"\u200B\"\nb\u00A0= 1", []Diagnostic{
+			{Line: 1, Column: 6, Offset: 5, Code: "U+200B", Name: "zero width space"},
+			{Line: 2, Column: 2, Offset: 11, Code: "U+00A0", Name: "no-break space"},
+		}},
+		{"if admin\u202E {", []Diagnostic{{Line: 1, Column: 9, Offset: 8, Code: "U+202E", Name: "right-to-left override"}}},
+		{"x\x07\u2003\U000E0041", []Diagnostic{
+			{Line: 1, Column: 2, Offset: 1, Code: "U+0007", Name: "control character"},
+			{Line: 1, Column: 3, Offset: 2, Code: "U+2003", Name: "unusual space"},
+			{Line: 1, Column: 4, Offset: 5, Code: "U+E0041", Name: "tag character"},
+		}},
+		// A zero width joiner in an emoji sequence is wanted; one between
+		// letters is not.
+		{"\U0001F469\u200D\U0001F4BB", nil},
+		{"a\u200Db", []Diagnostic{{Line: 1, Column: 2, Offset: 1, Code: "U+200D", Name: "zero width joiner"}}},
+	}
+	for _, tt := range tests {
+		if got := Scan(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Scan(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+	if got := Scan(strings.Repeat("\u200B", MaxDiagnostics+5)); len(got) != MaxDiagnostics {
+		t.Errorf("Scan of %d invisible characters reported %d, want %d", MaxDiagnostics+5, len(got), MaxDiagnostics)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	p := Policy{EOL: EOLLF, TrimTrailingWhitespace: true}
+	got, rep := p.Sanitize("a.go", "\uFEFFx\u00A0:= 1\u200B \r\ny\u2028z")
+	if want := "x := 1\ny\nz"; got != want {
+		t.Errorf("Sanitize = %q, want %q", got, want)
+	}
+	if rep.Removed != 3 || rep.EOL != 1 || rep.Trimmed != 1 || len(rep.Invisible) != 0 {
+		t.Errorf("Sanitize reports %+v", *rep)
+	}
+}
+
+func TestParseEOL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want EOL
+		ok   bool
+	}{
+		{"", EOLKeep, true},
+		{"keep", EOLKeep, true},
+		{"LF", EOLLF, true},
+		{"crlf", EOLCRLF, true},
+		{"auto", EOLAuto, true},
+		{"cr", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseEOL(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseEOL(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalid) {
+			t.Errorf("ParseEOL(%q) = %v, want ErrInvalid", tt.in, err)
+		}
+	}
+}
+
+func TestIsText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"", true},
+		{"héllo\r\n", true},
+		{"a\x00b", false},
+		{"\xff\xfe", false},
+	}
+	for _, tt := range tests {
+		if got := IsText([]byte(tt.in)); got != tt.want {
+			t.Errorf("IsText(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestService(t *testing.T) {
+	s := NewService(Config{SettingsDir: t.TempDir(), Default: Policy{EOL: "LF"}})
+	if p, err := s.Settings("ws1"); err != nil || p != (Policy{EOL: EOLLF}) {
+		t.Errorf("Settings of a workspace without a policy = %+v, %v; want the default", p, err)
+	}
+	if _, err := s.SetSettings("ws1", Policy{EOL: "cr"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("SetSettings(cr) = %v, want ErrInvalid", err)
+	}
+	if _, err := s.SetSettings("ws1", Policy{EOL: "CRLF", StripBOM: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, rep := s.Normalize("ws1", "a.txt", []byte("\uFEFFa\nb\n")); string(data) != "a\r\nb\r\n" || rep.EOL != 2 || !rep.BOM {
+		t.Errorf("Normalize in ws1 = %q, %+v", data, rep)
+	}
+	if data, _ := s.Normalize("ws2", "a.txt", []byte("a\r\n")); string(data) != "a\n" {
+		t.Errorf("Normalize in ws2 = %q, want the default policy applied", data)
+	}
+	if data, rep := s.Normalize("ws1", "a.bin", []byte("a\x00\n")); string(data) != "a\x00\n" || rep != nil {
+		t.Errorf("Normalize of binary data = %q, %+v; want it as it is", data, rep)
+	}
+}
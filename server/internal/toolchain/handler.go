@@ -0,0 +1,84 @@
+package toolchain
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the toolchain API.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the toolchain routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/toolchains", h.list)
+	mux.HandleFunc("GET /api/workspaces/{id}/settings/toolchain", h.settings)
+	mux.HandleFunc("PUT /api/workspaces/{id}/settings/toolchain", h.setSettings)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	httpx.JSON(w, http.StatusOK, map[string]any{
+		"toolchains": h.svc.List(),
+		"default":    h.svc.Default(),
+	})
+}
+
+func (h *Handler) settings(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	st, err := h.svc.Settings(id)
+	if err != nil {
+		slog.Error("read toolchain settings", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not read settings")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, st)
+}
+
+// setSettings declares the workspace's Go version. An empty version
+// follows the server default. The workspace container switches images the
+// next time a command is started in it.
+func (h *Handler) setSettings(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	var st Settings
+	if err := httpx.DecodeJSON(w, r, &st, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err := h.svc.SetSettings(id, st)
+	switch {
+	case errors.Is(err, ErrUnknownVersion):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		slog.Error("write toolchain settings", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not save settings")
+		return
+	}
+	if st.GoVersion == "" {
+		st.GoVersion = h.svc.Default()
+	}
+	httpx.JSON(w, http.StatusOK, st)
+}
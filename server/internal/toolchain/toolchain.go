@@ -0,0 +1,220 @@
+// Package toolchain manages the Go versions offered to workspaces. Each
+// version maps to a sandbox image for one-off runs and a workspace image
+// for terminals and tools; a workspace declares the version it uses.
+package toolchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Toolchain is one available Go version.
+type Toolchain struct {
+	Version string `json:"version"`
+	// Image holds the toolchain used to build and run programs.
+	Image string `json:"image"`
+	// WorkspaceImage is the image of the long-lived workspace container.
+	WorkspaceImage string `json:"workspaceImage"`
+}
+
+// Config configures a Service.
+type Config struct {
+	// Versions lists the offered Go versions; defaults to 1.21, 1.22 and
+	// 1.23.
+	Versions []string
+	// Default is the version of workspaces that declare none; defaults to
+	// 1.22.
+	Default string
+	// Image and WorkspaceImage are image names in which "{version}" is
+	// replaced by the Go version. They default to the official
+	// golang:{version}-alpine and golang:{version} images.
+	Image          string
+	WorkspaceImage string
+	// SettingsDir stores each workspace's declared version; defaults to a
+	// directory under the OS temp dir.
+	SettingsDir string
+}
+
+// ErrUnknownVersion is returned for Go versions that are not offered.
+var ErrUnknownVersion = errors.New("toolchain: unknown Go version")
+
+var versionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+(\.[0-9]+)?$`)
+
+// Settings is a workspace's toolchain configuration.
+type Settings struct {
+	GoVersion string `json:"goVersion"`
+}
+
+// Service resolves Go versions and per-workspace declarations.
+type Service struct {
+	cfg        Config
+	toolchains []Toolchain
+
+	mu sync.Mutex
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config) (*Service, error) {
+	if len(cfg.Versions) == 0 {
+		cfg.Versions = []string{"1.21", "1.22", "1.23"}
+	}
+	if cfg.Default == "" {
+		cfg.Default = "1.22"
+	}
+	if cfg.Image == "" {
+		cfg.Image = "golang:{version}-alpine"
+	}
+	if cfg.WorkspaceImage == "" {
+		cfg.WorkspaceImage = "golang:{version}"
+	}
+	if cfg.SettingsDir == "" {
+		cfg.SettingsDir = filepath.Join(os.TempDir(), "webide-toolchain")
+	}
+
+	s := &Service{cfg: cfg}
+	seen := make(map[string]bool)
+	for _, v := range cfg.Versions {
+		if !versionPattern.MatchString(v) {
+			return nil, fmt.Errorf("toolchain: invalid Go version %q", v)
+		}
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		s.toolchains = append(s.toolchains, Toolchain{
+			Version:        v,
+			Image:          strings.ReplaceAll(cfg.Image, "{version}", v),
+			WorkspaceImage: strings.ReplaceAll(cfg.WorkspaceImage, "{version}", v),
+		})
+	}
+	if !seen[cfg.Default] {
+		return nil, fmt.Errorf("toolchain: default Go version %q is not offered", cfg.Default)
+	}
+	sort.Slice(s.toolchains, func(i, j int) bool {
+		return versionLess(s.toolchains[i].Version, s.toolchains[j].Version)
+	})
+	return s, nil
+}
+
+// versionLess orders dotted versions numerically.
+func versionLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x < y
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// List returns the offered toolchains, oldest first.
+func (s *Service) List() []Toolchain {
+	return append([]Toolchain(nil), s.toolchains...)
+}
+
+// Default returns the version used by workspaces that declare none.
+func (s *Service) Default() string { return s.cfg.Default }
+
+// Lookup returns the toolchain for version; "" selects the default.
+func (s *Service) Lookup(version string) (Toolchain, error) {
+	if version == "" {
+		version = s.cfg.Default
+	}
+	for _, t := range s.toolchains {
+		if t.Version == version {
+			return t, nil
+		}
+	}
+	return Toolchain{}, fmt.Errorf("%w: %q", ErrUnknownVersion, version)
+}
+
+// Resolve picks the toolchain for a run: version if given, otherwise the
+// version declared by workspaceID, otherwise the default. workspaceID may
+// be empty.
+func (s *Service) Resolve(workspaceID, version string) (Toolchain, error) {
+	if version == "" && workspaceID != "" {
+		if !workspace.ValidID(workspaceID) {
+			return Toolchain{}, fmt.Errorf("%w: %q", workspace.ErrInvalidID, workspaceID)
+		}
+		st, err := s.Settings(workspaceID)
+		if err != nil {
+			return Toolchain{}, err
+		}
+		version = st.GoVersion
+	}
+	return s.Lookup(version)
+}
+
+// WorkspaceImage returns the workspace container image for workspaceID,
+// falling back to the default version if its declaration cannot be read
+// or is no longer offered.
+func (s *Service) WorkspaceImage(workspaceID string) string {
+	t, err := s.Resolve(workspaceID, "")
+	if err != nil {
+		t, _ = s.Lookup("")
+	}
+	return t.WorkspaceImage
+}
+
+// Settings returns the workspace's toolchain configuration.
+func (s *Service) Settings(workspaceID string) (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.settingsPath(workspaceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return Settings{GoVersion: s.cfg.Default}, nil
+	}
+	if err != nil {
+		return Settings{}, fmt.Errorf("toolchain: read settings: %w", err)
+	}
+	var st Settings
+	if err := json.Unmarshal(data, &st); err != nil {
+		return Settings{}, fmt.Errorf("toolchain: read settings: %w", err)
+	}
+	if st.GoVersion == "" {
+		st.GoVersion = s.cfg.Default
+	}
+	return st, nil
+}
+
+// SetSettings stores the workspace's toolchain configuration.
+func (s *Service) SetSettings(workspaceID string, st Settings) error {
+	if _, err := s.Lookup(st.GoVersion); err != nil {
+		return err
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.cfg.SettingsDir, 0o755); err != nil {
+		return fmt.Errorf("toolchain: write settings: %w", err)
+	}
+	p := s.settingsPath(workspaceID)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("toolchain: write settings: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("toolchain: write settings: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) settingsPath(workspaceID string) string {
+	return filepath.Join(s.cfg.SettingsDir, workspaceID+".json")
+}
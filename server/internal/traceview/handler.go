@@ -0,0 +1,57 @@
+package traceview
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/runner"
+)
+
+// Profiles looks up stored profiles, which include recorded traces.
+type Profiles interface {
+	Profile(ctx context.Context, id string) (*runner.Profile, string, error)
+}
+
+// Handler serves recorded traces.
+type Handler struct {
+	m        *Manager
+	profiles Profiles
+}
+
+// NewHandler returns a Handler serving the traces in profiles through m.
+func NewHandler(m *Manager, profiles Profiles) *Handler {
+	return &Handler{m: m, profiles: profiles}
+}
+
+// Register mounts the trace routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/profiles/{id}/trace", h.trace)
+}
+
+func (h *Handler) trace(w http.ResponseWriter, r *http.Request) {
+	p, file, err := h.profiles.Profile(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, runner.ErrNoProfile):
+		httpx.Error(w, http.StatusNotFound, "trace not found")
+		return
+	case err != nil:
+		slog.Error("read trace", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not read trace")
+		return
+	case p.Kind != runner.ProfileTrace:
+		httpx.Error(w, http.StatusBadRequest, "profile is not an execution trace")
+		return
+	}
+	err = h.m.ServeJSON(w, r, p.ID, file)
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+	case errors.Is(err, ErrUnavailable):
+		slog.Error("start trace viewer", "trace", p.ID, "err", err)
+		httpx.Error(w, http.StatusServiceUnavailable, "trace viewer not available")
+	default:
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+	}
+}
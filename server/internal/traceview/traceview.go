@@ -0,0 +1,221 @@
+// Package traceview serves execution traces recorded with runtime/trace
+// through go tool trace. A viewer process is started for each trace on
+// first use, bound to loopback, and its trace event JSON is proxied to
+// clients; it is stopped after Config.IdleTimeout without requests.
+package traceview
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Config configures a Manager.
+type Config struct {
+	// Command runs the trace viewer; "-http=127.0.0.1:0" and the trace file
+	// are appended. Defaults to go tool trace, which must be at least as
+	// new as the Go version that recorded the trace.
+	Command []string
+	// IdleTimeout is how long a viewer is kept after its last request.
+	IdleTimeout time.Duration
+	// StartTimeout bounds how long the viewer may take to parse a trace.
+	StartTimeout time.Duration
+}
+
+var (
+	// ErrClosed is returned after the Manager has been closed.
+	ErrClosed = errors.New("traceview: manager closed")
+	// ErrUnavailable is returned when the viewer cannot be started.
+	ErrUnavailable = errors.New("traceview: trace viewer not available")
+)
+
+// listening matches the address go tool trace announces once the trace
+// is parsed.
+var listening = regexp.MustCompile(`listening on (http://127\.0\.0\.1:[0-9]+)`)
+
+// Manager tracks the running viewer of every trace.
+type Manager struct {
+	cfg Config
+
+	mu      sync.Mutex
+	viewers map[string]*viewer
+	closed  bool
+}
+
+// viewer is one go tool trace process. ready is closed once url or err
+// is set.
+type viewer struct {
+	ready chan struct{}
+	url   *url.URL
+	err   error
+	cmd   *exec.Cmd
+	proxy *httputil.ReverseProxy
+	idle  *time.Timer
+}
+
+// NewManager returns a Manager, filling unset Config fields with defaults.
+func NewManager(cfg Config) *Manager {
+	if len(cfg.Command) == 0 {
+		cfg.Command = []string{"go", "tool", "trace"}
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 5 * time.Minute
+	}
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = time.Minute
+	}
+	return &Manager{cfg: cfg, viewers: make(map[string]*viewer)}
+}
+
+// ServeJSON writes the trace of id, stored in file, in the Trace Event
+// format of go tool trace's /jsontrace endpoint, which Perfetto and the
+// Chrome trace viewer load. The request's query, such as start and end
+// for part of a large trace or goid for one goroutine, is passed on.
+func (m *Manager) ServeJSON(w http.ResponseWriter, r *http.Request, id, file string) error {
+	v, err := m.viewer(r.Context(), id, file)
+	if err != nil {
+		return err
+	}
+	out := r.Clone(r.Context())
+	out.URL.Path, out.URL.RawPath = "/jsontrace", ""
+	v.proxy.ServeHTTP(w, out)
+	return nil
+}
+
+// viewer returns the running viewer of id, starting it if needed, and
+// restarts its idle timer.
+func (m *Manager) viewer(ctx context.Context, id, file string) (*viewer, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	v, ok := m.viewers[id]
+	if !ok {
+		v = &viewer{ready: make(chan struct{})}
+		m.viewers[id] = v
+		go m.start(id, file, v)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-v.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if v.err != nil {
+		return nil, v.err
+	}
+	m.mu.Lock()
+	if v.idle != nil {
+		v.idle.Reset(m.cfg.IdleTimeout)
+	}
+	m.mu.Unlock()
+	return v, nil
+}
+
+// start runs the viewer process and waits for it to announce its address.
+func (m *Manager) start(id, file string, v *viewer) {
+	defer close(v.ready)
+	fail := func(err error) {
+		v.err = err
+		m.remove(id, v)
+	}
+
+	args := append(m.cfg.Command[1:len(m.cfg.Command):len(m.cfg.Command)], "-http=127.0.0.1:0", file)
+	cmd := exec.Command(m.cfg.Command[0], args...)
+	// go tool trace tries to open a browser; "true" stands in for one.
+	cmd.Env = append(os.Environ(), "BROWSER=true")
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		fail(fmt.Errorf("traceview: %w", err))
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		fail(fmt.Errorf("%w: %v", ErrUnavailable, err))
+		return
+	}
+	timer := time.AfterFunc(m.cfg.StartTimeout, func() { cmd.Process.Kill() })
+	addr, out := waitListening(stderr)
+	timer.Stop()
+	if addr == nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		fail(fmt.Errorf("%w: %s", ErrUnavailable, out))
+		return
+	}
+	go func() {
+		io.Copy(io.Discard, stderr)
+		cmd.Wait()
+		m.remove(id, v)
+	}()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v.cmd, v.url, v.proxy = cmd, addr, httputil.NewSingleHostReverseProxy(addr)
+	if m.closed {
+		cmd.Process.Kill()
+		v.err = ErrClosed
+		return
+	}
+	v.idle = time.AfterFunc(m.cfg.IdleTimeout, func() {
+		m.remove(id, v)
+		cmd.Process.Kill()
+	})
+}
+
+// waitListening reads the viewer's output until it announces its
+// address. On failure it returns nil and the last line read.
+func waitListening(r io.Reader) (*url.URL, string) {
+	sc := bufio.NewScanner(r)
+	var last string
+	for sc.Scan() {
+		last = sc.Text()
+		if m := listening.FindStringSubmatch(last); m != nil {
+			u, err := url.Parse(m[1])
+			if err == nil {
+				return u, ""
+			}
+		}
+	}
+	return nil, last
+}
+
+func (m *Manager) remove(id string, v *viewer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.viewers[id] == v {
+		delete(m.viewers, id)
+	}
+}
+
+// Running returns the number of live viewers.
+func (m *Manager) Running() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.viewers)
+}
+
+// Close stops every viewer.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for id, v := range m.viewers {
+		if v.cmd != nil {
+			v.idle.Stop()
+			v.cmd.Process.Kill()
+		}
+		delete(m.viewers, id)
+	}
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+)
+
+// Middleware starts a server span for each request, continuing the trace
+// of its traceparent header. It belongs inside the ServeMux's auth, so the
+// user is known, and directly around the ServeMux, whose route names the
+// span once it is matched. Without a Tracer it returns next.
+func Middleware(t *Tracer, next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent, _ := ParseTraceparent(r.Header.Get("traceparent"))
+		ctx, span := t.Root(r.Context(), r.Method, KindServer, parent)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+		span.Set("http.request.method", r.Method)
+		span.Set("url.path", r.URL.Path)
+		if u := auth.UserFrom(ctx); u != nil {
+			span.Set("user.id", u.ID)
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(sw, r)
+
+		// The ServeMux sets the pattern and path values on the request
+		// it was given.
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			route := r.Pattern
+			if _, path, ok := strings.Cut(route, " "); ok {
+				route = path
+			}
+			span.Set("http.route", route)
+		}
+		if id := r.PathValue("id"); id != "" && strings.Contains(r.Pattern, "/api/workspaces/{id}") {
+			span.Set("workspace.id", id)
+		}
+		if sw.status != 0 {
+			span.Set("http.response.status_code", sw.status)
+			if sw.status >= http.StatusInternalServerError {
+				span.Fail(httpError(sw.status))
+			}
+		}
+	})
+}
+
+type httpError int
+
+func (e httpError) Error() string { return http.StatusText(int(e)) }
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the connection, to flush
+// streamed responses and hijack WebSocket upgrades.
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// ParseTraceparent parses a W3C traceparent header, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(h string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields; later ones may add more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.Valid()
+}
@@ -0,0 +1,253 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits of the export buffer. Spans that end while it is full are
+// dropped rather than slowing down the requests they cover.
+const (
+	maxQueued = 4096
+	maxBatch  = 512
+)
+
+// record is an ended span.
+type record struct {
+	traceID          TraceID
+	spanID, parentID SpanID
+	name             string
+	kind             int
+	start, end       time.Time
+	attrs            []attribute
+	failed           bool
+	message          string
+}
+
+// exporter posts ended spans to the collector in batches, in the JSON
+// encoding of OTLP/HTTP.
+type exporter struct {
+	cfg  Config
+	url  string
+	wake chan struct{}
+	done chan struct{}
+
+	mu      sync.Mutex
+	queue   []record
+	dropped int
+	closed  bool
+}
+
+func newExporter(cfg Config) *exporter {
+	e := &exporter{
+		cfg:  cfg,
+		url:  strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces",
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+func (e *exporter) add(rec record) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || len(e.queue) >= maxQueued {
+		e.dropped++
+		return
+	}
+	e.queue = append(e.queue, rec)
+	if len(e.queue) >= maxBatch {
+		select {
+		case e.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *exporter) loop() {
+	defer close(e.done)
+	tick := time.NewTicker(e.cfg.Interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-e.wake:
+		}
+		if !e.flush() {
+			return
+		}
+	}
+}
+
+// flush exports the queued spans, and reports whether the exporter is
+// still open.
+func (e *exporter) flush() bool {
+	for {
+		e.mu.Lock()
+		batch := e.queue[:min(len(e.queue), maxBatch)]
+		e.queue = e.queue[len(batch):]
+		dropped, closed := e.dropped, e.closed
+		e.dropped = 0
+		e.mu.Unlock()
+		if dropped > 0 {
+			slog.Warn("tracing: export queue full; spans dropped", "spans", dropped)
+		}
+		if len(batch) == 0 {
+			return !closed
+		}
+		if err := e.export(batch); err != nil {
+			slog.Warn("tracing: export spans", "spans", len(batch), "err", err)
+		}
+	}
+}
+
+func (e *exporter) close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	e.mu.Unlock()
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+	<-e.done
+}
+
+func (e *exporter) export(batch []record) error {
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// The OTLP JSON encoding: IDs are hex, and 64-bit integers strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"` // 2 is an error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    string   `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *exporter) request(batch []record) otlpRequest {
+	spans := make([]otlpSpan, len(batch))
+	for i, r := range batch {
+		s := otlpSpan{
+			TraceID:           r.traceID.String(),
+			SpanID:            r.spanID.String(),
+			Name:              r.name,
+			Kind:              r.kind,
+			StartTimeUnixNano: strconv.FormatInt(r.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(r.end.UnixNano(), 10),
+		}
+		if r.parentID != (SpanID{}) {
+			s.ParentSpanID = r.parentID.String()
+		}
+		for _, a := range r.attrs {
+			s.Attributes = append(s.Attributes, keyValue(a.key, a.value))
+		}
+		if r.failed {
+			s.Status = otlpStatus{Code: 2, Message: r.message}
+		}
+		spans[i] = s
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", e.cfg.Service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "webide-server"}, Spans: spans}},
+	}}}
+}
+
+func keyValue(key string, value any) otlpKeyValue {
+	var v otlpValue
+	switch x := value.(type) {
+	case string:
+		v.StringValue = &x
+	case bool:
+		v.BoolValue = &x
+	case int:
+		v.IntValue = strconv.Itoa(x)
+	case int64:
+		v.IntValue = strconv.FormatInt(x, 10)
+	case float64:
+		v.DoubleValue = &x
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
@@ -0,0 +1,261 @@
+// Package tracing records spans of the work done for a request and exports
+// them to an OpenTelemetry collector over OTLP, so that a slow run can be
+// followed from the HTTP request through the queue and the sandbox.
+//
+// Middleware starts a span for each request, continuing the trace of a
+// W3C traceparent header, and puts it in the request's context. Code
+// further down starts child spans with Start. Without a span in the
+// context Start returns a nil *Span, and the methods of a nil *Span do
+// nothing, so subsystems need not check whether tracing is on.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Kinds of span, as numbered by OTLP.
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Config configures a Tracer.
+type Config struct {
+	// Endpoint is the base URL of the collector's OTLP/HTTP receiver, such
+	// as "http://localhost:4318". Spans are posted to /v1/traces below it.
+	Endpoint string
+	// Headers are sent with every export, such as an API key.
+	Headers map[string]string
+	// Service is the service.name of the spans; defaults to
+	// "webide-server".
+	Service string
+	// SampleRatio is the share of traces started here that are recorded,
+	// from 0 to 1; defaults to 1. Traces continued from a caller follow
+	// the caller's decision.
+	SampleRatio float64
+	// Interval is how often ended spans are exported; defaults to 5
+	// seconds.
+	Interval time.Duration
+	// Client sends the exports; defaults to one with a 10 second timeout.
+	Client *http.Client
+}
+
+// Tracer starts root spans and exports the spans that have ended.
+type Tracer struct {
+	cfg Config
+	exp *exporter
+}
+
+// New returns a Tracer exporting to cfg.Endpoint, filling unset Config
+// fields with defaults. Close flushes the spans not yet exported.
+func New(cfg Config) *Tracer {
+	if cfg.Service == "" {
+		cfg.Service = "webide-server"
+	}
+	if cfg.SampleRatio <= 0 || cfg.SampleRatio > 1 {
+		cfg.SampleRatio = 1
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Tracer{cfg: cfg, exp: newExporter(cfg)}
+}
+
+// Close exports the spans that have ended and stops the exporter.
+func (t *Tracer) Close() {
+	if t != nil {
+		t.exp.close()
+	}
+}
+
+// TraceID identifies a trace; SpanID a span within it.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// Root starts a span of kind, continuing the trace of parent if it is
+// valid and starting a new one otherwise. A trace the parent did not
+// sample, or that falls outside Config.SampleRatio, is not recorded and
+// gets a nil span.
+func (t *Tracer) Root(ctx context.Context, name string, kind int, parent SpanContext) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	if parent.Valid() {
+		if !parent.Sampled {
+			return ctx, nil
+		}
+	} else {
+		parent = SpanContext{TraceID: newTraceID()}
+		// The trace ID is random, so its low bits sample uniformly.
+		if float64(binary.BigEndian.Uint64(parent.TraceID[8:])>>11)/(1<<53) >= t.cfg.SampleRatio {
+			return ctx, nil
+		}
+	}
+	s := &Span{t: t, traceID: parent.TraceID, parentID: parent.SpanID, spanID: newSpanID(), name: name, kind: kind, start: time.Now()}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+type spanKey struct{}
+
+// FromContext returns the span of ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start starts a child of the span of ctx, or returns nil if ctx has
+// none. The caller ends it.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	s := &Span{t: parent.t, traceID: parent.traceID, parentID: parent.spanID, spanID: newSpanID(), name: name, kind: KindInternal, start: time.Now()}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	t        *Tracer
+	traceID  TraceID
+	spanID   SpanID
+	parentID SpanID
+	kind     int
+	start    time.Time
+
+	mu      sync.Mutex
+	name    string
+	attrs   []attribute
+	failed  bool
+	message string
+	ended   bool
+}
+
+type attribute struct {
+	key   string
+	value any
+}
+
+// Set sets the attribute key to value, a string, bool, integer or float.
+// Other values are recorded as their fmt representation.
+func (s *Span) Set(key string, value any) {
+	if s == nil {
+		return
+	}
+	switch value.(type) {
+	case string, bool, int, int64, float64:
+	default:
+		value = fmt.Sprint(value)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, a := range s.attrs {
+		if a.key == key {
+			s.attrs[i].value = value
+			return
+		}
+	}
+	s.attrs = append(s.attrs, attribute{key, value})
+}
+
+// SetName renames the span, for names only known once it has started.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// Fail marks the span as failed with err, if err is not nil.
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed, s.message = true, err.Error()
+	s.mu.Unlock()
+}
+
+// End ends the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	rec := record{
+		traceID:  s.traceID,
+		spanID:   s.spanID,
+		parentID: s.parentID,
+		name:     s.name,
+		kind:     s.kind,
+		start:    s.start,
+		end:      end,
+		attrs:    slices.Clone(s.attrs),
+		failed:   s.failed,
+		message:  s.message,
+	}
+	s.mu.Unlock()
+	s.t.exp.add(rec)
+}
+
+// TraceID returns the ID of the span's trace, or "" for a nil span, to
+// log alongside the work it covers.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.traceID.String()
+}
+
+// SpanContext identifies a span across processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// Valid reports whether neither ID is zero.
+func (c SpanContext) Valid() bool {
+	return c.TraceID != TraceID{} && c.SpanID != SpanID{}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,153 @@
+package trash
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Handler serves the trash API for workspaces.
+type Handler struct {
+	store      *Store
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for store.
+func NewHandler(store *Store, wm Workspaces) *Handler {
+	return &Handler{store: store, workspaces: wm}
+}
+
+// Register mounts the trash routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/trash", h.list)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/trash", h.empty)
+	mux.HandleFunc("GET /api/workspaces/{id}/trash/{item}", h.get)
+	mux.HandleFunc("POST /api/workspaces/{id}/trash/{item}/restore", h.restore)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/trash/{item}", h.purge)
+}
+
+func (h *Handler) open(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := h.workspaces.Open(r.PathValue("id")); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return false
+	}
+	return true
+}
+
+type listing struct {
+	Items    []*Item `json:"items"`
+	Bytes    int64   `json:"bytes"`
+	MaxBytes int64   `json:"maxBytes"`
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	items, total, err := h.store.List(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, listing{Items: items, Bytes: total, MaxBytes: h.store.MaxBytes()})
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	it, err := h.store.Get(r.PathValue("id"), r.PathValue("item"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, it)
+}
+
+type restoreRequest struct {
+	// Path restores to somewhere other than where the item was deleted.
+	Path      string `json:"path"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// restore moves an item back into the workspace. The body may be empty.
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	var req restoreRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	e, err := h.store.Restore(r.Context(), r.PathValue("id"), r.PathValue("item"), req.Path, req.Overwrite)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, e)
+}
+
+func (h *Handler) purge(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	id, itemID := r.PathValue("id"), r.PathValue("item")
+	it, err := h.store.Get(id, itemID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.store.Purge(id, itemID); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Action:    audit.ActionTrashPurge,
+		Workspace: id,
+		Target:    it.Path,
+		Details:   map[string]string{"item": itemID},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) empty(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	n, err := h.store.Empty(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if n > 0 {
+		audit.Record(r.Context(), audit.Entry{
+			Action:    audit.ActionTrashPurge,
+			Workspace: r.PathValue("id"),
+			Details:   map[string]string{"items": strconv.Itoa(n)},
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "trash item not found")
+	case errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrRoot):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, files.ErrExists), errors.Is(err, fs.ErrExist), errors.Is(err, files.ErrNotDir):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("trash", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "trash request failed")
+	}
+}
@@ -0,0 +1,679 @@
+// Package trash keeps deleted workspace files for a while, so that an
+// accidental delete can be undone. Deletes through the file API move the
+// file or directory into the workspace's trash, outside the workspace;
+// restoring moves it back.
+//
+// Files removed some other way, such as rm in a terminal, are gone before
+// the server hears of it. While a workspace is watched for file changes,
+// the trash captures such deletes from the file's history instead: the
+// item holds the last version saved through the editor, which SavedAt
+// dates, and files that have no history are not kept.
+//
+// Items are kept for Config.Retention. Each workspace's trash is capped at
+// Config.MaxBytes, purging the oldest items to make room; what is larger
+// than the cap is deleted outright. The trash counts against its owner's
+// storage quota.
+package trash
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/history"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/watcher"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir holds the trash, one subdirectory per workspace; defaults to a
+	// directory under the OS temp dir.
+	Dir string
+	// Retention is how long items are kept; defaults to 7 days.
+	Retention time.Duration
+	// MaxBytes caps the trash of one workspace; defaults to 256 MiB.
+	MaxBytes int64
+	// MaxItems caps the items of one workspace; defaults to 1000.
+	MaxItems int
+}
+
+// ErrNotFound is returned for unknown or expired items.
+var ErrNotFound = errors.New("trash: no such item")
+
+// Source is how an item got into the trash.
+type Source string
+
+const (
+	// SourceDelete is a delete through the file API.
+	SourceDelete Source = "delete"
+	// SourceCapture is a delete the file watcher saw, kept from history.
+	SourceCapture Source = "capture"
+)
+
+// Item is a deleted file or directory.
+type Item struct {
+	ID   string          `json:"id"`
+	Path string          `json:"path"`
+	Type files.EntryType `json:"type"`
+	// Size is the bytes the item holds, and Files how many files.
+	Size      int64     `json:"size"`
+	Files     int       `json:"files"`
+	Source    Source    `json:"source"`
+	DeletedBy string    `json:"deletedBy,omitempty"`
+	DeletedAt time.Time `json:"deletedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// SavedAt is when the contents of a captured item were saved; the file
+	// may have changed after.
+	SavedAt *time.Time `json:"savedAt,omitempty"`
+}
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// History serves the saved versions of files, newest first.
+type History interface {
+	Versions(workspaceID, path string) ([]history.Version, error)
+	Read(workspaceID, path string, id int) (*history.Version, []byte, error)
+}
+
+// Hub reports the file changes of the workspaces being watched.
+type Hub interface {
+	Observe(fn func(id string, ev watcher.Event)) (cancel func())
+}
+
+// ownDeleteTTL is how long watcher events for a path the Store itself
+// removed are not captured.
+const ownDeleteTTL = 10 * time.Second
+
+// sweepInterval is how often expired items are purged.
+const sweepInterval = time.Hour
+
+// Store keeps the trash of all workspaces.
+type Store struct {
+	cfg        Config
+	workspaces Workspaces
+	now        func() time.Time
+
+	mu  sync.Mutex
+	own map[string]time.Time // workspace ID + "\x00" + path, removed by the Store
+
+	captures  chan capture
+	unobserve func()
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type capture struct {
+	workspaceID, path string
+}
+
+// New returns a Store, filling unset Config fields with defaults, and
+// starts purging expired items.
+func New(cfg Config, wm Workspaces) (*Store, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-trash")
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 24 * time.Hour
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 256 << 20
+	}
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = 1000
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("trash: create dir: %w", err)
+	}
+	s := &Store{
+		cfg:        cfg,
+		workspaces: wm,
+		now:        time.Now,
+		own:        make(map[string]time.Time),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go s.sweep()
+	return s, nil
+}
+
+// Watch captures the deletes hub reports from the versions in h. It must
+// be called at most once.
+func (s *Store) Watch(hub Hub, h History) {
+	s.captures = make(chan capture, 256)
+	s.unobserve = hub.Observe(func(id string, ev watcher.Event) {
+		if ev.Op != watcher.OpDelete || ev.IsDir {
+			return
+		}
+		select {
+		case s.captures <- capture{workspaceID: id, path: ev.Path}:
+		default:
+			slog.Warn("trash: dropping delete to capture", "workspace", id, "path", ev.Path)
+		}
+	})
+	go s.capture(h)
+}
+
+// Close stops purging and capturing.
+func (s *Store) Close() {
+	s.closeOnce.Do(func() {
+		if s.unobserve != nil {
+			s.unobserve()
+		}
+		close(s.stop)
+		<-s.done
+	})
+}
+
+func (s *Store) dir(workspaceID string) string { return filepath.Join(s.cfg.Dir, workspaceID) }
+
+func (s *Store) metaFile(workspaceID, id string) string {
+	return filepath.Join(s.dir(workspaceID), id+".json")
+}
+
+func (s *Store) dataPath(workspaceID, id string) string {
+	return filepath.Join(s.dir(workspaceID), id+".data")
+}
+
+func key(workspaceID, p string) string { return workspaceID + "\x00" + p }
+
+// Discard deletes p from the workspace of f. Unless permanent is set, or p
+// does not fit in the trash, it is moved to the trash and the item's ID is
+// returned; otherwise it is removed and the ID is "". Non-empty directories
+// require recursive, as for files.FS.Remove. Discard implements
+// files.Trash.
+func (s *Store) Discard(ctx context.Context, workspaceID string, f *files.FS, p string, recursive, permanent bool) (string, error) {
+	rel, err := files.Clean(p)
+	if err != nil {
+		return "", err
+	}
+	abs, err := f.ResolveLink(rel)
+	if err != nil {
+		return "", err
+	}
+	if abs == f.Root() {
+		return "", files.ErrRoot
+	}
+	fi, err := os.Lstat(abs)
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() && !recursive {
+		if des, err := os.ReadDir(abs); err != nil {
+			return "", err
+		} else if len(des) > 0 {
+			return "", files.ErrNotEmpty
+		}
+	}
+	s.markOwn(workspaceID, rel)
+	if permanent {
+		return "", os.RemoveAll(abs)
+	}
+	size, n, err := measure(abs)
+	if err != nil {
+		return "", err
+	}
+	if size > s.cfg.MaxBytes {
+		return "", os.RemoveAll(abs)
+	}
+
+	it := &Item{
+		ID:     newID(),
+		Path:   rel,
+		Type:   files.TypeFile,
+		Size:   size,
+		Files:  n,
+		Source: SourceDelete,
+	}
+	if fi.IsDir() {
+		it.Type = files.TypeDir
+	}
+	if u := auth.UserFrom(ctx); u != nil {
+		it.DeletedBy = u.ID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.makeRoomLocked(workspaceID, size); err != nil {
+		return "", err
+	}
+	if err := move(abs, s.dataPath(workspaceID, it.ID)); err != nil {
+		return "", err
+	}
+	if err := s.saveLocked(workspaceID, it); err != nil {
+		os.RemoveAll(s.dataPath(workspaceID, it.ID))
+		return "", err
+	}
+	return it.ID, nil
+}
+
+// List returns the workspace's items, most recently deleted first, and the
+// bytes they take.
+func (s *Store) List(workspaceID string) ([]*Item, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, err := s.listLocked(workspaceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	for _, it := range items {
+		total += it.Size
+	}
+	return items, total, nil
+}
+
+// MaxBytes is the cap of each workspace's trash.
+func (s *Store) MaxBytes() int64 { return s.cfg.MaxBytes }
+
+// Size returns the bytes the workspace's trash takes, for storage quotas.
+func (s *Store) Size(workspaceID string) (int64, error) {
+	_, total, err := s.List(workspaceID)
+	return total, err
+}
+
+// Get returns an item.
+func (s *Store) Get(workspaceID, id string) (*Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(workspaceID, id)
+}
+
+// Restore moves an item back into the workspace, to its original path or
+// to to when set. An existing file or directory there is refused with
+// files.ErrExists, or moved to the trash itself with overwrite.
+func (s *Store) Restore(ctx context.Context, workspaceID, id, to string, overwrite bool) (files.Entry, error) {
+	root, err := s.workspaces.Open(workspaceID)
+	if err != nil {
+		return files.Entry{}, err
+	}
+	f, err := files.New(root)
+	if err != nil {
+		return files.Entry{}, err
+	}
+	it, err := s.Get(workspaceID, id)
+	if err != nil {
+		return files.Entry{}, err
+	}
+	if to == "" {
+		to = it.Path
+	}
+	rel, err := files.Clean(to)
+	if err != nil {
+		return files.Entry{}, err
+	}
+	abs, err := f.ResolveLink(rel)
+	if err != nil {
+		return files.Entry{}, err
+	}
+	if abs == f.Root() {
+		return files.Entry{}, files.ErrRoot
+	}
+	if _, err := os.Lstat(abs); err == nil {
+		if !overwrite {
+			return files.Entry{}, fmt.Errorf("%w: %s", files.ErrExists, rel)
+		}
+		if _, err := s.Discard(ctx, workspaceID, f, rel, true, false); err != nil {
+			return files.Entry{}, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.loadLocked(workspaceID, id); err != nil {
+		return files.Entry{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return files.Entry{}, err
+	}
+	if err := move(s.dataPath(workspaceID, id), abs); err != nil {
+		return files.Entry{}, err
+	}
+	os.Remove(s.metaFile(workspaceID, id))
+	return f.Stat(rel)
+}
+
+// Purge deletes an item for good.
+func (s *Store) Purge(workspaceID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.loadLocked(workspaceID, id); err != nil {
+		return err
+	}
+	return s.removeLocked(workspaceID, id)
+}
+
+// Empty deletes every item of the workspace for good, returning how many.
+func (s *Store) Empty(workspaceID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, err := s.listLocked(workspaceID)
+	if err != nil {
+		return 0, err
+	}
+	for _, it := range items {
+		if err := s.removeLocked(workspaceID, it.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(items), nil
+}
+
+// markOwn notes that the Store removes workspace path p, so that the
+// watcher's events for it are not captured.
+func (s *Store) markOwn(workspaceID, p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for k, t := range s.own {
+		if now.Sub(t) > ownDeleteTTL {
+			delete(s.own, k)
+		}
+	}
+	s.own[key(workspaceID, p)] = now
+}
+
+// ownLocked reports whether p, or a directory holding it, was removed by
+// the Store lately. s.mu must be held.
+func (s *Store) ownLocked(workspaceID, p string) bool {
+	for q := p; ; q = path.Dir(q) {
+		if t, ok := s.own[key(workspaceID, q)]; ok && s.now().Sub(t) <= ownDeleteTTL {
+			return true
+		}
+		if !strings.Contains(q, "/") {
+			return false
+		}
+	}
+}
+
+// capture keeps the deletes the watcher reports until Close.
+func (s *Store) capture(h History) {
+	for {
+		select {
+		case <-s.stop:
+			return
+		case c := <-s.captures:
+			if err := s.captureOne(h, c.workspaceID, c.path); err != nil {
+				slog.Error("trash: capture delete", "workspace", c.workspaceID, "path", c.path, "err", err)
+			}
+		}
+	}
+}
+
+func (s *Store) captureOne(h History, workspaceID, p string) error {
+	s.mu.Lock()
+	own := s.ownLocked(workspaceID, p)
+	s.mu.Unlock()
+	if own {
+		return nil
+	}
+	if root, err := s.workspaces.Open(workspaceID); err == nil {
+		if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(p))); err == nil {
+			// Deleted and written again, as some editors save.
+			return nil
+		}
+	}
+	versions, err := h.Versions(workspaceID, p)
+	if err != nil || len(versions) == 0 {
+		return nil
+	}
+	v, data, err := h.Read(workspaceID, p, versions[0].ID)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > s.cfg.MaxBytes {
+		return nil
+	}
+	saved := v.Time
+	it := &Item{
+		ID:      newID(),
+		Path:    p,
+		Type:    files.TypeFile,
+		Size:    int64(len(data)),
+		Files:   1,
+		Source:  SourceCapture,
+		SavedAt: &saved,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.makeRoomLocked(workspaceID, it.Size); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.dataPath(workspaceID, it.ID), data, 0o644); err != nil {
+		return err
+	}
+	if err := s.saveLocked(workspaceID, it); err != nil {
+		os.Remove(s.dataPath(workspaceID, it.ID))
+		return err
+	}
+	return nil
+}
+
+// sweep purges expired items until Close.
+func (s *Store) sweep() {
+	defer close(s.done)
+	t := time.NewTicker(sweepInterval)
+	defer t.Stop()
+	for {
+		des, _ := os.ReadDir(s.cfg.Dir)
+		for _, de := range des {
+			if de.IsDir() {
+				s.List(de.Name())
+			}
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// makeRoomLocked purges the oldest items until size more bytes and one
+// more item fit, and creates the workspace's directory. s.mu must be held.
+func (s *Store) makeRoomLocked(workspaceID string, size int64) error {
+	items, err := s.listLocked(workspaceID)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, it := range items {
+		total += it.Size
+	}
+	for len(items) > 0 && (total+size > s.cfg.MaxBytes || len(items) >= s.cfg.MaxItems) {
+		oldest := items[len(items)-1]
+		if err := s.removeLocked(workspaceID, oldest.ID); err != nil {
+			return err
+		}
+		total -= oldest.Size
+		items = items[:len(items)-1]
+	}
+	return os.MkdirAll(s.dir(workspaceID), 0o700)
+}
+
+// listLocked returns the workspace's items, newest first, purging those
+// that expired. s.mu must be held.
+func (s *Store) listLocked(workspaceID string) ([]*Item, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir(workspaceID), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	items := []*Item{}
+	for _, name := range names {
+		it, err := s.loadLocked(workspaceID, strings.TrimSuffix(filepath.Base(name), ".json"))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	slices.SortFunc(items, func(a, b *Item) int { return b.DeletedAt.Compare(a.DeletedAt) })
+	return items, nil
+}
+
+// loadLocked reads an item, treating expired items as gone. s.mu must be
+// held.
+func (s *Store) loadLocked(workspaceID, id string) (*Item, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.metaFile(workspaceID, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var it Item
+	if err := json.Unmarshal(data, &it); err != nil {
+		return nil, fmt.Errorf("trash: decode %s: %w", id, err)
+	}
+	if s.now().After(it.ExpiresAt) {
+		if err := s.removeLocked(workspaceID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	return &it, nil
+}
+
+// saveLocked stamps a new item and writes it. s.mu must be held.
+func (s *Store) saveLocked(workspaceID string, it *Item) error {
+	it.DeletedAt = s.now().UTC()
+	it.ExpiresAt = it.DeletedAt.Add(s.cfg.Retention)
+	data, err := json.Marshal(it)
+	if err != nil {
+		return err
+	}
+	name := s.metaFile(workspaceID, it.ID)
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// removeLocked deletes an item's contents, then its metadata. s.mu must
+// be held.
+func (s *Store) removeLocked(workspaceID, id string) error {
+	if err := os.RemoveAll(s.dataPath(workspaceID, id)); err != nil {
+		return err
+	}
+	if err := os.Remove(s.metaFile(workspaceID, id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// measure returns the bytes and the number of files below abs, not
+// following symlinks.
+func measure(abs string) (size int64, n int, err error) {
+	err = filepath.WalkDir(abs, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += fi.Size()
+		n++
+		return nil
+	})
+	return size, n, err
+}
+
+// move renames src to dst, copying when they are on different file
+// systems.
+func move(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies the file, symlink or directory tree src to dst, keeping
+// permissions.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, fi.Mode().Perm())
+		case fi.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !fi.Mode().IsRegular():
+			return nil
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validID(id string) bool {
+	if len(id) != 24 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,72 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+type fakeWorkspaces map[string]string
+
+func (w fakeWorkspaces) Open(id string) (string, error) {
+	dir, ok := w[id]
+	if !ok {
+		return "", errors.New("no such workspace")
+	}
+	return dir, nil
+}
+
+func TestDiscardLink(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "dir", "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(Config{Dir: t.TempDir()}, fakeWorkspaces{"ws1": root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	f, err := files.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The link goes to the trash, and what it points to stays.
+	id, err := s.Discard(context.Background(), "ws1", f, "link", true, false)
+	if err != nil || id == "" {
+		t.Fatalf("Discard(link) = %q, %v", id, err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "link")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("link still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dir", "a.txt")); err != nil {
+		t.Errorf("Discard of a link removed its target: %v", err)
+	}
+
+	e, err := s.Restore(context.Background(), "ws1", id, "", false)
+	if err != nil || e.Type != files.TypeSymlink {
+		t.Fatalf("Restore = %+v, %v; want the link", e, err)
+	}
+	if target, err := os.Readlink(filepath.Join(root, "link")); err != nil || target != "dir" {
+		t.Errorf("restored link points to %q, %v", target, err)
+	}
+
+	// Permanently too.
+	if _, err := s.Discard(context.Background(), "ws1", f, "link", true, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dir", "a.txt")); err != nil {
+		t.Errorf("permanent Discard of a link removed its target: %v", err)
+	}
+}
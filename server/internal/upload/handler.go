@@ -0,0 +1,229 @@
+package upload
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Handler serves the upload API for workspaces.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler for svc. wsOpts configures the WebSocket
+// upgrade for progress and may be nil.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the upload routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/uploads", h.list)
+	mux.HandleFunc("POST /api/workspaces/{id}/uploads", h.create)
+	mux.HandleFunc("GET /api/workspaces/{id}/uploads/{upload}", h.get)
+	mux.HandleFunc("PATCH /api/workspaces/{id}/uploads/{upload}", h.patch)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/uploads/{upload}", h.delete)
+	mux.HandleFunc("GET /ws/workspaces/{id}/uploads/{upload}", h.progress)
+}
+
+func (h *Handler) open(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := h.workspaces.Open(r.PathValue("id")); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return false
+	}
+	return true
+}
+
+// offsetHeaders sets the tus-style headers telling where u stands.
+func offsetHeaders(w http.ResponseWriter, u *Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Size, 10))
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	ups, err := h.svc.List(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, ups)
+}
+
+type createRequest struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Overwrite bool   `json:"overwrite"`
+	SHA256    string `json:"sha256"`
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	var req createRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	u, err := h.svc.Create(r.Context(), r.PathValue("id"), req.Path, req.Size, req.Overwrite, req.SHA256)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	offsetHeaders(w, u)
+	if u.Entry == nil {
+		w.Header().Set("Location", "/api/workspaces/"+r.PathValue("id")+"/uploads/"+u.ID)
+	}
+	httpx.JSON(w, http.StatusCreated, u)
+}
+
+// get reports an upload, with Upload-Offset for clients resuming. HEAD
+// answers with the headers alone.
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	u, err := h.svc.Get(r.PathValue("id"), r.PathValue("upload"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	offsetHeaders(w, u)
+	httpx.JSON(w, http.StatusOK, u)
+}
+
+// patch appends the request body to the upload from the Upload-Offset
+// header. A 409 for a wrong offset carries the right one in Upload-Offset.
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		httpx.Error(w, http.StatusBadRequest, "Upload-Offset must be the byte offset the chunk starts at")
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, h.svc.MaxChunk())
+	u, err := h.svc.Append(r.Context(), r.PathValue("id"), r.PathValue("upload"), offset, body)
+	if u != nil {
+		offsetHeaders(w, u)
+	}
+	if err != nil {
+		if r.Context().Err() != nil {
+			// The connection dropped; the client resumes from the offset.
+			return
+		}
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, u)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	if !h.open(w, r) {
+		return
+	}
+	if err := h.svc.Delete(r.PathValue("id"), r.PathValue("upload")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// progressFrame is sent as an upload advances: "progress" while bytes
+// arrive, then "complete" with the file's entry or "cancelled".
+type progressFrame struct {
+	Type   string       `json:"type"`
+	Offset int64        `json:"offset"`
+	Size   int64        `json:"size"`
+	Entry  *files.Entry `json:"entry,omitempty"`
+}
+
+// progress streams an upload's progress frames, for the uploading tab and
+// anyone else in the workspace, until it completes or is cancelled. The
+// first frame is where the upload stands.
+func (h *Handler) progress(w http.ResponseWriter, r *http.Request) {
+	id, upID := r.PathValue("id"), r.PathValue("upload")
+	if !h.open(w, r) {
+		return
+	}
+	u, updates, cancel, err := h.svc.Subscribe(id, upID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer cancel()
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if conn.WriteJSON(progressFrame{Type: "progress", Offset: u.Offset, Size: u.Size}) != nil {
+		return
+	}
+	for {
+		select {
+		case <-gone:
+			return
+		case p := <-updates:
+			f := progressFrame{Type: "progress", Offset: p.Offset, Size: p.Size, Entry: p.Entry}
+			switch {
+			case p.Entry != nil:
+				f.Type = "complete"
+			case p.Cancelled:
+				f.Type = "cancelled"
+			}
+			if conn.WriteJSON(f) != nil || f.Type != "progress" {
+				return
+			}
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, "upload not found")
+	case errors.Is(err, ErrInvalid), errors.Is(err, files.ErrInvalidPath):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.As(err, &tooLarge):
+		httpx.Errorf(w, http.StatusRequestEntityTooLarge, "chunks are sent up to %d bytes", tooLarge.Limit)
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrOffset), errors.Is(err, ErrBusy), errors.Is(err, ErrExists),
+		errors.Is(err, ErrTooMany), errors.Is(err, files.ErrIsDir), errors.Is(err, files.ErrNotDir):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrChecksum):
+		httpx.Error(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+	default:
+		slog.Error("upload", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "upload failed")
+	}
+}
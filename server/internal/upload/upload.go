@@ -0,0 +1,584 @@
+// Package upload moves large files into workspaces in chunks, so that a
+// dataset or an archive of tens of megabytes survives a flaky connection.
+//
+// A client creates an upload with the file's path and size, then sends the
+// bytes in order with PATCH requests carrying the offset they start at, in
+// the manner of the tus protocol. Bytes are appended to a part file outside
+// the workspace as they arrive, so a chunk cut off midway keeps what got
+// through: the client asks for the offset and resumes from there. Once the
+// last byte is in, the file is moved into the workspace atomically and
+// file.saved is published as for a PUT.
+//
+// Uploads not finished within Config.Expiry of their last chunk are
+// removed.
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the uploads in progress, one subdirectory per workspace;
+	// defaults to a directory under the OS temp dir.
+	Dir string
+	// MaxSize caps the file of an upload; defaults to 1 GiB.
+	MaxSize int64
+	// MaxChunk caps the body of one PATCH; defaults to 32 MiB.
+	MaxChunk int64
+	// MaxUploads caps the uploads in progress per workspace; defaults to 10.
+	MaxUploads int
+	// Expiry is how long an upload is kept after its last chunk; defaults
+	// to 24 hours.
+	Expiry time.Duration
+}
+
+var (
+	// ErrNotFound is returned for unknown or expired uploads.
+	ErrNotFound = errors.New("upload: no such upload")
+	// ErrInvalid is returned for malformed requests.
+	ErrInvalid = errors.New("upload: invalid request")
+	// ErrTooLarge is returned for files over Config.MaxSize, and chunks
+	// running past the size of their upload.
+	ErrTooLarge = errors.New("upload: too large")
+	// ErrTooMany is returned when a workspace is at Config.MaxUploads.
+	ErrTooMany = errors.New("upload: too many uploads in progress")
+	// ErrOffset is returned for chunks that do not start where the upload
+	// stands.
+	ErrOffset = errors.New("upload: offset does not match the upload")
+	// ErrBusy is returned for chunks sent while another is being written.
+	ErrBusy = errors.New("upload: a chunk is already being written")
+	// ErrExists is returned when the file appeared meanwhile and the upload
+	// does not overwrite.
+	ErrExists = errors.New("upload: file already exists")
+	// ErrChecksum is returned when the bytes received do not match the
+	// upload's SHA-256. The upload starts over from offset 0.
+	ErrChecksum = errors.New("upload: checksum mismatch")
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Upload is a file being uploaded.
+type Upload struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Offset is how many bytes have been received.
+	Offset int64 `json:"offset"`
+	// Overwrite allows replacing an existing file.
+	Overwrite bool `json:"overwrite,omitempty"`
+	// SHA256, when set, is checked against the bytes received before the
+	// file is moved into place.
+	SHA256    string    `json:"sha256,omitempty"`
+	UserID    string    `json:"userId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Entry is the file written, once the upload is complete.
+	Entry *files.Entry `json:"entry,omitempty"`
+}
+
+// Progress is where an upload stands, as sent to its subscribers.
+type Progress struct {
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+	// Entry is set once the file is in place.
+	Entry *files.Entry `json:"entry,omitempty"`
+	// Cancelled reports that the upload was deleted or expired.
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// Service keeps the uploads of all workspaces.
+type Service struct {
+	cfg        Config
+	workspaces Workspaces
+	history    files.History
+	now        func() time.Time
+
+	mu   sync.Mutex
+	busy map[string]bool // uploads with a chunk being written
+	subs map[string]map[chan Progress]struct{}
+}
+
+// New returns a Service, filling unset Config fields with defaults. Files
+// completed are recorded in history unless it is nil. Uploads left over
+// from before past their expiry are removed.
+func New(cfg Config, wm Workspaces, history files.History) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-uploads")
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 1 << 30
+	}
+	if cfg.MaxChunk <= 0 {
+		cfg.MaxChunk = 32 << 20
+	}
+	if cfg.MaxUploads <= 0 {
+		cfg.MaxUploads = 10
+	}
+	if cfg.Expiry <= 0 {
+		cfg.Expiry = 24 * time.Hour
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("upload: create dir: %w", err)
+	}
+	s := &Service{
+		cfg:        cfg,
+		workspaces: wm,
+		history:    history,
+		now:        time.Now,
+		busy:       make(map[string]bool),
+		subs:       make(map[string]map[chan Progress]struct{}),
+	}
+	if dirs, err := os.ReadDir(cfg.Dir); err == nil {
+		for _, d := range dirs {
+			if d.IsDir() {
+				s.List(d.Name())
+			}
+		}
+	}
+	return s, nil
+}
+
+// MaxChunk is the largest chunk accepted at once.
+func (s *Service) MaxChunk() int64 { return s.cfg.MaxChunk }
+
+func (s *Service) dir(workspaceID string) string { return filepath.Join(s.cfg.Dir, workspaceID) }
+
+func (s *Service) metaFile(workspaceID, id string) string {
+	return filepath.Join(s.dir(workspaceID), id+".json")
+}
+
+func (s *Service) partFile(workspaceID, id string) string {
+	return filepath.Join(s.dir(workspaceID), id+".part")
+}
+
+func key(workspaceID, id string) string { return workspaceID + "\x00" + id }
+
+// Create starts an upload of size bytes to path. Uploads of empty files
+// are complete at once.
+func (s *Service) Create(ctx context.Context, workspaceID, path string, size int64, overwrite bool, sum string) (*Upload, error) {
+	p, err := files.Clean(path)
+	if err != nil || p == "" {
+		return nil, fmt.Errorf("%w: path %q", ErrInvalid, path)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("%w: size must not be negative", ErrInvalid)
+	}
+	if size > s.cfg.MaxSize {
+		return nil, fmt.Errorf("%w: files are uploaded up to %d bytes", ErrTooLarge, s.cfg.MaxSize)
+	}
+	sum = strings.ToLower(sum)
+	if b, err := hex.DecodeString(sum); err != nil || (sum != "" && len(b) != sha256.Size) {
+		return nil, fmt.Errorf("%w: sha256 must be 64 hex digits", ErrInvalid)
+	}
+	fsys, err := s.fs(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if e, err := fsys.Stat(p); err == nil && (!overwrite || e.Type == files.TypeDir) {
+		if e.Type == files.TypeDir {
+			return nil, fmt.Errorf("%w: %s", files.ErrIsDir, p)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrExists, p)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	u := &Upload{
+		ID:        newID(),
+		Path:      p,
+		Size:      size,
+		Overwrite: overwrite,
+		SHA256:    sum,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(s.cfg.Expiry),
+	}
+	if user := auth.UserFrom(ctx); user != nil {
+		u.UserID = user.ID
+	}
+	if err := s.add(workspaceID, u); err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		return u, nil
+	}
+	k := key(workspaceID, u.ID)
+	s.mu.Lock()
+	s.busy[k] = true
+	s.mu.Unlock()
+	err = s.complete(ctx, workspaceID, u)
+	s.mu.Lock()
+	delete(s.busy, k)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// add stores a new upload with an empty part file.
+func (s *Service) add(workspaceID string, u *Upload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ups, err := s.listLocked(workspaceID)
+	if err != nil {
+		return err
+	}
+	if len(ups) >= s.cfg.MaxUploads {
+		return fmt.Errorf("%w: %d uploads", ErrTooMany, len(ups))
+	}
+	if err := os.MkdirAll(s.dir(workspaceID), 0o700); err != nil {
+		return err
+	}
+	part, err := os.OpenFile(s.partFile(workspaceID, u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	part.Close()
+	if err := s.saveLocked(workspaceID, u); err != nil {
+		os.Remove(s.partFile(workspaceID, u.ID))
+		return err
+	}
+	return nil
+}
+
+// Get returns an upload in progress.
+func (s *Service) Get(workspaceID, id string) (*Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(workspaceID, id)
+}
+
+// List returns the workspace's uploads in progress, oldest first, and
+// removes those that expired.
+func (s *Service) List(workspaceID string) ([]*Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked(workspaceID)
+}
+
+// Delete cancels an upload and removes what was received.
+func (s *Service) Delete(workspaceID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.loadLocked(workspaceID, id); err != nil {
+		return err
+	}
+	if s.busy[key(workspaceID, id)] {
+		return ErrBusy
+	}
+	s.removeLocked(workspaceID, id, Progress{Cancelled: true})
+	return nil
+}
+
+// Append writes the bytes of r to the upload from offset, which must be
+// what the upload received so far. What is read before r fails is kept,
+// so the client resumes from the offset the upload reports. When the last
+// byte is in, the file is moved into place and the returned Upload has its
+// Entry.
+func (s *Service) Append(ctx context.Context, workspaceID, id string, offset int64, r io.Reader) (*Upload, error) {
+	k := key(workspaceID, id)
+	s.mu.Lock()
+	u, err := s.loadLocked(workspaceID, id)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if s.busy[k] {
+		s.mu.Unlock()
+		return u, ErrBusy
+	}
+	if offset != u.Offset {
+		s.mu.Unlock()
+		return u, fmt.Errorf("%w: it stands at %d", ErrOffset, u.Offset)
+	}
+	s.busy[k] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.busy, k)
+		s.mu.Unlock()
+	}()
+
+	part, err := os.OpenFile(s.partFile(workspaceID, id), os.O_WRONLY, 0)
+	if err != nil {
+		return u, err
+	}
+	// Drop anything past the offset, such as a write a crash cut short.
+	if err := part.Truncate(offset); err != nil {
+		part.Close()
+		return u, err
+	}
+	if _, err := part.Seek(offset, io.SeekStart); err != nil {
+		part.Close()
+		return u, err
+	}
+	pw := &progressWriter{w: part, s: s, workspaceID: workspaceID, id: id, offset: offset, size: u.Size}
+	n, copyErr := io.Copy(pw, io.LimitReader(r, u.Size-offset))
+	if copyErr == nil && u.Offset+n == u.Size {
+		var extra [1]byte
+		if m, _ := io.ReadFull(r, extra[:]); m > 0 {
+			// Refuse the chunk whole rather than finish the file from it.
+			copyErr = fmt.Errorf("%w: the chunk runs past the upload's %d bytes", ErrTooLarge, u.Size)
+			n = 0
+			if err := part.Truncate(offset); err != nil {
+				part.Close()
+				return u, err
+			}
+		}
+	}
+	syncErr := part.Sync()
+	if err := part.Close(); syncErr == nil {
+		syncErr = err
+	}
+	if syncErr != nil {
+		return u, syncErr
+	}
+
+	s.mu.Lock()
+	u.Offset += n
+	u.UpdatedAt = s.now().UTC()
+	u.ExpiresAt = u.UpdatedAt.Add(s.cfg.Expiry)
+	err = s.saveLocked(workspaceID, u)
+	s.mu.Unlock()
+	if err != nil {
+		return u, err
+	}
+	if copyErr != nil {
+		return u, copyErr
+	}
+	if u.Offset == u.Size {
+		if err := s.complete(ctx, workspaceID, u); err != nil {
+			return u, err
+		}
+	}
+	return u, nil
+}
+
+// complete moves a fully received upload into the workspace. The caller
+// marks it busy.
+func (s *Service) complete(ctx context.Context, workspaceID string, u *Upload) error {
+	part, err := os.Open(s.partFile(workspaceID, u.ID))
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+	if u.SHA256 != "" {
+		h := sha256.New()
+		if _, err := io.Copy(h, part); err != nil {
+			return err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != u.SHA256 {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			u.Offset = 0
+			os.Truncate(s.partFile(workspaceID, u.ID), 0)
+			if err := s.saveLocked(workspaceID, u); err != nil {
+				return err
+			}
+			s.notifyLocked(workspaceID, u.ID, Progress{Size: u.Size})
+			return ErrChecksum
+		}
+		if _, err := part.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	fsys, err := s.fs(workspaceID)
+	if err != nil {
+		return err
+	}
+	_, err = fsys.Stat(u.Path)
+	exists := err == nil
+	if exists && !u.Overwrite {
+		return fmt.Errorf("%w: %s", ErrExists, u.Path)
+	}
+	e, err := fsys.Write(u.Path, part)
+	if err != nil {
+		return err
+	}
+	if s.history != nil && s.history.Records(e.Size) {
+		if data, err := fsys.ReadFile(e.Path); err == nil {
+			s.history.Saved(ctx, workspaceID, e.Path, data)
+		}
+	}
+	events.Publish(ctx, events.Event{Type: events.FileSaved, Workspace: workspaceID, Data: map[string]any{
+		"path":    e.Path,
+		"size":    e.Size,
+		"created": !exists,
+		"upload":  u.ID,
+	}})
+	u.Entry = &e
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(workspaceID, u.ID, Progress{Offset: u.Size, Size: u.Size, Entry: &e})
+	return nil
+}
+
+// Subscribe returns a channel receiving the progress of an upload, and a
+// func that ends the subscription. Progress a slow subscriber has not read
+// yet is replaced by newer. The last value sent has an Entry or is
+// Cancelled.
+func (s *Service) Subscribe(workspaceID, id string) (*Upload, <-chan Progress, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := s.loadLocked(workspaceID, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	k := key(workspaceID, id)
+	c := make(chan Progress, 1)
+	if s.subs[k] == nil {
+		s.subs[k] = make(map[chan Progress]struct{})
+	}
+	s.subs[k][c] = struct{}{}
+	return u, c, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subs[k], c)
+		if len(s.subs[k]) == 0 {
+			delete(s.subs, k)
+		}
+	}, nil
+}
+
+// notifyLocked sends p to the upload's subscribers. s.mu must be held.
+func (s *Service) notifyLocked(workspaceID, id string, p Progress) {
+	for c := range s.subs[key(workspaceID, id)] {
+		select {
+		case <-c:
+		default:
+		}
+		c <- p
+	}
+}
+
+// progressWriter writes an upload's part file, telling subscribers how
+// far it got.
+type progressWriter struct {
+	w               io.Writer
+	s               *Service
+	workspaceID, id string
+	offset, size    int64
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	pw.s.mu.Lock()
+	pw.s.notifyLocked(pw.workspaceID, pw.id, Progress{Offset: pw.offset, Size: pw.size})
+	pw.s.mu.Unlock()
+	return n, err
+}
+
+func (s *Service) fs(workspaceID string) (*files.FS, error) {
+	root, err := s.workspaces.Open(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return files.New(root)
+}
+
+// loadLocked reads an upload's metadata, treating expired uploads as gone.
+// s.mu must be held.
+func (s *Service) loadLocked(workspaceID, id string) (*Upload, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.metaFile(workspaceID, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var u Upload
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, fmt.Errorf("upload: decode %s: %w", id, err)
+	}
+	if s.now().After(u.ExpiresAt) && !s.busy[key(workspaceID, id)] {
+		s.removeLocked(workspaceID, id, Progress{Cancelled: true})
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+// listLocked returns the workspace's uploads. s.mu must be held.
+func (s *Service) listLocked(workspaceID string) ([]*Upload, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir(workspaceID), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	ups := []*Upload{}
+	for _, name := range names {
+		u, err := s.loadLocked(workspaceID, strings.TrimSuffix(filepath.Base(name), ".json"))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ups = append(ups, u)
+	}
+	sort.Slice(ups, func(i, j int) bool { return ups[i].CreatedAt.Before(ups[j].CreatedAt) })
+	return ups, nil
+}
+
+// saveLocked writes an upload's metadata. s.mu must be held.
+func (s *Service) saveLocked(workspaceID string, u *Upload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	name := s.metaFile(workspaceID, u.ID)
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// removeLocked deletes an upload, sending last to its subscribers. s.mu
+// must be held.
+func (s *Service) removeLocked(workspaceID, id string, last Progress) {
+	os.Remove(s.metaFile(workspaceID, id))
+	os.Remove(s.partFile(workspaceID, id))
+	s.notifyLocked(workspaceID, id, last)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,361 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+type fakeWorkspaces map[string]string
+
+func (w fakeWorkspaces) Open(id string) (string, error) {
+	dir, ok := w[id]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return dir, nil
+}
+
+// testService returns a Service taking chunks of up to 8 bytes and files
+// of up to 32, and the root of its workspace ws1.
+func testService(t *testing.T) (*Service, string) {
+	t.Helper()
+	root := t.TempDir()
+	s, err := New(Config{Dir: t.TempDir(), MaxSize: 32, MaxChunk: 8, MaxUploads: 2}, fakeWorkspaces{"ws1": root}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, root
+}
+
+// cutReader returns the bytes of r and then fails, as a body does when the
+// connection drops.
+type cutReader struct{ r io.Reader }
+
+func (c *cutReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestResume(t *testing.T) {
+	s, root := testService(t)
+	ctx := context.Background()
+	u, err := s.Create(ctx, "ws1", "data/set.csv", 10, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A chunk cut off midway keeps what got through.
+	got, err := s.Append(ctx, "ws1", u.ID, 0, &cutReader{strings.NewReader("abcd")})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || got.Offset != 4 {
+		t.Fatalf("cut-off Append = %+v, %v; want offset 4", got, err)
+	}
+	if u, err := s.Get("ws1", u.ID); err != nil || u.Offset != 4 {
+		t.Fatalf("Get = %+v, %v; want offset 4", u, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "data/set.csv")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the file is in place before the upload is complete: %v", err)
+	}
+
+	// Resuming elsewhere than the offset is refused, and changes nothing.
+	for _, offset := range []int64{0, 2, 6} {
+		if got, err := s.Append(ctx, "ws1", u.ID, offset, strings.NewReader("xx")); !errors.Is(err, ErrOffset) || got.Offset != 4 {
+			t.Errorf("Append at %d = %+v, %v; want ErrOffset, offset 4", offset, got, err)
+		}
+	}
+
+	// Resuming from it finishes the file.
+	if got, err = s.Append(ctx, "ws1", u.ID, 4, strings.NewReader("ef")); err != nil || got.Offset != 6 || got.Entry != nil {
+		t.Fatalf("Append at 4 = %+v, %v", got, err)
+	}
+	got, err = s.Append(ctx, "ws1", u.ID, 6, strings.NewReader("ghij"))
+	if err != nil || got.Entry == nil || got.Entry.Path != "data/set.csv" || got.Entry.Size != 10 {
+		t.Fatalf("last Append = %+v, %v", got, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "data/set.csv")); err != nil || string(data) != "abcdefghij" {
+		t.Errorf("file = %q, %v", data, err)
+	}
+	if _, err := s.Get("ws1", u.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a complete upload = %v, want ErrNotFound", err)
+	}
+	if left, _ := os.ReadDir(s.dir("ws1")); len(left) != 0 {
+		t.Errorf("left behind %v", left)
+	}
+}
+
+func TestAppendPastSize(t *testing.T) {
+	s, root := testService(t)
+	ctx := context.Background()
+	u, err := s.Create(ctx, "ws1", "a.bin", 6, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Append(ctx, "ws1", u.ID, 0, strings.NewReader("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	// The whole chunk is refused, rather than the file finished from it.
+	got, err := s.Append(ctx, "ws1", u.ID, 4, strings.NewReader("efg"))
+	if !errors.Is(err, ErrTooLarge) || got.Offset != 4 {
+		t.Fatalf("Append past the size = %+v, %v; want ErrTooLarge, offset 4", got, err)
+	}
+	if got, err := s.Append(ctx, "ws1", u.ID, 4, strings.NewReader("ef")); err != nil || got.Entry == nil {
+		t.Fatalf("Append = %+v, %v", got, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.bin")); string(data) != "abcdef" {
+		t.Errorf("file = %q", data)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s, root := testService(t)
+	ctx := context.Background()
+	os.WriteFile(filepath.Join(root, "old.txt"), []byte("old"), 0o644)
+	os.Mkdir(filepath.Join(root, "dir"), 0o755)
+	tests := []struct {
+		path      string
+		size      int64
+		overwrite bool
+		sum       string
+		want      error
+	}{
+		{"big.bin", 33, false, "", ErrTooLarge},
+		{"neg.bin", -1, false, "", ErrInvalid},
+		{"../out.bin", 1, false, "", ErrInvalid},
+		{"", 1, false, "", ErrInvalid},
+		{"old.txt", 1, false, "", ErrExists},
+		{"dir", 1, true, "", files.ErrIsDir},
+		{"a.bin", 1, false, "abc", ErrInvalid},
+	}
+	for _, tt := range tests {
+		if _, err := s.Create(ctx, "ws1", tt.path, tt.size, tt.overwrite, tt.sum); !errors.Is(err, tt.want) {
+			t.Errorf("Create(%q, %d) = %v, want %v", tt.path, tt.size, err, tt.want)
+		}
+	}
+
+	// An empty file is complete at once, and takes no place.
+	u, err := s.Create(ctx, "ws1", "empty.txt", 0, false, "")
+	if err != nil || u.Entry == nil {
+		t.Fatalf("Create of an empty file = %+v, %v", u, err)
+	}
+	if _, err := s.Create(ctx, "ws1", "old.txt", 32, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, "ws1", "b.bin", 1, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, "ws1", "c.bin", 1, false, ""); !errors.Is(err, ErrTooMany) {
+		t.Errorf("Create past MaxUploads = %v, want ErrTooMany", err)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	s, root := testService(t)
+	ctx := context.Background()
+	sum := sha256.Sum256([]byte("hello"))
+	u, err := s.Create(ctx, "ws1", "h.txt", 5, false, strings.ToUpper(hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The wrong bytes start the upload over.
+	if got, err := s.Append(ctx, "ws1", u.ID, 0, strings.NewReader("jello")); !errors.Is(err, ErrChecksum) || got.Offset != 0 {
+		t.Fatalf("Append of the wrong bytes = %+v, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "h.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a file failing its checksum was moved into place: %v", err)
+	}
+	if got, err := s.Append(ctx, "ws1", u.ID, 0, strings.NewReader("hello")); err != nil || got.Entry == nil {
+		t.Fatalf("Append = %+v, %v", got, err)
+	}
+}
+
+func TestFileAppeared(t *testing.T) {
+	s, root := testService(t)
+	ctx := context.Background()
+	u, err := s.Create(ctx, "ws1", "a.txt", 3, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("mine"), 0o644)
+	if _, err := s.Append(ctx, "ws1", u.ID, 0, strings.NewReader("abc")); !errors.Is(err, ErrExists) {
+		t.Errorf("Append over a file made meanwhile = %v, want ErrExists", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(data) != "mine" {
+		t.Errorf("file = %q, want it kept", data)
+	}
+}
+
+func TestBusy(t *testing.T) {
+	s, _ := testService(t)
+	ctx := context.Background()
+	u, err := s.Create(ctx, "ws1", "a.txt", 4, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, pw := io.Pipe()
+	done := make(chan error)
+	go func() {
+		_, err := s.Append(ctx, "ws1", u.ID, 0, pr)
+		done <- err
+	}()
+	pw.Write([]byte("ab"))
+	if _, err := s.Append(ctx, "ws1", u.ID, 0, strings.NewReader("ab")); !errors.Is(err, ErrBusy) {
+		t.Errorf("Append during another = %v, want ErrBusy", err)
+	}
+	if err := s.Delete("ws1", u.ID); !errors.Is(err, ErrBusy) {
+		t.Errorf("Delete during an Append = %v, want ErrBusy", err)
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("ws1", u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("ws1", u.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s, _ := testService(t)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	u, err := s.Create(ctx, "ws1", "a.txt", 4, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, updates, cancel, err := s.Subscribe("ws1", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	// A chunk puts the expiry off.
+	now = now.Add(20 * time.Hour)
+	if _, err := s.Append(ctx, "ws1", u.ID, 0, strings.NewReader("ab")); err != nil {
+		t.Fatal(err)
+	}
+	if p := <-updates; p.Offset != 2 || p.Size != 4 {
+		t.Errorf("progress = %+v", p)
+	}
+	now = now.Add(20 * time.Hour)
+	if ups, _ := s.List("ws1"); len(ups) != 1 {
+		t.Fatalf("List = %+v, want the upload", ups)
+	}
+	now = now.Add(5 * time.Hour)
+	if ups, _ := s.List("ws1"); len(ups) != 0 {
+		t.Errorf("List after the expiry = %+v", ups)
+	}
+	if p := <-updates; !p.Cancelled {
+		t.Errorf("progress after the expiry = %+v, want cancelled", p)
+	}
+	if left, _ := os.ReadDir(s.dir("ws1")); len(left) != 0 {
+		t.Errorf("left behind %v", left)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s, root := testService(t)
+	mux := http.NewServeMux()
+	NewHandler(s, fakeWorkspaces{"ws1": root}, nil).Register(mux)
+	bus := events.NewBus()
+	var saved []events.Event
+	defer bus.Subscribe(func(e events.Event) { saved = append(saved, e) })()
+	h := events.Middleware(bus, mux)
+	do := func(method, target string, header map[string]string, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	patch := func(id string, offset int, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		return do("PATCH", "/api/workspaces/ws1/uploads/"+id, map[string]string{"Upload-Offset": strconv.Itoa(offset)}, body)
+	}
+	offset := func(rec *httptest.ResponseRecorder) string { return rec.Header().Get("Upload-Offset") }
+
+	rec := do("POST", "/api/workspaces/ws1/uploads", nil, `{"path": "vendor.tar", "size": 20}`)
+	if rec.Code != http.StatusCreated || offset(rec) != "0" || rec.Header().Get("Upload-Length") != "20" {
+		t.Fatalf("POST = %d %s", rec.Code, rec.Body)
+	}
+	var u Upload
+	json.Unmarshal(rec.Body.Bytes(), &u)
+	if want := "/api/workspaces/ws1/uploads/" + u.ID; rec.Header().Get("Location") != want {
+		t.Errorf("Location = %q, want %q", rec.Header().Get("Location"), want)
+	}
+
+	if rec := patch(u.ID, 0, "01234567"); rec.Code != http.StatusOK || offset(rec) != "8" {
+		t.Fatalf("first PATCH = %d %s, Upload-Offset %q", rec.Code, rec.Body, offset(rec))
+	}
+	// A chunk sent again, or one overlapping it, is told where to resume.
+	for _, o := range []int{0, 4, 12} {
+		if rec := patch(u.ID, o, "xxxx"); rec.Code != http.StatusConflict || offset(rec) != "8" {
+			t.Errorf("PATCH at %d = %d, Upload-Offset %q; want 409, 8", o, rec.Code, offset(rec))
+		}
+	}
+	// A chunk over MaxChunk keeps what fit.
+	if rec := patch(u.ID, 8, "89abcdefXX"); rec.Code != http.StatusRequestEntityTooLarge || offset(rec) != "16" {
+		t.Errorf("PATCH of 10 bytes = %d %s, Upload-Offset %q; want 413, 16", rec.Code, rec.Body, offset(rec))
+	}
+	if rec := do("HEAD", "/api/workspaces/ws1/uploads/"+u.ID, nil, ""); rec.Code != http.StatusOK || offset(rec) != "16" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("HEAD = %d, Upload-Offset %q", rec.Code, offset(rec))
+	}
+	if rec := patch(u.ID, 16, "ghijk"); rec.Code != http.StatusRequestEntityTooLarge || offset(rec) != "16" {
+		t.Errorf("PATCH past the size = %d %s, Upload-Offset %q; want 413, 16", rec.Code, rec.Body, offset(rec))
+	}
+	if len(saved) != 0 {
+		t.Errorf("published %+v before the upload was complete", saved)
+	}
+
+	rec = patch(u.ID, 16, "ghij")
+	if rec.Code != http.StatusOK || offset(rec) != "20" || !strings.Contains(rec.Body.String(), `"entry":{`) {
+		t.Fatalf("last PATCH = %d %s", rec.Code, rec.Body)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "vendor.tar")); string(data) != "0123456789abcdefghij" {
+		t.Errorf("file = %q", data)
+	}
+	if len(saved) != 1 || saved[0].Type != events.FileSaved || saved[0].Data["path"] != "vendor.tar" || saved[0].Data["created"] != true {
+		t.Errorf("published %+v, want file.saved", saved)
+	}
+	// Nothing is left to resume, or to put in place again.
+	if rec := patch(u.ID, 20, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("PATCH of a complete upload = %d, want 404", rec.Code)
+	}
+	if rec := do("GET", "/api/workspaces/ws1/uploads", nil, ""); rec.Body.String() != "[]\n" {
+		t.Errorf("GET uploads = %s", rec.Body)
+	}
+
+	for _, hdr := range []string{"", "x", "-1"} {
+		if rec := do("PATCH", "/api/workspaces/ws1/uploads/"+u.ID, map[string]string{"Upload-Offset": hdr}, "a"); rec.Code != http.StatusBadRequest {
+			t.Errorf("PATCH with Upload-Offset %q = %d, want 400", hdr, rec.Code)
+		}
+	}
+	if rec := do("POST", "/api/workspaces/ws1/uploads", nil, `{"path": "big", "size": 33}`); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST over MaxSize = %d, want 413", rec.Code)
+	}
+	if rec := do("GET", "/api/workspaces/ws2/uploads", nil, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET in an unknown workspace = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,42 @@
+// Package utf8x helps stream byte output as text without splitting
+// multi-byte UTF-8 sequences across messages.
+package utf8x
+
+import "unicode/utf8"
+
+// IncompleteSuffix returns the length of a truncated UTF-8 sequence at the
+// end of b, or 0 if b ends on a rune boundary.
+func IncompleteSuffix(b []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(b); i++ {
+		c := b[len(b)-i]
+		if !utf8.RuneStart(c) {
+			continue
+		}
+		if !utf8.FullRune(b[len(b)-i:]) {
+			return i
+		}
+		return 0
+	}
+	return 0
+}
+
+// Splitter buffers a byte stream and hands out the longest prefix that ends
+// on a rune boundary.
+type Splitter struct {
+	pending []byte
+}
+
+// Push appends p and returns the text that is safe to emit now.
+func (s *Splitter) Push(p []byte) []byte {
+	buf := append(s.pending, p...)
+	cut := len(buf) - IncompleteSuffix(buf)
+	s.pending = append([]byte(nil), buf[cut:]...)
+	return buf[:cut]
+}
+
+// Flush returns and clears any held-back bytes.
+func (s *Splitter) Flush() []byte {
+	out := s.pending
+	s.pending = nil
+	return out
+}
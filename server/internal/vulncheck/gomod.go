@@ -0,0 +1,39 @@
+package vulncheck
+
+import (
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gomod"
+)
+
+// goMod is what scans need of a go.mod file: where each module is
+// required and where the Go version is set.
+type goMod struct {
+	requires map[string]require
+	goLine   int // the toolchain line if there is one, else the go line
+}
+
+// require locates a require directive.
+type require struct {
+	line    int
+	version diag.Range // of the version
+}
+
+// parseGoMod reads the require, go and toolchain directives of a go.mod
+// file.
+func parseGoMod(data []byte) goMod {
+	f := gomod.Parse(data)
+	m := goMod{requires: make(map[string]require), goLine: f.GoLine}
+	if f.ToolchainLine > 0 {
+		m.goLine = f.ToolchainLine
+	}
+	for _, r := range f.Requires {
+		m.requires[r.Path] = require{
+			line: r.Line,
+			version: diag.Range{
+				Start: diag.Position{Line: r.Line, Column: r.Column},
+				End:   diag.Position{Line: r.Line, Column: r.Column + len(r.Version)},
+			},
+		}
+	}
+	return m
+}
@@ -0,0 +1,143 @@
+package vulncheck
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the vulnerability scan routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the vulnerability scan routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/vulns", h.last)
+	mux.HandleFunc("POST /api/workspaces/{id}/vulns", h.scan)
+	mux.HandleFunc("GET /ws/workspaces/{id}/vulns", h.watch)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", false
+	}
+	return id, dir, true
+}
+
+// last returns the latest scan of ?module=, the workspace root by default.
+func (h *Handler) last(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.svc.Last(id, r.URL.Query().Get("module"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+func (h *Handler) scan(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var req Request
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	res, err := h.svc.Scan(r.Context(), id, dir, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// watch sends the latest scan of each module, then a frame per Event as
+// dependency changes are scanned, until the client disconnects. Client
+// messages are ignored.
+func (h *Handler) watch(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	events, cancel, err := h.svc.Watch(id, dir)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer cancel()
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, res := range h.svc.lastAll(id) {
+		if err := conn.WriteJSON(Event{Type: EventResult, Module: res.Module, Result: res}); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case <-gone:
+			return
+		case ev := <-events:
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNoModule), errors.Is(err, ErrNotScanned):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrBusy):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrFailed):
+		httpx.Error(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, ErrUnavailable):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		slog.Error("vulnerability scan", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "vulnerability scan failed")
+	}
+}
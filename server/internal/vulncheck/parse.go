@@ -0,0 +1,377 @@
+package vulncheck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/gomod"
+)
+
+// How far a vulnerability reaches into the scanned module, from least to
+// most: its module is required, one of its packages is imported, or one
+// of its vulnerable functions is called.
+const (
+	LevelModule  = "module"
+	LevelPackage = "package"
+	LevelSymbol  = "symbol"
+)
+
+// stdlib is the module govulncheck reports standard library findings
+// under.
+const stdlib = "stdlib"
+
+// Vuln is one vulnerability affecting a dependency.
+type Vuln struct {
+	ID      string   `json:"id"`
+	Aliases []string `json:"aliases,omitempty"`
+	Summary string   `json:"summary"`
+	URL     string   `json:"url,omitempty"`
+	// FixedVersion is the first version without the vulnerability, ""
+	// when there is none yet.
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	Level        string `json:"level"`
+	// Symbols are the vulnerable functions the module calls.
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// Dependency is a module the scanned module depends on, with the
+// vulnerabilities found in it. Path "stdlib" stands for the standard
+// library, at the Go version the module is built with.
+type Dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// Line is the go.mod line that requires the module, 0 for modules
+	// only required indirectly and not listed.
+	Line int `json:"line,omitempty"`
+	// FixedVersion is the lowest version fixing all of Vulns, "" when
+	// some are not fixed yet.
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	Vulns        []Vuln `json:"vulns"`
+}
+
+// Result is the outcome of a scan.
+type Result struct {
+	// Module is the scanned module's directory relative to the workspace.
+	Module     string    `json:"module"`
+	Trigger    string    `json:"trigger"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMS int64     `json:"durationMs"`
+	Scanner    string    `json:"scanner,omitempty"`
+	GoVersion  string    `json:"goVersion,omitempty"`
+	DB         string    `json:"db,omitempty"`
+	// DBModified is when the vulnerability database was last updated.
+	DBModified   *time.Time        `json:"dbModified,omitempty"`
+	Dependencies []Dependency      `json:"dependencies"`
+	Diagnostics  []diag.Diagnostic `json:"diagnostics"`
+}
+
+// message is one object of govulncheck's JSON output.
+type message struct {
+	Config *struct {
+		ScannerVersion string     `json:"scanner_version"`
+		DB             string     `json:"db"`
+		DBLastModified *time.Time `json:"db_last_modified"`
+		GoVersion      string     `json:"go_version"`
+	} `json:"config"`
+	OSV     *osv     `json:"osv"`
+	Finding *finding `json:"finding"`
+}
+
+type osv struct {
+	ID               string   `json:"id"`
+	Aliases          []string `json:"aliases"`
+	Summary          string   `json:"summary"`
+	Details          string   `json:"details"`
+	DatabaseSpecific *struct {
+		URL string `json:"url"`
+	} `json:"database_specific"`
+}
+
+type finding struct {
+	OSV          string  `json:"osv"`
+	FixedVersion string  `json:"fixed_version"`
+	Trace        []frame `json:"trace"`
+}
+
+// frame is one step of a finding's trace, from the vulnerable symbol
+// towards the scanned module's code.
+type frame struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Receiver string `json:"receiver"`
+	Position *struct {
+		Filename string `json:"filename"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+	} `json:"position"`
+}
+
+// paths maps the file names govulncheck prints to workspace paths.
+type paths struct {
+	root   string // the workspace as seen by govulncheck
+	module string // the scanned module's directory in the workspace
+}
+
+// rel returns p relative to the workspace, or false for files outside it,
+// such as those in the module cache.
+func (m paths) rel(p string) (string, bool) {
+	if !path.IsAbs(p) {
+		return path.Join(m.module, p), true
+	}
+	rest, ok := strings.CutPrefix(p, m.root+"/")
+	return rest, ok
+}
+
+func (m paths) goMod() string {
+	return path.Join(m.module, "go.mod")
+}
+
+// parser collects govulncheck's output into a Result.
+type parser struct {
+	mod   goMod
+	paths paths
+	res   Result
+	osvs  map[string]*osv
+	// deps and vulns index the dependencies and their vulnerabilities as
+	// findings arrive.
+	deps  map[string]*Dependency
+	vulns map[string]*Vuln // module + "\x00" + OSV ID
+	calls map[string]bool  // call site diagnostics already made
+}
+
+func newParser(mod goMod, p paths) *parser {
+	return &parser{
+		mod:   mod,
+		paths: p,
+		osvs:  make(map[string]*osv),
+		deps:  make(map[string]*Dependency),
+		vulns: make(map[string]*Vuln),
+		calls: make(map[string]bool),
+		res:   Result{Dependencies: []Dependency{}, Diagnostics: []diag.Diagnostic{}},
+	}
+}
+
+// read consumes govulncheck's output stream. On malformed output the rest
+// is drained so the process can exit.
+func (p *parser) read(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var m message
+		err := dec.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			io.Copy(io.Discard, r)
+			return err
+		}
+		switch {
+		case m.Config != nil:
+			p.res.Scanner = m.Config.ScannerVersion
+			p.res.GoVersion = m.Config.GoVersion
+			p.res.DB = m.Config.DB
+			p.res.DBModified = m.Config.DBLastModified
+		case m.OSV != nil:
+			p.osvs[m.OSV.ID] = m.OSV
+		case m.Finding != nil && len(m.Finding.Trace) > 0:
+			p.finding(m.Finding)
+		}
+	}
+}
+
+// finding records a finding against its dependency. govulncheck reports
+// an OSV entry before the findings that refer to it.
+func (p *parser) finding(f *finding) {
+	top := f.Trace[0]
+	dep := p.deps[top.Module]
+	if dep == nil {
+		dep = &Dependency{Path: top.Module, Version: top.Version}
+		p.deps[top.Module] = dep
+	}
+	key := top.Module + "\x00" + f.OSV
+	v := p.vulns[key]
+	if v == nil {
+		v = &Vuln{ID: f.OSV, FixedVersion: f.FixedVersion, Level: LevelModule}
+		if o := p.osvs[f.OSV]; o != nil {
+			v.Aliases, v.Summary = o.Aliases, o.Summary
+			if v.Summary == "" {
+				v.Summary = firstLine(o.Details)
+			}
+			if o.DatabaseSpecific != nil {
+				v.URL = o.DatabaseSpecific.URL
+			}
+		}
+		p.vulns[key] = v
+	}
+	switch {
+	case top.Function != "":
+		v.Level = LevelSymbol
+		sym := symbol(top)
+		if !slices.Contains(v.Symbols, sym) {
+			v.Symbols = append(v.Symbols, sym)
+		}
+		p.callSite(f, sym)
+	case top.Package != "" && v.Level == LevelModule:
+		v.Level = LevelPackage
+	}
+}
+
+// callSite adds a diagnostic where the scanned module's code first leads
+// to a vulnerable call.
+func (p *parser) callSite(f *finding, sym string) {
+	for _, fr := range f.Trace[1:] {
+		if fr.Position == nil || fr.Position.Filename == "" || fr.Position.Line <= 0 {
+			continue
+		}
+		file, ok := p.paths.rel(fr.Position.Filename)
+		if !ok {
+			continue
+		}
+		key := file + ":" + strconv.Itoa(fr.Position.Line) + ":" + f.OSV
+		if p.calls[key] {
+			return
+		}
+		p.calls[key] = true
+		pos := diag.Position{Line: fr.Position.Line, Column: max(fr.Position.Column, 1)}
+		p.res.Diagnostics = append(p.res.Diagnostics, diag.Diagnostic{
+			File:     file,
+			Range:    diag.Range{Start: pos, End: pos},
+			Severity: diag.SeverityError,
+			Source:   "govulncheck",
+			Code:     f.OSV,
+			Message:  fmt.Sprintf("%s reaches %s, which has vulnerability %s%s", callee(fr), sym, f.OSV, fixedIn(f.FixedVersion)),
+		})
+		return
+	}
+}
+
+// result finishes the Result: dependencies by path, each with a go.mod
+// diagnostic per vulnerability.
+func (p *parser) result() *Result {
+	res := p.res
+	for _, dep := range p.deps {
+		for key, v := range p.vulns {
+			if strings.HasPrefix(key, dep.Path+"\x00") {
+				dep.Vulns = append(dep.Vulns, *v)
+			}
+		}
+		slices.SortFunc(dep.Vulns, func(a, b Vuln) int { return strings.Compare(a.ID, b.ID) })
+		dep.FixedVersion = fixingVersion(dep.Vulns)
+		if dep.Path == stdlib {
+			dep.Line = p.mod.goLine
+		} else if req, ok := p.mod.requires[dep.Path]; ok {
+			dep.Line = req.line
+		}
+		res.Dependencies = append(res.Dependencies, *dep)
+	}
+	slices.SortFunc(res.Dependencies, func(a, b Dependency) int { return strings.Compare(a.Path, b.Path) })
+	var mods []diag.Diagnostic
+	for _, dep := range res.Dependencies {
+		for _, v := range dep.Vulns {
+			mods = append(mods, p.requireDiagnostic(dep, v))
+		}
+	}
+	res.Diagnostics = append(mods, res.Diagnostics...)
+	return &res
+}
+
+// requireDiagnostic reports v on the go.mod line that brings in dep, with
+// the upgrade fixing it as a suggested fix.
+func (p *parser) requireDiagnostic(dep Dependency, v Vuln) diag.Diagnostic {
+	sev := diag.SeverityInfo
+	reach := "required but not imported"
+	switch v.Level {
+	case LevelSymbol:
+		sev, reach = diag.SeverityError, "called by this module"
+	case LevelPackage:
+		sev, reach = diag.SeverityWarning, "imported but not called"
+	}
+	name, fixed := dep.Path+"@"+dep.Version, v.FixedVersion
+	if dep.Path == stdlib {
+		name = "the standard library of " + goVersion(dep.Version)
+		if fixed != "" {
+			fixed = goVersion(fixed)
+		}
+	}
+	summary := strings.TrimSuffix(v.Summary, ".")
+	if summary == "" {
+		summary = "known vulnerability"
+	}
+	line := max(dep.Line, 1)
+	d := diag.Diagnostic{
+		File:     p.paths.goMod(),
+		Range:    diag.Range{Start: diag.Position{Line: line, Column: 1}, End: diag.Position{Line: line, Column: 1}},
+		Severity: sev,
+		Source:   "govulncheck",
+		Code:     v.ID,
+		Message:  fmt.Sprintf("%s: %s in %s, %s%s", v.ID, summary, name, reach, fixedIn(fixed)),
+	}
+	if req, ok := p.mod.requires[dep.Path]; ok && v.FixedVersion != "" {
+		d.Range = req.version
+		d.Fixes = []diag.Fix{{
+			Message: "Upgrade " + dep.Path + " to " + v.FixedVersion,
+			Edits:   []diag.Edit{{File: p.paths.goMod(), Range: req.version, NewText: v.FixedVersion}},
+		}}
+	}
+	return d
+}
+
+func fixedIn(version string) string {
+	if version == "" {
+		return " (no fixed version yet)"
+	}
+	return " (fixed in " + version + ")"
+}
+
+// goVersion turns govulncheck's standard library versions, v1.22.0, into
+// Go versions, go1.22.0.
+func goVersion(v string) string {
+	return "go" + strings.TrimPrefix(v, "v")
+}
+
+// symbol names a vulnerable function as pkg.Func or pkg.Type.Method.
+func symbol(f frame) string {
+	if f.Receiver != "" {
+		return f.Package + "." + strings.TrimPrefix(f.Receiver, "*") + "." + f.Function
+	}
+	return f.Package + "." + f.Function
+}
+
+// callee names the function whose code a trace frame is in.
+func callee(f frame) string {
+	s := path.Base(f.Package) + "."
+	if f.Receiver != "" {
+		s += strings.TrimPrefix(f.Receiver, "*") + "."
+	}
+	return s + f.Function
+}
+
+// fixingVersion returns the highest FixedVersion of vulns, "" if any has
+// none.
+func fixingVersion(vulns []Vuln) string {
+	best := ""
+	for _, v := range vulns {
+		if v.FixedVersion == "" {
+			return ""
+		}
+		if best == "" || gomod.CompareVersions(v.FixedVersion, best) > 0 {
+			best = v.FixedVersion
+		}
+	}
+	return best
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	return s
+}
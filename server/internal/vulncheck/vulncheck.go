@@ -0,0 +1,275 @@
+// Package vulncheck scans workspace modules for known vulnerabilities
+// with govulncheck, run inside the workspace's environment. Findings are
+// grouped by the go.mod dependency that brings them in and normalized into
+// diag diagnostics on the require lines, with the upgrade that fixes them
+// as a suggested fix.
+//
+// Scans run on demand, and again whenever a go.mod or go.sum changes
+// while someone watches the workspace.
+package vulncheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/watcher"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// GovulncheckPackage is installed with go install when govulncheck is
+	// not on PATH.
+	GovulncheckPackage string
+	// DB is the vulnerability database URL; defaults to govulncheck's,
+	// https://vuln.go.dev.
+	DB string
+	// Timeout bounds one scan; defaults to 10 minutes.
+	Timeout time.Duration
+	// Debounce is how long after the last go.mod or go.sum change a
+	// watched workspace is scanned; defaults to 5 seconds.
+	Debounce time.Duration
+}
+
+var (
+	// ErrInvalidRequest is returned for module directories outside the
+	// workspace.
+	ErrInvalidRequest = errors.New("vulncheck: invalid request")
+	// ErrNoModule is returned when the module directory has no go.mod.
+	ErrNoModule = errors.New("vulncheck: no go.mod in module directory")
+	// ErrBusy is returned while the module is being scanned.
+	ErrBusy = errors.New("vulncheck: a scan of the module is already running")
+	// ErrUnavailable is returned when govulncheck is not installed and
+	// cannot be.
+	ErrUnavailable = errors.New("vulncheck: govulncheck is not available")
+	// ErrFailed is returned when govulncheck fails, as it does for modules
+	// that do not build.
+	ErrFailed = errors.New("vulncheck: govulncheck failed")
+	// ErrNotScanned is returned for modules without a scan to report.
+	ErrNotScanned = errors.New("vulncheck: module not scanned yet")
+)
+
+// Request selects the module to scan.
+type Request struct {
+	// Module is the directory of the module's go.mod relative to the
+	// workspace root; defaults to ".".
+	Module string `json:"module,omitempty"`
+}
+
+// What started a scan.
+const (
+	TriggerManual       = "manual"
+	TriggerDependencies = "dependencies"
+)
+
+// Service runs govulncheck through a Launcher.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+	hub      *watcher.Hub
+
+	mu      sync.Mutex
+	results map[string]*Result // scanKey; the last scan of each module
+	running map[string]bool    // scanKey
+	watches map[string]*watch  // workspace ID
+}
+
+// New returns a Service, filling unset Config fields with defaults. hub
+// reports the file changes that trigger scans of watched workspaces.
+func New(cfg Config, l Launcher, hub *watcher.Hub) *Service {
+	if cfg.GovulncheckPackage == "" {
+		cfg.GovulncheckPackage = "golang.org/x/vuln/cmd/govulncheck@v1.1.4"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Minute
+	}
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = 5 * time.Second
+	}
+	return &Service{
+		cfg:      cfg,
+		launcher: l,
+		hub:      hub,
+		results:  make(map[string]*Result),
+		running:  make(map[string]bool),
+		watches:  make(map[string]*watch),
+	}
+}
+
+func scanKey(workspaceID, module string) string {
+	return workspaceID + "\x00" + module
+}
+
+// cleanModule validates a module directory and returns it in canonical
+// form.
+func cleanModule(m string) (string, error) {
+	if m == "" {
+		return ".", nil
+	}
+	c := path.Clean(strings.TrimPrefix(m, "./"))
+	if path.IsAbs(c) || c == ".." || strings.HasPrefix(c, "../") || strings.HasPrefix(c, "-") ||
+		strings.ContainsFunc(c, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) || r == '\\' }) {
+		return "", fmt.Errorf("%w: module %q", ErrInvalidRequest, m)
+	}
+	return c, nil
+}
+
+// Scan runs govulncheck on a module of the workspace at dir and records
+// the result as its latest.
+func (s *Service) Scan(ctx context.Context, workspaceID, dir string, req Request) (*Result, error) {
+	module, err := cleanModule(req.Module)
+	if err != nil {
+		return nil, err
+	}
+	return s.scan(ctx, workspaceID, dir, module, TriggerManual)
+}
+
+// Last returns the latest scan of a module.
+func (s *Service) Last(workspaceID, module string) (*Result, error) {
+	module, err := cleanModule(module)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.results[scanKey(workspaceID, module)]
+	if !ok {
+		return nil, ErrNotScanned
+	}
+	return res, nil
+}
+
+// lastAll returns the latest scans of a workspace's modules by module.
+func (s *Service) lastAll(workspaceID string) []*Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Result
+	for key, res := range s.results {
+		if strings.HasPrefix(key, workspaceID+"\x00") {
+			out = append(out, res)
+		}
+	}
+	slices.SortFunc(out, func(a, b *Result) int { return strings.Compare(a.Module, b.Module) })
+	return out
+}
+
+// script prints govulncheck's JSON on stdout and its errors on
+// stderr, and exits 127 when it is not available. $1 is the package to
+// install if needed and $2 the module directory; govulncheck's own flags
+// follow.
+const script = `bin="${TMPDIR:-/tmp}/webide-tools"
+PATH="$bin:$PATH"
+if ! command -v govulncheck >/dev/null 2>&1; then
+	GOBIN="$bin" GOFLAGS= go install "$1" >/dev/null 2>&1 || exit 127
+fi
+cd "$2" || exit 2
+shift 2
+exec govulncheck -format json "$@" ./...`
+
+// maxStderr caps the error output kept to explain a failed scan.
+const maxStderr = 16 << 10
+
+func (s *Service) scan(ctx context.Context, workspaceID, dir, module, trigger string) (*Result, error) {
+	modFile, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(module), "go.mod"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNoModule, module)
+		}
+		return nil, fmt.Errorf("vulncheck: read go.mod: %w", err)
+	}
+	key := scanKey(workspaceID, module)
+	s.mu.Lock()
+	if s.running[key] {
+		s.mu.Unlock()
+		return nil, ErrBusy
+	}
+	s.running[key] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, key)
+		s.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	start := time.Now()
+	argv := []string{"sh", "-c", script, "sh", s.cfg.GovulncheckPackage, module}
+	if s.cfg.DB != "" {
+		argv = append(argv, "-db", s.cfg.DB)
+	}
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	var stderr limitedBuffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.WaitDelay = 3 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+
+	root := s.launcher.Root(dir)
+	p := newParser(parseGoMod(modFile), paths{root: root, module: module})
+	perr := p.read(stdout)
+	err = cmd.Wait()
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("%w: scan timed out", ErrFailed)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 127:
+		return nil, ErrUnavailable
+	case err != nil:
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrFailed, msg)
+	case perr != nil:
+		return nil, fmt.Errorf("vulncheck: read govulncheck output: %w", perr)
+	}
+
+	res := p.result()
+	res.Module, res.Trigger = module, trigger
+	res.StartedAt = start.UTC()
+	res.DurationMS = time.Since(start).Milliseconds()
+	s.mu.Lock()
+	s.results[key] = res
+	s.mu.Unlock()
+	return res, nil
+}
+
+// limitedBuffer keeps the first maxStderr bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxStderr - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,173 @@
+package vulncheck
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/watcher"
+)
+
+// EventType names a watch event.
+type EventType string
+
+const (
+	// EventScanning is sent when a dependency change starts a scan.
+	EventScanning EventType = "scanning"
+	EventResult   EventType = "result"
+	// EventError reports a scan that failed.
+	EventError EventType = "error"
+)
+
+// Event is one item of a workspace watch.
+type Event struct {
+	Type   EventType `json:"type"`
+	Module string    `json:"module"`
+	Result *Result   `json:"result,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// watch rescans the modules of one workspace as their dependencies change,
+// for as long as anyone watches it.
+type watch struct {
+	sub  *watcher.Subscription
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// Watch reports the scans of a workspace's modules from now on, running
+// one whenever a go.mod or go.sum is written, until cancel is called.
+// Watchers of a workspace share its scans.
+func (s *Service) Watch(workspaceID, dir string) (<-chan Event, func(), error) {
+	c := make(chan Event, 16)
+	s.mu.Lock()
+	w := s.watches[workspaceID]
+	if w == nil {
+		sub, err := s.hub.Subscribe(workspaceID, dir)
+		if err != nil {
+			s.mu.Unlock()
+			return nil, nil, err
+		}
+		w = &watch{sub: sub, subs: make(map[chan Event]struct{})}
+		s.watches[workspaceID] = w
+		go s.watchLoop(w, workspaceID, dir)
+	}
+	w.mu.Lock()
+	w.subs[c] = struct{}{}
+	w.mu.Unlock()
+	s.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			w.mu.Lock()
+			delete(w.subs, c)
+			last := len(w.subs) == 0
+			w.mu.Unlock()
+			if last && s.watches[workspaceID] == w {
+				delete(s.watches, workspaceID)
+				w.sub.Close()
+			}
+		})
+	}
+	return c, cancel, nil
+}
+
+// send passes ev to every watcher. One too slow to keep up misses it and
+// can get the result with Last.
+func (w *watch) send(ev Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for c := range w.subs {
+		select {
+		case c <- ev:
+		default:
+		}
+	}
+}
+
+// watchLoop scans each module whose go.mod or go.sum changed, once the
+// changes have settled. Scans run apart from the loop so it keeps up with
+// the workspace's events meanwhile.
+func (s *Service) watchLoop(w *watch, workspaceID, dir string) {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	var pending []string
+	var done chan []string // while scanning; receives the modules to retry
+	for {
+		select {
+		case ev, ok := <-w.sub.Events():
+			if !ok {
+				return
+			}
+			if m, ok := changedModule(ev); ok {
+				if !slices.Contains(pending, m) {
+					pending = append(pending, m)
+				}
+				timer.Reset(s.cfg.Debounce)
+			}
+		case retry := <-done:
+			done = nil
+			for _, m := range retry {
+				if !slices.Contains(pending, m) {
+					pending = append(pending, m)
+				}
+			}
+			if len(pending) > 0 {
+				timer.Reset(s.cfg.Debounce)
+			}
+		case <-timer.C:
+			if done != nil {
+				// Started again when the running scans are done.
+				continue
+			}
+			done = make(chan []string, 1)
+			go func(modules []string) { done <- s.scanAll(w, workspaceID, dir, modules) }(pending)
+			pending = nil
+		}
+	}
+}
+
+// scanAll scans modules for a watch, returning those that could not be
+// scanned because a scan of them was already running.
+func (s *Service) scanAll(w *watch, workspaceID, dir string, modules []string) []string {
+	var retry []string
+	for _, m := range modules {
+		w.send(Event{Type: EventScanning, Module: m})
+		res, err := s.scan(context.Background(), workspaceID, dir, m, TriggerDependencies)
+		switch {
+		case errors.Is(err, ErrBusy):
+			retry = append(retry, m)
+		case errors.Is(err, ErrNoModule):
+			// The go.mod was removed.
+		case err != nil:
+			slog.Warn("vulnerability scan", "workspace", workspaceID, "module", m, "err", err)
+			w.send(Event{Type: EventError, Module: m, Error: err.Error()})
+		default:
+			w.send(Event{Type: EventResult, Module: m, Result: res})
+		}
+	}
+	return retry
+}
+
+// changedModule returns the directory of the module whose go.mod or
+// go.sum ev changes.
+func changedModule(ev watcher.Event) (string, bool) {
+	if ev.IsDir {
+		return "", false
+	}
+	for _, p := range []string{ev.Path, ev.OldPath} {
+		switch path.Base(p) {
+		case "go.mod", "go.sum":
+			return path.Dir(p), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,235 @@
+package wasm
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves WebAssembly builds and their preview pages.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler for svc. Preview pages run user code, so
+// they are served in a sandboxed, opaque origin; the reload socket accepts
+// any origin since it only announces bundle versions.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm, wsOpts: &ws.Options{CheckOrigin: func(*http.Request) bool { return true }}}
+}
+
+// Register mounts the WebAssembly routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/workspaces/{id}/wasm/build", h.build)
+	mux.HandleFunc("GET /api/workspaces/{id}/wasm", h.bundle)
+	mux.HandleFunc("GET /preview/{id}/{file...}", h.preview)
+	mux.HandleFunc("GET /ws/preview/{id}", h.reload)
+}
+
+func (h *Handler) build(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	var req BuildRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	res, err := h.svc.Build(r.Context(), id, dir, req)
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, ErrBuilding):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, ErrUnavailable):
+		httpx.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		slog.Error("wasm build", "workspace", id, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not build")
+		return
+	}
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+func (h *Handler) bundle(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	b, err := h.svc.Bundle(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.JSON(w, http.StatusOK, b)
+}
+
+// preview serves the latest bundle: main.wasm, wasm_exec.js, an index
+// page that runs them, and other files from the package directory, such
+// as stylesheets or a custom index.html.
+func (h *Handler) preview(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	b, err := h.svc.Bundle(id)
+	if err != nil {
+		http.Error(w, "no WebAssembly build yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	// The sandboxed page has an opaque origin, so its own requests for
+	// the bundle are cross-origin.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	file := r.PathValue("file")
+	switch file {
+	case "main.wasm":
+		w.Header().Set("Content-Type", "application/wasm")
+		w.Write(b.Wasm)
+		return
+	case "wasm_exec.js":
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Write(b.WasmExec)
+		return
+	case "":
+		file = "index.html"
+	}
+
+	fsys, err := files.New(dir)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := fsys.ReadFile(path.Join(b.Package, file))
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && file == "index.html":
+		data = defaultIndex
+	case err != nil:
+		http.NotFound(w, r)
+		return
+	}
+	ctype := mime.TypeByExtension(path.Ext(file))
+	if ctype == "" {
+		ctype = http.DetectContentType(data)
+	}
+	w.Header().Set("Content-Type", ctype)
+	if strings.HasPrefix(ctype, "text/html") {
+		w.Header().Set("Content-Security-Policy", "sandbox allow-scripts allow-forms allow-popups allow-modals")
+		data = injectReload(data, id)
+	}
+	w.Write(data)
+}
+
+// defaultIndex runs main.wasm when the package has no index.html.
+var defaultIndex = []byte(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>Preview</title></head>
+<body>
+<script src="wasm_exec.js"></script>
+<script>
+const go = new Go();
+WebAssembly.instantiateStreaming(fetch("main.wasm"), go.importObject)
+	.then(r => go.run(r.instance))
+	.catch(err => { document.body.textContent = err; });
+</script>
+</body>
+</html>
+`)
+
+var reloadScript = template.Must(template.New("reload").Parse(`<script>
+(() => {
+	const proto = location.protocol === "https:" ? "wss://" : "ws://";
+	const socket = new WebSocket(proto + location.host + "/ws/preview/" + {{.}});
+	socket.onmessage = () => location.reload();
+})();
+</script>
+`))
+
+// injectReload adds the hot-reload script before </body>, or at the end.
+func injectReload(page []byte, id string) []byte {
+	var script bytes.Buffer
+	if err := reloadScript.Execute(&script, id); err != nil {
+		return page
+	}
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i < 0 {
+		return append(page, script.Bytes()...)
+	}
+	out := make([]byte, 0, len(page)+script.Len())
+	out = append(out, page[:i]...)
+	out = append(out, script.Bytes()...)
+	return append(out, page[i:]...)
+}
+
+type rebuiltFrame struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+// reload sends {"type":"rebuilt","version":N} each time a new bundle is
+// built, until the preview page goes away.
+func (h *Handler) reload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	versions, cancel := h.svc.Subscribe(id)
+	defer cancel()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case v := <-versions:
+			if err := conn.WriteJSON(rebuiltFrame{Type: "rebuilt", Version: v}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Package wasm compiles a workspace package to WebAssembly, with the Go
+// toolchain (GOOS=js GOARCH=wasm) or TinyGo, and keeps the latest bundle
+// of each workspace for the preview endpoint. Preview pages reload when a
+// new bundle is built.
+package wasm
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+)
+
+// Compiler names a WebAssembly compiler.
+type Compiler string
+
+const (
+	Go     Compiler = "go"
+	TinyGo Compiler = "tinygo"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// TinyGo allows builds with TinyGo, which must be installed in the
+	// workspace image.
+	TinyGo bool
+	// Timeout bounds one build; defaults to 5 minutes.
+	Timeout time.Duration
+	// MaxBytes caps the size of a bundle; defaults to 64 MiB.
+	MaxBytes int64
+}
+
+var (
+	// ErrInvalidRequest is returned for malformed packages and disabled
+	// compilers.
+	ErrInvalidRequest = errors.New("wasm: invalid request")
+	// ErrNoBundle is returned before a workspace's first successful build.
+	ErrNoBundle = errors.New("wasm: no bundle built")
+	// ErrBuilding is returned when the workspace already has a build running.
+	ErrBuilding = errors.New("wasm: build already running")
+	// ErrUnavailable is returned when the selected compiler is missing.
+	ErrUnavailable = errors.New("wasm: compiler not available")
+)
+
+// BuildRequest selects what to compile.
+type BuildRequest struct {
+	// Package is the main package relative to the workspace root, like
+	// "./cmd/demo"; defaults to ".".
+	Package string `json:"package,omitempty"`
+	// Compiler defaults to go.
+	Compiler Compiler `json:"compiler,omitempty"`
+}
+
+// Bundle is a compiled module with the matching wasm_exec.js loader.
+type Bundle struct {
+	Version  int       `json:"version"`
+	Package  string    `json:"package"`
+	Compiler Compiler  `json:"compiler"`
+	Size     int64     `json:"size"`
+	BuiltAt  time.Time `json:"builtAt"`
+	Wasm     []byte    `json:"-"`
+	WasmExec []byte    `json:"-"`
+}
+
+// BuildResult is the outcome of a build. Bundle is set when it succeeded.
+type BuildResult struct {
+	OK          bool              `json:"ok"`
+	DurationMS  int64             `json:"durationMs"`
+	Output      string            `json:"output"`
+	Diagnostics []diag.Diagnostic `json:"diagnostics,omitempty"`
+	Bundle      *Bundle           `json:"bundle,omitempty"`
+}
+
+type workspaceState struct {
+	building bool
+	bundle   *Bundle
+	version  int
+	subs     map[chan int]struct{}
+}
+
+// Service builds bundles through a Launcher.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	mu         sync.Mutex
+	workspaces map[string]*workspaceState
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	return &Service{cfg: cfg, launcher: l, workspaces: make(map[string]*workspaceState)}
+}
+
+// buildScript compiles package $2 with compiler $1 and writes a tar of
+// main.wasm and wasm_exec.js to stdout. Compiler output goes to stderr.
+const buildScript = `set -e
+out="$(mktemp -d)"
+trap 'rm -rf "$out"' EXIT
+if [ "$1" = tinygo ]; then
+	command -v tinygo >/dev/null 2>&1 || exit 127
+	tinygo build -o "$out/main.wasm" -target wasm "$2" >&2
+	cp "$(tinygo env TINYGOROOT)/targets/wasm_exec.js" "$out/"
+else
+	GOOS=js GOARCH=wasm go build -o "$out/main.wasm" "$2" >&2
+	root="$(go env GOROOT)"
+	cp "$root/lib/wasm/wasm_exec.js" "$out/" 2>/dev/null || cp "$root/misc/wasm/wasm_exec.js" "$out/"
+fi
+tar -cf - -C "$out" main.wasm wasm_exec.js
+`
+
+// Build compiles the workspace at dir. A successful build replaces the
+// workspace's bundle and notifies its preview subscribers; compile errors
+// are reported through the result.
+func (s *Service) Build(ctx context.Context, workspaceID, dir string, req BuildRequest) (*BuildResult, error) {
+	if req.Package == "" {
+		req.Package = "."
+	}
+	if !validPackage(req.Package) {
+		return nil, fmt.Errorf("%w: package %q", ErrInvalidRequest, req.Package)
+	}
+	switch req.Compiler {
+	case "":
+		req.Compiler = Go
+	case Go:
+	case TinyGo:
+		if !s.cfg.TinyGo {
+			return nil, fmt.Errorf("%w: tinygo is not enabled", ErrInvalidRequest)
+		}
+	default:
+		return nil, fmt.Errorf("%w: compiler %q", ErrInvalidRequest, req.Compiler)
+	}
+
+	if !s.begin(workspaceID) {
+		return nil, ErrBuilding
+	}
+	defer s.end(workspaceID)
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	argv := []string{"sh", "-c", buildScript, "sh", string(req.Compiler), req.Package}
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	var stdout bytes.Buffer
+	stderr := &limitedBuffer{max: 64 << 10}
+	cmd.Stdout = &capped{w: &stdout, n: s.cfg.MaxBytes + 1<<20}
+	cmd.Stderr = stderr
+	cmd.WaitDelay = 3 * time.Second
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("wasm: start build: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+	err = cmd.Wait()
+
+	res := &BuildResult{DurationMS: time.Since(start).Milliseconds(), Output: stderr.String()}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		res.Output += "build timed out\n"
+		return res, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 127:
+		return nil, fmt.Errorf("%w: %s", ErrUnavailable, req.Compiler)
+	case errors.As(err, &exitErr):
+		res.Diagnostics = parseErrors(res.Output, s.launcher.Root(dir))
+		return res, nil
+	case err != nil:
+		return nil, fmt.Errorf("wasm: build: %w", err)
+	}
+
+	b, err := readBundle(&stdout, s.cfg.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	b.Package, b.Compiler, b.BuiltAt = req.Package, req.Compiler, time.Now().UTC()
+	s.publish(workspaceID, b)
+	res.OK, res.Bundle = true, b
+	return res, nil
+}
+
+// readBundle extracts the build script's tar stream.
+func readBundle(r io.Reader, max int64) (*Bundle, error) {
+	b := &Bundle{}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("wasm: read bundle: %w", err)
+		}
+		if h.Size > max {
+			return nil, fmt.Errorf("wasm: bundle exceeds %d bytes", max)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("wasm: read bundle: %w", err)
+		}
+		switch h.Name {
+		case "main.wasm":
+			b.Wasm = data
+		case "wasm_exec.js":
+			b.WasmExec = data
+		}
+	}
+	if b.Wasm == nil || b.WasmExec == nil {
+		return nil, errors.New("wasm: read bundle: incomplete build output")
+	}
+	b.Size = int64(len(b.Wasm))
+	return b, nil
+}
+
+// parseErrors extracts compiler errors, relative to the workspace root.
+func parseErrors(out, root string) []diag.Diagnostic {
+	var ds []diag.Diagnostic
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		if d, ok := diag.ParseLine(sc.Text()); ok {
+			d.File = strings.TrimPrefix(d.File, root+"/")
+			d.Source = "compiler"
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
+
+// Bundle returns the workspace's latest bundle.
+func (s *Service) Bundle(workspaceID string) (*Bundle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st := s.workspaces[workspaceID]; st != nil && st.bundle != nil {
+		return st.bundle, nil
+	}
+	return nil, ErrNoBundle
+}
+
+// Subscribe returns a channel receiving the version of every new bundle of
+// the workspace, and a func that ends the subscription. Versions a slow
+// subscriber has not read yet are replaced by newer ones.
+func (s *Service) Subscribe(workspaceID string) (<-chan int, func()) {
+	c := make(chan int, 1)
+	s.mu.Lock()
+	st := s.state(workspaceID)
+	st.subs[c] = struct{}{}
+	s.mu.Unlock()
+	return c, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(st.subs, c)
+	}
+}
+
+// state returns the workspace's state, creating it. s.mu must be held.
+func (s *Service) state(workspaceID string) *workspaceState {
+	st := s.workspaces[workspaceID]
+	if st == nil {
+		st = &workspaceState{subs: make(map[chan int]struct{})}
+		s.workspaces[workspaceID] = st
+	}
+	return st
+}
+
+// begin marks a build as running, reporting false if one already is.
+func (s *Service) begin(workspaceID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.state(workspaceID)
+	if st.building {
+		return false
+	}
+	st.building = true
+	return true
+}
+
+func (s *Service) end(workspaceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workspaces[workspaceID].building = false
+}
+
+func (s *Service) publish(workspaceID string, b *Bundle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.state(workspaceID)
+	st.version++
+	b.Version = st.version
+	st.bundle = b
+	for c := range st.subs {
+		select {
+		case <-c:
+		default:
+		}
+		c <- b.Version
+	}
+}
+
+// validPackage reports whether p names a single package below the root.
+func validPackage(p string) bool {
+	if p != "." && !strings.HasPrefix(p, "./") {
+		return false
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." || seg == "..." {
+			return false
+		}
+	}
+	for _, r := range p {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// capped writes up to n bytes to w and fails after that, which stops a
+// runaway build from filling memory.
+type capped struct {
+	w io.Writer
+	n int64
+}
+
+func (c *capped) Write(p []byte) (int, error) {
+	if int64(len(p)) > c.n {
+		return 0, errors.New("wasm: build output too large")
+	}
+	c.n -= int64(len(p))
+	return c.w.Write(p)
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	max int
+	buf bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.buf.Len(); n > 0 {
+		b.buf.Write(p[:min(n, len(p))])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string { return b.buf.String() }
@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/ws"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler pushes file change events to editors over WebSocket.
+type Handler struct {
+	hub        *Hub
+	workspaces Workspaces
+	wsOpts     *ws.Options
+}
+
+// NewHandler returns a Handler serving events from hub.
+func NewHandler(hub *Hub, wm Workspaces, wsOpts *ws.Options) *Handler {
+	return &Handler{hub: hub, workspaces: wm, wsOpts: wsOpts}
+}
+
+// Register mounts the workspace event socket on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /ws/workspaces/{id}/events", h.serve)
+}
+
+// fsFrame is an Event as sent to the editor.
+type fsFrame struct {
+	Type string `json:"type"`
+	Event
+}
+
+// serve streams {"type":"fs","op":...,"path":...} frames until the client
+// disconnects. Client messages are ignored.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	conn, err := ws.Upgrade(w, r, h.wsOpts)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sub, err := h.hub.Subscribe(id, dir)
+	if err != nil {
+		slog.Error("watch workspace", "workspace", id, "err", err)
+		conn.CloseWithCode(ws.CloseInternalError, "file watching unavailable")
+		return
+	}
+	defer sub.Close()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case ev, ok := <-sub.Events():
+			if !ok {
+				conn.CloseWithCode(ws.CloseGoingAway, "watcher stopped")
+				return
+			}
+			if err := conn.WriteJSON(fsFrame{Type: "fs", Event: ev}); err != nil {
+				return
+			}
+		}
+	}
+}
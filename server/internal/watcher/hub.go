@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// Hub shares one Watcher per workspace among all of its subscribers. The
+// watcher starts with the first subscriber and stops with the last.
+type Hub struct {
+	opts Options
+
+	mu        sync.Mutex
+	entries   map[string]*hubEntry
+	observers map[int]func(id string, ev Event)
+	next      int
+}
+
+type hubEntry struct {
+	w    *Watcher
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives the events of one workspace.
+type Subscription struct {
+	hub *Hub
+	id  string
+	c   chan Event
+}
+
+// subscriberBuffer is how many events a slow subscriber may lag behind
+// before further events are dropped for it.
+const subscriberBuffer = 256
+
+// NewHub returns a Hub creating watchers with opts.
+func NewHub(opts Options) *Hub {
+	return &Hub{opts: opts, entries: make(map[string]*hubEntry), observers: make(map[int]func(string, Event))}
+}
+
+// Observe calls fn with the events of every workspace being watched, until
+// cancel is called. Workspaces are only watched while they have
+// subscribers. fn is called with the hub locked, so it must not block.
+func (h *Hub) Observe(fn func(id string, ev Event)) (cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := h.next
+	h.next++
+	h.observers[n] = fn
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.observers, n)
+	}
+}
+
+// Subscribe starts receiving events for workspace id rooted at dir.
+func (h *Hub) Subscribe(id, dir string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, ok := h.entries[id]
+	if !ok {
+		w, err := New(dir, h.opts)
+		if err != nil {
+			return nil, err
+		}
+		e = &hubEntry{w: w, subs: make(map[*Subscription]struct{})}
+		h.entries[id] = e
+		go h.fanOut(id, e)
+	}
+	s := &Subscription{hub: h, id: id, c: make(chan Event, subscriberBuffer)}
+	e.subs[s] = struct{}{}
+	return s, nil
+}
+
+// Events returns the subscription's channel. It is closed after Close or
+// when the underlying watcher stops.
+func (s *Subscription) Events() <-chan Event { return s.c }
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, ok := h.entries[s.id]
+	if !ok {
+		return
+	}
+	if _, ok := e.subs[s]; !ok {
+		return
+	}
+	delete(e.subs, s)
+	close(s.c)
+	if len(e.subs) == 0 {
+		delete(h.entries, s.id)
+		e.w.Close()
+	}
+}
+
+func (h *Hub) fanOut(id string, e *hubEntry) {
+	for ev := range e.w.Events() {
+		h.mu.Lock()
+		h.deliverLocked(id, e, ev)
+		h.mu.Unlock()
+	}
+}
+
+func (h *Hub) deliverLocked(id string, e *hubEntry, ev Event) {
+	for _, fn := range h.observers {
+		fn(id, ev)
+	}
+	for s := range e.subs {
+		select {
+		case s.c <- ev:
+		default:
+			slog.Warn("dropping file event for slow subscriber", "workspace", id, "path", ev.Path)
+		}
+	}
+}
+
+// Close stops every watcher and ends all subscriptions.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, e := range h.entries {
+		for s := range e.subs {
+			close(s.c)
+		}
+		e.w.Close()
+		delete(h.entries, id)
+	}
+}
@@ -0,0 +1,244 @@
+//go:build linux
+
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_EXCL_UNLINK | syscall.IN_DONT_FOLLOW
+
+// inotify watches every directory of the tree with its own watch descriptor.
+type inotify struct {
+	w    *Watcher
+	file *os.File
+
+	mu    sync.Mutex
+	paths map[int32]string // watch descriptor -> root-relative dir
+	wds   map[string]int32
+}
+
+func newBackend(w *Watcher) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("watcher: inotify init: %w", err)
+	}
+	// A non-blocking fd wrapped in os.File is serviced by the runtime poller,
+	// so Close unblocks the pending Read.
+	in := &inotify{
+		w:     w,
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		paths: make(map[int32]string),
+		wds:   make(map[string]int32),
+	}
+	if err := in.addTree("", false); err != nil {
+		in.file.Close()
+		return nil, err
+	}
+	go in.readLoop()
+	return in, nil
+}
+
+func (in *inotify) close() error { return in.file.Close() }
+
+// addTree watches dir and all directories below it. With report set, files
+// and directories found are announced as created: they may have appeared
+// before the watch on a freshly created directory was in place.
+func (in *inotify) addTree(dir string, report bool) error {
+	return filepath.WalkDir(filepath.Join(in.w.root, filepath.FromSlash(dir)), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == in.w.root {
+				return err
+			}
+			return nil
+		}
+		rel := in.rel(p)
+		if rel != "" && in.w.opts.Ignore(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if report && rel != dir {
+			in.w.send(Event{Op: OpCreate, Path: rel, IsDir: d.IsDir()})
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(int(in.file.Fd()), p, watchMask)
+		if err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				slog.Warn("inotify watch limit reached", "root", in.w.root, "dir", rel)
+				return filepath.SkipAll
+			}
+			return nil
+		}
+		in.mu.Lock()
+		in.paths[int32(wd)] = rel
+		in.wds[rel] = int32(wd)
+		in.mu.Unlock()
+		return nil
+	})
+}
+
+func (in *inotify) rel(p string) string {
+	r, err := filepath.Rel(in.w.root, p)
+	if err != nil || r == "." {
+		return ""
+	}
+	return filepath.ToSlash(r)
+}
+
+// forget drops bookkeeping for dir and everything below it.
+func (in *inotify) forget(dir string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for rel, wd := range in.wds {
+		if rel == dir || strings.HasPrefix(rel, dir+"/") {
+			syscall.InotifyRmWatch(int(in.file.Fd()), uint32(wd))
+			delete(in.wds, rel)
+			delete(in.paths, wd)
+		}
+	}
+}
+
+// move re-keys bookkeeping after a watched directory was renamed; the
+// kernel keeps the descriptors, only our path names change.
+func (in *inotify) move(from, to string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for rel, wd := range in.wds {
+		if rel == from || strings.HasPrefix(rel, from+"/") {
+			np := to + strings.TrimPrefix(rel, from)
+			delete(in.wds, rel)
+			in.wds[np] = wd
+			in.paths[wd] = np
+		}
+	}
+}
+
+type rawEvent struct {
+	mask   uint32
+	cookie uint32
+	path   string
+}
+
+func (in *inotify) readLoop() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			return
+		}
+		in.handle(in.parse(buf[:n]))
+	}
+}
+
+func (in *inotify) parse(buf []byte) []rawEvent {
+	var out []rawEvent
+	for off := 0; off+syscall.SizeofInotifyEvent <= len(buf); {
+		raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		nameStart := off + syscall.SizeofInotifyEvent
+		nameEnd := nameStart + int(raw.Len)
+		if nameEnd > len(buf) {
+			break
+		}
+		name := strings.TrimRight(string(buf[nameStart:nameEnd]), "\x00")
+		off = nameEnd
+
+		if raw.Mask&syscall.IN_IGNORED != 0 {
+			in.mu.Lock()
+			if rel, ok := in.paths[raw.Wd]; ok {
+				delete(in.paths, raw.Wd)
+				if in.wds[rel] == raw.Wd {
+					delete(in.wds, rel)
+				}
+			}
+			in.mu.Unlock()
+			continue
+		}
+		in.mu.Lock()
+		dir, ok := in.paths[raw.Wd]
+		in.mu.Unlock()
+		if !ok || name == "" {
+			continue
+		}
+		out = append(out, rawEvent{mask: raw.Mask, cookie: raw.Cookie, path: path.Join(dir, name)})
+	}
+	return out
+}
+
+// handle translates one read's worth of kernel events. A MOVED_FROM is
+// paired with the MOVED_TO carrying the same cookie; an unpaired one means
+// the entry left the tree and is reported as a delete.
+func (in *inotify) handle(evs []rawEvent) {
+	moves := make(map[uint32]rawEvent)
+	for _, ev := range evs {
+		isDir := ev.mask&syscall.IN_ISDIR != 0
+		ignored := in.w.opts.Ignore(ev.path, isDir)
+		switch {
+		case ev.mask&syscall.IN_MOVED_FROM != 0:
+			moves[ev.cookie] = ev
+		case ev.mask&syscall.IN_MOVED_TO != 0:
+			from, paired := moves[ev.cookie]
+			delete(moves, ev.cookie)
+			fromIgnored := paired && in.w.opts.Ignore(from.path, isDir)
+			switch {
+			case ignored:
+				if paired && !fromIgnored {
+					in.dropDir(from.path, isDir)
+					in.w.send(Event{Op: OpDelete, Path: from.path, IsDir: isDir})
+				}
+			case paired && !fromIgnored:
+				if isDir {
+					in.move(from.path, ev.path)
+				}
+				in.w.send(Event{Op: OpRename, Path: ev.path, OldPath: from.path, IsDir: isDir})
+			case paired:
+				// An atomic write renamed its temp file over the target.
+				in.w.send(Event{Op: OpModify, Path: ev.path})
+			default:
+				in.w.send(Event{Op: OpCreate, Path: ev.path, IsDir: isDir})
+				if isDir {
+					in.addTree(ev.path, true)
+				}
+			}
+		case ignored:
+		case ev.mask&syscall.IN_CREATE != 0:
+			in.w.send(Event{Op: OpCreate, Path: ev.path, IsDir: isDir})
+			if isDir {
+				in.addTree(ev.path, true)
+			}
+		case ev.mask&syscall.IN_DELETE != 0:
+			in.dropDir(ev.path, isDir)
+			in.w.send(Event{Op: OpDelete, Path: ev.path, IsDir: isDir})
+		case ev.mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0 && !isDir:
+			in.w.send(Event{Op: OpModify, Path: ev.path})
+		}
+	}
+	for _, from := range moves {
+		isDir := from.mask&syscall.IN_ISDIR != 0
+		if in.w.opts.Ignore(from.path, isDir) {
+			continue
+		}
+		in.dropDir(from.path, isDir)
+		in.w.send(Event{Op: OpDelete, Path: from.path, IsDir: isDir})
+	}
+}
+
+func (in *inotify) dropDir(rel string, isDir bool) {
+	if isDir {
+		in.forget(rel)
+	}
+}
@@ -0,0 +1,98 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// poller rescans the tree every PollInterval and diffs it against the last
+// scan. Renames surface as a delete followed by a create.
+type poller struct {
+	w    *Watcher
+	stop chan struct{}
+}
+
+type fileState struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+}
+
+func newBackend(w *Watcher) (backend, error) {
+	p := &poller{w: w, stop: make(chan struct{})}
+	prev, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	go p.loop(prev)
+	return p, nil
+}
+
+func (p *poller) close() error {
+	close(p.stop)
+	return nil
+}
+
+func (p *poller) loop(prev map[string]fileState) {
+	t := time.NewTicker(p.w.opts.PollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+		}
+		cur, err := p.scan()
+		if err != nil {
+			continue
+		}
+		for rel, st := range cur {
+			old, ok := prev[rel]
+			switch {
+			case !ok:
+				p.w.send(Event{Op: OpCreate, Path: rel, IsDir: st.isDir})
+			case !st.isDir && (old.size != st.size || !old.modTime.Equal(st.modTime)):
+				p.w.send(Event{Op: OpModify, Path: rel})
+			}
+		}
+		for rel, st := range prev {
+			if _, ok := cur[rel]; !ok {
+				p.w.send(Event{Op: OpDelete, Path: rel, IsDir: st.isDir})
+			}
+		}
+		prev = cur
+	}
+}
+
+func (p *poller) scan() (map[string]fileState, error) {
+	out := make(map[string]fileState)
+	err := filepath.WalkDir(p.w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == p.w.root {
+				return err
+			}
+			return nil
+		}
+		r, _ := filepath.Rel(p.w.root, path)
+		if r == "." {
+			return nil
+		}
+		rel := filepath.ToSlash(r)
+		if p.w.opts.Ignore(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		out[rel] = fileState{isDir: d.IsDir(), size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return out, err
+}
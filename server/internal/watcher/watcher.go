@@ -0,0 +1,159 @@
+// Package watcher reports changes to a workspace tree as it happens, whether
+// they come from the file API, the terminal, or tools such as go generate.
+// On Linux it uses inotify; elsewhere it falls back to periodic scanning.
+package watcher
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Op is the kind of change an Event describes.
+type Op string
+
+const (
+	OpCreate Op = "create"
+	OpModify Op = "modify"
+	OpDelete Op = "delete"
+	OpRename Op = "rename"
+)
+
+// Event is a single change below the watched root. Paths are slash-separated
+// and relative to the root.
+type Event struct {
+	Op      Op     `json:"op"`
+	Path    string `json:"path"`
+	OldPath string `json:"oldPath,omitempty"`
+	IsDir   bool   `json:"isDir"`
+}
+
+// Options configures a Watcher.
+type Options struct {
+	// Ignore reports whether a path should not be watched or reported. The
+	// default skips .git, node_modules, and in-flight atomic writes.
+	Ignore func(rel string, isDir bool) bool
+	// Coalesce is how long events are batched so bursts of writes to the same
+	// file produce one modify event.
+	Coalesce time.Duration
+	// PollInterval is the scan period of the portable fallback backend.
+	PollInterval time.Duration
+}
+
+// DefaultIgnore is the Ignore func used when Options.Ignore is nil.
+func DefaultIgnore(rel string, isDir bool) bool {
+	name := path.Base(rel)
+	if strings.HasPrefix(name, files.TempPrefix) {
+		return true
+	}
+	return isDir && (name == ".git" || name == "node_modules")
+}
+
+// Watcher delivers Events for one directory tree.
+type Watcher struct {
+	root   string
+	opts   Options
+	raw    chan Event
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+	be     backend
+}
+
+// backend is the platform-specific change source feeding Watcher.raw.
+type backend interface {
+	close() error
+}
+
+// New starts watching root recursively.
+func New(root string, opts Options) (*Watcher, error) {
+	if opts.Ignore == nil {
+		opts.Ignore = DefaultIgnore
+	}
+	if opts.Coalesce <= 0 {
+		opts.Coalesce = 50 * time.Millisecond
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	w := &Watcher{
+		root:   root,
+		opts:   opts,
+		raw:    make(chan Event, 256),
+		events: make(chan Event, 256),
+		done:   make(chan struct{}),
+	}
+	be, err := newBackend(w)
+	if err != nil {
+		return nil, err
+	}
+	w.be = be
+	go w.coalesce()
+	return w, nil
+}
+
+// Events returns the channel of changes. It is closed after Close.
+func (w *Watcher) Events() <-chan Event { return w.events }
+
+// Close stops watching and releases the backend.
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.be.close()
+	})
+	return err
+}
+
+// send is called by backends; it drops the event once the watcher is closed.
+func (w *Watcher) send(ev Event) {
+	select {
+	case w.raw <- ev:
+	case <-w.done:
+	}
+}
+
+// coalesce batches raw events for opts.Coalesce and drops modify events for
+// paths that already have a pending create or modify.
+func (w *Watcher) coalesce() {
+	defer close(w.events)
+	var (
+		pending []Event
+		timer   <-chan time.Time
+	)
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev := <-w.raw:
+			if ev.Op == OpModify && hasPending(pending, ev.Path) {
+				continue
+			}
+			pending = append(pending, ev)
+			if timer == nil {
+				timer = time.After(w.opts.Coalesce)
+			}
+		case <-timer:
+			for _, ev := range pending {
+				select {
+				case w.events <- ev:
+				case <-w.done:
+					return
+				}
+			}
+			pending, timer = pending[:0], nil
+		}
+	}
+}
+
+func hasPending(pending []Event, p string) bool {
+	for _, ev := range pending {
+		if ev.Path == p && (ev.Op == OpModify || ev.Op == OpCreate) {
+			return true
+		}
+	}
+	return false
+}
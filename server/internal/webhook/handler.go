@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/auth"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the webhook routes, to workspace owners only.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the webhook routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/webhooks", h.list)
+	mux.HandleFunc("POST /api/workspaces/{id}/webhooks", h.create)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/webhooks/{hook}", h.delete)
+	mux.HandleFunc("GET /api/workspaces/{id}/webhooks/{hook}/deliveries", h.deliveries)
+	mux.HandleFunc("POST /api/workspaces/{id}/webhooks/{hook}/ping", h.ping)
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := h.workspaces.Open(id); err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	if workspace.RoleFrom(r.Context()) != workspace.RoleOwner {
+		httpx.Error(w, http.StatusForbidden, "only workspace owners can manage webhooks")
+		return "", false
+	}
+	return id, true
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	hooks, err := h.svc.List(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"webhooks": hooks})
+}
+
+type createRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var req createRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var by string
+	if u := auth.UserFrom(r.Context()); u != nil {
+		by = u.ID
+	}
+	hook, err := h.svc.Create(id, req.URL, req.Events, by)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionWebhookCreate, Workspace: id, Target: hook.ID,
+		Details: map[string]string{"url": hook.URL}})
+	httpx.JSON(w, http.StatusCreated, hook)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	hook := r.PathValue("hook")
+	if err := h.svc.Delete(id, hook); err != nil {
+		writeError(w, err)
+		return
+	}
+	audit.Record(r.Context(), audit.Entry{Action: audit.ActionWebhookDelete, Workspace: id, Target: hook})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) deliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.svc.Deliveries(id, r.PathValue("hook"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"deliveries": list})
+}
+
+// ping queues a ping event for the hook; its outcome shows in the
+// deliveries.
+func (h *Handler) ping(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	var by string
+	if u := auth.UserFrom(r.Context()); u != nil {
+		by = u.ID
+	}
+	delivery, err := h.svc.Ping(id, r.PathValue("hook"), by)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusAccepted, map[string]any{"delivery": delivery})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooMany):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("webhooks", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "webhook request failed")
+	}
+}
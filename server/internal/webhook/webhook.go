@@ -0,0 +1,537 @@
+// Package webhook sends workspace events to external tools, such as chat
+// bots or grading systems. A workspace's owners subscribe URLs to the
+// events they want, and each event is POSTed to them as JSON, signed with
+// the hook's secret.
+//
+// A delivery that fails with a network error, 408, 429 or a 5xx status is
+// retried with exponential backoff, up to Config.Attempts times. Hooks are
+// kept in Config.Dir, one file per workspace, and the recent deliveries of
+// each in memory, for debugging a receiver.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+)
+
+// Config configures a Service.
+type Config struct {
+	// Dir holds the hooks, one file per workspace; defaults to a
+	// directory under the OS temp dir.
+	Dir string
+	// MaxHooks caps the hooks per workspace; defaults to 10.
+	MaxHooks int
+	// Attempts is how many times a delivery is tried; defaults to 6.
+	Attempts int
+	// Backoff is the wait before the first retry, doubled for each one
+	// after; defaults to 10 seconds, so six attempts span about five
+	// minutes.
+	Backoff time.Duration
+	// Timeout bounds each attempt; defaults to 10 seconds.
+	Timeout time.Duration
+	// Client sends the deliveries; defaults to a client that refuses
+	// loopback and link-local addresses, so hooks cannot reach the
+	// server itself or a cloud metadata service.
+	Client *http.Client
+}
+
+// Bus delivers events to subscribers, as events.Bus does.
+type Bus interface {
+	Subscribe(fn func(events.Event)) (cancel func())
+}
+
+// Ping is the type of the event Ping sends.
+const Ping = "ping"
+
+var (
+	// ErrNotFound is returned for hooks that do not exist.
+	ErrNotFound = errors.New("webhook: not found")
+	// ErrInvalid is returned for hooks with an invalid URL or event.
+	ErrInvalid = errors.New("webhook: invalid hook")
+	// ErrTooMany is returned when a workspace is at Config.MaxHooks.
+	ErrTooMany = errors.New("webhook: too many hooks")
+)
+
+// Hook subscribes a URL to the events of a workspace.
+type Hook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Events are the types sent; empty sends them all.
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	// Secret signs the deliveries. It is only returned when the hook is
+	// created.
+	Secret string `json:"secret,omitempty"`
+}
+
+// wants reports whether the hook is sent events of type typ.
+func (h *Hook) wants(typ string) bool {
+	return typ == Ping || len(h.Events) == 0 || slices.Contains(h.Events, typ)
+}
+
+// Delivery is one attempt to deliver an event.
+type Delivery struct {
+	// ID is the same for every attempt of one delivery.
+	ID      string    `json:"id"`
+	Event   string    `json:"event"`
+	Attempt int       `json:"attempt"`
+	Time    time.Time `json:"time"`
+	// Status is the receiver's, and Error why the attempt failed, if it
+	// did.
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+	// Retry reports that the delivery will be attempted again.
+	Retry bool `json:"retry,omitempty"`
+}
+
+// maxDeliveries is how many attempts are kept per hook.
+const maxDeliveries = 20
+
+// queueSize bounds the deliveries waiting for a worker; past it events
+// are dropped.
+const queueSize = 1024
+
+// workers is how many deliveries are sent at once.
+const workers = 4
+
+// job is a delivery of one event to one hook.
+type job struct {
+	id        string
+	workspace string
+	hook      Hook
+	event     string
+	body      []byte
+	attempt   int
+}
+
+// Service keeps the hooks of workspaces and delivers events to them.
+type Service struct {
+	cfg Config
+	now func() time.Time
+
+	mu         sync.Mutex
+	hooks      map[string][]Hook // workspace ID; loaded on first use
+	deliveries map[string][]Delivery
+
+	queue       chan *job
+	stop        chan struct{}
+	wg          sync.WaitGroup
+	unsubscribe func()
+}
+
+// New returns a Service delivering the events of bus, filling unset
+// Config fields with defaults.
+func New(cfg Config, bus Bus) (*Service, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "webide-webhooks")
+	}
+	if cfg.MaxHooks <= 0 {
+		cfg.MaxHooks = 10
+	}
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = 6
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 10 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// A redirect counts as the receiver's answer, a failed one.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("webhook: create dir: %w", err)
+	}
+	s := &Service{
+		cfg:        cfg,
+		now:        time.Now,
+		hooks:      make(map[string][]Hook),
+		deliveries: make(map[string][]Delivery),
+		queue:      make(chan *job, queueSize),
+		stop:       make(chan struct{}),
+	}
+	for range workers {
+		s.wg.Add(1)
+		go s.work()
+	}
+	s.unsubscribe = bus.Subscribe(s.receive)
+	return s, nil
+}
+
+// Close stops delivering events. Deliveries waiting for a retry are
+// dropped.
+func (s *Service) Close() {
+	s.unsubscribe()
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// errForbidden is why the dialer refused an address. Retrying will not
+// help.
+var errForbidden = errors.New("webhook: destination address not allowed")
+
+// dialer refuses loopback, link-local and unspecified addresses whatever
+// name they were reached by.
+var dialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	Control: func(_, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+			ip.IsUnspecified() || ip.IsMulticast() {
+			return errForbidden
+		}
+		return nil
+	},
+}
+
+// List returns the hooks of a workspace, oldest first, without their
+// secrets.
+func (s *Service) List(workspaceID string) ([]Hook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hooks, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Hook, len(hooks))
+	for i, h := range hooks {
+		h.Secret = ""
+		out[i] = h
+	}
+	return out, nil
+}
+
+// Create subscribes rawURL to the events of a workspace, to all of them
+// when types is empty. The returned Hook has its secret.
+func (s *Service) Create(workspaceID, rawURL string, types []string, createdBy string) (*Hook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an http or https URL", ErrInvalid)
+	}
+	for _, t := range types {
+		if !slices.Contains(events.Types, t) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalid, t)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hooks, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) >= s.cfg.MaxHooks {
+		return nil, fmt.Errorf("%w: %d hooks", ErrTooMany, len(hooks))
+	}
+	var secret [32]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return nil, err
+	}
+	h := Hook{
+		ID:        newID(),
+		URL:       u.String(),
+		Events:    slices.Compact(slices.Sorted(slices.Values(types))),
+		CreatedAt: s.now().UTC(),
+		CreatedBy: createdBy,
+		Secret:    hex.EncodeToString(secret[:]),
+	}
+	if h.Events == nil {
+		h.Events = []string{}
+	}
+	if err := s.saveLocked(workspaceID, append(slices.Clip(hooks), h)); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// Delete removes a hook. Its deliveries in progress are not retried.
+func (s *Service) Delete(workspaceID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hooks, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(hooks, func(h Hook) bool { return h.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	if err := s.saveLocked(workspaceID, slices.Delete(slices.Clone(hooks), i, i+1)); err != nil {
+		return err
+	}
+	delete(s.deliveries, id)
+	return nil
+}
+
+// Deliveries returns the recent delivery attempts of a hook, newest
+// first.
+func (s *Service) Deliveries(workspaceID, id string) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.hookLocked(workspaceID, id); err != nil {
+		return nil, err
+	}
+	out := slices.Clone(s.deliveries[id])
+	slices.Reverse(out)
+	if out == nil {
+		out = []Delivery{}
+	}
+	return out, nil
+}
+
+// Ping sends a hook a ping event, to check that its receiver works, and
+// returns the delivery's ID.
+func (s *Service) Ping(workspaceID, id, user string) (string, error) {
+	s.mu.Lock()
+	h, err := s.hookLocked(workspaceID, id)
+	s.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	e := events.Event{ID: newID(), Type: Ping, Time: s.now().UTC(), Workspace: workspaceID, User: user}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	j := &job{id: newID(), workspace: workspaceID, hook: h, event: Ping, body: body, attempt: 1}
+	s.enqueue(j)
+	return j.id, nil
+}
+
+// receive queues a delivery of e to each hook of its workspace that wants
+// it.
+func (s *Service) receive(e events.Event) {
+	if e.Workspace == "" {
+		return
+	}
+	s.mu.Lock()
+	hooks, err := s.loadLocked(e.Workspace)
+	s.mu.Unlock()
+	if err != nil {
+		slog.Error("webhook: load hooks", "workspace", e.Workspace, "err", err)
+		return
+	}
+	var body []byte
+	for _, h := range hooks {
+		if !h.wants(e.Type) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(e); err != nil {
+				return
+			}
+		}
+		s.enqueue(&job{id: newID(), workspace: e.Workspace, hook: h, event: e.Type, body: body, attempt: 1})
+	}
+}
+
+// enqueue hands j to a worker, dropping it when the queue is full.
+func (s *Service) enqueue(j *job) {
+	select {
+	case <-s.stop:
+	case s.queue <- j:
+	default:
+		slog.Warn("webhook: queue full, dropping delivery", "workspace", j.workspace, "hook", j.hook.ID, "event", j.event)
+	}
+}
+
+func (s *Service) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case j := <-s.queue:
+			s.deliver(j)
+		}
+	}
+}
+
+// deliver makes one attempt at j, and schedules the next if it failed
+// in a way that may pass.
+func (s *Service) deliver(j *job) {
+	start := s.now()
+	status, err := s.send(j, start)
+	d := Delivery{
+		ID:         j.id,
+		Event:      j.event,
+		Attempt:    j.attempt,
+		Time:       start.UTC(),
+		Status:     status,
+		DurationMS: s.now().Sub(start).Milliseconds(),
+	}
+	retry := err != nil && !errors.Is(err, errForbidden) ||
+		status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+	if err != nil {
+		d.Error = err.Error()
+	} else if status < 200 || status > 299 {
+		d.Error = http.StatusText(status)
+	}
+	d.Retry = d.Error != "" && retry && j.attempt < s.cfg.Attempts
+
+	s.mu.Lock()
+	_, err = s.hookLocked(j.workspace, j.hook.ID)
+	if err == nil {
+		list := append(s.deliveries[j.hook.ID], d)
+		if len(list) > maxDeliveries {
+			list = slices.Delete(list, 0, len(list)-maxDeliveries)
+		}
+		s.deliveries[j.hook.ID] = list
+	}
+	s.mu.Unlock()
+	if err != nil || !d.Retry {
+		return
+	}
+	wait := s.cfg.Backoff << (j.attempt - 1)
+	next := *j
+	next.attempt++
+	time.AfterFunc(wait, func() { s.enqueue(&next) })
+}
+
+// send POSTs j's event to its hook and returns the receiver's status.
+func (s *Service) send(j *job, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.hook.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "webide-webhook")
+	req.Header.Set("X-Webide-Event", j.event)
+	req.Header.Set("X-Webide-Delivery", j.id)
+	req.Header.Set("X-Webide-Timestamp", ts)
+	req.Header.Set("X-Webide-Signature", "sha256="+Sign(j.hook.Secret, ts, j.body))
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256, keyed with secret, of the timestamp,
+// a dot and the body: the signature of a delivery, which receivers
+// compute to check it.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) hookLocked(workspaceID, id string) (Hook, error) {
+	hooks, err := s.loadLocked(workspaceID)
+	if err != nil {
+		return Hook{}, err
+	}
+	for _, h := range hooks {
+		if h.ID == id {
+			return h, nil
+		}
+	}
+	return Hook{}, ErrNotFound
+}
+
+func (s *Service) path(workspaceID string) string {
+	return filepath.Join(s.cfg.Dir, workspaceID+".json")
+}
+
+// loadLocked returns the hooks of a workspace, reading them on first use.
+// s.mu must be held.
+func (s *Service) loadLocked(workspaceID string) ([]Hook, error) {
+	if hooks, ok := s.hooks[workspaceID]; ok {
+		return hooks, nil
+	}
+	var hooks []Hook
+	data, err := os.ReadFile(s.path(workspaceID))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("webhook: read hooks: %w", err)
+	default:
+		if err := json.Unmarshal(data, &hooks); err != nil {
+			return nil, fmt.Errorf("webhook: read hooks: %w", err)
+		}
+	}
+	s.hooks[workspaceID] = hooks
+	return hooks, nil
+}
+
+// saveLocked replaces the hooks of a workspace. s.mu must be held.
+func (s *Service) saveLocked(workspaceID string, hooks []Hook) error {
+	p := s.path(workspaceID)
+	if len(hooks) == 0 {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("webhook: write hooks: %w", err)
+		}
+		s.hooks[workspaceID] = nil
+		return nil
+	}
+	data, err := json.MarshalIndent(hooks, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("webhook: write hooks: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("webhook: write hooks: %w", err)
+	}
+	s.hooks[workspaceID] = hooks
+	return nil
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,129 @@
+package workspace
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/scaffold"
+)
+
+// Info describes a workspace in API responses.
+type Info struct {
+	ID string `json:"id"`
+	// Template and Files describe what a workspace created from a
+	// template was seeded with; Setup lists the commands to run before it
+	// builds.
+	Template string   `json:"template,omitempty"`
+	Files    []string `json:"files,omitempty"`
+	Setup    []string `json:"setup,omitempty"`
+}
+
+// Handler serves workspace creation and listing, and the project
+// templates workspaces can be created from.
+type Handler struct {
+	mgr *Manager
+}
+
+// NewHandler returns a Handler for mgr.
+func NewHandler(mgr *Manager) *Handler {
+	return &Handler{mgr: mgr}
+}
+
+// Register mounts the workspace routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces", h.list)
+	mux.HandleFunc("POST /api/workspaces", h.create)
+	mux.HandleFunc("GET /api/templates", h.templates)
+}
+
+func (h *Handler) templates(w http.ResponseWriter, r *http.Request) {
+	httpx.JSON(w, http.StatusOK, map[string]any{"templates": scaffold.Templates})
+}
+
+// list returns the workspaces. When the request carries an owner, only
+// theirs and those without an owner are listed.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.mgr.List()
+	if err != nil {
+		slog.Error("list workspaces", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not list workspaces")
+		return
+	}
+	user := OwnerFrom(r.Context())
+	out := make([]Info, 0, len(ids))
+	for _, id := range ids {
+		if user != "" {
+			if owner, err := h.mgr.Owner(id); err != nil || (owner != "" && owner != user) {
+				continue
+			}
+		}
+		out = append(out, Info{ID: id})
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"workspaces": out})
+}
+
+type createRequest struct {
+	ID string `json:"id,omitempty"`
+	// Module and GoVersion parameterize the template, if any.
+	Module    string `json:"module,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+// create makes a workspace, empty or generated from the template named by
+// the template query parameter. The body is optional; without an ID a
+// random one is assigned.
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.ID == "" {
+		req.ID = NewID()
+	}
+	var tmpl *scaffold.Template
+	if name := r.URL.Query().Get("template"); name != "" {
+		var err error
+		if tmpl, err = scaffold.Lookup(name); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Module == "" {
+			req.Module = "example.com/" + req.ID
+		}
+	}
+	dir, err := h.mgr.Create(r.Context(), req.ID)
+	switch {
+	case errors.Is(err, ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, ErrExists):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("create workspace", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not create workspace")
+		return
+	}
+	if tmpl == nil {
+		httpx.JSON(w, http.StatusCreated, Info{ID: req.ID})
+		return
+	}
+	files, err := scaffold.Generate(tmpl.Name, dir, scaffold.Params{Module: req.Module, GoVersion: req.GoVersion})
+	if err != nil {
+		os.RemoveAll(dir)
+		if errors.Is(err, scaffold.ErrInvalidParams) {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("generate workspace", "template", tmpl.Name, "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not create workspace")
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, Info{ID: req.ID, Template: tmpl.Name, Files: files, Setup: tmpl.Setup})
+}
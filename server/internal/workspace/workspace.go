@@ -0,0 +1,466 @@
+// Package workspace maps workspace IDs to their directories on disk. Every
+// subsystem that touches workspace files (LSP, files, terminal, runner)
+// resolves paths through a Manager so IDs are validated in one place.
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidID is returned for IDs that are not safe directory names.
+	ErrInvalidID = errors.New("workspace: invalid id")
+	// ErrNotFound is returned when a workspace directory does not exist.
+	ErrNotFound = errors.New("workspace: not found")
+	// ErrExists is returned by Create when the workspace already exists.
+	ErrExists = errors.New("workspace: already exists")
+)
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// ValidID reports whether id is an acceptable workspace ID.
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// Manager owns the directory that holds one subdirectory per workspace.
+type Manager struct {
+	root    string
+	remote  Remote
+	catalog Catalog
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// Remote keeps workspaces beyond this node, as *storage.Store does. With
+// one, a workspace missing from the local directory is hydrated from it
+// when it is opened, and the local copy is pushed back by Flush.
+type Remote interface {
+	// Has reports whether workspace id is stored.
+	Has(ctx context.Context, id string) (bool, error)
+	// Pull writes the files of workspace id into the empty directory dir
+	// and returns the metadata pushed with them.
+	Pull(ctx context.Context, id, dir string) ([]byte, error)
+	// Push stores the files at dir and meta as workspace id.
+	Push(ctx context.Context, id, dir string, meta []byte) error
+}
+
+// Catalog records what the Manager knows about each workspace in place of
+// its metadata files, so the records can live in a database several
+// servers share.
+type Catalog interface {
+	// Create records a new workspace, or returns ErrExists if id is
+	// recorded already.
+	Create(id string, rec Record) error
+	// Record returns what is recorded about workspace id, or ErrNotFound.
+	Record(id string) (Record, error)
+	SetOwner(id, owner string) error
+	Delete(id string) error
+}
+
+// hydrateTimeout bounds the download of a workspace by Open.
+const hydrateTimeout = 5 * time.Minute
+
+// NewManager returns a Manager rooted at root, creating it if necessary.
+func NewManager(root string) (*Manager, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("workspace: resolve root: %w", err)
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("workspace: create root: %w", err)
+	}
+	return &Manager{root: abs, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+// SetRemote has workspaces kept in r as well as on local disk. It must be
+// called before the Manager is used.
+func (m *Manager) SetRemote(r Remote) {
+	m.remote = r
+}
+
+// SetCatalog has workspace records kept in c rather than in metadata
+// files. It must be called before the Manager is used.
+func (m *Manager) SetCatalog(c Catalog) {
+	m.catalog = c
+}
+
+// Root returns the absolute directory containing all workspaces.
+func (m *Manager) Root() string { return m.root }
+
+// Path returns the directory for id without checking that it exists.
+func (m *Manager) Path(id string) (string, error) {
+	if !ValidID(id) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	return filepath.Join(m.root, id), nil
+}
+
+// Open returns the directory of an existing workspace. With a Remote, a
+// workspace stored there but not here is downloaded first.
+func (m *Manager) Open(id string) (string, error) {
+	dir, err := m.Path(id)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) && m.remote != nil {
+		if err = m.hydrate(id, dir); err == nil {
+			fi, err = os.Stat(dir)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !fi.IsDir()) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("workspace: stat %s: %w", id, err)
+	}
+	return dir, nil
+}
+
+// Create makes a new, empty workspace directory. When ctx carries an
+// owner (see WithOwner), the workspace is recorded as theirs.
+func (m *Manager) Create(ctx context.Context, id string) (string, error) {
+	dir, err := m.Path(id)
+	if err != nil {
+		return "", err
+	}
+	if m.remote != nil {
+		ok, err := m.remote.Has(ctx, id)
+		if err != nil {
+			return "", fmt.Errorf("workspace: create %s: %w", id, err)
+		}
+		if ok {
+			return "", fmt.Errorf("%w: %s", ErrExists, id)
+		}
+	}
+	rec := Record{Owner: OwnerFrom(ctx), CreatedAt: time.Now().UTC()}
+	if m.catalog != nil {
+		// The record claims the ID, even against other servers.
+		if err := m.catalog.Create(id, rec); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		if m.catalog != nil {
+			m.catalog.Delete(id)
+		}
+		if errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("%w: %s", ErrExists, id)
+		}
+		return "", fmt.Errorf("workspace: create %s: %w", id, err)
+	}
+	if m.catalog == nil {
+		if err := m.writeMeta(id, rec); err != nil {
+			os.Remove(dir)
+			return "", err
+		}
+	}
+	if err := m.Flush(ctx, id); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// lock serializes the hydration and pushes of one workspace.
+func (m *Manager) lock(id string) func() {
+	m.mu.Lock()
+	l, ok := m.locks[id]
+	if !ok {
+		l = new(sync.Mutex)
+		m.locks[id] = l
+	}
+	m.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// hydrate downloads workspace id from the Remote into dir. The files go
+// to a temporary directory renamed into place once complete, so a failed
+// download leaves nothing behind. It returns os.ErrNotExist when the
+// Remote does not have the workspace either.
+func (m *Manager) hydrate(id, dir string) error {
+	defer m.lock(id)()
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		// Hydrated while waiting for the lock.
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hydrateTimeout)
+	defer cancel()
+	ok, err := m.remote.Has(ctx, id)
+	if err != nil {
+		return fmt.Errorf("workspace: hydrate %s: %w", id, err)
+	}
+	if !ok {
+		return os.ErrNotExist
+	}
+	tmp, err := os.MkdirTemp(m.root, ".hydrate-"+id+"-")
+	if err != nil {
+		return fmt.Errorf("workspace: hydrate %s: %w", id, err)
+	}
+	defer os.RemoveAll(tmp)
+	start := time.Now()
+	data, err := m.remote.Pull(ctx, id, tmp)
+	if err != nil {
+		return fmt.Errorf("workspace: hydrate %s: %w", id, err)
+	}
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return fmt.Errorf("workspace: hydrate %s: %w", id, err)
+	}
+	if len(data) > 0 && m.catalog == nil {
+		if err := m.writeMetaData(id, data); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return fmt.Errorf("workspace: hydrate %s: %w", id, err)
+	}
+	slog.Info("workspace hydrated", "workspace", id, "took", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// Flush pushes the local copy of workspace id to the Remote, if there is
+// one.
+func (m *Manager) Flush(ctx context.Context, id string) error {
+	if m.remote == nil {
+		return nil
+	}
+	dir, err := m.Path(id)
+	if err != nil {
+		return err
+	}
+	defer m.lock(id)()
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	var data []byte
+	if m.catalog == nil {
+		data, err = os.ReadFile(m.metaPath(id))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("workspace: read metadata of %s: %w", id, err)
+		}
+	}
+	return m.remote.Push(ctx, id, dir, data)
+}
+
+type ownerKey struct{}
+
+// WithOwner returns a context under which Create records owner, an opaque
+// user or organization ID, as the owner of new workspaces.
+func WithOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+// OwnerFrom returns the owner set by WithOwner, or "".
+func OwnerFrom(ctx context.Context) string {
+	owner, _ := ctx.Value(ownerKey{}).(string)
+	return owner
+}
+
+// Role is a user's access level on a workspace.
+type Role string
+
+// Roles, from most to least access.
+const (
+	// RoleOwner may do everything, including managing members.
+	RoleOwner Role = "owner"
+	// RoleEditor may change files and run code.
+	RoleEditor Role = "editor"
+	// RoleViewer may only read.
+	RoleViewer Role = "viewer"
+)
+
+// MaxRole returns the role with more access.
+func MaxRole(a, b Role) Role {
+	rank := func(r Role) int {
+		switch r {
+		case RoleOwner:
+			return 3
+		case RoleEditor:
+			return 2
+		case RoleViewer:
+			return 1
+		}
+		return 0
+	}
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+type roleKey struct{}
+
+// WithRole returns a context recording the caller's role on the workspace
+// a request addresses.
+func WithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFrom returns the role set by WithRole. Without one, as when
+// authentication is off, everyone is an owner.
+func RoleFrom(ctx context.Context) Role {
+	if role, ok := ctx.Value(roleKey{}).(Role); ok {
+		return role
+	}
+	return RoleOwner
+}
+
+// Record is what the Manager records about a workspace. Without a Catalog
+// it lives in a directory whose name is not a valid ID, so it is outside
+// every workspace and never listed as one.
+type Record struct {
+	Owner     string    `json:"owner,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+const metaDir = ".meta"
+
+// Owner returns the owner of workspace id, or "" for workspaces created
+// without one.
+func (m *Manager) Owner(id string) (string, error) {
+	md, err := m.readMeta(id)
+	if err != nil {
+		return "", err
+	}
+	return md.Owner, nil
+}
+
+// SetOwner hands workspace id to a new owner.
+func (m *Manager) SetOwner(id, owner string) error {
+	if _, err := m.Open(id); err != nil {
+		return err
+	}
+	if m.catalog != nil {
+		if err := m.catalog.SetOwner(id, owner); err != nil {
+			return err
+		}
+		return m.Flush(context.Background(), id)
+	}
+	md, err := m.readMeta(id)
+	if err != nil {
+		return err
+	}
+	if md.CreatedAt.IsZero() {
+		md.CreatedAt = time.Now().UTC()
+	}
+	md.Owner = owner
+	if err := m.writeMeta(id, md); err != nil {
+		return err
+	}
+	return m.Flush(context.Background(), id)
+}
+
+// readMeta returns what is recorded about workspace id; workspaces from
+// before metadata was kept have none.
+func (m *Manager) readMeta(id string) (Record, error) {
+	var md Record
+	if !ValidID(id) {
+		return md, fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	if m.catalog != nil {
+		// Workspaces from before the Catalog keep their metadata file.
+		rec, err := m.catalog.Record(id)
+		if !errors.Is(err, ErrNotFound) {
+			return rec, err
+		}
+	}
+	data, err := os.ReadFile(m.metaPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return md, nil
+	}
+	if err != nil {
+		return md, fmt.Errorf("workspace: read metadata of %s: %w", id, err)
+	}
+	if err := json.Unmarshal(data, &md); err != nil {
+		return md, fmt.Errorf("workspace: read metadata of %s: %w", id, err)
+	}
+	return md, nil
+}
+
+func (m *Manager) metaPath(id string) string {
+	return filepath.Join(m.root, metaDir, id+".json")
+}
+
+func (m *Manager) writeMeta(id string, md Record) error {
+	data, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	return m.writeMetaData(id, data)
+}
+
+func (m *Manager) writeMetaData(id string, data []byte) error {
+	dir := filepath.Join(m.root, metaDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("workspace: write metadata of %s: %w", id, err)
+	}
+	tmp := filepath.Join(dir, "."+id+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("workspace: write metadata of %s: %w", id, err)
+	}
+	if err := os.Rename(tmp, m.metaPath(id)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("workspace: write metadata of %s: %w", id, err)
+	}
+	return nil
+}
+
+// Ensure returns the directory for id, creating it if it does not exist
+// here or in the Remote.
+func (m *Manager) Ensure(id string) (string, error) {
+	if m.remote != nil {
+		if dir, err := m.Open(id); err == nil {
+			return dir, nil
+		}
+	}
+	dir, err := m.Path(id)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("workspace: create %s: %w", id, err)
+	}
+	return dir, nil
+}
+
+// List returns the IDs of all workspaces on this node, sorted. With a
+// Remote, workspaces stored there and not yet opened here are left out.
+func (m *Manager) List() ([]string, error) {
+	des, err := os.ReadDir(m.root)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(des))
+	for _, de := range des {
+		if de.IsDir() && ValidID(de.Name()) {
+			ids = append(ids, de.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// NewID returns a random workspace ID.
+func NewID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return "ws-" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,48 @@
+package ws
+
+import "sync"
+
+// Conns is a set of open server connections. The zero value is empty and
+// ready to use.
+type Conns struct {
+	mu  sync.Mutex
+	set map[*Conn]struct{}
+}
+
+func (cs *Conns) add(c *Conn) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.set == nil {
+		cs.set = make(map[*Conn]struct{})
+	}
+	cs.set[c] = struct{}{}
+}
+
+func (cs *Conns) remove(c *Conn) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	delete(cs.set, c)
+}
+
+// Len returns the number of open connections.
+func (cs *Conns) Len() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return len(cs.set)
+}
+
+// CloseAll closes every open connection with code and reason, and
+// returns how many there were. The handlers serving them see their reads
+// fail, as if the clients had gone.
+func (cs *Conns) CloseAll(code int, reason string) int {
+	cs.mu.Lock()
+	conns := make([]*Conn, 0, len(cs.set))
+	for c := range cs.set {
+		conns = append(conns, c)
+	}
+	cs.mu.Unlock()
+	for _, c := range conns {
+		c.CloseWithCode(code, reason)
+	}
+	return len(conns)
+}
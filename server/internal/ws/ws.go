@@ -0,0 +1,577 @@
+// Package ws is a small RFC 6455 WebSocket implementation covering what the
+// IDE's streaming endpoints need: the server handshake, the client one for
+// the CLI, text and binary messages with fragmentation, ping/pong, and the
+// close handshake.
+package ws
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/metrics"
+)
+
+// MessageType is the opcode of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close codes used by the IDE.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+	CloseServiceRestart  = 1012
+	CloseTryAgainLater   = 1013
+	DefaultReadLimit     = 1 << 20
+	handshakeGUID        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxControlPayload    = 125
+	closeHandshakeWindow = 2 * time.Second
+	// closeNoStatus is the code of a CloseError for a close frame without
+	// one.
+	closeNoStatus = 1005
+)
+
+// ErrMessageTooBig is returned when an incoming message exceeds the read limit.
+var ErrMessageTooBig = errors.New("ws: message exceeds read limit")
+
+// CloseError is returned by ReadMessage once the peer has closed the connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("ws: closed with code %d %q", e.Code, e.Reason)
+}
+
+// Options configures Upgrade.
+type Options struct {
+	// CheckOrigin reports whether the request origin is acceptable. When nil,
+	// cross-origin requests are rejected unless the Origin host matches Host.
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols lists supported subprotocols in order of preference.
+	Subprotocols []string
+	// ReadLimit caps the size of a single incoming message.
+	ReadLimit int64
+	// Metrics, when set, counts the connections open and accepted, by the
+	// pattern of the route that upgraded them.
+	Metrics *metrics.Registry
+	// Conns, when set, keeps the open connections, so they can be closed
+	// together when the server shuts down.
+	Conns *Conns
+}
+
+// Conn is a WebSocket connection. Writes are safe for concurrent use; reads
+// must happen from a single goroutine.
+type Conn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	isClient  bool
+	readLimit int64
+	protocol  string
+
+	wmu       sync.Mutex
+	closeSent bool
+
+	closeOnce sync.Once
+	onClose   []func() // run on the first Close
+}
+
+// IsUpgrade reports whether r asks for a WebSocket upgrade.
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade performs the server side of the opening handshake. On failure it
+// writes an HTTP error response and returns a non-nil error.
+func Upgrade(w http.ResponseWriter, r *http.Request, opts *Options) (*Conn, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	fail := func(status int, msg string) (*Conn, error) {
+		http.Error(w, msg, status)
+		return nil, errors.New("ws: " + msg)
+	}
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "websocket upgrade requires GET")
+	}
+	if !IsUpgrade(r) {
+		return fail(http.StatusBadRequest, "not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return fail(http.StatusBadRequest, "missing Sec-WebSocket-Key")
+	}
+	check := opts.CheckOrigin
+	if check == nil {
+		check = sameOrigin
+	}
+	if !check(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	protocol := selectProtocol(r, opts.Subprotocols)
+	rc := http.NewResponseController(w)
+	netConn, brw, err := rc.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "connection does not support hijacking")
+	}
+
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if protocol != "" {
+		resp.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	resp.WriteString("\r\n")
+	if _, err := netConn.Write([]byte(resp.String())); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("ws: write handshake: %w", err)
+	}
+	// Hijack clears any deadlines set by the server.
+	netConn.SetDeadline(time.Time{})
+	c := newConn(netConn, brw.Reader, false, opts.ReadLimit, protocol)
+	if opts.Metrics != nil {
+		open := opts.Metrics.Gauge("webide_websocket_connections", "WebSocket connections open, by route.", "route")
+		opts.Metrics.Counter("webide_websocket_connections_total", "WebSocket connections accepted, by route.", "route").Inc(r.Pattern)
+		open.Inc(r.Pattern)
+		c.onClose = append(c.onClose, func() { open.Dec(r.Pattern) })
+	}
+	if opts.Conns != nil {
+		opts.Conns.add(c)
+		c.onClose = append(c.onClose, func() { opts.Conns.remove(c) })
+	}
+	return c, nil
+}
+
+// Dial performs the client side of the opening handshake with the ws:// or
+// wss:// URL u, sending header with the request. A server refusing the
+// upgrade yields a *DialError.
+func Dial(ctx context.Context, u string, header http.Header) (*Conn, error) {
+	target, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("ws: %w", err)
+	}
+	var dialer interface {
+		DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	} = &net.Dialer{}
+	port := "80"
+	switch target.Scheme {
+	case "ws":
+		target.Scheme = "http"
+	case "wss":
+		target.Scheme = "https"
+		port = "443"
+		dialer = &tls.Dialer{Config: &tls.Config{ServerName: target.Hostname()}}
+	default:
+		return nil, fmt.Errorf("ws: unsupported scheme %q", target.Scheme)
+	}
+	addr := target.Host
+	if target.Port() == "" {
+		addr = net.JoinHostPort(target.Hostname(), port)
+	}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ws: dial: %w", err)
+	}
+	if d, ok := ctx.Deadline(); ok {
+		netConn.SetDeadline(d)
+	}
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := &http.Request{Method: http.MethodGet, URL: target, Host: target.Host, Header: make(http.Header)}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("ws: write handshake: %w", err)
+	}
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("ws: read handshake: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		netConn.Close()
+		return nil, &DialError{StatusCode: resp.StatusCode, Body: body}
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		netConn.Close()
+		return nil, errors.New("ws: bad Sec-WebSocket-Accept")
+	}
+	netConn.SetDeadline(time.Time{})
+	return newConn(netConn, br, true, 0, resp.Header.Get("Sec-WebSocket-Protocol")), nil
+}
+
+// DialError is returned by Dial when the server answers the handshake
+// with a status other than 101, whose body, or its start, is Body.
+type DialError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *DialError) Error() string {
+	return fmt.Sprintf("ws: handshake: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func newConn(c net.Conn, br *bufio.Reader, isClient bool, limit int64, protocol string) *Conn {
+	if br == nil {
+		br = bufio.NewReader(c)
+	}
+	if limit <= 0 {
+		limit = DefaultReadLimit
+	}
+	return &Conn{conn: c, br: br, isClient: isClient, readLimit: limit, protocol: protocol}
+}
+
+// Subprotocol returns the negotiated subprotocol, if any.
+func (c *Conn) Subprotocol() string { return c.protocol }
+
+// RemoteAddr returns the peer's network address.
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// SetReadLimit changes the maximum incoming message size.
+func (c *Conn) SetReadLimit(n int64) { c.readLimit = n }
+
+// SetReadDeadline sets the deadline for future reads.
+func (c *Conn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+// ReadMessage returns the next data message. Ping frames are answered
+// automatically; a close frame is acknowledged and surfaces as *CloseError.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		msgType MessageType
+		buf     []byte
+	)
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			// A close without a code is answered with a normal one, as
+			// 1005 stands for no code and may not be sent.
+			ce, reply := &CloseError{Code: closeNoStatus}, CloseNormal
+			switch {
+			case len(payload) == 1:
+				return 0, nil, c.protocolError("truncated close code")
+			case len(payload) >= 2:
+				ce.Code = int(binary.BigEndian.Uint16(payload))
+				ce.Reason = string(payload[2:])
+				if !validCloseCode(ce.Code) {
+					return 0, nil, c.protocolError(fmt.Sprintf("invalid close code %d", ce.Code))
+				}
+				reply = ce.Code
+			}
+			c.writeClose(reply, "")
+			c.conn.Close()
+			return 0, nil, ce
+		case opText, opBinary:
+			if msgType != 0 {
+				return 0, nil, c.protocolError("new message before previous one finished")
+			}
+			msgType = MessageType(op)
+		case opContinuation:
+			if msgType == 0 {
+				return 0, nil, c.protocolError("unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.protocolError(fmt.Sprintf("unknown opcode %d", op))
+		}
+		if int64(len(buf)+len(payload)) > c.readLimit {
+			c.CloseWithCode(CloseMessageTooBig, "message too big")
+			return 0, nil, ErrMessageTooBig
+		}
+		buf = append(buf, payload...)
+		if fin {
+			return msgType, buf, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it into v.
+func (c *Conn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends a single unfragmented data message.
+func (c *Conn) WriteMessage(t MessageType, data []byte) error {
+	return c.writeFrame(byte(t), data)
+}
+
+// WriteJSON encodes v and sends it as a text message.
+func (c *Conn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Ping sends a ping control frame.
+func (c *Conn) Ping(data []byte) error {
+	return c.writeFrame(opPing, data)
+}
+
+// CloseWithCode starts the close handshake and closes the underlying connection.
+func (c *Conn) CloseWithCode(code int, reason string) error {
+	c.writeClose(code, reason)
+	c.closeOnce.Do(func() {
+		for _, fn := range c.onClose {
+			fn()
+		}
+	})
+	return c.conn.Close()
+}
+
+// Close closes the connection with a normal closure code.
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormal, "")
+}
+
+func (c *Conn) writeClose(code int, reason string) {
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return
+	}
+	c.closeSent = true
+	c.conn.SetWriteDeadline(time.Now().Add(closeHandshakeWindow))
+	c.writeFrameLocked(opClose, payload)
+}
+
+func (c *Conn) protocolError(msg string) error {
+	c.CloseWithCode(CloseProtocolError, msg)
+	return errors.New("ws: protocol error: " + msg)
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	return c.writeFrameLocked(op, payload)
+}
+
+func (c *Conn) writeFrameLocked(op byte, payload []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | op
+	n := len(payload)
+	switch {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if c.isClient {
+		header[1] |= 0x80
+		var mask [4]byte
+		rand.Read(mask[:])
+		header = append(header, mask[:]...)
+		masked := make([]byte, n)
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+func (c *Conn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	fin = h[0]&0x80 != 0
+	if h[0]&0x70 != 0 {
+		err = c.protocolError("reserved bits set")
+		return
+	}
+	op = h[0] & 0x0F
+	masked := h[1]&0x80 != 0
+	if masked == c.isClient {
+		err = c.protocolError("bad frame masking")
+		return
+	}
+	length := int64(h[1] & 0x7F)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(b[:]))
+	}
+	if length < 0 {
+		err = c.protocolError("invalid frame length")
+		return
+	}
+	if op >= opClose && (length > maxControlPayload || !fin) {
+		err = c.protocolError("invalid control frame")
+		return
+	}
+	if length > c.readLimit {
+		c.CloseWithCode(CloseMessageTooBig, "message too big")
+		err = ErrMessageTooBig
+		return
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// validCloseCode reports whether a peer may send code in a close frame:
+// one RFC 6455 defines, or one registered or private from 3000 to 4999.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < 1000 || code > 1014:
+		return false
+	}
+	return code != 1004 && code != closeNoStatus && code != 1006
+}
+
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + handshakeGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+func selectProtocol(r *http.Request, supported []string) string {
+	for _, want := range supported {
+		for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+			for _, p := range strings.Split(v, ",") {
+				if strings.TrimSpace(p) == want {
+					return want
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	_, rest, ok := strings.Cut(origin, "://")
+	return ok && strings.EqualFold(rest, r.Host)
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AllowOrigins returns a CheckOrigin func accepting same-origin requests and
+// any Origin listed in origins. A "*" entry accepts every origin.
+func AllowOrigins(origins []string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return sameOrigin(r)
+	}
+}
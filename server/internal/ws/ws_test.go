@@ -0,0 +1,469 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455, section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey = %q", got)
+	}
+}
+
+// echoServer serves a WebSocket endpoint echoing every message.
+func echoServer(t *testing.T, opts *Options) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, opts)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			typ, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := c.WriteMessage(typ, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestDialEcho(t *testing.T) {
+	srv := echoServer(t, &Options{Subprotocols: []string{"v2", "v1"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, wsURL(srv), http.Header{"Sec-WebSocket-Protocol": {"v1, v2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Subprotocol() != "v2" {
+		t.Errorf("subprotocol = %q, want the server's preferred v2", c.Subprotocol())
+	}
+	for _, msg := range []struct {
+		typ  MessageType
+		data []byte
+	}{
+		{TextMessage, []byte("hello")},
+		{BinaryMessage, []byte{0, 1, 2, 255}},
+		{TextMessage, []byte{}},
+		// Lengths taking the 16 and 64 bit forms.
+		{BinaryMessage, bytes.Repeat([]byte("x"), 126)},
+		{BinaryMessage, bytes.Repeat([]byte("y"), 70000)},
+	} {
+		if err := c.WriteMessage(msg.typ, msg.data); err != nil {
+			t.Fatal(err)
+		}
+		typ, data, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != msg.typ || !bytes.Equal(data, msg.data) {
+			t.Errorf("echo of %d bytes = type %d, %d bytes", len(msg.data), typ, len(data))
+		}
+	}
+}
+
+func TestDialRefused(t *testing.T) {
+	srv := echoServer(t, &Options{CheckOrigin: func(*http.Request) bool { return false }})
+	_, err := Dial(context.Background(), wsURL(srv), nil)
+	var de *DialError
+	if !errors.As(err, &de) || de.StatusCode != http.StatusForbidden {
+		t.Errorf("Dial refused by origin = %v, want a 403 DialError", err)
+	}
+}
+
+func TestUpgradeHandshake(t *testing.T) {
+	srv := echoServer(t, nil)
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req := "GET / HTTP/1.1\r\nHost: " + srv.Listener.Addr().String() + "\r\n" +
+		"Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	if resp.Header.Get("Sec-WebSocket-Protocol") != "" {
+		t.Error("a subprotocol was chosen though none was offered")
+	}
+}
+
+func TestUpgradeRejects(t *testing.T) {
+	good := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://ide.example/ws", nil)
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		return r
+	}
+	tests := []struct {
+		name   string
+		change func(r *http.Request)
+		status int
+	}{
+		{"post", func(r *http.Request) { r.Method = http.MethodPost }, http.StatusMethodNotAllowed},
+		{"no upgrade", func(r *http.Request) { r.Header.Del("Upgrade") }, http.StatusBadRequest},
+		{"no connection upgrade", func(r *http.Request) { r.Header.Set("Connection", "keep-alive") }, http.StatusBadRequest},
+		{"old version", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, http.StatusUpgradeRequired},
+		{"no key", func(r *http.Request) { r.Header.Del("Sec-WebSocket-Key") }, http.StatusBadRequest},
+		{"cross origin", func(r *http.Request) { r.Header.Set("Origin", "https://evil.example") }, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := good()
+		tt.change(r)
+		w := httptest.NewRecorder()
+		if _, err := Upgrade(w, r, nil); err == nil {
+			t.Errorf("%s: Upgrade succeeded", tt.name)
+		}
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestAllowOrigins(t *testing.T) {
+	check := AllowOrigins([]string{"https://app.example", "HTTPS://Other.example"})
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://ide.example", true},
+		{"https://IDE.example", true},
+		{"https://app.example", true},
+		{"https://other.example", true},
+		{"https://evil.example", false},
+		{"https://app.example.evil", false},
+		{"https://ide.example.evil", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://ide.example/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := check(r); got != tt.want {
+			t.Errorf("origin %q: allowed = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://ide.example/ws", nil)
+	r.Header.Set("Origin", "https://anything.example")
+	if !AllowOrigins([]string{"*"})(r) {
+		t.Error(`AllowOrigins("*") refused an origin`)
+	}
+}
+
+// pair returns a Conn and the raw connection of its peer, over loopback
+// TCP so that writes on either side do not wait for the other to read.
+func pair(t *testing.T, isClient bool) (*Conn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+	raw, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := <-accepted
+	if nc == nil {
+		t.Fatal("accept failed")
+	}
+	c := newConn(nc, nil, isClient, 0, "")
+	t.Cleanup(func() {
+		c.conn.Close()
+		raw.Close()
+	})
+	raw.SetDeadline(time.Now().Add(5 * time.Second))
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+	return c, raw
+}
+
+// frame encodes a frame as a peer would send it, masked with a fixed key
+// when mask is set.
+func frame(fin bool, op byte, payload []byte, mask bool) []byte {
+	b := []byte{op, 0}
+	if fin {
+		b[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		b[1] = byte(n)
+	case n <= 0xFFFF:
+		b[1] = 126
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b[1] = 127
+		b = binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	if !mask {
+		return append(b, payload...)
+	}
+	b[1] |= 0x80
+	key := []byte{1, 2, 3, 4}
+	b = append(b, key...)
+	for i, c := range payload {
+		b = append(b, c^key[i%4])
+	}
+	return b
+}
+
+// readRaw reads an unmasked frame from the server at raw.
+func readRaw(t *testing.T, raw net.Conn) (op byte, payload []byte) {
+	t.Helper()
+	c := newConn(raw, nil, true, 0, "")
+	fin, op, payload, err := c.readFrame()
+	if err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if !fin {
+		t.Fatal("server sent a fragment")
+	}
+	return op, payload
+}
+
+func closeCode(t *testing.T, raw net.Conn) int {
+	t.Helper()
+	op, payload := readRaw(t, raw)
+	if op != opClose || len(payload) < 2 {
+		t.Fatalf("frame = op %d %q, want a close with a code", op, payload)
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func TestReadMasked(t *testing.T) {
+	c, raw := pair(t, false)
+	raw.Write(frame(true, opText, []byte("masked"), true))
+	if _, data, err := c.ReadMessage(); err != nil || string(data) != "masked" {
+		t.Fatalf("ReadMessage = %q, %v", data, err)
+	}
+
+	raw.Write(frame(true, opText, []byte("plain"), false))
+	if _, _, err := c.ReadMessage(); err == nil {
+		t.Fatal("server accepted an unmasked frame")
+	}
+	if code := closeCode(t, raw); code != CloseProtocolError {
+		t.Errorf("close code = %d, want %d", code, CloseProtocolError)
+	}
+}
+
+func TestClientReadUnmasked(t *testing.T) {
+	c, raw := pair(t, true)
+	raw.Write(frame(true, opBinary, []byte{7}, false))
+	if _, data, err := c.ReadMessage(); err != nil || !bytes.Equal(data, []byte{7}) {
+		t.Fatalf("ReadMessage = %v, %v", data, err)
+	}
+	raw.Write(frame(true, opBinary, []byte{7}, true))
+	if _, _, err := c.ReadMessage(); err == nil {
+		t.Fatal("client accepted a masked frame")
+	}
+}
+
+func TestClientWritesMasked(t *testing.T) {
+	c, raw := pair(t, true)
+	if err := c.WriteMessage(TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	server := newConn(raw, nil, false, 0, "")
+	fin, op, payload, err := server.readFrame()
+	if err != nil || !fin || op != opText || string(payload) != "hi" {
+		t.Errorf("client frame = %v %d %q %v", fin, op, payload, err)
+	}
+}
+
+func TestFragmentation(t *testing.T) {
+	c, raw := pair(t, false)
+	var b []byte
+	b = append(b, frame(false, opText, []byte("Hel"), true)...)
+	b = append(b, frame(true, opPing, []byte("p1"), true)...)
+	b = append(b, frame(false, opContinuation, []byte("lo, "), true)...)
+	b = append(b, frame(true, opPong, []byte("ignored"), true)...)
+	b = append(b, frame(true, opContinuation, []byte("world"), true)...)
+	raw.Write(b)
+	typ, data, err := c.ReadMessage()
+	if err != nil || typ != TextMessage || string(data) != "Hello, world" {
+		t.Fatalf("ReadMessage = %d %q %v, want the joined fragments", typ, data, err)
+	}
+	if op, payload := readRaw(t, raw); op != opPong || string(payload) != "p1" {
+		t.Errorf("answer to the ping = op %d %q, want a pong p1", op, payload)
+	}
+}
+
+func TestFragmentationErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"continuation first", [][]byte{frame(true, opContinuation, []byte("x"), true)}},
+		{"message inside a message", [][]byte{frame(false, opText, []byte("a"), true), frame(true, opBinary, []byte("b"), true)}},
+		{"fragmented ping", [][]byte{frame(false, opPing, []byte("a"), true)}},
+		{"long ping", [][]byte{frame(true, opPing, bytes.Repeat([]byte("a"), 126), true)}},
+		{"reserved bits", [][]byte{append([]byte{0x80 | 0x40 | opText}, frame(true, opText, nil, true)[1:]...)}},
+		{"unknown opcode", [][]byte{frame(true, 0x3, nil, true)}},
+	}
+	for _, tt := range tests {
+		c, raw := pair(t, false)
+		for _, f := range tt.frames {
+			raw.Write(f)
+		}
+		if _, _, err := c.ReadMessage(); err == nil {
+			t.Errorf("%s: ReadMessage succeeded", tt.name)
+			continue
+		}
+		if code := closeCode(t, raw); code != CloseProtocolError {
+			t.Errorf("%s: close code = %d, want %d", tt.name, code, CloseProtocolError)
+		}
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	c, raw := pair(t, false)
+	c.SetReadLimit(10)
+	raw.Write(frame(true, opBinary, bytes.Repeat([]byte("a"), 10), true))
+	if _, data, err := c.ReadMessage(); err != nil || len(data) != 10 {
+		t.Fatalf("message at the limit: %d bytes, %v", len(data), err)
+	}
+	raw.Write(frame(true, opBinary, bytes.Repeat([]byte("a"), 11), true))
+	if _, _, err := c.ReadMessage(); !errors.Is(err, ErrMessageTooBig) {
+		t.Fatalf("frame over the limit: err = %v, want ErrMessageTooBig", err)
+	}
+	if code := closeCode(t, raw); code != CloseMessageTooBig {
+		t.Errorf("close code = %d, want %d", code, CloseMessageTooBig)
+	}
+
+	// Fragments within the limit that together exceed it.
+	c, raw = pair(t, false)
+	c.SetReadLimit(10)
+	raw.Write(append(frame(false, opText, []byte("123456"), true), frame(true, opContinuation, []byte("789012"), true)...))
+	if _, _, err := c.ReadMessage(); !errors.Is(err, ErrMessageTooBig) {
+		t.Fatalf("fragments over the limit: err = %v, want ErrMessageTooBig", err)
+	}
+	if code := closeCode(t, raw); code != CloseMessageTooBig {
+		t.Errorf("close code = %d, want %d", code, CloseMessageTooBig)
+	}
+}
+
+func TestHugeLength(t *testing.T) {
+	c, raw := pair(t, false)
+	// A 64-bit length with its most significant bit set.
+	b := []byte{0x80 | opBinary, 0x80 | 127}
+	b = binary.BigEndian.AppendUint64(b, 1<<63|5)
+	raw.Write(append(b, 1, 2, 3, 4))
+	if _, _, err := c.ReadMessage(); err == nil {
+		t.Fatal("ReadMessage accepted a negative length")
+	}
+	if code := closeCode(t, raw); code != CloseProtocolError {
+		t.Errorf("close code = %d, want %d", code, CloseProtocolError)
+	}
+}
+
+func TestPeerClose(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    CloseError
+		reply   int
+	}{
+		{"with code", append(binary.BigEndian.AppendUint16(nil, CloseGoingAway), "bye"...), CloseError{CloseGoingAway, "bye"}, CloseGoingAway},
+		{"private code", binary.BigEndian.AppendUint16(nil, 4000), CloseError{4000, ""}, 4000},
+		{"without code", nil, CloseError{closeNoStatus, ""}, CloseNormal},
+	}
+	for _, tt := range tests {
+		c, raw := pair(t, false)
+		raw.Write(frame(true, opClose, tt.payload, true))
+		_, _, err := c.ReadMessage()
+		var ce *CloseError
+		if !errors.As(err, &ce) || *ce != tt.want {
+			t.Errorf("%s: ReadMessage = %v, want %v", tt.name, err, &tt.want)
+			continue
+		}
+		if code := closeCode(t, raw); code != tt.reply {
+			t.Errorf("%s: reply code = %d, want %d", tt.name, code, tt.reply)
+		}
+		if err := c.WriteMessage(TextMessage, []byte("late")); err == nil {
+			t.Errorf("%s: write after close succeeded", tt.name)
+		}
+	}
+}
+
+func TestPeerCloseInvalid(t *testing.T) {
+	for _, payload := range [][]byte{
+		{0x03},
+		binary.BigEndian.AppendUint16(nil, 999),
+		binary.BigEndian.AppendUint16(nil, closeNoStatus),
+		binary.BigEndian.AppendUint16(nil, 1006),
+		binary.BigEndian.AppendUint16(nil, 1015),
+		binary.BigEndian.AppendUint16(nil, 5000),
+	} {
+		c, raw := pair(t, false)
+		raw.Write(frame(true, opClose, payload, true))
+		_, _, err := c.ReadMessage()
+		var ce *CloseError
+		if err == nil || errors.As(err, &ce) {
+			t.Errorf("close payload %v: ReadMessage = %v, want a protocol error", payload, err)
+			continue
+		}
+		if code := closeCode(t, raw); code != CloseProtocolError {
+			t.Errorf("close payload %v: reply code = %d, want %d", payload, code, CloseProtocolError)
+		}
+	}
+}
+
+func TestCloseWithCode(t *testing.T) {
+	c, raw := pair(t, false)
+	closed := 0
+	c.onClose = append(c.onClose, func() { closed++ })
+	c.CloseWithCode(CloseTryAgainLater, strings.Repeat("r", 200))
+	c.Close()
+	op, payload := readRaw(t, raw)
+	if op != opClose || int(binary.BigEndian.Uint16(payload)) != CloseTryAgainLater {
+		t.Fatalf("close frame = op %d %q", op, payload)
+	}
+	if len(payload) > maxControlPayload {
+		t.Errorf("close payload of %d bytes exceeds a control frame", len(payload))
+	}
+	if closed != 1 {
+		t.Errorf("close hooks ran %d times, want once", closed)
+	}
+}
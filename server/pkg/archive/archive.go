@@ -0,0 +1,455 @@
+// Package archive moves workspaces in and out of the IDE as tar.gz and
+// zip archives. Exports are streamed and honor .gitignore-style
+// exclusions; imports are bounded in size and file count, and every entry
+// name is validated so an archive cannot write outside its destination.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Format is an archive format.
+type Format string
+
+const (
+	TarGz Format = "tar.gz"
+	Zip   Format = "zip"
+)
+
+var (
+	// ErrInvalidFormat is returned for unknown formats and for archives
+	// that are neither gzip-compressed tar nor zip.
+	ErrInvalidFormat = errors.New("archive: unsupported format")
+	// ErrInvalidArchive is returned for corrupt archives and entries with
+	// unsafe names.
+	ErrInvalidArchive = errors.New("archive: invalid archive")
+	// ErrTooLarge is returned when a tree or archive exceeds its Limits.
+	ErrTooLarge = errors.New("archive: too large")
+)
+
+// Limits bound exports and imports.
+type Limits struct {
+	// MaxBytes caps the total size of the files; defaults to 256 MiB.
+	MaxBytes int64
+	// MaxFiles caps the number of entries; defaults to 20000.
+	MaxFiles int
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = 256 << 20
+	}
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = 20000
+	}
+	return l
+}
+
+// ParseFormat returns the format named s: tar.gz (or tgz) or zip.
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "tar.gz", "tgz":
+		return TarGz, nil
+	case "zip":
+		return Zip, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+}
+
+// entry is one file or directory to export.
+type entry struct {
+	rel string
+	abs string
+	fi  fs.FileInfo
+}
+
+// Plan lists what an export of root will contain.
+type Plan struct {
+	entries []entry
+	// Files and Bytes count the regular files and their total size.
+	Files int
+	Bytes int64
+}
+
+// NewPlan walks root and collects the directories and regular files not
+// matched by ignore. Symlinks and in-flight atomic writes are skipped.
+// The walk fails with ErrTooLarge as soon as limits are exceeded.
+func NewPlan(root string, ignore *Ignore, limits Limits) (*Plan, error) {
+	limits = limits.withDefaults()
+	p := &Plan{}
+	err := filepath.WalkDir(root, func(abs string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if abs == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(de.Name(), files.TempPrefix) || ignore.Match(rel, de.IsDir()) {
+			if de.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !de.IsDir() && !de.Type().IsRegular() {
+			return nil
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return err
+		}
+		if !de.IsDir() {
+			p.Files++
+			p.Bytes += fi.Size()
+		}
+		p.entries = append(p.entries, entry{rel: rel, abs: abs, fi: fi})
+		switch {
+		case len(p.entries) > limits.MaxFiles:
+			return fmt.Errorf("%w: more than %d files", ErrTooLarge, limits.MaxFiles)
+		case p.Bytes > limits.MaxBytes:
+			return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, limits.MaxBytes)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrTooLarge) {
+		err = fmt.Errorf("archive: walk: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Write streams the planned tree to w in format f, under the directory
+// prefix inside the archive when it is not empty.
+func (p *Plan) Write(w io.Writer, f Format, prefix string) error {
+	switch f {
+	case TarGz:
+		return p.writeTar(w, prefix)
+	case Zip:
+		return p.writeZip(w, prefix)
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidFormat, f)
+}
+
+func (p *Plan) writeTar(w io.Writer, prefix string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, e := range p.entries {
+		hdr, err := tar.FileInfoHeader(e.fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, e.rel)
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if e.fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !e.fi.IsDir() {
+			if err := copyFile(tw, e.abs, e.fi.Size()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (p *Plan) writeZip(w io.Writer, prefix string) error {
+	zw := zip.NewWriter(w)
+	for _, e := range p.entries {
+		hdr, err := zip.FileInfoHeader(e.fi)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, e.rel)
+		if e.fi.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if !e.fi.IsDir() {
+			if err := copyFile(fw, e.abs, e.fi.Size()); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// Copy writes the planned tree into dir, as when forking a workspace.
+// Only the executable bit of file modes is kept, as by Extract.
+func (p *Plan) Copy(dir string) error {
+	for _, e := range p.entries {
+		dst := filepath.Join(dir, filepath.FromSlash(e.rel))
+		if e.fi.IsDir() {
+			if err := os.MkdirAll(dst, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		perm := fs.FileMode(0o644)
+		if e.fi.Mode()&0o111 != 0 {
+			perm = 0o755
+		}
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+		if err != nil {
+			return err
+		}
+		err = copyFile(f, e.abs, e.fi.Size())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile writes exactly size bytes of the file at abs, so a file that
+// changes during the export cannot corrupt the archive.
+func copyFile(w io.Writer, abs string, size int64) error {
+	f, err := os.Open(abs)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(w, io.LimitReader(f, size))
+	if err != nil {
+		return err
+	}
+	if n < size {
+		_, err = io.CopyN(w, zeros{}, size-n)
+	}
+	return err
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Extract unpacks the archive read from r into dir, detecting the format
+// from its first bytes. When every entry lies below one top-level
+// directory, as in GitHub tarballs, that directory is stripped. Symlinks
+// and other special entries are skipped, and only the executable bit of
+// file modes is kept. It returns the number of files written.
+func Extract(r io.Reader, dir string, limits Limits) (int, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	var next func() (*entryReader, error)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		next = tarEntries(tar.NewReader(gz))
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		// zip needs random access, so the upload is spooled to disk.
+		tmp, err := os.CreateTemp("", "webide-import-*.zip")
+		if err != nil {
+			return 0, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		size, err := io.Copy(tmp, br)
+		if err != nil {
+			return 0, err
+		}
+		zr, err := zip.NewReader(tmp, size)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		next = zipEntries(zr)
+	default:
+		return 0, ErrInvalidFormat
+	}
+
+	// Whether there is a top-level directory to strip is only known after
+	// the last entry, so entries are staged next to dir first.
+	stage, err := os.MkdirTemp(filepath.Dir(dir), ".import-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(stage)
+	x := &extractor{dir: stage, limits: limits.withDefaults(), tops: make(map[string]bool)}
+	for {
+		e, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		if err := x.add(e); err != nil {
+			return 0, err
+		}
+	}
+	src := stage
+	if top := x.top(); top != "" {
+		src = filepath.Join(stage, top)
+	}
+	des, err := os.ReadDir(src)
+	if err != nil {
+		return 0, err
+	}
+	for _, de := range des {
+		if err := os.Rename(filepath.Join(src, de.Name()), filepath.Join(dir, de.Name())); err != nil {
+			return 0, err
+		}
+	}
+	return x.files, nil
+}
+
+// entryReader is one archive entry; open is nil for anything other than
+// a regular file.
+type entryReader struct {
+	name string
+	mode fs.FileMode
+	open func() (io.ReadCloser, error)
+}
+
+func tarEntries(tr *tar.Reader) func() (*entryReader, error) {
+	return func() (*entryReader, error) {
+		hdr, err := tr.Next()
+		if err != nil {
+			return nil, err
+		}
+		e := &entryReader{name: hdr.Name, mode: hdr.FileInfo().Mode()}
+		if hdr.Typeflag == tar.TypeReg {
+			e.open = func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		}
+		return e, nil
+	}
+}
+
+func zipEntries(zr *zip.Reader) func() (*entryReader, error) {
+	i := 0
+	return func() (*entryReader, error) {
+		if i == len(zr.File) {
+			return nil, io.EOF
+		}
+		f := zr.File[i]
+		i++
+		e := &entryReader{name: f.Name, mode: f.Mode()}
+		if e.mode.IsRegular() {
+			e.open = f.Open
+		}
+		return e, nil
+	}
+}
+
+// extractor writes validated entries below dir.
+type extractor struct {
+	dir    string
+	limits Limits
+	// entries counts files and directories against the limit; files
+	// counts the files written.
+	entries int
+	files   int
+	bytes   int64
+	// tops records the top-level names seen, and whether each is only a
+	// directory.
+	tops map[string]bool
+}
+
+func (x *extractor) add(e *entryReader) error {
+	rel, err := files.Clean(e.name)
+	if err != nil || path.IsAbs(e.name) {
+		return fmt.Errorf("%w: unsafe entry name %q", ErrInvalidArchive, e.name)
+	}
+	isDir := e.mode.IsDir()
+	if rel == "" {
+		if isDir {
+			return nil
+		}
+		return fmt.Errorf("%w: unsafe entry name %q", ErrInvalidArchive, e.name)
+	}
+	if !isDir && e.open == nil {
+		return nil
+	}
+	if x.entries++; x.entries > x.limits.MaxFiles {
+		return fmt.Errorf("%w: more than %d files", ErrTooLarge, x.limits.MaxFiles)
+	}
+	top, rest, _ := strings.Cut(rel, "/")
+	onlyDir, seen := x.tops[top]
+	x.tops[top] = (!seen || onlyDir) && (isDir || rest != "")
+
+	dst := filepath.Join(x.dir, filepath.FromSlash(rel))
+	if isDir {
+		return os.MkdirAll(dst, 0o755)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	perm := fs.FileMode(0o644)
+	if e.mode&0o111 != 0 {
+		perm = 0o755
+	}
+	src, err := e.open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer src.Close()
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(src, x.limits.MaxBytes-x.bytes+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	if x.bytes += n; x.bytes > x.limits.MaxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, x.limits.MaxBytes)
+	}
+	x.files++
+	return nil
+}
+
+// top returns the single top-level directory holding every entry, if
+// there is one.
+func (x *extractor) top() string {
+	if len(x.tops) != 1 {
+		return ""
+	}
+	for top, onlyDir := range x.tops {
+		if onlyDir {
+			return top
+		}
+	}
+	return ""
+}
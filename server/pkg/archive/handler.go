@@ -0,0 +1,176 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/artifact"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/workspace"
+)
+
+// DefaultMaxUpload caps the compressed size of an imported archive.
+const DefaultMaxUpload = 64 << 20
+
+// Workspaces resolves and creates workspace directories.
+type Workspaces interface {
+	Open(id string) (string, error)
+	Create(ctx context.Context, id string) (string, error)
+}
+
+// Artifacts stores exported archives; *artifact.Store implements it.
+type Artifacts interface {
+	Put(ctx context.Context, a artifact.Artifact, ttl time.Duration, r io.Reader) (*artifact.Artifact, error)
+}
+
+// Handler serves workspace export and import.
+type Handler struct {
+	workspaces Workspaces
+	artifacts  Artifacts
+	limits     Limits
+	maxUpload  int64
+}
+
+// NewHandler returns a Handler for the workspaces of ws, storing exports
+// in artifacts. Unset limits get their defaults.
+func NewHandler(ws Workspaces, artifacts Artifacts, limits Limits) *Handler {
+	return &Handler{workspaces: ws, artifacts: artifacts, limits: limits.withDefaults(), maxUpload: DefaultMaxUpload}
+}
+
+// Register mounts the archive routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/export", h.export)
+	mux.HandleFunc("POST /api/workspaces/{id}/export", h.storeExport)
+	mux.HandleFunc("POST /api/workspaces/import", h.importArchive)
+}
+
+// export streams the workspace as an archive whose entries sit below a
+// directory named after the workspace. .git and the patterns of the root
+// .gitignore are left out unless all=1; exclude adds patterns.
+func (h *Handler) export(w http.ResponseWriter, r *http.Request) {
+	id, format, plan, ok := h.plan(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+"."+string(format)))
+	w.Header().Set("X-Archive-Files", fmt.Sprint(plan.Files))
+	if err := plan.Write(w, format, id); err != nil {
+		// The response has started; all that is left is to cut it short.
+		slog.Warn("export workspace", "workspace", id, "err", err)
+	}
+}
+
+// storeExport writes the archive export would stream into an artifact,
+// to download later or hand out by its URL.
+func (h *Handler) storeExport(w http.ResponseWriter, r *http.Request) {
+	id, format, plan, ok := h.plan(w, r)
+	if !ok {
+		return
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(plan.Write(pw, format, id))
+	}()
+	a, err := h.artifacts.Put(r.Context(), artifact.Artifact{
+		Kind:        artifact.KindArchive,
+		Name:        id + "." + string(format),
+		ContentType: contentTypes[format],
+		Workspace:   id,
+		Labels:      map[string]string{"format": string(format), "files": fmt.Sprint(plan.Files)},
+	}, 0, pr)
+	// Stop the writer if Put gave up first.
+	pr.CloseWithError(errors.New("archive: export abandoned"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, a)
+}
+
+var contentTypes = map[Format]string{TarGz: "application/gzip", Zip: "application/zip"}
+
+// plan resolves the workspace, format and file selection of an export
+// request, reporting errors to w.
+func (h *Handler) plan(w http.ResponseWriter, r *http.Request) (string, Format, *Plan, bool) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", nil, false
+	}
+	q := r.URL.Query()
+	format := TarGz
+	if v := q.Get("format"); v != "" {
+		if format, err = ParseFormat(v); err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return "", "", nil, false
+		}
+	}
+	ignore := &Ignore{}
+	if q.Get("all") != "1" {
+		gitignore, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+		ignore = ParseIgnore(".git/\n" + string(gitignore))
+	}
+	for _, p := range q["exclude"] {
+		ignore.Add(p)
+	}
+	plan, err := NewPlan(dir, ignore, h.limits)
+	if err != nil {
+		writeError(w, err)
+		return "", "", nil, false
+	}
+	return id, format, plan, true
+}
+
+// importArchive creates a workspace from the tar.gz or zip archive in the
+// request body. The workspace ID comes from the id query parameter or is
+// assigned at random.
+func (h *Handler) importArchive(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		id = workspace.NewID()
+	}
+	dir, err := h.workspaces.Create(r.Context(), id)
+	switch {
+	case errors.Is(err, workspace.ErrInvalidID):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, workspace.ErrExists):
+		httpx.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("create workspace", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "could not create workspace")
+		return
+	}
+	n, err := Extract(http.MaxBytesReader(w, r.Body, h.maxUpload), dir, h.limits)
+	if err != nil {
+		os.RemoveAll(dir)
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, map[string]any{"id": id, "files": n})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		httpx.Errorf(w, http.StatusRequestEntityTooLarge, "archive exceeds %d bytes", tooLarge.Limit)
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrInvalidFormat), errors.Is(err, ErrInvalidArchive):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("archive workspace", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "archive operation failed")
+	}
+}
@@ -0,0 +1,121 @@
+package archive
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Ignore matches paths against .gitignore-style patterns. Later patterns
+// take precedence over earlier ones, "!" negates a pattern, a trailing
+// "/" restricts it to directories, and a pattern containing another "/"
+// is anchored at the root; "*", "?", "[...]" and "**" behave as in git.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ParseIgnore returns the patterns of a .gitignore file. Blank lines and
+// comments are skipped.
+func ParseIgnore(text string) *Ignore {
+	ig := &Ignore{}
+	for _, line := range strings.Split(text, "\n") {
+		ig.Add(strings.TrimSuffix(line, "\r"))
+	}
+	return ig
+}
+
+// Add appends one pattern.
+func (ig *Ignore) Add(pattern string) {
+	if !strings.HasSuffix(pattern, `\ `) {
+		pattern = strings.TrimRight(pattern, " ")
+	}
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return
+	}
+	var r ignoreRule
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	expr := globRegexp(pattern)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return
+	}
+	r.re = re
+	ig.rules = append(ig.rules, r)
+}
+
+// Match reports whether the slash-separated path p, relative to the root,
+// is ignored. Callers walking a tree skip ignored directories, so paths
+// below them need not be matched.
+func (ig *Ignore) Match(p string, isDir bool) bool {
+	if ig == nil {
+		return false
+	}
+	ignored := false
+	for _, r := range ig.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(p) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// globRegexp translates a gitignore glob into a regular expression.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case glob[i:] == "**":
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
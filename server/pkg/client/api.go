@@ -0,0 +1,8226 @@
+// Code generated by webide-openapi; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// OpenapiServe returns the OpenAPI definition of the API.
+//
+//	GET /api/openapi.json
+func (c *Client) OpenapiServe(ctx context.Context) (any, error) {
+	var out any
+	resp, err := c.send(ctx, http.MethodGet, "/api/openapi.json", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// ApiversionList returns the versions, oldest first, and the latest one's
+// name.
+//
+//	GET /api/versions
+func (c *Client) ApiversionList(ctx context.Context) (*ApiversionListResponse, error) {
+	var out ApiversionListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/versions", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuthSignup calls POST /api/auth/signup.
+func (c *Client) AuthSignup(ctx context.Context, body *AuthCredentials) (json.RawMessage, error) {
+	var out json.RawMessage
+	resp, err := c.send(ctx, http.MethodPost, "/api/auth/signup", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// AuthLogin calls POST /api/auth/login.
+func (c *Client) AuthLogin(ctx context.Context, body *AuthCredentials) (json.RawMessage, error) {
+	var out json.RawMessage
+	resp, err := c.send(ctx, http.MethodPost, "/api/auth/login", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// AuthRefresh rotates a session. The refresh token comes from the body or, in
+// cookie mode, from the refresh cookie, in which case the new tokens are set
+// as cookies too.
+//
+//	POST /api/auth/refresh
+func (c *Client) AuthRefresh(ctx context.Context, body *AuthRefreshRequest) (json.RawMessage, error) {
+	var out json.RawMessage
+	resp, err := c.send(ctx, http.MethodPost, "/api/auth/refresh", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// AuthLogout calls POST /api/auth/logout.
+func (c *Client) AuthLogout(ctx context.Context, body *AuthRefreshRequest) error {
+	resp, err := c.send(ctx, http.MethodPost, "/api/auth/logout", nil, nil, body)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AuthMe calls GET /api/auth/me.
+func (c *Client) AuthMe(ctx context.Context) (*AuthMeResponse, error) {
+	var out AuthMeResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/auth/me", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuthProviders calls GET /api/auth/providers.
+func (c *Client) AuthProviders(ctx context.Context) (*AuthProvidersResponse, error) {
+	var out AuthProvidersResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/auth/providers", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuthOauthStart sends the browser to the provider. With link=1 the signed-in
+// user links the provider account instead of signing in; redirect is the local
+// path the browser returns to.
+//
+//	GET /api/auth/oauth/{provider}
+func (c *Client) AuthOauthStart(ctx context.Context, provider string, params *AuthOauthStartParams) error {
+	q, h := params.values()
+	resp, err := c.send(ctx, http.MethodGet, "/api/auth/oauth/"+url.PathEscape(provider), q, h, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AuthOauthCallback completes the flow, sets the session cookies and sends the
+// browser back to where it started.
+//
+//	GET /api/auth/oauth/{provider}/callback
+func (c *Client) AuthOauthCallback(ctx context.Context, provider string, params *AuthOauthCallbackParams) error {
+	q, h := params.values()
+	resp, err := c.send(ctx, http.MethodGet, "/api/auth/oauth/"+url.PathEscape(provider)+"/callback", q, h, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AuthIdentities calls GET /api/auth/identities.
+func (c *Client) AuthIdentities(ctx context.Context) (*AuthIdentitiesResponse, error) {
+	var out AuthIdentitiesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/auth/identities", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuthUnlink calls DELETE /api/auth/identities/{provider}.
+func (c *Client) AuthUnlink(ctx context.Context, provider string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/auth/identities/"+url.PathEscape(provider), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AuthListTokens calls GET /api/auth/tokens.
+func (c *Client) AuthListTokens(ctx context.Context) (*AuthListTokensResponse, error) {
+	var out AuthListTokensResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/auth/tokens", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuthCreateToken issues a personal access token. The response is the only
+// time the token itself is shown.
+//
+//	POST /api/auth/tokens
+func (c *Client) AuthCreateToken(ctx context.Context, body *AuthCreateTokenRequest) (*AuthCreateTokenResponse, error) {
+	var out AuthCreateTokenResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/auth/tokens", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuthRevokeToken calls DELETE /api/auth/tokens/{id}.
+func (c *Client) AuthRevokeToken(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/auth/tokens/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AuditQuery returns the newest matching entries, up to limit.
+//
+//	GET /api/admin/audit
+func (c *Client) AuditQuery(ctx context.Context, params *AuditQueryParams) (*AuditQueryResponse, error) {
+	q, h := params.values()
+	var out AuditQueryResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/audit", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuditExport streams every matching entry, oldest first, as JSON lines.
+//
+//	GET /api/admin/audit/export
+//
+// The caller must close the response's body.
+func (c *Client) AuditExport(ctx context.Context, params *AuditExportParams) (*http.Response, error) {
+	q, h := params.values()
+	return c.send(ctx, http.MethodGet, "/api/admin/audit/export", q, h, nil)
+}
+
+// AdminUsers calls GET /api/admin/users.
+func (c *Client) AdminUsers(ctx context.Context) (*AdminUsersResponse, error) {
+	var out AdminUsersResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/users", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminWorkspaces calls GET /api/admin/workspaces.
+func (c *Client) AdminWorkspaces(ctx context.Context) (*AdminWorkspacesResponse, error) {
+	var out AdminWorkspacesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/workspaces", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminStopWorkspace calls POST /api/admin/workspaces/{id}/stop.
+func (c *Client) AdminStopWorkspace(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodPost, "/api/admin/workspaces/"+url.PathEscape(id)+"/stop", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AdminSandboxes calls GET /api/admin/sandboxes.
+func (c *Client) AdminSandboxes(ctx context.Context) (*AdminSandboxesResponse, error) {
+	var out AdminSandboxesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/sandboxes", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminStopSandbox calls POST /api/admin/sandboxes/{id}/stop.
+func (c *Client) AdminStopSandbox(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodPost, "/api/admin/sandboxes/"+url.PathEscape(id)+"/stop", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AdminBroadcast calls POST /api/admin/notices.
+func (c *Client) AdminBroadcast(ctx context.Context, body *AdminNoticeRequest) (*AdminNotice, error) {
+	var out AdminNotice
+	resp, err := c.send(ctx, http.MethodPost, "/api/admin/notices", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminClearNotice calls DELETE /api/admin/notices.
+func (c *Client) AdminClearNotice(ctx context.Context) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/admin/notices", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AdminQuota reports a user's usage this month and the limits that apply.
+//
+//	GET /api/admin/users/{id}/quota
+func (c *Client) AdminQuota(ctx context.Context, id string) (*QuotaReport, error) {
+	var out QuotaReport
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/users/"+url.PathEscape(id)+"/quota", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminSetQuota gives a user limits of their own. Fields left out or zero keep
+// the server's limit, and negative ones disable it.
+//
+//	PUT /api/admin/users/{id}/quota
+func (c *Client) AdminSetQuota(ctx context.Context, id string, body *QuotaLimits) (*QuotaReport, error) {
+	var out QuotaReport
+	resp, err := c.send(ctx, http.MethodPut, "/api/admin/users/"+url.PathEscape(id)+"/quota", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminResetQuota returns a user to the server's limits.
+//
+//	DELETE /api/admin/users/{id}/quota
+func (c *Client) AdminResetQuota(ctx context.Context, id string) (*QuotaReport, error) {
+	var out QuotaReport
+	resp, err := c.send(ctx, http.MethodDelete, "/api/admin/users/"+url.PathEscape(id)+"/quota", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminNotice returns {"notice":...}, with null when there is none.
+//
+//	GET /api/notices
+func (c *Client) AdminNotice(ctx context.Context) (*AdminNoticeResponse, error) {
+	var out AdminNoticeResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/notices", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ConfigGet calls GET /api/admin/config.
+func (c *Client) ConfigGet(ctx context.Context) (*ConfigResponse, error) {
+	var out ConfigResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/config", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ConfigReload reads the file again, as SIGHUP does.
+//
+//	POST /api/admin/config/reload
+func (c *Client) ConfigReload(ctx context.Context) (*ConfigResult, error) {
+	var out ConfigResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/admin/config/reload", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FlagsMine reports which flags are on for the current user, for clients that
+// show or hide features.
+//
+//	GET /api/flags
+func (c *Client) FlagsMine(ctx context.Context) (*FlagsMineResponse, error) {
+	var out FlagsMineResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/flags", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FlagsList calls GET /api/admin/flags.
+func (c *Client) FlagsList(ctx context.Context) (*FlagsListResponse, error) {
+	var out FlagsListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/flags", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FlagsGet calls GET /api/admin/flags/{name}.
+func (c *Client) FlagsGet(ctx context.Context, name string) (*FlagsFlag, error) {
+	var out FlagsFlag
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/flags/"+url.PathEscape(name), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FlagsPut calls PUT /api/admin/flags/{name}.
+func (c *Client) FlagsPut(ctx context.Context, name string, body *FlagsRule) (*FlagsFlag, error) {
+	var out FlagsFlag
+	resp, err := c.send(ctx, http.MethodPut, "/api/admin/flags/"+url.PathEscape(name), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FlagsDelete returns a flag to the server's default.
+//
+//	DELETE /api/admin/flags/{name}
+func (c *Client) FlagsDelete(ctx context.Context, name string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/admin/flags/"+url.PathEscape(name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// FlagsCheck reports whether a flag is on for ?user=, an ID, and why; without
+// it, for a request that is not signed in.
+//
+//	GET /api/admin/flags/{name}/check
+func (c *Client) FlagsCheck(ctx context.Context, name string, params *FlagsCheckParams) (*FlagsCheckResponse, error) {
+	q, h := params.values()
+	var out FlagsCheckResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/flags/"+url.PathEscape(name)+"/check", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ModproxyServe calls GET /goproxy/{path}.
+//
+// The caller must close the response's body.
+func (c *Client) ModproxyServe(ctx context.Context, path string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, "/goproxy/"+escapePath(path), nil, nil, nil)
+}
+
+// AccessShared lists the workspaces other users shared with the caller.
+//
+//	GET /api/workspaces/shared
+func (c *Client) AccessShared(ctx context.Context) (*AccessSharedResponse, error) {
+	var out AccessSharedResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/shared", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessMembers calls GET /api/workspaces/{id}/members.
+func (c *Client) AccessMembers(ctx context.Context, id string) (*AccessMembersResponse, error) {
+	var out AccessMembersResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/members", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessSetRole calls PUT /api/workspaces/{id}/members/{user}.
+func (c *Client) AccessSetRole(ctx context.Context, id, user string, body *AccessRoleRequest) (*AccessMember, error) {
+	var out AccessMember
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/members/"+url.PathEscape(user), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessRemove takes a member off the workspace. Owners remove anyone; members
+// may remove themselves to leave.
+//
+//	DELETE /api/workspaces/{id}/members/{user}
+func (c *Client) AccessRemove(ctx context.Context, id, user string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/members/"+url.PathEscape(user), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AccessInvitations calls GET /api/workspaces/{id}/invitations.
+func (c *Client) AccessInvitations(ctx context.Context, id string) (*AccessInvitationsResponse, error) {
+	var out AccessInvitationsResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/invitations", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessInvite creates an invitation. The response carries its token, which
+// the owner passes on, for example as a link to the IDE, and which is shown
+// only once.
+//
+//	POST /api/workspaces/{id}/invitations
+func (c *Client) AccessInvite(ctx context.Context, id string, body *AccessInviteRequest) (*AccessInviteResponse, error) {
+	var out AccessInviteResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/invitations", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessRevoke calls DELETE /api/workspaces/{id}/invitations/{inv}.
+func (c *Client) AccessRevoke(ctx context.Context, id, inv string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/invitations/"+url.PathEscape(inv), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AccessAccept calls POST /api/invitations/{token}/accept.
+func (c *Client) AccessAccept(ctx context.Context, token string) (*AccessMember, error) {
+	var out AccessMember
+	resp, err := c.send(ctx, http.MethodPost, "/api/invitations/"+url.PathEscape(token)+"/accept", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessLinks calls GET /api/workspaces/{id}/share-links.
+func (c *Client) AccessLinks(ctx context.Context, id string) (*AccessLinksResponse, error) {
+	var out AccessLinksResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/share-links", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessCreateLink makes a share link. Like an invitation's, its token is
+// shown only in this response.
+//
+//	POST /api/workspaces/{id}/share-links
+func (c *Client) AccessCreateLink(ctx context.Context, id string, body *AccessLinkRequest) (*AccessCreateLinkResponse, error) {
+	var out AccessCreateLinkResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/share-links", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessRevokeLink calls DELETE /api/workspaces/{id}/share-links/{link}.
+func (c *Client) AccessRevokeLink(ctx context.Context, id, link string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/share-links/"+url.PathEscape(link), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AccessResolveLink tells a guest what a share link opens. It is public, like
+// the sessions the link admits to.
+//
+//	GET /api/share/{token}
+func (c *Client) AccessResolveLink(ctx context.Context, token string) (*AccessResolveLinkResponse, error) {
+	var out AccessResolveLinkResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/share/"+url.PathEscape(token), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EnvvarsList calls GET /api/workspaces/{id}/env.
+func (c *Client) EnvvarsList(ctx context.Context, id string) (*EnvvarsListResponse, error) {
+	var out EnvvarsListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/env", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EnvvarsSet calls PUT /api/workspaces/{id}/env/{name}.
+func (c *Client) EnvvarsSet(ctx context.Context, id, name string, body *EnvvarsSetRequest) (*EnvvarsVariable, error) {
+	var out EnvvarsVariable
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/env/"+url.PathEscape(name), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EnvvarsDelete calls DELETE /api/workspaces/{id}/env/{name}.
+func (c *Client) EnvvarsDelete(ctx context.Context, id, name string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/env/"+url.PathEscape(name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// EnvvarsExport returns the variables as a .env file, secrets left out.
+//
+//	GET /api/workspaces/{id}/env/dotenv
+//
+// The caller must close the response's body.
+func (c *Client) EnvvarsExport(ctx context.Context, id string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/env/dotenv", nil, nil, nil)
+}
+
+// EnvvarsImportDotenv sets the variables of the .env file in the body, as
+// secrets with ?secret=1.
+//
+//	POST /api/workspaces/{id}/env/dotenv
+func (c *Client) EnvvarsImportDotenv(ctx context.Context, id string, body io.Reader, params *EnvvarsImportDotenvParams) (*EnvvarsImportDotenvResponse, error) {
+	q, h := params.values()
+	var out EnvvarsImportDotenvResponse
+	resp, err := c.do(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/env/dotenv", q, h, body, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SecretsListWorkspace returns a workspace's secrets and, as inherited, those
+// of the organization that owns it.
+//
+//	GET /api/workspaces/{id}/secrets
+func (c *Client) SecretsListWorkspace(ctx context.Context, id string) (*SecretsListWorkspaceResponse, error) {
+	var out SecretsListWorkspaceResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/secrets", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SecretsSetWorkspace calls PUT /api/workspaces/{id}/secrets/{name}.
+func (c *Client) SecretsSetWorkspace(ctx context.Context, id, name string, body *SecretsSetRequest) (*SecretsSecret, error) {
+	var out SecretsSecret
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/secrets/"+url.PathEscape(name), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SecretsDeleteWorkspace calls DELETE /api/workspaces/{id}/secrets/{name}.
+func (c *Client) SecretsDeleteWorkspace(ctx context.Context, id, name string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/secrets/"+url.PathEscape(name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// SecretsAuditWorkspace serves a workspace's audit log to its owners.
+//
+//	GET /api/workspaces/{id}/secrets/audit
+func (c *Client) SecretsAuditWorkspace(ctx context.Context, id string, params *SecretsAuditWorkspaceParams) (*SecretsAuditWorkspaceResponse, error) {
+	q, h := params.values()
+	var out SecretsAuditWorkspaceResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/secrets/audit", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SecretsListOrg calls GET /api/orgs/{org}/secrets.
+func (c *Client) SecretsListOrg(ctx context.Context, org string) (*SecretsListOrgResponse, error) {
+	var out SecretsListOrgResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/secrets", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SecretsSetOrg calls PUT /api/orgs/{org}/secrets/{name}.
+func (c *Client) SecretsSetOrg(ctx context.Context, org, name string, body *SecretsSetRequest) (*SecretsSecret, error) {
+	var out SecretsSecret
+	resp, err := c.send(ctx, http.MethodPut, "/api/orgs/"+url.PathEscape(org)+"/secrets/"+url.PathEscape(name), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SecretsDeleteOrg calls DELETE /api/orgs/{org}/secrets/{name}.
+func (c *Client) SecretsDeleteOrg(ctx context.Context, org, name string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/orgs/"+url.PathEscape(org)+"/secrets/"+url.PathEscape(name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// SecretsAuditOrg calls GET /api/orgs/{org}/secrets/audit.
+func (c *Client) SecretsAuditOrg(ctx context.Context, org string, params *SecretsAuditOrgParams) (*SecretsAuditOrgResponse, error) {
+	q, h := params.values()
+	var out SecretsAuditOrgResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/secrets/audit", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WebhookList calls GET /api/workspaces/{id}/webhooks.
+func (c *Client) WebhookList(ctx context.Context, id string) (*WebhookListResponse, error) {
+	var out WebhookListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/webhooks", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WebhookCreate calls POST /api/workspaces/{id}/webhooks.
+func (c *Client) WebhookCreate(ctx context.Context, id string, body *WebhookCreateRequest) (*WebhookHook, error) {
+	var out WebhookHook
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/webhooks", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WebhookDelete calls DELETE /api/workspaces/{id}/webhooks/{hook}.
+func (c *Client) WebhookDelete(ctx context.Context, id, hook string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/webhooks/"+url.PathEscape(hook), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// WebhookDeliveries calls GET /api/workspaces/{id}/webhooks/{hook}/deliveries.
+func (c *Client) WebhookDeliveries(ctx context.Context, id, hook string) (*WebhookDeliveriesResponse, error) {
+	var out WebhookDeliveriesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/webhooks/"+url.PathEscape(hook)+"/deliveries", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WebhookPing queues a ping event for the hook; its outcome shows in the
+// deliveries.
+//
+//	POST /api/workspaces/{id}/webhooks/{hook}/ping
+func (c *Client) WebhookPing(ctx context.Context, id, hook string) (*WebhookPingResponse, error) {
+	var out WebhookPingResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/webhooks/"+url.PathEscape(hook)+"/ping", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgList calls GET /api/orgs.
+func (c *Client) OrgList(ctx context.Context) (*OrgListResponse, error) {
+	var out OrgListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgCreate calls POST /api/orgs.
+func (c *Client) OrgCreate(ctx context.Context, body *OrgCreateRequest) (*Org, error) {
+	var out Org
+	resp, err := c.send(ctx, http.MethodPost, "/api/orgs", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgGet returns an organization with the caller's role, its quota and what it
+// uses of it.
+//
+//	GET /api/orgs/{org}
+func (c *Client) OrgGet(ctx context.Context, org string) (*OrgGetResponse, error) {
+	var out OrgGetResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgUpdate calls PUT /api/orgs/{org}.
+func (c *Client) OrgUpdate(ctx context.Context, org string, body *OrgUpdateRequest) (*Org, error) {
+	var out Org
+	resp, err := c.send(ctx, http.MethodPut, "/api/orgs/"+url.PathEscape(org), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgDelete calls DELETE /api/orgs/{org}.
+func (c *Client) OrgDelete(ctx context.Context, org string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/orgs/"+url.PathEscape(org), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// OrgMembers calls GET /api/orgs/{org}/members.
+func (c *Client) OrgMembers(ctx context.Context, org string) (*OrgMembersResponse, error) {
+	var out OrgMembersResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/members", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgAddMember adds an existing account, found by its email address.
+//
+//	POST /api/orgs/{org}/members
+func (c *Client) OrgAddMember(ctx context.Context, org string, body *OrgMemberRequest) (*OrgMember, error) {
+	var out OrgMember
+	resp, err := c.send(ctx, http.MethodPost, "/api/orgs/"+url.PathEscape(org)+"/members", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgSetMemberRole calls PUT /api/orgs/{org}/members/{user}.
+func (c *Client) OrgSetMemberRole(ctx context.Context, org, user string, body *OrgMemberRequest) (*OrgMember, error) {
+	var out OrgMember
+	resp, err := c.send(ctx, http.MethodPut, "/api/orgs/"+url.PathEscape(org)+"/members/"+url.PathEscape(user), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgRemoveMember calls DELETE /api/orgs/{org}/members/{user}.
+func (c *Client) OrgRemoveMember(ctx context.Context, org, user string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/orgs/"+url.PathEscape(org)+"/members/"+url.PathEscape(user), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// OrgTeams calls GET /api/orgs/{org}/teams.
+func (c *Client) OrgTeams(ctx context.Context, org string) (*OrgTeamsResponse, error) {
+	var out OrgTeamsResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/teams", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgPutTeamPost creates a team on POST and replaces one on PUT.
+//
+//	POST /api/orgs/{org}/teams
+func (c *Client) OrgPutTeamPost(ctx context.Context, org string, body *OrgTeamRequest) (*OrgTeam, error) {
+	var out OrgTeam
+	resp, err := c.send(ctx, http.MethodPost, "/api/orgs/"+url.PathEscape(org)+"/teams", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgPutTeamPut creates a team on POST and replaces one on PUT.
+//
+//	PUT /api/orgs/{org}/teams/{team}
+func (c *Client) OrgPutTeamPut(ctx context.Context, org, team string, body *OrgTeamRequest) (*OrgTeam, error) {
+	var out OrgTeam
+	resp, err := c.send(ctx, http.MethodPut, "/api/orgs/"+url.PathEscape(org)+"/teams/"+url.PathEscape(team), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgDeleteTeam calls DELETE /api/orgs/{org}/teams/{team}.
+func (c *Client) OrgDeleteTeam(ctx context.Context, org, team string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/orgs/"+url.PathEscape(org)+"/teams/"+url.PathEscape(team), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// OrgWorkspaces lists the organization's workspaces with the caller's role on
+// each.
+//
+//	GET /api/orgs/{org}/workspaces
+func (c *Client) OrgWorkspaces(ctx context.Context, org string) (*OrgWorkspacesResponse, error) {
+	var out OrgWorkspacesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/workspaces", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrgAddWorkspace creates an organization workspace or transfers one of the
+// caller's own workspaces to the organization.
+//
+//	POST /api/orgs/{org}/workspaces
+func (c *Client) OrgAddWorkspace(ctx context.Context, org string, body *OrgWorkspaceRequest) (*OrgAddWorkspaceResponse, error) {
+	var out OrgAddWorkspaceResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/orgs/"+url.PathEscape(org)+"/workspaces", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QuotaUsage reports the caller's usage this month and their limits.
+//
+//	GET /api/usage
+func (c *Client) QuotaUsage(ctx context.Context) (*QuotaReport, error) {
+	var out QuotaReport
+	resp, err := c.send(ctx, http.MethodGet, "/api/usage", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WorkspaceList returns the workspaces. When the request carries an owner,
+// only theirs and those without an owner are listed.
+//
+//	GET /api/workspaces
+func (c *Client) WorkspaceList(ctx context.Context) (*WorkspaceListResponse, error) {
+	var out WorkspaceListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WorkspaceCreate makes a workspace, empty or generated from the template
+// named by the template query parameter. The body is optional; without an ID a
+// random one is assigned.
+//
+//	POST /api/workspaces
+func (c *Client) WorkspaceCreate(ctx context.Context, body *WorkspaceCreateRequest, params *WorkspaceCreateParams) (*WorkspaceInfo, error) {
+	q, h := params.values()
+	var out WorkspaceInfo
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces", q, h, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WorkspaceTemplates calls GET /api/templates.
+func (c *Client) WorkspaceTemplates(ctx context.Context) (*WorkspaceTemplatesResponse, error) {
+	var out WorkspaceTemplatesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/templates", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ToolchainList calls GET /api/toolchains.
+func (c *Client) ToolchainList(ctx context.Context) (*ToolchainListResponse, error) {
+	var out ToolchainListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/toolchains", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ToolchainSettings calls GET /api/workspaces/{id}/settings/toolchain.
+func (c *Client) ToolchainSettings(ctx context.Context, id string) (*ToolchainSettings, error) {
+	var out ToolchainSettings
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/settings/toolchain", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ToolchainSetSettings declares the workspace's Go version. An empty version
+// follows the server default. The workspace container switches images the next
+// time a command is started in it.
+//
+//	PUT /api/workspaces/{id}/settings/toolchain
+func (c *Client) ToolchainSetSettings(ctx context.Context, id string, body *ToolchainSettings) (*ToolchainSettings, error) {
+	var out ToolchainSettings
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/settings/toolchain", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EgressSettings calls GET /api/workspaces/{id}/settings/network.
+func (c *Client) EgressSettings(ctx context.Context, id string) (*EgressStatus, error) {
+	var out EgressStatus
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/settings/network", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EgressSetSettings calls PUT /api/workspaces/{id}/settings/network.
+func (c *Client) EgressSetSettings(ctx context.Context, id string, body *EgressSettings) (*EgressStatus, error) {
+	var out EgressStatus
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/settings/network", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EgressBlocked calls GET /api/workspaces/{id}/network/blocked.
+func (c *Client) EgressBlocked(ctx context.Context, id string) (*EgressBlockedResponse, error) {
+	var out EgressBlockedResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/network/blocked", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImagesSettings calls GET /api/workspaces/{id}/settings/image.
+func (c *Client) ImagesSettings(ctx context.Context, id string) (*ImagesStatusResponse, error) {
+	var out ImagesStatusResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/settings/image", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImagesSetSettings calls PUT /api/workspaces/{id}/settings/image.
+func (c *Client) ImagesSetSettings(ctx context.Context, id string, body *ImagesSettings) (*ImagesStatusResponse, error) {
+	var out ImagesStatusResponse
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/settings/image", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImagesBuild pulls or builds the workspace's image and returns the Result
+// once docker exits.
+//
+//	POST /api/workspaces/{id}/image/build
+func (c *Client) ImagesBuild(ctx context.Context, id string) (*ImagesResult, error) {
+	var out ImagesResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/image/build", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TextnormSettings calls GET /api/workspaces/{id}/settings/text.
+func (c *Client) TextnormSettings(ctx context.Context, id string) (*TextnormPolicy, error) {
+	var out TextnormPolicy
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/settings/text", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TextnormSetSettings sets how the workspace's files are normalized when they
+// are saved from now on; files already saved are left as they are.
+//
+//	PUT /api/workspaces/{id}/settings/text
+func (c *Client) TextnormSetSettings(ctx context.Context, id string, body *TextnormPolicy) (*TextnormPolicy, error) {
+	var out TextnormPolicy
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/settings/text", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TextnormSanitize cleans up text an editor is about to paste, with the
+// workspace's policy: invisible characters are removed and line endings and
+// whitespace normalized.
+//
+//	POST /api/workspaces/{id}/sanitize
+func (c *Client) TextnormSanitize(ctx context.Context, id string, body *TextnormSanitizeRequest) (*TextnormSanitized, error) {
+	var out TextnormSanitized
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/sanitize", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FilesGet lists a directory, or serves a file's bytes. With ?meta=1 it
+// returns the entry's metadata instead of its contents, including for files
+// their content type and whether they are binary. Files can also be read as
+// JSON with ?encoding=text, which refuses binaries, or ?encoding=base64, and
+// images as PNG thumbnails with ?thumbnail=<size>.
+//
+//	GET /api/workspaces/{id}/files
+//
+// The caller must close the response's body.
+func (c *Client) FilesGet(ctx context.Context, id string, params *FilesGetParams) (*http.Response, error) {
+	q, h := params.values()
+	return c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/files", q, h, nil)
+}
+
+// FilesGetPath lists a directory, or serves a file's bytes. With ?meta=1 it
+// returns the entry's metadata instead of its contents, including for files
+// their content type and whether they are binary. Files can also be read as
+// JSON with ?encoding=text, which refuses binaries, or ?encoding=base64, and
+// images as PNG thumbnails with ?thumbnail=<size>.
+//
+//	GET /api/workspaces/{id}/files/{path}
+//
+// The caller must close the response's body.
+func (c *Client) FilesGetPath(ctx context.Context, id, path string, params *FilesGetPathParams) (*http.Response, error) {
+	q, h := params.values()
+	return c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/files/"+escapePath(path), q, h, nil)
+}
+
+// FilesPut writes a file atomically from the request body, or creates a
+// directory with ?type=dir. If-Match and If-None-Match: * guard against lost
+// updates. Text sent as it is, rather than in base64, is normalized with the
+// workspace's policy unless ?normalize=0; the answer then tells what changed
+// and which invisible characters the file has.
+//
+//	PUT /api/workspaces/{id}/files/{path}
+func (c *Client) FilesPut(ctx context.Context, id, path string, body io.Reader, params *FilesPutParams) (json.RawMessage, error) {
+	q, h := params.values()
+	var out json.RawMessage
+	resp, err := c.do(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/files/"+escapePath(path), q, h, body, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// FilesDelete removes a file, or a directory tree with ?recursive=true. With a
+// trash it answers with the trash item it went to, unless it was deleted
+// outright with ?permanent=true or for being too large for the trash.
+//
+//	DELETE /api/workspaces/{id}/files/{path}
+func (c *Client) FilesDelete(ctx context.Context, id, path string, params *FilesDeleteParams) (*FilesDeleted, error) {
+	q, h := params.values()
+	var out FilesDeleted
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/files/"+escapePath(path), q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FilesMove renames or moves a file or directory within the workspace.
+//
+//	POST /api/workspaces/{id}/move
+func (c *Client) FilesMove(ctx context.Context, id string, body *FilesMoveRequest) (*FilesEntry, error) {
+	var out FilesEntry
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/move", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TrashList calls GET /api/workspaces/{id}/trash.
+func (c *Client) TrashList(ctx context.Context, id string) (*TrashListing, error) {
+	var out TrashListing
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/trash", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TrashEmpty calls DELETE /api/workspaces/{id}/trash.
+func (c *Client) TrashEmpty(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/trash", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// TrashGet calls GET /api/workspaces/{id}/trash/{item}.
+func (c *Client) TrashGet(ctx context.Context, id, item string) (*TrashItem, error) {
+	var out TrashItem
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/trash/"+url.PathEscape(item), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TrashRestore moves an item back into the workspace. The body may be empty.
+//
+//	POST /api/workspaces/{id}/trash/{item}/restore
+func (c *Client) TrashRestore(ctx context.Context, id, item string, body *TrashRestoreRequest) (*FilesEntry, error) {
+	var out FilesEntry
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/trash/"+url.PathEscape(item)+"/restore", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TrashPurge calls DELETE /api/workspaces/{id}/trash/{item}.
+func (c *Client) TrashPurge(ctx context.Context, id, item string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/trash/"+url.PathEscape(item), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// UploadList calls GET /api/workspaces/{id}/uploads.
+func (c *Client) UploadList(ctx context.Context, id string) ([]Upload, error) {
+	var out []Upload
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/uploads", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// UploadCreate calls POST /api/workspaces/{id}/uploads.
+func (c *Client) UploadCreate(ctx context.Context, id string, body *UploadCreateRequest) (*Upload, error) {
+	var out Upload
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/uploads", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadGet reports an upload, with Upload-Offset for clients resuming. HEAD
+// answers with the headers alone.
+//
+//	GET /api/workspaces/{id}/uploads/{upload}
+func (c *Client) UploadGet(ctx context.Context, id, upload string) (*Upload, error) {
+	var out Upload
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/uploads/"+url.PathEscape(upload), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadPatch appends the request body to the upload from the Upload-Offset
+// header. A 409 for a wrong offset carries the right one in Upload-Offset.
+//
+//	PATCH /api/workspaces/{id}/uploads/{upload}
+func (c *Client) UploadPatch(ctx context.Context, id, upload string, body io.Reader, params *UploadPatchParams) (*Upload, error) {
+	q, h := params.values()
+	var out Upload
+	resp, err := c.do(ctx, http.MethodPatch, "/api/workspaces/"+url.PathEscape(id)+"/uploads/"+url.PathEscape(upload), q, h, body, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadDelete calls DELETE /api/workspaces/{id}/uploads/{upload}.
+func (c *Client) UploadDelete(ctx context.Context, id, upload string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/uploads/"+url.PathEscape(upload), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// HistoryFiles lists the paths with history, including deleted files.
+//
+//	GET /api/workspaces/{id}/history
+func (c *Client) HistoryFiles(ctx context.Context, id string) (*HistoryFilesResponse, error) {
+	var out HistoryFilesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/history", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// HistoryGet lists the versions of a file. With ?version=N it returns the
+// contents of that version instead, and with ?version=N&diff=M the changes
+// from version N to version M, or to the file as it is now for diff=current.
+//
+//	GET /api/workspaces/{id}/history/{path}
+//
+// The caller must close the response's body.
+func (c *Client) HistoryGet(ctx context.Context, id, path string, params *HistoryGetParams) (*http.Response, error) {
+	q, h := params.values()
+	return c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/history/"+escapePath(path), q, h, nil)
+}
+
+// HistoryRestore writes ?version=N back to the file, which becomes the newest
+// version. Restoring is itself recorded, so it can be undone.
+//
+//	POST /api/workspaces/{id}/history/{path}
+func (c *Client) HistoryRestore(ctx context.Context, id, path string, params *HistoryRestoreParams) (*HistoryRestoreResponse, error) {
+	q, h := params.values()
+	var out HistoryRestoreResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/history/"+escapePath(path), q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Search runs a text search. The response is a single JSON object unless the
+// client accepts application/x-ndjson, in which case each match is written as
+// it is found, as a "match" event, followed by a "done" event with the summary
+// or an "error" event.
+//
+//	GET /api/workspaces/{id}/search
+func (c *Client) Search(ctx context.Context, id string, params *SearchParams) (*SearchResponse, error) {
+	q, h := params.values()
+	var out SearchResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/search", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchSymbols runs a Go symbol search: ?q= is the name to look for and
+// ?kind= optionally restricts the kinds, comma-separated.
+//
+//	GET /api/workspaces/{id}/symbols
+func (c *Client) SearchSymbols(ctx context.Context, id string, params *SearchSymbolsParams) (*SearchSymbolsResponse, error) {
+	q, h := params.values()
+	var out SearchSymbolsResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/symbols", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchPreview shows the edits a replace would make, with each file's ETag
+// for the replace to check.
+//
+//	POST /api/workspaces/{id}/replace/preview
+func (c *Client) SearchPreview(ctx context.Context, id string, body *SearchReplaceRequest) (*SearchPreview, error) {
+	var out SearchPreview
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/replace/preview", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchReplace calls POST /api/workspaces/{id}/replace.
+func (c *Client) SearchReplace(ctx context.Context, id string, body *SearchReplaceRequest) (*SearchResult, error) {
+	var out SearchResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/replace", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchUndo takes the undo bundle of a replace and restores the files in it.
+//
+//	POST /api/workspaces/{id}/replace/undo
+func (c *Client) SearchUndo(ctx context.Context, id string, body *SearchUndo) (*SearchUndoResponse, error) {
+	var out SearchUndoResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/replace/undo", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ArchiveExport streams the workspace as an archive whose entries sit below a
+// directory named after the workspace. .git and the patterns of the root
+// .gitignore are left out unless all=1; exclude adds patterns.
+//
+//	GET /api/workspaces/{id}/export
+func (c *Client) ArchiveExport(ctx context.Context, id string, params *ArchiveExportParams) error {
+	q, h := params.values()
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/export", q, h, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// ArchiveStoreExport writes the archive export would stream into an artifact,
+// to download later or hand out by its URL.
+//
+//	POST /api/workspaces/{id}/export
+func (c *Client) ArchiveStoreExport(ctx context.Context, id string, params *ArchiveStoreExportParams) (*Artifact, error) {
+	q, h := params.values()
+	var out Artifact
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/export", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ArchiveImportArchive creates a workspace from the tar.gz or zip archive in
+// the request body. The workspace ID comes from the id query parameter or is
+// assigned at random.
+//
+//	POST /api/workspaces/import
+func (c *Client) ArchiveImportArchive(ctx context.Context, body io.Reader, params *ArchiveImportArchiveParams) (*ArchiveImportArchiveResponse, error) {
+	q, h := params.values()
+	var out ArchiveImportArchiveResponse
+	resp, err := c.do(ctx, http.MethodPost, "/api/workspaces/import", q, h, body, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Fork copies a workspace into a new one of the caller's. Its viewers may fork
+// it too. As with POST /api/workspaces, the body is optional.
+//
+//	POST /api/workspaces/{id}/fork
+func (c *Client) Fork(ctx context.Context, id string, body *ForkRequest) (*ForkResult, error) {
+	var out ForkResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/fork", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ForkLink forks the workspace of a fork share link, for handing out a starter
+// project to people who are not its members.
+//
+//	POST /api/share/{token}/fork
+func (c *Client) ForkLink(ctx context.Context, token string, body *ForkRequest) (*ForkResult, error) {
+	var out ForkResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/share/"+url.PathEscape(token)+"/fork", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ArtifactList returns the caller's artifacts, newest first, of ?kind= if set.
+//
+//	GET /api/artifacts
+func (c *Client) ArtifactList(ctx context.Context, params *ArtifactListParams) (*ArtifactListResponse, error) {
+	q, h := params.values()
+	var out ArtifactListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/artifacts", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ArtifactListWorkspace returns the artifacts made from a workspace, newest
+// first, of ?kind= if set.
+//
+//	GET /api/workspaces/{id}/artifacts
+func (c *Client) ArtifactListWorkspace(ctx context.Context, id string, params *ArtifactListWorkspaceParams) (*ArtifactListWorkspaceResponse, error) {
+	q, h := params.values()
+	var out ArtifactListWorkspaceResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/artifacts", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ArtifactGet calls GET /api/artifacts/{id}.
+func (c *Client) ArtifactGet(ctx context.Context, id string) (*Artifact, error) {
+	var out Artifact
+	resp, err := c.send(ctx, http.MethodGet, "/api/artifacts/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ArtifactDownload serves an artifact as an attachment, or with inline=1 for
+// display in the browser. A request with the expires and sig of its URL needs
+// no access token.
+//
+//	GET /api/artifacts/{id}/download
+//
+// The caller must close the response's body.
+func (c *Client) ArtifactDownload(ctx context.Context, id string, params *ArtifactDownloadParams) (*http.Response, error) {
+	q, h := params.values()
+	return c.send(ctx, http.MethodGet, "/api/artifacts/"+url.PathEscape(id)+"/download", q, h, nil)
+}
+
+// ArtifactExtend keeps an artifact for longer, or less long.
+//
+//	PATCH /api/artifacts/{id}
+func (c *Client) ArtifactExtend(ctx context.Context, id string, body *ArtifactExtendRequest) (*Artifact, error) {
+	var out Artifact
+	resp, err := c.send(ctx, http.MethodPatch, "/api/artifacts/"+url.PathEscape(id), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ArtifactRemove calls DELETE /api/artifacts/{id}.
+func (c *Client) ArtifactRemove(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/artifacts/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// SnapshotList calls GET /api/workspaces/{id}/snapshots.
+func (c *Client) SnapshotList(ctx context.Context, id string) (*SnapshotListResponse, error) {
+	var out SnapshotListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/snapshots", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SnapshotCreate calls POST /api/workspaces/{id}/snapshots.
+func (c *Client) SnapshotCreate(ctx context.Context, id string, body *SnapshotCreateRequest) (*Snapshot, error) {
+	var out Snapshot
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/snapshots", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SnapshotGet calls GET /api/workspaces/{id}/snapshots/{snap}.
+func (c *Client) SnapshotGet(ctx context.Context, id, snap string) (*Snapshot, error) {
+	var out Snapshot
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/snapshots/"+url.PathEscape(snap), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SnapshotRemove calls DELETE /api/workspaces/{id}/snapshots/{snap}.
+func (c *Client) SnapshotRemove(ctx context.Context, id, snap string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/snapshots/"+url.PathEscape(snap), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// SnapshotRestore rolls the workspace back and returns the snapshot taken of
+// it just before, for undoing the restore.
+//
+//	POST /api/workspaces/{id}/snapshots/{snap}/restore
+func (c *Client) SnapshotRestore(ctx context.Context, id, snap string) (*SnapshotRestoreResponse, error) {
+	var out SnapshotRestoreResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/snapshots/"+url.PathEscape(snap)+"/restore", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SnapshotFork creates a new workspace from a snapshot. As with POST
+// /api/workspaces, the body is optional and without an ID a random one is
+// assigned.
+//
+//	POST /api/workspaces/{id}/snapshots/{snap}/fork
+func (c *Client) SnapshotFork(ctx context.Context, id, snap string, body *SnapshotForkRequest) (*SnapshotForkResponse, error) {
+	var out SnapshotForkResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/snapshots/"+url.PathEscape(snap)+"/fork", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecoveryList calls GET /api/workspaces/{id}/drafts.
+func (c *Client) RecoveryList(ctx context.Context, id string) (*RecoveryListResponse, error) {
+	var out RecoveryListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/drafts", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecoveryGet calls GET /api/workspaces/{id}/drafts/{path}.
+func (c *Client) RecoveryGet(ctx context.Context, id, path string) (*RecoveryDraft, error) {
+	var out RecoveryDraft
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/drafts/"+escapePath(path), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecoverySave records a dirty buffer. Editors call it as the buffer changes,
+// at most every second or so; the server coalesces the writes.
+//
+//	PUT /api/workspaces/{id}/drafts/{path}
+func (c *Client) RecoverySave(ctx context.Context, id, path string, body *RecoverySaveRequest) error {
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/drafts/"+escapePath(path), nil, nil, body)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// RecoveryRestore writes a draft into its file. A draft whose file changed
+// since it was started is refused with 409 unless ?force=1.
+//
+//	POST /api/workspaces/{id}/drafts/{path}
+func (c *Client) RecoveryRestore(ctx context.Context, id, path string, params *RecoveryRestoreParams) (*FilesEntry, error) {
+	q, h := params.values()
+	var out FilesEntry
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/drafts/"+escapePath(path), q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecoveryDiscard calls DELETE /api/workspaces/{id}/drafts/{path}.
+func (c *Client) RecoveryDiscard(ctx context.Context, id, path string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/drafts/"+escapePath(path), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// GitInit calls POST /api/workspaces/{id}/git/init.
+func (c *Client) GitInit(ctx context.Context, id string) (*GitStatus, error) {
+	var out GitStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/git/init", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitClone fills an empty workspace with a remote repository.
+//
+//	POST /api/workspaces/{id}/git/clone
+func (c *Client) GitClone(ctx context.Context, id string, body *GitCloneRequest) (*GitStatus, error) {
+	var out GitStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/git/clone", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitStatus calls GET /api/workspaces/{id}/git/status.
+func (c *Client) GitStatus(ctx context.Context, id string) (*GitStatus, error) {
+	var out GitStatus
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/git/status", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitStage calls POST /api/workspaces/{id}/git/stage.
+func (c *Client) GitStage(ctx context.Context, id string) (*GitStatus, error) {
+	var out GitStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/git/stage", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitUnstage calls POST /api/workspaces/{id}/git/unstage.
+func (c *Client) GitUnstage(ctx context.Context, id string) (*GitStatus, error) {
+	var out GitStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/git/unstage", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitCommit calls POST /api/workspaces/{id}/git/commit.
+func (c *Client) GitCommit(ctx context.Context, id string, body *GitCommitRequest) (*GitCommitResponse, error) {
+	var out GitCommitResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/git/commit", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitBranches calls GET /api/workspaces/{id}/git/branches.
+func (c *Client) GitBranches(ctx context.Context, id string) (*GitBranchesResponse, error) {
+	var out GitBranchesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/git/branches", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitSwitchBranch calls POST /api/workspaces/{id}/git/switch.
+func (c *Client) GitSwitchBranch(ctx context.Context, id string, body *GitSwitchRequest) (*GitStatus, error) {
+	var out GitStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/git/switch", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitLog returns the history; ?limit= (default 50, at most 500) and ?skip=
+// page through it.
+//
+//	GET /api/workspaces/{id}/git/log
+func (c *Client) GitLog(ctx context.Context, id string, params *GitLogParams) (*GitLogResponse, error) {
+	q, h := params.values()
+	var out GitLogResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/git/log", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitDiff returns structured hunks. ?from= (default HEAD) and ?to= pick the
+// revisions; without ?to= the working tree is compared, or the index with
+// ?staged=1. Repeated ?path= limits the files and ?context= sets the number of
+// context lines.
+//
+//	GET /api/workspaces/{id}/git/diff
+func (c *Client) GitDiff(ctx context.Context, id string, params *GitDiffParams) (*GitDiffResponse, error) {
+	q, h := params.values()
+	var out GitDiffResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/git/diff", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitPush calls POST /api/workspaces/{id}/git/push.
+func (c *Client) GitPush(ctx context.Context, id string) (*GitStatus, error) {
+	var out GitStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/git/push", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GitPull calls POST /api/workspaces/{id}/git/pull.
+func (c *Client) GitPull(ctx context.Context, id string) (*GitStatus, error) {
+	var out GitStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/git/pull", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DiffMerge three-way merges the texts in the body and reports conflicts.
+//
+//	POST /api/merge
+func (c *Client) DiffMerge(ctx context.Context, body *DiffMergeRequest) (*DiffMergeResult, error) {
+	var out DiffMergeResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/merge", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunnerRun executes a request and answers with its Result. A client accepting
+// application/x-ndjson receives the run's Events instead, one per line as they
+// happen, as on /ws/run.
+//
+//	POST /api/run
+func (c *Client) RunnerRun(ctx context.Context, body *RunnerRequest) (*RunnerResult, error) {
+	var out RunnerResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/run", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunnerLanguages calls GET /api/run/languages.
+func (c *Client) RunnerLanguages(ctx context.Context) (*RunnerLanguagesResponse, error) {
+	var out RunnerLanguagesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/run/languages", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunnerBuild calls POST /api/builds.
+func (c *Client) RunnerBuild(ctx context.Context, body *RunnerBuildRequest) (*RunnerBuildResult, error) {
+	var out RunnerBuildResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/builds", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunnerTargets calls GET /api/builds/targets.
+func (c *Client) RunnerTargets(ctx context.Context) (*RunnerTargetsResponse, error) {
+	var out RunnerTargetsResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/builds/targets", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunnerArtifact calls GET /api/builds/{id}.
+func (c *Client) RunnerArtifact(ctx context.Context, id string) (*RunnerArtifact, error) {
+	var out RunnerArtifact
+	resp, err := c.send(ctx, http.MethodGet, "/api/builds/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunnerDownload serves a built binary as an attachment.
+//
+//	GET /api/builds/{id}/download
+//
+// The caller must close the response's body.
+func (c *Client) RunnerDownload(ctx context.Context, id string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, "/api/builds/"+url.PathEscape(id)+"/download", nil, nil, nil)
+}
+
+// RunnerProfile returns a stored profile as a flame graph and a function
+// table. The sample query parameter picks the sample type and top bounds the
+// table, 50 rows by default.
+//
+//	GET /api/profiles/{id}
+func (c *Client) RunnerProfile(ctx context.Context, id string, params *RunnerProfileParams) (*RunnerProfileView, error) {
+	q, h := params.values()
+	var out RunnerProfileView
+	resp, err := c.send(ctx, http.MethodGet, "/api/profiles/"+url.PathEscape(id), q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunnerDownloadProfile serves the raw profile for go tool pprof.
+//
+//	GET /api/profiles/{id}/download
+//
+// The caller must close the response's body.
+func (c *Client) RunnerDownloadProfile(ctx context.Context, id string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, "/api/profiles/"+url.PathEscape(id)+"/download", nil, nil, nil)
+}
+
+// TraceviewTrace calls GET /api/profiles/{id}/trace.
+func (c *Client) TraceviewTrace(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/profiles/"+url.PathEscape(id)+"/trace", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// SnippetCreate calls POST /api/snippets.
+func (c *Client) SnippetCreate(ctx context.Context, body *Snippet) (*Snippet, error) {
+	var out Snippet
+	resp, err := c.send(ctx, http.MethodPost, "/api/snippets", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SnippetGet calls GET /api/snippets/{id}.
+func (c *Client) SnippetGet(ctx context.Context, id string) (*Snippet, error) {
+	var out Snippet
+	resp, err := c.send(ctx, http.MethodGet, "/api/snippets/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SnippetEmbed renders a read-only page for an iframe, with a button that runs
+// the snippet through /embed/{id}/run.
+//
+//	GET /embed/{id}
+func (c *Client) SnippetEmbed(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodGet, "/embed/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// SnippetRun runs a stored snippet. Unlike /api/run it needs no sign-in, since
+// the program has already been shared, so embeds work for anyone.
+//
+//	POST /embed/{id}/run
+func (c *Client) SnippetRun(ctx context.Context, id string) (*RunnerResult, error) {
+	var out RunnerResult
+	resp, err := c.send(ctx, http.MethodPost, "/embed/"+url.PathEscape(id)+"/run", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlaygroundRun calls POST /embed/run.
+func (c *Client) PlaygroundRun(ctx context.Context, body *PlaygroundRequest) (*PlaygroundResponse, error) {
+	var out PlaygroundResponse
+	resp, err := c.send(ctx, http.MethodPost, "/embed/run", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlaygroundScript serves play.js, which makes code blocks of a page runnable.
+//
+//	GET /embed/play.js
+//
+// The caller must close the response's body.
+func (c *Client) PlaygroundScript(ctx context.Context) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, "/embed/play.js", nil, nil, nil)
+}
+
+// GistExportFiles calls POST /api/workspaces/{id}/gist.
+func (c *Client) GistExportFiles(ctx context.Context, id string, body *GistExportRequest) (*Gist, error) {
+	var out Gist
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/gist", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GistImportFiles writes the files of a gist into the workspace.
+//
+//	POST /api/workspaces/{id}/gist/import
+func (c *Client) GistImportFiles(ctx context.Context, id string, body *GistImportRequest) (*GistImportFilesResponse, error) {
+	var out GistImportFilesResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/gist/import", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GistExportSnippet calls POST /api/snippets/{id}/gist.
+func (c *Client) GistExportSnippet(ctx context.Context, id string, body *GistExportRequest) (*Gist, error) {
+	var out Gist
+	resp, err := c.send(ctx, http.MethodPost, "/api/snippets/"+url.PathEscape(id)+"/gist", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GistImportSnippet stores the files of a gist as a snippet.
+//
+//	POST /api/snippets/gist
+func (c *Client) GistImportSnippet(ctx context.Context, body *GistImportRequest) (*Snippet, error) {
+	var out Snippet
+	resp, err := c.send(ctx, http.MethodPost, "/api/snippets/gist", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GalleryList calls GET /api/examples.
+func (c *Client) GalleryList(ctx context.Context, params *GalleryListParams) (*GalleryListResponse, error) {
+	q, h := params.values()
+	var out GalleryListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/examples", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GalleryGet calls GET /api/examples/{id}.
+func (c *Client) GalleryGet(ctx context.Context, id string) (*GalleryGetResponse, error) {
+	var out GalleryGetResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/examples/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GalleryOpen copies an example into a new workspace. As with POST
+// /api/workspaces, the body is optional and without an ID a random one is
+// assigned.
+//
+//	POST /api/examples/{id}/workspaces
+func (c *Client) GalleryOpen(ctx context.Context, id string, body *GalleryOpenRequest) (*GalleryOpenResponse, error) {
+	var out GalleryOpenResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/examples/"+url.PathEscape(id)+"/workspaces", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CollabPresence lists who is editing which file in the workspace.
+//
+//	GET /api/workspaces/{id}/presence
+func (c *Client) CollabPresence(ctx context.Context, id string) (*CollabPresenceResponse, error) {
+	var out CollabPresenceResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/presence", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReviewList returns the threads of ?path=, or of the whole workspace, with
+// ?resolved=true or false keeping only resolved or open ones.
+//
+//	GET /api/workspaces/{id}/reviews
+func (c *Client) ReviewList(ctx context.Context, id string, params *ReviewListParams) ([]ReviewThread, error) {
+	q, h := params.values()
+	var out []ReviewThread
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/reviews", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// ReviewCreate calls POST /api/workspaces/{id}/reviews.
+func (c *Client) ReviewCreate(ctx context.Context, id string, body *ReviewCreateRequest) (*ReviewThread, error) {
+	var out ReviewThread
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/reviews", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReviewGet calls GET /api/workspaces/{id}/reviews/{thread}.
+func (c *Client) ReviewGet(ctx context.Context, id, thread string) (*ReviewThread, error) {
+	var out ReviewThread
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/reviews/"+url.PathEscape(thread), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReviewResolve resolves or reopens a thread.
+//
+//	PATCH /api/workspaces/{id}/reviews/{thread}
+func (c *Client) ReviewResolve(ctx context.Context, id, thread string, body *ReviewResolveRequest) (*ReviewThread, error) {
+	var out ReviewThread
+	resp, err := c.send(ctx, http.MethodPatch, "/api/workspaces/"+url.PathEscape(id)+"/reviews/"+url.PathEscape(thread), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReviewDelete removes a thread, which only the user who started it and the
+// workspace's owner may.
+//
+//	DELETE /api/workspaces/{id}/reviews/{thread}
+func (c *Client) ReviewDelete(ctx context.Context, id, thread string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/reviews/"+url.PathEscape(thread), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// ReviewReply calls POST /api/workspaces/{id}/reviews/{thread}/comments.
+func (c *Client) ReviewReply(ctx context.Context, id, thread string, body *ReviewCommentRequest) (*ReviewThread, error) {
+	var out ReviewThread
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/reviews/"+url.PathEscape(thread)+"/comments", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReviewEdit calls PATCH /api/workspaces/{id}/reviews/{thread}/comments/{comment}.
+func (c *Client) ReviewEdit(ctx context.Context, id, thread, comment string, body *ReviewCommentRequest) (*ReviewThread, error) {
+	var out ReviewThread
+	resp, err := c.send(ctx, http.MethodPatch, "/api/workspaces/"+url.PathEscape(id)+"/reviews/"+url.PathEscape(thread)+"/comments/"+url.PathEscape(comment), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReviewDeleteComment removes a comment, and its thread with the last one.
+//
+//	DELETE /api/workspaces/{id}/reviews/{thread}/comments/{comment}
+func (c *Client) ReviewDeleteComment(ctx context.Context, id, thread, comment string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/reviews/"+url.PathEscape(thread)+"/comments/"+url.PathEscape(comment), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// RecordingList calls GET /api/workspaces/{id}/recordings.
+func (c *Client) RecordingList(ctx context.Context, id string) ([]Recording, error) {
+	var out []Recording
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/recordings", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// RecordingStart calls POST /api/workspaces/{id}/recordings.
+func (c *Client) RecordingStart(ctx context.Context, id string, body *RecordingStartRequest) (*Recording, error) {
+	var out Recording
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/recordings", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecordingGet calls GET /api/workspaces/{id}/recordings/{rec}.
+func (c *Client) RecordingGet(ctx context.Context, id, rec string) (*Recording, error) {
+	var out Recording
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/recordings/"+url.PathEscape(rec), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecordingStop calls POST /api/workspaces/{id}/recordings/{rec}/stop.
+func (c *Client) RecordingStop(ctx context.Context, id, rec string) (*Recording, error) {
+	var out Recording
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/recordings/"+url.PathEscape(rec)+"/stop", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecordingDelete removes a recording, which only the user who started it and
+// the workspace's owner may.
+//
+//	DELETE /api/workspaces/{id}/recordings/{rec}
+func (c *Client) RecordingDelete(ctx context.Context, id, rec string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/recordings/"+url.PathEscape(rec), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// RecordingTimeline sends the timeline as JSON Lines, for clients that replay
+// it themselves.
+//
+//	GET /api/workspaces/{id}/recordings/{rec}/timeline
+//
+// The caller must close the response's body.
+func (c *Client) RecordingTimeline(ctx context.Context, id, rec string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/recordings/"+url.PathEscape(rec)+"/timeline", nil, nil, nil)
+}
+
+// BootstrapGetDotfiles calls GET /api/dotfiles.
+func (c *Client) BootstrapGetDotfiles(ctx context.Context) (*BootstrapDotfiles, error) {
+	var out BootstrapDotfiles
+	resp, err := c.send(ctx, http.MethodGet, "/api/dotfiles", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BootstrapSetDotfiles calls PUT /api/dotfiles.
+func (c *Client) BootstrapSetDotfiles(ctx context.Context, body *BootstrapDotfiles) (*BootstrapDotfiles, error) {
+	var out BootstrapDotfiles
+	resp, err := c.send(ctx, http.MethodPut, "/api/dotfiles", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BootstrapDeleteDotfiles calls DELETE /api/dotfiles.
+func (c *Client) BootstrapDeleteDotfiles(ctx context.Context) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/dotfiles", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// BootstrapStatus calls GET /api/workspaces/{id}/bootstrap.
+func (c *Client) BootstrapStatus(ctx context.Context, id string) (*BootstrapStatus, error) {
+	var out BootstrapStatus
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/bootstrap", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BootstrapLog calls GET /api/workspaces/{id}/bootstrap/log.
+//
+// The caller must close the response's body.
+func (c *Client) BootstrapLog(ctx context.Context, id string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/bootstrap/log", nil, nil, nil)
+}
+
+// BootstrapRun sets the workspace's sandbox up again, such as after its setup
+// script was changed.
+//
+//	POST /api/workspaces/{id}/bootstrap
+func (c *Client) BootstrapRun(ctx context.Context, id string) (*BootstrapStatus, error) {
+	var out BootstrapStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/bootstrap", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsGet calls GET /api/settings.
+func (c *Client) SettingsGet(ctx context.Context, params *SettingsGetParams) (*SettingsDocument, error) {
+	q, h := params.values()
+	var out SettingsDocument
+	resp, err := c.send(ctx, http.MethodGet, "/api/settings", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsPut replaces the settings. ?device= notes which device the change
+// was made from.
+//
+//	PUT /api/settings
+func (c *Client) SettingsPut(ctx context.Context, body io.Reader, params *SettingsPutParams) (*SettingsDocument, error) {
+	q, h := params.values()
+	var out SettingsDocument
+	resp, err := c.do(ctx, http.MethodPut, "/api/settings", q, h, body, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsPatch calls PATCH /api/settings.
+func (c *Client) SettingsPatch(ctx context.Context, body io.Reader, params *SettingsPatchParams) (*SettingsDocument, error) {
+	q, h := params.values()
+	var out SettingsDocument
+	resp, err := c.do(ctx, http.MethodPatch, "/api/settings", q, h, body, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsEffective returns the settings ?device= should use.
+//
+//	GET /api/settings/effective
+func (c *Client) SettingsEffective(ctx context.Context, params *SettingsEffectiveParams) (*SettingsEffective, error) {
+	q, h := params.values()
+	var out SettingsEffective
+	resp, err := c.send(ctx, http.MethodGet, "/api/settings/effective", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsVersions calls GET /api/settings/versions.
+func (c *Client) SettingsVersions(ctx context.Context) ([]SettingsDocument, error) {
+	var out []SettingsDocument
+	resp, err := c.send(ctx, http.MethodGet, "/api/settings/versions", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// SettingsVersion calls GET /api/settings/versions/{version}.
+func (c *Client) SettingsVersion(ctx context.Context, version string) (*SettingsDocument, error) {
+	var out SettingsDocument
+	resp, err := c.send(ctx, http.MethodGet, "/api/settings/versions/"+url.PathEscape(version), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsRestore puts an earlier version back, as a new version.
+//
+//	POST /api/settings/versions/{version}/restore
+func (c *Client) SettingsRestore(ctx context.Context, version string, params *SettingsRestoreParams) (*SettingsDocument, error) {
+	q, h := params.values()
+	var out SettingsDocument
+	resp, err := c.send(ctx, http.MethodPost, "/api/settings/versions/"+url.PathEscape(version)+"/restore", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsDevices calls GET /api/settings/devices.
+func (c *Client) SettingsDevices(ctx context.Context) ([]SettingsDocument, error) {
+	var out []SettingsDocument
+	resp, err := c.send(ctx, http.MethodGet, "/api/settings/devices", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// SettingsGetDevice calls GET /api/settings/devices/{device}.
+func (c *Client) SettingsGetDevice(ctx context.Context, device string, params *SettingsGetDeviceParams) (*SettingsDocument, error) {
+	q, h := params.values()
+	var out SettingsDocument
+	resp, err := c.send(ctx, http.MethodGet, "/api/settings/devices/"+url.PathEscape(device), q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsPutDevice calls PUT /api/settings/devices/{device}.
+func (c *Client) SettingsPutDevice(ctx context.Context, device string, body io.Reader, params *SettingsPutDeviceParams) (*SettingsDocument, error) {
+	q, h := params.values()
+	var out SettingsDocument
+	resp, err := c.do(ctx, http.MethodPut, "/api/settings/devices/"+url.PathEscape(device), q, h, body, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsPatchDevice calls PATCH /api/settings/devices/{device}.
+func (c *Client) SettingsPatchDevice(ctx context.Context, device string, body io.Reader, params *SettingsPatchDeviceParams) (*SettingsDocument, error) {
+	q, h := params.values()
+	var out SettingsDocument
+	resp, err := c.do(ctx, http.MethodPatch, "/api/settings/devices/"+url.PathEscape(device), q, h, body, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SettingsDeleteDevice calls DELETE /api/settings/devices/{device}.
+func (c *Client) SettingsDeleteDevice(ctx context.Context, device string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/settings/devices/"+url.PathEscape(device), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// TerminalList calls GET /api/workspaces/{id}/terminals.
+func (c *Client) TerminalList(ctx context.Context, id string) (*TerminalListResponse, error) {
+	var out TerminalListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/terminals", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TerminalKill calls DELETE /api/workspaces/{id}/terminals/{name}.
+func (c *Client) TerminalKill(ctx context.Context, id, name string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/terminals/"+url.PathEscape(name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// SshdList calls GET /api/ssh-keys.
+func (c *Client) SshdList(ctx context.Context) (*SshdListResponse, error) {
+	var out SshdListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/ssh-keys", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SshdAdd calls POST /api/ssh-keys.
+func (c *Client) SshdAdd(ctx context.Context, body *SshdAddRequest) (*SshdKey, error) {
+	var out SshdKey
+	resp, err := c.send(ctx, http.MethodPost, "/api/ssh-keys", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SshdDelete calls DELETE /api/ssh-keys/{id}.
+func (c *Client) SshdDelete(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/ssh-keys/"+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// PortsList calls GET /api/workspaces/{id}/ports.
+func (c *Client) PortsList(ctx context.Context, id string) (*PortsListResponse, error) {
+	var out PortsListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/ports", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PortsDeclare adds the port to the workspace's ports; the body, optional,
+// labels it.
+//
+//	PUT /api/workspaces/{id}/ports/{port}
+func (c *Client) PortsDeclare(ctx context.Context, id, port string, body *PortsDeclareRequest) (*PortsPort, error) {
+	var out PortsPort
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/ports/"+url.PathEscape(port), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PortsUndeclare calls DELETE /api/workspaces/{id}/ports/{port}.
+func (c *Client) PortsUndeclare(ctx context.Context, id, port string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/ports/"+url.PathEscape(port), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// PortsURL returns a preview URL that signs the caller in to the port's
+// preview. Any member may open previews, viewers included.
+//
+//	GET /api/workspaces/{id}/ports/{port}/url
+func (c *Client) PortsURL(ctx context.Context, id, port string) (*PortsURLResponse, error) {
+	var out PortsURLResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/ports/"+url.PathEscape(port)+"/url", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DevserveStatus calls GET /api/workspaces/{id}/dev.
+func (c *Client) DevserveStatus(ctx context.Context, id string) (*DevserveStatus, error) {
+	var out DevserveStatus
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/dev", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DevserveStart runs the dev server described by the Options in the body.
+//
+//	POST /api/workspaces/{id}/dev
+func (c *Client) DevserveStart(ctx context.Context, id string, body *DevserveOptions) (*DevserveStatus, error) {
+	var out DevserveStatus
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/dev", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DevserveRestart calls POST /api/workspaces/{id}/dev/restart.
+func (c *Client) DevserveRestart(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/dev/restart", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// DevserveStop calls DELETE /api/workspaces/{id}/dev.
+func (c *Client) DevserveStop(ctx context.Context, id string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/dev", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// HibernateStatus calls GET /api/workspaces/{id}/lifecycle.
+func (c *Client) HibernateStatus(ctx context.Context, id string) (*HibernateStatus, error) {
+	var out HibernateStatus
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/lifecycle", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestRun calls POST /api/workspaces/{id}/tests.
+func (c *Client) GotestRun(ctx context.Context, id string, body *GotestRequest) (*GotestReport, error) {
+	var out GotestReport
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/tests", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestCoverage returns the latest coverage profile. ?path= limits it to one
+// file, for rendering the gutter of an open editor.
+//
+//	GET /api/workspaces/{id}/coverage
+func (c *Client) GotestCoverage(ctx context.Context, id string, params *GotestCoverageParams) (*GotestCoverage, error) {
+	q, h := params.values()
+	var out GotestCoverage
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/coverage", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestCoverageReport renders the latest coverage profile as an HTML page and
+// stores it as an artifact, to download or share.
+//
+//	POST /api/workspaces/{id}/coverage/html
+func (c *Client) GotestCoverageReport(ctx context.Context, id string) (*Artifact, error) {
+	var out Artifact
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/coverage/html", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestBench calls POST /api/workspaces/{id}/benchmarks.
+func (c *Client) GotestBench(ctx context.Context, id string, body *GotestBenchRequest) (*GotestBenchRun, error) {
+	var out GotestBenchRun
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/benchmarks", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestBenchRuns calls GET /api/workspaces/{id}/benchmarks.
+func (c *Client) GotestBenchRuns(ctx context.Context, id string) (*GotestBenchRunsResponse, error) {
+	var out GotestBenchRunsResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/benchmarks", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestCompare diffs ?old= against ?new=. new defaults to the latest run and
+// old to the run before new.
+//
+//	GET /api/workspaces/{id}/benchmarks/compare
+func (c *Client) GotestCompare(ctx context.Context, id string, params *GotestCompareParams) (*GotestComparison, error) {
+	q, h := params.values()
+	var out GotestComparison
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/benchmarks/compare", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestBenchRun calls GET /api/workspaces/{id}/benchmarks/{run}.
+func (c *Client) GotestBenchRun(ctx context.Context, id, run string) (*GotestBenchRun, error) {
+	var out GotestBenchRun
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/benchmarks/"+url.PathEscape(run), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestFuzz runs the fuzz target in the body and returns its result once the
+// time is up or it fails.
+//
+//	POST /api/workspaces/{id}/fuzz
+func (c *Client) GotestFuzz(ctx context.Context, id string, body *GotestFuzzRequest) (*GotestFuzzResult, error) {
+	var out GotestFuzzResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/fuzz", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestCorpus lists the corpus of ?module=&package=&target=.
+//
+//	GET /api/workspaces/{id}/fuzz/corpus
+func (c *Client) GotestCorpus(ctx context.Context, id string, params *GotestCorpusParams) (*GotestCorpus, error) {
+	q, h := params.values()
+	var out GotestCorpus
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/fuzz/corpus", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GotestClearCorpus removes the generated corpus of ?module=&package=&target=.
+//
+//	DELETE /api/workspaces/{id}/fuzz/corpus
+func (c *Client) GotestClearCorpus(ctx context.Context, id string, params *GotestClearCorpusParams) error {
+	q, h := params.values()
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/fuzz/corpus", q, h, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AssignmentList calls GET /api/orgs/{org}/assignments.
+func (c *Client) AssignmentList(ctx context.Context, org string) (*AssignmentListResponse, error) {
+	var out AssignmentListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/assignments", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssignmentCreate calls POST /api/orgs/{org}/assignments.
+func (c *Client) AssignmentCreate(ctx context.Context, org string, body *Assignment) (*Assignment, error) {
+	var out Assignment
+	resp, err := c.send(ctx, http.MethodPost, "/api/orgs/"+url.PathEscape(org)+"/assignments", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssignmentGet calls GET /api/orgs/{org}/assignments/{assignment}.
+func (c *Client) AssignmentGet(ctx context.Context, org, assignment string) (*Assignment, error) {
+	var out Assignment
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/assignments/"+url.PathEscape(assignment), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssignmentUpdate calls PUT /api/orgs/{org}/assignments/{assignment}.
+func (c *Client) AssignmentUpdate(ctx context.Context, org, assignment string, body *Assignment) (*Assignment, error) {
+	var out Assignment
+	resp, err := c.send(ctx, http.MethodPut, "/api/orgs/"+url.PathEscape(org)+"/assignments/"+url.PathEscape(assignment), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssignmentDelete calls DELETE /api/orgs/{org}/assignments/{assignment}.
+func (c *Client) AssignmentDelete(ctx context.Context, org, assignment string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/orgs/"+url.PathEscape(org)+"/assignments/"+url.PathEscape(assignment), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AssignmentSubmit queues a workspace for grading; the client polls the
+// submission until it is graded.
+//
+//	POST /api/orgs/{org}/assignments/{assignment}/submissions
+func (c *Client) AssignmentSubmit(ctx context.Context, org, assignment string, body *AssignmentSubmitRequest) (*AssignmentSubmission, error) {
+	var out AssignmentSubmission
+	resp, err := c.send(ctx, http.MethodPost, "/api/orgs/"+url.PathEscape(org)+"/assignments/"+url.PathEscape(assignment)+"/submissions", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssignmentSubmissions lists an assignment's submissions: the caller's own,
+// or for admins everyone's, filtered by ?user=.
+//
+//	GET /api/orgs/{org}/assignments/{assignment}/submissions
+func (c *Client) AssignmentSubmissions(ctx context.Context, org, assignment string, params *AssignmentSubmissionsParams) (*AssignmentSubmissionsResponse, error) {
+	q, h := params.values()
+	var out AssignmentSubmissionsResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/assignments/"+url.PathEscape(assignment)+"/submissions", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssignmentSubmission calls GET /api/orgs/{org}/assignments/{assignment}/submissions/{submission}.
+func (c *Client) AssignmentSubmission(ctx context.Context, org, assignment, submission string) (*AssignmentSubmission, error) {
+	var out AssignmentSubmission
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/assignments/"+url.PathEscape(assignment)+"/submissions/"+url.PathEscape(submission), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssignmentGrades calls GET /api/orgs/{org}/assignments/{assignment}/grades.
+func (c *Client) AssignmentGrades(ctx context.Context, org, assignment string) (*AssignmentGradesResponse, error) {
+	var out AssignmentGradesResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/assignments/"+url.PathEscape(assignment)+"/grades", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AnalyticsDeployment reports on the whole deployment, or with ?org= on one
+// organization, to administrators.
+//
+//	GET /api/admin/analytics
+func (c *Client) AnalyticsDeployment(ctx context.Context, params *AnalyticsDeploymentParams) (*AnalyticsReport, error) {
+	q, h := params.values()
+	var out AnalyticsReport
+	resp, err := c.send(ctx, http.MethodGet, "/api/admin/analytics", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AnalyticsOrg reports on an organization to its admins, its instructors, and
+// to the server's administrators.
+//
+//	GET /api/orgs/{org}/analytics
+func (c *Client) AnalyticsOrg(ctx context.Context, org string, params *AnalyticsOrgParams) (*AnalyticsReport, error) {
+	q, h := params.values()
+	var out AnalyticsReport
+	resp, err := c.send(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/analytics", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TasksList calls GET /api/workspaces/{id}/tasks.
+func (c *Client) TasksList(ctx context.Context, id string) (*TasksListResponse, error) {
+	var out TasksListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/tasks", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TasksRun runs the task named in the body and returns its Result once it
+// exits.
+//
+//	POST /api/workspaces/{id}/tasks/run
+func (c *Client) TasksRun(ctx context.Context, id string, body *TasksRequest) (*TasksResult, error) {
+	var out TasksResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/tasks/run", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Format formats the submitted source.
+//
+//	POST /api/format
+func (c *Client) Format(ctx context.Context, body *FormatRequest) (*FormatResult, error) {
+	var out FormatResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/format", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FormatFile formats a workspace file in place with the workspace's formatter,
+// as on save, and returns the edits made so an open editor can apply them to
+// its buffer.
+//
+//	POST /api/workspaces/{id}/format/{path}
+func (c *Client) FormatFile(ctx context.Context, id, path string, params *FormatFileParams) (*FormatResult, error) {
+	q, h := params.values()
+	var out FormatResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/format/"+escapePath(path), q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FormatSettings calls GET /api/workspaces/{id}/settings/format.
+func (c *Client) FormatSettings(ctx context.Context, id string) (*FormatSettings, error) {
+	var out FormatSettings
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/settings/format", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FormatSetSettings calls PUT /api/workspaces/{id}/settings/format.
+func (c *Client) FormatSetSettings(ctx context.Context, id string, body *FormatSettings) (*FormatSettings, error) {
+	var out FormatSettings
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/settings/format", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// MarkdownFile renders a saved file.
+//
+//	GET /api/workspaces/{id}/markdown/{path}
+//
+// The caller must close the response's body.
+func (c *Client) MarkdownFile(ctx context.Context, id, path string, params *MarkdownFileParams) (*http.Response, error) {
+	q, h := params.values()
+	return c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/markdown/"+escapePath(path), q, h, nil)
+}
+
+// MarkdownSource renders an editor's unsaved buffer for the file at the path,
+// so the preview follows typing. The file need not exist yet.
+//
+//	POST /api/workspaces/{id}/markdown/{path}
+//
+// The caller must close the response's body.
+func (c *Client) MarkdownSource(ctx context.Context, id, path string, body *MarkdownSourceRequest, params *MarkdownSourceParams) (*http.Response, error) {
+	q, h := params.values()
+	return c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/markdown/"+escapePath(path), q, h, body)
+}
+
+// Lint calls POST /api/workspaces/{id}/lint.
+func (c *Client) Lint(ctx context.Context, id string, body *LintRequest) (*LintResult, error) {
+	var out LintResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/lint", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// VulncheckLast returns the latest scan of ?module=, the workspace root by
+// default.
+//
+//	GET /api/workspaces/{id}/vulns
+func (c *Client) VulncheckLast(ctx context.Context, id string, params *VulncheckLastParams) (*VulncheckResult, error) {
+	q, h := params.values()
+	var out VulncheckResult
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/vulns", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// VulncheckScan calls POST /api/workspaces/{id}/vulns.
+func (c *Client) VulncheckScan(ctx context.Context, id string, body *VulncheckRequest) (*VulncheckResult, error) {
+	var out VulncheckResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/vulns", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GomodList returns the dependencies of ?module=, by default the workspace
+// root or every module of its go.work, with their available upgrades when
+// ?updates=1.
+//
+//	GET /api/workspaces/{id}/deps
+func (c *Client) GomodList(ctx context.Context, id string, params *GomodListParams) (*GomodDependencies, error) {
+	q, h := params.values()
+	var out GomodDependencies
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/deps", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GomodGraph returns the module graph of ?module=, with every version required
+// when ?all=1.
+//
+//	GET /api/workspaces/{id}/deps/graph
+func (c *Client) GomodGraph(ctx context.Context, id string, params *GomodGraphParams) (*GomodGraph, error) {
+	q, h := params.values()
+	var out GomodGraph
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/deps/graph", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GomodActionAdd calls POST /api/workspaces/{id}/deps/add.
+func (c *Client) GomodActionAdd(ctx context.Context, id string, body *GomodRequest) (*GomodResult, error) {
+	var out GomodResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/deps/add", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GomodActionUpgrade calls POST /api/workspaces/{id}/deps/upgrade.
+func (c *Client) GomodActionUpgrade(ctx context.Context, id string, body *GomodRequest) (*GomodResult, error) {
+	var out GomodResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/deps/upgrade", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GomodActionRemove calls POST /api/workspaces/{id}/deps/remove.
+func (c *Client) GomodActionRemove(ctx context.Context, id string, body *GomodRequest) (*GomodResult, error) {
+	var out GomodResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/deps/remove", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GomodActionTidy calls POST /api/workspaces/{id}/deps/tidy.
+func (c *Client) GomodActionTidy(ctx context.Context, id string, body *GomodRequest) (*GomodResult, error) {
+	var out GomodResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/deps/tidy", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JobsList calls GET /api/workspaces/{id}/jobs.
+func (c *Client) JobsList(ctx context.Context, id string) (*JobsListResponse, error) {
+	var out JobsListResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/jobs", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JobsCreate adds a job, which runs as the caller.
+//
+//	POST /api/workspaces/{id}/jobs
+func (c *Client) JobsCreate(ctx context.Context, id string, body *JobsSpec) (*JobsJob, error) {
+	var out JobsJob
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/jobs", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JobsGet calls GET /api/workspaces/{id}/jobs/{job}.
+func (c *Client) JobsGet(ctx context.Context, id, job string) (*JobsJob, error) {
+	var out JobsJob
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/jobs/"+url.PathEscape(job), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JobsUpdate replaces what a job runs and when. It keeps running as its
+// creator.
+//
+//	PUT /api/workspaces/{id}/jobs/{job}
+func (c *Client) JobsUpdate(ctx context.Context, id, job string, body *JobsSpec) (*JobsJob, error) {
+	var out JobsJob
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/jobs/"+url.PathEscape(job), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JobsDelete calls DELETE /api/workspaces/{id}/jobs/{job}.
+func (c *Client) JobsDelete(ctx context.Context, id, job string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/jobs/"+url.PathEscape(job), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// JobsRuns calls GET /api/workspaces/{id}/jobs/{job}/runs.
+func (c *Client) JobsRuns(ctx context.Context, id, job string) (*JobsRunsResponse, error) {
+	var out JobsRunsResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/jobs/"+url.PathEscape(job)+"/runs", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JobsTrigger queues a run of the job now; its outcome shows in the runs.
+//
+//	POST /api/workspaces/{id}/jobs/{job}/runs
+func (c *Client) JobsTrigger(ctx context.Context, id, job string) (*JobsRun, error) {
+	var out JobsRun
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/jobs/"+url.PathEscape(job)+"/runs", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JobsRun calls GET /api/workspaces/{id}/jobs/{job}/runs/{run}.
+func (c *Client) JobsRun(ctx context.Context, id, job, run string) (*JobsRun, error) {
+	var out JobsRun
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/jobs/"+url.PathEscape(job)+"/runs/"+url.PathEscape(run), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WasmBuild calls POST /api/workspaces/{id}/wasm/build.
+func (c *Client) WasmBuild(ctx context.Context, id string, body *WasmBuildRequest) (*WasmBuildResult, error) {
+	var out WasmBuildResult
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/wasm/build", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WasmBundle calls GET /api/workspaces/{id}/wasm.
+func (c *Client) WasmBundle(ctx context.Context, id string) (*WasmBundle, error) {
+	var out WasmBundle
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/wasm", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WasmPreview serves the latest bundle: main.wasm, wasm_exec.js, an index page
+// that runs them, and other files from the package directory, such as
+// stylesheets or a custom index.html.
+//
+//	GET /preview/{id}/{file}
+//
+// The caller must close the response's body.
+func (c *Client) WasmPreview(ctx context.Context, id, file string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, "/preview/"+url.PathEscape(id)+"/"+escapePath(file), nil, nil, nil)
+}
+
+// GhimportCreate calls POST /api/workspaces/github.
+func (c *Client) GhimportCreate(ctx context.Context, body *GhimportCreateRequest) (*GhimportImport, error) {
+	var out GhimportImport
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/github", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GhimportStatus reports the import of a workspace, including whether its
+// dependencies have finished downloading.
+//
+//	GET /api/workspaces/{id}/github
+func (c *Client) GhimportStatus(ctx context.Context, id string) (*GhimportImport, error) {
+	var out GhimportImport
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/github", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NotebookGet returns the notebook at the path, normalized as it would be
+// saved.
+//
+//	GET /api/workspaces/{id}/notebooks/{path}
+func (c *Client) NotebookGet(ctx context.Context, id, path string) (*Notebook, error) {
+	var out Notebook
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/notebooks/"+escapePath(path), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NotebookPut saves a notebook. Like file saves, If-Match and If-None-Match: *
+// guard against lost updates.
+//
+//	PUT /api/workspaces/{id}/notebooks/{path}
+func (c *Client) NotebookPut(ctx context.Context, id, path string, body *Notebook, params *NotebookPutParams) (*Notebook, error) {
+	q, h := params.values()
+	var out Notebook
+	resp, err := c.send(ctx, http.MethodPut, "/api/workspaces/"+url.PathEscape(id)+"/notebooks/"+escapePath(path), q, h, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NotebookRun executes cells and saves their outputs. The cells run against
+// the notebook as it was when the request arrived; their outputs are merged by
+// cell ID into the file as it is when they finish, so edits saved in the
+// meantime are kept.
+//
+//	POST /api/workspaces/{id}/notebooks/{path}
+func (c *Client) NotebookRun(ctx context.Context, id, path string, body *NotebookRunRequest) (*Notebook, error) {
+	var out Notebook
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/notebooks/"+escapePath(path), nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NotebookList calls GET /api/workspaces/{id}/kernels.
+func (c *Client) NotebookList(ctx context.Context, id string) ([]NotebookStatus, error) {
+	var out []NotebookStatus
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/kernels", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(resp, &out)
+	return out, err
+}
+
+// NotebookStatus calls GET /api/workspaces/{id}/kernels/{path}.
+func (c *Client) NotebookStatus(ctx context.Context, id, path string) (*NotebookStatus, error) {
+	var out NotebookStatus
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/kernels/"+escapePath(path), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NotebookControl interrupts the running cell, or restarts the kernel so the
+// next run starts from a clean state.
+//
+//	POST /api/workspaces/{id}/kernels/{path}
+func (c *Client) NotebookControl(ctx context.Context, id, path string, body *NotebookControlRequest) error {
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/kernels/"+escapePath(path), nil, nil, body)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// NotebookShutdown calls DELETE /api/workspaces/{id}/kernels/{path}.
+func (c *Client) NotebookShutdown(ctx context.Context, id, path string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/workspaces/"+url.PathEscape(id)+"/kernels/"+escapePath(path), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AITaskComplete answers one request with the full reply.
+//
+//	POST /api/workspaces/{id}/ai/complete
+func (c *Client) AITaskComplete(ctx context.Context, id string, body *AIRequest) (*AIResponse, error) {
+	var out AIResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/ai/complete", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AITaskExplain answers one request with the full reply.
+//
+//	POST /api/workspaces/{id}/ai/explain
+func (c *Client) AITaskExplain(ctx context.Context, id string, body *AIRequest) (*AIResponse, error) {
+	var out AIResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/ai/explain", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AITaskTests answers one request with the full reply.
+//
+//	POST /api/workspaces/{id}/ai/tests
+func (c *Client) AITaskTests(ctx context.Context, id string, body *AIRequest) (*AIResponse, error) {
+	var out AIResponse
+	resp, err := c.send(ctx, http.MethodPost, "/api/workspaces/"+url.PathEscape(id)+"/ai/tests", nil, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AIUsage reports the caller's tokens this month and their budget.
+//
+//	GET /api/ai/usage
+func (c *Client) AIUsage(ctx context.Context) (*AIReport, error) {
+	var out AIReport
+	resp, err := c.send(ctx, http.MethodGet, "/api/ai/usage", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LSPServers lists the registered language servers with the files they handle,
+// so the editor knows which one to connect to for a file; ?file= keeps those
+// handling that path.
+//
+//	GET /api/lsp/servers
+func (c *Client) LSPServers(ctx context.Context, params *LSPServersParams) (*LSPServersResponse, error) {
+	q, h := params.values()
+	var out LSPServersResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/lsp/servers", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GoastOutline returns the outline of ?path=.
+//
+//	GET /api/workspaces/{id}/goast/outline
+func (c *Client) GoastOutline(ctx context.Context, id string, params *GoastOutlineParams) (*GoastOutline, error) {
+	q, h := params.values()
+	var out GoastOutline
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/goast/outline", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GoastPkg lists the declarations of the package in ?dir=, the workspace root
+// by default.
+//
+//	GET /api/workspaces/{id}/goast/package
+func (c *Client) GoastPkg(ctx context.Context, id string, params *GoastPkgParams) (*GoastPackage, error) {
+	q, h := params.values()
+	var out GoastPackage
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/goast/package", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GoastDefinition finds the definition of the identifier at ?line=&column= in
+// ?path=.
+//
+//	GET /api/workspaces/{id}/goast/definition
+func (c *Client) GoastDefinition(ctx context.Context, id string, params *GoastDefinitionParams) (*GoastDefinitionResponse, error) {
+	q, h := params.values()
+	var out GoastDefinitionResponse
+	resp, err := c.send(ctx, http.MethodGet, "/api/workspaces/"+url.PathEscape(id)+"/goast/definition", q, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Healthz calls GET /healthz.
+func (c *Client) Healthz(ctx context.Context) (*HealthzResponse, error) {
+	var out HealthzResponse
+	resp, err := c.send(ctx, http.MethodGet, "/healthz", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// HealthReady calls GET /readyz.
+func (c *Client) HealthReady(ctx context.Context) (*HealthReadyResponse, error) {
+	var out HealthReadyResponse
+	resp, err := c.send(ctx, http.MethodGet, "/readyz", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Metrics writes the metrics in the Prometheus text format.
+//
+//	GET /metrics
+func (c *Client) Metrics(ctx context.Context) error {
+	resp, err := c.send(ctx, http.MethodGet, "/metrics", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// AIReport is a user's token usage this month.
+type AIReport struct {
+	PeriodStart *time.Time `json:"periodStart,omitempty"`
+	PeriodEnd   *time.Time `json:"periodEnd,omitempty"`
+	Used        int64      `json:"used,omitempty"`
+	// Limit is negative when usage is not limited.
+	Limit int64 `json:"limit,omitempty"`
+}
+
+// AIRequest asks for assistance with a file of a workspace.
+type AIRequest struct {
+	// Path is the open file, relative to the workspace root.
+	Path string `json:"path,omitempty"`
+	// Content is the editor's buffer, which may be unsaved; the saved file is
+	// read when it is nil.
+	Content *string `json:"content,omitempty"`
+	// Line and Column are the 1-based cursor position, in bytes, that Complete
+	// inserts at.
+	Line   int64 `json:"line,omitempty"`
+	Column int64 `json:"column,omitempty"`
+	// Error is the message Explain explains, such as a compiler error or a
+	// panic; the file's diagnostics are explained when it is empty.
+	Error string `json:"error,omitempty"`
+	// Function is the function Tests tests, as "Name" or "Type.Method".
+	Function string `json:"function,omitempty"`
+	// Diagnostics are the editor's problems for the file. For Go files without
+	// any, the server reports syntax errors itself.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// AIResponse is the backend's full reply.
+type AIResponse struct {
+	Text  string  `json:"text,omitempty"`
+	Usage AIUsage `json:"usage,omitempty"`
+}
+
+// AIUsage counts the tokens of a request.
+type AIUsage struct {
+	PromptTokens     int64 `json:"promptTokens,omitempty"`
+	CompletionTokens int64 `json:"completionTokens,omitempty"`
+	// Estimated reports that the backend did not count the tokens.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+type AccessCreateLinkResponse struct {
+	Link  *AccessLink `json:"link,omitempty"`
+	Token string      `json:"token,omitempty"`
+}
+
+// AccessInvitation offers a role on a workspace. Whoever holds its token may
+// accept it, provided their email address matches when one is set.
+type AccessInvitation struct {
+	ID        string `json:"id,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	Email     string `json:"email,omitempty"`
+	// One of editor, owner, viewer.
+	Role      string     `json:"role,omitempty"`
+	InvitedBy string     `json:"invitedBy,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type AccessInvitationsResponse struct {
+	Invitations []AccessInvitation `json:"invitations,omitempty"`
+}
+
+type AccessInviteRequest struct {
+	Email string `json:"email,omitempty"`
+	// One of editor, owner, viewer.
+	Role string `json:"role,omitempty"`
+}
+
+type AccessInviteResponse struct {
+	Invitation *AccessInvitation `json:"invitation,omitempty"`
+	Token      string            `json:"token,omitempty"`
+}
+
+// AccessLink is a share link: whoever holds its token may follow the
+// workspace's collaborative sessions, or only the one on Path, without an
+// account until ExpiresAt. Fork links instead let signed-in users fork it.
+type AccessLink struct {
+	ID        string     `json:"id,omitempty"`
+	Workspace string     `json:"workspace,omitempty"`
+	Path      string     `json:"path,omitempty"`
+	Access    string     `json:"access,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type AccessLinkRequest struct {
+	Path       string `json:"path,omitempty"`
+	Access     string `json:"access,omitempty"`
+	TTLSeconds int64  `json:"ttlSeconds,omitempty"`
+}
+
+type AccessLinksResponse struct {
+	Links []AccessLink `json:"links,omitempty"`
+}
+
+// AccessMember is a user a workspace is shared with.
+type AccessMember struct {
+	UserID string `json:"userId,omitempty"`
+	Email  string `json:"email,omitempty"`
+	// One of editor, owner, viewer.
+	Role    string     `json:"role,omitempty"`
+	AddedBy string     `json:"addedBy,omitempty"`
+	AddedAt *time.Time `json:"addedAt,omitempty"`
+}
+
+type AccessMembersResponse struct {
+	Owner   string         `json:"owner,omitempty"`
+	Members []AccessMember `json:"members,omitempty"`
+}
+
+type AccessResolveLinkResponse struct {
+	Workspace string     `json:"workspace,omitempty"`
+	Path      string     `json:"path,omitempty"`
+	Access    string     `json:"access,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type AccessRoleRequest struct {
+	// One of editor, owner, viewer.
+	Role string `json:"role,omitempty"`
+}
+
+// AccessShared is a workspace shared with a user.
+type AccessShared struct {
+	ID string `json:"id,omitempty"`
+	// One of editor, owner, viewer.
+	Role string `json:"role,omitempty"`
+}
+
+type AccessSharedResponse struct {
+	Workspaces []AccessShared `json:"workspaces,omitempty"`
+}
+
+// AdminNotice is a message for everyone using the server, such as one about
+// planned maintenance.
+type AdminNotice struct {
+	ID      string     `json:"id,omitempty"`
+	Level   string     `json:"level,omitempty"`
+	Message string     `json:"message,omitempty"`
+	Time    *time.Time `json:"time,omitempty"`
+	// Expires, if set, is when clients stop showing the notice.
+	Expires any `json:"expires,omitempty"`
+}
+
+// AdminNoticeRequest is the body of POST /api/admin/notices. Expires may be
+// given as a time or as a number of seconds from now.
+type AdminNoticeRequest struct {
+	Level          string `json:"level,omitempty"`
+	Message        string `json:"message,omitempty"`
+	Expires        any    `json:"expires,omitempty"`
+	ExpiresSeconds int64  `json:"expiresSeconds,omitempty"`
+}
+
+type AdminNoticeResponse struct {
+	Notice *AdminNotice `json:"notice,omitempty"`
+}
+
+// AdminSandboxInfo describes a process executing in a sandbox.
+type AdminSandboxInfo struct {
+	ID string `json:"id,omitempty"`
+	// User is who started it, and Workspace what for; both are empty for runs
+	// without them, such as shared snippets.
+	User      string       `json:"user,omitempty"`
+	Workspace string       `json:"workspace,omitempty"`
+	Image     string       `json:"image,omitempty"`
+	Limits    RunnerLimits `json:"limits,omitempty"`
+	Network   bool         `json:"network,omitempty"`
+	StartedAt *time.Time   `json:"startedAt,omitempty"`
+}
+
+type AdminSandboxesResponse struct {
+	Sandboxes []AdminSandboxInfo `json:"sandboxes,omitempty"`
+}
+
+// AdminUserInfo describes an active user.
+type AdminUserInfo struct {
+	ID    string `json:"id,omitempty"`
+	Email string `json:"email,omitempty"`
+	// LastSeen is the time of the user's last request, and Connections the
+	// requests and sockets they have open now.
+	LastSeen    *time.Time `json:"lastSeen,omitempty"`
+	Connections int64      `json:"connections,omitempty"`
+	Sandboxes   int64      `json:"sandboxes,omitempty"`
+}
+
+type AdminUsersResponse struct {
+	Users []AdminUserInfo `json:"users,omitempty"`
+}
+
+// AdminWorkspaceInfo describes an active workspace.
+type AdminWorkspaceInfo struct {
+	ID    string `json:"id,omitempty"`
+	Owner string `json:"owner,omitempty"`
+	// LastSeen is the time of the last request for the workspace, and
+	// Connections the requests and sockets open now.
+	LastSeen    any   `json:"lastSeen,omitempty"`
+	Connections int64 `json:"connections,omitempty"`
+	Sandboxes   int64 `json:"sandboxes,omitempty"`
+	Terminals   int64 `json:"terminals,omitempty"`
+	// Running reports that the workspace's container is running.
+	Running bool `json:"running,omitempty"`
+}
+
+type AdminWorkspacesResponse struct {
+	Workspaces []AdminWorkspaceInfo `json:"workspaces,omitempty"`
+}
+
+// AnalyticsDay is the usage of one day.
+type AnalyticsDay struct {
+	Date string `json:"date,omitempty"`
+	// ActiveUsers is how many signed-in users did anything: ran a program,
+	// saved a file, opened one for editing and the like. For a language, it is
+	// how many ran programs in it.
+	ActiveUsers int64 `json:"activeUsers,omitempty"`
+	Runs        int64 `json:"runs,omitempty"`
+	FailedRuns  int64 `json:"failedRuns,omitempty"`
+	// Builds counts the runs that built their program rather than taking it
+	// from the cache, and AverageBuildMS is how long that took.
+	Builds         int64 `json:"builds,omitempty"`
+	AverageBuildMs int64 `json:"averageBuildMs,omitempty"`
+	// Withheld says the runs were made by fewer than the report's MinUsers
+	// users, so their figures are left out.
+	Withheld bool `json:"withheld,omitempty"`
+}
+
+// AnalyticsDeploymentParams are the query and header parameters of AnalyticsDeployment; empty ones are not sent.
+type AnalyticsDeploymentParams struct {
+	To   string // ?to
+	From string // ?from
+	Org  string // ?org
+}
+
+func (p *AnalyticsDeploymentParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.To != "" {
+		q.Set("to", p.To)
+	}
+	if p.From != "" {
+		q.Set("from", p.From)
+	}
+	if p.Org != "" {
+		q.Set("org", p.Org)
+	}
+	return q, h
+}
+
+// AnalyticsLanguage is the usage of one language.
+type AnalyticsLanguage struct {
+	Language string `json:"language,omitempty"`
+	// ActiveUsers is how many signed-in users did anything: ran a program,
+	// saved a file, opened one for editing and the like. For a language, it is
+	// how many ran programs in it.
+	ActiveUsers int64 `json:"activeUsers,omitempty"`
+	Runs        int64 `json:"runs,omitempty"`
+	FailedRuns  int64 `json:"failedRuns,omitempty"`
+	// Builds counts the runs that built their program rather than taking it
+	// from the cache, and AverageBuildMS is how long that took.
+	Builds         int64 `json:"builds,omitempty"`
+	AverageBuildMs int64 `json:"averageBuildMs,omitempty"`
+	// Withheld says the runs were made by fewer than the report's MinUsers
+	// users, so their figures are left out.
+	Withheld bool `json:"withheld,omitempty"`
+	// Share is the fraction of the report's runs in the language.
+	Share float64 `json:"share,omitempty"`
+}
+
+// AnalyticsOrgParams are the query and header parameters of AnalyticsOrg; empty ones are not sent.
+type AnalyticsOrgParams struct {
+	To   string // ?to
+	From string // ?from
+}
+
+func (p *AnalyticsOrgParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.To != "" {
+		q.Set("to", p.To)
+	}
+	if p.From != "" {
+		q.Set("from", p.From)
+	}
+	return q, h
+}
+
+// AnalyticsReport sums up the usage of the deployment, or of an organization,
+// over a period.
+type AnalyticsReport struct {
+	Org  string `json:"org,omitempty"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// MinUsers is the fewest users whose figures the report shows.
+	MinUsers int64          `json:"minUsers,omitempty"`
+	Totals   AnalyticsUsage `json:"totals,omitempty"`
+	// Days are every day of the period, oldest first.
+	Days []AnalyticsDay `json:"days,omitempty"`
+	// Languages are the most used first; withheld ones come last.
+	Languages []AnalyticsLanguage `json:"languages,omitempty"`
+	// Assignments, for organizations, are their assignments, newest first,
+	// with the submissions of the period.
+	Assignments []AssignmentStats `json:"assignments,omitempty"`
+}
+
+// AnalyticsUsage is what users did in a day, in a language or over a whole
+// report.
+type AnalyticsUsage struct {
+	// ActiveUsers is how many signed-in users did anything: ran a program,
+	// saved a file, opened one for editing and the like. For a language, it is
+	// how many ran programs in it.
+	ActiveUsers int64 `json:"activeUsers,omitempty"`
+	Runs        int64 `json:"runs,omitempty"`
+	FailedRuns  int64 `json:"failedRuns,omitempty"`
+	// Builds counts the runs that built their program rather than taking it
+	// from the cache, and AverageBuildMS is how long that took.
+	Builds         int64 `json:"builds,omitempty"`
+	AverageBuildMs int64 `json:"averageBuildMs,omitempty"`
+	// Withheld says the runs were made by fewer than the report's MinUsers
+	// users, so their figures are left out.
+	Withheld bool `json:"withheld,omitempty"`
+}
+
+// ApiversionChangeInfo describes a change from the version before.
+type ApiversionChangeInfo struct {
+	// Route is the pattern of the routes changed, in the version's paths;
+	// empty for changes to every route.
+	Route string `json:"route,omitempty"`
+	// Previous is the routes' path in the version before, when they moved.
+	Previous    string `json:"previous,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type ApiversionListResponse struct {
+	Versions []ApiversionVersionInfo `json:"versions,omitempty"`
+	Latest   string                  `json:"latest,omitempty"`
+}
+
+// ApiversionVersionInfo describes a version of the API in responses.
+type ApiversionVersionInfo struct {
+	Name    string `json:"name,omitempty"`
+	Default bool   `json:"default,omitempty"`
+	// Released is when the version was introduced, unset for v1.
+	Released any `json:"released,omitempty"`
+	// Deprecated is when the version was deprecated, and Sunset when it is
+	// retired, for versions with a sunset.
+	Deprecated any                    `json:"deprecated,omitempty"`
+	Sunset     any                    `json:"sunset,omitempty"`
+	Changes    []ApiversionChangeInfo `json:"changes,omitempty"`
+}
+
+// ArchiveExportParams are the query and header parameters of ArchiveExport; empty ones are not sent.
+type ArchiveExportParams struct {
+	Format string // ?format
+	All    string // ?all
+}
+
+func (p *ArchiveExportParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Format != "" {
+		q.Set("format", p.Format)
+	}
+	if p.All != "" {
+		q.Set("all", p.All)
+	}
+	return q, h
+}
+
+// ArchiveImportArchiveParams are the query and header parameters of ArchiveImportArchive; empty ones are not sent.
+type ArchiveImportArchiveParams struct {
+	ID string // ?id
+}
+
+func (p *ArchiveImportArchiveParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.ID != "" {
+		q.Set("id", p.ID)
+	}
+	return q, h
+}
+
+type ArchiveImportArchiveResponse struct {
+	ID    string `json:"id,omitempty"`
+	Files int64  `json:"files,omitempty"`
+}
+
+// ArchiveStoreExportParams are the query and header parameters of ArchiveStoreExport; empty ones are not sent.
+type ArchiveStoreExportParams struct {
+	Format string // ?format
+	All    string // ?all
+}
+
+func (p *ArchiveStoreExportParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Format != "" {
+		q.Set("format", p.Format)
+	}
+	if p.All != "" {
+		q.Set("all", p.All)
+	}
+	return q, h
+}
+
+// Artifact is a stored output.
+type Artifact struct {
+	ID string `json:"id,omitempty"`
+	// One of archive, binary, coverage, profile, trace.
+	Kind        string `json:"kind,omitempty"`
+	Name        string `json:"name,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Sha256      string `json:"sha256,omitempty"`
+	// Workspace is the workspace the artifact was made from, if any, and Owner
+	// the user who made it.
+	Workspace string `json:"workspace,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	// Labels describe the artifact further, such as the target of a binary.
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt *time.Time        `json:"createdAt,omitempty"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+	// URL downloads the artifact without an access token until it expires. It
+	// is not stored.
+	URL string `json:"url,omitempty"`
+}
+
+// ArtifactDownloadParams are the query and header parameters of ArtifactDownload; empty ones are not sent.
+type ArtifactDownloadParams struct {
+	Sig     string // ?sig
+	Expires string // ?expires
+	Inline  string // ?inline
+}
+
+func (p *ArtifactDownloadParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Sig != "" {
+		q.Set("sig", p.Sig)
+	}
+	if p.Expires != "" {
+		q.Set("expires", p.Expires)
+	}
+	if p.Inline != "" {
+		q.Set("inline", p.Inline)
+	}
+	return q, h
+}
+
+type ArtifactExtendRequest struct {
+	// TTLSeconds is how long from now to keep the artifact.
+	TTLSeconds int64 `json:"ttlSeconds,omitempty"`
+}
+
+// ArtifactListParams are the query and header parameters of ArtifactList; empty ones are not sent.
+type ArtifactListParams struct {
+	Kind string // ?kind
+}
+
+func (p *ArtifactListParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Kind != "" {
+		q.Set("kind", p.Kind)
+	}
+	return q, h
+}
+
+type ArtifactListResponse struct {
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+}
+
+// ArtifactListWorkspaceParams are the query and header parameters of ArtifactListWorkspace; empty ones are not sent.
+type ArtifactListWorkspaceParams struct {
+	Kind string // ?kind
+}
+
+func (p *ArtifactListWorkspaceParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Kind != "" {
+		q.Set("kind", p.Kind)
+	}
+	return q, h
+}
+
+type ArtifactListWorkspaceResponse struct {
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+}
+
+// Assignment is a task graded by hidden tests.
+type Assignment struct {
+	ID          string `json:"id,omitempty"`
+	Org         string `json:"org,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Module is the directory of the Go module relative to the workspace root;
+	// defaults to the root. The tests must be inside it.
+	Module string `json:"module,omitempty"`
+	// Tests are the hidden test files; only instructors see them. They replace
+	// every test file of the submitted workspace.
+	Tests []AssignmentFile `json:"tests,omitempty"`
+	// Points are what each top-level test is worth, by name; tests not listed
+	// are worth 1.
+	Points map[string]int64 `json:"points,omitempty"`
+	// Due, when set, is when submissions close.
+	Due any `json:"due,omitempty"`
+	// MaxSubmissions caps each student's submissions; 0 allows any number.
+	MaxSubmissions int64 `json:"maxSubmissions,omitempty"`
+	// TimeoutMS bounds the test run, as gotest.Request.TimeoutMS.
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+	// Required are files a submission must have, relative to the workspace
+	// root.
+	Required []string `json:"required,omitempty"`
+	// ForbiddenImports are packages the submission's own Go files may not
+	// import, such as "os/exec"; a path ending in "/..." forbids the packages
+	// under it too.
+	ForbiddenImports []string `json:"forbiddenImports,omitempty"`
+	// ShowOutput shows students the output of the tests and of go test, which
+	// can give away what the hidden tests check.
+	ShowOutput bool `json:"showOutput,omitempty"`
+	// MaxScore is the sum of the points of the hidden tests.
+	MaxScore  int64      `json:"maxScore,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+}
+
+// AssignmentFile is a hidden test file.
+type AssignmentFile struct {
+	// Path is relative to the workspace root and ends in _test.go.
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// AssignmentGrade is a student's standing on an assignment, from their graded
+// submissions.
+type AssignmentGrade struct {
+	User        string `json:"user,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Submissions int64  `json:"submissions,omitempty"`
+	// Best is the highest score and Latest the last graded submission.
+	Best     int64                 `json:"best,omitempty"`
+	MaxScore int64                 `json:"maxScore,omitempty"`
+	Latest   *AssignmentSubmission `json:"latest,omitempty"`
+}
+
+type AssignmentGradesResponse struct {
+	Grades []AssignmentGrade `json:"grades,omitempty"`
+}
+
+type AssignmentListResponse struct {
+	Assignments []Assignment `json:"assignments,omitempty"`
+}
+
+// AssignmentStats sums up the submissions to an assignment over a period,
+// without telling the students apart.
+type AssignmentStats struct {
+	Assignment string `json:"assignment,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Due        any    `json:"due,omitempty"`
+	MaxScore   int64  `json:"maxScore,omitempty"`
+	// Submissions counts the submissions of the period, Graded those graded
+	// and Failed those that could not be.
+	Submissions int64 `json:"submissions,omitempty"`
+	Graded      int64 `json:"graded,omitempty"`
+	Failed      int64 `json:"failed,omitempty"`
+	// Students is how many students submitted, and Completed how many of them
+	// reached MaxScore.
+	Students  int64 `json:"students,omitempty"`
+	Completed int64 `json:"completed,omitempty"`
+	// AverageScore is the mean of each student's best score, and
+	// AverageDurationMS how long grading took on average.
+	AverageScore      float64 `json:"averageScore,omitempty"`
+	AverageDurationMs int64   `json:"averageDurationMs,omitempty"`
+}
+
+// AssignmentSubmission is a workspace submitted for grading.
+type AssignmentSubmission struct {
+	ID         string                   `json:"id,omitempty"`
+	Assignment string                   `json:"assignment,omitempty"`
+	Workspace  string                   `json:"workspace,omitempty"`
+	User       string                   `json:"user,omitempty"`
+	Email      string                   `json:"email,omitempty"`
+	Time       *time.Time               `json:"time,omitempty"`
+	Status     string                   `json:"status,omitempty"`
+	Score      int64                    `json:"score,omitempty"`
+	MaxScore   int64                    `json:"maxScore,omitempty"`
+	Tests      []AssignmentTestFeedback `json:"tests,omitempty"`
+	// Violations are the constraints the submission broke, such as a missing
+	// file or a forbidden import. A submission with violations is not tested
+	// and scores 0.
+	Violations []string `json:"violations,omitempty"`
+	// Output is what go test printed outside of the tests, such as build
+	// errors.
+	Output     string `json:"output,omitempty"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	GradedAt   any    `json:"gradedAt,omitempty"`
+}
+
+// AssignmentSubmissionsParams are the query and header parameters of AssignmentSubmissions; empty ones are not sent.
+type AssignmentSubmissionsParams struct {
+	User string // ?user
+}
+
+func (p *AssignmentSubmissionsParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.User != "" {
+		q.Set("user", p.User)
+	}
+	return q, h
+}
+
+type AssignmentSubmissionsResponse struct {
+	Submissions []AssignmentSubmission `json:"submissions,omitempty"`
+}
+
+type AssignmentSubmitRequest struct {
+	Workspace string `json:"workspace,omitempty"`
+}
+
+// AssignmentTestFeedback is the outcome of one hidden test.
+type AssignmentTestFeedback struct {
+	Name      string `json:"name,omitempty"`
+	Package   string `json:"package,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Points    int64  `json:"points,omitempty"`
+	MaxPoints int64  `json:"maxPoints,omitempty"`
+	// Output is the test's output, truncated to Config.MaxOutputBytes.
+	Output string `json:"output,omitempty"`
+}
+
+// AuditEntry is one recorded action.
+type AuditEntry struct {
+	Time   *time.Time `json:"time,omitempty"`
+	Action string     `json:"action,omitempty"`
+	// User and Email are who acted; empty for requests without a signed-in
+	// user.
+	User  string `json:"user,omitempty"`
+	Email string `json:"email,omitempty"`
+	IP    string `json:"ip,omitempty"`
+	// Workspace and Org are where, and Target what was acted on, such as a
+	// file path, a secret or a share link.
+	Workspace string            `json:"workspace,omitempty"`
+	Org       string            `json:"org,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// AuditExportParams are the query and header parameters of AuditExport; empty ones are not sent.
+type AuditExportParams struct {
+	User      string // ?user
+	Workspace string // ?workspace
+	Org       string // ?org
+	Action    string // ?action
+}
+
+func (p *AuditExportParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.User != "" {
+		q.Set("user", p.User)
+	}
+	if p.Workspace != "" {
+		q.Set("workspace", p.Workspace)
+	}
+	if p.Org != "" {
+		q.Set("org", p.Org)
+	}
+	if p.Action != "" {
+		q.Set("action", p.Action)
+	}
+	return q, h
+}
+
+// AuditQueryParams are the query and header parameters of AuditQuery; empty ones are not sent.
+type AuditQueryParams struct {
+	User      string // ?user
+	Workspace string // ?workspace
+	Org       string // ?org
+	Action    string // ?action
+	Limit     string // ?limit
+}
+
+func (p *AuditQueryParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.User != "" {
+		q.Set("user", p.User)
+	}
+	if p.Workspace != "" {
+		q.Set("workspace", p.Workspace)
+	}
+	if p.Org != "" {
+		q.Set("org", p.Org)
+	}
+	if p.Action != "" {
+		q.Set("action", p.Action)
+	}
+	if p.Limit != "" {
+		q.Set("limit", p.Limit)
+	}
+	return q, h
+}
+
+type AuditQueryResponse struct {
+	Entries []AuditEntry `json:"entries,omitempty"`
+}
+
+// AuthAPIToken describes a personal access token. The token itself is shown
+// once, when it is created; only its hash is stored.
+type AuthAPIToken struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Scope string `json:"scope,omitempty"`
+	// Prefix is the start of the token, to tell tokens apart.
+	Prefix     string     `json:"prefix,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	LastUsedAt any        `json:"lastUsedAt,omitempty"`
+	ExpiresAt  any        `json:"expiresAt,omitempty"`
+}
+
+type AuthCreateTokenRequest struct {
+	Name  string `json:"name,omitempty"`
+	Scope string `json:"scope,omitempty"`
+	// ExpiresInDays bounds the token's lifetime; without it the token does not
+	// expire.
+	ExpiresInDays int64 `json:"expiresInDays,omitempty"`
+}
+
+type AuthCreateTokenResponse struct {
+	Token string        `json:"token,omitempty"`
+	Info  *AuthAPIToken `json:"info,omitempty"`
+}
+
+type AuthCredentials struct {
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password,omitempty"`
+	Name     string `json:"name,omitempty"`
+	// Cookie asks for the tokens as HttpOnly cookies instead of in the
+	// response body.
+	Cookie bool `json:"cookie,omitempty"`
+}
+
+type AuthIdentitiesResponse struct {
+	Identities []AuthIdentity `json:"identities,omitempty"`
+}
+
+// AuthIdentity is a provider account linked to a user.
+type AuthIdentity struct {
+	Provider string `json:"provider,omitempty"`
+	// Subject is the account's ID at the provider.
+	Subject  string     `json:"subject,omitempty"`
+	Login    string     `json:"login,omitempty"`
+	Email    string     `json:"email,omitempty"`
+	Scopes   []string   `json:"scopes,omitempty"`
+	LinkedAt *time.Time `json:"linkedAt,omitempty"`
+}
+
+type AuthListTokensResponse struct {
+	Tokens []AuthAPIToken `json:"tokens,omitempty"`
+}
+
+type AuthMeResponse struct {
+	ID        string     `json:"id,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	Admin     bool       `json:"admin,omitempty"`
+}
+
+// AuthOauthCallbackParams are the query and header parameters of AuthOauthCallback; empty ones are not sent.
+type AuthOauthCallbackParams struct {
+	Error            string // ?error
+	ErrorDescription string // ?error_description
+	State            string // ?state
+	Code             string // ?code
+}
+
+func (p *AuthOauthCallbackParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Error != "" {
+		q.Set("error", p.Error)
+	}
+	if p.ErrorDescription != "" {
+		q.Set("error_description", p.ErrorDescription)
+	}
+	if p.State != "" {
+		q.Set("state", p.State)
+	}
+	if p.Code != "" {
+		q.Set("code", p.Code)
+	}
+	return q, h
+}
+
+// AuthOauthStartParams are the query and header parameters of AuthOauthStart; empty ones are not sent.
+type AuthOauthStartParams struct {
+	Link     string // ?link
+	Redirect string // ?redirect
+}
+
+func (p *AuthOauthStartParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Link != "" {
+		q.Set("link", p.Link)
+	}
+	if p.Redirect != "" {
+		q.Set("redirect", p.Redirect)
+	}
+	return q, h
+}
+
+type AuthProvidersResponse struct {
+	Providers []string `json:"providers,omitempty"`
+}
+
+type AuthRefreshRequest struct {
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// BootstrapDotfiles is a user's dotfiles repository, installed in the
+// sandboxes of the workspaces they own.
+type BootstrapDotfiles struct {
+	// Repository is an https Git URL, or "owner/name" for a GitHub repository.
+	Repository string `json:"repository,omitempty"`
+	// Ref is the branch or tag to clone; defaults to the repository's default
+	// branch.
+	Ref string `json:"ref,omitempty"`
+	// Install is the script in the repository that installs it. When empty the
+	// first of the usual names is run, and without one the repository's
+	// top-level dotfiles are linked into the home directory.
+	Install   string     `json:"install,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// BootstrapStatus describes the last setup of a workspace.
+type BootstrapStatus struct {
+	State string `json:"state,omitempty"`
+	// Dotfiles is the repository that was installed, if any.
+	Dotfiles   string `json:"dotfiles,omitempty"`
+	StartedAt  any    `json:"startedAt,omitempty"`
+	FinishedAt any    `json:"finishedAt,omitempty"`
+	ExitCode   int64  `json:"exitCode,omitempty"`
+	// Error is why the setup could not run at all.
+	Error        string `json:"error,omitempty"`
+	LogTruncated bool   `json:"logTruncated,omitempty"`
+}
+
+// CollabID identifies one character of a document. Seq is a Lamport timestamp
+// and Site the replica that created the character; together they are unique.
+type CollabID struct {
+	Site string `json:"site,omitempty"`
+	Seq  int64  `json:"seq,omitempty"`
+}
+
+// CollabPosition is a caret location that survives concurrent edits: it sits
+// immediately after the character After, or at the start when After is nil.
+type CollabPosition struct {
+	After *CollabID `json:"after,omitempty"`
+}
+
+// CollabPresence describes one peer of a document.
+type CollabPresence struct {
+	Site      string           `json:"site,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Color     string           `json:"color,omitempty"`
+	Path      string           `json:"path,omitempty"`
+	Selection *CollabSelection `json:"selection,omitempty"`
+}
+
+type CollabPresenceResponse struct {
+	Users []CollabPresence `json:"users,omitempty"`
+}
+
+// CollabSelection is a peer's caret (Head) and the other end of its selection
+// (Anchor). They are equal when nothing is selected.
+type CollabSelection struct {
+	Anchor CollabPosition `json:"anchor,omitempty"`
+	Head   CollabPosition `json:"head,omitempty"`
+}
+
+// ConfigChange is a setting Reload found changed in the file.
+type ConfigChange struct {
+	Key string `json:"key,omitempty"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// Live reports that the change took effect. The others wait for a restart,
+	// or, when Overridden, for the variable to be unset.
+	Live       bool `json:"live,omitempty"`
+	Overridden bool `json:"overridden,omitempty"`
+}
+
+type ConfigResponse struct {
+	File     string          `json:"file,omitempty"`
+	LoadedAt *time.Time      `json:"loadedAt,omitempty"`
+	Settings []ConfigSetting `json:"settings,omitempty"`
+}
+
+// ConfigResult is what a Reload did.
+type ConfigResult struct {
+	LoadedAt *time.Time     `json:"loadedAt,omitempty"`
+	Changes  []ConfigChange `json:"changes,omitempty"`
+	// Errors are those of reload hooks that could not apply their settings;
+	// the others did.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ConfigSetting is one setting of the configuration.
+type ConfigSetting struct {
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Source is "env", "file" or "" for a setting left to its default.
+	Source string `json:"source,omitempty"`
+	Live   bool   `json:"live,omitempty"`
+	// Unused marks settings of the file nothing reads.
+	Unused bool `json:"unused,omitempty"`
+}
+
+// DevserveOptions describe the server a dev session runs.
+type DevserveOptions struct {
+	// Package is the main package to build, relative to the workspace root;
+	// defaults to ".".
+	Package string `json:"package,omitempty"`
+	// Args are passed to the server.
+	Args []string `json:"args,omitempty"`
+	// Env is extra environment of the server, as "KEY=value".
+	Env []string `json:"env,omitempty"`
+	// Port is the port the server listens on. Its previews are reloaded after
+	// each restart, and PORT is set to it unless Env sets it.
+	Port int64 `json:"port,omitempty"`
+	// Extensions are the file name extensions whose changes restart the
+	// server; defaults to DefaultExtensions. Test files never do.
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+// DevserveStatus describes a workspace's dev server.
+type DevserveStatus struct {
+	// One of building, exited, failed, running, stopped.
+	State     string           `json:"state,omitempty"`
+	Options   *DevserveOptions `json:"options,omitempty"`
+	StartedAt any              `json:"startedAt,omitempty"`
+	// Builds counts the builds of the session, the first included.
+	Builds   int64  `json:"builds,omitempty"`
+	ExitCode *int64 `json:"exitCode,omitempty"`
+}
+
+// DiagEdit replaces Range of File with NewText.
+type DiagEdit struct {
+	File    string    `json:"file,omitempty"`
+	Range   DiagRange `json:"range,omitempty"`
+	NewText string    `json:"newText,omitempty"`
+}
+
+// DiagFix is a suggested change resolving a diagnostic.
+type DiagFix struct {
+	Message string     `json:"message,omitempty"`
+	Edits   []DiagEdit `json:"edits,omitempty"`
+}
+
+// DiagPosition is a 1-based line and column; columns count bytes, as in the Go
+// tools' output.
+type DiagPosition struct {
+	Line   int64 `json:"line,omitempty"`
+	Column int64 `json:"column,omitempty"`
+}
+
+// DiagRange is a span of a file. End equals Start when the tool reports a
+// single position.
+type DiagRange struct {
+	Start DiagPosition `json:"start,omitempty"`
+	End   DiagPosition `json:"end,omitempty"`
+}
+
+// Diagnostic is one finding. File is relative to the workspace or module root.
+// Source names the tool and Code the check within it, such as "printf" for vet
+// or "SA4006" for staticcheck.
+type Diagnostic struct {
+	File  string    `json:"file,omitempty"`
+	Range DiagRange `json:"range,omitempty"`
+	// One of error, info, warning.
+	Severity string    `json:"severity,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Code     string    `json:"code,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Fixes    []DiagFix `json:"fixes,omitempty"`
+}
+
+// DiffConflict is a place where both sides changed the base differently. Start
+// and End are the 1-based lines of Merged, inclusive, covered by the conflict
+// markers.
+type DiffConflict struct {
+	Start  int64      `json:"start,omitempty"`
+	End    int64      `json:"end,omitempty"`
+	Base   DiffRegion `json:"base,omitempty"`
+	Ours   DiffRegion `json:"ours,omitempty"`
+	Theirs DiffRegion `json:"theirs,omitempty"`
+}
+
+// DiffHunk is a region of change with surrounding context. Starts are 1-based
+// line numbers; a zero count means the hunk inserts or deletes at that
+// position, in which case the start is the line before it.
+type DiffHunk struct {
+	OldStart int64      `json:"oldStart,omitempty"`
+	OldLines int64      `json:"oldLines,omitempty"`
+	NewStart int64      `json:"newStart,omitempty"`
+	NewLines int64      `json:"newLines,omitempty"`
+	Section  string     `json:"section,omitempty"`
+	Lines    []DiffLine `json:"lines,omitempty"`
+}
+
+// DiffLabels name the sides in conflict markers.
+type DiffLabels struct {
+	Ours   string `json:"ours,omitempty"`
+	Base   string `json:"base,omitempty"`
+	Theirs string `json:"theirs,omitempty"`
+}
+
+// DiffLine is one line of a hunk. Text excludes the line terminator.
+type DiffLine struct {
+	// One of , +, -.
+	Op   string `json:"op,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+type DiffMergeRequest struct {
+	Base   string     `json:"base,omitempty"`
+	Ours   string     `json:"ours,omitempty"`
+	Theirs string     `json:"theirs,omitempty"`
+	Labels DiffLabels `json:"labels,omitempty"`
+}
+
+// DiffMergeResult is the outcome of Merge3. Merged holds the combined text,
+// with diff3-style markers around each conflict.
+type DiffMergeResult struct {
+	Merged    string         `json:"merged,omitempty"`
+	Clean     bool           `json:"clean,omitempty"`
+	Conflicts []DiffConflict `json:"conflicts,omitempty"`
+}
+
+// DiffRegion is a stretch of lines from one merge input. Start is the 1-based
+// line number of the first line, or of the line before an empty region.
+type DiffRegion struct {
+	Start int64    `json:"start,omitempty"`
+	Lines []string `json:"lines,omitempty"`
+}
+
+// EgressBlocked is a destination the proxy refused a workspace's sandboxes.
+type EgressBlocked struct {
+	Host     string     `json:"host,omitempty"`
+	Port     int64      `json:"port,omitempty"`
+	Attempts int64      `json:"attempts,omitempty"`
+	FirstAt  *time.Time `json:"firstAt,omitempty"`
+	LastAt   *time.Time `json:"lastAt,omitempty"`
+}
+
+type EgressBlockedResponse struct {
+	Blocked []EgressBlocked `json:"blocked,omitempty"`
+}
+
+// EgressSettings are the hosts a workspace adds to the allowlist. An entry is
+// a host name or IP address, or "*." and a domain for its subdomains,
+// optionally followed by ":port"; without a port it allows ports 80 and 443.
+type EgressSettings struct {
+	Allow []string `json:"allow,omitempty"`
+}
+
+// EgressStatus is the network policy of a workspace.
+type EgressStatus struct {
+	Allow []string `json:"allow,omitempty"`
+	// Default is the allowlist every sandbox has.
+	Default []string `json:"default,omitempty"`
+	// Overridable is what the workspace may add.
+	Overridable []string `json:"overridable,omitempty"`
+}
+
+// EnvvarsImportDotenvParams are the query and header parameters of EnvvarsImportDotenv; empty ones are not sent.
+type EnvvarsImportDotenvParams struct {
+	Secret string // ?secret
+}
+
+func (p *EnvvarsImportDotenvParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Secret != "" {
+		q.Set("secret", p.Secret)
+	}
+	return q, h
+}
+
+type EnvvarsImportDotenvResponse struct {
+	Imported int64 `json:"imported,omitempty"`
+}
+
+type EnvvarsListResponse struct {
+	Variables []EnvvarsVariable `json:"variables,omitempty"`
+}
+
+type EnvvarsSetRequest struct {
+	Value  string `json:"value,omitempty"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// EnvvarsVariable is a workspace environment variable. The Value of a secret
+// is never returned.
+type EnvvarsVariable struct {
+	Name      string     `json:"name,omitempty"`
+	Value     string     `json:"value,omitempty"`
+	Secret    bool       `json:"secret,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+}
+
+// FilesDeleteParams are the query and header parameters of FilesDelete; empty ones are not sent.
+type FilesDeleteParams struct {
+	Recursive string // ?recursive
+	Permanent string // ?permanent
+}
+
+func (p *FilesDeleteParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Recursive != "" {
+		q.Set("recursive", p.Recursive)
+	}
+	if p.Permanent != "" {
+		q.Set("permanent", p.Permanent)
+	}
+	return q, h
+}
+
+// FilesDeleted tells where a delete went.
+type FilesDeleted struct {
+	Path string `json:"path,omitempty"`
+	// Trash is the ID of the trash item to restore it from.
+	Trash string `json:"trash,omitempty"`
+}
+
+// FilesEntry describes a file or directory within the root.
+type FilesEntry struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+	// One of dir, file, symlink.
+	Type    string     `json:"type,omitempty"`
+	Size    int64      `json:"size,omitempty"`
+	Mode    string     `json:"mode,omitempty"`
+	ModTime *time.Time `json:"modTime,omitempty"`
+}
+
+// FilesGetParams are the query and header parameters of FilesGet; empty ones are not sent.
+type FilesGetParams struct {
+	Meta        string // ?meta
+	Encoding    string // ?encoding
+	Thumbnail   string // ?thumbnail
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *FilesGetParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Meta != "" {
+		q.Set("meta", p.Meta)
+	}
+	if p.Encoding != "" {
+		q.Set("encoding", p.Encoding)
+	}
+	if p.Thumbnail != "" {
+		q.Set("thumbnail", p.Thumbnail)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+// FilesGetPathParams are the query and header parameters of FilesGetPath; empty ones are not sent.
+type FilesGetPathParams struct {
+	Meta        string // ?meta
+	Encoding    string // ?encoding
+	Thumbnail   string // ?thumbnail
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *FilesGetPathParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Meta != "" {
+		q.Set("meta", p.Meta)
+	}
+	if p.Encoding != "" {
+		q.Set("encoding", p.Encoding)
+	}
+	if p.Thumbnail != "" {
+		q.Set("thumbnail", p.Thumbnail)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+type FilesMoveRequest struct {
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+}
+
+// FilesPutParams are the query and header parameters of FilesPut; empty ones are not sent.
+type FilesPutParams struct {
+	Type        string // ?type
+	IfMatch     string // If-Match header
+	IfNoneMatch string // If-None-Match header
+	Encoding    string // ?encoding
+	Normalize   string // ?normalize
+}
+
+func (p *FilesPutParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Type != "" {
+		q.Set("type", p.Type)
+	}
+	if p.IfMatch != "" {
+		h.Set("If-Match", p.IfMatch)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	if p.Encoding != "" {
+		q.Set("encoding", p.Encoding)
+	}
+	if p.Normalize != "" {
+		q.Set("normalize", p.Normalize)
+	}
+	return q, h
+}
+
+// FlagsCheckParams are the query and header parameters of FlagsCheck; empty ones are not sent.
+type FlagsCheckParams struct {
+	User string // ?user
+}
+
+func (p *FlagsCheckParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.User != "" {
+		q.Set("user", p.User)
+	}
+	return q, h
+}
+
+type FlagsCheckResponse struct {
+	Flag    string `json:"flag,omitempty"`
+	User    string `json:"user,omitempty"`
+	Enabled bool   `json:"enabled,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// FlagsFlag is a flag with what it is for and its rule.
+type FlagsFlag struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Enabled turns the flag on for everyone.
+	Enabled bool `json:"enabled,omitempty"`
+	// Percentage turns it on for this share of signed-in users, from 0 to 100.
+	Percentage float64 `json:"percentage,omitempty"`
+	// Users are user IDs or email addresses.
+	Users []string `json:"users,omitempty"`
+	// Orgs are organization IDs whose members have the flag.
+	Orgs []string `json:"orgs,omitempty"`
+	// Default is the server's rule; Set reports that administrators set one
+	// over it.
+	Default   FlagsRule `json:"default,omitempty"`
+	Set       bool      `json:"set,omitempty"`
+	UpdatedAt any       `json:"updatedAt,omitempty"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+}
+
+type FlagsListResponse struct {
+	Flags []FlagsFlag `json:"flags,omitempty"`
+}
+
+type FlagsMineResponse struct {
+	Flags map[string]bool `json:"flags,omitempty"`
+}
+
+// FlagsRule says whom a flag is on for. It is on for a user when any of its
+// fields says so.
+type FlagsRule struct {
+	// Enabled turns the flag on for everyone.
+	Enabled bool `json:"enabled,omitempty"`
+	// Percentage turns it on for this share of signed-in users, from 0 to 100.
+	Percentage float64 `json:"percentage,omitempty"`
+	// Users are user IDs or email addresses.
+	Users []string `json:"users,omitempty"`
+	// Orgs are organization IDs whose members have the flag.
+	Orgs []string `json:"orgs,omitempty"`
+}
+
+// ForkRequest describes a fork.
+type ForkRequest struct {
+	// ID is the new workspace's; a random one when empty.
+	ID string `json:"id,omitempty"`
+	// All copies every file, .git and what .gitignore matches included, rather
+	// than what an export takes by default.
+	All bool `json:"all,omitempty"`
+	// Exclude are more .gitignore-style patterns to leave out.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// ForkResult describes a new fork.
+type ForkResult struct {
+	ID     string `json:"id,omitempty"`
+	Source string `json:"source,omitempty"`
+	Files  int64  `json:"files,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	// Variables counts the variables copied, and Secrets the secret ones left
+	// behind.
+	Variables int64 `json:"variables,omitempty"`
+	Secrets   int64 `json:"secrets,omitempty"`
+	// Settings are the run settings copied.
+	Settings []string `json:"settings,omitempty"`
+}
+
+// FormatEdit replaces the 1-based lines [StartLine, EndLine) with Text. An
+// edit with EndLine == StartLine inserts before StartLine.
+type FormatEdit struct {
+	StartLine int64  `json:"startLine,omitempty"`
+	EndLine   int64  `json:"endLine,omitempty"`
+	Text      string `json:"text,omitempty"`
+}
+
+// FormatFileParams are the query and header parameters of FormatFile; empty ones are not sent.
+type FormatFileParams struct {
+	Formatter string // ?formatter
+}
+
+func (p *FormatFileParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Formatter != "" {
+		q.Set("formatter", p.Formatter)
+	}
+	return q, h
+}
+
+type FormatRequest struct {
+	Source string `json:"source,omitempty"`
+	// Formatter defaults to the workspace's setting, or gofmt. One of gofmt,
+	// goimports.
+	Formatter string `json:"formatter,omitempty"`
+	// Workspace and Path give goimports the context to resolve imports in.
+	Workspace string `json:"workspace,omitempty"`
+	Path      string `json:"path,omitempty"`
+	// Edits asks for an edit list in addition to the formatted text.
+	Edits bool `json:"edits,omitempty"`
+}
+
+// FormatResult is the outcome of formatting.
+type FormatResult struct {
+	// One of gofmt, goimports.
+	Formatter string `json:"formatter,omitempty"`
+	Formatted string `json:"formatted,omitempty"`
+	Changed   bool   `json:"changed,omitempty"`
+	// Edits turn the input into Formatted, applied in order from the bottom up
+	// or with line numbers adjusted as they are applied.
+	Edits []FormatEdit `json:"edits,omitempty"`
+}
+
+// FormatSettings is a workspace's formatting configuration.
+type FormatSettings struct {
+	// One of gofmt, goimports.
+	Formatter string `json:"formatter,omitempty"`
+}
+
+// GalleryExample describes one starter.
+type GalleryExample struct {
+	ID          string   `json:"id,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// Files are the paths the example's files get in a workspace.
+	Files []string `json:"files,omitempty"`
+}
+
+// GalleryFile is one file of an example with its contents.
+type GalleryFile struct {
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type GalleryGetResponse struct {
+	Example *GalleryExample `json:"example,omitempty"`
+	Files   []GalleryFile   `json:"files,omitempty"`
+}
+
+// GalleryListParams are the query and header parameters of GalleryList; empty ones are not sent.
+type GalleryListParams struct {
+	Tag string // ?tag
+}
+
+func (p *GalleryListParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Tag != "" {
+		q.Set("tag", p.Tag)
+	}
+	return q, h
+}
+
+type GalleryListResponse struct {
+	Examples []GalleryExample `json:"examples,omitempty"`
+}
+
+type GalleryOpenRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+type GalleryOpenResponse struct {
+	ID      string          `json:"id,omitempty"`
+	Example *GalleryExample `json:"example,omitempty"`
+}
+
+type GhimportCreateRequest struct {
+	// URL is https://github.com/owner/repo, optionally with .git or
+	// /tree/<branch>, github.com/owner/repo, or just owner/repo.
+	URL string `json:"url,omitempty"`
+	// Ref is the branch to check out; defaults to the one in URL, then to the
+	// repository's default branch.
+	Ref string `json:"ref,omitempty"`
+	// Token authenticates the clone of a private repository, such as an OAuth
+	// or personal access token.
+	Token string `json:"token,omitempty"`
+	// Depth makes a shallow clone when positive.
+	Depth int64 `json:"depth,omitempty"`
+	// ID names the new workspace; a random one is assigned without it.
+	ID string `json:"id,omitempty"`
+}
+
+// GhimportImport is the outcome of importing a repository.
+type GhimportImport struct {
+	WorkspaceID string             `json:"workspaceId,omitempty"`
+	Repository  GhimportRepository `json:"repository,omitempty"`
+	Layout      GhimportLayout     `json:"layout,omitempty"`
+	Warmup      GhimportWarmup     `json:"warmup,omitempty"`
+	CreatedAt   *time.Time         `json:"createdAt,omitempty"`
+}
+
+// GhimportLayout describes how the repository is organized as Go code.
+type GhimportLayout struct {
+	Modules []GhimportModule `json:"modules,omitempty"`
+	// Work reports a go.work file at the root.
+	Work bool `json:"work,omitempty"`
+}
+
+// GhimportModule is a Go module found in the repository.
+type GhimportModule struct {
+	// Dir is the module's directory relative to the workspace root, "." for
+	// the root.
+	Dir       string `json:"dir,omitempty"`
+	Path      string `json:"path,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+// GhimportRepository identifies a GitHub repository.
+type GhimportRepository struct {
+	Owner string `json:"owner,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Ref   string `json:"ref,omitempty"`
+}
+
+// GhimportWarmup is the progress of downloading dependencies.
+type GhimportWarmup struct {
+	State      string `json:"state,omitempty"`
+	Output     string `json:"output,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+}
+
+// Gist is a gist as the API returns it, with its files in name order.
+type Gist struct {
+	ID          string     `json:"id,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Public      bool       `json:"public,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Files       []GistFile `json:"files,omitempty"`
+}
+
+type GistExportRequest struct {
+	Token       string `json:"token,omitempty"`
+	Description string `json:"description,omitempty"`
+	Public      bool   `json:"public,omitempty"`
+	// Paths are the workspace files to publish; gists are flat, so they become
+	// files named after their base names.
+	Paths []string `json:"paths,omitempty"`
+}
+
+// GistFile is one file of a gist.
+type GistFile struct {
+	Name    string `json:"name,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type GistImportFilesResponse struct {
+	Gist  string       `json:"gist,omitempty"`
+	Files []FilesEntry `json:"files,omitempty"`
+}
+
+type GistImportRequest struct {
+	// Gist is a gist ID or URL.
+	Gist  string `json:"gist,omitempty"`
+	Token string `json:"token,omitempty"`
+	// Dir is the workspace directory the files are written to.
+	Dir string `json:"dir,omitempty"`
+	// Overwrite replaces existing files instead of failing.
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+// GitAuthor identifies who made a commit.
+type GitAuthor struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// GitBranch is a local or remote-tracking branch.
+type GitBranch struct {
+	Name     string `json:"name,omitempty"`
+	Head     string `json:"head,omitempty"`
+	Current  bool   `json:"current,omitempty"`
+	Remote   bool   `json:"remote,omitempty"`
+	Upstream string `json:"upstream,omitempty"`
+}
+
+type GitBranchesResponse struct {
+	Branches []GitBranch `json:"branches,omitempty"`
+}
+
+type GitCloneRequest struct {
+	URL    string          `json:"url,omitempty"`
+	Branch string          `json:"branch,omitempty"`
+	Depth  int64           `json:"depth,omitempty"`
+	Auth   *GitCredentials `json:"auth,omitempty"`
+}
+
+// GitCommit is one entry of the history.
+type GitCommit struct {
+	Hash    string     `json:"hash,omitempty"`
+	Parents []string   `json:"parents,omitempty"`
+	Author  GitAuthor  `json:"author,omitempty"`
+	Time    *time.Time `json:"time,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type GitCommitRequest struct {
+	Message string    `json:"message,omitempty"`
+	Author  GitAuthor `json:"author,omitempty"`
+}
+
+type GitCommitResponse struct {
+	Hash string `json:"hash,omitempty"`
+}
+
+// GitCredentials authenticate against an HTTPS remote. An empty Username is
+// sent as "x-access-token", which token-based hosts such as GitHub accept.
+type GitCredentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// GitDiffParams are the query and header parameters of GitDiff; empty ones are not sent.
+type GitDiffParams struct {
+	From    string // ?from
+	To      string // ?to
+	Staged  string // ?staged
+	Context string // ?context
+}
+
+func (p *GitDiffParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.From != "" {
+		q.Set("from", p.From)
+	}
+	if p.To != "" {
+		q.Set("to", p.To)
+	}
+	if p.Staged != "" {
+		q.Set("staged", p.Staged)
+	}
+	if p.Context != "" {
+		q.Set("context", p.Context)
+	}
+	return q, h
+}
+
+type GitDiffResponse struct {
+	Files []GitFileDiff `json:"files,omitempty"`
+}
+
+// GitFileDiff is the difference for one path.
+type GitFileDiff struct {
+	Path    string `json:"path,omitempty"`
+	OldPath string `json:"oldPath,omitempty"`
+	// One of , added, copied, deleted, modified, renamed, typechange,
+	// unmerged, untracked.
+	Status string     `json:"status,omitempty"`
+	Binary bool       `json:"binary,omitempty"`
+	Hunks  []DiffHunk `json:"hunks,omitempty"`
+}
+
+// GitFileStatus is one changed path. Staged is the change recorded in the
+// index relative to HEAD, Unstaged the change in the working tree relative to
+// the index.
+type GitFileStatus struct {
+	Path     string `json:"path,omitempty"`
+	OrigPath string `json:"origPath,omitempty"`
+	// One of , added, copied, deleted, modified, renamed, typechange,
+	// unmerged, untracked.
+	Staged string `json:"staged,omitempty"`
+	// One of , added, copied, deleted, modified, renamed, typechange,
+	// unmerged, untracked.
+	Unstaged   string `json:"unstaged,omitempty"`
+	Conflicted bool   `json:"conflicted,omitempty"`
+}
+
+// GitLogParams are the query and header parameters of GitLog; empty ones are not sent.
+type GitLogParams struct {
+	Limit string // ?limit
+	Skip  string // ?skip
+}
+
+func (p *GitLogParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Limit != "" {
+		q.Set("limit", p.Limit)
+	}
+	if p.Skip != "" {
+		q.Set("skip", p.Skip)
+	}
+	return q, h
+}
+
+type GitLogResponse struct {
+	Commits []GitCommit `json:"commits,omitempty"`
+}
+
+// GitStatus summarizes the working tree.
+type GitStatus struct {
+	// Branch is empty when HEAD is detached.
+	Branch   string          `json:"branch,omitempty"`
+	Head     string          `json:"head,omitempty"`
+	Upstream string          `json:"upstream,omitempty"`
+	Ahead    int64           `json:"ahead,omitempty"`
+	Behind   int64           `json:"behind,omitempty"`
+	Files    []GitFileStatus `json:"files,omitempty"`
+}
+
+type GitSwitchRequest struct {
+	Branch string `json:"branch,omitempty"`
+	Create bool   `json:"create,omitempty"`
+}
+
+// GoastDefinitionParams are the query and header parameters of GoastDefinition; empty ones are not sent.
+type GoastDefinitionParams struct {
+	Line   string // ?line
+	Column string // ?column
+	Path   string // ?path
+}
+
+func (p *GoastDefinitionParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Line != "" {
+		q.Set("line", p.Line)
+	}
+	if p.Column != "" {
+		q.Set("column", p.Column)
+	}
+	if p.Path != "" {
+		q.Set("path", p.Path)
+	}
+	return q, h
+}
+
+type GoastDefinitionResponse struct {
+	Locations []GoastLocation `json:"locations,omitempty"`
+}
+
+// GoastImport is an import of a file.
+type GoastImport struct {
+	Path  string    `json:"path,omitempty"`
+	Name  string    `json:"name,omitempty"`
+	Range DiagRange `json:"range,omitempty"`
+}
+
+// GoastLocation is where a definition is.
+type GoastLocation struct {
+	Path  string    `json:"path,omitempty"`
+	Range DiagRange `json:"range,omitempty"`
+	// Kind is the kind of a package-level definition. One of const, field,
+	// func, interface, method, struct, type, var.
+	Kind string `json:"kind,omitempty"`
+}
+
+// GoastOutline is the structure of one file. A file with syntax errors is
+// outlined as far as it parses, and its errors listed.
+type GoastOutline struct {
+	Path    string        `json:"path,omitempty"`
+	Package string        `json:"package,omitempty"`
+	Imports []GoastImport `json:"imports,omitempty"`
+	Symbols []GoastSymbol `json:"symbols,omitempty"`
+	Errors  []string      `json:"errors,omitempty"`
+}
+
+// GoastOutlineParams are the query and header parameters of GoastOutline; empty ones are not sent.
+type GoastOutlineParams struct {
+	Path string // ?path
+}
+
+func (p *GoastOutlineParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Path != "" {
+		q.Set("path", p.Path)
+	}
+	return q, h
+}
+
+// GoastPackage lists the declarations of the package in one directory, tests
+// excluded.
+type GoastPackage struct {
+	Dir     string        `json:"dir,omitempty"`
+	Name    string        `json:"name,omitempty"`
+	Files   []string      `json:"files,omitempty"`
+	Symbols []GoastSymbol `json:"symbols,omitempty"`
+}
+
+// GoastPkgParams are the query and header parameters of GoastPkg; empty ones are not sent.
+type GoastPkgParams struct {
+	Dir string // ?dir
+}
+
+func (p *GoastPkgParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Dir != "" {
+		q.Set("dir", p.Dir)
+	}
+	return q, h
+}
+
+// GoastSymbol is a declaration. Range spans the whole declaration and
+// Selection its name. Detail is the signature of a func or method and the type
+// of anything else, when written out. Receiver is the receiver type of a
+// method, without pointer or type parameters.
+type GoastSymbol struct {
+	Name string `json:"name,omitempty"`
+	// One of const, field, func, interface, method, struct, type, var.
+	Kind      string    `json:"kind,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Receiver  string    `json:"receiver,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Range     DiagRange `json:"range,omitempty"`
+	Selection DiagRange `json:"selection,omitempty"`
+	// Children are the fields of a struct and the methods of an interface.
+	Children []GoastSymbol `json:"children,omitempty"`
+}
+
+// GomodDependencies lists a module's dependencies, sorted by path.
+type GomodDependencies struct {
+	// Module is the directory of the go.mod relative to the workspace root,
+	// empty for the dependencies of every module a go.work uses.
+	Module string `json:"module,omitempty"`
+	// Work is "go.work" when the list covers the modules of the workspace's
+	// go.work, those in Modules. Each dependency then has the highest version
+	// any of them requires, which is the one the workspace builds with unless
+	// a dependency increases it.
+	Work      string   `json:"work,omitempty"`
+	Modules   []string `json:"modules,omitempty"`
+	Path      string   `json:"path,omitempty"`
+	Go        string   `json:"go,omitempty"`
+	Toolchain string   `json:"toolchain,omitempty"`
+	// Updates reports whether Update fields were looked up.
+	Updates  bool              `json:"updates,omitempty"`
+	Direct   []GomodDependency `json:"direct,omitempty"`
+	Indirect []GomodDependency `json:"indirect,omitempty"`
+}
+
+// GomodDependency is a module the main module depends on.
+type GomodDependency struct {
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Line is that of its require directive, 0 for modules only the build list
+	// has.
+	Line    int64        `json:"line,omitempty"`
+	Replace *GomodModule `json:"replace,omitempty"`
+	// Sum reports whether go.sum has the hash of its content.
+	Sum bool `json:"sum,omitempty"`
+	// Update is the latest version when newer than Version; Deprecated and
+	// Retracted are set from the module's own go.mod. All three are only
+	// looked up on request.
+	Update     string   `json:"update,omitempty"`
+	Deprecated string   `json:"deprecated,omitempty"`
+	Retracted  []string `json:"retracted,omitempty"`
+	// Modules lists, in the dependencies of a go.work workspace, the
+	// directories of the modules requiring it.
+	Modules []string `json:"modules,omitempty"`
+}
+
+// GomodEdge is a requirement of one module version on another.
+type GomodEdge struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// GomodFileChange is the change to go.mod or go.sum made by an action.
+type GomodFileChange struct {
+	// Path is relative to the workspace root.
+	Path  string     `json:"path,omitempty"`
+	Hunks []DiffHunk `json:"hunks,omitempty"`
+}
+
+// GomodGraph is a module's requirement graph, each node and edge once. The
+// graph of a go.work workspace has a main module for each module it uses.
+type GomodGraph struct {
+	Module string `json:"module,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Work and Modules are set as in Dependencies for the graph of a go.work
+	// workspace.
+	Work    string   `json:"work,omitempty"`
+	Modules []string `json:"modules,omitempty"`
+	// All reports whether Nodes has every version required, rather than only
+	// the selected ones.
+	All   bool        `json:"all,omitempty"`
+	Nodes []GomodNode `json:"nodes,omitempty"`
+	Edges []GomodEdge `json:"edges,omitempty"`
+	// Warning explains missing package counts, as when the module does not
+	// build.
+	Warning string `json:"warning,omitempty"`
+}
+
+// GomodGraphParams are the query and header parameters of GomodGraph; empty ones are not sent.
+type GomodGraphParams struct {
+	All    string // ?all
+	Module string // ?module
+}
+
+func (p *GomodGraphParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.All != "" {
+		q.Set("all", p.All)
+	}
+	if p.Module != "" {
+		q.Set("module", p.Module)
+	}
+	return q, h
+}
+
+// GomodListParams are the query and header parameters of GomodList; empty ones are not sent.
+type GomodListParams struct {
+	Updates string // ?updates
+	Module  string // ?module
+}
+
+func (p *GomodListParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Updates != "" {
+		q.Set("updates", p.Updates)
+	}
+	if p.Module != "" {
+		q.Set("module", p.Module)
+	}
+	return q, h
+}
+
+// GomodModule is a module path and version.
+type GomodModule struct {
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// GomodNode is a module version in a Graph.
+type GomodNode struct {
+	// ID is path@version, or the path alone for a main module.
+	ID      string `json:"id,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	Main    bool   `json:"main,omitempty"`
+	// Selected reports whether this is the version the build uses.
+	Selected bool `json:"selected,omitempty"`
+	// Direct reports whether a main module's go.mod requires the module other
+	// than as an indirect dependency.
+	Direct bool `json:"direct,omitempty"`
+	// Size is the module's size in the module cache in bytes, 0 when it is not
+	// downloaded.
+	Size int64 `json:"size,omitempty"`
+	// Packages counts the module's packages the main modules' packages import,
+	// directly or not.
+	Packages int64 `json:"packages,omitempty"`
+}
+
+// GomodRequest selects a module and, for add, upgrade and remove, a
+// dependency.
+type GomodRequest struct {
+	// Module is the directory of the go.mod relative to the workspace root;
+	// defaults to ".".
+	Module string `json:"module,omitempty"`
+	// Path is the dependency's module path. Upgrade without one upgrades every
+	// dependency of the module's packages.
+	Path string `json:"path,omitempty"`
+	// Version is what add requires: a version, a query such as "latest" or
+	// "v1.2", a branch or a commit. Defaults to "latest".
+	Version string `json:"version,omitempty"`
+	// Patch limits upgrade to patch releases.
+	Patch bool `json:"patch,omitempty"`
+}
+
+// GomodResult reports an action.
+type GomodResult struct {
+	Command      string             `json:"command,omitempty"`
+	Output       string             `json:"output,omitempty"`
+	Changes      []GomodFileChange  `json:"changes,omitempty"`
+	Dependencies *GomodDependencies `json:"dependencies,omitempty"`
+}
+
+// GopanicFrame is one call: the function and the line of its source being run.
+type GopanicFrame struct {
+	Function string `json:"function,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int64  `json:"line,omitempty"`
+}
+
+// GopanicGoroutine is the stack of one goroutine.
+type GopanicGoroutine struct {
+	ID int64 `json:"id,omitempty"`
+	// State is what the goroutine was doing, such as "running" or "chan
+	// receive, 2 minutes".
+	State string `json:"state,omitempty"`
+	// Frames are its calls, innermost first.
+	Frames []GopanicFrame `json:"frames,omitempty"`
+	// Elided reports that the runtime left out frames of a deep stack.
+	Elided bool `json:"elided,omitempty"`
+	// CreatedBy is the go statement that started the goroutine, and Parent the
+	// goroutine that ran it, when the runtime reports one.
+	CreatedBy *GopanicFrame `json:"createdBy,omitempty"`
+	Parent    int64         `json:"parent,omitempty"`
+}
+
+// GopanicPanic is a parsed panic or fatal error report.
+type GopanicPanic struct {
+	// Message is the panic value or fatal error, without its "panic: " or
+	// "fatal error: " prefix. A panic raised while another was unwinding
+	// follows on its own line, as the runtime prints it.
+	Message string `json:"message,omitempty"`
+	// Fatal reports a fatal error, such as a deadlock or concurrent map
+	// writes, which unlike a panic cannot be recovered.
+	Fatal bool `json:"fatal,omitempty"`
+	// Signal is the signal a fault was raised for, such as "SIGSEGV:
+	// segmentation violation code=0x1 addr=0x0 pc=0x47e4a4".
+	Signal string `json:"signal,omitempty"`
+	// Goroutines are the stacks in the report, the goroutine that panicked
+	// first.
+	Goroutines []GopanicGoroutine `json:"goroutines,omitempty"`
+}
+
+// GotestBenchRequest selects the benchmarks to run.
+type GotestBenchRequest struct {
+	// Module and Packages select packages as in Request.
+	Module   string   `json:"module,omitempty"`
+	Packages []string `json:"packages,omitempty"`
+	// Bench is passed to -bench; defaults to ".".
+	Bench string `json:"bench,omitempty"`
+	// Count is passed to -count; defaults to 1. Several samples per benchmark
+	// are needed for Compare to judge significance.
+	Count int64 `json:"count,omitempty"`
+	// BenchTime is passed to -benchtime, e.g. "2s" or "1000x".
+	BenchTime string `json:"benchTime,omitempty"`
+	// TimeoutMS overrides Config.DefaultTimeout, up to Config.MaxTimeout.
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+}
+
+// GotestBenchRun is one stored execution of the benchmarks. Output holds build
+// errors and the output of failed packages.
+type GotestBenchRun struct {
+	ID         string             `json:"id,omitempty"`
+	CreatedAt  *time.Time         `json:"createdAt,omitempty"`
+	Request    GotestBenchRequest `json:"request,omitempty"`
+	Goos       string             `json:"goos,omitempty"`
+	Goarch     string             `json:"goarch,omitempty"`
+	CPU        string             `json:"cpu,omitempty"`
+	Passed     bool               `json:"passed,omitempty"`
+	ExitCode   int64              `json:"exitCode,omitempty"`
+	TimedOut   bool               `json:"timedOut,omitempty"`
+	DurationMs int64              `json:"durationMs,omitempty"`
+	Benchmarks []GotestBenchmark  `json:"benchmarks,omitempty"`
+	Output     string             `json:"output,omitempty"`
+}
+
+type GotestBenchRunsResponse struct {
+	Runs []GotestBenchRun `json:"runs,omitempty"`
+}
+
+// GotestBenchmark collects the samples of one benchmark. Name excludes the
+// GOMAXPROCS suffix, which is reported as Procs.
+type GotestBenchmark struct {
+	Package    string         `json:"package,omitempty"`
+	Name       string         `json:"name,omitempty"`
+	Procs      int64          `json:"procs,omitempty"`
+	Iterations []int64        `json:"iterations,omitempty"`
+	Metrics    []GotestMetric `json:"metrics,omitempty"`
+}
+
+// GotestBlock is one basic block of a coverage profile. Lines and columns are
+// 1-based; Count is how often the block ran, or 1 in "set" mode.
+type GotestBlock struct {
+	StartLine  int64 `json:"startLine,omitempty"`
+	StartCol   int64 `json:"startCol,omitempty"`
+	EndLine    int64 `json:"endLine,omitempty"`
+	EndCol     int64 `json:"endCol,omitempty"`
+	Statements int64 `json:"statements,omitempty"`
+	Count      int64 `json:"count,omitempty"`
+}
+
+// GotestClearCorpusParams are the query and header parameters of GotestClearCorpus; empty ones are not sent.
+type GotestClearCorpusParams struct {
+	Module  string // ?module
+	Package string // ?package
+	Target  string // ?target
+}
+
+func (p *GotestClearCorpusParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Module != "" {
+		q.Set("module", p.Module)
+	}
+	if p.Package != "" {
+		q.Set("package", p.Package)
+	}
+	if p.Target != "" {
+		q.Set("target", p.Target)
+	}
+	return q, h
+}
+
+// GotestCompareParams are the query and header parameters of GotestCompare; empty ones are not sent.
+type GotestCompareParams struct {
+	Old string // ?old
+	New string // ?new
+}
+
+func (p *GotestCompareParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Old != "" {
+		q.Set("old", p.Old)
+	}
+	if p.New != "" {
+		q.Set("new", p.New)
+	}
+	return q, h
+}
+
+// GotestComparison is the benchstat-style difference between two runs.
+type GotestComparison struct {
+	Old    string        `json:"old,omitempty"`
+	New    string        `json:"new,omitempty"`
+	Deltas []GotestDelta `json:"deltas,omitempty"`
+}
+
+// GotestCorpus lists the inputs of a fuzz target: the seeds in testdata/fuzz,
+// crashers among them, and those fuzzing found, in CorpusDir.
+type GotestCorpus struct {
+	Package   string             `json:"package,omitempty"`
+	Target    string             `json:"target,omitempty"`
+	Seeds     []GotestCorpusFile `json:"seeds,omitempty"`
+	Generated []GotestCorpusFile `json:"generated,omitempty"`
+	// GeneratedBytes totals the generated files.
+	GeneratedBytes int64 `json:"generatedBytes,omitempty"`
+}
+
+// GotestCorpusFile is a file of a fuzz corpus, its Path relative to the
+// workspace root.
+type GotestCorpusFile struct {
+	Name    string     `json:"name,omitempty"`
+	Path    string     `json:"path,omitempty"`
+	Size    int64      `json:"size,omitempty"`
+	ModTime *time.Time `json:"modTime,omitempty"`
+}
+
+// GotestCorpusParams are the query and header parameters of GotestCorpus; empty ones are not sent.
+type GotestCorpusParams struct {
+	Module  string // ?module
+	Package string // ?package
+	Target  string // ?target
+}
+
+func (p *GotestCorpusParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Module != "" {
+		q.Set("module", p.Module)
+	}
+	if p.Package != "" {
+		q.Set("package", p.Package)
+	}
+	if p.Target != "" {
+		q.Set("target", p.Target)
+	}
+	return q, h
+}
+
+// GotestCoverage is the parsed profile of a test run. Paths are relative to
+// the workspace root when the file belongs to one of its modules, and import
+// paths otherwise.
+type GotestCoverage struct {
+	Mode       string               `json:"mode,omitempty"`
+	CreatedAt  *time.Time           `json:"createdAt,omitempty"`
+	Statements int64                `json:"statements,omitempty"`
+	Covered    int64                `json:"covered,omitempty"`
+	Percent    float64              `json:"percent,omitempty"`
+	Files      []GotestFileCoverage `json:"files,omitempty"`
+}
+
+// GotestCoverageParams are the query and header parameters of GotestCoverage; empty ones are not sent.
+type GotestCoverageParams struct {
+	Path string // ?path
+}
+
+func (p *GotestCoverageParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Path != "" {
+		q.Set("path", p.Path)
+	}
+	return q, h
+}
+
+// GotestCrasher is an input that made the fuzz target fail. go test writes it
+// to the package's testdata/fuzz directory, where it reproduces the failure on
+// every go test run until it is fixed or removed.
+type GotestCrasher struct {
+	ID string `json:"id,omitempty"`
+	// File is the reproduction file relative to the workspace root, and Input
+	// its content: the values passed to the fuzz target.
+	File  string `json:"file,omitempty"`
+	Input string `json:"input,omitempty"`
+	// Output is what the failing target printed, its error included.
+	Output string `json:"output,omitempty"`
+	// Command reruns the target on this input alone.
+	Command string `json:"command,omitempty"`
+}
+
+// GotestDelta compares one metric of one benchmark between two runs. Old or
+// New is nil when the benchmark only appears in one of them.
+type GotestDelta struct {
+	Package string       `json:"package,omitempty"`
+	Name    string       `json:"name,omitempty"`
+	Procs   int64        `json:"procs,omitempty"`
+	Unit    string       `json:"unit,omitempty"`
+	Old     *GotestStats `json:"old,omitempty"`
+	New     *GotestStats `json:"new,omitempty"`
+	// Change is the relative change of the mean in percent.
+	Change *float64 `json:"change,omitempty"`
+	// PValue is from a two-sided Mann-Whitney U test; it needs at least two
+	// samples on each side.
+	PValue *float64 `json:"pValue,omitempty"`
+	// Significant reports PValue <= 0.05. Changes that are not significant are
+	// within the noise of the measurements.
+	Significant bool `json:"significant,omitempty"`
+}
+
+// GotestFileCoverage is the coverage of one source file. Covered lines are
+// only in blocks that ran, uncovered lines only in blocks that did not, and
+// partial lines in both.
+type GotestFileCoverage struct {
+	Path           string        `json:"path,omitempty"`
+	Statements     int64         `json:"statements,omitempty"`
+	Covered        int64         `json:"covered,omitempty"`
+	Percent        float64       `json:"percent,omitempty"`
+	Blocks         []GotestBlock `json:"blocks,omitempty"`
+	CoveredLines   []int64       `json:"coveredLines,omitempty"`
+	UncoveredLines []int64       `json:"uncoveredLines,omitempty"`
+	PartialLines   []int64       `json:"partialLines,omitempty"`
+}
+
+// GotestFuzzProgress is what go test last reported of a fuzzing run.
+type GotestFuzzProgress struct {
+	ElapsedMs      int64 `json:"elapsedMs,omitempty"`
+	Execs          int64 `json:"execs,omitempty"`
+	ExecsPerSec    int64 `json:"execsPerSec,omitempty"`
+	NewInteresting int64 `json:"newInteresting,omitempty"`
+	// TotalInteresting counts the corpus, seeds included.
+	TotalInteresting int64 `json:"totalInteresting,omitempty"`
+	// Baseline is set while go test runs the existing corpus before fuzzing:
+	// how many entries it has run of how many.
+	Baseline []int64 `json:"baseline,omitempty"`
+}
+
+// GotestFuzzRequest selects the fuzz target to run.
+type GotestFuzzRequest struct {
+	// Module is the module directory as in Request, and Package the package of
+	// the target relative to it, such as "./parser"; it defaults to ".".
+	Module  string `json:"module,omitempty"`
+	Package string `json:"package,omitempty"`
+	// Target is the fuzz test, such as "FuzzParse".
+	Target string `json:"target,omitempty"`
+	// DurationMS is how long to fuzz, passed to -fuzztime; defaults to
+	// Config.DefaultFuzzTime, up to Config.MaxFuzzTime.
+	DurationMs int64 `json:"durationMs,omitempty"`
+	// Parallel sets -parallel, the number of fuzzing workers; defaults to
+	// GOMAXPROCS.
+	Parallel int64 `json:"parallel,omitempty"`
+}
+
+// GotestFuzzResult reports a finished fuzzing run.
+type GotestFuzzResult struct {
+	Package    string `json:"package,omitempty"`
+	Target     string `json:"target,omitempty"`
+	Passed     bool   `json:"passed,omitempty"`
+	ExitCode   int64  `json:"exitCode,omitempty"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	// Progress is the last progress report, missing when fuzzing never
+	// started, as when the package does not build or a seed fails.
+	Progress *GotestFuzzProgress `json:"progress,omitempty"`
+	Crashers []GotestCrasher     `json:"crashers,omitempty"`
+	// Corpus is the target's corpus after the run.
+	Corpus    *GotestCorpus `json:"corpus,omitempty"`
+	Output    string        `json:"output,omitempty"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+// GotestMetric is one measured unit of a benchmark, such as "ns/op", "B/op",
+// "allocs/op", "MB/s" or a custom b.ReportMetric unit, with one value per
+// sample.
+type GotestMetric struct {
+	Unit   string    `json:"unit,omitempty"`
+	Values []float64 `json:"values,omitempty"`
+	Mean   float64   `json:"mean,omitempty"`
+}
+
+// GotestPackageResult collects the tests of one package. Output holds what the
+// package printed outside of any test, such as the final ok/FAIL line.
+type GotestPackageResult struct {
+	Package string `json:"package,omitempty"`
+	// One of fail, pass, skip.
+	Status     string             `json:"status,omitempty"`
+	DurationMs int64              `json:"durationMs,omitempty"`
+	Output     string             `json:"output,omitempty"`
+	Truncated  bool               `json:"truncated,omitempty"`
+	Tests      []GotestTestResult `json:"tests,omitempty"`
+}
+
+// GotestReport is the structured outcome of a go test run. Output holds build
+// errors and anything else go test printed outside of a package.
+type GotestReport struct {
+	Passed     bool                  `json:"passed,omitempty"`
+	ExitCode   int64                 `json:"exitCode,omitempty"`
+	TimedOut   bool                  `json:"timedOut,omitempty"`
+	DurationMs int64                 `json:"durationMs,omitempty"`
+	Summary    GotestSummary         `json:"summary,omitempty"`
+	Packages   []GotestPackageResult `json:"packages,omitempty"`
+	Output     string                `json:"output,omitempty"`
+	Truncated  bool                  `json:"truncated,omitempty"`
+	// Coverage is the percentage of statements covered, set for runs with
+	// Request.Cover.
+	Coverage *float64 `json:"coverage,omitempty"`
+}
+
+// GotestRequest selects the tests to run.
+type GotestRequest struct {
+	// Module is the directory of the module to test relative to the workspace
+	// root, for workspaces holding several; defaults to the root.
+	Module string `json:"module,omitempty"`
+	// Packages are package patterns relative to the module, such as "./..." or
+	// "./internal/store"; defaults to "./...", or at the root of a go.work
+	// workspace to every module it uses.
+	Packages []string `json:"packages,omitempty"`
+	// Run and Skip are passed to -run and -skip.
+	Run  string `json:"run,omitempty"`
+	Skip string `json:"skip,omitempty"`
+	// Short sets -short.
+	Short bool `json:"short,omitempty"`
+	// Cover records a coverage profile, which replaces the workspace's
+	// previous one in Service.Coverage.
+	Cover bool `json:"cover,omitempty"`
+	// TimeoutMS overrides Config.DefaultTimeout, up to Config.MaxTimeout.
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+}
+
+// GotestStats summarizes the samples of one metric.
+type GotestStats struct {
+	Mean float64 `json:"mean,omitempty"`
+	// Variation is the sample standard deviation as a percentage of Mean.
+	Variation float64 `json:"variation,omitempty"`
+	N         int64   `json:"n,omitempty"`
+}
+
+// GotestSummary counts the tests of a run by outcome.
+type GotestSummary struct {
+	Passed  int64 `json:"passed,omitempty"`
+	Failed  int64 `json:"failed,omitempty"`
+	Skipped int64 `json:"skipped,omitempty"`
+}
+
+// GotestTestResult is the outcome of one test or subtest. Subtests are
+// reported under their full name, e.g. "TestParse/empty".
+type GotestTestResult struct {
+	Name string `json:"name,omitempty"`
+	// One of fail, pass, skip.
+	Status     string `json:"status,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+}
+
+type HealthReadyResponse struct {
+	Status string            `json:"status,omitempty"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type HealthzResponse struct {
+	Status string `json:"status,omitempty"`
+}
+
+// HibernateStatus describes the lifecycle of a workspace.
+type HibernateStatus struct {
+	State string `json:"state,omitempty"`
+	// LastActive is when the workspace was last seen busy since the server
+	// started.
+	LastActive   any `json:"lastActive,omitempty"`
+	HibernatedAt any `json:"hibernatedAt,omitempty"`
+}
+
+// HistoryAuthor is who saved a version.
+type HistoryAuthor struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// HistoryFile summarizes the history of one path.
+type HistoryFile struct {
+	Path      string     `json:"path,omitempty"`
+	Versions  int64      `json:"versions,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type HistoryFilesResponse struct {
+	Files []HistoryFile `json:"files,omitempty"`
+}
+
+// HistoryGetParams are the query and header parameters of HistoryGet; empty ones are not sent.
+type HistoryGetParams struct {
+	Version string // ?version
+	Diff    string // ?diff
+}
+
+func (p *HistoryGetParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Version != "" {
+		q.Set("version", p.Version)
+	}
+	if p.Diff != "" {
+		q.Set("diff", p.Diff)
+	}
+	return q, h
+}
+
+// HistoryRestoreParams are the query and header parameters of HistoryRestore; empty ones are not sent.
+type HistoryRestoreParams struct {
+	Version string // ?version
+}
+
+func (p *HistoryRestoreParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Version != "" {
+		q.Set("version", p.Version)
+	}
+	return q, h
+}
+
+type HistoryRestoreResponse struct {
+	File    FilesEntry      `json:"file,omitempty"`
+	Version *HistoryVersion `json:"version,omitempty"`
+}
+
+// HistoryVersion is one recorded state of a file.
+type HistoryVersion struct {
+	ID     int64         `json:"id,omitempty"`
+	Time   *time.Time    `json:"time,omitempty"`
+	Author HistoryAuthor `json:"author,omitempty"`
+	// One of collab, restore, save.
+	Source string `json:"source,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	// RestoredFrom is the version a restore brought back.
+	RestoredFrom int64 `json:"restoredFrom,omitempty"`
+}
+
+// ImagesBuilt is a pulled or built image.
+type ImagesBuilt struct {
+	Ref     string     `json:"ref,omitempty"`
+	ID      string     `json:"id,omitempty"`
+	Size    int64      `json:"size,omitempty"`
+	BuiltAt *time.Time `json:"builtAt,omitempty"`
+	Source  string     `json:"source,omitempty"`
+}
+
+// ImagesResult reports a finished pull or build. Ready reports whether the
+// workspace now uses the image; Error explains why an image that was pulled or
+// built is not used, as when it is too large.
+type ImagesResult struct {
+	Ref        string     `json:"ref,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	DurationMs int64      `json:"durationMs,omitempty"`
+	ExitCode   int64      `json:"exitCode,omitempty"`
+	TimedOut   bool       `json:"timedOut,omitempty"`
+	Size       int64      `json:"size,omitempty"`
+	Ready      bool       `json:"ready,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Output is the docker log, up to Config.MaxOutputBytes.
+	Output    string `json:"output,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// ImagesSettings is a workspace's custom image: a registry image in Image, the
+// workspace-relative path of a Dockerfile, whose build context is the
+// workspace root, or that of a devcontainer.json. None means the stock images.
+type ImagesSettings struct {
+	Image        string `json:"image,omitempty"`
+	Dockerfile   string `json:"dockerfile,omitempty"`
+	Devcontainer string `json:"devcontainer,omitempty"`
+}
+
+// ImagesStatusResponse is a Status with the registries images may come from.
+type ImagesStatusResponse struct {
+	Image        string `json:"image,omitempty"`
+	Dockerfile   string `json:"dockerfile,omitempty"`
+	Devcontainer string `json:"devcontainer,omitempty"`
+	// Active is the image the workspace uses, nil for the stock ones until the
+	// configured image has been pulled or built.
+	Active     *ImagesBuilt `json:"active,omitempty"`
+	Building   bool         `json:"building,omitempty"`
+	Registries []string     `json:"registries,omitempty"`
+}
+
+// JobsJob is a scheduled chore of a workspace.
+type JobsJob struct {
+	ID        string `json:"id,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// One of dependencies, snapshot, tests.
+	Kind string `json:"kind,omitempty"`
+	// Schedule is a cron expression, such as "0 2 * * *" for 02:00 every day,
+	// or a macro such as @daily; see ParseSchedule.
+	Schedule string `json:"schedule,omitempty"`
+	// TimeZone is the IANA time zone of Schedule; defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Paused stops scheduled runs; the job can still be run by hand.
+	Paused bool `json:"paused,omitempty"`
+	// Tests configures tests jobs.
+	Tests *GotestRequest `json:"tests,omitempty"`
+	// Module is the directory of the module whose dependencies a dependencies
+	// job checks; defaults to the workspace root.
+	Module    string     `json:"module,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	// NextRun is when the job runs next, unset while paused, and LastRun its
+	// latest run.
+	NextRun any      `json:"nextRun,omitempty"`
+	LastRun *JobsRun `json:"lastRun,omitempty"`
+}
+
+type JobsListResponse struct {
+	Jobs []JobsJob `json:"jobs,omitempty"`
+}
+
+// JobsRun is one run of a job.
+type JobsRun struct {
+	ID  string `json:"id,omitempty"`
+	Job string `json:"job,omitempty"`
+	// One of manual, schedule.
+	Trigger string `json:"trigger,omitempty"`
+	// One of error, failed, passed, queued, running.
+	Status     string     `json:"status,omitempty"`
+	QueuedAt   *time.Time `json:"queuedAt,omitempty"`
+	StartedAt  any        `json:"startedAt,omitempty"`
+	FinishedAt any        `json:"finishedAt,omitempty"`
+	DurationMs int64      `json:"durationMs,omitempty"`
+	// Summary says in a line what the run found, and Error why it could not be
+	// run.
+	Summary string `json:"summary,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Result is the outcome of the run: the test report, the dependency list
+	// or the snapshot. Results over 256 KiB are dropped.
+	Result any `json:"result,omitempty"`
+}
+
+type JobsRunsResponse struct {
+	Runs []JobsRun `json:"runs,omitempty"`
+}
+
+// JobsSpec is what a job runs and when.
+type JobsSpec struct {
+	Name string `json:"name,omitempty"`
+	// One of dependencies, snapshot, tests.
+	Kind string `json:"kind,omitempty"`
+	// Schedule is a cron expression, such as "0 2 * * *" for 02:00 every day,
+	// or a macro such as @daily; see ParseSchedule.
+	Schedule string `json:"schedule,omitempty"`
+	// TimeZone is the IANA time zone of Schedule; defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Paused stops scheduled runs; the job can still be run by hand.
+	Paused bool `json:"paused,omitempty"`
+	// Tests configures tests jobs.
+	Tests *GotestRequest `json:"tests,omitempty"`
+	// Module is the directory of the module whose dependencies a dependencies
+	// job checks; defaults to the workspace root.
+	Module string `json:"module,omitempty"`
+}
+
+// LSPServerInfo is what clients learn of a Server; its command and environment
+// stay on the server.
+type LSPServerInfo struct {
+	Name      string   `json:"name,omitempty"`
+	Languages []string `json:"languages,omitempty"`
+	Files     []string `json:"files,omitempty"`
+}
+
+// LSPServersParams are the query and header parameters of LSPServers; empty ones are not sent.
+type LSPServersParams struct {
+	File string // ?file
+}
+
+func (p *LSPServersParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.File != "" {
+		q.Set("file", p.File)
+	}
+	return q, h
+}
+
+type LSPServersResponse struct {
+	Servers []LSPServerInfo `json:"servers,omitempty"`
+}
+
+// LintRequest selects what to lint.
+type LintRequest struct {
+	// Linters defaults to vet alone.
+	Linters []string `json:"linters,omitempty"`
+	// Packages are package patterns relative to the workspace root; defaults
+	// to "./...".
+	Packages []string `json:"packages,omitempty"`
+}
+
+// LintResult collects every finding of a lint request.
+type LintResult struct {
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	Linters     []LinterResult `json:"linters,omitempty"`
+}
+
+// LinterResult reports how one linter ran. Error explains a failed or
+// unavailable linter.
+type LinterResult struct {
+	// One of golangci-lint, staticcheck, vet.
+	Linter string `json:"linter,omitempty"`
+	// One of failed, ok, unavailable.
+	Status      string `json:"status,omitempty"`
+	Diagnostics int64  `json:"diagnostics,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"durationMs,omitempty"`
+}
+
+// MarkdownFileParams are the query and header parameters of MarkdownFile; empty ones are not sent.
+type MarkdownFileParams struct {
+	Format string // ?format
+}
+
+func (p *MarkdownFileParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Format != "" {
+		q.Set("format", p.Format)
+	}
+	return q, h
+}
+
+// MarkdownSourceParams are the query and header parameters of MarkdownSource; empty ones are not sent.
+type MarkdownSourceParams struct {
+	Format string // ?format
+}
+
+func (p *MarkdownSourceParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Format != "" {
+		q.Set("format", p.Format)
+	}
+	return q, h
+}
+
+type MarkdownSourceRequest struct {
+	Source string `json:"source,omitempty"`
+}
+
+// Notebook is an nbformat 4 document.
+type Notebook struct {
+	Cells         []any          `json:"cells,omitempty"`
+	Metadata      map[string]any `json:"metadata,omitempty"`
+	Nbformat      int64          `json:"nbformat,omitempty"`
+	NbformatMinor int64          `json:"nbformat_minor,omitempty"`
+}
+
+type NotebookControlRequest struct {
+	Action string `json:"action,omitempty"`
+}
+
+// NotebookPutParams are the query and header parameters of NotebookPut; empty ones are not sent.
+type NotebookPutParams struct {
+	IfMatch     string // If-Match header
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *NotebookPutParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.IfMatch != "" {
+		h.Set("If-Match", p.IfMatch)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+type NotebookRunRequest struct {
+	// Notebook, if set, is saved before running, so unsaved edits run.
+	Notebook *Notebook `json:"notebook,omitempty"`
+	// Cells are the IDs of the cells to run; all code cells when empty.
+	Cells []string `json:"cells,omitempty"`
+	// Restart starts the kernel afresh, discarding its state.
+	Restart bool `json:"restart,omitempty"`
+}
+
+// NotebookStatus describes a running kernel.
+type NotebookStatus struct {
+	Path           string     `json:"path,omitempty"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	LastRunAt      *time.Time `json:"lastRunAt,omitempty"`
+	ExecutionCount int64      `json:"executionCount,omitempty"`
+	// Running is the ID of the cell being executed.
+	Running string `json:"running,omitempty"`
+}
+
+// Org is an organization.
+type Org struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// DefaultRole is every member's role on the organization's workspaces; ""
+	// gives members access only through teams. One of editor, owner, viewer.
+	DefaultRole string     `json:"defaultRole,omitempty"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+}
+
+type OrgAddWorkspaceResponse struct {
+	ID    string `json:"id,omitempty"`
+	Owner string `json:"owner,omitempty"`
+}
+
+type OrgCreateRequest struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type OrgEntry struct {
+	ID string `json:"id,omitempty"`
+	// One of editor, owner, viewer.
+	Role string `json:"role,omitempty"`
+}
+
+type OrgGetResponse struct {
+	Org   *Org      `json:"org,omitempty"`
+	Role  string    `json:"role,omitempty"`
+	Quota OrgQuota  `json:"quota,omitempty"`
+	Usage *OrgUsage `json:"usage,omitempty"`
+}
+
+type OrgListResponse struct {
+	Orgs []Org `json:"orgs,omitempty"`
+}
+
+// OrgMember is a user in an organization.
+type OrgMember struct {
+	UserID  string     `json:"userId,omitempty"`
+	Email   string     `json:"email,omitempty"`
+	Role    string     `json:"role,omitempty"`
+	AddedAt *time.Time `json:"addedAt,omitempty"`
+}
+
+type OrgMemberRequest struct {
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role,omitempty"`
+}
+
+type OrgMembersResponse struct {
+	Members []OrgMember `json:"members,omitempty"`
+}
+
+// OrgQuota caps an organization's resources.
+type OrgQuota struct {
+	// MaxWorkspaces defaults to 100.
+	MaxWorkspaces int64 `json:"maxWorkspaces,omitempty"`
+	// MaxMembers defaults to 200.
+	MaxMembers int64 `json:"maxMembers,omitempty"`
+	// MaxStorageBytes bounds the size of all workspaces together; defaults to
+	// 10 GiB. It is checked when workspaces are added.
+	MaxStorageBytes int64 `json:"maxStorageBytes,omitempty"`
+}
+
+// OrgTeam gives its members Role on the organization's Workspaces, or on all
+// of them when Workspaces is empty.
+type OrgTeam struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// One of editor, owner, viewer.
+	Role       string   `json:"role,omitempty"`
+	Members    []string `json:"members,omitempty"`
+	Workspaces []string `json:"workspaces,omitempty"`
+}
+
+type OrgTeamRequest struct {
+	Name string `json:"name,omitempty"`
+	// One of editor, owner, viewer.
+	Role       string   `json:"role,omitempty"`
+	Members    []string `json:"members,omitempty"`
+	Workspaces []string `json:"workspaces,omitempty"`
+}
+
+type OrgTeamsResponse struct {
+	Teams []OrgTeam `json:"teams,omitempty"`
+}
+
+type OrgUpdateRequest struct {
+	Name string `json:"name,omitempty"`
+	// One of editor, owner, viewer.
+	DefaultRole string `json:"defaultRole,omitempty"`
+}
+
+// OrgUsage is what an organization uses of its Quota.
+type OrgUsage struct {
+	Workspaces   int64 `json:"workspaces,omitempty"`
+	Members      int64 `json:"members,omitempty"`
+	StorageBytes int64 `json:"storageBytes,omitempty"`
+}
+
+type OrgWorkspaceRequest struct {
+	// ID names the workspace; a random one is generated when empty.
+	ID string `json:"id,omitempty"`
+	// Existing transfers the caller's workspace ID instead of creating one.
+	Existing bool `json:"existing,omitempty"`
+}
+
+type OrgWorkspacesResponse struct {
+	Workspaces []OrgEntry `json:"workspaces,omitempty"`
+}
+
+// PlaygroundRequest is a program to run.
+type PlaygroundRequest struct {
+	// Language defaults to "go".
+	Language string `json:"language,omitempty"`
+	// Source is the program's main file, or Files its project, as in
+	// runner.Request.
+	Source string       `json:"source,omitempty"`
+	Files  []RunnerFile `json:"files,omitempty"`
+	// Stdin is the program's standard input.
+	Stdin string `json:"stdin,omitempty"`
+}
+
+// PlaygroundResponse is the outcome of a run.
+type PlaygroundResponse struct {
+	// Phase is where it stopped: "build" for programs that did not compile,
+	// with their Diagnostics, or "run". One of build, run.
+	Phase      string `json:"phase,omitempty"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	ExitCode   int64  `json:"exitCode,omitempty"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	// One of memory, output, timeout.
+	Killed          string       `json:"killed,omitempty"`
+	Message         string       `json:"message,omitempty"`
+	OutputTruncated bool         `json:"outputTruncated,omitempty"`
+	Diagnostics     []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+type PortsDeclareRequest struct {
+	Label string `json:"label,omitempty"`
+}
+
+type PortsListResponse struct {
+	Ports []PortsPort `json:"ports,omitempty"`
+}
+
+// PortsPort is a port of a workspace. Declared ports were added by a user, and
+// forwarded ones by the workspace's configuration; both are listed whether or
+// not anything listens on them. Loopback is set when the process only listens
+// on localhost, which the proxy cannot reach.
+type PortsPort struct {
+	Port      int64  `json:"port,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Declared  bool   `json:"declared,omitempty"`
+	Forwarded bool   `json:"forwarded,omitempty"`
+	Listening bool   `json:"listening,omitempty"`
+	Loopback  bool   `json:"loopback,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type PortsURLResponse struct {
+	URL       string `json:"url,omitempty"`
+	ExpiresIn int64  `json:"expiresIn,omitempty"`
+}
+
+// PprofFunc is a function's share of a sample type: Flat counts samples with
+// it at the leaf, Cum samples with it anywhere on the stack.
+type PprofFunc struct {
+	Function string `json:"function,omitempty"`
+	File     string `json:"file,omitempty"`
+	Flat     int64  `json:"flat,omitempty"`
+	Cum      int64  `json:"cum,omitempty"`
+}
+
+// PprofNode is a flame graph node: the total Value of the samples passing
+// through Name, split among the calls it made. The shape matches what
+// d3-flame-graph and speedscope-style viewers consume.
+type PprofNode struct {
+	Name     string      `json:"name,omitempty"`
+	File     string      `json:"file,omitempty"`
+	Value    int64       `json:"value,omitempty"`
+	Children []PprofNode `json:"children,omitempty"`
+}
+
+// PprofValueType describes one value of every sample, such as "cpu" in
+// "nanoseconds".
+type PprofValueType struct {
+	Type string `json:"type,omitempty"`
+	Unit string `json:"unit,omitempty"`
+}
+
+// QuotaLimits bounds a user's monthly usage and concurrent sandboxes. Negative
+// values disable a limit.
+type QuotaLimits struct {
+	// CPUSeconds defaults to 36000, ten CPU-hours.
+	CPUSeconds float64 `json:"cpuSeconds,omitempty"`
+	// MemoryGBHours defaults to 50.
+	MemoryGbHours float64 `json:"memoryGbHours,omitempty"`
+	// StorageBytes bounds the size of the user's workspaces; defaults to 1
+	// GiB.
+	StorageBytes int64 `json:"storageBytes,omitempty"`
+	// Sandboxes bounds concurrent runs and terminals; defaults to 3.
+	Sandboxes int64 `json:"sandboxes,omitempty"`
+}
+
+// QuotaReport is a user's usage with their limits.
+type QuotaReport struct {
+	PeriodStart *time.Time  `json:"periodStart,omitempty"`
+	PeriodEnd   *time.Time  `json:"periodEnd,omitempty"`
+	Usage       QuotaUsage  `json:"usage,omitempty"`
+	Limits      QuotaLimits `json:"limits,omitempty"`
+	// Custom reports that the limits are the user's own rather than the
+	// server's.
+	Custom bool `json:"custom,omitempty"`
+}
+
+// QuotaUsage is what a user has used this month, and is using now.
+type QuotaUsage struct {
+	CPUSeconds    float64 `json:"cpuSeconds,omitempty"`
+	MemoryGbHours float64 `json:"memoryGbHours,omitempty"`
+	StorageBytes  int64   `json:"storageBytes,omitempty"`
+	Sandboxes     int64   `json:"sandboxes,omitempty"`
+}
+
+// Recording describes a recording.
+type Recording struct {
+	ID    string `json:"id,omitempty"`
+	Title string `json:"title,omitempty"`
+	// UserID and By are who started it.
+	UserID string `json:"userId,omitempty"`
+	By     string `json:"by,omitempty"`
+	// Input reports whether terminal input is recorded.
+	Input     bool       `json:"input,omitempty"`
+	Active    bool       `json:"active,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	EndedAt   any        `json:"endedAt,omitempty"`
+	// Ended says why it ended; see EndStopped and the others.
+	Ended      string `json:"ended,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Entries    int64  `json:"entries,omitempty"`
+	Bytes      int64  `json:"bytes,omitempty"`
+}
+
+type RecordingStartRequest struct {
+	Title string `json:"title,omitempty"`
+	// Input asks for terminal input to be recorded.
+	Input bool `json:"input,omitempty"`
+}
+
+// RecoveryDraft is the unsaved contents of a buffer.
+type RecoveryDraft struct {
+	Path string `json:"path,omitempty"`
+	// Base is the ETag of the file the buffer was opened from, or "" for a new
+	// file.
+	Base      string     `json:"base,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	Size      int64      `json:"size,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	// Conflict reports that the file changed since Base, so restoring the
+	// draft would overwrite someone else's save.
+	Conflict bool `json:"conflict,omitempty"`
+}
+
+type RecoveryListResponse struct {
+	Drafts []RecoveryDraft `json:"drafts,omitempty"`
+}
+
+// RecoveryRestoreParams are the query and header parameters of RecoveryRestore; empty ones are not sent.
+type RecoveryRestoreParams struct {
+	Force string // ?force
+}
+
+func (p *RecoveryRestoreParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Force != "" {
+		q.Set("force", p.Force)
+	}
+	return q, h
+}
+
+type RecoverySaveRequest struct {
+	Base    string `json:"base,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// ReviewAuthor is who wrote a comment. Both fields are empty when
+// authentication is off.
+type ReviewAuthor struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// ReviewComment is one message of a thread.
+type ReviewComment struct {
+	ID        string       `json:"id,omitempty"`
+	Author    ReviewAuthor `json:"author,omitempty"`
+	Body      string       `json:"body,omitempty"`
+	CreatedAt *time.Time   `json:"createdAt,omitempty"`
+	EditedAt  any          `json:"editedAt,omitempty"`
+}
+
+type ReviewCommentRequest struct {
+	Body string `json:"body,omitempty"`
+}
+
+type ReviewCreateRequest struct {
+	Path      string `json:"path,omitempty"`
+	StartLine int64  `json:"startLine,omitempty"`
+	// EndLine defaults to StartLine.
+	EndLine int64  `json:"endLine,omitempty"`
+	Body    string `json:"body,omitempty"`
+}
+
+// ReviewListParams are the query and header parameters of ReviewList; empty ones are not sent.
+type ReviewListParams struct {
+	Resolved string // ?resolved
+	Path     string // ?path
+}
+
+func (p *ReviewListParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Resolved != "" {
+		q.Set("resolved", p.Resolved)
+	}
+	if p.Path != "" {
+		q.Set("path", p.Path)
+	}
+	return q, h
+}
+
+type ReviewResolveRequest struct {
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+// ReviewThread is a conversation about lines StartLine to EndLine of a file,
+// 1-based and inclusive.
+type ReviewThread struct {
+	ID        string `json:"id,omitempty"`
+	Path      string `json:"path,omitempty"`
+	StartLine int64  `json:"startLine,omitempty"`
+	EndLine   int64  `json:"endLine,omitempty"`
+	// Quote is the text of the lines when the thread was started.
+	Quote string `json:"quote,omitempty"`
+	// Outdated reports that the lines were changed or removed, so the range is
+	// where they last were.
+	Outdated   bool            `json:"outdated,omitempty"`
+	Resolved   bool            `json:"resolved,omitempty"`
+	ResolvedBy *ReviewAuthor   `json:"resolvedBy,omitempty"`
+	ResolvedAt any             `json:"resolvedAt,omitempty"`
+	Comments   []ReviewComment `json:"comments,omitempty"`
+	CreatedAt  *time.Time      `json:"createdAt,omitempty"`
+	UpdatedAt  *time.Time      `json:"updatedAt,omitempty"`
+}
+
+// RunnerArtifact is a stored build output, downloadable until ExpiresAt.
+type RunnerArtifact struct {
+	ID        string       `json:"id,omitempty"`
+	Name      string       `json:"name,omitempty"`
+	Target    RunnerTarget `json:"target,omitempty"`
+	GoVersion string       `json:"goVersion,omitempty"`
+	Size      int64        `json:"size,omitempty"`
+	Sha256    string       `json:"sha256,omitempty"`
+	CreatedAt *time.Time   `json:"createdAt,omitempty"`
+	ExpiresAt *time.Time   `json:"expiresAt,omitempty"`
+	// URL downloads the binary without an access token until it expires.
+	URL string `json:"url,omitempty"`
+}
+
+// RunnerBuildOptions are the go build flags a request may set. Every value is
+// checked against an allowlist, since the flags reach the build command.
+type RunnerBuildOptions struct {
+	// Race builds with the race detector. It needs cgo and a C compiler in the
+	// toolchain image, and makes programs several times slower and larger; a
+	// detected race exits with status 66.
+	Race bool `json:"race,omitempty"`
+	// Trimpath removes file system paths from the binary.
+	Trimpath bool `json:"trimpath,omitempty"`
+	// Tags are build tags.
+	Tags []string `json:"tags,omitempty"`
+	// GCFlags are compiler flags for the packages being built, such as "-N",
+	// "-l" or "-m".
+	Gcflags []string `json:"gcflags,omitempty"`
+	// LDFlags are linker flags: "-s", "-w", or "-X importpath.name=value".
+	Ldflags []string `json:"ldflags,omitempty"`
+}
+
+// RunnerBuildRequest is a program to compile without running it.
+type RunnerBuildRequest struct {
+	Source    string       `json:"source,omitempty"`
+	Files     []RunnerFile `json:"files,omitempty"`
+	Module    string       `json:"module,omitempty"`
+	Main      string       `json:"main,omitempty"`
+	GoVersion string       `json:"goVersion,omitempty"`
+	Workspace string       `json:"workspace,omitempty"`
+	// GOOS and GOARCH default to linux/amd64.
+	Goos   string `json:"goos,omitempty"`
+	Goarch string `json:"goarch,omitempty"`
+	// CGO enables cgo. Cross-compiling with cgo needs a C cross compiler in
+	// the toolchain image; without one the build fails.
+	Cgo bool `json:"cgo,omitempty"`
+	// Options are extra go build flags, as for runs.
+	Options RunnerBuildOptions `json:"options,omitempty"`
+}
+
+// RunnerBuildResult is the outcome of a Build. Artifact is set when the build
+// succeeded.
+type RunnerBuildResult struct {
+	Target      RunnerTarget    `json:"target,omitempty"`
+	GoVersion   string          `json:"goVersion,omitempty"`
+	ExitCode    int64           `json:"exitCode,omitempty"`
+	TimedOut    bool            `json:"timedOut,omitempty"`
+	DurationMs  int64           `json:"durationMs,omitempty"`
+	Stderr      string          `json:"stderr,omitempty"`
+	Diagnostics []Diagnostic    `json:"diagnostics,omitempty"`
+	Artifact    *RunnerArtifact `json:"artifact,omitempty"`
+	// Cached reports that the binary of an identical earlier build was reused.
+	Cached bool `json:"cached,omitempty"`
+}
+
+// RunnerFile is one source file of a submitted project.
+type RunnerFile struct {
+	// Path is slash-separated and relative to the module root.
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// RunnerLanguageInfo describes a language a request may select.
+type RunnerLanguageInfo struct {
+	Name       string `json:"name,omitempty"`
+	SourceFile string `json:"sourceFile,omitempty"`
+}
+
+type RunnerLanguagesResponse struct {
+	Languages []RunnerLanguageInfo `json:"languages,omitempty"`
+}
+
+// RunnerLimits bounds the resources a single sandboxed run may consume.
+type RunnerLimits struct {
+	// CPUs is the number of CPU cores available to the run (fractions
+	// allowed).
+	Cpus float64 `json:"cpus,omitempty"`
+	// MemoryMB is the hard memory limit in megabytes, swap included.
+	MemoryMb int64 `json:"memoryMb,omitempty"`
+	// TimeoutMS is the wall-clock budget for the run phase in milliseconds.
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+	// MaxProcs is the maximum number of processes and threads in the sandbox.
+	MaxProcs int64 `json:"maxProcs,omitempty"`
+	// OutputBytes caps stdout and stderr together. A program writing more is
+	// stopped, and its output ends in a truncation marker. Zero means no cap,
+	// as for builds.
+	OutputBytes int64 `json:"outputBytes,omitempty"`
+}
+
+// RunnerProfile is a stored pprof profile or execution trace, downloadable
+// until ExpiresAt.
+type RunnerProfile struct {
+	ID string `json:"id,omitempty"`
+	// One of cpu, heap, trace.
+	Kind      string     `json:"kind,omitempty"`
+	Size      int64      `json:"size,omitempty"`
+	Sha256    string     `json:"sha256,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// URL downloads the profile without an access token until it expires.
+	URL string `json:"url,omitempty"`
+}
+
+// RunnerProfileParams are the query and header parameters of RunnerProfile; empty ones are not sent.
+type RunnerProfileParams struct {
+	Top    string // ?top
+	Sample string // ?sample
+}
+
+func (p *RunnerProfileParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Top != "" {
+		q.Set("top", p.Top)
+	}
+	if p.Sample != "" {
+		q.Set("sample", p.Sample)
+	}
+	return q, h
+}
+
+// RunnerProfileView is a profile summarized for one sample type: the flame
+// graph of all stacks and the functions with the most samples.
+type RunnerProfileView struct {
+	Profile     *RunnerProfile   `json:"profile,omitempty"`
+	SampleTypes []PprofValueType `json:"sampleTypes,omitempty"`
+	SampleType  PprofValueType   `json:"sampleType,omitempty"`
+	DurationMs  int64            `json:"durationMs,omitempty"`
+	Flame       *PprofNode       `json:"flame,omitempty"`
+	Top         []PprofFunc      `json:"top,omitempty"`
+}
+
+// RunnerRequest is a program submitted for execution: either a single source
+// file in Source, or a whole project tree in Files.
+type RunnerRequest struct {
+	// Language names the program's Language; defaults to "go".
+	Language string `json:"language,omitempty"`
+	// Source is the contents of the language's source file, such as main.go.
+	Source string `json:"source,omitempty"`
+	// Files is a project manifest; for Go it may include go.mod and nested
+	// packages, or a go.work and the modules it uses.
+	Files []RunnerFile `json:"files,omitempty"`
+	// Module is the directory of the module to build, relative to the project
+	// root; defaults to the root. It selects one of the modules of a go.work
+	// project, whose root need not be a module.
+	Module string `json:"module,omitempty"`
+	// Main is the module-relative directory of the package to run; defaults to
+	// the module root. For other languages it is the file to run.
+	Main string `json:"main,omitempty"`
+	// Limits overrides DefaultLimits; zero fields keep the default.
+	Limits RunnerLimits `json:"limits,omitempty"`
+	// GoVersion selects the toolchain. Without it, the version declared by
+	// Workspace is used, or the server default.
+	GoVersion string `json:"goVersion,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	// Options are extra go build flags, such as the race detector.
+	Options RunnerBuildOptions `json:"options,omitempty"`
+	// Profile, when set, runs the program under the profiler of that kind. One
+	// of cpu, heap, trace.
+	Profile string `json:"profile,omitempty"`
+	// Output selects how escape sequences and carriage returns in the output
+	// are delivered; defaults to OutputRaw. One of html, plain, raw.
+	Output string `json:"output,omitempty"`
+	// CacheOutput declares the program deterministic: the output of an
+	// identical earlier run, with the same files, options and limits, is
+	// replayed instead of running it again. It is ignored with Stdin or
+	// Profile.
+	CacheOutput bool `json:"cacheOutput,omitempty"`
+}
+
+// RunnerResult is the structured outcome of a run.
+type RunnerResult struct {
+	// One of build, run.
+	Phase    string `json:"phase,omitempty"`
+	Language string `json:"language,omitempty"`
+	// GoVersion is the toolchain's Go version, for Go programs.
+	GoVersion  string `json:"goVersion,omitempty"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	ExitCode   int64  `json:"exitCode,omitempty"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	// BuildMS is how long the build took, included in DurationMS; 0 when it
+	// was cached.
+	BuildMs int64        `json:"buildMs,omitempty"`
+	Limits  RunnerLimits `json:"limits,omitempty"`
+	// Killed says which limit the sandbox stopped the phase for, if any, and
+	// Message explains it in words for the user. One of memory, output,
+	// timeout.
+	Killed  string `json:"killed,omitempty"`
+	Message string `json:"message,omitempty"`
+	// OutputTruncated reports that output beyond Limits.OutputBytes was
+	// dropped.
+	OutputTruncated bool `json:"outputTruncated,omitempty"`
+	// Cached is CachedBuild or CachedOutput when the result reused an earlier
+	// build or run.
+	Cached string `json:"cached,omitempty"`
+	// Diagnostics are the compiler or syntax errors of a failed build, with
+	// paths relative to the module root, or for other languages the project
+	// root.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// Profile is the recorded profile of a profiled run. It is missing when
+	// the program did not return from main, such as after os.Exit or a
+	// timeout.
+	Profile *RunnerProfile `json:"profile,omitempty"`
+	// Panic is the parsed panic or fatal error report of a Go program that
+	// crashed, with paths relative to the project root like Diagnostics.
+	Panic *GopanicPanic `json:"panic,omitempty"`
+}
+
+// RunnerTarget is a GOOS/GOARCH pair to compile for.
+type RunnerTarget struct {
+	Goos   string `json:"goos,omitempty"`
+	Goarch string `json:"goarch,omitempty"`
+}
+
+type RunnerTargetsResponse struct {
+	Targets []RunnerTarget `json:"targets,omitempty"`
+}
+
+// ScaffoldTemplate describes one project template.
+type ScaffoldTemplate struct {
+	Name        string `json:"name,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Setup lists the commands to run in the new workspace before the project
+	// builds, typically to fetch its dependencies.
+	Setup []string `json:"setup,omitempty"`
+}
+
+// SearchEdit is one changed line. Line is 1-based.
+type SearchEdit struct {
+	Line   int64  `json:"line,omitempty"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// SearchFileChange is what a replace changes in one file.
+type SearchFileChange struct {
+	Path         string       `json:"path,omitempty"`
+	Etag         string       `json:"etag,omitempty"`
+	Replacements int64        `json:"replacements,omitempty"`
+	Edits        []SearchEdit `json:"edits,omitempty"`
+}
+
+// SearchFileRef names a file in the state identified by its ETag.
+type SearchFileRef struct {
+	Path string `json:"path,omitempty"`
+	Etag string `json:"etag,omitempty"`
+}
+
+// SearchMatch is one matching line. Line is 1-based; Start and End are the
+// byte offsets of the first match within Text.
+type SearchMatch struct {
+	Path  string `json:"path,omitempty"`
+	Line  int64  `json:"line,omitempty"`
+	Start int64  `json:"start,omitempty"`
+	End   int64  `json:"end,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+// SearchParams are the query and header parameters of Search; empty ones are not sent.
+type SearchParams struct {
+	Q     string // ?q
+	Regex string // ?regex
+	Case  string // ?case
+	Word  string // ?word
+	Max   string // ?max
+}
+
+func (p *SearchParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Q != "" {
+		q.Set("q", p.Q)
+	}
+	if p.Regex != "" {
+		q.Set("regex", p.Regex)
+	}
+	if p.Case != "" {
+		q.Set("case", p.Case)
+	}
+	if p.Word != "" {
+		q.Set("word", p.Word)
+	}
+	if p.Max != "" {
+		q.Set("max", p.Max)
+	}
+	return q, h
+}
+
+// SearchPreview lists what a replace would change.
+type SearchPreview struct {
+	Files        []SearchFileChange `json:"files,omitempty"`
+	Replacements int64              `json:"replacements,omitempty"`
+}
+
+// SearchReplaceRequest describes a find-and-replace. The matches of Query are
+// replaced by Replacement, in which $1 or ${name} refer to the groups of a
+// regular expression.
+type SearchReplaceRequest struct {
+	Pattern string `json:"pattern,omitempty"`
+	// Regex treats Pattern as an RE2 regular expression rather than a literal.
+	Regex         bool `json:"regex,omitempty"`
+	CaseSensitive bool `json:"caseSensitive,omitempty"`
+	// WholeWord only matches Pattern between word boundaries.
+	WholeWord bool `json:"wholeWord,omitempty"`
+	// Include restricts the search to files matching one of these
+	// .gitignore-style globs; Exclude skips files and directories matching any
+	// of them.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// MaxResults lowers Config.MaxResults for this search.
+	MaxResults  int64  `json:"maxResults,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	// Files, when set, restricts the replace to these files, each of which
+	// must still have the ETag the preview showed.
+	Files []SearchFileRef `json:"files,omitempty"`
+}
+
+type SearchResponse struct {
+	Results []SearchMatch `json:"results,omitempty"`
+	Summary SearchSummary `json:"summary,omitempty"`
+}
+
+// SearchResult describes an applied replace; Files have their new ETags.
+type SearchResult struct {
+	Files        []SearchFileRef `json:"files,omitempty"`
+	Replacements int64           `json:"replacements,omitempty"`
+	Undo         SearchUndo      `json:"undo,omitempty"`
+}
+
+// SearchSummary describes a finished search.
+type SearchSummary struct {
+	Files   int64 `json:"files,omitempty"`
+	Matches int64 `json:"matches,omitempty"`
+	// Truncated is set when the search stopped at MaxResults.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// SearchSymbol is a top-level Go declaration. Container is the receiver type
+// of a method. Line and Column are 1-based.
+type SearchSymbol struct {
+	Name string `json:"name,omitempty"`
+	// One of const, func, interface, method, struct, type, var.
+	Kind      string `json:"kind,omitempty"`
+	Container string `json:"container,omitempty"`
+	Package   string `json:"package,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Line      int64  `json:"line,omitempty"`
+	Column    int64  `json:"column,omitempty"`
+}
+
+// SearchSymbolsParams are the query and header parameters of SearchSymbols; empty ones are not sent.
+type SearchSymbolsParams struct {
+	Q     string // ?q
+	Limit string // ?limit
+}
+
+func (p *SearchSymbolsParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Q != "" {
+		q.Set("q", p.Q)
+	}
+	if p.Limit != "" {
+		q.Set("limit", p.Limit)
+	}
+	return q, h
+}
+
+type SearchSymbolsResponse struct {
+	Symbols []SearchSymbol `json:"symbols,omitempty"`
+}
+
+// SearchUndo holds the previous contents of the files a replace changed.
+// Passing it to Service.Undo puts them back, provided the files were not
+// changed again since.
+type SearchUndo struct {
+	Files []SearchUndoFile `json:"files,omitempty"`
+}
+
+// SearchUndoFile is one file of an Undo: the contents to restore and the ETag
+// the file must have for that.
+type SearchUndoFile struct {
+	Path    string `json:"path,omitempty"`
+	Etag    string `json:"etag,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type SearchUndoResponse struct {
+	Files []SearchFileRef `json:"files,omitempty"`
+}
+
+// SecretsAuditOrgParams are the query and header parameters of SecretsAuditOrg; empty ones are not sent.
+type SecretsAuditOrgParams struct {
+	Limit string // ?limit
+}
+
+func (p *SecretsAuditOrgParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Limit != "" {
+		q.Set("limit", p.Limit)
+	}
+	return q, h
+}
+
+type SecretsAuditOrgResponse struct {
+	Entries []SecretsEntry `json:"entries,omitempty"`
+}
+
+// SecretsAuditWorkspaceParams are the query and header parameters of SecretsAuditWorkspace; empty ones are not sent.
+type SecretsAuditWorkspaceParams struct {
+	Limit string // ?limit
+}
+
+func (p *SecretsAuditWorkspaceParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Limit != "" {
+		q.Set("limit", p.Limit)
+	}
+	return q, h
+}
+
+type SecretsAuditWorkspaceResponse struct {
+	Entries []SecretsEntry `json:"entries,omitempty"`
+}
+
+// SecretsEntry is one access to a scope's secrets.
+type SecretsEntry struct {
+	Time   *time.Time `json:"time,omitempty"`
+	Action string     `json:"action,omitempty"`
+	Names  []string   `json:"names,omitempty"`
+	User   string     `json:"user,omitempty"`
+	// Workspace and Via, for injections, are the workspace the process runs in
+	// and what kind of process it is: "terminal", "process" or "run".
+	Workspace string `json:"workspace,omitempty"`
+	Via       string `json:"via,omitempty"`
+}
+
+type SecretsListOrgResponse struct {
+	Secrets []SecretsSecret `json:"secrets,omitempty"`
+}
+
+type SecretsListWorkspaceResponse struct {
+	Secrets   []SecretsSecret `json:"secrets,omitempty"`
+	Org       string          `json:"org,omitempty"`
+	Inherited []SecretsSecret `json:"inherited,omitempty"`
+}
+
+// SecretsSecret describes a secret. Its value is never returned.
+type SecretsSecret struct {
+	Name string `json:"name,omitempty"`
+	// Version counts the times the secret was set.
+	Version   int64      `json:"version,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+}
+
+type SecretsSetRequest struct {
+	Value string `json:"value,omitempty"`
+}
+
+// SettingsDocument is a settings or override document at one version.
+type SettingsDocument struct {
+	// Version is 0 for a document never written.
+	Version   int64      `json:"version,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	// Device is the device of an override, or the device a change of the
+	// settings was made from.
+	Device   string `json:"device,omitempty"`
+	Settings any    `json:"settings,omitempty"`
+}
+
+// SettingsEffective are the settings of a device, with its override applied.
+type SettingsEffective struct {
+	Settings any `json:"settings,omitempty"`
+	// Version is the version of the settings, and DeviceVersion that of the
+	// device's override, 0 without one.
+	Version       int64  `json:"version,omitempty"`
+	Device        string `json:"device,omitempty"`
+	DeviceVersion int64  `json:"deviceVersion,omitempty"`
+}
+
+// SettingsEffectiveParams are the query and header parameters of SettingsEffective; empty ones are not sent.
+type SettingsEffectiveParams struct {
+	Device string // ?device
+}
+
+func (p *SettingsEffectiveParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Device != "" {
+		q.Set("device", p.Device)
+	}
+	return q, h
+}
+
+// SettingsGetDeviceParams are the query and header parameters of SettingsGetDevice; empty ones are not sent.
+type SettingsGetDeviceParams struct {
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *SettingsGetDeviceParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+// SettingsGetParams are the query and header parameters of SettingsGet; empty ones are not sent.
+type SettingsGetParams struct {
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *SettingsGetParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+// SettingsPatchDeviceParams are the query and header parameters of SettingsPatchDevice; empty ones are not sent.
+type SettingsPatchDeviceParams struct {
+	IfMatch     string // If-Match header
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *SettingsPatchDeviceParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.IfMatch != "" {
+		h.Set("If-Match", p.IfMatch)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+// SettingsPatchParams are the query and header parameters of SettingsPatch; empty ones are not sent.
+type SettingsPatchParams struct {
+	IfMatch     string // If-Match header
+	Device      string // ?device
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *SettingsPatchParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.IfMatch != "" {
+		h.Set("If-Match", p.IfMatch)
+	}
+	if p.Device != "" {
+		q.Set("device", p.Device)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+// SettingsPutDeviceParams are the query and header parameters of SettingsPutDevice; empty ones are not sent.
+type SettingsPutDeviceParams struct {
+	IfMatch     string // If-Match header
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *SettingsPutDeviceParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.IfMatch != "" {
+		h.Set("If-Match", p.IfMatch)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+// SettingsPutParams are the query and header parameters of SettingsPut; empty ones are not sent.
+type SettingsPutParams struct {
+	IfMatch     string // If-Match header
+	Device      string // ?device
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *SettingsPutParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.IfMatch != "" {
+		h.Set("If-Match", p.IfMatch)
+	}
+	if p.Device != "" {
+		q.Set("device", p.Device)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+// SettingsRestoreParams are the query and header parameters of SettingsRestore; empty ones are not sent.
+type SettingsRestoreParams struct {
+	IfMatch     string // If-Match header
+	Device      string // ?device
+	IfNoneMatch string // If-None-Match header
+}
+
+func (p *SettingsRestoreParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.IfMatch != "" {
+		h.Set("If-Match", p.IfMatch)
+	}
+	if p.Device != "" {
+		q.Set("device", p.Device)
+	}
+	if p.IfNoneMatch != "" {
+		h.Set("If-None-Match", p.IfNoneMatch)
+	}
+	return q, h
+}
+
+// Snapshot describes a restore point. Entries is only filled by Get.
+type Snapshot struct {
+	ID        string     `json:"id,omitempty"`
+	Workspace string     `json:"workspace,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	// One of job, manual, restore, scheduled, shutdown.
+	Trigger string          `json:"trigger,omitempty"`
+	Label   string          `json:"label,omitempty"`
+	Files   int64           `json:"files,omitempty"`
+	Bytes   int64           `json:"bytes,omitempty"`
+	Entries []SnapshotEntry `json:"entries,omitempty"`
+}
+
+type SnapshotCreateRequest struct {
+	Label string `json:"label,omitempty"`
+}
+
+// SnapshotEntry is a file or directory in a snapshot.
+type SnapshotEntry struct {
+	Path    string     `json:"path,omitempty"`
+	Dir     bool       `json:"dir,omitempty"`
+	Exec    bool       `json:"exec,omitempty"`
+	Size    int64      `json:"size,omitempty"`
+	ModTime *time.Time `json:"modTime,omitempty"`
+	Hash    string     `json:"hash,omitempty"`
+}
+
+type SnapshotForkRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+type SnapshotForkResponse struct {
+	ID       string    `json:"id,omitempty"`
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+}
+
+type SnapshotListResponse struct {
+	Snapshots []Snapshot `json:"snapshots,omitempty"`
+}
+
+type SnapshotRestoreResponse struct {
+	Restored string    `json:"restored,omitempty"`
+	Backup   *Snapshot `json:"backup,omitempty"`
+}
+
+// Snippet is a shared program. The fields other than ID and CreatedAt mirror
+// runner.Request, so a snippet can be run as it is.
+type Snippet struct {
+	ID        string             `json:"id,omitempty"`
+	Title     string             `json:"title,omitempty"`
+	Language  string             `json:"language,omitempty"`
+	Source    string             `json:"source,omitempty"`
+	Files     []RunnerFile       `json:"files,omitempty"`
+	Main      string             `json:"main,omitempty"`
+	GoVersion string             `json:"goVersion,omitempty"`
+	Limits    RunnerLimits       `json:"limits,omitempty"`
+	Options   RunnerBuildOptions `json:"options,omitempty"`
+	CreatedAt *time.Time         `json:"createdAt,omitempty"`
+}
+
+type SshdAddRequest struct {
+	Name string `json:"name,omitempty"`
+	// PublicKey is a line of an authorized_keys file, such as the contents of
+	// ~/.ssh/id_ed25519.pub.
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// SshdKey is a public key a user signs in to the gateway with.
+type SshdKey struct {
+	ID   string `json:"id,omitempty"`
+	User string `json:"user,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Type is the key's algorithm, such as ssh-ed25519.
+	Type string `json:"type,omitempty"`
+	// Fingerprint is the SHA256 fingerprint ssh-keygen -l prints.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// PublicKey is the key in authorized_keys form, without a comment.
+	PublicKey  string     `json:"publicKey,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	LastUsedAt any        `json:"lastUsedAt,omitempty"`
+}
+
+type SshdListResponse struct {
+	Keys []SshdKey `json:"keys,omitempty"`
+}
+
+type TasksListResponse struct {
+	Tasks []TasksTask `json:"tasks,omitempty"`
+}
+
+// TasksRequest selects the task to run.
+type TasksRequest struct {
+	Task string `json:"task,omitempty"`
+	// TimeoutMS overrides Config.DefaultTimeout, up to Config.MaxTimeout.
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+}
+
+// TasksResult reports a finished run.
+type TasksResult struct {
+	Task       TasksTask  `json:"task,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	DurationMs int64      `json:"durationMs,omitempty"`
+	// ExitCode is -1 when the task timed out. 127 means the tool it needs,
+	// such as make, is not installed.
+	ExitCode int64 `json:"exitCode,omitempty"`
+	TimedOut bool  `json:"timedOut,omitempty"`
+	// Output is stdout and stderr as they were written, up to
+	// Config.MaxOutputBytes.
+	Output    string `json:"output,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// TasksTask is a runnable project chore found in the workspace.
+type TasksTask struct {
+	// ID names the task in run requests. It is derived from where the task is
+	// defined, so it stays the same while the file does.
+	ID   string `json:"id,omitempty"`
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Dir is the directory the task runs in, relative to the workspace root.
+	Dir string `json:"dir,omitempty"`
+	// File and Line locate the definition; Line is 0 for tasks not defined on
+	// one line.
+	File        string `json:"file,omitempty"`
+	Line        int64  `json:"line,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Command is the command line run, for display.
+	Command string `json:"command,omitempty"`
+}
+
+// TerminalInfo describes a session for listings.
+type TerminalInfo struct {
+	Name      string     `json:"name,omitempty"`
+	Shell     string     `json:"shell,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	Clients   int64      `json:"clients,omitempty"`
+	Cols      int64      `json:"cols,omitempty"`
+	Rows      int64      `json:"rows,omitempty"`
+}
+
+type TerminalListResponse struct {
+	Terminals []TerminalInfo `json:"terminals,omitempty"`
+}
+
+// TextnormDiagnostic is an invisible character found in text.
+type TextnormDiagnostic struct {
+	// Line and Column are 1-based; Column counts runes. Offset is in bytes
+	// from the start of the text.
+	Line   int64 `json:"line,omitempty"`
+	Column int64 `json:"column,omitempty"`
+	Offset int64 `json:"offset,omitempty"`
+	// Code is the character's code point, such as U+200B, and Name its name.
+	Code string `json:"code,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// TextnormPolicy says how text is normalized when it is saved.
+type TextnormPolicy struct {
+	// One of , auto, crlf, lf.
+	Eol string `json:"eol,omitempty"`
+	// TrimTrailingWhitespace removes spaces and tabs at the ends of lines,
+	// except in Markdown, where two of them break the line.
+	TrimTrailingWhitespace bool `json:"trimTrailingWhitespace,omitempty"`
+	// StripBOM removes a UTF-8 byte order mark from the start of a file.
+	StripBom bool `json:"stripBom,omitempty"`
+}
+
+// TextnormReport tells what normalizing a text did.
+type TextnormReport struct {
+	// EOL counts the line endings converted, and Trimmed the lines trailing
+	// whitespace was removed from.
+	Eol     int64 `json:"eol,omitempty"`
+	Trimmed int64 `json:"trimmed,omitempty"`
+	// BOM says a byte order mark was removed.
+	Bom bool `json:"bom,omitempty"`
+	// Removed counts the invisible characters Sanitize removed or replaced.
+	Removed int64 `json:"removed,omitempty"`
+	// Invisible are the first MaxDiagnostics invisible characters left in the
+	// text.
+	Invisible []TextnormDiagnostic `json:"invisible,omitempty"`
+}
+
+type TextnormSanitizeRequest struct {
+	// Path is the file the text is pasted into, for the policy's exceptions
+	// such as Markdown's trailing spaces.
+	Path string `json:"path,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+type TextnormSanitized struct {
+	Text   string          `json:"text,omitempty"`
+	Report *TextnormReport `json:"report,omitempty"`
+}
+
+// Toolchain is one available Go version.
+type Toolchain struct {
+	Version string `json:"version,omitempty"`
+	// Image holds the toolchain used to build and run programs.
+	Image string `json:"image,omitempty"`
+	// WorkspaceImage is the image of the long-lived workspace container.
+	WorkspaceImage string `json:"workspaceImage,omitempty"`
+}
+
+type ToolchainListResponse struct {
+	Toolchains []Toolchain `json:"toolchains,omitempty"`
+	Default    string      `json:"default,omitempty"`
+}
+
+// ToolchainSettings is a workspace's toolchain configuration.
+type ToolchainSettings struct {
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+// TrashItem is a deleted file or directory.
+type TrashItem struct {
+	ID   string `json:"id,omitempty"`
+	Path string `json:"path,omitempty"`
+	// One of dir, file, symlink.
+	Type string `json:"type,omitempty"`
+	// Size is the bytes the item holds, and Files how many files.
+	Size  int64 `json:"size,omitempty"`
+	Files int64 `json:"files,omitempty"`
+	// One of capture, delete.
+	Source    string     `json:"source,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// SavedAt is when the contents of a captured item were saved; the file may
+	// have changed after.
+	SavedAt any `json:"savedAt,omitempty"`
+}
+
+type TrashListing struct {
+	Items    []TrashItem `json:"items,omitempty"`
+	Bytes    int64       `json:"bytes,omitempty"`
+	MaxBytes int64       `json:"maxBytes,omitempty"`
+}
+
+type TrashRestoreRequest struct {
+	// Path restores to somewhere other than where the item was deleted.
+	Path      string `json:"path,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+}
+
+// Upload is a file being uploaded.
+type Upload struct {
+	ID   string `json:"id,omitempty"`
+	Path string `json:"path,omitempty"`
+	Size int64  `json:"size,omitempty"`
+	// Offset is how many bytes have been received.
+	Offset int64 `json:"offset,omitempty"`
+	// Overwrite allows replacing an existing file.
+	Overwrite bool `json:"overwrite,omitempty"`
+	// SHA256, when set, is checked against the bytes received before the file
+	// is moved into place.
+	Sha256    string     `json:"sha256,omitempty"`
+	UserID    string     `json:"userId,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Entry is the file written, once the upload is complete.
+	Entry *FilesEntry `json:"entry,omitempty"`
+}
+
+type UploadCreateRequest struct {
+	Path      string `json:"path,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+	Sha256    string `json:"sha256,omitempty"`
+}
+
+// UploadPatchParams are the query and header parameters of UploadPatch; empty ones are not sent.
+type UploadPatchParams struct {
+	UploadOffset string // Upload-Offset header
+}
+
+func (p *UploadPatchParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.UploadOffset != "" {
+		h.Set("Upload-Offset", p.UploadOffset)
+	}
+	return q, h
+}
+
+// VulncheckDependency is a module the scanned module depends on, with the
+// vulnerabilities found in it. Path "stdlib" stands for the standard library,
+// at the Go version the module is built with.
+type VulncheckDependency struct {
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Line is the go.mod line that requires the module, 0 for modules only
+	// required indirectly and not listed.
+	Line int64 `json:"line,omitempty"`
+	// FixedVersion is the lowest version fixing all of Vulns, "" when some are
+	// not fixed yet.
+	FixedVersion string          `json:"fixedVersion,omitempty"`
+	Vulns        []VulncheckVuln `json:"vulns,omitempty"`
+}
+
+// VulncheckLastParams are the query and header parameters of VulncheckLast; empty ones are not sent.
+type VulncheckLastParams struct {
+	Module string // ?module
+}
+
+func (p *VulncheckLastParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Module != "" {
+		q.Set("module", p.Module)
+	}
+	return q, h
+}
+
+// VulncheckRequest selects the module to scan.
+type VulncheckRequest struct {
+	// Module is the directory of the module's go.mod relative to the workspace
+	// root; defaults to ".".
+	Module string `json:"module,omitempty"`
+}
+
+// VulncheckResult is the outcome of a scan.
+type VulncheckResult struct {
+	// Module is the scanned module's directory relative to the workspace.
+	Module     string     `json:"module,omitempty"`
+	Trigger    string     `json:"trigger,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	DurationMs int64      `json:"durationMs,omitempty"`
+	Scanner    string     `json:"scanner,omitempty"`
+	GoVersion  string     `json:"goVersion,omitempty"`
+	Db         string     `json:"db,omitempty"`
+	// DBModified is when the vulnerability database was last updated.
+	DbModified   any                   `json:"dbModified,omitempty"`
+	Dependencies []VulncheckDependency `json:"dependencies,omitempty"`
+	Diagnostics  []Diagnostic          `json:"diagnostics,omitempty"`
+}
+
+// VulncheckVuln is one vulnerability affecting a dependency.
+type VulncheckVuln struct {
+	ID      string   `json:"id,omitempty"`
+	Aliases []string `json:"aliases,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	// FixedVersion is the first version without the vulnerability, "" when
+	// there is none yet.
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	Level        string `json:"level,omitempty"`
+	// Symbols are the vulnerable functions the module calls.
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// WasmBuildRequest selects what to compile.
+type WasmBuildRequest struct {
+	// Package is the main package relative to the workspace root, like
+	// "./cmd/demo"; defaults to ".".
+	Package string `json:"package,omitempty"`
+	// Compiler defaults to go. One of go, tinygo.
+	Compiler string `json:"compiler,omitempty"`
+}
+
+// WasmBuildResult is the outcome of a build. Bundle is set when it succeeded.
+type WasmBuildResult struct {
+	Ok          bool         `json:"ok,omitempty"`
+	DurationMs  int64        `json:"durationMs,omitempty"`
+	Output      string       `json:"output,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	Bundle      *WasmBundle  `json:"bundle,omitempty"`
+}
+
+// WasmBundle is a compiled module with the matching wasm_exec.js loader.
+type WasmBundle struct {
+	Version int64  `json:"version,omitempty"`
+	Package string `json:"package,omitempty"`
+	// One of go, tinygo.
+	Compiler string     `json:"compiler,omitempty"`
+	Size     int64      `json:"size,omitempty"`
+	BuiltAt  *time.Time `json:"builtAt,omitempty"`
+}
+
+type WebhookCreateRequest struct {
+	URL    string   `json:"url,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries,omitempty"`
+}
+
+// WebhookDelivery is one attempt to deliver an event.
+type WebhookDelivery struct {
+	// ID is the same for every attempt of one delivery.
+	ID      string     `json:"id,omitempty"`
+	Event   string     `json:"event,omitempty"`
+	Attempt int64      `json:"attempt,omitempty"`
+	Time    *time.Time `json:"time,omitempty"`
+	// Status is the receiver's, and Error why the attempt failed, if it did.
+	Status     int64  `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	// Retry reports that the delivery will be attempted again.
+	Retry bool `json:"retry,omitempty"`
+}
+
+// WebhookHook subscribes a URL to the events of a workspace.
+type WebhookHook struct {
+	ID  string `json:"id,omitempty"`
+	URL string `json:"url,omitempty"`
+	// Events are the types sent; empty sends them all.
+	Events    []string   `json:"events,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	// Secret signs the deliveries. It is only returned when the hook is
+	// created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookListResponse struct {
+	Webhooks []WebhookHook `json:"webhooks,omitempty"`
+}
+
+type WebhookPingResponse struct {
+	Delivery string `json:"delivery,omitempty"`
+}
+
+// WorkspaceCreateParams are the query and header parameters of WorkspaceCreate; empty ones are not sent.
+type WorkspaceCreateParams struct {
+	Template string // ?template
+}
+
+func (p *WorkspaceCreateParams) values() (url.Values, http.Header) {
+	q, h := make(url.Values), make(http.Header)
+	if p == nil {
+		return q, h
+	}
+	if p.Template != "" {
+		q.Set("template", p.Template)
+	}
+	return q, h
+}
+
+type WorkspaceCreateRequest struct {
+	ID string `json:"id,omitempty"`
+	// Module and GoVersion parameterize the template, if any.
+	Module    string `json:"module,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+// WorkspaceInfo describes a workspace in API responses.
+type WorkspaceInfo struct {
+	ID string `json:"id,omitempty"`
+	// Template and Files describe what a workspace created from a template was
+	// seeded with; Setup lists the commands to run before it builds.
+	Template string   `json:"template,omitempty"`
+	Files    []string `json:"files,omitempty"`
+	Setup    []string `json:"setup,omitempty"`
+}
+
+type WorkspaceListResponse struct {
+	Workspaces []WorkspaceInfo `json:"workspaces,omitempty"`
+}
+
+type WorkspaceTemplatesResponse struct {
+	Templates []ScaffoldTemplate `json:"templates,omitempty"`
+}
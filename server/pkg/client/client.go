@@ -0,0 +1,158 @@
+// Package client is a Go client of the IDE's HTTP API, with a method per
+// operation of its OpenAPI definition, served at /api/openapi.json. The
+// methods and the types of their requests and responses are generated
+// from the server's handlers by cmd/webide-openapi, so they follow the
+// server release by release:
+//
+//	c, err := client.New(client.Config{Server: "https://ide.example.com", Token: token})
+//	...
+//	list, err := c.WorkspaceList(ctx)
+//
+// Calls the server refuses fail with an *Error carrying its status and
+// message. WebSocket routes, such as terminals and streamed runs, are left
+// out.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Config configures a Client.
+type Config struct {
+	// Server is the URL of the IDE, such as https://ide.example.com.
+	Server string
+	// Token is an access token or personal access token; empty for a
+	// server without sign-in.
+	Token string
+	// HTTPClient sends the requests; defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Client calls the API of one server.
+type Client struct {
+	server *url.URL
+	token  string
+	http   *http.Client
+}
+
+// New returns a Client for cfg.
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(cfg.Server, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("client: bad server URL %q", cfg.Server)
+	}
+	hc := cfg.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{server: u, token: cfg.Token, http: hc}, nil
+}
+
+// Error is the answer of a call the server refused.
+type Error struct {
+	StatusCode int
+	// Message is the error of the response body, or its status text.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.StatusCode, e.Message)
+}
+
+// IsStatus reports whether err is an *Error with the status code.
+func IsStatus(err error, code int) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == code
+}
+
+// do sends a request with the body, unless nil, and returns the response
+// of a 2xx or 3xx status, failing with an *Error for others.
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, h http.Header, body io.Reader, contentType string) (*http.Response, error) {
+	u := c.server.String() + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	// The methods are generated from v1 of the API, whichever version
+	// the server defaults to.
+	req.Header.Set("API-Version", "v1")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil && contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, apiError(resp.StatusCode, data)
+	}
+	return resp, nil
+}
+
+// send is do with in, unless nil, as the JSON body.
+func (c *Client) send(ctx context.Context, method, path string, q url.Values, h http.Header, in any) (*http.Response, error) {
+	if in == nil {
+		return c.do(ctx, method, path, q, h, nil, "")
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, method, path, q, h, bytes.NewReader(data), "application/json")
+}
+
+// decode decodes the JSON body of resp into out, unless it is nil, and
+// closes it.
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode response: %w", err)
+	}
+	return nil
+}
+
+func apiError(status int, body []byte) error {
+	var e struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		msg = e.Error
+	}
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	return &Error{StatusCode: status, Message: msg}
+}
+
+// escapePath escapes each element of a slash-separated path parameter.
+func escapePath(p string) string {
+	elems := strings.Split(p, "/")
+	for i, e := range elems {
+		elems[i] = url.PathEscape(e)
+	}
+	return strings.Join(elems, "/")
+}
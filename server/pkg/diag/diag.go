@@ -0,0 +1,114 @@
+// Package diag defines the diagnostic schema shared by the compiler, vet,
+// and linters, and parses the "file:line:col: message" form the Go tools
+// print.
+package diag
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severity ranks a diagnostic.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Position is a 1-based line and column; columns count bytes, as in the
+// Go tools' output.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Range is a span of a file. End equals Start when the tool reports a
+// single position.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Edit replaces Range of File with NewText.
+type Edit struct {
+	File    string `json:"file"`
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// Fix is a suggested change resolving a diagnostic.
+type Fix struct {
+	Message string `json:"message"`
+	Edits   []Edit `json:"edits"`
+}
+
+// Diagnostic is one finding. File is relative to the workspace or module
+// root. Source names the tool and Code the check within it, such as
+// "printf" for vet or "SA4006" for staticcheck.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Range    Range    `json:"range"`
+	Severity Severity `json:"severity"`
+	Source   string   `json:"source"`
+	Code     string   `json:"code,omitempty"`
+	Message  string   `json:"message"`
+	Fixes    []Fix    `json:"fixes,omitempty"`
+}
+
+var linePattern = regexp.MustCompile(`^(.+?\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// ParseLine parses "file.go:line[:col]: message". Column defaults to 1.
+func ParseLine(line string) (Diagnostic, bool) {
+	m := linePattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+	if m == nil {
+		return Diagnostic{}, false
+	}
+	ln, _ := strconv.Atoi(m[2])
+	col := 1
+	if m[3] != "" {
+		col, _ = strconv.Atoi(m[3])
+	}
+	pos := Position{Line: ln, Column: col}
+	return Diagnostic{
+		File:     strings.TrimPrefix(m[1], "./"),
+		Range:    Range{Start: pos, End: pos},
+		Severity: SeverityError,
+		Message:  m[4],
+	}, true
+}
+
+// ParsePosition parses "file.go:line:col" as printed in vet's JSON output.
+func ParsePosition(s string) (file string, pos Position, ok bool) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return "", Position{}, false
+	}
+	j := strings.LastIndexByte(s[:i], ':')
+	if j < 0 {
+		return "", Position{}, false
+	}
+	ln, err1 := strconv.Atoi(s[j+1 : i])
+	col, err2 := strconv.Atoi(s[i+1:])
+	if err1 != nil || err2 != nil {
+		return "", Position{}, false
+	}
+	return s[:j], Position{Line: ln, Column: col}, true
+}
+
+// OffsetPosition converts a byte offset in src to a Position.
+func OffsetPosition(src []byte, offset int) Position {
+	offset = min(max(offset, 0), len(src))
+	pos := Position{Line: 1, Column: 1}
+	for _, c := range src[:offset] {
+		if c == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
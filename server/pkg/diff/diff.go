@@ -0,0 +1,250 @@
+// Package diff computes line-based differences and three-way merges. It
+// uses Myers' O(ND) algorithm, so inputs that differ little are compared
+// quickly however long they are.
+package diff
+
+import "strings"
+
+// LineOp marks a line of a hunk.
+type LineOp string
+
+const (
+	OpContext LineOp = " "
+	OpInsert  LineOp = "+"
+	OpDelete  LineOp = "-"
+)
+
+// Line is one line of a hunk. Text excludes the line terminator.
+type Line struct {
+	Op   LineOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// Hunk is a region of change with surrounding context. Starts are 1-based
+// line numbers; a zero count means the hunk inserts or deletes at that
+// position, in which case the start is the line before it.
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"`
+	NewLines int    `json:"newLines"`
+	Section  string `json:"section,omitempty"`
+	Lines    []Line `json:"lines"`
+}
+
+// SplitLines splits s after each newline, keeping the terminators so that
+// joining the result restores s exactly.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// match pairs a line of a with an equal line of b.
+type match struct{ a, b int }
+
+// lcs returns a longest common subsequence of a and b as index pairs in
+// increasing order.
+func lcs(a, b []string) []match {
+	// Trim the common prefix and suffix, which is most of the input for
+	// typical edits.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	out := make([]match, 0, pre+suf)
+	for i := 0; i < pre; i++ {
+		out = append(out, match{i, i})
+	}
+	for _, m := range myers(a[pre:len(a)-suf], b[pre:len(b)-suf]) {
+		out = append(out, match{m.a + pre, m.b + pre})
+	}
+	for i := suf; i > 0; i-- {
+		out = append(out, match{len(a) - i, len(b) - i})
+	}
+	return out
+}
+
+// maxEditDistance bounds the search. Inputs that differ by more are
+// treated as entirely replaced, which is still a correct, if not minimal,
+// diff and keeps memory use bounded.
+const maxEditDistance = 4096
+
+// myers finds the matches of a shortest edit script from a to b.
+func myers(a, b []string) []match {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+	limit := n + m
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds v[off-d-1 : off+d+2] as it was before round d.
+	var trace [][]int
+	for d := 0; d <= limit && d <= maxEditDistance; d++ {
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d, off)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, a, b []string, d, off int) []match {
+	x, y := len(a), len(b)
+	var out []match
+	for ; d > 0; d-- {
+		at := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			out = append(out, match{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		out = append(out, match{x, y})
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Lines returns the hunks turning oldText into newText, each with up to
+// context unchanged lines around its changes. Hunks closer together than
+// twice the context are joined, as in unified diffs.
+func Lines(oldText, newText string, context int) []Hunk {
+	a, b := SplitLines(oldText), SplitLines(newText)
+	ms := append(lcs(a, b), match{len(a), len(b)}) // sentinel
+
+	// Expand the matches into a flat edit script.
+	type edit struct {
+		op   LineOp
+		a, b int // line indexes before the edit
+	}
+	var script []edit
+	i, j := 0, 0
+	for _, m := range ms {
+		for ; i < m.a; i++ {
+			script = append(script, edit{OpDelete, i, j})
+		}
+		for ; j < m.b; j++ {
+			script = append(script, edit{OpInsert, i, j})
+		}
+		if m.a < len(a) {
+			script = append(script, edit{OpContext, i, j})
+			i++
+			j++
+		}
+	}
+
+	var hunks []Hunk
+	for s := 0; s < len(script); {
+		if script[s].op == OpContext {
+			s++
+			continue
+		}
+		start := max(s-context, 0)
+		for start < s && script[start].op != OpContext {
+			start++
+		}
+		end := s
+		for end < len(script) {
+			if script[end].op != OpContext {
+				end++
+				continue
+			}
+			run := end
+			for run < len(script) && script[run].op == OpContext {
+				run++
+			}
+			if run == len(script) || run-end > 2*context {
+				end = min(end+context, run)
+				break
+			}
+			end = run
+		}
+		h := Hunk{OldStart: script[start].a + 1, NewStart: script[start].b + 1}
+		for _, e := range script[start:end] {
+			switch e.op {
+			case OpContext:
+				h.Lines = append(h.Lines, Line{OpContext, trimEOL(a[e.a])})
+				h.OldLines++
+				h.NewLines++
+			case OpDelete:
+				h.Lines = append(h.Lines, Line{OpDelete, trimEOL(a[e.a])})
+				h.OldLines++
+			case OpInsert:
+				h.Lines = append(h.Lines, Line{OpInsert, trimEOL(b[e.b])})
+				h.NewLines++
+			}
+		}
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		hunks = append(hunks, h)
+		s = end
+	}
+	return hunks
+}
+
+// MapLines follows the lines of oldText into newText: the result holds,
+// for each old line, the 1-based number of the same line in newText, or 0
+// if the edit changed or removed it. Line terminators are ignored.
+func MapLines(oldText, newText string) []int {
+	a, b := SplitLines(oldText), SplitLines(newText)
+	for i := range a {
+		a[i] = trimEOL(a[i])
+	}
+	for i := range b {
+		b[i] = trimEOL(b[i])
+	}
+	out := make([]int, len(a))
+	for _, m := range lcs(a, b) {
+		out[m.a] = m.b + 1
+	}
+	return out
+}
+
+func trimEOL(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
@@ -0,0 +1,163 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// apply applies hunks to the lines of oldText and returns the lines of the
+// result, without terminators, failing t where a hunk does not fit.
+func apply(t *testing.T, oldText string, hunks []Hunk) []string {
+	t.Helper()
+	old := trimAll(SplitLines(oldText))
+	var out []string
+	pos := 0
+	for _, h := range hunks {
+		start, newStart := h.OldStart-1, h.NewStart-1
+		if h.OldLines == 0 {
+			start = h.OldStart
+		}
+		if h.NewLines == 0 {
+			newStart = h.NewStart
+		}
+		if start < pos || start > len(old) {
+			t.Fatalf("hunk %+v starts at old line %d, after line %d", h, start, pos)
+		}
+		out = append(out, old[pos:start]...)
+		pos = start
+		if len(out) != newStart {
+			t.Fatalf("hunk %+v starts at new line %d, want %d", h, newStart, len(out))
+		}
+		oldN, newN := 0, 0
+		for _, l := range h.Lines {
+			switch l.Op {
+			case OpContext, OpDelete:
+				if pos >= len(old) || old[pos] != l.Text {
+					t.Fatalf("hunk %+v: line %q does not match old line %d", h, l.Text, pos+1)
+				}
+				if l.Op == OpContext {
+					out = append(out, l.Text)
+					newN++
+				}
+				pos++
+				oldN++
+			case OpInsert:
+				out = append(out, l.Text)
+				newN++
+			}
+		}
+		if oldN != h.OldLines || newN != h.NewLines {
+			t.Fatalf("hunk %+v counts %d old and %d new lines", h, oldN, newN)
+		}
+	}
+	return append(out, old[pos:]...)
+}
+
+func randomText(r *rand.Rand, alphabet string) string {
+	var b strings.Builder
+	for range r.IntN(30) {
+		b.WriteByte(alphabet[r.IntN(len(alphabet))])
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// edit changes a few random lines of text.
+func edit(r *rand.Rand, text string) string {
+	lines := SplitLines(text)
+	for range r.IntN(4) {
+		i := r.IntN(len(lines) + 1)
+		switch r.IntN(3) {
+		case 0:
+			lines = slices.Insert(lines, i, fmt.Sprintf("new %d\n", r.IntN(100)))
+		case 1:
+			if i < len(lines) {
+				lines = slices.Delete(lines, i, i+1)
+			}
+		case 2:
+			if i < len(lines) {
+				lines[i] = fmt.Sprintf("changed %d\n", r.IntN(100))
+			}
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func TestLinesApply(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for n := range 2000 {
+		oldText, newText := randomText(r, "abcde"), randomText(r, "abcde")
+		if n%2 == 0 {
+			newText = edit(r, oldText)
+		}
+		context := r.IntN(4)
+		hunks := Lines(oldText, newText, context)
+		got := apply(t, oldText, hunks)
+		if want := trimAll(SplitLines(newText)); !slices.Equal(got, want) {
+			t.Fatalf("Lines(%q, %q, %d) applied gives %q, want %q", oldText, newText, context, got, want)
+		}
+		if oldText == newText && len(hunks) != 0 {
+			t.Fatalf("Lines of equal texts = %v, want none", hunks)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	got := Lines("a\nb\nc\nd\ne\nf\ng\n", "a\nb\nC\nd\ne\nf\ng\nh\n", 1)
+	want := []Hunk{
+		{OldStart: 2, OldLines: 3, NewStart: 2, NewLines: 3, Lines: []Line{
+			{OpContext, "b"}, {OpDelete, "c"}, {OpInsert, "C"}, {OpContext, "d"},
+		}},
+		{OldStart: 7, OldLines: 1, NewStart: 7, NewLines: 2, Lines: []Line{
+			{OpContext, "g"}, {OpInsert, "h"},
+		}},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Lines = %+v, want %+v", got, want)
+	}
+
+	// Changes closer than twice the context share a hunk.
+	if got := Lines("a\nb\nc\nd\n", "A\nb\nc\nD\n", 1); len(got) != 1 {
+		t.Errorf("Lines of nearby changes = %d hunks, want 1", len(got))
+	}
+	// An insert into an empty text starts after line 0.
+	got = Lines("", "x\n", 3)
+	if len(got) != 1 || got[0].OldStart != 0 || got[0].OldLines != 0 || got[0].NewStart != 1 {
+		t.Errorf("Lines of an insert into nothing = %+v", got)
+	}
+}
+
+// TestLinesEditDistance checks that inputs differing by more than
+// maxEditDistance still get a correct diff, replacing everything.
+func TestLinesEditDistance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compares long inputs")
+	}
+	var a, b strings.Builder
+	for i := range maxEditDistance/2 + 10 {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	// A shared line in the middle is not found beyond the limit.
+	oldText, newText := a.String()+"same\n"+a.String(), b.String()+"same\n"+b.String()
+	if ms := myers(SplitLines(oldText), SplitLines(newText)); ms != nil {
+		t.Fatalf("myers beyond maxEditDistance found %d matches, want none", len(ms))
+	}
+	hunks := Lines(oldText, newText, 3)
+	if got, want := apply(t, oldText, hunks), trimAll(SplitLines(newText)); !slices.Equal(got, want) {
+		t.Fatal("Lines beyond maxEditDistance does not reproduce the new text")
+	}
+	if len(hunks) != 1 || hunks[0].OldLines != len(SplitLines(oldText)) {
+		t.Errorf("Lines beyond maxEditDistance = %d hunks, want one replacing everything", len(hunks))
+	}
+}
+
+func TestMapLines(t *testing.T) {
+	got := MapLines("a\nb\nc\r\nd\n", "x\na\nc\nd\n")
+	if want := []int{2, 0, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("MapLines = %v, want %v", got, want)
+	}
+}
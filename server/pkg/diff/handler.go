@@ -0,0 +1,38 @@
+package diff
+
+import (
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// maxMergeInput caps the combined size of the three merge inputs.
+const maxMergeInput = 12 << 20
+
+// Handler serves the text merge helper.
+type Handler struct{}
+
+// NewHandler returns a Handler.
+func NewHandler() *Handler { return &Handler{} }
+
+// Register mounts the merge route on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/merge", h.merge)
+}
+
+type mergeRequest struct {
+	Base   string `json:"base"`
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+	Labels Labels `json:"labels,omitempty"`
+}
+
+// merge three-way merges the texts in the body and reports conflicts.
+func (h *Handler) merge(w http.ResponseWriter, r *http.Request) {
+	var req mergeRequest
+	if err := httpx.DecodeJSON(w, r, &req, maxMergeInput); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.JSON(w, http.StatusOK, Merge3(req.Base, req.Ours, req.Theirs, req.Labels))
+}
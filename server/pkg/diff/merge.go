@@ -0,0 +1,160 @@
+package diff
+
+import "strings"
+
+// Region is a stretch of lines from one merge input. Start is the 1-based
+// line number of the first line, or of the line before an empty region.
+type Region struct {
+	Start int      `json:"start"`
+	Lines []string `json:"lines"`
+}
+
+// Conflict is a place where both sides changed the base differently.
+// Start and End are the 1-based lines of Merged, inclusive, covered by the
+// conflict markers.
+type Conflict struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Base   Region `json:"base"`
+	Ours   Region `json:"ours"`
+	Theirs Region `json:"theirs"`
+}
+
+// Labels name the sides in conflict markers.
+type Labels struct {
+	Ours   string `json:"ours,omitempty"`
+	Base   string `json:"base,omitempty"`
+	Theirs string `json:"theirs,omitempty"`
+}
+
+// MergeResult is the outcome of Merge3. Merged holds the combined text,
+// with diff3-style markers around each conflict.
+type MergeResult struct {
+	Merged    string     `json:"merged"`
+	Clean     bool       `json:"clean"`
+	Conflicts []Conflict `json:"conflicts"`
+}
+
+// Merge3 combines the changes that ours and theirs each made to base.
+// Changes to different regions are both kept; identical changes are kept
+// once; differing changes to the same region are conflicts.
+func Merge3(base, ours, theirs string, labels Labels) MergeResult {
+	if labels.Ours == "" {
+		labels.Ours = "ours"
+	}
+	if labels.Base == "" {
+		labels.Base = "base"
+	}
+	if labels.Theirs == "" {
+		labels.Theirs = "theirs"
+	}
+	o, a, b := SplitLines(base), SplitLines(ours), SplitLines(theirs)
+
+	// Map each base line to its partner on either side, if any.
+	toA := make([]int, len(o))
+	toB := make([]int, len(o))
+	for i := range o {
+		toA[i], toB[i] = -1, -1
+	}
+	for _, m := range lcs(o, a) {
+		toA[m.a] = m.b
+	}
+	for _, m := range lcs(o, b) {
+		toB[m.a] = m.b
+	}
+
+	res := MergeResult{Conflicts: []Conflict{}}
+	var out strings.Builder
+	line := 0         // lines written to out
+	openLine := false // out ends without a newline
+	emit := func(lines []string) {
+		for _, l := range lines {
+			out.WriteString(l)
+			line++
+			openLine = !strings.HasSuffix(l, "\n")
+		}
+	}
+	// marker writes a conflict marker, first ending the previous line if
+	// the input lacked a final newline.
+	marker := func(s string) {
+		if openLine {
+			out.WriteString("\n")
+		}
+		out.WriteString(s + "\n")
+		line++
+		openLine = false
+	}
+
+	i, j, k := 0, 0, 0
+	for {
+		// Find the next base line kept by both sides.
+		next := i
+		for next < len(o) && (toA[next] < j || toB[next] < k) {
+			next++
+		}
+		if next == i && next < len(o) && toA[next] == j && toB[next] == k {
+			emit(o[i : i+1])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		ni, nj, nk := len(o), len(a), len(b)
+		if next < len(o) {
+			ni, nj, nk = next, toA[next], toB[next]
+		}
+		oc, ac, bc := o[i:ni], a[j:nj], b[k:nk]
+		switch {
+		case equal(ac, oc):
+			emit(bc)
+		case equal(bc, oc), equal(ac, bc):
+			emit(ac)
+		default:
+			c := Conflict{
+				Start:  line + 1,
+				Base:   Region{Start: i + 1, Lines: trimAll(oc)},
+				Ours:   Region{Start: j + 1, Lines: trimAll(ac)},
+				Theirs: Region{Start: k + 1, Lines: trimAll(bc)},
+			}
+			for _, r := range []*Region{&c.Base, &c.Ours, &c.Theirs} {
+				if len(r.Lines) == 0 {
+					r.Start--
+				}
+			}
+			marker("<<<<<<< " + labels.Ours)
+			emit(ac)
+			marker("||||||| " + labels.Base)
+			emit(oc)
+			marker("=======")
+			emit(bc)
+			marker(">>>>>>> " + labels.Theirs)
+			c.End = line
+			res.Conflicts = append(res.Conflicts, c)
+		}
+		if next >= len(o) {
+			break
+		}
+		i, j, k = ni, nj, nk
+	}
+	res.Merged = out.String()
+	res.Clean = len(res.Conflicts) == 0
+	return res
+}
+
+func equal(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func trimAll(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = trimEOL(l)
+	}
+	return out
+}
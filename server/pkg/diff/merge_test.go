@@ -0,0 +1,93 @@
+package diff
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		clean              bool
+	}{
+		{"both unchanged", "a\nb\n", "a\nb\n", "a\nb\n", "a\nb\n", true},
+		{"ours only", "a\nb\nc\n", "a\nB\nc\n", "a\nb\nc\n", "a\nB\nc\n", true},
+		{"theirs only", "a\nb\nc\n", "a\nb\nc\n", "a\nb\nC\n", "a\nb\nC\n", true},
+		{"separate regions", "a\nb\nc\nd\ne\n", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", true},
+		{"same change", "a\nb\nc\n", "a\nX\nc\n", "a\nX\nc\n", "a\nX\nc\n", true},
+		{"insert and delete apart", "a\nb\nc\nd\n", "a\nnew\nb\nc\nd\n", "a\nb\nc\n", "a\nnew\nb\nc\n", true},
+		{"conflict", "a\nb\nc\n", "a\nB\nc\n", "a\nX\nc\n",
+			"a\n<<<<<<< ours\nB\n||||||| base\nb\n=======\nX\n>>>>>>> theirs\nc\n", false},
+		{"delete against change", "a\nb\nc\n", "a\nc\n", "a\nX\nc\n",
+			"a\n<<<<<<< ours\n||||||| base\nb\n=======\nX\n>>>>>>> theirs\nc\n", false},
+		{"no final newline", "a", "b", "c",
+			"<<<<<<< ours\nb\n||||||| base\na\n=======\nc\n>>>>>>> theirs\n", false},
+	}
+	for _, tt := range tests {
+		res := Merge3(tt.base, tt.ours, tt.theirs, Labels{})
+		if res.Merged != tt.want || res.Clean != tt.clean {
+			t.Errorf("%s: Merge3 = %q (clean %v), want %q (clean %v)", tt.name, res.Merged, res.Clean, tt.want, tt.clean)
+		}
+		if res.Clean != (len(res.Conflicts) == 0) {
+			t.Errorf("%s: clean %v with %d conflicts", tt.name, res.Clean, len(res.Conflicts))
+		}
+	}
+}
+
+func TestMerge3Conflict(t *testing.T) {
+	res := Merge3("a\nb\nc\n", "a\nc\n", "a\nX\nY\nc\n", Labels{Ours: "mine", Base: "v1", Theirs: "main"})
+	want := []Conflict{{
+		Start:  2,
+		End:    8,
+		Base:   Region{Start: 2, Lines: []string{"b"}},
+		Ours:   Region{Start: 1, Lines: []string{}},
+		Theirs: Region{Start: 2, Lines: []string{"X", "Y"}},
+	}}
+	if !reflect.DeepEqual(res.Conflicts, want) {
+		t.Errorf("conflicts = %+v, want %+v", res.Conflicts, want)
+	}
+	wantText := "a\n<<<<<<< mine\n||||||| v1\nb\n=======\nX\nY\n>>>>>>> main\nc\n"
+	if res.Merged != wantText {
+		t.Errorf("merged = %q, want %q", res.Merged, wantText)
+	}
+}
+
+// TestMerge3OneSided checks that a merge where only one side changed the
+// base gives that side, for random texts.
+func TestMerge3OneSided(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	for range 1000 {
+		base := randomText(r, "abc")
+		changed := edit(r, base)
+		for _, res := range []MergeResult{
+			Merge3(base, changed, base, Labels{}),
+			Merge3(base, base, changed, Labels{}),
+			Merge3(base, changed, changed, Labels{}),
+		} {
+			if !res.Clean || res.Merged != changed {
+				t.Fatalf("one-sided merge of %q into %q = %q (clean %v)", changed, base, res.Merged, res.Clean)
+			}
+		}
+	}
+}
+
+// TestMerge3EditDistance checks that sides too far apart to be compared
+// conflict as a whole rather than merging wrongly.
+func TestMerge3EditDistance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compares long inputs")
+	}
+	r := rand.New(rand.NewPCG(5, 6))
+	var base, ours []byte
+	for range maxEditDistance/2 + 10 {
+		base = append(base, byte('a'+r.IntN(26)), '\n')
+		ours = append(ours, byte('A'+r.IntN(26)), '\n')
+	}
+	res := Merge3(string(base), string(ours)+"x\n", string(base)+"y\n", Labels{})
+	if res.Clean || len(res.Conflicts) != 1 {
+		t.Errorf("Merge3 of sides beyond maxEditDistance: clean %v, %d conflicts; want one conflict", res.Clean, len(res.Conflicts))
+	}
+}
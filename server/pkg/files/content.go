@@ -0,0 +1,169 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // registered for DecodeConfig and thumbnails
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrBinary is returned when a binary file is asked for as text.
+	ErrBinary = errors.New("files: file is binary")
+	// ErrTooLarge is returned when a file exceeds the limit of how it is
+	// asked for.
+	ErrTooLarge = errors.New("files: file too large")
+	// ErrNotImage is returned for thumbnails of files that are not images
+	// the server can decode.
+	ErrNotImage = errors.New("files: not a PNG, JPEG or GIF image")
+)
+
+// sniffLen is how much of a file Sniff reads.
+const sniffLen = 8 << 10
+
+// Content tells what a file holds, so that editors do not open binaries as
+// text.
+type Content struct {
+	ContentType string `json:"contentType"`
+	// Binary reports that the file is not UTF-8 text.
+	Binary bool `json:"binary"`
+	// Width and Height are the pixel size of images thumbnails can be made
+	// of.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+}
+
+// Sniff reads the start of file p to tell what it holds.
+func (f *FS) Sniff(p string) (Content, error) {
+	file, _, err := f.Open(p)
+	if err != nil {
+		return Content{}, err
+	}
+	defer file.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Content{}, err
+	}
+	c := Detect(path.Base(p), head[:n], n == sniffLen)
+	if strings.HasPrefix(c.ContentType, "image/") {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return Content{}, err
+		}
+		if cfg, _, err := image.DecodeConfig(file); err == nil {
+			c.Width, c.Height = cfg.Width, cfg.Height
+		}
+	}
+	return c, nil
+}
+
+// sqliteMagic starts every SQLite database.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// Detect tells what a file called name holds from its first bytes, head.
+// truncated reports that head is only the start of the file, so a
+// multibyte character may be cut off at its end.
+func Detect(name string, head []byte, truncated bool) Content {
+	if isText(head, truncated) {
+		ct := mime.TypeByExtension(path.Ext(name))
+		if !textType(ct) {
+			ct = "text/plain; charset=utf-8"
+		}
+		return Content{ContentType: ct}
+	}
+	ct := http.DetectContentType(head)
+	if ct == "application/octet-stream" {
+		switch {
+		case bytes.HasPrefix(head, sqliteMagic):
+			ct = "application/vnd.sqlite3"
+		default:
+			if byExt := mime.TypeByExtension(path.Ext(name)); byExt != "" {
+				ct = byExt
+			}
+		}
+	}
+	return Content{ContentType: ct, Binary: true}
+}
+
+// isText reports whether head is UTF-8 without NUL bytes, which binary
+// formats are full of and text never holds.
+func isText(head []byte, truncated bool) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	if truncated {
+		// Allow a character cut off at the end.
+		for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(head[i]) {
+				if !utf8.FullRune(head[i:]) {
+					head = head[:i]
+				}
+				break
+			}
+		}
+	}
+	return utf8.Valid(head)
+}
+
+// textType reports whether ct, as mapped from an extension, is a kind of
+// text, such as text/html, application/json or image/svg+xml.
+func textType(ct string) bool {
+	mt, _, _ := strings.Cut(ct, ";")
+	switch {
+	case mt == "":
+		return false
+	case strings.HasPrefix(mt, "text/"),
+		strings.HasSuffix(mt, "+xml"), strings.HasSuffix(mt, "+json"),
+		mt == "application/json", mt == "application/xml",
+		mt == "application/javascript", mt == "application/x-sh":
+		return true
+	}
+	return false
+}
+
+// inert reports whether content of type ct can be shown in a browser
+// from the IDE's origin without running anything in it: plain text, and
+// images other than SVG, which may hold scripts.
+func inert(ct string) bool {
+	mt, _, _ := strings.Cut(ct, ";")
+	switch {
+	case mt == "text/plain":
+		return true
+	case mt == "image/svg+xml":
+		return false
+	}
+	return strings.HasPrefix(mt, "image/")
+}
+
+// ReadText returns the contents of file p as text, failing with ErrBinary
+// for binaries and ErrTooLarge past limit bytes.
+func (f *FS) ReadText(p string, limit int64) (string, Entry, error) {
+	file, e, err := f.Open(p)
+	if err != nil {
+		return "", Entry{}, err
+	}
+	defer file.Close()
+	if e.Size > limit {
+		return "", e, fmt.Errorf("%w: %s is %d bytes, over the %d bytes opened as text", ErrTooLarge, e.Path, e.Size, limit)
+	}
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		return "", e, err
+	}
+	if int64(len(data)) > limit {
+		return "", e, fmt.Errorf("%w: %s is over the %d bytes opened as text", ErrTooLarge, e.Path, limit)
+	}
+	if !isText(data, false) {
+		return "", e, fmt.Errorf("%w: %s", ErrBinary, e.Path)
+	}
+	return string(data), e, nil
+}
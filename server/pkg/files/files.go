@@ -0,0 +1,442 @@
+// Package files implements workspace file operations confined to a root
+// directory: listing with metadata, reads, atomic writes, deletes, and
+// moves. Every client-supplied path is resolved through FS.Resolve, which
+// rejects absolute paths, ".." escapes, and symlinks pointing outside the root.
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidPath is returned for paths that are malformed or escape the root.
+	ErrInvalidPath = errors.New("files: invalid path")
+	// ErrIsDir is returned when a file operation targets a directory.
+	ErrIsDir = errors.New("files: is a directory")
+	// ErrNotDir is returned when a directory operation targets a file.
+	ErrNotDir = errors.New("files: not a directory")
+	// ErrExists is returned when a destination already exists.
+	ErrExists = errors.New("files: already exists")
+	// ErrNotEmpty is returned when removing a non-empty directory without recursion.
+	ErrNotEmpty = errors.New("files: directory not empty")
+	// ErrRoot is returned for operations that may not target the root itself.
+	ErrRoot = errors.New("files: operation not allowed on workspace root")
+)
+
+// TempPrefix marks in-flight atomic writes. Such files are hidden from
+// listings and should be ignored by anything observing the tree.
+const TempPrefix = ".webide-tmp-"
+
+// EntryType distinguishes files, directories, and symlinks.
+type EntryType string
+
+const (
+	TypeFile    EntryType = "file"
+	TypeDir     EntryType = "dir"
+	TypeSymlink EntryType = "symlink"
+)
+
+// Entry describes a file or directory within the root.
+type Entry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Type    EntryType `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ETag returns a validator for the entry's current contents.
+func (e Entry) ETag() string {
+	return fmt.Sprintf(`"%x-%x"`, e.Size, e.ModTime.UnixNano())
+}
+
+// FS is a file tree rooted at a single directory.
+type FS struct {
+	root string
+}
+
+// New returns an FS rooted at root, which must be an existing directory.
+func New(root string) (*FS, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("files: resolve root: %w", err)
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("files: resolve root: %w", err)
+	}
+	return &FS{root: real}, nil
+}
+
+// Root returns the absolute root directory.
+func (f *FS) Root() string { return f.root }
+
+// Clean validates a client path and returns it in canonical slash form
+// relative to the root; "" denotes the root itself. A leading slash is
+// accepted and ignored.
+func Clean(p string) (string, error) {
+	if strings.ContainsRune(p, 0) || strings.Contains(p, `\`) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, p)
+	}
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("%w: %q escapes the workspace", ErrInvalidPath, p)
+		}
+	}
+	c := path.Clean(p)
+	if c == "." {
+		return "", nil
+	}
+	return c, nil
+}
+
+// Resolve maps a client path to an absolute host path inside the root. The
+// deepest existing ancestor is resolved through symlinks so a link cannot be
+// used to reach files outside the workspace. A dangling link is followed to
+// where its target would be, since creating the file there would follow it.
+func (f *FS) Resolve(p string) (string, error) {
+	rel, err := Clean(p)
+	if err != nil {
+		return "", err
+	}
+	abs := filepath.Join(f.root, filepath.FromSlash(rel))
+
+	existing, rest := abs, ""
+	for hops := 0; ; {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !within(f.root, real) {
+				return "", fmt.Errorf("%w: %q resolves outside the workspace", ErrInvalidPath, p)
+			}
+			return filepath.Join(real, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if target, err := os.Readlink(existing); err == nil {
+			if hops++; hops > 255 {
+				return "", fmt.Errorf("%w: %q: too many links", ErrInvalidPath, p)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(existing), target)
+			}
+			existing = filepath.Clean(target)
+			continue
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing || !within(f.root, parent) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidPath, p)
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// ResolveLink is Resolve for operations on p itself rather than what it
+// points to: only its parent directory is resolved and confined, so a
+// symlink in the last element names the link, not its target.
+func (f *FS) ResolveLink(p string) (string, error) {
+	rel, err := Clean(p)
+	if err != nil {
+		return "", err
+	}
+	if rel == "" {
+		return f.root, nil
+	}
+	dir, err := f.Resolve(path.Dir(rel))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, path.Base(rel)), nil
+}
+
+func within(root, p string) bool {
+	if p == root {
+		return true
+	}
+	return strings.HasPrefix(p, root+string(filepath.Separator))
+}
+
+// relPath converts an absolute host path back to a slash-separated root-relative path.
+func (f *FS) relPath(abs string) string {
+	rel, err := filepath.Rel(f.root, abs)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (f *FS) entry(abs string, fi fs.FileInfo) Entry {
+	e := Entry{
+		Name:    fi.Name(),
+		Path:    f.relPath(abs),
+		Type:    TypeFile,
+		Size:    fi.Size(),
+		Mode:    fi.Mode().Perm().String(),
+		ModTime: fi.ModTime().UTC(),
+	}
+	switch {
+	case fi.IsDir():
+		e.Type, e.Size = TypeDir, 0
+	case fi.Mode()&fs.ModeSymlink != 0:
+		e.Type = TypeSymlink
+	}
+	if e.Path == "" {
+		e.Name = ""
+	}
+	return e
+}
+
+// Stat returns metadata for p. A symlink is reported as one.
+func (f *FS) Stat(p string) (Entry, error) {
+	abs, err := f.ResolveLink(p)
+	if err != nil {
+		return Entry{}, err
+	}
+	fi, err := os.Lstat(abs)
+	if err != nil {
+		return Entry{}, err
+	}
+	return f.entry(abs, fi), nil
+}
+
+// Target returns metadata for what p names once symlinks are followed:
+// the file reads and writes of p reach, whose ETag Open and Write give.
+func (f *FS) Target(p string) (Entry, error) {
+	abs, err := f.Resolve(p)
+	if err != nil {
+		return Entry{}, err
+	}
+	fi, err := os.Lstat(abs)
+	if err != nil {
+		return Entry{}, err
+	}
+	return f.entry(abs, fi), nil
+}
+
+// List returns the entries of directory p, directories first, then by name.
+func (f *FS) List(p string) ([]Entry, error) {
+	abs, err := f.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, ErrNotDir
+	}
+	des, err := os.ReadDir(abs)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(des))
+	for _, de := range des {
+		if strings.HasPrefix(de.Name(), TempPrefix) {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, f.entry(filepath.Join(abs, de.Name()), info))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if (entries[i].Type == TypeDir) != (entries[j].Type == TypeDir) {
+			return entries[i].Type == TypeDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// Open opens file p for reading and returns its metadata.
+func (f *FS) Open(p string) (*os.File, Entry, error) {
+	abs, err := f.Resolve(p)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+	file, err := os.Open(abs)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, Entry{}, err
+	}
+	if fi.IsDir() {
+		file.Close()
+		return nil, Entry{}, ErrIsDir
+	}
+	return file, f.entry(abs, fi), nil
+}
+
+// ReadFile returns the contents of file p.
+func (f *FS) ReadFile(p string) ([]byte, error) {
+	file, _, err := f.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Write atomically replaces file p with the contents of r, creating parent
+// directories as needed. Readers see either the old or the new contents,
+// never a partial write. An existing file keeps its permissions.
+func (f *FS) Write(p string, r io.Reader) (Entry, error) {
+	abs, err := f.Resolve(p)
+	if err != nil {
+		return Entry{}, err
+	}
+	if abs == f.root {
+		return Entry{}, ErrRoot
+	}
+	mode := fs.FileMode(0o644)
+	if fi, err := os.Stat(abs); err == nil {
+		if fi.IsDir() {
+			return Entry{}, ErrIsDir
+		}
+		mode = fi.Mode().Perm()
+	}
+	dir := filepath.Dir(abs)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Entry{}, err
+	}
+
+	tmp, err := os.CreateTemp(dir, TempPrefix+"*")
+	if err != nil {
+		return Entry{}, err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return Entry{}, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return Entry{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Rename(tmpName, abs); err != nil {
+		return Entry{}, err
+	}
+	fi, err := os.Lstat(abs)
+	if err != nil {
+		return Entry{}, err
+	}
+	return f.entry(abs, fi), nil
+}
+
+// WriteFile is Write for in-memory contents.
+func (f *FS) WriteFile(p string, data []byte) (Entry, error) {
+	return f.Write(p, strings.NewReader(string(data)))
+}
+
+// Mkdir creates directory p and any missing parents.
+func (f *FS) Mkdir(p string) (Entry, error) {
+	abs, err := f.Resolve(p)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return Entry{}, err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return Entry{}, err
+	}
+	return f.entry(abs, fi), nil
+}
+
+// Remove deletes p. Non-empty directories require recursive. A symlink
+// is removed itself, never what it points to.
+func (f *FS) Remove(p string, recursive bool) error {
+	abs, err := f.ResolveLink(p)
+	if err != nil {
+		return err
+	}
+	if abs == f.root {
+		return ErrRoot
+	}
+	fi, err := os.Lstat(abs)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() && recursive {
+		return os.RemoveAll(abs)
+	}
+	if err := os.Remove(abs); err != nil {
+		if fi.IsDir() {
+			return ErrNotEmpty
+		}
+		return err
+	}
+	return nil
+}
+
+// Move renames from to to. An existing destination is replaced only when
+// overwrite is set, and a directory is never moved into itself. Symlinks
+// are moved and replaced themselves, not their targets.
+func (f *FS) Move(from, to string, overwrite bool) (Entry, error) {
+	src, err := f.ResolveLink(from)
+	if err != nil {
+		return Entry{}, err
+	}
+	dst, err := f.ResolveLink(to)
+	if err != nil {
+		return Entry{}, err
+	}
+	if src == f.root || dst == f.root {
+		return Entry{}, ErrRoot
+	}
+	if _, err := os.Lstat(src); err != nil {
+		return Entry{}, err
+	}
+	if within(src, dst) && src != dst {
+		return Entry{}, fmt.Errorf("%w: cannot move a directory into itself", ErrInvalidPath)
+	}
+	if fi, err := os.Lstat(dst); err == nil {
+		if !overwrite {
+			return Entry{}, ErrExists
+		}
+		if fi.IsDir() {
+			if err := os.RemoveAll(dst); err != nil {
+				return Entry{}, err
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return Entry{}, err
+	}
+	fi, err := os.Lstat(dst)
+	if err != nil {
+		return Entry{}, err
+	}
+	return f.entry(dst, fi), nil
+}
@@ -0,0 +1,218 @@
+package files
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// linkTree returns an FS whose root holds dir/a.txt, a link "link" to dir,
+// a link "out" to a directory outside the root and a dangling link
+// "dangling" to a file to be in that directory.
+func linkTree(t *testing.T) (f *FS, outside string) {
+	t.Helper()
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "dir", "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{
+		"link":     "dir",
+		"out":      outside,
+		"dangling": filepath.Join(outside, "new.txt"),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, outside
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", "", true},
+		{"/", "", true},
+		{".", "", true},
+		{"a/b", "a/b", true},
+		{"/a//b/./c/", "a/b/c", true},
+		{"..", "", false},
+		{"a/../../b", "", false},
+		{`a\b`, "", false},
+		{"a\x00b", "", false},
+	}
+	for _, tt := range tests {
+		got, err := Clean(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Clean(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	f, _ := linkTree(t)
+	tests := []struct {
+		path string
+		want string // relative to the root; "!" for ErrInvalidPath
+	}{
+		{"dir/a.txt", "dir/a.txt"},
+		{"link/a.txt", "dir/a.txt"},
+		{"link/new/b.txt", "dir/new/b.txt"},
+		{"out", "!"},
+		{"out/secret.txt", "!"},
+		{"dangling", "!"},
+		{"../x", "!"},
+	}
+	for _, tt := range tests {
+		got, err := f.Resolve(tt.path)
+		if tt.want == "!" {
+			if !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("Resolve(%q) = %q, %v; want ErrInvalidPath", tt.path, got, err)
+			}
+			continue
+		}
+		if want := filepath.Join(f.Root(), filepath.FromSlash(tt.want)); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tt.path, got, err, want)
+		}
+	}
+
+	// Links chasing each other are cut off.
+	if err := os.Symlink("loop2", filepath.Join(f.Root(), "loop1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("loop1", filepath.Join(f.Root(), "loop2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Resolve("loop1"); err == nil {
+		t.Error("Resolve of a link loop succeeded")
+	}
+}
+
+func TestResolveLink(t *testing.T) {
+	f, _ := linkTree(t)
+	for _, p := range []string{"link", "out", "dangling"} {
+		if got, err := f.ResolveLink(p); err != nil || got != filepath.Join(f.Root(), p) {
+			t.Errorf("ResolveLink(%q) = %q, %v; want the link itself", p, got, err)
+		}
+	}
+	if got, err := f.ResolveLink("link/a.txt"); err != nil || got != filepath.Join(f.Root(), "dir", "a.txt") {
+		t.Errorf("ResolveLink(link/a.txt) = %q, %v", got, err)
+	}
+	if _, err := f.ResolveLink("out/secret.txt"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("ResolveLink(out/secret.txt) = %v, want ErrInvalidPath", err)
+	}
+	if got, err := f.ResolveLink("/"); err != nil || got != f.Root() {
+		t.Errorf("ResolveLink(/) = %q, %v", got, err)
+	}
+}
+
+func TestStatLink(t *testing.T) {
+	f, _ := linkTree(t)
+	e, err := f.Stat("link")
+	if err != nil || e.Type != TypeSymlink || e.Path != "link" {
+		t.Errorf("Stat(link) = %+v, %v; want a symlink", e, err)
+	}
+	if e, err := f.Target("link"); err != nil || e.Type != TypeDir || e.Path != "dir" {
+		t.Errorf("Target(link) = %+v, %v; want dir", e, err)
+	}
+	if e, err := f.Stat("dangling"); err != nil || e.Type != TypeSymlink {
+		t.Errorf("Stat(dangling) = %+v, %v; want a symlink", e, err)
+	}
+	entries, err := f.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]EntryType{}
+	for _, e := range entries {
+		types[e.Name] = e.Type
+	}
+	if types["dir"] != TypeDir || types["link"] != TypeSymlink || types["out"] != TypeSymlink {
+		t.Errorf("List = %v", types)
+	}
+}
+
+func TestRemoveLink(t *testing.T) {
+	for _, recursive := range []bool{false, true} {
+		f, outside := linkTree(t)
+		if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("s"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{"link", "out"} {
+			if err := f.Remove(p, recursive); err != nil {
+				t.Fatalf("Remove(%q, %v): %v", p, recursive, err)
+			}
+			if _, err := os.Lstat(filepath.Join(f.Root(), p)); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Remove(%q, %v) left the link: %v", p, recursive, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(f.Root(), "dir", "a.txt")); err != nil {
+			t.Errorf("Remove(link, %v) removed its target: %v", recursive, err)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil {
+			t.Errorf("Remove(out, %v) removed its target: %v", recursive, err)
+		}
+	}
+}
+
+func TestMoveLink(t *testing.T) {
+	f, _ := linkTree(t)
+	e, err := f.Move("link", "moved", false)
+	if err != nil || e.Type != TypeSymlink || e.Path != "moved" {
+		t.Fatalf("Move(link, moved) = %+v, %v", e, err)
+	}
+	if target, err := os.Readlink(filepath.Join(f.Root(), "moved")); err != nil || target != "dir" {
+		t.Errorf("moved link points to %q, %v", target, err)
+	}
+	if _, err := os.Stat(filepath.Join(f.Root(), "dir", "a.txt")); err != nil {
+		t.Errorf("Move of a link moved its target: %v", err)
+	}
+
+	// Overwriting a link replaces the link, not what it points to.
+	if err := os.WriteFile(filepath.Join(f.Root(), "b.txt"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Move("b.txt", "moved", true); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(filepath.Join(f.Root(), "moved")); err != nil || !fi.Mode().IsRegular() {
+		t.Errorf("overwritten link: %v, %v", fi, err)
+	}
+	if _, err := os.Stat(filepath.Join(f.Root(), "dir", "a.txt")); err != nil {
+		t.Errorf("overwriting a link removed its target: %v", err)
+	}
+
+	if _, err := f.Move("dir", "dir/sub", false); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Move of a directory into itself = %v, want ErrInvalidPath", err)
+	}
+	if _, err := f.Move("dir/a.txt", "out/a.txt", false); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Move out of the workspace = %v, want ErrInvalidPath", err)
+	}
+}
+
+func TestWriteThroughLink(t *testing.T) {
+	f, outside := linkTree(t)
+	if _, err := f.WriteFile("link/b.txt", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(f.Root(), "dir", "b.txt")); string(data) != "b" {
+		t.Errorf("dir/b.txt = %q, %v", data, err)
+	}
+	for _, p := range []string{"dangling", "out/x.txt"} {
+		if _, err := f.WriteFile(p, []byte("x")); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("WriteFile(%q) = %v, want ErrInvalidPath", p, err)
+		}
+	}
+	if ents, _ := os.ReadDir(outside); len(ents) != 0 {
+		t.Errorf("wrote outside the workspace: %v", ents)
+	}
+}
@@ -0,0 +1,483 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/audit"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/events"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/internal/textnorm"
+)
+
+// DefaultMaxUpload caps the body of a single PUT.
+const DefaultMaxUpload = 32 << 20
+
+// DefaultMaxText caps files read with ?encoding=text, which editors open,
+// and DefaultMaxBase64 those read with ?encoding=base64. Larger files are
+// only served raw.
+const (
+	DefaultMaxText   = 8 << 20
+	DefaultMaxBase64 = 16 << 20
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// History records the contents of files saved through the API.
+type History interface {
+	// Records reports whether a file of size bytes is recorded at all.
+	Records(size int64) bool
+	// Saved is called with the contents of a file after it is written.
+	Saved(ctx context.Context, workspaceID, path string, data []byte)
+}
+
+// Trash keeps deleted files so that they can be restored.
+type Trash interface {
+	// Discard deletes p from the workspace of f, keeping it in the trash
+	// unless permanent is set or it does not fit. It returns the ID of the
+	// trash item, or "" when p was deleted outright.
+	Discard(ctx context.Context, workspaceID string, f *FS, p string, recursive, permanent bool) (string, error)
+}
+
+// Normalizer rewrites text before it is saved, as textnorm.Service does.
+type Normalizer interface {
+	Normalize(workspaceID, name string, data []byte) ([]byte, *textnorm.Report)
+}
+
+// Handler serves the workspace file API.
+type Handler struct {
+	workspaces Workspaces
+	history    History
+	trash      Trash
+	normalizer Normalizer
+	maxUpload  int64
+	maxText    int64
+	maxBase64  int64
+}
+
+// NewHandler returns a Handler for the workspaces resolved by ws. Saves
+// are recorded in history unless it is nil, deletes go to trash unless it
+// is nil, and text saves are normalized unless normalizer is nil.
+func NewHandler(ws Workspaces, history History, trash Trash, normalizer Normalizer) *Handler {
+	return &Handler{workspaces: ws, history: history, trash: trash, normalizer: normalizer,
+		maxUpload: DefaultMaxUpload, maxText: DefaultMaxText, maxBase64: DefaultMaxBase64}
+}
+
+// Register mounts the file routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/files", h.get)
+	mux.HandleFunc("GET /api/workspaces/{id}/files/{path...}", h.get)
+	mux.HandleFunc("PUT /api/workspaces/{id}/files/{path...}", h.put)
+	mux.HandleFunc("DELETE /api/workspaces/{id}/files/{path...}", h.delete)
+	mux.HandleFunc("POST /api/workspaces/{id}/move", h.move)
+}
+
+// fsFor returns the FS of the workspace named in the request path.
+func (h *Handler) fsFor(w http.ResponseWriter, r *http.Request) (*FS, bool) {
+	dir, err := h.workspaces.Open(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return nil, false
+	}
+	f, err := New(dir)
+	if err != nil {
+		slog.Error("open workspace root", "workspace", r.PathValue("id"), "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "workspace unavailable")
+		return nil, false
+	}
+	return f, true
+}
+
+type listing struct {
+	Path    string  `json:"path"`
+	Entries []Entry `json:"entries"`
+}
+
+// meta is the metadata of a file, with what it holds.
+type meta struct {
+	Entry
+	Content
+}
+
+// fileContent is a file's contents as JSON, for ?encoding=text or base64.
+type fileContent struct {
+	Entry
+	Content
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"`
+}
+
+// get lists a directory, or serves a file's bytes. With ?meta=1 it returns
+// the entry's metadata instead of its contents, including for files their
+// content type and whether they are binary. Files can also be read as JSON
+// with ?encoding=text, which refuses binaries, or ?encoding=base64, and
+// images as PNG thumbnails with ?thumbnail=<size>. Raw files are only
+// shown inline when they are plain text or inert images; the rest are
+// served as attachments.
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.fsFor(w, r)
+	if !ok {
+		return
+	}
+	p := r.PathValue("path")
+	e, err := f.Stat(p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	q := r.URL.Query()
+	// A link is described as itself but read as what it points to.
+	target := e
+	if e.Type == TypeSymlink {
+		if target, err = f.Target(p); err != nil && q.Get("meta") != "1" {
+			writeError(w, err)
+			return
+		}
+	}
+	if q.Get("meta") == "1" {
+		if target.Type != TypeFile {
+			httpx.JSON(w, http.StatusOK, e)
+			return
+		}
+		c, err := f.Sniff(p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpx.JSON(w, http.StatusOK, meta{Entry: e, Content: c})
+		return
+	}
+	if target.Type == TypeDir {
+		entries, err := f.List(p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpx.JSON(w, http.StatusOK, listing{Path: e.Path, Entries: entries})
+		return
+	}
+
+	switch enc := q.Get("encoding"); {
+	case q.Has("thumbnail"):
+		h.thumbnail(w, r, f, p)
+		return
+	case enc == "text" || enc == "base64":
+		h.content(w, f, p, enc)
+		return
+	case enc != "":
+		httpx.Error(w, http.StatusBadRequest, "encoding must be text or base64")
+		return
+	}
+
+	file, e, err := f.Open(p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer file.Close()
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(file, head)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		writeError(w, err)
+		return
+	}
+	ct := Detect(e.Name, head[:n], n == sniffLen).ContentType
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Any editor can put HTML or SVG in a workspace, and opened from the
+	// IDE's origin it would run with the viewer's session. Such files are
+	// downloaded instead, and the sandbox keeps scripts from running
+	// should a browser render one anyway.
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	if !inert(ct) {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.Name}))
+	}
+	w.Header().Set("ETag", e.ETag())
+	http.ServeContent(w, r, e.Name, e.ModTime, file)
+}
+
+// content answers with the file at p as JSON, in UTF-8 text or base64.
+func (h *Handler) content(w http.ResponseWriter, f *FS, p, encoding string) {
+	var res fileContent
+	if encoding == "text" {
+		text, e, err := f.ReadText(p, h.maxText)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		head := text[:min(len(text), sniffLen)]
+		res = fileContent{Entry: e, Content: Detect(e.Name, []byte(head), len(head) < len(text)), Encoding: encoding, Data: text}
+	} else {
+		file, e, err := f.Open(p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer file.Close()
+		if e.Size > h.maxBase64 {
+			writeError(w, fmt.Errorf("%w: files over %d bytes are only served raw", ErrTooLarge, h.maxBase64))
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(file, h.maxBase64+1))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if int64(len(data)) > h.maxBase64 {
+			writeError(w, fmt.Errorf("%w: files over %d bytes are only served raw", ErrTooLarge, h.maxBase64))
+			return
+		}
+		head := data[:min(len(data), sniffLen)]
+		res = fileContent{Entry: e, Content: Detect(e.Name, head, len(head) < len(data)), Encoding: encoding, Data: base64.StdEncoding.EncodeToString(data)}
+	}
+	w.Header().Set("ETag", res.ETag())
+	httpx.JSON(w, http.StatusOK, res)
+}
+
+// thumbnail answers with a PNG of the image at p, ?thumbnail= pixels on
+// its longer side.
+func (h *Handler) thumbnail(w http.ResponseWriter, r *http.Request, f *FS, p string) {
+	size := 128
+	if v := r.URL.Query().Get("thumbnail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < MinThumbnailSize || n > MaxThumbnailSize {
+			httpx.Errorf(w, http.StatusBadRequest, "thumbnail must be a size of %d to %d pixels", MinThumbnailSize, MaxThumbnailSize)
+			return
+		}
+		size = n
+	}
+	e, err := f.Target(p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	etag := fmt.Sprintf(`"%x-%x-t%d"`, e.Size, e.ModTime.UnixNano(), size)
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var buf bytes.Buffer
+	if err := f.Thumbnail(&buf, p, size); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("ETag", etag)
+	w.Write(buf.Bytes())
+}
+
+// put writes a file atomically from the request body, or creates a directory
+// with ?type=dir. If-Match and If-None-Match: * guard against lost updates.
+// Text of at most maxText bytes sent as it is, rather than in base64, is
+// normalized with the workspace's policy unless ?normalize=0; larger bodies
+// are written as they come. The answer then tells what
+// changed and which invisible characters the file has.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.fsFor(w, r)
+	if !ok {
+		return
+	}
+	p := r.PathValue("path")
+	if r.URL.Query().Get("type") == "dir" {
+		e, err := f.Mkdir(p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpx.JSON(w, http.StatusCreated, e)
+		return
+	}
+
+	cur, err := f.Target(p)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		writeError(w, err)
+		return
+	}
+	if m := r.Header.Get("If-Match"); m != "" && (!exists || m != cur.ETag()) {
+		httpx.Error(w, http.StatusPreconditionFailed, "file changed since it was read")
+		return
+	}
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		httpx.Error(w, http.StatusPreconditionFailed, "file already exists")
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, h.maxUpload)
+	var report *textnorm.Report
+	switch r.URL.Query().Get("encoding") {
+	case "":
+		if h.normalizer == nil || r.URL.Query().Get("normalize") == "0" {
+			break
+		}
+		data, err := io.ReadAll(io.LimitReader(body, h.maxText+1))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if int64(len(data)) > h.maxText {
+			body = io.MultiReader(bytes.NewReader(data), body)
+			break
+		}
+		data, report = h.normalizer.Normalize(r.PathValue("id"), p, data)
+		body = bytes.NewReader(data)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	default:
+		httpx.Error(w, http.StatusBadRequest, "encoding must be base64 or left out")
+		return
+	}
+	e, err := f.Write(p, body)
+	var corrupt base64.CorruptInputError
+	if errors.As(err, &corrupt) {
+		httpx.Error(w, http.StatusBadRequest, "request body is not valid base64")
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if h.history != nil && h.history.Records(e.Size) {
+		if data, err := f.ReadFile(e.Path); err == nil {
+			h.history.Saved(r.Context(), r.PathValue("id"), e.Path, data)
+		}
+	}
+	events.Publish(r.Context(), events.Event{Type: events.FileSaved, Workspace: r.PathValue("id"), Data: map[string]any{
+		"path":    e.Path,
+		"size":    e.Size,
+		"created": !exists,
+	}})
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+	}
+	w.Header().Set("ETag", e.ETag())
+	if report != nil && !report.Empty() {
+		httpx.JSON(w, status, saved{Entry: e, Normalized: report})
+		return
+	}
+	httpx.JSON(w, status, e)
+}
+
+// saved is a file written through the API, with what normalizing its text
+// changed and the invisible characters left in it.
+type saved struct {
+	Entry
+	Normalized *textnorm.Report `json:"normalized,omitempty"`
+}
+
+// deleted tells where a delete went.
+type deleted struct {
+	Path string `json:"path"`
+	// Trash is the ID of the trash item to restore it from.
+	Trash string `json:"trash"`
+}
+
+// delete removes a file, or a directory tree with ?recursive=true. With a
+// trash it answers with the trash item it went to, unless it was deleted
+// outright with ?permanent=true or for being too large for the trash.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.fsFor(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	recursive, _ := strconv.ParseBool(q.Get("recursive"))
+	permanent, _ := strconv.ParseBool(q.Get("permanent"))
+	var trashID string
+	var err error
+	if h.trash != nil {
+		trashID, err = h.trash.Discard(r.Context(), r.PathValue("id"), f, r.PathValue("path"), recursive, permanent)
+	} else {
+		err = f.Remove(r.PathValue("path"), recursive)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	details := map[string]string{"recursive": strconv.FormatBool(recursive)}
+	if trashID != "" {
+		details["trash"] = trashID
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Action:    audit.ActionFileDelete,
+		Workspace: r.PathValue("id"),
+		Target:    r.PathValue("path"),
+		Details:   details,
+	})
+	if trashID == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, deleted{Path: r.PathValue("path"), Trash: trashID})
+}
+
+type moveRequest struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// move renames or moves a file or directory within the workspace.
+func (h *Handler) move(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.fsFor(w, r)
+	if !ok {
+		return
+	}
+	var req moveRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	e, err := f.Move(req.From, req.To, req.Overwrite)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Overwrite {
+		// Overwriting deletes what was at the destination.
+		audit.Record(r.Context(), audit.Entry{
+			Action:    audit.ActionFileMove,
+			Workspace: r.PathValue("id"),
+			Target:    req.To,
+			Details:   map[string]string{"from": req.From},
+		})
+	}
+	httpx.JSON(w, http.StatusOK, e)
+}
+
+// writeError maps file system errors onto HTTP responses.
+func writeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrInvalidPath), errors.Is(err, ErrRoot):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		httpx.Error(w, http.StatusNotFound, "no such file or directory")
+	case errors.Is(err, ErrExists), errors.Is(err, fs.ErrExist),
+		errors.Is(err, ErrIsDir), errors.Is(err, ErrNotDir), errors.Is(err, ErrNotEmpty):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.As(err, &tooLarge):
+		httpx.Errorf(w, http.StatusRequestEntityTooLarge, "file exceeds %d bytes", tooLarge.Limit)
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrBinary), errors.Is(err, ErrNotImage):
+		httpx.Error(w, http.StatusUnsupportedMediaType, err.Error())
+	default:
+		slog.Error("file operation failed", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "file operation failed")
+	}
+}
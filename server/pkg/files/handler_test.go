@@ -0,0 +1,207 @@
+package files
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/textnorm"
+)
+
+// fakeWorkspaces maps workspace IDs to their directories.
+type fakeWorkspaces map[string]string
+
+func (w fakeWorkspaces) Open(id string) (string, error) {
+	dir, ok := w[id]
+	if !ok {
+		return "", errors.New("no such workspace")
+	}
+	return dir, nil
+}
+
+// testHandler returns the routes of h for workspace ws1, which holds
+// files, a map of slash-separated paths to contents.
+func testHandler(t *testing.T, h *Handler, files map[string]string) (*http.ServeMux, string) {
+	t.Helper()
+	root := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.workspaces = fakeWorkspaces{"ws1": root}
+	mux := http.NewServeMux()
+	h.Register(mux)
+	return mux, root
+}
+
+func do(mux http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestGetRawActiveContent(t *testing.T) {
+	mux, _ := testHandler(t, NewHandler(nil, nil, nil, nil), map[string]string{
+		"page.html":  "<script>alert(document.cookie)</script>",
+		"logo.svg":   `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`,
+		"app.js":     "alert(1)",
+		"notes.txt":  "hello",
+		"pixel.png":  "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"data.json":  `{"a": 1}`,
+		"style.css":  "body{}",
+		"report.pdf": "%PDF-1.4\n\x00",
+	})
+	tests := []struct {
+		path       string
+		attachment bool
+	}{
+		{"page.html", true},
+		{"logo.svg", true},
+		{"app.js", true},
+		{"data.json", true},
+		{"style.css", true},
+		{"report.pdf", true},
+		{"notes.txt", false},
+		{"pixel.png", false},
+	}
+	for _, tt := range tests {
+		rec := do(mux, "GET", "/api/workspaces/ws1/files/"+tt.path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d", tt.path, rec.Code)
+		}
+		hdr := rec.Header()
+		if hdr.Get("Content-Security-Policy") != "sandbox" || hdr.Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("GET %s: Content-Security-Policy %q, X-Content-Type-Options %q", tt.path, hdr.Get("Content-Security-Policy"), hdr.Get("X-Content-Type-Options"))
+		}
+		cd := hdr.Get("Content-Disposition")
+		if got := strings.HasPrefix(cd, "attachment"); got != tt.attachment {
+			t.Errorf("GET %s (%s): Content-Disposition %q, want attachment %v", tt.path, hdr.Get("Content-Type"), cd, tt.attachment)
+		}
+		if tt.attachment && !strings.Contains(cd, tt.path) {
+			t.Errorf("GET %s: Content-Disposition %q does not name the file", tt.path, cd)
+		}
+	}
+}
+
+func TestInert(t *testing.T) {
+	tests := []struct {
+		ct   string
+		want bool
+	}{
+		{"text/plain; charset=utf-8", true},
+		{"image/png", true},
+		{"image/webp", true},
+		{"image/svg+xml", false},
+		{"text/html; charset=utf-8", false},
+		{"text/javascript; charset=utf-8", false},
+		{"application/javascript", false},
+		{"application/xhtml+xml", false},
+		{"application/pdf", false},
+		{"application/octet-stream", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := inert(tt.ct); got != tt.want {
+			t.Errorf("inert(%q) = %v, want %v", tt.ct, got, tt.want)
+		}
+	}
+}
+
+func TestLinkRoutes(t *testing.T) {
+	mux, root := testHandler(t, NewHandler(nil, nil, nil, nil), map[string]string{"dir/a.txt": "a"})
+	if err := os.Symlink("dir", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/a.txt", filepath.Join(root, "a-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A link is described as a link, and read as what it points to.
+	rec := do(mux, "GET", "/api/workspaces/ws1/files/link?meta=1", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"type":"symlink"`) {
+		t.Errorf("GET link?meta=1 = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(mux, "GET", "/api/workspaces/ws1/files/link", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"a.txt"`) {
+		t.Errorf("GET link = %d %s, want the listing of dir", rec.Code, rec.Body)
+	}
+	rec = do(mux, "GET", "/api/workspaces/ws1/files/a-link", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "a" {
+		t.Errorf("GET a-link = %d %q", rec.Code, rec.Body)
+	}
+	// Saving through a link with the ETag read through it works.
+	req := httptest.NewRequest("PUT", "/api/workspaces/ws1/files/a-link", strings.NewReader("b"))
+	req.Header.Set("If-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("PUT a-link with If-Match = %d %s", rec.Code, rec.Body)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "dir", "a.txt")); string(data) != "b" {
+		t.Errorf("dir/a.txt = %q after saving through a link", data)
+	}
+
+	// Deleting and moving a link leave the directory alone.
+	if rec := do(mux, "DELETE", "/api/workspaces/ws1/files/link?recursive=true", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE link = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(mux, "POST", "/api/workspaces/ws1/move", `{"from":"a-link","to":"b-link"}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"type":"symlink"`) {
+		t.Errorf("move a-link = %d %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "link")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("link still there: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "dir", "a.txt")); err != nil || string(data) != "b" {
+		t.Errorf("dir/a.txt = %q, %v after deleting and moving links to it", data, err)
+	}
+}
+
+// lfNormalizer converts line endings to LF.
+type lfNormalizer struct{}
+
+func (lfNormalizer) Normalize(workspaceID, name string, data []byte) ([]byte, *textnorm.Report) {
+	text, rep := textnorm.Policy{EOL: textnorm.EOLLF}.Normalize(name, string(data))
+	return []byte(text), rep
+}
+
+func TestPutNormalize(t *testing.T) {
+	h := NewHandler(nil, nil, nil, lfNormalizer{})
+	h.maxText = 16
+	mux, root := testHandler(t, h, nil)
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		want   string
+		report bool
+	}{
+		{"normalized", "a.txt", "a\r\nb\r\n", "a\nb\n", true},
+		{"unchanged", "b.txt", "a\nb\n", "a\nb\n", false},
+		{"normalize=0", "c.txt?normalize=0", "a\r\nb\r\n", "a\r\nb\r\n", false},
+		{"at the text limit", "d.txt", strings.Repeat("a\r\n", 5) + "a", strings.Repeat("a\n", 5) + "a", true},
+		{"over the text limit", "e.txt", strings.Repeat("a\r\n", 6), strings.Repeat("a\r\n", 6), false},
+		{"base64", "f.txt?encoding=base64", "YQ0KYg0K", "a\r\nb\r\n", false},
+	}
+	for _, tt := range tests {
+		rec := do(mux, "PUT", "/api/workspaces/ws1/files/"+tt.target, tt.body)
+		if rec.Code != http.StatusCreated {
+			t.Errorf("%s: PUT = %d %s", tt.name, rec.Code, rec.Body)
+			continue
+		}
+		if got := strings.Contains(rec.Body.String(), `"normalized"`); got != tt.report {
+			t.Errorf("%s: PUT answered %s, want a report %v", tt.name, rec.Body, tt.report)
+		}
+		name, _, _ := strings.Cut(tt.target, "?")
+		if data, err := os.ReadFile(filepath.Join(root, name)); err != nil || string(data) != tt.want {
+			t.Errorf("%s: saved %q, %v; want %q", tt.name, data, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Thumbnail limits. Images are decoded whole, so the pixel cap keeps a
+// small file that decompresses to a huge image from exhausting memory.
+const (
+	MinThumbnailSize   = 16
+	MaxThumbnailSize   = 512
+	MaxThumbnailSource = 32 << 20
+	MaxThumbnailPixels = 40 << 20
+)
+
+// Thumbnail writes a PNG of image file p scaled down to fit in size by
+// size pixels, with size clamped to MinThumbnailSize and
+// MaxThumbnailSize; smaller images keep their size.
+func (f *FS) Thumbnail(w io.Writer, p string, size int) error {
+	size = min(max(size, MinThumbnailSize), MaxThumbnailSize)
+	file, e, err := f.Open(p)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if e.Size > MaxThumbnailSource {
+		return fmt.Errorf("%w: thumbnails are made of images up to %d bytes", ErrTooLarge, MaxThumbnailSource)
+	}
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return ErrNotImage
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxThumbnailPixels {
+		return fmt.Errorf("%w: thumbnails are made of images up to %d pixels", ErrTooLarge, MaxThumbnailPixels)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return ErrNotImage
+		}
+		return fmt.Errorf("%w: %v", ErrNotImage, err)
+	}
+	return png.Encode(w, scale(src, size))
+}
+
+// scale shrinks src to fit in size by size, averaging the source pixels
+// each thumbnail pixel covers.
+func scale(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw <= size && sh <= size {
+		return src
+	}
+	dw, dh := size, size
+	if sw > sh {
+		dh = max(sh*size/sw, 1)
+	} else {
+		dw = max(sw*size/sh, 1)
+	}
+	at := func(x, y int) (r, g, b, a uint32) { return src.At(x, y).RGBA() }
+	if fast, ok := src.(image.RGBA64Image); ok {
+		at = func(x, y int) (r, g, b, a uint32) {
+			c := fast.RGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := b.Min.Y+dy*sh/dh, b.Min.Y+(dy+1)*sh/dh
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := b.Min.X+dx*sw/dw, b.Min.X+(dx+1)*sw/dw
+			var r, g, bl, a, n uint64
+			for y := y0; y < max(y1, y0+1); y++ {
+				for x := x0; x < max(x1, x0+1); x++ {
+					cr, cg, cb, ca := at(x, y)
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// The channels are premultiplied, so averaging them blends
+			// transparent pixels correctly.
+			dst.SetRGBA64(dx, dy, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
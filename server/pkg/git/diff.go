@@ -0,0 +1,319 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diff"
+)
+
+// emptyTree is the hash of the empty tree, which stands in for HEAD before
+// the first commit.
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// maxUntrackedDiff is the largest untracked file included in a working
+// tree diff; bigger ones are reported without hunks.
+const maxUntrackedDiff = 1 << 20
+
+// FileDiff is the difference for one path.
+type FileDiff struct {
+	Path    string      `json:"path"`
+	OldPath string      `json:"oldPath,omitempty"`
+	Status  Change      `json:"status"`
+	Binary  bool        `json:"binary,omitempty"`
+	Hunks   []diff.Hunk `json:"hunks"`
+}
+
+// DiffOptions selects what Diff compares. With neither To nor Staged it
+// compares the working tree, untracked files included, against From.
+type DiffOptions struct {
+	// From is the old side; defaults to HEAD.
+	From string
+	// To is the new side; empty means the working tree or, with Staged,
+	// the index.
+	To     string
+	Staged bool
+	// Paths limits the diff to these files or directories.
+	Paths []string
+	// Context is the number of unchanged lines around each change;
+	// defaults to 3.
+	Context int
+}
+
+// Diff returns per-file hunks between two states of the repository.
+func (r *Repo) Diff(ctx context.Context, opts DiffOptions) ([]FileDiff, error) {
+	if opts.From == "" {
+		opts.From = "HEAD"
+	}
+	for _, rev := range []string{opts.From, opts.To} {
+		if rev != "" && !validRev(rev) {
+			return nil, fmt.Errorf("%w: revision %q", ErrInvalidArg, rev)
+		}
+	}
+	if opts.To != "" && opts.Staged {
+		return nil, fmt.Errorf("%w: staged diffs compare against the index, not a revision", ErrInvalidArg)
+	}
+	if err := checkPaths(opts.Paths); err != nil {
+		return nil, err
+	}
+	if opts.Context <= 0 {
+		opts.Context = 3
+	}
+	if opts.From == "HEAD" {
+		hasHead, err := r.hasHead(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !hasHead {
+			opts.From = emptyTree
+		}
+	}
+
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--no-textconv", "--find-renames",
+		"--unified=" + strconv.Itoa(opts.Context)}
+	if opts.Staged {
+		args = append(args, "--cached")
+	}
+	args = append(args, "--end-of-options", opts.From)
+	if opts.To != "" {
+		args = append(args, opts.To)
+	}
+	args = append(append(args, "--"), opts.Paths...)
+	out, err := r.run(ctx, nil, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	files, err := parseDiff(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.To == "" && !opts.Staged {
+		untracked, err := r.untrackedDiffs(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, untracked...)
+	}
+	return files, nil
+}
+
+// untrackedDiffs reports untracked files as additions, which git diff omits.
+func (r *Repo) untrackedDiffs(ctx context.Context, opts DiffOptions) ([]FileDiff, error) {
+	args := append([]string{"ls-files", "-z", "--others", "--exclude-standard", "--"}, opts.Paths...)
+	out, err := r.run(ctx, nil, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	var files []FileDiff
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p == "" {
+			continue
+		}
+		fd := FileDiff{Path: p, Status: Added, Hunks: []diff.Hunk{}}
+		data, err := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(p)))
+		switch {
+		case err != nil:
+			continue
+		case len(data) > maxUntrackedDiff || bytes.IndexByte(data, 0) >= 0:
+			fd.Binary = true
+		default:
+			fd.Hunks = diff.Lines("", string(data), opts.Context)
+		}
+		files = append(files, fd)
+	}
+	return files, nil
+}
+
+// parseDiff reads git's unified diff output.
+func parseDiff(out []byte) ([]FileDiff, error) {
+	files := []FileDiff{}
+	var cur *FileDiff
+	var hunk *diff.Hunk
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if hunk != nil {
+			cur.Hunks = append(cur.Hunks, *hunk)
+			hunk = nil
+		}
+		files = append(files, *cur)
+		cur = nil
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), 64<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			cur = &FileDiff{Status: Modified, Hunks: []diff.Hunk{}}
+			cur.OldPath, cur.Path = splitGitHeader(strings.TrimPrefix(line, "diff --git "))
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if hunk != nil {
+			switch {
+			case line == "" || line[0] == ' ':
+				hunk.Lines = append(hunk.Lines, diff.Line{Op: diff.OpContext, Text: strings.TrimPrefix(line, " ")})
+				continue
+			case line[0] == '+':
+				hunk.Lines = append(hunk.Lines, diff.Line{Op: diff.OpInsert, Text: line[1:]})
+				continue
+			case line[0] == '-':
+				hunk.Lines = append(hunk.Lines, diff.Line{Op: diff.OpDelete, Text: line[1:]})
+				continue
+			case line[0] == '\\':
+				continue // "\ No newline at end of file"
+			}
+		}
+		switch {
+		case strings.HasPrefix(line, "@@ "):
+			if hunk != nil {
+				cur.Hunks = append(cur.Hunks, *hunk)
+			}
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			hunk = &h
+		case strings.HasPrefix(line, "new file mode"):
+			cur.Status = Added
+		case strings.HasPrefix(line, "deleted file mode"):
+			cur.Status = Deleted
+		case strings.HasPrefix(line, "rename from "):
+			cur.Status = Renamed
+			cur.OldPath = unquote(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			cur.Path = unquote(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "copy from "):
+			cur.Status = Copied
+			cur.OldPath = unquote(strings.TrimPrefix(line, "copy from "))
+		case strings.HasPrefix(line, "copy to "):
+			cur.Path = unquote(strings.TrimPrefix(line, "copy to "))
+		case strings.HasPrefix(line, "--- "):
+			if p := strings.TrimPrefix(line, "--- "); p != "/dev/null" {
+				cur.OldPath = strings.TrimPrefix(unquote(p), "a/")
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if p := strings.TrimPrefix(line, "+++ "); p != "/dev/null" {
+				cur.Path = strings.TrimPrefix(unquote(p), "b/")
+			}
+		case strings.HasPrefix(line, "Binary files "):
+			cur.Binary = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("git: read diff: %w", err)
+	}
+	flush()
+	for i := range files {
+		f := &files[i]
+		if f.Status == Deleted {
+			f.Path = f.OldPath
+		}
+		if f.Status == Modified || f.Status == Added || f.Status == Deleted {
+			f.OldPath = ""
+		}
+	}
+	return files, nil
+}
+
+// parseHunkHeader parses "@@ -a,b +c,d @@ section".
+func parseHunkHeader(line string) (diff.Hunk, error) {
+	rest := strings.TrimPrefix(line, "@@ ")
+	ranges, section, ok := strings.Cut(rest, " @@")
+	fields := strings.Fields(ranges)
+	if !ok || len(fields) != 2 {
+		return diff.Hunk{}, fmt.Errorf("git: bad hunk header %q", line)
+	}
+	var h diff.Hunk
+	var err1, err2 error
+	h.OldStart, h.OldLines, err1 = parseRange(strings.TrimPrefix(fields[0], "-"))
+	h.NewStart, h.NewLines, err2 = parseRange(strings.TrimPrefix(fields[1], "+"))
+	if err1 != nil || err2 != nil {
+		return diff.Hunk{}, fmt.Errorf("git: bad hunk header %q", line)
+	}
+	h.Section = strings.TrimSpace(section)
+	return h, nil
+}
+
+func parseRange(s string) (start, count int, err error) {
+	a, b, hasCount := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(a); err != nil {
+		return 0, 0, err
+	}
+	count = 1
+	if hasCount {
+		count, err = strconv.Atoi(b)
+	}
+	return start, count, err
+}
+
+// splitGitHeader extracts the paths from "a/old b/new". Header paths are
+// ambiguous when they contain spaces, so the ---/+++ and rename lines that
+// follow take precedence when present.
+func splitGitHeader(s string) (oldPath, newPath string) {
+	if strings.HasPrefix(s, `"`) {
+		if end := closingQuote(s); end > 0 {
+			oldPath = unquote(s[:end+1])
+			newPath = unquote(strings.TrimSpace(s[end+1:]))
+			return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+		}
+	}
+	// Unquoted: both halves are equal unless the file was renamed.
+	if half := (len(s) - 1) / 2; len(s)%2 == 1 && s[half] == ' ' && s[2:half] == s[half+3:] {
+		return s[2:half], s[half+3:]
+	}
+	if i := strings.LastIndex(s, " b/"); i >= 0 {
+		return strings.TrimPrefix(s[:i], "a/"), s[i+3:]
+	}
+	return s, s
+}
+
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote decodes a C-style quoted path as printed by git.
+func unquote(s string) string {
+	s = strings.TrimRight(s, "\t")
+	if !strings.HasPrefix(s, `"`) {
+		return s
+	}
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// validRev reports whether rev is safe to pass as a revision argument.
+func validRev(rev string) bool {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return false
+	}
+	for _, r := range rev {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
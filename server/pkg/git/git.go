@@ -0,0 +1,285 @@
+// Package git versions workspaces through the git command line. Every
+// operation runs git in the workspace directory with a fixed, hardened
+// configuration: repository hooks and fsmonitor commands are disabled,
+// remotes are reachable over HTTPS only, and git never prompts. Credentials
+// for push, pull, and clone are passed to a one-shot credential helper
+// through the environment, so they are neither written to .git/config nor
+// visible in the process list.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotRepo is returned when the workspace is not a git repository.
+	ErrNotRepo = errors.New("git: not a git repository")
+	// ErrInvalidURL is returned for clone URLs that are not HTTPS.
+	ErrInvalidURL = errors.New("git: only https:// remotes are supported")
+	// ErrInvalidArg is returned for branch, remote, or path arguments that
+	// could be mistaken for options or are otherwise malformed.
+	ErrInvalidArg = errors.New("git: invalid argument")
+)
+
+// Error is a failed git command. Stderr holds git's explanation, which is
+// usually fit to show to the user.
+type Error struct {
+	Args     []string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *Error) Error() string {
+	msg := strings.TrimSpace(e.Stderr)
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	if msg == "" {
+		msg = "exit status " + strconv.Itoa(e.ExitCode)
+	}
+	return fmt.Sprintf("git %s: %s", e.Args[0], msg)
+}
+
+// Credentials authenticate against an HTTPS remote. An empty Username is
+// sent as "x-access-token", which token-based hosts such as GitHub accept.
+type Credentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password"`
+}
+
+// Author identifies who made a commit.
+type Author struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// hardening is passed before every subcommand.
+var hardening = []string{
+	"-c", "core.hooksPath=/dev/null",
+	"-c", "core.fsmonitor=false",
+	"-c", "protocol.allow=never",
+	"-c", "protocol.https.allow=always",
+	"-c", "credential.helper=",
+	"-c", "core.quotePath=false",
+}
+
+// credentialHelper answers git's credential request from the environment.
+const credentialHelper = `!f() { test "$1" = get || exit 0; echo "username=$WEBIDE_GIT_USERNAME"; echo "password=$WEBIDE_GIT_PASSWORD"; }; f`
+
+// Repo is a working tree.
+type Repo struct {
+	dir string
+}
+
+// Open returns the repository rooted at dir. It does not check that dir
+// holds a repository; operations on a plain directory fail with ErrNotRepo.
+func Open(dir string) *Repo {
+	return &Repo{dir: dir}
+}
+
+// Dir returns the working tree root.
+func (r *Repo) Dir() string { return r.dir }
+
+// run executes git with args and returns its standard output.
+func (r *Repo) run(ctx context.Context, creds *Credentials, env []string, args ...string) ([]byte, error) {
+	return run(ctx, r.dir, creds, env, args...)
+}
+
+func run(ctx context.Context, dir string, creds *Credentials, env []string, args ...string) ([]byte, error) {
+	argv := append([]string{}, hardening...)
+	argv = append(argv, "-c", "safe.directory="+dir)
+	cmdEnv := append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ASKPASS=",
+		"GIT_LITERAL_PATHSPECS=1",
+		"GIT_CONFIG_NOSYSTEM=1",
+		// Never pick up a repository enclosing the workspace.
+		"GIT_CEILING_DIRECTORIES="+filepath.Dir(dir),
+		"LC_ALL=C",
+	)
+	if creds != nil {
+		user := creds.Username
+		if user == "" {
+			user = "x-access-token"
+		}
+		argv = append(argv, "-c", "credential.helper="+credentialHelper)
+		cmdEnv = append(cmdEnv, "WEBIDE_GIT_USERNAME="+user, "WEBIDE_GIT_PASSWORD="+creds.Password)
+	}
+	argv = append(argv, args...)
+
+	cmd := exec.CommandContext(ctx, "git", argv...)
+	cmd.Dir = dir
+	cmd.Env = append(cmdEnv, env...)
+	cmd.WaitDelay = 5 * time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return stdout.Bytes(), nil
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("git %s: %w", args[0], ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	if strings.Contains(stderr.String(), "not a git repository") {
+		return nil, ErrNotRepo
+	}
+	return nil, &Error{Args: args, ExitCode: exitErr.ExitCode(), Stderr: stderr.String()}
+}
+
+// CloneOptions configures Clone.
+type CloneOptions struct {
+	// Branch checks out this branch instead of the remote's default.
+	Branch string
+	// Depth makes a shallow clone of that many commits when positive.
+	Depth int
+	Auth  *Credentials
+}
+
+// Clone clones the HTTPS repository at rawURL into dir, which must not
+// exist or be empty. Credentials in the URL itself are rejected; pass them
+// in opts.Auth instead.
+func Clone(ctx context.Context, rawURL, dir string, opts CloneOptions) (*Repo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: put credentials in auth, not the URL", ErrInvalidURL)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	args := []string{"clone", "--quiet"}
+	if opts.Branch != "" {
+		if !validRef(opts.Branch) {
+			return nil, fmt.Errorf("%w: branch %q", ErrInvalidArg, opts.Branch)
+		}
+		args = append(args, "--branch", opts.Branch)
+	}
+	if opts.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(opts.Depth))
+	}
+	args = append(args, "--", u.String(), ".")
+	if _, err := run(ctx, dir, opts.Auth, nil, args...); err != nil {
+		return nil, err
+	}
+	return Open(dir), nil
+}
+
+// Init creates an empty repository in the working tree.
+func (r *Repo) Init(ctx context.Context, branch string) error {
+	args := []string{"init", "--quiet"}
+	if branch != "" {
+		if !validRef(branch) {
+			return fmt.Errorf("%w: branch %q", ErrInvalidArg, branch)
+		}
+		args = append(args, "--initial-branch", branch)
+	}
+	_, err := r.run(ctx, nil, nil, args...)
+	return err
+}
+
+// Stage adds the current contents of paths to the index. An empty list
+// stages every change, including deletions and new files.
+func (r *Repo) Stage(ctx context.Context, paths []string) error {
+	if err := checkPaths(paths); err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		_, err := r.run(ctx, nil, nil, "add", "--all")
+		return err
+	}
+	_, err := r.run(ctx, nil, nil, append([]string{"add", "--all", "--"}, paths...)...)
+	return err
+}
+
+// Unstage removes paths from the index, leaving the working tree alone.
+// An empty list unstages everything.
+func (r *Repo) Unstage(ctx context.Context, paths []string) error {
+	if err := checkPaths(paths); err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	hasHead, err := r.hasHead(ctx)
+	if err != nil {
+		return err
+	}
+	args := []string{"reset", "--quiet", "HEAD", "--"}
+	if !hasHead {
+		// Before the first commit there is nothing to reset to.
+		args = []string{"rm", "--cached", "--quiet", "-r", "--ignore-unmatch", "--"}
+	}
+	_, err = r.run(ctx, nil, nil, append(args, paths...)...)
+	return err
+}
+
+// Commit records the index as a new commit and returns its hash.
+func (r *Repo) Commit(ctx context.Context, message string, author Author) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("%w: empty commit message", ErrInvalidArg)
+	}
+	if author.Name == "" || author.Email == "" {
+		return "", fmt.Errorf("%w: commit author needs a name and email", ErrInvalidArg)
+	}
+	env := []string{
+		"GIT_AUTHOR_NAME=" + author.Name, "GIT_AUTHOR_EMAIL=" + author.Email,
+		"GIT_COMMITTER_NAME=" + author.Name, "GIT_COMMITTER_EMAIL=" + author.Email,
+	}
+	if _, err := r.run(ctx, nil, env, "commit", "--quiet", "--cleanup=strip", "--message", message); err != nil {
+		return "", err
+	}
+	out, err := r.run(ctx, nil, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (r *Repo) hasHead(ctx context.Context) (bool, error) {
+	_, err := r.run(ctx, nil, nil, "rev-parse", "--verify", "--quiet", "HEAD")
+	var gitErr *Error
+	if errors.As(err, &gitErr) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// validRef reports whether name is acceptable as a branch or remote name
+// on the command line.
+func validRef(name string) bool {
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " ~^:?*[\\\x00") {
+		return false
+	}
+	return !strings.Contains(name, "..") && !strings.HasSuffix(name, ".lock") && !strings.HasSuffix(name, "/")
+}
+
+func checkPaths(paths []string) error {
+	for _, p := range paths {
+		if p == "" || strings.ContainsRune(p, 0) || filepath.IsAbs(p) {
+			return fmt.Errorf("%w: path %q", ErrInvalidArg, p)
+		}
+		for _, seg := range strings.Split(filepath.ToSlash(p), "/") {
+			if seg == ".." {
+				return fmt.Errorf("%w: path %q", ErrInvalidArg, p)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+const (
+	// localTimeout bounds commands that only touch the working tree.
+	localTimeout = 30 * time.Second
+	// networkTimeout bounds clone, push, and pull.
+	networkTimeout = 5 * time.Minute
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the git API for workspaces.
+type Handler struct {
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for the workspaces resolved by ws.
+func NewHandler(ws Workspaces) *Handler {
+	return &Handler{workspaces: ws}
+}
+
+// Register mounts the git routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/workspaces/{id}/git/init", h.init)
+	mux.HandleFunc("POST /api/workspaces/{id}/git/clone", h.clone)
+	mux.HandleFunc("GET /api/workspaces/{id}/git/status", h.status)
+	mux.HandleFunc("POST /api/workspaces/{id}/git/stage", h.stage)
+	mux.HandleFunc("POST /api/workspaces/{id}/git/unstage", h.unstage)
+	mux.HandleFunc("POST /api/workspaces/{id}/git/commit", h.commit)
+	mux.HandleFunc("GET /api/workspaces/{id}/git/branches", h.branches)
+	mux.HandleFunc("POST /api/workspaces/{id}/git/switch", h.switchBranch)
+	mux.HandleFunc("GET /api/workspaces/{id}/git/log", h.log)
+	mux.HandleFunc("GET /api/workspaces/{id}/git/diff", h.diff)
+	mux.HandleFunc("POST /api/workspaces/{id}/git/push", h.push)
+	mux.HandleFunc("POST /api/workspaces/{id}/git/pull", h.pull)
+}
+
+// repoFor returns the repository of the workspace named in the request path.
+func (h *Handler) repoFor(w http.ResponseWriter, r *http.Request) (*Repo, bool) {
+	dir, err := h.workspaces.Open(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return nil, false
+	}
+	return Open(dir), true
+}
+
+// decode reads an optional JSON body into v; an empty body leaves v as is.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	if err := httpx.DecodeJSON(w, r, v, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func (h *Handler) init(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Branch string `json:"branch"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+	defer cancel()
+	if err := repo.Init(ctx, req.Branch); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeStatus(ctx, w, repo, http.StatusCreated)
+}
+
+type cloneRequest struct {
+	URL    string       `json:"url"`
+	Branch string       `json:"branch,omitempty"`
+	Depth  int          `json:"depth,omitempty"`
+	Auth   *Credentials `json:"auth,omitempty"`
+}
+
+// clone fills an empty workspace with a remote repository.
+func (h *Handler) clone(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	var req cloneRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), networkTimeout)
+	defer cancel()
+	if _, err := Clone(ctx, req.URL, repo.Dir(), CloneOptions{Branch: req.Branch, Depth: req.Depth, Auth: req.Auth}); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeStatus(ctx, w, repo, http.StatusCreated)
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+	defer cancel()
+	h.writeStatus(ctx, w, repo, http.StatusOK)
+}
+
+func (h *Handler) writeStatus(ctx context.Context, w http.ResponseWriter, repo *Repo, code int) {
+	st, err := repo.Status(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, code, st)
+}
+
+type pathsRequest struct {
+	Paths []string `json:"paths"`
+}
+
+func (h *Handler) stage(w http.ResponseWriter, r *http.Request) {
+	h.index(w, r, (*Repo).Stage)
+}
+
+func (h *Handler) unstage(w http.ResponseWriter, r *http.Request) {
+	h.index(w, r, (*Repo).Unstage)
+}
+
+// index applies a stage or unstage operation and responds with the new status.
+func (h *Handler) index(w http.ResponseWriter, r *http.Request, op func(*Repo, context.Context, []string) error) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	var req pathsRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+	defer cancel()
+	if err := op(repo, ctx, req.Paths); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeStatus(ctx, w, repo, http.StatusOK)
+}
+
+type commitRequest struct {
+	Message string `json:"message"`
+	Author  Author `json:"author"`
+}
+
+func (h *Handler) commit(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	var req commitRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+	defer cancel()
+	hash, err := repo.Commit(ctx, req.Message, req.Author)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusCreated, map[string]string{"hash": hash})
+}
+
+func (h *Handler) branches(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+	defer cancel()
+	branches, err := repo.Branches(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"branches": branches})
+}
+
+type switchRequest struct {
+	Branch string `json:"branch"`
+	Create bool   `json:"create,omitempty"`
+}
+
+func (h *Handler) switchBranch(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	var req switchRequest
+	if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+	defer cancel()
+	if err := repo.Switch(ctx, req.Branch, req.Create); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeStatus(ctx, w, repo, http.StatusOK)
+}
+
+// log returns the history; ?limit= (default 50, at most 500) and ?skip=
+// page through it.
+func (h *Handler) log(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	limit = min(limit, 500)
+	skip, _ := strconv.Atoi(q.Get("skip"))
+	skip = max(skip, 0)
+
+	ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+	defer cancel()
+	commits, err := repo.Log(ctx, limit, skip)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"commits": commits})
+}
+
+// diff returns structured hunks. ?from= (default HEAD) and ?to= pick the
+// revisions; without ?to= the working tree is compared, or the index with
+// ?staged=1. Repeated ?path= limits the files and ?context= sets the
+// number of context lines.
+func (h *Handler) diff(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	opts := DiffOptions{
+		From:   q.Get("from"),
+		To:     q.Get("to"),
+		Staged: q.Get("staged") == "1" || q.Get("staged") == "true",
+		Paths:  q["path"],
+	}
+	if c, err := strconv.Atoi(q.Get("context")); err == nil {
+		opts.Context = min(c, 1000)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+	defer cancel()
+	files, err := repo.Diff(ctx, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"files": files})
+}
+
+type syncRequest struct {
+	Remote      string       `json:"remote,omitempty"`
+	Branch      string       `json:"branch,omitempty"`
+	Auth        *Credentials `json:"auth,omitempty"`
+	Rebase      bool         `json:"rebase,omitempty"`
+	SetUpstream bool         `json:"setUpstream,omitempty"`
+}
+
+func (req syncRequest) options() SyncOptions {
+	return SyncOptions{Remote: req.Remote, Branch: req.Branch, Auth: req.Auth, Rebase: req.Rebase, SetUpstream: req.SetUpstream}
+}
+
+func (h *Handler) push(w http.ResponseWriter, r *http.Request) {
+	h.sync(w, r, (*Repo).Push)
+}
+
+func (h *Handler) pull(w http.ResponseWriter, r *http.Request) {
+	h.sync(w, r, (*Repo).Pull)
+}
+
+func (h *Handler) sync(w http.ResponseWriter, r *http.Request, op func(*Repo, context.Context, SyncOptions) error) {
+	repo, ok := h.repoFor(w, r)
+	if !ok {
+		return
+	}
+	var req syncRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), networkTimeout)
+	defer cancel()
+	if err := op(repo, ctx, req.options()); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeStatus(ctx, w, repo, http.StatusOK)
+}
+
+// writeError maps git errors to HTTP statuses. Failed git commands are
+// conflicts with the repository state, and git's message is passed on.
+func writeError(w http.ResponseWriter, err error) {
+	var gitErr *Error
+	switch {
+	case errors.Is(err, ErrInvalidArg), errors.Is(err, ErrInvalidURL):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotRepo):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.As(err, &gitErr):
+		httpx.Error(w, http.StatusConflict, gitErr.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		httpx.Error(w, http.StatusGatewayTimeout, "git command timed out")
+	default:
+		slog.Error("git", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "git operation failed")
+	}
+}
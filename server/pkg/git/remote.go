@@ -0,0 +1,65 @@
+package git
+
+import (
+	"context"
+	"fmt"
+)
+
+// SyncOptions configures Push and Pull. Empty Remote means "origin" and
+// empty Branch the current branch.
+type SyncOptions struct {
+	Remote string
+	Branch string
+	Auth   *Credentials
+	// Rebase makes Pull rebase local commits instead of requiring a
+	// fast-forward.
+	Rebase bool
+	// SetUpstream makes Push record the remote branch as upstream.
+	SetUpstream bool
+}
+
+func (o SyncOptions) target() ([]string, error) {
+	remote := o.Remote
+	if remote == "" {
+		remote = "origin"
+	}
+	if !validRef(remote) {
+		return nil, fmt.Errorf("%w: remote %q", ErrInvalidArg, remote)
+	}
+	if o.Branch == "" {
+		return []string{remote}, nil
+	}
+	if !validRef(o.Branch) {
+		return nil, fmt.Errorf("%w: branch %q", ErrInvalidArg, o.Branch)
+	}
+	return []string{remote, o.Branch}, nil
+}
+
+// Push sends local commits to the remote.
+func (r *Repo) Push(ctx context.Context, opts SyncOptions) error {
+	target, err := opts.target()
+	if err != nil {
+		return err
+	}
+	args := []string{"push", "--quiet", "--porcelain"}
+	if opts.SetUpstream {
+		args = append(args, "--set-upstream")
+	}
+	_, err = r.run(ctx, opts.Auth, nil, append(args, target...)...)
+	return err
+}
+
+// Pull fetches from the remote and integrates its commits, refusing to
+// create a merge commit unless Rebase is set.
+func (r *Repo) Pull(ctx context.Context, opts SyncOptions) error {
+	target, err := opts.target()
+	if err != nil {
+		return err
+	}
+	args := []string{"pull", "--quiet", "--ff-only"}
+	if opts.Rebase {
+		args = []string{"pull", "--quiet", "--rebase"}
+	}
+	_, err = r.run(ctx, opts.Auth, nil, append(args, target...)...)
+	return err
+}
@@ -0,0 +1,210 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change describes how a file differs in the index or working tree.
+type Change string
+
+const (
+	Unchanged  Change = ""
+	Modified   Change = "modified"
+	Added      Change = "added"
+	Deleted    Change = "deleted"
+	Renamed    Change = "renamed"
+	Copied     Change = "copied"
+	TypeChange Change = "typechange"
+	Untracked  Change = "untracked"
+	Unmerged   Change = "unmerged"
+)
+
+var changeCodes = map[byte]Change{
+	'.': Unchanged, 'M': Modified, 'A': Added, 'D': Deleted,
+	'R': Renamed, 'C': Copied, 'T': TypeChange, 'U': Unmerged,
+}
+
+// FileStatus is one changed path. Staged is the change recorded in the
+// index relative to HEAD, Unstaged the change in the working tree relative
+// to the index.
+type FileStatus struct {
+	Path       string `json:"path"`
+	OrigPath   string `json:"origPath,omitempty"`
+	Staged     Change `json:"staged,omitempty"`
+	Unstaged   Change `json:"unstaged,omitempty"`
+	Conflicted bool   `json:"conflicted,omitempty"`
+}
+
+// Status summarizes the working tree.
+type Status struct {
+	// Branch is empty when HEAD is detached.
+	Branch   string       `json:"branch"`
+	Head     string       `json:"head,omitempty"`
+	Upstream string       `json:"upstream,omitempty"`
+	Ahead    int          `json:"ahead"`
+	Behind   int          `json:"behind"`
+	Files    []FileStatus `json:"files"`
+}
+
+// Status reports the current branch and every changed or untracked file.
+func (r *Repo) Status(ctx context.Context) (*Status, error) {
+	out, err := r.run(ctx, nil, nil, "status", "--porcelain=v2", "--branch", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(out)
+}
+
+func parseStatus(out []byte) (*Status, error) {
+	st := &Status{Files: []FileStatus{}}
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i < len(fields); i++ {
+		line := string(fields[i])
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case '#':
+			parseBranchHeader(st, line)
+		case '1', '2', 'u':
+			// "1 XY sub mH mI mW hH hI path"
+			// "2 XY sub mH mI mW hH hI Xscore path" followed by the original path
+			// "u XY sub m1 m2 m3 mW h1 h2 h3 path"
+			n := map[byte]int{'1': 9, '2': 10, 'u': 11}[line[0]]
+			parts := strings.SplitN(line, " ", n)
+			if len(parts) < n || len(parts[1]) != 2 {
+				return nil, fmt.Errorf("git: unexpected status line %q", line)
+			}
+			f := FileStatus{
+				Path:     parts[n-1],
+				Staged:   changeCodes[parts[1][0]],
+				Unstaged: changeCodes[parts[1][1]],
+			}
+			if line[0] == 'u' {
+				f.Conflicted = true
+			}
+			if line[0] == '2' && i+1 < len(fields) {
+				i++
+				f.OrigPath = string(fields[i])
+			}
+			st.Files = append(st.Files, f)
+		case '?':
+			st.Files = append(st.Files, FileStatus{Path: line[2:], Unstaged: Untracked})
+		}
+	}
+	return st, nil
+}
+
+func parseBranchHeader(st *Status, line string) {
+	key, val, _ := strings.Cut(strings.TrimPrefix(line, "# "), " ")
+	switch key {
+	case "branch.oid":
+		if val != "(initial)" {
+			st.Head = val
+		}
+	case "branch.head":
+		if val != "(detached)" {
+			st.Branch = val
+		}
+	case "branch.upstream":
+		st.Upstream = val
+	case "branch.ab":
+		a, b, _ := strings.Cut(val, " ")
+		st.Ahead, _ = strconv.Atoi(strings.TrimPrefix(a, "+"))
+		st.Behind, _ = strconv.Atoi(strings.TrimPrefix(b, "-"))
+	}
+}
+
+// Branch is a local or remote-tracking branch.
+type Branch struct {
+	Name     string `json:"name"`
+	Head     string `json:"head"`
+	Current  bool   `json:"current,omitempty"`
+	Remote   bool   `json:"remote,omitempty"`
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// Branches lists local branches followed by remote-tracking branches.
+func (r *Repo) Branches(ctx context.Context) ([]Branch, error) {
+	out, err := r.run(ctx, nil, nil, "for-each-ref",
+		"--format=%(refname)%00%(objectname)%00%(HEAD)%00%(upstream:short)",
+		"refs/heads", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+	branches := []Branch{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) != 4 || strings.HasSuffix(parts[0], "/HEAD") {
+			continue
+		}
+		b := Branch{Head: parts[1], Current: parts[2] == "*", Upstream: parts[3]}
+		if name, ok := strings.CutPrefix(parts[0], "refs/heads/"); ok {
+			b.Name = name
+		} else {
+			b.Name = strings.TrimPrefix(parts[0], "refs/remotes/")
+			b.Remote = true
+		}
+		branches = append(branches, b)
+	}
+	return branches, nil
+}
+
+// Switch checks out branch, creating it from HEAD first when create is set.
+func (r *Repo) Switch(ctx context.Context, branch string, create bool) error {
+	if !validRef(branch) {
+		return fmt.Errorf("%w: branch %q", ErrInvalidArg, branch)
+	}
+	args := []string{"switch", "--quiet"}
+	if create {
+		args = append(args, "--create")
+	}
+	_, err := r.run(ctx, nil, nil, append(args, branch)...)
+	return err
+}
+
+// Commit is one entry of the history.
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Parents []string  `json:"parents"`
+	Author  Author    `json:"author"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Log returns up to limit commits reachable from HEAD, newest first,
+// after skipping the first skip. A repository without commits has an
+// empty history.
+func (r *Repo) Log(ctx context.Context, limit, skip int) ([]Commit, error) {
+	hasHead, err := r.hasHead(ctx)
+	if err != nil || !hasHead {
+		return []Commit{}, err
+	}
+	out, err := r.run(ctx, nil, nil, "log", "-z",
+		"--max-count="+strconv.Itoa(limit), "--skip="+strconv.Itoa(skip),
+		"--format=%H%x1f%P%x1f%an%x1f%ae%x1f%at%x1f%B")
+	if err != nil {
+		return nil, err
+	}
+	commits := []Commit{}
+	for _, rec := range strings.Split(string(out), "\x00") {
+		parts := strings.SplitN(rec, "\x1f", 6)
+		if len(parts) != 6 {
+			continue
+		}
+		sec, _ := strconv.ParseInt(parts[4], 10, 64)
+		commits = append(commits, Commit{
+			Hash:    parts[0],
+			Parents: strings.Fields(parts[1]),
+			Author:  Author{Name: parts[2], Email: parts[3]},
+			Time:    time.Unix(sec, 0).UTC(),
+			Message: strings.TrimRight(parts[5], "\n"),
+		})
+	}
+	return commits, nil
+}
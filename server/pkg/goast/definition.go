@@ -0,0 +1,235 @@
+package goast
+
+import (
+	"fmt"
+	"go/ast"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// maxCandidates bounds the definitions returned for an ambiguous name.
+const maxCandidates = 20
+
+// Location is where a definition is.
+type Location struct {
+	Path  string     `json:"path"`
+	Range diag.Range `json:"range"`
+	// Kind is the kind of a package-level definition.
+	Kind Kind `json:"kind,omitempty"`
+}
+
+// Definition finds the definition of the identifier at pos in file p of
+// the workspace rooted at root. Local names resolve within the file,
+// package-level names within the file's package, and qualified names
+// within the packages of the workspace's module. A field or method reached
+// through a value resolves to every field or method of that name in the
+// package, or failing that in the imported workspace packages, so it may
+// return several locations. Definitions outside the workspace, such as
+// in the standard library, are not found.
+func Definition(root, p string, pos diag.Position) ([]Location, error) {
+	fsys, err := files.New(root)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := files.Clean(p)
+	if err != nil {
+		return nil, err
+	}
+	pf, err := parseFile(fsys, rel)
+	if err != nil {
+		return nil, err
+	}
+	id, sel := pf.identAt(pos)
+	if id == nil {
+		return nil, fmt.Errorf("%w: no identifier at %d:%d", ErrNotFound, pos.Line, pos.Column)
+	}
+
+	// A declaration is its own definition.
+	here := pf.position(id.Pos())
+	for _, s := range pf.symbols() {
+		for _, d := range append([]Symbol{s}, s.Children...) {
+			if d.Selection.Start == here {
+				return []Location{{Path: rel, Range: d.Selection, Kind: d.Kind}}, nil
+			}
+		}
+	}
+
+	if sel != nil && sel.Sel == id {
+		if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+			if _, importPath := pf.importNamed(x.Name); importPath != "" {
+				dir, ok := importDir(fsys, rel, importPath)
+				if !ok {
+					return nil, fmt.Errorf("%w: %s is not in the workspace", ErrNotFound, importPath)
+				}
+				return found(topLevel(loadDir(fsys, dir, ""), id.Name))
+			}
+		}
+		// A field or method: without types, look for the name in the
+		// package, then in the workspace packages the file imports.
+		pkg := loadDir(fsys, path.Dir(rel), pf.file.Name.Name)
+		if locs := members(pkg, id.Name); len(locs) > 0 {
+			return locs[:min(len(locs), maxCandidates)], nil
+		}
+		var locs []Location
+		for _, im := range pf.file.Imports {
+			importPath, _ := strconv.Unquote(im.Path.Value)
+			if dir, ok := importDir(fsys, rel, importPath); ok {
+				locs = append(locs, members(loadDir(fsys, dir, ""), id.Name)...)
+			}
+		}
+		return found(locs[:min(len(locs), maxCandidates)])
+	}
+
+	if id.Obj != nil {
+		// Resolved by the parser within the file; the position of a
+		// declaration is that of its name.
+		if decl := declIdent(id.Obj); decl != nil {
+			return []Location{{Path: rel, Range: pf.span(decl.Pos(), decl.End())}}, nil
+		}
+	}
+	if im, _ := pf.importNamed(id.Name); im != nil && pf.file.Scope.Lookup(id.Name) == nil {
+		// A package name leads to its import.
+		return []Location{{Path: rel, Range: pf.span(im.Pos(), im.End())}}, nil
+	}
+	if pf.file.Scope.Lookup(id.Name) == nil {
+		// Declared in another file of the package, if anywhere.
+		return found(topLevel(loadDir(fsys, path.Dir(rel), pf.file.Name.Name), id.Name))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, id.Name)
+}
+
+func found(locs []Location) ([]Location, error) {
+	if len(locs) == 0 {
+		return nil, ErrNotFound
+	}
+	return locs, nil
+}
+
+// identAt returns the identifier at pos and the selector expression it is
+// part of, if any.
+func (pf *parsed) identAt(pos diag.Position) (*ast.Ident, *ast.SelectorExpr) {
+	tf := pf.fset.File(pf.file.Pos())
+	if tf == nil || pos.Line < 1 || pos.Line > tf.LineCount() || pos.Column < 1 {
+		return nil, nil
+	}
+	start := tf.LineStart(pos.Line)
+	off := tf.Offset(start) + pos.Column - 1
+	if off > tf.Size() {
+		return nil, nil
+	}
+	at := tf.Pos(off)
+
+	var id *ast.Ident
+	var sel *ast.SelectorExpr
+	ast.Inspect(pf.file, func(n ast.Node) bool {
+		if n == nil || at < n.Pos() || at > n.End() {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if at >= n.Sel.Pos() && at <= n.Sel.End() {
+				id, sel = n.Sel, n
+				return false
+			}
+		case *ast.Ident:
+			id = n
+			return false
+		}
+		return true
+	})
+	return id, sel
+}
+
+// importNamed returns the import that name refers to and its path. An
+// unnamed import is known by the last element of its path, less any
+// "go-" prefix or version suffix, as packages are usually named.
+func (pf *parsed) importNamed(name string) (*ast.ImportSpec, string) {
+	for _, im := range pf.file.Imports {
+		importPath, err := strconv.Unquote(im.Path.Value)
+		if err != nil {
+			continue
+		}
+		if im.Name != nil {
+			if im.Name.Name == name {
+				return im, importPath
+			}
+			continue
+		}
+		base := path.Base(importPath)
+		if len(base) > 1 && base[0] == 'v' && strings.Trim(base[1:], "0123456789") == "" {
+			base = path.Base(path.Dir(importPath))
+		}
+		base = strings.TrimPrefix(base, "go-")
+		base = strings.NewReplacer("-", "", ".", "").Replace(base)
+		if base == name {
+			return im, importPath
+		}
+	}
+	return nil, ""
+}
+
+// declIdent returns the identifier declaring obj.
+func declIdent(obj *ast.Object) *ast.Ident {
+	var idents []*ast.Ident
+	switch d := obj.Decl.(type) {
+	case *ast.Field:
+		idents = d.Names
+	case *ast.ValueSpec:
+		idents = d.Names
+	case *ast.TypeSpec:
+		return d.Name
+	case *ast.FuncDecl:
+		return d.Name
+	case *ast.LabeledStmt:
+		return d.Label
+	case *ast.AssignStmt:
+		for _, e := range d.Lhs {
+			if id, ok := e.(*ast.Ident); ok {
+				idents = append(idents, id)
+			}
+		}
+	}
+	for _, id := range idents {
+		if id.Name == obj.Name {
+			return id
+		}
+	}
+	return nil
+}
+
+// topLevel returns the package-level declarations named name, methods
+// excluded.
+func topLevel(pkg []*parsed, name string) []Location {
+	var out []Location
+	for _, pf := range pkg {
+		for _, s := range pf.symbols() {
+			if s.Name == name && s.Kind != KindMethod {
+				out = append(out, Location{Path: pf.rel, Range: s.Selection, Kind: s.Kind})
+			}
+		}
+	}
+	return out
+}
+
+// members returns the methods, struct fields and interface methods named
+// name.
+func members(pkg []*parsed, name string) []Location {
+	var out []Location
+	for _, pf := range pkg {
+		for _, s := range pf.symbols() {
+			if s.Kind == KindMethod && s.Name == name {
+				out = append(out, Location{Path: pf.rel, Range: s.Selection, Kind: s.Kind})
+			}
+			for _, c := range s.Children {
+				if c.Name == name {
+					out = append(out, Location{Path: pf.rel, Range: c.Selection, Kind: c.Kind})
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,439 @@
+// Package goast provides Go navigation from the syntax tree alone:
+// document outlines, the declarations of a package, and go-to-definition
+// within the workspace. It needs neither a toolchain nor gopls, so the
+// editor can keep navigating while the language server is unavailable;
+// without type information, definitions of fields and methods reached
+// through a value are found by name and may be ambiguous.
+package goast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// MaxFileBytes is the largest file parsed.
+const MaxFileBytes = 2 << 20
+
+var (
+	// ErrNotGo is returned for paths that are not Go source files.
+	ErrNotGo = errors.New("goast: not a Go file")
+	// ErrTooLarge is returned for files over MaxFileBytes.
+	ErrTooLarge = errors.New("goast: file too large")
+	// ErrNotFound is returned when no definition is found.
+	ErrNotFound = errors.New("goast: no definition found")
+)
+
+// Kind classifies a symbol.
+type Kind string
+
+const (
+	KindFunc      Kind = "func"
+	KindMethod    Kind = "method"
+	KindStruct    Kind = "struct"
+	KindInterface Kind = "interface"
+	KindType      Kind = "type"
+	KindConst     Kind = "const"
+	KindVar       Kind = "var"
+	KindField     Kind = "field"
+)
+
+// Symbol is a declaration. Range spans the whole declaration and
+// Selection its name. Detail is the signature of a func or method and the
+// type of anything else, when written out. Receiver is the receiver type
+// of a method, without pointer or type parameters.
+type Symbol struct {
+	Name      string     `json:"name"`
+	Kind      Kind       `json:"kind"`
+	Detail    string     `json:"detail,omitempty"`
+	Receiver  string     `json:"receiver,omitempty"`
+	Path      string     `json:"path,omitempty"`
+	Range     diag.Range `json:"range"`
+	Selection diag.Range `json:"selection"`
+	// Children are the fields of a struct and the methods of an interface.
+	Children []Symbol `json:"children,omitempty"`
+}
+
+// Import is an import of a file.
+type Import struct {
+	Path  string     `json:"path"`
+	Name  string     `json:"name,omitempty"`
+	Range diag.Range `json:"range"`
+}
+
+// Outline is the structure of one file. A file with syntax errors is
+// outlined as far as it parses, and its errors listed.
+type Outline struct {
+	Path    string   `json:"path"`
+	Package string   `json:"package"`
+	Imports []Import `json:"imports"`
+	Symbols []Symbol `json:"symbols"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Package lists the declarations of the package in one directory, tests
+// excluded.
+type Package struct {
+	Dir     string   `json:"dir"`
+	Name    string   `json:"name"`
+	Files   []string `json:"files"`
+	Symbols []Symbol `json:"symbols"`
+}
+
+// parsed is a parsed file of the workspace.
+type parsed struct {
+	rel  string
+	fset *token.FileSet
+	file *ast.File
+	err  error
+}
+
+// parseFile parses the workspace file rel. Syntax errors are kept in err
+// alongside the partial tree.
+func parseFile(fsys *files.FS, rel string) (*parsed, error) {
+	if path.Ext(rel) != ".go" {
+		return nil, fmt.Errorf("%w: %s", ErrNotGo, rel)
+	}
+	abs, err := fsys.Resolve(rel)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, fmt.Errorf("%w: %s", files.ErrIsDir, rel)
+	}
+	if fi.Size() > MaxFileBytes {
+		return nil, fmt.Errorf("%w: %s", ErrTooLarge, rel)
+	}
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	p := &parsed{rel: rel, fset: token.NewFileSet()}
+	p.file, p.err = parser.ParseFile(p.fset, rel, src, parser.ParseComments)
+	if p.file == nil {
+		return nil, fmt.Errorf("goast: parse %s: %w", rel, p.err)
+	}
+	return p, nil
+}
+
+// FileOutline returns the outline of file p in the workspace rooted at
+// root.
+func FileOutline(root, p string) (*Outline, error) {
+	fsys, err := files.New(root)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := files.Clean(p)
+	if err != nil {
+		return nil, err
+	}
+	pf, err := parseFile(fsys, rel)
+	if err != nil {
+		return nil, err
+	}
+	o := &Outline{Path: rel, Package: pf.file.Name.Name, Imports: []Import{}, Symbols: pf.symbols()}
+	for _, im := range pf.file.Imports {
+		i := Import{Path: strings.Trim(im.Path.Value, "`\""), Range: pf.span(im.Pos(), im.End())}
+		if im.Name != nil {
+			i.Name = im.Name.Name
+		}
+		o.Imports = append(o.Imports, i)
+	}
+	var list scanner.ErrorList
+	if errors.As(pf.err, &list) {
+		for _, e := range list {
+			o.Errors = append(o.Errors, e.Error())
+		}
+	}
+	return o, nil
+}
+
+// PackageSymbols returns the declarations of the package in directory dir
+// of the workspace rooted at root, sorted by name.
+func PackageSymbols(root, dir string) (*Package, error) {
+	fsys, err := files.New(root)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := files.Clean(dir)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := fsys.Resolve(rel)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(abs); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%w: %s is not a directory", files.ErrInvalidPath, rel)
+	}
+	pkg := &Package{Dir: rel, Files: []string{}, Symbols: []Symbol{}}
+	for _, pf := range loadDir(fsys, rel, "") {
+		if pkg.Name == "" {
+			pkg.Name = pf.file.Name.Name
+		}
+		pkg.Files = append(pkg.Files, pf.rel)
+		pkg.Symbols = append(pkg.Symbols, pf.symbols()...)
+	}
+	slices.SortStableFunc(pkg.Symbols, func(a, b Symbol) int { return strings.Compare(a.Name, b.Name) })
+	return pkg, nil
+}
+
+// loadDir parses the non-test Go files of directory rel. Only files of
+// package name are returned, or of the package most files declare when
+// name is empty. Files that fail to read are skipped.
+func loadDir(fsys *files.FS, rel, name string) []*parsed {
+	abs, err := fsys.Resolve(rel)
+	if err != nil {
+		return nil
+	}
+	des, err := os.ReadDir(abs)
+	if err != nil {
+		return nil
+	}
+	var all []*parsed
+	count := make(map[string]int)
+	for _, de := range des {
+		n := de.Name()
+		if de.IsDir() || !strings.HasSuffix(n, ".go") || strings.HasSuffix(n, "_test.go") {
+			continue
+		}
+		pf, err := parseFile(fsys, path.Join(rel, n))
+		if err != nil {
+			continue
+		}
+		all = append(all, pf)
+		count[pf.file.Name.Name]++
+	}
+	if name == "" {
+		for n, c := range count {
+			if c > count[name] || (c == count[name] && n < name) {
+				name = n
+			}
+		}
+	}
+	return slices.DeleteFunc(all, func(pf *parsed) bool { return pf.file.Name.Name != name })
+}
+
+// position converts a token position to a 1-based line and byte column.
+func (pf *parsed) position(pos token.Pos) diag.Position {
+	p := pf.fset.Position(pos)
+	return diag.Position{Line: p.Line, Column: p.Column}
+}
+
+func (pf *parsed) span(from, to token.Pos) diag.Range {
+	return diag.Range{Start: pf.position(from), End: pf.position(to)}
+}
+
+// symbol describes the declaration of name spanning node.
+func (pf *parsed) symbol(name *ast.Ident, kind Kind, node ast.Node) Symbol {
+	return Symbol{
+		Name:      name.Name,
+		Kind:      kind,
+		Path:      pf.rel,
+		Range:     pf.span(node.Pos(), node.End()),
+		Selection: pf.span(name.Pos(), name.End()),
+	}
+}
+
+// symbols lists the top-level declarations of the file in source order.
+func (pf *parsed) symbols() []Symbol {
+	out := []Symbol{}
+	for _, decl := range pf.file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Name == nil {
+				continue
+			}
+			s := pf.symbol(d.Name, KindFunc, d)
+			s.Detail = pf.print(d.Type)
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				s.Kind = KindMethod
+				s.Receiver = receiverName(d.Recv.List[0].Type)
+			}
+			out = append(out, s)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				out = append(out, pf.specSymbols(d, spec)...)
+			}
+		}
+	}
+	return out
+}
+
+func (pf *parsed) specSymbols(d *ast.GenDecl, spec ast.Spec) []Symbol {
+	// A lone spec spans its keyword too, as "type T struct{...}" reads.
+	node := ast.Node(spec)
+	if len(d.Specs) == 1 {
+		node = d
+	}
+	switch sp := spec.(type) {
+	case *ast.TypeSpec:
+		s := pf.symbol(sp.Name, KindType, node)
+		switch t := sp.Type.(type) {
+		case *ast.StructType:
+			s.Kind, s.Detail = KindStruct, "struct{...}"
+			s.Children = pf.fields(t.Fields, KindField)
+		case *ast.InterfaceType:
+			s.Kind, s.Detail = KindInterface, "interface{...}"
+			s.Children = pf.fields(t.Methods, KindMethod)
+		default:
+			s.Detail = pf.print(sp.Type)
+		}
+		return []Symbol{s}
+	case *ast.ValueSpec:
+		kind := KindVar
+		if d.Tok == token.CONST {
+			kind = KindConst
+		}
+		var out []Symbol
+		for _, id := range sp.Names {
+			if id.Name == "_" {
+				continue
+			}
+			s := pf.symbol(id, kind, node)
+			if sp.Type != nil {
+				s.Detail = pf.print(sp.Type)
+			}
+			out = append(out, s)
+		}
+		return out
+	}
+	return nil
+}
+
+// fields lists the named fields of a struct or methods of an interface;
+// embedded ones are listed by their type name.
+func (pf *parsed) fields(list *ast.FieldList, kind Kind) []Symbol {
+	if list == nil {
+		return nil
+	}
+	var out []Symbol
+	for _, f := range list.List {
+		detail := pf.print(f.Type)
+		if len(f.Names) == 0 {
+			if id := embeddedIdent(f.Type); id != nil {
+				s := pf.symbol(id, KindField, f)
+				s.Detail = detail
+				out = append(out, s)
+			}
+			continue
+		}
+		for _, id := range f.Names {
+			s := pf.symbol(id, kind, f)
+			s.Detail = detail
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// print renders an expression as source, cut short if long.
+func (pf *parsed) print(node ast.Node) string {
+	var b bytes.Buffer
+	if printer.Fprint(&b, pf.fset, node) != nil {
+		return ""
+	}
+	s := strings.Join(strings.Fields(b.String()), " ")
+	if len(s) > 200 {
+		s = s[:197] + "..."
+	}
+	return s
+}
+
+// receiverName returns the type name of a receiver or embedded field,
+// without pointer, package or type parameters.
+func receiverName(expr ast.Expr) string {
+	if id := embeddedIdent(expr); id != nil {
+		return id.Name
+	}
+	return ""
+}
+
+func embeddedIdent(expr ast.Expr) *ast.Ident {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			return e.Sel
+		case *ast.Ident:
+			return e
+		default:
+			return nil
+		}
+	}
+}
+
+// modulePath returns the module root, relative to the workspace, and the
+// module path of the go.mod nearest above rel, or false outside a module.
+func modulePath(fsys *files.FS, rel string) (dir, mod string, ok bool) {
+	for dir = rel; ; dir = path.Dir(dir) {
+		if dir == "." {
+			dir = ""
+		}
+		abs, err := fsys.Resolve(path.Join(dir, "go.mod"))
+		if err == nil {
+			if data, err := os.ReadFile(abs); err == nil {
+				for _, line := range strings.Split(string(data), "\n") {
+					if f := strings.Fields(line); len(f) >= 2 && f[0] == "module" {
+						return dir, strings.Trim(f[1], "\"`"), true
+					}
+				}
+			}
+		}
+		if dir == "" {
+			return "", "", false
+		}
+	}
+}
+
+// importDir maps an import path to a workspace directory, for packages of
+// the module containing rel or vendored into it.
+func importDir(fsys *files.FS, rel, importPath string) (string, bool) {
+	root, mod, ok := modulePath(fsys, path.Dir(rel))
+	if !ok {
+		return "", false
+	}
+	var dir string
+	switch {
+	case importPath == mod:
+		dir = root
+	case strings.HasPrefix(importPath, mod+"/"):
+		dir = path.Join(root, strings.TrimPrefix(importPath, mod+"/"))
+	default:
+		dir = path.Join(root, "vendor", importPath)
+	}
+	abs, err := fsys.Resolve(dir)
+	if err != nil {
+		return "", false
+	}
+	if fi, err := os.Stat(abs); err != nil || !fi.IsDir() {
+		return "", false
+	}
+	return filepath.ToSlash(dir), true
+}
@@ -0,0 +1,111 @@
+package goast
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diag"
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/files"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the syntax-based navigation routes.
+type Handler struct {
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for the workspaces resolved by ws.
+func NewHandler(ws Workspaces) *Handler {
+	return &Handler{workspaces: ws}
+}
+
+// Register mounts the navigation routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/goast/outline", h.outline)
+	mux.HandleFunc("GET /api/workspaces/{id}/goast/package", h.pkg)
+	mux.HandleFunc("GET /api/workspaces/{id}/goast/definition", h.definition)
+}
+
+func (h *Handler) rootFor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	dir, err := h.workspaces.Open(r.PathValue("id"))
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", false
+	}
+	return dir, true
+}
+
+// outline returns the outline of ?path=.
+func (h *Handler) outline(w http.ResponseWriter, r *http.Request) {
+	root, ok := h.rootFor(w, r)
+	if !ok {
+		return
+	}
+	o, err := FileOutline(root, r.URL.Query().Get("path"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, o)
+}
+
+// pkg lists the declarations of the package in ?dir=, the workspace root
+// by default.
+func (h *Handler) pkg(w http.ResponseWriter, r *http.Request) {
+	root, ok := h.rootFor(w, r)
+	if !ok {
+		return
+	}
+	p, err := PackageSymbols(root, r.URL.Query().Get("dir"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, p)
+}
+
+// definition finds the definition of the identifier at ?line=&column= in
+// ?path=.
+func (h *Handler) definition(w http.ResponseWriter, r *http.Request) {
+	root, ok := h.rootFor(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	line, err1 := strconv.Atoi(q.Get("line"))
+	col, err2 := strconv.Atoi(q.Get("column"))
+	if err1 != nil || err2 != nil || line < 1 || col < 1 {
+		httpx.Error(w, http.StatusBadRequest, "line and column must be positive integers")
+		return
+	}
+	locs, err := Definition(root, q.Get("path"), diag.Position{Line: line, Column: col})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"locations": locs})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		httpx.Error(w, http.StatusNotFound, "file not found")
+	case errors.Is(err, ErrNotGo), errors.Is(err, files.ErrInvalidPath), errors.Is(err, files.ErrIsDir):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooLarge):
+		httpx.Error(w, http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		slog.Error("go navigation", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "navigation failed")
+	}
+}
@@ -0,0 +1,240 @@
+// Package gomod reads go.mod, go.sum and go.work files and manages the
+// dependencies of workspace modules. Listing dependencies reads the files
+// directly; looking up upgrades and changing dependencies run the go
+// command inside the workspace's environment, and every change is
+// returned as a diff of go.mod and go.sum.
+package gomod
+
+import (
+	"strconv"
+	"strings"
+)
+
+// File is the parsed content of a go.mod file. Line numbers are 1-based.
+type File struct {
+	Module    string `json:"module"`
+	Go        string `json:"go,omitempty"`
+	Toolchain string `json:"toolchain,omitempty"`
+	// GoLine and ToolchainLine locate the go and toolchain directives, 0
+	// when missing.
+	GoLine        int       `json:"-"`
+	ToolchainLine int       `json:"-"`
+	Requires      []Require `json:"requires"`
+	Replaces      []Replace `json:"replaces,omitempty"`
+	Excludes      []Module  `json:"excludes,omitempty"`
+}
+
+// Module is a module path and version.
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+}
+
+// Require is a require directive.
+type Require struct {
+	Path     string `json:"path"`
+	Version  string `json:"version"`
+	Indirect bool   `json:"indirect,omitempty"`
+	Line     int    `json:"line"`
+	// Column is the 1-based byte column of Version on Line.
+	Column int `json:"-"`
+}
+
+// Replace is a replace directive. Old.Version is empty when every version
+// is replaced, and New.Version when New is a directory.
+type Replace struct {
+	Old  Module `json:"old"`
+	New  Module `json:"new"`
+	Line int    `json:"line"`
+}
+
+// Parse reads a go.mod file. It is lenient: lines it does not understand
+// are skipped, so a file being edited still yields what can be read.
+func Parse(data []byte) *File {
+	f := &File{Requires: []Require{}}
+	directives(data, func(verb string, fields []string, line, comment string, ln int) {
+		switch verb {
+		case "module":
+			if len(fields) == 1 {
+				f.Module = unquote(fields[0])
+			}
+		case "go":
+			if len(fields) == 1 {
+				f.Go, f.GoLine = fields[0], ln
+			}
+		case "toolchain":
+			if len(fields) == 1 {
+				f.Toolchain, f.ToolchainLine = fields[0], ln
+			}
+		case "require":
+			if len(fields) == 2 {
+				f.Requires = append(f.Requires, Require{
+					Path:     unquote(fields[0]),
+					Version:  fields[1],
+					Indirect: strings.TrimSpace(comment) == "indirect" || strings.HasPrefix(strings.TrimSpace(comment), "indirect;"),
+					Line:     ln,
+					Column:   strings.LastIndex(line, fields[1]) + 1,
+				})
+			}
+		case "exclude":
+			if len(fields) == 2 {
+				f.Excludes = append(f.Excludes, Module{Path: unquote(fields[0]), Version: fields[1]})
+			}
+		case "replace":
+			if r, ok := parseReplace(fields); ok {
+				r.Line = ln
+				f.Replaces = append(f.Replaces, r)
+			}
+		}
+	})
+	return f
+}
+
+// directives calls fn for each directive of a go.mod or go.work file,
+// those in a block under the block's verb, with the line it is on
+// without its comment, the comment and the 1-based line number.
+func directives(data []byte, fn func(verb string, fields []string, line, comment string, ln int)) {
+	block := ""
+	for i, raw := range strings.Split(string(data), "\n") {
+		line, comment, _ := strings.Cut(raw, "//")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		verb := block
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			verb, fields = fields[0], fields[1:]
+		}
+		fn(verb, fields, line, comment, i+1)
+	}
+}
+
+// parseReplace reads "old [version] => new [version]".
+func parseReplace(fields []string) (Replace, bool) {
+	arrow := -1
+	for i, f := range fields {
+		if f == "=>" {
+			arrow = i
+		}
+	}
+	old, repl := fields[:max(arrow, 0)], fields[arrow+1:]
+	if arrow < 1 || len(old) > 2 || len(repl) < 1 || len(repl) > 2 {
+		return Replace{}, false
+	}
+	var r Replace
+	r.Old.Path = unquote(old[0])
+	if len(old) == 2 {
+		r.Old.Version = old[1]
+	}
+	r.New.Path = unquote(repl[0])
+	if len(repl) == 2 {
+		r.New.Version = repl[1]
+	}
+	return r, true
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '`') {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return s
+}
+
+// Require returns the require directive for path.
+func (f *File) Require(path string) (Require, bool) {
+	for _, r := range f.Requires {
+		if r.Path == path {
+			return r, true
+		}
+	}
+	return Require{}, false
+}
+
+// Replacement returns the replace directive applying to path at version.
+// One naming the version wins over one for every version.
+func (f *File) Replacement(path, version string) (Replace, bool) {
+	var found Replace
+	ok := false
+	for _, r := range f.Replaces {
+		if r.Old.Path != path {
+			continue
+		}
+		if r.Old.Version == version {
+			return r, true
+		}
+		if r.Old.Version == "" {
+			found, ok = r, true
+		}
+	}
+	return found, ok
+}
+
+// Sum is a go.sum line: the hash of a module's content, or with GoMod of
+// its go.mod file alone.
+type Sum struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	GoMod   bool   `json:"goMod,omitempty"`
+	Hash    string `json:"hash"`
+}
+
+// ParseSum reads a go.sum file, skipping malformed lines.
+func ParseSum(data []byte) []Sum {
+	var out []Sum
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 3 {
+			continue
+		}
+		s := Sum{Path: f[0], Version: f[1], Hash: f[2]}
+		if v, ok := strings.CutSuffix(s.Version, "/go.mod"); ok {
+			s.Version, s.GoMod = v, true
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// CompareVersions orders two module versions of the form
+// vMAJOR.MINOR.PATCH with an optional pre-release, the newer one greater.
+// Build metadata is ignored, and pre-releases compare as strings.
+func CompareVersions(a, b string) int {
+	a, _, _ = strings.Cut(strings.TrimPrefix(a, "v"), "+")
+	b, _, _ = strings.Cut(strings.TrimPrefix(b, "v"), "+")
+	ac, apre, _ := strings.Cut(a, "-")
+	bc, bpre, _ := strings.Cut(b, "-")
+	an, bn := strings.Split(ac, "."), strings.Split(bc, ".")
+	for i := range max(len(an), len(bn)) {
+		var x, y int
+		if i < len(an) {
+			x, _ = strconv.Atoi(an[i])
+		}
+		if i < len(bn) {
+			y, _ = strconv.Atoi(bn[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case apre == bpre:
+		return 0
+	case apre == "":
+		return 1
+	case bpre == "":
+		return -1
+	}
+	return strings.Compare(apre, bpre)
+}
@@ -0,0 +1,294 @@
+package gomod
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Node is a module version in a Graph.
+type Node struct {
+	// ID is path@version, or the path alone for a main module.
+	ID      string `json:"id"`
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	Main    bool   `json:"main,omitempty"`
+	// Selected reports whether this is the version the build uses.
+	Selected bool `json:"selected"`
+	// Direct reports whether a main module's go.mod requires the module
+	// other than as an indirect dependency.
+	Direct bool `json:"direct,omitempty"`
+	// Size is the module's size in the module cache in bytes, 0 when it
+	// is not downloaded.
+	Size int64 `json:"size,omitempty"`
+	// Packages counts the module's packages the main modules' packages
+	// import, directly or not.
+	Packages int `json:"packages,omitempty"`
+}
+
+// Edge is a requirement of one module version on another.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is a module's requirement graph, each node and edge once. The
+// graph of a go.work workspace has a main module for each module it uses.
+type Graph struct {
+	Module string `json:"module"`
+	Path   string `json:"path"`
+	// Work and Modules are set as in Dependencies for the graph of a
+	// go.work workspace.
+	Work    string   `json:"work,omitempty"`
+	Modules []string `json:"modules,omitempty"`
+	// All reports whether Nodes has every version required, rather than
+	// only the selected ones.
+	All   bool   `json:"all"`
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	// Warning explains missing package counts, as when the module does not
+	// build.
+	Warning string `json:"warning,omitempty"`
+}
+
+// graphScript prints, in the module directory $1, the module graph, the
+// selected version and cache directory of each module with the size of
+// that directory in KiB, and the packages needed to build the packages
+// matching the remaining arguments, separated by marker lines. Only the
+// module graph must succeed; the package list's errors follow its own
+// marker.
+const graphScript = `cd "$1" || exit 2
+shift
+go mod graph || exit 1
+echo '-- modules --'
+go list -m -f '{{if not .Main}}{{.Path}} {{.Version}} {{.Dir}}{{end}}' all 2>/dev/null | while read -r p v d; do
+	[ -n "$p" ] || continue
+	s=0
+	if [ -n "$d" ] && [ -d "$d" ]; then s=$(du -sk "$d" 2>/dev/null | cut -f1); fi
+	echo "$p $v ${s:-0}"
+done
+echo '-- packages --'
+errs=$(mktemp) || exit 1
+go list -deps -json=Module,Standard "$@" 2>"$errs"
+echo '-- errors --'
+head -c 4096 "$errs"
+rm -f "$errs"`
+
+// ModuleGraph returns the requirement graph of a module of the workspace
+// at dir, or as List does without one, of every module its go.work uses.
+// Unless all, requirements on versions the build does not select point at
+// the selected version instead, leaving one node per module.
+func (s *Service) ModuleGraph(ctx context.Context, workspaceID, dir, module string, all bool) (*Graph, error) {
+	if module == "" {
+		w, err := ReadWork(dir)
+		if err != nil {
+			return nil, err
+		}
+		if w != nil {
+			return s.workGraph(ctx, workspaceID, dir, w, all)
+		}
+	}
+	module, err := cleanModule(module)
+	if err != nil {
+		return nil, err
+	}
+	mod, _, err := s.files(dir, module)
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.acquire(workspaceID, module)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	out, err := s.run(ctx, workspaceID, dir, graphScript, []string{module, "./..."}, false)
+	if err != nil {
+		return nil, err
+	}
+	f := Parse(mod)
+	g := &Graph{Module: module, Path: f.Module, All: all}
+	return buildGraph(g, []*File{f}, out)
+}
+
+// workGraph returns the graph of the modules w uses. go mod graph at the
+// workspace root covers all of them.
+func (s *Service) workGraph(ctx context.Context, workspaceID, dir string, w *WorkFile, all bool) (*Graph, error) {
+	g := &Graph{Work: "go.work", Modules: []string{}, All: all}
+	mains := make([]*File, 0, len(w.Uses))
+	for _, u := range w.Uses {
+		mod, _, err := s.files(dir, u.Dir)
+		if err != nil {
+			return nil, err
+		}
+		g.Modules = append(g.Modules, u.Dir)
+		mains = append(mains, Parse(mod))
+	}
+	release, err := s.acquire(workspaceID, "go.work")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	out, err := s.run(ctx, workspaceID, dir, graphScript, append([]string{"."}, w.Patterns()...), false)
+	if err != nil {
+		return nil, err
+	}
+	return buildGraph(g, mains, out)
+}
+
+// buildGraph fills in g from the output of graphScript for the main
+// modules with go.mod files mains.
+func buildGraph(g *Graph, mains []*File, out []byte) (*Graph, error) {
+	sections := map[string][]byte{}
+	name := "graph"
+	for _, part := range bytes.SplitAfter(out, []byte("\n")) {
+		if m, ok := strings.CutPrefix(strings.TrimSpace(string(part)), "-- "); ok && strings.HasSuffix(m, " --") {
+			name = strings.TrimSuffix(m, " --")
+			continue
+		}
+		sections[name] = append(sections[name], part...)
+	}
+
+	g.Nodes, g.Edges = []Node{}, []Edge{}
+	all := g.All
+	main := make(map[string]bool)
+	direct := make(map[string]bool)
+	for _, f := range mains {
+		main[f.Module] = true
+		for _, r := range f.Requires {
+			direct[r.Path] = direct[r.Path] || !r.Indirect
+		}
+	}
+	selected := make(map[string]string) // path to version
+	sizes := make(map[string]int64)     // path to bytes
+	sc := bufio.NewScanner(bytes.NewReader(sections["modules"]))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		selected[fields[0]] = fields[1]
+		if kb, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			sizes[fields[0]] = kb << 10
+		}
+	}
+
+	nodes := make(map[string]*Node)
+	node := func(id string) *Node {
+		if n := nodes[id]; n != nil {
+			return n
+		}
+		p, v, _ := strings.Cut(id, "@")
+		if !all && v != "" {
+			if sv, ok := selected[p]; ok {
+				v = sv
+			}
+		}
+		key := p
+		if v != "" {
+			key = p + "@" + v
+		}
+		n := nodes[key]
+		if n == nil {
+			n = &Node{ID: key, Path: p, Version: v, Main: v == "" && main[p]}
+			n.Selected = n.Main || selected[p] == v
+			n.Direct = !n.Main && direct[p]
+			if n.Selected {
+				n.Size = sizes[p]
+			}
+			nodes[key] = n
+		}
+		nodes[id] = n
+		return n
+	}
+	for _, f := range mains {
+		node(f.Module)
+	}
+	edges := make(map[Edge]bool)
+	sc = bufio.NewScanner(bytes.NewReader(sections["graph"]))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || notModule(fields[0]) || notModule(fields[1]) {
+			continue
+		}
+		e := Edge{From: node(fields[0]).ID, To: node(fields[1]).ID}
+		if e.From != e.To && !edges[e] {
+			edges[e] = true
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("gomod: read go mod graph output: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(sections["packages"]))
+	for {
+		var p struct {
+			Standard bool
+			Module   *struct{ Path, Version string }
+		}
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			g.Warning = "could not read the package list"
+			break
+		}
+		if p.Standard || p.Module == nil || main[p.Module.Path] {
+			continue
+		}
+		id := p.Module.Path
+		if v, ok := selected[id]; ok && v != "" {
+			id += "@" + v
+		}
+		if n := nodes[id]; n != nil {
+			n.Packages++
+		}
+	}
+	if msg := strings.TrimSpace(string(sections["errors"])); msg != "" {
+		g.Warning = firstLine(msg)
+	}
+
+	seen := make(map[*Node]bool)
+	for _, n := range nodes {
+		if !seen[n] {
+			seen[n] = true
+			g.Nodes = append(g.Nodes, *n)
+		}
+	}
+	slices.SortFunc(g.Nodes, func(a, b Node) int {
+		if a.Main != b.Main {
+			if a.Main {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return CompareVersions(a.Version, b.Version)
+	})
+	slices.SortFunc(g.Edges, func(a, b Edge) int {
+		if c := strings.Compare(a.From, b.From); c != 0 {
+			return c
+		}
+		return strings.Compare(a.To, b.To)
+	})
+	return g, nil
+}
+
+// notModule reports whether a go mod graph node is the go or toolchain
+// version a module declares rather than a module.
+func notModule(id string) bool {
+	return strings.HasPrefix(id, "go@") || strings.HasPrefix(id, "toolchain@")
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
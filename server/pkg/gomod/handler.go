@@ -0,0 +1,121 @@
+package gomod
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VedantPanchal23/Web-IDE/server/internal/httpx"
+)
+
+// Workspaces resolves a workspace ID to its root directory.
+type Workspaces interface {
+	Open(id string) (string, error)
+}
+
+// Handler serves the dependency routes.
+type Handler struct {
+	svc        *Service
+	workspaces Workspaces
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, wm Workspaces) *Handler {
+	return &Handler{svc: svc, workspaces: wm}
+}
+
+// Register mounts the dependency routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces/{id}/deps", h.list)
+	mux.HandleFunc("GET /api/workspaces/{id}/deps/graph", h.graph)
+	mux.HandleFunc("POST /api/workspaces/{id}/deps/add", h.action(h.svc.Add))
+	mux.HandleFunc("POST /api/workspaces/{id}/deps/upgrade", h.action(h.svc.Upgrade))
+	mux.HandleFunc("POST /api/workspaces/{id}/deps/remove", h.action(h.svc.Remove))
+	mux.HandleFunc("POST /api/workspaces/{id}/deps/tidy", h.action(h.svc.Tidy))
+}
+
+func (h *Handler) workspace(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	id := r.PathValue("id")
+	dir, err := h.workspaces.Open(id)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, "workspace not found")
+		return "", "", false
+	}
+	return id, dir, true
+}
+
+// list returns the dependencies of ?module=, by default the workspace
+// root or every module of its go.work, with their available upgrades when
+// ?updates=1.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	updates := q.Get("updates") == "1" || q.Get("updates") == "true"
+	deps, err := h.svc.List(r.Context(), id, dir, q.Get("module"), updates)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, deps)
+}
+
+// graph returns the module graph of ?module=, with every version required
+// when ?all=1.
+func (h *Handler) graph(w http.ResponseWriter, r *http.Request) {
+	id, dir, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	all := q.Get("all") == "1" || q.Get("all") == "true"
+	g, err := h.svc.ModuleGraph(r.Context(), id, dir, q.Get("module"), all)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	httpx.JSON(w, http.StatusOK, g)
+}
+
+type actionFunc func(ctx context.Context, workspaceID, dir string, req Request) (*Result, error)
+
+func (h *Handler) action(fn actionFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, dir, ok := h.workspace(w, r)
+		if !ok {
+			return
+		}
+		var req Request
+		if r.ContentLength != 0 {
+			if err := httpx.DecodeJSON(w, r, &req, 0); err != nil {
+				httpx.Error(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		res, err := fn(r.Context(), id, dir, req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpx.JSON(w, http.StatusOK, res)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNoModule):
+		httpx.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrBusy):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrFailed):
+		httpx.Error(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		slog.Error("dependencies", "err", err)
+		httpx.Error(w, http.StatusInternalServerError, "dependency command failed")
+	}
+}
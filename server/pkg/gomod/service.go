@@ -0,0 +1,615 @@
+package gomod
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/VedantPanchal23/Web-IDE/server/pkg/diff"
+)
+
+// Launcher runs commands inside a workspace's environment.
+type Launcher interface {
+	Exec(ctx context.Context, workspaceID, dir string, argv, env []string) (*exec.Cmd, error)
+	Root(dir string) string
+}
+
+// Config configures a Service.
+type Config struct {
+	// Timeout bounds one go command; defaults to 5 minutes.
+	Timeout time.Duration
+}
+
+var (
+	// ErrInvalidRequest is returned for module directories outside the
+	// workspace and malformed module paths or versions.
+	ErrInvalidRequest = errors.New("gomod: invalid request")
+	// ErrNoModule is returned when the module directory has no go.mod.
+	ErrNoModule = errors.New("gomod: no go.mod in module directory")
+	// ErrBusy is returned while a go command runs on the module.
+	ErrBusy = errors.New("gomod: a go command is already running on the module")
+	// ErrFailed is returned when the go command fails, with its output.
+	// go.mod and go.sum are left as they were.
+	ErrFailed = errors.New("gomod: go command failed")
+)
+
+// Dependency is a module the main module depends on.
+type Dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// Line is that of its require directive, 0 for modules only the
+	// build list has.
+	Line    int     `json:"line,omitempty"`
+	Replace *Module `json:"replace,omitempty"`
+	// Sum reports whether go.sum has the hash of its content.
+	Sum bool `json:"sum"`
+	// Update is the latest version when newer than Version; Deprecated and
+	// Retracted are set from the module's own go.mod. All three are only
+	// looked up on request.
+	Update     string   `json:"update,omitempty"`
+	Deprecated string   `json:"deprecated,omitempty"`
+	Retracted  []string `json:"retracted,omitempty"`
+	// Modules lists, in the dependencies of a go.work workspace, the
+	// directories of the modules requiring it.
+	Modules []string `json:"modules,omitempty"`
+}
+
+// Dependencies lists a module's dependencies, sorted by path.
+type Dependencies struct {
+	// Module is the directory of the go.mod relative to the workspace
+	// root, empty for the dependencies of every module a go.work uses.
+	Module string `json:"module"`
+	// Work is "go.work" when the list covers the modules of the
+	// workspace's go.work, those in Modules. Each dependency then has the
+	// highest version any of them requires, which is the one the
+	// workspace builds with unless a dependency increases it.
+	Work      string   `json:"work,omitempty"`
+	Modules   []string `json:"modules,omitempty"`
+	Path      string   `json:"path"`
+	Go        string   `json:"go,omitempty"`
+	Toolchain string   `json:"toolchain,omitempty"`
+	// Updates reports whether Update fields were looked up.
+	Updates  bool         `json:"updates"`
+	Direct   []Dependency `json:"direct"`
+	Indirect []Dependency `json:"indirect"`
+}
+
+// Request selects a module and, for add, upgrade and remove, a dependency.
+type Request struct {
+	// Module is the directory of the go.mod relative to the workspace
+	// root; defaults to ".".
+	Module string `json:"module,omitempty"`
+	// Path is the dependency's module path. Upgrade without one upgrades
+	// every dependency of the module's packages.
+	Path string `json:"path,omitempty"`
+	// Version is what add requires: a version, a query such as "latest"
+	// or "v1.2", a branch or a commit. Defaults to "latest".
+	Version string `json:"version,omitempty"`
+	// Patch limits upgrade to patch releases.
+	Patch bool `json:"patch,omitempty"`
+}
+
+// FileChange is the change to go.mod or go.sum made by an action.
+type FileChange struct {
+	// Path is relative to the workspace root.
+	Path  string      `json:"path"`
+	Hunks []diff.Hunk `json:"hunks"`
+}
+
+// Result reports an action.
+type Result struct {
+	Command      string        `json:"command"`
+	Output       string        `json:"output"`
+	Changes      []FileChange  `json:"changes"`
+	Dependencies *Dependencies `json:"dependencies"`
+}
+
+// Service lists and changes the dependencies of workspace modules.
+type Service struct {
+	cfg      Config
+	launcher Launcher
+
+	mu      sync.Mutex
+	running map[string]bool // workspace ID and module
+}
+
+// NewService returns a Service, filling unset Config fields with defaults.
+func NewService(cfg Config, l Launcher) *Service {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	return &Service{cfg: cfg, launcher: l, running: make(map[string]bool)}
+}
+
+// cleanModule validates a module directory and returns it in canonical
+// form.
+func cleanModule(m string) (string, error) {
+	if m == "" {
+		return ".", nil
+	}
+	c := path.Clean(strings.TrimPrefix(m, "./"))
+	if path.IsAbs(c) || c == ".." || strings.HasPrefix(c, "../") || strings.HasPrefix(c, "-") ||
+		strings.ContainsFunc(c, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) || r == '\\' }) {
+		return "", fmt.Errorf("%w: module %q", ErrInvalidRequest, m)
+	}
+	return c, nil
+}
+
+// checkPath validates a dependency's module path for the go command line.
+func checkPath(p string) error {
+	if p == "" || strings.HasPrefix(p, "-") || strings.HasPrefix(p, "/") ||
+		strings.ContainsFunc(p, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) || r == '@' || r == '\\' }) {
+		return fmt.Errorf("%w: module path %q", ErrInvalidRequest, p)
+	}
+	return nil
+}
+
+// checkVersion validates a version query.
+func checkVersion(v string) error {
+	if v == "" || strings.HasPrefix(v, "-") ||
+		strings.ContainsFunc(v, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(".-+_<>=/", r))
+		}) {
+		return fmt.Errorf("%w: version %q", ErrInvalidRequest, v)
+	}
+	return nil
+}
+
+func (s *Service) files(dir, module string) (mod, sum []byte, err error) {
+	base := filepath.Join(dir, filepath.FromSlash(module))
+	mod, err = os.ReadFile(filepath.Join(base, "go.mod"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNoModule, module)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("gomod: read go.mod: %w", err)
+	}
+	sum, err = os.ReadFile(filepath.Join(base, "go.sum"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("gomod: read go.sum: %w", err)
+	}
+	return mod, sum, nil
+}
+
+// List returns the dependencies of a module of the workspace at dir, or
+// without one in a workspace with a go.work, those of every module it
+// uses. With updates, it asks the go command for the build list and the
+// latest version of each module, which needs network access to the
+// module proxy; otherwise only go.mod and go.sum are read.
+func (s *Service) List(ctx context.Context, workspaceID, dir, module string, updates bool) (*Dependencies, error) {
+	if module == "" {
+		w, err := ReadWork(dir)
+		if err != nil {
+			return nil, err
+		}
+		if w != nil {
+			return s.listWork(ctx, workspaceID, dir, w, updates)
+		}
+	}
+	module, err := cleanModule(module)
+	if err != nil {
+		return nil, err
+	}
+	mod, sum, err := s.files(dir, module)
+	if err != nil {
+		return nil, err
+	}
+	deps := dependencies(module, mod, sum)
+	if !updates {
+		return deps, nil
+	}
+	release, err := s.acquire(workspaceID, module)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	out, err := s.run(ctx, workspaceID, dir, goScript, []string{module, "list", "-m", "-u", "-json", "all"}, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := mergeBuildList(deps, out); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+// listWork merges the dependencies of the modules w uses. In workspace
+// mode the go command checks hashes against every module's go.sum and
+// go.work.sum, and go.work's replace directives win over the modules'.
+func (s *Service) listWork(ctx context.Context, workspaceID, dir string, w *WorkFile, updates bool) (*Dependencies, error) {
+	mods := make([][]byte, len(w.Uses))
+	sums, err := os.ReadFile(filepath.Join(dir, "go.work.sum"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("gomod: read go.work.sum: %w", err)
+	}
+	mains := make(map[string]bool)
+	for i, u := range w.Uses {
+		mod, sum, err := s.files(dir, u.Dir)
+		if err != nil {
+			return nil, err
+		}
+		mods[i] = mod
+		sums = append(append(sums, '\n'), sum...)
+		mains[u.Path] = true
+	}
+
+	deps := &Dependencies{
+		Work:      "go.work",
+		Modules:   []string{},
+		Go:        w.Go,
+		Toolchain: w.Toolchain,
+		Direct:    []Dependency{},
+		Indirect:  []Dependency{},
+	}
+	work := &File{Replaces: w.Replaces}
+	summed := summedModules(sums)
+	merged := make(map[string]*Dependency)
+	direct := make(map[string]bool)
+	var order []string
+	for i, u := range w.Uses {
+		deps.Modules = append(deps.Modules, u.Dir)
+		md := dependencies(u.Dir, mods[i], sums)
+		for _, d := range slices.Concat(md.Direct, md.Indirect) {
+			if mains[d.Path] {
+				continue
+			}
+			d.Line = 0
+			if rep, ok := work.Replacement(d.Path, d.Version); ok {
+				d.Replace = &rep.New
+				d.Sum = rep.New.Version == "" || summed[rep.New.Path+"@"+rep.New.Version]
+			}
+			e := merged[d.Path]
+			switch {
+			case e == nil:
+				d.Modules = []string{u.Dir}
+				merged[d.Path] = &d
+				order = append(order, d.Path)
+			case CompareVersions(d.Version, e.Version) > 0:
+				d.Modules = append(e.Modules, u.Dir)
+				*e = d
+			default:
+				e.Modules = append(e.Modules, u.Dir)
+			}
+		}
+		for _, d := range md.Direct {
+			direct[d.Path] = true
+		}
+	}
+	for _, p := range order {
+		if direct[p] {
+			deps.Direct = append(deps.Direct, *merged[p])
+		} else {
+			deps.Indirect = append(deps.Indirect, *merged[p])
+		}
+	}
+	sortDeps(deps)
+	if !updates {
+		return deps, nil
+	}
+	release, err := s.acquire(workspaceID, "go.work")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	out, err := s.run(ctx, workspaceID, dir, goScript, []string{".", "list", "-m", "-u", "-json", "all"}, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := mergeBuildList(deps, out); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+// summedModules returns the path@version of each module whose content
+// hash a go.sum has.
+func summedModules(sum []byte) map[string]bool {
+	summed := make(map[string]bool)
+	for _, s := range ParseSum(sum) {
+		if !s.GoMod {
+			summed[s.Path+"@"+s.Version] = true
+		}
+	}
+	return summed
+}
+
+// dependencies lists what go.mod requires and replaces.
+func dependencies(module string, mod, sum []byte) *Dependencies {
+	f := Parse(mod)
+	summed := summedModules(sum)
+	deps := &Dependencies{
+		Module:    module,
+		Path:      f.Module,
+		Go:        f.Go,
+		Toolchain: f.Toolchain,
+		Direct:    []Dependency{},
+		Indirect:  []Dependency{},
+	}
+	for _, r := range f.Requires {
+		d := Dependency{Path: r.Path, Version: r.Version, Line: r.Line, Sum: summed[r.Path+"@"+r.Version]}
+		if rep, ok := f.Replacement(r.Path, r.Version); ok {
+			d.Replace = &rep.New
+			if rep.New.Version != "" {
+				d.Sum = summed[rep.New.Path+"@"+rep.New.Version]
+			} else {
+				d.Sum = true // directories have no hash
+			}
+		}
+		if r.Indirect {
+			deps.Indirect = append(deps.Indirect, d)
+		} else {
+			deps.Direct = append(deps.Direct, d)
+		}
+	}
+	sortDeps(deps)
+	return deps
+}
+
+func sortDeps(deps *Dependencies) {
+	byPath := func(a, b Dependency) int { return strings.Compare(a.Path, b.Path) }
+	slices.SortFunc(deps.Direct, byPath)
+	slices.SortFunc(deps.Indirect, byPath)
+}
+
+// listed is a module in the output of go list -m -json.
+type listed struct {
+	Path       string
+	Version    string
+	Main       bool
+	Indirect   bool
+	Update     *struct{ Version string }
+	Replace    *struct{ Path, Version string }
+	Deprecated string
+	Retracted  []string
+}
+
+// mergeBuildList adds what go list -m -u -json all reports to deps:
+// updates, deprecations and retractions of required modules, and the
+// modules only the build list has as indirect dependencies.
+func mergeBuildList(deps *Dependencies, out []byte) error {
+	index := make(map[string]*Dependency)
+	for i := range deps.Direct {
+		index[deps.Direct[i].Path] = &deps.Direct[i]
+	}
+	for i := range deps.Indirect {
+		index[deps.Indirect[i].Path] = &deps.Indirect[i]
+	}
+	var extra []Dependency
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var m listed
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("gomod: read go list output: %w", err)
+		}
+		if m.Main {
+			continue
+		}
+		d := index[m.Path]
+		if d == nil {
+			// go.mod files from before Go 1.17 leave out indirect
+			// dependencies, so the build list has more modules.
+			extra = append(extra, Dependency{Path: m.Path, Version: m.Version})
+			d = &extra[len(extra)-1]
+			if m.Replace != nil {
+				d.Replace = &Module{Path: m.Replace.Path, Version: m.Replace.Version}
+			}
+		}
+		if m.Update != nil && CompareVersions(m.Update.Version, d.Version) > 0 {
+			d.Update = m.Update.Version
+		}
+		d.Deprecated, d.Retracted = m.Deprecated, m.Retracted
+	}
+	deps.Indirect = append(deps.Indirect, extra...)
+	deps.Updates = true
+	sortDeps(deps)
+	return nil
+}
+
+// Add requires req.Path at req.Version.
+func (s *Service) Add(ctx context.Context, workspaceID, dir string, req Request) (*Result, error) {
+	if err := checkPath(req.Path); err != nil {
+		return nil, err
+	}
+	v := req.Version
+	if v == "" {
+		v = "latest"
+	}
+	if err := checkVersion(v); err != nil {
+		return nil, err
+	}
+	return s.change(ctx, workspaceID, dir, req.Module, []string{"get", req.Path + "@" + v})
+}
+
+// Upgrade upgrades req.Path to its latest version, or its latest patch
+// release with req.Patch. Without a path it upgrades the dependencies of
+// every package of the module.
+func (s *Service) Upgrade(ctx context.Context, workspaceID, dir string, req Request) (*Result, error) {
+	if req.Path == "" {
+		flag := "-u"
+		if req.Patch {
+			flag = "-u=patch"
+		}
+		return s.change(ctx, workspaceID, dir, req.Module, []string{"get", flag, "./..."})
+	}
+	if err := checkPath(req.Path); err != nil {
+		return nil, err
+	}
+	query := "latest"
+	if req.Patch {
+		query = "patch"
+	}
+	return s.change(ctx, workspaceID, dir, req.Module, []string{"get", req.Path + "@" + query})
+}
+
+// Remove drops the requirement on req.Path, downgrading what needs it.
+func (s *Service) Remove(ctx context.Context, workspaceID, dir string, req Request) (*Result, error) {
+	if err := checkPath(req.Path); err != nil {
+		return nil, err
+	}
+	return s.change(ctx, workspaceID, dir, req.Module, []string{"get", req.Path + "@none"})
+}
+
+// Tidy runs go mod tidy.
+func (s *Service) Tidy(ctx context.Context, workspaceID, dir string, req Request) (*Result, error) {
+	return s.change(ctx, workspaceID, dir, req.Module, []string{"mod", "tidy"})
+}
+
+// change runs a go command that edits go.mod and go.sum and reports the
+// edits. If the command fails, both files are put back as they were.
+func (s *Service) change(ctx context.Context, workspaceID, dir, module string, args []string) (*Result, error) {
+	module, err := cleanModule(module)
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.acquire(workspaceID, module)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	oldMod, oldSum, err := s.files(dir, module)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.run(ctx, workspaceID, dir, goScript, append([]string{module}, args...), true)
+	if err != nil {
+		if rerr := restore(dir, module, oldMod, oldSum); rerr != nil {
+			return nil, errors.Join(err, rerr)
+		}
+		return nil, err
+	}
+	newMod, newSum, err := s.files(dir, module)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{
+		Command:      "go " + strings.Join(args, " "),
+		Output:       string(out),
+		Changes:      []FileChange{},
+		Dependencies: dependencies(module, newMod, newSum),
+	}
+	for _, f := range []struct {
+		name     string
+		old, new []byte
+	}{{"go.mod", oldMod, newMod}, {"go.sum", oldSum, newSum}} {
+		if !bytes.Equal(f.old, f.new) {
+			res.Changes = append(res.Changes, FileChange{
+				Path:  path.Join(module, f.name),
+				Hunks: diff.Lines(string(f.old), string(f.new), 3),
+			})
+		}
+	}
+	return res, nil
+}
+
+// restore writes back the go.mod and go.sum a failed command may have
+// changed, removing a go.sum that did not exist.
+func restore(dir, module string, mod, sum []byte) error {
+	base := filepath.Join(dir, filepath.FromSlash(module))
+	if err := os.WriteFile(filepath.Join(base, "go.mod"), mod, 0o644); err != nil {
+		return fmt.Errorf("gomod: restore go.mod: %w", err)
+	}
+	p := filepath.Join(base, "go.sum")
+	if sum == nil {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("gomod: restore go.sum: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(p, sum, 0o644); err != nil {
+		return fmt.Errorf("gomod: restore go.sum: %w", err)
+	}
+	return nil
+}
+
+// acquire marks a go command as running on the module until release is
+// called.
+func (s *Service) acquire(workspaceID, module string) (release func(), err error) {
+	key := workspaceID + "\x00" + module
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[key] {
+		return nil, ErrBusy
+	}
+	s.running[key] = true
+	return func() {
+		s.mu.Lock()
+		delete(s.running, key)
+		s.mu.Unlock()
+	}, nil
+}
+
+// goScript runs the go command in the module directory $1.
+const goScript = `cd "$1" || exit 2
+shift
+exec go "$@"`
+
+// maxOutput caps the output kept of a go command that edits the module,
+// and the error output of any. Other output is read in full.
+const maxOutput = 64 << 10
+
+// run runs script with args in the workspace's environment and returns
+// its output: stdout, or with combined stdout and stderr together, as go
+// get and go mod tidy report on stderr.
+func (s *Service) run(ctx context.Context, workspaceID, dir, script string, args []string, combined bool) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	argv := append([]string{"sh", "-c", script, "sh"}, args...)
+	cmd, err := s.launcher.Exec(ctx, workspaceID, dir, argv, nil)
+	if err != nil {
+		return nil, err
+	}
+	var stdout bytes.Buffer
+	var stderr limitedBuffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if combined {
+		cmd.Stdout = &stderr
+	}
+	cmd.WaitDelay = 3 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("gomod: start go: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+
+	err = cmd.Wait()
+	switch {
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("%w: timed out", ErrFailed)
+	case err != nil:
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrFailed, msg)
+	}
+	if combined {
+		return stderr.Bytes(), nil
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer keeps the first maxOutput bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxOutput - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,112 @@
+package gomod
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WorkFile is the parsed content of a go.work file. Line numbers are
+// 1-based.
+type WorkFile struct {
+	Go        string    `json:"go,omitempty"`
+	Toolchain string    `json:"toolchain,omitempty"`
+	Uses      []Use     `json:"uses"`
+	Replaces  []Replace `json:"replaces,omitempty"`
+}
+
+// Use is a use directive: a module of the workspace.
+type Use struct {
+	// Dir is the module's directory as written, relative to the go.work.
+	Dir string `json:"dir"`
+	// Path is the module path its go.mod declares; ReadWork fills it in.
+	Path string `json:"path,omitempty"`
+	Line int    `json:"line"`
+}
+
+// ParseWork reads a go.work file, as leniently as Parse reads go.mod.
+func ParseWork(data []byte) *WorkFile {
+	w := &WorkFile{Uses: []Use{}}
+	directives(data, func(verb string, fields []string, _, _ string, ln int) {
+		switch verb {
+		case "go":
+			if len(fields) == 1 {
+				w.Go = fields[0]
+			}
+		case "toolchain":
+			if len(fields) == 1 {
+				w.Toolchain = fields[0]
+			}
+		case "use":
+			if len(fields) == 1 {
+				w.Uses = append(w.Uses, Use{Dir: unquote(fields[0]), Line: ln})
+			}
+		case "replace":
+			if r, ok := parseReplace(fields); ok {
+				r.Line = ln
+				w.Replaces = append(w.Replaces, r)
+			}
+		}
+	})
+	return w
+}
+
+// ReadWork reads the go.work file at the root of the workspace at dir,
+// returning nil when there is none. Use directories are cleaned to be
+// relative to the root, and those outside it or without a go.mod are
+// left out, as files outside the workspace cannot be reached.
+func ReadWork(dir string) (*WorkFile, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.work"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gomod: read go.work: %w", err)
+	}
+	w := ParseWork(data)
+	uses := w.Uses[:0]
+	for _, u := range w.Uses {
+		d := path.Clean(strings.TrimPrefix(filepath.ToSlash(u.Dir), "./"))
+		if path.IsAbs(d) || d == ".." || strings.HasPrefix(d, "../") {
+			continue
+		}
+		mod, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(d), "go.mod"))
+		if err != nil {
+			continue
+		}
+		u.Dir, u.Path = d, Parse(mod).Module
+		uses = append(uses, u)
+	}
+	w.Uses = uses
+	return w, nil
+}
+
+// Patterns returns the package patterns matching every package of the
+// workspace's modules, for go commands run at its root: ./... only
+// matches packages of the module containing the current directory, and
+// the root of a workspace is often in none.
+func (w *WorkFile) Patterns() []string {
+	out := make([]string, 0, len(w.Uses))
+	for _, u := range w.Uses {
+		if u.Dir == "." {
+			out = append(out, "./...")
+		} else {
+			out = append(out, "./"+u.Dir+"/...")
+		}
+	}
+	return out
+}
+
+// Module returns the use directive of the module in directory d,
+// relative to the workspace root.
+func (w *WorkFile) Module(d string) (Use, bool) {
+	for _, u := range w.Uses {
+		if u.Dir == d {
+			return u, true
+		}
+	}
+	return Use{}, false
+}
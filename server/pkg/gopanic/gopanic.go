@@ -0,0 +1,179 @@
+// Package gopanic parses the report the Go runtime prints to stderr when a
+// program panics or stops with a fatal error: the panic value, then the
+// stack of each goroutine it was asked to show.
+package gopanic
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Panic is a parsed panic or fatal error report.
+type Panic struct {
+	// Message is the panic value or fatal error, without its "panic: "
+	// or "fatal error: " prefix. A panic raised while another was
+	// unwinding follows on its own line, as the runtime prints it.
+	Message string `json:"message"`
+	// Fatal reports a fatal error, such as a deadlock or concurrent map
+	// writes, which unlike a panic cannot be recovered.
+	Fatal bool `json:"fatal,omitempty"`
+	// Signal is the signal a fault was raised for, such as
+	// "SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47e4a4".
+	Signal string `json:"signal,omitempty"`
+	// Goroutines are the stacks in the report, the goroutine that
+	// panicked first.
+	Goroutines []Goroutine `json:"goroutines"`
+}
+
+// Goroutine is the stack of one goroutine.
+type Goroutine struct {
+	ID int `json:"id"`
+	// State is what the goroutine was doing, such as "running" or
+	// "chan receive, 2 minutes".
+	State string `json:"state"`
+	// Frames are its calls, innermost first.
+	Frames []Frame `json:"frames"`
+	// Elided reports that the runtime left out frames of a deep stack.
+	Elided bool `json:"elided,omitempty"`
+	// CreatedBy is the go statement that started the goroutine, and
+	// Parent the goroutine that ran it, when the runtime reports one.
+	CreatedBy *Frame `json:"createdBy,omitempty"`
+	Parent    int    `json:"parent,omitempty"`
+}
+
+// Frame is one call: the function and the line of its source being run.
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+var (
+	goroutinePattern = regexp.MustCompile(`^goroutine (\d+)(?: [^\[]*)? \[([^\]]*)\]:$`)
+	locationPattern  = regexp.MustCompile(`^\t(.+?):(\d+)(?: \+0x[0-9a-f]+)?(?: .*)?$`)
+	createdByPattern = regexp.MustCompile(`^created by (\S+)(?: in goroutine (\d+))?$`)
+)
+
+// Parse finds the last panic or fatal error report in out, which may hold
+// whatever else the program wrote to stderr before it. It returns nil if
+// there is none with at least one goroutine stack.
+func Parse(out string) *Panic {
+	lines := strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			start = i
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	p := &Panic{}
+	var msg []string
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if i == start {
+			if rest, ok := strings.CutPrefix(line, "fatal error: "); ok {
+				p.Fatal = true
+				line = rest
+			} else {
+				line = strings.TrimPrefix(line, "panic: ")
+			}
+		}
+		if line == "" || goroutinePattern.MatchString(line) {
+			break
+		}
+		if sig, ok := strings.CutPrefix(line, "[signal "); ok && strings.HasSuffix(sig, "]") {
+			p.Signal = strings.TrimSuffix(sig, "]")
+			continue
+		}
+		if i > start {
+			line = strings.TrimPrefix(line, "\tpanic: ")
+		}
+		msg = append(msg, line)
+	}
+	p.Message = strings.Join(msg, "\n")
+
+	var g *Goroutine
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := goroutinePattern.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			p.Goroutines = append(p.Goroutines, Goroutine{ID: id, State: m[2], Frames: []Frame{}})
+			g = &p.Goroutines[len(p.Goroutines)-1]
+			continue
+		}
+		if g == nil {
+			continue
+		}
+		switch {
+		case line == "":
+			g = nil
+		case strings.HasPrefix(line, "...") && strings.Contains(line, "frames elided"):
+			g.Elided = true
+		case strings.HasPrefix(line, "created by "):
+			m := createdByPattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			f := Frame{Function: m[1]}
+			if i+1 < len(lines) {
+				f.File, f.Line, _ = location(lines[i+1])
+			}
+			g.CreatedBy = &f
+			g.Parent, _ = strconv.Atoi(m[2])
+			i++
+		case !strings.HasPrefix(line, "\t"):
+			// A call is followed by its location; anything else ends the
+			// stack.
+			f := Frame{Function: function(line)}
+			var ok bool
+			if i+1 < len(lines) {
+				f.File, f.Line, ok = location(lines[i+1])
+			}
+			if !ok {
+				g = nil
+				continue
+			}
+			g.Frames = append(g.Frames, f)
+			i++
+		}
+	}
+	if len(p.Goroutines) == 0 {
+		return nil
+	}
+	return p
+}
+
+// function returns the function of a frame's call line, such as
+// "main.(*T).run" of "main.(*T).run(0xc000012345, {0x4b2f20, 0x3})".
+func function(line string) string {
+	if !strings.HasSuffix(line, ")") {
+		return line
+	}
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ')':
+			depth++
+		case '(':
+			if depth--; depth == 0 {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// location parses the indented "file:line +0x1d" line after a call.
+func location(line string) (file string, n int, ok bool) {
+	m := locationPattern.FindStringSubmatch(line)
+	if m == nil {
+		return "", 0, false
+	}
+	n, _ = strconv.Atoi(m[2])
+	return m[1], n, true
+}
@@ -0,0 +1,112 @@
+package pprof
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Node is a flame graph node: the total Value of the samples passing
+// through Name, split among the calls it made. The shape matches what
+// d3-flame-graph and speedscope-style viewers consume.
+type Node struct {
+	Name     string  `json:"name"`
+	File     string  `json:"file,omitempty"`
+	Value    int64   `json:"value"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Func is a function's share of a sample type: Flat counts samples with
+// it at the leaf, Cum samples with it anywhere on the stack.
+type Func struct {
+	Function string `json:"function"`
+	File     string `json:"file,omitempty"`
+	Flat     int64  `json:"flat"`
+	Cum      int64  `json:"cum"`
+}
+
+// Flame merges the stacks of p into a tree rooted at "root", weighted by
+// sample type i. Children are ordered by name, so equal profiles give
+// equal trees.
+func (p *Profile) Flame(i int) *Node {
+	root := &Node{Name: "root"}
+	index := map[*Node]map[string]*Node{}
+	for _, s := range p.Samples {
+		v := s.Values[i]
+		if v == 0 {
+			continue
+		}
+		root.Value += v
+		n := root
+		for j := len(s.Stack) - 1; j >= 0; j-- {
+			f := s.Stack[j]
+			kids := index[n]
+			if kids == nil {
+				kids = make(map[string]*Node)
+				index[n] = kids
+			}
+			c := kids[f.Function]
+			if c == nil {
+				c = &Node{Name: f.Function, File: f.File}
+				kids[f.Function] = c
+				n.Children = append(n.Children, c)
+			}
+			c.Value += v
+			n = c
+		}
+	}
+	sortNodes(root)
+	return root
+}
+
+func sortNodes(n *Node) {
+	slices.SortFunc(n.Children, func(a, b *Node) int { return cmp.Compare(a.Name, b.Name) })
+	for _, c := range n.Children {
+		sortNodes(c)
+	}
+}
+
+// Top returns the functions of p by descending flat value of sample type
+// i, at most n of them when n > 0.
+func (p *Profile) Top(i, n int) []Func {
+	byName := map[string]*Func{}
+	for _, s := range p.Samples {
+		v := s.Values[i]
+		if v == 0 {
+			continue
+		}
+		// Recursive calls appear several times in one stack but count
+		// once towards the cumulative value.
+		seen := make(map[string]bool, len(s.Stack))
+		for j, f := range s.Stack {
+			fn := byName[f.Function]
+			if fn == nil {
+				fn = &Func{Function: f.Function, File: f.File}
+				byName[f.Function] = fn
+			}
+			if j == 0 {
+				fn.Flat += v
+			}
+			if !seen[f.Function] {
+				seen[f.Function] = true
+				fn.Cum += v
+			}
+		}
+	}
+	out := make([]Func, 0, len(byName))
+	for _, fn := range byName {
+		out = append(out, *fn)
+	}
+	slices.SortFunc(out, func(a, b Func) int {
+		if c := cmp.Compare(b.Flat, a.Flat); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Cum, a.Cum); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Function, b.Function)
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
@@ -0,0 +1,391 @@
+// Package pprof decodes profiles in the pprof protocol buffer format, as
+// written by runtime/pprof, and summarizes them as flame graphs and
+// per-function tables. Only the parts of the format needed for that are
+// decoded: sample types, samples, locations and functions.
+package pprof
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ValueType describes one value of every sample, such as "cpu" in
+// "nanoseconds".
+type ValueType struct {
+	Type string `json:"type"`
+	Unit string `json:"unit"`
+}
+
+// Frame is one call in a stack.
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file,omitempty"`
+	Line     int64  `json:"line,omitempty"`
+}
+
+// Sample is a stack, leaf first, with one value per sample type.
+type Sample struct {
+	Stack  []Frame
+	Values []int64
+}
+
+// Profile is a decoded profile.
+type Profile struct {
+	SampleTypes []ValueType
+	Samples     []Sample
+	// DefaultSampleType names the sample type to show first; it may be
+	// empty.
+	DefaultSampleType string
+	DurationNanos     int64
+	Period            int64
+	PeriodType        ValueType
+}
+
+// ErrInvalid is returned for data that is not a pprof profile.
+var ErrInvalid = errors.New("pprof: invalid profile")
+
+// Parse decodes a profile, gzip-compressed or not.
+func Parse(r io.Reader) (*Profile, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return decode(data)
+}
+
+// SampleIndex returns the index of the named sample type. An empty name
+// selects the default sample type, or the last one.
+func (p *Profile) SampleIndex(name string) (int, error) {
+	if len(p.SampleTypes) == 0 {
+		return 0, fmt.Errorf("%w: no sample types", ErrInvalid)
+	}
+	if name == "" {
+		name = p.DefaultSampleType
+	}
+	if name == "" {
+		return len(p.SampleTypes) - 1, nil
+	}
+	for i, t := range p.SampleTypes {
+		if t.Type == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("pprof: no sample type %q", name)
+}
+
+// Messages and fields of profile.proto that decode reads.
+type rawProfile struct {
+	sampleTypes    []rawValueType
+	samples        []rawSample
+	locations      map[uint64][]rawLine
+	functions      map[uint64]rawFunction
+	strings        []string
+	defaultSample  int64
+	durationNanos  int64
+	period         int64
+	periodType     rawValueType
+	hasPeriodType  bool
+	hasDefaultType bool
+}
+
+type rawValueType struct{ typ, unit int64 }
+
+type rawSample struct {
+	locations []uint64
+	values    []int64
+}
+
+type rawLine struct {
+	function uint64
+	line     int64
+}
+
+type rawFunction struct{ name, file int64 }
+
+func decode(data []byte) (*Profile, error) {
+	raw := rawProfile{locations: make(map[uint64][]rawLine), functions: make(map[uint64]rawFunction)}
+	err := fields(data, func(num int, b buffer) error {
+		var err error
+		switch num {
+		case 1:
+			var vt rawValueType
+			vt, err = decodeValueType(b.data)
+			raw.sampleTypes = append(raw.sampleTypes, vt)
+		case 2:
+			var s rawSample
+			s, err = decodeSample(b.data)
+			raw.samples = append(raw.samples, s)
+		case 4:
+			err = decodeLocation(b.data, raw.locations)
+		case 5:
+			err = decodeFunction(b.data, raw.functions)
+		case 6:
+			raw.strings = append(raw.strings, string(b.data))
+		case 10:
+			raw.durationNanos = int64(b.u64)
+		case 11:
+			raw.periodType, err = decodeValueType(b.data)
+			raw.hasPeriodType = true
+		case 12:
+			raw.period = int64(b.u64)
+		case 14:
+			raw.defaultSample, raw.hasDefaultType = int64(b.u64), true
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(raw.strings) == 0 || raw.strings[0] != "" {
+		return nil, fmt.Errorf("%w: bad string table", ErrInvalid)
+	}
+	return raw.resolve()
+}
+
+// resolve replaces string and ID references with their values.
+func (raw *rawProfile) resolve() (*Profile, error) {
+	str := func(i int64) (string, error) {
+		if i < 0 || i >= int64(len(raw.strings)) {
+			return "", fmt.Errorf("%w: string index %d out of range", ErrInvalid, i)
+		}
+		return raw.strings[i], nil
+	}
+	valueType := func(vt rawValueType) (ValueType, error) {
+		t, err := str(vt.typ)
+		if err != nil {
+			return ValueType{}, err
+		}
+		u, err := str(vt.unit)
+		return ValueType{Type: t, Unit: u}, err
+	}
+
+	p := &Profile{DurationNanos: raw.durationNanos, Period: raw.period}
+	for _, vt := range raw.sampleTypes {
+		t, err := valueType(vt)
+		if err != nil {
+			return nil, err
+		}
+		p.SampleTypes = append(p.SampleTypes, t)
+	}
+	var err error
+	if raw.hasPeriodType {
+		if p.PeriodType, err = valueType(raw.periodType); err != nil {
+			return nil, err
+		}
+	}
+	if raw.hasDefaultType {
+		if p.DefaultSampleType, err = str(raw.defaultSample); err != nil {
+			return nil, err
+		}
+	}
+
+	frames := make(map[uint64][]Frame, len(raw.locations))
+	for id, lines := range raw.locations {
+		fs := make([]Frame, 0, len(lines))
+		for _, l := range lines {
+			fn, ok := raw.functions[l.function]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown function %d", ErrInvalid, l.function)
+			}
+			f := Frame{Line: l.line}
+			if f.Function, err = str(fn.name); err != nil {
+				return nil, err
+			}
+			if f.File, err = str(fn.file); err != nil {
+				return nil, err
+			}
+			fs = append(fs, f)
+		}
+		frames[id] = fs
+	}
+	for _, rs := range raw.samples {
+		if len(rs.values) != len(p.SampleTypes) {
+			return nil, fmt.Errorf("%w: sample has %d values for %d types", ErrInvalid, len(rs.values), len(p.SampleTypes))
+		}
+		s := Sample{Values: rs.values}
+		for _, id := range rs.locations {
+			fs, ok := frames[id]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown location %d", ErrInvalid, id)
+			}
+			// A location's lines run from the innermost inlined call
+			// outwards, which matches the leaf-first stack order.
+			s.Stack = append(s.Stack, fs...)
+		}
+		p.Samples = append(p.Samples, s)
+	}
+	return p, nil
+}
+
+func decodeValueType(data []byte) (rawValueType, error) {
+	var vt rawValueType
+	err := fields(data, func(num int, b buffer) error {
+		switch num {
+		case 1:
+			vt.typ = int64(b.u64)
+		case 2:
+			vt.unit = int64(b.u64)
+		}
+		return nil
+	})
+	return vt, err
+}
+
+func decodeSample(data []byte) (rawSample, error) {
+	var s rawSample
+	err := fields(data, func(num int, b buffer) error {
+		switch num {
+		case 1:
+			return b.uints(func(v uint64) { s.locations = append(s.locations, v) })
+		case 2:
+			return b.uints(func(v uint64) { s.values = append(s.values, int64(v)) })
+		}
+		return nil
+	})
+	return s, err
+}
+
+func decodeLocation(data []byte, locations map[uint64][]rawLine) error {
+	var id uint64
+	var lines []rawLine
+	err := fields(data, func(num int, b buffer) error {
+		switch num {
+		case 1:
+			id = b.u64
+		case 4:
+			var l rawLine
+			err := fields(b.data, func(num int, b buffer) error {
+				switch num {
+				case 1:
+					l.function = b.u64
+				case 2:
+					l.line = int64(b.u64)
+				}
+				return nil
+			})
+			lines = append(lines, l)
+			return err
+		}
+		return nil
+	})
+	locations[id] = lines
+	return err
+}
+
+func decodeFunction(data []byte, functions map[uint64]rawFunction) error {
+	var id uint64
+	var fn rawFunction
+	err := fields(data, func(num int, b buffer) error {
+		switch num {
+		case 1:
+			id = b.u64
+		case 2:
+			fn.name = int64(b.u64)
+		case 4:
+			fn.file = int64(b.u64)
+		}
+		return nil
+	})
+	functions[id] = fn
+	return err
+}
+
+// buffer is one decoded protobuf field: a varint or fixed value in u64,
+// or the bytes of a length-delimited field in data.
+type buffer struct {
+	wire int
+	u64  uint64
+	data []byte
+}
+
+// uints calls fn for a repeated integer field, packed or not.
+func (b buffer) uints(fn func(uint64)) error {
+	if b.wire != 2 {
+		fn(b.u64)
+		return nil
+	}
+	for data := b.data; len(data) > 0; {
+		v, n := varint(data)
+		if n == 0 {
+			return fmt.Errorf("%w: bad packed field", ErrInvalid)
+		}
+		fn(v)
+		data = data[n:]
+	}
+	return nil
+}
+
+// fields calls fn for each field of the encoded message data.
+func fields(data []byte, fn func(num int, b buffer) error) error {
+	for len(data) > 0 {
+		key, n := varint(data)
+		if n == 0 {
+			return fmt.Errorf("%w: bad field key", ErrInvalid)
+		}
+		data = data[n:]
+		b := buffer{wire: int(key & 7)}
+		switch b.wire {
+		case 0:
+			if b.u64, n = varint(data); n == 0 {
+				return fmt.Errorf("%w: bad varint", ErrInvalid)
+			}
+		case 1:
+			if n = 8; len(data) < n {
+				return fmt.Errorf("%w: truncated fixed64", ErrInvalid)
+			}
+			for i := 7; i >= 0; i-- {
+				b.u64 = b.u64<<8 | uint64(data[i])
+			}
+		case 2:
+			size, m := varint(data)
+			if m == 0 || size > uint64(len(data)-m) {
+				return fmt.Errorf("%w: truncated field", ErrInvalid)
+			}
+			b.data = data[m : m+int(size)]
+			n = m + int(size)
+		case 5:
+			if n = 4; len(data) < n {
+				return fmt.Errorf("%w: truncated fixed32", ErrInvalid)
+			}
+			for i := 3; i >= 0; i-- {
+				b.u64 = b.u64<<8 | uint64(data[i])
+			}
+		default:
+			return fmt.Errorf("%w: wire type %d", ErrInvalid, b.wire)
+		}
+		data = data[n:]
+		if err := fn(int(key>>3), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varint decodes a base-128 varint, returning n == 0 on malformed input.
+func varint(data []byte) (v uint64, n int) {
+	for i, c := range data {
+		if i == 10 {
+			return 0, 0
+		}
+		v |= uint64(c&0x7f) << (7 * i)
+		if c < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}